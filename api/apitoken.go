@@ -0,0 +1,149 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// apiTokenUser returns the email of the user whose api tokens are handled by
+// the request, the one in the user parameter or the authenticated one. Named
+// api tokens can't be used to handle api tokens, otherwise a restricted
+// token could create unrestricted ones.
+func apiTokenUser(r *http.Request, t auth.Token, scheme *permission.PermissionScheme) (string, error) {
+	if _, ok := t.(*auth.NamedAPIToken); ok {
+		return "", &errors.HTTP{Code: http.StatusForbidden, Message: "api tokens can't be used to manage api tokens"}
+	}
+	email := r.URL.Query().Get("user")
+	if email == "" {
+		email = t.GetUserName()
+	}
+	if !permission.Check(t, scheme, permission.Context(permission.CtxUser, email)) {
+		return "", permission.ErrUnauthorized
+	}
+	return email, nil
+}
+
+// title: create api token
+// path: /tokens
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Token created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: User not found
+//   409: Token already exists
+func createAPIToken(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	email, err := apiTokenUser(r, t, permission.PermUserUpdateTokenCreate)
+	if err != nil {
+		return err
+	}
+	opts := auth.NamedAPITokenOpts{
+		Name:               r.FormValue("name"),
+		Description:        r.FormValue("description"),
+		AllowedPermissions: r.Form["permission"],
+		AllowedContexts:    r.Form["context"],
+	}
+	if expires := r.FormValue("expires"); expires != "" {
+		opts.ExpiresIn, err = time.ParseDuration(expires)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid expires duration: " + err.Error()}
+		}
+	}
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(email),
+		Kind:       permission.PermUserUpdateTokenCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	token, err := auth.CreateNamedAPIToken(u, opts)
+	if err == auth.ErrAPITokenAlreadyExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(token)
+}
+
+// title: list api tokens
+// path: /tokens
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func listAPITokens(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	email, err := apiTokenUser(r, t, permission.PermUserReadTokens)
+	if err != nil {
+		return err
+	}
+	tokens, err := auth.ListNamedAPITokens(email)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tokens)
+}
+
+// title: revoke api token
+// path: /tokens/{name}
+// method: DELETE
+// responses:
+//   200: Token revoked
+//   401: Unauthorized
+//   404: Token not found
+func revokeAPIToken(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	email, err := apiTokenUser(r, t, permission.PermUserUpdateTokenRevoke)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(email),
+		Kind:       permission.PermUserUpdateTokenRevoke,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = auth.RevokeNamedAPIToken(email, r.URL.Query().Get(":name"))
+	if err == auth.ErrAPITokenNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
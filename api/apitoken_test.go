@@ -0,0 +1,160 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *AuthSuite) TestCreateAPIToken(c *check.C) {
+	body := strings.NewReader("name=ci&description=deploys&expires=720h&permission=app.deploy")
+	request, err := http.NewRequest("POST", "/1.3/tokens", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var token auth.NamedAPIToken
+	err = json.NewDecoder(recorder.Body).Decode(&token)
+	c.Assert(err, check.IsNil)
+	c.Assert(token.Name, check.Equals, "ci")
+	c.Assert(token.Value, check.Not(check.Equals), "")
+	c.Assert(token.AllowedPermissions, check.DeepEquals, []string{"app.deploy"})
+	c.Assert(token.ExpiresAt.IsZero(), check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(s.user.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.token.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "ci"},
+			{"name": "description", "value": "deploys"},
+			{"name": "expires", "value": "720h"},
+			{"name": "permission", "value": "app.deploy"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/1.3/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.Value)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Not(check.Equals), http.StatusUnauthorized)
+}
+
+func (s *AuthSuite) TestCreateAPITokenWithContexts(c *check.C) {
+	body := strings.NewReader("name=ci&permission=app.read&context=team:" + s.team.Name)
+	request, err := http.NewRequest("POST", "/1.3/tokens", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var token auth.NamedAPIToken
+	err = json.NewDecoder(recorder.Body).Decode(&token)
+	c.Assert(err, check.IsNil)
+	c.Assert(token.AllowedContexts, check.DeepEquals, []string{"team:" + s.team.Name})
+	t, err := auth.NamedAPITokenAuth(context.Background(), "bearer "+token.Value)
+	c.Assert(err, check.IsNil)
+	c.Assert(permission.Check(t, permission.PermAppRead, permission.Context(permission.CtxTeam, s.team.Name)), check.Equals, true)
+	c.Assert(permission.Check(t, permission.PermAppRead, permission.Context(permission.CtxTeam, "otherteam")), check.Equals, false)
+	c.Assert(permission.Check(t, permission.PermAppDelete, permission.Context(permission.CtxTeam, s.team.Name)), check.Equals, false)
+}
+
+func (s *AuthSuite) TestCreateAPITokenInvalid(c *check.C) {
+	tests := []struct {
+		body string
+		code int
+		msg  string
+	}{
+		{"name=", http.StatusBadRequest, auth.ErrInvalidAPITokenName.Error()},
+		{"name=ci&expires=tomorrow", http.StatusBadRequest, `invalid expires duration: time: invalid duration "tomorrow"`},
+		{"name=ci&permission=app.nothing", http.StatusBadRequest, `invalid permission "app.nothing": unregistered permission`},
+		{"name=ci&context=planet:earth", http.StatusBadRequest, `invalid context type "planet"`},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/1.3/tokens", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, tt.code)
+		c.Check(recorder.Body.String(), check.Equals, tt.msg+"\n")
+	}
+}
+
+func (s *AuthSuite) TestCreateAPITokenWithAPITokenIsForbidden(c *check.C) {
+	token, err := auth.CreateNamedAPIToken(s.user, auth.NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.3/tokens", strings.NewReader("name=other"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.Value)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "api tokens can't be used to manage api tokens\n")
+}
+
+func (s *AuthSuite) TestListAPITokens(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/tokens", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	_, err = auth.CreateNamedAPIToken(s.user, auth.NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var tokens []auth.NamedAPIToken
+	err = json.NewDecoder(recorder.Body).Decode(&tokens)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 1)
+	c.Assert(tokens[0].Name, check.Equals, "ci")
+	c.Assert(tokens[0].Value, check.Equals, "")
+}
+
+func (s *AuthSuite) TestRevokeAPIToken(c *check.C) {
+	token, err := auth.CreateNamedAPIToken(s.user, auth.NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/1.3/tokens/ci", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(s.user.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.token.revoke",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "ci"},
+		},
+	}, eventtest.HasEvent)
+	_, err = auth.NamedAPITokenAuth(context.Background(), "bearer "+token.Value)
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
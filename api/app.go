@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ajg/form"
@@ -30,10 +31,20 @@ import (
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
+	"github.com/tsuru/tsuru/secret"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/mgo.v2/bson"
 )
 
+func init() {
+	event.SetChangeRateLimit(event.ChangeRateSpec{
+		KindName: permission.PermAppDelete.FullName(),
+		Max:      5,
+		Time:     10 * time.Minute,
+	})
+	event.SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), scheduledRestart)
+}
+
 func appTarget(appName string) event.Target {
 	return event.Target{Type: event.TargetTypeApp, Value: appName}
 }
@@ -42,9 +53,9 @@ func getAppFromContext(name string, r *http.Request) (app.App, error) {
 	var err error
 	a := context.GetApp(r)
 	if a == nil {
-		a, err = getApp(name)
+		a, err = app.GetByNameContext(r.Context(), name)
 		if err != nil {
-			return app.App{}, err
+			return app.App{}, &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", name)}
 		}
 		context.SetApp(r, a)
 	}
@@ -67,6 +78,7 @@ func getApp(name string) (*app.App, error) {
 //   200: App removed
 //   401: Unauthorized
 //   404: Not found
+//   409: Too many apps removed recently, confirmation required
 func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
@@ -79,15 +91,17 @@ func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if !canDelete {
 		return permission.ErrUnauthorized
 	}
+	confirmChangeRate, _ := strconv.ParseBool(r.URL.Query().Get("confirm-change-rate"))
 	evt, err := event.New(&event.Opts{
-		Target:     appTarget(a.Name),
-		Kind:       permission.PermAppDelete,
-		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
-		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		Target:            appTarget(a.Name),
+		Kind:              permission.PermAppDelete,
+		Owner:             t,
+		CustomData:        event.FormToCustomData(r.Form),
+		Allowed:           event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		ConfirmChangeRate: confirmChangeRate,
 	})
 	if err != nil {
-		return err
+		return changeRateError(err)
 	}
 	defer func() { evt.Done(err) }()
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
@@ -109,6 +123,7 @@ type miniApp struct {
 	Ip        string            `json:"ip"`
 	Lock      provision.AppLock `json:"lock"`
 	Tags      []string          `json:"tags"`
+	Labels    map[string]string `json:"labels,omitempty"`
 }
 
 func minifyApp(app app.App) (miniApp, error) {
@@ -126,6 +141,7 @@ func minifyApp(app app.App) (miniApp, error) {
 		Ip:        app.Ip,
 		Lock:      &app.Lock,
 		Tags:      app.Tags,
+		Labels:    app.Labels,
 	}, nil
 }
 
@@ -157,6 +173,7 @@ contextsLoop:
 // responses:
 //   200: List apps
 //   204: No content
+//   400: Invalid data
 //   401: Unauthorized
 func appList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	filter := &app.Filter{}
@@ -185,6 +202,11 @@ func appList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if tags, ok := r.URL.Query()["tag"]; ok {
 		filter.Tags = tags
 	}
+	var err error
+	filter.Labels, err = provision.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
 	contexts := permission.ContextsForPermission(t, permission.PermAppRead)
 	if len(contexts) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -275,6 +297,13 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 		Router:      ia.Router,
 		Tags:        r.Form["tag"],
 	}
+	a.Labels, err = provision.ParseLabels(r.Form["label"])
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if a.TeamOwner == "" {
+		a.TeamOwner = poolDefaultTeamOwner(t, a.Pool)
+	}
 	if a.TeamOwner == "" {
 		a.TeamOwner, err = permission.TeamForPermission(t, permission.PermAppCreate)
 		if err != nil {
@@ -326,30 +355,7 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	err = app.CreateApp(&a, u)
 	if err != nil {
 		log.Errorf("Got error while creating app: %s", err)
-		if e, ok := err.(*errors.ValidationError); ok {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
-		}
-		if _, ok := err.(app.NoTeamsError); ok {
-			return &errors.HTTP{
-				Code:    http.StatusBadRequest,
-				Message: "In order to create an app, you should be member of at least one team",
-			}
-		}
-		if e, ok := err.(*app.AppCreationError); ok {
-			if e.Err == app.ErrAppAlreadyExists {
-				return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
-			}
-			if _, ok := e.Err.(*quota.QuotaExceededError); ok {
-				return &errors.HTTP{
-					Code:    http.StatusForbidden,
-					Message: "Quota exceeded",
-				}
-			}
-		}
-		if err == app.InvalidPlatformError {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
-		}
-		return err
+		return appCreationHTTPError(err)
 	}
 	repo, err := repository.Manager().GetRepository(a.Name)
 	if err != nil {
@@ -377,6 +383,7 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 // produce: application/x-json-stream
 // responses:
 //   200: App updated
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Not found
 func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
@@ -392,6 +399,10 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 		Router:      r.FormValue("router"),
 		Tags:        r.Form["tag"],
 	}
+	updateData.Labels, err = provision.ParseLabels(r.Form["label"])
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
 	appName := r.URL.Query().Get(":appname")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -404,6 +415,9 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if len(updateData.Tags) > 0 {
 		wantedPerms = append(wantedPerms, permission.PermAppUpdateTags)
 	}
+	if len(updateData.Labels) > 0 {
+		wantedPerms = append(wantedPerms, permission.PermAppUpdateLabels)
+	}
 	if updateData.Plan.Name != "" {
 		wantedPerms = append(wantedPerms, permission.PermAppUpdatePlan)
 	}
@@ -453,6 +467,36 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	return err
 }
 
+// appCreationHTTPError returns the HTTP error describing errors returned by
+// app.CreateApp.
+func appCreationHTTPError(err error) error {
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	if _, ok := err.(app.NoTeamsError); ok {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "In order to create an app, you should be member of at least one team",
+		}
+	}
+	if e, ok := err.(*app.AppCreationError); ok {
+		if e.Err == app.ErrAppAlreadyExists {
+			return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
+		}
+		if _, ok := e.Err.(*quota.QuotaExceededError); ok {
+			return &errors.HTTP{
+				Code:      http.StatusForbidden,
+				Message:   "Quota exceeded",
+				ErrorCode: errors.CodeQuotaExceeded,
+			}
+		}
+	}
+	if err == app.InvalidPlatformError {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
 func numberOfUnits(r *http.Request) (uint, error) {
 	unitsStr := r.FormValue("units")
 	if unitsStr == "" {
@@ -508,12 +552,20 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
+	var gateResults []provision.UnitGateResult
+	defer func() {
+		var endData map[string]interface{}
+		if gateResults != nil {
+			endData = map[string]interface{}{"units": gateResults}
+		}
+		evt.DoneCustomData(err, endData)
+	}()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.AddUnits(n, processName, writer)
+	gateResults, err = a.AddUnitsGated(n, processName, writer)
+	return err
 }
 
 // title: remove units
@@ -754,6 +806,7 @@ func revokeAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 // method: POST
 // responses:
 //   200: Ok
+//   202: Run started in detached mode
 //   401: Unauthorized
 //   404: App not found
 func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
@@ -785,14 +838,22 @@ func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err != nil {
 		return err
 	}
+	onceBool, _ := strconv.ParseBool(once)
+	isolatedBool, _ := strconv.ParseBool(isolated)
+	args := provision.RunArgs{Once: onceBool, Isolated: isolatedBool}
+	if detach, _ := strconv.ParseBool(r.FormValue("detach")); detach {
+		// The run outlives the request, its status and output are
+		// retrieved later by the id of its event.
+		go runDetached(evt, &a, command, args)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		return json.NewEncoder(w).Encode(newAppRun(evt))
+	}
 	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	onceBool, _ := strconv.ParseBool(once)
-	isolatedBool, _ := strconv.ParseBool(isolated)
-	args := provision.RunArgs{Once: onceBool, Isolated: isolatedBool}
 	return a.Run(command, writer, args)
 }
 
@@ -822,12 +883,18 @@ func getEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return permission.ErrUnauthorized
 		}
 	}
-	return writeEnvVars(w, &a, variables...)
+	resolveSecrets := permission.Check(t, permission.PermAppAdminSecrets, contextsForApp(&a)...)
+	return writeEnvVars(w, &a, resolveSecrets, variables...)
 }
 
-func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error {
+// secretEnvValue replaces the values of secret variables for users without
+// permission to read them.
+const secretEnvValue = "*** (secret variable)"
+
+// writeEnvVars writes the variables of the app, the values of secret
+// variables are resolved only when resolveSecrets is true.
+func writeEnvVars(w http.ResponseWriter, a *app.App, resolveSecrets bool, variables ...string) error {
 	var result []bind.EnvVar
-	w.Header().Set("Content-Type", "application/json")
 	if len(variables) > 0 {
 		for _, variable := range variables {
 			if v, ok := a.Env[variable]; ok {
@@ -839,9 +906,39 @@ func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error
 			result = append(result, v)
 		}
 	}
+	for i := range result {
+		if !result[i].Secret {
+			continue
+		}
+		if !resolveSecrets {
+			result[i].Value = secretEnvValue
+			continue
+		}
+		value, err := secret.Resolve(result[i].Value)
+		if err != nil {
+			return err
+		}
+		result[i].Value = value
+	}
+	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(result)
 }
 
+// envsCustomData returns the custom data of events changing variables,
+// without the values of secret variables.
+func envsCustomData(form url.Values, isSecret bool) []map[string]interface{} {
+	data := event.FormToCustomData(form)
+	if !isSecret {
+		return data
+	}
+	for _, item := range data {
+		if name, _ := item["name"].(string); strings.HasPrefix(name, "Envs.") && strings.HasSuffix(name, ".Value") {
+			item["value"] = secretEnvValue
+		}
+	}
+	return data
+}
+
 // title: set envs
 // path: /apps/{app}/env
 // method: POST
@@ -879,11 +976,33 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	if e.Secret {
+		if err = secret.Check(); err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+	}
+	for _, v := range e.Envs {
+		if e.Secret {
+			continue
+		}
+		for _, refName := range app.EnvReferences(v.Value) {
+			refApp, errRef := app.GetByName(refName)
+			if errRef == app.ErrAppNotFound {
+				return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("referenced app %q not found", refName)}
+			}
+			if errRef != nil {
+				return errRef
+			}
+			if !permission.Check(t, permission.PermAppReadEnv, contextsForApp(refApp)...) {
+				return permission.ErrUnauthorized
+			}
+		}
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateEnvSet,
 		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
+		CustomData: envsCustomData(r.Form, e.Secret),
 		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
 	})
 	if err != nil {
@@ -892,21 +1011,26 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	defer func() { evt.Done(err) }()
 	envs := map[string]string{}
 	variables := []bind.EnvVar{}
+	names := []string{}
 	for _, v := range e.Envs {
 		envs[v.Name] = v.Value
-		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value, Public: !e.Private})
+		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value, Public: !e.Private && !e.Secret, Secret: e.Secret})
+		names = append(names, v.Name)
 	}
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.SetEnvs(
+	previous := a.UserEnvs()
+	err = a.SetEnvs(
 		bind.SetEnvApp{
 			Envs:          variables,
 			PublicOnly:    true,
 			ShouldRestart: !e.NoRestart,
 		}, writer,
 	)
+	saveEnvRevision(&a, t, app.EnvRevisionSet, names, previous)
+	return err
 }
 
 // title: unset envs
@@ -956,13 +1080,16 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
 	noRestart, _ := strconv.ParseBool(r.FormValue("noRestart"))
-	return a.UnsetEnvs(
+	previous := a.UserEnvs()
+	err = a.UnsetEnvs(
 		bind.UnsetEnvApp{
 			VariableNames: variables,
 			PublicOnly:    true,
 			ShouldRestart: !noRestart,
 		}, writer,
 	)
+	saveEnvRevision(&a, t, app.EnvRevisionUnset, variables, previous)
+	return err
 }
 
 // title: set cname
@@ -1086,6 +1213,10 @@ func appLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	source := r.URL.Query().Get("source")
 	unit := r.URL.Query().Get("unit")
 	follow := r.URL.Query().Get("follow")
+	search := r.URL.Query().Get("search")
+	if search != "" && follow == "1" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: `Parameter "search" can't be used with "follow".`}
+	}
 	appName := r.URL.Query().Get(":app")
 	filterLog := app.Applog{Source: source, Unit: unit}
 	a, err := getAppFromContext(appName, r)
@@ -1098,7 +1229,13 @@ func appLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	logs, err := a.LastLogs(lines, filterLog)
+	logs, err := a.SearchLogs(lines, filterLog, search)
+	if err == app.ErrLogStorageDisabled && follow == "1" {
+		logs, err = []app.Applog{}, nil
+	}
+	if err == app.ErrLogStorageDisabled {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
 	if err != nil {
 		return err
 	}
@@ -1288,10 +1425,11 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 // produce: application/x-json-stream
 // responses:
 //   200: Ok
+//   201: Restart scheduled
+//   400: Invalid data
 //   401: Unauthorized
 //   404: App not found
 func restart(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
-	process := r.FormValue("process")
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1303,22 +1441,127 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	var runAt time.Time
+	if v := r.FormValue("run-at"); v != "" {
+		runAt, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "run-at must be a RFC 3339 time"}
+		}
+	}
+	progress, err := restartProgress(&a, r.Form)
+	if err != nil {
+		return err
+	}
 	evt, err := event.New(&event.Opts{
-		Target:     appTarget(appName),
-		Kind:       permission.PermAppUpdateRestart,
-		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
-		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		Target:        appTarget(appName),
+		Kind:          permission.PermAppUpdateRestart,
+		Owner:         t,
+		CustomData:    event.FormToCustomData(r.Form),
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateRestart, contextsForApp(&a)...),
+		Cancelable:    true,
+		RunAt:         runAt,
+		Context:       context.RequestContext(r),
 	})
 	if err != nil {
 		return err
 	}
+	if evt.Scheduled() {
+		sched, err := event.GetScheduled(evt.UniqueID)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		return json.NewEncoder(w).Encode(sched)
+	}
 	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.Restart(process, writer)
+	evt.SetLogWriter(writer)
+	return a.RollingRestart(evt.Context(), *progress, evt)
+}
+
+// restartProgress returns the progress of the restart requested in form,
+// either a new restart of the process or the resumed progress of the restart
+// event in the resume field. The rolling update limits in the max-surge and
+// max-unavailable fields replace the ones of the resumed restart.
+func restartProgress(a *app.App, form url.Values) (*provision.RestartProgress, error) {
+	progress := &provision.RestartProgress{Process: form.Get("process")}
+	if id := form.Get("resume"); id != "" {
+		var err error
+		progress, err = a.RestartProgressFromEvent(id)
+		if err == event.ErrEventNotFound {
+			return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		if err == app.ErrRestartNotResumable {
+			return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	updates := map[string]provision.RollingUpdate{}
+	for _, v := range form["max-surge"] {
+		process, n, err := parseProcessLimit("max-surge", v)
+		if err != nil {
+			return nil, err
+		}
+		u := updates[process]
+		u.MaxSurge = n
+		updates[process] = u
+	}
+	for _, v := range form["max-unavailable"] {
+		process, n, err := parseProcessLimit("max-unavailable", v)
+		if err != nil {
+			return nil, err
+		}
+		u := updates[process]
+		u.MaxUnavailable = n
+		updates[process] = u
+	}
+	if len(updates) > 0 {
+		progress.Updates = updates
+	}
+	return progress, nil
+}
+
+// parseProcessLimit parses a rolling update limit, optionally prefixed by the
+// name of the process it applies to, e.g.: "web:2".
+func parseProcessLimit(field, value string) (string, int, error) {
+	var process string
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		process, value = value[:i], value[i+1:]
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return "", 0, &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("%s must be a non negative number, optionally prefixed by the process name, e.g.: web:2", field),
+		}
+	}
+	return process, n, nil
+}
+
+// scheduledRestart restarts the app targeted by a restart scheduled with
+// the run-at parameter, logging the output in the event.
+func scheduledRestart(evt *event.Event) error {
+	var data []map[string]interface{}
+	err := evt.StartData(&data)
+	if err != nil {
+		return err
+	}
+	a, err := app.GetByName(evt.Target.Value)
+	if err != nil {
+		return err
+	}
+	progress, err := restartProgress(a, event.CustomDataToForm(data))
+	if err != nil {
+		return err
+	}
+	return a.RollingRestart(evt.Context(), *progress, evt)
 }
 
 // title: app sleep
@@ -1416,12 +1659,13 @@ func addLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 // path: /swap
 // method: POST
 // consume: application/x-www-form-urlencoded
+// produce: application/json
 // responses:
 //   200: Ok
 //   400: Invalid data
 //   401: Unauthorized
 //   404: App not found
-//   409: App locked
+//   409: App locked or swap in progress
 //   412: Number of units or platform don't match
 func swap(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	app1Name := r.FormValue("app1")
@@ -1509,6 +1753,16 @@ func swap(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 			}
 		}
 	}
+	if r.FormValue("step") != "" {
+		return startGradualSwap(w, r, t, app1, app2, cnameOnly)
+	}
+	inProgress, err := app.GradualSwapInProgress(app1.Name, app2.Name)
+	if err != nil {
+		return err
+	}
+	if inProgress {
+		return &errors.HTTP{Code: http.StatusConflict, Message: app.ErrSwapInProgress.Error()}
+	}
 	return app.Swap(app1, app2, cnameOnly)
 }
 
@@ -1540,6 +1794,7 @@ func start(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		Owner:      t,
 		CustomData: event.FormToCustomData(r.Form),
 		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		Context:    context.RequestContext(r),
 	})
 	if err != nil {
 		return err
@@ -1549,7 +1804,7 @@ func start(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.Start(writer, process)
+	return a.Start(evt.Context(), writer, process)
 }
 
 // title: app stop
@@ -1580,6 +1835,7 @@ func stop(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		Owner:      t,
 		CustomData: event.FormToCustomData(r.Form),
 		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		Context:    context.RequestContext(r),
 	})
 	if err != nil {
 		return err
@@ -1589,7 +1845,7 @@ func stop(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.Stop(writer, process)
+	return a.Stop(evt.Context(), writer, process)
 }
 
 // title: app unlock
@@ -1674,7 +1930,7 @@ func registerUnit(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		}
 		return err
 	}
-	return writeEnvVars(w, a)
+	return writeEnvVars(w, a, true)
 }
 
 // title: metric envs
@@ -1704,6 +1960,54 @@ func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(metricMap)
 }
 
+// title: app metrics
+// path: /apps/{app}/metrics
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appMetrics(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadMetric,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	window := time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		window, err = time.ParseDuration(value)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: `Parameter "window" must be a duration, like 1h.`}
+		}
+	}
+	step := window / 60
+	if value := r.URL.Query().Get("step"); value != "" {
+		step, err = time.ParseDuration(value)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: `Parameter "step" must be a duration, like 1m.`}
+		}
+	}
+	metrics, err := a.Metrics(window, step)
+	if err != nil {
+		if _, ok := err.(*errors.ValidationError); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if _, ok := err.(provision.ProvisionerNotSupported); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(metrics)
+}
+
 // title: rebuild routes
 // path: /apps/{app}/routes
 // method: POST
@@ -1864,6 +2168,444 @@ func listCertificates(w http.ResponseWriter, r *http.Request, t auth.Token) erro
 	return json.NewEncoder(w).Encode(&result)
 }
 
+// title: app changelog
+// path: /apps/{app}/changelog
+// method: GET
+// produce: application/json, text/plain
+// responses:
+//   200: Ok
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appChangelog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadEvents,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid value for limit."}
+		}
+	}
+	page, err := a.Changelog(limit, r.URL.Query().Get("cursor"))
+	if err != nil {
+		if _, ok := err.(event.ErrValidation); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	if len(page.Entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if page.Next != "" {
+		w.Header().Set("X-Tsuru-Next-Cursor", page.Next)
+	}
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain")
+		for _, entry := range page.Entries {
+			fmt.Fprintf(w, "%s  %s  %s\n", entry.Time.UTC().Format("2006-01-02 15:04:05 MST"), entry.Owner, entry.Message)
+		}
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(page)
+}
+
+// title: app build cache info
+// path: /apps/{app}/build-cache
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App not found
+func appBuildCacheInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	info, err := a.BuildCache()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(info)
+}
+
+// title: set app build cache
+// path: /apps/{app}/build-cache
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appBuildCacheSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateBuildCacheSet,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid value for enabled, expected true or false."}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateBuildCacheSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.SetBuildCacheEnabled(enabled)
+}
+
+// title: clear app build cache
+// path: /apps/{app}/build-cache
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App not found
+func appBuildCacheClear(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateBuildCacheClear,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateBuildCacheClear,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.ClearBuildCache()
+}
+
+// title: set app deploy approval
+// path: /apps/{app}/deploy-approval
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appDeployApprovalSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppAdminDeployApproval,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	required, err := strconv.ParseBool(r.FormValue("required"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid value for required, expected true or false."}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppAdminDeployApproval,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.SetDeployApprovalRequired(required)
+}
+
+// title: set app rate limit
+// path: /apps/{app}/ratelimit
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appRateLimitSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateRatelimit,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	limit, err := rateLimitFromForm(r)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateRatelimit,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	before := a.RateLimit
+	defer func() {
+		evt.DoneCustomData(err, map[string]interface{}{"before": before, "after": limit})
+	}()
+	err = a.SetRateLimit(limit)
+	if _, ok := err.(*router.RateLimitError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: set app process plan
+// path: /apps/{app}/processes/{process}/plan
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appProcessPlanSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdatePlan,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	process := r.URL.Query().Get(":process")
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdatePlan,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	err = a.SetProcessPlan(process, r.FormValue("plan"), writer)
+	if err == app.ErrPlanNotFound {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if _, ok := err.(provision.InvalidProcessError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app healthcheck info
+// path: /apps/{app}/healthcheck
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No healthcheck set in the app
+//   401: Unauthorized
+//   404: App not found
+func appHealthcheckInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	if a.Healthcheck == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.Healthcheck)
+}
+
+// title: set app healthcheck
+// path: /apps/{app}/healthcheck
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appHealthcheckSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateHealthcheck,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	hc, err := healthcheckFromForm(r)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateHealthcheck,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	before := a.Healthcheck
+	defer func() {
+		evt.DoneCustomData(err, map[string]interface{}{"before": before, "after": hc})
+	}()
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	err = a.SetHealthcheck(hc, writer)
+	if _, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// healthcheckFromForm returns the healthcheck in the path, interval, timeout,
+// failure-threshold and restart-policy form values. Empty path and
+// restart-policy remove the healthcheck.
+func healthcheckFromForm(r *http.Request) (*provision.AppHealthcheck, error) {
+	hc := provision.AppHealthcheck{
+		Path:          r.FormValue("path"),
+		RestartPolicy: r.FormValue("restart-policy"),
+	}
+	fields := []struct {
+		name  string
+		value *int
+	}{
+		{"interval", &hc.Interval},
+		{"timeout", &hc.Timeout},
+		{"failure-threshold", &hc.FailureThreshold},
+	}
+	for _, f := range fields {
+		v := r.FormValue(f.name)
+		if v == "" {
+			continue
+		}
+		var err error
+		*f.value, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for %s, expected an integer.", f.name)
+		}
+	}
+	if hc == (provision.AppHealthcheck{}) {
+		return nil, nil
+	}
+	return &hc, nil
+}
+
+// rateLimitFromForm returns the rate limit in the rps, burst and per-ip form
+// values. A zero rps removes the limit.
+func rateLimitFromForm(r *http.Request) (*router.RateLimit, error) {
+	var limit router.RateLimit
+	var err error
+	limit.RequestsPerSecond, err = strconv.Atoi(r.FormValue("rps"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value for rps, expected an integer.")
+	}
+	if limit.RequestsPerSecond == 0 {
+		return nil, nil
+	}
+	if burst := r.FormValue("burst"); burst != "" {
+		limit.Burst, err = strconv.Atoi(burst)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for burst, expected an integer.")
+		}
+	}
+	if perIP := r.FormValue("per-ip"); perIP != "" {
+		limit.PerIP, err = strconv.ParseBool(perIP)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for per-ip, expected true or false.")
+		}
+	}
+	return &limit, nil
+}
+
 func contextsForApp(a *app.App) []permission.PermissionContext {
 	return append(permission.Contexts(permission.CtxTeam, a.Teams),
 		permission.Context(permission.CtxApp, a.Name),
@@ -25,9 +25,11 @@ import (
 	"github.com/tsuru/tsuru/api/types"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
@@ -39,6 +41,9 @@ import (
 	"github.com/tsuru/tsuru/repository/repositorytest"
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
+	"github.com/tsuru/tsuru/router/routertest"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/secret/secrettest"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -250,6 +255,45 @@ func (s *S) TestAppListFilteringByTags(c *check.C) {
 	c.Assert(apps[0].Tags, check.DeepEquals, app1.Tags)
 }
 
+func (s *S) TestAppListFilteringByLabels(c *check.C) {
+	app1 := app.App{Name: "app1", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "1234"}}
+	err := app.CreateApp(&app1, s.user)
+	c.Assert(err, check.IsNil)
+	app2 := app.App{Name: "app2", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "4321", "product": "store"}}
+	err = app.CreateApp(&app2, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps?label=cost-center=1234", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	apps := []app.App{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &apps)
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+	c.Assert(apps[0].Name, check.Equals, app1.Name)
+	c.Assert(apps[0].Labels, check.DeepEquals, app1.Labels)
+	request, err = http.NewRequest("GET", "/apps?label=product", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	apps = []app.App{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &apps)
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+	c.Assert(apps[0].Name, check.Equals, app2.Name)
+	request, err = http.NewRequest("GET", "/apps?label=cost.center", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *S) TestAppListFilteringByLockState(c *check.C) {
 	app1 := app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&app1, s.user)
@@ -572,6 +616,49 @@ func (s *S) TestDelete(c *check.C) {
 	c.Assert(err, check.NotNil)
 }
 
+func (s *S) TestDeleteChangeRateExceeded(c *check.C) {
+	myApp := &app.App{Name: "myapptodelete", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDelete,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	for i := 0; i < 5; i++ {
+		evt, evtErr := event.New(&event.Opts{
+			Target:  appTarget(fmt.Sprintf("removed%d", i)),
+			Kind:    permission.PermAppDelete,
+			Owner:   token,
+			Allowed: event.Allowed(permission.PermAppReadEvents),
+		})
+		c.Assert(evtErr, check.IsNil)
+		evt.Done(nil)
+	}
+	request, err := http.NewRequest("DELETE", "/apps/"+myApp.Name+"?:app="+myApp.Name, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)user ".*" started 5 app.delete events in the last 10m0s, .*confirm-change-rate=true.*`)
+	_, err = app.GetByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeUser, Value: token.GetUserName()},
+		Kind:         "change-rate-exceeded",
+		ErrorMatches: `.*exceeding the limit of 5 requires confirmation`,
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("DELETE", "/apps/"+myApp.Name+"?:app="+myApp.Name+"&confirm-change-rate=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = app.GetByName(myApp.Name)
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+}
+
 func (s *S) TestDeleteShouldReturnForbiddenIfTheGivenUserDoesNotHaveAccessToTheApp(c *check.C) {
 	myApp := app.App{Name: "app-to-delete", Platform: "zend"}
 	err := s.conn.Apps().Insert(myApp)
@@ -1015,6 +1102,44 @@ func (s *S) TestCreateAppWithTags(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestCreateAppWithLabels(c *check.C) {
+	data, err := url.QueryUnescape("name=someapp&platform=zend&label=cost-center=1234&label=product=store")
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader(data)
+	request, err := http.NewRequest("POST", "/apps", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "someapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.Labels, check.DeepEquals, map[string]string{"cost-center": "1234", "product": "store"})
+}
+
+func (s *S) TestCreateAppWithInvalidLabel(c *check.C) {
+	b := strings.NewReader("name=someapp&platform=zend&label=cost-center")
+	request, err := http.NewRequest("POST", "/apps", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, `invalid label "cost-center", labels must be in the key=value format`+"\n")
+	count, err := s.conn.Apps().Find(bson.M{"name": "someapp"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
 func (s *S) TestCreateAppWithPool(c *check.C) {
 	err := provision.AddPool(provision.AddPoolOptions{Name: "mypool1", Public: true})
 	c.Assert(err, check.IsNil)
@@ -1406,6 +1531,57 @@ func (s *S) TestUpdateAppWithTagsWithoutPermission(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestUpdateAppWithLabelsOnly(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "1234"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateLabels,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	b := strings.NewReader("label=cost-center=&label=product=store")
+	request, err := http.NewRequest("PUT", "/apps/myapp", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "myapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.Labels, check.DeepEquals, map[string]string{"product": "store"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":appname", "value": a.Name},
+			{"name": "label", "value": []string{"cost-center=", "product=store"}},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUpdateAppWithLabelsWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateTags,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	b := strings.NewReader("tag=tag1&label=product=store")
+	request, err := http.NewRequest("PUT", "/apps/myapp", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestUpdateAppWithRouterOnly(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
 	err := app.CreateApp(&a, s.user)
@@ -1682,6 +1858,12 @@ func (s *S) TestAddUnits(c *check.C) {
 		Owner:           s.token.GetUserName(),
 		Kind:            "app.update.unit.add",
 		StartCustomData: []map[string]interface{}{{"name": "units", "value": "3"}, {"name": "process", "value": "web"}, {"name": ":app", "value": "armorandsword"}},
+		EndCustomData: map[string]interface{}{
+			"units.id":      units[0].ID,
+			"units.process": "web",
+			"units.healthy": true,
+			"units.routed":  true,
+		},
 	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"added 3 units"}`+"\n")
 }
@@ -2785,6 +2967,110 @@ func (s *S) TestGetEnvWithAppToken(c *check.C) {
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
 }
 
+func (s *S) TestGetEnvSecret(c *check.C) {
+	config.Set("secrets:backend", "fake")
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	ref, err := secret.Store("four-sticks", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.IsNil)
+	a := app.App{
+		Name:      "four-sticks",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: ref, Secret: true},
+		},
+	}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	reader := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEnv,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	tests := []struct {
+		token    string
+		expected string
+	}{
+		{s.token.GetValue(), "s3cr3t"},
+		{reader.GetValue(), "*** (secret variable)"},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env?env=DATABASE_PASSWORD", a.Name), nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "b "+tt.token)
+		recorder := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusOK)
+		var result []map[string]interface{}
+		err = json.Unmarshal(recorder.Body.Bytes(), &result)
+		c.Assert(err, check.IsNil)
+		c.Check(result, check.DeepEquals, []map[string]interface{}{
+			{"name": "DATABASE_PASSWORD", "value": tt.expected, "public": false, "secret": true},
+		})
+	}
+}
+
+func (s *S) TestSetEnvSecret(c *check.C) {
+	config.Set("secrets:backend", "fake")
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_PASSWORD", "s3cr3t"},
+		},
+		NoRestart: true,
+		Secret:    true,
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/env", a.Name), strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DATABASE_PASSWORD"], check.DeepEquals, bind.EnvVar{
+		Name:   "DATABASE_PASSWORD",
+		Value:  "fake:black-dog/DATABASE_PASSWORD",
+		Secret: true,
+	})
+	c.Assert(secrettest.Secrets(), check.DeepEquals, map[string]string{"black-dog/DATABASE_PASSWORD": "s3cr3t"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Envs.0.Name", "value": "DATABASE_PASSWORD"},
+			{"name": "Envs.0.Value", "value": "*** (secret variable)"},
+			{"name": "Secret", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestSetEnvSecretWithoutBackend(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	v := url.Values{"Envs.0.Name": {"DATABASE_PASSWORD"}, "Envs.0.Value": {"s3cr3t"}, "Secret": {"true"}}
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/env", a.Name), strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "secrets backend not configured\n")
+}
+
 func (s *S) TestSetEnvPublicEnvironmentVariableInTheApp(c *check.C) {
 	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -3153,6 +3439,46 @@ func (s *S) TestSetEnvHandlerReturnsForbiddenIfTheGivenUserDoesNotHaveAccessToTh
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestSetEnvHandlerReturnsForbiddenIfTheUserCantReadReferencedApp(c *check.C) {
+	a := app.App{Name: "rock-and-roll", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	other := app.App{Name: "billing-api", Platform: "zend", Teams: []string{"other-team"}}
+	err = s.conn.Apps().Insert(other)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"BILLING_HOST", `{{app "billing-api".address}}`},
+		},
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/env", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	d.Envs[0].Value = `{{app "unknown".address}}`
+	v, err = form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	request, err = http.NewRequest("POST", url, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "referenced app \"unknown\" not found\n")
+}
+
 func (s *S) TestUnsetEnv(c *check.C) {
 	a := app.App{
 		Name:     "swift",
@@ -3879,53 +4205,108 @@ func (s *S) TestAppLogSelectByUnit(c *check.C) {
 	c.Assert(logs[0].Unit, check.Equals, "caliban")
 }
 
-func (s *S) TestAppLogSelectByLinesShouldReturnTheLastestEntries(c *check.C) {
+func (s *S) TestAppLogSearch(c *check.C) {
 	a := app.App{Name: "lost", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
 	c.Assert(err, check.IsNil)
-	now := time.Now()
-	coll := s.logConn.Logs(a.Name)
-	for i := 0; i < 15; i++ {
-		l := app.Applog{
-			Date:    now.Add(time.Duration(i) * time.Hour),
-			Message: strconv.Itoa(i),
-			Source:  "source",
-			AppName: a.Name,
-		}
-		coll.Insert(l)
-	}
+	a.Log("mars log", "mars", "prospero")
+	a.Log("earth error", "earth", "caliban")
 	token := userWithPermission(c, permission.Permission{
 		Scheme:  permission.PermAppReadLog,
 		Context: permission.Context(permission.CtxTeam, s.team.Name),
 	})
-	url := fmt.Sprintf("/apps/%s/log/?:app=%s&lines=3", a.Name, a.Name)
+	url := fmt.Sprintf("/apps/%s/log/?:app=%s&search=ERROR&lines=10", a.Name, a.Name)
 	request, err := http.NewRequest("GET", url, nil)
 	c.Assert(err, check.IsNil)
 	recorder := httptest.NewRecorder()
-	request.Header.Set("Content-Type", "application/json")
 	err = appLog(recorder, request, token)
 	c.Assert(err, check.IsNil)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
-	var logs []app.Applog
+	logs := []app.Applog{}
 	err = json.Unmarshal(recorder.Body.Bytes(), &logs)
 	c.Assert(err, check.IsNil)
-	c.Assert(logs, check.HasLen, 3)
-	c.Assert(logs[0].Message, check.Equals, "12")
-	c.Assert(logs[1].Message, check.Equals, "13")
-	c.Assert(logs[2].Message, check.Equals, "14")
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Message, check.Equals, "earth error")
 }
 
-func (s *S) TestAppLogShouldReturnLogByApp(c *check.C) {
-	app1 := app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}
-	err := app.CreateApp(&app1, s.user)
-	c.Assert(err, check.IsNil)
-	app1.Log("app1 log", "source", "")
-	app2 := app.App{Name: "app2", Platform: "zend", TeamOwner: s.team.Name}
-	err = app.CreateApp(&app2, s.user)
-	c.Assert(err, check.IsNil)
-	app2.Log("app2 log", "sourc ", "")
-	app3 := app.App{Name: "app3", Platform: "zend", TeamOwner: s.team.Name}
-	err = app.CreateApp(&app3, s.user)
+func (s *S) TestAppLogSearchWithFollow(c *check.C) {
+	url := "/apps/something/log/?:app=doesntmatter&lines=10&search=error&follow=1"
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appLog(recorder, request, s.token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, check.Equals, `Parameter "search" can't be used with "follow".`)
+}
+
+func (s *S) TestAppLogWithStorageDisabled(c *check.C) {
+	config.Set("logs:storage", "none")
+	defer config.Unset("logs:storage")
+	a := app.App{Name: "lost", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/log/?:app=%s&lines=10", a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appLog(recorder, request, s.token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, check.Equals, app.ErrLogStorageDisabled.Error())
+}
+
+func (s *S) TestAppLogSelectByLinesShouldReturnTheLastestEntries(c *check.C) {
+	a := app.App{Name: "lost", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	now := time.Now()
+	coll := s.logConn.Logs(a.Name)
+	for i := 0; i < 15; i++ {
+		l := app.Applog{
+			Date:    now.Add(time.Duration(i) * time.Hour),
+			Message: strconv.Itoa(i),
+			Source:  "source",
+			AppName: a.Name,
+		}
+		coll.Insert(l)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadLog,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	url := fmt.Sprintf("/apps/%s/log/?:app=%s&lines=3", a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Content-Type", "application/json")
+	err = appLog(recorder, request, token)
+	c.Assert(err, check.IsNil)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var logs []app.Applog
+	err = json.Unmarshal(recorder.Body.Bytes(), &logs)
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 3)
+	c.Assert(logs[0].Message, check.Equals, "12")
+	c.Assert(logs[1].Message, check.Equals, "13")
+	c.Assert(logs[2].Message, check.Equals, "14")
+}
+
+func (s *S) TestAppLogShouldReturnLogByApp(c *check.C) {
+	app1 := app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&app1, s.user)
+	c.Assert(err, check.IsNil)
+	app1.Log("app1 log", "source", "")
+	app2 := app.App{Name: "app2", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&app2, s.user)
+	c.Assert(err, check.IsNil)
+	app2.Log("app2 log", "sourc ", "")
+	app3 := app.App{Name: "app3", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&app3, s.user)
 	c.Assert(err, check.IsNil)
 	app3.Log("app3 log", "tsuru", "")
 	token := userWithPermission(c, permission.Permission{
@@ -4709,6 +5090,184 @@ func (s *S) TestRestartHandlerSingleProcess(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestRestartHandlerScheduled(c *check.C) {
+	config.Set("docker:router", "fake")
+	defer config.Unset("docker:router")
+	a := app.App{
+		Name:      "stress",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/restart", a.Name)
+	runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := strings.NewReader("process=web&run-at=" + runAt.Format(time.RFC3339))
+	request, err := http.NewRequest("POST", url, body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var sched event.ScheduledEvent
+	err = json.NewDecoder(recorder.Body).Decode(&sched)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.RunAt.Equal(runAt), check.Equals, true)
+	c.Assert(sched.Target, check.Equals, appTarget(a.Name))
+	c.Assert(s.provisioner.Restarts(&a, "web"), check.Equals, 0)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.EventScheduled().UpdateId(sched.ID, bson.M{"$set": bson.M{"runat": time.Now().Add(-time.Second)}})
+	c.Assert(err, check.IsNil)
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.Restarts(&a, "web"), check.Equals, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.restart",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "process", "value": "web"},
+			{"name": "run-at", "value": runAt.Format(time.RFC3339)},
+		},
+		LogMatches: `restarting app`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRestartHandlerRollingUpdates(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("max-surge=2&max-unavailable=1&max-unavailable=worker:0")
+	request, err := http.NewRequest("POST", "/apps/stress/restart", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(s.provisioner.LastRestartProgress(&a), check.DeepEquals, &provision.RestartProgress{
+		Updates: map[string]provision.RollingUpdate{
+			"":       {MaxSurge: 2, MaxUnavailable: 1},
+			"worker": {MaxUnavailable: 0},
+		},
+	})
+}
+
+func (s *S) TestRestartHandlerInvalidMaxSurge(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("max-surge=web:-1")
+	request, err := http.NewRequest("POST", "/apps/stress/restart", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "max-surge must be a non negative number, optionally prefixed by the process name, e.g.: web:2\n")
+}
+
+func (s *S) TestRestartHandlerRecordsProgress(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/stress/restart", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	evts, err := event.List(&event.Filter{Target: appTarget(a.Name), KindName: "app.update.restart"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	var progress provision.RestartProgress
+	err = evts[0].OtherData(&progress)
+	c.Assert(err, check.IsNil)
+	c.Assert(progress.Restarted, check.DeepEquals, []string{units[0].ID, units[1].ID})
+}
+
+func (s *S) TestRestartHandlerResume(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:  appTarget(a.Name),
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.SetOtherCustomData(provision.RestartProgress{
+		Process:   "web",
+		Updates:   map[string]provision.RollingUpdate{"": {MaxSurge: 3}},
+		Restarted: []string{"unit1"},
+	})
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	body := strings.NewReader("resume=" + evt.UniqueID.Hex())
+	request, err := http.NewRequest("POST", "/apps/stress/restart", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrRestartNotResumable.Error()+"\n")
+	err = evt.Done(fmt.Errorf("healthcheck failed"))
+	c.Assert(err, check.IsNil)
+	body = strings.NewReader("resume=" + evt.UniqueID.Hex())
+	request, err = http.NewRequest("POST", "/apps/stress/restart", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(s.provisioner.Restarts(&a, "web"), check.Equals, 1)
+	c.Assert(s.provisioner.LastRestartProgress(&a), check.DeepEquals, &provision.RestartProgress{
+		Process:   "web",
+		Updates:   map[string]provision.RollingUpdate{"": {MaxSurge: 3}},
+		Restarted: []string{"unit1"},
+	})
+	body = strings.NewReader("resume=" + bson.NewObjectId().Hex())
+	request, err = http.NewRequest("POST", "/apps/stress/restart", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestRestartHandlerInvalidRunAt(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("run-at=tomorrow")
+	request, err := http.NewRequest("POST", "/apps/stress/restart", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "run-at must be a RFC 3339 time\n")
+}
+
 func (s *S) TestRestartHandlerReturns404IfTheAppDoesNotExist(c *check.C) {
 	request, err := http.NewRequest("GET", "/apps/unknown/restart?:app=unknown", nil)
 	c.Assert(err, check.IsNil)
@@ -5389,6 +5948,89 @@ func (s *S) TestMEtricEnvsWhenAppDoesNotExist(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Matches, "^App .* not found.\n$")
 }
 
+func (s *S) TestAppMetrics(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	request, err := http.NewRequest("GET", "/1.3/apps/myappx/metrics?window=10m&step=1m", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var metrics []provision.UnitMetrics
+	err = json.NewDecoder(recorder.Body).Decode(&metrics)
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.HasLen, 2)
+	c.Assert(metrics[0].CPU, check.HasLen, 11)
+	c.Assert(metrics[0].CPU[0].Value, check.Equals, float64(10))
+	c.Assert(metrics[0].Memory, check.HasLen, 11)
+}
+
+func (s *S) TestAppMetricsDefaultWindow(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	request, err := http.NewRequest("GET", "/1.3/apps/myappx/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var metrics []provision.UnitMetrics
+	err = json.NewDecoder(recorder.Body).Decode(&metrics)
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.HasLen, 1)
+	c.Assert(metrics[0].CPU, check.HasLen, 61)
+}
+
+func (s *S) TestAppMetricsInvalidParameters(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		query string
+		msg   string
+	}{
+		{"window=1x", `Parameter "window" must be a duration, like 1h.`},
+		{"step=abc", `Parameter "step" must be a duration, like 1m.`},
+		{"window=-1h", "the window and the step of the metrics must be positive"},
+		{"window=24h&step=1s", "the window of the metrics can have at most 1000 steps"},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		request, err := http.NewRequest("GET", "/1.3/apps/myappx/metrics?"+tt.query, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, tt.msg+"\n")
+	}
+}
+
+func (s *S) TestAppMetricsWhenUserDoesNotHaveAccess(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend"}
+	err := s.conn.Apps().Insert(&a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadMetric,
+		Context: permission.Context(permission.CtxApp, "-invalid-"),
+	})
+	request, err := http.NewRequest("GET", "/1.3/apps/myappx/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestRebuildRoutes(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
 	err := app.CreateApp(&a, s.user)
@@ -5575,3 +6217,604 @@ func (s *S) TestListCertificates(c *check.C) {
 		"myapp.fakerouter.com": "",
 	})
 }
+
+func (s *S) TestAppChangelog(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	for _, img := range []string{"myimg:v1", "myimg:v2"} {
+		evt, evtErr := event.New(&event.Opts{
+			Target:     appTarget(a.Name),
+			Kind:       permission.PermAppDeploy,
+			Owner:      s.token,
+			CustomData: app.DeployOptions{Image: img},
+			Allowed:    event.Allowed(permission.PermAppReadEvents),
+		})
+		c.Assert(evtErr, check.IsNil)
+		evt.Done(nil)
+	}
+	request, err := http.NewRequest("GET", "/apps/myapp/changelog?limit=1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	next := recorder.Header().Get("X-Tsuru-Next-Cursor")
+	c.Assert(next, check.Not(check.Equals), "")
+	var page app.ChangelogPage
+	err = json.NewDecoder(recorder.Body).Decode(&page)
+	c.Assert(err, check.IsNil)
+	c.Assert(page.Entries, check.HasLen, 1)
+	c.Assert(page.Entries[0].Message, check.Equals, "Deployed image myimg:v2")
+	c.Assert(page.Next, check.Equals, next)
+	request, err = http.NewRequest("GET", "/apps/myapp/changelog?format=text&limit=1&cursor="+next, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(recorder.Body.String(), check.Matches, `\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} UTC  `+s.token.GetUserName()+`  Deployed image myimg:v1\n`)
+}
+
+func (s *S) TestAppChangelogEmpty(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/changelog", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppChangelogInvalidCursor(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/changelog?cursor=invalid", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestAppChangelogNoPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "nopermission")
+	request, err := http.NewRequest("GET", "/apps/myapp/changelog", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppBuildCacheInfo(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/build-cache", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var info app.BuildCacheInfo
+	err = json.NewDecoder(recorder.Body).Decode(&info)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Enabled, check.Equals, true)
+	c.Assert(info.Cleared, check.Equals, false)
+	c.Assert(info.CacheImage, check.Equals, "")
+	c.Assert(info.BuildImage, check.Not(check.Equals), "")
+}
+
+func (s *S) TestAppBuildCacheInfoNoPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "nopermission")
+	request, err := http.NewRequest("GET", "/apps/myapp/build-cache", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppBuildCacheSet(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("enabled=false")
+	request, err := http.NewRequest("PUT", "/apps/myapp/build-cache", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.BuildCacheDisabled, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.build-cache.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "enabled", "value": "false"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppBuildCacheSetInvalidValue(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("enabled=maybe")
+	request, err := http.NewRequest("PUT", "/apps/myapp/build-cache", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid value for enabled, expected true or false.\n")
+}
+
+func (s *S) TestAppDeployApprovalSet(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("required=true")
+	request, err := http.NewRequest("PUT", "/apps/myapp/deploy-approval", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RequireDeployApproval, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.admin.deploy-approval",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "required", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+	body = strings.NewReader("required=maybe")
+	request, err = http.NewRequest("PUT", "/apps/myapp/deploy-approval", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid value for required, expected true or false.\n")
+}
+
+func (s *S) TestAppDeployApprovalSetRequiresAdminPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApprovalRequired(true)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "developer", permission.Permission{
+		Scheme:  permission.PermAppUpdate,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader("required=false")
+	request, err := http.NewRequest("PUT", "/apps/myapp/deploy-approval", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RequireDeployApproval, check.Equals, true)
+}
+
+func (s *S) TestAppBuildCacheClear(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/build-cache", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.UpdatePlatform, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.build-cache.clear",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppRateLimitSet(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-ratelimit"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("rps=10&burst=5&per-ip=true")
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/ratelimit", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	limit := router.RateLimit{RequestsPerSecond: 10, Burst: 5, PerIP: true}
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RateLimit, check.DeepEquals, &limit)
+	c.Assert(routertest.RateLimitRouter.Limits[a.Name], check.DeepEquals, limit)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.ratelimit",
+		StartCustomData: []map[string]interface{}{
+			{"name": "rps", "value": "10"},
+			{"name": "burst", "value": "5"},
+			{"name": "per-ip", "value": "true"},
+		},
+		EndCustomData: map[string]interface{}{
+			"before":                  nil,
+			"after.requestspersecond": 10,
+			"after.burst":             5,
+			"after.perip":             true,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppRateLimitSetRemove(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-ratelimit"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetRateLimit(&router.RateLimit{RequestsPerSecond: 10})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/ratelimit", strings.NewReader("rps=0"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RateLimit, check.IsNil)
+	c.Assert(routertest.RateLimitRouter.Limits, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.ratelimit",
+		EndCustomData: map[string]interface{}{
+			"before.requestspersecond": 10,
+			"after":                    nil,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppRateLimitSetInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{"rps=fast", "Invalid value for rps, expected an integer.\n"},
+		{"rps=10&burst=x", "Invalid value for burst, expected an integer.\n"},
+		{"rps=10&per-ip=maybe", "Invalid value for per-ip, expected true or false.\n"},
+		{"rps=10", "router does not support rate limiting\n"},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("PUT", "/1.3/apps/myapp/ratelimit", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest, check.Commentf("body: %s", tt.body))
+		c.Assert(recorder.Body.String(), check.Equals, tt.message)
+	}
+}
+
+func (s *S) TestAppRateLimitSetWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-ratelimit"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateRouter,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/ratelimit", strings.NewReader("rps=10"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) setupProcessPlanApp(c *check.C) app.App {
+	plan := app.Plan{Name: "big", Memory: 1073741824, Swap: 1073741824, CpuShare: 100}
+	err := plan.Save()
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python app.py",
+			"worker": "python worker.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	return a
+}
+
+func (s *S) TestAppProcessPlanSet(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := s.provisioner.AddUnits(&a, 1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/processes/worker/plan", strings.NewReader("plan=big"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessPlans["worker"].Name, check.Equals, "big")
+	c.Assert(s.provisioner.Restarts(&a, "worker"), check.Equals, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.plan",
+		StartCustomData: []map[string]interface{}{
+			{"name": "plan", "value": "big"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppProcessPlanSetRemove(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := a.SetProcessPlan("worker", "big", nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/processes/worker/plan", strings.NewReader("plan="))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessPlans, check.HasLen, 0)
+}
+
+func (s *S) TestAppProcessPlanSetInvalid(c *check.C) {
+	s.setupProcessPlanApp(c)
+	tests := []struct {
+		process string
+		plan    string
+		message string
+	}{
+		{"worker", "unknown", app.ErrPlanNotFound.Error()},
+		{"scheduler", "big", `process error: no process "scheduler" declared in Procfile`},
+	}
+	for _, test := range tests {
+		url := fmt.Sprintf("/1.3/apps/myapp/processes/%s/plan", test.process)
+		request, err := http.NewRequest("PUT", url, strings.NewReader("plan="+test.plan))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, test.message+"\n")
+	}
+}
+
+func (s *S) TestAppProcessPlanSetWithoutPermission(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnv,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/processes/worker/plan", strings.NewReader("plan=big"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppHealthcheckSet(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("path=/status&interval=10&timeout=2&failure-threshold=3&restart-policy=on-failure")
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/healthcheck", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	hc := provision.AppHealthcheck{
+		Path:             "/status",
+		Interval:         10,
+		Timeout:          2,
+		FailureThreshold: 3,
+		RestartPolicy:    provision.RestartPolicyOnFailure,
+	}
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Healthcheck, check.DeepEquals, &hc)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.healthcheck",
+		StartCustomData: []map[string]interface{}{
+			{"name": "path", "value": "/status"},
+			{"name": "interval", "value": "10"},
+			{"name": "timeout", "value": "2"},
+			{"name": "failure-threshold", "value": "3"},
+			{"name": "restart-policy", "value": "on-failure"},
+		},
+		EndCustomData: map[string]interface{}{
+			"before":               nil,
+			"after.path":           "/status",
+			"after.restart_policy": "on-failure",
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppHealthcheckSetRemove(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetHealthcheck(&provision.AppHealthcheck{Path: "/status"}, nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/healthcheck", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Healthcheck, check.IsNil)
+}
+
+func (s *S) TestAppHealthcheckSetInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{"path=/status&interval=abc", "Invalid value for interval, expected an integer."},
+		{"path=status", "the healthcheck path must start with /"},
+		{"restart-policy=sometimes", `invalid restart policy "sometimes", valid policies are: always, on-failure, never`},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest("PUT", "/1.3/apps/myapp/healthcheck", strings.NewReader(test.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, test.message+"\n")
+	}
+}
+
+func (s *S) TestAppHealthcheckSetWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("PUT", "/1.3/apps/myapp/healthcheck", strings.NewReader("path=/status"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppHealthcheckInfo(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/apps/myapp/healthcheck", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	hc := provision.AppHealthcheck{Path: "/status", Interval: 10, RestartPolicy: provision.RestartPolicyNever}
+	err = a.SetHealthcheck(&hc, nil)
+	c.Assert(err, check.IsNil)
+	request, err = http.NewRequest("GET", "/1.3/apps/myapp/healthcheck", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result provision.AppHealthcheck
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, hc)
+}
+
+func (s *S) TestCreateAppWithPoolDefaultTeamOwner(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "team1"}, auth.Team{Name: "team2"})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolDefaults("test1", provision.PoolDefaults{TeamOwner: "team2"})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "anotheruser", permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	body := strings.NewReader("name=someapp&platform=zend&pool=test1")
+	request, err := http.NewRequest("POST", "/apps", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "someapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.TeamOwner, check.Equals, "team2")
+	c.Assert(gotApp.Teams, check.DeepEquals, []string{"team2"})
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+)
+
+// title: app clone
+// path: /apps/{app}/clone
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: App cloned
+//   400: Invalid data
+//   401: Unauthorized
+//   403: Quota exceeded
+//   404: App not found
+//   409: App already exists
+func appClone(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	src, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	opts := app.CloneOptions{
+		Name:      r.FormValue("name"),
+		TeamOwner: r.FormValue("teamowner"),
+		Pool:      r.FormValue("pool"),
+	}
+	opts.IncludeSecrets, _ = strconv.ParseBool(r.FormValue("secrets"))
+	opts.NewInstances, _ = strconv.ParseBool(r.FormValue("newinstances"))
+	if opts.Name == "" {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "the name of the new app is required"}
+	}
+	if opts.TeamOwner == "" {
+		opts.TeamOwner = src.TeamOwner
+	}
+	if opts.Pool == "" {
+		opts.Pool = src.Pool
+	}
+	canRead := permission.Check(t, permission.PermAppRead, contextsForApp(&src)...) &&
+		permission.Check(t, permission.PermAppReadEnv, contextsForApp(&src)...)
+	if !canRead {
+		return permission.ErrUnauthorized
+	}
+	if opts.IncludeSecrets && !permission.Check(t, permission.PermAppAdminSecrets, contextsForApp(&src)...) {
+		return permission.ErrUnauthorized
+	}
+	if !permission.Check(t, permission.PermAppCreateClone, permission.Context(permission.CtxTeam, opts.TeamOwner)) {
+		return permission.ErrUnauthorized
+	}
+	err = checkClonedInstancesPermission(t, src.Name, opts)
+	if err != nil {
+		return err
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	clone := app.App{Name: opts.Name, TeamOwner: opts.TeamOwner, Pool: opts.Pool}
+	evt, err := event.New(&event.Opts{
+		Target:       appTarget(clone.Name),
+		Kind:         permission.PermAppCreateClone,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermAppReadEvents, contextsForApp(&clone)...),
+		ExtraTargets: []event.Target{appTarget(src.Name)},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	requestIDHeader, _ := config.GetString("request-id-header")
+	opts.RequestID = context.GetRequestID(r, requestIDHeader)
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	_, err = app.Clone(&src, opts, u, writer)
+	if err != nil {
+		return appCreationHTTPError(err)
+	}
+	fmt.Fprintf(writer, "App %q cloned from %q.\n", opts.Name, src.Name)
+	return nil
+}
+
+// checkClonedInstancesPermission checks whether the user is able to bind the
+// clone to the instances bound to the source app or, when new instances are
+// created, to create instances in the team of the clone.
+func checkClonedInstancesPermission(t auth.Token, appName string, opts app.CloneOptions) error {
+	instances, err := service.GetServicesInstancesByTeamsAndNames(nil, nil, appName, "")
+	if err != nil {
+		return err
+	}
+	for i := range instances {
+		var allowed bool
+		if opts.NewInstances {
+			allowed = permission.Check(t, permission.PermServiceInstanceCreate,
+				permission.Context(permission.CtxTeam, opts.TeamOwner),
+			)
+		} else {
+			allowed = permission.Check(t, permission.PermServiceInstanceUpdateBind,
+				contextsForServiceInstance(&instances[i], instances[i].ServiceName)...,
+			)
+		}
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppClone(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "PUBLIC", Value: "1", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("name=web-review")
+	req, err := http.NewRequest("POST", "/1.3/apps/web/clone", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*App \\"web-review\\" cloned from \\"web\\"..*`)
+	clone, err := app.GetByName("web-review")
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.Platform, check.Equals, "zend")
+	c.Assert(clone.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(clone.UserEnvs(), check.DeepEquals, map[string]bind.EnvVar{
+		"PUBLIC": {Name: "PUBLIC", Value: "1", Public: true},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("web-review"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.create.clone",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "web"},
+			{"name": "name", "value": "web-review"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppCloneInvalid(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body string
+		code int
+	}{
+		{"", http.StatusBadRequest},
+		{"name=web", http.StatusConflict},
+		{"name=Invalid_Name", http.StatusBadRequest},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/1.3/apps/web/clone", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, tt.code, check.Commentf("body: %q", tt.body))
+	}
+}
+
+func (s *S) TestAppCloneRequiresPermission(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body  string
+		perms []permission.Permission
+	}{
+		{"name=web-review", []permission.Permission{
+			{Scheme: permission.PermAppRead, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+			{Scheme: permission.PermAppReadEnv, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+		}},
+		{"name=web-review", []permission.Permission{
+			{Scheme: permission.PermAppRead, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+			{Scheme: permission.PermAppCreate, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+		}},
+		{"name=web-review&secrets=true", []permission.Permission{
+			{Scheme: permission.PermAppRead, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+			{Scheme: permission.PermAppReadEnv, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+			{Scheme: permission.PermAppCreate, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+		}},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		token := userWithPermission(c, tt.perms...)
+		req, err := http.NewRequest("POST", "/1.3/apps/web/clone", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, http.StatusForbidden, check.Commentf("body: %q", tt.body))
+	}
+	_, err = app.GetByName("web-review")
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// detachedRunLogInterval is the interval between saves of the output of
+// detached runs, making it available while they're running.
+var detachedRunLogInterval = 5 * time.Second
+
+// appRun is a command run in an app, tracked by its app.run event.
+type appRun struct {
+	ID        string
+	Command   string
+	Owner     string
+	StartTime time.Time
+	EndTime   time.Time
+	Running   bool
+	Error     string
+	Output    string
+}
+
+func newAppRun(evt *event.Event) appRun {
+	run := appRun{
+		ID:        evt.UniqueID.Hex(),
+		Owner:     evt.Owner.Name,
+		StartTime: evt.StartTime,
+		EndTime:   evt.EndTime,
+		Running:   evt.Running,
+		Error:     evt.Error,
+		Output:    evt.Log,
+	}
+	var data []map[string]interface{}
+	if err := evt.StartData(&data); err == nil {
+		for _, item := range data {
+			if item["name"] == "command" {
+				run.Command, _ = item["value"].(string)
+			}
+		}
+	}
+	return run
+}
+
+// runDetached runs the command in the app, recording its output in the event
+// and finishing it once the command is done. The output recorded so far is
+// saved every detachedRunLogInterval.
+func runDetached(evt *event.Event, a *app.App, command string, args provision.RunArgs) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(detachedRunLogInterval):
+			}
+			if err := evt.SaveLog(); err != nil {
+				log.Errorf("[app-run] unable to save output of detached run %s: %s", evt.UniqueID.Hex(), err)
+			}
+		}
+	}()
+	err := a.Run(command, evt, args)
+	close(done)
+	if doneErr := evt.Done(err); doneErr != nil {
+		log.Errorf("[app-run] unable to finish detached run %s: %s", evt.UniqueID.Hex(), doneErr)
+	}
+}
+
+// title: run info
+// path: /apps/{app}/runs/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid id
+//   401: Unauthorized
+//   404: App or run not found
+func appRunInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRun,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid run id: %s", id)}
+	}
+	notFound := &errors.HTTP{Code: http.StatusNotFound, Message: "run not found"}
+	evt, err := event.GetByID(bson.ObjectIdHex(id))
+	if err != nil {
+		return notFound
+	}
+	if evt.Kind.Name != permission.PermAppRun.FullName() || evt.Target != appTarget(a.Name) {
+		return notFound
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(newAppRun(evt))
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) getAppRun(c *check.C, appName, id string) (int, appRun) {
+	request, err := http.NewRequest("GET", fmt.Sprintf("/1.3/apps/%s/runs/%s", appName, id), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	var run appRun
+	if recorder.Code == http.StatusOK {
+		c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+		err = json.Unmarshal(recorder.Body.Bytes(), &run)
+		c.Assert(err, check.IsNil)
+	}
+	return recorder.Code, run
+}
+
+func (s *S) TestRunDetached(c *check.C) {
+	s.provisioner.PrepareOutput([]byte("migrated"))
+	a := app.App{Name: "secrets", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/secrets/run", strings.NewReader("command=./migrate&isolated=true&detach=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var started appRun
+	err = json.Unmarshal(recorder.Body.Bytes(), &started)
+	c.Assert(err, check.IsNil)
+	c.Assert(started.ID, check.Not(check.Equals), "")
+	c.Assert(started.Command, check.Equals, "./migrate")
+	c.Assert(started.Owner, check.Equals, s.token.GetUserName())
+	c.Assert(started.Running, check.Equals, true)
+	var run appRun
+	timeout := time.After(5 * time.Second)
+	for {
+		var code int
+		code, run = s.getAppRun(c, a.Name, started.ID)
+		c.Assert(code, check.Equals, http.StatusOK)
+		if !run.Running {
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for detached run to finish")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	c.Assert(run.Command, check.Equals, "./migrate")
+	c.Assert(run.Error, check.Equals, "")
+	c.Assert(run.Output, check.Equals, "migrated")
+	c.Assert(run.EndTime.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestRunDetachedFailure(c *check.C) {
+	s.provisioner.PrepareFailure("ExecuteCommandIsolated", fmt.Errorf("exit status 1"))
+	a := app.App{Name: "secrets", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/secrets/run", strings.NewReader("command=./migrate&isolated=true&detach=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted)
+	var started appRun
+	err = json.Unmarshal(recorder.Body.Bytes(), &started)
+	c.Assert(err, check.IsNil)
+	var run appRun
+	timeout := time.After(5 * time.Second)
+	for {
+		_, run = s.getAppRun(c, a.Name, started.ID)
+		if !run.Running {
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for detached run to finish")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	c.Assert(run.Error, check.Equals, "exit status 1")
+}
+
+func (s *S) TestAppRunInfoNotFound(c *check.C) {
+	a := app.App{Name: "secrets", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	code, _ := s.getAppRun(c, a.Name, "invalid")
+	c.Assert(code, check.Equals, http.StatusBadRequest)
+	evt, err := event.New(&event.Opts{
+		Target:  appTarget(a.Name),
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	code, _ = s.getAppRun(c, a.Name, evt.UniqueID.Hex())
+	c.Assert(code, check.Equals, http.StatusNotFound)
+	code, _ = s.getAppRun(c, a.Name, "59128a000000000000000001")
+	c.Assert(code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppRunInfoWithoutPermission(c *check.C) {
+	a := app.App{Name: "secrets", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEvents,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/1.3/apps/secrets/runs/59128a000000000000000001", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -7,7 +7,11 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
@@ -16,6 +20,7 @@ import (
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/repository"
 )
 
@@ -30,7 +35,12 @@ func handleAuthError(err error) error {
 	if err == auth.ErrUserNotFound {
 		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
 	}
+	if err == auth.ErrUserSuspended {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+	}
 	switch err.(type) {
+	case *auth.LoginLockedError:
+		return &errors.HTTP{Code: http.StatusTooManyRequests, Message: err.Error()}
 	case *errors.ValidationError:
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	case *errors.ConflictError:
@@ -66,7 +76,7 @@ func createUser(w http.ResponseWriter, r *http.Request) error {
 	registrationEnabled, _ := config.GetBool("auth:user-registration")
 	if !registrationEnabled {
 		token := r.Header.Get("Authorization")
-		t, err := app.AuthScheme.Auth(token)
+		t, err := app.AuthScheme.Auth(r.Context(), token)
 		if err != nil {
 			return createDisabledErr
 		}
@@ -122,11 +132,125 @@ func login(w http.ResponseWriter, r *http.Request) (err error) {
 	for key := range r.Form {
 		params[key] = r.FormValue(key)
 	}
+	params[auth.LoginParamRemoteAddr] = remoteAddr(r)
+	params[auth.LoginParamUserAgent] = r.UserAgent()
 	token, err := app.AuthScheme.Login(params)
 	if err != nil {
+		if lockErr, ok := err.(*auth.LoginLockedError); ok {
+			retryAfter := int(time.Until(lockErr.Until).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
 		return handleAuthError(err)
 	}
-	return json.NewEncoder(w).Encode(map[string]string{"token": token.GetValue()})
+	if recordErr := auth.RecordLogin(token.GetUserName()); recordErr != nil {
+		log.Errorf("unable to record login of %s: %s", token.GetUserName(), recordErr)
+	}
+	return writeToken(w, token)
+}
+
+// remoteAddr returns the address of the client sending the request. The
+// X-Forwarded-For header is only honored for requests coming from the proxies
+// in the server:trusted-proxies setting, in which case the address is the
+// right-most one in the header that doesn't belong to a trusted proxy.
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	proxies := trustedProxies()
+	if !isTrustedProxy(host, proxies) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !isTrustedProxy(hop, proxies) {
+			break
+		}
+	}
+	return host
+}
+
+// trustedProxies returns the networks of the proxies in front of the API,
+// listed as addresses or CIDR blocks in the server:trusted-proxies setting.
+func trustedProxies() []*net.IPNet {
+	entries, _ := config.GetList("server:trusted-proxies")
+	var proxies []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Errorf("ignoring invalid trusted proxy %q", entry)
+				continue
+			}
+			if v4 := ip.To4(); v4 != nil {
+				ip = v4
+			}
+			bits := len(ip) * 8
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Errorf("ignoring invalid trusted proxy %q: %s", entry, err)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func isTrustedProxy(addr string, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeToken writes the token in the response, along with its expiration
+// time when it has one, so clients can tell when the session ends.
+func writeToken(w http.ResponseWriter, token auth.Token) error {
+	data := map[string]string{"token": token.GetValue()}
+	if t, ok := token.(auth.ExpirableToken); ok {
+		if expiration := t.GetExpiration(); !expiration.IsZero() {
+			data["expires"] = expiration.UTC().Format(time.RFC3339)
+		}
+	}
+	return json.NewEncoder(w).Encode(data)
+}
+
+// title: refresh token
+// path: /users/tokens/refresh
+// method: POST
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+func refreshToken(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	scheme, ok := app.AuthScheme.(auth.RefreshScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonManagedSchemeMsg}
+	}
+	newToken, err := scheme.Refresh(t)
+	if err != nil {
+		if err == auth.ErrInvalidToken {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Only session tokens can be refreshed."}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return writeToken(w, newToken)
 }
 
 // title: logout
@@ -138,6 +262,76 @@ func logout(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	return app.AuthScheme.Logout(t.GetValue())
 }
 
+// title: list sessions
+// path: /users/me/sessions
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func listSessions(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	scheme, ok := app.AuthScheme.(auth.SessionScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonManagedSchemeMsg}
+	}
+	allowed := permission.Check(t, permission.PermUserReadSessions,
+		permission.Context(permission.CtxUser, t.GetUserName()),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	sessions, err := scheme.Sessions(t)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(sessions)
+}
+
+// title: revoke session
+// path: /users/me/sessions/{id}
+// method: DELETE
+// responses:
+//   200: Session revoked
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Session not found
+func revokeSession(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	scheme, ok := app.AuthScheme.(auth.SessionScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonManagedSchemeMsg}
+	}
+	allowed := permission.Check(t, permission.PermUserUpdateSessionRevoke,
+		permission.Context(permission.CtxUser, t.GetUserName()),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(t.GetUserName()),
+		Kind:       permission.PermUserUpdateSessionRevoke,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, t.GetUserName())),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = scheme.RevokeSession(t, r.URL.Query().Get(":id"))
+	if err == auth.ErrSessionNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
 // title: change password
 // path: /users/password
 // method: PUT
@@ -316,6 +510,159 @@ func removeTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	return nil
 }
 
+// title: rename team
+// path: /teams/{name}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Team renamed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+//   409: Team already exists
+func renameTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamUpdateName,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	}
+	newName := r.FormValue("newname")
+	if newName == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: auth.ErrInvalidTeamName.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:       teamTarget(name),
+		ExtraTargets: []event.Target{teamTarget(newName)},
+		Kind:         permission.PermTeamUpdateName,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermTeamReadEvents,
+			permission.Context(permission.CtxTeam, name),
+			permission.Context(permission.CtxTeam, newName),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = auth.RenameTeam(name, newName)
+	switch err {
+	case nil:
+	case auth.ErrInvalidTeamName:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case auth.ErrTeamAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case auth.ErrTeamNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	default:
+		return err
+	}
+	return event.RenameTeam(name, newName)
+}
+
+// title: set team parent
+// path: /teams/{name}/parent
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Parent set
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func teamParentSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamUpdateParent,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	}
+	parent := r.FormValue("parent")
+	if parent != "" && !permission.Check(t, permission.PermTeamUpdateParent, permission.Context(permission.CtxTeam, parent)) {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, parent)}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamUpdateParent,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	// Deploy permissions granted in the ancestors of the team are inherited,
+	// so the users able to deploy to the team or to its new parent may have
+	// their repository access changed.
+	wantedPerms := []permission.Permission{
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, name)},
+	}
+	if parent != "" {
+		wantedPerms = append(wantedPerms, permission.Permission{
+			Scheme:  permission.PermAppDeploy,
+			Context: permission.Context(permission.CtxTeam, parent),
+		})
+	}
+	users, err := auth.ListUsersWithPermissions(wantedPerms...)
+	if err != nil {
+		return err
+	}
+	err = runWithPermSync(users, func() error {
+		return auth.SetTeamParent(name, parent)
+	})
+	switch err {
+	case auth.ErrTeamNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	case auth.ErrParentNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, parent)}
+	case auth.ErrTeamCycle:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: team tree
+// path: /teams/tree
+// method: GET
+// produce: application/json
+// responses:
+//   200: Team hierarchy
+//   204: No content
+//   401: Unauthorized
+func teamTree(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	permsForTeam := permission.PermissionRegistry.PermissionsWithContextType(permission.CtxTeam)
+	teams, err := auth.ListTeams()
+	if err != nil {
+		return err
+	}
+	perms, err := t.Permissions()
+	if err != nil {
+		return err
+	}
+	var visible []auth.Team
+	for _, team := range teams {
+		teamCtx := permission.Context(permission.CtxTeam, team.Name)
+		for _, p := range permsForTeam {
+			if permission.CheckFromPermList(perms, p, teamCtx) {
+				visible = append(visible, team)
+				break
+			}
+		}
+	}
+	tree := auth.BuildTeamTree(visible)
+	if len(tree) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tree)
+}
+
 // title: team list
 // path: /teams
 // method: GET
@@ -363,6 +710,108 @@ func teamList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(result)
 }
 
+type teamMember struct {
+	Email string   `json:"email"`
+	Roles []string `json:"roles"`
+}
+
+type teamApp struct {
+	Name      string `json:"name"`
+	TeamOwner string `json:"teamowner"`
+	Pool      string `json:"pool"`
+}
+
+type teamInfoResult struct {
+	Name   string            `json:"name"`
+	Parent string            `json:"parent,omitempty"`
+	Users  []teamMember      `json:"users"`
+	Apps   []teamApp         `json:"apps"`
+	Pools  []string          `json:"pools"`
+	Roles  []permission.Role `json:"roles"`
+}
+
+// title: team info
+// path: /teams/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: Info about the team
+//   401: Unauthorized
+//   404: Not found
+func teamInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamRead,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	}
+	team, err := auth.GetTeam(name)
+	if err != nil {
+		if err == auth.ErrTeamNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+		}
+		return err
+	}
+	result := teamInfoResult{
+		Name:   team.Name,
+		Parent: team.Parent,
+		Users:  []teamMember{},
+		Apps:   []teamApp{},
+		Pools:  []string{},
+		Roles:  []permission.Role{},
+	}
+	roles, err := permission.ListRoles()
+	if err != nil {
+		return err
+	}
+	teamRoles := map[string]permission.Role{}
+	for _, role := range roles {
+		if role.ContextType == permission.CtxTeam {
+			teamRoles[role.Name] = role
+		}
+	}
+	users, err := auth.ListUsers()
+	if err != nil {
+		return err
+	}
+	granted := map[string]bool{}
+	for _, u := range users {
+		member := teamMember{Email: u.Email}
+		for _, roleInstance := range u.Roles {
+			if _, ok := teamRoles[roleInstance.Name]; !ok || roleInstance.ContextValue != team.Name {
+				continue
+			}
+			member.Roles = append(member.Roles, roleInstance.Name)
+			granted[roleInstance.Name] = true
+		}
+		if len(member.Roles) > 0 {
+			result.Users = append(result.Users, member)
+		}
+	}
+	for _, role := range roles {
+		if granted[role.Name] {
+			result.Roles = append(result.Roles, role)
+		}
+	}
+	apps, err := app.List(&app.Filter{Extra: map[string][]string{"teams": {team.Name}}})
+	if err != nil {
+		return err
+	}
+	for _, a := range apps {
+		result.Apps = append(result.Apps, teamApp{Name: a.Name, TeamOwner: a.TeamOwner, Pool: a.Pool})
+	}
+	pools, err := provision.ListPoolsForTeam(team.Name)
+	if err != nil {
+		return err
+	}
+	for _, p := range pools {
+		result.Pools = append(result.Pools, p.Name)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
 // title: add key
 // path: /users/keys
 // method: POST
@@ -520,6 +969,12 @@ func removeUser(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
 	}
+	return deleteUser(u)
+}
+
+// deleteUser revokes the access of the user to the repositories of apps and
+// removes it from the repository manager and the auth scheme.
+func deleteUser(u *auth.User) error {
 	appNames, err := deployableApps(u, make(map[string]*permission.Role))
 	if err != nil {
 		return err
@@ -534,6 +989,133 @@ func removeUser(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	return app.AuthScheme.Remove(u)
 }
 
+// title: suspend user
+// path: /users/{email}/suspend
+// method: POST
+// responses:
+//   200: User suspended
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func suspendUser(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	email := r.URL.Query().Get(":email")
+	allowed := permission.Check(t, permission.PermUserUpdateSuspend,
+		permission.Context(permission.CtxUser, email),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	if email == t.GetUserName() {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "you cannot suspend yourself"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(email),
+		Kind:       permission.PermUserUpdateSuspend,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return runWithPermSync([]auth.User{*u}, u.Suspend)
+}
+
+// title: unlock login
+// path: /auth/unlock
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Unlocked
+//   400: Invalid data
+//   401: Unauthorized
+func unlockLogin(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	scheme, ok := app.AuthScheme.(auth.LockoutScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonManagedSchemeMsg}
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	email := r.FormValue("email")
+	ip := r.FormValue("ip")
+	if email == "" && ip == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "you must provide the email of the user or the ip address to unlock"}
+	}
+	var targets []event.Target
+	var allowedContexts []permission.PermissionContext
+	if email != "" {
+		ctx := permission.Context(permission.CtxUser, email)
+		if !permission.Check(t, permission.PermUserUpdateUnlock, ctx) {
+			return permission.ErrUnauthorized
+		}
+		targets = append(targets, userTarget(email))
+		allowedContexts = append(allowedContexts, ctx)
+	}
+	if ip != "" {
+		// Addresses may be shared by many users, only global holders of
+		// the permission may unlock them.
+		if !permission.Check(t, permission.PermUserUpdateUnlock) {
+			return permission.ErrUnauthorized
+		}
+		targets = append(targets, event.Target{Type: event.TargetTypeRemoteAddr, Value: ip})
+	}
+	evt, err := event.New(&event.Opts{
+		Target:       targets[0],
+		ExtraTargets: targets[1:],
+		Kind:         permission.PermUserUpdateUnlock,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermUserReadEvents, allowedContexts...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return scheme.UnlockLogin(email, ip)
+}
+
+// title: activate user
+// path: /users/{email}/activate
+// method: POST
+// responses:
+//   200: User activated
+//   401: Unauthorized
+//   404: Not found
+func activateUser(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	email := r.URL.Query().Get(":email")
+	allowed := permission.Check(t, permission.PermUserUpdateActivate,
+		permission.Context(permission.CtxUser, email),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(email),
+		Kind:       permission.PermUserUpdateActivate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return runWithPermSync([]auth.User{*u}, u.Activate)
+}
+
 type schemeData struct {
 	Name string          `json:"name"`
 	Data auth.SchemeInfo `json:"data"`
@@ -555,6 +1137,26 @@ func authScheme(w http.ResponseWriter, r *http.Request) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
+// title: get password policy
+// path: /auth/password-policy
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Authentication scheme has no password policy
+func passwordPolicy(w http.ResponseWriter, r *http.Request) error {
+	scheme, ok := app.AuthScheme.(auth.PasswordPolicyScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonManagedSchemeMsg}
+	}
+	policy, err := scheme.PasswordPolicy()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(policy)
+}
+
 // title: regenerate token
 // path: /users/api-key
 // method: POST
@@ -633,12 +1235,14 @@ type rolePermissionData struct {
 	Name         string
 	ContextType  string
 	ContextValue string
+	ExpiresAt    *time.Time `json:",omitempty"`
 }
 
 type apiUser struct {
 	Email       string
 	Roles       []rolePermissionData
 	Permissions []rolePermissionData
+	Suspended   bool `json:",omitempty"`
 }
 
 func createAPIUser(perms []permission.Permission, user *auth.User, roleMap map[string]*permission.Role, includeAll bool) (*apiUser, error) {
@@ -648,7 +1252,11 @@ func createAPIUser(perms []permission.Permission, user *auth.User, roleMap map[s
 		roleMap = make(map[string]*permission.Role)
 	}
 	allGlobal := true
+	now := time.Now()
 	for _, userRole := range user.Roles {
+		if userRole.Expired(now) {
+			continue
+		}
 		role := roleMap[userRole.Name]
 		if role == nil {
 			r, err := permission.FindRole(userRole.Name)
@@ -678,11 +1286,16 @@ func createAPIUser(perms []permission.Permission, user *auth.User, roleMap map[s
 		if !allPermsMatch {
 			continue
 		}
-		roleData = append(roleData, rolePermissionData{
+		data := rolePermissionData{
 			Name:         userRole.Name,
 			ContextType:  string(role.ContextType),
 			ContextValue: userRole.ContextValue,
-		})
+		}
+		if !userRole.ExpiresAt.IsZero() {
+			expiresAt := userRole.ExpiresAt
+			data.ExpiresAt = &expiresAt
+		}
+		roleData = append(roleData, data)
 		permData = append(permData, rolePerms...)
 		if role.ContextType != permission.CtxGlobal {
 			allGlobal = false
@@ -695,6 +1308,7 @@ func createAPIUser(perms []permission.Permission, user *auth.User, roleMap map[s
 		Email:       user.Email,
 		Roles:       roleData,
 		Permissions: permData,
+		Suspended:   user.Suspended,
 	}, nil
 }
 
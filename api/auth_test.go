@@ -6,6 +6,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -22,6 +23,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
@@ -305,6 +307,9 @@ func (s *AuthSuite) TestLoginShouldCreateTokenInTheDatabaseAndReturnItWithinTheR
 	n, err := conn.Tokens().Find(bson.M{"token": recorderJSON["token"]}).Count()
 	c.Assert(err, check.IsNil)
 	c.Assert(n, check.Equals, 1)
+	expires, err := time.Parse(time.RFC3339, recorderJSON["expires"])
+	c.Assert(err, check.IsNil)
+	c.Assert(expires.After(time.Now()), check.Equals, true)
 }
 
 func (s *AuthSuite) TestLoginPasswordMissing(c *check.C) {
@@ -387,8 +392,232 @@ func (s *AuthSuite) TestLogout(c *check.C) {
 	recorder := httptest.NewRecorder()
 	err = logout(recorder, request, token)
 	c.Assert(err, check.IsNil)
-	_, err = nativeScheme.Auth(token.GetValue())
+	_, err = nativeScheme.Auth(context.Background(), token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (s *AuthSuite) TestRefreshToken(c *check.C) {
+	token, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/users/tokens/refresh", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var data map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data["token"], check.Not(check.Equals), token.GetValue())
+	_, err = time.Parse(time.RFC3339, data["expires"])
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Auth(context.Background(), token.GetValue())
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	newToken, err := nativeScheme.Auth(context.Background(), data["token"])
+	c.Assert(err, check.IsNil)
+	c.Assert(newToken.GetUserName(), check.Equals, s.user.Email)
+}
+
+func (s *AuthSuite) TestRefreshTokenAppToken(c *check.C) {
+	token, err := nativeScheme.AppLogin("myapp")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/users/tokens/refresh", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Only session tokens can be refreshed.\n")
+}
+
+func (s *AuthSuite) TestLoginRecordsSessionOrigin(c *check.C) {
+	b := strings.NewReader("password=123456&remote-addr=10.0.0.1")
+	request, err := http.NewRequest("POST", "/users/"+s.user.Email+"/tokens", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("User-Agent", "tsuru-client/1.0")
+	request.RemoteAddr = "192.168.50.3:41032"
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var data map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &data)
+	c.Assert(err, check.IsNil)
+	token, err := nativeScheme.Auth(context.Background(), data["token"])
+	c.Assert(err, check.IsNil)
+	sessions, err := native.NativeScheme{}.Sessions(token)
+	c.Assert(err, check.IsNil)
+	var current *auth.Session
+	for i := range sessions {
+		if sessions[i].Current {
+			current = &sessions[i]
+		}
+	}
+	c.Assert(current, check.NotNil)
+	c.Assert(current.RemoteAddr, check.Equals, "192.168.50.3")
+	c.Assert(current.UserAgent, check.Equals, "tsuru-client/1.0")
+}
+
+func (s *AuthSuite) TestRemoteAddr(c *check.C) {
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.RemoteAddr = "192.168.50.3:41032"
+	c.Assert(remoteAddr(request), check.Equals, "192.168.50.3")
+	request.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	c.Assert(remoteAddr(request), check.Equals, "192.168.50.3")
+}
+
+func (s *AuthSuite) TestRemoteAddrTrustedProxies(c *check.C) {
+	config.Set("server:trusted-proxies", []interface{}{"192.168.50.3", "10.1.0.0/16", "invalid"})
+	defer config.Unset("server:trusted-proxies")
+	tests := []struct {
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"192.168.50.3:41032", nil, "192.168.50.3"},
+		{"192.168.50.3:41032", []string{"10.0.0.1"}, "10.0.0.1"},
+		{"192.168.50.3:41032", []string{"1.1.1.1, 10.0.0.1, 10.1.2.3"}, "10.0.0.1"},
+		{"192.168.50.3:41032", []string{"1.1.1.1", "10.0.0.1,10.1.2.3"}, "10.0.0.1"},
+		{"192.168.50.3:41032", []string{"10.1.2.3, 10.1.2.4"}, "10.1.2.3"},
+		{"10.1.9.9:41032", []string{"10.0.0.1, "}, "10.0.0.1"},
+		{"192.168.50.4:41032", []string{"10.0.0.1"}, "192.168.50.4"},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("GET", "/", nil)
+		c.Assert(err, check.IsNil)
+		request.RemoteAddr = tt.remoteAddr
+		for _, f := range tt.forwarded {
+			request.Header.Add("X-Forwarded-For", f)
+		}
+		c.Assert(remoteAddr(request), check.Equals, tt.expected, check.Commentf("%#v", tt))
+	}
+}
+
+func (s *AuthSuite) TestListSessions(c *check.C) {
+	token, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456", auth.LoginParamUserAgent: "tsuru-client/1.0"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/users/me/sessions", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var sessions []map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &sessions)
+	c.Assert(err, check.IsNil)
+	var current []map[string]interface{}
+	for _, session := range sessions {
+		c.Assert(session["token"], check.IsNil)
+		c.Assert(session["id"], check.Not(check.Equals), "")
+		if session["current"] == true {
+			current = append(current, session)
+		}
+	}
+	c.Assert(current, check.HasLen, 1)
+	c.Assert(current[0]["userAgent"], check.Equals, "tsuru-client/1.0")
+}
+
+func (s *AuthSuite) TestListSessionsNotSupported(c *check.C) {
+	oldScheme := app.AuthScheme
+	defer func() { app.AuthScheme = oldScheme }()
+	app.AuthScheme = TestScheme{}
+	request, err := http.NewRequest("GET", "/1.3/users/me/sessions", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, nonManagedSchemeMsg+"\n")
+}
+
+func (s *AuthSuite) TestRevokeSession(c *check.C) {
+	leaked, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	sessions, err := native.NativeScheme{}.Sessions(leaked)
+	c.Assert(err, check.IsNil)
+	var id string
+	for _, session := range sessions {
+		if session.Current {
+			id = session.ID
+		}
+	}
+	c.Assert(id, check.Not(check.Equals), "")
+	token, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/1.3/users/me/sessions/"+id, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(s.user.Email),
+		Owner:  s.user.Email,
+		Kind:   "user.update.session.revoke",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":id", "value": id},
+		},
+	}, eventtest.HasEvent)
+	_, err = nativeScheme.Auth(context.Background(), leaked.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "session not found\n")
+}
+
+func (s *AuthSuite) TestRevokeSessionOfAnotherUser(c *check.C) {
+	user := &auth.User{Email: "other@tsuru.io", Password: "123456"}
+	_, err := nativeScheme.Create(user)
+	c.Assert(err, check.IsNil)
+	other, err := nativeScheme.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	sessions, err := native.NativeScheme{}.Sessions(other)
+	c.Assert(err, check.IsNil)
+	c.Assert(sessions, check.HasLen, 1)
+	token, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/1.3/users/me/sessions/"+sessions[0].ID, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	_, err = nativeScheme.Auth(context.Background(), other.GetValue())
+	c.Assert(err, check.IsNil)
+}
+
+func (s *AuthSuite) TestExpiredTokenIsReported(c *check.C) {
+	token, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Tokens().Update(bson.M{"token": token.GetValue()}, bson.M{"$set": bson.M{"creation": time.Now().Add(-30 * 24 * time.Hour)}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/users/keys", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+	var payload errors.Payload
+	err = json.Unmarshal(recorder.Body.Bytes(), &payload)
+	c.Assert(err, check.IsNil)
+	c.Assert(payload.Code, check.Equals, errors.CodeTokenExpired)
+	c.Assert(payload.Message, check.Equals, "Your session has expired, please log in again")
 }
 
 func (s *AuthSuite) TestCreateTeam(c *check.C) {
@@ -534,6 +763,303 @@ func (s *AuthSuite) TestRemoveTeamGives404WhenUserDoesNotHaveAccessToTheTeam(c *
 	c.Assert(e.Message, check.Equals, `Team "painofsalvation" not found.`)
 }
 
+func (s *AuthSuite) TestRenameTeam(c *check.C) {
+	a := app.App{Name: "leviathan", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("newname=mastodon")
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name, body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = auth.GetTeam(s.team.Name)
+	c.Assert(err, check.Equals, auth.ErrTeamNotFound)
+	_, err = auth.GetTeam("mastodon")
+	c.Assert(err, check.IsNil)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.TeamOwner, check.Equals, "mastodon")
+	c.Assert(dbApp.Teams, check.DeepEquals, []string{"mastodon"})
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.update.name",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": s.team.Name},
+			{"name": "newname", "value": "mastodon"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *AuthSuite) TestRenameTeamAlreadyExists(c *check.C) {
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s?:name=%s", s.team.Name, s.team.Name), strings.NewReader("newname="+s.team2.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = renameTeam(recorder, request, s.token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusConflict)
+	c.Assert(e.Message, check.Equals, auth.ErrTeamAlreadyExists.Error())
+}
+
+func (s *AuthSuite) TestRenameTeamInvalidName(c *check.C) {
+	for _, newName := range []string{"", "1nvalid"} {
+		request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s?:name=%s", s.team.Name, s.team.Name), strings.NewReader("newname="+newName))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		err = renameTeam(recorder, request, s.token)
+		c.Assert(err, check.NotNil)
+		e, ok := err.(*errors.HTTP)
+		c.Assert(ok, check.Equals, true)
+		c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(e.Message, check.Equals, auth.ErrInvalidTeamName.Error())
+	}
+}
+
+func (s *AuthSuite) TestRenameTeamWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamUpdateName,
+		Context: permission.Context(permission.CtxTeam, s.team2.Name),
+	})
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s?:name=%s", s.team.Name, s.team.Name), strings.NewReader("newname=mastodon"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = renameTeam(recorder, request, token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusNotFound)
+	_, err = auth.GetTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *AuthSuite) TestTeamParentSet(c *check.C) {
+	request, err := http.NewRequest("PUT", "/1.3/teams/"+s.team2.Name+"/parent", strings.NewReader("parent="+s.team.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	team, err := auth.GetTeam(s.team2.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Parent, check.Equals, s.team.Name)
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team2.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.update.parent",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": s.team2.Name},
+			{"name": "parent", "value": s.team.Name},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("PUT", "/1.3/teams/"+s.team.Name+"/parent", strings.NewReader("parent="+s.team2.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrTeamCycle.Error()+"\n")
+}
+
+func (s *AuthSuite) TestTeamParentSetSyncGitRepository(c *check.C) {
+	role, err := permission.NewRole("deployer", "team", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "leviathan", Platform: "python", TeamOwner: s.team2.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	u, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "deployer")
+	err = u.AddRole("deployer", s.team.Name)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/1.3/teams/"+s.team2.Name+"/parent", strings.NewReader("parent="+s.team.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	users, err := repositorytest.Granted(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.DeepEquals, []string{s.user.Email, u.Email})
+	request, err = http.NewRequest("PUT", "/1.3/teams/"+s.team2.Name+"/parent", strings.NewReader("parent="))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	users, err = repositorytest.Granted(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.DeepEquals, []string{s.user.Email})
+}
+
+func (s *AuthSuite) TestTeamParentSetParentNotFound(c *check.C) {
+	request, err := http.NewRequest("PUT", "/1.3/teams/"+s.team.Name+"/parent", strings.NewReader("parent=unknown"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "Team \"unknown\" not found.\n")
+}
+
+func (s *AuthSuite) TestTeamParentSetWithoutPermissionInParent(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamUpdateParent,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s/parent?:name=%s", s.team.Name, s.team.Name), strings.NewReader("parent="+s.team2.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = teamParentSet(recorder, request, token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusNotFound)
+	team, err := auth.GetTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Parent, check.Equals, "")
+}
+
+func (s *AuthSuite) TestTeamParentInheritsPermissions(c *check.C) {
+	err := auth.SetTeamParent(s.team2.Name, s.team.Name)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/teams/"+s.team2.Name, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result teamInfoResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Name, check.Equals, s.team2.Name)
+	c.Assert(result.Parent, check.Equals, s.team.Name)
+}
+
+func (s *AuthSuite) TestTeamTree(c *check.C) {
+	err := auth.SetTeamParent(s.team2.Name, s.team.Name)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/teams/tree", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var tree []auth.TeamNode
+	err = json.Unmarshal(recorder.Body.Bytes(), &tree)
+	c.Assert(err, check.IsNil)
+	c.Assert(tree, check.DeepEquals, []auth.TeamNode{
+		{Name: s.team.Name, Children: []auth.TeamNode{{Name: s.team2.Name}}},
+	})
+}
+
+func (s *AuthSuite) TestTeamTreeOnlyVisibleTeams(c *check.C) {
+	err := auth.SetTeamParent(s.team2.Name, s.team.Name)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamRead,
+		Context: permission.Context(permission.CtxTeam, s.team2.Name),
+	})
+	request, err := http.NewRequest("GET", "/1.3/teams/tree", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var tree []auth.TeamNode
+	err = json.Unmarshal(recorder.Body.Bytes(), &tree)
+	c.Assert(err, check.IsNil)
+	c.Assert(tree, check.DeepEquals, []auth.TeamNode{{Name: s.team2.Name}})
+}
+
+func (s *AuthSuite) TestTeamInfo(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	a := app.App{Name: "leviathan", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("pool1", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result teamInfoResult
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	role, err := permission.FindRole("majortomteam.readtsuruteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, teamInfoResult{
+		Name: s.team.Name,
+		Users: []teamMember{
+			{Email: "majortom@groundcontrol.com", Roles: []string{role.Name}},
+		},
+		Apps:  []teamApp{{Name: "leviathan", TeamOwner: s.team.Name, Pool: "test1"}},
+		Pools: []string{"pool1"},
+		Roles: []permission.Role{role},
+	})
+}
+
+func (s *AuthSuite) TestTeamInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/teams/unknown?:name=unknown", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = teamInfo(recorder, request, s.token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusNotFound)
+	c.Assert(e.Message, check.Equals, `Team "unknown" not found.`)
+}
+
+func (s *AuthSuite) TestTeamInfoWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamRead,
+		Context: permission.Context(permission.CtxTeam, s.team2.Name),
+	})
+	request, err := http.NewRequest("GET", fmt.Sprintf("/teams/%s?:name=%s", s.team.Name, s.team.Name), nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = teamInfo(recorder, request, token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusNotFound)
+}
+
 func (s *AuthSuite) TestRemoveTeamGives403WhenTeamHasAccessToAnyApp(c *check.C) {
 	conn, _ := db.Conn()
 	defer conn.Close()
@@ -1225,7 +1751,7 @@ func (t TestScheme) Login(params map[string]string) (auth.Token, error) {
 func (t TestScheme) Logout(token string) error {
 	return nil
 }
-func (t TestScheme) Auth(token string) (auth.Token, error) {
+func (t TestScheme) Auth(ctx context.Context, token string) (auth.Token, error) {
 	return nil, nil
 }
 func (t TestScheme) Info() (auth.SchemeInfo, error) {
@@ -1259,6 +1785,53 @@ func (s *AuthSuite) TestAuthScheme(c *check.C) {
 	c.Assert(parsed["data"], check.DeepEquals, map[string]interface{}{"foo": "bar", "foo2": "bar2"})
 }
 
+func (s *AuthSuite) TestPasswordPolicy(c *check.C) {
+	config.Set("auth:password-policy:min-length", 8)
+	config.Set("auth:password-policy:require-digit", true)
+	config.Set("auth:password-policy:history", 3)
+	defer config.Unset("auth:password-policy")
+	request, err := http.NewRequest("GET", "/1.3/auth/password-policy", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var policy auth.PasswordPolicy
+	err = json.NewDecoder(recorder.Body).Decode(&policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, auth.PasswordPolicy{MinLength: 8, MaxLength: 50, RequireDigit: true, History: 3})
+}
+
+func (s *AuthSuite) TestPasswordPolicyNotSupported(c *check.C) {
+	oldScheme := app.AuthScheme
+	defer func() { app.AuthScheme = oldScheme }()
+	app.AuthScheme = TestScheme{}
+	request, err := http.NewRequest("GET", "/1.3/auth/password-policy", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, nonManagedSchemeMsg+"\n")
+}
+
+func (s *AuthSuite) TestChangePasswordBreakingPolicy(c *check.C) {
+	config.Set("auth:password-policy:require-uppercase", true)
+	config.Set("auth:password-policy:require-digit", true)
+	defer config.Unset("auth:password-policy")
+	body := strings.NewReader("old=123456&new=newpassword&confirm=newpassword")
+	request, err := http.NewRequest("PUT", "/users/password", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "password must contain an uppercase letter and a digit\n")
+}
+
 func (s *AuthSuite) TestRegenerateAPITokenHandler(c *check.C) {
 	conn, _ := db.Conn()
 	defer conn.Close()
@@ -1741,3 +2314,215 @@ func (s *AuthSuite) BenchmarkListUsersManyUsers(c *check.C) {
 	sort.Strings(expectedNames)
 	c.Assert(names, check.DeepEquals, expectedNames)
 }
+
+func (s *AuthSuite) TestLoginLockedOut(c *check.C) {
+	config.Set("auth:login-rate-limit:max-attempts-per-user", 1)
+	defer config.Unset("auth:login-rate-limit")
+	m := RunServer(true)
+	for _, password := range []string{"wrong-password", "123456"} {
+		request, err := http.NewRequest("POST", "/users/"+s.user.Email+"/tokens", strings.NewReader("password="+password))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		if password == "wrong-password" {
+			c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+			continue
+		}
+		c.Assert(recorder.Code, check.Equals, http.StatusTooManyRequests)
+		c.Assert(recorder.Body.String(), check.Matches, "too many failed login attempts, try again after .*\n")
+		c.Assert(recorder.Header().Get("Retry-After"), check.Not(check.Equals), "")
+	}
+	request, err := http.NewRequest("POST", "/1.3/auth/unlock", strings.NewReader("email="+s.user.Email))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(s.user.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.unlock",
+		StartCustomData: []map[string]interface{}{
+			{"name": "email", "value": s.user.Email},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("POST", "/users/"+s.user.Email+"/tokens", strings.NewReader("password=123456"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *AuthSuite) TestUnlockLoginAddressRequiresGlobalPermission(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "unlocker", permission.Permission{
+		Scheme:  permission.PermUserUpdateUnlock,
+		Context: permission.Context(permission.CtxUser, "someone@tsuru.io"),
+	})
+	m := RunServer(true)
+	request, err := http.NewRequest("POST", "/1.3/auth/unlock", strings.NewReader("email=someone@tsuru.io&ip=10.0.0.1"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	request, err = http.NewRequest("POST", "/1.3/auth/unlock", strings.NewReader("email=someone@tsuru.io"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	request, err = http.NewRequest("POST", "/1.3/auth/unlock", strings.NewReader("ip=10.0.0.1"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeRemoteAddr, Value: "10.0.0.1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.unlock",
+		StartCustomData: []map[string]interface{}{
+			{"name": "ip", "value": "10.0.0.1"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("POST", "/1.3/auth/unlock", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *AuthSuite) TestSuspendUser(c *check.C) {
+	role, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "leviathan", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	u, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "tosuspend")
+	err = u.AddRole("deployer", a.Name)
+	c.Assert(err, check.IsNil)
+	err = repository.Manager().GrantAccess(a.Name, u.Email)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.3/users/"+u.Email+"/suspend", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	u, err = auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Suspended, check.Equals, true)
+	c.Assert(u.Roles, check.HasLen, 1)
+	users, err := repositorytest.Granted(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.DeepEquals, []string{s.user.Email})
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(u.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.suspend",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":email", "value": u.Email},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/users/info", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *AuthSuite) TestSuspendUserHimself(c *check.C) {
+	request, err := http.NewRequest("POST", "/1.3/users/"+s.user.Email+"/suspend", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "you cannot suspend yourself\n")
+}
+
+func (s *AuthSuite) TestSuspendUserNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/1.3/users/unknown@tsuru.io/suspend", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *AuthSuite) TestSuspendUserNoPermission(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("POST", "/1.3/users/"+s.user.Email+"/suspend", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Suspended, check.Equals, false)
+}
+
+func (s *AuthSuite) TestSuspendedUserTokenIsRejected(c *check.C) {
+	u, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "suspended")
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Users().Update(bson.M{"email": u.Email}, bson.M{"$set": bson.M{"suspended": true}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/users/info", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrUserSuspended.Error()+"\n")
+	request, err = http.NewRequest("POST", "/users/"+u.Email+"/tokens", strings.NewReader("password=123456"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrUserSuspended.Error()+"\n")
+}
+
+func (s *AuthSuite) TestActivateUser(c *check.C) {
+	u, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "toactivate")
+	err := u.Suspend()
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.3/users/"+u.Email+"/activate", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	u, err = auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Suspended, check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(u.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.activate",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":email", "value": u.Email},
+		},
+	}, eventtest.HasEvent)
+	_, err = nativeScheme.Login(map[string]string{"email": u.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+}
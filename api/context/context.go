@@ -5,12 +5,15 @@
 package context
 
 import (
+	stdcontext "context"
 	"net/http"
 
 	"github.com/gorilla/context"
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
 )
 
 const (
@@ -19,6 +22,7 @@ const (
 	delayedHandlerKey
 	preventUnlockKey
 	appContextKey
+	tokenExpiredKey
 )
 
 func Clear(r *http.Request) {
@@ -88,6 +92,19 @@ func IsPreventUnlock(r *http.Request) bool {
 	return false
 }
 
+// SetTokenExpired marks the request as carrying a token that was valid
+// but has expired.
+func SetTokenExpired(r *http.Request) {
+	context.Set(r, tokenExpiredKey, true)
+}
+
+func IsTokenExpired(r *http.Request) bool {
+	if v := context.Get(r, tokenExpiredKey); v != nil {
+		return v.(bool)
+	}
+	return false
+}
+
 func SetRequestID(r *http.Request, requestIDHeader, requestID string) {
 	context.Set(r, requestIDHeader, requestID)
 }
@@ -99,3 +116,20 @@ func GetRequestID(r *http.Request, requestIDHeader string) string {
 	}
 	return requestID.(string)
 }
+
+// RequestContext returns the context of the request carrying its auth token
+// and request id, to be given to the operations started by the request. The
+// request id is the one in the header named by the "request-id-header"
+// setting.
+func RequestContext(r *http.Request) stdcontext.Context {
+	ctx := r.Context()
+	if t := GetAuthToken(r); t != nil {
+		ctx = auth.ContextWithToken(ctx, t)
+	}
+	if requestIDHeader, _ := config.GetString("request-id-header"); requestIDHeader != "" {
+		if requestID := GetRequestID(r, requestIDHeader); requestID != "" {
+			ctx = log.ContextWithRequestID(ctx, requestID)
+		}
+	}
+	return ctx
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/tsuru/tsuru/auth/native"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/repository/repositorytest"
 	"gopkg.in/check.v1"
 )
@@ -113,6 +114,14 @@ func (s *S) TestSetPreventUnlock(c *check.C) {
 	c.Assert(IsPreventUnlock(r), check.Equals, true)
 }
 
+func (s *S) TestSetTokenExpired(c *check.C) {
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(IsTokenExpired(r), check.Equals, false)
+	SetTokenExpired(r)
+	c.Assert(IsTokenExpired(r), check.Equals, true)
+}
+
 func (s *S) TestGetApp(c *check.C) {
 	r, err := http.NewRequest("GET", "/", nil)
 	c.Assert(err, check.IsNil)
@@ -132,3 +141,18 @@ func (s *S) TestRequestID(c *check.C) {
 	id = GetRequestID(r, "Request-ID")
 	c.Assert(id, check.Equals, "test")
 }
+
+func (s *S) TestRequestContext(c *check.C) {
+	config.Set("request-id-header", "Request-ID")
+	defer config.Unset("request-id-header")
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	ctx := RequestContext(r)
+	c.Assert(auth.TokenFromContext(ctx), check.IsNil)
+	c.Assert(log.RequestIDFromContext(ctx), check.Equals, "")
+	SetAuthToken(r, s.token)
+	SetRequestID(r, "Request-ID", "test")
+	ctx = RequestContext(r)
+	c.Assert(auth.TokenFromContext(ctx), check.Equals, s.token)
+	c.Assert(log.RequestIDFromContext(ctx), check.Equals, "test")
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+)
+
+// title: app dependency list
+// path: /apps/{app}/dependencies
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func appDependencyList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppRead, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	if len(a.Dependencies) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.Dependencies)
+}
+
+// title: app dependency add
+// path: /apps/{app}/dependencies
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Dependency added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or service instance not found
+//   409: Dependency already exists
+func appDependencyAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateDependencyAdd, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	dep := app.AppDependency{
+		App:      r.FormValue("app"),
+		Service:  r.FormValue("service"),
+		Instance: r.FormValue("instance"),
+	}
+	err = checkDependencyPermission(t, dep)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateDependencyAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.AddDependency(dep)
+	switch err.(type) {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	case *terrors.ValidationError, *app.ErrDependencyCycle:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	switch err {
+	case app.ErrDependencyAlreadyExists:
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case app.ErrAppNotFound, service.ErrServiceInstanceNotFound:
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// checkDependencyPermission checks whether the user is able to see the app
+// or the service instance the dependency points to.
+func checkDependencyPermission(t auth.Token, dep app.AppDependency) error {
+	if dep.App != "" {
+		depApp, err := app.GetByName(dep.App)
+		if err == app.ErrAppNotFound {
+			return &terrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("dependency app %q not found", dep.App)}
+		}
+		if err != nil {
+			return err
+		}
+		if !permission.Check(t, permission.PermAppRead, contextsForApp(depApp)...) {
+			return permission.ErrUnauthorized
+		}
+		return nil
+	}
+	if dep.Service == "" || dep.Instance == "" {
+		return nil
+	}
+	si, err := getServiceInstanceOrError(dep.Service, dep.Instance)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermServiceInstanceReadStatus, contextsForServiceInstance(si, dep.Service)...) {
+		return permission.ErrUnauthorized
+	}
+	return nil
+}
+
+// title: app dependency remove
+// path: /apps/{app}/dependencies
+// method: DELETE
+// responses:
+//   200: Dependency removed
+//   401: Unauthorized
+//   404: Not found
+func appDependencyRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateDependencyRemove, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateDependencyRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RemoveDependency(app.AppDependency{
+		App:      r.URL.Query().Get("app"),
+		Service:  r.URL.Query().Get("service"),
+		Instance: r.URL.Query().Get("instance"),
+	})
+	if err == app.ErrDependencyNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app dependency graph
+// path: /apps/{app}/dependencies/graph
+// method: GET
+// produce: application/json or text/vnd.graphviz
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: App not found
+func appDependencyGraph(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppRead, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	graph, err := a.DependencyGraph()
+	if err != nil {
+		return err
+	}
+	if r.URL.Query().Get("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		_, err = fmt.Fprint(w, graph.DOT())
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(graph)
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppDependencyAddListAndRemove(c *check.C) {
+	for _, name := range []string{"web", "api"} {
+		a := app.App{Name: name, Platform: "zend", TeamOwner: s.team.Name}
+		err := app.CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+	}
+	body := strings.NewReader("app=api")
+	req, err := http.NewRequest("POST", "/1.3/apps/web/dependencies", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("web"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.dependency.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "web"},
+			{"name": "app", "value": "api"},
+		},
+	}, eventtest.HasEvent)
+	body = strings.NewReader("app=api")
+	req, err = http.NewRequest("POST", "/1.3/apps/web/dependencies", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	req, err = http.NewRequest("GET", "/1.3/apps/web/dependencies", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var deps []app.AppDependency
+	err = json.NewDecoder(rec.Body).Decode(&deps)
+	c.Assert(err, check.IsNil)
+	c.Assert(deps, check.DeepEquals, []app.AppDependency{{App: "api"}})
+	req, err = http.NewRequest("DELETE", "/1.3/apps/web/dependencies?app=api", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("web"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.dependency.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "web"},
+			{"name": "app", "value": "api"},
+		},
+	}, eventtest.HasEvent)
+	req, err = http.NewRequest("GET", "/1.3/apps/web/dependencies", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	req, err = http.NewRequest("DELETE", "/1.3/apps/web/dependencies?app=api", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+	c.Assert(rec.Body.String(), check.Equals, app.ErrDependencyNotFound.Error()+"\n")
+}
+
+func (s *S) TestAppDependencyAddInvalid(c *check.C) {
+	for _, name := range []string{"web", "api"} {
+		a := app.App{Name: name, Platform: "zend", TeamOwner: s.team.Name}
+		err := app.CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+	}
+	a, err := app.GetByName("api")
+	c.Assert(err, check.IsNil)
+	err = a.AddDependency(app.AppDependency{App: "web"})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body string
+		code int
+		msg  string
+	}{
+		{"", http.StatusBadRequest, "the app or the service and the instance of the dependency are required"},
+		{"app=web", http.StatusBadRequest, "an app can't depend on itself"},
+		{"app=api", http.StatusBadRequest, "dependency cycle: web -> api -> web"},
+		{"app=unknown", http.StatusNotFound, `dependency app "unknown" not found`},
+		{"service=mysql&instance=db", http.StatusNotFound, "service instance not found"},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/1.3/apps/web/dependencies", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, tt.code, check.Commentf("body: %q", tt.body))
+		c.Assert(rec.Body.String(), check.Equals, tt.msg+"\n")
+	}
+}
+
+func (s *S) TestAppDependencyAddRequiresPermissionOnDependency(c *check.C) {
+	team := auth.Team{Name: "angra"}
+	err := s.conn.Teams().Insert(team)
+	c.Assert(err, check.IsNil)
+	web := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&web, s.user)
+	c.Assert(err, check.IsNil)
+	dep := app.App{Name: "api", Platform: "zend", TeamOwner: team.Name}
+	err = app.CreateApp(&dep, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDependencyAdd,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	req, err := http.NewRequest("POST", "/1.3/apps/web/dependencies", strings.NewReader("app=api"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+	a, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Dependencies, check.HasLen, 0)
+}
+
+func (s *S) TestAppDependencyAddRequiresPermission(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDependencyRemove,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	req, err := http.NewRequest("POST", "/1.3/apps/web/dependencies", strings.NewReader("app=api"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppDependencyGraph(c *check.C) {
+	for _, name := range []string{"web", "api"} {
+		a := app.App{Name: name, Platform: "zend", TeamOwner: s.team.Name}
+		err := app.CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+	}
+	a, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	err = a.AddDependency(app.AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/1.3/apps/api/dependencies/graph", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var graph app.DependencyGraph
+	err = json.NewDecoder(rec.Body).Decode(&graph)
+	c.Assert(err, check.IsNil)
+	c.Assert(graph, check.DeepEquals, app.DependencyGraph{
+		App: "api",
+		Nodes: []app.DependencyNode{
+			{Type: app.DependencyTypeApp, Name: "api"},
+			{Type: app.DependencyTypeApp, Name: "web"},
+		},
+		Edges: []app.DependencyEdge{
+			{From: "web", To: app.DependencyNode{Type: app.DependencyTypeApp, Name: "api"}},
+		},
+		Dependents: []string{"web"},
+	})
+	req, err = http.NewRequest("GET", "/1.3/apps/api/dependencies/graph?format=dot", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "text/vnd.graphviz")
+	c.Assert(rec.Body.String(), check.Equals, `digraph "api" {
+	"api" [shape=box];
+	"web" [shape=box];
+	"web" -> "api";
+}
+`)
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"strconv"
@@ -15,27 +16,41 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/repository"
 )
 
+const defaultDeployMaxWaitLock = 10 * time.Minute
+
+func init() {
+	event.SetScheduledExecutor(permission.PermAppDeploy.FullName(), scheduledDeploy)
+}
+
 // title: app deploy
 // path: /apps/{appname}/deploy
 // method: POST
 // consume: application/x-www-form-urlencoded
 // responses:
 //   200: OK
+//   201: Deploy scheduled
+//   202: Deploy pending approval
 //   400: Invalid data
 //   403: Forbidden
 //   404: Not found
+//   409: Scheduled inside a freeze window
 func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	var file multipart.File
 	var fileSize int64
+	uploadStart := time.Now()
+	var uploadDuration time.Duration
 	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
 		file, _, err = r.FormFile("file")
 		if err != nil {
@@ -50,13 +65,40 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		}
 		file.Seek(0, io.SeekStart)
 		defer file.Close()
+		uploadDuration = time.Since(uploadStart)
+	}
+	chunks := r.FormValue("chunks")
+	if chunks != "" && file != nil {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "chunks can't be used along with an uploaded file.",
+		}
 	}
 	archiveURL := r.FormValue("archive-url")
 	image := r.FormValue("image")
-	if image == "" && archiveURL == "" && file == nil {
+	gitURL := r.FormValue("git-url")
+	gitRef := r.FormValue("ref")
+	if image == "" && archiveURL == "" && gitURL == "" && file == nil && chunks == "" {
 		return &tsuruErrors.HTTP{
 			Code:    http.StatusBadRequest,
-			Message: "you must specify either the archive-url, a image url or upload a file.",
+			Message: "you must specify either the archive-url, a image url, a git url or upload a file.",
+		}
+	}
+	if gitURL != "" {
+		if image != "" || archiveURL != "" || file != nil || chunks != "" {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "git-url can't be used along with archive-url, image or an uploaded file.",
+			}
+		}
+		err = app.ValidateGitSource(gitURL, gitRef)
+		if err != nil {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+	} else if gitRef != "" {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "ref can only be used along with git-url.",
 		}
 	}
 	commit := r.FormValue("commit")
@@ -66,6 +108,9 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if image != "" {
 		origin = "image"
 	}
+	if gitURL != "" && origin == "" {
+		origin = "git"
+	}
 	if origin != "" {
 		if !app.ValidateOrigin(origin) {
 			return &tsuruErrors.HTTP{
@@ -88,6 +133,23 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if err != nil {
 		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
 	}
+	if chunks != "" {
+		hashes := strings.Split(chunks, ",")
+		for _, hash := range hashes {
+			if !app.ValidDeployChunkHash(hash) {
+				return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid chunk hash %q", hash)}
+			}
+		}
+		file, fileSize, err = instance.AssembleDeployChunks(hashes)
+		if err != nil {
+			if _, ok := err.(*app.DeployChunkNotFoundError); ok {
+				return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+			}
+			return err
+		}
+		defer file.Close()
+		uploadDuration = time.Since(uploadStart)
+	}
 	var build bool
 	buildString := r.FormValue("build")
 	if buildString != "" {
@@ -99,6 +161,44 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 			}
 		}
 	}
+	var dryRun bool
+	if dryRunString := r.FormValue("dry-run"); dryRunString != "" {
+		dryRun, err = strconv.ParseBool(dryRunString)
+		if err != nil {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			}
+		}
+	}
+	strategy, err := deployStrategyFromForm(r)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	waitLock, err := deployWaitLockFromForm(r)
+	if err != nil {
+		return err
+	}
+	var runAt time.Time
+	if v := r.FormValue("run-at"); v != "" {
+		runAt, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "run-at must be a RFC 3339 time"}
+		}
+		if file != nil {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "uploaded files can't be deployed at a scheduled time, use archive-url, image or git-url.",
+			}
+		}
+	}
+	requireApproval := deployRequiresApproval(instance, t)
+	if requireApproval && file != nil {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "uploaded files can't be deployed to apps requiring deploy approval, use archive-url, image or git-url.",
+		}
+	}
 	message := r.FormValue("message")
 	if commit != "" && message == "" {
 		var messages []string
@@ -124,6 +224,10 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		Origin:     origin,
 		Build:      build,
 		Message:    message,
+		Signature:  r.FormValue("signature"),
+		GitURL:     gitURL,
+		GitRef:     gitRef,
+		Strategy:   strategy,
 	}
 	opts.GetKind()
 	if t.GetAppName() != app.InternalAppName {
@@ -133,6 +237,7 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		}
 	}
 	var imageID string
+	var signature *app.SignatureVerification
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
 		Kind:          permission.PermAppDeploy,
@@ -141,15 +246,43 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(instance)...),
 		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(instance)...),
 		Cancelable:    true,
+		Context:       context.RequestContext(r),
+		WaitLock:      waitLock,
+		DryRun:        dryRun,
+		RunAt:         runAt,
+
+		RequireApproval: requireApproval,
+		AllowedApprove:  event.Allowed(permission.PermAppApproveDeploy, contextsForApp(instance)...),
 	})
 	if err != nil {
+		if _, ok := err.(*event.ErrEventBlocked); ok && !runAt.IsZero() {
+			return &tsuruErrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+		}
 		return err
 	}
-	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
+	if evt.DryRun() {
+		fmt.Fprintln(w, "Dry run OK, the deploy would be started.")
+		return nil
+	}
+	if evt.Scheduled() {
+		return writeScheduledDeploy(w, evt)
+	}
+	defer func() { evt.DoneCustomData(err, app.DeployEndData(evt, imageID, signature)) }()
+	if file != nil {
+		evt.AddPhase(provision.DeployPhaseUpload, uploadStart, uploadDuration)
+	}
 	opts.Event = evt
 	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
 	defer writer.Stop()
 	opts.OutputStream = writer
+	evt.SetLogWriter(writer)
+	signature, err = app.VerifyDeploySignature(&opts)
+	if err != nil {
+		return err
+	}
+	if file == nil && opts.File != nil {
+		defer opts.File.Close()
+	}
 	imageID, err = app.Deploy(opts)
 	if err == nil {
 		fmt.Fprintln(w, "\nOK")
@@ -157,9 +290,197 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	return err
 }
 
+// deployStrategyFromForm reads the strategy of the deploy from the strategy,
+// steps and step-interval (in seconds) form values.
+func deployStrategyFromForm(r *http.Request) (provision.DeployStrategy, error) {
+	strategy := provision.DeployStrategy{Name: r.FormValue("strategy")}
+	if v := r.FormValue("steps"); v != "" {
+		steps, err := strconv.Atoi(v)
+		if err != nil {
+			return strategy, errors.New("steps must be an integer")
+		}
+		strategy.Steps = steps
+	}
+	if v := r.FormValue("step-interval"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return strategy, errors.New("step-interval must be a number of seconds")
+		}
+		strategy.StepInterval = time.Duration(seconds * float64(time.Second))
+	}
+	return strategy, strategy.Validate()
+}
+
+// deployWaitLockFromForm reads, from the wait-lock form value in seconds,
+// for how long the deploy waits for the app lock held by another event
+// instead of failing right away. Waiting is limited to the
+// deploy:max-wait-lock config, 600 seconds by default.
+func deployWaitLockFromForm(r *http.Request) (time.Duration, error) {
+	v := r.FormValue("wait-lock")
+	if v == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil || seconds < 0 {
+		return 0, &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "wait-lock must be a number of seconds"}
+	}
+	max := defaultDeployMaxWaitLock
+	if maxSeconds, _ := config.GetFloat("deploy:max-wait-lock"); maxSeconds > 0 {
+		max = time.Duration(maxSeconds * float64(time.Second))
+	}
+	waitLock := time.Duration(seconds * float64(time.Second))
+	if waitLock > max {
+		waitLock = max
+	}
+	return waitLock, nil
+}
+
+// title: deploy chunks missing
+// path: /apps/{appname}/deploy/chunks/missing
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   403: Forbidden
+//   404: Not found
+func deployChunksMissing(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	instance, err := app.GetByName(r.URL.Query().Get(":appname"))
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if t.IsAppToken() {
+		err = checkDeployChunksAppToken(t, instance)
+		if err != nil {
+			return err
+		}
+	} else if !permission.Check(t, permission.PermAppDeployUpload, contextsForApp(instance)...) {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	hashes := r.Form["hash"]
+	for _, hash := range hashes {
+		if !app.ValidDeployChunkHash(hash) {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid chunk hash %q", hash)}
+		}
+	}
+	missing, err := instance.MissingDeployChunks(hashes)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"missing":  missing,
+		"max-size": app.DeployChunkMaxSize(),
+	})
+}
+
+// title: deploy chunk upload
+// path: /apps/{appname}/deploy/chunks/{hash}
+// method: PUT
+// consume: application/octet-stream
+// responses:
+//   201: Chunk stored
+//   400: Invalid data
+//   403: Forbidden
+//   404: Not found
+//   413: Chunk too large
+func deployChunkUpload(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hash := r.URL.Query().Get(":hash")
+	if !app.ValidDeployChunkHash(hash) {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid chunk hash %q", hash)}
+	}
+	instance, err := app.GetByName(r.URL.Query().Get(":appname"))
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if t.IsAppToken() {
+		err = checkDeployChunksAppToken(t, instance)
+		if err != nil {
+			return err
+		}
+	} else if !permission.Check(t, permission.PermAppDeployUpload, contextsForApp(instance)...) {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	err = instance.SaveDeployChunk(hash, r.Body)
+	switch err {
+	case nil:
+	case app.ErrDeployChunkHashMismatch:
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case app.ErrDeployChunkTooLarge:
+		return &tsuruErrors.HTTP{Code: http.StatusRequestEntityTooLarge, Message: err.Error()}
+	default:
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// checkDeployChunksAppToken checks that app tokens are only used to upload
+// chunks to their own apps.
+func checkDeployChunksAppToken(t auth.Token, instance *app.App) error {
+	if t.GetAppName() != instance.Name && t.GetAppName() != app.InternalAppName {
+		return &tsuruErrors.HTTP{Code: http.StatusUnauthorized, Message: "invalid app token"}
+	}
+	return nil
+}
+
+// scheduledDeploy runs a deploy scheduled with the run-at parameter, using
+// the options stored in the event and the current state of the app.
+// deployRequiresApproval returns whether deploys of the app requested with
+// the token are scheduled, only to be started once approved by another user.
+// Deploys started by tsuru itself never require approval.
+func deployRequiresApproval(a *app.App, t auth.Token) bool {
+	return a.RequireDeployApproval && t.GetAppName() != app.InternalAppName
+}
+
+// writeScheduledDeploy answers the request of a scheduled deploy with the
+// scheduled event, accepted when it's pending approval.
+func writeScheduledDeploy(w http.ResponseWriter, evt *event.Event) error {
+	sched, err := event.GetScheduled(evt.UniqueID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if sched.PendingApproval {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return json.NewEncoder(w).Encode(sched)
+}
+
+func scheduledDeploy(evt *event.Event) error {
+	var opts app.DeployOptions
+	err := evt.StartData(&opts)
+	if err != nil {
+		return err
+	}
+	opts.App, err = app.GetByName(evt.Target.Value)
+	if err != nil {
+		return err
+	}
+	opts.Event = evt
+	opts.OutputStream = ioutil.Discard
+	var imageID string
+	signature, err := app.VerifyDeploySignature(&opts)
+	if err == nil {
+		if opts.File != nil {
+			defer opts.File.Close()
+		}
+		imageID, err = app.Deploy(opts)
+	}
+	evt.DoneCustomData(err, app.DeployEndData(evt, imageID, signature))
+	return err
+}
+
 func permSchemeForDeploy(opts app.DeployOptions) *permission.PermissionScheme {
 	switch opts.GetKind() {
-	case app.DeployGit:
+	case app.DeployGit, app.DeployGitURL:
 		return permission.PermAppDeployGit
 	case app.DeployImage:
 		return permission.PermAppDeployImage
@@ -217,6 +538,7 @@ func diffDeploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 // produce: application/x-json-stream
 // responses:
 //   200: OK
+//   202: Rollback pending approval
 //   400: Invalid data
 //   403: Forbidden
 //   404: Not found
@@ -242,23 +564,23 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 			}
 		}
 	}
-	w.Header().Set("Content-Type", "application/x-json-stream")
-	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
-	defer keepAliveWriter.Stop()
-	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	waitLock, err := deployWaitLockFromForm(r)
+	if err != nil {
+		return err
+	}
 	opts := app.DeployOptions{
-		App:          instance,
-		OutputStream: writer,
-		Image:        image,
-		User:         t.GetUserName(),
-		Origin:       origin,
-		Rollback:     true,
+		App:      instance,
+		Image:    image,
+		User:     t.GetUserName(),
+		Origin:   origin,
+		Rollback: true,
 	}
 	opts.GetKind()
 	canRollback := permission.Check(t, permSchemeForDeploy(opts), contextsForApp(instance)...)
 	if !canRollback {
 		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
 	}
+	app.PrepareRollback(&opts)
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -268,11 +590,24 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(instance)...),
 		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(instance)...),
 		Cancelable:    true,
+		Context:       context.RequestContext(r),
+		WaitLock:      waitLock,
+
+		RequireApproval: deployRequiresApproval(instance, t),
+		AllowedApprove:  event.Allowed(permission.PermAppApproveDeploy, contextsForApp(instance)...),
 	})
 	if err != nil {
 		return err
 	}
-	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
+	if evt.Scheduled() {
+		return writeScheduledDeploy(w, evt)
+	}
+	defer func() { evt.DoneCustomData(err, app.DeployEndData(evt, imageID, nil)) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	opts.OutputStream = writer
 	opts.Event = evt
 	imageID, err = app.Deploy(opts)
 	if err != nil {
@@ -281,6 +616,37 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	return nil
 }
 
+// title: rollback image list
+// path: /apps/{appname}/deploy/rollback
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   403: Forbidden
+//   404: Not found
+func deployRollbackImages(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	canRead := permission.Check(t, permission.PermAppReadDeploy, contextsForApp(instance)...)
+	if !canRead {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	images, err := app.ListRollbackImages(appName)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(images)
+}
+
 // title: deploy list
 // path: /deploys
 // method: GET
@@ -312,6 +678,82 @@ func deploysList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(deploys)
 }
 
+// title: unsigned deploys report
+// path: /deploys/unsigned
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func unsignedDeploysReport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermAppReadDeploy) {
+		return permission.ErrUnauthorized
+	}
+	since := time.Now().UTC().Add(-30 * 24 * time.Hour)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid since, it must be in RFC3339 format",
+			}
+		}
+	}
+	deploys, err := app.ListUnsignedDeploys(since)
+	if err != nil {
+		return err
+	}
+	if len(deploys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deploys)
+}
+
+// title: deploy timing stats
+// path: /deploys/timings
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func deployTimingStats(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermAppReadDeploy)
+	if len(contexts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	since := time.Now().UTC().Add(-30 * 24 * time.Hour)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid since, it must be in RFC3339 format",
+			}
+		}
+	}
+	filter := appFilterByContext(contexts, nil)
+	filter.Name = r.URL.Query().Get("app")
+	stats, err := app.DeployTimingStats(filter, since)
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(stats)
+}
+
 // title: deploy info
 // path: /deploys/{deploy}
 // method: GET
@@ -341,6 +783,76 @@ func deployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(deploy)
 }
 
+// title: deploy pause info
+// path: /apps/{appname}/deploy/pause
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: Deploys are not paused
+//   403: Forbidden
+//   404: Not found
+func deployPauseInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	canRead := permission.Check(t, permission.PermAppReadDeploy, contextsForApp(instance)...)
+	if !canRead {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	pause, err := app.GetDeployPause(appName)
+	if err == app.ErrDeployNotPaused {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(struct {
+		*app.DeployPause
+		RollbackCommand string `json:",omitempty"`
+	}{pause, pause.RollbackCommand()})
+}
+
+// title: deploy resume
+// path: /apps/{appname}/deploy/pause
+// method: DELETE
+// responses:
+//   200: OK
+//   400: Deploys are not paused
+//   403: Forbidden
+//   404: Not found
+func deployResume(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	canResume := permission.Check(t, permission.PermAppDeployResume, contextsForApp(instance)...)
+	if !canResume {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppDeployResume,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(instance)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.ResumeDeploys(appName)
+	if err == app.ErrDeployNotPaused {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
 // title: rebuild
 // path: /apps/{appname}/deploy/rebuild
 // method: POST
@@ -348,6 +860,7 @@ func deployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 // produce: application/x-json-stream
 // responses:
 //   200: OK
+//   202: Rebuild pending approval
 //   400: Invalid data
 //   403: Forbidden
 //   404: Not found
@@ -364,16 +877,15 @@ func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			Message: "Invalid deployment origin",
 		}
 	}
-	w.Header().Set("Content-Type", "application/x-json-stream")
-	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
-	defer keepAliveWriter.Stop()
-	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	waitLock, err := deployWaitLockFromForm(r)
+	if err != nil {
+		return err
+	}
 	opts := app.DeployOptions{
-		App:          instance,
-		OutputStream: writer,
-		User:         t.GetUserName(),
-		Origin:       origin,
-		Kind:         app.DeployRebuild,
+		App:    instance,
+		User:   t.GetUserName(),
+		Origin: origin,
+		Kind:   app.DeployRebuild,
 	}
 	canDeploy := permission.Check(t, permSchemeForDeploy(opts), contextsForApp(instance)...)
 	if !canDeploy {
@@ -388,11 +900,24 @@ func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(instance)...),
 		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(instance)...),
 		Cancelable:    true,
+		Context:       context.RequestContext(r),
+		WaitLock:      waitLock,
+
+		RequireApproval: deployRequiresApproval(instance, t),
+		AllowedApprove:  event.Allowed(permission.PermAppApproveDeploy, contextsForApp(instance)...),
 	})
 	if err != nil {
 		return err
 	}
-	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
+	if evt.Scheduled() {
+		return writeScheduledDeploy(w, evt)
+	}
+	defer func() { evt.DoneCustomData(err, app.DeployEndData(evt, imageID, nil)) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	opts.OutputStream = writer
 	opts.Event = evt
 	imageID, err = app.Deploy(opts)
 	if err != nil {
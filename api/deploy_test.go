@@ -6,6 +6,8 @@ package api
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -518,6 +520,174 @@ func (s *DeploySuite) TestDeployDockerImage(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *DeploySuite) TestDeployWithStrategy(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	body := strings.NewReader("image=127.0.0.1:5000/tsuru/otherapp&strategy=canary&steps=4&step-interval=60")
+	request, err := http.NewRequest("POST", url, body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)---- Deploying with the canary in 4 steps strategy ----.*Image deploy called\nOK\n`)
+	c.Assert(s.provisioner.LastDeployStrategy(&a), check.DeepEquals, provision.DeployStrategy{
+		Name:         provision.DeployStrategyCanary,
+		Steps:        4,
+		StepInterval: time.Minute,
+	})
+}
+
+func (s *DeploySuite) TestDeployInvalidStrategy(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{"strategy=recreate", `invalid deploy strategy "recreate", valid strategies are "rolling", "blue-green" and "canary"`},
+		{"strategy=canary&steps=many", "steps must be an integer"},
+		{"strategy=canary&step-interval=1m", "step-interval must be a number of seconds"},
+		{"strategy=blue-green&steps=3", `steps are only allowed in the "canary" strategy`},
+	}
+	for _, tt := range tests {
+		url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+		request, err := http.NewRequest("POST", url, strings.NewReader("image=127.0.0.1:5000/tsuru/otherapp&"+tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, tt.message+"\n")
+	}
+}
+
+func (s *DeploySuite) TestDeployDockerImageSignatureEnforced(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	defer config.Unset("deploy:signature")
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("image=127.0.0.1:5000/tsuru/otherapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*signature invalid: deploy:signature:cosign-key is not configured.*`)
+	c.Assert(recorder.Body.String(), check.Not(check.Matches), `(?s).*Image deploy called.*`)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		EndCustomData: map[string]interface{}{
+			"image":            "",
+			"signaturemode":    "enforce",
+			"signaturestatus":  "invalid",
+			"signaturemessage": "deploy:signature:cosign-key is not configured",
+		},
+		ErrorMatches: `deploy of app "otherapp" rejected.*`,
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestUnsignedDeploysReport(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target:   appTarget("myapp"),
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.token.GetUserName()},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.DoneCustomData(nil, app.DeployEndData(nil, "myimg", &app.SignatureVerification{
+		Mode:   app.SignatureModeWarn,
+		Status: app.SignatureUnsigned,
+	}))
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/deploys/unsigned", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []app.DeploySignatureReportEntry
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].App, check.Equals, "myapp")
+	c.Assert(result[0].Status, check.Equals, app.SignatureUnsigned)
+}
+
+func (s *DeploySuite) TestDeployTimingStats(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   appTarget(a.Name),
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.token.GetUserName()},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	evt.AddPhase(provision.DeployPhaseBuild, time.Now(), 3*time.Second)
+	err = evt.DoneCustomData(nil, app.DeployEndData(evt, "myimg", nil))
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/deploys/timings", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []app.DeployPhaseStats
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []app.DeployPhaseStats{
+		{Phase: provision.DeployPhaseBuild, Count: 1, Total: 3, Average: 3, Max: 3},
+	})
+}
+
+func (s *DeploySuite) TestDeployTimingStatsNoContent(c *check.C) {
+	since := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	request, err := http.NewRequest("GET", "/1.3/deploys/timings?since="+url.QueryEscape(since), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *DeploySuite) TestDeployTimingStatsInvalidSince(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/deploys/timings?since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *DeploySuite) TestDeployShouldIncrementDeployNumberOnApp(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
@@ -536,6 +706,355 @@ func (s *DeploySuite) TestDeployShouldIncrementDeployNumberOnApp(c *check.C) {
 	c.Assert(a.Deploys, check.Equals, uint(1))
 }
 
+func (s *DeploySuite) TestDeployDryRun(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&dry-run=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Dry run OK, the deploy would be started.\n")
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(0))
+	evts, err := event.List(&event.Filter{KindName: permission.PermAppDeploy.FullName()})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *DeploySuite) TestDeployDryRunLocked(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   appTarget(a.Name),
+		Kind:     permission.PermAppUpdateEnvSet,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.token.GetUserName()},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&dry-run=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)event locked: app\(otherapp\) running "app.update.env.set".*`)
+}
+
+func (s *DeploySuite) TestDeployWaitLock(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   appTarget(a.Name),
+		Kind:     permission.PermAppUpdateEnvSet,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.token.GetUserName()},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		evt.Done(nil)
+	}()
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&wait-lock=10"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Archive deploy called\nOK\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployWaitLockTimeout(c *check.C) {
+	config.Set("deploy:max-wait-lock", 0.1)
+	defer config.Unset("deploy:max-wait-lock")
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   appTarget(a.Name),
+		Kind:     permission.PermAppUpdateEnvSet,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.token.GetUserName()},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&wait-lock=3600"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)event locked: app\(otherapp\) running "app.update.env.set".*`)
+}
+
+func (s *DeploySuite) TestDeployInvalidWaitLock(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&wait-lock=-1"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "wait-lock must be a number of seconds\n")
+}
+
+func (s *DeploySuite) TestDeployInvalidDryRun(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&dry-run=maybe"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *DeploySuite) TestDeployScheduled(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	runAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	body := strings.NewReader("archive-url=http://something.tar.gz&run-at=" + url.QueryEscape(runAt.Format(time.RFC3339)))
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var sched event.ScheduledEvent
+	err = json.NewDecoder(recorder.Body).Decode(&sched)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.RunAt.Equal(runAt), check.Equals, true)
+	c.Assert(sched.Kind.Name, check.Equals, "app.deploy")
+	c.Assert(sched.Target, check.Equals, appTarget(a.Name))
+	evts, err := event.List(&event.Filter{KindName: permission.PermAppDeploy.FullName()})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(1))
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		EndCustomData: map[string]interface{}{
+			"image": "app-image",
+		},
+		LogMatches: `Archive deploy called`,
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployScheduledInFreezeWindow(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	runAt := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Minute)
+	err = event.AddBlock(&event.Block{
+		KindName: "app.deploy",
+		Reason:   "release freeze",
+		Schedule: fmt.Sprintf("%d %d * * *", runAt.Minute(), runAt.Hour()),
+		Duration: time.Hour,
+	})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("image=myimg&run-at=" + url.QueryEscape(runAt.Format(time.RFC3339)))
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Matches, `.*release freeze\n`)
+	scheduled, err := event.ListScheduled(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 0)
+}
+
+func (s *DeploySuite) TestDeployScheduledUploadFile(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("run-at", time.Now().Add(time.Hour).Format(time.RFC3339))
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write([]byte("hello world!"))
+	writer.Close()
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", &body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "uploaded files can't be deployed at a scheduled time, use archive-url, image or git-url.\n")
+}
+
+func (s *DeploySuite) TestDeployRequiringApproval(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApprovalRequired(true)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", strings.NewReader("archive-url=http://something.tar.gz"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted, check.Commentf("body: %s", recorder.Body.String()))
+	var sched event.ScheduledEvent
+	err = json.NewDecoder(recorder.Body).Decode(&sched)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.PendingApproval, check.Equals, true)
+	c.Assert(sched.Kind.Name, check.Equals, "app.deploy")
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(0))
+	request, err = http.NewRequest("POST", "/events/"+sched.ID.Hex()+"/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, event.ErrApproverIsOwner.Error()+"\n")
+	_, deployerToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "deployer", permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err = http.NewRequest("POST", "/events/"+sched.ID.Hex()+"/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+deployerToken.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	approver, approverToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "approver", permission.Permission{
+		Scheme:  permission.PermAppApproveDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err = http.NewRequest("POST", "/events/"+sched.ID.Hex()+"/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+approverToken.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	err = json.NewDecoder(recorder.Body).Decode(&sched)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.PendingApproval, check.Equals, false)
+	c.Assert(sched.ApprovedBy, check.Equals, approver.Email)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  approver.Email,
+		Kind:   "app.approve.deploy",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":uuid", "value": sched.ID.Hex()},
+		},
+	}, eventtest.HasEvent)
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(1))
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		EndCustomData: map[string]interface{}{
+			"image": "app-image",
+		},
+		LogMatches: `Archive deploy called`,
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployRequiringApprovalUploadFile(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApprovalRequired(true)
+	c.Assert(err, check.IsNil)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write([]byte("hello world!"))
+	writer.Close()
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", &body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "uploaded files can't be deployed to apps requiring deploy approval, use archive-url, image or git-url.\n")
+}
+
+func (s *DeploySuite) TestDeployInvalidRunAt(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", strings.NewReader("image=myimg&run-at=tomorrow"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "run-at must be a RFC 3339 time\n")
+}
+
 func (s *DeploySuite) TestDeployShouldReturnNotFoundWhenAppDoesNotExist(c *check.C) {
 	request, err := http.NewRequest("POST", "/apps/abc/repository/clone", strings.NewReader("archive-url=http://something.tar.gz"))
 	c.Assert(err, check.IsNil)
@@ -633,7 +1152,46 @@ func (s *DeploySuite) TestDeployWithoutArchiveURL(c *check.C) {
 	server.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
 	message := recorder.Body.String()
-	c.Assert(message, check.Equals, "you must specify either the archive-url, a image url or upload a file.\n")
+	c.Assert(message, check.Equals, "you must specify either the archive-url, a image url, a git url or upload a file.\n")
+}
+
+func (s *DeploySuite) TestDeployInvalidGitSource(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "abc", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		params url.Values
+		msg    string
+	}{
+		{
+			params: url.Values{"git-url": {"file:///etc"}},
+			msg:    `invalid git url "file:///etc", supported protocols are: http, https, git, ssh` + "\n",
+		},
+		{
+			params: url.Values{"git-url": {"https://github.com/tsuru/helloworld.git"}, "ref": {"--output=/tmp/x"}},
+			msg:    `invalid git ref "--output=/tmp/x"` + "\n",
+		},
+		{
+			params: url.Values{"git-url": {"https://github.com/tsuru/helloworld.git"}, "image": {"myimage"}},
+			msg:    "git-url can't be used along with archive-url, image or an uploaded file.\n",
+		},
+		{
+			params: url.Values{"image": {"myimage"}, "ref": {"master"}},
+			msg:    "ref can only be used along with git-url.\n",
+		},
+	}
+	server := RunServer(true)
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/apps/abc/repository/clone", strings.NewReader(tt.params.Encode()))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, tt.msg)
+	}
 }
 
 func (s *DeploySuite) TestPermSchemeForDeploy(c *check.C) {
@@ -1015,6 +1573,60 @@ func (s *DeploySuite) TestDeployRollbackHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *DeploySuite) TestDeployRollbackHandlerRequiringApproval(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApprovalRequired(true)
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("origin", "rollback")
+	v.Set("image", "my-image-123:v1")
+	u := fmt.Sprintf("/apps/%s/deploy/rollback", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var sched event.ScheduledEvent
+	err = json.NewDecoder(recorder.Body).Decode(&sched)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.PendingApproval, check.Equals, true)
+	c.Assert(sched.Kind.Name, check.Equals, "app.deploy")
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(0))
+	_, approverToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "approver", permission.Permission{
+		Scheme:  permission.PermAppApproveDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err = http.NewRequest("POST", "/events/"+sched.ID.Hex()+"/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+approverToken.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(1))
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		EndCustomData: map[string]interface{}{
+			"image": "my-image-123:v1",
+		},
+		LogMatches: `Rollback deploy called`,
+	}, eventtest.HasEvent)
+}
+
 func (s *DeploySuite) TestDeployRollbackHandlerWithCompleteImage(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
@@ -1129,6 +1741,109 @@ func (s *DeploySuite) TestDeployRollbackHandlerWithInexistVersion(c *check.C) {
 	c.Assert(body, check.DeepEquals, map[string]string{"Message": "", "Error": `invalid version: "v3"`})
 }
 
+func (s *DeploySuite) TestDeployRollbackHandlerRecordsRollbackData(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-otherapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-otherapp:v2")
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("image", "v1")
+	u := fmt.Sprintf("/apps/%s/deploy/rollback", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Rollback deploy called\"}\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		StartCustomData: map[string]interface{}{
+			"image":        "v1",
+			"rollback":     true,
+			"rollbackfrom": "tsuru/app-otherapp:v2",
+		},
+		EndCustomData: map[string]interface{}{
+			"image":         "tsuru/app-otherapp:v1",
+			"rollback.from": "tsuru/app-otherapp:v2",
+			"rollback.to":   "tsuru/app-otherapp:v1",
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployRollbackImages(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-otherapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-otherapp:v2")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/deploy/rollback", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var images []app.RollbackImage
+	err = json.Unmarshal(recorder.Body.Bytes(), &images)
+	c.Assert(err, check.IsNil)
+	c.Assert(images, check.DeepEquals, []app.RollbackImage{
+		{Image: "tsuru/app-otherapp:v2", Version: "v2", Current: true},
+		{Image: "tsuru/app-otherapp:v1", Version: "v1"},
+	})
+}
+
+func (s *DeploySuite) TestDeployRollbackImagesNoContent(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/deploy/rollback", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *DeploySuite) TestDeployRollbackImagesAppNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/apps/unknown/deploy/rollback", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *DeploySuite) TestDeployRollbackImagesForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/deploy/rollback", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *DeploySuite) TestDiffDeploy(c *check.C) {
 	diff := `--- hello.go	2015-11-25 16:04:22.409241045 +0000
 +++ hello.go	2015-11-18 18:40:21.385697080 +0000
@@ -1262,3 +1977,263 @@ func (s *DeploySuite) TestDeployRebuildHandler(c *check.C) {
 		},
 	}, eventtest.HasEvent)
 }
+
+func (s *DeploySuite) TestDeployRebuildHandlerRequiringApproval(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApprovalRequired(true)
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("origin", "rebuild")
+	u := fmt.Sprintf("/apps/%s/deploy/rebuild", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var sched event.ScheduledEvent
+	err = json.NewDecoder(recorder.Body).Decode(&sched)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.PendingApproval, check.Equals, true)
+	c.Assert(sched.Kind.Name, check.Equals, "app.deploy")
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(0))
+	_, approverToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "approver", permission.Permission{
+		Scheme:  permission.PermAppApproveDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err = http.NewRequest("POST", "/events/"+sched.ID.Hex()+"/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+approverToken.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	err = event.RunScheduled()
+	c.Assert(err, check.IsNil)
+	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(a.Deploys, check.Equals, uint(1))
+	c.Assert(eventtest.EventDesc{
+		Target:     appTarget(a.Name),
+		Owner:      s.token.GetUserName(),
+		Kind:       "app.deploy",
+		LogMatches: `Rebuild deploy called`,
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployPauseInfo(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = s.conn.DeployPauses().Insert(app.DeployPause{
+		App:           a.Name,
+		Reason:        "crash loop detected",
+		Image:         "app-image:v2",
+		RollbackImage: "app-image:v1",
+		Restarts:      map[string]int{"unit1": 3},
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/otherapp/deploy/pause", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result["Reason"], check.Equals, "crash loop detected")
+	c.Assert(result["Image"], check.Equals, "app-image:v2")
+	c.Assert(result["Restarts"], check.DeepEquals, map[string]interface{}{"unit1": float64(3)})
+	c.Assert(result["RollbackCommand"], check.Equals, "tsuru app-deploy-rollback -a otherapp app-image:v1")
+}
+
+func (s *DeploySuite) TestDeployPauseInfoNotPaused(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/otherapp/deploy/pause", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *DeploySuite) TestDeployResume(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = s.conn.DeployPauses().Insert(app.DeployPause{App: a.Name, Reason: "crash loop detected"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/otherapp/deploy/pause", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = app.GetDeployPause(a.Name)
+	c.Assert(err, check.Equals, app.ErrDeployNotPaused)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy.resume",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrDeployNotPaused.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployResumeForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("DELETE", "/apps/otherapp/deploy/pause", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func deployChunkHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *DeploySuite) TestDeployChunks(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	hello, world := deployChunkHash("hello "), deployChunkHash("world!")
+	server := RunServer(true)
+	body := url.Values{"hash": []string{hello, world}}.Encode()
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/chunks/missing", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result struct {
+		Missing []string
+		MaxSize int64 `json:"max-size"`
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Missing, check.DeepEquals, []string{hello, world})
+	c.Assert(result.MaxSize, check.Equals, app.DeployChunkMaxSize())
+	for hash, data := range map[string]string{hello: "hello ", world: "world!"} {
+		request, err = http.NewRequest("PUT", "/apps/otherapp/deploy/chunks/"+hash, strings.NewReader(data))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/octet-stream")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	}
+	request, err = http.NewRequest("POST", "/apps/otherapp/deploy", strings.NewReader("chunks="+hello+","+world))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Upload deploy called\nOK\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		StartCustomData: map[string]interface{}{
+			"app.name": a.Name,
+			"filesize": 12,
+			"kind":     "upload",
+		},
+		EndCustomData: map[string]interface{}{
+			"image": "app-image",
+		},
+		LogMatches: `Upload deploy called`,
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployChunksNotUploaded(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	hash := deployChunkHash("hello")
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy", strings.NewReader("chunks="+hash))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "chunk "+hash+" not found, upload it before deploying\n")
+}
+
+func (s *DeploySuite) TestDeployChunksInvalidHash(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy", strings.NewReader("chunks=abc"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, `invalid chunk hash "abc"`+"\n")
+}
+
+func (s *DeploySuite) TestDeployChunkUploadHashMismatch(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/apps/otherapp/deploy/chunks/"+deployChunkHash("hello"), strings.NewReader("world"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrDeployChunkHashMismatch.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployChunkUploadForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeployImage,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("PUT", "/apps/otherapp/deploy/chunks/"+deployChunkHash("hello"), strings.NewReader("hello"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
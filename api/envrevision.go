@@ -0,0 +1,194 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// privateEnvValue replaces the values of private variables in revisions and
+// diffs.
+const privateEnvValue = "*** (private variable)"
+
+func saveEnvRevision(a *app.App, t auth.Token, kind string, changed []string, previous map[string]bind.EnvVar) {
+	_, err := a.SaveEnvRevision(app.EnvRevisionOpts{
+		Kind:     kind,
+		User:     t.GetUserName(),
+		Changed:  changed,
+		Previous: previous,
+	})
+	if err != nil {
+		log.Errorf("[env-revisions] unable to save env revision of app %q: %s", a.Name, err)
+	}
+}
+
+func maskPrivateEnv(env *bind.EnvVar) {
+	if env != nil && !env.Public {
+		env.Value = privateEnvValue
+	}
+}
+
+// title: env revision list
+// path: /apps/{app}/env/revisions
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func envRevisionList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppReadEnv, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	revisions, err := app.ListEnvRevisions(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	for _, rev := range revisions {
+		for name, env := range rev.Envs {
+			maskPrivateEnv(&env)
+			rev.Envs[name] = env
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(revisions)
+}
+
+// title: env diff
+// path: /apps/{app}/env/diff
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func envRevisionDiff(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "from must be the version of a revision"}
+	}
+	var to int
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err = strconv.Atoi(v)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "to must be the version of a revision"}
+		}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppReadEnv, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	fromRev, err := app.GetEnvRevision(a.Name, from)
+	if err == app.ErrEnvRevisionNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("env revision %d not found", from)}
+	}
+	if err != nil {
+		return err
+	}
+	// Without a target revision, the revision is compared to the current
+	// variables of the app.
+	toEnvs := a.UserEnvs()
+	if to != 0 {
+		var toRev *app.EnvRevision
+		toRev, err = app.GetEnvRevision(a.Name, to)
+		if err == app.ErrEnvRevisionNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("env revision %d not found", to)}
+		}
+		if err != nil {
+			return err
+		}
+		toEnvs = toRev.Envs
+	}
+	diffs := app.DiffEnvs(fromRev.Envs, toEnvs)
+	for i := range diffs {
+		maskPrivateEnv(diffs[i].From)
+		maskPrivateEnv(diffs[i].To)
+	}
+	if diffs == nil {
+		diffs = []app.EnvDiff{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(diffs)
+}
+
+// title: env revision restore
+// path: /apps/{app}/env/revisions/{version}/restore
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Envs restored
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func envRevisionRestore(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	version, err := strconv.Atoi(r.URL.Query().Get(":version"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid revision version"}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateEnvRestore, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	_, err = app.GetEnvRevision(a.Name, version)
+	if err == app.ErrEnvRevisionNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("env revision %d not found", version)}
+	}
+	if err != nil {
+		return err
+	}
+	noRestart, _ := strconv.ParseBool(r.FormValue("noRestart"))
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEnvRestore,
+		Owner:      t,
+		CustomData: append(event.FormToCustomData(r.Form), map[string]interface{}{"name": "version", "value": version}),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	rev, err := a.RestoreEnvRevision(version, t.GetUserName(), !noRestart, writer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "Environment variables restored as revision %d.\n", rev.Version)
+	return nil
+}
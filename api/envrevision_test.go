@@ -0,0 +1,234 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) setEnvsForRevision(c *check.C, appName string, v url.Values) {
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/env", appName), strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *S) unsetEnvsForRevision(c *check.C, appName string, names ...string) {
+	v := url.Values{"env": names, "noRestart": {"true"}}
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/env?%s", appName, v.Encode()), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *S) TestSetAndUnsetEnvSaveRevisions(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.setEnvsForRevision(c, a.Name, url.Values{"Envs.0.Name": {"DATABASE_HOST"}, "Envs.0.Value": {"localhost"}})
+	s.setEnvsForRevision(c, a.Name, url.Values{"Envs.0.Name": {"DATABASE_PASSWORD"}, "Envs.0.Value": {"secret"}, "Private": {"true"}})
+	s.unsetEnvsForRevision(c, a.Name, "DATABASE_HOST")
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env/revisions", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var revisions []app.EnvRevision
+	err = json.Unmarshal(recorder.Body.Bytes(), &revisions)
+	c.Assert(err, check.IsNil)
+	c.Assert(revisions, check.HasLen, 3)
+	c.Assert(revisions[0].Version, check.Equals, 3)
+	c.Assert(revisions[0].Kind, check.Equals, app.EnvRevisionUnset)
+	c.Assert(revisions[0].User, check.Equals, s.token.GetUserName())
+	c.Assert(revisions[0].Changed, check.DeepEquals, []string{"DATABASE_HOST"})
+	c.Assert(revisions[0].Envs, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: privateEnvValue},
+	})
+	c.Assert(revisions[1].Kind, check.Equals, app.EnvRevisionSet)
+	c.Assert(revisions[1].Changed, check.DeepEquals, []string{"DATABASE_PASSWORD"})
+	c.Assert(revisions[2].Version, check.Equals, 1)
+	c.Assert(revisions[2].Envs, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+	})
+}
+
+func (s *S) TestEnvRevisionListNoContent(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env/revisions", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestEnvRevisionListForbidden(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env/revisions", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestEnvRevisionDiff(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.setEnvsForRevision(c, a.Name, url.Values{"Envs.0.Name": {"DATABASE_HOST"}, "Envs.0.Value": {"localhost"}})
+	s.setEnvsForRevision(c, a.Name, url.Values{
+		"Envs.0.Name": {"DATABASE_HOST"}, "Envs.0.Value": {"db.example.com"},
+		"Envs.1.Name": {"DATABASE_PORT"}, "Envs.1.Value": {"3306"},
+	})
+	s.setEnvsForRevision(c, a.Name, url.Values{"Envs.0.Name": {"DATABASE_PASSWORD"}, "Envs.0.Value": {"secret"}, "Private": {"true"}})
+	tests := []struct {
+		query    string
+		expected []app.EnvDiff
+	}{
+		{"from=1&to=2", []app.EnvDiff{
+			{
+				Name:   "DATABASE_HOST",
+				Change: app.EnvChanged,
+				From:   &bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true},
+				To:     &bind.EnvVar{Name: "DATABASE_HOST", Value: "db.example.com", Public: true},
+			},
+			{Name: "DATABASE_PORT", Change: app.EnvAdded, To: &bind.EnvVar{Name: "DATABASE_PORT", Value: "3306", Public: true}},
+		}},
+		{"from=2", []app.EnvDiff{
+			{Name: "DATABASE_PASSWORD", Change: app.EnvAdded, To: &bind.EnvVar{Name: "DATABASE_PASSWORD", Value: privateEnvValue}},
+		}},
+		{"from=3&to=3", []app.EnvDiff{}},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env/diff?%s", a.Name, tt.query), nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusOK)
+		var diffs []app.EnvDiff
+		err = json.Unmarshal(recorder.Body.Bytes(), &diffs)
+		c.Assert(err, check.IsNil)
+		c.Check(diffs, check.DeepEquals, tt.expected, check.Commentf(tt.query))
+	}
+}
+
+func (s *S) TestEnvRevisionDiffInvalid(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		query   string
+		code    int
+		message string
+	}{
+		{"", http.StatusBadRequest, "from must be the version of a revision\n"},
+		{"from=1&to=x", http.StatusBadRequest, "to must be the version of a revision\n"},
+		{"from=1", http.StatusNotFound, "env revision 1 not found\n"},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env/diff?%s", a.Name, tt.query), nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, tt.code)
+		c.Check(recorder.Body.String(), check.Equals, tt.message)
+	}
+}
+
+func (s *S) TestEnvRevisionRestore(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.setEnvsForRevision(c, a.Name, url.Values{
+		"Envs.0.Name": {"DATABASE_HOST"}, "Envs.0.Value": {"localhost"},
+		"Envs.1.Name": {"DATABASE_PORT"}, "Envs.1.Value": {"3306"},
+	})
+	s.unsetEnvsForRevision(c, a.Name, "DATABASE_HOST", "DATABASE_PORT")
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/env/revisions/1/restore", a.Name), strings.NewReader("noRestart=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"---- Restoring environment variables of revision 1 ----\n"}
+{"Message":"Environment variables restored as revision 3.\n"}
+`)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DATABASE_HOST"], check.DeepEquals, bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true})
+	c.Assert(dbApp.Env["DATABASE_PORT"], check.DeepEquals, bind.EnvVar{Name: "DATABASE_PORT", Value: "3306", Public: true})
+	rev, err := app.GetEnvRevision(a.Name, 3)
+	c.Assert(err, check.IsNil)
+	c.Assert(rev.Kind, check.Equals, app.EnvRevisionRestore)
+	c.Assert(rev.RestoredFrom, check.Equals, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.env.restore",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": ":version", "value": "1"},
+			{"name": "noRestart", "value": "true"},
+			{"name": "version", "value": 1},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestEnvRevisionRestoreNotFound(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/env/revisions/9/restore", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "env revision 9 not found\n")
+}
+
+func (s *S) TestEnvRevisionRestoreForbidden(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEnv,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/env/revisions/1/restore", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -7,14 +7,20 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -25,6 +31,7 @@ import (
 // responses:
 //   200: OK
 //   204: No content
+//   400: Invalid data
 func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	r.ParseForm()
 	filter := &event.Filter{}
@@ -40,18 +47,57 @@ func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
+	selector, err := provision.ParseLabelSelector(r.Form["label"])
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if selector != nil {
+		filter.Targets, err = labeledTargets(selector)
+		if err != nil {
+			return err
+		}
+	}
 	events, err := event.List(filter)
 	if err != nil {
+		if _, ok := err.(event.ErrValidation); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
 		return err
 	}
 	if len(events) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
+	if len(events) == filter.Limit {
+		w.Header().Set("X-Tsuru-Next-Cursor", events[len(events)-1].Cursor())
+	}
 	w.Header().Add("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(events)
 }
 
+// labeledTargets returns the apps and the pools with the labels in the
+// selector as event targets.
+func labeledTargets(selector provision.LabelSelector) ([]event.Target, error) {
+	apps, err := app.List(&app.Filter{Labels: selector})
+	if err != nil {
+		return nil, err
+	}
+	targets := []event.Target{}
+	for _, a := range apps {
+		targets = append(targets, event.Target{Type: event.TargetTypeApp, Value: a.Name})
+	}
+	pools, err := provision.ListPossiblePools(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pools {
+		if selector.Matches(p.Labels) {
+			targets = append(targets, event.Target{Type: event.TargetTypePool, Value: p.Name})
+		}
+	}
+	return targets, nil
+}
+
 // title: kind list
 // path: /events/kinds
 // method: GET
@@ -68,8 +114,135 @@ func kindList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
+	aliases, err := event.LoadKindAliases()
+	if err != nil {
+		return err
+	}
+	result := make([]kindInfo, len(kinds))
+	for i, kind := range kinds {
+		alias := aliases.Resolve(kind.Name)
+		result[i] = kindInfo{Kind: kind, Label: alias.Label, Category: alias.Category}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// kindInfo is a kind with its friendly label and category.
+type kindInfo struct {
+	event.Kind
+	Label    string
+	Category string
+}
+
+// title: event kind alias list
+// path: /events/kinds/aliases
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+func eventKindAliasList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	aliases, err := event.ListKindAliases()
+	if err != nil {
+		return err
+	}
+	if len(aliases) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(aliases)
+}
+
+// title: event kind alias set
+// path: /events/kinds/aliases/{name}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func eventKindAliasSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventKindAliasSet) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	alias := event.KindAlias{
+		Name:     r.URL.Query().Get(":name"),
+		Label:    r.FormValue("label"),
+		Category: r.FormValue("category"),
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeEventKindAlias, Value: alias.Name},
+		Kind:       permission.PermEventKindAliasSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermEventKindAliasReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.SetKindAlias(alias)
+	if _, ok := err.(event.ErrValidation); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: event kind alias remove
+// path: /events/kinds/aliases/{name}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func eventKindAliasRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventKindAliasRemove) {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeEventKindAlias, Value: name},
+		Kind:    permission.PermEventKindAliasRemove,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermEventKindAliasReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.RemoveKindAlias(name)
+	if err == event.ErrKindAliasNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: event custom data schemas
+// path: /events/schemas
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+func eventSchemaList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	kindName := r.URL.Query().Get("kind")
+	var schemas []event.CustomDataSchema
+	for _, schema := range event.CustomDataSchemas() {
+		if kindName == "" || schema.KindName == kindName {
+			schemas = append(schemas, schema)
+		}
+	}
+	if len(schemas) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
 	w.Header().Add("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(kinds)
+	return json.NewEncoder(w).Encode(schemas)
 }
 
 // title: event info
@@ -100,8 +273,95 @@ func eventInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	result := eventInfoResult{Event: e, URL: e.URL(), TargetURL: e.TargetURL()}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: event log overflow
+// path: /events/{uuid}/log/overflow
+// method: GET
+// produce: text/plain
+// responses:
+//   200: OK
+//   400: Invalid uuid
+//   401: Unauthorized
+//   404: Not found
+func eventLogOverflow(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	uuid := r.URL.Query().Get(":uuid")
+	if !bson.IsObjectIdHex(uuid) {
+		msg := fmt.Sprintf("uuid parameter is not ObjectId: %s", uuid)
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	e, err := event.GetByID(bson.ObjectIdHex(uuid))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	scheme, err := permission.SafeGet(e.Allowed.Scheme)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, scheme, e.Allowed.Contexts...) {
+		return permission.ErrUnauthorized
+	}
+	overflow, err := e.OverflowLog()
+	if err == event.ErrNoLogOverflow {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	defer overflow.Close()
+	w.Header().Set("Content-Type", "text/plain")
+	_, err = io.Copy(w, overflow)
+	return err
+}
+
+// title: event diff
+// path: /events/{uuid}/diff/{otheruuid}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid uuid or events not comparable
+//   401: Unauthorized
+//   404: Not found
+func eventDiff(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var evts []*event.Event
+	for _, param := range []string{":uuid", ":otheruuid"} {
+		uuid := r.URL.Query().Get(param)
+		if !bson.IsObjectIdHex(uuid) {
+			msg := fmt.Sprintf("uuid parameter is not ObjectId: %s", uuid)
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+		}
+		e, err := event.GetByID(bson.ObjectIdHex(uuid))
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		scheme, err := permission.SafeGet(e.Allowed.Scheme)
+		if err != nil {
+			return err
+		}
+		if !permission.Check(t, scheme, e.Allowed.Contexts...) {
+			return permission.ErrUnauthorized
+		}
+		evts = append(evts, e)
+	}
+	diff, err := evts[0].Diff(evts[1])
+	if err == event.ErrEventsNotComparable {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
 	w.Header().Add("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(e)
+	return json.NewEncoder(w).Encode(diff)
+}
+
+type eventInfoResult struct {
+	*event.Event
+	URL       string `json:",omitempty"`
+	TargetURL string `json:",omitempty"`
 }
 
 // title: event cancel
@@ -146,6 +406,132 @@ func eventCancel(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return nil
 }
 
+// title: event redrive
+// path: /events/{uuid}/redrive
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   400: Invalid uuid or event can't be redriven
+//   401: Unauthorized
+//   404: Not found
+func eventRedrive(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	uuid := r.URL.Query().Get(":uuid")
+	if !bson.IsObjectIdHex(uuid) {
+		msg := fmt.Sprintf("uuid parameter is not ObjectId: %s", uuid)
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	objID := bson.ObjectIdHex(uuid)
+	e, err := event.GetByID(objID)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	scheme, err := permission.SafeGet(e.Allowed.Scheme)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, scheme, e.Allowed.Contexts...)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = event.Redrive(objID, t, w)
+	if err != nil {
+		switch err.(type) {
+		case event.ErrValidation, event.ErrRedriveNotSupported:
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	return nil
+}
+
+// formRedrive returns a redrive function calling handler with a request
+// rebuilt from the form values stored in the event StartCustomData, so the
+// operation runs again exactly as it was requested.
+func formRedrive(handler func(http.ResponseWriter, *http.Request, auth.Token) error) event.RedriveFunc {
+	return func(evt *event.Event, t auth.Token, w io.Writer) error {
+		var data []map[string]interface{}
+		err := evt.StartData(&data)
+		if err != nil {
+			return err
+		}
+		values := event.CustomDataToForm(data)
+		req := &http.Request{
+			Method:   "POST",
+			URL:      &url.URL{RawQuery: values.Encode()},
+			Header:   http.Header{},
+			Form:     values,
+			PostForm: url.Values{},
+		}
+		rw, ok := w.(http.ResponseWriter)
+		if !ok {
+			rw = &redriveResponseWriter{Writer: w, header: http.Header{}}
+		}
+		return handler(rw, req, t)
+	}
+}
+
+type redriveResponseWriter struct {
+	io.Writer
+	header http.Header
+}
+
+func (w *redriveResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *redriveResponseWriter) WriteHeader(int) {}
+
+// changeRateError converts the error returned by event.New when the token
+// exceeded the change rate limit of the operation, asking for confirmation.
+func changeRateError(err error) error {
+	if _, ok := err.(event.ErrChangeRateExceeded); ok {
+		return &errors.HTTP{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("%s, use confirm-change-rate=true to proceed", err),
+		}
+	}
+	return err
+}
+
+// title: event cancel many
+// path: /events
+// method: DELETE
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data or empty reason
+func eventCancelMany(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	r.ParseForm()
+	filter := &event.Filter{}
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err := dec.DecodeValues(&filter, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
+	}
+	filter.PruneUserValues()
+	filter.Permissions, err = t.Permissions()
+	if err != nil {
+		return err
+	}
+	canceled, err := event.CancelMany(filter, r.FormValue("reason"), t)
+	if err != nil {
+		if _, ok := err.(event.ErrValidation); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	if len(canceled) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(canceled)
+}
+
 // title: event block list
 // path: /events/blocks
 // method: GET
@@ -195,11 +581,23 @@ func eventBlockAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 	dec := form.NewDecoder(nil)
 	dec.IgnoreUnknownKeys(true)
 	dec.IgnoreCase(true)
+	values := url.Values{}
+	for k, v := range r.Form {
+		if strings.ToLower(k) != "duration" {
+			values[k] = v
+		}
+	}
 	var block event.Block
-	err = dec.DecodeValues(&block, r.Form)
+	err = dec.DecodeValues(&block, values)
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse block: %s", err)}
 	}
+	if durationStr := r.FormValue("duration"); durationStr != "" {
+		block.Duration, err = parseBlockDuration(durationStr)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse block duration: %s", err)}
+		}
+	}
 	if block.Reason == "" {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("reason is required")}
 	}
@@ -217,7 +615,53 @@ func eventBlockAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 		evt.Target.Value = block.ID.Hex()
 		evt.Done(err)
 	}()
-	return event.AddBlock(&block)
+	err = event.AddBlock(&block)
+	if _, ok := err.(event.ErrValidation); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// parseBlockDuration accepts both duration strings, like "2h30m", and the
+// number of nanoseconds sent by clients encoding event.Block directly.
+func parseBlockDuration(value string) (time.Duration, error) {
+	if ns, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(ns), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// title: event block windows
+// path: /events/blocks/windows
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func eventBlockWindows(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermEventBlockRead) {
+		return permission.ErrUnauthorized
+	}
+	until := time.Now().Add(7 * 24 * time.Hour)
+	if untilStr := r.URL.Query().Get("until"); untilStr != "" {
+		var err error
+		until, err = time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse until: %s", err)}
+		}
+	}
+	windows, err := event.ListBlockWindows(until)
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(windows)
 }
 
 // title: event block list
@@ -257,3 +701,432 @@ func eventBlockRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	}
 	return err
 }
+
+// title: event legal hold list
+// path: /events/legal-holds
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func eventLegalHoldList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermEventLegalHoldRead) {
+		return permission.ErrUnauthorized
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var active *bool
+	if activeStr := r.FormValue("active"); activeStr != "" {
+		b, _ := strconv.ParseBool(activeStr)
+		active = &b
+	}
+	holds, err := event.ListLegalHolds(active)
+	if err != nil {
+		return err
+	}
+	if len(holds) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(holds)
+}
+
+// title: add event legal hold
+// path: /events/legal-holds
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data or empty reason
+//   401: Unauthorized
+func eventLegalHoldAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventLegalHoldAdd) {
+		return permission.ErrUnauthorized
+	}
+	r.ParseForm()
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	var hold event.LegalHold
+	err = dec.DecodeValues(&hold, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse legal hold: %s", err)}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeEventLegalHold},
+		Kind:       permission.PermEventLegalHoldAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermEventLegalHoldReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		evt.Target.Value = hold.ID.Hex()
+		evt.Done(err)
+	}()
+	err = event.AddLegalHold(&hold)
+	if _, ok := err.(event.ErrValidation); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: release event legal hold
+// path: /events/legal-holds/{uuid}
+// method: DELETE
+// responses:
+//   200: OK
+//   400: Invalid uuid
+//   401: Unauthorized
+//   404: Active legal hold with provided uuid not found
+func eventLegalHoldRelease(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventLegalHoldRelease) {
+		return permission.ErrUnauthorized
+	}
+	uuid := r.URL.Query().Get(":uuid")
+	if !bson.IsObjectIdHex(uuid) {
+		msg := fmt.Sprintf("uuid parameter is not ObjectId: %s", uuid)
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	objID := bson.ObjectIdHex(uuid)
+	evt, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeEventLegalHold, Value: objID.Hex()},
+		Kind:   permission.PermEventLegalHoldRelease,
+		Owner:  t,
+		CustomData: []map[string]interface{}{
+			{"name": "ID", "value": objID.Hex()},
+		},
+		Allowed: event.Allowed(permission.PermEventLegalHoldReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.ReleaseLegalHold(objID)
+	if _, ok := err.(*event.ErrActiveLegalHoldNotFound); ok {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: scheduled event list
+// path: /events/scheduled
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+func eventScheduledList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	r.ParseForm()
+	filter := &event.Filter{}
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err := dec.DecodeValues(&filter, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
+	}
+	filter.PruneUserValues()
+	filter.Permissions, err = t.Permissions()
+	if err != nil {
+		return err
+	}
+	scheduled, err := event.ListScheduled(filter)
+	if err != nil {
+		return err
+	}
+	if len(scheduled) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(scheduled)
+}
+
+// title: cancel scheduled event
+// path: /events/scheduled/{id}
+// method: DELETE
+// responses:
+//   200: OK
+//   400: Invalid id or event already starting
+//   401: Unauthorized
+//   404: Not found
+func eventScheduledCancel(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		msg := fmt.Sprintf("id parameter is not ObjectId: %s", id)
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	objID := bson.ObjectIdHex(id)
+	sched, err := event.GetScheduled(objID)
+	if err != nil {
+		if err == event.ErrScheduledEventNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		return err
+	}
+	// Scheduled operations can be canceled by anyone allowed to run them
+	// on the same contexts.
+	schemeName := sched.Allowed.Scheme
+	if sched.Kind.Type == event.KindTypePermission {
+		schemeName = sched.Kind.Name
+	}
+	scheme, err := permission.SafeGet(schemeName)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, scheme, sched.Allowed.Contexts...) {
+		return permission.ErrUnauthorized
+	}
+	err = event.CancelScheduled(objID)
+	if err != nil {
+		switch err.(type) {
+		case event.ErrValidation:
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if err == event.ErrScheduledEventNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		return err
+	}
+	return nil
+}
+
+// title: approve scheduled event
+// path: /events/{uuid}/approve
+// method: POST
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid id, event not pending approval or approved by its owner
+//   401: Unauthorized
+//   404: Not found
+func eventApprove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	uuid := r.URL.Query().Get(":uuid")
+	if !bson.IsObjectIdHex(uuid) {
+		msg := fmt.Sprintf("uuid parameter is not ObjectId: %s", uuid)
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	objID := bson.ObjectIdHex(uuid)
+	sched, err := event.GetScheduled(objID)
+	if err != nil {
+		if err == event.ErrScheduledEventNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		return err
+	}
+	if !sched.PendingApproval {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: event.ErrNotPendingApproval.Error()}
+	}
+	scheme, err := permission.SafeGet(sched.AllowedApprove.Scheme)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, scheme, sched.AllowedApprove.Contexts...) {
+		return permission.ErrUnauthorized
+	}
+	r.ParseForm()
+	evt, err := event.New(&event.Opts{
+		Target:      sched.Target,
+		Kind:        scheme,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		Allowed:     sched.Allowed,
+		DisableLock: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	sched, err = event.ApproveScheduled(objID, t.GetUserName())
+	if err != nil {
+		switch err.(type) {
+		case event.ErrValidation:
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if err == event.ErrScheduledEventNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(sched)
+}
+
+// title: consume events
+// path: /events/consumers/{group}/consume
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func eventConsume(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermEventConsumerConsume) {
+		return permission.ErrUnauthorized
+	}
+	batch := 100
+	if batchStr := r.FormValue("batch"); batchStr != "" {
+		var err error
+		batch, err = strconv.Atoi(batchStr)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid batch: %s", err)}
+		}
+	}
+	evts, err := event.Consume(r.URL.Query().Get(":group"), batch)
+	if err != nil {
+		if _, ok := err.(event.ErrValidation); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	if len(evts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(evts)
+}
+
+// title: acknowledge consumed events
+// path: /events/consumers/{group}/ack
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func eventAck(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermEventConsumerConsume) {
+		return permission.ErrUnauthorized
+	}
+	r.ParseForm()
+	ids := make([]bson.ObjectId, len(r.Form["id"]))
+	for i, id := range r.Form["id"] {
+		if !bson.IsObjectIdHex(id) {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("id parameter is not ObjectId: %s", id)}
+		}
+		ids[i] = bson.ObjectIdHex(id)
+	}
+	err := event.Ack(r.URL.Query().Get(":group"), ids...)
+	if _, ok := err.(event.ErrValidation); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: event notification rule list
+// path: /events/notifications
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func eventNotificationList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	rules, err := event.ListNotificationRules(t.GetUserName())
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(rules)
+}
+
+// title: add event notification rule
+// path: /events/notifications
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Rule created
+//   400: Invalid data
+//   401: Unauthorized
+func eventNotificationAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermUserUpdateNotificationAdd,
+		permission.Context(permission.CtxUser, t.GetUserName()),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	r.ParseForm()
+	rule := event.NotificationRule{
+		ID:          bson.NewObjectId(),
+		Owner:       t.GetUserName(),
+		Scope:       r.FormValue("scope"),
+		TargetType:  event.TargetType(r.FormValue("target.type")),
+		TargetValue: r.FormValue("target.value"),
+		KindName:    r.FormValue("kindname"),
+		ErrorOnly:   r.FormValue("erroronly") == "true",
+		Channel:     r.FormValue("channel"),
+		URL:         r.FormValue("url"),
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(t.GetUserName()),
+		Kind:       permission.PermUserUpdateNotificationAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, t.GetUserName())),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.AddNotificationRule(&rule)
+	if _, ok := err.(event.ErrValidation); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(rule)
+}
+
+// title: remove event notification rule
+// path: /events/notifications/{id}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func eventNotificationRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermUserUpdateNotificationRemove,
+		permission.Context(permission.CtxUser, t.GetUserName()),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	id := r.URL.Query().Get(":id")
+	evt, err := event.New(&event.Opts{
+		Target: userTarget(t.GetUserName()),
+		Kind:   permission.PermUserUpdateNotificationRemove,
+		Owner:  t,
+		CustomData: []map[string]interface{}{
+			{"name": "ID", "value": id},
+		},
+		Allowed: event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, t.GetUserName())),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.RemoveNotificationRule(t.GetUserName(), id)
+	if err == event.ErrNotificationRuleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -6,10 +6,14 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ajg/form"
 	"github.com/tsuru/config"
@@ -142,6 +146,46 @@ func (s *EventSuite) TestEventList(c *check.C) {
 	c.Assert(result, check.HasLen, 10)
 }
 
+func (s *EventSuite) TestEventListCursor(c *check.C) {
+	_, err := s.insertEvents("app", c)
+	c.Assert(err, check.IsNil)
+	var names []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		request, err := http.NewRequest("GET", "/events?limit=4&cursor="+cursor, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusOK)
+		var result []event.Event
+		err = json.Unmarshal(recorder.Body.Bytes(), &result)
+		c.Assert(err, check.IsNil)
+		for i := range result {
+			names = append(names, result[i].Target.Value)
+		}
+		cursor = recorder.Header().Get("X-Tsuru-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+	}
+	c.Assert(names, check.DeepEquals, []string{
+		"app-9", "app-8", "app-7", "app-6", "app-5", "app-4", "app-3", "app-2", "app-1", "app-0",
+	})
+}
+
+func (s *EventSuite) TestEventListInvalidCursor(c *check.C) {
+	request, err := http.NewRequest("GET", "/events?cursor=invalid", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid event cursor\n")
+}
+
 func (s *EventSuite) TestEventListFilterByTarget(c *check.C) {
 	_, err := s.insertEvents("app", c)
 	c.Assert(err, check.IsNil)
@@ -167,6 +211,35 @@ func (s *EventSuite) TestEventListFilterByTarget(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
 }
 
+func (s *EventSuite) TestEventListFilterByLabel(c *check.C) {
+	for _, name := range []string{"app-1", "app-2"} {
+		a := app.App{Name: name, Labels: map[string]string{"product": "store"}}
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+	}
+	_, err := s.insertEvents("app", c)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events?label=product=store", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	c.Assert(result[0].Target.Value, check.Equals, "app-2")
+	c.Assert(result[1].Target.Value, check.Equals, "app-1")
+	request, err = http.NewRequest("GET", "/events?label=product=blog", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
 func (s *EventSuite) TestEventListFilterRunning(c *check.C) {
 	_, err := s.insertEvents("app", c)
 	c.Assert(err, check.IsNil)
@@ -231,10 +304,142 @@ func (s *EventSuite) TestKindList(c *check.C) {
 	server.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
-	var result []event.Kind
+	var result []kindInfo
 	err = json.Unmarshal(recorder.Body.Bytes(), &result)
 	c.Assert(err, check.IsNil)
-	c.Assert(result, check.HasLen, 1)
+	c.Assert(result, check.DeepEquals, []kindInfo{
+		{Kind: event.Kind{Type: event.KindTypePermission, Name: "app.deploy"}, Label: "App deploy", Category: "app"},
+	})
+}
+
+func (s *EventSuite) TestKindListWithAliases(c *check.C) {
+	_, err := s.insertEvents("app", c)
+	c.Assert(err, check.IsNil)
+	err = event.SetKindAlias(event.KindAlias{Name: "app", Category: "Applications"})
+	c.Assert(err, check.IsNil)
+	err = event.SetKindAlias(event.KindAlias{Name: "app.deploy", Label: "Deploy"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/kinds", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []kindInfo
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []kindInfo{
+		{Kind: event.Kind{Type: event.KindTypePermission, Name: "app.deploy"}, Label: "Deploy", Category: "Applications"},
+	})
+}
+
+func (s *EventSuite) TestEventKindAliasList(c *check.C) {
+	err := event.SetKindAlias(event.KindAlias{Name: "app.deploy", Label: "Deploy", Category: "Deploys"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/kinds/aliases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.KindAlias
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []event.KindAlias{{Name: "app.deploy", Label: "Deploy", Category: "Deploys"}})
+}
+
+func (s *EventSuite) TestEventKindAliasListNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/kinds/aliases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventKindAliasSet(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventKindAliasSet,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	body := strings.NewReader("label=Set env vars&category=Configuration")
+	request, err := http.NewRequest("PUT", "/events/kinds/aliases/app.update.env.set", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	aliases, err := event.ListKindAliases()
+	c.Assert(err, check.IsNil)
+	c.Assert(aliases, check.DeepEquals, []event.KindAlias{
+		{Name: "app.update.env.set", Label: "Set env vars", Category: "Configuration"},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventKindAlias, Value: "app.update.env.set"},
+		Owner:  token.GetUserName(),
+		Kind:   "event-kind-alias.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "label", "value": "Set env vars"},
+			{"name": "category", "value": "Configuration"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventKindAliasSetInvalid(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventKindAliasSet,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("PUT", "/events/kinds/aliases/app.deploy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "event kind alias requires a label or a category\n")
+}
+
+func (s *EventSuite) TestEventKindAliasSetWithoutPermission(c *check.C) {
+	request, err := http.NewRequest("PUT", "/events/kinds/aliases/app.deploy", strings.NewReader("label=Deploy"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *EventSuite) TestEventKindAliasRemove(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventKindAliasRemove,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	err := event.SetKindAlias(event.KindAlias{Name: "app.deploy", Label: "Deploy"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/events/kinds/aliases/app.deploy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	aliases, err := event.ListKindAliases()
+	c.Assert(err, check.IsNil)
+	c.Assert(aliases, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventKindAlias, Value: "app.deploy"},
+		Owner:  token.GetUserName(),
+		Kind:   "event-kind-alias.remove",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("DELETE", "/events/kinds/aliases/app.deploy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
 }
 
 func (s *EventSuite) TestKindListNoContent(c *check.C) {
@@ -247,6 +452,111 @@ func (s *EventSuite) TestKindListNoContent(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
 }
 
+func (s *EventSuite) TestEventSchemaList(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/schemas?kind=app.deploy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.CustomDataSchema
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].KindName, check.Equals, "app.deploy")
+	c.Assert(result[0].TargetType, check.Equals, event.TargetTypeApp)
+	c.Assert(result[0].StartCustomData, check.IsNil)
+	c.Assert(result[0].EndCustomData.Type, check.Equals, "object")
+	c.Assert(result[0].EndCustomData.Required, check.DeepEquals, []string{"image"})
+	c.Assert(result[0].EndCustomData.Properties["image"].Type, check.Equals, "string")
+}
+
+func (s *EventSuite) TestEventSchemaListNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/schemas?kind=unknown.kind", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventRedrive(c *check.C) {
+	var redriven *event.Event
+	var redriveToken auth.Token
+	event.SetRedriveHandler("redrive-kind", func(evt *event.Event, t auth.Token, w io.Writer) error {
+		redriven, redriveToken = evt, t
+		w.Write([]byte("redriving"))
+		return nil
+	})
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		InternalKind: "redrive-kind",
+		Allowed:      event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("my error"))
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/events/"+evt.UniqueID.Hex()+"/redrive", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "redriving")
+	c.Assert(redriven, check.NotNil)
+	c.Assert(redriven.UniqueID, check.Equals, evt.UniqueID)
+	c.Assert(redriveToken.GetValue(), check.Equals, s.token.GetValue())
+}
+
+func (s *EventSuite) TestEventRedriveNotFailed(c *check.C) {
+	evts, err := s.insertEvents("app", c)
+	c.Assert(err, check.IsNil)
+	for _, evt := range evts[:2] {
+		request, err := http.NewRequest("POST", "/events/"+evt.UniqueID.Hex()+"/redrive", nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, "only finished events with errors can be redriven\n")
+	}
+}
+
+func (s *EventSuite) TestEventRedriveNotSupported(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Owner:   s.token,
+		Kind:    permission.PermAppDeploy,
+		Allowed: event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("my error"))
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/events/"+evt.UniqueID.Hex()+"/redrive", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, `redrive is not supported for events of kind "app.deploy"`+"\n")
+}
+
+func (s *EventSuite) TestEventRedriveNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/events/"+bson.NewObjectId().Hex()+"/redrive", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
 func (s *EventSuite) TestEventInfoInvalidObjectID(c *check.C) {
 	u := fmt.Sprintf("/events/%s", "123")
 	request, err := http.NewRequest("GET", u, nil)
@@ -285,6 +595,40 @@ func (s *EventSuite) TestEventCancel(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
 }
 
+func (s *EventSuite) TestEventCancelMany(c *check.C) {
+	events, err := s.insertEvents("app", c)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/events?kindname=app.deploy&reason=incident", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].UniqueID, check.Equals, events[0].UniqueID)
+	c.Assert(result[0].CancelInfo.Asked, check.Equals, true)
+	c.Assert(result[0].CancelInfo.Reason, check.Equals, "incident")
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventCancelManyNoReason(c *check.C) {
+	request, err := http.NewRequest("DELETE", "/events?kindname=app.deploy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "reason is mandatory\n")
+}
+
 func (s *EventSuite) TestEventCancelInvalidObjectID(c *check.C) {
 	u := fmt.Sprintf("/events/%s/cancel", "123")
 	body := strings.NewReader("reason=we ain't gonna take it")
@@ -373,6 +717,31 @@ func (s *EventSuite) TestEventInfoPermission(c *check.C) {
 	c.Assert(result.Target, check.DeepEquals, evt.Target)
 }
 
+func (s *EventSuite) TestEventInfoWithDashboardURL(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.example.com")
+	defer config.Unset("event:dashboard-url")
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "aha"},
+		Owner:   s.token,
+		Kind:    permission.PermAppDeploy,
+		Allowed: event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+	})
+	c.Assert(err, check.IsNil)
+	u := fmt.Sprintf("/events/%s", evt.UniqueID.Hex())
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result["URL"], check.Equals, "https://dashboard.example.com/events/"+evt.UniqueID.Hex())
+	c.Assert(result["TargetURL"], check.Equals, "https://dashboard.example.com/apps/aha")
+}
+
 func (s *EventSuite) TestEventInfoWithoutPermission(c *check.C) {
 	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
 		Scheme:  permission.PermAppRead,
@@ -395,33 +764,165 @@ func (s *EventSuite) TestEventInfoWithoutPermission(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
-func (s *EventSuite) TestEventCancelPermission(c *check.C) {
-	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
-		Scheme:  permission.PermAppUpdate,
-		Context: permission.Context(permission.CtxTeam, s.team.Name),
-	})
+func (s *EventSuite) TestEventLogOverflow(c *check.C) {
+	config.Set("event:log:max-size", 3)
+	config.Set("event:log:overflow", "gridfs")
+	defer config.Unset("event:log")
 	evt, err := event.New(&event.Opts{
-		Target:        event.Target{Type: event.TargetTypeApp, Value: "anything"},
-		Owner:         s.token,
-		Kind:          permission.PermAppDeploy,
-		Cancelable:    true,
-		Allowed:       event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, s.team.Name)),
-		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "aha"},
+		Owner:   s.token,
+		Kind:    permission.PermAppDeploy,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
 	})
 	c.Assert(err, check.IsNil)
-	body := strings.NewReader("reason=we ain't gonna take it")
-	u := fmt.Sprintf("/events/%s/cancel", evt.UniqueID.Hex())
-	request, err := http.NewRequest("POST", u, body)
+	evt.Write([]byte("build output"))
+	err = evt.Done(nil)
 	c.Assert(err, check.IsNil)
-	request.Header.Set("Authorization", "bearer "+token.GetValue())
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	u := fmt.Sprintf("/1.3/events/%s/log/overflow", evt.UniqueID.Hex())
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
 	recorder := httptest.NewRecorder()
 	server := RunServer(true)
 	server.ServeHTTP(recorder, request)
-	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(recorder.Body.String(), check.Equals, "ld output")
 }
 
-func (s *EventSuite) TestEventCancelWithoutPermission(c *check.C) {
+func (s *EventSuite) TestEventLogOverflowNotTruncated(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "aha"},
+		Owner:   s.token,
+		Kind:    permission.PermAppDeploy,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	evt.Write([]byte("build output"))
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	u := fmt.Sprintf("/1.3/events/%s/log/overflow", evt.UniqueID.Hex())
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *EventSuite) TestEventDiff(c *check.C) {
+	var ids []string
+	for _, image := range []string{"v1", "v2"} {
+		evt, err := event.New(&event.Opts{
+			Target:     event.Target{Type: event.TargetTypeApp, Value: "aha"},
+			Owner:      s.token,
+			Kind:       permission.PermAppDeploy,
+			CustomData: map[string]string{"image": image},
+			Allowed:    event.Allowed(permission.PermAppReadEvents),
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.Done(nil)
+		c.Assert(err, check.IsNil)
+		ids = append(ids, evt.UniqueID.Hex())
+	}
+	u := fmt.Sprintf("/1.3/events/%s/diff/%s", ids[0], ids[1])
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var diff event.Diff
+	err = json.Unmarshal(recorder.Body.Bytes(), &diff)
+	c.Assert(err, check.IsNil)
+	c.Assert(diff.Event, check.Equals, ids[0])
+	c.Assert(diff.Other, check.Equals, ids[1])
+	c.Assert(diff.StartCustomData, check.DeepEquals, []event.DataChange{{Path: "image", Old: "v1", New: "v2"}})
+}
+
+func (s *EventSuite) TestEventDiffNotComparable(c *check.C) {
+	var ids []string
+	for _, appName := range []string{"aha", "ehe"} {
+		evt, err := event.New(&event.Opts{
+			Target:  event.Target{Type: event.TargetTypeApp, Value: appName},
+			Owner:   s.token,
+			Kind:    permission.PermAppDeploy,
+			Allowed: event.Allowed(permission.PermAppReadEvents),
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.Done(nil)
+		c.Assert(err, check.IsNil)
+		ids = append(ids, evt.UniqueID.Hex())
+	}
+	u := fmt.Sprintf("/1.3/events/%s/diff/%s", ids[0], ids[1])
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, event.ErrEventsNotComparable.Error()+"\n")
+}
+
+func (s *EventSuite) TestEventDiffWithoutPermission(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermAppReadEvents,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	var ids []string
+	for _, team := range []string{s.team.Name, "some-other-team"} {
+		evt, err := event.New(&event.Opts{
+			Target:  event.Target{Type: event.TargetTypeApp, Value: "aha"},
+			Owner:   s.token,
+			Kind:    permission.PermAppDeploy,
+			Allowed: event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, team)),
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.Done(nil)
+		c.Assert(err, check.IsNil)
+		ids = append(ids, evt.UniqueID.Hex())
+	}
+	u := fmt.Sprintf("/1.3/events/%s/diff/%s", ids[0], ids[1])
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *EventSuite) TestEventCancelPermission(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermAppUpdate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypeApp, Value: "anything"},
+		Owner:         s.token,
+		Kind:          permission.PermAppDeploy,
+		Cancelable:    true,
+		Allowed:       event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+	})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("reason=we ain't gonna take it")
+	u := fmt.Sprintf("/events/%s/cancel", evt.UniqueID.Hex())
+	request, err := http.NewRequest("POST", u, body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventCancelWithoutPermission(c *check.C) {
 	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
 		Scheme:  permission.PermAppRead,
 		Context: permission.Context(permission.CtxTeam, s.team.Name),
@@ -579,6 +1080,100 @@ func (s *EventSuite) TestEventBlockAddWithoutReason(c *check.C) {
 	c.Assert(len(blocks), check.Equals, 0)
 }
 
+func (s *EventSuite) TestEventBlockAddScheduled(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventBlockAdd,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	expire := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	values := url.Values{
+		"kindname":   []string{"app.deploy"},
+		"reason":     []string{"weekly maintenance"},
+		"schedule":   []string{"0 22 * * 6"},
+		"duration":   []string{"2h30m"},
+		"expiretime": []string{expire.Format(time.RFC3339)},
+	}
+	request, err := http.NewRequest("POST", "/events/blocks", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	blocks, err := event.ListBlocks(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(len(blocks), check.Equals, 1)
+	c.Assert(blocks[0].Schedule, check.Equals, "0 22 * * 6")
+	c.Assert(blocks[0].Duration, check.Equals, 150*time.Minute)
+	c.Assert(blocks[0].ExpireTime.Equal(expire), check.Equals, true)
+}
+
+func (s *EventSuite) TestEventBlockAddInvalidSchedule(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventBlockAdd,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	values := url.Values{
+		"reason":   []string{"maintenance"},
+		"schedule": []string{"0 22 * * 6"},
+	}
+	request, err := http.NewRequest("POST", "/events/blocks", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "block duration is required for scheduled blocks\n")
+}
+
+func (s *EventSuite) TestEventBlockWindows(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventBlockRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	now := time.Now().UTC()
+	next := now.Add(2 * time.Hour).Truncate(time.Minute)
+	block := &event.Block{
+		KindName: "app.deploy",
+		Reason:   "daily maintenance",
+		Schedule: fmt.Sprintf("%d %d * * *", next.Minute(), next.Hour()),
+		Duration: time.Hour,
+	}
+	err := event.AddBlock(block)
+	c.Assert(err, check.IsNil)
+	until := now.Add(49 * time.Hour).Format(time.RFC3339)
+	request, err := http.NewRequest("GET", "/events/blocks/windows?until="+url.QueryEscape(until), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var windows []event.BlockWindow
+	err = json.Unmarshal(recorder.Body.Bytes(), &windows)
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 2)
+	for i, w := range windows {
+		c.Assert(w.Block.ID, check.Equals, block.ID)
+		c.Assert(w.StartTime.Equal(next.Add(time.Duration(i)*24*time.Hour)), check.Equals, true)
+		c.Assert(w.EndTime.Sub(w.StartTime), check.Equals, time.Hour)
+	}
+}
+
+func (s *EventSuite) TestEventBlockWindowsWithoutPermission(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/blocks/windows", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *EventSuite) TestEventBlockRemove(c *check.C) {
 	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
 		Scheme:  permission.PermEventBlockRemove,
@@ -663,3 +1258,404 @@ func addBlocks(c *check.C) []*event.Block {
 	}
 	return blocks
 }
+
+func (s *EventSuite) TestEventLegalHoldAdd(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventLegalHoldAdd,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	body := strings.NewReader("target.type=app&target.value=myapp&reason=litigation")
+	request, err := http.NewRequest("POST", "/events/legal-holds", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	holds, err := event.ListLegalHolds(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 1)
+	c.Assert(holds[0].Active, check.Equals, true)
+	c.Assert(holds[0].Target, check.DeepEquals, event.Target{Type: event.TargetTypeApp, Value: "myapp"})
+	c.Assert(holds[0].Reason, check.Equals, "litigation")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventLegalHold, Value: holds[0].ID.Hex()},
+		Owner:  token.GetUserName(),
+		Kind:   "event-legal-hold.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": "target.type", "value": "app"},
+			{"name": "target.value", "value": "myapp"},
+			{"name": "reason", "value": "litigation"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventLegalHoldAddWithoutReason(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventLegalHoldAdd,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("POST", "/events/legal-holds", strings.NewReader("ownername=me@me.com"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "legal hold reason is required\n")
+}
+
+func (s *EventSuite) TestEventLegalHoldAddWithoutPermission(c *check.C) {
+	request, err := http.NewRequest("POST", "/events/legal-holds", strings.NewReader("reason=litigation"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	holds, err := event.ListLegalHolds(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 0)
+}
+
+func (s *EventSuite) TestEventLegalHoldList(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventLegalHoldRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	hold1 := &event.LegalHold{OwnerName: "me@me.com", Reason: "litigation"}
+	hold2 := &event.LegalHold{Target: event.Target{Type: event.TargetTypeTeam}, Reason: "audit"}
+	for _, h := range []*event.LegalHold{hold1, hold2} {
+		err := event.AddLegalHold(h)
+		c.Assert(err, check.IsNil)
+	}
+	err := event.ReleaseLegalHold(hold1.ID)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/legal-holds?active=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var holds []event.LegalHold
+	err = json.NewDecoder(recorder.Body).Decode(&holds)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 1)
+	c.Assert(holds[0].ID, check.Equals, hold2.ID)
+}
+
+func (s *EventSuite) TestEventLegalHoldRelease(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventLegalHoldRelease,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	hold := &event.LegalHold{OwnerName: "me@me.com", Reason: "litigation"}
+	err := event.AddLegalHold(hold)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/events/legal-holds/%s", hold.ID.Hex()), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	active := true
+	holds, err := event.ListLegalHolds(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventLegalHold, Value: hold.ID.Hex()},
+		Owner:  token.GetUserName(),
+		Kind:   "event-legal-hold.release",
+		StartCustomData: []map[string]interface{}{
+			{"name": "ID", "value": hold.ID.Hex()},
+		},
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *EventSuite) scheduleRestarts(c *check.C) []*event.Event {
+	var evts []*event.Event
+	for i, team := range []string{s.team.Name, "other-team"} {
+		evt, err := event.New(&event.Opts{
+			Target:  event.Target{Type: event.TargetTypeApp, Value: "app-" + team},
+			Owner:   s.token,
+			Kind:    permission.PermAppUpdateRestart,
+			Allowed: event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, team)),
+			RunAt:   time.Now().Add(time.Duration(i+1) * time.Hour),
+		})
+		c.Assert(err, check.IsNil)
+		evts = append(evts, evt)
+	}
+	return evts
+}
+
+func (s *EventSuite) TestEventScheduledList(c *check.C) {
+	evts := s.scheduleRestarts(c)
+	request, err := http.NewRequest("GET", "/events/scheduled", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.ScheduledEvent
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].ID, check.Equals, evts[0].UniqueID)
+	c.Assert(result[0].Kind.Name, check.Equals, "app.update.restart")
+}
+
+func (s *EventSuite) TestEventScheduledListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/scheduled", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventScheduledCancel(c *check.C) {
+	evts := s.scheduleRestarts(c)
+	request, err := http.NewRequest("DELETE", "/events/scheduled/"+evts[0].UniqueID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = event.GetScheduled(evts[0].UniqueID)
+	c.Assert(err, check.Equals, event.ErrScheduledEventNotFound)
+	_, err = event.GetScheduled(evts[1].UniqueID)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *EventSuite) TestEventScheduledCancelWithoutPermission(c *check.C) {
+	evts := s.scheduleRestarts(c)
+	request, err := http.NewRequest("DELETE", "/events/scheduled/"+evts[1].UniqueID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = event.GetScheduled(evts[1].UniqueID)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *EventSuite) TestEventScheduledCancelNotFound(c *check.C) {
+	for id, code := range map[string]int{"123": http.StatusBadRequest, bson.NewObjectId().Hex(): http.StatusNotFound} {
+		request, err := http.NewRequest("DELETE", "/events/scheduled/"+id, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, code)
+	}
+}
+
+func (s *EventSuite) TestEventConsumeAndAck(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventConsumerConsume,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Events().Update(bson.M{"uniqueid": evt.UniqueID}, bson.M{"$set": bson.M{"endtime": time.Now().Add(-time.Minute)}})
+	c.Assert(err, check.IsNil)
+	server := RunServer(true)
+	request, err := http.NewRequest("POST", "/events/consumers/billing/consume", strings.NewReader("batch=10"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var evts []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &evts)
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt.UniqueID)
+	request, err = http.NewRequest("POST", "/events/consumers/billing/ack", strings.NewReader("id="+evt.UniqueID.Hex()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	n, err := conn.EventConsumerDeliveries().Find(bson.M{"group": "billing"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *EventSuite) TestEventConsumeInvalidBatch(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventConsumerConsume,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("POST", "/events/consumers/billing/consume", strings.NewReader("batch=0"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "consume batch size must be greater than zero\n")
+}
+
+func (s *EventSuite) TestEventAckInvalidID(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventConsumerConsume,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("POST", "/events/consumers/billing/ack", strings.NewReader("id=abc"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *EventSuite) TestEventConsumeWithoutPermission(c *check.C) {
+	request, err := http.NewRequest("POST", "/events/consumers/billing/consume", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *EventSuite) TestEventNotificationAdd(c *check.C) {
+	body := strings.NewReader("scope=apps&erroronly=true&kindname=app.deploy&channel=webhook&url=https://hooks.example.com/tsuru")
+	request, err := http.NewRequest("POST", "/events/notifications", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var created event.NotificationRule
+	err = json.Unmarshal(recorder.Body.Bytes(), &created)
+	c.Assert(err, check.IsNil)
+	rules, err := event.ListNotificationRules(s.token.GetUserName())
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].ID, check.Equals, created.ID)
+	c.Assert(rules[0].Scope, check.Equals, event.NotificationScopeApps)
+	c.Assert(rules[0].KindName, check.Equals, "app.deploy")
+	c.Assert(rules[0].ErrorOnly, check.Equals, true)
+	c.Assert(rules[0].Channel, check.Equals, event.NotificationChannelWebhook)
+	c.Assert(rules[0].URL, check.Equals, "https://hooks.example.com/tsuru")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeUser, Value: s.token.GetUserName()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.notification.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": "scope", "value": "apps"},
+			{"name": "erroronly", "value": "true"},
+			{"name": "kindname", "value": "app.deploy"},
+			{"name": "channel", "value": "webhook"},
+			{"name": "url", "value": "https://hooks.example.com/tsuru"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventNotificationAddInvalid(c *check.C) {
+	request, err := http.NewRequest("POST", "/events/notifications", strings.NewReader("channel=webhook"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, event.ErrInvalidNotificationURL.Error()+"\n")
+}
+
+func (s *EventSuite) TestEventNotificationList(c *check.C) {
+	rule := event.NotificationRule{Owner: s.token.GetUserName()}
+	err := event.AddNotificationRule(&rule)
+	c.Assert(err, check.IsNil)
+	err = event.AddNotificationRule(&event.NotificationRule{Owner: "other@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/notifications", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var rules []event.NotificationRule
+	err = json.Unmarshal(recorder.Body.Bytes(), &rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].ID, check.Equals, rule.ID)
+}
+
+func (s *EventSuite) TestEventNotificationListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/notifications", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventNotificationRemove(c *check.C) {
+	rule := event.NotificationRule{Owner: s.token.GetUserName()}
+	err := event.AddNotificationRule(&rule)
+	c.Assert(err, check.IsNil)
+	other := event.NotificationRule{Owner: "other@tsuru.io"}
+	err = event.AddNotificationRule(&other)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/events/notifications/"+other.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("DELETE", "/events/notifications/"+rule.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rules, err := event.ListNotificationRules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].ID, check.Equals, other.ID)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeUser, Value: s.token.GetUserName()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.notification.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": "ID", "value": rule.ID.Hex()},
+		},
+	}, eventtest.HasEvent)
+}
@@ -0,0 +1,364 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+type groupRole struct {
+	Name    string `json:"name"`
+	Context string `json:"context"`
+}
+
+type groupResult struct {
+	Name    string      `json:"name"`
+	Members []string    `json:"members"`
+	Roles   []groupRole `json:"roles"`
+}
+
+func newGroupResult(g *auth.Group) groupResult {
+	result := groupResult{Name: g.Name, Members: g.Members, Roles: []groupRole{}}
+	if result.Members == nil {
+		result.Members = []string{}
+	}
+	for _, r := range g.Roles {
+		result.Roles = append(result.Roles, groupRole{Name: r.Name, Context: r.ContextValue})
+	}
+	return result
+}
+
+func groupTarget(name string) event.Target {
+	return event.Target{Type: event.TargetTypeGroup, Value: name}
+}
+
+func groupContext(name string) permission.PermissionContext {
+	return permission.Context(permission.CtxGroup, name)
+}
+
+func groupNotFound(name string) error {
+	return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Group "%s" not found.`, name)}
+}
+
+// groupMembers returns the users in the group, so the repository access of
+// all of them can be synced after the roles of the group change.
+func groupMembers(g *auth.Group) ([]auth.User, error) {
+	var users []auth.User
+	for _, email := range g.Members {
+		u, err := auth.GetUserByEmail(email)
+		if err == auth.ErrUserNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, nil
+}
+
+// getGroupForEvent returns the group with the given name and starts an event
+// of the permission targeting it.
+func getGroupForEvent(r *http.Request, t auth.Token, name string, perm *permission.PermissionScheme) (*auth.Group, *event.Event, error) {
+	g, err := auth.GetGroup(name)
+	if err == auth.ErrGroupNotFound {
+		return nil, nil, groupNotFound(name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     groupTarget(name),
+		Kind:       perm,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermGroupReadEvents, groupContext(name)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, evt, nil
+}
+
+// title: group create
+// path: /groups
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Group created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Group already exists
+func groupCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	if !permission.Check(t, permission.PermGroupCreate) {
+		return permission.ErrUnauthorized
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: auth.ErrInvalidGroupName.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     groupTarget(name),
+		Kind:       permission.PermGroupCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermGroupReadEvents, groupContext(name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = auth.CreateGroup(name)
+	switch err {
+	case auth.ErrInvalidGroupName:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case auth.ErrGroupAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err == nil {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return err
+}
+
+// title: group list
+// path: /groups
+// method: GET
+// produce: application/json
+// responses:
+//   200: List groups
+//   204: No content
+//   401: Unauthorized
+func groupList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermGroupRead)
+	allowed := map[string]bool{}
+	var global bool
+	for _, ctx := range contexts {
+		if ctx.CtxType == permission.CtxGlobal {
+			global = true
+		} else if ctx.CtxType == permission.CtxGroup {
+			allowed[ctx.Value] = true
+		}
+	}
+	if !global && len(allowed) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	groups, err := auth.ListGroups()
+	if err != nil {
+		return err
+	}
+	var result []groupResult
+	for i := range groups {
+		if global || allowed[groups[i].Name] {
+			result = append(result, newGroupResult(&groups[i]))
+		}
+	}
+	if len(result) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: group info
+// path: /groups/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: Info about the group
+//   401: Unauthorized
+//   404: Group not found
+func groupInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermGroupRead, groupContext(name)) {
+		return groupNotFound(name)
+	}
+	g, err := auth.GetGroup(name)
+	if err == auth.ErrGroupNotFound {
+		return groupNotFound(name)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(newGroupResult(g))
+}
+
+// title: group remove
+// path: /groups/{name}
+// method: DELETE
+// responses:
+//   200: Group removed
+//   401: Unauthorized
+//   404: Group not found
+func groupRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermGroupDelete, groupContext(name)) {
+		return groupNotFound(name)
+	}
+	g, evt, err := getGroupForEvent(r, t, name, permission.PermGroupDelete)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	members, err := groupMembers(g)
+	if err != nil {
+		return err
+	}
+	return runWithPermSync(members, func() error {
+		return auth.RemoveGroup(name)
+	})
+}
+
+// title: group member add
+// path: /groups/{name}/members
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Member added
+//   401: Unauthorized
+//   403: Forbidden
+//   404: Group or user not found
+func groupMemberAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermGroupUpdateMemberAdd, groupContext(name)) {
+		return groupNotFound(name)
+	}
+	g, evt, err := getGroupForEvent(r, t, name, permission.PermGroupUpdateMemberAdd)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	// New members get all the roles of the group, which must be roles the
+	// caller could assign directly.
+	for _, role := range g.Roles {
+		err = canUseRole(t, role.Name, role.ContextValue)
+		if err != nil {
+			return err
+		}
+	}
+	u, err := auth.GetUserByEmail(r.FormValue("email"))
+	if err == auth.ErrUserNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	return runWithPermSync([]auth.User{*u}, func() error {
+		return g.AddMember(u.Email)
+	})
+}
+
+// title: group member remove
+// path: /groups/{name}/members/{email}
+// method: DELETE
+// responses:
+//   200: Member removed
+//   401: Unauthorized
+//   404: Group not found
+func groupMemberRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermGroupUpdateMemberRemove, groupContext(name)) {
+		return groupNotFound(name)
+	}
+	g, evt, err := getGroupForEvent(r, t, name, permission.PermGroupUpdateMemberRemove)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	email := r.URL.Query().Get(":email")
+	var users []auth.User
+	u, err := auth.GetUserByEmail(email)
+	if err == nil {
+		users = append(users, *u)
+	} else if err != auth.ErrUserNotFound {
+		return err
+	}
+	return runWithPermSync(users, func() error {
+		return g.RemoveMember(email)
+	})
+}
+
+// title: group role add
+// path: /groups/{name}/roles
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Role added
+//   401: Unauthorized
+//   403: Forbidden
+//   404: Group or role not found
+func groupRoleAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermGroupUpdateRoleAdd, groupContext(name)) {
+		return groupNotFound(name)
+	}
+	g, evt, err := getGroupForEvent(r, t, name, permission.PermGroupUpdateRoleAdd)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	roleName := r.FormValue("role")
+	contextValue := r.FormValue("context")
+	err = canUseRole(t, roleName, contextValue)
+	if err != nil {
+		return err
+	}
+	members, err := groupMembers(g)
+	if err != nil {
+		return err
+	}
+	return runWithPermSync(members, func() error {
+		return g.AddRole(roleName, contextValue)
+	})
+}
+
+// title: group role remove
+// path: /groups/{name}/roles/{role}
+// method: DELETE
+// responses:
+//   200: Role removed
+//   401: Unauthorized
+//   403: Forbidden
+//   404: Group or role not found
+func groupRoleRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermGroupUpdateRoleRemove, groupContext(name)) {
+		return groupNotFound(name)
+	}
+	g, evt, err := getGroupForEvent(r, t, name, permission.PermGroupUpdateRoleRemove)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	roleName := r.URL.Query().Get(":role")
+	contextValue := r.URL.Query().Get("context")
+	err = canUseRole(t, roleName, contextValue)
+	if err != nil {
+		return err
+	}
+	members, err := groupMembers(g)
+	if err != nil {
+		return err
+	}
+	return runWithPermSync(members, func() error {
+		return g.RemoveRole(roleName, contextValue)
+	})
+}
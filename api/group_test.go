@@ -0,0 +1,230 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) groupRequest(c *check.C, method, path string, body url.Values, token auth.Token) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(method, path, strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *S) TestGroupCreate(c *check.C) {
+	recorder := s.groupRequest(c, "POST", "/1.3/groups", url.Values{"name": {"robots"}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	_, err := auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGroup, Value: "robots"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "group.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "robots"},
+		},
+	}, eventtest.HasEvent)
+	recorder = s.groupRequest(c, "POST", "/1.3/groups", url.Values{"name": {"robots"}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrGroupAlreadyExists.Error()+"\n")
+}
+
+func (s *S) TestGroupCreateInvalidName(c *check.C) {
+	recorder := s.groupRequest(c, "POST", "/1.3/groups", url.Values{"name": {""}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	recorder = s.groupRequest(c, "POST", "/1.3/groups", url.Values{"name": {"1robots"}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrInvalidGroupName.Error()+"\n")
+}
+
+func (s *S) TestGroupCreateUnauthorized(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "nobody")
+	recorder := s.groupRequest(c, "POST", "/1.3/groups", url.Values{"name": {"robots"}}, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestGroupList(c *check.C) {
+	for _, name := range []string{"robots", "guild"} {
+		err := auth.CreateGroup(name)
+		c.Assert(err, check.IsNil)
+	}
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "reader", permission.Permission{
+		Scheme:  permission.PermGroupRead,
+		Context: permission.Context(permission.CtxGroup, "guild"),
+	})
+	recorder := s.groupRequest(c, "GET", "/1.3/groups", nil, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var groups []groupResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &groups)
+	c.Assert(err, check.IsNil)
+	c.Assert(groups, check.DeepEquals, []groupResult{{Name: "guild", Members: []string{}, Roles: []groupRole{}}})
+	recorder = s.groupRequest(c, "GET", "/1.3/groups", nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.Unmarshal(recorder.Body.Bytes(), &groups)
+	c.Assert(err, check.IsNil)
+	c.Assert(groups, check.HasLen, 2)
+}
+
+func (s *S) TestGroupListEmpty(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "nobody")
+	recorder := s.groupRequest(c, "GET", "/1.3/groups", nil, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestGroupInfo(c *check.C) {
+	_, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	g, err := auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	err = g.AddMember("robot@tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("deployer", "myapp")
+	c.Assert(err, check.IsNil)
+	recorder := s.groupRequest(c, "GET", "/1.3/groups/robots", nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result groupResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, groupResult{
+		Name:    "robots",
+		Members: []string{"robot@tsuru.io"},
+		Roles:   []groupRole{{Name: "deployer", Context: "myapp"}},
+	})
+}
+
+func (s *S) TestGroupInfoNotAllowed(c *check.C) {
+	err := auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "reader", permission.Permission{
+		Scheme:  permission.PermGroupRead,
+		Context: permission.Context(permission.CtxGroup, "guild"),
+	})
+	recorder := s.groupRequest(c, "GET", "/1.3/groups/robots", nil, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "Group \"robots\" not found.\n")
+	recorder = s.groupRequest(c, "GET", "/1.3/groups/unknown", nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestGroupMemberAddGrantsGroupRoles(c *check.C) {
+	role, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	err = auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	robot, robotToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "robot")
+	recorder := s.groupRequest(c, "POST", "/1.3/groups/robots/roles", url.Values{"role": {"deployer"}, "context": {"myapp"}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	recorder = s.groupRequest(c, "PUT", "/1.3/groups/robots/members", url.Values{"email": {robot.Email}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(permission.Check(robotToken, permission.PermAppDeploy, permission.Context(permission.CtxApp, "myapp")), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGroup, Value: "robots"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "group.update.member.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "robots"},
+			{"name": "email", "value": robot.Email},
+		},
+	}, eventtest.HasEvent)
+	recorder = s.groupRequest(c, "DELETE", "/1.3/groups/robots/members/"+robot.Email, nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(permission.Check(robotToken, permission.PermAppDeploy, permission.Context(permission.CtxApp, "myapp")), check.Equals, false)
+}
+
+func (s *S) TestGroupMemberAddForbiddenGroupRoles(c *check.C) {
+	role, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	err = auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	g, err := auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("deployer", "myapp")
+	c.Assert(err, check.IsNil)
+	manager, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "manager", permission.Permission{
+		Scheme:  permission.PermGroupUpdateMemberAdd,
+		Context: permission.Context(permission.CtxGroup, "robots"),
+	})
+	recorder := s.groupRequest(c, "PUT", "/1.3/groups/robots/members", url.Values{"email": {manager.Email}}, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	g, err = auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Members, check.HasLen, 0)
+	c.Assert(permission.Check(token, permission.PermAppDeploy, permission.Context(permission.CtxApp, "myapp")), check.Equals, false)
+}
+
+func (s *S) TestGroupMemberAddUserNotFound(c *check.C) {
+	err := auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	recorder := s.groupRequest(c, "PUT", "/1.3/groups/robots/members", url.Values{"email": {"unknown@tsuru.io"}}, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrUserNotFound.Error()+"\n")
+}
+
+func (s *S) TestGroupRoleAddForbidden(c *check.C) {
+	role, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	err = auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "manager", permission.Permission{
+		Scheme:  permission.PermGroupUpdateRoleAdd,
+		Context: permission.Context(permission.CtxGroup, "robots"),
+	})
+	recorder := s.groupRequest(c, "POST", "/1.3/groups/robots/roles", url.Values{"role": {"deployer"}, "context": {"myapp"}}, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	g, err := auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Roles, check.HasLen, 0)
+}
+
+func (s *S) TestGroupRoleRemove(c *check.C) {
+	_, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	g, err := auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("deployer", "myapp")
+	c.Assert(err, check.IsNil)
+	recorder := s.groupRequest(c, "DELETE", "/1.3/groups/robots/roles/deployer?context=myapp", nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	g, err = auth.GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Roles, check.HasLen, 0)
+}
+
+func (s *S) TestGroupRemove(c *check.C) {
+	err := auth.CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	recorder := s.groupRequest(c, "DELETE", "/1.3/groups/robots", nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = auth.GetGroup("robots")
+	c.Assert(err, check.Equals, auth.ErrGroupNotFound)
+	recorder = s.groupRequest(c, "DELETE", "/1.3/groups/robots", nil, s.token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
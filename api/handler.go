@@ -17,6 +17,11 @@ var (
 		Code:    http.StatusUnauthorized,
 		Message: "You must provide a valid Authorization header",
 	}
+	tokenExpiredErr = &errors.HTTP{
+		Code:      http.StatusUnauthorized,
+		Message:   "Your session has expired, please log in again",
+		ErrorCode: errors.CodeTokenExpired,
+	}
 )
 
 type Handler func(http.ResponseWriter, *http.Request) error
@@ -31,7 +36,11 @@ func (fn AuthorizationRequiredHandler) ServeHTTP(w http.ResponseWriter, r *http.
 	t := context.GetAuthToken(r)
 	if t == nil {
 		w.Header().Set("WWW-Authenticate", "Bearer realm=\"tsuru\" scope=\"tsuru\"")
-		context.AddRequestError(r, tokenRequiredErr)
+		if context.IsTokenExpired(r) {
+			context.AddRequestError(r, tokenExpiredErr)
+		} else {
+			context.AddRequestError(r, tokenRequiredErr)
+		}
 	} else {
 		context.AddRequestError(r, fn(w, r, t))
 	}
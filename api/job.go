@@ -0,0 +1,337 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func init() {
+	event.SetScheduledExecutor(permission.PermAppRunJob.FullName(), scheduledJobRun)
+}
+
+func jobTarget(appName, jobName string) event.Target {
+	return event.Target{Type: event.TargetTypeJob, Value: fmt.Sprintf("%s/%s", appName, jobName)}
+}
+
+func getJobOrError(appName, jobName string) (*app.Job, error) {
+	job, err := app.GetJob(appName, jobName)
+	if err == app.ErrJobNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return job, err
+}
+
+// title: job create
+// path: /apps/{app}/jobs
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Job created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+//   409: Job already exists
+func jobCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateJobCreate,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	job := app.Job{
+		Name:      r.FormValue("name"),
+		App:       a.Name,
+		Schedule:  r.FormValue("schedule"),
+		Command:   r.FormValue("command"),
+		CreatedBy: t.GetUserName(),
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateJobCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.CreateJob(&job)
+	if err != nil {
+		if err == app.ErrJobAlreadyExists {
+			return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+		}
+		if _, ok := err.(*errors.ValidationError); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	err = scheduleJobRun(&job, &event.Opts{Owner: t})
+	if err != nil {
+		if rmErr := app.RemoveJob(job.App, job.Name); rmErr != nil {
+			log.Errorf("[job] unable to remove job %s of app %s after failing to schedule it: %s", job.Name, job.App, rmErr)
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(job)
+}
+
+// title: job list
+// path: /apps/{app}/jobs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func jobList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadJob,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	jobs, err := app.ListJobs(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(jobs)
+}
+
+// title: job remove
+// path: /apps/{app}/jobs/{job}
+// method: DELETE
+// responses:
+//   200: Job removed
+//   401: Unauthorized
+//   404: App or job not found
+func jobRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateJobDelete,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	job, err := getJobOrError(a.Name, r.URL.Query().Get(":job"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateJobDelete,
+		Owner:      t,
+		CustomData: append(event.FormToCustomData(r.Form), map[string]interface{}{"name": "job", "value": job.Name}),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.RemoveJob(job.App, job.Name)
+	if err != nil {
+		return err
+	}
+	return cancelScheduledJobRuns(job)
+}
+
+// title: job trigger
+// path: /apps/{app}/jobs/{job}/trigger
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: Job run
+//   401: Unauthorized
+//   404: App or job not found
+func jobTrigger(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRunJob,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	job, err := getJobOrError(a.Name, r.URL.Query().Get(":job"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:       jobTarget(job.App, job.Name),
+		ExtraTargets: []event.Target{appTarget(job.App)},
+		Kind:         permission.PermAppRunJob,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	return job.Run(evt)
+}
+
+// title: job run list
+// path: /apps/{app}/jobs/{job}/runs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or job not found
+func jobRunList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadJob,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	job, err := getJobOrError(a.Name, r.URL.Query().Get(":job"))
+	if err != nil {
+		return err
+	}
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "limit must be a positive integer"}
+		}
+	}
+	filter := &event.Filter{
+		Target:   jobTarget(job.App, job.Name),
+		KindName: permission.PermAppRunJob.FullName(),
+		Limit:    limit,
+	}
+	filter.PruneUserValues()
+	runs, err := event.List(filter)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(runs)
+}
+
+// cancelScheduledJobRuns cancels the runs of the job waiting to be started.
+func cancelScheduledJobRuns(job *app.Job) error {
+	scheduled, err := event.ListScheduled(&event.Filter{
+		Target:   jobTarget(job.App, job.Name),
+		KindName: permission.PermAppRunJob.FullName(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, sched := range scheduled {
+		err = event.CancelScheduled(sched.ID)
+		if err != nil && err != event.ErrScheduledEventNotFound && err != event.ErrScheduledEventStarting {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleJobRun replaces the scheduled run of the job with one at the next
+// occurrence of its schedule. Owner or RawOwner must be set in opts, the run
+// is started on their behalf.
+func scheduleJobRun(job *app.Job, opts *event.Opts) error {
+	err := cancelScheduledJobRuns(job)
+	if err != nil {
+		return err
+	}
+	a, err := app.GetByName(job.App)
+	if err != nil {
+		return err
+	}
+	opts.RunAt, err = job.NextRun(time.Now())
+	if err != nil {
+		return err
+	}
+	opts.Target = jobTarget(job.App, job.Name)
+	opts.ExtraTargets = []event.Target{appTarget(job.App)}
+	opts.Kind = permission.PermAppRunJob
+	opts.CustomData = map[string]string{"schedule": job.Schedule}
+	opts.Allowed = event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...)
+	_, err = event.New(opts)
+	return err
+}
+
+// scheduledJobRun runs the job in the target of a scheduled run event and
+// schedules the next one.
+func scheduledJobRun(evt *event.Event) error {
+	parts := strings.SplitN(evt.Target.Value, "/", 2)
+	if len(parts) != 2 {
+		return app.ErrJobNotFound
+	}
+	job, err := app.GetJob(parts[0], parts[1])
+	if err == app.ErrJobNotFound {
+		evt.Logf("The job was removed, skipping run.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	schedErr := scheduleJobRun(job, &event.Opts{RawOwner: evt.Owner})
+	if schedErr != nil {
+		log.Errorf("[job] unable to schedule the next run of %s: %s", evt.Target.Value, schedErr)
+	}
+	return job.Run(evt)
+}
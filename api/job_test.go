@@ -0,0 +1,292 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createJob(c *check.C, appName, name string) *app.Job {
+	v := url.Values{"name": {name}, "schedule": {"0 3 * * *"}, "command": {"./cleanup.sh"}}
+	request, err := http.NewRequest("POST", "/1.3/apps/"+appName+"/jobs", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	job, err := app.GetJob(appName, name)
+	c.Assert(err, check.IsNil)
+	return job
+}
+
+func (s *S) TestJobCreate(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	job := s.createJob(c, a.Name, "cleanup")
+	c.Assert(job.Schedule, check.Equals, "0 3 * * *")
+	c.Assert(job.Command, check.Equals, "./cleanup.sh")
+	c.Assert(job.CreatedBy, check.Equals, s.token.GetUserName())
+	scheduled, err := event.ListScheduled(&event.Filter{KindName: "app.run.job"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+	c.Assert(scheduled[0].Target, check.DeepEquals, jobTarget(a.Name, "cleanup"))
+	c.Assert(scheduled[0].ExtraTargets, check.DeepEquals, []event.Target{appTarget(a.Name)})
+	c.Assert(scheduled[0].Owner.Name, check.Equals, s.token.GetUserName())
+	c.Assert(scheduled[0].RunAt.UTC().Hour(), check.Equals, 3)
+	c.Assert(scheduled[0].RunAt.After(time.Now()), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.job.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "cleanup"},
+			{"name": "schedule", "value": "0 3 * * *"},
+			{"name": "command", "value": "./cleanup.sh"},
+			{"name": ":app", "value": a.Name},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobCreateInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createJob(c, a.Name, "cleanup")
+	tests := []struct {
+		values  url.Values
+		code    int
+		message string
+	}{
+		{url.Values{"name": {"report"}, "schedule": {"0 0 30 2 *"}, "command": {"ls"}}, http.StatusBadRequest, `invalid schedule "0 0 30 2 *": it never runs`},
+		{url.Values{"name": {"report"}, "schedule": {"* * * * *"}}, http.StatusBadRequest, "the command of the job is required"},
+		{url.Values{"name": {"cleanup"}, "schedule": {"* * * * *"}, "command": {"ls"}}, http.StatusConflict, app.ErrJobAlreadyExists.Error()},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest("POST", "/1.3/apps/myapp/jobs", strings.NewReader(test.values.Encode()))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, test.code)
+		c.Check(recorder.Body.String(), check.Equals, test.message+"\n")
+	}
+	scheduled, err := event.ListScheduled(&event.Filter{KindName: "app.run.job"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+}
+
+func (s *S) TestJobCreateWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	v := url.Values{"name": {"cleanup"}, "schedule": {"* * * * *"}, "command": {"ls"}}
+	request, err := http.NewRequest("POST", "/1.3/apps/myapp/jobs", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestJobList(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	s.createJob(c, a.Name, "report")
+	s.createJob(c, a.Name, "cleanup")
+	request, err = http.NewRequest("GET", "/1.3/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var jobs []app.Job
+	err = json.Unmarshal(recorder.Body.Bytes(), &jobs)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 2)
+	c.Assert(jobs[0].Name, check.Equals, "cleanup")
+	c.Assert(jobs[1].Name, check.Equals, "report")
+}
+
+func (s *S) TestJobRemove(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createJob(c, a.Name, "cleanup")
+	request, err := http.NewRequest("DELETE", "/1.3/apps/myapp/jobs/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = app.GetJob(a.Name, "cleanup")
+	c.Assert(err, check.Equals, app.ErrJobNotFound)
+	scheduled, err := event.ListScheduled(&event.Filter{KindName: "app.run.job"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.job.delete",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": ":job", "value": "cleanup"},
+			{"name": "job", "value": "cleanup"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobRemoveNotFound(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/1.3/apps/myapp/jobs/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrJobNotFound.Error()+"\n")
+}
+
+func (s *S) TestJobTrigger(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createJob(c, a.Name, "cleanup")
+	s.provisioner.PrepareOutput([]byte("cleaned up"))
+	request, err := http.NewRequest("POST", "/1.3/apps/myapp/jobs/cleanup/trigger", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"cleaned up"}`+"\n")
+	c.Assert(eventtest.EventDesc{
+		Target: jobTarget(a.Name, "cleanup"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.run.job",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": ":job", "value": "cleanup"},
+		},
+		LogMatches: "cleaned up",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/1.3/apps/myapp/jobs/cleanup/runs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var runs []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &runs)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Log, check.Equals, "cleaned up")
+}
+
+func (s *S) TestJobTriggerWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createJob(c, a.Name, "cleanup")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", "/1.3/apps/myapp/jobs/cleanup/trigger", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestJobRunListInvalidLimit(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createJob(c, a.Name, "cleanup")
+	request, err := http.NewRequest("GET", "/1.3/apps/myapp/jobs/cleanup/runs?limit=x", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestScheduledJobRun(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createJob(c, a.Name, "cleanup")
+	s.provisioner.PrepareOutput([]byte("cleaned up"))
+	evt, err := event.New(&event.Opts{
+		Target:   jobTarget(a.Name, "cleanup"),
+		Kind:     permission.PermAppRunJob,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = scheduledJobRun(evt)
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	scheduled, err := event.ListScheduled(&event.Filter{KindName: "app.run.job"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+	c.Assert(scheduled[0].Owner.Name, check.Equals, s.user.Email)
+	c.Assert(eventtest.EventDesc{
+		Target:     jobTarget(a.Name, "cleanup"),
+		Owner:      s.user.Email,
+		Kind:       "app.run.job",
+		LogMatches: "cleaned up",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestScheduledJobRunRemovedJob(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   jobTarget(a.Name, "cleanup"),
+		Kind:     permission.PermAppRunJob,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = scheduledJobRun(evt)
+	c.Assert(err, check.IsNil)
+	scheduled, err := event.ListScheduled(&event.Filter{KindName: "app.run.job"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 0)
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+// title: app log drain list
+// path: /apps/{app}/log-drains
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func appLogDrainList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppReadLog, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	return writeLogDrains(w, a.Name, "")
+}
+
+// title: app log drain add
+// path: /apps/{app}/log-drains
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Log drain added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+//   409: Log drain already exists
+func appLogDrainAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateLogDrainAdd, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateLogDrainAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return addLogDrain(w, app.LogDrain{Name: r.FormValue("name"), URL: r.FormValue("url"), App: a.Name})
+}
+
+// title: app log drain remove
+// path: /apps/{app}/log-drains/{drain}
+// method: DELETE
+// responses:
+//   200: Log drain removed
+//   401: Unauthorized
+//   404: Not found
+func appLogDrainRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateLogDrainRemove, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateLogDrainRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return removeLogDrain(a.Name, "", r.URL.Query().Get(":drain"))
+}
+
+// title: pool log drain list
+// path: /pools/{name}/log-drains
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: Pool not found
+func poolLogDrainList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pool := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermPoolReadLogDrain, permission.Context(permission.CtxPool, pool)) {
+		return permission.ErrUnauthorized
+	}
+	_, err := provision.GetPoolByName(pool)
+	if err == provision.ErrPoolNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	return writeLogDrains(w, "", pool)
+}
+
+// title: pool log drain add
+// path: /pools/{name}/log-drains
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Log drain added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+//   409: Log drain already exists
+func poolLogDrainAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	pool := r.URL.Query().Get(":name")
+	ctx := permission.Context(permission.CtxPool, pool)
+	if !permission.Check(t, permission.PermPoolUpdateLogDrainAdd, ctx) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: pool},
+		Kind:       permission.PermPoolUpdateLogDrainAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, ctx),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return addLogDrain(w, app.LogDrain{Name: r.FormValue("name"), URL: r.FormValue("url"), Pool: pool})
+}
+
+// title: pool log drain remove
+// path: /pools/{name}/log-drains/{drain}
+// method: DELETE
+// responses:
+//   200: Log drain removed
+//   401: Unauthorized
+//   404: Not found
+func poolLogDrainRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	pool := r.URL.Query().Get(":name")
+	ctx := permission.Context(permission.CtxPool, pool)
+	if !permission.Check(t, permission.PermPoolUpdateLogDrainRemove, ctx) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: pool},
+		Kind:       permission.PermPoolUpdateLogDrainRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, ctx),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return removeLogDrain("", pool, r.URL.Query().Get(":drain"))
+}
+
+func writeLogDrains(w http.ResponseWriter, appName, pool string) error {
+	drains, err := app.ListLogDrains(appName, pool)
+	if err != nil {
+		return err
+	}
+	if len(drains) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(drains)
+}
+
+func addLogDrain(w http.ResponseWriter, drain app.LogDrain) error {
+	err := app.AddLogDrain(drain)
+	if err == nil {
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}
+	if _, ok := err.(*terrors.ValidationError); ok {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	switch err {
+	case app.ErrLogDrainAlreadyExists:
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case provision.ErrPoolNotFound:
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+func removeLogDrain(appName, pool, name string) error {
+	err := app.RemoveLogDrain(appName, pool, name)
+	if err == app.ErrLogDrainNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppLogDrainAddListAndRemove(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("name=central&url=syslog%2Btcp%3A%2F%2Flogs.example.com%3A514")
+	req, err := http.NewRequest("POST", "/1.3/apps/myapp/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.log-drain.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "myapp"},
+			{"name": "name", "value": "central"},
+			{"name": "url", "value": "syslog+tcp://logs.example.com:514"},
+		},
+	}, eventtest.HasEvent)
+	req, err = http.NewRequest("GET", "/1.3/apps/myapp/log-drains", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var drains []app.LogDrain
+	err = json.NewDecoder(rec.Body).Decode(&drains)
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.DeepEquals, []app.LogDrain{
+		{Name: "central", URL: "syslog+tcp://logs.example.com:514", App: "myapp"},
+	})
+	req, err = http.NewRequest("DELETE", "/1.3/apps/myapp/log-drains/central", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.log-drain.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "myapp"},
+			{"name": ":drain", "value": "central"},
+		},
+	}, eventtest.HasEvent)
+	req, err = http.NewRequest("GET", "/1.3/apps/myapp/log-drains", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	req, err = http.NewRequest("DELETE", "/1.3/apps/myapp/log-drains/central", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+	c.Assert(rec.Body.String(), check.Equals, app.ErrLogDrainNotFound.Error()+"\n")
+}
+
+func (s *S) TestAppLogDrainAddInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("name=central&url=http%3A%2F%2Flogs.example.com%3A80")
+	req, err := http.NewRequest("POST", "/1.3/apps/myapp/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, `invalid log drain protocol "http", valid protocols are: syslog+tcp, syslog+udp, fluentd`+"\n")
+}
+
+func (s *S) TestAppLogDrainAddRequiresPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateLogDrainRemove,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("name=central&url=fluentd%3A%2F%2Ffluentd%3A24224")
+	req, err := http.NewRequest("POST", "/1.3/apps/myapp/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolLogDrainAddAndList(c *check.C) {
+	body := strings.NewReader("name=central&url=fluentd%3A%2F%2Ffluentd%3A24224")
+	req, err := http.NewRequest("POST", "/1.3/pools/test1/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "test1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.log-drain.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "test1"},
+			{"name": "name", "value": "central"},
+			{"name": "url", "value": "fluentd://fluentd:24224"},
+		},
+	}, eventtest.HasEvent)
+	body = strings.NewReader("name=central&url=fluentd%3A%2F%2Ffluentd%3A24224")
+	req, err = http.NewRequest("POST", "/1.3/pools/test1/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	req, err = http.NewRequest("GET", "/1.3/pools/test1/log-drains", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var drains []app.LogDrain
+	err = json.NewDecoder(rec.Body).Decode(&drains)
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.DeepEquals, []app.LogDrain{
+		{Name: "central", URL: "fluentd://fluentd:24224", Pool: "test1"},
+	})
+}
+
+func (s *S) TestPoolLogDrainRemove(c *check.C) {
+	err := app.AddLogDrain(app.LogDrain{Name: "central", URL: "syslog+udp://logs:514", Pool: "test1"})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/1.3/pools/test1/log-drains/central", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "test1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.log-drain.remove",
+	}, eventtest.HasEvent)
+	drains, err := app.ListLogDrains("", "test1")
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.HasLen, 0)
+}
+
+func (s *S) TestPoolLogDrainPoolNotFound(c *check.C) {
+	body := strings.NewReader("name=central&url=fluentd%3A%2F%2Ffluentd%3A24224")
+	req, err := http.NewRequest("POST", "/1.3/pools/unknown/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+	req, err = http.NewRequest("GET", "/1.3/pools/unknown/log-drains", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestPoolLogDrainAddRequiresPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateLogDrainAdd,
+		Context: permission.Context(permission.CtxPool, "other"),
+	})
+	body := strings.NewReader("name=central&url=fluentd%3A%2F%2Ffluentd%3A24224")
+	req, err := http.NewRequest("POST", "/1.3/pools/test1/log-drains", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
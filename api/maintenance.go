@@ -0,0 +1,98 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router"
+)
+
+// title: app maintenance start
+// path: /apps/{app}/maintenance
+// method: POST
+// responses:
+//   200: App in maintenance
+//   400: Router does not support maintenance
+//   401: Unauthorized
+//   404: App not found
+//   409: App already in maintenance
+func appMaintenanceStart(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateMaintenance, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	if a.Maintenance != nil {
+		return &terrors.HTTP{Code: http.StatusConflict, Message: app.ErrAppInMaintenance.Error()}
+	}
+	// The event runs for as long as the app is in maintenance, without
+	// holding the lock of the app, and canceling it ends the maintenance.
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(a.Name),
+		Kind:          permission.PermAppUpdateMaintenance,
+		Owner:         t,
+		CustomData:    event.FormToCustomData(r.Form),
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(&a)...),
+		Cancelable:    true,
+		DisableLock:   true,
+	})
+	if err != nil {
+		return err
+	}
+	err = a.StartMaintenance(evt)
+	if err != nil {
+		evt.Done(err)
+	}
+	switch err {
+	case router.ErrMaintenanceNotSupported:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case app.ErrAppInMaintenance:
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app maintenance end
+// path: /apps/{app}/maintenance
+// method: DELETE
+// responses:
+//   200: App out of maintenance
+//   401: Unauthorized
+//   404: Not found
+func appMaintenanceEnd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateMaintenance, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	if a.Maintenance == nil {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: app.ErrAppNotInMaintenance.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateMaintenance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.EndMaintenance()
+}
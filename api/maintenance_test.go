@@ -0,0 +1,88 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMaintenanceStart(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(routertest.MaintenanceRouter.Pages["web"], check.Not(check.Equals), "")
+	dbApp, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.NotNil)
+	evt, err := event.GetByID(dbApp.Maintenance.EventID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Running, check.Equals, true)
+	c.Assert(evt.Cancelable, check.Equals, true)
+	c.Assert(evt.Kind.Name, check.Equals, "app.update.maintenance")
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAppMaintenanceStartNotSupported(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, router.ErrMaintenanceNotSupported.Error()+"\n")
+	c.Assert(eventtest.EventDesc{
+		Target:       appTarget("web"),
+		Owner:        s.token.GetUserName(),
+		Kind:         "app.update.maintenance",
+		ErrorMatches: router.ErrMaintenanceNotSupported.Error(),
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppMaintenanceEnd(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	req, err = http.NewRequest("DELETE", "/1.3/apps/web/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(routertest.MaintenanceRouter.Pages, check.HasLen, 0)
+	dbApp, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.IsNil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const (
+	// maintenanceCanceledEventKind is the internal kind of the events
+	// recording the end of maintenances whose events were canceled.
+	maintenanceCanceledEventKind = "app-maintenance-canceled"
+
+	defaultMaintenanceWatcherInterval = 10 * time.Second
+)
+
+// maintenanceWatcher periodically ends the maintenance of apps whose
+// maintenance events were canceled.
+type maintenanceWatcher struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// initializeMaintenanceWatcher starts watching the events of apps in
+// maintenance, checking them every maintenance:watch-interval seconds.
+func initializeMaintenanceWatcher() {
+	mw := &maintenanceWatcher{
+		interval: defaultMaintenanceWatcherInterval,
+		done:     make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("maintenance:watch-interval"); seconds > 0 {
+		mw.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(mw)
+	go mw.run()
+}
+
+func (mw *maintenanceWatcher) run() {
+	for {
+		err := endCanceledMaintenances()
+		if err != nil {
+			log.Errorf("[maintenance watcher] unable to end canceled maintenances: %s", err)
+		}
+		select {
+		case <-mw.done:
+			return
+		case <-time.After(mw.interval):
+		}
+	}
+}
+
+func (mw *maintenanceWatcher) Shutdown() {
+	mw.done <- true
+}
+
+func (mw *maintenanceWatcher) String() string {
+	return "maintenance watcher"
+}
+
+// endCanceledMaintenances ends the maintenance of the apps whose maintenance
+// events had their cancellation asked or finished otherwise, like by
+// expiring. Apps locked by other events are skipped until the next run.
+func endCanceledMaintenances() error {
+	apps, err := app.ListAppsInMaintenance()
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		a := &apps[i]
+		evt, err := event.GetByID(a.Maintenance.EventID)
+		if err != nil && err != event.ErrEventNotFound {
+			log.Errorf("[maintenance watcher] unable to get maintenance event of app %q: %s", a.Name, err)
+			continue
+		}
+		if err == nil && evt.Running && !evt.CancelInfo.Asked {
+			continue
+		}
+		err = endCanceledMaintenance(a, evt)
+		if err != nil {
+			log.Errorf("[maintenance watcher] unable to end maintenance of app %q: %s", a.Name, err)
+		}
+	}
+	return nil
+}
+
+func endCanceledMaintenance(a *app.App, maintenanceEvt *event.Event) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       appTarget(a.Name),
+		InternalKind: maintenanceCanceledEventKind,
+		CustomData: map[string]interface{}{
+			"eventID":   a.Maintenance.EventID.Hex(),
+			"startTime": a.Maintenance.StartTime,
+			"owner":     a.Maintenance.Owner,
+		},
+		Allowed: event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...),
+	})
+	if _, locked := err.(event.ErrEventLocked); locked {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	if maintenanceEvt != nil && maintenanceEvt.Running {
+		_, err = maintenanceEvt.AckCancel()
+		if err != nil {
+			return err
+		}
+	}
+	return a.EndMaintenance()
+}
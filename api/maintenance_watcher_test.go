@@ -0,0 +1,52 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEndCanceledMaintenances(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	err = endCanceledMaintenances()
+	c.Assert(err, check.IsNil)
+	dbApp, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.NotNil)
+	evt, err := event.GetByID(dbApp.Maintenance.EventID)
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("done", s.user.Email)
+	c.Assert(err, check.IsNil)
+	err = endCanceledMaintenances()
+	c.Assert(err, check.IsNil)
+	dbApp, err = app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.IsNil)
+	c.Assert(routertest.MaintenanceRouter.Pages, check.HasLen, 0)
+	evt, err = event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Running, check.Equals, false)
+	c.Assert(evt.Error, check.Equals, "canceled by user request")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("web"),
+		Kind:   maintenanceCanceledEventKind,
+	}, eventtest.HasEvent)
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/codegangsta/negroni"
@@ -23,20 +24,40 @@ import (
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/readonly"
 )
 
 const (
 	tsuruMin      = "1.0.1"
 	craneMin      = "1.0.0"
 	tsuruAdminMin = "1.0.0"
+
+	// onBehalfOfHeader is sent by admins acting on behalf of a team, events
+	// created by the request record the team as their acting owner.
+	onBehalfOfHeader = "X-Tsuru-On-Behalf-Of"
 )
 
 func validate(token string, r *http.Request) (auth.Token, error) {
-	t, err := app.AuthScheme.Auth(token)
+	var (
+		t   auth.Token
+		err error
+	)
+	if auth.IsNamedAPIToken(token) {
+		t, err = auth.NamedAPITokenAuth(r.Context(), token)
+	} else {
+		t, err = app.AuthScheme.Auth(r.Context(), token)
+		if err != nil && err != auth.ErrTokenExpired {
+			t, err = auth.APIAuth(r.Context(), token)
+		}
+	}
 	if err != nil {
-		t, err = auth.APIAuth(token)
-		if err != nil {
-			return nil, err
+		return nil, err
+	}
+	if !t.IsAppToken() {
+		if u, userErr := t.User(); userErr == nil && u.Suspended {
+			return nil, &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: auth.ErrUserSuspended.Error()}
 		}
 	}
 	if t.IsAppToken() {
@@ -54,9 +75,32 @@ func validate(token string, r *http.Request) (auth.Token, error) {
 			}
 		}
 	}
+	if teamName := r.Header.Get(onBehalfOfHeader); teamName != "" {
+		return impersonateTeam(t, teamName)
+	}
 	return t, nil
 }
 
+func impersonateTeam(t auth.Token, teamName string) (auth.Token, error) {
+	allowed := !t.IsAppToken() && permission.Check(t, permission.PermTeamImpersonate,
+		permission.Context(permission.CtxTeam, teamName),
+	)
+	if !allowed {
+		return nil, &tsuruErrors.HTTP{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("not allowed to act on behalf of team %q", teamName),
+		}
+	}
+	_, err := auth.GetTeam(teamName)
+	if err == auth.ErrTeamNotFound {
+		return nil, &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &auth.ImpersonationToken{Token: t, Team: teamName}, nil
+}
+
 func contextClearerMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	defer context.Clear(r)
 	next(w, r)
@@ -103,10 +147,8 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 	next(w, r)
 	err := context.GetRequestError(r)
 	if err != nil {
-		code := http.StatusInternalServerError
-		if e, ok := err.(*tsuruErrors.HTTP); ok {
-			code = e.Code
-		}
+		httpErr := toHTTPError(err)
+		code := httpErr.Code
 		flushing, ok := w.(*io.FlushingWriter)
 		if ok && flushing.Wrote() {
 			if w.Header().Get("Content-Type") == "application/x-json-stream" {
@@ -117,6 +159,11 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 			} else {
 				fmt.Fprintln(w, err)
 			}
+		} else if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(httpErr.Payload())
 		} else {
 			http.Error(w, err.Error(), code)
 		}
@@ -124,23 +171,113 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 	}
 }
 
+// toHTTPError returns the HTTP error describing err, classifying the known
+// errors that don't carry a status code. Other errors are internal server
+// errors.
+func toHTTPError(err error) *tsuruErrors.HTTP {
+	if e, ok := err.(*tsuruErrors.HTTP); ok {
+		return e
+	}
+	if _, ok := errors.Cause(err).(*quota.QuotaExceededError); ok {
+		return &tsuruErrors.HTTP{
+			Code:      http.StatusForbidden,
+			Message:   err.Error(),
+			ErrorCode: tsuruErrors.CodeQuotaExceeded,
+		}
+	}
+	return &tsuruErrors.HTTP{Code: http.StatusInternalServerError, Message: err.Error()}
+}
+
 func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	token := r.Header.Get("Authorization")
 	if token != "" {
 		t, err := validate(token, r)
 		if err != nil {
-			if err != auth.ErrInvalidToken {
+			if err == auth.ErrTokenExpired {
+				context.SetTokenExpired(r)
+			} else if err != auth.ErrInvalidToken {
 				context.AddRequestError(r, err)
 				return
 			}
 			log.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
 		} else {
 			context.SetAuthToken(r, t)
+			recordTokenUsage(t)
+		}
+	}
+	next(w, r)
+}
+
+func recordTokenUsage(t auth.Token) {
+	if t.IsAppToken() {
+		return
+	}
+	if impToken, ok := t.(*auth.ImpersonationToken); ok {
+		t = impToken.Token
+	}
+	kind := auth.TokenKindSession
+	switch t.(type) {
+	case *auth.APIToken:
+		kind = auth.TokenKindAPIKey
+	case *auth.NamedAPIToken:
+		kind = auth.TokenKindAPIToken
+	}
+	err := auth.RecordTokenUsage(t.GetUserName(), kind)
+	if err != nil {
+		log.Errorf("unable to record token usage of %s: %s", t.GetUserName(), err)
+	}
+}
+
+// readOnlyMiddleware rejects mutating requests while the whole API, or the
+// pool of the app or of the pool parameter of the request, is in read-only
+// mode. Excluded handlers are called even in read-only mode.
+type readOnlyMiddleware struct {
+	excludedHandlers []http.Handler
+}
+
+func (m *readOnlyMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		next(w, r)
+		return
+	}
+	currentHandler := context.GetDelayedHandler(r)
+	if currentHandler != nil {
+		currentHandlerPtr := reflect.ValueOf(currentHandler).Pointer()
+		for _, h := range m.excludedHandlers {
+			if reflect.ValueOf(h).Pointer() == currentHandlerPtr {
+				next(w, r)
+				return
+			}
 		}
 	}
+	err := readonly.Check(requestPool(r))
+	if err != nil {
+		context.AddRequestError(r, &tsuruErrors.HTTP{Code: http.StatusServiceUnavailable, Message: err.Error()})
+		return
+	}
 	next(w, r)
 }
 
+// requestPool returns the pool affected by the request, which is the pool of
+// the app in the URL or the pool parameter of url encoded forms.
+func requestPool(r *http.Request) string {
+	appName := r.URL.Query().Get(":app")
+	if appName == "" {
+		appName = r.URL.Query().Get(":appname")
+	}
+	if appName != "" {
+		a, err := app.GetByName(appName)
+		if err == nil {
+			return a.Pool
+		}
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return r.FormValue("pool")
+	}
+	return r.URL.Query().Get("pool")
+}
+
 type appLockMiddleware struct {
 	excludedHandlers []http.Handler
 }
@@ -206,7 +343,7 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 			err = errors.Wrap(err, "Error to get application")
 		}
 	} else {
-		httpErr := &tsuruErrors.HTTP{Code: http.StatusConflict}
+		httpErr := &tsuruErrors.HTTP{Code: http.StatusConflict, ErrorCode: tsuruErrors.CodeLocked}
 		if a.Lock.Locked {
 			httpErr.Message = fmt.Sprintf("%s", &a.Lock)
 		} else {
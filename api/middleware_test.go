@@ -6,6 +6,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -20,6 +21,8 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -153,6 +156,59 @@ func (s *S) TestErrorHandlingMiddlewareWithHTTPError(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, 403)
 }
 
+func (s *S) TestErrorHandlingMiddlewareWithHTTPErrorJSON(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "application/json")
+	h, log := doHandler()
+	context.AddRequestError(request, &errors.HTTP{
+		Code:    400,
+		Message: "invalid units",
+		Fields:  map[string]string{"units": "must be positive"},
+	})
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Code, check.Equals, 400)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var payload errors.Payload
+	err = json.Unmarshal(recorder.Body.Bytes(), &payload)
+	c.Assert(err, check.IsNil)
+	c.Assert(payload, check.DeepEquals, errors.Payload{
+		Code:    errors.CodeInvalid,
+		Message: "invalid units",
+		Fields:  map[string]string{"units": "must be positive"},
+	})
+}
+
+func (s *S) TestErrorHandlingMiddlewareWithErrorJSON(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "application/json")
+	h, _ := doHandler()
+	context.AddRequestError(request, fmt.Errorf("something"))
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(recorder.Code, check.Equals, 500)
+	c.Assert(recorder.Body.String(), check.Equals, `{"code":"internal","message":"something"}`+"\n")
+}
+
+func (s *S) TestErrorHandlingMiddlewareWithQuotaExceededError(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "application/json")
+	h, _ := doHandler()
+	context.AddRequestError(request, &quota.QuotaExceededError{Available: 0, Requested: 1})
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	var payload errors.Payload
+	err = json.Unmarshal(recorder.Body.Bytes(), &payload)
+	c.Assert(err, check.IsNil)
+	c.Assert(payload.Code, check.Equals, errors.CodeQuotaExceeded)
+	c.Assert(payload.Message, check.Equals, "Quota exceeded. Available: 0. Requested: 1.")
+}
+
 func (s *S) TestAuthTokenMiddlewareWithoutToken(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
@@ -291,6 +347,60 @@ func (s *S) TestAuthTokenMiddlewareUserTokenAppNotFound(c *check.C) {
 	c.Assert(e.Code, check.Equals, http.StatusNotFound)
 }
 
+func (s *S) TestAuthTokenMiddlewareOnBehalfOfTeam(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("X-Tsuru-On-Behalf-Of", s.team.Name)
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	t := context.GetAuthToken(request)
+	c.Assert(t, check.FitsTypeOf, &auth.ImpersonationToken{})
+	c.Assert(t.(*auth.ImpersonationToken).Team, check.Equals, s.team.Name)
+	c.Assert(t.GetValue(), check.Equals, s.token.GetValue())
+	c.Assert(t.GetUserName(), check.Equals, s.token.GetUserName())
+}
+
+func (s *S) TestAuthTokenMiddlewareOnBehalfOfTeamNotFound(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("X-Tsuru-On-Behalf-Of", "unknown-team")
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	err = context.GetRequestError(request)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAuthTokenMiddlewareOnBehalfOfTeamForbidden(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeam,
+		Context: permission.Context(permission.CtxTeam, "other-team"),
+	})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("X-Tsuru-On-Behalf-Of", s.team.Name)
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	t := context.GetAuthToken(request)
+	c.Assert(t, check.IsNil)
+	err = context.GetRequestError(request)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestRunDelayedHandlerWithoutHandler(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
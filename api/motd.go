@@ -0,0 +1,162 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/motd"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// title: message of the day list
+// path: /motd
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func motdList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var (
+		msgs []motd.Message
+		err  error
+	)
+	if r.URL.Query().Get("all") == "true" {
+		if !permission.Check(t, permission.PermMotdRead) {
+			return permission.ErrUnauthorized
+		}
+		msgs, err = motd.List()
+	} else {
+		var teams []string
+		teams, err = tokenTeams(t)
+		if err != nil {
+			return err
+		}
+		msgs, err = motd.ListActive(teams)
+	}
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(msgs)
+}
+
+// tokenTeams returns the names of the teams the token has any permission on,
+// tokens with permissions in the global context are members of every team.
+func tokenTeams(t auth.Token) ([]string, error) {
+	perms, err := t.Permissions()
+	if err != nil {
+		return nil, err
+	}
+	var teams []string
+	for _, p := range perms {
+		switch p.Context.CtxType {
+		case permission.CtxGlobal:
+			allTeams, err := auth.ListTeams()
+			if err != nil {
+				return nil, err
+			}
+			return auth.GetTeamsNames(allTeams), nil
+		case permission.CtxTeam:
+			teams = append(teams, p.Context.Value)
+		}
+	}
+	return teams, nil
+}
+
+// title: message of the day create
+// path: /motd
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Message created
+//   400: Invalid data
+//   401: Unauthorized
+func motdAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermMotdCreate) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	msg := motd.Message{
+		ID:       bson.NewObjectId(),
+		Text:     r.FormValue("message"),
+		Severity: r.FormValue("severity"),
+		Teams:    r.Form["teams"],
+		Owner:    t.GetUserName(),
+	}
+	if expires := r.FormValue("expires"); expires != "" {
+		msg.Expires, err = time.Parse(time.RFC3339, expires)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid expires, it must be in RFC3339 format"}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeMotd, Value: msg.ID.Hex()},
+		Kind:       permission.PermMotdCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermMotdRead),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = motd.Add(&msg)
+	switch err {
+	case nil:
+	case motd.ErrTextRequired, motd.ErrInvalidSeverity, motd.ErrAlreadyExpired:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	default:
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(msg)
+}
+
+// title: message of the day remove
+// path: /motd/{id}
+// method: DELETE
+// responses:
+//   200: Message removed
+//   401: Unauthorized
+//   404: Not found
+func motdRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermMotdDelete) {
+		return permission.ErrUnauthorized
+	}
+	id := r.URL.Query().Get(":id")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeMotd, Value: id},
+		Kind:       permission.PermMotdDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermMotdRead),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = motd.Remove(id)
+	if err == motd.ErrMessageNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
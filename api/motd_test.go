@@ -0,0 +1,171 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/motd"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestMotdAdd(c *check.C) {
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := strings.NewReader("message=maintenance+at+10pm&severity=warning&teams=tsuruteam&expires=" + expires.Format(time.RFC3339))
+	request, err := http.NewRequest("POST", "/motd", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var created motd.Message
+	err = json.Unmarshal(recorder.Body.Bytes(), &created)
+	c.Assert(err, check.IsNil)
+	msgs, err := motd.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].ID, check.Equals, created.ID)
+	c.Assert(msgs[0].Text, check.Equals, "maintenance at 10pm")
+	c.Assert(msgs[0].Severity, check.Equals, motd.SeverityWarning)
+	c.Assert(msgs[0].Teams, check.DeepEquals, []string{"tsuruteam"})
+	c.Assert(msgs[0].Expires.Equal(expires), check.Equals, true)
+	c.Assert(msgs[0].Owner, check.Equals, s.token.GetUserName())
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeMotd, Value: created.ID.Hex()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "motd.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "message", "value": "maintenance at 10pm"},
+			{"name": "severity", "value": "warning"},
+			{"name": "teams", "value": "tsuruteam"},
+			{"name": "expires", "value": expires.Format(time.RFC3339)},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestMotdAddInvalid(c *check.C) {
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{"severity=info", motd.ErrTextRequired.Error()},
+		{"message=hi&severity=panic", motd.ErrInvalidSeverity.Error()},
+		{"message=hi&expires=tomorrow", "invalid expires, it must be in RFC3339 format"},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/motd", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, tt.message+"\n")
+	}
+	msgs, err := motd.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 0)
+}
+
+func (s *S) TestMotdAddForbidden(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("POST", "/motd", strings.NewReader("message=hi"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestMotdList(c *check.C) {
+	for _, msg := range []motd.Message{
+		{Text: "everyone"},
+		{Text: "my team", Teams: []string{s.team.Name}},
+		{Text: "other team", Teams: []string{"otherteam"}},
+	} {
+		err := motd.Add(&msg)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/motd", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var msgs []motd.Message
+	err = json.Unmarshal(recorder.Body.Bytes(), &msgs)
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 2)
+	c.Assert(msgs[0].Text, check.Equals, "my team")
+	c.Assert(msgs[1].Text, check.Equals, "everyone")
+}
+
+func (s *S) TestMotdListAll(c *check.C) {
+	err := motd.Add(&motd.Message{Text: "other team", Teams: []string{"otherteam"}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/motd?all=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var msgs []motd.Message
+	err = json.Unmarshal(recorder.Body.Bytes(), &msgs)
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	token := userWithPermission(c)
+	request, err = http.NewRequest("GET", "/motd?all=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestMotdListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/motd", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestMotdRemove(c *check.C) {
+	msg := motd.Message{Text: "hi"}
+	err := motd.Add(&msg)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/motd/"+msg.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	msgs, err := motd.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeMotd, Value: msg.ID.Hex()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "motd.delete",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -22,12 +22,22 @@ import (
 	"github.com/tsuru/tsuru/healer"
 	"github.com/tsuru/tsuru/iaas"
 	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"gopkg.in/mgo.v2"
 )
 
+func init() {
+	event.SetRedriveHandler(permission.PermNodeUpdateRebalance.FullName(), formRedrive(rebalanceNodesHandler))
+	event.SetChangeRateLimit(event.ChangeRateSpec{
+		KindName: permission.PermNodeDelete.FullName(),
+		Max:      10,
+		Time:     10 * time.Minute,
+	})
+}
+
 func validateNodeAddress(address string) error {
 	if address == "" {
 		return errors.Errorf("address=url parameter is required")
@@ -107,8 +117,17 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 		}
 	}
 	poolName := params.Metadata["pool"]
+	var poolRule *provision.NodePoolRule
 	if poolName == "" {
-		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "pool is required"}
+		poolRule, err = provision.FindNodePoolRule(params.Metadata)
+		if err != nil {
+			return err
+		}
+		if poolRule == nil {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "pool is required"}
+		}
+		poolName = poolRule.Pool
+		params.Metadata["pool"] = poolName
 	}
 	if !permission.Check(t, permission.PermNodeCreate, permission.Context(permission.CtxPool, poolName)) {
 		return permission.ErrUnauthorized
@@ -132,6 +151,9 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 		return err
 	}
 	defer func() { evt.Done(err) }()
+	if poolRule != nil {
+		evt.Logf("node assigned to pool %q by rule %q", poolName, poolRule.Name)
+	}
 	pool, err := provision.GetPoolByName(poolName)
 	if err != nil {
 		return err
@@ -166,6 +188,7 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 //   200: Ok
 //   401: Unauthorized
 //   404: Not found
+//   409: Too many nodes removed recently, confirmation required
 func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
 	address := r.URL.Query().Get(":address")
@@ -199,15 +222,18 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 			return permission.ErrUnauthorized
 		}
 	}
+	confirmChangeRate, _ := strconv.ParseBool(r.URL.Query().Get("confirm-change-rate"))
 	evt, err := event.New(&event.Opts{
-		Target:     event.Target{Type: event.TargetTypeNode, Value: node.Address()},
-		Kind:       permission.PermNodeDelete,
-		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
-		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, pool)),
+		Target:            event.Target{Type: event.TargetTypeNode, Value: node.Address()},
+		ExtraTargets:      nodeAppTargets(node),
+		Kind:              permission.PermNodeDelete,
+		Owner:             t,
+		CustomData:        event.FormToCustomData(r.Form),
+		Allowed:           event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, pool)),
+		ConfirmChangeRate: confirmChangeRate,
 	})
 	if err != nil {
-		return err
+		return changeRateError(err)
 	}
 	defer func() { evt.Done(err) }()
 	noRebalance, _ := strconv.ParseBool(r.URL.Query().Get("no-rebalance"))
@@ -230,6 +256,26 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	return nil
 }
 
+// nodeAppTargets returns targets for the apps with units in the node, which
+// are affected by its removal.
+func nodeAppTargets(node provision.Node) []event.Target {
+	units, err := node.Units()
+	if err != nil {
+		log.Errorf("unable to list units of node %q: %s", node.Address(), err)
+		return nil
+	}
+	var targets []event.Target
+	seen := map[string]bool{}
+	for _, u := range units {
+		if u.AppName == "" || seen[u.AppName] {
+			continue
+		}
+		seen[u.AppName] = true
+		targets = append(targets, event.Target{Type: event.TargetTypeApp, Value: u.AppName})
+	}
+	return targets
+}
+
 type listNodeResponse struct {
 	Nodes    []json.RawMessage `json:"nodes"`
 	Machines []iaas.Machine    `json:"machines"`
@@ -27,6 +27,50 @@ import (
 	"gopkg.in/mgo.v2"
 )
 
+func (s *S) TestAddNodeHandlerPoolFromRule(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	err = provision.AddNodePoolRule(provision.NodePoolRule{Name: "zone-a", Pool: "pool1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	serverAddr := "http://mysrv1"
+	params := provision.AddNodeOptions{
+		Register: true,
+		Metadata: map[string]string{
+			"address": serverAddr,
+			"zone":    "a",
+		},
+	}
+	v, err := form.EncodeToValues(&params)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.2/node", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	nodes, err := s.provisioner.ListNodes(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Metadata(), check.DeepEquals, map[string]string{
+		"pool": "pool1",
+		"zone": "a",
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: serverAddr},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Metadata.address", "value": serverAddr},
+			{"name": "Metadata.zone", "value": "a"},
+			{"name": "Register", "value": "true"},
+		},
+		LogMatches: `node assigned to pool "pool1" by rule "zone-a"`,
+	}, eventtest.HasEvent)
+}
+
 func (s *S) TestValidateNodeAddress(c *check.C) {
 	err := validateNodeAddress("/invalid")
 	c.Assert(err, check.ErrorMatches, "Invalid address url: host cannot be empty")
@@ -23,6 +23,10 @@ import (
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 )
 
+func init() {
+	event.SetRedriveHandler(permission.PermNodecontainerUpdateUpgrade.FullName(), formRedrive(nodeContainerUpgrade))
+}
+
 // title: remove node container list
 // path: /docker/nodecontainers
 // method: GET
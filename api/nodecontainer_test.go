@@ -6,6 +6,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -596,6 +597,47 @@ func (s *S) TestNodeContainerUpgrade(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestNodeContainerUpgradeRedrive(c *check.C) {
+	err := nodecontainer.AddNewContainer("p1", &nodecontainer.NodeContainerConfig{
+		Name:        "c1",
+		PinnedImage: "tsuru/c1@sha256:abcef384829283eff",
+		Config: docker.Config{
+			Image: "img1",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	failedEvt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNodeContainer, Value: "c1"},
+		Kind:       permission.PermNodecontainerUpdateUpgrade,
+		Owner:      s.token,
+		CustomData: event.FormToCustomData(url.Values{":name": {"c1"}, "pool": {"p1"}}),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = failedEvt.Done(errors.New("upgrade failed"))
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/events/"+failedEvt.UniqueID.Hex()+"/redrive", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	all, err := nodecontainer.AllNodeContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.DeepEquals, []nodecontainer.NodeContainerConfigGroup{
+		{Name: "c1", ConfigPools: map[string]nodecontainer.NodeContainerConfig{
+			"p1": {Name: "c1", Config: docker.Config{Image: "img1"}},
+		}},
+	})
+	evts, err := event.List(&event.Filter{KindName: "nodecontainer.update.upgrade"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	c.Assert(evts[0].Error, check.Equals, "")
+	c.Assert(evts[0].Owner.Name, check.Equals, s.token.GetUserName())
+}
+
 func (s *S) TestNodeContainerUpgradeLimited(c *check.C) {
 	err := nodecontainer.AddNewContainer("p1", &nodecontainer.NodeContainerConfig{
 		Name:        "c1",
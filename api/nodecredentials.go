@@ -0,0 +1,196 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/nodecredentials"
+)
+
+func poolContexts(pools []string) []permission.PermissionContext {
+	contexts := make([]permission.PermissionContext, len(pools))
+	for i, pool := range pools {
+		contexts[i] = permission.Context(permission.CtxPool, pool)
+	}
+	return contexts
+}
+
+// checkRotationPools returns whether t has the permission in all pools
+// touched by a credentials rotation.
+func checkRotationPools(t auth.Token, scheme *permission.PermissionScheme, pools []string) bool {
+	for _, ctx := range poolContexts(pools) {
+		if !permission.Check(t, scheme, ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// title: rotate node credentials
+// path: /node/credentials/rotations
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Node not found
+//   409: Node already in an unfinished rotation
+func nodeCredentialsRotate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	nodes, err := nodecredentials.FindNodes(r.FormValue("pool"), r.Form["node"])
+	if err != nil {
+		if errors.Cause(err) == provision.ErrNodeNotFound {
+			return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		if _, ok := err.(provision.ProvisionerNotSupported); ok {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	pools := map[string]bool{}
+	for _, n := range nodes {
+		if pools[n.Pool()] {
+			continue
+		}
+		pools[n.Pool()] = true
+		if !permission.Check(t, permission.PermNodeUpdateCredentials, permission.Context(permission.CtxPool, n.Pool())) {
+			return permission.ErrUnauthorized
+		}
+	}
+	rotation, err := nodecredentials.Create(nodes)
+	if err == nodecredentials.ErrNoNodes {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if _, ok := err.(*nodecredentials.ErrNodeInRotation); ok {
+		return &tsuruErrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(rotationEventOpts(r, t, rotation))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return runCredentialsRotation(w, evt, rotation)
+}
+
+// title: resume node credentials rotation
+// path: /node/credentials/rotations/{id}/resume
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Rotation already finished
+//   401: Unauthorized
+//   404: Rotation not found
+func nodeCredentialsResume(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	rotation, err := nodecredentials.Get(r.URL.Query().Get(":id"))
+	if err == nodecredentials.ErrRotationNotFound {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if !checkRotationPools(t, permission.PermNodeUpdateCredentials, rotation.Pools) {
+		return permission.ErrUnauthorized
+	}
+	if rotation.Status == nodecredentials.StatusDone {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: nodecredentials.ErrRotationFinished.Error()}
+	}
+	evt, err := event.New(rotationEventOpts(r, t, rotation))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return runCredentialsRotation(w, evt, rotation)
+}
+
+func rotationEventOpts(r *http.Request, t auth.Token, rotation *nodecredentials.Rotation) *event.Opts {
+	contexts := poolContexts(rotation.Pools)
+	return &event.Opts{
+		Target:        event.Target{Type: event.TargetTypeCredentialRotation, Value: rotation.ID},
+		ExtraTargets:  rotation.Targets(),
+		Kind:          permission.PermNodeUpdateCredentials,
+		Owner:         t,
+		CustomData:    event.FormToCustomData(r.Form),
+		Allowed:       event.Allowed(permission.PermPoolReadEvents, contexts...),
+		AllowedCancel: event.Allowed(permission.PermNodeUpdateCredentials, contexts...),
+		Cancelable:    true,
+	}
+}
+
+func runCredentialsRotation(w http.ResponseWriter, evt *event.Event, rotation *nodecredentials.Rotation) error {
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	evt.Logf("Credentials rotation %q, resume it with the id if it's interrupted.", rotation.ID)
+	return rotation.Run(evt)
+}
+
+// title: list node credentials rotations
+// path: /node/credentials/rotations
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   204: No content
+//   401: Unauthorized
+func nodeCredentialsRotationList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	rotations, err := nodecredentials.List()
+	if err != nil {
+		return err
+	}
+	var allowed []nodecredentials.Rotation
+	for _, rotation := range rotations {
+		if checkRotationPools(t, permission.PermNodeRead, rotation.Pools) {
+			allowed = append(allowed, rotation)
+		}
+	}
+	if len(allowed) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(allowed)
+}
+
+// title: node credentials rotation info
+// path: /node/credentials/rotations/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Rotation not found
+func nodeCredentialsRotationInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	rotation, err := nodecredentials.Get(r.URL.Query().Get(":id"))
+	if err == nodecredentials.ErrRotationNotFound {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if !checkRotationPools(t, permission.PermNodeRead, rotation.Pools) {
+		return permission.ErrUnauthorized
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(rotation)
+}
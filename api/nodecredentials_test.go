@@ -0,0 +1,185 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/iaas"
+	iaasTesting "github.com/tsuru/tsuru/iaas/testing"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/nodecredentials"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addCredentialsNode(c *check.C, addr, pool string) {
+	factory, _ := iaasTesting.NewCredentialsIaaSConstructorWithInst(addr)
+	iaas.RegisterIaasProvider("creds-iaas-"+addr, factory)
+	m, err := iaas.CreateMachineForIaaS("creds-iaas-"+addr, map[string]string{})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://" + addr + ":2375",
+		Metadata: map[string]string{"pool": pool, "iaas-id": m.Id},
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestNodeCredentialsRotate(c *check.C) {
+	s.addCredentialsNode(c, "addr1", "pool1")
+	s.addCredentialsNode(c, "addr2", "pool2")
+	body := strings.NewReader("pool=pool1")
+	request, err := http.NewRequest("POST", "/1.3/node/credentials/rotations", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Rotating credentials of node \\"http://addr1:2375\\" \(1/1\).*Credentials of 1 nodes successfully rotated.*`)
+	rotations, err := nodecredentials.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(rotations, check.HasLen, 1)
+	c.Assert(rotations[0].Status, check.Equals, nodecredentials.StatusDone)
+	c.Assert(rotations[0].Pools, check.DeepEquals, []string{"pool1"})
+	creds, err := s.provisioner.NodeCredentials("http://addr1:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(creds.ClientCert), check.Equals, "cert-m-addr1-1")
+	creds, err = s.provisioner.NodeCredentials("http://addr2:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.ClientCert, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeCredentialRotation, Value: rotations[0].ID},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update.credentials",
+		StartCustomData: []map[string]interface{}{
+			{"name": "pool", "value": "pool1"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestNodeCredentialsRotateNodeNotFound(c *check.C) {
+	body := strings.NewReader("node=http://addr9:2375")
+	request, err := http.NewRequest("POST", "/1.3/node/credentials/rotations", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestNodeCredentialsRotateNoPermission(c *check.C) {
+	s.addCredentialsNode(c, "addr1", "pool1")
+	s.addCredentialsNode(c, "addr2", "pool2")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermNodeUpdateCredentials,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	request, err := http.NewRequest("POST", "/1.3/node/credentials/rotations", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	rotations, err := nodecredentials.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(rotations, check.HasLen, 0)
+}
+
+func (s *S) TestNodeCredentialsRotateConflict(c *check.C) {
+	s.addCredentialsNode(c, "addr1", "pool1")
+	nodes, err := nodecredentials.FindNodes("pool1", nil)
+	c.Assert(err, check.IsNil)
+	rotation, err := nodecredentials.Create(nodes)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.3/node/credentials/rotations", strings.NewReader("pool=pool1"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, `node "http://addr1:2375" is already part of the unfinished credentials rotation "`+rotation.ID+"\"\n")
+}
+
+func (s *S) TestNodeCredentialsResume(c *check.C) {
+	s.addCredentialsNode(c, "addr1", "pool1")
+	nodes, err := nodecredentials.FindNodes("pool1", nil)
+	c.Assert(err, check.IsNil)
+	rotation, err := nodecredentials.Create(nodes)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.3/node/credentials/rotations/"+rotation.ID+"/resume", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	rotation, err = nodecredentials.Get(rotation.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(rotation.Status, check.Equals, nodecredentials.StatusDone)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "credentials rotation already finished\n")
+	request, err = http.NewRequest("POST", "/1.3/node/credentials/rotations/unknown/resume", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestNodeCredentialsRotationListAndInfo(c *check.C) {
+	s.addCredentialsNode(c, "addr1", "pool1")
+	s.addCredentialsNode(c, "addr2", "pool2")
+	nodes, err := nodecredentials.FindNodes("pool1", nil)
+	c.Assert(err, check.IsNil)
+	rotation1, err := nodecredentials.Create(nodes)
+	c.Assert(err, check.IsNil)
+	nodes, err = nodecredentials.FindNodes("pool2", nil)
+	c.Assert(err, check.IsNil)
+	_, err = nodecredentials.Create(nodes)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermNodeRead,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	request, err := http.NewRequest("GET", "/1.3/node/credentials/rotations", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var rotations []nodecredentials.Rotation
+	err = json.NewDecoder(recorder.Body).Decode(&rotations)
+	c.Assert(err, check.IsNil)
+	c.Assert(rotations, check.HasLen, 1)
+	c.Assert(rotations[0].ID, check.Equals, rotation1.ID)
+	request, err = http.NewRequest("GET", "/1.3/node/credentials/rotations/"+rotation1.ID, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var rotation nodecredentials.Rotation
+	err = json.NewDecoder(recorder.Body).Decode(&rotation)
+	c.Assert(err, check.IsNil)
+	c.Assert(rotation.Nodes, check.DeepEquals, []nodecredentials.NodeRotation{
+		{Address: "http://addr1:2375", Status: nodecredentials.NodeStatusPending},
+	})
+}
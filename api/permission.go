@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
@@ -69,6 +70,61 @@ func addRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	return err
 }
 
+// title: role clone
+// path: /roles/{name}/clone
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Role created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Role not found
+//   409: Role already exists
+func cloneRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	if !permission.Check(t, permission.PermRoleCreate) {
+		return permission.ErrUnauthorized
+	}
+	newName := r.FormValue("name")
+	if newName == "" {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: permission.ErrInvalidRoleName.Error(),
+		}
+	}
+	role, err := permission.FindRole(r.URL.Query().Get(":name"))
+	if err == permission.ErrRoleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeRole, Value: newName},
+		Kind:       permission.PermRoleCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermRoleReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	clone, err := role.Clone(newName, r.FormValue("description"))
+	if err == permission.ErrInvalidRoleName {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err == permission.ErrRoleAlreadyExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(clone)
+}
+
 // title: remove role
 // path: /roles/{name}
 // method: DELETE
@@ -97,6 +153,10 @@ func removeRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err != nil {
 		return err
 	}
+	err = auth.RemoveRoleFromAllGroups(roleName)
+	if err != nil {
+		return err
+	}
 	err = permission.DestroyRole(roleName)
 	if err == permission.ErrRoleNotFound {
 		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
@@ -169,8 +229,23 @@ func roleInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 }
 
 func deployableApps(u *auth.User, rolesCache map[string]*permission.Role) ([]string, error) {
+	if u.Suspended {
+		return nil, nil
+	}
 	var perms []permission.Permission
-	for _, roleData := range u.Roles {
+	groups, err := auth.ListGroupsByMember(u.Email)
+	if err != nil {
+		return nil, err
+	}
+	roleInstances := append([]auth.RoleInstance{}, u.Roles...)
+	for _, g := range groups {
+		roleInstances = append(roleInstances, g.Roles...)
+	}
+	now := time.Now()
+	for _, roleData := range roleInstances {
+		if roleData.Expired(now) {
+			continue
+		}
 		role := rolesCache[roleData.Name]
 		if role == nil {
 			foundRole, err := permission.FindRole(roleData.Name)
@@ -182,6 +257,10 @@ func deployableApps(u *auth.User, rolesCache map[string]*permission.Role) ([]str
 		}
 		perms = append(perms, role.PermissionsFor(roleData.ContextValue)...)
 	}
+	perms, err = auth.InheritTeamPermissions(perms)
+	if err != nil {
+		return nil, err
+	}
 	contexts := permission.ContextsFromListForPermission(perms, permission.PermAppDeploy)
 	if len(contexts) == 0 {
 		return nil, nil
@@ -281,11 +360,13 @@ func addPermissions(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
+	var before, after []string
+	defer func() { evt.DoneCustomData(err, permissionChangeData(before, after)) }()
 	role, err := permission.FindRole(roleName)
 	if err != nil {
 		return err
 	}
+	before = role.SchemeNames
 	err = r.ParseForm()
 	if err != nil {
 		return err
@@ -297,6 +378,9 @@ func addPermissions(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	err = runWithPermSync(users, func() error {
 		return role.AddPermissions(r.Form["permission"]...)
 	})
+	if err == nil {
+		after = role.SchemeNames
+	}
 	if err == permission.ErrInvalidPermissionName {
 		return &errors.HTTP{
 			Code:    http.StatusBadRequest,
@@ -341,7 +425,8 @@ func removePermissions(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
+	var before, after []string
+	defer func() { evt.DoneCustomData(err, permissionChangeData(before, after)) }()
 	permName := r.URL.Query().Get(":permission")
 	role, err := permission.FindRole(roleName)
 	if err != nil {
@@ -357,10 +442,36 @@ func removePermissions(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	if err != nil {
 		return err
 	}
+	before = role.SchemeNames
 	err = runWithPermSync(users, func() error {
 		return role.RemovePermissions(permName)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	after = role.SchemeNames
+	return nil
+}
+
+// permissionChangeData is the end custom data of the events changing
+// permissions, holding the permissions before and after the change so
+// security reviews can tell who had what and when.
+func permissionChangeData(before, after []string) map[string]interface{} {
+	return map[string]interface{}{"before": before, "after": after}
+}
+
+// userPermissionNames returns the permissions of the user, as recorded in
+// the events changing them.
+func userPermissionNames(u *auth.User) ([]string, error) {
+	perms, err := u.Permissions()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(perms))
+	for i := range perms {
+		names[i] = perms[i].String()
+	}
+	return names, nil
 }
 
 func canUseRole(t auth.Token, roleName, contextValue string) error {
@@ -405,19 +516,32 @@ func assignRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 		return permission.ErrUnauthorized
 	}
 	roleName := r.URL.Query().Get(":name")
+	email := r.FormValue("email")
 	evt, err := event.New(&event.Opts{
-		Target:     event.Target{Type: event.TargetTypeRole, Value: roleName},
-		Kind:       permission.PermRoleUpdateAssign,
-		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
-		Allowed:    event.Allowed(permission.PermRoleReadEvents),
+		Target:       event.Target{Type: event.TargetTypeRole, Value: roleName},
+		ExtraTargets: []event.Target{userTarget(email)},
+		Kind:         permission.PermRoleUpdateAssign,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermRoleReadEvents),
 	})
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
-	email := r.FormValue("email")
+	var before, after []string
+	defer func() { evt.DoneCustomData(err, permissionChangeData(before, after)) }()
 	contextValue := r.FormValue("context")
+	var expires time.Duration
+	if v := r.FormValue("expires"); v != "" {
+		expires, err = time.ParseDuration(v)
+		if err != nil || expires <= 0 {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "expires must be a positive duration, like 24h",
+				Fields:  map[string]string{"expires": "must be a positive duration"},
+			}
+		}
+	}
 	user, err := auth.GetUserByEmail(email)
 	if err != nil {
 		return err
@@ -426,9 +550,20 @@ func assignRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err != nil {
 		return err
 	}
+	before, err = userPermissionNames(user)
+	if err != nil {
+		return err
+	}
 	err = runWithPermSync([]auth.User{*user}, func() error {
+		if expires > 0 {
+			return user.AddRoleWithExpiration(roleName, contextValue, time.Now().Add(expires))
+		}
 		return user.AddRole(roleName, contextValue)
 	})
+	if err != nil {
+		return err
+	}
+	after, err = userPermissionNames(user)
 	return err
 }
 
@@ -446,18 +581,20 @@ func dissociateRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 		return permission.ErrUnauthorized
 	}
 	roleName := r.URL.Query().Get(":name")
+	email := r.URL.Query().Get(":email")
 	evt, err := event.New(&event.Opts{
-		Target:     event.Target{Type: event.TargetTypeRole, Value: roleName},
-		Kind:       permission.PermRoleUpdateDissociate,
-		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
-		Allowed:    event.Allowed(permission.PermRoleReadEvents),
+		Target:       event.Target{Type: event.TargetTypeRole, Value: roleName},
+		ExtraTargets: []event.Target{userTarget(email)},
+		Kind:         permission.PermRoleUpdateDissociate,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermRoleReadEvents),
 	})
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
-	email := r.URL.Query().Get(":email")
+	var before, after []string
+	defer func() { evt.DoneCustomData(err, permissionChangeData(before, after)) }()
 	contextValue := r.URL.Query().Get("context")
 	user, err := auth.GetUserByEmail(email)
 	if err != nil {
@@ -467,9 +604,17 @@ func dissociateRole(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err != nil {
 		return err
 	}
+	before, err = userPermissionNames(user)
+	if err != nil {
+		return err
+	}
 	err = runWithPermSync([]auth.User{*user}, func() error {
 		return user.RemoveRole(roleName, contextValue)
 	})
+	if err != nil {
+		return err
+	}
+	after, err = userPermissionNames(user)
 	return err
 }
 
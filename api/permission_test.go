@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sort"
+	"time"
 
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
@@ -53,6 +54,90 @@ func (s *S) TestAddRole(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestCloneRole(c *check.C) {
+	role, err := permission.NewRole("deployer", "team", "deploys apps")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy", "app.read")
+	c.Assert(err, check.IsNil)
+	body := bytes.NewBufferString("name=deployer2")
+	req, err := http.NewRequest("POST", "/1.3/roles/deployer/clone", body)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermRoleCreate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result permission.Role
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Name, check.Equals, "deployer2")
+	clone, err := permission.FindRole("deployer2")
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.ContextType, check.Equals, permission.CtxTeam)
+	c.Assert(clone.Description, check.Equals, "deploys apps")
+	c.Assert(clone.SchemeNames, check.DeepEquals, []string{"app.deploy", "app.read"})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeRole, Value: "deployer2"},
+		Owner:  token.GetUserName(),
+		Kind:   "role.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "deployer"},
+			{"name": "name", "value": "deployer2"},
+		},
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/1.3/roles/deployer/clone", bytes.NewBufferString("name=deployer2"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	server.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestCloneRoleNotFound(c *check.C) {
+	req, err := http.NewRequest("POST", "/1.3/roles/unknown/clone", bytes.NewBufferString("name=deployer2"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestCloneRoleWithoutName(c *check.C) {
+	_, err := permission.NewRole("deployer", "team", "")
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.3/roles/deployer/clone", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, permission.ErrInvalidRoleName.Error()+"\n")
+}
+
+func (s *S) TestCloneRoleUnauthorized(c *check.C) {
+	_, err := permission.NewRole("deployer", "team", "")
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.3/roles/deployer/clone", bytes.NewBufferString("name=deployer2"))
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestAddRoleUnauthorized(c *check.C) {
 	role := bytes.NewBufferString("name=test&context=global")
 	req, err := http.NewRequest("POST", "/roles", role)
@@ -232,6 +317,9 @@ func (s *S) TestAddPermissionsToARole(c *check.C) {
 		StartCustomData: []map[string]interface{}{
 			{"name": "permission", "value": []string{"app.update", "app.deploy"}},
 		},
+		EndCustomData: map[string]interface{}{
+			"after": []string{"app.update", "app.deploy"},
+		},
 	}, eventtest.HasEvent)
 }
 
@@ -367,6 +455,10 @@ func (s *S) TestRemovePermissionsFromRole(c *check.C) {
 			{"name": ":name", "value": "test"},
 			{"name": ":permission", "value": "app.update"},
 		},
+		EndCustomData: map[string]interface{}{
+			"before": []string{"app.update"},
+			"after":  []string{},
+		},
 	}, eventtest.HasEvent)
 }
 
@@ -406,6 +498,27 @@ func (s *S) TestRemovePermissionsFromRoleSyncGitRepository(c *check.C) {
 	c.Assert(users, check.DeepEquals, []string{s.user.Email})
 }
 
+func (s *S) TestDeployableAppsIgnoresExpiredRoles(c *check.C) {
+	r, err := permission.NewRole("test", "team", "")
+	c.Assert(err, check.IsNil)
+	defer permission.DestroyRole(r.Name)
+	err = r.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	user := &auth.User{Email: "userWithRole@groundcontrol.com", Roles: []auth.RoleInstance{
+		{Name: "test", ContextValue: s.team.Name, ExpiresAt: time.Now().Add(-time.Minute)},
+	}}
+	apps, err := deployableApps(user, map[string]*permission.Role{})
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 0)
+	user.Roles[0].ExpiresAt = time.Now().Add(time.Hour)
+	apps, err = deployableApps(user, map[string]*permission.Role{})
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.DeepEquals, []string{a.Name})
+}
+
 func (s *S) TestAssignRole(c *check.C) {
 	role, err := permission.NewRole("test", "team", "")
 	c.Assert(err, check.IsNil)
@@ -440,7 +553,72 @@ func (s *S) TestAssignRole(c *check.C) {
 			{"name": "email", "value": emptyToken.GetUserName()},
 			{"name": "context", "value": "myteam"},
 		},
+		EndCustomData: map[string]interface{}{
+			"before": []string{"user(user " + emptyUser.Email + ")"},
+			"after":  []string{"user(user " + emptyUser.Email + ")", "app.create(team myteam)"},
+		},
 	}, eventtest.HasEvent)
+	evts, err := event.List(&event.Filter{Target: userTarget(emptyUser.Email)})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Kind.Name, check.Equals, "role.update.assign")
+}
+
+func (s *S) TestAssignRoleWithExpiration(c *check.C) {
+	role, err := permission.NewRole("test", "team", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.create")
+	c.Assert(err, check.IsNil)
+	_, emptyToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "user2")
+	roleBody := bytes.NewBufferString(fmt.Sprintf("email=%s&context=myteam&expires=24h", emptyToken.GetUserName()))
+	req, err := http.NewRequest("POST", "/roles/test/user", roleBody)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "user1", permission.Permission{
+		Scheme:  permission.PermRoleUpdateAssign,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	}, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, "myteam"),
+	})
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	before := time.Now()
+	server.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	emptyUser, err := emptyToken.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(emptyUser.Roles, check.HasLen, 1)
+	c.Assert(emptyUser.Roles[0].Name, check.Equals, "test")
+	expiresAt := emptyUser.Roles[0].ExpiresAt
+	c.Assert(expiresAt.After(before.Add(24*time.Hour-time.Second)), check.Equals, true)
+	c.Assert(expiresAt.Before(time.Now().Add(24*time.Hour+time.Second)), check.Equals, true)
+}
+
+func (s *S) TestAssignRoleInvalidExpiration(c *check.C) {
+	_, err := permission.NewRole("test", "team", "")
+	c.Assert(err, check.IsNil)
+	_, emptyToken := permissiontest.CustomUserWithPermission(c, nativeScheme, "user2")
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "user1", permission.Permission{
+		Scheme:  permission.PermRoleUpdateAssign,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	for _, expires := range []string{"tomorrow", "-1h", "0s"} {
+		roleBody := bytes.NewBufferString(fmt.Sprintf("email=%s&context=myteam&expires=%s", emptyToken.GetUserName(), expires))
+		req, err := http.NewRequest("POST", "/roles/test/user", roleBody)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, req)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest, check.Commentf("expires=%s", expires))
+		c.Assert(recorder.Body.String(), check.Equals, "expires must be a positive duration, like 24h\n")
+	}
+	emptyUser, err := emptyToken.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(emptyUser.Roles, check.HasLen, 0)
 }
 
 func (s *S) TestAssignRoleNotFound(c *check.C) {
@@ -586,7 +764,15 @@ func (s *S) TestDissociateRole(c *check.C) {
 			{"name": ":email", "value": otherToken.GetUserName()},
 			{"name": "context", "value": "myteam"},
 		},
+		EndCustomData: map[string]interface{}{
+			"before": []string{"user(user " + otherUser.Email + ")", "app.create(team myteam)"},
+			"after":  []string{"user(user " + otherUser.Email + ")"},
+		},
 	}, eventtest.HasEvent)
+	evts, err := event.List(&event.Filter{Target: userTarget(otherUser.Email)})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Kind.Name, check.Equals, "role.update.dissociate")
 }
 
 func (s *S) TestDissociateRoleNotAuthorized(c *check.C) {
@@ -10,6 +10,7 @@ import (
 	"strconv"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	terrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
@@ -24,9 +25,14 @@ import (
 // responses:
 //   200: OK
 //   204: No content
+//   400: Invalid data
 //   401: Unauthorized
 //   404: User not found
 func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	selector, err := provision.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
 	teams := []string{}
 	contexts := permission.ContextsForPermission(t, permission.PermAppCreate)
 	for _, c := range contexts {
@@ -43,6 +49,15 @@ func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
+	if selector != nil {
+		var selected []provision.Pool
+		for _, p := range pools {
+			if selector.Matches(p.Labels) {
+				selected = append(selected, p)
+			}
+		}
+		pools = selected
+	}
 	if len(pools) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
@@ -73,6 +88,9 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err == nil {
 		err = dec.DecodeValues(&addOpts, r.Form)
 	}
+	if err == nil {
+		addOpts.Labels, err = provision.ParseLabels(r.Form["label"])
+	}
 	if err != nil {
 		return &terrors.HTTP{
 			Code:    http.StatusBadRequest,
@@ -233,6 +251,7 @@ func removeTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Toke
 // consume: application/x-www-form-urlencoded
 // responses:
 //   200: Pool updated
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Pool not found
 //   409: Default pool already defined
@@ -259,6 +278,9 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	dec.IgnoreUnknownKeys(true)
 	var updateOpts provision.UpdatePoolOptions
 	err = dec.DecodeValues(&updateOpts, r.Form)
+	if err == nil {
+		updateOpts.Labels, err = provision.ParseLabels(r.Form["label"])
+	}
 	if err != nil {
 		return &terrors.HTTP{
 			Code:    http.StatusBadRequest,
@@ -278,6 +300,95 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	return err
 }
 
+// title: pool defaults
+// path: /pools/{name}/defaults
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Pool not found
+func poolDefaultsGet(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermPoolReadDefaults, permission.Context(permission.CtxPool, poolName)) {
+		return permission.ErrUnauthorized
+	}
+	defaults, err := app.EffectiveDefaults(poolName)
+	if err == provision.ErrPoolNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(defaults)
+}
+
+// title: set pool defaults
+// path: /pools/{name}/defaults
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func poolDefaultsSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	poolName := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermPoolUpdateDefaultsSet, permission.Context(permission.CtxPool, poolName)) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateDefaultsSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	defaults := provision.PoolDefaults{
+		Plan:      r.FormValue("plan"),
+		TeamOwner: r.FormValue("teamowner"),
+		Router:    r.FormValue("router"),
+		Tags:      r.Form["tag"],
+	}
+	err = app.SetPoolDefaults(poolName, defaults)
+	if err == provision.ErrPoolNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if _, ok := err.(*provision.ErrInvalidPoolDefault); ok || err == app.ErrPlanNotFound {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// poolDefaultTeamOwner returns the default team owner of the given pool, or
+// of the default pool when no pool is given, as long as the user is allowed
+// to create apps for it.
+func poolDefaultTeamOwner(t auth.Token, poolName string) string {
+	var pool *provision.Pool
+	var err error
+	if poolName == "" {
+		pool, err = provision.GetDefaultPool()
+	} else {
+		pool, err = provision.GetPoolByName(poolName)
+	}
+	if err != nil || pool.Defaults.TeamOwner == "" {
+		return ""
+	}
+	canCreate := permission.Check(t, permission.PermAppCreate,
+		permission.Context(permission.CtxTeam, pool.Defaults.TeamOwner),
+	)
+	if !canCreate {
+		return ""
+	}
+	return pool.Defaults.TeamOwner
+}
+
 // title: pool constraints list
 // path: /constraints
 // method: GET
@@ -353,3 +464,176 @@ func poolConstraintSet(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	}
 	return provision.SetPoolConstraint(&poolConstraint)
 }
+
+func nodePoolRuleError(err error) error {
+	switch err {
+	case provision.ErrNodePoolRuleNameRequired,
+		provision.ErrNodePoolRulePoolRequired,
+		provision.ErrNodePoolRuleMetadataRequired,
+		provision.ErrNodePoolRuleInvalidMetadata,
+		provision.ErrPoolNotFound:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case provision.ErrNodePoolRuleNotFound:
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case provision.ErrNodePoolRuleAlreadyExists:
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if _, ok := err.(*provision.NodePoolRuleConflictError); ok {
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+func decodeNodePoolRule(r *http.Request) (provision.NodePoolRule, error) {
+	var rule provision.NodePoolRule
+	dec := form.NewDecoder(nil)
+	dec.IgnoreCase(true)
+	dec.IgnoreUnknownKeys(true)
+	err := r.ParseForm()
+	if err == nil {
+		err = dec.DecodeValues(&rule, r.Form)
+	}
+	if err != nil {
+		return rule, &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return rule, nil
+}
+
+// title: node pool rule list
+// path: /node/poolrules
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func nodePoolRuleList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermPoolReadNodeRules) {
+		return permission.ErrUnauthorized
+	}
+	rules, err := provision.ListNodePoolRules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(rules)
+}
+
+// title: node pool rule create
+// path: /node/poolrules
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Rule created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Rule already exists or conflicts with another rule
+func nodePoolRuleCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	rule, err := decodeNodePoolRule(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermPoolUpdateNodeRulesSet, permission.Context(permission.CtxPool, rule.Pool)) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNodePoolRule, Value: rule.Name},
+		Kind:       permission.PermPoolUpdateNodeRulesSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, rule.Pool)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.AddNodePoolRule(rule)
+	if err != nil {
+		return nodePoolRuleError(err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: node pool rule update
+// path: /node/poolrules/{name}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Rule updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Rule not found
+//   409: Rule conflicts with another rule
+func nodePoolRuleUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	rule, err := decodeNodePoolRule(r)
+	if err != nil {
+		return err
+	}
+	rule.Name = r.URL.Query().Get(":name")
+	current, err := provision.GetNodePoolRule(rule.Name)
+	if err != nil {
+		return nodePoolRuleError(err)
+	}
+	if rule.Pool == "" {
+		rule.Pool = current.Pool
+	}
+	if len(rule.Metadata) == 0 {
+		rule.Metadata = current.Metadata
+	}
+	canUpdate := permission.Check(t, permission.PermPoolUpdateNodeRulesSet, permission.Context(permission.CtxPool, current.Pool)) &&
+		permission.Check(t, permission.PermPoolUpdateNodeRulesSet, permission.Context(permission.CtxPool, rule.Pool))
+	if !canUpdate {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNodePoolRule, Value: rule.Name},
+		Kind:       permission.PermPoolUpdateNodeRulesSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermPoolReadEvents,
+			permission.Context(permission.CtxPool, current.Pool),
+			permission.Context(permission.CtxPool, rule.Pool),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return nodePoolRuleError(provision.UpdateNodePoolRule(rule))
+}
+
+// title: node pool rule remove
+// path: /node/poolrules/{name}
+// method: DELETE
+// responses:
+//   200: Rule removed
+//   401: Unauthorized
+//   404: Rule not found
+func nodePoolRuleRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	rule, err := provision.GetNodePoolRule(name)
+	if err != nil {
+		return nodePoolRuleError(err)
+	}
+	if !permission.Check(t, permission.PermPoolUpdateNodeRulesRemove, permission.Context(permission.CtxPool, rule.Pool)) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNodePoolRule, Value: name},
+		Kind:       permission.PermPoolUpdateNodeRulesRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, rule.Pool)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return nodePoolRuleError(provision.RemoveNodePoolRule(name))
+}
@@ -9,9 +9,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
@@ -326,6 +328,34 @@ func (s *S) TestPoolListHandler(c *check.C) {
 	c.Assert(pools, check.DeepEquals, expected)
 }
 
+func (s *S) TestPoolListHandlerFilteringByLabels(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1", Public: true, Labels: map[string]string{"cost-center": "1234"}})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool2")
+	req, err := http.NewRequest("GET", "/pools?label=cost-center=1234", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "b "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var pools []provision.Pool
+	err = json.NewDecoder(rec.Body).Decode(&pools)
+	c.Assert(err, check.IsNil)
+	c.Assert(pools, check.HasLen, 1)
+	c.Assert(pools[0].Name, check.Equals, "pool1")
+	c.Assert(pools[0].Labels, check.DeepEquals, map[string]string{"cost-center": "1234"})
+	req, err = http.NewRequest("GET", "/pools?label=cost-center=4321", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "b "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+}
+
 func (s *S) TestPoolListEmptyHandler(c *check.C) {
 	_, err := s.conn.Pools().RemoveAll(nil)
 	c.Assert(err, check.IsNil)
@@ -514,6 +544,33 @@ func (s *S) TestPoolUpdateProvisioner(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestPoolUpdateLabels(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "pool1", Labels: map[string]string{"cost-center": "1234", "product": "store"}}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	b := bytes.NewBufferString("label=product=&label=env=prod")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Labels, check.DeepEquals, map[string]string{"cost-center": "1234", "env": "prod"})
+	b = bytes.NewBufferString("label=env")
+	req, err = http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *S) TestPoolUpdateNotFound(c *check.C) {
 	b := bytes.NewBufferString("public=true")
 	request, err := http.NewRequest("PUT", "/pools/not-found", b)
@@ -650,3 +707,261 @@ func (s *S) TestPoolConstraintSetRequiresPoolExpr(c *check.C) {
 	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
 	c.Assert(rec.Body.String(), check.Equals, "You must provide a Pool Expression\n")
 }
+
+func (s *S) TestNodePoolRuleCreate(c *check.C) {
+	v := url.Values{"name": {"zone-a"}, "pool": {"test1"}, "metadata.zone": {"a"}}
+	req, err := http.NewRequest("POST", "/1.3/node/poolrules", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	rules, err := provision.ListNodePoolRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []provision.NodePoolRule{
+		{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "a"}},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNodePoolRule, Value: "zone-a"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.node-rules.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "zone-a"},
+			{"name": "pool", "value": "test1"},
+			{"name": "metadata.zone", "value": "a"},
+			{"name": ":version", "value": "1.3"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestNodePoolRuleCreateConflict(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddNodePoolRule(provision.NodePoolRule{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	v := url.Values{"name": {"large"}, "pool": {"pool2"}, "metadata.type": {"large"}}
+	req, err := http.NewRequest("POST", "/1.3/node/poolrules", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	c.Assert(rec.Body.String(), check.Matches, `node pool rule "large" conflicts with rule "zone-a".*\n`)
+}
+
+func (s *S) TestNodePoolRuleList(c *check.C) {
+	err := provision.AddNodePoolRule(provision.NodePoolRule{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/1.3/node/poolrules", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var rules []provision.NodePoolRule
+	err = json.NewDecoder(rec.Body).Decode(&rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []provision.NodePoolRule{
+		{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "a"}},
+	})
+}
+
+func (s *S) TestNodePoolRuleListEmpty(c *check.C) {
+	req, err := http.NewRequest("GET", "/1.3/node/poolrules", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestNodePoolRuleUpdate(c *check.C) {
+	err := provision.AddNodePoolRule(provision.NodePoolRule{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	v := url.Values{"metadata.zone": {"b"}}
+	req, err := http.NewRequest("PUT", "/1.3/node/poolrules/zone-a", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	rule, err := provision.GetNodePoolRule("zone-a")
+	c.Assert(err, check.IsNil)
+	c.Assert(*rule, check.DeepEquals, provision.NodePoolRule{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "b"}})
+}
+
+func (s *S) TestNodePoolRuleUpdateNotFound(c *check.C) {
+	req, err := http.NewRequest("PUT", "/1.3/node/poolrules/zone-a", strings.NewReader("pool=test1"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestNodePoolRuleRemove(c *check.C) {
+	err := provision.AddNodePoolRule(provision.NodePoolRule{Name: "zone-a", Pool: "test1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/1.3/node/poolrules/zone-a", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	_, err = provision.GetNodePoolRule("zone-a")
+	c.Assert(err, check.Equals, provision.ErrNodePoolRuleNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNodePoolRule, Value: "zone-a"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.node-rules.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "zone-a"},
+			{"name": ":version", "value": "1.3"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestNodePoolRuleRequiresPermission(c *check.C) {
+	token := userWithPermission(c)
+	req, err := http.NewRequest("GET", "/1.3/node/poolrules", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolDefaultsSet(c *check.C) {
+	body := strings.NewReader("teamowner=tsuruteam&router=fake&tag=a&tag=b")
+	req, err := http.NewRequest("PUT", "/1.3/pools/test1/defaults", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	pool, err := provision.GetPoolByName("test1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Defaults, check.DeepEquals, provision.PoolDefaults{
+		TeamOwner: "tsuruteam",
+		Router:    "fake",
+		Tags:      []string{"a", "b"},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "test1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.defaults.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "test1"},
+			{"name": ":version", "value": "1.3"},
+			{"name": "teamowner", "value": "tsuruteam"},
+			{"name": "router", "value": "fake"},
+			{"name": "tag", "value": []string{"a", "b"}},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolDefaultsSetInvalid(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "test1", Field: "router", Values: []string{"fake-tls"}})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"router=fake", `router "fake" is not allowed in pool "test1"`},
+		{"plan=unknown", "plan not found"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", "/1.3/pools/test1/defaults", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(rec.Body.String(), check.Equals, tt.msg+"\n")
+	}
+}
+
+func (s *S) TestPoolDefaultsSetNotFound(c *check.C) {
+	req, err := http.NewRequest("PUT", "/1.3/pools/unknown/defaults", strings.NewReader("router=fake"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestPoolDefaultsSetRequiresPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateDefaultsSet,
+		Context: permission.Context(permission.CtxPool, "other"),
+	})
+	req, err := http.NewRequest("PUT", "/1.3/pools/test1/defaults", strings.NewReader("router=fake"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolDefaultsGet(c *check.C) {
+	err := provision.SetPoolDefaults("test1", provision.PoolDefaults{TeamOwner: "tsuruteam"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolReadDefaults,
+		Context: permission.Context(permission.CtxPool, "test1"),
+	})
+	req, err := http.NewRequest("GET", "/1.3/pools/test1/defaults", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var defaults app.AppDefaults
+	err = json.NewDecoder(rec.Body).Decode(&defaults)
+	c.Assert(err, check.IsNil)
+	c.Assert(defaults, check.DeepEquals, app.AppDefaults{
+		Pool:      "test1",
+		Plan:      "autogenerated",
+		TeamOwner: "tsuruteam",
+		Router:    "fake",
+		Sources: map[string]string{
+			"plan":      app.DefaultSourceGlobal,
+			"teamowner": app.DefaultSourcePool,
+			"router":    app.DefaultSourceGlobal,
+		},
+	})
+}
+
+func (s *S) TestPoolDefaultsGetRequiresPermission(c *check.C) {
+	token := userWithPermission(c)
+	req, err := http.NewRequest("GET", "/1.3/pools/test1/defaults", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
@@ -8,12 +8,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 )
 
 // title: user quota
@@ -151,3 +153,198 @@ func changeAppQuota(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	}
 	return app.ChangeQuota(&a, limit)
 }
+
+// title: quota utilization report
+// path: /quota/report
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func quotaReport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	canReadApps := permission.Check(t, permission.PermAppAdminQuota)
+	canReadUsers := permission.Check(t, permission.PermUserUpdateQuota)
+	canReadTeams := permission.Check(t, permission.PermTeamReadQuota)
+	canReadPools := permission.Check(t, permission.PermPoolReadQuota)
+	if !canReadApps && !canReadUsers && !canReadTeams && !canReadPools {
+		return permission.ErrUnauthorized
+	}
+	since := time.Now().UTC().Add(-7 * 24 * time.Hour)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid since, it must be in RFC3339 format",
+			}
+		}
+	}
+	labels, err := provision.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	report, err := app.GetQuotaReport(since, labels)
+	if err != nil {
+		return err
+	}
+	if !canReadApps {
+		report.Apps = nil
+	}
+	if !canReadUsers {
+		report.Users = nil
+	}
+	if !canReadTeams {
+		report.Teams = nil
+	}
+	if !canReadPools {
+		report.Pools = nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(report)
+}
+
+// title: team quota
+// path: /teams/{name}/quota
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Team not found
+func getTeamQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamReadQuota, permission.Context(permission.CtxTeam, name))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	return writeResourceQuota(w, app.ResourceQuotaTeam, name)
+}
+
+// title: update team quota
+// path: /teams/{name}/quota
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Quota updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func changeTeamQuota(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	ctx := permission.Context(permission.CtxTeam, name)
+	allowed := permission.Check(t, permission.PermTeamUpdateQuota, ctx)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeTeam, Value: name},
+		Kind:       permission.PermTeamUpdateQuota,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, ctx),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return changeResourceQuota(r, app.ResourceQuotaTeam, name)
+}
+
+// title: pool quota
+// path: /pools/{name}/quota
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Pool not found
+func getPoolQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolReadQuota, permission.Context(permission.CtxPool, name))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	return writeResourceQuota(w, app.ResourceQuotaPool, name)
+}
+
+// title: update pool quota
+// path: /pools/{name}/quota
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Quota updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func changePoolQuota(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	ctx := permission.Context(permission.CtxPool, name)
+	allowed := permission.Check(t, permission.PermPoolUpdateQuota, ctx)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: name},
+		Kind:       permission.PermPoolUpdateQuota,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, ctx),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return changeResourceQuota(r, app.ResourceQuotaPool, name)
+}
+
+func writeResourceQuota(w http.ResponseWriter, scope, name string) error {
+	q, err := app.GetResourceQuota(scope, name)
+	if err != nil {
+		return resourceQuotaError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(q)
+}
+
+// changeResourceQuota updates the limits sent in the form, keeping the
+// current value of the missing ones.
+func changeResourceQuota(r *http.Request, scope, name string) error {
+	q, err := app.GetResourceQuota(scope, name)
+	if err != nil {
+		return resourceQuotaError(err)
+	}
+	limit := q.Limit
+	if apps := r.FormValue("apps"); apps != "" {
+		limit.Apps, err = strconv.Atoi(apps)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid apps limit"}
+		}
+	}
+	if units := r.FormValue("units"); units != "" {
+		limit.Units, err = strconv.Atoi(units)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid units limit"}
+		}
+	}
+	if memory := r.FormValue("memory"); memory != "" {
+		limit.Memory = getSize(memory)
+		if limit.Memory == 0 && memory != "0" {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid memory limit"}
+		}
+	}
+	return resourceQuotaError(app.ChangeResourceQuota(scope, name, limit))
+}
+
+func resourceQuotaError(err error) error {
+	switch err {
+	case auth.ErrTeamNotFound, provision.ErrPoolNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrResourceQuotaLesserThanInUse:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
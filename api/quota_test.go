@@ -19,6 +19,7 @@ import (
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository/repositorytest"
 	"gopkg.in/check.v1"
@@ -395,3 +396,334 @@ func (s *QuotaSuite) TestChangeAppQuotaAppNotFound(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
 	c.Assert(recorder.Body.String(), check.Equals, app.ErrAppNotFound.Error()+"\n")
 }
+
+func (s *QuotaSuite) TestQuotaReport(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "reporter", permission.Permission{
+		Scheme:  permission.PermAppAdminQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(app.App{Name: "almost", Quota: quota.Quota{Limit: 10, InUse: 9}})
+	c.Assert(err, check.IsNil)
+	err = conn.Apps().Insert(app.App{Name: "unlimited", Quota: quota.Unlimited})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/quota/report", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var report app.QuotaReport
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Users, check.IsNil)
+	c.Assert(report.Apps, check.DeepEquals, []app.QuotaUsage{
+		{Name: "almost", Limit: 10, InUse: 9, Usage: 90},
+	})
+}
+
+func (s *QuotaSuite) TestQuotaReportFilteringByLabels(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "reporter", permission.Permission{
+		Scheme:  permission.PermAppAdminQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(app.App{Name: "store", Quota: quota.Quota{Limit: 10, InUse: 9}, Labels: map[string]string{"cost-center": "1234"}})
+	c.Assert(err, check.IsNil)
+	err = conn.Apps().Insert(app.App{Name: "blog", Quota: quota.Quota{Limit: 10, InUse: 5}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/quota/report?label=cost-center=1234", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var report app.QuotaReport
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Apps, check.DeepEquals, []app.QuotaUsage{
+		{Name: "store", Limit: 10, InUse: 9, Usage: 90},
+	})
+}
+
+func (s *QuotaSuite) TestQuotaReportInvalidSince(c *check.C) {
+	request, err := http.NewRequest("GET", "/quota/report?since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *QuotaSuite) TestQuotaReportRequiresPermission(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/quota/report", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *QuotaSuite) TestGetTeamQuota(c *check.C) {
+	err := app.ChangeResourceQuota(app.ResourceQuotaTeam, s.team.Name, app.ResourceLimits{Apps: 5, Units: 10, Memory: -1})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "teamreader", permission.Permission{
+		Scheme:  permission.PermTeamReadQuota,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, _ := http.NewRequest("GET", "/teams/superteam/quota", nil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var q app.ResourceQuota
+	err = json.NewDecoder(recorder.Body).Decode(&q)
+	c.Assert(err, check.IsNil)
+	c.Assert(q, check.DeepEquals, app.ResourceQuota{
+		Scope: app.ResourceQuotaTeam,
+		Name:  s.team.Name,
+		Limit: app.ResourceLimits{Apps: 5, Units: 10, Memory: -1},
+	})
+}
+
+func (s *QuotaSuite) TestGetTeamQuotaRequiresPermission(c *check.C) {
+	request, _ := http.NewRequest("GET", "/teams/superteam/quota", nil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *QuotaSuite) TestGetTeamQuotaTeamNotFound(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "teamreader", permission.Permission{
+		Scheme:  permission.PermTeamReadQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, _ := http.NewRequest("GET", "/teams/unknown/quota", nil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrTeamNotFound.Error()+"\n")
+}
+
+func (s *QuotaSuite) TestChangeTeamQuota(c *check.C) {
+	err := app.ChangeResourceQuota(app.ResourceQuotaTeam, s.team.Name, app.ResourceLimits{Apps: 5, Units: 10, Memory: -1})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "teamadmin", permission.Permission{
+		Scheme:  permission.PermTeamUpdateQuota,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := bytes.NewBufferString("units=20&memory=1G")
+	request, _ := http.NewRequest("PUT", "/teams/superteam/quota", body)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	q, err := app.GetResourceQuota(app.ResourceQuotaTeam, s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Limit, check.DeepEquals, app.ResourceLimits{Apps: 5, Units: 20, Memory: 1024 * 1024 * 1024})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeTeam, Value: s.team.Name},
+		Owner:  token.GetUserName(),
+		Kind:   "team.update.quota",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": s.team.Name},
+			{"name": "units", "value": "20"},
+			{"name": "memory", "value": "1G"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *QuotaSuite) TestChangeTeamQuotaRequiresPermission(c *check.C) {
+	body := bytes.NewBufferString("apps=2")
+	request, _ := http.NewRequest("PUT", "/teams/superteam/quota", body)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *QuotaSuite) TestChangeTeamQuotaInvalidValues(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "teamadmin", permission.Permission{
+		Scheme:  permission.PermTeamUpdateQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"apps=many", "Invalid apps limit"},
+		{"units=1.5", "Invalid units limit"},
+		{"memory=lots", "Invalid memory limit"},
+	}
+	for _, tt := range tests {
+		request, _ := http.NewRequest("PUT", "/teams/superteam/quota", bytes.NewBufferString(tt.body))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+token.GetValue())
+		recorder := httptest.NewRecorder()
+		handler := RunServer(true)
+		handler.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, tt.msg+"\n")
+	}
+}
+
+func (s *QuotaSuite) TestChangeTeamQuotaLesserThanInUse(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(app.App{Name: "shangrila", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "teamadmin", permission.Permission{
+		Scheme:  permission.PermTeamUpdateQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	body := bytes.NewBufferString("apps=0")
+	request, _ := http.NewRequest("PUT", "/teams/superteam/quota", body)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrResourceQuotaLesserThanInUse.Error()+"\n")
+}
+
+func (s *QuotaSuite) TestGetPoolQuota(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "quotapool"})
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(app.App{
+		Name:  "shangrila",
+		Pool:  "quotapool",
+		Plan:  app.Plan{Memory: 512},
+		Quota: quota.Quota{Limit: -1, InUse: 2},
+	})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "poolreader", permission.Permission{
+		Scheme:  permission.PermPoolReadQuota,
+		Context: permission.Context(permission.CtxPool, "quotapool"),
+	})
+	request, _ := http.NewRequest("GET", "/pools/quotapool/quota", nil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var q app.ResourceQuota
+	err = json.NewDecoder(recorder.Body).Decode(&q)
+	c.Assert(err, check.IsNil)
+	c.Assert(q, check.DeepEquals, app.ResourceQuota{
+		Scope: app.ResourceQuotaPool,
+		Name:  "quotapool",
+		Limit: app.UnlimitedResources,
+		InUse: app.ResourceLimits{Apps: 1, Units: 2, Memory: 1024},
+	})
+}
+
+func (s *QuotaSuite) TestGetPoolQuotaPoolNotFound(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "poolreader", permission.Permission{
+		Scheme:  permission.PermPoolReadQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, _ := http.NewRequest("GET", "/pools/unknown/quota", nil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrPoolNotFound.Error()+"\n")
+}
+
+func (s *QuotaSuite) TestChangePoolQuota(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "quotapool"})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "pooladmin", permission.Permission{
+		Scheme:  permission.PermPoolUpdateQuota,
+		Context: permission.Context(permission.CtxPool, "quotapool"),
+	})
+	body := bytes.NewBufferString("apps=3&units=-1")
+	request, _ := http.NewRequest("PUT", "/pools/quotapool/quota", body)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	q, err := app.GetResourceQuota(app.ResourceQuotaPool, "quotapool")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Limit, check.DeepEquals, app.ResourceLimits{Apps: 3, Units: -1, Memory: -1})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "quotapool"},
+		Owner:  token.GetUserName(),
+		Kind:   "pool.update.quota",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "quotapool"},
+			{"name": "apps", "value": "3"},
+			{"name": "units", "value": "-1"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *QuotaSuite) TestChangePoolQuotaRequiresPermission(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "quotapool"})
+	c.Assert(err, check.IsNil)
+	body := bytes.NewBufferString("apps=3")
+	request, _ := http.NewRequest("PUT", "/pools/quotapool/quota", body)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *QuotaSuite) TestQuotaReportTeamsAndPools(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "quotapool"})
+	c.Assert(err, check.IsNil)
+	err = app.ChangeResourceQuota(app.ResourceQuotaTeam, s.team.Name, app.ResourceLimits{Apps: 5, Units: -1, Memory: -1})
+	c.Assert(err, check.IsNil)
+	err = app.ChangeResourceQuota(app.ResourceQuotaPool, "quotapool", app.ResourceLimits{Apps: -1, Units: 10, Memory: -1})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "reporter", permission.Permission{
+		Scheme:  permission.PermTeamReadQuota,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/quota/report", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var report app.QuotaReport
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Apps, check.IsNil)
+	c.Assert(report.Users, check.IsNil)
+	c.Assert(report.Pools, check.IsNil)
+	c.Assert(report.Teams, check.DeepEquals, []app.ResourceQuota{
+		{Scope: app.ResourceQuotaTeam, Name: s.team.Name, Limit: app.ResourceLimits{Apps: 5, Units: -1, Memory: -1}},
+	})
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/readonly"
+)
+
+// title: read-only mode list
+// path: /readonly
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func readOnlyList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermReadonlyRead) {
+		return permission.ErrUnauthorized
+	}
+	statuses, err := readonly.List()
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(statuses)
+}
+
+// title: read-only mode enable
+// path: /readonly
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Read-only mode enabled
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func readOnlyEnable(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermReadonlyEnable) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	pool := r.FormValue("pool")
+	reason := r.FormValue("reason")
+	if reason == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: readonly.ErrReasonRequired.Error()}
+	}
+	if pool != "" {
+		_, err = provision.GetPoolByName(pool)
+		if err == provision.ErrPoolNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeReadOnly, Value: pool},
+		Kind:         permission.PermReadonlyEnable,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermReadonlyReadEvents),
+		ExtraTargets: readOnlyPoolTargets(pool),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return readonly.Enable(pool, reason, t.GetUserName())
+}
+
+// title: read-only mode disable
+// path: /readonly
+// method: DELETE
+// responses:
+//   200: Read-only mode disabled
+//   401: Unauthorized
+//   404: Read-only mode not enabled
+func readOnlyDisable(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermReadonlyDisable) {
+		return permission.ErrUnauthorized
+	}
+	pool := r.URL.Query().Get("pool")
+	evt, err := event.New(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeReadOnly, Value: pool},
+		Kind:         permission.PermReadonlyDisable,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.URL.Query()),
+		Allowed:      event.Allowed(permission.PermReadonlyReadEvents),
+		ExtraTargets: readOnlyPoolTargets(pool),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = readonly.Disable(pool)
+	if err == readonly.ErrNotReadOnly {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// readOnlyPoolTargets makes read-only mode changes of a pool visible in the
+// events of the pool.
+func readOnlyPoolTargets(pool string) []event.Target {
+	if pool == "" {
+		return nil
+	}
+	return []event.Target{{Type: event.TargetTypePool, Value: pool}}
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/readonly"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestReadOnlyEnable(c *check.C) {
+	defer readonly.Disable("")
+	body := strings.NewReader("reason=database+upgrade")
+	request, err := http.NewRequest("POST", "/1.3/readonly", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	statuses, err := readonly.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 1)
+	c.Assert(statuses[0].Pool, check.Equals, "")
+	c.Assert(statuses[0].Reason, check.Equals, "database upgrade")
+	c.Assert(statuses[0].Owner, check.Equals, s.token.GetUserName())
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeReadOnly},
+		Owner:  s.token.GetUserName(),
+		Kind:   "readonly.enable",
+		StartCustomData: []map[string]interface{}{
+			{"name": "reason", "value": "database upgrade"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestReadOnlyEnablePool(c *check.C) {
+	defer readonly.Disable(s.Pool)
+	body := strings.NewReader("reason=maintenance&pool=" + s.Pool)
+	request, err := http.NewRequest("POST", "/1.3/readonly", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(readonly.Check(s.Pool), check.NotNil)
+	c.Assert(readonly.Check(""), check.IsNil)
+	evts, err := event.List(&event.Filter{Target: event.Target{Type: event.TargetTypePool, Value: s.Pool}})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Kind.Name, check.Equals, "readonly.enable")
+}
+
+func (s *S) TestReadOnlyEnableInvalid(c *check.C) {
+	tests := []struct {
+		body string
+		code int
+	}{
+		{"pool=" + s.Pool, http.StatusBadRequest},
+		{"reason=maintenance&pool=unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/1.3/readonly", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, tt.code)
+	}
+	statuses, err := readonly.List()
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 0)
+}
+
+func (s *S) TestReadOnlyEnableForbidden(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermReadonlyRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/1.3/readonly", strings.NewReader("reason=maintenance"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestReadOnlyDisable(c *check.C) {
+	err := readonly.Enable("", "database upgrade", s.token.GetUserName())
+	c.Assert(err, check.IsNil)
+	defer readonly.Disable("")
+	request, err := http.NewRequest("DELETE", "/1.3/readonly", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(readonly.Check(""), check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeReadOnly},
+		Owner:  s.token.GetUserName(),
+		Kind:   "readonly.disable",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestReadOnlyList(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/readonly", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	err = readonly.Enable(s.Pool, "maintenance", s.token.GetUserName())
+	c.Assert(err, check.IsNil)
+	defer readonly.Disable(s.Pool)
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var statuses []readonly.Status
+	err = json.Unmarshal(recorder.Body.Bytes(), &statuses)
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 1)
+	c.Assert(statuses[0].Pool, check.Equals, s.Pool)
+	c.Assert(statuses[0].Reason, check.Equals, "maintenance")
+}
+
+func (s *S) TestReadOnlyRejectsMutatingRequests(c *check.C) {
+	err := readonly.Enable("", "database upgrade", s.token.GetUserName())
+	c.Assert(err, check.IsNil)
+	defer readonly.Disable("")
+	request, err := http.NewRequest("POST", "/motd", strings.NewReader("message=hi"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(recorder.Body.String(), check.Matches, "tsuru is in read-only mode since .+: database upgrade\n")
+	request, err = http.NewRequest("GET", "/motd", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestReadOnlyPoolRejectsAppRequests(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = readonly.Enable(s.Pool, "maintenance", s.token.GetUserName())
+	c.Assert(err, check.IsNil)
+	defer readonly.Disable(s.Pool)
+	request, err := http.NewRequest("POST", "/apps/myapp/env", strings.NewReader("Envs.0.Name=FOO&Envs.0.Value=bar"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(recorder.Body.String(), check.Matches, `pool "test1" is in read-only mode since .+: maintenance\n`)
+	request, err = http.NewRequest("POST", "/apps", strings.NewReader("name=otherapp&platform=zend&pool="+s.Pool))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+	request, err = http.NewRequest("GET", "/apps/myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// registryScope describes the permissions and the event target used by the
+// registry credential handlers of pools and teams.
+type registryScope struct {
+	pool       bool
+	targetType event.TargetType
+	read       *permission.PermissionScheme
+	set        *permission.PermissionScheme
+	remove     *permission.PermissionScheme
+	readEvents *permission.PermissionScheme
+}
+
+var (
+	poolRegistryScope = registryScope{
+		pool:       true,
+		targetType: event.TargetTypePool,
+		read:       permission.PermPoolReadRegistry,
+		set:        permission.PermPoolUpdateRegistrySet,
+		remove:     permission.PermPoolUpdateRegistryRemove,
+		readEvents: permission.PermPoolReadEvents,
+	}
+	teamRegistryScope = registryScope{
+		targetType: event.TargetTypeTeam,
+		read:       permission.PermTeamReadRegistry,
+		set:        permission.PermTeamUpdateRegistrySet,
+		remove:     permission.PermTeamUpdateRegistryRemove,
+		readEvents: permission.PermTeamReadEvents,
+	}
+)
+
+func (s *registryScope) context(name string) permission.PermissionContext {
+	if s.pool {
+		return permission.Context(permission.CtxPool, name)
+	}
+	return permission.Context(permission.CtxTeam, name)
+}
+
+func (s *registryScope) credential(name string) provision.RegistryCredential {
+	if s.pool {
+		return provision.RegistryCredential{Pool: name}
+	}
+	return provision.RegistryCredential{Team: name}
+}
+
+func (s *registryScope) notFound(err error) bool {
+	return err == provision.ErrPoolNotFound || err == auth.ErrTeamNotFound || err == provision.ErrRegistryCredentialNotFound
+}
+
+// title: pool registry list
+// path: /pools/{name}/registries
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func poolRegistryList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return registryList(w, r, t, &poolRegistryScope)
+}
+
+// title: pool registry set
+// path: /pools/{name}/registries
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func poolRegistrySet(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return registrySet(w, r, t, &poolRegistryScope)
+}
+
+// title: pool registry remove
+// path: /pools/{name}/registries/{server}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func poolRegistryRemove(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return registryRemove(w, r, t, &poolRegistryScope)
+}
+
+// title: team registry list
+// path: /teams/{name}/registries
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func teamRegistryList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return registryList(w, r, t, &teamRegistryScope)
+}
+
+// title: team registry set
+// path: /teams/{name}/registries
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func teamRegistrySet(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return registrySet(w, r, t, &teamRegistryScope)
+}
+
+// title: team registry remove
+// path: /teams/{name}/registries/{server}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func teamRegistryRemove(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return registryRemove(w, r, t, &teamRegistryScope)
+}
+
+func registryList(w http.ResponseWriter, r *http.Request, t auth.Token, scope *registryScope) error {
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, scope.read, scope.context(name)) {
+		return permission.ErrUnauthorized
+	}
+	cred := scope.credential(name)
+	creds, err := provision.ListRegistryCredentials(bson.M{"pool": cred.Pool, "team": cred.Team})
+	if err != nil {
+		return err
+	}
+	if len(creds) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(creds)
+}
+
+func registrySet(w http.ResponseWriter, r *http.Request, t auth.Token, scope *registryScope) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, scope.set, scope.context(name)) {
+		return permission.ErrUnauthorized
+	}
+	cred := scope.credential(name)
+	cred.Server = r.FormValue("server")
+	cred.Username = r.FormValue("username")
+	cred.Password = r.FormValue("password")
+	cred.Email = r.FormValue("email")
+	delete(r.Form, "password")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: scope.targetType, Value: name},
+		Kind:       scope.set,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(scope.readEvents, scope.context(name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.SetRegistryCredential(&cred)
+	switch err {
+	case provision.ErrRegistryServerRequired, provision.ErrRegistryUserRequired:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if scope.notFound(err) {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+func registryRemove(w http.ResponseWriter, r *http.Request, t auth.Token, scope *registryScope) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, scope.remove, scope.context(name)) {
+		return permission.ErrUnauthorized
+	}
+	server := r.URL.Query().Get(":server")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: scope.targetType, Value: name},
+		Kind:       scope.remove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(scope.readEvents, scope.context(name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	cred := scope.credential(name)
+	err = provision.RemoveRegistryCredential(server, cred.Pool, cred.Team)
+	if scope.notFound(err) {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
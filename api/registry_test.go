@@ -0,0 +1,144 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPoolRegistrySet(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	body := strings.NewReader("server=registry.example.com&username=user&password=pass")
+	req, err := http.NewRequest("PUT", "/1.3/pools/test1/registries", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	a.Pool = "test1"
+	cred, err := provision.RegistryCredentialForApp(a, "registry.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred.Username, check.Equals, "user")
+	c.Assert(cred.Password, check.Equals, "pass")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "test1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.registry.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "test1"},
+			{"name": "server", "value": "registry.example.com"},
+			{"name": "username", "value": "user"},
+		},
+	}, eventtest.HasEvent)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	for i := range evts {
+		var data []map[string]interface{}
+		evts[i].StartData(&data)
+		for _, field := range data {
+			c.Assert(field["name"], check.Not(check.Equals), "password")
+		}
+	}
+}
+
+func (s *S) TestPoolRegistrySetInvalid(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"username=user&password=pass", "registry server is required"},
+		{"server=r.io&username=user", "registry username and password are required"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", "/1.3/pools/test1/registries", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(rec.Body.String(), check.Equals, tt.msg+"\n")
+	}
+}
+
+func (s *S) TestPoolRegistrySetRequiresPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateRegistrySet,
+		Context: permission.Context(permission.CtxPool, "other"),
+	})
+	body := strings.NewReader("server=r.io&username=user&password=pass")
+	req, err := http.NewRequest("PUT", "/1.3/pools/test1/registries", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestTeamRegistryListAndRemove(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	err := provision.SetRegistryCredential(&provision.RegistryCredential{
+		Server: "r.io", Team: s.team.Name, Username: "user", Password: "pass",
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/1.3/teams/"+s.team.Name+"/registries", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Not(check.Matches), "(?s).*pass.*")
+	var creds []provision.RegistryCredential
+	err = json.NewDecoder(rec.Body).Decode(&creds)
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.HasLen, 1)
+	c.Assert(creds[0].Server, check.Equals, "r.io")
+	c.Assert(creds[0].Username, check.Equals, "user")
+	req, err = http.NewRequest("DELETE", "/1.3/teams/"+s.team.Name+"/registries/r.io", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeTeam, Value: s.team.Name},
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.update.registry.remove",
+	}, eventtest.HasEvent)
+	req, err = http.NewRequest("GET", "/1.3/teams/"+s.team.Name+"/registries", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	req, err = http.NewRequest("DELETE", "/1.3/teams/"+s.team.Name+"/registries/r.io", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
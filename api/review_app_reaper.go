@@ -0,0 +1,106 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const (
+	// reviewAppExpiredEventKind is the internal kind of the events recording
+	// the removal of inactive review apps.
+	reviewAppExpiredEventKind = "review-app-expired"
+
+	defaultReviewAppReaperInterval = 5 * time.Minute
+)
+
+// reviewAppReaper periodically removes the review apps inactive for longer
+// than the TTL of their template apps.
+type reviewAppReaper struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// initializeReviewAppReaper starts removing inactive review apps, checking
+// for them every review-apps:reaper-interval seconds.
+func initializeReviewAppReaper() {
+	rr := &reviewAppReaper{
+		interval: defaultReviewAppReaperInterval,
+		done:     make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("review-apps:reaper-interval"); seconds > 0 {
+		rr.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(rr)
+	go rr.run()
+}
+
+func (rr *reviewAppReaper) run() {
+	for {
+		err := reapInactiveReviewApps(time.Now())
+		if err != nil {
+			log.Errorf("[review app reaper] unable to remove inactive review apps: %s", err)
+		}
+		select {
+		case <-rr.done:
+			return
+		case <-time.After(rr.interval):
+		}
+	}
+}
+
+func (rr *reviewAppReaper) Shutdown() {
+	rr.done <- true
+}
+
+func (rr *reviewAppReaper) String() string {
+	return "review app reaper"
+}
+
+// reapInactiveReviewApps removes the review apps inactive until now,
+// recording the removal of each one in an internal event targeting the app.
+// Apps locked by other events are skipped until the next run.
+func reapInactiveReviewApps(now time.Time) error {
+	apps, err := app.ListInactiveReviewApps(now)
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		err = removeInactiveReviewApp(&apps[i])
+		if err != nil {
+			log.Errorf("[review app reaper] unable to remove review app %q: %s", apps[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func removeInactiveReviewApp(a *app.App) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       appTarget(a.Name),
+		InternalKind: reviewAppExpiredEventKind,
+		CustomData: map[string]interface{}{
+			"template":     a.ReviewApp.Template,
+			"branch":       a.ReviewApp.Branch,
+			"lastActivity": a.ReviewApp.LastActivity,
+		},
+		Allowed:      event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...),
+		ExtraTargets: []event.Target{appTarget(a.ReviewApp.Template)},
+	})
+	if _, locked := err.(event.ErrEventLocked); locked {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return app.DestroyReviewApp(a, "", evt)
+}
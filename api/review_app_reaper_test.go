@@ -0,0 +1,67 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestReapInactiveReviewApps(c *check.C) {
+	template := s.createReviewAppsTemplate(c)
+	inactive, err := app.SyncReviewApp(template, "feature", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	_, err = app.SyncReviewApp(template, "master", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	lastActivity := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Millisecond)
+	err = s.conn.Apps().Update(bson.M{"name": inactive.Name}, bson.M{
+		"$set": bson.M{"reviewapp.lastactivity": lastActivity},
+	})
+	c.Assert(err, check.IsNil)
+	err = reapInactiveReviewApps(time.Now())
+	c.Assert(err, check.IsNil)
+	_, err = app.GetByName(inactive.Name)
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+	_, err = app.GetByName("web-master")
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(inactive.Name),
+		Kind:   reviewAppExpiredEventKind,
+		StartCustomData: map[string]interface{}{
+			"template":     "web",
+			"branch":       "feature",
+			"lastActivity": lastActivity,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestReapInactiveReviewAppsSkipsLockedApps(c *check.C) {
+	template := s.createReviewAppsTemplate(c)
+	inactive, err := app.SyncReviewApp(template, "feature", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:  appTarget(inactive.Name),
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = reapInactiveReviewApps(time.Now().Add(2 * time.Hour))
+	c.Assert(err, check.IsNil)
+	_, err = app.GetByName(inactive.Name)
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = reapInactiveReviewApps(time.Now().Add(2 * time.Hour))
+	c.Assert(err, check.IsNil)
+	_, err = app.GetByName(inactive.Name)
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+}
@@ -0,0 +1,315 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// reviewAppSummary is the representation of a review app in the list of
+// review apps of a template app.
+type reviewAppSummary struct {
+	Name         string    `json:"name"`
+	Branch       string    `json:"branch"`
+	CName        []string  `json:"cname"`
+	LastActivity time.Time `json:"lastActivity"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// title: review app list
+// path: /apps/{app}/review-apps
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func reviewAppList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppRead, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	apps, err := app.ListReviewApps(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	ttl := a.ReviewAppsTTL()
+	result := make([]reviewAppSummary, len(apps))
+	for i, reviewApp := range apps {
+		result[i] = reviewAppSummary{
+			Name:         reviewApp.Name,
+			Branch:       reviewApp.ReviewApp.Branch,
+			CName:        reviewApp.CName,
+			LastActivity: reviewApp.ReviewApp.LastActivity,
+			ExpiresAt:    reviewApp.ReviewApp.LastActivity.Add(ttl),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: review apps enable
+// path: /apps/{app}/review-apps
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Review apps enabled
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func reviewAppsEnable(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	cfg := app.ReviewAppsConfig{
+		GitURL: r.FormValue("git-url"),
+		Pool:   r.FormValue("pool"),
+	}
+	if v := r.FormValue("ttl"); v != "" {
+		seconds, parseErr := strconv.ParseFloat(v, 64)
+		if parseErr != nil {
+			return &terrors.HTTP{Code: http.StatusBadRequest, Message: "ttl must be a number of seconds"}
+		}
+		cfg.TTL = time.Duration(seconds * float64(time.Second))
+	}
+	cfg.IncludeSecrets, _ = strconv.ParseBool(r.FormValue("secrets"))
+	cfg.NewInstances, _ = strconv.ParseBool(r.FormValue("newinstances"))
+	if !permission.Check(t, permission.PermAppUpdateReviewApps, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	// Review apps are clones of the app created on notifications, so the
+	// user enabling them must be able to clone it.
+	cloneOpts := app.CloneOptions{
+		TeamOwner:      a.TeamOwner,
+		IncludeSecrets: cfg.IncludeSecrets,
+		NewInstances:   cfg.NewInstances,
+	}
+	canClone := permission.Check(t, permission.PermAppReadEnv, contextsForApp(&a)...) &&
+		permission.Check(t, permission.PermAppCreateClone, permission.Context(permission.CtxTeam, a.TeamOwner))
+	if !canClone {
+		return permission.ErrUnauthorized
+	}
+	if cfg.IncludeSecrets && !permission.Check(t, permission.PermAppAdminSecrets, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	err = checkClonedInstancesPermission(t, a.Name, cloneOpts)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateReviewApps,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.SetReviewApps(&cfg)
+	if e, ok := err.(*terrors.ValidationError); ok {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	return err
+}
+
+// title: review apps disable
+// path: /apps/{app}/review-apps
+// method: DELETE
+// responses:
+//   200: Review apps disabled
+//   401: Unauthorized
+//   404: Not found
+func reviewAppsDisable(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateReviewApps, contextsForApp(&a)...) {
+		return permission.ErrUnauthorized
+	}
+	if a.ReviewApps == nil {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: app.ErrReviewAppsDisabled.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateReviewApps,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.SetReviewApps(nil)
+}
+
+// title: review app notify
+// path: /apps/{app}/review-apps
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   403: Quota exceeded
+//   404: Not found
+//   409: Conflict
+func reviewAppNotify(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	template, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppDeployReviewApp, contextsForApp(&template)...) {
+		return permission.ErrUnauthorized
+	}
+	branch := r.FormValue("branch")
+	if branch == "" {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "the branch is required"}
+	}
+	action := r.FormValue("action")
+	if action == "" {
+		action = "sync"
+	}
+	if action != "open" && action != "sync" && action != "close" {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid action %q, it must be open, sync or close", action)}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:       appTarget(app.ReviewAppName(template.Name, branch)),
+		Kind:         permission.PermAppDeployReviewApp,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermAppReadEvents, contextsForApp(&template)...),
+		ExtraTargets: []event.Target{appTarget(template.Name)},
+	})
+	if err != nil {
+		return err
+	}
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	requestIDHeader, _ := config.GetString("request-id-header")
+	requestID := context.GetRequestID(r, requestIDHeader)
+	if action == "close" {
+		defer func() { evt.Done(err) }()
+		return closeReviewApp(&template, branch, requestID, writer)
+	}
+	reviewApp, err := syncReviewApp(&template, branch, t, requestID, writer)
+	evt.Done(err)
+	if err != nil {
+		return err
+	}
+	return deployReviewApp(reviewApp, &template, t, writer)
+}
+
+func closeReviewApp(template *app.App, branch, requestID string, writer *tsuruIo.SimpleJsonMessageEncoderWriter) error {
+	reviewApp, err := app.GetReviewApp(template, branch)
+	if err == app.ErrReviewAppNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if e, ok := err.(*terrors.ConflictError); ok {
+		return &terrors.HTTP{Code: http.StatusConflict, Message: e.Message}
+	}
+	if err != nil {
+		return err
+	}
+	err = app.DestroyReviewApp(reviewApp, requestID, writer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "Review app %q of the branch %q removed.\n", reviewApp.Name, branch)
+	return nil
+}
+
+// syncReviewApp creates or refreshes the review app of the branch. Nothing
+// is written before the review app is created, keeping the status code of
+// errors.
+func syncReviewApp(template *app.App, branch string, t auth.Token, requestID string, writer *tsuruIo.SimpleJsonMessageEncoderWriter) (*app.App, error) {
+	u, err := t.User()
+	if err != nil {
+		return nil, err
+	}
+	reviewApp, err := app.SyncReviewApp(template, branch, u, requestID, writer)
+	switch e := err.(type) {
+	case nil:
+		return reviewApp, nil
+	case *terrors.ConflictError:
+		return nil, &terrors.HTTP{Code: http.StatusConflict, Message: e.Message}
+	}
+	if err == app.ErrReviewAppsDisabled {
+		return nil, &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return nil, appCreationHTTPError(err)
+}
+
+// deployReviewApp deploys the branch of the review app from the repository
+// of the template, recording it in a deploy event of the review app.
+func deployReviewApp(reviewApp, template *app.App, t auth.Token, writer *tsuruIo.SimpleJsonMessageEncoderWriter) (err error) {
+	opts := app.DeployOptions{
+		App:          reviewApp,
+		User:         t.GetUserName(),
+		Origin:       "git",
+		GitURL:       template.ReviewApps.GitURL,
+		GitRef:       reviewApp.ReviewApp.Branch,
+		OutputStream: writer,
+	}
+	opts.GetKind()
+	var imageID string
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(reviewApp.Name),
+		Kind:          permission.PermAppDeploy,
+		Owner:         t,
+		CustomData:    opts,
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(reviewApp)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(reviewApp)...),
+		Cancelable:    true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.DoneCustomData(err, app.DeployEndData(evt, imageID, nil)) }()
+	opts.Event = evt
+	imageID, err = app.Deploy(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "Review app %q of the branch %q deployed.\n", reviewApp.Name, reviewApp.ReviewApp.Branch)
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createReviewAppsTemplate(c *check.C) *app.App {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetReviewApps(&app.ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git", TTL: time.Hour})
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestReviewAppsEnable(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("git-url=https://github.com/tsuru/web.git&ttl=3600&newinstances=true")
+	m := RunServer(true)
+	req, err := http.NewRequest("PUT", "/1.3/apps/web/review-apps", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApps, check.DeepEquals, &app.ReviewAppsConfig{
+		GitURL:       "https://github.com/tsuru/web.git",
+		TTL:          time.Hour,
+		NewInstances: true,
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("web"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.review-apps",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "web"},
+			{"name": "git-url", "value": "https://github.com/tsuru/web.git"},
+			{"name": "ttl", "value": "3600"},
+			{"name": "newinstances", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestReviewAppsEnableInvalid(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []string{
+		"",
+		"git-url=file:///etc",
+		"git-url=https://github.com/tsuru/web.git&ttl=abc",
+		"git-url=https://github.com/tsuru/web.git&ttl=1",
+	}
+	m := RunServer(true)
+	for _, body := range tests {
+		req, err := http.NewRequest("PUT", "/1.3/apps/web/review-apps", strings.NewReader(body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, http.StatusBadRequest, check.Commentf("body: %q", body))
+	}
+}
+
+func (s *S) TestReviewAppsEnableRequiresClonePermission(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c,
+		permission.Permission{Scheme: permission.PermAppUpdateReviewApps, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+		permission.Permission{Scheme: permission.PermAppReadEnv, Context: permission.Context(permission.CtxTeam, s.team.Name)},
+	)
+	body := strings.NewReader("git-url=https://github.com/tsuru/web.git")
+	m := RunServer(true)
+	req, err := http.NewRequest("PUT", "/1.3/apps/web/review-apps", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+	dbApp, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApps, check.IsNil)
+}
+
+func (s *S) TestReviewAppsDisable(c *check.C) {
+	s.createReviewAppsTemplate(c)
+	m := RunServer(true)
+	req, err := http.NewRequest("DELETE", "/1.3/apps/web/review-apps", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApps, check.IsNil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestReviewAppList(c *check.C) {
+	template := s.createReviewAppsTemplate(c)
+	m := RunServer(true)
+	req, err := http.NewRequest("GET", "/1.3/apps/web/review-apps", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	reviewApp, err := app.SyncReviewApp(template, "feature/login", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []reviewAppSummary
+	err = json.NewDecoder(rec.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].Name, check.Equals, "web-feature-login")
+	c.Assert(result[0].Branch, check.Equals, "feature/login")
+	c.Assert(result[0].ExpiresAt.Sub(result[0].LastActivity), check.Equals, time.Hour)
+	c.Assert(result[0].LastActivity.Sub(reviewApp.ReviewApp.LastActivity) < time.Second, check.Equals, true)
+}
+
+func (s *S) TestReviewAppNotifyClose(c *check.C) {
+	template := s.createReviewAppsTemplate(c)
+	_, err := app.SyncReviewApp(template, "feature/login", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("branch=feature/login&action=close")
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/review-apps", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*Review app \\"web-feature-login\\" of the branch \\"feature/login\\" removed..*`)
+	_, err = app.GetByName("web-feature-login")
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("web-feature-login"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy.review-app",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "web"},
+			{"name": "branch", "value": "feature/login"},
+			{"name": "action", "value": "close"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestReviewAppNotifyInvalid(c *check.C) {
+	s.createReviewAppsTemplate(c)
+	other := app.App{Name: "web-master", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&other, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body string
+		code int
+	}{
+		{"", http.StatusBadRequest},
+		{"branch=master&action=merge", http.StatusBadRequest},
+		{"branch=a..b", http.StatusBadRequest},
+		{"branch=master", http.StatusConflict},
+		{"branch=feature&action=close", http.StatusNotFound},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/1.3/apps/web/review-apps", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, tt.code, check.Commentf("body: %q", tt.body))
+	}
+	dbApp, err := app.GetByName(other.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApp, check.IsNil)
+}
+
+func (s *S) TestReviewAppNotifyDisabled(c *check.C) {
+	a := app.App{Name: "web", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/review-apps", strings.NewReader("branch=master"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, app.ErrReviewAppsDisabled.Error()+"\n")
+}
+
+func (s *S) TestReviewAppNotifyRequiresPermission(c *check.C) {
+	s.createReviewAppsTemplate(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	m := RunServer(true)
+	req, err := http.NewRequest("POST", "/1.3/apps/web/review-apps", strings.NewReader("branch=master"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+	_, err = app.GetByName("web-master")
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+}
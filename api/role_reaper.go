@@ -0,0 +1,122 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const (
+	// roleExpiredEventKind is the internal kind of the events recording the
+	// removal of expired role assignments.
+	roleExpiredEventKind = "role-expired"
+
+	defaultRoleReaperInterval = time.Minute
+)
+
+// roleReaper periodically removes the expired role assignments of users.
+type roleReaper struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// initializeRoleReaper starts removing expired role assignments, checking
+// for them every auth:role-reaper-interval seconds.
+func initializeRoleReaper() {
+	rr := &roleReaper{
+		interval: defaultRoleReaperInterval,
+		done:     make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("auth:role-reaper-interval"); seconds > 0 {
+		rr.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(rr)
+	go rr.run()
+}
+
+func (rr *roleReaper) run() {
+	for {
+		err := reapExpiredRoles(time.Now())
+		if err != nil {
+			log.Errorf("[role reaper] unable to remove expired roles: %s", err)
+		}
+		select {
+		case <-rr.done:
+			return
+		case <-time.After(rr.interval):
+		}
+	}
+}
+
+func (rr *roleReaper) Shutdown() {
+	rr.done <- true
+}
+
+func (rr *roleReaper) String() string {
+	return "role reaper"
+}
+
+// reapExpiredRoles removes the role assignments that expired before now,
+// recording the removal of each one in an internal event targeting the user.
+// Users locked by other events are skipped until the next run.
+func reapExpiredRoles(now time.Time) error {
+	users, err := auth.ListUsersWithExpiredRoles(now)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		u := &users[i]
+		for _, role := range u.Roles {
+			if !role.Expired(now) {
+				continue
+			}
+			err = removeExpiredRole(u, role)
+			if err != nil {
+				log.Errorf("[role reaper] unable to remove expired role %q from %s: %s", role.Name, u.Email, err)
+			}
+		}
+	}
+	return nil
+}
+
+func removeExpiredRole(u *auth.User, role auth.RoleInstance) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeUser, Value: u.Email},
+		InternalKind: roleExpiredEventKind,
+		CustomData: map[string]interface{}{
+			"role":      role.Name,
+			"context":   role.ContextValue,
+			"expiresAt": role.ExpiresAt,
+		},
+		Allowed: event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, u.Email)),
+	})
+	if _, locked := err.(event.ErrEventLocked); locked {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var before, after []string
+	defer func() { evt.DoneCustomData(err, permissionChangeData(before, after)) }()
+	before, err = userPermissionNames(u)
+	if err != nil {
+		return err
+	}
+	err = runWithPermSync([]auth.User{*u}, func() error {
+		return u.RemoveExpiredRole(role)
+	})
+	if err != nil {
+		return err
+	}
+	after, err = userPermissionNames(u)
+	return err
+}
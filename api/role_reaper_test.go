@@ -0,0 +1,73 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestReapExpiredRoles(c *check.C) {
+	_, err := permission.NewRole("oncall", "global", "")
+	c.Assert(err, check.IsNil)
+	u := auth.User{Email: "oncall@tsuru.io", Password: "123456"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRole("oncall", "")
+	c.Assert(err, check.IsNil)
+	expiresAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+	err = u.AddRoleWithExpiration("oncall", "", expiresAt)
+	c.Assert(err, check.IsNil)
+	other := auth.User{Email: "other@tsuru.io", Password: "123456"}
+	err = other.Create()
+	c.Assert(err, check.IsNil)
+	err = other.AddRoleWithExpiration("oncall", "", time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	err = reapExpiredRoles(time.Now())
+	c.Assert(err, check.IsNil)
+	dbUser, err := auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbUser.Roles, check.DeepEquals, []auth.RoleInstance{{Name: "oncall"}})
+	dbUser, err = auth.GetUserByEmail(other.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbUser.Roles, check.HasLen, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeUser, Value: u.Email},
+		Kind:   roleExpiredEventKind,
+		StartCustomData: map[string]interface{}{
+			"role":      "oncall",
+			"context":   "",
+			"expiresAt": expiresAt,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestReapExpiredRolesSkipsLockedUsers(c *check.C) {
+	_, err := permission.NewRole("oncall", "global", "")
+	c.Assert(err, check.IsNil)
+	u := auth.User{Email: "oncall@tsuru.io", Password: "123456"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRoleWithExpiration("oncall", "", time.Now().Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeUser, Value: u.Email},
+		Kind:    permission.PermUserUpdate,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermUserReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	err = reapExpiredRoles(time.Now())
+	c.Assert(err, check.IsNil)
+	dbUser, err := auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbUser.Roles, check.HasLen, 1)
+}
@@ -0,0 +1,880 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// SCIM 2.0 (RFC 7643 and RFC 7644) resources are mapped to tsuru users and
+// teams. Users are identified by their email, and groups by the name of the
+// team. Members of a group are the users with the role set in
+// scim:team-member-role assigned in the context of the team.
+
+const (
+	scimUserSchema      = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema     = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema      = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema     = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimPatchSchema     = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimContentType     = "application/scim+json"
+	defaultSCIMTeamRole = "team-member"
+)
+
+var scimFilterRegexp = regexp.MustCompile(`^\s*(\w+)\s+(?i:eq)\s+"([^"]*)"\s*$`)
+
+type scimValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type scimUser struct {
+	Schemas  []string    `json:"schemas"`
+	ID       string      `json:"id,omitempty"`
+	UserName string      `json:"userName"`
+	Active   *bool       `json:"active,omitempty"`
+	Emails   []scimValue `json:"emails,omitempty"`
+	Groups   []scimValue `json:"groups,omitempty"`
+	Meta     *scimMeta   `json:"meta,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []scimValue `json:"members"`
+	Meta        *scimMeta   `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type scimPatch struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+type scimError struct {
+	Schemas []string `json:"schemas"`
+	Status  string   `json:"status"`
+	Detail  string   `json:"detail"`
+}
+
+// scimHandler writes the errors returned by the handler in the format of
+// SCIM errors, which identity platforms expect instead of plain text.
+func scimHandler(fn AuthorizationRequiredHandler) AuthorizationRequiredHandler {
+	return func(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+		err := fn(w, r, t)
+		if err == nil {
+			return nil
+		}
+		httpErr := toHTTPError(err)
+		log.Errorf("failure running SCIM request %s %s (%d): %s", r.Method, r.URL.Path, httpErr.Code, err)
+		w.Header().Set("Content-Type", scimContentType)
+		w.WriteHeader(httpErr.Code)
+		return json.NewEncoder(w).Encode(scimError{
+			Schemas: []string{scimErrorSchema},
+			Status:  strconv.Itoa(httpErr.Code),
+			Detail:  httpErr.Message,
+		})
+	}
+}
+
+func writeSCIM(w http.ResponseWriter, code int, data interface{}) error {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(data)
+}
+
+func decodeSCIM(r *http.Request, data interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse request body: %s", err)}
+	}
+	return nil
+}
+
+func scimTeamMemberRole() string {
+	role, _ := config.GetString("scim:team-member-role")
+	if role == "" {
+		role = defaultSCIMTeamRole
+	}
+	return role
+}
+
+// scimFilter returns the value compared with the given attribute in the
+// filter query parameter. Only the eq operator is supported, as it's the one
+// used by identity platforms to look up resources.
+func scimFilter(r *http.Request, attribute string) (string, bool, error) {
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		return "", false, nil
+	}
+	parts := scimFilterRegexp.FindStringSubmatch(filter)
+	if parts == nil || !strings.EqualFold(parts[1], attribute) {
+		return "", false, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unsupported filter %q, only %s eq is supported", filter, attribute)}
+	}
+	return parts[2], true, nil
+}
+
+// scimPage returns the page of resources selected by the startIndex and
+// count query parameters.
+func scimPage(r *http.Request, resources []interface{}) scimListResponse {
+	start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if start < 1 {
+		start = 1
+	}
+	page := []interface{}{}
+	if start <= len(resources) {
+		page = resources[start-1:]
+	}
+	if count, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && count >= 0 && count < len(page) {
+		page = page[:count]
+	}
+	return scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: len(resources),
+		StartIndex:   start,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}
+}
+
+func toSCIMUser(u *auth.User) scimUser {
+	active := !u.Suspended
+	role := scimTeamMemberRole()
+	groups := []scimValue{}
+	for _, roleInstance := range u.Roles {
+		if roleInstance.Name == role {
+			groups = append(groups, scimValue{Value: roleInstance.ContextValue, Display: roleInstance.ContextValue})
+		}
+	}
+	return scimUser{
+		Schemas:  []string{scimUserSchema},
+		ID:       u.Email,
+		UserName: u.Email,
+		Active:   &active,
+		Emails:   []scimValue{{Value: u.Email, Primary: true}},
+		Groups:   groups,
+		Meta:     &scimMeta{ResourceType: "User", Location: "/scim/v2/Users/" + u.Email},
+	}
+}
+
+func toSCIMGroup(team *auth.Team) (scimGroup, error) {
+	users, err := auth.ListUsersWithRoleInContext(scimTeamMemberRole(), team.Name)
+	if err != nil {
+		return scimGroup{}, err
+	}
+	members := make([]scimValue, len(users))
+	for i, u := range users {
+		members[i] = scimValue{Value: u.Email, Display: u.Email}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Value < members[j].Value })
+	return scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          team.Name,
+		DisplayName: team.Name,
+		Members:     members,
+		Meta:        &scimMeta{ResourceType: "Group", Location: "/scim/v2/Groups/" + team.Name},
+	}, nil
+}
+
+func scimGetUser(email string) (*auth.User, error) {
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("User %q not found.", email)}
+	}
+	return u, nil
+}
+
+func scimGetTeam(name string) (*auth.Team, error) {
+	team, err := auth.GetTeam(name)
+	if err != nil {
+		if err == auth.ErrTeamNotFound {
+			return nil, &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("Group %q not found.", name)}
+		}
+		return nil, err
+	}
+	return team, nil
+}
+
+// setSCIMUserActive suspends or activates the user, as requested by the
+// active attribute of SCIM users.
+func setSCIMUserActive(u *auth.User, active bool) error {
+	if active == !u.Suspended {
+		return nil
+	}
+	if active {
+		return runWithPermSync([]auth.User{*u}, u.Activate)
+	}
+	return runWithPermSync([]auth.User{*u}, u.Suspend)
+}
+
+func scimRandomPassword() (string, error) {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// title: scim user list
+// path: /scim/v2/Users
+// method: GET
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   400: Invalid filter
+//   401: Unauthorized
+func scimUserList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermScimUsers) {
+		return permission.ErrUnauthorized
+	}
+	userName, filtered, err := scimFilter(r, "userName")
+	if err != nil {
+		return err
+	}
+	var users []auth.User
+	if filtered {
+		var u *auth.User
+		u, err = auth.GetUserByEmail(userName)
+		if err == nil {
+			users = append(users, *u)
+		}
+	} else {
+		users, err = auth.ListUsers()
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	resources := make([]interface{}, len(users))
+	for i := range users {
+		resources[i] = toSCIMUser(&users[i])
+	}
+	return writeSCIM(w, http.StatusOK, scimPage(r, resources))
+}
+
+// title: scim user info
+// path: /scim/v2/Users/{id}
+// method: GET
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func scimUserInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermScimUsers) {
+		return permission.ErrUnauthorized
+	}
+	u, err := scimGetUser(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, toSCIMUser(u))
+}
+
+// title: scim user create
+// path: /scim/v2/Users
+// method: POST
+// consume: application/scim+json
+// produce: application/scim+json
+// responses:
+//   201: User created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: User already exists
+func scimUserCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimUsers) {
+		return permission.ErrUnauthorized
+	}
+	var data scimUser
+	err = decodeSCIM(r, &data)
+	if err != nil {
+		return err
+	}
+	email := data.UserName
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(email),
+		Kind:       permission.PermScimUsers,
+		Owner:      t,
+		CustomData: data,
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	u := &auth.User{Email: email}
+	// Users created by identity platforms log in through them, native
+	// users must reset the random password to log in with a password.
+	if _, ok := app.AuthScheme.(auth.ManagedScheme); ok {
+		u.Password, err = scimRandomPassword()
+		if err != nil {
+			return err
+		}
+	}
+	_, err = app.AuthScheme.Create(u)
+	if err != nil {
+		return handleAuthError(err)
+	}
+	u, err = auth.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if data.Active != nil && !*data.Active {
+		err = setSCIMUserActive(u, false)
+		if err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Location", "/scim/v2/Users/"+u.Email)
+	return writeSCIM(w, http.StatusCreated, toSCIMUser(u))
+}
+
+// title: scim user replace
+// path: /scim/v2/Users/{id}
+// method: PUT
+// consume: application/scim+json
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func scimUserReplace(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimUsers) {
+		return permission.ErrUnauthorized
+	}
+	var data scimUser
+	err = decodeSCIM(r, &data)
+	if err != nil {
+		return err
+	}
+	active := data.Active == nil || *data.Active
+	return scimUpdateUser(w, r, t, data, func(u *auth.User) error {
+		return setSCIMUserActive(u, active)
+	})
+}
+
+// title: scim user patch
+// path: /scim/v2/Users/{id}
+// method: PATCH
+// consume: application/scim+json
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func scimUserPatch(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimUsers) {
+		return permission.ErrUnauthorized
+	}
+	var patch scimPatch
+	err = decodeSCIM(r, &patch)
+	if err != nil {
+		return err
+	}
+	var active *bool
+	for _, op := range patch.Operations {
+		if !strings.EqualFold(op.Op, "replace") && !strings.EqualFold(op.Op, "add") {
+			continue
+		}
+		value := op.Value
+		if op.Path == "" {
+			// Some platforms send the attributes in the value instead
+			// of using a path.
+			var attrs map[string]json.RawMessage
+			if json.Unmarshal(op.Value, &attrs) != nil {
+				continue
+			}
+			if value = attrs["active"]; value == nil {
+				continue
+			}
+		} else if !strings.EqualFold(op.Path, "active") {
+			continue
+		}
+		var v bool
+		v, err = parseSCIMBool(value)
+		if err != nil {
+			return err
+		}
+		active = &v
+	}
+	return scimUpdateUser(w, r, t, patch, func(u *auth.User) error {
+		if active == nil {
+			return nil
+		}
+		return setSCIMUserActive(u, *active)
+	})
+}
+
+// parseSCIMBool parses boolean values, also accepting the strings sent by
+// some identity platforms.
+func parseSCIMBool(data json.RawMessage) (bool, error) {
+	var v bool
+	if json.Unmarshal(data, &v) == nil {
+		return v, nil
+	}
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v, nil
+		}
+	}
+	return false, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid value for active: %s", data)}
+}
+
+func scimUpdateUser(w http.ResponseWriter, r *http.Request, t auth.Token, data interface{}, update func(*auth.User) error) (err error) {
+	u, err := scimGetUser(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(u.Email),
+		Kind:       permission.PermScimUsers,
+		Owner:      t,
+		CustomData: data,
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, u.Email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = update(u)
+	if err != nil {
+		return err
+	}
+	u, err = auth.GetUserByEmail(u.Email)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, toSCIMUser(u))
+}
+
+// title: scim user delete
+// path: /scim/v2/Users/{id}
+// method: DELETE
+// responses:
+//   204: User removed
+//   401: Unauthorized
+//   404: Not found
+func scimUserDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimUsers) {
+		return permission.ErrUnauthorized
+	}
+	u, err := scimGetUser(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:  userTarget(u.Email),
+		Kind:    permission.PermScimUsers,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, u.Email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = deleteUser(u)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// title: scim group list
+// path: /scim/v2/Groups
+// method: GET
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   400: Invalid filter
+//   401: Unauthorized
+func scimGroupList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermScimGroups) {
+		return permission.ErrUnauthorized
+	}
+	name, filtered, err := scimFilter(r, "displayName")
+	if err != nil {
+		return err
+	}
+	var teams []auth.Team
+	if filtered {
+		var team *auth.Team
+		team, err = auth.GetTeam(name)
+		if err == nil {
+			teams = append(teams, *team)
+		}
+	} else {
+		teams, err = auth.ListTeams()
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	resources := make([]interface{}, len(teams))
+	for i := range teams {
+		resources[i], err = toSCIMGroup(&teams[i])
+		if err != nil {
+			return err
+		}
+	}
+	return writeSCIM(w, http.StatusOK, scimPage(r, resources))
+}
+
+// title: scim group info
+// path: /scim/v2/Groups/{id}
+// method: GET
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func scimGroupInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermScimGroups) {
+		return permission.ErrUnauthorized
+	}
+	team, err := scimGetTeam(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	group, err := toSCIMGroup(team)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, group)
+}
+
+// title: scim group create
+// path: /scim/v2/Groups
+// method: POST
+// consume: application/scim+json
+// produce: application/scim+json
+// responses:
+//   201: Group created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Group already exists
+func scimGroupCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimGroups) {
+		return permission.ErrUnauthorized
+	}
+	var data scimGroup
+	err = decodeSCIM(r, &data)
+	if err != nil {
+		return err
+	}
+	name := data.DisplayName
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermScimGroups,
+		Owner:      t,
+		CustomData: data,
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	err = auth.CreateTeam(name, u)
+	switch err {
+	case auth.ErrInvalidTeamName:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case auth.ErrTeamAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	err = setSCIMGroupMembers(name, nil, scimMemberEmails(data.Members))
+	if err != nil {
+		return err
+	}
+	team, err := auth.GetTeam(name)
+	if err != nil {
+		return err
+	}
+	group, err := toSCIMGroup(team)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", group.Meta.Location)
+	return writeSCIM(w, http.StatusCreated, group)
+}
+
+// title: scim group replace
+// path: /scim/v2/Groups/{id}
+// method: PUT
+// consume: application/scim+json
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func scimGroupReplace(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimGroups) {
+		return permission.ErrUnauthorized
+	}
+	var data scimGroup
+	err = decodeSCIM(r, &data)
+	if err != nil {
+		return err
+	}
+	return scimUpdateGroup(w, r, t, data, func(group *scimGroup) ([]string, []string, error) {
+		current := scimMemberEmails(group.Members)
+		wanted := scimMemberEmails(data.Members)
+		return difference(wanted, current), difference(current, wanted), nil
+	})
+}
+
+var scimMemberPathRegexp = regexp.MustCompile(`^members\[\s*value\s+(?i:eq)\s+"([^"]*)"\s*\]$`)
+
+// title: scim group patch
+// path: /scim/v2/Groups/{id}
+// method: PATCH
+// consume: application/scim+json
+// produce: application/scim+json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func scimGroupPatch(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimGroups) {
+		return permission.ErrUnauthorized
+	}
+	var patch scimPatch
+	err = decodeSCIM(r, &patch)
+	if err != nil {
+		return err
+	}
+	return scimUpdateGroup(w, r, t, patch, func(group *scimGroup) ([]string, []string, error) {
+		members := map[string]bool{}
+		for _, email := range scimMemberEmails(group.Members) {
+			members[email] = true
+		}
+		for _, op := range patch.Operations {
+			var values []scimValue
+			if len(op.Value) > 0 && json.Unmarshal(op.Value, &values) != nil {
+				return nil, nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid value for members: %s", op.Value)}
+			}
+			if parts := scimMemberPathRegexp.FindStringSubmatch(op.Path); parts != nil {
+				values = append(values, scimValue{Value: parts[1]})
+			} else if !strings.EqualFold(op.Path, "members") {
+				continue
+			}
+			switch strings.ToLower(op.Op) {
+			case "add":
+				for _, v := range values {
+					members[v.Value] = true
+				}
+			case "remove":
+				if len(values) == 0 {
+					members = map[string]bool{}
+				}
+				for _, v := range values {
+					delete(members, v.Value)
+				}
+			case "replace":
+				members = map[string]bool{}
+				for _, v := range values {
+					members[v.Value] = true
+				}
+			default:
+				return nil, nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unsupported operation %q", op.Op)}
+			}
+		}
+		var wanted []string
+		for email := range members {
+			wanted = append(wanted, email)
+		}
+		current := scimMemberEmails(group.Members)
+		return difference(wanted, current), difference(current, wanted), nil
+	})
+}
+
+// scimUpdateGroup changes the members of the group, adding and removing the
+// members returned by changes.
+func scimUpdateGroup(w http.ResponseWriter, r *http.Request, t auth.Token, data interface{}, changes func(*scimGroup) ([]string, []string, error)) (err error) {
+	team, err := scimGetTeam(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	group, err := toSCIMGroup(team)
+	if err != nil {
+		return err
+	}
+	added, removed, err := changes(&group)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(team.Name),
+		Kind:       permission.PermScimGroups,
+		Owner:      t,
+		CustomData: data,
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, team.Name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = setSCIMGroupMembers(team.Name, removed, added)
+	if err != nil {
+		return err
+	}
+	group, err = toSCIMGroup(team)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, group)
+}
+
+// setSCIMGroupMembers assigns the team member role, in the context of the
+// team, to the added users and dissociates it from the removed users.
+func setSCIMGroupMembers(teamName string, removed, added []string) error {
+	role := scimTeamMemberRole()
+	if len(added) > 0 {
+		_, err := permission.FindRole(role)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to find the team member role %q: %s", role, err)}
+		}
+	}
+	var users []auth.User
+	for _, email := range append(added, removed...) {
+		u, err := scimGetUser(email)
+		if err != nil {
+			return err
+		}
+		users = append(users, *u)
+	}
+	return runWithPermSync(users, func() error {
+		for i := range users {
+			var err error
+			if i < len(added) {
+				err = users[i].AddRole(role, teamName)
+			} else {
+				err = users[i].RemoveRole(role, teamName)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func scimMemberEmails(members []scimValue) []string {
+	emails := make([]string, len(members))
+	for i, m := range members {
+		emails[i] = m.Value
+	}
+	return emails
+}
+
+// difference returns the values of a not in b.
+func difference(a, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, v := range b {
+		set[v] = true
+	}
+	var result []string
+	for _, v := range a {
+		if !set[v] {
+			result = append(result, v)
+			set[v] = true
+		}
+	}
+	return result
+}
+
+// title: scim group delete
+// path: /scim/v2/Groups/{id}
+// method: DELETE
+// responses:
+//   204: Group removed
+//   401: Unauthorized
+//   403: Team still used
+//   404: Not found
+func scimGroupDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermScimGroups) {
+		return permission.ErrUnauthorized
+	}
+	team, err := scimGetTeam(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:  teamTarget(team.Name),
+		Kind:    permission.PermScimGroups,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, team.Name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	group, err := toSCIMGroup(team)
+	if err != nil {
+		return err
+	}
+	err = auth.RemoveTeam(team.Name)
+	if err != nil {
+		if _, ok := err.(*auth.ErrTeamStillUsed); ok {
+			msg := fmt.Sprintf("This team cannot be removed because there are still references to it:\n%s", err)
+			return &errors.HTTP{Code: http.StatusForbidden, Message: msg}
+		}
+		return err
+	}
+	err = setSCIMGroupMembers(team.Name, scimMemberEmails(group.Members), nil)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
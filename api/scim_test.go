@@ -0,0 +1,248 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) scimRequest(c *check.C, method, path, body string, token auth.Token) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(method, path, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", scimContentType)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *S) scimToken(c *check.C) auth.Token {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "idp", permission.Permission{
+		Scheme:  permission.PermScim,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	return token
+}
+
+func (s *S) TestSCIMUserCreate(c *check.C) {
+	token := s.scimToken(c)
+	body := `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "ana@tsuru.io", "active": true}`
+	recorder := s.scimRequest(c, "POST", "/scim/v2/Users", body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, scimContentType)
+	c.Assert(recorder.Header().Get("Location"), check.Equals, "/scim/v2/Users/ana@tsuru.io")
+	var result scimUser
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ID, check.Equals, "ana@tsuru.io")
+	c.Assert(*result.Active, check.Equals, true)
+	u, err := auth.GetUserByEmail("ana@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Password, check.Not(check.Equals), "")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeUser, Value: "ana@tsuru.io"},
+		Owner:  token.GetUserName(),
+		Kind:   "scim.users",
+	}, eventtest.HasEvent)
+	recorder = s.scimRequest(c, "POST", "/scim/v2/Users", body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	var scimErr scimError
+	err = json.Unmarshal(recorder.Body.Bytes(), &scimErr)
+	c.Assert(err, check.IsNil)
+	c.Assert(scimErr, check.DeepEquals, scimError{
+		Schemas: []string{scimErrorSchema},
+		Status:  "409",
+		Detail:  native.ErrEmailRegistered.Error(),
+	})
+}
+
+func (s *S) TestSCIMUserCreateInactive(c *check.C) {
+	body := `{"userName": "ana@tsuru.io", "active": false}`
+	recorder := s.scimRequest(c, "POST", "/scim/v2/Users", body, s.scimToken(c))
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	u, err := auth.GetUserByEmail("ana@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Suspended, check.Equals, true)
+}
+
+func (s *S) TestSCIMUserCreateUnauthorized(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "nobody")
+	recorder := s.scimRequest(c, "POST", "/scim/v2/Users", `{"userName": "ana@tsuru.io"}`, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err := auth.GetUserByEmail("ana@tsuru.io")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (s *S) TestSCIMUserListFilter(c *check.C) {
+	token := s.scimToken(c)
+	recorder := s.scimRequest(c, "GET", "/scim/v2/Users?filter="+`userName+eq+"idp@groundcontrol.com"`, "", token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result struct {
+		TotalResults int
+		Resources    []scimUser
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.TotalResults, check.Equals, 1)
+	c.Assert(result.Resources[0].UserName, check.Equals, "idp@groundcontrol.com")
+	recorder = s.scimRequest(c, "GET", "/scim/v2/Users?filter="+`userName+eq+"unknown@tsuru.io"`, "", token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.TotalResults, check.Equals, 0)
+	c.Assert(result.Resources, check.HasLen, 0)
+	recorder = s.scimRequest(c, "GET", "/scim/v2/Users?filter="+`emails+co+"tsuru"`, "", token)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestSCIMUserListPagination(c *check.C) {
+	recorder := s.scimRequest(c, "GET", "/scim/v2/Users?startIndex=2&count=1", "", s.scimToken(c))
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result scimListResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.TotalResults, check.Equals, 2)
+	c.Assert(result.StartIndex, check.Equals, 2)
+	c.Assert(result.ItemsPerPage, check.Equals, 1)
+	c.Assert(result.Resources[0].(map[string]interface{})["userName"], check.Equals, "super-root-toremove@groundcontrol.com")
+}
+
+func (s *S) TestSCIMUserInfoNotFound(c *check.C) {
+	recorder := s.scimRequest(c, "GET", "/scim/v2/Users/unknown@tsuru.io", "", s.scimToken(c))
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, scimContentType)
+}
+
+func (s *S) TestSCIMUserPatchActive(c *check.C) {
+	token := s.scimToken(c)
+	u, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "ana")
+	body := `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "Replace", "path": "active", "value": "False"}]}`
+	recorder := s.scimRequest(c, "PATCH", "/scim/v2/Users/"+u.Email, body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	u, err := auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Suspended, check.Equals, true)
+	body = `{"Operations": [{"op": "replace", "value": {"active": true}}]}`
+	recorder = s.scimRequest(c, "PATCH", "/scim/v2/Users/"+u.Email, body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result scimUser
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(*result.Active, check.Equals, true)
+	body = `{"Operations": [{"op": "replace", "path": "active", "value": "maybe"}]}`
+	recorder = s.scimRequest(c, "PATCH", "/scim/v2/Users/"+u.Email, body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestSCIMUserDelete(c *check.C) {
+	u, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "ana")
+	recorder := s.scimRequest(c, "DELETE", "/scim/v2/Users/"+u.Email, "", s.scimToken(c))
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	_, err := auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (s *S) TestSCIMGroupCreate(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	token := s.scimToken(c)
+	u, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "ana")
+	body := `{"displayName": "squad", "members": [{"value": "` + u.Email + `"}]}`
+	recorder := s.scimRequest(c, "POST", "/scim/v2/Groups", body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var result scimGroup
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ID, check.Equals, "squad")
+	c.Assert(result.Members, check.DeepEquals, []scimValue{{Value: u.Email, Display: u.Email}})
+	_, err = auth.GetTeam("squad")
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeTeam, Value: "squad"},
+		Owner:  token.GetUserName(),
+		Kind:   "scim.groups",
+	}, eventtest.HasEvent)
+	recorder = s.scimRequest(c, "POST", "/scim/v2/Groups", body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestSCIMGroupPatchMembers(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	token := s.scimToken(c)
+	ana, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "ana")
+	bob, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "bob")
+	body := `{"Operations": [{"op": "add", "path": "members", "value": [{"value": "` + ana.Email + `"}, {"value": "` + bob.Email + `"}]}]}`
+	recorder := s.scimRequest(c, "PATCH", "/scim/v2/Groups/"+s.team.Name, body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	users, err := auth.ListUsersWithRoleInContext("team-member", s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 2)
+	body = `{"Operations": [{"op": "remove", "path": "members[value eq \"` + ana.Email + `\"]"}]}`
+	recorder = s.scimRequest(c, "PATCH", "/scim/v2/Groups/"+s.team.Name, body, token)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result scimGroup
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Members, check.DeepEquals, []scimValue{{Value: bob.Email, Display: bob.Email}})
+	ana, err = auth.GetUserByEmail(ana.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(ana.Roles, check.HasLen, 0)
+}
+
+func (s *S) TestSCIMGroupReplaceMembers(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	ana, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "ana")
+	err = ana.AddRole("team-member", s.team.Name)
+	c.Assert(err, check.IsNil)
+	bob, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "bob")
+	body := `{"displayName": "tsuruteam", "members": [{"value": "` + bob.Email + `"}]}`
+	recorder := s.scimRequest(c, "PUT", "/scim/v2/Groups/"+s.team.Name, body, s.scimToken(c))
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	users, err := auth.ListUsersWithRoleInContext("team-member", s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 1)
+	c.Assert(users[0].Email, check.Equals, bob.Email)
+}
+
+func (s *S) TestSCIMGroupPatchMemberNotFound(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	body := `{"Operations": [{"op": "add", "path": "members", "value": [{"value": "unknown@tsuru.io"}]}]}`
+	recorder := s.scimRequest(c, "PATCH", "/scim/v2/Groups/"+s.team.Name, body, s.scimToken(c))
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestSCIMGroupDelete(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	err = auth.CreateTeam("squad", s.user)
+	c.Assert(err, check.IsNil)
+	ana, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "ana")
+	err = ana.AddRole("team-member", "squad")
+	c.Assert(err, check.IsNil)
+	token := s.scimToken(c)
+	recorder := s.scimRequest(c, "DELETE", "/scim/v2/Groups/squad", "", token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	_, err = auth.GetTeam("squad")
+	c.Assert(err, check.Equals, auth.ErrTeamNotFound)
+	ana, err = auth.GetUserByEmail(ana.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(ana.Roles, check.HasLen, 0)
+	recorder = s.scimRequest(c, "DELETE", "/scim/v2/Groups/squad", "", token)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -26,6 +26,9 @@ import (
 	_ "github.com/tsuru/tsuru/auth/saml"
 	"github.com/tsuru/tsuru/autoscale"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event/export"
+	"github.com/tsuru/tsuru/event/notify"
+	"github.com/tsuru/tsuru/event/scheduler"
 	"github.com/tsuru/tsuru/hc"
 	"github.com/tsuru/tsuru/healer"
 	"github.com/tsuru/tsuru/log"
@@ -33,6 +36,7 @@ import (
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
+	"github.com/tsuru/tsuru/storageusage"
 	"golang.org/x/net/websocket"
 	"gopkg.in/tylerb/graceful.v1"
 )
@@ -109,6 +113,10 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Delete", "/services/{service}/instances/{instance}", AuthorizationRequiredHandler(removeServiceInstance))
 	m.Add("1.0", "Post", "/services/{service}/instances", AuthorizationRequiredHandler(createServiceInstance))
 	m.Add("1.0", "Put", "/services/{service}/instances/{instance}", AuthorizationRequiredHandler(updateServiceInstance))
+	m.Add("1.3", "Get", "/services/{service}/instances/{instance}/backups", AuthorizationRequiredHandler(serviceInstanceBackupList))
+	m.Add("1.3", "Post", "/services/{service}/instances/{instance}/backups", AuthorizationRequiredHandler(serviceInstanceBackupCreate))
+	m.Add("1.3", "Post", "/services/{service}/instances/{instance}/backups/{backup}/restore", AuthorizationRequiredHandler(serviceInstanceBackupRestore))
+	m.Add("1.3", "Put", "/services/{service}/instances/{instance}/backup-policy", AuthorizationRequiredHandler(serviceInstanceBackupPolicySet))
 	m.Add("1.0", "Put", "/services/{service}/instances/{instance}/{app}", AuthorizationRequiredHandler(bindServiceInstance))
 	m.Add("1.0", "Delete", "/services/{service}/instances/{instance}/{app}", AuthorizationRequiredHandler(unbindServiceInstance))
 	m.Add("1.0", "Get", "/services/{service}/instances/{instance}/status", AuthorizationRequiredHandler(serviceInstanceStatus))
@@ -135,6 +143,7 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Delete", "/apps/{app}/cname", AuthorizationRequiredHandler(unsetCName))
 	runHandler := AuthorizationRequiredHandler(runCommand)
 	m.Add("1.0", "Post", "/apps/{app}/run", runHandler)
+	m.Add("1.3", "Get", "/apps/{app}/runs/{id}", AuthorizationRequiredHandler(appRunInfo))
 	m.Add("1.0", "Post", "/apps/{app}/restart", AuthorizationRequiredHandler(restart))
 	m.Add("1.0", "Post", "/apps/{app}/start", AuthorizationRequiredHandler(start))
 	m.Add("1.0", "Post", "/apps/{app}/stop", AuthorizationRequiredHandler(stop))
@@ -145,6 +154,9 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}/env", AuthorizationRequiredHandler(getEnv))
 	m.Add("1.0", "Post", "/apps/{app}/env", AuthorizationRequiredHandler(setEnv))
 	m.Add("1.0", "Delete", "/apps/{app}/env", AuthorizationRequiredHandler(unsetEnv))
+	m.Add("1.3", "Get", "/apps/{app}/env/revisions", AuthorizationRequiredHandler(envRevisionList))
+	m.Add("1.3", "Get", "/apps/{app}/env/diff", AuthorizationRequiredHandler(envRevisionDiff))
+	m.Add("1.3", "Post", "/apps/{app}/env/revisions/{version}/restore", AuthorizationRequiredHandler(envRevisionRestore))
 	m.Add("1.0", "Get", "/apps", AuthorizationRequiredHandler(appList))
 	m.Add("1.0", "Post", "/apps", AuthorizationRequiredHandler(createApp))
 	forceDeleteLockHandler := AuthorizationRequiredHandler(forceDeleteLock)
@@ -160,26 +172,83 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}/log", AuthorizationRequiredHandler(appLog))
 	logPostHandler := AuthorizationRequiredHandler(addLog)
 	m.Add("1.0", "Post", "/apps/{app}/log", logPostHandler)
+	m.Add("1.3", "Get", "/apps/{app}/log-drains", AuthorizationRequiredHandler(appLogDrainList))
+	m.Add("1.3", "Post", "/apps/{app}/log-drains", AuthorizationRequiredHandler(appLogDrainAdd))
+	m.Add("1.3", "Delete", "/apps/{app}/log-drains/{drain}", AuthorizationRequiredHandler(appLogDrainRemove))
+	m.Add("1.3", "Get", "/apps/{app}/dependencies", AuthorizationRequiredHandler(appDependencyList))
+	m.Add("1.3", "Post", "/apps/{app}/dependencies", AuthorizationRequiredHandler(appDependencyAdd))
+	m.Add("1.3", "Delete", "/apps/{app}/dependencies", AuthorizationRequiredHandler(appDependencyRemove))
+	m.Add("1.3", "Get", "/apps/{app}/dependencies/graph", AuthorizationRequiredHandler(appDependencyGraph))
+	m.Add("1.3", "Post", "/apps/{app}/clone", AuthorizationRequiredHandler(appClone))
+	m.Add("1.3", "Get", "/apps/{app}/review-apps", AuthorizationRequiredHandler(reviewAppList))
+	m.Add("1.3", "Put", "/apps/{app}/review-apps", AuthorizationRequiredHandler(reviewAppsEnable))
+	m.Add("1.3", "Delete", "/apps/{app}/review-apps", AuthorizationRequiredHandler(reviewAppsDisable))
+	reviewAppNotifyHandler := AuthorizationRequiredHandler(reviewAppNotify)
+	m.Add("1.3", "Post", "/apps/{app}/review-apps", reviewAppNotifyHandler)
+	m.Add("1.3", "Post", "/apps/{app}/maintenance", AuthorizationRequiredHandler(appMaintenanceStart))
+	m.Add("1.3", "Delete", "/apps/{app}/maintenance", AuthorizationRequiredHandler(appMaintenanceEnd))
 	m.Add("1.0", "Post", "/apps/{appname}/deploy/rollback", AuthorizationRequiredHandler(deployRollback))
+	m.Add("1.3", "Get", "/apps/{appname}/deploy/rollback", AuthorizationRequiredHandler(deployRollbackImages))
 	m.Add("1.3", "Post", "/apps/{appname}/deploy/rebuild", AuthorizationRequiredHandler(deployRebuild))
+	m.Add("1.3", "Get", "/apps/{appname}/deploy/pause", AuthorizationRequiredHandler(deployPauseInfo))
+	m.Add("1.3", "Delete", "/apps/{appname}/deploy/pause", AuthorizationRequiredHandler(deployResume))
+	m.Add("1.3", "Post", "/apps/{appname}/deploy/chunks/missing", AuthorizationRequiredHandler(deployChunksMissing))
+	m.Add("1.3", "Put", "/apps/{appname}/deploy/chunks/{hash}", AuthorizationRequiredHandler(deployChunkUpload))
 	m.Add("1.0", "Get", "/apps/{app}/metric/envs", AuthorizationRequiredHandler(appMetricEnvs))
+	m.Add("1.3", "Get", "/apps/{app}/metrics", AuthorizationRequiredHandler(appMetrics))
 	m.Add("1.0", "Post", "/apps/{app}/routes", AuthorizationRequiredHandler(appRebuildRoutes))
 	m.Add("1.2", "Get", "/apps/{app}/certificate", AuthorizationRequiredHandler(listCertificates))
 	m.Add("1.2", "Put", "/apps/{app}/certificate", AuthorizationRequiredHandler(setCertificate))
 	m.Add("1.2", "Delete", "/apps/{app}/certificate", AuthorizationRequiredHandler(unsetCertificate))
+	m.Add("1.3", "Get", "/apps/{app}/changelog", AuthorizationRequiredHandler(appChangelog))
+	m.Add("1.3", "Get", "/apps/{app}/build-cache", AuthorizationRequiredHandler(appBuildCacheInfo))
+	m.Add("1.3", "Put", "/apps/{app}/build-cache", AuthorizationRequiredHandler(appBuildCacheSet))
+	m.Add("1.3", "Delete", "/apps/{app}/build-cache", AuthorizationRequiredHandler(appBuildCacheClear))
+	m.Add("1.3", "Put", "/apps/{app}/ratelimit", AuthorizationRequiredHandler(appRateLimitSet))
+	m.Add("1.3", "Put", "/apps/{app}/deploy-approval", AuthorizationRequiredHandler(appDeployApprovalSet))
+	m.Add("1.3", "Put", "/apps/{app}/processes/{process}/plan", AuthorizationRequiredHandler(appProcessPlanSet))
+	m.Add("1.3", "Get", "/apps/{app}/healthcheck", AuthorizationRequiredHandler(appHealthcheckInfo))
+	m.Add("1.3", "Put", "/apps/{app}/healthcheck", AuthorizationRequiredHandler(appHealthcheckSet))
+	m.Add("1.3", "Get", "/apps/{app}/jobs", AuthorizationRequiredHandler(jobList))
+	m.Add("1.3", "Post", "/apps/{app}/jobs", AuthorizationRequiredHandler(jobCreate))
+	m.Add("1.3", "Delete", "/apps/{app}/jobs/{job}", AuthorizationRequiredHandler(jobRemove))
+	m.Add("1.3", "Post", "/apps/{app}/jobs/{job}/trigger", AuthorizationRequiredHandler(jobTrigger))
+	m.Add("1.3", "Get", "/apps/{app}/jobs/{job}/runs", AuthorizationRequiredHandler(jobRunList))
 
 	m.Add("1.0", "Post", "/node/status", AuthorizationRequiredHandler(setNodeStatus))
 
 	m.Add("1.0", "Get", "/deploys", AuthorizationRequiredHandler(deploysList))
+	m.Add("1.3", "Get", "/deploys/unsigned", AuthorizationRequiredHandler(unsignedDeploysReport))
+	m.Add("1.3", "Get", "/deploys/timings", AuthorizationRequiredHandler(deployTimingStats))
 	m.Add("1.0", "Get", "/deploys/{deploy}", AuthorizationRequiredHandler(deployInfo))
 
 	m.Add("1.1", "Get", "/events", AuthorizationRequiredHandler(eventList))
+	m.Add("1.3", "Delete", "/events", AuthorizationRequiredHandler(eventCancelMany))
 	m.Add("1.3", "Get", "/events/blocks", AuthorizationRequiredHandler(eventBlockList))
 	m.Add("1.3", "Post", "/events/blocks", AuthorizationRequiredHandler(eventBlockAdd))
+	m.Add("1.3", "Get", "/events/blocks/windows", AuthorizationRequiredHandler(eventBlockWindows))
 	m.Add("1.3", "Delete", "/events/blocks/{uuid}", AuthorizationRequiredHandler(eventBlockRemove))
+	m.Add("1.3", "Get", "/events/legal-holds", AuthorizationRequiredHandler(eventLegalHoldList))
+	m.Add("1.3", "Post", "/events/legal-holds", AuthorizationRequiredHandler(eventLegalHoldAdd))
+	m.Add("1.3", "Delete", "/events/legal-holds/{uuid}", AuthorizationRequiredHandler(eventLegalHoldRelease))
+	m.Add("1.3", "Post", "/events/consumers/{group}/consume", AuthorizationRequiredHandler(eventConsume))
+	m.Add("1.3", "Post", "/events/consumers/{group}/ack", AuthorizationRequiredHandler(eventAck))
+	m.Add("1.3", "Get", "/events/scheduled", AuthorizationRequiredHandler(eventScheduledList))
+	m.Add("1.3", "Delete", "/events/scheduled/{id}", AuthorizationRequiredHandler(eventScheduledCancel))
+	m.Add("1.3", "Get", "/events/notifications", AuthorizationRequiredHandler(eventNotificationList))
+	m.Add("1.3", "Post", "/events/notifications", AuthorizationRequiredHandler(eventNotificationAdd))
+	m.Add("1.3", "Delete", "/events/notifications/{id}", AuthorizationRequiredHandler(eventNotificationRemove))
 	m.Add("1.1", "Get", "/events/kinds", AuthorizationRequiredHandler(kindList))
+	m.Add("1.3", "Get", "/events/kinds/aliases", AuthorizationRequiredHandler(eventKindAliasList))
+	m.Add("1.3", "Put", "/events/kinds/aliases/{name}", AuthorizationRequiredHandler(eventKindAliasSet))
+	m.Add("1.3", "Delete", "/events/kinds/aliases/{name}", AuthorizationRequiredHandler(eventKindAliasRemove))
+	m.Add("1.3", "Get", "/events/schemas", AuthorizationRequiredHandler(eventSchemaList))
 	m.Add("1.1", "Get", "/events/{uuid}", AuthorizationRequiredHandler(eventInfo))
 	m.Add("1.1", "Post", "/events/{uuid}/cancel", AuthorizationRequiredHandler(eventCancel))
+	m.Add("1.3", "Post", "/events/{uuid}/approve", AuthorizationRequiredHandler(eventApprove))
+	m.Add("1.3", "Get", "/events/{uuid}/log/overflow", AuthorizationRequiredHandler(eventLogOverflow))
+	m.Add("1.3", "Get", "/events/{uuid}/diff/{otheruuid}", AuthorizationRequiredHandler(eventDiff))
+	m.Add("1.3", "Post", "/events/{uuid}/redrive", AuthorizationRequiredHandler(eventRedrive))
 
 	m.Add("1.0", "Get", "/platforms", AuthorizationRequiredHandler(platformList))
 	m.Add("1.0", "Post", "/platforms", AuthorizationRequiredHandler(platformAdd))
@@ -201,17 +270,28 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/users", AuthorizationRequiredHandler(listUsers))
 	m.Add("1.0", "Post", "/users", Handler(createUser))
 	m.Add("1.0", "Get", "/users/info", AuthorizationRequiredHandler(userInfo))
+	m.Add("1.3", "Get", "/users/inactive", AuthorizationRequiredHandler(inactiveUsersReport))
+	m.Add("1.3", "Get", "/users/{email}/activity", AuthorizationRequiredHandler(userActivityInfo))
+	m.Add("1.3", "Post", "/users/{email}/suspend", AuthorizationRequiredHandler(suspendUser))
+	m.Add("1.3", "Post", "/users/{email}/activate", AuthorizationRequiredHandler(activateUser))
+	m.Add("1.3", "Post", "/auth/unlock", AuthorizationRequiredHandler(unlockLogin))
 	m.Add("1.0", "Get", "/auth/scheme", Handler(authScheme))
-	m.Add("1.0", "Post", "/auth/login", Handler(login))
+	m.Add("1.3", "Get", "/auth/password-policy", Handler(passwordPolicy))
+	loginHandler := Handler(login)
+	m.Add("1.0", "Post", "/auth/login", loginHandler)
 
 	m.Add("1.0", "Post", "/auth/saml", Handler(samlCallbackLogin))
 	m.Add("1.0", "Get", "/auth/saml", Handler(samlMetadata))
 
 	m.Add("1.0", "Post", "/users/{email}/password", Handler(resetPassword))
-	m.Add("1.0", "Post", "/users/{email}/tokens", Handler(login))
+	m.Add("1.0", "Post", "/users/{email}/tokens", loginHandler)
 	m.Add("1.0", "Get", "/users/{email}/quota", AuthorizationRequiredHandler(getUserQuota))
 	m.Add("1.0", "Put", "/users/{email}/quota", AuthorizationRequiredHandler(changeUserQuota))
+	m.Add("1.3", "Get", "/quota/report", AuthorizationRequiredHandler(quotaReport))
 	m.Add("1.0", "Delete", "/users/tokens", AuthorizationRequiredHandler(logout))
+	m.Add("1.3", "Post", "/users/tokens/refresh", AuthorizationRequiredHandler(refreshToken))
+	m.Add("1.3", "Get", "/users/me/sessions", AuthorizationRequiredHandler(listSessions))
+	m.Add("1.3", "Delete", "/users/me/sessions/{id}", AuthorizationRequiredHandler(revokeSession))
 	m.Add("1.0", "Put", "/users/password", AuthorizationRequiredHandler(changePassword))
 	m.Add("1.0", "Delete", "/users", AuthorizationRequiredHandler(removeUser))
 	m.Add("1.0", "Get", "/users/keys", AuthorizationRequiredHandler(listKeys))
@@ -219,17 +299,57 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Delete", "/users/keys/{key}", AuthorizationRequiredHandler(removeKeyFromUser))
 	m.Add("1.0", "Get", "/users/api-key", AuthorizationRequiredHandler(showAPIToken))
 	m.Add("1.0", "Post", "/users/api-key", AuthorizationRequiredHandler(regenerateAPIToken))
+	m.Add("1.3", "Get", "/tokens", AuthorizationRequiredHandler(listAPITokens))
+	m.Add("1.3", "Post", "/tokens", AuthorizationRequiredHandler(createAPIToken))
+	m.Add("1.3", "Delete", "/tokens/{name}", AuthorizationRequiredHandler(revokeAPIToken))
 
 	m.Add("1.0", "Get", "/logs", websocket.Handler(addLogs))
 
 	m.Add("1.0", "Get", "/teams", AuthorizationRequiredHandler(teamList))
 	m.Add("1.0", "Post", "/teams", AuthorizationRequiredHandler(createTeam))
 	m.Add("1.0", "Delete", "/teams/{name}", AuthorizationRequiredHandler(removeTeam))
+	m.Add("1.3", "Get", "/teams/tree", AuthorizationRequiredHandler(teamTree))
+	m.Add("1.3", "Get", "/teams/{name}", AuthorizationRequiredHandler(teamInfo))
+	m.Add("1.3", "Put", "/teams/{name}/parent", AuthorizationRequiredHandler(teamParentSet))
+	m.Add("1.3", "Put", "/teams/{name}", AuthorizationRequiredHandler(renameTeam))
+	m.Add("1.3", "Get", "/teams/{name}/users", AuthorizationRequiredHandler(teamUsersExport))
+	m.Add("1.3", "Post", "/teams/{name}/users", AuthorizationRequiredHandler(teamUsersImport))
+	m.Add("1.3", "Get", "/teams/{name}/registries", AuthorizationRequiredHandler(teamRegistryList))
+	m.Add("1.3", "Put", "/teams/{name}/registries", AuthorizationRequiredHandler(teamRegistrySet))
+	m.Add("1.3", "Delete", "/teams/{name}/registries/{server}", AuthorizationRequiredHandler(teamRegistryRemove))
+	m.Add("1.3", "Get", "/teams/{name}/quota", AuthorizationRequiredHandler(getTeamQuota))
+	m.Add("1.3", "Put", "/teams/{name}/quota", AuthorizationRequiredHandler(changeTeamQuota))
+
+	m.Add("1.3", "Get", "/groups", AuthorizationRequiredHandler(groupList))
+	m.Add("1.3", "Post", "/groups", AuthorizationRequiredHandler(groupCreate))
+	m.Add("1.3", "Get", "/groups/{name}", AuthorizationRequiredHandler(groupInfo))
+	m.Add("1.3", "Delete", "/groups/{name}", AuthorizationRequiredHandler(groupRemove))
+	m.Add("1.3", "Put", "/groups/{name}/members", AuthorizationRequiredHandler(groupMemberAdd))
+	m.Add("1.3", "Delete", "/groups/{name}/members/{email}", AuthorizationRequiredHandler(groupMemberRemove))
+	m.Add("1.3", "Post", "/groups/{name}/roles", AuthorizationRequiredHandler(groupRoleAdd))
+	m.Add("1.3", "Delete", "/groups/{name}/roles/{role}", AuthorizationRequiredHandler(groupRoleRemove))
+
+	m.Add("1.3", "Get", "/scim/v2/Users", AuthorizationRequiredHandler(scimHandler(scimUserList)))
+	m.Add("1.3", "Post", "/scim/v2/Users", AuthorizationRequiredHandler(scimHandler(scimUserCreate)))
+	m.Add("1.3", "Get", "/scim/v2/Users/{id}", AuthorizationRequiredHandler(scimHandler(scimUserInfo)))
+	m.Add("1.3", "Put", "/scim/v2/Users/{id}", AuthorizationRequiredHandler(scimHandler(scimUserReplace)))
+	m.Add("1.3", "Patch", "/scim/v2/Users/{id}", AuthorizationRequiredHandler(scimHandler(scimUserPatch)))
+	m.Add("1.3", "Delete", "/scim/v2/Users/{id}", AuthorizationRequiredHandler(scimHandler(scimUserDelete)))
+	m.Add("1.3", "Get", "/scim/v2/Groups", AuthorizationRequiredHandler(scimHandler(scimGroupList)))
+	m.Add("1.3", "Post", "/scim/v2/Groups", AuthorizationRequiredHandler(scimHandler(scimGroupCreate)))
+	m.Add("1.3", "Get", "/scim/v2/Groups/{id}", AuthorizationRequiredHandler(scimHandler(scimGroupInfo)))
+	m.Add("1.3", "Put", "/scim/v2/Groups/{id}", AuthorizationRequiredHandler(scimHandler(scimGroupReplace)))
+	m.Add("1.3", "Patch", "/scim/v2/Groups/{id}", AuthorizationRequiredHandler(scimHandler(scimGroupPatch)))
+	m.Add("1.3", "Delete", "/scim/v2/Groups/{id}", AuthorizationRequiredHandler(scimHandler(scimGroupDelete)))
 
 	m.Add("1.0", "Post", "/swap", AuthorizationRequiredHandler(swap))
+	m.Add("1.3", "Get", "/swaps", AuthorizationRequiredHandler(gradualSwapList))
+	m.Add("1.3", "Get", "/swaps/{id}", AuthorizationRequiredHandler(gradualSwapInfo))
+	m.Add("1.3", "Post", "/swaps/{id}", AuthorizationRequiredHandler(gradualSwapUpdate))
 
 	m.Add("1.0", "Get", "/healthcheck/", http.HandlerFunc(healthcheck))
 	m.Add("1.0", "Get", "/healthcheck", http.HandlerFunc(healthcheck))
+	m.Add("1.3", "Get", "/status", AuthorizationRequiredHandler(statusSummaryHandler))
 
 	m.Add("1.0", "Get", "/iaas/machines", AuthorizationRequiredHandler(machinesList))
 	m.Add("1.0", "Delete", "/iaas/machines/{machine_id}", AuthorizationRequiredHandler(machineDestroy))
@@ -248,6 +368,16 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Put", "/pools/{name}", AuthorizationRequiredHandler(poolUpdateHandler))
 	m.Add("1.0", "Post", "/pools/{name}/team", AuthorizationRequiredHandler(addTeamToPoolHandler))
 	m.Add("1.0", "Delete", "/pools/{name}/team", AuthorizationRequiredHandler(removeTeamToPoolHandler))
+	m.Add("1.3", "Get", "/pools/{name}/defaults", AuthorizationRequiredHandler(poolDefaultsGet))
+	m.Add("1.3", "Put", "/pools/{name}/defaults", AuthorizationRequiredHandler(poolDefaultsSet))
+	m.Add("1.3", "Get", "/pools/{name}/registries", AuthorizationRequiredHandler(poolRegistryList))
+	m.Add("1.3", "Put", "/pools/{name}/registries", AuthorizationRequiredHandler(poolRegistrySet))
+	m.Add("1.3", "Delete", "/pools/{name}/registries/{server}", AuthorizationRequiredHandler(poolRegistryRemove))
+	m.Add("1.3", "Get", "/pools/{name}/quota", AuthorizationRequiredHandler(getPoolQuota))
+	m.Add("1.3", "Put", "/pools/{name}/quota", AuthorizationRequiredHandler(changePoolQuota))
+	m.Add("1.3", "Get", "/pools/{name}/log-drains", AuthorizationRequiredHandler(poolLogDrainList))
+	m.Add("1.3", "Post", "/pools/{name}/log-drains", AuthorizationRequiredHandler(poolLogDrainAdd))
+	m.Add("1.3", "Delete", "/pools/{name}/log-drains/{drain}", AuthorizationRequiredHandler(poolLogDrainRemove))
 
 	m.Add("1.3", "Get", "/constraints", AuthorizationRequiredHandler(poolConstraintList))
 	m.Add("1.3", "Put", "/constraints", AuthorizationRequiredHandler(poolConstraintSet))
@@ -256,6 +386,7 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/roles", AuthorizationRequiredHandler(addRole))
 	m.Add("1.0", "Get", "/roles/{name}", AuthorizationRequiredHandler(roleInfo))
 	m.Add("1.0", "Delete", "/roles/{name}", AuthorizationRequiredHandler(removeRole))
+	m.Add("1.3", "Post", "/roles/{name}/clone", AuthorizationRequiredHandler(cloneRole))
 	m.Add("1.0", "Post", "/roles/{name}/permissions", AuthorizationRequiredHandler(addPermissions))
 	m.Add("1.0", "Delete", "/roles/{name}/permissions/{permission}", AuthorizationRequiredHandler(removePermissions))
 	m.Add("1.0", "Post", "/roles/{name}/user", AuthorizationRequiredHandler(assignRole))
@@ -284,6 +415,11 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.3", "DELETE", "/node/autoscale/rules", AuthorizationRequiredHandler(autoScaleDeleteRule))
 	m.Add("1.3", "DELETE", "/node/autoscale/rules/{id}", AuthorizationRequiredHandler(autoScaleDeleteRule))
 
+	m.Add("1.3", "GET", "/node/poolrules", AuthorizationRequiredHandler(nodePoolRuleList))
+	m.Add("1.3", "POST", "/node/poolrules", AuthorizationRequiredHandler(nodePoolRuleCreate))
+	m.Add("1.3", "PUT", "/node/poolrules/{name}", AuthorizationRequiredHandler(nodePoolRuleUpdate))
+	m.Add("1.3", "DELETE", "/node/poolrules/{name}", AuthorizationRequiredHandler(nodePoolRuleRemove))
+
 	m.Add("1.2", "GET", "/node", AuthorizationRequiredHandler(listNodesHandler))
 	m.Add("1.2", "GET", "/node/apps/{appname}/containers", AuthorizationRequiredHandler(listUnitsByApp))
 	m.Add("1.2", "GET", "/node/{address:.*}/containers", AuthorizationRequiredHandler(listUnitsByNode))
@@ -291,6 +427,10 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.2", "PUT", "/node", AuthorizationRequiredHandler(updateNodeHandler))
 	m.Add("1.2", "DELETE", "/node/{address:.*}", AuthorizationRequiredHandler(removeNodeHandler))
 	m.Add("1.3", "POST", "/node/rebalance", AuthorizationRequiredHandler(rebalanceNodesHandler))
+	m.Add("1.3", "GET", "/node/credentials/rotations", AuthorizationRequiredHandler(nodeCredentialsRotationList))
+	m.Add("1.3", "POST", "/node/credentials/rotations", AuthorizationRequiredHandler(nodeCredentialsRotate))
+	m.Add("1.3", "GET", "/node/credentials/rotations/{id}", AuthorizationRequiredHandler(nodeCredentialsRotationInfo))
+	m.Add("1.3", "POST", "/node/credentials/rotations/{id}/resume", AuthorizationRequiredHandler(nodeCredentialsResume))
 
 	m.Add("1.2", "GET", "/nodecontainers", AuthorizationRequiredHandler(nodeContainerList))
 	m.Add("1.2", "POST", "/nodecontainers", AuthorizationRequiredHandler(nodeContainerCreate))
@@ -303,6 +443,23 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.2", "GET", "/install/hosts", AuthorizationRequiredHandler(installHostList))
 	m.Add("1.2", "GET", "/install/hosts/{name}", AuthorizationRequiredHandler(installHostInfo))
 
+	m.Add("1.3", "GET", "/motd", AuthorizationRequiredHandler(motdList))
+	m.Add("1.3", "POST", "/motd", AuthorizationRequiredHandler(motdAdd))
+	m.Add("1.3", "DELETE", "/motd/{id}", AuthorizationRequiredHandler(motdRemove))
+
+	readOnlyEnableHandler := AuthorizationRequiredHandler(readOnlyEnable)
+	readOnlyDisableHandler := AuthorizationRequiredHandler(readOnlyDisable)
+	m.Add("1.3", "GET", "/readonly", AuthorizationRequiredHandler(readOnlyList))
+	m.Add("1.3", "POST", "/readonly", readOnlyEnableHandler)
+	m.Add("1.3", "DELETE", "/readonly", readOnlyDisableHandler)
+
+	m.Add("1.3", "GET", "/storage/usage", AuthorizationRequiredHandler(storageUsage))
+
+	m.Add("1.3", "GET", "/status-page", http.HandlerFunc(statusPage))
+	m.Add("1.3", "GET", "/status-page/incidents", AuthorizationRequiredHandler(statusPageIncidentList))
+	m.Add("1.3", "POST", "/status-page/incidents", AuthorizationRequiredHandler(statusPageIncidentAdd))
+	m.Add("1.3", "POST", "/status-page/incidents/{id}/resolve", AuthorizationRequiredHandler(statusPageIncidentResolve))
+
 	m.Add("1.2", "GET", "/healing/node", AuthorizationRequiredHandler(nodeHealingRead))
 	m.Add("1.2", "POST", "/healing/node", AuthorizationRequiredHandler(nodeHealingUpdate))
 	m.Add("1.2", "DELETE", "/healing/node", AuthorizationRequiredHandler(nodeHealingDelete))
@@ -352,6 +509,14 @@ func RunServer(dry bool) http.Handler {
 	n.Use(negroni.HandlerFunc(errorHandlingMiddleware))
 	n.Use(negroni.HandlerFunc(setVersionHeadersMiddleware))
 	n.Use(negroni.HandlerFunc(authTokenMiddleware))
+	n.Use(&readOnlyMiddleware{excludedHandlers: []http.Handler{
+		loginHandler,
+		logPostHandler,
+		registerUnitHandler,
+		setUnitStatusHandler,
+		readOnlyEnableHandler,
+		readOnlyDisableHandler,
+	}})
 	n.Use(&appLockMiddleware{excludedHandlers: []http.Handler{
 		logPostHandler,
 		runHandler,
@@ -359,6 +524,7 @@ func RunServer(dry bool) http.Handler {
 		registerUnitHandler,
 		setUnitStatusHandler,
 		diffDeployHandler,
+		reviewAppNotifyHandler,
 	}})
 	n.UseHandler(http.HandlerFunc(runDelayedHandler))
 
@@ -487,6 +653,26 @@ func startServer(handler http.Handler) {
 	if err != nil {
 		fatal(err)
 	}
+	err = export.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = notify.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = scheduler.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = storageusage.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	initializeRoleReaper()
+	initializeReviewAppReaper()
+	initializeMaintenanceWatcher()
+	initializeSwapShifter()
 	fmt.Println("Checking components status:")
 	results := hc.Check()
 	for _, result := range results {
@@ -0,0 +1,274 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+)
+
+func init() {
+	event.SetScheduledExecutor(permission.PermServiceInstanceUpdateBackup.FullName(), scheduledServiceInstanceBackup)
+}
+
+func backupError(err error) error {
+	switch err {
+	case service.ErrBackupNotSupported:
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case service.ErrBackupNotFound:
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: service instance backup list
+// path: /services/{service}/instances/{instance}/backups
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Service does not support backups
+//   401: Unauthorized
+//   404: Service instance not found
+func serviceInstanceBackupList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	serviceName := r.URL.Query().Get(":service")
+	instanceName := r.URL.Query().Get(":instance")
+	si, err := getServiceInstanceOrError(serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceReadBackups,
+		contextsForServiceInstance(si, serviceName)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	requestIDHeader, _ := config.GetString("request-id-header")
+	backups, err := si.Backups(context.GetRequestID(r, requestIDHeader))
+	if err != nil {
+		return backupError(err)
+	}
+	if len(backups) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(backups)
+}
+
+// title: service instance backup create
+// path: /services/{service}/instances/{instance}/backups
+// method: POST
+// produce: application/json
+// responses:
+//   201: Backup created
+//   400: Service does not support backups
+//   401: Unauthorized
+//   404: Service instance not found
+func serviceInstanceBackupCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	serviceName := r.URL.Query().Get(":service")
+	instanceName := r.URL.Query().Get(":instance")
+	si, err := getServiceInstanceOrError(serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceUpdateBackup,
+		contextsForServiceInstance(si, serviceName)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, instanceName),
+		Kind:       permission.PermServiceInstanceUpdateBackup,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(si, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	var backup *service.Backup
+	defer func() { evt.DoneCustomData(err, backup) }()
+	requestIDHeader, _ := config.GetString("request-id-header")
+	backup, err = si.Backup(context.GetRequestID(r, requestIDHeader))
+	if err != nil {
+		return backupError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(backup)
+}
+
+// title: service instance backup restore
+// path: /services/{service}/instances/{instance}/backups/{backup}/restore
+// method: POST
+// responses:
+//   200: Instance restored
+//   401: Unauthorized
+//   404: Service instance or backup not found
+func serviceInstanceBackupRestore(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	serviceName := r.URL.Query().Get(":service")
+	instanceName := r.URL.Query().Get(":instance")
+	backupID := r.URL.Query().Get(":backup")
+	si, err := getServiceInstanceOrError(serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceUpdateRestore,
+		contextsForServiceInstance(si, serviceName)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, instanceName),
+		Kind:       permission.PermServiceInstanceUpdateRestore,
+		Owner:      t,
+		CustomData: append(event.FormToCustomData(r.Form), map[string]interface{}{"name": "backup", "value": backupID}),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(si, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	requestIDHeader, _ := config.GetString("request-id-header")
+	return backupError(si.Restore(backupID, context.GetRequestID(r, requestIDHeader)))
+}
+
+// title: service instance backup policy set
+// path: /services/{service}/instances/{instance}/backup-policy
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Backup policy set
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Service instance not found
+func serviceInstanceBackupPolicySet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	serviceName := r.URL.Query().Get(":service")
+	instanceName := r.URL.Query().Get(":instance")
+	si, err := getServiceInstanceOrError(serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceUpdateBackupPolicy,
+		contextsForServiceInstance(si, serviceName)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	policy := service.BackupPolicy{Schedule: strings.TrimSpace(r.FormValue("schedule"))}
+	if v := r.FormValue("before-unbind"); v != "" {
+		policy.BeforeUnbind, err = strconv.ParseBool(v)
+		if err != nil {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "before-unbind must be a boolean",
+				Fields:  map[string]string{"before-unbind": "must be a boolean"},
+			}
+		}
+	}
+	if policy.Schedule != "" {
+		_, err = event.NextScheduleTime(policy.Schedule, time.Now())
+		if err != nil {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, instanceName),
+		Kind:       permission.PermServiceInstanceUpdateBackupPolicy,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(si, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = si.SetBackupPolicy(policy)
+	if err != nil {
+		return err
+	}
+	return scheduleServiceInstanceBackup(si, &event.Opts{Owner: t})
+}
+
+// scheduleServiceInstanceBackup replaces the scheduled backup of the
+// instance with one at the next occurrence of the schedule of its backup
+// policy. Owner or RawOwner must be set in opts, the scheduled backup is
+// started on their behalf.
+func scheduleServiceInstanceBackup(si *service.ServiceInstance, opts *event.Opts) error {
+	target := serviceInstanceTarget(si.ServiceName, si.Name)
+	kind := permission.PermServiceInstanceUpdateBackup
+	scheduled, err := event.ListScheduled(&event.Filter{Target: target, KindName: kind.FullName()})
+	if err != nil {
+		return err
+	}
+	for _, sched := range scheduled {
+		err = event.CancelScheduled(sched.ID)
+		if err != nil && err != event.ErrScheduledEventNotFound && err != event.ErrScheduledEventStarting {
+			return err
+		}
+	}
+	if si.BackupPolicy.Schedule == "" {
+		return nil
+	}
+	opts.RunAt, err = event.NextScheduleTime(si.BackupPolicy.Schedule, time.Now())
+	if err != nil {
+		return err
+	}
+	opts.Target = target
+	opts.Kind = kind
+	opts.CustomData = map[string]string{"schedule": si.BackupPolicy.Schedule}
+	opts.Allowed = event.Allowed(permission.PermServiceInstanceReadEvents,
+		contextsForServiceInstance(si, si.ServiceName)...)
+	_, err = event.New(opts)
+	return err
+}
+
+// scheduledServiceInstanceBackup backs up the instance in the target of a
+// scheduled backup event and schedules the next one.
+func scheduledServiceInstanceBackup(evt *event.Event) error {
+	parts := strings.SplitN(evt.Target.Value, "/", 2)
+	if len(parts) != 2 {
+		return service.ErrServiceInstanceNotFound
+	}
+	si, err := service.GetServiceInstance(parts[0], parts[1])
+	if err != nil {
+		return err
+	}
+	if si.BackupPolicy.Schedule == "" {
+		evt.Logf("The backup schedule of the instance was removed, skipping backup.")
+		return nil
+	}
+	backup, err := si.Backup(evt.UniqueID.Hex())
+	schedErr := scheduleServiceInstanceBackup(si, &event.Opts{RawOwner: evt.Owner})
+	if schedErr != nil {
+		log.Errorf("[service backup] unable to schedule the next backup of %s: %s", evt.Target.Value, schedErr)
+	}
+	if err != nil {
+		return err
+	}
+	return evt.DoneCustomData(nil, backup)
+}
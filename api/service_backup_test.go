@@ -0,0 +1,230 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+func (s *ServiceInstanceSuite) createBackupService(c *check.C, handler http.HandlerFunc) *httptest.Server {
+	ts := httptest.NewServer(handler)
+	srv := service.Service{
+		Name:     "bkpsql",
+		Teams:    []string{s.team.Name},
+		Endpoint: map[string]string{"production": ts.URL},
+	}
+	err := srv.Create()
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{
+		Name:        "my-sql",
+		ServiceName: "bkpsql",
+		Teams:       []string{s.team.Name},
+	}
+	err = si.Create()
+	c.Assert(err, check.IsNil)
+	return ts
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupList(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/resources/my-sql/backups" {
+			w.Write([]byte(`[{"ID": "bkp-1", "Status": "done", "Size": 10}]`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer ts.Close()
+	request, err := http.NewRequest("GET", "/services/bkpsql/instances/my-sql/backups", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var backups []service.Backup
+	err = json.NewDecoder(recorder.Body).Decode(&backups)
+	c.Assert(err, check.IsNil)
+	c.Assert(backups, check.DeepEquals, []service.Backup{{ID: "bkp-1", Status: "done", Size: 10}})
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupListNotSupported(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer ts.Close()
+	request, err := http.NewRequest("GET", "/services/bkpsql/instances/my-sql/backups", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, service.ErrBackupNotSupported.Error()+"\n")
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupCreate(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/resources/my-sql/backups" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"ID": "bkp-1", "Status": "pending"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer ts.Close()
+	request, err := http.NewRequest("POST", "/services/bkpsql/instances/my-sql/backups", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var backup service.Backup
+	err = json.NewDecoder(recorder.Body).Decode(&backup)
+	c.Assert(err, check.IsNil)
+	c.Assert(backup, check.DeepEquals, service.Backup{ID: "bkp-1", Status: "pending"})
+	c.Assert(eventtest.EventDesc{
+		Target: serviceInstanceTarget("bkpsql", "my-sql"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "service-instance.update.backup",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":service", "value": "bkpsql"},
+			{"name": ":instance", "value": "my-sql"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupCreateNoPermission(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ID": "bkp-1"}`))
+	})
+	defer ts.Close()
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermServiceInstanceReadBackups,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/services/bkpsql/instances/my-sql/backups", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupRestore(c *check.C) {
+	var restored bool
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/resources/my-sql/backups/bkp-1/restore" {
+			restored = true
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer ts.Close()
+	request, err := http.NewRequest("POST", "/services/bkpsql/instances/my-sql/backups/bkp-1/restore", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(restored, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: serviceInstanceTarget("bkpsql", "my-sql"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "service-instance.update.restore",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":service", "value": "bkpsql"},
+			{"name": ":instance", "value": "my-sql"},
+			{"name": ":backup", "value": "bkp-1"},
+			{"name": "backup", "value": "bkp-1"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupRestoreNotFound(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer ts.Close()
+	request, err := http.NewRequest("POST", "/services/bkpsql/instances/my-sql/backups/bkp-1/restore", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, service.ErrBackupNotFound.Error()+"\n")
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupPolicySet(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {})
+	defer ts.Close()
+	body := strings.NewReader("schedule=0+3+*+*+*&before-unbind=true")
+	request, err := http.NewRequest("PUT", "/services/bkpsql/instances/my-sql/backup-policy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	si, err := service.GetServiceInstance("bkpsql", "my-sql")
+	c.Assert(err, check.IsNil)
+	c.Assert(si.BackupPolicy, check.DeepEquals, service.BackupPolicy{Schedule: "0 3 * * *", BeforeUnbind: true})
+	scheduled, err := event.ListScheduled(&event.Filter{KindName: "service-instance.update.backup"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+	c.Assert(scheduled[0].Target, check.DeepEquals, serviceInstanceTarget("bkpsql", "my-sql"))
+	c.Assert(scheduled[0].Owner.Name, check.Equals, s.token.GetUserName())
+	c.Assert(scheduled[0].RunAt.UTC().Hour(), check.Equals, 3)
+	c.Assert(scheduled[0].RunAt.After(time.Now()), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: serviceInstanceTarget("bkpsql", "my-sql"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "service-instance.update.backup.policy",
+		StartCustomData: []map[string]interface{}{
+			{"name": "schedule", "value": "0 3 * * *"},
+			{"name": "before-unbind", "value": "true"},
+			{"name": ":service", "value": "bkpsql"},
+			{"name": ":instance", "value": "my-sql"},
+		},
+	}, eventtest.HasEvent)
+	body = strings.NewReader("schedule=")
+	request, err = http.NewRequest("PUT", "/services/bkpsql/instances/my-sql/backup-policy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	scheduled, err = event.ListScheduled(&event.Filter{KindName: "service-instance.update.backup"})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 0)
+}
+
+func (s *ServiceInstanceSuite) TestServiceInstanceBackupPolicySetInvalidSchedule(c *check.C) {
+	ts := s.createBackupService(c, func(w http.ResponseWriter, r *http.Request) {})
+	defer ts.Close()
+	body := strings.NewReader("schedule=0+0+30+2+*")
+	request, err := http.NewRequest("PUT", "/services/bkpsql/instances/my-sql/backup-policy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid schedule \"0 0 30 2 *\": it never runs\n")
+	si, err := service.GetServiceInstance("bkpsql", "my-sql")
+	c.Assert(err, check.IsNil)
+	c.Assert(si.BackupPolicy, check.DeepEquals, service.BackupPolicy{})
+}
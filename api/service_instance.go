@@ -391,6 +391,7 @@ type serviceInstanceInfo struct {
 	PlanDescription string
 	CustomInfo      map[string]string
 	Tags            []string
+	BackupPolicy    service.BackupPolicy
 }
 
 // title: service instance info
@@ -433,6 +434,7 @@ func serviceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		PlanDescription: plan.Description,
 		CustomInfo:      info,
 		Tags:            serviceInstance.Tags,
+		BackupPolicy:    serviceInstance.BackupPolicy,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(sInfo)
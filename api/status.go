@@ -0,0 +1,148 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// statusSummaryMaxApps bounds the number of apps in the status summary, as
+// the units and the last deploy of each app are loaded one app at a time.
+var statusSummaryMaxApps = 50
+
+type statusSummary struct {
+	API       apiStatus
+	Apps      []appStatus
+	TotalApps int
+	Quota     quota.Quota
+	Events    []statusEvent
+}
+
+type apiStatus struct {
+	Healthy bool
+	Checks  []hc.Result
+}
+
+type appStatus struct {
+	Name       string
+	Pool       string
+	Units      map[string]int
+	Quota      quota.Quota
+	LastDeploy *app.DeployData `json:",omitempty"`
+}
+
+type statusEvent struct {
+	ID        bson.ObjectId
+	Target    event.Target
+	Kind      string
+	Owner     string
+	StartTime time.Time
+}
+
+// title: status summary
+// path: /status
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func statusSummaryHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	summary := statusSummary{API: apiStatus{Healthy: true, Checks: hc.Check()}}
+	for _, result := range summary.API.Checks {
+		if result.Status != hc.HealthCheckOK {
+			summary.API.Healthy = false
+		}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	summary.Quota = u.Quota
+	contexts := permission.ContextsForPermission(t, permission.PermAppRead)
+	if len(contexts) > 0 {
+		apps, err := app.List(appFilterByContext(contexts, nil))
+		if err != nil {
+			return err
+		}
+		sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+		appNames := make([]string, len(apps))
+		for i, a := range apps {
+			appNames[i] = a.Name
+		}
+		summary.TotalApps = len(apps)
+		if len(apps) > statusSummaryMaxApps {
+			apps = apps[:statusSummaryMaxApps]
+		}
+		summary.Apps = make([]appStatus, len(apps))
+		for i, a := range apps {
+			summary.Apps[i], err = newAppStatus(a)
+			if err != nil {
+				return err
+			}
+		}
+		summary.Events, err = runningAppEvents(appNames)
+		if err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(summary)
+}
+
+func newAppStatus(a app.App) (appStatus, error) {
+	units, err := a.Units()
+	if err != nil {
+		return appStatus{}, err
+	}
+	status := appStatus{
+		Name:  a.Name,
+		Pool:  a.Pool,
+		Units: map[string]int{},
+		Quota: a.Quota,
+	}
+	for _, u := range units {
+		status.Units[u.Status.String()]++
+	}
+	status.LastDeploy, err = app.LastDeploy(a.Name)
+	return status, err
+}
+
+func runningAppEvents(appNames []string) ([]statusEvent, error) {
+	if len(appNames) == 0 {
+		return nil, nil
+	}
+	running := true
+	evts, err := event.List(&event.Filter{
+		Target:  event.Target{Type: event.TargetTypeApp},
+		Raw:     bson.M{"target.value": bson.M{"$in": appNames}},
+		Running: &running,
+		Limit:   -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]statusEvent, len(evts))
+	for i := range evts {
+		result[i] = statusEvent{
+			ID:        evts[i].UniqueID,
+			Target:    evts[i].Target,
+			Kind:      evts[i].Kind.Name,
+			Owner:     evts[i].Owner.Name,
+			StartTime: evts[i].StartTime,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestStatusSummary(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	deployEvt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = deployEvt.DoneCustomData(nil, map[string]string{"image": "myimg:v1"})
+	c.Assert(err, check.IsNil)
+	runningEvt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	defer runningEvt.Done(nil)
+	request, err := http.NewRequest("GET", "/status", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var summary statusSummary
+	err = json.Unmarshal(recorder.Body.Bytes(), &summary)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.API.Healthy, check.Equals, true)
+	c.Assert(summary.Quota, check.DeepEquals, s.user.Quota)
+	c.Assert(summary.Apps, check.HasLen, 1)
+	c.Assert(summary.Apps[0].Name, check.Equals, "myapp")
+	c.Assert(summary.Apps[0].Units, check.DeepEquals, map[string]int{"started": 2})
+	c.Assert(summary.Apps[0].LastDeploy, check.NotNil)
+	c.Assert(summary.Apps[0].LastDeploy.ID, check.Equals, deployEvt.UniqueID)
+	c.Assert(summary.Apps[0].LastDeploy.Image, check.Equals, "myimg:v1")
+	c.Assert(summary.Events, check.HasLen, 1)
+	c.Assert(summary.Events[0].ID, check.Equals, runningEvt.UniqueID)
+	c.Assert(summary.Events[0].Kind, check.Equals, "app.update.restart")
+}
+
+func (s *S) TestStatusSummaryMaxApps(c *check.C) {
+	defer func(max int) { statusSummaryMaxApps = max }(statusSummaryMaxApps)
+	statusSummaryMaxApps = 2
+	for _, name := range []string{"app3", "app1", "app2"} {
+		a := app.App{Name: name, Platform: "zend", TeamOwner: s.team.Name}
+		err := app.CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+	}
+	request, err := http.NewRequest("GET", "/status", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var summary statusSummary
+	err = json.Unmarshal(recorder.Body.Bytes(), &summary)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.TotalApps, check.Equals, 3)
+	c.Assert(summary.Apps, check.HasLen, 2)
+	c.Assert(summary.Apps[0].Name, check.Equals, "app1")
+	c.Assert(summary.Apps[1].Name, check.Equals, "app2")
+}
+
+func (s *S) TestStatusSummaryNoApps(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/status", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var summary statusSummary
+	err = json.Unmarshal(recorder.Body.Bytes(), &summary)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.API.Healthy, check.Equals, true)
+	c.Assert(summary.Apps, check.HasLen, 0)
+	c.Assert(summary.Events, check.HasLen, 0)
+}
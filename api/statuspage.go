@@ -0,0 +1,163 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/statuspage"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// title: status page
+// path: /status-page
+// method: GET
+// produce: application/json, text/html
+// responses:
+//   200: OK
+//   404: Status page not enabled
+//   500: Internal server error
+func statusPage(w http.ResponseWriter, r *http.Request) {
+	settings := statuspage.LoadSettings()
+	if !settings.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := statuspage.Get()
+	if err != nil {
+		log.Errorf("[status-page] unable to generate the status page: %s", err)
+		http.Error(w, "unable to generate the status page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(settings.CacheTTL.Seconds())))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.URL.Query().Get("format") == "html" || strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = statuspage.WriteHTML(w, page)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(page)
+	}
+	if err != nil {
+		log.Errorf("[status-page] unable to write the status page: %s", err)
+	}
+}
+
+// title: status page incident list
+// path: /status-page/incidents
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func statusPageIncidentList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermStatusPageRead) {
+		return permission.ErrUnauthorized
+	}
+	incidents, err := statuspage.ListIncidents()
+	if err != nil {
+		return err
+	}
+	if len(incidents) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(incidents)
+}
+
+// title: status page incident add
+// path: /status-page/incidents
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Incident added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Event not found
+//   409: Event already flagged
+func statusPageIncidentAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermStatusPageIncidentAdd) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	eventID := r.FormValue("event")
+	if !bson.IsObjectIdHex(eventID) {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "event must be the id of an event"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeStatusPageIncident, Value: eventID},
+		Kind:       permission.PermStatusPageIncidentAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermStatusPageReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	incident, err := statuspage.FlagIncident(bson.ObjectIdHex(eventID), r.FormValue("title"), r.FormValue("description"), t.GetUserName())
+	switch err {
+	case nil:
+	case event.ErrEventNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case statuspage.ErrAlreadyFlagged:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	default:
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(incident)
+}
+
+// title: status page incident resolve
+// path: /status-page/incidents/{id}/resolve
+// method: POST
+// responses:
+//   200: Incident resolved
+//   401: Unauthorized
+//   404: Incident not found
+//   409: Incident already resolved
+func statusPageIncidentResolve(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermStatusPageIncidentResolve) {
+		return permission.ErrUnauthorized
+	}
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: statuspage.ErrIncidentNotFound.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeStatusPageIncident, Value: id},
+		Kind:    permission.PermStatusPageIncidentResolve,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermStatusPageReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = statuspage.ResolveIncident(bson.ObjectIdHex(id))
+	switch err {
+	case statuspage.ErrIncidentNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case statuspage.ErrAlreadyResolved:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/statuspage"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) enableStatusPage() func() {
+	config.Set("status-page:enabled", true)
+	config.Set("status-page:cache-ttl", 0.001)
+	return func() { config.Unset("status-page") }
+}
+
+func (s *S) newStatusPageEvent(c *check.C) *event.Event {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypePool, Value: s.Pool},
+		InternalKind: "healer",
+		Allowed:      event.Allowed(permission.PermPoolReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestStatusPage(c *check.C) {
+	defer s.enableStatusPage()()
+	_, err := statuspage.FlagIncident(s.newStatusPageEvent(c).UniqueID, "router failures", "", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/status-page", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(recorder.Header().Get("Cache-Control"), check.Equals, "public, max-age=0")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), check.Equals, "*")
+	var page statuspage.Page
+	err = json.NewDecoder(recorder.Body).Decode(&page)
+	c.Assert(err, check.IsNil)
+	c.Assert(page.API, check.NotNil)
+	c.Assert(page.Incidents, check.HasLen, 1)
+	c.Assert(page.Incidents[0].Title, check.Equals, "router failures")
+	var names []string
+	for _, p := range page.Pools {
+		names = append(names, p.Name)
+	}
+	c.Assert(names, check.DeepEquals, []string{s.Pool})
+}
+
+func (s *S) TestStatusPageHTML(c *check.C) {
+	defer s.enableStatusPage()()
+	request, err := http.NewRequest("GET", "/1.3/status-page", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/html; charset=utf-8")
+	c.Assert(recorder.Body.String(), check.Matches, "(?s).*<title>tsuru status</title>.*")
+}
+
+func (s *S) TestStatusPageNotEnabled(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/status-page", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestStatusPageIncidentList(c *check.C) {
+	evt := s.newStatusPageEvent(c)
+	_, err := statuspage.FlagIncident(evt.UniqueID, "router failures", "", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/status-page/incidents", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var incidents []statuspage.Incident
+	err = json.NewDecoder(recorder.Body).Decode(&incidents)
+	c.Assert(err, check.IsNil)
+	c.Assert(incidents, check.HasLen, 1)
+	c.Assert(incidents[0].ID, check.Equals, evt.UniqueID)
+	c.Assert(incidents[0].FlaggedBy, check.Equals, "admin@example.com")
+}
+
+func (s *S) TestStatusPageIncidentListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/status-page/incidents", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestStatusPageIncidentAdd(c *check.C) {
+	flagged := s.newStatusPageEvent(c)
+	body := strings.NewReader("event=" + flagged.UniqueID.Hex() + "&title=router+failures&description=some+apps+unreachable")
+	request, err := http.NewRequest("POST", "/1.3/status-page/incidents", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var incident statuspage.Incident
+	err = json.NewDecoder(recorder.Body).Decode(&incident)
+	c.Assert(err, check.IsNil)
+	c.Assert(incident.ID, check.Equals, flagged.UniqueID)
+	c.Assert(incident.Title, check.Equals, "router failures")
+	c.Assert(incident.Description, check.Equals, "some apps unreachable")
+	c.Assert(incident.FlaggedBy, check.Equals, s.token.GetUserName())
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeStatusPageIncident, Value: flagged.UniqueID.Hex()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "status-page.incident.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": "event", "value": flagged.UniqueID.Hex()},
+			{"name": "title", "value": "router failures"},
+			{"name": "description", "value": "some apps unreachable"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("POST", "/1.3/status-page/incidents", strings.NewReader("event="+flagged.UniqueID.Hex()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestStatusPageIncidentAddInvalid(c *check.C) {
+	tests := []struct {
+		body string
+		code int
+	}{
+		{"event=invalid", http.StatusBadRequest},
+		{"event=" + bson.NewObjectId().Hex(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/1.3/status-page/incidents", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		RunServer(true).ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, tt.code, check.Commentf("body: %s", tt.body))
+	}
+}
+
+func (s *S) TestStatusPageIncidentAddWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermStatusPageRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	body := strings.NewReader("event=" + s.newStatusPageEvent(c).UniqueID.Hex())
+	request, err := http.NewRequest("POST", "/1.3/status-page/incidents", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestStatusPageIncidentResolve(c *check.C) {
+	incident, err := statuspage.FlagIncident(s.newStatusPageEvent(c).UniqueID, "router failures", "", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	url := "/1.3/status-page/incidents/" + incident.ID.Hex() + "/resolve"
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	incidents, err := statuspage.ListIncidents()
+	c.Assert(err, check.IsNil)
+	c.Assert(incidents, check.HasLen, 1)
+	c.Assert(incidents[0].Resolved, check.NotNil)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeStatusPageIncident, Value: incident.ID.Hex()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "status-page.incident.resolve",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestStatusPageIncidentResolveNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/1.3/status-page/incidents/"+bson.NewObjectId().Hex()+"/resolve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
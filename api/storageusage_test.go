@@ -0,0 +1,63 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/storageusage"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestStorageUsage(c *check.C) {
+	for _, name := range []string{"app1", "app2"} {
+		err := s.conn.Apps().Insert(bson.M{"name": name})
+		c.Assert(err, check.IsNil)
+		err = s.logConn.Logs(name).Insert(bson.M{"message": "msg"})
+		c.Assert(err, check.IsNil)
+	}
+	request, err := http.NewRequest("GET", "/1.3/storage/usage?apps=1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var report storageusage.Report
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Collections, check.Not(check.HasLen), 0)
+	c.Assert(report.AppLogs, check.HasLen, 1)
+}
+
+func (s *S) TestStorageUsageInvalidAppsLimit(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/storage/usage?apps=many", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "apps must be a positive integer\n")
+}
+
+func (s *S) TestStorageUsageNoPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/1.3/storage/usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
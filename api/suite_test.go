@@ -92,8 +92,14 @@ func (s *S) SetUpSuite(c *check.C) {
 func (s *S) SetUpTest(c *check.C) {
 	config.Set("routers:fake:default", true)
 	config.Set("routers:fake-tls:type", "fake-tls")
+	config.Set("routers:fake-ratelimit:type", "fake-ratelimit")
+	config.Set("routers:fake-maintenance:type", "fake-maintenance")
+	config.Set("routers:fake-weighted:type", "fake-weighted")
 	routertest.FakeRouter.Reset()
 	routertest.TLSRouter.Reset()
+	routertest.RateLimitRouter.Reset()
+	routertest.MaintenanceRouter.Reset()
+	routertest.WeightedRouter.Reset()
 	repositorytest.Reset()
 	var err error
 	s.conn, err = db.Conn()
@@ -0,0 +1,183 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// startGradualSwap starts shifting the traffic of app1 to app2 in steps of
+// the percentage in the step form value, every interval seconds, instead of
+// swapping the apps right away.
+func startGradualSwap(w http.ResponseWriter, r *http.Request, t auth.Token, app1, app2 *app.App, cnameOnly bool) error {
+	opts := app.GradualSwapOptions{CNameOnly: cnameOnly, Owner: t.GetUserName()}
+	step, err := strconv.Atoi(r.FormValue("step"))
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "step must be a percentage"}
+	}
+	opts.Step = step
+	if v := r.FormValue("interval"); v != "" {
+		seconds, parseErr := strconv.ParseFloat(v, 64)
+		if parseErr != nil {
+			return &terrors.HTTP{Code: http.StatusBadRequest, Message: "interval must be a number of seconds"}
+		}
+		opts.Interval = time.Duration(seconds * float64(time.Second))
+	}
+	swap, err := app.StartGradualSwap(app1, app2, opts)
+	switch err {
+	case nil:
+	case app.ErrSwapInProgress:
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case app.ErrGradualSwapNotSupported:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	default:
+		if e, ok := err.(*terrors.ValidationError); ok {
+			return &terrors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(swap)
+}
+
+// title: gradual swap list
+// path: /swaps
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func gradualSwapList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	swaps, err := app.ListGradualSwaps()
+	if err != nil {
+		return err
+	}
+	var result []app.GradualSwap
+	for _, swap := range swaps {
+		a, err := app.GetByName(swap.App1)
+		if err != nil {
+			continue
+		}
+		if permission.Check(t, permission.PermAppRead, contextsForApp(a)...) {
+			result = append(result, swap)
+		}
+	}
+	if len(result) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: gradual swap info
+// path: /swaps/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func gradualSwapInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	swap, err := getGradualSwap(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	a, err := getApp(swap.App1)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppRead, contextsForApp(a)...) {
+		return permission.ErrUnauthorized
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(swap)
+}
+
+// title: gradual swap update
+// path: /swaps/{id}
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+//   409: Swap not running or paused
+func gradualSwapUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	swap, err := getGradualSwap(r.URL.Query().Get(":id"))
+	if err != nil {
+		return err
+	}
+	var change func() error
+	switch action := r.FormValue("action"); action {
+	case "pause":
+		change = swap.Pause
+	case "resume":
+		change = swap.Resume
+	case "abort":
+		change = swap.Abort
+	default:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid action %q, it must be pause, resume or abort", action)}
+	}
+	app1, err := getApp(swap.App1)
+	if err != nil {
+		return err
+	}
+	app2, err := getApp(swap.App2)
+	if err != nil {
+		return err
+	}
+	allowed1 := permission.Check(t, permission.PermAppUpdateSwap, contextsForApp(app1)...)
+	allowed2 := permission.Check(t, permission.PermAppUpdateSwap, contextsForApp(app2)...)
+	if !allowed1 || !allowed2 {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:       appTarget(swap.App1),
+		Kind:         permission.PermAppUpdateSwap,
+		Owner:        t,
+		CustomData:   event.FormToCustomData(r.Form),
+		Allowed:      event.Allowed(permission.PermAppReadEvents, contextsForApp(app1)...),
+		ExtraTargets: []event.Target{appTarget(swap.App2)},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = change()
+	if e, ok := err.(*terrors.ConflictError); ok {
+		return &terrors.HTTP{Code: http.StatusConflict, Message: e.Message}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(swap)
+}
+
+func getGradualSwap(id string) (*app.GradualSwap, error) {
+	swap, err := app.GetGradualSwap(id)
+	if err == app.ErrSwapNotFound {
+		return nil, &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return swap, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const (
+	// swapStepEventKind is the internal kind of the events recording the
+	// steps of gradual swaps.
+	swapStepEventKind = "app-swap-step"
+
+	defaultSwapShifterInterval = 10 * time.Second
+)
+
+// swapShifter periodically shifts the traffic of running gradual swaps
+// whose next step is due.
+type swapShifter struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// initializeSwapShifter starts advancing gradual swaps, checking for due
+// steps every swap:check-interval seconds.
+func initializeSwapShifter() {
+	ss := &swapShifter{
+		interval: defaultSwapShifterInterval,
+		done:     make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("swap:check-interval"); seconds > 0 {
+		ss.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(ss)
+	go ss.run()
+}
+
+func (ss *swapShifter) run() {
+	for {
+		err := advanceGradualSwaps(time.Now())
+		if err != nil {
+			log.Errorf("[swap shifter] unable to advance gradual swaps: %s", err)
+		}
+		select {
+		case <-ss.done:
+			return
+		case <-time.After(ss.interval):
+		}
+	}
+}
+
+func (ss *swapShifter) Shutdown() {
+	ss.done <- true
+}
+
+func (ss *swapShifter) String() string {
+	return "swap shifter"
+}
+
+// advanceGradualSwaps runs the steps of gradual swaps due until now,
+// recording each one in an internal event targeting the first app of the
+// swap. Swaps of apps locked by other events or requests are skipped until
+// the next run.
+func advanceGradualSwaps(now time.Time) error {
+	swaps, err := app.ListDueGradualSwaps(now)
+	if err != nil {
+		return err
+	}
+	for i := range swaps {
+		err = advanceGradualSwap(&swaps[i])
+		if err != nil {
+			log.Errorf("[swap shifter] unable to advance swap of apps %q and %q: %s", swaps[i].App1, swaps[i].App2, err)
+		}
+	}
+	return nil
+}
+
+func advanceGradualSwap(swap *app.GradualSwap) (err error) {
+	var apps []*app.App
+	for _, appName := range []string{swap.App1, swap.App2} {
+		a, getErr := app.GetByName(appName)
+		if getErr == app.ErrAppNotFound {
+			// Advancing swaps of removed apps fails them.
+			continue
+		}
+		if getErr != nil {
+			return getErr
+		}
+		apps = append(apps, a)
+	}
+	// The last step swaps the apps, which must not run alongside other
+	// requests changing them, like the swap handler.
+	for _, a := range apps {
+		locked, lockErr := app.AcquireApplicationLock(a.Name, app.InternalAppName, "gradual swap")
+		if lockErr != nil {
+			return lockErr
+		}
+		if !locked {
+			return nil
+		}
+		defer app.ReleaseApplicationLock(a.Name)
+	}
+	allowed := event.Allowed(permission.PermAppReadEvents)
+	if len(apps) > 0 && apps[0].Name == swap.App1 {
+		allowed = event.Allowed(permission.PermAppReadEvents, contextsForApp(apps[0])...)
+	}
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       appTarget(swap.App1),
+		InternalKind: swapStepEventKind,
+		CustomData: map[string]interface{}{
+			"swap":   swap.ID.Hex(),
+			"weight": swap.Weight,
+		},
+		Allowed:      allowed,
+		ExtraTargets: []event.Target{appTarget(swap.App2)},
+	})
+	if _, locked := err.(event.ErrEventLocked); locked {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	// The swap may have been paused or aborted since it was listed.
+	current, err := app.GetGradualSwap(swap.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if current.Status != app.SwapStatusRunning {
+		return nil
+	}
+	return current.Advance(evt)
+}
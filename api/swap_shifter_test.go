@@ -0,0 +1,63 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAdvanceGradualSwaps(c *check.C) {
+	s.createGradualSwapApps(c)
+	app1, err := app.GetByName("app1")
+	c.Assert(err, check.IsNil)
+	app2, err := app.GetByName("app2")
+	c.Assert(err, check.IsNil)
+	swap, err := app.StartGradualSwap(app1, app2, app.GradualSwapOptions{Step: 50, Interval: time.Minute})
+	c.Assert(err, check.IsNil)
+	err = advanceGradualSwaps(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.WeightedRouter.History["app1"], check.DeepEquals, []int{50})
+	err = advanceGradualSwaps(time.Now().Add(time.Minute))
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.WeightedRouter.History["app1"], check.DeepEquals, []int{50, 100})
+	err = advanceGradualSwaps(time.Now().Add(2 * time.Minute))
+	c.Assert(err, check.IsNil)
+	dbSwap, err := app.GetGradualSwap(swap.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSwap.Status, check.Equals, app.SwapStatusDone)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("app1"),
+		Kind:   swapStepEventKind,
+		StartCustomData: map[string]interface{}{
+			"swap":   swap.ID.Hex(),
+			"weight": 100,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAdvanceGradualSwapsSkipsLockedApps(c *check.C) {
+	s.createGradualSwapApps(c)
+	app1, err := app.GetByName("app1")
+	c.Assert(err, check.IsNil)
+	app2, err := app.GetByName("app2")
+	c.Assert(err, check.IsNil)
+	_, err = app.StartGradualSwap(app1, app2, app.GradualSwapOptions{Step: 50, Interval: time.Minute})
+	c.Assert(err, check.IsNil)
+	locked, err := app.AcquireApplicationLock("app2", s.user.Email, "deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	err = advanceGradualSwaps(time.Now().Add(time.Minute))
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.WeightedRouter.History["app1"], check.DeepEquals, []int{50})
+	app.ReleaseApplicationLock("app2")
+	err = advanceGradualSwaps(time.Now().Add(time.Minute))
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.WeightedRouter.History["app1"], check.DeepEquals, []int{50, 100})
+}
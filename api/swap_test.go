@@ -0,0 +1,154 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createGradualSwapApps(c *check.C) {
+	for i, name := range []string{"app1", "app2"} {
+		a := app.App{Name: name, Platform: "zend", TeamOwner: s.team.Name, Router: "fake-weighted"}
+		err := app.CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+		addr := &url.URL{Scheme: "http", Host: []string{"10.0.0.1:8080", "10.0.0.2:8080"}[i]}
+		err = routertest.WeightedRouter.AddRoutes(name, []*url.URL{addr})
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) startGradualSwap(c *check.C, m http.Handler, body string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest("POST", "/swap", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *S) TestSwapGradual(c *check.C) {
+	s.createGradualSwapApps(c)
+	m := RunServer(true)
+	recorder := s.startGradualSwap(c, m, "app1=app1&app2=app2&cnameOnly=false&step=25&interval=30")
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var swap app.GradualSwap
+	err := json.NewDecoder(recorder.Body).Decode(&swap)
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.App1, check.Equals, "app1")
+	c.Assert(swap.App2, check.Equals, "app2")
+	c.Assert(swap.Weight, check.Equals, 25)
+	c.Assert(swap.Interval, check.Equals, 30*time.Second)
+	c.Assert(swap.Status, check.Equals, app.SwapStatusRunning)
+	c.Assert(routertest.WeightedRouter.Weights["app1"], check.DeepEquals, map[string]int{"10.0.0.2:8080": 25})
+	recorder = s.startGradualSwap(c, m, "app1=app2&app2=app1&cnameOnly=false")
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrSwapInProgress.Error()+"\n")
+}
+
+func (s *S) TestSwapGradualInvalid(c *check.C) {
+	s.createGradualSwapApps(c)
+	m := RunServer(true)
+	tests := []string{
+		"app1=app1&app2=app2&step=abc",
+		"app1=app1&app2=app2&step=100",
+		"app1=app1&app2=app2&step=10&interval=abc",
+	}
+	for _, body := range tests {
+		recorder := s.startGradualSwap(c, m, body)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest, check.Commentf("body: %q", body))
+	}
+	inProgress, err := app.GradualSwapInProgress("app1", "app2")
+	c.Assert(err, check.IsNil)
+	c.Assert(inProgress, check.Equals, false)
+}
+
+func (s *S) TestGradualSwapList(c *check.C) {
+	s.createGradualSwapApps(c)
+	m := RunServer(true)
+	request, err := http.NewRequest("GET", "/1.3/swaps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	recorder = s.startGradualSwap(c, m, "app1=app1&app2=app2&step=10")
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var swaps []app.GradualSwap
+	err = json.NewDecoder(recorder.Body).Decode(&swaps)
+	c.Assert(err, check.IsNil)
+	c.Assert(swaps, check.HasLen, 1)
+	c.Assert(swaps[0].App1, check.Equals, "app1")
+	c.Assert(swaps[0].Weight, check.Equals, 10)
+}
+
+func (s *S) TestGradualSwapUpdate(c *check.C) {
+	s.createGradualSwapApps(c)
+	m := RunServer(true)
+	recorder := s.startGradualSwap(c, m, "app1=app1&app2=app2&step=10")
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var swap app.GradualSwap
+	err := json.NewDecoder(recorder.Body).Decode(&swap)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		action string
+		code   int
+		status string
+	}{
+		{"pause", http.StatusOK, app.SwapStatusPaused},
+		{"pause", http.StatusConflict, app.SwapStatusPaused},
+		{"resume", http.StatusOK, app.SwapStatusRunning},
+		{"abort", http.StatusOK, app.SwapStatusAborted},
+		{"resume", http.StatusConflict, app.SwapStatusAborted},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/1.3/swaps/"+swap.ID.Hex(), strings.NewReader("action="+tt.action))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder = httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, tt.code, check.Commentf("action: %s", tt.action))
+		dbSwap, err := app.GetGradualSwap(swap.ID.Hex())
+		c.Assert(err, check.IsNil)
+		c.Assert(dbSwap.Status, check.Equals, tt.status)
+	}
+	c.Assert(routertest.WeightedRouter.Weights, check.HasLen, 0)
+	c.Assert(routertest.WeightedRouter.HasRoute("app1", "10.0.0.2:8080"), check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("app1"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.swap",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":id", "value": swap.ID.Hex()},
+			{"name": "action", "value": "abort"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestGradualSwapUpdateInvalid(c *check.C) {
+	m := RunServer(true)
+	request, err := http.NewRequest("POST", "/1.3/swaps/abc", strings.NewReader("action=pause"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
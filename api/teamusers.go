@@ -0,0 +1,247 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+var teamUsersCSVHeader = []string{"email", "role"}
+
+type teamUserImport struct {
+	Line  int    `json:"line"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	Error string `json:"error,omitempty"`
+	user  *auth.User
+	added bool
+}
+
+type teamUsersImportResult struct {
+	Imported bool             `json:"imported"`
+	Users    []teamUserImport `json:"users"`
+}
+
+// title: team users export
+// path: /teams/{name}/users
+// method: GET
+// produce: text/csv
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Team not found
+func teamUsersExport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermTeamRead, permission.Context(permission.CtxTeam, name)) {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	}
+	_, err := auth.GetTeam(name)
+	if err != nil {
+		if err == auth.ErrTeamNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+		}
+		return err
+	}
+	users, err := auth.ListUsers()
+	if err != nil {
+		return err
+	}
+	roles, err := permission.ListRoles()
+	if err != nil {
+		return err
+	}
+	teamRoles := map[string]bool{}
+	for _, role := range roles {
+		teamRoles[role.Name] = role.ContextType == permission.CtxTeam
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	writer := csv.NewWriter(w)
+	writer.Write(teamUsersCSVHeader)
+	for _, u := range users {
+		for _, roleInstance := range u.Roles {
+			if teamRoles[roleInstance.Name] && roleInstance.ContextValue == name {
+				writer.Write([]string{u.Email, roleInstance.Name})
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// title: team users import
+// path: /teams/{name}/users
+// method: POST
+// consume: text/csv
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func teamUsersImport(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermRoleUpdateAssign) {
+		return permission.ErrUnauthorized
+	}
+	_, err = auth.GetTeam(name)
+	if err != nil {
+		if err == auth.ErrTeamNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+		}
+		return err
+	}
+	imports, err := parseTeamUsers(r.Body)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermRoleUpdateAssign,
+		Owner:      t,
+		CustomData: imports,
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	var importErr error
+	defer func() {
+		if err == nil {
+			err = importErr
+		}
+		evt.Done(err)
+	}()
+	result := teamUsersImportResult{Users: imports}
+	result.Imported = validateTeamUsers(t, name, imports)
+	if result.Imported {
+		result.Imported = addTeamUsers(name, imports)
+	}
+	if !result.Imported {
+		for i := range imports {
+			if imports[i].Error == "" {
+				imports[i].Error = "not imported due to other failures"
+			}
+		}
+		importErr = fmt.Errorf("unable to import users to team %q, no roles were assigned", name)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+func parseTeamUsers(body io.Reader) ([]teamUserImport, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var imports []teamUserImport
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(strings.Join(record, ","), strings.Join(teamUsersCSVHeader, ",")) {
+			continue
+		}
+		item := teamUserImport{Line: line}
+		if len(record) != len(teamUsersCSVHeader) {
+			item.Error = "each line must have the email of the user and the role to assign"
+		} else {
+			item.Email, item.Role = strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		}
+		imports = append(imports, item)
+	}
+	if len(imports) == 0 {
+		return nil, fmt.Errorf("no users to import")
+	}
+	return imports, nil
+}
+
+func validateTeamUsers(t auth.Token, team string, imports []teamUserImport) bool {
+	valid := true
+	for i := range imports {
+		item := &imports[i]
+		if item.Error == "" {
+			item.user, item.Error = validateTeamUser(t, team, item)
+		}
+		if item.Error != "" {
+			valid = false
+		}
+	}
+	return valid
+}
+
+func validateTeamUser(t auth.Token, team string, item *teamUserImport) (*auth.User, string) {
+	user, err := auth.GetUserByEmail(item.Email)
+	if err != nil {
+		return nil, err.Error()
+	}
+	role, err := permission.FindRole(item.Role)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if role.ContextType != permission.CtxTeam {
+		return nil, fmt.Sprintf("role %q is not a team role", role.Name)
+	}
+	if err = canUseRole(t, role.Name, team); err != nil {
+		return nil, err.Error()
+	}
+	return user, ""
+}
+
+// addTeamUsers assigns the roles of the validated imports, removing the
+// roles already assigned by the import if any of them fails.
+func addTeamUsers(team string, imports []teamUserImport) bool {
+	users := make([]auth.User, len(imports))
+	for i := range imports {
+		users[i] = *imports[i].user
+	}
+	err := runWithPermSync(users, func() error {
+		for i := range imports {
+			item := &imports[i]
+			if hasRole(item.user, item.Role, team) {
+				continue
+			}
+			if err := item.user.AddRole(item.Role, team); err != nil {
+				item.Error = err.Error()
+				return err
+			}
+			item.added = true
+		}
+		return nil
+	})
+	if err == nil {
+		return true
+	}
+	for i := range imports {
+		item := &imports[i]
+		if item.added {
+			if rollbackErr := item.user.RemoveRole(item.Role, team); rollbackErr != nil {
+				item.Error = fmt.Sprintf("unable to roll back the role assignment: %s", rollbackErr)
+			}
+		}
+	}
+	return false
+}
+
+func hasRole(u *auth.User, roleName, contextValue string) bool {
+	for _, r := range u.Roles {
+		if r.Name == roleName && r.ContextValue == contextValue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *AuthSuite) createTeamUsers(c *check.C, emails ...string) []*auth.User {
+	var users []*auth.User
+	for _, email := range emails {
+		u := &auth.User{Email: email, Password: "123456"}
+		_, err := nativeScheme.Create(u)
+		c.Assert(err, check.IsNil)
+		users = append(users, u)
+	}
+	return users
+}
+
+func (s *AuthSuite) importTeamUsers(c *check.C, body string) (*httptest.ResponseRecorder, teamUsersImportResult) {
+	request, err := http.NewRequest("POST", "/teams/"+s.team.Name+"/users", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "text/csv")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	var result teamUsersImportResult
+	if recorder.Code == http.StatusOK {
+		err = json.NewDecoder(recorder.Body).Decode(&result)
+		c.Assert(err, check.IsNil)
+	}
+	return recorder, result
+}
+
+func (s *AuthSuite) TestTeamUsersExport(c *check.C) {
+	role, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	users := s.createTeamUsers(c, "chico@tsuru.io", "zeca@tsuru.io")
+	err = users[0].AddRole(role.Name, s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = users[1].AddRole(role.Name, s.team2.Name)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name+"/users", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/csv")
+	c.Assert(recorder.Body.String(), check.Equals, "email,role\nchico@tsuru.io,team-member\n")
+}
+
+func (s *AuthSuite) TestTeamUsersExportWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamRead,
+		Context: permission.Context(permission.CtxTeam, s.team2.Name),
+	})
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name+"/users", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *AuthSuite) TestTeamUsersImport(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	_, err = permission.NewRole("team-admin", "team", "")
+	c.Assert(err, check.IsNil)
+	s.createTeamUsers(c, "chico@tsuru.io", "zeca@tsuru.io")
+	recorder, result := s.importTeamUsers(c, "email,role\nchico@tsuru.io,team-member\nzeca@tsuru.io, team-admin\n")
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(result, check.DeepEquals, teamUsersImportResult{
+		Imported: true,
+		Users: []teamUserImport{
+			{Line: 2, Email: "chico@tsuru.io", Role: "team-member"},
+			{Line: 3, Email: "zeca@tsuru.io", Role: "team-admin"},
+		},
+	})
+	u, err := auth.GetUserByEmail("chico@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.DeepEquals, []auth.RoleInstance{{Name: "team-member", ContextValue: s.team.Name}})
+	u, err = auth.GetUserByEmail("zeca@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.DeepEquals, []auth.RoleInstance{{Name: "team-admin", ContextValue: s.team.Name}})
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "role.update.assign",
+		StartCustomData: []map[string]interface{}{
+			{"line": 2, "email": "chico@tsuru.io", "role": "team-member"},
+			{"line": 3, "email": "zeca@tsuru.io", "role": "team-admin"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *AuthSuite) TestTeamUsersImportIsAllOrNothing(c *check.C) {
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	_, err = permission.NewRole("global-reader", "global", "")
+	c.Assert(err, check.IsNil)
+	s.createTeamUsers(c, "chico@tsuru.io", "zeca@tsuru.io")
+	body := "chico@tsuru.io,team-member\nunknown@tsuru.io,team-member\nzeca@tsuru.io,global-reader\nzeca@tsuru.io\n"
+	recorder, result := s.importTeamUsers(c, body)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(result, check.DeepEquals, teamUsersImportResult{
+		Imported: false,
+		Users: []teamUserImport{
+			{Line: 1, Email: "chico@tsuru.io", Role: "team-member", Error: "not imported due to other failures"},
+			{Line: 2, Email: "unknown@tsuru.io", Role: "team-member", Error: auth.ErrUserNotFound.Error()},
+			{Line: 3, Email: "zeca@tsuru.io", Role: "global-reader", Error: `role "global-reader" is not a team role`},
+			{Line: 4, Error: "each line must have the email of the user and the role to assign"},
+		},
+	})
+	u, err := auth.GetUserByEmail("chico@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "role.update.assign",
+		StartCustomData: []map[string]interface{}{
+			{"line": 1, "email": "chico@tsuru.io", "role": "team-member"},
+			{"line": 2, "email": "unknown@tsuru.io", "role": "team-member"},
+			{"line": 3, "email": "zeca@tsuru.io", "role": "global-reader"},
+			{"line": 4, "email": "", "role": "", "error": "each line must have the email of the user and the role to assign"},
+		},
+		ErrorMatches: `unable to import users to team "tsuruteam", no roles were assigned`,
+	}, eventtest.HasEvent)
+}
+
+func (s *AuthSuite) TestTeamUsersImportEmptyFile(c *check.C) {
+	recorder, _ := s.importTeamUsers(c, "email,role\n")
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "no users to import\n")
+}
+
+func (s *AuthSuite) TestTeamUsersImportWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/teams/"+s.team.Name+"/users", strings.NewReader("chico@tsuru.io,team-member\n"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -10,4 +10,5 @@ type Envs struct {
 	Envs      []struct{ Name, Value string }
 	NoRestart bool
 	Private   bool
+	Secret    bool
 }
@@ -0,0 +1,142 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const (
+	userActivityEventsLimit = 20
+	defaultInactiveDays     = 90
+)
+
+type userActivityEvent struct {
+	ID        string
+	Kind      string
+	Target    event.Target
+	StartTime time.Time
+	EndTime   time.Time `json:",omitempty"`
+	Error     string    `json:",omitempty"`
+	Running   bool
+}
+
+type userActivity struct {
+	Email        string
+	LastLogin    time.Time            `json:",omitempty"`
+	LastActivity time.Time            `json:",omitempty"`
+	TokenUsage   map[string]time.Time `json:",omitempty"`
+	Apps         []string
+	Events       []userActivityEvent
+}
+
+// title: user activity
+// path: /users/{email}/activity
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: User not found
+func userActivityInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	email := r.URL.Query().Get(":email")
+	if !permission.Check(t, permission.PermUserReadActivity, permission.Context(permission.CtxUser, email)) {
+		return permission.ErrUnauthorized
+	}
+	_, err := auth.GetUserByEmail(email)
+	if err == auth.ErrUserNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	activity, err := auth.GetActivity(email)
+	if err != nil {
+		return err
+	}
+	apps, err := app.List(&app.Filter{UserOwner: email})
+	if err != nil {
+		return err
+	}
+	perms, err := t.Permissions()
+	if err != nil {
+		return err
+	}
+	evts, err := event.List(&event.Filter{
+		OwnerType:   event.OwnerTypeUser,
+		OwnerName:   email,
+		Permissions: perms,
+		Limit:       userActivityEventsLimit,
+	})
+	if err != nil {
+		return err
+	}
+	result := userActivity{
+		Email:        email,
+		LastLogin:    activity.LastLogin,
+		LastActivity: activity.LastActivity(),
+		TokenUsage:   activity.TokenUsage,
+		Apps:         make([]string, len(apps)),
+		Events:       make([]userActivityEvent, len(evts)),
+	}
+	for i := range apps {
+		result.Apps[i] = apps[i].Name
+	}
+	for i := range evts {
+		result.Events[i] = userActivityEvent{
+			ID:        evts[i].UniqueID.Hex(),
+			Kind:      evts[i].Kind.Name,
+			Target:    evts[i].Target,
+			StartTime: evts[i].StartTime,
+			EndTime:   evts[i].EndTime,
+			Error:     evts[i].Error,
+			Running:   evts[i].Running,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: inactive users report
+// path: /users/inactive
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid days
+//   401: Unauthorized
+func inactiveUsersReport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermUserReadActivity) {
+		return permission.ErrUnauthorized
+	}
+	days := defaultInactiveDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "days must be a positive integer"}
+		}
+	}
+	users, err := auth.InactiveUsers(time.Now().UTC().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(users)
+}
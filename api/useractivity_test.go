@@ -0,0 +1,147 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestUserActivity(c *check.C) {
+	user, _ := permissiontest.CustomUserWithPermission(c, nativeScheme, "activityuser", permission.Permission{
+		Scheme:  permission.PermUserReadActivity,
+		Context: permission.Context(permission.CtxUser, "activityuser@groundcontrol.com"),
+	})
+	request, err := http.NewRequest("POST", "/users/"+user.Email+"/tokens", strings.NewReader("password=123456"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var login map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &login)
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "activityapp", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   appTarget(a.Name),
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: user.Email},
+		Allowed:  event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	request, err = http.NewRequest("GET", "/1.3/users/"+user.Email+"/activity", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+login["token"])
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result userActivity
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Email, check.Equals, user.Email)
+	c.Assert(result.LastLogin.IsZero(), check.Equals, false)
+	c.Assert(result.TokenUsage[auth.TokenKindSession].IsZero(), check.Equals, false)
+	c.Assert(result.LastActivity.Before(result.LastLogin), check.Equals, false)
+	c.Assert(result.Apps, check.DeepEquals, []string{"activityapp"})
+	c.Assert(result.Events, check.HasLen, 0)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Events, check.HasLen, 1)
+	c.Assert(result.Events[0].Kind, check.Equals, "app.deploy")
+	c.Assert(result.Events[0].Target, check.Equals, appTarget(a.Name))
+}
+
+func (s *S) TestUserActivityForbidden(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermUserReadActivity,
+		Context: permission.Context(permission.CtxUser, "majortom@groundcontrol.com"),
+	})
+	request, err := http.NewRequest("GET", "/1.3/users/"+s.user.Email+"/activity", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestUserActivityUserNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/users/unknown@example.com/activity", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestInactiveUsersReport(c *check.C) {
+	inactive := auth.User{Email: "inactive@example.com", Password: "123456"}
+	err := inactive.Create()
+	c.Assert(err, check.IsNil)
+	active := auth.User{Email: "active@example.com", Password: "123456"}
+	err = active.Create()
+	c.Assert(err, check.IsNil)
+	err = auth.RecordLogin(active.Email)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.3/users/inactive?days=30", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var users []auth.InactiveUser
+	err = json.Unmarshal(recorder.Body.Bytes(), &users)
+	c.Assert(err, check.IsNil)
+	emails := make([]string, len(users))
+	for i := range users {
+		emails[i] = users[i].Email
+	}
+	c.Assert(emails, check.Not(check.HasLen), 0)
+	var found bool
+	for _, email := range emails {
+		c.Assert(email, check.Not(check.Equals), active.Email)
+		found = found || email == inactive.Email
+	}
+	c.Assert(found, check.Equals, true)
+}
+
+func (s *S) TestInactiveUsersReportInvalidDays(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.3/users/inactive?days=-1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestInactiveUsersReportForbidden(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermUserReadActivity,
+		Context: permission.Context(permission.CtxUser, "majortom@groundcontrol.com"),
+	})
+	request, err := http.NewRequest("GET", "/1.3/users/inactive", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	RunServer(true).ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
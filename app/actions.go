@@ -17,6 +17,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router"
@@ -59,6 +60,11 @@ var reserveUserApp = action.Action{
 		if err := auth.ReserveApp(usr); err != nil {
 			return nil, err
 		}
+		// ReserveApp sets the quota the app was reserved from, so concurrent
+		// reservations don't warn twice, or never, about the soft limit.
+		if usr.Quota.CrossesSoftLimit(1) {
+			warnUserSoftLimit(usr, 1)
+		}
 		return map[string]string{"app": app.Name, "user": user.Email}, nil
 	},
 	Backward: func(ctx action.BWContext) {
@@ -348,6 +354,14 @@ var reserveUnitsToAdd = action.Action{
 		if err != nil {
 			return nil, ErrAppNotFound
 		}
+		var process string
+		if len(ctx.Params) > 3 {
+			process, _ = ctx.Params[3].(string)
+		}
+		err = checkResourceQuotas(app, 0, n, int64(n)*app.GetProcessMemory(process))
+		if err != nil {
+			return nil, err
+		}
 		err = reserveUnits(app, n)
 		if err != nil {
 			return nil, err
@@ -386,6 +400,9 @@ var provisionAddUnits = action.Action{
 		if err != nil {
 			return nil, err
 		}
+		if gatedProv, ok := prov.(provision.GatedUnitsAdder); ok {
+			return gatedProv.AddUnitsGated(app, uint(n), process, w)
+		}
 		return nil, prov.AddUnits(app, uint(n), process, w)
 	},
 	MinParams: 1,
@@ -500,7 +517,7 @@ var restartApp = action.Action{
 		if !ok {
 			return nil, errors.New("invalid previous result, should be changePlanPipelineResult")
 		}
-		err := result.app.Restart("", w)
+		err := result.app.Restart(writerContext(w), "", w)
 		if err != nil {
 			return nil, err
 		}
@@ -5,12 +5,14 @@
 package app
 
 import (
+	"context"
 	"errors"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router/routertest"
@@ -172,7 +174,7 @@ func (s *S) TestExportEnvironmentsForward(c *check.C) {
 	c.Assert(appEnv["TSURU_APP_TOKEN"].Public, check.Equals, false)
 	c.Assert(appEnv["TSURU_APPDir"].Value, check.Not(check.Equals), "/home/application/current")
 	c.Assert(appEnv["TSURU_APPDir"].Public, check.Equals, false)
-	t, err := nativeScheme.Auth(appEnv["TSURU_APP_TOKEN"].Value)
+	t, err := nativeScheme.Auth(context.Background(), appEnv["TSURU_APP_TOKEN"].Value)
 	c.Assert(err, check.IsNil)
 	c.Assert(t.IsAppToken(), check.Equals, true)
 	c.Assert(t.GetAppName(), check.Equals, app.Name)
@@ -207,7 +209,7 @@ func (s *S) TestExportEnvironmentsBackward(c *check.C) {
 			c.Errorf("Variable %q should be unexported, but it's still exported.", name)
 		}
 	}
-	_, err = nativeScheme.Auth(token.GetValue())
+	_, err = nativeScheme.Auth(context.Background(), token.GetValue())
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
@@ -349,6 +351,30 @@ func (s *S) TestReserveUserAppForward(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestReserveUserAppForwardSoftLimit(c *check.C) {
+	config.Set("quota:soft-limit", 80)
+	defer config.Unset("quota:soft-limit")
+	user := auth.User{
+		Email: "clap@yes.com",
+		Quota: quota.Quota{Limit: 5, InUse: 3},
+	}
+	err := user.Create()
+	c.Assert(err, check.IsNil)
+	defer s.conn.Users().Remove(bson.M{"email": user.Email})
+	app := App{Name: "clap", Platform: "django"}
+	_, err = reserveUserApp.Forward(action.FWContext{Params: []interface{}{&app, &user}})
+	c.Assert(err, check.IsNil)
+	evts, err := event.List(&event.Filter{KindName: quota.SoftLimitEventKind})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target, check.Equals, event.Target{Type: event.TargetTypeUser, Value: user.Email})
+	_, err = reserveUserApp.Forward(action.FWContext{Params: []interface{}{&app, &user}})
+	c.Assert(err, check.IsNil)
+	evts, err = event.List(&event.Filter{KindName: quota.SoftLimitEventKind})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+}
+
 func (s *S) TestReserveUserAppForwardNonPointer(c *check.C) {
 	user := auth.User{
 		Email: "clap@yes.com",
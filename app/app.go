@@ -5,6 +5,7 @@
 package app
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -32,6 +33,7 @@ import (
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
+	"github.com/tsuru/tsuru/secret"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -42,10 +44,11 @@ var AuthScheme auth.Scheme
 var (
 	nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
 
-	ErrAlreadyHaveAccess = errors.New("team already have access to this app")
-	ErrNoAccess          = errors.New("team does not have access to this app")
-	ErrCannotOrphanApp   = errors.New("cannot revoke access from this team, as it's the unique team with access to the app")
-	ErrDisabledPlatform  = errors.New("Disabled Platform, only admin users can create applications with the platform")
+	ErrAlreadyHaveAccess   = errors.New("team already have access to this app")
+	ErrNoAccess            = errors.New("team does not have access to this app")
+	ErrCannotOrphanApp     = errors.New("cannot revoke access from this team, as it's the unique team with access to the app")
+	ErrDisabledPlatform    = errors.New("Disabled Platform, only admin users can create applications with the platform")
+	ErrRestartNotResumable = errors.New("only finished restarts of the app can be resumed")
 )
 
 const (
@@ -111,31 +114,66 @@ func (l *AppLock) GetAcquireDate() time.Time {
 // This struct holds information about the app: its name, address, list of
 // teams that have access to it, used platform, etc.
 type App struct {
-	Env            map[string]bind.EnvVar
-	Platform       string `bson:"framework"`
-	Name           string
-	Ip             string
-	CName          []string
-	Teams          []string
-	TeamOwner      string
-	Owner          string
-	Plan           Plan
-	UpdatePlatform bool
-	Lock           AppLock
-	Pool           string
-	Description    string
-	Router         string
-	RouterOpts     map[string]string
-	Deploys        uint
-	Tags           []string
+	Env                map[string]bind.EnvVar
+	Platform           string `bson:"framework"`
+	Name               string
+	Ip                 string
+	CName              []string
+	Teams              []string
+	TeamOwner          string
+	Owner              string
+	Plan               Plan
+	UpdatePlatform     bool
+	Lock               AppLock
+	Pool               string
+	Description        string
+	Router             string
+	RouterOpts         map[string]string
+	Deploys            uint
+	Tags               []string
+	BuildCacheDisabled bool
+	// EnvReferences holds the names of the apps referenced by the
+	// environment variables of the app.
+	EnvReferences []string `bson:",omitempty"`
+	// RateLimit is the limit of requests enforced by the router of the app,
+	// nil when requests are not limited.
+	RateLimit *router.RateLimit `bson:",omitempty"`
+	// RequireDeployApproval makes deploys of the app wait for the approval
+	// of another user before being started.
+	RequireDeployApproval bool `bson:",omitempty"`
+	// ProcessPlans holds the plans of processes whose units don't use the
+	// plan of the app, by process name.
+	ProcessPlans map[string]Plan `bson:",omitempty"`
+	// Healthcheck is the healthcheck and restart policy of the units of the
+	// app, nil when the healthcheck in the tsuru.yaml is used.
+	Healthcheck *provision.AppHealthcheck `bson:",omitempty"`
+	// Dependencies holds the apps and service instances the app depends on,
+	// waited for before deploys and restarts of the app.
+	Dependencies []AppDependency `bson:",omitempty"`
+	// Labels are key/value pairs used to group apps, like by cost center or
+	// product, unlike Tags, that are plain values.
+	Labels map[string]string `bson:",omitempty"`
+	// ReviewApps enables review apps, copies of the app deploying branches
+	// of a repository, when the app is a template for them.
+	ReviewApps *ReviewAppsConfig `bson:",omitempty"`
+	// ReviewApp is set when the app is the review app of a branch.
+	ReviewApp *ReviewApp `bson:",omitempty"`
+	// Maintenance is set while the app is in maintenance, with its router
+	// serving a maintenance page instead of routing requests to its units.
+	Maintenance *Maintenance `bson:",omitempty"`
 
 	quota.Quota
 	provisioner provision.Provisioner
+	// deployStrategy is the strategy of the deploy being run, read by
+	// provisioners implementing provision.StrategyDeployer.
+	deployStrategy provision.DeployStrategy
 }
 
 var (
-	_ provision.App      = &App{}
-	_ rebuild.RebuildApp = &App{}
+	_ provision.App            = &App{}
+	_ provision.StrategyApp    = &App{}
+	_ provision.ProcessPlanApp = &App{}
+	_ rebuild.RebuildApp       = &App{}
 )
 
 func (app *App) getProvisioner() (provision.Provisioner, error) {
@@ -198,6 +236,33 @@ func (app *App) MarshalJSON() ([]byte, error) {
 	result["router"] = app.Router
 	result["lock"] = app.Lock
 	result["tags"] = app.Tags
+	if len(app.Labels) > 0 {
+		result["labels"] = app.Labels
+	}
+	if app.RateLimit != nil {
+		result["ratelimit"] = app.RateLimit
+	}
+	if app.RequireDeployApproval {
+		result["requiredeployapproval"] = true
+	}
+	if len(app.ProcessPlans) > 0 {
+		result["processplans"] = app.ProcessPlans
+	}
+	if app.Healthcheck != nil {
+		result["healthcheck"] = app.Healthcheck
+	}
+	if len(app.Dependencies) > 0 {
+		result["dependencies"] = app.Dependencies
+	}
+	if app.ReviewApps != nil {
+		result["reviewapps"] = app.ReviewApps
+	}
+	if app.ReviewApp != nil {
+		result["reviewapp"] = app.ReviewApp
+	}
+	if app.Maintenance != nil {
+		result["maintenance"] = app.Maintenance
+	}
 	return json.Marshal(&result)
 }
 
@@ -266,8 +331,14 @@ func ReleaseApplicationLock(appName string) {
 // GetByName queries the database to find an app identified by the given
 // name.
 func GetByName(name string) (*App, error) {
+	return GetByNameContext(context.Background(), name)
+}
+
+// GetByNameContext is like GetByName, with the query bound to ctx, see
+// db.ConnContext.
+func GetByNameContext(ctx context.Context, name string) (*App, error) {
 	var app App
-	conn, err := db.Conn()
+	conn, err := db.ConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -287,8 +358,11 @@ func GetByName(name string) (*App, error) {
 //       2. Create the git repository using the repository manager
 //       3. Provision the app using the provisioner
 func CreateApp(app *App, user *auth.User) error {
+	err := app.applyPoolDefaults()
+	if err != nil {
+		return err
+	}
 	var plan *Plan
-	var err error
 	if app.Plan.Name == "" {
 		plan, err = DefaultPlan()
 	} else {
@@ -313,10 +387,15 @@ func CreateApp(app *App, user *auth.User) error {
 	app.Teams = []string{app.TeamOwner}
 	app.Owner = user.Email
 	app.Tags = processTags(app.Tags)
+	app.Labels = provision.MergeLabels(nil, app.Labels)
 	err = app.validate()
 	if err != nil {
 		return err
 	}
+	err = checkResourceQuotas(app, 1, 0, 0)
+	if err != nil {
+		return err
+	}
 	actions := []*action.Action{
 		&reserveUserApp,
 		&insertApp,
@@ -354,6 +433,7 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	}
 	oldPlan := app.Plan
 	oldRouter := app.Router
+	oldIp := app.Ip
 	if routerName != "" {
 		_, err = router.Get(routerName)
 		if err != nil {
@@ -383,10 +463,46 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	if tags != nil {
 		app.Tags = tags
 	}
+	if len(updateData.Labels) > 0 {
+		app.Labels = provision.MergeLabels(app.Labels, updateData.Labels)
+	}
 	err = app.validate()
 	if err != nil {
 		return err
 	}
+	if app.Router != oldRouter && app.RateLimit != nil {
+		var rlRouter router.RateLimitRouter
+		rlRouter, err = app.rateLimitRouter()
+		if err != nil {
+			return err
+		}
+		err = router.ValidateRateLimit(*app.RateLimit, rlRouter.RateLimitSupport())
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err == nil {
+				err = rlRouter.SetRateLimit(app.GetName(), *app.RateLimit)
+			}
+		}()
+	}
+	if app.Router != oldRouter && app.Maintenance != nil {
+		var mRouter router.MaintenanceRouter
+		mRouter, err = app.maintenanceRouter()
+		if err != nil {
+			return err
+		}
+		var page string
+		page, err = maintenancePage()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err == nil {
+				err = mRouter.SetMaintenancePage(app.GetName(), page)
+			}
+		}()
+	}
 	if app.Router != oldRouter || app.Plan != oldPlan {
 		actions := []*action.Action{
 			&moveRouterUnits,
@@ -404,7 +520,14 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 		return err
 	}
 	defer conn.Close()
-	return conn.Apps().Update(bson.M{"name": app.Name}, app)
+	err = conn.Apps().Update(bson.M{"name": app.Name}, app)
+	if err != nil {
+		return err
+	}
+	if app.Ip != oldIp {
+		updateEnvReferences(app.Name)
+	}
+	return nil
 }
 
 func processTags(tags []string) []string {
@@ -459,6 +582,14 @@ func Delete(app *App, w io.Writer) error {
 	if isSwapped {
 		return errors.Errorf("application is swapped with %q, cannot remove it", swappedWith)
 	}
+	err = app.checkNotReferenced()
+	if err != nil {
+		return err
+	}
+	err = app.checkNotDependedOn()
+	if err != nil {
+		return err
+	}
 	appName := app.Name
 	if w == nil {
 		w = ioutil.Discard
@@ -517,13 +648,12 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to release app quota", err)
 	}
-	logConn, err := db.LogConn()
+	logStorage, err := CurrentLogStorage()
 	if err == nil {
-		defer logConn.Close()
-		err = logConn.Logs(appName).DropCollection()
+		err = logStorage.Remove(appName)
 	}
 	if err != nil {
-		logErr("Unable to remove logs collection", err)
+		logErr("Unable to remove logs", err)
 	}
 	conn, err := db.Conn()
 	if err == nil {
@@ -533,6 +663,27 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to remove app from db", err)
 	}
+	err = removeEnvRevisions(appName)
+	if err != nil {
+		logErr("Unable to remove env revisions", err)
+	}
+	err = removeJobs(appName)
+	if err != nil {
+		logErr("Unable to remove jobs", err)
+	}
+	var secrets []string
+	for _, env := range app.Env {
+		if env.Secret {
+			secrets = append(secrets, env.Value)
+		}
+	}
+	removeSecrets(appName, secrets)
+	if app.Maintenance != nil {
+		err = finishMaintenance(app.Maintenance)
+		if err != nil {
+			logErr("Unable to finish maintenance", err)
+		}
+	}
 	err = event.MarkAsRemoved(event.Target{Type: event.TargetTypeApp, Value: appName})
 	if err != nil {
 		logErr("Unable to mark old events as removed", err)
@@ -598,16 +749,44 @@ func (app *App) serviceInstances() ([]service.ServiceInstance, error) {
 // AddUnits creates n new units within the provisioner, saves new units in the
 // database and enqueues the apprc serialization.
 func (app *App) AddUnits(n uint, process string, w io.Writer) error {
+	_, err := app.AddUnitsGated(n, process, w)
+	return err
+}
+
+// AddUnitsGated adds n units to the app like AddUnits, returning the result
+// of gating the traffic to each new unit on its healthcheck. Results are only
+// returned by provisioners implementing provision.GatedUnitsAdder, units
+// removed after failing the healthcheck are released from the app quota.
+func (app *App) AddUnitsGated(n uint, process string, w io.Writer) ([]provision.UnitGateResult, error) {
 	if n == 0 {
-		return errors.New("Cannot add zero units.")
+		return nil, errors.New("Cannot add zero units.")
 	}
 	w = app.withLogWriter(w)
-	err := action.NewPipeline(
+	pipeline := action.NewPipeline(
 		&reserveUnitsToAdd,
 		&provisionAddUnits,
-	).Execute(app, n, w, process)
+	)
+	err := pipeline.Execute(app, n, w, process)
 	rebuild.RoutesRebuildOrEnqueue(app.Name)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	results, _ := pipeline.Result().([]provision.UnitGateResult)
+	for _, r := range results {
+		if r.Removed {
+			err = app.releaseRemovedUnitsQuota()
+			break
+		}
+	}
+	return results, err
+}
+
+func (app *App) releaseRemovedUnitsQuota() error {
+	units, err := app.Units()
+	if err != nil {
+		return err
+	}
+	return app.SetQuotaInUse(len(units))
 }
 
 // RemoveUnits removes n units from the app. It's a process composed of
@@ -900,6 +1079,18 @@ func (app *App) getEnv(name string) (bind.EnvVar, error) {
 	return env, err
 }
 
+// removeSecrets removes from the secrets backend the values of secret
+// variables no longer set in the app. Failures are only logged, as the
+// variables were already changed.
+func removeSecrets(appName string, refs []string) {
+	for _, ref := range refs {
+		err := secret.Remove(ref)
+		if err != nil {
+			log.Errorf("[secrets] unable to remove secret of app %q: %s", appName, err)
+		}
+	}
+}
+
 // validate checks app name format
 func (app *App) validate() error {
 	if app.Name == InternalAppName || !nameRegexp.MatchString(app.Name) {
@@ -1011,19 +1202,47 @@ func (app *App) run(cmd string, w io.Writer, args provision.RunArgs) error {
 	return execProv.ExecuteCommand(w, w, app, cmd)
 }
 
-// Restart runs the restart hook for the app, writing its output to w.
-func (app *App) Restart(process string, w io.Writer) error {
+// Restart runs the restart hook for the app, writing its output to w. The
+// restart is aborted once ctx is done.
+func (app *App) Restart(ctx context.Context, process string, w io.Writer) error {
+	return app.RollingRestart(ctx, provision.RestartProgress{Process: process}, w)
+}
+
+// RollingRestart restarts the units of the app in batches, limited by the
+// rolling updates in progress, when supported by the provisioner. Units
+// already restarted by a previous interrupted restart, listed in progress,
+// are skipped. When w is an event, the progress is recorded in its custom
+// data, see RestartProgressFromEvent. The restart is aborted once ctx is
+// done.
+func (app *App) RollingRestart(ctx context.Context, progress provision.RestartProgress, w io.Writer) error {
+	evt, _ := w.(*event.Event)
 	w = app.withLogWriter(w)
-	msg := fmt.Sprintf("---- Restarting process %q ----", process)
-	if process == "" {
+	msg := fmt.Sprintf("---- Restarting process %q ----", progress.Process)
+	if progress.Process == "" {
 		msg = fmt.Sprintf("---- Restarting the app %q ----", app.Name)
 	}
 	fmt.Fprintf(w, "%s\n", msg)
+	err := app.waitDependencies(ctx, w)
+	if err != nil {
+		return err
+	}
 	prov, err := app.getProvisioner()
 	if err != nil {
 		return err
 	}
-	err = prov.Restart(app, process, w)
+	if restarter, ok := prov.(provision.RollingRestarter); ok {
+		args := provision.RollingRestartArgs{Progress: progress, Event: evt}
+		if evt != nil {
+			args.OnProgress = func(p provision.RestartProgress) {
+				if progressErr := evt.SetOtherCustomData(p); progressErr != nil {
+					log.Errorf("[restart] unable to record restart progress of app %s: %s", app.Name, progressErr)
+				}
+			}
+		}
+		err = restarter.RollingRestart(ctx, app, args, w)
+	} else {
+		err = prov.Restart(ctx, app, progress.Process, w)
+	}
 	if err != nil {
 		log.Errorf("[restart] error on restart the app %s - %s", app.Name, err)
 		return err
@@ -1032,7 +1251,37 @@ func (app *App) Restart(process string, w io.Writer) error {
 	return nil
 }
 
-func (app *App) Stop(w io.Writer, process string) error {
+// RestartProgressFromEvent returns the progress recorded by the restart of
+// the app in the event with the given id, to be resumed by RollingRestart.
+func (app *App) RestartProgressFromEvent(id string) (*provision.RestartProgress, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, event.ErrEventNotFound
+	}
+	evt, err := event.GetByID(bson.ObjectIdHex(id))
+	if err != nil {
+		return nil, err
+	}
+	target := event.Target{Type: event.TargetTypeApp, Value: app.Name}
+	if evt.Kind.Name != permission.PermAppUpdateRestart.FullName() || evt.Target != target || evt.Running {
+		return nil, ErrRestartNotResumable
+	}
+	var progress provision.RestartProgress
+	err = evt.OtherData(&progress)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Process == "" {
+		var data []map[string]interface{}
+		if evt.StartData(&data) == nil {
+			progress.Process = event.CustomDataToForm(data).Get("process")
+		}
+	}
+	return &progress, nil
+}
+
+// Stop stops the app calling the provisioner.Stop method, aborted once ctx
+// is done.
+func (app *App) Stop(ctx context.Context, w io.Writer, process string) error {
 	w = app.withLogWriter(w)
 	msg := fmt.Sprintf("\n ---> Stopping the process %q", process)
 	if process == "" {
@@ -1043,7 +1292,7 @@ func (app *App) Stop(w io.Writer, process string) error {
 	if err != nil {
 		return err
 	}
-	err = prov.Stop(app, process)
+	err = prov.Stop(ctx, app, process)
 	if err != nil {
 		log.Errorf("[stop] error on stop the app %s - %s", app.Name, err)
 		return err
@@ -1232,6 +1481,7 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 	if w != nil {
 		fmt.Fprintf(w, "---- Setting %d new environment variables ----\n", len(setEnvs.Envs))
 	}
+	var replacedSecrets []string
 	for _, env := range setEnvs.Envs {
 		set := true
 		if setEnvs.PublicOnly {
@@ -1240,19 +1490,48 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 				set = false
 			}
 		}
-		if set {
-			app.setEnv(env)
+		if !set {
+			continue
+		}
+		if env.Secret {
+			// Secret values are stored in the secrets backend, and never
+			// parsed for references to other apps.
+			ref, err := secret.Store(app.Name, env.Name, env.Value)
+			if err != nil {
+				return err
+			}
+			env.Value = ref
+			env.Public = false
+			env.Reference = ""
+		} else {
+			if env.Reference == "" && len(EnvReferences(env.Value)) > 0 {
+				env.Reference = env.Value
+			}
+			if env.Reference != "" {
+				value, err := app.resolveEnvReferences(env.Reference)
+				if err != nil {
+					return err
+				}
+				env.Value = value
+			}
 		}
+		if old, ok := app.Env[env.Name]; ok && old.Secret && old.Value != env.Value {
+			replacedSecrets = append(replacedSecrets, old.Value)
+		}
+		app.setEnv(env)
 	}
+	app.EnvReferences = app.envReferences()
 	conn, err := db.Conn()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env}})
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env, "envreferences": app.EnvReferences}})
 	if err != nil {
 		return err
 	}
+	removeSecrets(app.Name, replacedSecrets)
+	defer updateEnvReferences(app.Name)
 	if !setEnvs.ShouldRestart {
 		return nil
 	}
@@ -1260,7 +1539,7 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return prov.Restart(app, "", w)
+	return prov.Restart(writerContext(w), app, "", w)
 }
 
 // UnsetEnvs removes environment variables from an app, serializing the
@@ -1288,25 +1567,32 @@ func (app *App) unsetEnvsToApp(unsetEnvs bind.UnsetEnvApp, w io.Writer) error {
 	if w != nil {
 		fmt.Fprintf(w, "---- Unsetting %d environment variables ----\n", len(unsetEnvs.VariableNames))
 	}
+	var removedSecrets []string
 	for _, name := range unsetEnvs.VariableNames {
 		var unset bool
 		e, err := app.getEnv(name)
-		if !unsetEnvs.PublicOnly || (err == nil && e.Public) {
+		if !unsetEnvs.PublicOnly || (err == nil && (e.Public || e.Secret)) {
 			unset = true
 		}
 		if unset {
+			if e.Secret {
+				removedSecrets = append(removedSecrets, e.Value)
+			}
 			delete(app.Env, name)
 		}
 	}
+	app.EnvReferences = app.envReferences()
 	conn, err := db.Conn()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env}})
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env, "envreferences": app.EnvReferences}})
 	if err != nil {
 		return err
 	}
+	removeSecrets(app.Name, removedSecrets)
+	defer updateEnvReferences(app.Name)
 	if !unsetEnvs.ShouldRestart {
 		return nil
 	}
@@ -1314,7 +1600,7 @@ func (app *App) unsetEnvsToApp(unsetEnvs bind.UnsetEnvApp, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return prov.Restart(app, "", w)
+	return prov.Restart(writerContext(w), app, "", w)
 }
 
 // AddCName adds a CName to app. It updates the attribute,
@@ -1493,7 +1779,7 @@ func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) e
 // user can filter where the message come from.
 func (app *App) Log(message, source, unit string) error {
 	messages := strings.Split(message, "\n")
-	logs := make([]interface{}, 0, len(messages))
+	logs := make([]Applog, 0, len(messages))
 	for _, msg := range messages {
 		if msg != "" {
 			l := Applog{
@@ -1507,13 +1793,17 @@ func (app *App) Log(message, source, unit string) error {
 		}
 	}
 	if len(logs) > 0 {
-		notify(app.Name, logs)
-		conn, err := db.LogConn()
+		messages := make([]interface{}, len(logs))
+		for i := range logs {
+			messages[i] = logs[i]
+		}
+		notify(app.Name, messages)
+		forwardLogs(app.Name, logs)
+		logStorage, err := CurrentLogStorage()
 		if err != nil {
 			return err
 		}
-		defer conn.Close()
-		return conn.Logs(app.Name).Insert(logs...)
+		return logStorage.Insert(app.Name, logs)
 	}
 	return nil
 }
@@ -1521,6 +1811,13 @@ func (app *App) Log(message, source, unit string) error {
 // LastLogs returns a list of the last `lines` log of the app, matching the
 // fields in the log instance received as an example.
 func (app *App) LastLogs(lines int, filterLog Applog) ([]Applog, error) {
+	return app.SearchLogs(lines, filterLog, "")
+}
+
+// SearchLogs returns a list of the last `lines` log of the app, matching the
+// fields in the log instance received as an example and the search terms, as
+// defined by the configured log storage.
+func (app *App) SearchLogs(lines int, filterLog Applog, search string) ([]Applog, error) {
 	prov, err := app.getProvisioner()
 	if err != nil {
 		return nil, err
@@ -1537,28 +1834,16 @@ func (app *App) LastLogs(lines int, filterLog Applog) ([]Applog, error) {
 			return nil, errors.New(doc)
 		}
 	}
-	conn, err := db.LogConn()
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-	logs := []Applog{}
-	q := bson.M{}
-	if filterLog.Source != "" {
-		q["source"] = filterLog.Source
-	}
-	if filterLog.Unit != "" {
-		q["unit"] = filterLog.Unit
-	}
-	err = conn.Logs(app.Name).Find(q).Sort("-$natural").Limit(lines).All(&logs)
+	logStorage, err := CurrentLogStorage()
 	if err != nil {
 		return nil, err
 	}
-	l := len(logs)
-	for i := 0; i < l/2; i++ {
-		logs[i], logs[l-1-i] = logs[l-1-i], logs[i]
-	}
-	return logs, nil
+	return logStorage.List(app.Name, LogListArgs{
+		Limit:  lines,
+		Source: filterLog.Source,
+		Unit:   filterLog.Unit,
+		Search: search,
+	})
 }
 
 type Filter struct {
@@ -1572,6 +1857,7 @@ type Filter struct {
 	Statuses    []string
 	Locked      bool
 	Tags        []string
+	Labels      provision.LabelSelector
 	Extra       map[string][]string
 }
 
@@ -1624,6 +1910,9 @@ func (f *Filter) Query() bson.M {
 	if len(tags) > 0 {
 		query["tags"] = bson.M{"$all": tags}
 	}
+	for k, v := range f.Labels.Query("labels") {
+		query[k] = v
+	}
 	return query
 }
 
@@ -1707,23 +1996,34 @@ func Swap(app1, app2 *App, cnameOnly bool) error {
 	if err != nil {
 		return err
 	}
-	return updateCName(app2, r2)
+	err = updateCName(app2, r2)
+	if err != nil {
+		return err
+	}
+	updateEnvReferences(app1.Name)
+	updateEnvReferences(app2.Name)
+	return nil
 }
 
 // Start starts the app calling the provisioner.Start method and
-// changing the units state to StatusStarted.
-func (app *App) Start(w io.Writer, process string) error {
+// changing the units state to StatusStarted. Starting is aborted once ctx is
+// done.
+func (app *App) Start(ctx context.Context, w io.Writer, process string) error {
 	w = app.withLogWriter(w)
 	msg := fmt.Sprintf("\n ---> Starting the process %q", process)
 	if process == "" {
 		msg = fmt.Sprintf("\n ---> Starting the app %q", app.Name)
 	}
 	fmt.Fprintf(w, "%s\n", msg)
+	err := app.waitDependencies(ctx, w)
+	if err != nil {
+		return err
+	}
 	prov, err := app.getProvisioner()
 	if err != nil {
 		return err
 	}
-	err = prov.Start(app, process)
+	err = prov.Start(ctx, app, process)
 	if err != nil {
 		log.Errorf("[start] error on start the app %s - %s", app.Name, err)
 		return err
@@ -1748,6 +2048,10 @@ func (app *App) GetUpdatePlatform() bool {
 	return app.UpdatePlatform
 }
 
+func (app *App) GetBuildCacheDisabled() bool {
+	return app.BuildCacheDisabled
+}
+
 func (app *App) RegisterUnit(unitId string, customData map[string]interface{}) error {
 	prov, err := app.getProvisioner()
 	if err != nil {
@@ -1896,6 +2200,7 @@ func (app *App) UpdateAddr() error {
 		return err
 	}
 	app.Ip = newAddr
+	updateEnvReferences(app.Name)
 	return nil
 }
 
@@ -1915,6 +2220,16 @@ func (app *App) Unlock() {
 	ReleaseApplicationLock(app.Name)
 }
 
+// writerContext returns the context of the event w, for operations writing
+// their output to the event of the request that started them, or a
+// background context when w is not an event.
+func writerContext(w io.Writer) context.Context {
+	if evt, ok := w.(*event.Event); ok {
+		return evt.Context()
+	}
+	return context.Background()
+}
+
 func (app *App) withLogWriter(w io.Writer) io.Writer {
 	logWriter := &LogWriter{App: app}
 	if w != nil {
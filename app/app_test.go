@@ -6,6 +6,7 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -36,6 +37,8 @@ import (
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/routertest"
 	"github.com/tsuru/tsuru/safe"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/secret/secrettest"
 	"github.com/tsuru/tsuru/service"
 	"github.com/tsuru/tsuru/tsurutest"
 	"gopkg.in/check.v1"
@@ -60,6 +63,27 @@ func (s *S) TestGetAppByNameNotFound(c *check.C) {
 	c.Assert(app, check.IsNil)
 }
 
+func (s *S) TestGetAppByNameContext(c *check.C) {
+	newApp := App{Name: "my-app", Platform: "Django", TeamOwner: s.team.Name}
+	err := CreateApp(&newApp, s.user)
+	c.Assert(err, check.IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	myApp, err := GetByNameContext(ctx, "my-app")
+	c.Assert(err, check.IsNil)
+	c.Assert(myApp.Name, check.Equals, newApp.Name)
+	_, err = GetByNameContext(ctx, "wat")
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestGetAppByNameContextCanceled(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	app, err := GetByNameContext(ctx, "my-app")
+	c.Assert(err, check.Equals, context.Canceled)
+	c.Assert(app, check.IsNil)
+}
+
 func (s *S) TestDelete(c *check.C) {
 	a := App{
 		Name:      "ritual",
@@ -1317,6 +1341,76 @@ func (s *S) TestUnsetEnvNoUnits(c *check.C) {
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
+func (s *S) TestSetAndUnsetSecretEnvs(c *check.C) {
+	config.Set("secrets:backend", "fake")
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_PASSWORD", Value: "123", Public: true, Secret: true},
+			{Name: "API_KEY", Value: `{{app "other".address}}`, Secret: true},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.UserEnvs(), check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "fake:myapp/DATABASE_PASSWORD", Secret: true},
+		"API_KEY":           {Name: "API_KEY", Value: "fake:myapp/API_KEY", Secret: true},
+	})
+	c.Assert(secrettest.Secrets(), check.DeepEquals, map[string]string{
+		"myapp/DATABASE_PASSWORD": "123",
+		"myapp/API_KEY":           `{{app "other".address}}`,
+	})
+	err = newApp.SetEnvs(bind.SetEnvApp{
+		Envs:       []bind.EnvVar{{Name: "API_KEY", Value: "not-secret", Public: true}},
+		PublicOnly: true,
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(secrettest.Secrets(), check.DeepEquals, map[string]string{"myapp/DATABASE_PASSWORD": "123"})
+	err = newApp.UnsetEnvs(bind.UnsetEnvApp{VariableNames: []string{"DATABASE_PASSWORD"}, PublicOnly: true}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(secrettest.Secrets(), check.HasLen, 0)
+	newApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.UserEnvs(), check.DeepEquals, map[string]bind.EnvVar{
+		"API_KEY": {Name: "API_KEY", Value: "not-secret", Public: true},
+	})
+}
+
+func (s *S) TestSetSecretEnvsWithoutBackend(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "123", Secret: true}},
+	}, nil)
+	c.Assert(err, check.Equals, secret.ErrNoBackend)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.UserEnvs(), check.HasLen, 0)
+}
+
+func (s *S) TestDeleteRemovesSecrets(c *check.C) {
+	config.Set("secrets:backend", "fake")
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "123", Secret: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(secrettest.Secrets(), check.HasLen, 1)
+	err = Delete(&a, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(secrettest.Secrets(), check.HasLen, 0)
+}
+
 func (s *S) TestGetEnvironmentVariableFromApp(c *check.C) {
 	a := App{Name: "whole-lotta-love"}
 	a.setEnv(bind.EnvVar{Name: "PATH", Value: "/"})
@@ -2200,19 +2294,90 @@ func (s *S) TestRestart(c *check.C) {
 	err := CreateApp(&a, s.user)
 	c.Assert(err, check.IsNil)
 	var b bytes.Buffer
-	err = a.Restart("", &b)
+	err = a.Restart(context.Background(), "", &b)
 	c.Assert(err, check.IsNil)
 	c.Assert(b.String(), check.Matches, `(?s).*---- Restarting the app "someapp" ----.*`)
 	restarts := s.provisioner.Restarts(&a, "")
 	c.Assert(restarts, check.Equals, 1)
 }
 
+func (s *S) TestRestartContextCanceled(c *check.C) {
+	s.provisioner.PrepareOutput([]byte("not yaml")) // loadConf
+	a := App{
+		Name:      "someapp",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var b bytes.Buffer
+	err = a.Restart(ctx, "", &b)
+	c.Assert(err, check.Equals, context.Canceled)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
+}
+
+func (s *S) TestRollingRestartRecordsProgress(c *check.C) {
+	a := App{Name: "someapp", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppUpdateRestart,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	progress := provision.RestartProgress{
+		Process: "web",
+		Updates: map[string]provision.RollingUpdate{"": {MaxUnavailable: 1}},
+	}
+	err = a.RollingRestart(context.Background(), progress, evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.Restarts(&a, "web"), check.Equals, 1)
+	c.Assert(s.provisioner.LastRestartProgress(&a), check.DeepEquals, &progress)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	resumed, err := a.RestartProgressFromEvent(evt.UniqueID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(resumed, check.DeepEquals, &provision.RestartProgress{
+		Process:   "web",
+		Updates:   map[string]provision.RollingUpdate{"": {MaxUnavailable: 1}},
+		Restarted: []string{units[0].ID, units[1].ID},
+	})
+}
+
+func (s *S) TestRestartProgressFromEventInvalidEvent(c *check.C) {
+	a := App{Name: "someapp", Platform: "django", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = a.RestartProgressFromEvent("invalid")
+	c.Assert(err, check.Equals, event.ErrEventNotFound)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppUpdateStop,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	_, err = a.RestartProgressFromEvent(evt.UniqueID.Hex())
+	c.Assert(err, check.Equals, ErrRestartNotResumable)
+}
+
 func (s *S) TestStop(c *check.C) {
 	a := App{Name: "app", TeamOwner: s.team.Name}
 	err := CreateApp(&a, s.user)
 	c.Assert(err, check.IsNil)
 	var buf bytes.Buffer
-	err = a.Stop(&buf, "")
+	err = a.Stop(context.Background(), &buf, "")
 	c.Assert(err, check.IsNil)
 	err = s.conn.Apps().Find(bson.M{"name": a.GetName()}).One(&a)
 	c.Assert(err, check.IsNil)
@@ -2235,7 +2400,7 @@ func (s *S) TestSleep(c *check.C) {
 	c.Assert(err, check.IsNil)
 	routertest.FakeRouter.AddBackend(a.Name)
 	var b bytes.Buffer
-	err = a.Start(&b, "")
+	err = a.Start(context.Background(), &b, "")
 	c.Assert(err, check.IsNil)
 	proxyURL, err := url.Parse("http://example.com")
 	c.Assert(err, check.IsNil)
@@ -3094,7 +3259,7 @@ func (s *S) TestListFilteringByStatuses(c *check.C) {
 		apps = append(apps, &a)
 	}
 	var buf bytes.Buffer
-	err := apps[1].Stop(&buf, "")
+	err := apps[1].Stop(context.Background(), &buf, "")
 	c.Assert(err, check.IsNil)
 	proxyUrl, _ := url.Parse("http://somewhere.com")
 	err = apps[2].Sleep(&buf, "", proxyUrl)
@@ -3131,6 +3296,30 @@ func (s *S) TestListFilteringByTag(c *check.C) {
 	c.Assert(resultApps, check.HasLen, 0)
 }
 
+func (s *S) TestListFilteringByLabels(c *check.C) {
+	app1 := App{Name: "app1", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "1234"}}
+	err := CreateApp(&app1, s.user)
+	c.Assert(err, check.IsNil)
+	app2 := App{Name: "app2", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "1234", "product": "store"}}
+	err = CreateApp(&app2, s.user)
+	c.Assert(err, check.IsNil)
+	app3 := App{Name: "app3", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "4321"}}
+	err = CreateApp(&app3, s.user)
+	c.Assert(err, check.IsNil)
+	resultApps, err := List(&Filter{Labels: provision.LabelSelector{"cost-center": "1234"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(resultApps, check.HasLen, 2)
+	c.Assert(resultApps[0].Name, check.Equals, app1.Name)
+	c.Assert(resultApps[1].Name, check.Equals, app2.Name)
+	resultApps, err = List(&Filter{Labels: provision.LabelSelector{"product": ""}})
+	c.Assert(err, check.IsNil)
+	c.Assert(resultApps, check.HasLen, 1)
+	c.Assert(resultApps[0].Name, check.Equals, app2.Name)
+	resultApps, err = List(&Filter{Labels: provision.LabelSelector{"cost-center": "4321", "product": ""}})
+	c.Assert(err, check.IsNil)
+	c.Assert(resultApps, check.HasLen, 0)
+}
+
 func (s *S) TestListReturnsEmptyAppArrayWhenUserHasNoAccessToAnyApp(c *check.C) {
 	apps, err := List(nil)
 	c.Assert(err, check.IsNil)
@@ -3309,7 +3498,7 @@ func (s *S) TestAppAvailable(c *check.C) {
 	c.Assert(err, check.IsNil)
 	s.provisioner.AddUnits(&a, 1, "web", nil)
 	c.Assert(a.available(), check.Equals, true)
-	s.provisioner.Stop(&a, "")
+	s.provisioner.Stop(context.Background(), &a, "")
 	c.Assert(a.available(), check.Equals, false)
 }
 
@@ -3367,7 +3556,7 @@ func (s *S) TestStart(c *check.C) {
 	err := CreateApp(&a, s.user)
 	c.Assert(err, check.IsNil)
 	var b bytes.Buffer
-	err = a.Start(&b, "")
+	err = a.Start(context.Background(), &b, "")
 	c.Assert(err, check.IsNil)
 	starts := s.provisioner.Starts(&a, "")
 	c.Assert(starts, check.Equals, 1)
@@ -3386,7 +3575,7 @@ func (s *S) TestStartAsleepApp(c *check.C) {
 	for _, u := range units {
 		c.Assert(u.Status, check.Not(check.Equals), provision.StatusStarted)
 	}
-	err = a.Start(&b, "web")
+	err = a.Start(context.Background(), &b, "web")
 	c.Assert(err, check.IsNil)
 	routes, err := routertest.FakeRouter.Routes(a.Name)
 	c.Assert(err, check.IsNil)
@@ -3408,7 +3597,7 @@ func (s *S) TestRestartAsleepApp(c *check.C) {
 	for _, u := range units {
 		c.Assert(u.Status, check.Not(check.Equals), provision.StatusStarted)
 	}
-	err = a.Restart("web", &b)
+	err = a.Restart(context.Background(), "web", &b)
 	c.Assert(err, check.IsNil)
 	routes, err := routertest.FakeRouter.Routes(a.Name)
 	c.Assert(err, check.IsNil)
@@ -4207,6 +4396,26 @@ func (s *S) TestUpdateWithoutTagsKeepsOriginalTags(c *check.C) {
 	c.Assert(dbApp.Tags, check.DeepEquals, []string{"tag1", "tag2"})
 }
 
+func (s *S) TestUpdateLabels(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Labels: map[string]string{"cost-center": "1234", "empty": ""}}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Labels, check.DeepEquals, map[string]string{"cost-center": "1234"})
+	updateData := App{Labels: map[string]string{"cost-center": "", "product": "store"}}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Labels, check.DeepEquals, map[string]string{"product": "store"})
+	err = app.Update(App{Description: "ble"}, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Labels, check.DeepEquals, map[string]string{"product": "store"})
+}
+
 func (s *S) TestUpdateDescriptionPoolPlanAndRouter(c *check.C) {
 	opts := provision.AddPoolOptions{Name: "test"}
 	err := provision.AddPool(opts)
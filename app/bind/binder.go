@@ -14,6 +14,12 @@ type EnvVar struct {
 	Value        string `json:"value"`
 	Public       bool   `json:"public"`
 	InstanceName string `json:"-"`
+	// Reference is the original value of variables referencing other apps,
+	// e.g. {{app "billing-api".address}}, Value holds its resolved value.
+	Reference string `json:"reference,omitempty" bson:",omitempty"`
+	// Secret marks variables stored in the secrets backend, Value holds the
+	// reference to the stored value until it's resolved.
+	Secret bool `json:"secret,omitempty" bson:",omitempty"`
 }
 
 // Unit represents an application unit to be used in binds.
@@ -0,0 +1,87 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// BuildCacheInfo describes the build cache of an app. Builds reuse the last
+// image of the app, keeping files generated by previous builds (like
+// downloaded dependencies), unless the cache is disabled or cleared.
+type BuildCacheInfo struct {
+	Enabled bool
+	// Cleared indicates that the next build will start from the platform
+	// image, discarding the current cache.
+	Cleared bool
+	// BuildImage is the image the next build will start from.
+	BuildImage string
+	// CacheImage is the current image of the app, used as cache.
+	CacheImage string
+	// Size is the size, in bytes, of CacheImage. It's only reported by
+	// provisioners implementing provision.ImageSizer.
+	Size int64 `json:",omitempty"`
+}
+
+// BuildCache returns information about the build cache of the app.
+func (app *App) BuildCache() (*BuildCacheInfo, error) {
+	info := BuildCacheInfo{
+		Enabled:    !app.BuildCacheDisabled,
+		Cleared:    app.UpdatePlatform,
+		BuildImage: image.GetBuildImage(app),
+	}
+	cacheImage, err := image.AppCurrentImageName(app.Name)
+	if err == image.ErrNoImagesAvailable {
+		return &info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.CacheImage = cacheImage
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	if sizer, ok := prov.(provision.ImageSizer); ok {
+		info.Size, err = sizer.ImageSize(cacheImage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &info, nil
+}
+
+// SetBuildCacheEnabled enables or disables the build cache of the app. When
+// disabled, every build starts from the platform image.
+func (app *App) SetBuildCacheEnabled(enabled bool) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name},
+		bson.M{"$set": bson.M{"buildcachedisabled": !enabled}},
+	)
+	if err != nil {
+		return err
+	}
+	app.BuildCacheDisabled = !enabled
+	return nil
+}
+
+// ClearBuildCache discards the build cache of the app, making its next build
+// start from the platform image.
+func (app *App) ClearBuildCache() error {
+	err := app.SetUpdatePlatform(true)
+	if err != nil {
+		return err
+	}
+	app.UpdatePlatform = true
+	return nil
+}
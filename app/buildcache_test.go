@@ -0,0 +1,70 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/app/image"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestBuildCache(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name, Deploys: 3}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	info, err := a.BuildCache()
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, &BuildCacheInfo{
+		Enabled:    true,
+		BuildImage: image.PlatformImageName("python"),
+	})
+	err = image.AppendAppImageName(a.Name, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	info, err = a.BuildCache()
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, &BuildCacheInfo{
+		Enabled:    true,
+		BuildImage: "tsuru/app-myapp:v1",
+		CacheImage: "tsuru/app-myapp:v1",
+	})
+}
+
+func (s *S) TestSetBuildCacheEnabled(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name, Deploys: 3}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = a.SetBuildCacheEnabled(false)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.BuildCacheDisabled, check.Equals, true)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.BuildCacheDisabled, check.Equals, true)
+	info, err := dbApp.BuildCache()
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Enabled, check.Equals, false)
+	c.Assert(info.BuildImage, check.Equals, image.PlatformImageName("python"))
+	c.Assert(info.CacheImage, check.Equals, "tsuru/app-myapp:v1")
+	err = dbApp.SetBuildCacheEnabled(true)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.BuildCacheDisabled, check.Equals, false)
+}
+
+func (s *S) TestClearBuildCache(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name, Deploys: 3}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = a.ClearBuildCache()
+	c.Assert(err, check.IsNil)
+	c.Assert(a.UpdatePlatform, check.Equals, true)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.UpdatePlatform, check.Equals, true)
+	info, err := dbApp.BuildCache()
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Cleared, check.Equals, true)
+}
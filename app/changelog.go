@@ -0,0 +1,193 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultChangelogLimit = 20
+
+// ChangelogEntry is a human readable description of a change made to an app,
+// derived from a successful event.
+type ChangelogEntry struct {
+	EventID string
+	Time    time.Time
+	Kind    string
+	Owner   string
+	Message string
+}
+
+// ChangelogPage is a page of the changelog of an app. Next is the cursor used
+// to fetch the next page, it's empty in the last page.
+type ChangelogPage struct {
+	Entries []ChangelogEntry
+	Next    string `json:",omitempty"`
+}
+
+type changelogFormatter func(evt *event.Event) (string, error)
+
+var changelogFormatters = map[string]changelogFormatter{
+	permission.PermAppDeploy.FullName():           formatDeployChange,
+	permission.PermAppUpdateEnvSet.FullName():     formatEnvSetChange,
+	permission.PermAppUpdateEnvUnset.FullName():   formatEnvUnsetChange,
+	permission.PermAppUpdateUnitAdd.FullName():    formatUnitChange("Added"),
+	permission.PermAppUpdateUnitRemove.FullName(): formatUnitChange("Removed"),
+	permission.PermAppUpdateBind.FullName():       formatBindChange("Bound"),
+	permission.PermAppUpdateUnbind.FullName():     formatBindChange("Unbound"),
+}
+
+// Changelog returns up to limit changes made to the app, newest first,
+// starting after the given cursor. Only deploys, environment variable, unit
+// and service binding changes that finished successfully are included.
+func (app *App) Changelog(limit int, cursor string) (*ChangelogPage, error) {
+	if limit <= 0 {
+		limit = defaultChangelogLimit
+	}
+	kinds := make([]string, 0, len(changelogFormatters))
+	for kind := range changelogFormatters {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	running := false
+	evts, err := event.List(&event.Filter{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		Running: &running,
+		Raw: bson.M{
+			"kind.name": bson.M{"$in": kinds},
+			"error":     "",
+		},
+		Limit:  limit,
+		Cursor: cursor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := ChangelogPage{Entries: make([]ChangelogEntry, 0, len(evts))}
+	for i := range evts {
+		evt := &evts[i]
+		msg, err := changelogFormatters[evt.Kind.Name](evt)
+		if err != nil {
+			return nil, err
+		}
+		page.Entries = append(page.Entries, ChangelogEntry{
+			EventID: evt.UniqueID.Hex(),
+			Time:    evt.StartTime,
+			Kind:    evt.Kind.Name,
+			Owner:   evt.Owner.Name,
+			Message: msg,
+		})
+	}
+	if len(evts) == limit {
+		page.Next = evts[len(evts)-1].Cursor()
+	}
+	return &page, nil
+}
+
+func formValues(evt *event.Event) (url.Values, error) {
+	var data []map[string]interface{}
+	err := evt.StartData(&data)
+	if err != nil {
+		return nil, err
+	}
+	return event.CustomDataToForm(data), nil
+}
+
+func formatDeployChange(evt *event.Event) (string, error) {
+	var opts DeployOptions
+	err := evt.StartData(&opts)
+	if err != nil {
+		return "", err
+	}
+	var msg string
+	switch {
+	case opts.Rollback:
+		msg = fmt.Sprintf("Rolled back to image %s", opts.Image)
+	case opts.Commit != "":
+		commit := opts.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		msg = fmt.Sprintf("Deployed commit %s", commit)
+	case opts.Image != "":
+		msg = fmt.Sprintf("Deployed image %s", opts.Image)
+	case opts.GetOrigin() != "":
+		msg = fmt.Sprintf("Deployed from %s", opts.GetOrigin())
+	default:
+		msg = "Deployed"
+	}
+	if opts.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, strings.SplitN(opts.Message, "\n", 2)[0])
+	}
+	return msg, nil
+}
+
+func formatEnvSetChange(evt *event.Event) (string, error) {
+	form, err := formValues(evt)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for i := 0; ; i++ {
+		name, ok := form[fmt.Sprintf("Envs.%d.Name", i)]
+		if !ok {
+			break
+		}
+		names = append(names, name...)
+	}
+	msg := fmt.Sprintf("Set environment variables: %s", strings.Join(names, ", "))
+	if form.Get("Private") == "true" {
+		msg += " (private)"
+	}
+	return msg, nil
+}
+
+func formatEnvUnsetChange(evt *event.Event) (string, error) {
+	form, err := formValues(evt)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Unset environment variables: %s", strings.Join(form["env"], ", ")), nil
+}
+
+func formatUnitChange(action string) changelogFormatter {
+	return func(evt *event.Event) (string, error) {
+		form, err := formValues(evt)
+		if err != nil {
+			return "", err
+		}
+		units := "units"
+		switch n := form.Get("units"); n {
+		case "":
+		case "1":
+			units = "1 unit"
+		default:
+			units = n + " units"
+		}
+		msg := fmt.Sprintf("%s %s", action, units)
+		if process := form.Get("process"); process != "" {
+			msg += fmt.Sprintf(" of process %s", process)
+		}
+		return msg, nil
+	}
+}
+
+func formatBindChange(action string) changelogFormatter {
+	return func(evt *event.Event) (string, error) {
+		form, err := formValues(evt)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s service instance %s (%s)", action, form.Get(":instance"), form.Get(":service")), nil
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addChangelogEvent(c *check.C, appName string, kind *permission.PermissionScheme, data interface{}, evtErr error) {
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: appName},
+		Kind:       kind,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: "me@me.com"},
+		CustomData: data,
+		Allowed:    event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(evtErr)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestChangelog(c *check.C) {
+	a := App{Name: "myapp"}
+	s.addChangelogEvent(c, a.Name, permission.PermAppDeploy, DeployOptions{
+		Commit:  "1a2b3c4d5e6f",
+		Message: "fix login\n\nlong description",
+	}, nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppUpdateEnvSet, event.FormToCustomData(url.Values{
+		"Envs.0.Name":  {"DATABASE_HOST"},
+		"Envs.0.Value": {"secret"},
+		"Envs.1.Name":  {"DATABASE_PORT"},
+		"Envs.1.Value": {"3306"},
+		"Private":      {"true"},
+	}), nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppUpdateEnvUnset, event.FormToCustomData(url.Values{
+		"env": {"DATABASE_HOST", "DATABASE_PORT"},
+	}), nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppUpdateUnitAdd, event.FormToCustomData(url.Values{
+		"units":   {"3"},
+		"process": {"web"},
+	}), nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppUpdateUnitRemove, event.FormToCustomData(url.Values{
+		"units": {"1"},
+	}), nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppUpdateBind, event.FormToCustomData(url.Values{
+		":instance": {"mydb"},
+		":service":  {"mysql"},
+	}), nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppDeploy, DeployOptions{Image: "myimg:v1", Rollback: true}, nil)
+	s.addChangelogEvent(c, a.Name, permission.PermAppDeploy, DeployOptions{Image: "myimg:v2"}, errors.New("failed"))
+	s.addChangelogEvent(c, a.Name, permission.PermAppUpdateRestart, nil, nil)
+	s.addChangelogEvent(c, "otherapp", permission.PermAppDeploy, DeployOptions{Image: "other"}, nil)
+	page, err := a.Changelog(0, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(page.Next, check.Equals, "")
+	var messages []string
+	for _, entry := range page.Entries {
+		c.Assert(entry.Owner, check.Equals, "me@me.com")
+		messages = append(messages, entry.Message)
+	}
+	c.Assert(messages, check.DeepEquals, []string{
+		"Rolled back to image myimg:v1",
+		"Bound service instance mydb (mysql)",
+		"Removed 1 unit",
+		"Added 3 units of process web",
+		"Unset environment variables: DATABASE_HOST, DATABASE_PORT",
+		"Set environment variables: DATABASE_HOST, DATABASE_PORT (private)",
+		"Deployed commit 1a2b3c4: fix login",
+	})
+	c.Assert(page.Entries[0].Kind, check.Equals, "app.deploy")
+}
+
+func (s *S) TestChangelogPagination(c *check.C) {
+	a := App{Name: "myapp"}
+	for i := 0; i < 3; i++ {
+		s.addChangelogEvent(c, a.Name, permission.PermAppDeploy, DeployOptions{Image: "myimg"}, nil)
+	}
+	page, err := a.Changelog(2, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(page.Entries, check.HasLen, 2)
+	c.Assert(page.Next, check.Not(check.Equals), "")
+	next, err := a.Changelog(2, page.Next)
+	c.Assert(err, check.IsNil)
+	c.Assert(next.Entries, check.HasLen, 1)
+	c.Assert(next.Next, check.Equals, "")
+	c.Assert(next.Entries[0].EventID, check.Not(check.Equals), page.Entries[1].EventID)
+	_, err = a.Changelog(2, "invalid")
+	c.Assert(err, check.Equals, event.ErrInvalidCursor)
+}
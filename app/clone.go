@@ -0,0 +1,205 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/service"
+)
+
+// CloneOptions describes the app created by Clone.
+type CloneOptions struct {
+	// Name is the name of the new app.
+	Name string
+	// TeamOwner and Pool of the new app, the ones of the source app are used
+	// when they're empty.
+	TeamOwner string
+	Pool      string
+	// IncludeSecrets copies the values of secret variables, which are
+	// skipped by default.
+	IncludeSecrets bool
+	// NewInstances creates new instances, with the same plans, of the
+	// services the source app is bound to, instead of binding the new app
+	// to the same instances. New instances are named after the source
+	// instance and the new app, like "mydb-myapp-review".
+	NewInstances bool
+	RequestID    string
+}
+
+// Clone creates a new app with the platform, plan, router, teams, tags,
+// labels, environment variables and service bindings of the src app. Nothing
+// is deployed to the new app.
+//
+// The new app is removed, along with the instances created for it, if any
+// step after its creation fails.
+func Clone(src *App, opts CloneOptions, user *auth.User, w io.Writer) (*App, error) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	instances, err := src.serviceInstances()
+	if err != nil {
+		return nil, err
+	}
+	clone := App{
+		Name:                  opts.Name,
+		Platform:              src.Platform,
+		Plan:                  Plan{Name: src.Plan.Name},
+		TeamOwner:             opts.TeamOwner,
+		Pool:                  opts.Pool,
+		Description:           src.Description,
+		Router:                src.Router,
+		RouterOpts:            src.RouterOpts,
+		Tags:                  src.Tags,
+		Labels:                provision.MergeLabels(nil, src.Labels),
+		BuildCacheDisabled:    src.BuildCacheDisabled,
+		RequireDeployApproval: src.RequireDeployApproval,
+	}
+	if clone.TeamOwner == "" {
+		clone.TeamOwner = src.TeamOwner
+	}
+	if clone.Pool == "" {
+		clone.Pool = src.Pool
+	}
+	err = CreateApp(&clone, user)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "---- App %q created from %q ----\n", clone.Name, src.Name)
+	created, err := copyToClone(src, clone.Name, instances, opts, user, w)
+	if err != nil {
+		removeClone(clone.Name, created, opts.RequestID, w)
+		return nil, err
+	}
+	return GetByName(clone.Name)
+}
+
+// copyToClone grants the teams of src to the clone, copies its environment
+// variables and binds the clone to the service instances, returning the
+// instances created for the clone.
+func copyToClone(src *App, cloneName string, instances []service.ServiceInstance, opts CloneOptions, user *auth.User, w io.Writer) ([]service.ServiceInstance, error) {
+	clone, err := GetByName(cloneName)
+	if err != nil {
+		return nil, err
+	}
+	for _, teamName := range src.Teams {
+		if teamName == clone.TeamOwner {
+			continue
+		}
+		team, err := auth.GetTeam(teamName)
+		if err != nil {
+			return nil, err
+		}
+		err = clone.Grant(team)
+		if err != nil && err != ErrAlreadyHaveAccess {
+			return nil, err
+		}
+	}
+	envs, skipped, err := cloneEnvs(src, opts.IncludeSecrets)
+	if err != nil {
+		return nil, err
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(w, "---- Skipping secret environment variables: %s ----\n", strings.Join(skipped, ", "))
+	}
+	err = clone.setEnvsToApp(bind.SetEnvApp{Envs: envs}, w)
+	if err != nil {
+		return nil, err
+	}
+	var created []service.ServiceInstance
+	for _, instance := range instances {
+		if opts.NewInstances {
+			instance, err = createCloneInstance(instance, clone, user, opts.RequestID, w)
+			if err != nil {
+				return created, err
+			}
+			created = append(created, instance)
+		}
+		fmt.Fprintf(w, "---- Binding service instance %q of service %q ----\n", instance.Name, instance.ServiceName)
+		err = instance.BindApp(clone, false, w)
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// cloneEnvs returns the variables of src set by users, sorted by name, and
+// the names of the secret variables skipped. Variables referencing other
+// apps are resolved again in the clone.
+func cloneEnvs(src *App, includeSecrets bool) ([]bind.EnvVar, []string, error) {
+	var envs []bind.EnvVar
+	var skipped []string
+	for _, env := range src.UserEnvs() {
+		if env.Secret {
+			if !includeSecrets {
+				skipped = append(skipped, env.Name)
+				continue
+			}
+			value, err := secret.Resolve(env.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			env.Value = value
+		} else if env.Reference != "" {
+			env.Value = env.Reference
+		}
+		envs = append(envs, env)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+	sort.Strings(skipped)
+	return envs, skipped, nil
+}
+
+func createCloneInstance(instance service.ServiceInstance, clone *App, user *auth.User, requestID string, w io.Writer) (service.ServiceInstance, error) {
+	srv := service.Service{Name: instance.ServiceName}
+	err := srv.Get()
+	if err != nil {
+		return instance, err
+	}
+	newInstance := service.ServiceInstance{
+		Name:        fmt.Sprintf("%s-%s", instance.Name, clone.Name),
+		ServiceName: instance.ServiceName,
+		PlanName:    instance.PlanName,
+		TeamOwner:   clone.TeamOwner,
+		Description: instance.Description,
+		Tags:        instance.Tags,
+	}
+	fmt.Fprintf(w, "---- Creating service instance %q of service %q ----\n", newInstance.Name, newInstance.ServiceName)
+	err = service.CreateServiceInstance(newInstance, &srv, user, requestID)
+	if err != nil {
+		return instance, err
+	}
+	newInstance.Teams = []string{newInstance.TeamOwner}
+	return newInstance, nil
+}
+
+// removeClone removes a partially cloned app and the instances created for
+// it.
+func removeClone(name string, instances []service.ServiceInstance, requestID string, w io.Writer) {
+	fmt.Fprintf(w, "---- Removing app %q after failed clone ----\n", name)
+	clone, err := GetByName(name)
+	if err == nil {
+		err = Delete(clone, w)
+	}
+	if err != nil {
+		log.Errorf("[clone] unable to remove app %q: %s", name, err)
+	}
+	for i := range instances {
+		err = service.DeleteInstance(&instances[i], requestID)
+		if err != nil {
+			log.Errorf("[clone] unable to remove service instance %q of app %q: %s", instances[i].Name, name, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/secret/secrettest"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) createCloneSource(c *check.C, serviceURL string) *App {
+	config.Set("secrets:backend", "fake")
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": serviceURL}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanName: "small", Teams: []string{s.team.Name}}
+	err = instance.Create()
+	c.Assert(err, check.IsNil)
+	team := auth.Team{Name: "reviewers"}
+	err = s.conn.Teams().Insert(team)
+	c.Assert(err, check.IsNil)
+	src := App{
+		Name:        "web",
+		Platform:    "python",
+		TeamOwner:   s.team.Name,
+		Description: "the web app",
+		Tags:        []string{"frontend"},
+		Labels:      map[string]string{"product": "store"},
+	}
+	err = CreateApp(&src, s.user)
+	c.Assert(err, check.IsNil)
+	err = src.Grant(&team)
+	c.Assert(err, check.IsNil)
+	err = src.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "PUBLIC", Value: "1", Public: true},
+			{Name: "PRIVATE", Value: "2"},
+			{Name: "PASSWORD", Value: "123", Secret: true},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	err = instance.BindApp(&src, false, nil)
+	c.Assert(err, check.IsNil)
+	srcApp, err := GetByName(src.Name)
+	c.Assert(err, check.IsNil)
+	return srcApp
+}
+
+func cloneServiceHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/bind-app") {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"DATABASE_HOST":"localhost"}`))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *S) TestClone(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(cloneServiceHandler))
+	defer server.Close()
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	src := s.createCloneSource(c, server.URL)
+	var buf bytes.Buffer
+	clone, err := Clone(src, CloneOptions{Name: "web-review"}, s.user, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.Name, check.Equals, "web-review")
+	c.Assert(clone.Platform, check.Equals, src.Platform)
+	c.Assert(clone.Plan, check.DeepEquals, src.Plan)
+	c.Assert(clone.Pool, check.Equals, src.Pool)
+	c.Assert(clone.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(clone.Teams, check.DeepEquals, []string{s.team.Name, "reviewers"})
+	c.Assert(clone.Description, check.Equals, src.Description)
+	c.Assert(clone.Tags, check.DeepEquals, src.Tags)
+	c.Assert(clone.Labels, check.DeepEquals, src.Labels)
+	c.Assert(clone.UserEnvs(), check.DeepEquals, map[string]bind.EnvVar{
+		"PUBLIC":  {Name: "PUBLIC", Value: "1", Public: true},
+		"PRIVATE": {Name: "PRIVATE", Value: "2"},
+	})
+	c.Assert(clone.InstanceEnv("mydb"), check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", InstanceName: "mydb"},
+	})
+	instance, err := service.GetServiceInstance("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.Apps, check.DeepEquals, []string{"web", "web-review"})
+	c.Assert(buf.String(), check.Matches, `(?s).*Skipping secret environment variables: PASSWORD.*`)
+}
+
+func (s *S) TestCloneWithSecretsAndNewInstances(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(cloneServiceHandler))
+	defer server.Close()
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	src := s.createCloneSource(c, server.URL)
+	opts := CloneOptions{Name: "web-review", IncludeSecrets: true, NewInstances: true}
+	clone, err := Clone(src, opts, s.user, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.UserEnvs()["PASSWORD"], check.DeepEquals, bind.EnvVar{
+		Name: "PASSWORD", Value: "fake:web-review/PASSWORD", Secret: true,
+	})
+	c.Assert(secrettest.Secrets()["web-review/PASSWORD"], check.Equals, "123")
+	instance, err := service.GetServiceInstance("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.Apps, check.DeepEquals, []string{"web"})
+	instance, err = service.GetServiceInstance("mysql", "mydb-web-review")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.PlanName, check.Equals, "small")
+	c.Assert(instance.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(instance.Apps, check.DeepEquals, []string{"web-review"})
+	c.Assert(clone.InstanceEnv("mydb-web-review"), check.HasLen, 1)
+}
+
+func (s *S) TestCloneRemovesAppOnFailure(c *check.C) {
+	var failBind bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failBind && strings.HasSuffix(r.URL.Path, "/bind-app") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		cloneServiceHandler(w, r)
+	}))
+	defer server.Close()
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	src := s.createCloneSource(c, server.URL)
+	failBind = true
+	_, err := Clone(src, CloneOptions{Name: "web-review", NewInstances: true}, s.user, nil)
+	c.Assert(err, check.NotNil)
+	_, err = GetByName("web-review")
+	c.Assert(err, check.Equals, ErrAppNotFound)
+	_, err = service.GetServiceInstance("mysql", "mydb-web-review")
+	c.Assert(err, check.Equals, service.ErrServiceInstanceNotFound)
+	count, err := s.conn.ServiceInstances().Find(bson.M{"apps": "web-review"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestCloneAppAlreadyExists(c *check.C) {
+	src := App{Name: "web", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&src, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = Clone(&src, CloneOptions{Name: "web"}, s.user, nil)
+	c.Assert(err, check.FitsTypeOf, &AppCreationError{})
+	c.Assert(err.(*AppCreationError).Err, check.Equals, ErrAppAlreadyExists)
+}
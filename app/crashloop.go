@@ -0,0 +1,317 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultCrashLoopWindow   = time.Minute
+	defaultCrashLoopInterval = 5 * time.Second
+)
+
+var ErrDeployNotPaused = errors.New("deploys of the app are not paused")
+
+// DeployPause describes why the deploys of an app were paused. Deploys are
+// paused when the units of a deploy crash loop, until the app is rolled back
+// or deploys are explicitly resumed. Restarts holds the number of crashes of
+// each unit counted after the deploy.
+type DeployPause struct {
+	App           string `bson:"_id"`
+	Reason        string
+	DeployEvent   bson.ObjectId
+	Image         string
+	RollbackImage string `bson:",omitempty"`
+	Restarts      map[string]int
+	Time          time.Time
+}
+
+// RollbackCommand returns the command used to roll the app back to the
+// image deployed before the crash loop, if known.
+func (p *DeployPause) RollbackCommand() string {
+	if p.RollbackImage == "" {
+		return ""
+	}
+	return fmt.Sprintf("tsuru app-deploy-rollback -a %s %s", p.App, p.RollbackImage)
+}
+
+type ErrDeployPaused struct {
+	Pause *DeployPause
+}
+
+func (e *ErrDeployPaused) Error() string {
+	msg := fmt.Sprintf("deploys of app %q are paused: %s", e.Pause.App, e.Pause.Reason)
+	if cmd := e.Pause.RollbackCommand(); cmd != "" {
+		msg += fmt.Sprintf(", run %q to roll back", cmd)
+	}
+	return msg
+}
+
+type crashLoopSettings struct {
+	maxRestarts int
+	window      time.Duration
+	interval    time.Duration
+}
+
+// getCrashLoopSettings returns the crash loop detection settings, detection
+// is disabled unless deploy:crash-loop:max-restarts is set.
+func getCrashLoopSettings() crashLoopSettings {
+	s := crashLoopSettings{window: defaultCrashLoopWindow, interval: defaultCrashLoopInterval}
+	s.maxRestarts, _ = config.GetInt("deploy:crash-loop:max-restarts")
+	if seconds, _ := config.GetFloat("deploy:crash-loop:window"); seconds > 0 {
+		s.window = time.Duration(seconds * float64(time.Second))
+	}
+	if seconds, _ := config.GetFloat("deploy:crash-loop:interval"); seconds > 0 {
+		s.interval = time.Duration(seconds * float64(time.Second))
+	}
+	return s
+}
+
+type unitCrashes struct {
+	status   provision.Status
+	errors   int
+	restarts int
+}
+
+func (c *unitCrashes) count() int {
+	if c.errors > c.restarts {
+		return c.errors
+	}
+	return c.restarts
+}
+
+// watchCrashLoop samples the units of the app during the detection window,
+// counting the crashes of each unit either as restarts reported by the
+// provisioner or as changes to the error status. It returns the crashes of
+// the units as soon as any of them reaches the threshold, or nil if none
+// did until the window ends.
+func watchCrashLoop(app *App, settings crashLoopSettings) (map[string]int, error) {
+	crashes := map[string]*unitCrashes{}
+	deadline := time.Now().Add(settings.window)
+	for {
+		units, err := app.Units()
+		if err != nil {
+			return nil, err
+		}
+		looping := false
+		for _, u := range units {
+			c := crashes[u.ID]
+			if c == nil {
+				c = &unitCrashes{}
+				crashes[u.ID] = c
+			}
+			if u.Status == provision.StatusError && c.status != provision.StatusError {
+				c.errors++
+			}
+			c.status = u.Status
+			c.restarts = u.Restarts
+			if c.count() >= settings.maxRestarts {
+				looping = true
+			}
+		}
+		if looping {
+			result := map[string]int{}
+			for id, c := range crashes {
+				if n := c.count(); n > 0 {
+					result[id] = n
+				}
+			}
+			return result, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		time.Sleep(settings.interval)
+	}
+}
+
+// checkCrashLoop watches the units of a finished deploy for crash loops. If
+// any unit crashes too many times, deploys of the app are paused, the owner
+// of the deploy is notified and an ErrDeployPaused is returned. Errors
+// checking units are logged without failing the deploy. A deploy with
+// healthy units resumes deploys paused by previous ones.
+func checkCrashLoop(opts *DeployOptions, imageID, previousImage string) error {
+	settings := getCrashLoopSettings()
+	if settings.maxRestarts <= 0 {
+		return nil
+	}
+	evt := opts.Event
+	evt.Logf("---- Watching units for crash loops for %v, deploys are paused if a unit crashes %d time(s) ----", settings.window, settings.maxRestarts)
+	crashes, err := watchCrashLoop(opts.App, settings)
+	if err != nil {
+		evt.Logf("Unable to watch units for crash loops, skipping detection: %s", err)
+		return nil
+	}
+	if crashes == nil {
+		evt.Logf("No crash loops detected.")
+		err = ResumeDeploys(opts.App.Name)
+		if err == nil {
+			evt.Logf("Deploys paused by a previous crash loop were resumed.")
+		} else if err != ErrDeployNotPaused {
+			log.Errorf("[crash-loop] unable to resume deploys of app %q: %s", opts.App.Name, err)
+		}
+		return nil
+	}
+	ids := make([]string, 0, len(crashes))
+	for id := range crashes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		evt.Logf("Unit %s crashed %d time(s).", id, crashes[id])
+	}
+	pause := DeployPause{
+		App:         opts.App.Name,
+		Reason:      fmt.Sprintf("crash loop detected after deploying %s, %d unit(s) crashed at least %d time(s) in %v", imageID, len(crashes), settings.maxRestarts, settings.window),
+		DeployEvent: evt.UniqueID,
+		Image:       imageID,
+		Restarts:    crashes,
+		Time:        time.Now().UTC(),
+	}
+	if previousImage != imageID {
+		pause.RollbackImage = previousImage
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.DeployPauses().UpsertId(pause.App, pause)
+	if err != nil {
+		return err
+	}
+	evt.Logf("Deploys of the app are paused: %s.", pause.Reason)
+	if cmd := pause.RollbackCommand(); cmd != "" {
+		evt.Logf("To roll back to the previous image run: %s", cmd)
+	}
+	if evt.Owner.Type == event.OwnerTypeUser {
+		notifyDeployPause(&pause, evt.Owner.Name, evt.URL())
+	}
+	return &ErrDeployPaused{Pause: &pause}
+}
+
+func notifyDeployPause(pause *DeployPause, email, eventURL string) {
+	if server, _ := config.GetString("smtp:server"); server == "" {
+		return
+	}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "Subject: [tsuru] deploys of app %s paused after a crash loop\r\n\r\n", pause.App)
+	fmt.Fprintf(&body, "%s.\r\n", strings.ToUpper(pause.Reason[:1])+pause.Reason[1:])
+	if cmd := pause.RollbackCommand(); cmd != "" {
+		fmt.Fprintf(&body, "\r\nTo roll back to the previous image run:\r\n\r\n    %s\r\n", cmd)
+	}
+	if eventURL != "" {
+		fmt.Fprintf(&body, "\r\nMore details: %s\r\n", eventURL)
+	}
+	go func() {
+		if err := auth.SendEmail(email, body.Bytes()); err != nil {
+			log.Errorf("[crash-loop] unable to notify %q about paused deploys of app %q: %s", email, pause.App, err)
+		}
+	}()
+}
+
+// previousDeployImage returns the image running before the deploy, used to
+// suggest a rollback when the deploy crash loops.
+func previousDeployImage(appName string) string {
+	if getCrashLoopSettings().maxRestarts <= 0 {
+		return ""
+	}
+	imgs, err := image.ListValidAppImages(appName)
+	if err != nil || len(imgs) == 0 {
+		return ""
+	}
+	return imgs[len(imgs)-1]
+}
+
+// GetDeployPause returns the reason deploys of the app are paused, or
+// ErrDeployNotPaused.
+func GetDeployPause(appName string) (*DeployPause, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var pause DeployPause
+	err = conn.DeployPauses().FindId(appName).One(&pause)
+	if err == mgo.ErrNotFound {
+		return nil, ErrDeployNotPaused
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pause, nil
+}
+
+// ResumeDeploys allows new deploys of an app paused after a crash loop.
+func ResumeDeploys(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.DeployPauses().RemoveId(appName)
+	if err == mgo.ErrNotFound {
+		return ErrDeployNotPaused
+	}
+	return err
+}
+
+// checkDeployPaused returns an ErrDeployPaused if deploys of the app are
+// paused. Rollbacks are always allowed, they're the way out of a pause.
+func checkDeployPaused(opts *DeployOptions) error {
+	if opts.Rollback {
+		return nil
+	}
+	pause, err := GetDeployPause(opts.App.Name)
+	if err == ErrDeployNotPaused {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &ErrDeployPaused{Pause: pause}
+}
+
+// deployPauseData returns the data describing the pause caused by the
+// deploy event, stored in the deploy end custom data.
+func deployPauseData(evt *event.Event) map[string]interface{} {
+	if evt == nil || getCrashLoopSettings().maxRestarts <= 0 {
+		return nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	var pause DeployPause
+	err = conn.DeployPauses().Find(bson.M{"deployevent": evt.UniqueID}).One(&pause)
+	if err != nil {
+		return nil
+	}
+	data := map[string]interface{}{
+		"reason":   pause.Reason,
+		"restarts": pause.Restarts,
+	}
+	if pause.RollbackImage != "" {
+		data["rollbackimage"] = pause.RollbackImage
+	}
+	return data
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) setupCrashLoop(c *check.C) *App {
+	config.Set("deploy:crash-loop:max-restarts", 1)
+	config.Set("deploy:crash-loop:window", 0.05)
+	config.Set("deploy:crash-loop:interval", 0.01)
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-some-app:v1")
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) newDeployEvent(c *check.C, a *App) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestDeployCrashLoopPausesDeploys(c *check.C) {
+	defer config.Unset("deploy:crash-loop")
+	a := s.setupCrashLoop(c)
+	unit := s.provisioner.GetUnits(a)[0]
+	err := s.provisioner.SetUnitStatus(unit, provision.StatusError)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	evt := s.newDeployEvent(c, a)
+	imageID, err := Deploy(DeployOptions{App: a, Image: "myimage", OutputStream: writer, Event: evt})
+	c.Assert(err, check.FitsTypeOf, &ErrDeployPaused{})
+	c.Assert(imageID, check.Equals, "myimage")
+	c.Assert(err.Error(), check.Equals, `deploys of app "some-app" are paused: crash loop detected after deploying myimage, 1 unit(s) crashed at least 1 time(s) in 50ms, run "tsuru app-deploy-rollback -a some-app registry.somewhere/tsuru/app-some-app:v1" to roll back`)
+	c.Assert(writer.String(), check.Matches, "(?s).*Unit "+unit.ID+" crashed 1 time\\(s\\).*")
+	c.Assert(writer.String(), check.Matches, "(?s).*To roll back to the previous image run: tsuru app-deploy-rollback -a some-app registry.somewhere/tsuru/app-some-app:v1.*")
+	pause, err := GetDeployPause(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(pause.DeployEvent, check.Equals, evt.UniqueID)
+	c.Assert(pause.Image, check.Equals, "myimage")
+	c.Assert(pause.RollbackImage, check.Equals, "registry.somewhere/tsuru/app-some-app:v1")
+	c.Assert(pause.Restarts, check.DeepEquals, map[string]int{unit.ID: 1})
+	data := DeployEndData(evt, imageID, nil)
+	c.Assert(data["crashloop"], check.DeepEquals, map[string]interface{}{
+		"reason":        pause.Reason,
+		"restarts":      map[string]int{unit.ID: 1},
+		"rollbackimage": "registry.somewhere/tsuru/app-some-app:v1",
+	})
+	err = evt.DoneCustomData(err, data)
+	c.Assert(err, check.IsNil)
+	evt = s.newDeployEvent(c, a)
+	_, err = Deploy(DeployOptions{App: a, Image: "otherimage", OutputStream: writer, Event: evt})
+	c.Assert(err, check.FitsTypeOf, &ErrDeployPaused{})
+	c.Assert(err.(*ErrDeployPaused).Pause.Image, check.Equals, "myimage")
+}
+
+func (s *S) TestDeployCrashLoopRollbackResumesDeploys(c *check.C) {
+	defer config.Unset("deploy:crash-loop")
+	a := s.setupCrashLoop(c)
+	err := s.conn.DeployPauses().Insert(DeployPause{App: a.Name, Reason: "crash loop", Image: "myimage"})
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	evt := s.newDeployEvent(c, a)
+	_, err = Deploy(DeployOptions{
+		App:          a,
+		Image:        "registry.somewhere/tsuru/app-some-app:v1",
+		Rollback:     true,
+		OutputStream: writer,
+		Event:        evt,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Matches, "(?s).*No crash loops detected.*Deploys paused by a previous crash loop were resumed.*")
+	_, err = GetDeployPause(a.Name)
+	c.Assert(err, check.Equals, ErrDeployNotPaused)
+	c.Assert(DeployEndData(evt, "img", nil)["crashloop"], check.IsNil)
+}
+
+func (s *S) TestDeployCrashLoopDisabled(c *check.C) {
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetUnitStatus(s.provisioner.GetUnits(&a)[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	_, err = Deploy(DeployOptions{App: &a, Image: "myimage", OutputStream: writer, Event: s.newDeployEvent(c, &a)})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Image deploy called")
+}
+
+func (s *S) TestWatchCrashLoopRestarts(c *check.C) {
+	a := &App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	settings := crashLoopSettings{maxRestarts: 2, window: 0, interval: 0}
+	crashes, err := watchCrashLoop(a, settings)
+	c.Assert(err, check.IsNil)
+	c.Assert(crashes, check.IsNil)
+	units := s.provisioner.GetUnits(a)
+	err = s.provisioner.SetUnitStatus(units[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	crashes, err = watchCrashLoop(a, settings)
+	c.Assert(err, check.IsNil)
+	c.Assert(crashes, check.IsNil)
+	settings.maxRestarts = 1
+	crashes, err = watchCrashLoop(a, settings)
+	c.Assert(err, check.IsNil)
+	c.Assert(crashes, check.DeepEquals, map[string]int{units[0].ID: 1})
+}
+
+func (s *S) TestResumeDeploysNotPaused(c *check.C) {
+	err := ResumeDeploys("some-app")
+	c.Assert(err, check.Equals, ErrDeployNotPaused)
+	_, err = GetDeployPause("some-app")
+	c.Assert(err, check.Equals, ErrDeployNotPaused)
+}
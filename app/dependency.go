@@ -0,0 +1,430 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	DependencyTypeApp             = "app"
+	DependencyTypeServiceInstance = "service-instance"
+
+	defaultDependencyWaitTimeout = 5 * time.Minute
+)
+
+var (
+	ErrDependencyAlreadyExists = errors.New("dependency already exists")
+	ErrDependencyNotFound      = errors.New("dependency not found")
+
+	dependencyCheckInterval = 5 * time.Second
+)
+
+// AppDependency is a dependency of an app on another app, set in App, or on
+// a service instance, set in Service and Instance.
+type AppDependency struct {
+	App      string `json:",omitempty" bson:",omitempty"`
+	Service  string `json:",omitempty" bson:",omitempty"`
+	Instance string `json:",omitempty" bson:",omitempty"`
+}
+
+func (d AppDependency) Type() string {
+	if d.App != "" {
+		return DependencyTypeApp
+	}
+	return DependencyTypeServiceInstance
+}
+
+// Name returns the name of the app or the service and instance names
+// separated by a slash.
+func (d AppDependency) Name() string {
+	if d.App != "" {
+		return d.App
+	}
+	return d.Service + "/" + d.Instance
+}
+
+func (d AppDependency) String() string {
+	if d.App != "" {
+		return fmt.Sprintf("app %q", d.App)
+	}
+	return fmt.Sprintf("service instance %q", d.Name())
+}
+
+type ErrDependencyCycle struct {
+	Path []string
+}
+
+func (e *ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Path, " -> "))
+}
+
+type ErrAppDependedOn struct {
+	App  string
+	Apps []string
+}
+
+func (e *ErrAppDependedOn) Error() string {
+	return fmt.Sprintf("app %q is a dependency of the apps: %v", e.App, e.Apps)
+}
+
+type ErrDependenciesNotReady struct {
+	App     string
+	Pending []string
+}
+
+func (e *ErrDependenciesNotReady) Error() string {
+	return fmt.Sprintf("dependencies of app %q are not ready: %s", e.App, strings.Join(e.Pending, ", "))
+}
+
+// AddDependency makes the app depend on another app or on a service
+// instance. Dependencies on apps can't form cycles.
+func (app *App) AddDependency(dep AppDependency) error {
+	switch {
+	case dep.App != "" && (dep.Service != "" || dep.Instance != ""):
+		return &tsuruErrors.ValidationError{Message: "a dependency must be either an app or a service instance"}
+	case dep.App == "" && (dep.Service == "" || dep.Instance == ""):
+		return &tsuruErrors.ValidationError{Message: "the app or the service and the instance of the dependency are required"}
+	case dep.App == app.Name:
+		return &tsuruErrors.ValidationError{Message: "an app can't depend on itself"}
+	}
+	for _, d := range app.Dependencies {
+		if d == dep {
+			return ErrDependencyAlreadyExists
+		}
+	}
+	if dep.App != "" {
+		_, err := GetByName(dep.App)
+		if err != nil {
+			return err
+		}
+		err = checkDependencyCycle(app.Name, dep.App)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := service.GetServiceInstance(dep.Service, dep.Instance)
+		if err != nil {
+			return err
+		}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name, "dependencies": bson.M{"$ne": dep}},
+		bson.M{"$push": bson.M{"dependencies": dep}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrDependencyAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	app.Dependencies = append(app.Dependencies, dep)
+	return nil
+}
+
+// RemoveDependency removes a dependency of the app.
+func (app *App) RemoveDependency(dep AppDependency) error {
+	index := -1
+	for i, d := range app.Dependencies {
+		if d == dep {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrDependencyNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$pull": bson.M{"dependencies": dep}})
+	if err != nil {
+		return err
+	}
+	app.Dependencies = append(app.Dependencies[:index], app.Dependencies[index+1:]...)
+	return nil
+}
+
+// checkDependencyCycle returns an error if the app named depName depends,
+// directly or not, on the app named appName.
+func checkDependencyCycle(appName, depName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	parents := map[string]string{depName: appName}
+	frontier := []string{depName}
+	for len(frontier) > 0 {
+		var apps []App
+		err = conn.Apps().Find(bson.M{"name": bson.M{"$in": frontier}}).Select(bson.M{"name": 1, "dependencies": 1}).All(&apps)
+		if err != nil {
+			return err
+		}
+		frontier = nil
+		for _, a := range apps {
+			for _, d := range a.Dependencies {
+				if d.App == "" {
+					continue
+				}
+				if d.App == appName {
+					path := []string{a.Name, appName}
+					for name := a.Name; name != depName; {
+						name = parents[name]
+						path = append([]string{name}, path...)
+					}
+					return &ErrDependencyCycle{Path: append([]string{appName}, path...)}
+				}
+				if _, seen := parents[d.App]; !seen {
+					parents[d.App] = a.Name
+					frontier = append(frontier, d.App)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkNotDependedOn returns an error if other apps depend on the app.
+func (app *App) checkNotDependedOn() error {
+	names, err := dependentApps(app.Name)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return &ErrAppDependedOn{App: app.Name, Apps: names}
+}
+
+func dependentApps(appName string) ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"dependencies.app": appName}).Select(bson.M{"name": 1}).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(apps))
+	for i := range apps {
+		names[i] = apps[i].Name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// waitDependencies waits until the dependencies of the app are ready, apps
+// with at least one started unit and service instances whose status isn't
+// pending or down, failing after the timeout in the
+// "dependencies:wait-timeout" setting, in seconds, or when ctx is done.
+func (app *App) waitDependencies(ctx context.Context, w io.Writer) error {
+	if len(app.Dependencies) == 0 {
+		return nil
+	}
+	timeout := defaultDependencyWaitTimeout
+	if seconds, err := config.GetInt("dependencies:wait-timeout"); err == nil {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	fmt.Fprintf(w, "---- Waiting for the dependencies of the app %q ----\n", app.Name)
+	deadline := time.Now().Add(timeout)
+	var lastPending string
+	for {
+		var pending []string
+		for _, dep := range app.Dependencies {
+			if !dependencyReady(dep) {
+				pending = append(pending, dep.String())
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return &ErrDependenciesNotReady{App: app.Name, Pending: pending}
+		}
+		if msg := strings.Join(pending, ", "); msg != lastPending {
+			fmt.Fprintf(w, " ---> Waiting for %s\n", msg)
+			lastPending = msg
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dependencyCheckInterval):
+		}
+	}
+}
+
+func dependencyReady(dep AppDependency) bool {
+	if dep.App != "" {
+		a, err := GetByName(dep.App)
+		if err != nil {
+			return false
+		}
+		units, err := a.Units()
+		if err != nil {
+			return false
+		}
+		for _, u := range units {
+			if u.Status == provision.StatusStarted {
+				return true
+			}
+		}
+		return false
+	}
+	instance, err := service.GetServiceInstance(dep.Service, dep.Instance)
+	if err != nil {
+		return false
+	}
+	status, err := instance.Status("")
+	return err == nil && status != "pending" && status != "down"
+}
+
+// DependencyNode is an app or a service instance in a dependency graph.
+type DependencyNode struct {
+	Type string
+	Name string
+}
+
+// DependencyEdge means that the app in From depends on the node in To.
+type DependencyEdge struct {
+	From string
+	To   DependencyNode
+}
+
+// DependencyGraph holds the dependencies of an app, direct or not, and the
+// apps depending on it, the ones affected by problems in the app.
+type DependencyGraph struct {
+	App        string
+	Nodes      []DependencyNode
+	Edges      []DependencyEdge
+	Dependents []string
+}
+
+// DependencyGraph returns the graph of the dependencies of the app and the
+// apps depending on it.
+func (app *App) DependencyGraph() (*DependencyGraph, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	graph := DependencyGraph{App: app.Name}
+	nodes := map[DependencyNode]bool{{Type: DependencyTypeApp, Name: app.Name}: true}
+	visited := map[string]bool{app.Name: true}
+	queue := []*App{app}
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		for _, dep := range a.Dependencies {
+			node := DependencyNode{Type: dep.Type(), Name: dep.Name()}
+			nodes[node] = true
+			graph.Edges = append(graph.Edges, DependencyEdge{From: a.Name, To: node})
+			if dep.App == "" || visited[dep.App] {
+				continue
+			}
+			visited[dep.App] = true
+			var depApp App
+			err = conn.Apps().Find(bson.M{"name": dep.App}).Select(bson.M{"name": 1, "dependencies": 1}).One(&depApp)
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, &depApp)
+		}
+	}
+	dependents := map[string]bool{}
+	frontier := []string{app.Name}
+	for len(frontier) > 0 {
+		name := frontier[0]
+		frontier = frontier[1:]
+		names, err := dependentApps(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, dependent := range names {
+			nodes[DependencyNode{Type: DependencyTypeApp, Name: dependent}] = true
+			edge := DependencyEdge{From: dependent, To: DependencyNode{Type: DependencyTypeApp, Name: name}}
+			if !containsEdge(graph.Edges, edge) {
+				graph.Edges = append(graph.Edges, edge)
+			}
+			if !dependents[dependent] && dependent != app.Name {
+				dependents[dependent] = true
+				graph.Dependents = append(graph.Dependents, dependent)
+				frontier = append(frontier, dependent)
+			}
+		}
+	}
+	for node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		if graph.Nodes[i].Type != graph.Nodes[j].Type {
+			return graph.Nodes[i].Type < graph.Nodes[j].Type
+		}
+		return graph.Nodes[i].Name < graph.Nodes[j].Name
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To.Name < graph.Edges[j].To.Name
+	})
+	sort.Strings(graph.Dependents)
+	return &graph, nil
+}
+
+func containsEdge(edges []DependencyEdge, edge DependencyEdge) bool {
+	for _, e := range edges {
+		if e == edge {
+			return true
+		}
+	}
+	return false
+}
+
+// DOT returns the graph in the DOT language of Graphviz, with service
+// instances drawn as cylinders.
+func (g *DependencyGraph) DOT() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph %q {\n", g.App)
+	for _, n := range g.Nodes {
+		shape := "box"
+		if n.Type == DependencyTypeServiceInstance {
+			shape = "cylinder"
+		}
+		fmt.Fprintf(&buf, "\t%q [shape=%s];\n", n.Name, shape)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\t%q -> %q;\n", e.From, e.To.Name)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
@@ -0,0 +1,251 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) createDependencyApps(c *check.C, names ...string) []*App {
+	apps := make([]*App, len(names))
+	for i, name := range names {
+		a := App{Name: name, Platform: "python", TeamOwner: s.team.Name}
+		err := CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+		apps[i] = &a
+	}
+	return apps
+}
+
+func (s *S) TestAddDependency(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api")
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": "http://localhost:1234"}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{Name: "db", ServiceName: srvc.Name}
+	err = instance.Create()
+	c.Assert(err, check.IsNil)
+	err = apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	err = apps[0].AddDependency(AppDependency{Service: "mysql", Instance: "db"})
+	c.Assert(err, check.IsNil)
+	a, err := GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Dependencies, check.DeepEquals, []AppDependency{
+		{App: "api"},
+		{Service: "mysql", Instance: "db"},
+	})
+	err = a.AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.Equals, ErrDependencyAlreadyExists)
+}
+
+func (s *S) TestAddDependencyInvalid(c *check.C) {
+	apps := s.createDependencyApps(c, "web")
+	tests := []struct {
+		dep AppDependency
+		msg string
+	}{
+		{AppDependency{}, "the app or the service and the instance of the dependency are required"},
+		{AppDependency{Service: "mysql"}, "the app or the service and the instance of the dependency are required"},
+		{AppDependency{App: "api", Service: "mysql", Instance: "db"}, "a dependency must be either an app or a service instance"},
+		{AppDependency{App: "web"}, "an app can't depend on itself"},
+	}
+	for _, tt := range tests {
+		err := apps[0].AddDependency(tt.dep)
+		c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{Message: tt.msg})
+	}
+}
+
+func (s *S) TestAddDependencyNotFound(c *check.C) {
+	apps := s.createDependencyApps(c, "web")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.Equals, ErrAppNotFound)
+	err = apps[0].AddDependency(AppDependency{Service: "mysql", Instance: "db"})
+	c.Assert(err, check.Equals, service.ErrServiceInstanceNotFound)
+}
+
+func (s *S) TestAddDependencyCycle(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api", "worker")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	err = apps[1].AddDependency(AppDependency{App: "worker"})
+	c.Assert(err, check.IsNil)
+	err = apps[2].AddDependency(AppDependency{App: "web"})
+	c.Assert(err, check.DeepEquals, &ErrDependencyCycle{Path: []string{"worker", "web", "api", "worker"}})
+	c.Assert(err, check.ErrorMatches, "dependency cycle: worker -> web -> api -> worker")
+}
+
+func (s *S) TestRemoveDependency(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	err = apps[0].RemoveDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	a, err := GetByName("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Dependencies, check.HasLen, 0)
+	err = a.RemoveDependency(AppDependency{App: "api"})
+	c.Assert(err, check.Equals, ErrDependencyNotFound)
+}
+
+func (s *S) TestDeleteAppDependedOn(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	err = Delete(apps[1], nil)
+	c.Assert(err, check.DeepEquals, &ErrAppDependedOn{App: "api", Apps: []string{"web"}})
+}
+
+func (s *S) TestRestartWaitsDependencies(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	oldInterval := dependencyCheckInterval
+	dependencyCheckInterval = 10 * time.Millisecond
+	defer func() { dependencyCheckInterval = oldInterval }()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.provisioner.AddUnits(apps[1], 1, "web", nil)
+	}()
+	var buf bytes.Buffer
+	err = apps[0].Restart(context.Background(), "", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*---- Waiting for the dependencies of the app "web" ----\n ---> Waiting for app "api"\n.*`)
+	c.Assert(s.provisioner.Restarts(apps[0], ""), check.Equals, 1)
+}
+
+func (s *S) TestRestartDependenciesNotReady(c *check.C) {
+	config.Set("dependencies:wait-timeout", 0)
+	defer config.Unset("dependencies")
+	apps := s.createDependencyApps(c, "web", "api")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	err = apps[0].Restart(context.Background(), "", nil)
+	c.Assert(err, check.DeepEquals, &ErrDependenciesNotReady{App: "web", Pending: []string{`app "api"`}})
+	c.Assert(s.provisioner.Restarts(apps[0], ""), check.Equals, 0)
+}
+
+func (s *S) TestRestartDependenciesContextCanceled(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api")
+	err := apps[0].AddDependency(AppDependency{App: "api"})
+	c.Assert(err, check.IsNil)
+	oldInterval := dependencyCheckInterval
+	dependencyCheckInterval = 10 * time.Millisecond
+	defer func() { dependencyCheckInterval = oldInterval }()
+	ctx, cancel := context.WithCancel(context.Background())
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: "web"},
+		Kind:     permission.PermAppUpdateRestart,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermAppReadEvents),
+		Context:  ctx,
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err = apps[0].RollingRestart(context.Background(), provision.RestartProgress{}, evt)
+	c.Assert(err, check.Equals, context.Canceled)
+	c.Assert(s.provisioner.Restarts(apps[0], ""), check.Equals, 0)
+}
+
+func (s *S) TestDependencyReadyServiceInstance(c *check.C) {
+	status := http.StatusNoContent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	defer s.conn.Services().Remove(bson.M{"_id": srvc.Name})
+	instance := service.ServiceInstance{Name: "db", ServiceName: srvc.Name}
+	err = instance.Create()
+	c.Assert(err, check.IsNil)
+	dep := AppDependency{Service: "mysql", Instance: "db"}
+	c.Assert(dependencyReady(dep), check.Equals, true)
+	status = http.StatusAccepted
+	c.Assert(dependencyReady(dep), check.Equals, false)
+	status = http.StatusInternalServerError
+	c.Assert(dependencyReady(dep), check.Equals, false)
+}
+
+func (s *S) TestDependencyGraph(c *check.C) {
+	apps := s.createDependencyApps(c, "web", "api", "worker", "admin")
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": "http://localhost:1234"}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{Name: "db", ServiceName: srvc.Name}
+	err = instance.Create()
+	c.Assert(err, check.IsNil)
+	c.Assert(apps[0].AddDependency(AppDependency{App: "api"}), check.IsNil)
+	c.Assert(apps[1].AddDependency(AppDependency{App: "worker"}), check.IsNil)
+	c.Assert(apps[2].AddDependency(AppDependency{Service: "mysql", Instance: "db"}), check.IsNil)
+	c.Assert(apps[3].AddDependency(AppDependency{App: "web"}), check.IsNil)
+	graph, err := apps[1].DependencyGraph()
+	c.Assert(err, check.IsNil)
+	c.Assert(graph, check.DeepEquals, &DependencyGraph{
+		App: "api",
+		Nodes: []DependencyNode{
+			{Type: DependencyTypeApp, Name: "admin"},
+			{Type: DependencyTypeApp, Name: "api"},
+			{Type: DependencyTypeApp, Name: "web"},
+			{Type: DependencyTypeApp, Name: "worker"},
+			{Type: DependencyTypeServiceInstance, Name: "mysql/db"},
+		},
+		Edges: []DependencyEdge{
+			{From: "admin", To: DependencyNode{Type: DependencyTypeApp, Name: "web"}},
+			{From: "api", To: DependencyNode{Type: DependencyTypeApp, Name: "worker"}},
+			{From: "web", To: DependencyNode{Type: DependencyTypeApp, Name: "api"}},
+			{From: "worker", To: DependencyNode{Type: DependencyTypeServiceInstance, Name: "mysql/db"}},
+		},
+		Dependents: []string{"admin", "web"},
+	})
+}
+
+func (s *S) TestDependencyGraphDOT(c *check.C) {
+	graph := DependencyGraph{
+		App: "web",
+		Nodes: []DependencyNode{
+			{Type: DependencyTypeApp, Name: "web"},
+			{Type: DependencyTypeServiceInstance, Name: "mysql/db"},
+		},
+		Edges: []DependencyEdge{
+			{From: "web", To: DependencyNode{Type: DependencyTypeServiceInstance, Name: "mysql/db"}},
+		},
+	}
+	c.Assert(graph.DOT(), check.Equals, `digraph "web" {
+	"web" [shape=box];
+	"mysql/db" [shape=cylinder];
+	"web" -> "mysql/db";
+}
+`)
+}
+
+func (s *S) TestDependencyTypeAndName(c *check.C) {
+	dep := AppDependency{Service: "mysql", Instance: "db"}
+	c.Assert(dep.Type(), check.Equals, DependencyTypeServiceInstance)
+	c.Assert(dep.Name(), check.Equals, "mysql/db")
+	c.Assert(dep.String(), check.Equals, `service instance "mysql/db"`)
+	dep = AppDependency{App: "api"}
+	c.Assert(dep.Type(), check.Equals, DependencyTypeApp)
+	c.Assert(dep.Name(), check.Equals, "api")
+	c.Assert(dep.String(), check.Equals, `app "api"`)
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"regexp"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -30,6 +29,7 @@ type DeployKind string
 const (
 	DeployArchiveURL  DeployKind = "archive-url"
 	DeployGit         DeployKind = "git"
+	DeployGitURL      DeployKind = "git-url"
 	DeployImage       DeployKind = "image"
 	DeployRollback    DeployKind = "rollback"
 	DeployUpload      DeployKind = "upload"
@@ -53,6 +53,8 @@ type DeployData struct {
 	CanRollback bool
 	RemoveDate  time.Time `bson:",omitempty"`
 	Diff        string
+	Signature   SignatureStatus    `bson:",omitempty"`
+	Timings     map[string]float64 `bson:",omitempty"`
 }
 
 func findValidImages(apps ...App) (set.Set, error) {
@@ -104,6 +106,26 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 	return list, nil
 }
 
+// LastDeploy returns the last finished deploy of the app, or nil if the app
+// was never deployed.
+func LastDeploy(appName string) (*DeployData, error) {
+	running := false
+	evts, err := event.List(&event.Filter{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: appName},
+		KindName: permission.PermAppDeploy.FullName(),
+		KindType: event.KindTypePermission,
+		Running:  &running,
+		Limit:    1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(evts) == 0 {
+		return nil, nil
+	}
+	return eventToDeployData(&evts[0], nil, false), nil
+}
+
 func GetDeploy(id string) (*DeployData, error) {
 	if !bson.IsObjectIdHex(id) {
 		return nil, errors.Errorf("id parameter is not ObjectId: %s", id)
@@ -139,10 +161,12 @@ func eventToDeployData(evt *event.Event, validImages set.Set, full bool) *Deploy
 			data.Diff = otherData["diff"]
 		}
 	}
+	data.Timings = eventDeployTimings(evt)
 	var endData map[string]string
 	err = evt.EndData(&endData)
 	if err == nil {
 		data.Image = endData["image"]
+		data.Signature = SignatureStatus(endData["signaturestatus"])
 		if validImages != nil {
 			data.CanRollback = validImages.Includes(data.Image)
 			if reImageVersion.MatchString(data.Image) {
@@ -169,6 +193,11 @@ type DeployOptions struct {
 	Event        *event.Event `bson:"-"`
 	Kind         DeployKind
 	Message      string
+	Signature    string
+	GitURL       string                   `bson:",omitempty"`
+	GitRef       string                   `bson:",omitempty"`
+	Strategy     provision.DeployStrategy `bson:",omitempty"`
+	RollbackFrom string                   `bson:",omitempty"`
 }
 
 func (o *DeployOptions) GetOrigin() string {
@@ -191,6 +220,9 @@ func (o *DeployOptions) GetKind() (kind DeployKind) {
 	if o.Image != "" {
 		return DeployImage
 	}
+	if o.GitURL != "" {
+		return DeployGitURL
+	}
 	if o.File != nil {
 		if o.Build {
 			return DeployUploadBuild
@@ -210,25 +242,41 @@ func Deploy(opts DeployOptions) (string, error) {
 	if opts.Event == nil {
 		return "", errors.Errorf("missing event in deploy opts")
 	}
-	if opts.Rollback && !regexp.MustCompile(":v[0-9]+$").MatchString(opts.Image) {
-		validImages, err := findValidImages(*opts.App)
-		if err == nil {
-			inputImage := opts.Image
-			for img := range validImages {
-				if strings.HasSuffix(img, opts.Image) {
-					opts.Image = img
-					break
-				}
-			}
-			if opts.Image == inputImage {
-				return "", errors.Errorf("invalid version: %q", inputImage)
-			}
+	if opts.Rollback {
+		img, err := rollbackImage(opts.App.Name, opts.Image)
+		if err != nil {
+			return "", err
 		}
+		opts.Image = img
+	}
+	err := checkDeployPaused(&opts)
+	if err != nil {
+		return "", err
 	}
+	err = prepareDeployStrategy(&opts)
+	if err != nil {
+		return "", err
+	}
+	opts.App.deployStrategy = opts.Strategy
+	defer func() { opts.App.deployStrategy = provision.DeployStrategy{} }()
 	logWriter := LogWriter{App: opts.App}
 	logWriter.Async()
 	defer logWriter.Close()
 	opts.Event.SetLogWriter(io.MultiWriter(&tsuruIo.NoErrorWriter{Writer: opts.OutputStream}, &logWriter))
+	if !opts.Strategy.IsRolling() {
+		fmt.Fprintf(opts.Event, "---- Deploying with the %s strategy ----\n", opts.Strategy)
+	}
+	err = opts.App.waitDependencies(opts.Event.Context(), opts.Event)
+	if err != nil {
+		return "", err
+	}
+	// Deploys whose request is gone while waiting for dependencies are not
+	// started.
+	err = opts.Event.Context().Err()
+	if err != nil {
+		return "", err
+	}
+	previousImage := previousDeployImage(opts.App.Name)
 	imageId, err := deployToProvisioner(&opts, opts.Event)
 	rebuild.RoutesRebuildOrEnqueue(opts.App.Name)
 	if err != nil {
@@ -241,6 +289,10 @@ func Deploy(opts DeployOptions) (string, error) {
 	if opts.App.UpdatePlatform {
 		opts.App.SetUpdatePlatform(false)
 	}
+	err = checkCrashLoop(&opts, imageId, previousImage)
+	if err != nil {
+		return imageId, err
+	}
 	return imageId, nil
 }
 
@@ -265,11 +317,28 @@ func deployToProvisioner(opts *DeployOptions, evt *event.Event) (string, error)
 		if deployer, ok := prov.(provision.UploadDeployer); ok {
 			return deployer.UploadDeploy(opts.App, opts.File, opts.FileSize, opts.Build, evt)
 		}
+	case DeployGitURL:
+		if deployer, ok := prov.(provision.UploadDeployer); ok {
+			file, fileSize, err := gitArchive(opts)
+			if err != nil {
+				return "", err
+			}
+			defer file.Close()
+			return deployer.UploadDeploy(opts.App, file, fileSize, opts.Build, evt)
+		}
 	case DeployRebuild:
 		if deployer, ok := prov.(provision.RebuildableDeployer); ok {
 			return deployer.Rebuild(opts.App, evt)
 		}
 	default:
+		if opts.File != nil {
+			// Archives whose signature was verified are deployed from the
+			// verified copy, never downloaded again.
+			if deployer, ok := prov.(provision.UploadDeployer); ok {
+				return deployer.UploadDeploy(opts.App, opts.File, opts.FileSize, false, evt)
+			}
+			break
+		}
 		if deployer, ok := prov.(provision.ArchiveDeployer); ok {
 			return deployer.ArchiveDeploy(opts.App, opts.ArchiveURL, evt)
 		}
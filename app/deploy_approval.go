@@ -0,0 +1,29 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetDeployApprovalRequired changes whether deploys of the app must be
+// approved by another user before being started.
+func (app *App) SetDeployApprovalRequired(required bool) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name},
+		bson.M{"$set": bson.M{"requiredeployapproval": required}},
+	)
+	if err != nil {
+		return err
+	}
+	app.RequireDeployApproval = required
+	return nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestSetDeployApprovalRequired(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApprovalRequired(true)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.RequireDeployApproval, check.Equals, true)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RequireDeployApproval, check.Equals, true)
+	err = dbApp.SetDeployApprovalRequired(false)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RequireDeployApproval, check.Equals, false)
+}
@@ -105,6 +105,31 @@ func (s *S) TestListAppDeploys(c *check.C) {
 	}
 }
 
+func (s *S) TestLastDeploy(c *check.C) {
+	deploy, err := LastDeploy("g1")
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy, check.IsNil)
+	insert := []DeployData{
+		{App: "g1", Timestamp: time.Now().Add(-3600 * time.Second), Image: "img:v1"},
+		{App: "g1", Timestamp: time.Now(), Image: "img:v2", User: "me@me.com"},
+		{App: "g2", Timestamp: time.Now(), Image: "img:v3"},
+	}
+	insertDeploysAsEvents(insert, c)
+	running, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: "g1"},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: "me@me.com"},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	defer running.Done(nil)
+	deploy, err = LastDeploy("g1")
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.App, check.Equals, "g1")
+	c.Assert(deploy.Image, check.Equals, "img:v2")
+	c.Assert(deploy.User, check.Equals, "me@me.com")
+}
+
 func (s *S) TestListAppDeploysWithImage(c *check.C) {
 	a := App{Name: "g1", TeamOwner: s.team.Name}
 	err := CreateApp(&a, s.user)
@@ -276,6 +301,37 @@ func (s *S) TestDeployApp(c *check.C) {
 	c.Assert(logs, check.Equals, "Image deploy called")
 }
 
+func (s *S) TestDeployAppArchiveURLVerifiedCopy(c *check.C) {
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		ArchiveURL:   "http://example.com/archive.tar.gz",
+		File:         ioutil.NopCloser(bytes.NewBufferString("my archive")),
+		FileSize:     10,
+		Kind:         DeployArchiveURL,
+		OutputStream: writer,
+		Event:        evt,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Upload deploy called")
+}
+
 func (s *S) TestDeployAppWithUpdatePlatform(c *check.C) {
 	a := App{
 		Name:           "some-app",
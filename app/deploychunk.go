@@ -0,0 +1,240 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	deployChunksPrefix            = "deploy_chunks"
+	defaultDeployChunkMaxSize     = 4 << 20
+	defaultDeployChunksExpiration = 7 * 24 * time.Hour
+)
+
+var (
+	ErrDeployChunkHashMismatch = errors.New("the chunk content doesn't match its hash")
+	ErrDeployChunkTooLarge     = errors.New("the chunk exceeds the maximum chunk size")
+
+	deployChunkHashRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// DeployChunkNotFoundError is returned when assembling an archive from chunks
+// that were never uploaded or expired.
+type DeployChunkNotFoundError struct {
+	Hash string
+}
+
+func (e *DeployChunkNotFoundError) Error() string {
+	return fmt.Sprintf("chunk %s not found, upload it before deploying", e.Hash)
+}
+
+// ValidDeployChunkHash reports whether hash is the hex encoded SHA-256 sum
+// identifying a chunk.
+func ValidDeployChunkHash(hash string) bool {
+	return deployChunkHashRegexp.MatchString(hash)
+}
+
+// DeployChunkMaxSize returns the maximum size, in bytes, of an uploaded chunk,
+// set in deploy:chunks:max-size.
+func DeployChunkMaxSize() int64 {
+	size, _ := config.GetInt("deploy:chunks:max-size")
+	if size <= 0 {
+		return defaultDeployChunkMaxSize
+	}
+	return int64(size)
+}
+
+func deployChunksExpiration() time.Duration {
+	seconds, _ := config.GetFloat("deploy:chunks:expiration")
+	if seconds <= 0 {
+		return defaultDeployChunksExpiration
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// deployChunkName is the name of the chunk in GridFS. Chunks are scoped by
+// app, an app can't deploy chunks uploaded to other apps.
+func deployChunkName(appName, hash string) string {
+	return appName + "/" + hash
+}
+
+func deployChunksFS(conn *db.Storage) *mgo.GridFS {
+	return conn.Collection(deployChunksPrefix).Database.GridFS(deployChunksPrefix)
+}
+
+// MissingDeployChunks returns the hashes, in the given order, of the chunks
+// not stored for the app yet. The stored ones have their expiration renewed.
+func (app *App) MissingDeployChunks(hashes []string) ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	names := make([]string, len(hashes))
+	for i, hash := range hashes {
+		names[i] = deployChunkName(app.Name, hash)
+	}
+	gfs := deployChunksFS(conn)
+	var stored []struct {
+		Filename string
+	}
+	query := bson.M{"filename": bson.M{"$in": names}}
+	err = gfs.Find(query).Select(bson.M{"filename": 1}).All(&stored)
+	if err != nil {
+		return nil, err
+	}
+	_, err = gfs.Files.UpdateAll(query, bson.M{"$set": bson.M{"metadata.lastused": time.Now().UTC()}})
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(stored))
+	for _, file := range stored {
+		found[file.Filename] = true
+	}
+	missing := []string{}
+	for i, name := range names {
+		if !found[name] {
+			missing = append(missing, hashes[i])
+			found[name] = true
+		}
+	}
+	return missing, nil
+}
+
+// SaveDeployChunk stores a chunk of a deploy archive, checking that its
+// content matches the hash. Saving a chunk that is already stored is a no-op.
+func (app *App) SaveDeployChunk(hash string, r io.Reader) error {
+	maxSize := DeployChunkMaxSize()
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > maxSize {
+		return ErrDeployChunkTooLarge
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return ErrDeployChunkHashMismatch
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	name := deployChunkName(app.Name, hash)
+	gfs := deployChunksFS(conn)
+	n, err := gfs.Find(bson.M{"filename": name}).Count()
+	if err != nil || n > 0 {
+		return err
+	}
+	file, err := gfs.Create(name)
+	if err != nil {
+		return err
+	}
+	file.SetMeta(bson.M{"app": app.Name, "lastused": time.Now().UTC()})
+	_, err = file.Write(data)
+	if err != nil {
+		file.Abort()
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// deployChunksFile is the archive assembled from chunks, removed from disk
+// when closed.
+type deployChunksFile struct {
+	*os.File
+}
+
+func (f *deployChunksFile) Close() error {
+	defer os.Remove(f.Name())
+	return f.File.Close()
+}
+
+// AssembleDeployChunks concatenates the stored chunks with the given hashes,
+// in order, into a temporary file that is removed when closed. Expired
+// chunks of the app are removed afterwards.
+func (app *App) AssembleDeployChunks(hashes []string) (multipart.File, int64, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	tmp, err := ioutil.TempFile("", "tsuru-deploy-")
+	if err != nil {
+		return nil, 0, err
+	}
+	archive := &deployChunksFile{File: tmp}
+	gfs := deployChunksFS(conn)
+	var size int64
+	for _, hash := range hashes {
+		var n int64
+		n, err = copyDeployChunk(gfs, archive, deployChunkName(app.Name, hash))
+		if err == mgo.ErrNotFound {
+			err = &DeployChunkNotFoundError{Hash: hash}
+		}
+		if err != nil {
+			archive.Close()
+			return nil, 0, err
+		}
+		size += n
+	}
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		archive.Close()
+		return nil, 0, err
+	}
+	err = removeExpiredDeployChunks(gfs, app.Name)
+	if err != nil {
+		log.Errorf("[deploy chunks] unable to remove expired chunks of app %q: %s", app.Name, err)
+	}
+	return archive, size, nil
+}
+
+func copyDeployChunk(gfs *mgo.GridFS, w io.Writer, name string) (int64, error) {
+	file, err := gfs.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(w, file)
+}
+
+func removeExpiredDeployChunks(gfs *mgo.GridFS, appName string) error {
+	var expired []struct {
+		ID interface{} `bson:"_id"`
+	}
+	err := gfs.Find(bson.M{
+		"metadata.app":      appName,
+		"metadata.lastused": bson.M{"$lt": time.Now().UTC().Add(-deployChunksExpiration())},
+	}).Select(bson.M{"_id": 1}).All(&expired)
+	if err != nil {
+		return err
+	}
+	for _, file := range expired {
+		err = gfs.RemoveId(file.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func chunkHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *S) TestValidDeployChunkHash(c *check.C) {
+	c.Assert(ValidDeployChunkHash(chunkHash("hello")), check.Equals, true)
+	c.Assert(ValidDeployChunkHash(strings.ToUpper(chunkHash("hello"))), check.Equals, false)
+	c.Assert(ValidDeployChunkHash("abc"), check.Equals, false)
+	c.Assert(ValidDeployChunkHash("../"+chunkHash("hello")[3:]), check.Equals, false)
+}
+
+func (s *S) TestSaveDeployChunkAndMissing(c *check.C) {
+	a := App{Name: "myapp"}
+	hello, world := chunkHash("hello "), chunkHash("world")
+	missing, err := a.MissingDeployChunks([]string{hello, world, hello})
+	c.Assert(err, check.IsNil)
+	c.Assert(missing, check.DeepEquals, []string{hello, world})
+	err = a.SaveDeployChunk(hello, strings.NewReader("hello "))
+	c.Assert(err, check.IsNil)
+	err = a.SaveDeployChunk(hello, strings.NewReader("hello "))
+	c.Assert(err, check.IsNil)
+	missing, err = a.MissingDeployChunks([]string{hello, world})
+	c.Assert(err, check.IsNil)
+	c.Assert(missing, check.DeepEquals, []string{world})
+	n, err := deployChunksFS(s.conn).Find(nil).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	other := App{Name: "otherapp"}
+	missing, err = other.MissingDeployChunks([]string{hello})
+	c.Assert(err, check.IsNil)
+	c.Assert(missing, check.DeepEquals, []string{hello})
+}
+
+func (s *S) TestSaveDeployChunkHashMismatch(c *check.C) {
+	a := App{Name: "myapp"}
+	err := a.SaveDeployChunk(chunkHash("hello"), strings.NewReader("world"))
+	c.Assert(err, check.Equals, ErrDeployChunkHashMismatch)
+	n, err := deployChunksFS(s.conn).Find(nil).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestSaveDeployChunkTooLarge(c *check.C) {
+	config.Set("deploy:chunks:max-size", 4)
+	defer config.Unset("deploy:chunks:max-size")
+	a := App{Name: "myapp"}
+	err := a.SaveDeployChunk(chunkHash("hello"), strings.NewReader("hello"))
+	c.Assert(err, check.Equals, ErrDeployChunkTooLarge)
+}
+
+func (s *S) TestAssembleDeployChunks(c *check.C) {
+	a := App{Name: "myapp"}
+	hello, world := chunkHash("hello "), chunkHash("world")
+	err := a.SaveDeployChunk(hello, strings.NewReader("hello "))
+	c.Assert(err, check.IsNil)
+	err = a.SaveDeployChunk(world, bytes.NewBufferString("world"))
+	c.Assert(err, check.IsNil)
+	file, size, err := a.AssembleDeployChunks([]string{hello, world, hello})
+	c.Assert(err, check.IsNil)
+	defer file.Close()
+	c.Assert(size, check.Equals, int64(17))
+	data, err := ioutil.ReadAll(file)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "hello worldhello ")
+}
+
+func (s *S) TestAssembleDeployChunksNotFound(c *check.C) {
+	a := App{Name: "myapp"}
+	hello := chunkHash("hello")
+	err := a.SaveDeployChunk(hello, strings.NewReader("hello"))
+	c.Assert(err, check.IsNil)
+	other := App{Name: "otherapp"}
+	_, _, err = other.AssembleDeployChunks([]string{hello})
+	c.Assert(err, check.DeepEquals, &DeployChunkNotFoundError{Hash: hello})
+}
+
+func (s *S) TestAssembleDeployChunksRemovesExpired(c *check.C) {
+	a := App{Name: "myapp"}
+	hello, world := chunkHash("hello"), chunkHash("world")
+	err := a.SaveDeployChunk(hello, strings.NewReader("hello"))
+	c.Assert(err, check.IsNil)
+	err = a.SaveDeployChunk(world, strings.NewReader("world"))
+	c.Assert(err, check.IsNil)
+	gfs := deployChunksFS(s.conn)
+	err = gfs.Files.Update(
+		bson.M{"filename": deployChunkName(a.Name, world)},
+		bson.M{"$set": bson.M{"metadata.lastused": time.Now().UTC().Add(-8 * 24 * time.Hour)}},
+	)
+	c.Assert(err, check.IsNil)
+	file, _, err := a.AssembleDeployChunks([]string{hello})
+	c.Assert(err, check.IsNil)
+	file.Close()
+	missing, err := a.MissingDeployChunks([]string{hello, world})
+	c.Assert(err, check.IsNil)
+	c.Assert(missing, check.DeepEquals, []string{world})
+}
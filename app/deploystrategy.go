@@ -0,0 +1,50 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const defaultDeployStepInterval = 30 * time.Second
+
+// GetDeployStrategy returns the strategy of the deploy being run, the rolling
+// strategy when the app isn't being deployed.
+func (app *App) GetDeployStrategy() provision.DeployStrategy {
+	return app.deployStrategy
+}
+
+// prepareDeployStrategy validates the strategy of the deploy against the
+// provisioner of the app, filling the default step interval.
+func prepareDeployStrategy(opts *DeployOptions) error {
+	strategy := &opts.Strategy
+	if strategy.IsRolling() {
+		*strategy = provision.DeployStrategy{}
+		return nil
+	}
+	err := strategy.Validate()
+	if err != nil {
+		return err
+	}
+	if strategy.StepInterval == 0 {
+		strategy.StepInterval = defaultDeployStepInterval
+		if seconds, _ := config.GetFloat("deploy:strategy:step-interval"); seconds > 0 {
+			strategy.StepInterval = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	prov, err := opts.App.getProvisioner()
+	if err != nil {
+		return err
+	}
+	deployer, ok := prov.(provision.StrategyDeployer)
+	if !ok {
+		return provision.ProvisionerNotSupported{Prov: prov, Action: fmt.Sprintf("%s deploys", strategy.Name)}
+	}
+	return deployer.ValidateDeployStrategy(opts.App, *strategy)
+}
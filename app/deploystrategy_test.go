@@ -0,0 +1,101 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) newStrategyDeployEvent(c *check.C, a *App) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestDeployWithStrategy(c *check.C) {
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	strategy := provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: 5}
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: writer,
+		Event:        s.newStrategyDeployEvent(c, &a),
+		Strategy:     strategy,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "---- Deploying with the canary in 5 steps strategy ----\nImage deploy called")
+	strategy.StepInterval = defaultDeployStepInterval
+	c.Assert(s.provisioner.LastDeployStrategy(&a), check.DeepEquals, strategy)
+	c.Assert(a.GetDeployStrategy(), check.DeepEquals, provision.DeployStrategy{})
+}
+
+func (s *S) TestDeployWithStrategyStepIntervalFromConfig(c *check.C) {
+	config.Set("deploy:strategy:step-interval", 90)
+	defer config.Unset("deploy:strategy")
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		Event:        s.newStrategyDeployEvent(c, &a),
+		Strategy:     provision.DeployStrategy{Name: provision.DeployStrategyBlueGreen},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.LastDeployStrategy(&a), check.DeepEquals, provision.DeployStrategy{
+		Name:         provision.DeployStrategyBlueGreen,
+		StepInterval: 90 * time.Second,
+	})
+}
+
+func (s *S) TestDeployWithRollingStrategy(c *check.C) {
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: writer,
+		Event:        s.newStrategyDeployEvent(c, &a),
+		Strategy:     provision.DeployStrategy{Name: provision.DeployStrategyRolling},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Image deploy called")
+	c.Assert(s.provisioner.LastDeployStrategy(&a), check.DeepEquals, provision.DeployStrategy{})
+}
+
+func (s *S) TestDeployWithStrategyNotSupported(c *check.C) {
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("ValidateDeployStrategy", errors.New("router can't split traffic"))
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		Event:        s.newStrategyDeployEvent(c, &a),
+		Strategy:     provision.DeployStrategy{Name: provision.DeployStrategyCanary},
+	})
+	c.Assert(err, check.ErrorMatches, "router can't split traffic")
+	c.Assert(s.provisioner.LastDeployStrategy(&a), check.DeepEquals, provision.DeployStrategy{})
+}
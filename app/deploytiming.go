@@ -0,0 +1,128 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// deployPhasesOrder is the order in which phases happen in a deploy, used to
+// sort timing stats. Unknown phases are sorted by name after them.
+var deployPhasesOrder = []string{
+	provision.DeployPhaseUpload,
+	provision.DeployPhaseBuild,
+	provision.DeployPhaseImagePush,
+	provision.DeployPhaseUnitStart,
+	provision.DeployPhaseHealthcheck,
+	provision.DeployPhaseRouteUpdate,
+}
+
+// DeployPhaseStats aggregates the durations, in seconds, of a phase in a set
+// of deploys.
+type DeployPhaseStats struct {
+	Phase   string
+	Count   int
+	Total   float64
+	Average float64
+	Max     float64
+}
+
+// deployTimings returns the total duration, in seconds, of each phase timed
+// in the deploy event.
+func deployTimings(evt *event.Event) map[string]float64 {
+	phases := evt.Phases()
+	if len(phases) == 0 {
+		return nil
+	}
+	timings := make(map[string]float64, len(phases))
+	for _, p := range phases {
+		timings[p.Name] += p.Duration.Seconds()
+	}
+	return timings
+}
+
+func eventDeployTimings(evt *event.Event) map[string]float64 {
+	var endData struct {
+		Timings map[string]float64
+	}
+	err := evt.EndData(&endData)
+	if err != nil {
+		return nil
+	}
+	return endData.Timings
+}
+
+// DeployTimingStats aggregates the phase timings of the successful deploys
+// of the apps matching the filter started after since.
+func DeployTimingStats(filter *Filter, since time.Time) ([]DeployPhaseStats, error) {
+	appsList, err := List(filter)
+	if err != nil {
+		return nil, err
+	}
+	apps := make([]string, len(appsList))
+	for i, a := range appsList {
+		apps[i] = a.GetName()
+	}
+	running := false
+	evts, err := event.List(&event.Filter{
+		Target:   event.Target{Type: event.TargetTypeApp},
+		KindName: permission.PermAppDeploy.FullName(),
+		KindType: event.KindTypePermission,
+		Running:  &running,
+		Since:    since,
+		Raw: bson.M{
+			"target.value":          bson.M{"$in": apps},
+			"error":                 "",
+			"endcustomdata.timings": bson.M{"$exists": true},
+		},
+		Limit: -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	statsMap := map[string]*DeployPhaseStats{}
+	for i := range evts {
+		for phase, seconds := range eventDeployTimings(&evts[i]) {
+			stats := statsMap[phase]
+			if stats == nil {
+				stats = &DeployPhaseStats{Phase: phase}
+				statsMap[phase] = stats
+			}
+			stats.Count++
+			stats.Total += seconds
+			if seconds > stats.Max {
+				stats.Max = seconds
+			}
+		}
+	}
+	result := make([]DeployPhaseStats, 0, len(statsMap))
+	for _, stats := range statsMap {
+		stats.Average = stats.Total / float64(stats.Count)
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		pi, pj := deployPhaseIndex(result[i].Phase), deployPhaseIndex(result[j].Phase)
+		if pi == pj {
+			return result[i].Phase < result[j].Phase
+		}
+		return pi < pj
+	})
+	return result, nil
+}
+
+func deployPhaseIndex(phase string) int {
+	for i, p := range deployPhasesOrder {
+		if p == phase {
+			return i
+		}
+	}
+	return len(deployPhasesOrder)
+}
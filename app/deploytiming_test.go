@@ -0,0 +1,75 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeployEndDataTimings(c *check.C) {
+	evt := &event.Event{}
+	now := time.Now()
+	evt.AddPhase(provision.DeployPhaseUpload, now, 2*time.Second)
+	evt.AddPhase(provision.DeployPhaseRouteUpdate, now, time.Second)
+	evt.AddPhase(provision.DeployPhaseRouteUpdate, now, 500*time.Millisecond)
+	c.Assert(DeployEndData(evt, "img:v1", nil), check.DeepEquals, map[string]interface{}{
+		"image": "img:v1",
+		"timings": map[string]float64{
+			provision.DeployPhaseUpload:      2,
+			provision.DeployPhaseRouteUpdate: 1.5,
+		},
+	})
+}
+
+func (s *S) TestDeployTimingStats(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	now := time.Now()
+	timings := []map[string]time.Duration{
+		{provision.DeployPhaseBuild: 10 * time.Second, provision.DeployPhaseUpload: time.Second, "custom": time.Second},
+		{provision.DeployPhaseBuild: 20 * time.Second, provision.DeployPhaseHealthcheck: 4 * time.Second},
+		{},
+	}
+	for _, phases := range timings {
+		evt, err := event.New(&event.Opts{
+			Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+			Kind:       permission.PermAppDeploy,
+			RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+			Allowed:    event.Allowed(permission.PermApp),
+			CustomData: DeployOptions{Origin: "git"},
+		})
+		c.Assert(err, check.IsNil)
+		for name, d := range phases {
+			evt.AddPhase(name, now, d)
+		}
+		err = evt.DoneCustomData(nil, DeployEndData(evt, "img:v1", nil))
+		c.Assert(err, check.IsNil)
+	}
+	stats, err := DeployTimingStats(&Filter{}, now.Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(stats, check.DeepEquals, []DeployPhaseStats{
+		{Phase: provision.DeployPhaseUpload, Count: 1, Total: 1, Average: 1, Max: 1},
+		{Phase: provision.DeployPhaseBuild, Count: 2, Total: 30, Average: 15, Max: 20},
+		{Phase: provision.DeployPhaseHealthcheck, Count: 1, Total: 4, Average: 4, Max: 4},
+		{Phase: "custom", Count: 1, Total: 1, Average: 1, Max: 1},
+	})
+	stats, err = DeployTimingStats(&Filter{Name: "otherapp"}, now.Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(stats, check.HasLen, 0)
+	deploys, err := ListDeploys(nil, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 3)
+	c.Assert(deploys[2].Timings, check.DeepEquals, map[string]float64{
+		provision.DeployPhaseBuild:  10,
+		provision.DeployPhaseUpload: 1,
+		"custom":                    1,
+	})
+}
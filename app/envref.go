@@ -0,0 +1,170 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// envReferenceRegexp matches references to other apps in the value of
+// environment variables, either to their address, {{app "name".address}}, or
+// to one of their public variables, {{app "name".env.VARIABLE}}.
+var envReferenceRegexp = regexp.MustCompile(`\{\{\s*app\s+"([^"]+)"\.(address|env\.([A-Za-z_][A-Za-z0-9_]*))\s*\}\}`)
+
+type ErrAppReferenced struct {
+	App  string
+	Apps []string
+}
+
+func (e *ErrAppReferenced) Error() string {
+	return fmt.Sprintf("app %q is referenced by environment variables of the apps: %v", e.App, e.Apps)
+}
+
+// EnvReferences returns the names of the apps referenced by the given value
+// of an environment variable.
+func EnvReferences(value string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range envReferenceRegexp.FindAllStringSubmatch(value, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// resolveEnvReferences replaces the references to other apps in value with
+// their current address or variable values.
+func (app *App) resolveEnvReferences(value string) (string, error) {
+	refApps := map[string]*App{}
+	var resolveErr error
+	resolved := envReferenceRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+		if resolveErr != nil {
+			return ref
+		}
+		match := envReferenceRegexp.FindStringSubmatch(ref)
+		name := match[1]
+		if name == app.Name {
+			resolveErr = errors.Errorf("app %q can't reference itself in environment variables", name)
+			return ref
+		}
+		refApp, ok := refApps[name]
+		if !ok {
+			var err error
+			refApp, err = GetByName(name)
+			if err != nil {
+				resolveErr = errors.Wrapf(err, "unable to resolve reference to app %q", name)
+				return ref
+			}
+			refApps[name] = refApp
+		}
+		if match[2] == "address" {
+			return refApp.Ip
+		}
+		env, ok := refApp.Env[match[3]]
+		if !ok || !env.Public {
+			resolveErr = errors.Errorf("app %q has no public environment variable %q", name, match[3])
+			return ref
+		}
+		return env.Value
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// envReferences returns the sorted names of the apps referenced by the
+// environment variables of the app.
+func (app *App) envReferences() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, env := range app.Env {
+		for _, name := range EnvReferences(env.Reference) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkNotReferenced returns an error if the environment variables of other
+// apps reference the app.
+func (app *App) checkNotReferenced() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"envreferences": app.Name}).Select(bson.M{"name": 1}).All(&apps)
+	if err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		return nil
+	}
+	names := make([]string, len(apps))
+	for i := range apps {
+		names[i] = apps[i].Name
+	}
+	sort.Strings(names)
+	return &ErrAppReferenced{App: app.Name, Apps: names}
+}
+
+// updateEnvReferences resolves again the variables of the apps referencing
+// the app named appName, restarting the ones with changed values. It's called
+// after the address or the variables of an app change.
+func updateEnvReferences(appName string) {
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("[env-references] unable to update references to app %q: %s", appName, err)
+		return
+	}
+	var apps []App
+	err = conn.Apps().Find(bson.M{"envreferences": appName}).All(&apps)
+	conn.Close()
+	if err != nil {
+		log.Errorf("[env-references] unable to update references to app %q: %s", appName, err)
+		return
+	}
+	for i := range apps {
+		a := &apps[i]
+		var changed []bind.EnvVar
+		for _, env := range a.Env {
+			if env.Reference == "" {
+				continue
+			}
+			value, err := a.resolveEnvReferences(env.Reference)
+			if err != nil {
+				log.Errorf("[env-references] unable to update env %s of app %q: %s", env.Name, a.Name, err)
+				continue
+			}
+			if value != env.Value {
+				env.Value = value
+				changed = append(changed, env)
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		err = a.SetEnvs(bind.SetEnvApp{Envs: changed, ShouldRestart: true}, nil)
+		if err != nil {
+			log.Errorf("[env-references] unable to update envs of app %q: %s", a.Name, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/app/bind"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEnvReferences(c *check.C) {
+	c.Assert(EnvReferences("plain value"), check.IsNil)
+	c.Assert(EnvReferences(`http://{{app "billing-api".address}}/v1`), check.DeepEquals, []string{"billing-api"})
+	value := `{{ app "a1".address }}:{{app "a2".env.PORT}},{{app "a1".env.HOST}}`
+	c.Assert(EnvReferences(value), check.DeepEquals, []string{"a1", "a2"})
+	c.Assert(EnvReferences(`{{app "a1".other}}`), check.IsNil)
+}
+
+func (s *S) TestSetEnvsResolvesReferences(c *check.C) {
+	billing := App{Name: "billing-api", TeamOwner: s.team.Name}
+	err := CreateApp(&billing, s.user)
+	c.Assert(err, check.IsNil)
+	err = billing.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "PORT", Value: "8080", Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	err = billing.UpdateAddr()
+	c.Assert(err, check.IsNil)
+	a := App{Name: "frontend", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	ref := `http://{{app "billing-api".address}}:{{app "billing-api".env.PORT}}`
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "BILLING_URL", Value: ref, Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["BILLING_URL"], check.DeepEquals, bind.EnvVar{
+		Name:      "BILLING_URL",
+		Value:     "http://" + billing.Ip + ":8080",
+		Public:    true,
+		Reference: ref,
+	})
+	c.Assert(dbApp.EnvReferences, check.DeepEquals, []string{"billing-api"})
+}
+
+func (s *S) TestSetEnvsInvalidReferences(c *check.C) {
+	other := App{Name: "other", TeamOwner: s.team.Name}
+	err := CreateApp(&other, s.user)
+	c.Assert(err, check.IsNil)
+	err = other.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "SECRET", Value: "s3cr3t", Public: false}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	a := App{Name: "frontend", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		value string
+		msg   string
+	}{
+		{`{{app "unknown".address}}`, `unable to resolve reference to app "unknown": App not found.`},
+		{`{{app "frontend".address}}`, `app "frontend" can't reference itself in environment variables`},
+		{`{{app "other".env.SECRET}}`, `app "other" has no public environment variable "SECRET"`},
+		{`{{app "other".env.MISSING}}`, `app "other" has no public environment variable "MISSING"`},
+	}
+	for _, tt := range tests {
+		err = a.SetEnvs(bind.SetEnvApp{
+			Envs: []bind.EnvVar{{Name: "REF", Value: tt.value, Public: true}},
+		}, nil)
+		c.Check(err, check.ErrorMatches, tt.msg)
+	}
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	_, ok := dbApp.Env["REF"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestReferencesUpdatedWhenReferencedAppChanges(c *check.C) {
+	billing := App{Name: "billing-api", TeamOwner: s.team.Name}
+	err := CreateApp(&billing, s.user)
+	c.Assert(err, check.IsNil)
+	err = billing.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "PORT", Value: "8080", Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	a := App{Name: "frontend", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs:          []bind.EnvVar{{Name: "BILLING_PORT", Value: `{{app "billing-api".env.PORT}}`, Public: true}},
+		ShouldRestart: true,
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 1)
+	err = billing.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "PORT", Value: "9090", Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["BILLING_PORT"].Value, check.Equals, "9090")
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 2)
+	err = billing.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "OTHER", Value: "value", Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 2)
+}
+
+func (s *S) TestReferencesUpdatedOnSwap(c *check.C) {
+	app1 := App{Name: "app1", TeamOwner: s.team.Name}
+	err := CreateApp(&app1, s.user)
+	c.Assert(err, check.IsNil)
+	err = app1.UpdateAddr()
+	c.Assert(err, check.IsNil)
+	app2 := App{Name: "app2", TeamOwner: s.team.Name}
+	err = CreateApp(&app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = app2.UpdateAddr()
+	c.Assert(err, check.IsNil)
+	a := App{Name: "frontend", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "APP1_ADDR", Value: `{{app "app1".address}}`, Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	oldIp2 := app2.Ip
+	err = Swap(&app1, &app2, false)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["APP1_ADDR"].Value, check.Equals, oldIp2)
+}
+
+func (s *S) TestDeleteReferencedApp(c *check.C) {
+	billing := App{Name: "billing-api", TeamOwner: s.team.Name}
+	err := CreateApp(&billing, s.user)
+	c.Assert(err, check.IsNil)
+	a := App{Name: "frontend", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "BILLING", Value: `{{app "billing-api".address}}`, Public: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	err = Delete(&billing, nil)
+	c.Assert(err, check.FitsTypeOf, &ErrAppReferenced{})
+	c.Assert(err, check.ErrorMatches, `app "billing-api" is referenced by environment variables of the apps: \[frontend\]`)
+	c.Assert(s.provisioner.Provisioned(&billing), check.Equals, true)
+	err = a.UnsetEnvs(bind.UnsetEnvApp{VariableNames: []string{"BILLING"}}, nil)
+	c.Assert(err, check.IsNil)
+	err = Delete(&billing, nil)
+	c.Assert(err, check.IsNil)
+}
@@ -0,0 +1,309 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	EnvRevisionInitial = "initial"
+	EnvRevisionSet     = "set"
+	EnvRevisionUnset   = "unset"
+	EnvRevisionRestore = "restore"
+
+	EnvAdded   = "added"
+	EnvRemoved = "removed"
+	EnvChanged = "changed"
+)
+
+var ErrEnvRevisionNotFound = errors.New("env revision not found")
+
+// internalEnvs are the variables managed by tsuru, which are neither recorded
+// in revisions nor changed when revisions are restored.
+var internalEnvs = map[string]bool{
+	"TSURU_APPNAME":     true,
+	"TSURU_APPDIR":      true,
+	"TSURU_APP_TOKEN":   true,
+	TsuruServicesEnvVar: true,
+}
+
+// EnvRevision is a version of the environment variables set by users in an
+// app, recorded on every env-set, env-unset and restore. Variables set by
+// service instances aren't part of revisions.
+type EnvRevision struct {
+	App          string
+	Version      int
+	Timestamp    time.Time
+	Kind         string
+	User         string   `json:",omitempty"`
+	Changed      []string `json:",omitempty"`
+	RestoredFrom int      `json:",omitempty" bson:",omitempty"`
+	Envs         map[string]bind.EnvVar
+}
+
+// EnvRevisionOpts describes the change recorded in a new revision. Previous
+// holds the variables before the change, recorded as the initial revision of
+// apps without revisions.
+type EnvRevisionOpts struct {
+	Kind         string
+	User         string
+	Changed      []string
+	RestoredFrom int
+	Previous     map[string]bind.EnvVar
+}
+
+// EnvDiff is the change of a single variable between two revisions.
+type EnvDiff struct {
+	Name   string
+	Change string
+	From   *bind.EnvVar `json:",omitempty"`
+	To     *bind.EnvVar `json:",omitempty"`
+}
+
+// UserEnvs returns the variables of the app set by users.
+func (app *App) UserEnvs() map[string]bind.EnvVar {
+	return userEnvs(app.Env)
+}
+
+func userEnvs(envs map[string]bind.EnvVar) map[string]bind.EnvVar {
+	result := make(map[string]bind.EnvVar)
+	for name, env := range envs {
+		if env.InstanceName == "" && !internalEnvs[name] {
+			result[name] = env
+		}
+	}
+	return result
+}
+
+// SaveEnvRevision records the current variables of the app set by users as a
+// new revision, unless they're the same of the last revision.
+func (app *App) SaveEnvRevision(opts EnvRevisionOpts) (*EnvRevision, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	current := app.UserEnvs()
+	for attempt := 0; ; attempt++ {
+		var last EnvRevision
+		err = conn.AppEnvRevisions().Find(bson.M{"app": app.Name}).Sort("-version").One(&last)
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+		var revisions []interface{}
+		if err == mgo.ErrNotFound {
+			if previous := userEnvs(opts.Previous); len(previous) > 0 && !envsEqual(previous, current) {
+				last = EnvRevision{
+					App:       app.Name,
+					Version:   1,
+					Timestamp: time.Now().UTC(),
+					Kind:      EnvRevisionInitial,
+					Envs:      previous,
+				}
+				revisions = append(revisions, last)
+			}
+		} else if envsEqual(last.Envs, current) {
+			return &last, nil
+		}
+		rev := EnvRevision{
+			App:          app.Name,
+			Version:      last.Version + 1,
+			Timestamp:    time.Now().UTC(),
+			Kind:         opts.Kind,
+			User:         opts.User,
+			Changed:      opts.Changed,
+			RestoredFrom: opts.RestoredFrom,
+			Envs:         current,
+		}
+		revisions = append(revisions, rev)
+		err = conn.AppEnvRevisions().Insert(revisions...)
+		if mgo.IsDup(err) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &rev, nil
+	}
+}
+
+// ListEnvRevisions returns the revisions of the variables of the app, the
+// newest first.
+func ListEnvRevisions(appName string) ([]EnvRevision, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var revisions []EnvRevision
+	err = conn.AppEnvRevisions().Find(bson.M{"app": appName}).Sort("-version").All(&revisions)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetEnvRevision returns a revision of the variables of the app.
+func GetEnvRevision(appName string, version int) (*EnvRevision, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var rev EnvRevision
+	err = conn.AppEnvRevisions().Find(bson.M{"app": appName, "version": version}).One(&rev)
+	if err == mgo.ErrNotFound {
+		return nil, ErrEnvRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// DiffEnvs returns the changes needed to go from the variables in from to the
+// ones in to, sorted by name.
+func DiffEnvs(from, to map[string]bind.EnvVar) []EnvDiff {
+	var diffs []EnvDiff
+	for name, fromEnv := range from {
+		fromEnv := fromEnv
+		toEnv, ok := to[name]
+		if !ok {
+			diffs = append(diffs, EnvDiff{Name: name, Change: EnvRemoved, From: &fromEnv})
+		} else if !envEqual(fromEnv, toEnv) {
+			diffs = append(diffs, EnvDiff{Name: name, Change: EnvChanged, From: &fromEnv, To: &toEnv})
+		}
+	}
+	for name, toEnv := range to {
+		toEnv := toEnv
+		if _, ok := from[name]; !ok {
+			diffs = append(diffs, EnvDiff{Name: name, Change: EnvAdded, To: &toEnv})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// RestoreEnvRevision sets the variables of the app set by users to the ones of
+// the revision, recording the restore as a new revision. Variables set by
+// service instances and secret variables are kept.
+func (app *App) RestoreEnvRevision(version int, user string, shouldRestart bool, w io.Writer) (*EnvRevision, error) {
+	rev, err := GetEnvRevision(app.Name, version)
+	if err != nil {
+		return nil, err
+	}
+	previous := app.UserEnvs()
+	var diffs []EnvDiff
+	for _, diff := range DiffEnvs(previous, rev.Envs) {
+		// The values of secret variables are stored only in the secrets
+		// backend, and the ones recorded in revisions may be gone.
+		if (diff.From == nil || !diff.From.Secret) && (diff.To == nil || !diff.To.Secret) {
+			diffs = append(diffs, diff)
+		}
+	}
+	if len(diffs) == 0 {
+		return nil, errors.Errorf("environment variables already match revision %d", version)
+	}
+	if w != nil {
+		fmt.Fprintf(w, "---- Restoring environment variables of revision %d ----\n", version)
+	}
+	if app.Env == nil {
+		app.Env = make(map[string]bind.EnvVar)
+	}
+	changed := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		if current, ok := app.Env[diff.Name]; ok && current.InstanceName != "" {
+			continue
+		}
+		changed = append(changed, diff.Name)
+		if diff.To == nil {
+			delete(app.Env, diff.Name)
+			continue
+		}
+		env := *diff.To
+		if env.Reference != "" {
+			env.Value, err = app.resolveEnvReferences(env.Reference)
+			if err != nil {
+				return nil, err
+			}
+		}
+		app.setEnv(env)
+	}
+	app.EnvReferences = app.envReferences()
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env, "envreferences": app.EnvReferences}})
+	if err != nil {
+		return nil, err
+	}
+	defer updateEnvReferences(app.Name)
+	newRev, err := app.SaveEnvRevision(EnvRevisionOpts{
+		Kind:         EnvRevisionRestore,
+		User:         user,
+		Changed:      changed,
+		RestoredFrom: version,
+		Previous:     previous,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !shouldRestart {
+		return newRev, nil
+	}
+	units, err := app.GetUnits()
+	if err != nil || len(units) == 0 {
+		return newRev, err
+	}
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return newRev, err
+	}
+	return newRev, prov.Restart(writerContext(w), app, "", w)
+}
+
+func removeEnvRevisions(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.AppEnvRevisions().RemoveAll(bson.M{"app": appName})
+	return err
+}
+
+// envEqual compares variables referencing other apps by their references, as
+// their values change along with the referenced apps.
+func envEqual(a, b bind.EnvVar) bool {
+	if a.Public != b.Public || a.Reference != b.Reference {
+		return false
+	}
+	return a.Reference != "" || a.Value == b.Value
+}
+
+func envsEqual(a, b map[string]bind.EnvVar) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, env := range a {
+		other, ok := b[name]
+		if !ok || !envEqual(env, other) {
+			return false
+		}
+	}
+	return true
+}
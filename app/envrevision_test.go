@@ -0,0 +1,133 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newEnvRevisionApp(c *check.C, envs ...bind.EnvVar) *App {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name, Env: map[string]bind.EnvVar{}}
+	for _, env := range envs {
+		a.Env[env.Name] = env
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestSaveEnvRevision(c *check.C) {
+	a := s.newEnvRevisionApp(c,
+		bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		bind.EnvVar{Name: "TSURU_APP_TOKEN", Value: "secret"},
+		bind.EnvVar{Name: "MYSQL_PASSWORD", Value: "pass", InstanceName: "mydb"},
+	)
+	previous := a.UserEnvs()
+	c.Assert(previous, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+	})
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DATABASE_PORT", Value: "3306", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	rev, err := a.SaveEnvRevision(EnvRevisionOpts{Kind: EnvRevisionSet, User: "me@me.com", Changed: []string{"DATABASE_PORT"}, Previous: previous})
+	c.Assert(err, check.IsNil)
+	c.Assert(rev.Version, check.Equals, 2)
+	revisions, err := ListEnvRevisions(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(revisions, check.HasLen, 2)
+	c.Assert(revisions[0].Version, check.Equals, 2)
+	c.Assert(revisions[0].Kind, check.Equals, EnvRevisionSet)
+	c.Assert(revisions[0].User, check.Equals, "me@me.com")
+	c.Assert(revisions[0].Changed, check.DeepEquals, []string{"DATABASE_PORT"})
+	c.Assert(revisions[0].Envs, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		"DATABASE_PORT": {Name: "DATABASE_PORT", Value: "3306", Public: true},
+	})
+	c.Assert(revisions[1].Version, check.Equals, 1)
+	c.Assert(revisions[1].Kind, check.Equals, EnvRevisionInitial)
+	c.Assert(revisions[1].Envs, check.DeepEquals, previous)
+}
+
+func (s *S) TestSaveEnvRevisionWithoutChanges(c *check.C) {
+	a := s.newEnvRevisionApp(c, bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true})
+	rev, err := a.SaveEnvRevision(EnvRevisionOpts{Kind: EnvRevisionSet})
+	c.Assert(err, check.IsNil)
+	c.Assert(rev.Version, check.Equals, 1)
+	rev, err = a.SaveEnvRevision(EnvRevisionOpts{Kind: EnvRevisionSet})
+	c.Assert(err, check.IsNil)
+	c.Assert(rev.Version, check.Equals, 1)
+	revisions, err := ListEnvRevisions(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(revisions, check.HasLen, 1)
+}
+
+func (s *S) TestGetEnvRevisionNotFound(c *check.C) {
+	_, err := GetEnvRevision("myapp", 1)
+	c.Assert(err, check.Equals, ErrEnvRevisionNotFound)
+}
+
+func (s *S) TestDiffEnvs(c *check.C) {
+	from := map[string]bind.EnvVar{
+		"A": {Name: "A", Value: "1", Public: true},
+		"B": {Name: "B", Value: "2", Public: true},
+		"C": {Name: "C", Value: "3", Public: true},
+		"D": {Name: "D", Value: "old", Reference: `{{app "other".address}}`, Public: true},
+	}
+	to := map[string]bind.EnvVar{
+		"B": {Name: "B", Value: "20", Public: true},
+		"C": {Name: "C", Value: "3", Public: true},
+		"D": {Name: "D", Value: "new", Reference: `{{app "other".address}}`, Public: true},
+		"E": {Name: "E", Value: "5"},
+	}
+	a, b, e := from["A"], to["B"], to["E"]
+	oldB := from["B"]
+	c.Assert(DiffEnvs(from, to), check.DeepEquals, []EnvDiff{
+		{Name: "A", Change: EnvRemoved, From: &a},
+		{Name: "B", Change: EnvChanged, From: &oldB, To: &b},
+		{Name: "E", Change: EnvAdded, To: &e},
+	})
+	c.Assert(DiffEnvs(from, from), check.HasLen, 0)
+}
+
+func (s *S) TestRestoreEnvRevision(c *check.C) {
+	a := s.newEnvRevisionApp(c,
+		bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "secret"},
+		bind.EnvVar{Name: "MYSQL_PASSWORD", Value: "pass", InstanceName: "mydb"},
+	)
+	_, err := a.SaveEnvRevision(EnvRevisionOpts{Kind: EnvRevisionSet})
+	c.Assert(err, check.IsNil)
+	previous := a.UserEnvs()
+	err = a.UnsetEnvs(bind.UnsetEnvApp{VariableNames: []string{"DATABASE_HOST", "DATABASE_PASSWORD"}}, nil)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "NEW_VAR", Value: "x", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	_, err = a.SaveEnvRevision(EnvRevisionOpts{Kind: EnvRevisionUnset, Previous: previous})
+	c.Assert(err, check.IsNil)
+	buf := bytes.Buffer{}
+	rev, err := a.RestoreEnvRevision(1, "me@me.com", false, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "---- Restoring environment variables of revision 1 ----\n")
+	c.Assert(rev.Version, check.Equals, 3)
+	c.Assert(rev.Kind, check.Equals, EnvRevisionRestore)
+	c.Assert(rev.RestoredFrom, check.Equals, 1)
+	c.Assert(rev.User, check.Equals, "me@me.com")
+	c.Assert(rev.Changed, check.DeepEquals, []string{"DATABASE_HOST", "DATABASE_PASSWORD", "NEW_VAR"})
+	var dbApp App
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST":     {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "secret"},
+		"MYSQL_PASSWORD":    {Name: "MYSQL_PASSWORD", Value: "pass", InstanceName: "mydb"},
+	})
+	_, err = a.RestoreEnvRevision(1, "me@me.com", false, nil)
+	c.Assert(err, check.ErrorMatches, "environment variables already match revision 1")
+	_, err = a.RestoreEnvRevision(10, "me@me.com", false, nil)
+	c.Assert(err, check.Equals, ErrEnvRevisionNotFound)
+}
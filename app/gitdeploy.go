@@ -0,0 +1,136 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/exec"
+)
+
+var (
+	gitExecutor exec.Executor = exec.OsExecutor{}
+
+	// gitAllowedProtocols are the transports used to clone the repositories
+	// of git URL deploys. The file and ext transports are not allowed, they
+	// would give access to the files and commands of the tsuru server.
+	gitAllowedProtocols = []string{"http", "https", "git", "ssh"}
+
+	reGitScpURL = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^\s-][^\s]*$`)
+	reGitRef    = regexp.MustCompile(`^\w[\w./-]*$`)
+)
+
+// ValidateGitSource checks the repository URL and ref of a git URL deploy.
+// URLs may use any of the allowed transports or the scp-like syntax of ssh,
+// the ref is a commit, a branch or a tag and defaults to the HEAD of the
+// repository.
+func ValidateGitSource(gitURL, ref string) error {
+	if !reGitScpURL.MatchString(gitURL) {
+		u, err := url.Parse(gitURL)
+		if err != nil || u.Host == "" || !gitProtocolAllowed(u.Scheme) {
+			return errors.Errorf("invalid git url %q, supported protocols are: %s", gitURL, strings.Join(gitAllowedProtocols, ", "))
+		}
+	}
+	if ref != "" && (!reGitRef.MatchString(ref) || strings.Contains(ref, "..")) {
+		return errors.Errorf("invalid git ref %q", ref)
+	}
+	return nil
+}
+
+func gitProtocolAllowed(protocol string) bool {
+	for _, p := range gitAllowedProtocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// gitArchive clones the repository of a git URL deploy and returns a tarball
+// of the source code at the deployed ref, which is then deployed as an
+// uploaded file. The resolved commit is stored in opts.Commit.
+func gitArchive(opts *DeployOptions) (io.ReadCloser, int64, error) {
+	err := ValidateGitSource(opts.GitURL, opts.GitRef)
+	if err != nil {
+		return nil, 0, err
+	}
+	dir, err := ioutil.TempDir("", "tsuru-git-deploy")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+	repoDir := filepath.Join(dir, "repo.git")
+	opts.Event.Logf("---- Cloning %s ----", opts.GitURL)
+	_, err = runGit(dir, "clone", "--quiet", "--bare", "--", opts.GitURL, repoDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	ref := opts.GitRef
+	if ref == "" {
+		ref = "HEAD"
+	}
+	commit, err := runGit(repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		// Refs not fetched by the clone, e.g. pull request refs, are
+		// fetched explicitly.
+		_, err = runGit(repoDir, "fetch", "--quiet", "origin", "--", ref)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "unable to find git ref %q", opts.GitRef)
+		}
+		commit, err = runGit(repoDir, "rev-parse", "--verify", "--quiet", "FETCH_HEAD^{commit}")
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "unable to find git ref %q", opts.GitRef)
+		}
+	}
+	opts.Commit = commit
+	opts.Event.Logf("---- Deploying commit %s ----", commit)
+	archivePath := filepath.Join(dir, "archive.tar.gz")
+	_, err = runGit(repoDir, "archive", "--format=tar.gz", "-o", archivePath, commit)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The archive is removed along with the temporary directory, the open
+	// file is still readable until it's closed.
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := gitExecutor.Execute(exec.ExecuteOptions{
+		Cmd:  "git",
+		Args: args,
+		Envs: append(os.Environ(),
+			"GIT_ALLOW_PROTOCOL="+strings.Join(gitAllowedProtocols, ":"),
+			"GIT_TERMINAL_PROMPT=0",
+		),
+		Dir:    dir,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return "", errors.Wrapf(err, "git %s failed", args[0])
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
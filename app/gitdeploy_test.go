@@ -0,0 +1,156 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/exec"
+	"github.com/tsuru/tsuru/exec/exectest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+// fakeGitExecutor records git commands like a FakeExecutor, writing the
+// archive requested by git archive and failing the commands whose arguments
+// are in fail.
+type fakeGitExecutor struct {
+	exectest.FakeExecutor
+	fail map[string]bool
+}
+
+func (e *fakeGitExecutor) Execute(opts exec.ExecuteOptions) error {
+	e.FakeExecutor.Execute(opts)
+	if e.fail[strings.Join(opts.Args, " ")] {
+		opts.Stderr.Write([]byte("fatal: not found"))
+		return errors.New("exit status 128")
+	}
+	if opts.Args[0] == "archive" {
+		return ioutil.WriteFile(opts.Args[3], []byte("archive data"), 0600)
+	}
+	return nil
+}
+
+func (s *S) setGitExecutor(e exec.Executor) func() {
+	old := gitExecutor
+	gitExecutor = e
+	return func() { gitExecutor = old }
+}
+
+func (s *S) TestValidateGitSource(c *check.C) {
+	tests := []struct {
+		url, ref string
+		err      string
+	}{
+		{url: "https://github.com/tsuru/tsuru.git"},
+		{url: "https://github.com/tsuru/tsuru.git", ref: "8f3a1c2"},
+		{url: "git://git.example.com/repo.git", ref: "refs/tags/v1.0"},
+		{url: "ssh://git@git.example.com/repo.git", ref: "feature/login"},
+		{url: "git@github.com:tsuru/tsuru.git", ref: "master"},
+		{url: "file:///etc", err: `invalid git url "file:///etc", supported protocols are: http, https, git, ssh`},
+		{url: "ext::sh -c touch% /tmp/pwned", err: `invalid git url .*`},
+		{url: "/var/lib/repo", err: `invalid git url .*`},
+		{url: "--upload-pack=touch /tmp/pwned", err: `invalid git url .*`},
+		{url: "https://github.com/tsuru/tsuru.git", ref: "--output=/tmp/x", err: `invalid git ref "--output=/tmp/x"`},
+		{url: "https://github.com/tsuru/tsuru.git", ref: "master..dev", err: `invalid git ref "master..dev"`},
+		{url: "https://github.com/tsuru/tsuru.git", ref: "my ref", err: `invalid git ref "my ref"`},
+	}
+	for _, tt := range tests {
+		err := ValidateGitSource(tt.url, tt.ref)
+		if tt.err == "" {
+			c.Check(err, check.IsNil, check.Commentf("url %q ref %q", tt.url, tt.ref))
+		} else {
+			c.Check(err, check.ErrorMatches, tt.err, check.Commentf("url %q ref %q", tt.url, tt.ref))
+		}
+	}
+}
+
+func (s *S) TestDeployGitURL(c *check.C) {
+	executor := &fakeGitExecutor{FakeExecutor: exectest.FakeExecutor{
+		Output: map[string][][]byte{
+			"rev-parse --verify --quiet 8f3a1c2^{commit}": {[]byte("8f3a1c2d4e5f60718293a4b5c6d7e8f901234567\n")},
+		},
+	}}
+	defer s.setGitExecutor(executor)()
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	opts := DeployOptions{
+		App:          &a,
+		GitURL:       "https://github.com/tsuru/helloworld.git",
+		GitRef:       "8f3a1c2",
+		OutputStream: writer,
+		Event:        evt,
+	}
+	c.Assert(opts.GetKind(), check.Equals, DeployGitURL)
+	_, err = Deploy(opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Matches, "(?s).*Cloning https://github.com/tsuru/helloworld.git.*Deploying commit 8f3a1c2d4e5f60718293a4b5c6d7e8f901234567.*Upload deploy called")
+	cmds := executor.GetCommands("git")
+	c.Assert(cmds, check.HasLen, 3)
+	c.Assert(cmds[0].GetArgs()[:4], check.DeepEquals, []string{"clone", "--quiet", "--bare", "--"})
+	c.Assert(cmds[0].GetArgs()[4], check.Equals, "https://github.com/tsuru/helloworld.git")
+	c.Assert(cmds[0].GetEnvs(), check.Not(check.HasLen), 0)
+	c.Assert(cmds[0].GetEnvs()[len(cmds[0].GetEnvs())-2:], check.DeepEquals, []string{
+		"GIT_ALLOW_PROTOCOL=http:https:git:ssh",
+		"GIT_TERMINAL_PROMPT=0",
+	})
+	c.Assert(cmds[2].GetArgs()[0], check.Equals, "archive")
+	c.Assert(cmds[2].GetArgs()[4], check.Equals, "8f3a1c2d4e5f60718293a4b5c6d7e8f901234567")
+}
+
+func (s *S) TestDeployGitURLFetchesMissingRef(c *check.C) {
+	executor := &fakeGitExecutor{
+		FakeExecutor: exectest.FakeExecutor{
+			Output: map[string][][]byte{
+				"rev-parse --verify --quiet FETCH_HEAD^{commit}": {[]byte("abcdef\n")},
+			},
+		},
+		fail: map[string]bool{},
+	}
+	defer s.setGitExecutor(executor)()
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	opts := &DeployOptions{
+		App:          &a,
+		GitURL:       "https://github.com/tsuru/helloworld.git",
+		GitRef:       "refs/pull/1/head",
+		OutputStream: ioutil.Discard,
+		Event:        evt,
+	}
+	executor.fail["rev-parse --verify --quiet refs/pull/1/head^{commit}"] = true
+	executor.fail["fetch --quiet origin -- refs/pull/1/head"] = true
+	_, _, err = gitArchive(opts)
+	c.Assert(err, check.ErrorMatches, `unable to find git ref "refs/pull/1/head": git fetch failed: fatal: not found`)
+	executor.fail["fetch --quiet origin -- refs/pull/1/head"] = false
+	file, size, err := gitArchive(opts)
+	c.Assert(err, check.IsNil)
+	defer file.Close()
+	c.Assert(opts.Commit, check.Equals, "abcdef")
+	data, err := ioutil.ReadAll(file)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "archive data")
+	c.Assert(size, check.Equals, int64(len(data)))
+}
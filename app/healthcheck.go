@@ -0,0 +1,82 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetHealthcheck changes the healthcheck and the restart policy of the units
+// of the app, restarting them. A nil hc removes the configuration, units go
+// back to the healthcheck in the tsuru.yaml of the app and are always
+// restarted.
+func (app *App) SetHealthcheck(hc *provision.AppHealthcheck, w io.Writer) error {
+	update := bson.M{"$unset": bson.M{"healthcheck": ""}}
+	if hc != nil {
+		err := validateHealthcheck(hc)
+		if err != nil {
+			return err
+		}
+		update = bson.M{"$set": bson.M{"healthcheck": hc}}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.Healthcheck = hc
+	units, err := app.Units()
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return nil
+	}
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Restart(writerContext(w), app, "", w)
+}
+
+func validateHealthcheck(hc *provision.AppHealthcheck) error {
+	if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+		return &tsuruErrors.ValidationError{Message: "the healthcheck path must start with /"}
+	}
+	if hc.Interval < 0 || hc.Timeout < 0 || hc.FailureThreshold < 0 {
+		return &tsuruErrors.ValidationError{Message: "the healthcheck interval, timeout and failure threshold must not be negative"}
+	}
+	if hc.Path == "" && (hc.Interval > 0 || hc.Timeout > 0 || hc.FailureThreshold > 0) {
+		return &tsuruErrors.ValidationError{Message: "the healthcheck path is required to set the interval, timeout or failure threshold"}
+	}
+	if hc.RestartPolicy == "" {
+		return nil
+	}
+	for _, policy := range provision.RestartPolicies {
+		if hc.RestartPolicy == policy {
+			return nil
+		}
+	}
+	return &tsuruErrors.ValidationError{
+		Message: fmt.Sprintf("invalid restart policy %q, valid policies are: %s", hc.RestartPolicy, strings.Join(provision.RestartPolicies, ", ")),
+	}
+}
+
+// GetHealthcheck returns the healthcheck configured in the app, nil when the
+// app uses the healthcheck in its tsuru.yaml.
+func (app *App) GetHealthcheck() *provision.AppHealthcheck {
+	return app.Healthcheck
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetHealthcheck(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	hc := provision.AppHealthcheck{
+		Path:             "/status",
+		Interval:         10,
+		Timeout:          2,
+		FailureThreshold: 3,
+		RestartPolicy:    provision.RestartPolicyOnFailure,
+	}
+	err = a.SetHealthcheck(&hc, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	c.Assert(a.GetHealthcheck(), check.DeepEquals, &hc)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Healthcheck, check.DeepEquals, &hc)
+	c.Assert(provision.RestartPolicyForApp(dbApp), check.Equals, provision.RestartPolicyOnFailure)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 1)
+}
+
+func (s *S) TestSetHealthcheckWithoutUnits(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetHealthcheck(&provision.AppHealthcheck{RestartPolicy: provision.RestartPolicyNever}, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Healthcheck.RestartPolicy, check.Equals, provision.RestartPolicyNever)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
+}
+
+func (s *S) TestSetHealthcheckRemovesHealthcheck(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetHealthcheck(&provision.AppHealthcheck{Path: "/status"}, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	err = a.SetHealthcheck(nil, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Healthcheck, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Healthcheck, check.IsNil)
+	c.Assert(provision.RestartPolicyForApp(dbApp), check.Equals, provision.RestartPolicyAlways)
+}
+
+func (s *S) TestSetHealthcheckInvalid(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		hc  provision.AppHealthcheck
+		msg string
+	}{
+		{provision.AppHealthcheck{Path: "status"}, "the healthcheck path must start with /"},
+		{provision.AppHealthcheck{Path: "/status", Timeout: -1}, "the healthcheck interval, timeout and failure threshold must not be negative"},
+		{provision.AppHealthcheck{Interval: 10}, "the healthcheck path is required to set the interval, timeout or failure threshold"},
+		{provision.AppHealthcheck{RestartPolicy: "sometimes"}, `invalid restart policy "sometimes", valid policies are: always, on-failure, never`},
+	}
+	for _, tt := range tests {
+		err = a.SetHealthcheck(&tt.hc, new(bytes.Buffer))
+		c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{Message: tt.msg})
+	}
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Healthcheck, check.IsNil)
+}
@@ -52,7 +52,8 @@ var ErrNoImagesAvailable = errors.New("no images available for app")
 // the platform image will be returned if:
 // * there are no containers;
 // * the container have an empty image name;
-// * the deploy number is multiple of 10.
+// * the deploy number is multiple of 10;
+// * the build cache is disabled for the app.
 // in all other cases the app image name will be returne.
 func GetBuildImage(app provision.App) string {
 	if usePlatformImage(app) {
@@ -360,7 +361,7 @@ func usePlatformImage(app provision.App) bool {
 		maxLayers = 10
 	}
 	deploys := app.GetDeploys()
-	return deploys%maxLayers == 0 || app.GetUpdatePlatform()
+	return deploys%maxLayers == 0 || app.GetUpdatePlatform() || app.GetBuildCacheDisabled()
 }
 
 func appImagesColl() (*storage.Collection, error) {
@@ -0,0 +1,157 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobAlreadyExists = errors.New("a job with the same name already exists in the app")
+)
+
+// Job is a task of an app run periodically, following the cron-like Schedule
+// evaluated in UTC. Each run executes Command in a new and ephemeral unit of
+// the app and is recorded as an event.
+type Job struct {
+	Name      string
+	App       string
+	Schedule  string
+	Command   string
+	CreatedAt time.Time
+	CreatedBy string `json:",omitempty" bson:",omitempty"`
+}
+
+// CreateJob validates and stores a new job of an app. Scheduling its runs is
+// up to the caller.
+func CreateJob(job *Job) error {
+	job.Schedule = strings.TrimSpace(job.Schedule)
+	job.Command = strings.TrimSpace(job.Command)
+	err := job.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = GetByName(job.App)
+	if err != nil {
+		return err
+	}
+	job.CreatedAt = time.Now().UTC()
+	err = conn.Jobs().Insert(job)
+	if mgo.IsDup(err) {
+		return ErrJobAlreadyExists
+	}
+	return err
+}
+
+func (job *Job) validate() error {
+	if !nameRegexp.MatchString(job.Name) {
+		msg := "Invalid job name, the job name should have at most 63 " +
+			"characters, containing only lower case letters, numbers or dashes, " +
+			"starting with a letter."
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	if job.Command == "" {
+		return &tsuruErrors.ValidationError{Message: "the command of the job is required"}
+	}
+	_, err := event.NextScheduleTime(job.Schedule, time.Now())
+	if err != nil {
+		return &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	return nil
+}
+
+// GetJob returns the job of the app with the given name.
+func GetJob(appName, name string) (*Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var job Job
+	err = conn.Jobs().Find(bson.M{"app": appName, "name": name}).One(&job)
+	if err == mgo.ErrNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the jobs of the app sorted by name.
+func ListJobs(appName string) ([]Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var jobs []Job
+	err = conn.Jobs().Find(bson.M{"app": appName}).Sort("name").All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// RemoveJob removes the job of the app with the given name. Runs already
+// scheduled are skipped once they find the job is gone.
+func RemoveJob(appName, name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Remove(bson.M{"app": appName, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrJobNotFound
+	}
+	return err
+}
+
+func removeJobs(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Jobs().RemoveAll(bson.M{"app": appName})
+	return err
+}
+
+// NextRun returns the time of the first run of the job after t.
+func (job *Job) NextRun(t time.Time) (time.Time, error) {
+	return event.NextScheduleTime(job.Schedule, t)
+}
+
+// Run executes the command of the job in a new and ephemeral unit of its app,
+// writing the output to w and to the logs of the app.
+func (job *Job) Run(w io.Writer) error {
+	a, err := GetByName(job.App)
+	if err != nil {
+		return err
+	}
+	a.Log(fmt.Sprintf("running job %q: '%s'", job.Name, job.Command), "tsuru", "api")
+	logWriter := LogWriter{App: a, Source: "job"}
+	logWriter.Async()
+	defer logWriter.Close()
+	return a.sourced(job.Command, io.MultiWriter(w, &logWriter), provision.RunArgs{Isolated: true})
+}
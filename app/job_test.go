@@ -0,0 +1,116 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"time"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createJobApp(c *check.C) *App {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestCreateJob(c *check.C) {
+	a := s.createJobApp(c)
+	job := Job{Name: "cleanup", App: a.Name, Schedule: " 0 3 * * * ", Command: "./cleanup.sh", CreatedBy: s.user.Email}
+	err := CreateJob(&job)
+	c.Assert(err, check.IsNil)
+	dbJob, err := GetJob(a.Name, "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbJob.Schedule, check.Equals, "0 3 * * *")
+	c.Assert(dbJob.Command, check.Equals, "./cleanup.sh")
+	c.Assert(dbJob.CreatedBy, check.Equals, s.user.Email)
+	c.Assert(dbJob.CreatedAt.IsZero(), check.Equals, false)
+	err = CreateJob(&Job{Name: "cleanup", App: a.Name, Schedule: "* * * * *", Command: "ls"})
+	c.Assert(err, check.Equals, ErrJobAlreadyExists)
+}
+
+func (s *S) TestCreateJobInvalid(c *check.C) {
+	a := s.createJobApp(c)
+	tests := []struct {
+		job     Job
+		message string
+	}{
+		{Job{Name: "Invalid_Name", Schedule: "* * * * *", Command: "ls"}, "Invalid job name.*"},
+		{Job{Name: "cleanup", Schedule: "* * * * *", Command: " "}, "the command of the job is required"},
+		{Job{Name: "cleanup", Schedule: "* * *", Command: "ls"}, `invalid schedule "\* \* \*": expected 5 fields, got 3`},
+	}
+	for _, t := range tests {
+		t.job.App = a.Name
+		err := CreateJob(&t.job)
+		c.Check(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+		c.Check(err, check.ErrorMatches, t.message)
+	}
+	jobs, err := ListJobs(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+}
+
+func (s *S) TestCreateJobAppNotFound(c *check.C) {
+	err := CreateJob(&Job{Name: "cleanup", App: "unknown", Schedule: "* * * * *", Command: "ls"})
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestListAndRemoveJobs(c *check.C) {
+	a := s.createJobApp(c)
+	for _, name := range []string{"report", "cleanup"} {
+		err := CreateJob(&Job{Name: name, App: a.Name, Schedule: "0 * * * *", Command: "ls"})
+		c.Assert(err, check.IsNil)
+	}
+	jobs, err := ListJobs(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 2)
+	c.Assert(jobs[0].Name, check.Equals, "cleanup")
+	c.Assert(jobs[1].Name, check.Equals, "report")
+	err = RemoveJob(a.Name, "cleanup")
+	c.Assert(err, check.IsNil)
+	_, err = GetJob(a.Name, "cleanup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+	err = RemoveJob(a.Name, "cleanup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+	jobs, err = ListJobs(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+}
+
+func (s *S) TestDeleteRemovesJobs(c *check.C) {
+	a := s.createJobApp(c)
+	err := CreateJob(&Job{Name: "cleanup", App: a.Name, Schedule: "0 * * * *", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	err = Delete(a, nil)
+	c.Assert(err, check.IsNil)
+	jobs, err := ListJobs(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+}
+
+func (s *S) TestJobNextRun(c *check.C) {
+	job := Job{Schedule: "30 3 * * *"}
+	next, err := job.NextRun(time.Date(2017, 5, 10, 4, 0, 0, 0, time.UTC))
+	c.Assert(err, check.IsNil)
+	c.Assert(next, check.DeepEquals, time.Date(2017, 5, 11, 3, 30, 0, 0, time.UTC))
+}
+
+func (s *S) TestJobRun(c *check.C) {
+	s.provisioner.PrepareOutput([]byte("cleaned up"))
+	a := s.createJobApp(c)
+	job := Job{Name: "cleanup", App: a.Name, Schedule: "0 * * * *", Command: "./cleanup.sh"}
+	var buf bytes.Buffer
+	err := job.Run(&buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "cleaned up")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " ./cleanup.sh"
+	cmds := s.provisioner.GetCmds(expected, a)
+	c.Assert(cmds, check.HasLen, 1)
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/queue"
 )
@@ -42,7 +41,7 @@ var (
 
 	logsWritten = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "tsuru_logs_write_total",
-		Help: "The number of log entries written to mongo.",
+		Help: "The number of log entries written to the log storage.",
 	})
 )
 
@@ -218,7 +217,7 @@ func (d *appLogDispatcher) runFlusher() {
 	t := time.NewTimer(bulkMaxWaitTime)
 	pos := 0
 	sz := 200
-	bulkBuffer := make([]interface{}, sz)
+	bulkBuffer := make([]Applog, sz)
 	for {
 		var flush bool
 		select {
@@ -229,7 +228,7 @@ func (d *appLogDispatcher) runFlusher() {
 				flush = true
 				break
 			}
-			bulkBuffer[pos] = msg
+			bulkBuffer[pos] = *msg
 			pos++
 			flush = sz == pos
 		case <-t.C:
@@ -237,14 +236,13 @@ func (d *appLogDispatcher) runFlusher() {
 			t.Reset(bulkMaxWaitTime)
 		}
 		if flush {
-			conn, err := db.LogConn()
+			forwardLogs(d.appName, bulkBuffer[:pos])
+			logStorage, err := CurrentLogStorage()
 			if err != nil {
-				log.Errorf("[log flusher] unable to connect to mongodb: %s", err)
+				log.Errorf("[log flusher] unable to get log storage: %s", err)
 				continue
 			}
-			coll := conn.Logs(d.appName)
-			err = coll.Insert(bulkBuffer[:pos]...)
-			coll.Close()
+			err = logStorage.Insert(d.appName, bulkBuffer[:pos])
 			if err != nil {
 				log.Errorf("[log flusher] unable to insert logs: %s", err)
 				continue
@@ -0,0 +1,142 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrLogDrainNotFound      = errors.New("log drain not found")
+	ErrLogDrainAlreadyExists = errors.New("log drain already exists")
+
+	logDrainProtocols = []string{"syslog+tcp", "syslog+udp", "fluentd"}
+)
+
+// LogDrain is an external destination of the logs of an app, or of all apps
+// in a pool. The URL defines the protocol: syslog+tcp://host:port and
+// syslog+udp://host:port send RFC 5424 syslog messages, and
+// fluentd://host:port sends the logs using the Fluentd forward protocol.
+type LogDrain struct {
+	Name string
+	URL  string
+	App  string `json:",omitempty"`
+	Pool string `json:",omitempty"`
+}
+
+func (d *LogDrain) validate() error {
+	if d.Name == "" {
+		return &tsuruErrors.ValidationError{Message: "the log drain name is required"}
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || u.Hostname() == "" || u.Port() == "" {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid log drain url %q, it must be like protocol://host:port", d.URL)}
+	}
+	for _, protocol := range logDrainProtocols {
+		if u.Scheme == protocol {
+			return nil
+		}
+	}
+	return &tsuruErrors.ValidationError{
+		Message: fmt.Sprintf("invalid log drain protocol %q, valid protocols are: %s", u.Scheme, strings.Join(logDrainProtocols, ", ")),
+	}
+}
+
+// AddLogDrain adds a log drain to the app or to the pool set in the drain.
+// The logs of an app are sent to its drains and to the drains of its pool.
+func AddLogDrain(drain LogDrain) error {
+	err := drain.validate()
+	if err != nil {
+		return err
+	}
+	if drain.Pool != "" {
+		_, err = provision.GetPoolByName(drain.Pool)
+	} else {
+		_, err = GetByName(drain.App)
+	}
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.LogDrains().Insert(drain)
+	if mgo.IsDup(err) {
+		return ErrLogDrainAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	drainForwarder.invalidate()
+	return nil
+}
+
+// ListLogDrains returns the drains added to the app or to the pool.
+func ListLogDrains(appName, pool string) ([]LogDrain, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	drains := []LogDrain{}
+	err = conn.LogDrains().Find(bson.M{"app": appName, "pool": pool}).Sort("name").All(&drains)
+	if err != nil {
+		return nil, err
+	}
+	return drains, nil
+}
+
+// RemoveLogDrain removes the drain with the given name from the app or from
+// the pool.
+func RemoveLogDrain(appName, pool, name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.LogDrains().Remove(bson.M{"app": appName, "pool": pool, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrLogDrainNotFound
+	}
+	if err != nil {
+		return err
+	}
+	drainForwarder.invalidate()
+	return nil
+}
+
+// appLogDrains returns the drains of the app and of its pool.
+func appLogDrains(appName string) ([]LogDrain, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var a App
+	err = conn.Apps().Find(bson.M{"name": appName}).Select(bson.M{"pool": 1}).One(&a)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var drains []LogDrain
+	err = conn.LogDrains().Find(bson.M{"$or": []bson.M{
+		{"app": appName, "pool": ""},
+		{"app": "", "pool": a.Pool},
+	}}).All(&drains)
+	return drains, err
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bufio"
+	"net"
+	"time"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAddLogDrain(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = AddLogDrain(LogDrain{Name: "central", URL: "syslog+tcp://logs.example.com:514", App: a.Name})
+	c.Assert(err, check.IsNil)
+	err = AddLogDrain(LogDrain{Name: "central", URL: "fluentd://fluentd:24224", Pool: s.Pool})
+	c.Assert(err, check.IsNil)
+	drains, err := ListLogDrains(a.Name, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.DeepEquals, []LogDrain{
+		{Name: "central", URL: "syslog+tcp://logs.example.com:514", App: a.Name},
+	})
+	drains, err = ListLogDrains("", s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.DeepEquals, []LogDrain{
+		{Name: "central", URL: "fluentd://fluentd:24224", Pool: s.Pool},
+	})
+	drains, err = appLogDrains(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.HasLen, 2)
+}
+
+func (s *S) TestAddLogDrainAlreadyExists(c *check.C) {
+	drain := LogDrain{Name: "central", URL: "syslog+udp://logs.example.com:514", Pool: s.Pool}
+	err := AddLogDrain(drain)
+	c.Assert(err, check.IsNil)
+	err = AddLogDrain(drain)
+	c.Assert(err, check.Equals, ErrLogDrainAlreadyExists)
+}
+
+func (s *S) TestAddLogDrainNotFound(c *check.C) {
+	err := AddLogDrain(LogDrain{Name: "central", URL: "syslog+udp://logs.example.com:514", Pool: "unknown"})
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+	err = AddLogDrain(LogDrain{Name: "central", URL: "syslog+udp://logs.example.com:514", App: "unknown"})
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestAddLogDrainInvalid(c *check.C) {
+	tests := []struct {
+		drain LogDrain
+		msg   string
+	}{
+		{LogDrain{URL: "syslog+tcp://logs:514", Pool: s.Pool}, "the log drain name is required"},
+		{LogDrain{Name: "central", URL: "syslog+tcp://logs", Pool: s.Pool}, `invalid log drain url "syslog+tcp://logs", it must be like protocol://host:port`},
+		{LogDrain{Name: "central", URL: "http://logs:80", Pool: s.Pool}, `invalid log drain protocol "http", valid protocols are: syslog+tcp, syslog+udp, fluentd`},
+	}
+	for _, tt := range tests {
+		err := AddLogDrain(tt.drain)
+		c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{Message: tt.msg})
+	}
+}
+
+func (s *S) TestRemoveLogDrain(c *check.C) {
+	err := AddLogDrain(LogDrain{Name: "central", URL: "syslog+udp://logs.example.com:514", Pool: s.Pool})
+	c.Assert(err, check.IsNil)
+	err = RemoveLogDrain("", s.Pool, "central")
+	c.Assert(err, check.IsNil)
+	drains, err := ListLogDrains("", s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(drains, check.HasLen, 0)
+	err = RemoveLogDrain("", s.Pool, "central")
+	c.Assert(err, check.Equals, ErrLogDrainNotFound)
+}
+
+func (s *S) TestLogForwardsToDrains(c *check.C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer listener.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = AddLogDrain(LogDrain{Name: "central", URL: "syslog+tcp://" + listener.Addr().String(), Pool: s.Pool})
+	c.Assert(err, check.IsNil)
+	err = a.Log("hello\nworld", "web", "unit1")
+	c.Assert(err, check.IsNil)
+	for _, msg := range []string{"hello", "world"} {
+		select {
+		case line := <-lines:
+			c.Assert(line, check.Matches, `<14>1 \S+ unit1 myapp web - - `+msg)
+		case <-time.After(5 * time.Second):
+			c.Fatal("timeout waiting for forwarded logs")
+		}
+	}
+}
+
+func (s *S) TestSyslogMessage(c *check.C) {
+	l := Applog{Date: time.Date(2017, 10, 14, 10, 0, 0, 0, time.UTC), Message: "hello world", Source: "web", Unit: ""}
+	c.Assert(string(syslogMessage("myapp", l)), check.Equals, "<14>1 2017-10-14T10:00:00Z - myapp web - - hello world\n")
+}
+
+func (s *S) TestFluentdMessage(c *check.C) {
+	l := Applog{Date: time.Unix(1508000000, 0), Message: "hi", Source: "web", Unit: "u1"}
+	expected := []byte{
+		0x92,
+		0xab, 't', 's', 'u', 'r', 'u', '.', 'm', 'y', 'a', 'p', 'p',
+		0x91,
+		0x92,
+		0xce, 0x59, 0xe2, 0x41, 0x00,
+		0x84,
+		0xa3, 'a', 'p', 'p', 0xa5, 'm', 'y', 'a', 'p', 'p',
+		0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xa3, 'w', 'e', 'b',
+		0xa4, 'u', 'n', 'i', 't', 0xa2, 'u', '1',
+		0xa7, 'm', 'e', 's', 's', 'a', 'g', 'e', 0xa2, 'h', 'i',
+	}
+	c.Assert(fluentdMessage("myapp", []Applog{l}), check.DeepEquals, expected)
+}
+
+func (s *S) TestNoneLogStorage(c *check.C) {
+	storage := noneLogStorage{}
+	err := storage.Insert("myapp", []Applog{{Message: "hello"}})
+	c.Assert(err, check.IsNil)
+	_, err = storage.List("myapp", LogListArgs{Limit: 10})
+	c.Assert(err, check.Equals, ErrLogStorageDisabled)
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tsuru/tsuru/log"
+)
+
+const (
+	// syslogPriority is the facility user (1) with the severity
+	// informational (6).
+	syslogPriority = 14
+
+	drainTimeout = 5 * time.Second
+)
+
+var (
+	drainForwarder = newLogForwarder()
+
+	logDrainsCacheTTL = 30 * time.Second
+
+	logsForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsuru_logs_drain_forwarded_total",
+		Help: "The number of log entries forwarded to log drains.",
+	}, []string{"protocol"})
+
+	logsForwardErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsuru_logs_drain_errors_total",
+		Help: "The number of failures forwarding log entries to log drains.",
+	}, []string{"protocol"})
+)
+
+func init() {
+	prometheus.MustRegister(logsForwarded)
+	prometheus.MustRegister(logsForwardErrors)
+}
+
+// forwardLogs sends the log entries of the app to its log drains and the
+// drains of its pool. Failures are logged and don't affect the storage of
+// the logs.
+func forwardLogs(appName string, logs []Applog) {
+	drains, err := drainForwarder.drains(appName)
+	if err != nil {
+		log.Errorf("[log drain] unable to get log drains of app %q: %s", appName, err)
+		return
+	}
+	for _, d := range drains {
+		err = drainForwarder.send(d, appName, logs)
+		protocol := strings.SplitN(d.URL, ":", 2)[0]
+		if err != nil {
+			logsForwardErrors.WithLabelValues(protocol).Add(float64(len(logs)))
+			log.Errorf("[log drain] unable to forward logs of app %q to drain %q: %s", appName, d.Name, err)
+			continue
+		}
+		logsForwarded.WithLabelValues(protocol).Add(float64(len(logs)))
+	}
+}
+
+type cachedLogDrains struct {
+	drains  []LogDrain
+	expires time.Time
+}
+
+// logForwarder keeps the drains of each app for a while, avoiding database
+// queries for each log entry, and one connection for each drain URL.
+type logForwarder struct {
+	mu     sync.Mutex
+	cache  map[string]cachedLogDrains
+	connMu sync.Mutex
+	conns  map[string]*drainConn
+}
+
+type drainConn struct {
+	sync.Mutex
+	conn net.Conn
+}
+
+func newLogForwarder() *logForwarder {
+	return &logForwarder{
+		cache: make(map[string]cachedLogDrains),
+		conns: make(map[string]*drainConn),
+	}
+}
+
+func (f *logForwarder) invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cache = make(map[string]cachedLogDrains)
+}
+
+func (f *logForwarder) drains(appName string) ([]LogDrain, error) {
+	f.mu.Lock()
+	cached, ok := f.cache[appName]
+	f.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.drains, nil
+	}
+	drains, err := appLogDrains(appName)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.cache[appName] = cachedLogDrains{drains: drains, expires: time.Now().Add(logDrainsCacheTTL)}
+	f.mu.Unlock()
+	return drains, nil
+}
+
+func (f *logForwarder) send(drain LogDrain, appName string, logs []Applog) error {
+	u, err := url.Parse(drain.URL)
+	if err != nil {
+		return err
+	}
+	var messages [][]byte
+	network := "tcp"
+	switch u.Scheme {
+	case "syslog+udp":
+		network = "udp"
+		for _, l := range logs {
+			messages = append(messages, syslogMessage(appName, l))
+		}
+	case "syslog+tcp":
+		var buf bytes.Buffer
+		for _, l := range logs {
+			buf.Write(syslogMessage(appName, l))
+		}
+		messages = [][]byte{buf.Bytes()}
+	case "fluentd":
+		messages = [][]byte{fluentdMessage(appName, logs)}
+	default:
+		return fmt.Errorf("unknown log drain protocol %q", u.Scheme)
+	}
+	f.connMu.Lock()
+	c, ok := f.conns[drain.URL]
+	if !ok {
+		c = &drainConn{}
+		f.conns[drain.URL] = c
+	}
+	f.connMu.Unlock()
+	c.Lock()
+	defer c.Unlock()
+	if c.conn == nil {
+		c.conn, err = net.DialTimeout(network, u.Host, drainTimeout)
+		if err != nil {
+			c.conn = nil
+			return err
+		}
+	}
+	for _, msg := range messages {
+		c.conn.SetWriteDeadline(time.Now().Add(drainTimeout))
+		_, err = c.conn.Write(msg)
+		if err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// syslogMessage formats the log entry as a RFC 5424 message, using the unit
+// as the hostname and the source as the process id, terminated by a new line
+// as in the non-transparent framing of RFC 6587.
+func syslogMessage(appName string, l Applog) []byte {
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %s - - %s\n", syslogPriority,
+		l.Date.UTC().Format(time.RFC3339Nano), syslogField(l.Unit), syslogField(appName),
+		syslogField(l.Source), l.Message))
+}
+
+func syslogField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.Replace(value, " ", "_", -1)
+}
+
+// fluentdMessage encodes the log entries in the forward mode of the Fluentd
+// forward protocol, a msgpack array with the tag and the entries, tagged as
+// tsuru.<appname>.
+func fluentdMessage(appName string, logs []Applog) []byte {
+	var buf bytes.Buffer
+	msgpackArrayHeader(&buf, 2)
+	msgpackString(&buf, "tsuru."+appName)
+	msgpackArrayHeader(&buf, len(logs))
+	for _, l := range logs {
+		msgpackArrayHeader(&buf, 2)
+		msgpackUint(&buf, uint64(l.Date.Unix()))
+		record := [][2]string{
+			{"app", appName},
+			{"source", l.Source},
+			{"unit", l.Unit},
+			{"message", l.Message},
+		}
+		msgpackMapHeader(&buf, len(record))
+		for _, field := range record {
+			msgpackString(&buf, field[0])
+			msgpackString(&buf, field[1])
+		}
+	}
+	return buf.Bytes()
+}
+
+func msgpackArrayHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xdc)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdd)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func msgpackMapHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xde)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdf)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func msgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func msgpackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n < 128:
+		buf.WriteByte(byte(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultLogStorage = "mongodb"
+
+var (
+	logStorages = map[string]LogStorage{
+		defaultLogStorage: mongodbLogStorage{},
+		"none":            noneLogStorage{},
+	}
+
+	ErrLogStorageDisabled = errors.New("app logs are not stored by tsuru, they are only sent to the log drains of the app and its pool")
+)
+
+// LogStorage represents a storage of app logs, chosen by the "logs:storage"
+// setting. The default storage keeps the logs in capped MongoDB collections,
+// and the "none" storage discards them, leaving them only in the log drains.
+type LogStorage interface {
+	// Insert stores the log entries of the app.
+	Insert(appName string, logs []Applog) error
+
+	// List returns the last args.Limit log entries of the app matching the
+	// args, sorted from the oldest to the newest.
+	List(appName string, args LogListArgs) ([]Applog, error)
+
+	// Remove removes all log entries of the app.
+	Remove(appName string) error
+}
+
+// LogListArgs holds the filters for listing logs. Search is matched against
+// the messages, in a way that depends on the storage: MongoDB looks for the
+// text in the messages, ignoring case, while full-text storages run a query
+// requiring all the terms.
+type LogListArgs struct {
+	Limit  int
+	Source string
+	Unit   string
+	Search string
+}
+
+// RegisterLogStorage registers a new log storage, that can be later
+// configured and used.
+func RegisterLogStorage(name string, storage LogStorage) {
+	logStorages[name] = storage
+}
+
+// CurrentLogStorage returns the log storage configured in the "logs:storage"
+// setting.
+func CurrentLogStorage() (LogStorage, error) {
+	name, _ := config.GetString("logs:storage")
+	if name == "" {
+		name = defaultLogStorage
+	}
+	storage, ok := logStorages[name]
+	if !ok {
+		return nil, errors.Errorf("unknown log storage %q", name)
+	}
+	return storage, nil
+}
+
+type mongodbLogStorage struct{}
+
+func (mongodbLogStorage) Insert(appName string, logs []Applog) error {
+	conn, err := db.LogConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	docs := make([]interface{}, len(logs))
+	for i := range logs {
+		docs[i] = logs[i]
+	}
+	return conn.Logs(appName).Insert(docs...)
+}
+
+func (mongodbLogStorage) List(appName string, args LogListArgs) ([]Applog, error) {
+	conn, err := db.LogConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	logs := []Applog{}
+	q := bson.M{}
+	if args.Source != "" {
+		q["source"] = args.Source
+	}
+	if args.Unit != "" {
+		q["unit"] = args.Unit
+	}
+	if args.Search != "" {
+		q["message"] = bson.RegEx{Pattern: regexp.QuoteMeta(args.Search), Options: "i"}
+	}
+	err = conn.Logs(appName).Find(q).Sort("-$natural").Limit(args.Limit).All(&logs)
+	if err != nil {
+		return nil, err
+	}
+	l := len(logs)
+	for i := 0; i < l/2; i++ {
+		logs[i], logs[l-1-i] = logs[l-1-i], logs[i]
+	}
+	return logs, nil
+}
+
+func (mongodbLogStorage) Remove(appName string) error {
+	conn, err := db.LogConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Logs(appName).DropCollection()
+}
+
+type noneLogStorage struct{}
+
+func (noneLogStorage) Insert(appName string, logs []Applog) error {
+	return nil
+}
+
+func (noneLogStorage) List(appName string, args LogListArgs) ([]Applog, error) {
+	return nil, ErrLogStorageDisabled
+}
+
+func (noneLogStorage) Remove(appName string) error {
+	return nil
+}
@@ -0,0 +1,371 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package elasticsearch provides an implementation of the app.LogStorage,
+// that stores app logs in Elasticsearch or OpenSearch, using the REST API of
+// Elasticsearch 7. This package doesn't expose any public types, in order to
+// use it, users need to import the package and then configure tsuru to use the
+// "elasticsearch" log storage.
+//
+//     import _ "github.com/tsuru/tsuru/app/logstorage/elasticsearch"
+//
+// Logs are stored in one index per day, named after the configured prefix and
+// the day, like tsuru-logs-2017.10.14. Indices older than the retention are
+// removed while logs are inserted.
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/net"
+)
+
+const (
+	defaultIndexPrefix   = "tsuru-logs"
+	defaultRetentionDays = 30
+	indexDateLayout      = "2006.01.02"
+	maintenanceInterval  = time.Hour
+)
+
+var (
+	maintenanceMu   sync.Mutex
+	lastMaintenance = map[string]time.Time{}
+
+	now = time.Now
+)
+
+func init() {
+	app.RegisterLogStorage("elasticsearch", esStorage{})
+	hc.AddChecker("Elasticsearch", healthCheck)
+}
+
+func healthCheck() error {
+	storageName, _ := config.GetString("logs:storage")
+	if storageName != "elasticsearch" {
+		return hc.ErrDisabledComponent
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	resp, err := c.do("GET", "/_cluster/health", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+type client struct {
+	url           string
+	username      string
+	password      string
+	indexPrefix   string
+	retentionDays int
+	http          *http.Client
+}
+
+func newClient() (*client, error) {
+	url, err := config.GetString("logs:elasticsearch:url")
+	if err != nil {
+		return nil, err
+	}
+	username, _ := config.GetString("logs:elasticsearch:username")
+	password, _ := config.GetString("logs:elasticsearch:password")
+	indexPrefix, _ := config.GetString("logs:elasticsearch:index-prefix")
+	if indexPrefix == "" {
+		indexPrefix = defaultIndexPrefix
+	}
+	retentionDays, err := config.GetInt("logs:elasticsearch:retention-days")
+	if err != nil {
+		retentionDays = defaultRetentionDays
+	}
+	return &client{
+		url:           strings.TrimRight(url, "/"),
+		username:      username,
+		password:      password,
+		indexPrefix:   indexPrefix,
+		retentionDays: retentionDays,
+		http:          net.Dial5Full300Client,
+	}, nil
+}
+
+// do sends a request to the API, body may be a []byte, sent as newline
+// delimited JSON, or any value to be encoded as JSON.
+func (c *client) do(method, path string, body interface{}) (*http.Response, error) {
+	var reqBody bytes.Buffer
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reqBody.Write(b)
+		contentType = "application/x-ndjson"
+	default:
+		err := json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, c.url+path, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.http.Do(req)
+}
+
+func (c *client) index(t time.Time) string {
+	return c.indexPrefix + "-" + t.UTC().Format(indexDateLayout)
+}
+
+func (c *client) indexPattern() string {
+	return c.indexPrefix + "-*"
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("elasticsearch returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// maintain creates the index template of the log indices and removes the
+// indices older than the retention, at most once per maintenance interval.
+func (c *client) maintain() {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	key := c.url + "/" + c.indexPrefix
+	if now().Sub(lastMaintenance[key]) < maintenanceInterval {
+		return
+	}
+	lastMaintenance[key] = now()
+	err := c.putTemplate()
+	if err != nil {
+		log.Errorf("[elasticsearch] unable to create index template: %s", err)
+	}
+	err = c.removeExpiredIndices()
+	if err != nil {
+		log.Errorf("[elasticsearch] unable to remove expired indices: %s", err)
+	}
+}
+
+func (c *client) putTemplate() error {
+	keyword := map[string]string{"type": "keyword"}
+	template := map[string]interface{}{
+		"index_patterns": []string{c.indexPattern()},
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"date":    map[string]string{"type": "date"},
+				"message": map[string]string{"type": "text"},
+				"source":  keyword,
+				"app":     keyword,
+				"unit":    keyword,
+			},
+		},
+	}
+	resp, err := c.do("PUT", "/_template/"+c.indexPrefix, template)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (c *client) removeExpiredIndices() error {
+	if c.retentionDays <= 0 {
+		return nil
+	}
+	resp, err := c.do("GET", "/_cat/indices/"+c.indexPattern()+"?format=json&h=index", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp)
+	if err != nil {
+		return err
+	}
+	var indices []struct {
+		Index string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&indices)
+	if err != nil {
+		return err
+	}
+	oldest := c.index(now().AddDate(0, 0, -c.retentionDays+1))
+	for _, idx := range indices {
+		// Index names sort as their dates, unrelated indices matching the
+		// pattern are kept.
+		day := strings.TrimPrefix(idx.Index, c.indexPrefix+"-")
+		if _, err := time.Parse(indexDateLayout, day); err != nil || idx.Index >= oldest {
+			continue
+		}
+		resp, err := c.do("DELETE", "/"+idx.Index, nil)
+		if err != nil {
+			return err
+		}
+		err = checkResponse(resp)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type logEntry struct {
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Source  string    `json:"source"`
+	App     string    `json:"app"`
+	Unit    string    `json:"unit"`
+}
+
+type esStorage struct{}
+
+func (esStorage) Insert(appName string, logs []app.Applog) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	c.maintain()
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, l := range logs {
+		action := map[string]interface{}{"index": map[string]string{"_index": c.index(l.Date)}}
+		err = encoder.Encode(action)
+		if err != nil {
+			return err
+		}
+		err = encoder.Encode(logEntry{Date: l.Date, Message: l.Message, Source: l.Source, App: appName, Unit: l.Unit})
+		if err != nil {
+			return err
+		}
+	}
+	resp, err := c.do("POST", "/_bulk", body.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Errors bool
+		Items  []map[string]struct {
+			Error json.RawMessage
+		}
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, r := range item {
+				if len(r.Error) > 0 {
+					return errors.Errorf("unable to index logs: %s", r.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (esStorage) List(appName string, args app.LogListArgs) ([]app.Applog, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	filters := []interface{}{
+		map[string]interface{}{"term": map[string]string{"app": appName}},
+	}
+	if args.Source != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"source": args.Source}})
+	}
+	if args.Unit != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"unit": args.Unit}})
+	}
+	query := map[string]interface{}{"filter": filters}
+	if args.Search != "" {
+		query["must"] = map[string]interface{}{
+			"match": map[string]interface{}{
+				"message": map[string]string{"query": args.Search, "operator": "and"},
+			},
+		}
+	}
+	search := map[string]interface{}{
+		"size":  args.Limit,
+		"sort":  []interface{}{map[string]string{"date": "desc"}},
+		"query": map[string]interface{}{"bool": query},
+	}
+	path := fmt.Sprintf("/%s/_search?ignore_unavailable=true", c.indexPattern())
+	resp, err := c.do("POST", path, search)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source logEntry `json:"_source"`
+			}
+		}
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	hits := result.Hits.Hits
+	logs := make([]app.Applog, len(hits))
+	for i, h := range hits {
+		logs[len(hits)-1-i] = app.Applog{
+			Date:    h.Source.Date,
+			Message: h.Source.Message,
+			Source:  h.Source.Source,
+			AppName: h.Source.App,
+			Unit:    h.Source.Unit,
+		}
+	}
+	return logs, nil
+}
+
+func (esStorage) Remove(appName string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"term": map[string]string{"app": appName}},
+	}
+	path := fmt.Sprintf("/%s/_delete_by_query?ignore_unavailable=true", c.indexPattern())
+	resp, err := c.do("POST", path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/hc"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	server    *httptest.Server
+	indices   map[string][]logEntry
+	templates map[string]json.RawMessage
+	mu        sync.Mutex
+}
+
+var _ = check.Suite(&S{})
+
+// ServeHTTP implements the parts of the Elasticsearch API used by the
+// storage, matching searches as all the terms being in the message.
+func (s *S) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, _ := r.BasicAuth(); user != "tsuru" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.URL.Path == "/_cluster/health":
+		w.Write([]byte(`{"status":"green"}`))
+	case strings.HasPrefix(r.URL.Path, "/_template/"):
+		var data json.RawMessage
+		json.NewDecoder(r.Body).Decode(&data)
+		s.templates[strings.TrimPrefix(r.URL.Path, "/_template/")] = data
+	case r.URL.Path == "/_bulk":
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+				}
+			}
+			json.Unmarshal(scanner.Bytes(), &action)
+			scanner.Scan()
+			var entry logEntry
+			json.Unmarshal(scanner.Bytes(), &entry)
+			s.indices[action.Index.Index] = append(s.indices[action.Index.Index], entry)
+		}
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	case strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+		var indices []map[string]string
+		for name := range s.indices {
+			indices = append(indices, map[string]string{"index": name})
+		}
+		json.NewEncoder(w).Encode(indices)
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		s.search(w, r)
+	case strings.HasSuffix(r.URL.Path, "/_delete_by_query"):
+		var query struct {
+			Query struct {
+				Term struct {
+					App string
+				}
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&query)
+		for name, entries := range s.indices {
+			var kept []logEntry
+			for _, e := range entries {
+				if e.App != query.Query.Term.App {
+					kept = append(kept, e)
+				}
+			}
+			s.indices[name] = kept
+		}
+		w.Write([]byte(`{}`))
+	case r.Method == "DELETE":
+		delete(s.indices, strings.TrimPrefix(r.URL.Path, "/"))
+		w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *S) search(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		Size  int
+		Query struct {
+			Bool struct {
+				Filter []struct {
+					Term map[string]string
+				}
+				Must struct {
+					Match struct {
+						Message struct {
+							Query string
+						}
+					}
+				}
+			}
+		}
+	}
+	json.NewDecoder(r.Body).Decode(&query)
+	var hits []map[string]logEntry
+	for _, entries := range s.indices {
+	entries:
+		for _, e := range entries {
+			values := map[string]string{"app": e.App, "source": e.Source, "unit": e.Unit}
+			for _, f := range query.Query.Bool.Filter {
+				for k, v := range f.Term {
+					if values[k] != v {
+						continue entries
+					}
+				}
+			}
+			for _, term := range strings.Fields(query.Query.Bool.Must.Match.Message.Query) {
+				if !strings.Contains(strings.ToLower(e.Message), strings.ToLower(term)) {
+					continue entries
+				}
+			}
+			hits = append(hits, map[string]logEntry{"_source": e})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i]["_source"].Date.After(hits[j]["_source"].Date)
+	})
+	if len(hits) > query.Size {
+		hits = hits[:query.Size]
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.indices = make(map[string][]logEntry)
+	s.templates = make(map[string]json.RawMessage)
+	s.server = httptest.NewServer(s)
+	lastMaintenance = map[string]time.Time{}
+	now = func() time.Time {
+		return time.Date(2017, 10, 14, 12, 0, 0, 0, time.UTC)
+	}
+	config.Set("logs:storage", "elasticsearch")
+	config.Set("logs:elasticsearch:url", s.server.URL)
+	config.Set("logs:elasticsearch:username", "tsuru")
+	config.Set("logs:elasticsearch:password", "secret")
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+	now = time.Now
+	config.Unset("logs")
+}
+
+func (s *S) TestCurrentLogStorage(c *check.C) {
+	storage, err := app.CurrentLogStorage()
+	c.Assert(err, check.IsNil)
+	c.Assert(storage, check.Equals, app.LogStorage(esStorage{}))
+}
+
+func (s *S) TestInsertAndList(c *check.C) {
+	date := time.Date(2017, 10, 14, 10, 0, 0, 0, time.UTC)
+	logs := []app.Applog{
+		{Date: date, Message: "GET /status 200", Source: "web", AppName: "myapp", Unit: "u1"},
+		{Date: date.Add(time.Second), Message: "connection refused", Source: "worker", AppName: "myapp", Unit: "u2"},
+		{Date: date.Add(2 * time.Second), Message: "GET /users 500", Source: "web", AppName: "myapp", Unit: "u1"},
+	}
+	storage := esStorage{}
+	err := storage.Insert("myapp", logs)
+	c.Assert(err, check.IsNil)
+	err = storage.Insert("otherapp", []app.Applog{{Date: date, Message: "GET /status 200", Source: "web", AppName: "otherapp"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.indices["tsuru-logs-2017.10.14"], check.HasLen, 4)
+	result, err := storage.List("myapp", app.LogListArgs{Limit: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, logs)
+	result, err = storage.List("myapp", app.LogListArgs{Limit: 2})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, logs[1:])
+	result, err = storage.List("myapp", app.LogListArgs{Limit: 10, Source: "web", Search: "get"})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []app.Applog{logs[0], logs[2]})
+	result, err = storage.List("myapp", app.LogListArgs{Limit: 10, Unit: "u1", Search: "users 500"})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, logs[2:])
+}
+
+func (s *S) TestInsertCreatesTemplateAndRemovesExpiredIndices(c *check.C) {
+	config.Set("logs:elasticsearch:retention-days", 2)
+	s.indices["tsuru-logs-2017.10.12"] = nil
+	s.indices["tsuru-logs-2017.10.13"] = nil
+	s.indices["tsuru-logs-custom"] = nil
+	err := esStorage{}.Insert("myapp", []app.Applog{{Date: now(), Message: "hello", AppName: "myapp"}})
+	c.Assert(err, check.IsNil)
+	var indices []string
+	for name := range s.indices {
+		indices = append(indices, name)
+	}
+	sort.Strings(indices)
+	c.Assert(indices, check.DeepEquals, []string{"tsuru-logs-2017.10.13", "tsuru-logs-2017.10.14", "tsuru-logs-custom"})
+	var template struct {
+		IndexPatterns []string `json:"index_patterns"`
+	}
+	err = json.Unmarshal(s.templates["tsuru-logs"], &template)
+	c.Assert(err, check.IsNil)
+	c.Assert(template.IndexPatterns, check.DeepEquals, []string{"tsuru-logs-*"})
+	s.indices["tsuru-logs-2017.10.01"] = nil
+	err = esStorage{}.Insert("myapp", []app.Applog{{Date: now(), Message: "hello", AppName: "myapp"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.indices, check.HasLen, 4)
+}
+
+func (s *S) TestInsertBulkErrors(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`))
+		}
+	}))
+	defer server.Close()
+	config.Set("logs:elasticsearch:url", server.URL)
+	err := esStorage{}.Insert("myapp", []app.Applog{{Date: now(), Message: "hello"}, {Date: now(), Message: "world"}})
+	c.Assert(err, check.ErrorMatches, `unable to index logs: {"type":"mapper_parsing_exception"}`)
+}
+
+func (s *S) TestRemove(c *check.C) {
+	storage := esStorage{}
+	err := storage.Insert("myapp", []app.Applog{{Date: now(), Message: "hello"}})
+	c.Assert(err, check.IsNil)
+	err = storage.Insert("otherapp", []app.Applog{{Date: now(), Message: "hello"}})
+	c.Assert(err, check.IsNil)
+	err = storage.Remove("myapp")
+	c.Assert(err, check.IsNil)
+	result, err := storage.List("myapp", app.LogListArgs{Limit: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 0)
+	result, err = storage.List("otherapp", app.LogListArgs{Limit: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+}
+
+func (s *S) TestListErrorStatus(c *check.C) {
+	config.Set("logs:elasticsearch:password", "wrong")
+	_, err := esStorage{}.List("myapp", app.LogListArgs{Limit: 10})
+	c.Assert(err, check.ErrorMatches, "elasticsearch returned status 401: ")
+}
+
+func (s *S) TestHealthCheck(c *check.C) {
+	c.Assert(healthCheck(), check.IsNil)
+	config.Set("logs:storage", "mongodb")
+	c.Assert(healthCheck(), check.Equals, hc.ErrDisabledComponent)
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type fakeLogStorage struct {
+	logs map[string][]Applog
+}
+
+func (s *fakeLogStorage) Insert(appName string, logs []Applog) error {
+	s.logs[appName] = append(s.logs[appName], logs...)
+	return nil
+}
+
+func (s *fakeLogStorage) List(appName string, args LogListArgs) ([]Applog, error) {
+	return s.logs[appName], nil
+}
+
+func (s *fakeLogStorage) Remove(appName string) error {
+	delete(s.logs, appName)
+	return nil
+}
+
+func (s *S) TestCurrentLogStorage(c *check.C) {
+	storage, err := CurrentLogStorage()
+	c.Assert(err, check.IsNil)
+	c.Assert(storage, check.Equals, LogStorage(mongodbLogStorage{}))
+	config.Set("logs:storage", "cassandra")
+	defer config.Unset("logs:storage")
+	_, err = CurrentLogStorage()
+	c.Assert(err, check.ErrorMatches, `unknown log storage "cassandra"`)
+}
+
+func (s *S) TestRegisteredLogStorage(c *check.C) {
+	storage := &fakeLogStorage{logs: map[string][]Applog{}}
+	RegisterLogStorage("fake", storage)
+	defer delete(logStorages, "fake")
+	config.Set("logs:storage", "fake")
+	defer config.Unset("logs:storage")
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.Log("hello\nworld", "tsuru", "u1")
+	c.Assert(err, check.IsNil)
+	logs, err := a.LastLogs(10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[1].Message, check.Equals, "world")
+	err = Delete(&a, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(storage.logs, check.HasLen, 0)
+}
+
+func (s *S) TestSearchLogs(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	a.Log("GET /status 200", "web", "u1")
+	a.Log("connection refused [db]", "worker", "u1")
+	a.Log("GET /users 500", "web", "u2")
+	logs, err := a.SearchLogs(10, Applog{}, "get")
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[0].Message, check.Equals, "GET /status 200")
+	c.Assert(logs[1].Message, check.Equals, "GET /users 500")
+	logs, err = a.SearchLogs(10, Applog{Unit: "u1"}, "[DB]")
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Message, check.Equals, "connection refused [db]")
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultMaintenancePage = `<!DOCTYPE html>
+<html>
+<head><title>Under maintenance</title></head>
+<body>
+<h1>Under maintenance</h1>
+<p>This application is under maintenance, please try again later.</p>
+</body>
+</html>
+`
+
+var (
+	ErrAppInMaintenance    = errors.New("app is already in maintenance")
+	ErrAppNotInMaintenance = errors.New("app is not in maintenance")
+)
+
+// Maintenance is the maintenance mode of an app, in which its router answers
+// all requests with a static page while the units of the app keep running.
+type Maintenance struct {
+	// EventID is the unique ID of the event recording the maintenance, which
+	// runs until the maintenance ends. Canceling it ends the maintenance.
+	EventID bson.ObjectId
+	// BlockID is the ID of the event block preventing deploys of the app
+	// while it's in maintenance.
+	BlockID   bson.ObjectId
+	StartTime time.Time
+	Owner     string
+}
+
+// StartMaintenance puts the app in maintenance, recorded by evt, making its
+// router serve the maintenance page and blocking deploys of the app until
+// the maintenance ends.
+func (app *App) StartMaintenance(evt *event.Event) error {
+	if app.Maintenance != nil {
+		return ErrAppInMaintenance
+	}
+	mRouter, err := app.maintenanceRouter()
+	if err != nil {
+		return err
+	}
+	page, err := maintenancePage()
+	if err != nil {
+		return err
+	}
+	block := event.Block{
+		KindName: permission.PermAppDeploy.FullName(),
+		Target:   event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		Reason:   "app in maintenance",
+	}
+	err = event.AddBlock(&block)
+	if err != nil {
+		return err
+	}
+	m := Maintenance{
+		EventID:   evt.UniqueID,
+		BlockID:   block.ID,
+		StartTime: time.Now().UTC(),
+		Owner:     evt.Owner.Name,
+	}
+	err = mRouter.SetMaintenancePage(app.GetName(), page)
+	if err == nil {
+		err = app.saveMaintenance(&m)
+		if err != nil {
+			mRouter.RemoveMaintenancePage(app.GetName())
+		}
+	}
+	if err != nil {
+		if blockErr := event.RemoveBlock(block.ID); blockErr != nil {
+			log.Errorf("[maintenance] unable to remove block of app %q: %s", app.Name, blockErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// EndMaintenance takes the app out of maintenance, restoring the routing of
+// requests to its units, removing the block of deploys and finishing the
+// event recording the maintenance.
+func (app *App) EndMaintenance() error {
+	if app.Maintenance == nil {
+		return ErrAppNotInMaintenance
+	}
+	mRouter, err := app.maintenanceRouter()
+	if err != nil {
+		return err
+	}
+	err = mRouter.RemoveMaintenancePage(app.GetName())
+	if err != nil {
+		return err
+	}
+	m := app.Maintenance
+	err = app.saveMaintenance(nil)
+	if err != nil {
+		return err
+	}
+	return finishMaintenance(m)
+}
+
+// finishMaintenance removes the block of deploys of a maintenance and
+// finishes its event, ignoring blocks and events ended by users.
+func finishMaintenance(m *Maintenance) error {
+	err := event.RemoveBlock(m.BlockID)
+	if _, ok := err.(*event.ErrActiveEventBlockNotFound); ok {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	evt, err := event.GetByID(m.EventID)
+	if err == event.ErrEventNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !evt.Running {
+		return nil
+	}
+	return evt.Done(nil)
+}
+
+func (app *App) saveMaintenance(m *Maintenance) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	update := bson.M{"$unset": bson.M{"maintenance": ""}}
+	if m != nil {
+		update = bson.M{"$set": bson.M{"maintenance": m}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.Maintenance = m
+	return nil
+}
+
+func (app *App) maintenanceRouter() (router.MaintenanceRouter, error) {
+	r, err := app.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	mRouter, ok := r.(router.MaintenanceRouter)
+	if !ok {
+		return nil, router.ErrMaintenanceNotSupported
+	}
+	return mRouter, nil
+}
+
+// maintenancePage returns the page served by the routers of apps in
+// maintenance, read from the file in the maintenance:page-file setting or,
+// when it's not set, a generic page.
+func maintenancePage() (string, error) {
+	path, _ := config.GetString("maintenance:page-file")
+	if path == "" {
+		return defaultMaintenancePage, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read the maintenance page")
+	}
+	return string(data), nil
+}
+
+// ListAppsInMaintenance returns the apps currently in maintenance.
+func ListAppsInMaintenance() ([]App, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"maintenance": bson.M{"$exists": true}}).Sort("name").All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) newMaintenanceEvent(c *check.C, appName string) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypeApp, Value: appName},
+		Kind:        permission.PermAppUpdateMaintenance,
+		RawOwner:    event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:     event.Allowed(permission.PermAppReadEvents),
+		Cancelable:  true,
+		DisableLock: true,
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestStartMaintenance(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt := s.newMaintenanceEvent(c, a.Name)
+	err = a.StartMaintenance(evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Maintenance, check.NotNil)
+	c.Assert(a.Maintenance.EventID, check.Equals, evt.UniqueID)
+	c.Assert(a.Maintenance.Owner, check.Equals, s.user.Email)
+	c.Assert(routertest.MaintenanceRouter.Pages[a.Name], check.Equals, defaultMaintenancePage)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.NotNil)
+	c.Assert(dbApp.Maintenance.BlockID, check.Equals, a.Maintenance.BlockID)
+	_, err = event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.FitsTypeOf, &event.ErrEventBlocked{})
+	err = a.StartMaintenance(s.newMaintenanceEvent(c, a.Name))
+	c.Assert(err, check.Equals, ErrAppInMaintenance)
+}
+
+func (s *S) TestStartMaintenanceCustomPage(c *check.C) {
+	f, err := ioutil.TempFile("", "maintenance")
+	c.Assert(err, check.IsNil)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("<h1>Back soon</h1>")
+	c.Assert(err, check.IsNil)
+	f.Close()
+	config.Set("maintenance:page-file", f.Name())
+	defer config.Unset("maintenance:page-file")
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.StartMaintenance(s.newMaintenanceEvent(c, a.Name))
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.MaintenanceRouter.Pages[a.Name], check.Equals, "<h1>Back soon</h1>")
+}
+
+func (s *S) TestStartMaintenanceNotSupportedByRouter(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.StartMaintenance(s.newMaintenanceEvent(c, a.Name))
+	c.Assert(err, check.Equals, router.ErrMaintenanceNotSupported)
+	c.Assert(a.Maintenance, check.IsNil)
+	active := true
+	blocks, err := event.ListBlocks(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(blocks, check.HasLen, 0)
+}
+
+func (s *S) TestEndMaintenance(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt := s.newMaintenanceEvent(c, a.Name)
+	err = a.StartMaintenance(evt)
+	c.Assert(err, check.IsNil)
+	err = a.EndMaintenance()
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Maintenance, check.IsNil)
+	c.Assert(routertest.MaintenanceRouter.Pages, check.HasLen, 0)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.IsNil)
+	active := true
+	blocks, err := event.ListBlocks(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(blocks, check.HasLen, 0)
+	dbEvt, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.Running, check.Equals, false)
+	c.Assert(dbEvt.Error, check.Equals, "")
+	err = a.EndMaintenance()
+	c.Assert(err, check.Equals, ErrAppNotInMaintenance)
+}
+
+func (s *S) TestUpdateRouterValidatesMaintenance(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.StartMaintenance(s.newMaintenanceEvent(c, a.Name))
+	c.Assert(err, check.IsNil)
+	err = a.Update(App{Router: "fake"}, nil)
+	c.Assert(err, check.Equals, router.ErrMaintenanceNotSupported)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Router, check.Equals, "fake-maintenance")
+}
+
+func (s *S) TestListAppsInMaintenance(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	other := App{Name: "other-app", TeamOwner: s.team.Name, Router: "fake-maintenance"}
+	err = CreateApp(&other, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.StartMaintenance(s.newMaintenanceEvent(c, a.Name))
+	c.Assert(err, check.IsNil)
+	apps, err := ListAppsInMaintenance()
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+	c.Assert(apps[0].Name, check.Equals, a.Name)
+}
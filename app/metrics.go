@@ -0,0 +1,83 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const (
+	defaultMetricsSource = "provisioner"
+
+	maxMetricsPoints = 1000
+)
+
+var metricsSources = map[string]MetricsSource{
+	defaultMetricsSource: provisionerMetricsSource{},
+}
+
+// MetricsSource represents a source of the resource usage of the units of
+// apps, chosen by the "metrics:source" setting. The default source asks the
+// provisioner of the app, other sources query external monitoring systems.
+type MetricsSource interface {
+	UnitsMetrics(app *App, opts provision.MetricsOpts) ([]provision.UnitMetrics, error)
+}
+
+// RegisterMetricsSource registers a new metrics source, that can be later
+// configured and used.
+func RegisterMetricsSource(name string, source MetricsSource) {
+	metricsSources[name] = source
+}
+
+// CurrentMetricsSource returns the metrics source configured in the
+// "metrics:source" setting.
+func CurrentMetricsSource() (MetricsSource, error) {
+	name, _ := config.GetString("metrics:source")
+	if name == "" {
+		name = defaultMetricsSource
+	}
+	source, ok := metricsSources[name]
+	if !ok {
+		return nil, errors.Errorf("unknown metrics source %q", name)
+	}
+	return source, nil
+}
+
+// Metrics returns the CPU, memory and network usage of each unit of the app
+// in the window, with one point every step.
+func (app *App) Metrics(window, step time.Duration) ([]provision.UnitMetrics, error) {
+	if window <= 0 || step <= 0 {
+		return nil, &tsuruErrors.ValidationError{Message: "the window and the step of the metrics must be positive"}
+	}
+	if window/step > maxMetricsPoints {
+		return nil, &tsuruErrors.ValidationError{Message: fmt.Sprintf("the window of the metrics can have at most %d steps", maxMetricsPoints)}
+	}
+	source, err := CurrentMetricsSource()
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now().UTC().Truncate(step)
+	return source.UnitsMetrics(app, provision.MetricsOpts{Start: end.Add(-window), End: end, Step: step})
+}
+
+type provisionerMetricsSource struct{}
+
+func (provisionerMetricsSource) UnitsMetrics(app *App, opts provision.MetricsOpts) ([]provision.UnitMetrics, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	metricsProv, ok := prov.(provision.MetricsProvisioner)
+	if !ok {
+		return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "unit metrics"}
+	}
+	return metricsProv.UnitsMetrics(app, opts)
+}
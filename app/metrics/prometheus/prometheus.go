@@ -0,0 +1,200 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package prometheus provides an implementation of the app.MetricsSource,
+// that queries the container metrics exported by cAdvisor from a Prometheus
+// server. This package doesn't expose any public types, in order to use it,
+// users need to import the package and then configure tsuru to use the
+// "prometheus" metrics source.
+//
+//     import _ "github.com/tsuru/tsuru/app/metrics/prometheus"
+//
+// The metrics of an app are selected by a label holding the name of the app,
+// and grouped in units by another label, both configurable.
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const (
+	defaultAppLabel  = "container_label_app_name"
+	defaultUnitLabel = "name"
+
+	// minRateWindow is the minimum range of the rates, so that it always
+	// includes at least two samples with the usual scrape intervals.
+	minRateWindow = time.Minute
+)
+
+func init() {
+	app.RegisterMetricsSource("prometheus", promSource{})
+	hc.AddChecker("Prometheus", healthCheck)
+}
+
+func healthCheck() error {
+	sourceName, _ := config.GetString("metrics:source")
+	if sourceName != "prometheus" {
+		return hc.ErrDisabledComponent
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Get(c.url + "/-/healthy")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+type client struct {
+	url       string
+	appLabel  string
+	unitLabel string
+	http      *http.Client
+}
+
+func newClient() (*client, error) {
+	url, err := config.GetString("metrics:prometheus:url")
+	if err != nil {
+		return nil, err
+	}
+	appLabel, _ := config.GetString("metrics:prometheus:app-label")
+	if appLabel == "" {
+		appLabel = defaultAppLabel
+	}
+	unitLabel, _ := config.GetString("metrics:prometheus:unit-label")
+	if unitLabel == "" {
+		unitLabel = defaultUnitLabel
+	}
+	return &client{
+		url:       strings.TrimRight(url, "/"),
+		appLabel:  appLabel,
+		unitLabel: unitLabel,
+		http:      net.Dial5Full300Client,
+	}, nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("prometheus returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+type rangeResult struct {
+	Status string
+	Error  string
+	Data   struct {
+		Result []struct {
+			Metric map[string]string
+			Values [][2]interface{}
+		}
+	}
+}
+
+// queryRange runs the query in the window, returning the points of each
+// value of the unit label. NaN values, as in rates without enough samples,
+// are skipped since they can't be encoded in JSON.
+func (c *client) queryRange(query string, opts provision.MetricsOpts) (map[string][]provision.MetricPoint, error) {
+	v := url.Values{}
+	v.Set("query", query)
+	v.Set("start", strconv.FormatInt(opts.Start.Unix(), 10))
+	v.Set("end", strconv.FormatInt(opts.End.Unix(), 10))
+	v.Set("step", strconv.FormatFloat(opts.Step.Seconds(), 'f', -1, 64))
+	resp, err := c.http.Get(c.url + "/api/v1/query_range?" + v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result rangeResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "prometheus returned status %d", resp.StatusCode)
+	}
+	if result.Status != "success" {
+		return nil, errors.Errorf("prometheus query failed: %s", result.Error)
+	}
+	series := make(map[string][]provision.MetricPoint)
+	for _, r := range result.Data.Result {
+		unit := r.Metric[c.unitLabel]
+		points := make([]provision.MetricPoint, 0, len(r.Values))
+		for _, value := range r.Values {
+			ts, _ := value[0].(float64)
+			str, _ := value[1].(string)
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				continue
+			}
+			points = append(points, provision.MetricPoint{Timestamp: time.Unix(int64(ts), 0).UTC(), Value: f})
+		}
+		series[unit] = points
+	}
+	return series, nil
+}
+
+type promSource struct{}
+
+func (promSource) UnitsMetrics(a *app.App, opts provision.MetricsOpts) ([]provision.UnitMetrics, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	rateWindow := opts.Step
+	if rateWindow < minRateWindow {
+		rateWindow = minRateWindow
+	}
+	selector := fmt.Sprintf(`{%s=%q,%s!=""}`, c.appLabel, a.Name, c.unitLabel)
+	rate := func(metric string) string {
+		return fmt.Sprintf("sum by (%s) (rate(%s%s[%ds]))", c.unitLabel, metric, selector, int64(rateWindow.Seconds()))
+	}
+	queries := []struct {
+		query string
+		set   func(*provision.UnitMetrics, []provision.MetricPoint)
+	}{
+		{rate("container_cpu_usage_seconds_total") + " * 100", func(m *provision.UnitMetrics, p []provision.MetricPoint) { m.CPU = p }},
+		{fmt.Sprintf("sum by (%s) (container_memory_usage_bytes%s)", c.unitLabel, selector), func(m *provision.UnitMetrics, p []provision.MetricPoint) { m.Memory = p }},
+		{rate("container_network_receive_bytes_total"), func(m *provision.UnitMetrics, p []provision.MetricPoint) { m.NetworkRx = p }},
+		{rate("container_network_transmit_bytes_total"), func(m *provision.UnitMetrics, p []provision.MetricPoint) { m.NetworkTx = p }},
+	}
+	units := make(map[string]*provision.UnitMetrics)
+	for _, q := range queries {
+		series, err := c.queryRange(q.query, opts)
+		if err != nil {
+			return nil, err
+		}
+		for unit, points := range series {
+			m, ok := units[unit]
+			if !ok {
+				m = &provision.UnitMetrics{ID: unit}
+				units[unit] = m
+			}
+			q.set(m, points)
+		}
+	}
+	metrics := make([]provision.UnitMetrics, 0, len(units))
+	for _, m := range units {
+		metrics = append(metrics, *m)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].ID < metrics[j].ID })
+	return metrics, nil
+}
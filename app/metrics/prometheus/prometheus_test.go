@@ -0,0 +1,129 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	server  *httptest.Server
+	queries []string
+	mu      sync.Mutex
+}
+
+var _ = check.Suite(&S{})
+
+// ServeHTTP implements the parts of the Prometheus API used by the source,
+// returning for the units u1 and u2 a point, with a value depending on the
+// metric, and a NaN point.
+func (s *S) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/-/healthy":
+		w.Write([]byte("Prometheus is Healthy."))
+	case "/api/v1/query_range":
+		query := r.FormValue("query")
+		s.mu.Lock()
+		s.queries = append(s.queries, query)
+		s.mu.Unlock()
+		value := "1"
+		switch {
+		case strings.Contains(query, "cpu"):
+			value = "12.5"
+		case strings.Contains(query, "memory"):
+			value = "67108864"
+		case strings.Contains(query, "receive"):
+			value = "1024"
+		}
+		start := r.FormValue("start")
+		var result []string
+		for _, unit := range []string{"u2", "u1"} {
+			result = append(result, fmt.Sprintf(`{"metric":{"name":%q},"values":[[%s,%q],[%s.5,"NaN"]]}`,
+				unit, start, value, start))
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[%s]}}`, strings.Join(result, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.queries = nil
+	s.server = httptest.NewServer(s)
+	config.Set("metrics:source", "prometheus")
+	config.Set("metrics:prometheus:url", s.server.URL+"/")
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+	config.Unset("metrics")
+}
+
+func (s *S) TestUnitsMetrics(c *check.C) {
+	a := app.App{Name: "myapp"}
+	start := time.Date(2017, 10, 14, 10, 0, 0, 0, time.UTC)
+	metrics, err := promSource{}.UnitsMetrics(&a, provision.MetricsOpts{Start: start, End: start.Add(time.Minute), Step: 30 * time.Second})
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.HasLen, 2)
+	c.Assert(metrics[0].ID, check.Equals, "u1")
+	c.Assert(metrics[1].ID, check.Equals, "u2")
+	points := func(value float64) []provision.MetricPoint {
+		return []provision.MetricPoint{{Timestamp: start, Value: value}}
+	}
+	c.Assert(metrics[0].CPU, check.DeepEquals, points(12.5))
+	c.Assert(metrics[0].Memory, check.DeepEquals, points(67108864))
+	c.Assert(metrics[0].NetworkRx, check.DeepEquals, points(1024))
+	c.Assert(metrics[0].NetworkTx, check.DeepEquals, points(1))
+	c.Assert(s.queries, check.DeepEquals, []string{
+		`sum by (name) (rate(container_cpu_usage_seconds_total{container_label_app_name="myapp",name!=""}[60s])) * 100`,
+		`sum by (name) (container_memory_usage_bytes{container_label_app_name="myapp",name!=""})`,
+		`sum by (name) (rate(container_network_receive_bytes_total{container_label_app_name="myapp",name!=""}[60s]))`,
+		`sum by (name) (rate(container_network_transmit_bytes_total{container_label_app_name="myapp",name!=""}[60s]))`,
+	})
+}
+
+func (s *S) TestUnitsMetricsCustomLabels(c *check.C) {
+	config.Set("metrics:prometheus:app-label", "app")
+	config.Set("metrics:prometheus:unit-label", "unit")
+	a := app.App{Name: "myapp"}
+	start := time.Date(2017, 10, 14, 10, 0, 0, 0, time.UTC)
+	_, err := promSource{}.UnitsMetrics(&a, provision.MetricsOpts{Start: start, End: start.Add(time.Hour), Step: 5 * time.Minute})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.queries[1], check.Equals, `sum by (unit) (container_memory_usage_bytes{app="myapp",unit!=""})`)
+	c.Assert(s.queries[2], check.Equals, `sum by (unit) (rate(container_network_receive_bytes_total{app="myapp",unit!=""}[300s]))`)
+}
+
+func (s *S) TestUnitsMetricsQueryError(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	}))
+	defer server.Close()
+	config.Set("metrics:prometheus:url", server.URL)
+	a := app.App{Name: "myapp"}
+	start := time.Now()
+	_, err := promSource{}.UnitsMetrics(&a, provision.MetricsOpts{Start: start, End: start.Add(time.Hour), Step: time.Minute})
+	c.Assert(err, check.ErrorMatches, "prometheus query failed: parse error")
+}
+
+func (s *S) TestHealthCheck(c *check.C) {
+	c.Assert(healthCheck(), check.IsNil)
+	config.Set("metrics:source", "provisioner")
+	c.Assert(healthCheck(), check.Equals, hc.ErrDisabledComponent)
+}
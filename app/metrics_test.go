@@ -0,0 +1,68 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+type fakeMetricsSource struct {
+	opts provision.MetricsOpts
+}
+
+func (s *fakeMetricsSource) UnitsMetrics(app *App, opts provision.MetricsOpts) ([]provision.UnitMetrics, error) {
+	s.opts = opts
+	return []provision.UnitMetrics{{ID: app.Name + "-0"}}, nil
+}
+
+func (s *S) TestAppMetrics(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	metrics, err := a.Metrics(time.Hour, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.HasLen, 2)
+	for _, m := range metrics {
+		c.Assert(m.CPU, check.HasLen, 61)
+		c.Assert(m.CPU[60].Timestamp.Sub(m.CPU[0].Timestamp), check.Equals, time.Hour)
+	}
+}
+
+func (s *S) TestAppMetricsInvalidWindow(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	_, err := a.Metrics(0, time.Minute)
+	c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{Message: "the window and the step of the metrics must be positive"})
+	_, err = a.Metrics(24*time.Hour, time.Second)
+	c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{Message: "the window of the metrics can have at most 1000 steps"})
+}
+
+func (s *S) TestAppMetricsCustomSource(c *check.C) {
+	source := &fakeMetricsSource{}
+	RegisterMetricsSource("fake-metrics", source)
+	defer delete(metricsSources, "fake-metrics")
+	config.Set("metrics:source", "fake-metrics")
+	defer config.Unset("metrics:source")
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	metrics, err := a.Metrics(10*time.Minute, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []provision.UnitMetrics{{ID: "myapp-0"}})
+	c.Assert(source.opts.Step, check.Equals, time.Minute)
+	c.Assert(source.opts.End.Sub(source.opts.Start), check.Equals, 10*time.Minute)
+}
+
+func (s *S) TestAppMetricsUnknownSource(c *check.C) {
+	config.Set("metrics:source", "unknown")
+	defer config.Unset("metrics:source")
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	_, err := a.Metrics(10*time.Minute, time.Minute)
+	c.Assert(err, check.ErrorMatches, `unknown metrics source "unknown"`)
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+)
+
+const (
+	DefaultSourcePool   = "pool"
+	DefaultSourceGlobal = "global"
+)
+
+// AppDefaults are the effective values used when creating an app in a pool
+// without explicitly setting them. Sources maps each field (plan, teamowner,
+// router and tags) to the origin of its value, either DefaultSourcePool or
+// DefaultSourceGlobal.
+type AppDefaults struct {
+	Pool      string
+	Plan      string
+	TeamOwner string
+	Router    string
+	Tags      []string
+	Sources   map[string]string
+}
+
+// SetPoolDefaults validates and replaces the app defaults of a pool.
+func SetPoolDefaults(poolName string, defaults provision.PoolDefaults) error {
+	if defaults.Plan != "" {
+		_, err := findPlanByName(defaults.Plan)
+		if err != nil {
+			return err
+		}
+	}
+	defaults.Tags = processTags(defaults.Tags)
+	return provision.SetPoolDefaults(poolName, defaults)
+}
+
+// EffectiveDefaults returns the values an app created in the given pool would
+// get when the plan, team owner, router and tags are omitted.
+func EffectiveDefaults(poolName string) (*AppDefaults, error) {
+	pool, err := provision.GetPoolByName(poolName)
+	if err != nil {
+		return nil, err
+	}
+	result := AppDefaults{Pool: pool.Name, Sources: map[string]string{}}
+	if pool.Defaults.Plan != "" {
+		result.Plan = pool.Defaults.Plan
+		result.Sources["plan"] = DefaultSourcePool
+	} else {
+		plan, err := DefaultPlan()
+		if err != nil {
+			return nil, err
+		}
+		result.Plan = plan.Name
+		result.Sources["plan"] = DefaultSourceGlobal
+	}
+	if pool.Defaults.Router != "" {
+		result.Router = pool.Defaults.Router
+		result.Sources["router"] = DefaultSourcePool
+	} else {
+		result.Router, err = router.Default()
+		if err != nil && err != router.ErrDefaultRouterNotFound {
+			return nil, err
+		}
+		result.Sources["router"] = DefaultSourceGlobal
+	}
+	if pool.Defaults.TeamOwner != "" {
+		result.TeamOwner = pool.Defaults.TeamOwner
+		result.Sources["teamowner"] = DefaultSourcePool
+	}
+	if len(pool.Defaults.Tags) > 0 {
+		result.Tags = pool.Defaults.Tags
+		result.Sources["tags"] = DefaultSourcePool
+	}
+	return &result, nil
+}
+
+// applyPoolDefaults fills the plan, team owner, router and tags omitted in
+// the app creation with the defaults of the pool the app will be created in.
+// Explicit values always take precedence over pool defaults, which take
+// precedence over the global defaults.
+func (app *App) applyPoolDefaults() error {
+	pool, err := app.creationPool()
+	if err != nil || pool == nil {
+		return err
+	}
+	if app.Plan.Name == "" {
+		app.Plan.Name = pool.Defaults.Plan
+	}
+	if app.TeamOwner == "" {
+		app.TeamOwner = pool.Defaults.TeamOwner
+	}
+	if app.Router == "" {
+		app.Router = pool.Defaults.Router
+	}
+	if len(app.Tags) == 0 && len(pool.Defaults.Tags) > 0 {
+		app.Tags = append([]string{}, pool.Defaults.Tags...)
+	}
+	return nil
+}
+
+// creationPool returns the pool the app will be created in, or nil when it
+// can't be determined yet, in which case SetPool reports the proper error.
+func (app *App) creationPool() (*provision.Pool, error) {
+	if app.Pool != "" {
+		return provision.GetPoolByName(app.Pool)
+	}
+	if app.TeamOwner != "" {
+		poolName, err := app.getPoolForApp("")
+		if err != nil {
+			return nil, nil
+		}
+		if poolName != "" {
+			return provision.GetPoolByName(poolName)
+		}
+	}
+	pool, err := provision.GetDefaultPool()
+	if err == provision.ErrPoolNotFound {
+		return nil, nil
+	}
+	return pool, err
+}
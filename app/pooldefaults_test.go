@@ -0,0 +1,129 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateAppWithPoolDefaults(c *check.C) {
+	plan := Plan{Name: "pool-plan", Memory: 2048, Swap: 1024, CpuShare: 50}
+	err := plan.Save()
+	c.Assert(err, check.IsNil)
+	err = auth.CreateTeam("poolteam", s.user)
+	c.Assert(err, check.IsNil)
+	err = SetPoolDefaults(s.Pool, provision.PoolDefaults{
+		Plan:      "pool-plan",
+		TeamOwner: "poolteam",
+		Router:    "fake-hc",
+		Tags:      []string{" default ", "default"},
+	})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "appname", Platform: "python"}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	retrievedApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(retrievedApp.Pool, check.Equals, s.Pool)
+	c.Assert(retrievedApp.Plan, check.DeepEquals, plan)
+	c.Assert(retrievedApp.TeamOwner, check.Equals, "poolteam")
+	c.Assert(retrievedApp.Teams, check.DeepEquals, []string{"poolteam"})
+	c.Assert(retrievedApp.Router, check.Equals, "fake-hc")
+	c.Assert(retrievedApp.Tags, check.DeepEquals, []string{"default"})
+}
+
+func (s *S) TestCreateAppExplicitValuesOverridePoolDefaults(c *check.C) {
+	plan := Plan{Name: "pool-plan", Memory: 2048, Swap: 1024, CpuShare: 50}
+	err := plan.Save()
+	c.Assert(err, check.IsNil)
+	err = auth.CreateTeam("poolteam", s.user)
+	c.Assert(err, check.IsNil)
+	err = SetPoolDefaults(s.Pool, provision.PoolDefaults{
+		Plan:      "pool-plan",
+		TeamOwner: "poolteam",
+		Router:    "fake-hc",
+		Tags:      []string{"default"},
+	})
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name:      "appname",
+		Platform:  "python",
+		Pool:      s.Pool,
+		Plan:      Plan{Name: s.defaultPlan.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+		Tags:      []string{"mine"},
+	}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	retrievedApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(retrievedApp.Plan, check.DeepEquals, s.defaultPlan)
+	c.Assert(retrievedApp.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(retrievedApp.Router, check.Equals, "fake")
+	c.Assert(retrievedApp.Tags, check.DeepEquals, []string{"mine"})
+}
+
+func (s *S) TestCreateAppWithPartialPoolDefaults(c *check.C) {
+	err := SetPoolDefaults(s.Pool, provision.PoolDefaults{Router: "fake-hc"})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "appname", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	retrievedApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(retrievedApp.Plan, check.DeepEquals, s.defaultPlan)
+	c.Assert(retrievedApp.Router, check.Equals, "fake-hc")
+}
+
+func (s *S) TestSetPoolDefaultsPlanNotFound(c *check.C) {
+	err := SetPoolDefaults(s.Pool, provision.PoolDefaults{Plan: "unknown"})
+	c.Assert(err, check.Equals, ErrPlanNotFound)
+	pool, err := provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Defaults, check.DeepEquals, provision.PoolDefaults{})
+}
+
+func (s *S) TestEffectiveDefaults(c *check.C) {
+	defaults, err := EffectiveDefaults(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(defaults, check.DeepEquals, &AppDefaults{
+		Pool:   s.Pool,
+		Plan:   s.defaultPlan.Name,
+		Router: "fake",
+		Sources: map[string]string{
+			"plan":   DefaultSourceGlobal,
+			"router": DefaultSourceGlobal,
+		},
+	})
+	err = SetPoolDefaults(s.Pool, provision.PoolDefaults{
+		TeamOwner: s.team.Name,
+		Router:    "fake-hc",
+		Tags:      []string{"tag1"},
+	})
+	c.Assert(err, check.IsNil)
+	defaults, err = EffectiveDefaults(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(defaults, check.DeepEquals, &AppDefaults{
+		Pool:      s.Pool,
+		Plan:      s.defaultPlan.Name,
+		TeamOwner: s.team.Name,
+		Router:    "fake-hc",
+		Tags:      []string{"tag1"},
+		Sources: map[string]string{
+			"plan":      DefaultSourceGlobal,
+			"teamowner": DefaultSourcePool,
+			"router":    DefaultSourcePool,
+			"tags":      DefaultSourcePool,
+		},
+	})
+}
+
+func (s *S) TestEffectiveDefaultsPoolNotFound(c *check.C) {
+	_, err := EffectiveDefaults("unknown")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+}
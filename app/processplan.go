@@ -0,0 +1,104 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetProcessPlan changes the plan of the units of a process of the app,
+// restarting them. An empty planName removes the plan of the process, whose
+// units go back to the plan of the app.
+func (app *App) SetProcessPlan(process, planName string, w io.Writer) error {
+	processes, err := image.AllAppProcesses(app.Name)
+	if err != nil {
+		return provision.InvalidProcessError{Msg: "the app must be deployed before setting plans of its processes"}
+	}
+	var found bool
+	for _, name := range processes {
+		if name == process {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return provision.InvalidProcessError{Msg: fmt.Sprintf("no process %q declared in Procfile", process)}
+	}
+	update := bson.M{"$unset": bson.M{"processplans." + process: ""}}
+	var plan *Plan
+	if planName != "" {
+		plan, err = findPlanByName(planName)
+		if err != nil {
+			return err
+		}
+		update = bson.M{"$set": bson.M{"processplans." + process: plan}}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		delete(app.ProcessPlans, process)
+	} else {
+		if app.ProcessPlans == nil {
+			app.ProcessPlans = make(map[string]Plan)
+		}
+		app.ProcessPlans[process] = *plan
+	}
+	units, err := app.Units()
+	if err != nil {
+		return err
+	}
+	var hasUnits bool
+	for _, u := range units {
+		if u.ProcessName == process {
+			hasUnits = true
+			break
+		}
+	}
+	if !hasUnits {
+		return nil
+	}
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Restart(writerContext(w), app, process, w)
+}
+
+// processPlan returns the plan of the units of the process.
+func (app *App) processPlan(process string) Plan {
+	if plan, ok := app.ProcessPlans[process]; ok {
+		return plan
+	}
+	return app.Plan
+}
+
+// GetProcessMemory returns the memory limit (in bytes) for units of the
+// process.
+func (app *App) GetProcessMemory(process string) int64 {
+	return app.processPlan(process).Memory
+}
+
+// GetProcessSwap returns the swap limit (in bytes) for units of the process.
+func (app *App) GetProcessSwap(process string) int64 {
+	return app.processPlan(process).Swap
+}
+
+// GetProcessCpuShare returns the cpu share for units of the process.
+func (app *App) GetProcessCpuShare(process string) int {
+	return app.processPlan(process).CpuShare
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) setupProcessPlanApp(c *check.C) *App {
+	plan := Plan{Name: "big", CpuShare: 100, Memory: 1073741824, Swap: 1024}
+	err := s.conn.Plans().Insert(plan)
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", Router: "fake", Plan: Plan{Memory: 268435456, CpuShare: 50}, TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python app.py",
+			"worker": "python worker.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestSetProcessPlan(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := s.provisioner.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(a, 2, "worker", nil)
+	c.Assert(err, check.IsNil)
+	err = a.SetProcessPlan("worker", "big", new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessPlans, check.DeepEquals, map[string]Plan{
+		"worker": {Name: "big", CpuShare: 100, Memory: 1073741824, Swap: 1024},
+	})
+	c.Assert(dbApp.GetProcessMemory("worker"), check.Equals, int64(1073741824))
+	c.Assert(dbApp.GetProcessSwap("worker"), check.Equals, int64(1024))
+	c.Assert(dbApp.GetProcessCpuShare("worker"), check.Equals, 100)
+	c.Assert(dbApp.GetProcessMemory("web"), check.Equals, int64(268435456))
+	c.Assert(dbApp.GetProcessCpuShare("web"), check.Equals, 50)
+	c.Assert(s.provisioner.Restarts(a, "worker"), check.Equals, 1)
+	c.Assert(s.provisioner.Restarts(a, "web"), check.Equals, 0)
+}
+
+func (s *S) TestSetProcessPlanWithoutUnits(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := a.SetProcessPlan("worker", "big", new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	c.Assert(a.ProcessPlans["worker"].Name, check.Equals, "big")
+	c.Assert(s.provisioner.Restarts(a, "worker"), check.Equals, 0)
+}
+
+func (s *S) TestSetProcessPlanRemovesPlan(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := a.SetProcessPlan("worker", "big", new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	err = a.SetProcessPlan("worker", "", new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	c.Assert(a.ProcessPlans, check.HasLen, 0)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessPlans, check.HasLen, 0)
+	c.Assert(dbApp.GetProcessMemory("worker"), check.Equals, int64(268435456))
+}
+
+func (s *S) TestSetProcessPlanInvalidProcess(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := a.SetProcessPlan("scheduler", "big", new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, provision.InvalidProcessError{Msg: `no process "scheduler" declared in Procfile`})
+}
+
+func (s *S) TestSetProcessPlanNotDeployed(c *check.C) {
+	a := App{Name: "myapp", Router: "fake", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetProcessPlan("web", "big", new(bytes.Buffer))
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+}
+
+func (s *S) TestSetProcessPlanNotFound(c *check.C) {
+	a := s.setupProcessPlanApp(c)
+	err := a.SetProcessPlan("worker", "unknown", new(bytes.Buffer))
+	c.Assert(err, check.Equals, ErrPlanNotFound)
+	c.Assert(a.ProcessPlans, check.HasLen, 0)
+}
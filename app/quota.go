@@ -5,8 +5,19 @@
 package app
 
 import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
 	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -36,6 +47,9 @@ func reserveUnits(app *App, quantity int) error {
 			bson.M{"$inc": bson.M{"quota.inuse": quantity}},
 		)
 	}
+	if err == nil && app.Quota.CrossesSoftLimit(quantity) {
+		warnUnitsSoftLimit(app, quantity)
+	}
 	return err
 }
 
@@ -116,3 +130,200 @@ func ChangeQuota(app *App, limit int) error {
 	app.Quota.Limit = limit
 	return nil
 }
+
+// warnUnitsSoftLimit registers a warning event for the app and notifies the
+// members of the app team owner that the units quota reached the soft limit.
+func warnUnitsSoftLimit(app *App, quantity int) {
+	usage := quota.Quota{Limit: app.Quota.Limit, InUse: app.Quota.InUse + quantity}
+	allowed := event.Allowed(permission.PermAppReadEvents, append(permission.Contexts(permission.CtxTeam, app.Teams),
+		permission.Context(permission.CtxApp, app.Name),
+		permission.Context(permission.CtxPool, app.Pool),
+	)...)
+	users, err := auth.ListUsersWithPermissions(permission.Permission{
+		Scheme:  permission.PermAppAdminQuota,
+		Context: permission.Context(permission.CtxTeam, app.TeamOwner),
+	})
+	if err != nil {
+		log.Errorf("[quota] unable to list owners of team %q: %s", app.TeamOwner, err)
+	}
+	emails := make([]string, len(users))
+	for i := range users {
+		emails[i] = users[i].Email
+	}
+	target := event.Target{Type: event.TargetTypeApp, Value: app.Name}
+	warnSoftLimit(target, usage, allowed, emails)
+}
+
+// warnUserSoftLimit registers a warning event for the user and notifies them
+// that the apps quota reached the soft limit.
+func warnUserSoftLimit(user *auth.User, quantity int) {
+	usage := quota.Quota{Limit: user.Quota.Limit, InUse: user.Quota.InUse + quantity}
+	allowed := event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, user.Email))
+	target := event.Target{Type: event.TargetTypeUser, Value: user.Email}
+	warnSoftLimit(target, usage, allowed, []string{user.Email})
+}
+
+func warnSoftLimit(target event.Target, usage quota.Quota, allowed event.AllowedPermission, emails []string) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       target,
+		InternalKind: quota.SoftLimitEventKind,
+		CustomData:   usage,
+		Allowed:      allowed,
+		DisableLock:  true,
+	})
+	if err != nil {
+		log.Errorf("[quota] unable to create soft limit event for %s: %s", target, err)
+		return
+	}
+	msg := fmt.Sprintf("quota soft limit reached for %s %q: %d of %d in use (%.0f%%)",
+		target.Type, target.Value, usage.InUse, usage.Limit, usage.Usage())
+	evt.Logf("%s", msg)
+	evt.Done(nil)
+	if server, _ := config.GetString("smtp:server"); server == "" {
+		return
+	}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "Subject: [tsuru] %s\r\n\r\n", msg)
+	fmt.Fprintf(&body, "The hard limit is %d, operations exceeding it will be rejected.\r\n", usage.Limit)
+	if url := evt.URL(); url != "" {
+		fmt.Fprintf(&body, "\r\nMore details: %s\r\n", url)
+	}
+	go func() {
+		for _, email := range emails {
+			if err := auth.SendEmail(email, body.Bytes()); err != nil {
+				log.Errorf("[quota] unable to send soft limit warning to %q: %s", email, err)
+			}
+		}
+	}()
+}
+
+// QuotaUsage describes the utilization of the quota of an app or user.
+type QuotaUsage struct {
+	Name     string
+	Limit    int
+	InUse    int
+	Usage    float64
+	Warnings int
+}
+
+// QuotaReport summarizes the utilization of limited app and user quotas,
+// along with the number of soft limit warnings emitted since a given time,
+// and the resource quotas of teams and pools.
+type QuotaReport struct {
+	Since            time.Time
+	SoftLimitPercent int
+	Apps             []QuotaUsage
+	Users            []QuotaUsage
+	Teams            []ResourceQuota
+	Pools            []ResourceQuota
+}
+
+type quotaUsageList []QuotaUsage
+
+func (l quotaUsageList) Len() int           { return len(l) }
+func (l quotaUsageList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l quotaUsageList) Less(i, j int) bool { return l[i].Usage > l[j].Usage }
+
+// GetQuotaReport returns the utilization of all apps and users with limited
+// quotas, sorted from the most used to the least used. When labels is set,
+// only the apps and the pools with the labels are included.
+func GetQuotaReport(since time.Time, labels provision.LabelSelector) (*QuotaReport, error) {
+	warnings, err := softLimitWarnings(since)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	limited := bson.M{"quota.limit": bson.M{"$gte": 0}}
+	appsQuery := labels.Query("labels")
+	appsQuery["quota.limit"] = limited["quota.limit"]
+	var apps []App
+	err = conn.Apps().Find(appsQuery).Select(bson.M{"name": 1, "quota": 1}).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	var users []auth.User
+	err = conn.Users().Find(limited).Select(bson.M{"email": 1, "quota": 1}).All(&users)
+	if err != nil {
+		return nil, err
+	}
+	report := QuotaReport{Since: since, SoftLimitPercent: quota.SoftLimitPercent()}
+	for _, a := range apps {
+		target := event.Target{Type: event.TargetTypeApp, Value: a.Name}
+		report.Apps = append(report.Apps, newQuotaUsage(a.Name, a.Quota, warnings[target]))
+	}
+	for _, u := range users {
+		target := event.Target{Type: event.TargetTypeUser, Value: u.Email}
+		report.Users = append(report.Users, newQuotaUsage(u.Email, u.Quota, warnings[target]))
+	}
+	sort.Sort(quotaUsageList(report.Apps))
+	sort.Sort(quotaUsageList(report.Users))
+	report.Teams, err = ListResourceQuotas(ResourceQuotaTeam)
+	if err != nil {
+		return nil, err
+	}
+	report.Pools, err = ListResourceQuotas(ResourceQuotaPool)
+	if err != nil {
+		return nil, err
+	}
+	if labels != nil {
+		report.Pools, err = poolQuotasWithLabels(report.Pools, labels)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &report, nil
+}
+
+func poolQuotasWithLabels(quotas []ResourceQuota, labels provision.LabelSelector) ([]ResourceQuota, error) {
+	var result []ResourceQuota
+	for _, q := range quotas {
+		pool, err := provision.GetPoolByName(q.Name)
+		if err == provision.ErrPoolNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if labels.Matches(pool.Labels) {
+			result = append(result, q)
+		}
+	}
+	return result, nil
+}
+
+func newQuotaUsage(name string, q quota.Quota, warnings int) QuotaUsage {
+	return QuotaUsage{
+		Name:     name,
+		Limit:    q.Limit,
+		InUse:    q.InUse,
+		Usage:    q.Usage(),
+		Warnings: warnings,
+	}
+}
+
+func softLimitWarnings(since time.Time) (map[event.Target]int, error) {
+	const pageSize = 100
+	warnings := map[event.Target]int{}
+	filter := event.Filter{
+		KindName: quota.SoftLimitEventKind,
+		Since:    since,
+		Limit:    pageSize,
+	}
+	for {
+		evts, err := event.List(&filter)
+		if err != nil {
+			return nil, err
+		}
+		for i := range evts {
+			warnings[evts[i].Target]++
+		}
+		if len(evts) < pageSize {
+			return warnings, nil
+		}
+		filter.Skip += pageSize
+	}
+}
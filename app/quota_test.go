@@ -7,7 +7,11 @@ package app
 import (
 	"runtime"
 	"sync"
+	"time"
 
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2"
@@ -209,3 +213,73 @@ func (s *S) TestChangeQuotaAppNotFound(c *check.C) {
 	c.Assert(err, check.NotNil)
 	c.Assert(err, check.Equals, mgo.ErrNotFound)
 }
+
+func (s *S) TestReserveUnitsSoftLimit(c *check.C) {
+	config.Set("quota:soft-limit", 80)
+	defer config.Unset("quota:soft-limit")
+	app := &App{
+		Name:   "together",
+		Quota:  quota.Quota{Limit: 10, InUse: 7},
+		Router: "fake",
+	}
+	s.conn.Apps().Insert(app)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	err := reserveUnits(app, 1)
+	c.Assert(err, check.IsNil)
+	evts, err := event.List(&event.Filter{KindName: quota.SoftLimitEventKind})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target, check.Equals, event.Target{Type: event.TargetTypeApp, Value: "together"})
+	report, err := GetQuotaReport(time.Now().Add(-time.Hour), nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.SoftLimitPercent, check.Equals, 80)
+	c.Assert(report.Apps, check.DeepEquals, []QuotaUsage{
+		{Name: "together", Limit: 10, InUse: 8, Usage: 80, Warnings: 1},
+	})
+}
+
+func (s *S) TestGetQuotaReportFilteringByLabels(c *check.C) {
+	apps := []App{
+		{Name: "store", Quota: quota.Quota{Limit: 10, InUse: 2}, Labels: map[string]string{"cost-center": "1234"}},
+		{Name: "blog", Quota: quota.Quota{Limit: 10, InUse: 1}, Labels: map[string]string{"cost-center": "4321"}},
+		{Name: "unlabeled", Quota: quota.Quota{Limit: 10}},
+	}
+	for i := range apps {
+		err := s.conn.Apps().Insert(&apps[i])
+		c.Assert(err, check.IsNil)
+	}
+	err := provision.AddPool(provision.AddPoolOptions{Name: "labeled", Labels: map[string]string{"cost-center": "1234"}})
+	c.Assert(err, check.IsNil)
+	err = ChangeResourceQuota(ResourceQuotaPool, "labeled", ResourceLimits{Apps: 5, Units: -1, Memory: -1})
+	c.Assert(err, check.IsNil)
+	err = ChangeResourceQuota(ResourceQuotaPool, s.Pool, ResourceLimits{Apps: 5, Units: -1, Memory: -1})
+	c.Assert(err, check.IsNil)
+	report, err := GetQuotaReport(time.Now().Add(-time.Hour), provision.LabelSelector{"cost-center": "1234"})
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Apps, check.DeepEquals, []QuotaUsage{
+		{Name: "store", Limit: 10, InUse: 2, Usage: 20},
+	})
+	c.Assert(report.Pools, check.HasLen, 1)
+	c.Assert(report.Pools[0].Name, check.Equals, "labeled")
+	report, err = GetQuotaReport(time.Now().Add(-time.Hour), nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Apps, check.HasLen, 3)
+	c.Assert(report.Pools, check.HasLen, 2)
+}
+
+func (s *S) TestReserveUnitsBelowSoftLimit(c *check.C) {
+	config.Set("quota:soft-limit", 80)
+	defer config.Unset("quota:soft-limit")
+	app := &App{
+		Name:   "together",
+		Quota:  quota.Quota{Limit: 10},
+		Router: "fake",
+	}
+	s.conn.Apps().Insert(app)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	err := reserveUnits(app, 7)
+	c.Assert(err, check.IsNil)
+	evts, err := event.List(&event.Filter{KindName: quota.SoftLimitEventKind})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetRateLimit changes the limit of requests sent to the app by its router,
+// removing it when limit is nil. The limit is validated against the rate
+// limits supported by the router.
+func (app *App) SetRateLimit(limit *router.RateLimit) error {
+	rlRouter, err := app.rateLimitRouter()
+	if limit == nil {
+		if err == nil {
+			err = rlRouter.RemoveRateLimit(app.GetName())
+		} else if err == router.ErrRateLimitNotSupported {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		return app.saveRateLimit(nil)
+	}
+	if err != nil {
+		return err
+	}
+	err = router.ValidateRateLimit(*limit, rlRouter.RateLimitSupport())
+	if err != nil {
+		return err
+	}
+	err = rlRouter.SetRateLimit(app.GetName(), *limit)
+	if err != nil {
+		return err
+	}
+	return app.saveRateLimit(limit)
+}
+
+func (app *App) saveRateLimit(limit *router.RateLimit) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	update := bson.M{"$unset": bson.M{"ratelimit": ""}}
+	if limit != nil {
+		update = bson.M{"$set": bson.M{"ratelimit": limit}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.RateLimit = limit
+	return nil
+}
+
+func (app *App) rateLimitRouter() (router.RateLimitRouter, error) {
+	r, err := app.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	rlRouter, ok := r.(router.RateLimitRouter)
+	if !ok {
+		return nil, router.ErrRateLimitNotSupported
+	}
+	return rlRouter, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetRateLimit(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-ratelimit"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	limit := router.RateLimit{RequestsPerSecond: 10, Burst: 5, PerIP: true}
+	err = a.SetRateLimit(&limit)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.RateLimit, check.DeepEquals, &limit)
+	c.Assert(routertest.RateLimitRouter.Limits[a.Name], check.DeepEquals, limit)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RateLimit, check.DeepEquals, &limit)
+	err = a.SetRateLimit(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.RateLimit, check.IsNil)
+	c.Assert(routertest.RateLimitRouter.Limits, check.HasLen, 0)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.RateLimit, check.IsNil)
+}
+
+func (s *S) TestSetRateLimitNotSupportedByRouter(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetRateLimit(&router.RateLimit{RequestsPerSecond: 10})
+	c.Assert(err, check.Equals, router.ErrRateLimitNotSupported)
+	err = a.SetRateLimit(nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestSetRateLimitInvalidForRouter(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-ratelimit"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	routertest.RateLimitRouter.Support = router.RateLimitSupport{Global: true}
+	err = a.SetRateLimit(&router.RateLimit{RequestsPerSecond: 10, PerIP: true})
+	c.Assert(err, check.ErrorMatches, "router does not support per-IP rate limiting")
+	c.Assert(a.RateLimit, check.IsNil)
+	c.Assert(routertest.RateLimitRouter.Limits, check.HasLen, 0)
+}
+
+func (s *S) TestUpdateRouterValidatesRateLimit(c *check.C) {
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-ratelimit"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetRateLimit(&router.RateLimit{RequestsPerSecond: 10})
+	c.Assert(err, check.IsNil)
+	err = a.Update(App{Router: "fake"}, nil)
+	c.Assert(err, check.Equals, router.ErrRateLimitNotSupported)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Router, check.Equals, "fake-ratelimit")
+}
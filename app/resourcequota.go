@@ -0,0 +1,231 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	ResourceQuotaTeam = "team"
+	ResourceQuotaPool = "pool"
+)
+
+var (
+	ErrInvalidResourceQuotaScope    = errors.New("invalid quota scope, valid scopes are: team, pool")
+	ErrResourceQuotaLesserThanInUse = errors.New("new limit is lesser than the current allocated value")
+)
+
+// ResourceLimits are the number of apps, units and the memory (in bytes) of
+// the units of the apps owned by a team or in a pool. As limits, -1 means
+// unlimited.
+type ResourceLimits struct {
+	Apps   int
+	Units  int
+	Memory int64
+}
+
+var UnlimitedResources = ResourceLimits{Apps: -1, Units: -1, Memory: -1}
+
+// ResourceQuota holds the limits and the usage of the resources of the apps
+// owned by a team, or in a pool. The memory in use is the memory of the plan
+// of each app times its number of units.
+type ResourceQuota struct {
+	Scope string
+	Name  string
+	Limit ResourceLimits
+	InUse ResourceLimits
+}
+
+type resourceQuotaLimit struct {
+	Scope string
+	Name  string
+	Limit ResourceLimits
+}
+
+// GetResourceQuota returns the limits and the usage of the apps owned by the
+// team, or in the pool, according to the scope. Teams and pools without
+// limits are unlimited.
+func GetResourceQuota(scope, name string) (*ResourceQuota, error) {
+	err := checkResourceQuotaTarget(scope, name)
+	if err != nil {
+		return nil, err
+	}
+	return resourceQuota(scope, name)
+}
+
+func resourceQuota(scope, name string) (*ResourceQuota, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	q := ResourceQuota{Scope: scope, Name: name, Limit: UnlimitedResources}
+	var limit resourceQuotaLimit
+	err = conn.ResourceQuotas().Find(bson.M{"scope": scope, "name": name}).One(&limit)
+	if err == nil {
+		q.Limit = limit.Limit
+	} else if err != mgo.ErrNotFound {
+		return nil, err
+	}
+	q.InUse, err = resourceUsage(scope, name)
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// ChangeResourceQuota redefines the limits of the team or the pool. Each new
+// limit must be bigger than or equal to the current usage, negative limits
+// mean unlimited.
+func ChangeResourceQuota(scope, name string, limit ResourceLimits) error {
+	q, err := GetResourceQuota(scope, name)
+	if err != nil {
+		return err
+	}
+	if limit.Apps < 0 {
+		limit.Apps = -1
+	}
+	if limit.Units < 0 {
+		limit.Units = -1
+	}
+	if limit.Memory < 0 {
+		limit.Memory = -1
+	}
+	if (limit.Apps >= 0 && limit.Apps < q.InUse.Apps) ||
+		(limit.Units >= 0 && limit.Units < q.InUse.Units) ||
+		(limit.Memory >= 0 && limit.Memory < q.InUse.Memory) {
+		return ErrResourceQuotaLesserThanInUse
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ResourceQuotas().Upsert(
+		bson.M{"scope": scope, "name": name},
+		resourceQuotaLimit{Scope: scope, Name: name, Limit: limit},
+	)
+	return err
+}
+
+// ListResourceQuotas returns the quotas of the teams or the pools with
+// limits, sorted by name.
+func ListResourceQuotas(scope string) ([]ResourceQuota, error) {
+	if scope != ResourceQuotaTeam && scope != ResourceQuotaPool {
+		return nil, ErrInvalidResourceQuotaScope
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var limits []resourceQuotaLimit
+	err = conn.ResourceQuotas().Find(bson.M{"scope": scope}).All(&limits)
+	if err != nil {
+		return nil, err
+	}
+	quotas := make([]ResourceQuota, 0, len(limits))
+	for _, l := range limits {
+		usage, err := resourceUsage(scope, l.Name)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, ResourceQuota{Scope: scope, Name: l.Name, Limit: l.Limit, InUse: usage})
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		return quotas[i].Name < quotas[j].Name
+	})
+	return quotas, nil
+}
+
+func checkResourceQuotaTarget(scope, name string) error {
+	var err error
+	switch scope {
+	case ResourceQuotaTeam:
+		_, err = auth.GetTeam(name)
+	case ResourceQuotaPool:
+		_, err = provision.GetPoolByName(name)
+	default:
+		err = ErrInvalidResourceQuotaScope
+	}
+	return err
+}
+
+func resourceUsage(scope, name string) (ResourceLimits, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return ResourceLimits{}, err
+	}
+	defer conn.Close()
+	field := "teamowner"
+	if scope == ResourceQuotaPool {
+		field = "pool"
+	}
+	var result []ResourceLimits
+	err = conn.Apps().Pipe([]bson.M{
+		{"$match": bson.M{field: name}},
+		{"$group": bson.M{
+			"_id":    nil,
+			"apps":   bson.M{"$sum": 1},
+			"units":  bson.M{"$sum": "$quota.inuse"},
+			"memory": bson.M{"$sum": bson.M{"$multiply": []interface{}{"$quota.inuse", "$plan.memory"}}},
+		}},
+	}).All(&result)
+	if err != nil || len(result) == 0 {
+		return ResourceLimits{}, err
+	}
+	return result[0], nil
+}
+
+// checkResourceQuotas checks whether adding apps, units and memory to the
+// usage of the team owner and the pool of the app exceeds their limits.
+func checkResourceQuotas(app *App, apps, units int, memory int64) error {
+	targets := []struct{ scope, name string }{
+		{ResourceQuotaTeam, app.TeamOwner},
+		{ResourceQuotaPool, app.Pool},
+	}
+	for _, t := range targets {
+		if t.name == "" {
+			continue
+		}
+		q, err := resourceQuota(t.scope, t.name)
+		if err != nil {
+			return err
+		}
+		resources := []struct {
+			name             string
+			limit, requested int64
+			inUse            int64
+		}{
+			{"apps", int64(q.Limit.Apps), int64(apps), int64(q.InUse.Apps)},
+			{"units", int64(q.Limit.Units), int64(units), int64(q.InUse.Units)},
+			{"memory", q.Limit.Memory, memory, q.InUse.Memory},
+		}
+		for _, r := range resources {
+			if r.limit < 0 || r.requested == 0 || r.inUse+r.requested <= r.limit {
+				continue
+			}
+			available := r.limit - r.inUse
+			if available < 0 {
+				available = 0
+			}
+			return errors.WithMessage(&quota.QuotaExceededError{
+				Available: uint(available),
+				Requested: uint(r.requested),
+			}, fmt.Sprintf("%s %q %s quota", t.scope, t.name, r.name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGetResourceQuotaUnlimited(c *check.C) {
+	q, err := GetResourceQuota(ResourceQuotaTeam, s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(q, check.DeepEquals, &ResourceQuota{
+		Scope: ResourceQuotaTeam,
+		Name:  s.team.Name,
+		Limit: UnlimitedResources,
+	})
+}
+
+func (s *S) TestGetResourceQuotaUsage(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(3, "web", nil)
+	c.Assert(err, check.IsNil)
+	b := App{Name: "otherapp", TeamOwner: s.team.Name}
+	err = CreateApp(&b, s.user)
+	c.Assert(err, check.IsNil)
+	expected := ResourceLimits{Apps: 2, Units: 3, Memory: 3 * s.defaultPlan.Memory}
+	q, err := GetResourceQuota(ResourceQuotaTeam, s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(q.InUse, check.DeepEquals, expected)
+	q, err = GetResourceQuota(ResourceQuotaPool, s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(q.InUse, check.DeepEquals, expected)
+}
+
+func (s *S) TestGetResourceQuotaNotFound(c *check.C) {
+	_, err := GetResourceQuota(ResourceQuotaTeam, "unknown")
+	c.Assert(err, check.Equals, auth.ErrTeamNotFound)
+	_, err = GetResourceQuota(ResourceQuotaPool, "unknown")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+	_, err = GetResourceQuota("cluster", "unknown")
+	c.Assert(err, check.Equals, ErrInvalidResourceQuotaScope)
+}
+
+func (s *S) TestChangeResourceQuota(c *check.C) {
+	err := ChangeResourceQuota(ResourceQuotaPool, s.Pool, ResourceLimits{Apps: 10, Units: -5, Memory: 4096})
+	c.Assert(err, check.IsNil)
+	q, err := GetResourceQuota(ResourceQuotaPool, s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Limit, check.DeepEquals, ResourceLimits{Apps: 10, Units: -1, Memory: 4096})
+	quotas, err := ListResourceQuotas(ResourceQuotaPool)
+	c.Assert(err, check.IsNil)
+	c.Assert(quotas, check.DeepEquals, []ResourceQuota{*q})
+	quotas, err = ListResourceQuotas(ResourceQuotaTeam)
+	c.Assert(err, check.IsNil)
+	c.Assert(quotas, check.HasLen, 0)
+}
+
+func (s *S) TestChangeResourceQuotaLesserThanInUse(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = ChangeResourceQuota(ResourceQuotaTeam, s.team.Name, ResourceLimits{Apps: 1, Units: 1, Memory: -1})
+	c.Assert(err, check.Equals, ErrResourceQuotaLesserThanInUse)
+	q, err := GetResourceQuota(ResourceQuotaTeam, s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Limit, check.DeepEquals, UnlimitedResources)
+}
+
+func (s *S) TestCreateAppResourceQuotaExceeded(c *check.C) {
+	err := ChangeResourceQuota(ResourceQuotaTeam, s.team.Name, ResourceLimits{Apps: 1, Units: -1, Memory: -1})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	b := App{Name: "otherapp", TeamOwner: s.team.Name}
+	err = CreateApp(&b, s.user)
+	c.Assert(err, check.ErrorMatches, `team "tsuruteam" apps quota: .*`)
+	e, ok := errors.Cause(err).(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Available, check.Equals, uint(0))
+	c.Assert(e.Requested, check.Equals, uint(1))
+	_, err = GetByName(b.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestAddUnitsResourceQuotaExceeded(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = ChangeResourceQuota(ResourceQuotaPool, s.Pool, ResourceLimits{Apps: -1, Units: -1, Memory: 2 * s.defaultPlan.Memory})
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(1, "web", nil)
+	c.Assert(err, check.ErrorMatches, `pool "pool1" memory quota: .*`)
+	e, ok := errors.Cause(err).(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Available, check.Equals, uint(0))
+	c.Assert(e.Requested, check.Equals, uint(s.defaultPlan.Memory))
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 2)
+}
@@ -0,0 +1,347 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultReviewAppsTTL = 72 * time.Hour
+	minReviewAppsTTL     = time.Minute
+
+	// maxAppNameLength is the length limit of app names, which are also
+	// used as DNS labels.
+	maxAppNameLength = 63
+	// reviewAppHashLength is the length of the hash of the branch appended
+	// to truncated review app names, keeping them unique.
+	reviewAppHashLength = 6
+)
+
+var (
+	ErrReviewAppsDisabled = errors.New("review apps are not enabled for the app")
+	ErrReviewAppNotFound  = errors.New("review app not found")
+
+	reNonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ReviewAppsConfig enables review apps for a template app: short-lived
+// copies of the app, created by Clone, deploying the branches of GitURL.
+type ReviewAppsConfig struct {
+	// GitURL is the repository whose branches are deployed to the review
+	// apps.
+	GitURL string
+	// TTL is the inactivity period after which review apps are removed.
+	TTL time.Duration
+	// Pool of the review apps, the pool of the template is used when it's
+	// empty.
+	Pool           string `json:",omitempty" bson:",omitempty"`
+	IncludeSecrets bool   `json:",omitempty" bson:",omitempty"`
+	NewInstances   bool   `json:",omitempty" bson:",omitempty"`
+}
+
+// ReviewApp identifies an app as the review app of a branch of a template
+// app. LastActivity is updated whenever the branch is notified and is used
+// to remove inactive review apps.
+type ReviewApp struct {
+	Template     string
+	Branch       string
+	LastActivity time.Time
+	// Instances are the service instances created for the review app, which
+	// are removed along with it.
+	Instances []ReviewAppInstance `json:",omitempty" bson:",omitempty"`
+}
+
+// ReviewAppInstance is a service instance created for a review app.
+type ReviewAppInstance struct {
+	Service  string
+	Instance string
+}
+
+// SetReviewApps enables review apps for the app with the given config, or
+// disables them when cfg is nil. Existing review apps are kept when they're
+// disabled, being removed once inactive for the default TTL.
+func (app *App) SetReviewApps(cfg *ReviewAppsConfig) error {
+	update := bson.M{"$unset": bson.M{"reviewapps": ""}}
+	if cfg != nil {
+		if app.ReviewApp != nil {
+			return &tsuruErrors.ValidationError{Message: "review apps can't be enabled for review apps"}
+		}
+		if cfg.TTL == 0 {
+			cfg.TTL = reviewAppsDefaultTTL()
+		}
+		if cfg.TTL < minReviewAppsTTL {
+			return &tsuruErrors.ValidationError{Message: fmt.Sprintf("the ttl of review apps must be at least %v", minReviewAppsTTL)}
+		}
+		err := ValidateGitSource(cfg.GitURL, "")
+		if err != nil {
+			return &tsuruErrors.ValidationError{Message: err.Error()}
+		}
+		update = bson.M{"$set": bson.M{"reviewapps": cfg}}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.ReviewApps = cfg
+	return nil
+}
+
+// ReviewAppsTTL returns the inactivity period after which the review apps
+// of the app are removed, the default TTL when review apps are disabled.
+func (app *App) ReviewAppsTTL() time.Duration {
+	if app.ReviewApps != nil {
+		return app.ReviewApps.TTL
+	}
+	return reviewAppsDefaultTTL()
+}
+
+func reviewAppsDefaultTTL() time.Duration {
+	seconds, _ := config.GetFloat("review-apps:default-ttl")
+	if seconds <= 0 {
+		return defaultReviewAppsTTL
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ReviewAppName returns the name of the review app of the branch, the name
+// of the template followed by the branch in lower case, with other
+// characters replaced by dashes, like "web-feature-login" for the branch
+// "feature/Login" of the app "web". Names longer than the limit of app names
+// are truncated, ending with a hash of the branch.
+func ReviewAppName(template, branch string) string {
+	return truncateWithHash(template+"-"+branchSlug(branch), branch, maxAppNameLength)
+}
+
+// ReviewAppCName returns the cname of the review app of the branch, like
+// "feature-login.web.review.example.com" for the branch "feature/Login" of
+// the app "web" and the review-apps:domain setting "review.example.com".
+// It's empty when the domain isn't set.
+func ReviewAppCName(template, branch string) string {
+	domain, _ := config.GetString("review-apps:domain")
+	if domain == "" {
+		return ""
+	}
+	slug := truncateWithHash(branchSlug(branch), branch, maxAppNameLength)
+	return fmt.Sprintf("%s.%s.%s", slug, template, strings.Trim(domain, "."))
+}
+
+func branchSlug(branch string) string {
+	return strings.Trim(reNonSlugChars.ReplaceAllString(strings.ToLower(branch), "-"), "-")
+}
+
+func truncateWithHash(name, branch string, limit int) string {
+	if len(name) <= limit {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(branch)))[:reviewAppHashLength]
+	return strings.TrimRight(name[:limit-reviewAppHashLength-1], "-") + "-" + hash
+}
+
+// ListReviewApps returns the review apps of the template app, sorted by
+// name.
+func ListReviewApps(template string) ([]App, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"reviewapp.template": template}).Sort("name").All(&apps)
+	return apps, err
+}
+
+// GetReviewApp returns the review app of the branch of the template app.
+func GetReviewApp(template *App, branch string) (*App, error) {
+	a, err := GetByName(ReviewAppName(template.Name, branch))
+	if err == ErrAppNotFound {
+		return nil, ErrReviewAppNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.ReviewApp == nil || a.ReviewApp.Template != template.Name || a.ReviewApp.Branch != branch {
+		return nil, &tsuruErrors.ConflictError{
+			Message: fmt.Sprintf("app %q exists and isn't the review app of the branch %q of %q", a.Name, branch, template.Name),
+		}
+	}
+	return a, nil
+}
+
+// SyncReviewApp returns the review app of the branch of the template app,
+// creating it with Clone when it doesn't exist, and records the activity of
+// the branch. The deploy of the branch is up to the caller.
+//
+// When the review-apps:domain setting is set the cname of the review app,
+// see ReviewAppCName, is added to it.
+func SyncReviewApp(template *App, branch string, user *auth.User, requestID string, w io.Writer) (*App, error) {
+	if template.ReviewApps == nil {
+		return nil, ErrReviewAppsDisabled
+	}
+	err := ValidateGitSource(template.ReviewApps.GitURL, branch)
+	if err != nil {
+		return nil, &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	now := time.Now().UTC()
+	a, err := GetReviewApp(template, branch)
+	if err == ErrReviewAppNotFound {
+		return createReviewApp(template, branch, now, user, requestID, w)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = a.setReviewApp(ReviewApp{
+		Template:     template.Name,
+		Branch:       branch,
+		LastActivity: now,
+		Instances:    a.ReviewApp.Instances,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func createReviewApp(template *App, branch string, now time.Time, user *auth.User, requestID string, w io.Writer) (*App, error) {
+	cfg := template.ReviewApps
+	a, err := Clone(template, CloneOptions{
+		Name:           ReviewAppName(template.Name, branch),
+		Pool:           cfg.Pool,
+		IncludeSecrets: cfg.IncludeSecrets,
+		NewInstances:   cfg.NewInstances,
+		RequestID:      requestID,
+	}, user, w)
+	if err != nil {
+		return nil, err
+	}
+	reviewApp := ReviewApp{Template: template.Name, Branch: branch, LastActivity: now}
+	if cfg.NewInstances {
+		instances, err := a.serviceInstances()
+		if err != nil {
+			return nil, destroyFailedReviewApp(a, err, requestID, w)
+		}
+		for _, instance := range instances {
+			reviewApp.Instances = append(reviewApp.Instances, ReviewAppInstance{
+				Service:  instance.ServiceName,
+				Instance: instance.Name,
+			})
+		}
+	}
+	err = a.setReviewApp(reviewApp)
+	if err != nil {
+		return nil, destroyFailedReviewApp(a, err, requestID, w)
+	}
+	if cname := ReviewAppCName(template.Name, branch); cname != "" {
+		fmt.Fprintf(w, "---- Adding cname %q ----\n", cname)
+		err = a.AddCName(cname)
+		if err != nil {
+			return nil, destroyFailedReviewApp(a, err, requestID, w)
+		}
+	}
+	return GetByName(a.Name)
+}
+
+func destroyFailedReviewApp(a *App, err error, requestID string, w io.Writer) error {
+	destroyErr := DestroyReviewApp(a, requestID, w)
+	if destroyErr != nil {
+		log.Errorf("[review-app] unable to remove review app %q: %s", a.Name, destroyErr)
+	}
+	return err
+}
+
+func (app *App) setReviewApp(reviewApp ReviewApp) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"reviewapp": reviewApp}})
+	if err != nil {
+		return err
+	}
+	app.ReviewApp = &reviewApp
+	return nil
+}
+
+// DestroyReviewApp removes the review app and the service instances created
+// for it.
+func DestroyReviewApp(a *App, requestID string, w io.Writer) error {
+	if a.ReviewApp == nil {
+		return errors.Errorf("app %q isn't a review app", a.Name)
+	}
+	err := Delete(a, w)
+	if err != nil {
+		return err
+	}
+	for _, created := range a.ReviewApp.Instances {
+		instance, err := service.GetServiceInstance(created.Service, created.Instance)
+		if err == nil {
+			err = service.DeleteInstance(instance, requestID)
+		}
+		if err != nil && err != service.ErrServiceInstanceNotFound {
+			log.Errorf("[review-app] unable to remove service instance %q of review app %q: %s", created.Instance, a.Name, err)
+		}
+	}
+	return nil
+}
+
+// ListInactiveReviewApps returns the review apps without activity for the
+// TTL of their template apps until now. The default TTL is used for the
+// review apps of templates that no longer have review apps enabled.
+func ListInactiveReviewApps(now time.Time) ([]App, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"reviewapp": bson.M{"$exists": true}}).Sort("name").All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	ttls := map[string]time.Duration{}
+	var inactive []App
+	for _, a := range apps {
+		ttl, ok := ttls[a.ReviewApp.Template]
+		if !ok {
+			template, err := GetByName(a.ReviewApp.Template)
+			if err == ErrAppNotFound {
+				template, err = &App{}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			ttl = template.ReviewAppsTTL()
+			ttls[a.ReviewApp.Template] = ttl
+		}
+		if a.ReviewApp.LastActivity.Add(ttl).Before(now) {
+			inactive = append(inactive, a)
+		}
+	}
+	return inactive, nil
+}
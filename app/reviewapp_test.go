@@ -0,0 +1,204 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/secret/secrettest"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestReviewAppName(c *check.C) {
+	tests := []struct {
+		template, branch, expected string
+	}{
+		{"web", "master", "web-master"},
+		{"web", "feature/Login", "web-feature-login"},
+		{"web", "fix_bug.123--x", "web-fix-bug-123-x"},
+		{"web", "-weird-", "web-weird"},
+		{"web", strings.Repeat("a", 70), "web-" + strings.Repeat("a", 52) + "-ed6c69"},
+	}
+	for _, tt := range tests {
+		name := ReviewAppName(tt.template, tt.branch)
+		c.Check(name, check.Equals, tt.expected)
+		c.Check(len(name) <= 63, check.Equals, true, check.Commentf("%q", name))
+		c.Check(nameRegexp.MatchString(name), check.Equals, true, check.Commentf("%q", name))
+	}
+	long := strings.Repeat("a", 70)
+	c.Assert(ReviewAppName("web", long), check.Not(check.Equals), ReviewAppName("web", long+"b"))
+}
+
+func (s *S) TestReviewAppCName(c *check.C) {
+	c.Assert(ReviewAppCName("web", "feature/Login"), check.Equals, "")
+	config.Set("review-apps:domain", "review.example.com.")
+	defer config.Unset("review-apps:domain")
+	c.Assert(ReviewAppCName("web", "feature/Login"), check.Equals, "feature-login.web.review.example.com")
+}
+
+func (s *S) TestSetReviewApps(c *check.C) {
+	a := App{Name: "web", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git", NewInstances: true})
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApps, check.DeepEquals, &ReviewAppsConfig{
+		GitURL:       "https://github.com/tsuru/web.git",
+		TTL:          defaultReviewAppsTTL,
+		NewInstances: true,
+	})
+	err = a.SetReviewApps(nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApps, check.IsNil)
+}
+
+func (s *S) TestSetReviewAppsInvalid(c *check.C) {
+	a := App{Name: "web", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []ReviewAppsConfig{
+		{GitURL: "file:///etc"},
+		{GitURL: "https://github.com/tsuru/web.git", TTL: time.Second},
+	}
+	for _, cfg := range tests {
+		err = a.SetReviewApps(&cfg)
+		c.Check(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	}
+	a.ReviewApp = &ReviewApp{Template: "other", Branch: "master"}
+	err = a.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git"})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApps, check.IsNil)
+}
+
+func (s *S) TestSyncReviewAppCreatesApp(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(cloneServiceHandler))
+	defer server.Close()
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	config.Set("review-apps:domain", "review.example.com")
+	defer config.Unset("review-apps:domain")
+	template := s.createCloneSource(c, server.URL)
+	err := template.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git"})
+	c.Assert(err, check.IsNil)
+	reviewApp, err := SyncReviewApp(template, "feature/login", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(reviewApp.Name, check.Equals, "web-feature-login")
+	c.Assert(reviewApp.ReviewApp.Template, check.Equals, "web")
+	c.Assert(reviewApp.ReviewApp.Branch, check.Equals, "feature/login")
+	c.Assert(reviewApp.ReviewApp.LastActivity.IsZero(), check.Equals, false)
+	c.Assert(reviewApp.ReviewApp.Instances, check.IsNil)
+	c.Assert(reviewApp.ReviewApps, check.IsNil)
+	c.Assert(reviewApp.CName, check.DeepEquals, []string{"feature-login.web.review.example.com"})
+	c.Assert(reviewApp.UserEnvs()["PUBLIC"], check.DeepEquals, bind.EnvVar{Name: "PUBLIC", Value: "1", Public: true})
+	apps, err := ListReviewApps("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+	c.Assert(apps[0].Name, check.Equals, "web-feature-login")
+}
+
+func (s *S) TestSyncReviewAppUpdatesActivity(c *check.C) {
+	template := App{Name: "web", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&template, s.user)
+	c.Assert(err, check.IsNil)
+	err = template.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git"})
+	c.Assert(err, check.IsNil)
+	first, err := SyncReviewApp(&template, "master", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	second, err := SyncReviewApp(&template, "master", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(second.Name, check.Equals, first.Name)
+	dbApp, err := GetByName(first.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApp.LastActivity.Before(first.ReviewApp.LastActivity), check.Equals, false)
+	apps, err := ListReviewApps("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+}
+
+func (s *S) TestSyncReviewAppErrors(c *check.C) {
+	template := App{Name: "web", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&template, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = SyncReviewApp(&template, "master", s.user, "", nil)
+	c.Assert(err, check.Equals, ErrReviewAppsDisabled)
+	err = template.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git"})
+	c.Assert(err, check.IsNil)
+	_, err = SyncReviewApp(&template, "a..b", s.user, "", nil)
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	other := App{Name: "web-master", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&other, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = SyncReviewApp(&template, "master", s.user, "", nil)
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ConflictError{})
+	dbApp, err := GetByName(other.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ReviewApp, check.IsNil)
+}
+
+func (s *S) TestDestroyReviewAppRemovesCreatedInstances(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(cloneServiceHandler))
+	defer server.Close()
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	template := s.createCloneSource(c, server.URL)
+	err := template.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git", NewInstances: true})
+	c.Assert(err, check.IsNil)
+	reviewApp, err := SyncReviewApp(template, "master", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(reviewApp.ReviewApp.Instances, check.DeepEquals, []ReviewAppInstance{
+		{Service: "mysql", Instance: "mydb-web-master"},
+	})
+	err = DestroyReviewApp(reviewApp, "", nil)
+	c.Assert(err, check.IsNil)
+	_, err = GetByName("web-master")
+	c.Assert(err, check.Equals, ErrAppNotFound)
+	_, err = service.GetServiceInstance("mysql", "mydb-web-master")
+	c.Assert(err, check.Equals, service.ErrServiceInstanceNotFound)
+	instance, err := service.GetServiceInstance("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.Apps, check.DeepEquals, []string{"web"})
+}
+
+func (s *S) TestListInactiveReviewApps(c *check.C) {
+	template := App{Name: "web", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&template, s.user)
+	c.Assert(err, check.IsNil)
+	err = template.SetReviewApps(&ReviewAppsConfig{GitURL: "https://github.com/tsuru/web.git", TTL: time.Hour})
+	c.Assert(err, check.IsNil)
+	_, err = SyncReviewApp(&template, "master", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	_, err = SyncReviewApp(&template, "feature", s.user, "", nil)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Update(bson.M{"name": "web-feature"}, bson.M{
+		"$set": bson.M{"reviewapp.lastactivity": time.Now().Add(-2 * time.Hour)},
+	})
+	c.Assert(err, check.IsNil)
+	apps, err := ListInactiveReviewApps(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+	c.Assert(apps[0].Name, check.Equals, "web-feature")
+	err = template.SetReviewApps(nil)
+	c.Assert(err, check.IsNil)
+	apps, err = ListInactiveReviewApps(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 0)
+	apps, err = ListInactiveReviewApps(time.Now().Add(defaultReviewAppsTTL + time.Minute))
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 2)
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// RollbackImage is an image of an app retained for rollbacks, along with the
+// deploy that generated it, when it's still recorded.
+type RollbackImage struct {
+	Image   string
+	Version string
+	Current bool
+	Deploy  *DeployData `json:",omitempty"`
+}
+
+// ListRollbackImages returns the images of the app retained for rollbacks,
+// the newest first. The number of images retained is controlled by the
+// docker:image-history-size setting.
+func ListRollbackImages(appName string) ([]RollbackImage, error) {
+	imgs, err := image.ListValidAppImages(appName)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 {
+		return nil, nil
+	}
+	evts, err := event.List(&event.Filter{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: appName},
+		KindName: permission.PermAppDeploy.FullName(),
+		KindType: event.KindTypePermission,
+		Raw: bson.M{
+			"endcustomdata.image": bson.M{"$in": imgs},
+			"error":               "",
+		},
+		Limit: -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Events are sorted from the newest to the oldest, the deploy generating
+	// each image is the last one seen with it.
+	deploys := make(map[string]*DeployData, len(imgs))
+	for i := range evts {
+		var endData map[string]interface{}
+		if evts[i].EndData(&endData) != nil {
+			continue
+		}
+		img, _ := endData["image"].(string)
+		deploys[img] = eventToDeployData(&evts[i], nil, false)
+	}
+	result := make([]RollbackImage, len(imgs))
+	for i, img := range imgs {
+		result[len(imgs)-i-1] = RollbackImage{
+			Image:   img,
+			Version: reImageVersion.FindString(img),
+			Current: i == len(imgs)-1,
+			Deploy:  deploys[img],
+		}
+	}
+	return result, nil
+}
+
+// PrepareRollback records the current image of the app in the options of a
+// rollback, as the image being rolled back.
+func PrepareRollback(opts *DeployOptions) {
+	imgs, err := image.ListValidAppImages(opts.App.Name)
+	if err == nil && len(imgs) > 0 {
+		opts.RollbackFrom = imgs[len(imgs)-1]
+	}
+}
+
+func rollbackImage(appName, input string) (string, error) {
+	if strings.Contains(input, ":") {
+		return input, nil
+	}
+	version := input
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	imgs, err := image.ListValidAppImages(appName)
+	if err != nil {
+		return "", err
+	}
+	for _, img := range imgs {
+		if strings.HasSuffix(img, ":"+version) {
+			return img, nil
+		}
+	}
+	return "", errors.Errorf("invalid version: %q", input)
+}
+
+func deployRollbackData(evt *event.Event, imageID string) map[string]interface{} {
+	if evt == nil || imageID == "" {
+		return nil
+	}
+	var startOpts DeployOptions
+	if evt.StartData(&startOpts) != nil || !startOpts.Rollback {
+		return nil
+	}
+	data := map[string]interface{}{"to": imageID}
+	if startOpts.RollbackFrom != "" {
+		data["from"] = startOpts.RollbackFrom
+	}
+	return data
+}
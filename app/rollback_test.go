@@ -0,0 +1,140 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addRollbackDeployEvent(c *check.C, appName string, opts DeployOptions, imageID string) {
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: appName},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: "me@me.com"},
+		CustomData: opts,
+		Allowed:    event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.DoneCustomData(nil, map[string]interface{}{"image": imageID})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestListRollbackImages(c *check.C) {
+	for _, img := range []string{"tsuru/app-myapp:v1", "tsuru/app-myapp:v2", "tsuru/app-myapp:v3"} {
+		err := image.AppendAppImageName("myapp", img)
+		c.Assert(err, check.IsNil)
+	}
+	s.addRollbackDeployEvent(c, "myapp", DeployOptions{Commit: "abc123"}, "tsuru/app-myapp:v1")
+	s.addRollbackDeployEvent(c, "myapp", DeployOptions{Image: "v1", Rollback: true}, "tsuru/app-myapp:v1")
+	s.addRollbackDeployEvent(c, "myapp", DeployOptions{Commit: "def456"}, "tsuru/app-myapp:v3")
+	imgs, err := ListRollbackImages("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(imgs, check.HasLen, 3)
+	c.Assert(imgs[0].Image, check.Equals, "tsuru/app-myapp:v3")
+	c.Assert(imgs[0].Version, check.Equals, "v3")
+	c.Assert(imgs[0].Current, check.Equals, true)
+	c.Assert(imgs[0].Deploy, check.NotNil)
+	c.Assert(imgs[0].Deploy.Commit, check.Equals, "def456")
+	c.Assert(imgs[1].Version, check.Equals, "v2")
+	c.Assert(imgs[1].Current, check.Equals, false)
+	c.Assert(imgs[1].Deploy, check.IsNil)
+	c.Assert(imgs[2].Version, check.Equals, "v1")
+	c.Assert(imgs[2].Deploy, check.NotNil)
+	c.Assert(imgs[2].Deploy.Commit, check.Equals, "abc123")
+}
+
+func (s *S) TestListRollbackImagesRetention(c *check.C) {
+	config.Set("docker:image-history-size", 2)
+	defer config.Unset("docker:image-history-size")
+	for _, img := range []string{"tsuru/app-myapp:v1", "tsuru/app-myapp:v2", "tsuru/app-myapp:v3"} {
+		err := image.AppendAppImageName("myapp", img)
+		c.Assert(err, check.IsNil)
+	}
+	imgs, err := ListRollbackImages("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(imgs, check.HasLen, 2)
+	c.Assert(imgs[0].Version, check.Equals, "v3")
+	c.Assert(imgs[1].Version, check.Equals, "v2")
+	_, err = rollbackImage("myapp", "v1")
+	c.Assert(err, check.ErrorMatches, `invalid version: "v1"`)
+}
+
+func (s *S) TestListRollbackImagesNoImages(c *check.C) {
+	imgs, err := ListRollbackImages("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(imgs, check.HasLen, 0)
+}
+
+func (s *S) TestRollbackImage(c *check.C) {
+	for _, img := range []string{"tsuru/app-myapp:v1", "tsuru/app-myapp:v11"} {
+		err := image.AppendAppImageName("myapp", img)
+		c.Assert(err, check.IsNil)
+	}
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"v1", "tsuru/app-myapp:v1"},
+		{"1", "tsuru/app-myapp:v1"},
+		{"v11", "tsuru/app-myapp:v11"},
+		{"11", "tsuru/app-myapp:v11"},
+		{"registry.somewhere/tsuru/app-myapp:v9", "registry.somewhere/tsuru/app-myapp:v9"},
+	}
+	for _, tt := range tests {
+		img, err := rollbackImage("myapp", tt.version)
+		c.Check(err, check.IsNil)
+		c.Check(img, check.Equals, tt.expected)
+	}
+	_, err := rollbackImage("myapp", "2")
+	c.Assert(err, check.ErrorMatches, `invalid version: "2"`)
+}
+
+func (s *S) TestDeployRollbackEndData(c *check.C) {
+	a := App{Name: "some-app", Platform: "django", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	for _, img := range []string{"tsuru/app-some-app:v1", "tsuru/app-some-app:v2"} {
+		err = image.AppendAppImageName(a.Name, img)
+		c.Assert(err, check.IsNil)
+	}
+	opts := DeployOptions{App: &a, Image: "v1", Rollback: true, OutputStream: &bytes.Buffer{}}
+	PrepareRollback(&opts)
+	c.Assert(opts.RollbackFrom, check.Equals, "tsuru/app-some-app:v2")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		CustomData: opts,
+		Allowed:    event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	opts.Event = evt
+	imgID, err := Deploy(opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(imgID, check.Equals, "tsuru/app-some-app:v1")
+	c.Assert(DeployEndData(evt, imgID, nil)["rollback"], check.DeepEquals, map[string]interface{}{
+		"from": "tsuru/app-some-app:v2",
+		"to":   "tsuru/app-some-app:v1",
+	})
+}
+
+func (s *S) TestDeployEndDataNotRollback(c *check.C) {
+	a := App{Name: "some-app"}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		CustomData: DeployOptions{App: &a, Image: "myimage"},
+		Allowed:    event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(DeployEndData(evt, "myimage", nil)["rollback"], check.IsNil)
+}
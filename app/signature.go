@@ -0,0 +1,464 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/exec"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type SignatureMode string
+
+const (
+	SignatureModeDisabled SignatureMode = "disabled"
+	SignatureModeWarn     SignatureMode = "warn"
+	SignatureModeEnforce  SignatureMode = "enforce"
+)
+
+type SignatureStatus string
+
+const (
+	SignatureVerified SignatureStatus = "verified"
+	SignatureUnsigned SignatureStatus = "unsigned"
+	SignatureInvalid  SignatureStatus = "invalid"
+)
+
+// SignatureVerification is the result of verifying the signature of the
+// artifact being deployed.
+type SignatureVerification struct {
+	Mode    SignatureMode
+	Status  SignatureStatus
+	Message string
+}
+
+type SignatureVerificationError struct {
+	App          string
+	Verification SignatureVerification
+}
+
+func (e *SignatureVerificationError) Error() string {
+	return fmt.Sprintf("deploy of app %q rejected, signature %s: %s", e.App, e.Verification.Status, e.Verification.Message)
+}
+
+var signatureExecutor exec.Executor = exec.OsExecutor{}
+
+// signatureMode returns the signature verification mode for the app. The mode
+// is looked up in deploy:signature:apps:<app>, deploy:signature:pools:<pool>
+// and deploy:signature:mode, in this order.
+func signatureMode(app *App) (SignatureMode, error) {
+	keys := []string{
+		"deploy:signature:apps:" + app.Name,
+		"deploy:signature:pools:" + app.Pool,
+		"deploy:signature:mode",
+	}
+	for _, key := range keys {
+		value, err := config.GetString(key)
+		if err != nil || value == "" {
+			continue
+		}
+		mode := SignatureMode(value)
+		switch mode {
+		case SignatureModeDisabled, SignatureModeWarn, SignatureModeEnforce:
+			return mode, nil
+		}
+		return "", errors.Errorf("invalid signature mode %q in %s, valid modes are: disabled, warn, enforce", value, key)
+	}
+	return SignatureModeDisabled, nil
+}
+
+// VerifyDeploySignature checks the signature of the image or archive being
+// deployed according to the signature mode of the app. Images are verified
+// using cosign and archives are verified against the detached GPG signature
+// in opts.Signature. It returns nil if verification is disabled for the app
+// and a *SignatureVerificationError if the mode is enforce and the artifact
+// could not be verified.
+//
+// The artifact verified is the one deployed: verified images are deployed by
+// the digest reported by cosign, instead of their tag, and archive-url
+// archives are downloaded once, with opts.File set to the downloaded copy,
+// deployed instead of downloading the archive again. Callers must close
+// opts.File after the deploy when it's not the file they set.
+func VerifyDeploySignature(opts *DeployOptions) (*SignatureVerification, error) {
+	mode, err := signatureMode(opts.App)
+	if err != nil {
+		return nil, err
+	}
+	if mode == SignatureModeDisabled {
+		return nil, nil
+	}
+	if opts.Kind == "" {
+		opts.GetKind()
+	}
+	result := SignatureVerification{Mode: mode}
+	var archive io.ReadCloser
+	switch {
+	case opts.Kind == DeployImage:
+		var pinned string
+		pinned, err = verifyImageSignature(opts.Image)
+		if err == nil {
+			opts.Image = pinned
+		}
+	case opts.Signature == "":
+		result.Status = SignatureUnsigned
+		result.Message = fmt.Sprintf("no signature provided for %s deploy", opts.Kind)
+	case opts.Kind == DeployUpload || opts.Kind == DeployUploadBuild:
+		err = verifyUploadSignature(opts)
+	case opts.Kind == DeployArchiveURL || opts.Kind == DeployGit:
+		archive, err = verifyArchiveURLSignature(opts)
+	default:
+		result.Status = SignatureUnsigned
+		result.Message = fmt.Sprintf("signature verification is not supported for %s deploy", opts.Kind)
+	}
+	if result.Status == "" {
+		if err != nil {
+			result.Status = SignatureInvalid
+			result.Message = err.Error()
+		} else {
+			result.Status = SignatureVerified
+		}
+	}
+	if opts.Event != nil {
+		if result.Status == SignatureVerified {
+			opts.Event.Logf("---- Signature verified ----")
+		} else {
+			opts.Event.Logf("---- WARNING: signature %s: %s ----", result.Status, result.Message)
+		}
+	}
+	if result.Status != SignatureVerified && mode == SignatureModeEnforce {
+		if archive != nil {
+			archive.Close()
+			opts.File, opts.FileSize = nil, 0
+		}
+		return &result, &SignatureVerificationError{App: opts.App.Name, Verification: result}
+	}
+	return &result, nil
+}
+
+// runVerifier runs the verification command, returning its standard output.
+func runVerifier(cmd string, args []string, stdin io.Reader) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	err := signatureExecutor.Execute(exec.ExecuteOptions{
+		Cmd:    cmd,
+		Args:   args,
+		Stdin:  stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		out := strings.TrimSpace(stderr.String())
+		if out == "" {
+			out = strings.TrimSpace(stdout.String())
+		}
+		if out != "" {
+			return nil, errors.Errorf("%s failed: %s", cmd, out)
+		}
+		return nil, errors.Wrapf(err, "%s failed", cmd)
+	}
+	return stdout.Bytes(), nil
+}
+
+// cosignPayload is the signed payload of an image, printed by cosign verify.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			Digest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// verifyImageSignature verifies the image with cosign, returning the image
+// pinned to the digest verified, so the deploy can't pull another image
+// pushed to the same tag after the verification.
+func verifyImageSignature(image string) (string, error) {
+	key, _ := config.GetString("deploy:signature:cosign-key")
+	if key == "" {
+		return "", errors.New("deploy:signature:cosign-key is not configured")
+	}
+	out, err := runVerifier("cosign", []string{"verify", "--key", key, image}, nil)
+	if err != nil {
+		return "", err
+	}
+	digest, err := cosignImageDigest(out)
+	if err != nil {
+		return "", err
+	}
+	return imageWithDigest(image, digest), nil
+}
+
+// cosignImageDigest returns the digest of the image in the payloads printed
+// by cosign verify, either as a JSON array or as one JSON object per line.
+func cosignImageDigest(out []byte) (string, error) {
+	var payloads []cosignPayload
+	out = bytes.TrimSpace(out)
+	if bytes.HasPrefix(out, []byte("[")) {
+		if err := json.Unmarshal(out, &payloads); err != nil {
+			return "", errors.Wrap(err, "unable to parse cosign output")
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(out))
+		for {
+			var payload cosignPayload
+			err := decoder.Decode(&payload)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", errors.Wrap(err, "unable to parse cosign output")
+			}
+			payloads = append(payloads, payload)
+		}
+	}
+	var digest string
+	for _, payload := range payloads {
+		d := payload.Critical.Image.Digest
+		if d == "" {
+			continue
+		}
+		if digest != "" && d != digest {
+			return "", errors.Errorf("cosign verified different digests: %s and %s", digest, d)
+		}
+		digest = d
+	}
+	if digest == "" {
+		return "", errors.New("cosign output has no verified image digest")
+	}
+	return digest, nil
+}
+
+// imageWithDigest replaces the tag, or digest, of the image by the digest.
+func imageWithDigest(image, digest string) string {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name + "@" + digest
+}
+
+func verifyGPGSignature(data io.Reader, signature string) error {
+	sigFile, err := ioutil.TempFile("", "tsuru-signature")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sigFile.Name())
+	_, err = sigFile.WriteString(signature)
+	sigFile.Close()
+	if err != nil {
+		return err
+	}
+	args := []string{"--batch"}
+	if keyring, _ := config.GetString("deploy:signature:gpg-keyring"); keyring != "" {
+		args = append(args, "--no-default-keyring", "--keyring", keyring)
+	}
+	args = append(args, "--verify", sigFile.Name(), "-")
+	_, err = runVerifier("gpg", args, data)
+	return err
+}
+
+func verifyUploadSignature(opts *DeployOptions) error {
+	seeker, ok := opts.File.(io.Seeker)
+	if !ok {
+		return errors.New("uploaded archive cannot be read twice")
+	}
+	err := verifyGPGSignature(opts.File, opts.Signature)
+	if _, seekErr := seeker.Seek(0, io.SeekStart); seekErr != nil {
+		return seekErr
+	}
+	return err
+}
+
+// downloadedArchive is an archive downloaded to a temporary file, removed
+// when the archive is closed.
+type downloadedArchive struct {
+	*os.File
+}
+
+func (a *downloadedArchive) Close() error {
+	err := a.File.Close()
+	os.Remove(a.File.Name())
+	return err
+}
+
+func downloadArchive(archiveURL string) (*downloadedArchive, int64, error) {
+	rsp, err := tsuruNet.Dial5Full300ClientNoKeepAlive.Get(archiveURL)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "unable to download archive")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != 200 {
+		return nil, 0, errors.Errorf("unable to download archive: unexpected status code %d", rsp.StatusCode)
+	}
+	f, err := ioutil.TempFile("", "tsuru-archive")
+	if err != nil {
+		return nil, 0, err
+	}
+	archive := &downloadedArchive{File: f}
+	size, err := io.Copy(f, rsp.Body)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		archive.Close()
+		return nil, 0, errors.Wrap(err, "unable to download archive")
+	}
+	return archive, size, nil
+}
+
+// verifyArchiveURLSignature downloads the archive in opts.ArchiveURL and
+// verifies the downloaded copy, which is set in opts.File to be deployed, so
+// the server can't send a different archive to the deploy. The copy is
+// returned so it can be removed if the deploy is rejected.
+func verifyArchiveURLSignature(opts *DeployOptions) (io.ReadCloser, error) {
+	archive, size, err := downloadArchive(opts.ArchiveURL)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyGPGSignature(archive, opts.Signature)
+	if _, seekErr := archive.Seek(0, io.SeekStart); seekErr != nil {
+		archive.Close()
+		return nil, seekErr
+	}
+	opts.File = archive
+	opts.FileSize = size
+	return archive, err
+}
+
+// DeploySignatureReportEntry describes a deploy whose artifact was not
+// successfully verified.
+type DeploySignatureReportEntry struct {
+	ID        bson.ObjectId
+	App       string
+	Timestamp time.Time
+	User      string
+	Origin    string
+	Image     string
+	Mode      SignatureMode
+	Status    SignatureStatus
+	Message   string
+}
+
+// ListUnsignedDeploys returns the deploys started after since whose
+// signature was either missing or invalid.
+func ListUnsignedDeploys(since time.Time) ([]DeploySignatureReportEntry, error) {
+	evts, err := event.List(&event.Filter{
+		Target:   event.Target{Type: event.TargetTypeApp},
+		KindName: permission.PermAppDeploy.FullName(),
+		KindType: event.KindTypePermission,
+		Since:    since,
+		Raw: bson.M{"endcustomdata.signaturestatus": bson.M{
+			"$in": []SignatureStatus{SignatureUnsigned, SignatureInvalid},
+		}},
+		Limit: -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]DeploySignatureReportEntry, len(evts))
+	for i := range evts {
+		data := eventToDeployData(&evts[i], nil, false)
+		var endData struct {
+			SignatureMode    SignatureMode
+			SignatureStatus  SignatureStatus
+			SignatureMessage string
+		}
+		err = evts[i].EndData(&endData)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = DeploySignatureReportEntry{
+			ID:        data.ID,
+			App:       data.App,
+			Timestamp: data.Timestamp,
+			User:      data.User,
+			Origin:    data.Origin,
+			Image:     data.Image,
+			Mode:      endData.SignatureMode,
+			Status:    endData.SignatureStatus,
+			Message:   endData.SignatureMessage,
+		}
+	}
+	return entries, nil
+}
+
+// DeployEndData returns the custom data stored in the deploy event once the
+// deploy finishes, including the timings of the phases recorded in it.
+func DeployEndData(evt *event.Event, imageID string, signature *SignatureVerification) map[string]interface{} {
+	data := map[string]interface{}{"image": imageID}
+	if signature != nil {
+		data["signaturemode"] = string(signature.Mode)
+		data["signaturestatus"] = string(signature.Status)
+		data["signaturemessage"] = signature.Message
+	}
+	if timings := deployTimings(evt); len(timings) > 0 {
+		data["timings"] = timings
+	}
+	if pause := deployPauseData(evt); pause != nil {
+		data["crashloop"] = pause
+	}
+	if rollback := deployRollbackData(evt, imageID); rollback != nil {
+		data["rollback"] = rollback
+	}
+	return data
+}
+
+func init() {
+	event.SetCustomDataSchema(event.CustomDataSchema{
+		TargetType: event.TargetTypeApp,
+		KindName:   permission.PermAppDeploy.FullName(),
+		EndCustomData: &event.Schema{
+			Type: event.SchemaTypeObject,
+			Properties: map[string]*event.Schema{
+				"image": {Type: event.SchemaTypeString, Description: "Image generated by the deploy."},
+				"signaturemode": {
+					Type: event.SchemaTypeString,
+					Enum: []interface{}{SignatureModeDisabled, SignatureModeWarn, SignatureModeEnforce},
+				},
+				"signaturestatus": {
+					Type: event.SchemaTypeString,
+					Enum: []interface{}{SignatureVerified, SignatureUnsigned, SignatureInvalid},
+				},
+				"signaturemessage": {Type: event.SchemaTypeString},
+				"timings": {
+					Type:        event.SchemaTypeObject,
+					Description: "Duration, in seconds, of each deploy phase.",
+				},
+				"crashloop": {
+					Type:        event.SchemaTypeObject,
+					Description: "Crash loop detected after the deploy, which paused deploys of the app.",
+					Properties: map[string]*event.Schema{
+						"reason":        {Type: event.SchemaTypeString},
+						"restarts":      {Type: event.SchemaTypeObject, Description: "Number of crashes of each unit."},
+						"rollbackimage": {Type: event.SchemaTypeString},
+					},
+				},
+				"rollback": {
+					Type:        event.SchemaTypeObject,
+					Description: "Images replaced and deployed by a rollback.",
+					Properties: map[string]*event.Schema{
+						"from": {Type: event.SchemaTypeString},
+						"to":   {Type: event.SchemaTypeString},
+					},
+				},
+			},
+			Required: []string{"image"},
+		},
+	})
+}
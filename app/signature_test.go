@@ -0,0 +1,289 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/exec"
+	"github.com/tsuru/tsuru/exec/exectest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+const cosignOutput = `[{"critical":{"identity":{"docker-reference":"registry/myimg"},` +
+	`"image":{"docker-manifest-digest":"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},` +
+	`"type":"cosign container image signature"},"optional":null}]`
+
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error { return nil }
+
+func (s *S) setSignatureExecutor(e exec.Executor) func() {
+	old := signatureExecutor
+	signatureExecutor = e
+	return func() { signatureExecutor = old }
+}
+
+func (s *S) TestSignatureMode(c *check.C) {
+	defer config.Unset("deploy:signature")
+	a := &App{Name: "myapp", Pool: "pool1"}
+	mode, err := signatureMode(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(mode, check.Equals, SignatureModeDisabled)
+	config.Set("deploy:signature:mode", "warn")
+	mode, err = signatureMode(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(mode, check.Equals, SignatureModeWarn)
+	config.Set("deploy:signature:pools:pool1", "enforce")
+	mode, err = signatureMode(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(mode, check.Equals, SignatureModeEnforce)
+	config.Set("deploy:signature:apps:myapp", "disabled")
+	mode, err = signatureMode(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(mode, check.Equals, SignatureModeDisabled)
+	config.Set("deploy:signature:apps:myapp", "sometimes")
+	_, err = signatureMode(a)
+	c.Assert(err, check.ErrorMatches, `invalid signature mode "sometimes" in deploy:signature:apps:myapp.*`)
+}
+
+func (s *S) TestVerifyDeploySignatureDisabled(c *check.C) {
+	result, err := VerifyDeploySignature(&DeployOptions{App: &App{Name: "myapp"}, Image: "myimg"})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.IsNil)
+}
+
+func (s *S) TestVerifyDeploySignatureImage(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	config.Set("deploy:signature:cosign-key", "/etc/tsuru/cosign.pub")
+	defer config.Unset("deploy:signature")
+	executor := &exectest.FakeExecutor{
+		Output: map[string][][]byte{
+			"verify --key /etc/tsuru/cosign.pub registry/myimg:v1": {[]byte(cosignOutput)},
+		},
+	}
+	defer s.setSignatureExecutor(executor)()
+	opts := DeployOptions{App: &App{Name: "myapp"}, Image: "registry/myimg:v1"}
+	result, err := VerifyDeploySignature(&opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(*result, check.DeepEquals, SignatureVerification{Mode: SignatureModeEnforce, Status: SignatureVerified})
+	c.Assert(executor.ExecutedCmd("cosign", []string{"verify", "--key", "/etc/tsuru/cosign.pub", "registry/myimg:v1"}), check.Equals, true)
+	c.Assert(opts.Image, check.Equals, "registry/myimg@sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+}
+
+func (s *S) TestVerifyDeploySignatureImageWithoutDigest(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	config.Set("deploy:signature:cosign-key", "/etc/tsuru/cosign.pub")
+	defer config.Unset("deploy:signature")
+	executor := &exectest.FakeExecutor{}
+	defer s.setSignatureExecutor(executor)()
+	opts := DeployOptions{App: &App{Name: "myapp"}, Image: "registry/myimg:v1"}
+	result, err := VerifyDeploySignature(&opts)
+	c.Assert(err, check.FitsTypeOf, &SignatureVerificationError{})
+	c.Assert(result.Status, check.Equals, SignatureInvalid)
+	c.Assert(result.Message, check.Equals, "cosign output has no verified image digest")
+	c.Assert(opts.Image, check.Equals, "registry/myimg:v1")
+}
+
+func (s *S) TestCosignImageDigest(c *check.C) {
+	digest, err := cosignImageDigest([]byte(cosignOutput))
+	c.Assert(err, check.IsNil)
+	c.Assert(digest, check.Equals, "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+	digest, err = cosignImageDigest([]byte(`{"critical":{"image":{"docker-manifest-digest":"sha256:abc"}}}` + "\n"))
+	c.Assert(err, check.IsNil)
+	c.Assert(digest, check.Equals, "sha256:abc")
+	_, err = cosignImageDigest([]byte(`[{"critical":{"image":{"docker-manifest-digest":"sha256:abc"}}},` +
+		`{"critical":{"image":{"docker-manifest-digest":"sha256:def"}}}]`))
+	c.Assert(err, check.ErrorMatches, "cosign verified different digests: sha256:abc and sha256:def")
+}
+
+func (s *S) TestImageWithDigest(c *check.C) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"myimg", "myimg@sha256:abc"},
+		{"myimg:v1", "myimg@sha256:abc"},
+		{"registry:5000/ns/myimg", "registry:5000/ns/myimg@sha256:abc"},
+		{"registry:5000/ns/myimg:v1", "registry:5000/ns/myimg@sha256:abc"},
+		{"registry/myimg@sha256:old", "registry/myimg@sha256:abc"},
+	}
+	for _, tt := range tests {
+		c.Check(imageWithDigest(tt.image, "sha256:abc"), check.Equals, tt.expected, check.Commentf("image: %s", tt.image))
+	}
+}
+
+func (s *S) TestVerifyDeploySignatureImageInvalidEnforce(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	config.Set("deploy:signature:cosign-key", "/etc/tsuru/cosign.pub")
+	defer config.Unset("deploy:signature")
+	executor := &exectest.ErrorExecutor{
+		FakeExecutor: exectest.FakeExecutor{Output: map[string][][]byte{"*": {[]byte("no matching signatures")}}},
+	}
+	defer s.setSignatureExecutor(executor)()
+	result, err := VerifyDeploySignature(&DeployOptions{App: &App{Name: "myapp"}, Image: "registry/myimg:v1"})
+	c.Assert(err, check.FitsTypeOf, &SignatureVerificationError{})
+	c.Assert(err, check.ErrorMatches, `deploy of app "myapp" rejected, signature invalid: cosign failed: no matching signatures`)
+	c.Assert(result.Status, check.Equals, SignatureInvalid)
+}
+
+func (s *S) TestVerifyDeploySignatureUnsignedWarn(c *check.C) {
+	config.Set("deploy:signature:mode", "warn")
+	defer config.Unset("deploy:signature")
+	executor := &exectest.FakeExecutor{}
+	defer s.setSignatureExecutor(executor)()
+	file := readSeekNopCloser{bytes.NewReader([]byte("my archive"))}
+	result, err := VerifyDeploySignature(&DeployOptions{App: &App{Name: "myapp"}, File: file})
+	c.Assert(err, check.IsNil)
+	c.Assert(*result, check.DeepEquals, SignatureVerification{
+		Mode:    SignatureModeWarn,
+		Status:  SignatureUnsigned,
+		Message: "no signature provided for upload deploy",
+	})
+	c.Assert(executor.GetCommands("gpg"), check.HasLen, 0)
+}
+
+func (s *S) TestVerifyDeploySignatureUpload(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	config.Set("deploy:signature:gpg-keyring", "/etc/tsuru/trusted.gpg")
+	defer config.Unset("deploy:signature")
+	executor := &exectest.FakeExecutor{}
+	defer s.setSignatureExecutor(executor)()
+	file := readSeekNopCloser{bytes.NewReader([]byte("my archive"))}
+	opts := DeployOptions{App: &App{Name: "myapp"}, File: file, Signature: "my signature"}
+	result, err := VerifyDeploySignature(&opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Status, check.Equals, SignatureVerified)
+	cmds := executor.GetCommands("gpg")
+	c.Assert(cmds, check.HasLen, 1)
+	args := cmds[0].GetArgs()
+	c.Assert(args, check.HasLen, 7)
+	c.Assert(args[:5], check.DeepEquals, []string{"--batch", "--no-default-keyring", "--keyring", "/etc/tsuru/trusted.gpg", "--verify"})
+	c.Assert(args[6], check.Equals, "-")
+	data, err := ioutil.ReadAll(opts.File)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "my archive")
+}
+
+func (s *S) TestVerifyDeploySignatureArchiveURLDeploysVerifiedCopy(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	defer config.Unset("deploy:signature")
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, "archive %d", requests)
+	}))
+	defer server.Close()
+	executor := &stdinRecordingExecutor{}
+	defer s.setSignatureExecutor(executor)()
+	opts := DeployOptions{App: &App{Name: "myapp"}, ArchiveURL: server.URL, Signature: "my signature"}
+	result, err := VerifyDeploySignature(&opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Status, check.Equals, SignatureVerified)
+	c.Assert(executor.stdin, check.Equals, "archive 1")
+	c.Assert(opts.Kind, check.Equals, DeployArchiveURL)
+	c.Assert(opts.File, check.NotNil)
+	c.Assert(opts.FileSize, check.Equals, int64(len("archive 1")))
+	data, err := ioutil.ReadAll(opts.File)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "archive 1")
+	c.Assert(requests, check.Equals, 1)
+	name := opts.File.(*downloadedArchive).Name()
+	err = opts.File.Close()
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(name)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *S) TestVerifyDeploySignatureArchiveURLInvalidEnforce(c *check.C) {
+	config.Set("deploy:signature:mode", "enforce")
+	defer config.Unset("deploy:signature")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("my archive"))
+	}))
+	defer server.Close()
+	executor := &exectest.ErrorExecutor{
+		FakeExecutor: exectest.FakeExecutor{Output: map[string][][]byte{"*": {[]byte("BAD signature")}}},
+	}
+	defer s.setSignatureExecutor(executor)()
+	opts := DeployOptions{App: &App{Name: "myapp"}, ArchiveURL: server.URL, Signature: "my signature"}
+	result, err := VerifyDeploySignature(&opts)
+	c.Assert(err, check.FitsTypeOf, &SignatureVerificationError{})
+	c.Assert(result.Status, check.Equals, SignatureInvalid)
+	c.Assert(opts.File, check.IsNil)
+}
+
+// stdinRecordingExecutor records the input given to the command executed.
+type stdinRecordingExecutor struct {
+	stdin string
+}
+
+func (e *stdinRecordingExecutor) Execute(opts exec.ExecuteOptions) error {
+	if opts.Stdin != nil {
+		data, err := ioutil.ReadAll(opts.Stdin)
+		if err != nil {
+			return err
+		}
+		e.stdin = string(data)
+	}
+	return nil
+}
+
+func (s *S) TestDeployEndData(c *check.C) {
+	c.Assert(DeployEndData(nil, "img:v1", nil), check.DeepEquals, map[string]interface{}{"image": "img:v1"})
+	c.Assert(DeployEndData(nil, "img:v1", &SignatureVerification{
+		Mode:    SignatureModeWarn,
+		Status:  SignatureInvalid,
+		Message: "bad signature",
+	}), check.DeepEquals, map[string]interface{}{
+		"image":            "img:v1",
+		"signaturemode":    "warn",
+		"signaturestatus":  "invalid",
+		"signaturemessage": "bad signature",
+	})
+}
+
+func (s *S) TestListUnsignedDeploys(c *check.C) {
+	results := []*SignatureVerification{
+		nil,
+		{Mode: SignatureModeWarn, Status: SignatureVerified},
+		{Mode: SignatureModeWarn, Status: SignatureUnsigned, Message: "no signature provided for upload deploy"},
+	}
+	var evts []*event.Event
+	for _, result := range results {
+		evt, err := event.New(&event.Opts{
+			Target:     event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+			Kind:       permission.PermAppDeploy,
+			RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: "me@me.com"},
+			Allowed:    event.Allowed(permission.PermApp),
+			CustomData: DeployOptions{Origin: "drag-and-drop"},
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.DoneCustomData(nil, DeployEndData(evt, "img:v1", result))
+		c.Assert(err, check.IsNil)
+		evts = append(evts, evt)
+	}
+	entries, err := ListUnsignedDeploys(time.Now().Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].ID, check.Equals, evts[2].UniqueID)
+	c.Assert(entries[0].App, check.Equals, "myapp")
+	c.Assert(entries[0].Origin, check.Equals, "drag-and-drop")
+	c.Assert(entries[0].Image, check.Equals, "img:v1")
+	c.Assert(entries[0].Status, check.Equals, SignatureUnsigned)
+	c.Assert(entries[0].Message, check.Equals, "no signature provided for upload deploy")
+}
@@ -89,6 +89,9 @@ func (s *S) SetUpSuite(c *check.C) {
 	config.Set("queue:mongo-polling-interval", 0.01)
 	config.Set("docker:registry", "registry.somewhere")
 	config.Set("routers:fake-tls:type", "fake-tls")
+	config.Set("routers:fake-ratelimit:type", "fake-ratelimit")
+	config.Set("routers:fake-maintenance:type", "fake-maintenance")
+	config.Set("routers:fake-weighted:type", "fake-weighted")
 	s.conn, err = db.Conn()
 	c.Assert(err, check.IsNil)
 	s.logConn, err = db.LogConn()
@@ -121,6 +124,9 @@ func (s *S) SetUpTest(c *check.C) {
 	routertest.FakeRouter.Reset()
 	routertest.HCRouter.Reset()
 	routertest.TLSRouter.Reset()
+	routertest.RateLimitRouter.Reset()
+	routertest.MaintenanceRouter.Reset()
+	routertest.WeightedRouter.Reset()
 	err := rebuild.RegisterTask(func(appName string) (rebuild.RebuildApp, error) {
 		a, err := GetByName(appName)
 		if err == ErrAppNotFound {
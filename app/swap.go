@@ -0,0 +1,409 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	SwapStatusRunning = "running"
+	SwapStatusPaused  = "paused"
+	SwapStatusDone    = "done"
+	SwapStatusAborted = "aborted"
+	SwapStatusFailed  = "failed"
+
+	defaultSwapInterval = time.Minute
+)
+
+var (
+	ErrSwapNotFound            = errors.New("swap not found")
+	ErrSwapInProgress          = errors.New("app has a swap in progress")
+	ErrGradualSwapNotSupported = errors.New("router does not support shifting the traffic gradually")
+)
+
+// GradualSwapOptions are the options of a gradual swap. Step is the
+// percentage of the traffic shifted at a time and Interval the time between
+// the shifts, defaulting to one minute.
+type GradualSwapOptions struct {
+	CNameOnly bool
+	Step      int
+	Interval  time.Duration
+	Owner     string
+}
+
+// GradualSwap shifts the traffic sent to the backend of App1 to the units of
+// App2 in increments of Step percent, swapping the apps like Swap does once
+// all the traffic is sent to the units of App2.
+type GradualSwap struct {
+	ID        bson.ObjectId `bson:"_id" json:"id"`
+	App1      string        `json:"app1"`
+	App2      string        `json:"app2"`
+	CNameOnly bool          `json:"cnameOnly"`
+	Step      int           `json:"step"`
+	Interval  time.Duration `json:"interval"`
+	// Weight is the percentage of the traffic of App1 currently sent to
+	// the units of App2.
+	Weight int `json:"weight"`
+	// Routes holds the routes of App2 added to the backend of App1, removed
+	// when the swap ends.
+	Routes       []string  `json:"routes"`
+	Status       string    `json:"status"`
+	Owner        string    `json:"owner"`
+	Error        string    `bson:",omitempty" json:"error,omitempty"`
+	StartTime    time.Time `json:"startTime"`
+	UpdateTime   time.Time `json:"updateTime"`
+	NextStepTime time.Time `json:"nextStepTime"`
+}
+
+// Active returns whether the swap is still running or paused.
+func (s *GradualSwap) Active() bool {
+	return s.Status == SwapStatusRunning || s.Status == SwapStatusPaused
+}
+
+// StartGradualSwap starts shifting the traffic of app1 to the units of app2,
+// shifting the first step right away. Both apps must use the same router,
+// able to split the traffic of a backend between its routes.
+func StartGradualSwap(app1, app2 *App, opts GradualSwapOptions) (*GradualSwap, error) {
+	if opts.Step < 1 || opts.Step > 99 {
+		return nil, &tsuruErrors.ValidationError{Message: "step must be between 1 and 99 percent"}
+	}
+	if opts.Interval < 0 {
+		return nil, &tsuruErrors.ValidationError{Message: "interval must not be negative"}
+	}
+	if opts.Interval == 0 {
+		opts.Interval = defaultSwapInterval
+	}
+	if app1.Router != app2.Router {
+		return nil, &tsuruErrors.ValidationError{Message: "gradual swaps require apps using the same router"}
+	}
+	_, _, err := swapRouter(app1)
+	if err != nil {
+		return nil, err
+	}
+	inProgress, err := GradualSwapInProgress(app1.Name, app2.Name)
+	if err != nil {
+		return nil, err
+	}
+	if inProgress {
+		return nil, ErrSwapInProgress
+	}
+	now := time.Now().UTC()
+	s := GradualSwap{
+		ID:           bson.NewObjectId(),
+		App1:         app1.Name,
+		App2:         app2.Name,
+		CNameOnly:    opts.CNameOnly,
+		Step:         opts.Step,
+		Interval:     opts.Interval,
+		Status:       SwapStatusRunning,
+		Owner:        opts.Owner,
+		StartTime:    now,
+		UpdateTime:   now,
+		NextStepTime: now,
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.AppSwaps().Insert(s)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Advance(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GradualSwapInProgress returns whether any of the apps has a running or
+// paused gradual swap.
+func GradualSwapInProgress(appNames ...string) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	n, err := conn.AppSwaps().Find(bson.M{
+		"status": bson.M{"$in": []string{SwapStatusRunning, SwapStatusPaused}},
+		"$or": []bson.M{
+			{"app1": bson.M{"$in": appNames}},
+			{"app2": bson.M{"$in": appNames}},
+		},
+	}).Count()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetGradualSwap returns the gradual swap with the given ID.
+func GetGradualSwap(id string) (*GradualSwap, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrSwapNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var s GradualSwap
+	err = conn.AppSwaps().FindId(bson.ObjectIdHex(id)).One(&s)
+	if err == mgo.ErrNotFound {
+		return nil, ErrSwapNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListGradualSwaps returns the running and paused gradual swaps.
+func ListGradualSwaps() ([]GradualSwap, error) {
+	return listGradualSwaps(bson.M{"status": bson.M{"$in": []string{SwapStatusRunning, SwapStatusPaused}}})
+}
+
+// ListDueGradualSwaps returns the running gradual swaps whose next step is
+// due at now.
+func ListDueGradualSwaps(now time.Time) ([]GradualSwap, error) {
+	return listGradualSwaps(bson.M{"status": SwapStatusRunning, "nextsteptime": bson.M{"$lte": now}})
+}
+
+func listGradualSwaps(query bson.M) ([]GradualSwap, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var swaps []GradualSwap
+	err = conn.AppSwaps().Find(query).Sort("starttime").All(&swaps)
+	if err != nil {
+		return nil, err
+	}
+	return swaps, nil
+}
+
+// Advance shifts one more step of the traffic to the units of App2 or, when
+// all the traffic was already shifted, swaps the apps, ending the swap. The
+// backend of App1 keeps its own routes while the traffic is shifted, so the
+// traffic shifted back when the swap fails or is aborted still reaches the
+// units of App1.
+func (s *GradualSwap) Advance(w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if s.Status != SwapStatusRunning {
+		return &tsuruErrors.ConflictError{Message: fmt.Sprintf("swap is %s", s.Status)}
+	}
+	app1, app2, err := s.apps()
+	if err != nil {
+		return s.fail(err)
+	}
+	r, wRouter, err := swapRouter(app1)
+	if err != nil {
+		return s.fail(err)
+	}
+	if s.Weight >= 100 {
+		fmt.Fprintf(w, "All the traffic of app %q shifted, swapping apps %q and %q.\n", s.App1, s.App1, s.App2)
+		err = s.resetRoutes(app1, r, wRouter)
+		if err == nil {
+			err = Swap(app1, app2, s.CNameOnly)
+		}
+		if err != nil {
+			return s.fail(err)
+		}
+		return s.update(bson.M{"status": SwapStatusDone, "routes": s.Routes})
+	}
+	routes, err := r.Routes(app2.Name)
+	if err != nil {
+		return s.fail(err)
+	}
+	if len(routes) == 0 {
+		return s.fail(errors.Errorf("app %q has no routes to shift the traffic to", s.App2))
+	}
+	weight := s.Weight + s.Step
+	if weight > 100 {
+		weight = 100
+	}
+	fmt.Fprintf(w, "Shifting %d%% of the traffic of app %q to the units of app %q.\n", weight, s.App1, s.App2)
+	s.Routes = mergeSwapRoutes(s.Routes, routes)
+	err = r.AddRoutes(app1.Name, routes)
+	if err == nil {
+		err = wRouter.SetRoutesWeight(app1.Name, routes, weight)
+	}
+	if err != nil {
+		return s.fail(err)
+	}
+	return s.update(bson.M{
+		"weight":       weight,
+		"routes":       s.Routes,
+		"nextsteptime": time.Now().UTC().Add(s.Interval),
+	})
+}
+
+// Pause stops shifting the traffic, keeping the traffic already shifted to
+// the units of App2 until the swap is resumed or aborted.
+func (s *GradualSwap) Pause() error {
+	return s.changeStatus(SwapStatusRunning, bson.M{"status": SwapStatusPaused})
+}
+
+// Resume continues shifting the traffic of a paused swap, starting with the
+// next step right away.
+func (s *GradualSwap) Resume() error {
+	return s.changeStatus(SwapStatusPaused, bson.M{
+		"status":       SwapStatusRunning,
+		"nextsteptime": time.Now().UTC(),
+	})
+}
+
+// Abort ends the swap without swapping the apps, shifting all the traffic
+// back to the units of App1.
+func (s *GradualSwap) Abort() error {
+	if !s.Active() {
+		return &tsuruErrors.ConflictError{Message: fmt.Sprintf("swap is %s", s.Status)}
+	}
+	app1, err := GetByName(s.App1)
+	if err != nil {
+		return err
+	}
+	r, wRouter, err := swapRouter(app1)
+	if err != nil {
+		return err
+	}
+	err = s.resetRoutes(app1, r, wRouter)
+	if err != nil {
+		return err
+	}
+	return s.changeStatus(s.Status, bson.M{"status": SwapStatusAborted, "routes": s.Routes})
+}
+
+func (s *GradualSwap) changeStatus(from string, changes bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	changes["updatetime"] = time.Now().UTC()
+	_, err = conn.AppSwaps().Find(bson.M{"_id": s.ID, "status": from}).Apply(mgo.Change{
+		Update:    bson.M{"$set": changes},
+		ReturnNew: true,
+	}, s)
+	if err == mgo.ErrNotFound {
+		current, getErr := GetGradualSwap(s.ID.Hex())
+		if getErr != nil {
+			return getErr
+		}
+		return &tsuruErrors.ConflictError{Message: fmt.Sprintf("swap is %s", current.Status)}
+	}
+	return err
+}
+
+func (s *GradualSwap) update(changes bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	changes["updatetime"] = time.Now().UTC()
+	_, err = conn.AppSwaps().FindId(s.ID).Apply(mgo.Change{
+		Update:    bson.M{"$set": changes},
+		ReturnNew: true,
+	}, s)
+	return err
+}
+
+// fail ends the swap with err, shifting the traffic back to the units of
+// App1 when possible.
+func (s *GradualSwap) fail(err error) error {
+	if app1, getErr := GetByName(s.App1); getErr == nil {
+		if r, wRouter, routerErr := swapRouter(app1); routerErr == nil {
+			if resetErr := s.resetRoutes(app1, r, wRouter); resetErr != nil {
+				log.Errorf("[swap] unable to shift the traffic of app %q back: %s", s.App1, resetErr)
+			}
+		}
+	}
+	if updateErr := s.update(bson.M{"status": SwapStatusFailed, "error": err.Error(), "routes": s.Routes}); updateErr != nil {
+		log.Errorf("[swap] unable to store failure of swap %q: %s", s.ID.Hex(), updateErr)
+	}
+	return err
+}
+
+// resetRoutes removes the weights of the routes of App1 and the routes of
+// App2 added to its backend.
+func (s *GradualSwap) resetRoutes(app1 *App, r router.Router, wRouter router.WeightedRouter) error {
+	err := wRouter.SetRoutesWeight(app1.Name, nil, 0)
+	if err != nil {
+		return err
+	}
+	routes := make([]*url.URL, 0, len(s.Routes))
+	for _, route := range s.Routes {
+		u, parseErr := url.Parse(route)
+		if parseErr != nil {
+			continue
+		}
+		routes = append(routes, u)
+	}
+	if len(routes) > 0 {
+		err = r.RemoveRoutes(app1.Name, routes)
+		if err != nil {
+			return err
+		}
+	}
+	s.Routes = nil
+	return nil
+}
+
+func (s *GradualSwap) apps() (*App, *App, error) {
+	app1, err := GetByName(s.App1)
+	if err != nil {
+		return nil, nil, err
+	}
+	app2, err := GetByName(s.App2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app1, app2, nil
+}
+
+func mergeSwapRoutes(current []string, routes []*url.URL) []string {
+	result := append([]string{}, current...)
+	seen := make(map[string]bool, len(current))
+	for _, route := range current {
+		seen[route] = true
+	}
+	for _, route := range routes {
+		if !seen[route.String()] {
+			seen[route.String()] = true
+			result = append(result, route.String())
+		}
+	}
+	return result
+}
+
+func swapRouter(a *App) (router.Router, router.WeightedRouter, error) {
+	r, err := a.GetRouter()
+	if err != nil {
+		return nil, nil, err
+	}
+	wRouter, ok := r.(router.WeightedRouter)
+	if !ok {
+		return nil, nil, ErrGradualSwapNotSupported
+	}
+	return r, wRouter, nil
+}
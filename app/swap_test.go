@@ -0,0 +1,134 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createGradualSwapApps(c *check.C) (*App, *App) {
+	app1 := &App{Name: "app1", CName: []string{"cname"}, TeamOwner: s.team.Name, Router: "fake-weighted"}
+	err := CreateApp(app1, s.user)
+	c.Assert(err, check.IsNil)
+	app2 := &App{Name: "app2", TeamOwner: s.team.Name, Router: "fake-weighted"}
+	err = CreateApp(app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = routertest.WeightedRouter.AddRoutes("app1", []*url.URL{{Scheme: "http", Host: "10.0.0.1:8080"}})
+	c.Assert(err, check.IsNil)
+	err = routertest.WeightedRouter.AddRoutes("app2", []*url.URL{{Scheme: "http", Host: "10.0.0.2:8080"}})
+	c.Assert(err, check.IsNil)
+	return app1, app2
+}
+
+func (s *S) TestStartGradualSwap(c *check.C) {
+	app1, app2 := s.createGradualSwapApps(c)
+	swap, err := StartGradualSwap(app1, app2, GradualSwapOptions{Step: 40, Owner: s.user.Email})
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.Status, check.Equals, SwapStatusRunning)
+	c.Assert(swap.Weight, check.Equals, 40)
+	c.Assert(swap.Interval, check.Equals, defaultSwapInterval)
+	c.Assert(swap.Routes, check.DeepEquals, []string{"http://10.0.0.2:8080"})
+	c.Assert(routertest.WeightedRouter.HasRoute("app1", "10.0.0.1:8080"), check.Equals, true)
+	c.Assert(routertest.WeightedRouter.HasRoute("app1", "10.0.0.2:8080"), check.Equals, true)
+	c.Assert(routertest.WeightedRouter.Weights["app1"], check.DeepEquals, map[string]int{"10.0.0.2:8080": 40})
+	dbSwap, err := GetGradualSwap(swap.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSwap.Weight, check.Equals, 40)
+	_, err = StartGradualSwap(app2, app1, GradualSwapOptions{Step: 10})
+	c.Assert(err, check.Equals, ErrSwapInProgress)
+}
+
+func (s *S) TestStartGradualSwapInvalid(c *check.C) {
+	app1, app2 := s.createGradualSwapApps(c)
+	_, err := StartGradualSwap(app1, app2, GradualSwapOptions{Step: 0})
+	c.Assert(err, check.ErrorMatches, "step must be between 1 and 99 percent")
+	_, err = StartGradualSwap(app1, app2, GradualSwapOptions{Step: 100})
+	c.Assert(err, check.ErrorMatches, "step must be between 1 and 99 percent")
+	_, err = StartGradualSwap(app1, app2, GradualSwapOptions{Step: 10, Interval: -time.Second})
+	c.Assert(err, check.ErrorMatches, "interval must not be negative")
+	other := &App{Name: "other", TeamOwner: s.team.Name}
+	err = CreateApp(other, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = StartGradualSwap(app1, other, GradualSwapOptions{Step: 10})
+	c.Assert(err, check.ErrorMatches, "gradual swaps require apps using the same router")
+	app3 := &App{Name: "app3", TeamOwner: s.team.Name}
+	err = CreateApp(app3, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = StartGradualSwap(other, app3, GradualSwapOptions{Step: 10})
+	c.Assert(err, check.Equals, ErrGradualSwapNotSupported)
+}
+
+func (s *S) TestGradualSwapAdvanceUntilSwap(c *check.C) {
+	app1, app2 := s.createGradualSwapApps(c)
+	swap, err := StartGradualSwap(app1, app2, GradualSwapOptions{Step: 60})
+	c.Assert(err, check.IsNil)
+	err = swap.Advance(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.Weight, check.Equals, 100)
+	c.Assert(swap.Status, check.Equals, SwapStatusRunning)
+	c.Assert(routertest.WeightedRouter.History["app1"], check.DeepEquals, []int{60, 100})
+	err = swap.Advance(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.Status, check.Equals, SwapStatusDone)
+	c.Assert(swap.Routes, check.HasLen, 0)
+	c.Assert(routertest.WeightedRouter.History["app1"], check.DeepEquals, []int{60, 100, -1})
+	dbApp1, err := GetByName("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp1.CName, check.HasLen, 0)
+	dbApp2, err := GetByName("app2")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp2.CName, check.DeepEquals, []string{"cname"})
+	inProgress, err := GradualSwapInProgress("app1", "app2")
+	c.Assert(err, check.IsNil)
+	c.Assert(inProgress, check.Equals, false)
+}
+
+func (s *S) TestGradualSwapPauseResume(c *check.C) {
+	app1, app2 := s.createGradualSwapApps(c)
+	swap, err := StartGradualSwap(app1, app2, GradualSwapOptions{Step: 10, Interval: time.Hour})
+	c.Assert(err, check.IsNil)
+	due, err := ListDueGradualSwaps(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(due, check.HasLen, 0)
+	err = swap.Pause()
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.Status, check.Equals, SwapStatusPaused)
+	err = swap.Pause()
+	c.Assert(err, check.ErrorMatches, "swap is paused")
+	err = swap.Advance(nil)
+	c.Assert(err, check.ErrorMatches, "swap is paused")
+	c.Assert(routertest.WeightedRouter.Weights["app1"], check.DeepEquals, map[string]int{"10.0.0.2:8080": 10})
+	swaps, err := ListGradualSwaps()
+	c.Assert(err, check.IsNil)
+	c.Assert(swaps, check.HasLen, 1)
+	err = swap.Resume()
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.Status, check.Equals, SwapStatusRunning)
+	due, err = ListDueGradualSwaps(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(due, check.HasLen, 1)
+	c.Assert(due[0].ID, check.Equals, swap.ID)
+}
+
+func (s *S) TestGradualSwapAbort(c *check.C) {
+	app1, app2 := s.createGradualSwapApps(c)
+	swap, err := StartGradualSwap(app1, app2, GradualSwapOptions{Step: 30})
+	c.Assert(err, check.IsNil)
+	err = swap.Abort()
+	c.Assert(err, check.IsNil)
+	c.Assert(swap.Status, check.Equals, SwapStatusAborted)
+	c.Assert(routertest.WeightedRouter.Weights, check.HasLen, 0)
+	c.Assert(routertest.WeightedRouter.HasRoute("app1", "10.0.0.1:8080"), check.Equals, true)
+	c.Assert(routertest.WeightedRouter.HasRoute("app1", "10.0.0.2:8080"), check.Equals, false)
+	dbApp1, err := GetByName("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp1.CName, check.DeepEquals, []string{"cname"})
+	err = swap.Abort()
+	c.Assert(err, check.ErrorMatches, "swap is aborted")
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	TokenKindSession  = "session"
+	TokenKindAPIKey   = "api-key"
+	TokenKindAPIToken = "api-token"
+)
+
+var (
+	// activityInterval is the minimum interval between updates of the token
+	// usage of a user recorded by an API instance.
+	activityInterval = time.Minute
+
+	recentUsage   = map[string]time.Time{}
+	recentUsageMu sync.Mutex
+)
+
+// Activity holds when a user last logged in and last used each kind of
+// token, either TokenKindSession, TokenKindAPIKey or TokenKindAPIToken.
+type Activity struct {
+	Email      string               `bson:"_id"`
+	LastLogin  time.Time            `bson:",omitempty"`
+	TokenUsage map[string]time.Time `bson:",omitempty"`
+}
+
+// LastActivity returns the most recent of the last login and token usages.
+func (a *Activity) LastActivity() time.Time {
+	last := a.LastLogin
+	for _, t := range a.TokenUsage {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// InactiveUser is a user with no activity since the time asked in
+// InactiveUsers. LastActivity is zero for users with no recorded activity.
+type InactiveUser struct {
+	Email        string
+	LastActivity time.Time `json:",omitempty"`
+}
+
+// RecordLogin stores the current time as the last login of the user.
+func RecordLogin(email string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.UserActivity().UpsertId(email, bson.M{"$set": bson.M{"lastlogin": time.Now().UTC()}})
+	return err
+}
+
+// RecordTokenUsage stores the current time as the last usage of the given
+// kind of token by the user. Usages are recorded at most once per minute by
+// each API instance.
+func RecordTokenUsage(email, kind string) error {
+	now := time.Now().UTC()
+	key := email + "\x00" + kind
+	recentUsageMu.Lock()
+	if now.Sub(recentUsage[key]) < activityInterval {
+		recentUsageMu.Unlock()
+		return nil
+	}
+	recentUsage[key] = now
+	recentUsageMu.Unlock()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.UserActivity().UpsertId(email, bson.M{"$set": bson.M{"tokenusage." + kind: now}})
+	return err
+}
+
+// GetActivity returns the recorded activity of the user, which is empty when
+// the user never logged in or used a token.
+func GetActivity(email string) (*Activity, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	activity := Activity{Email: email}
+	err = conn.UserActivity().FindId(email).One(&activity)
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	return &activity, nil
+}
+
+// InactiveUsers returns the users that didn't log in nor used any token since
+// the given time, sorted by their last activity, the ones with no recorded
+// activity first.
+func InactiveUsers(since time.Time) ([]InactiveUser, error) {
+	users, err := ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var activities []Activity
+	err = conn.UserActivity().Find(nil).All(&activities)
+	if err != nil {
+		return nil, err
+	}
+	lastActivity := make(map[string]time.Time, len(activities))
+	for i := range activities {
+		lastActivity[activities[i].Email] = activities[i].LastActivity()
+	}
+	var inactive []InactiveUser
+	for _, u := range users {
+		last := lastActivity[u.Email]
+		if last.Before(since) {
+			inactive = append(inactive, InactiveUser{Email: u.Email, LastActivity: last})
+		}
+	}
+	sort.Slice(inactive, func(i, j int) bool {
+		if inactive[i].LastActivity.Equal(inactive[j].LastActivity) {
+			return inactive[i].Email < inactive[j].Email
+		}
+		return inactive[i].LastActivity.Before(inactive[j].LastActivity)
+	})
+	return inactive, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestRecordLogin(c *check.C) {
+	err := RecordLogin(s.user.Email)
+	c.Assert(err, check.IsNil)
+	activity, err := GetActivity(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(activity.Email, check.Equals, s.user.Email)
+	c.Assert(time.Since(activity.LastLogin) < time.Minute, check.Equals, true)
+	c.Assert(activity.LastActivity(), check.Equals, activity.LastLogin)
+}
+
+func (s *S) TestRecordTokenUsage(c *check.C) {
+	recentUsage = map[string]time.Time{}
+	err := RecordTokenUsage(s.user.Email, TokenKindAPIKey)
+	c.Assert(err, check.IsNil)
+	activity, err := GetActivity(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(activity.LastLogin.IsZero(), check.Equals, true)
+	c.Assert(activity.TokenUsage, check.HasLen, 1)
+	lastUsage := activity.TokenUsage[TokenKindAPIKey]
+	c.Assert(time.Since(lastUsage) < time.Minute, check.Equals, true)
+	c.Assert(activity.LastActivity(), check.Equals, lastUsage)
+	err = RecordTokenUsage(s.user.Email, TokenKindAPIKey)
+	c.Assert(err, check.IsNil)
+	err = RecordTokenUsage(s.user.Email, TokenKindSession)
+	c.Assert(err, check.IsNil)
+	activity, err = GetActivity(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(activity.TokenUsage, check.HasLen, 2)
+	c.Assert(activity.TokenUsage[TokenKindAPIKey], check.Equals, lastUsage)
+}
+
+func (s *S) TestGetActivityNoActivity(c *check.C) {
+	activity, err := GetActivity(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(activity, check.DeepEquals, &Activity{Email: s.user.Email})
+	c.Assert(activity.LastActivity().IsZero(), check.Equals, true)
+}
+
+func (s *S) TestInactiveUsers(c *check.C) {
+	for _, email := range []string{"active@example.com", "old@example.com", "never@example.com"} {
+		u := User{Email: email, Password: "123456"}
+		err := u.Create()
+		c.Assert(err, check.IsNil)
+	}
+	err := RecordLogin("active@example.com")
+	c.Assert(err, check.IsNil)
+	old := time.Now().UTC().AddDate(0, 0, -100).Truncate(time.Millisecond)
+	_, err = s.conn.UserActivity().UpsertId("old@example.com", bson.M{"$set": bson.M{"tokenusage.session": old}})
+	c.Assert(err, check.IsNil)
+	users, err := InactiveUsers(time.Now().AddDate(0, 0, -30))
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 3)
+	c.Assert(users[0], check.DeepEquals, InactiveUser{Email: "never@example.com"})
+	c.Assert(users[1], check.DeepEquals, InactiveUser{Email: s.user.Email})
+	c.Assert(users[2].Email, check.Equals, "old@example.com")
+	c.Assert(users[2].LastActivity.Equal(old), check.Equals, true)
+}
@@ -5,6 +5,8 @@
 package auth
 
 import (
+	"context"
+
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/permission"
 	"gopkg.in/mgo.v2"
@@ -42,8 +44,8 @@ func (t *APIToken) Permissions() ([]permission.Permission, error) {
 	return BaseTokenPermission(t)
 }
 
-func getAPIToken(header string) (*APIToken, error) {
-	conn, err := db.Conn()
+func getAPIToken(ctx context.Context, header string) (*APIToken, error) {
+	conn, err := db.ConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +65,6 @@ func getAPIToken(header string) (*APIToken, error) {
 	return &t, nil
 }
 
-func APIAuth(token string) (*APIToken, error) {
-	return getAPIToken(token)
+func APIAuth(ctx context.Context, token string) (*APIToken, error) {
+	return getAPIToken(ctx, token)
 }
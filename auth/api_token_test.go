@@ -4,7 +4,11 @@
 
 package auth
 
-import "gopkg.in/check.v1"
+import (
+	"context"
+
+	"gopkg.in/check.v1"
+)
 
 func (s *S) TestGetAPIToken(c *check.C) {
 	user := User{Email: "para@xmen.com", APIKey: "Quenço"}
@@ -13,26 +17,26 @@ func (s *S) TestGetAPIToken(c *check.C) {
 	defer user.Delete()
 	APIKey, err := user.RegenerateAPIKey()
 	c.Assert(err, check.IsNil)
-	t, err := getAPIToken("bearer " + APIKey)
+	t, err := getAPIToken(context.Background(), "bearer "+APIKey)
 	c.Assert(err, check.IsNil)
 	c.Assert(t.Token, check.Equals, APIKey)
 	c.Assert(t.UserEmail, check.Equals, user.Email)
 }
 
 func (s *S) TestGetAPITokenEmptyToken(c *check.C) {
-	u, err := getAPIToken("bearer tokenthatdoesnotexist")
+	u, err := getAPIToken(context.Background(), "bearer tokenthatdoesnotexist")
 	c.Assert(u, check.IsNil)
 	c.Assert(err, check.Equals, ErrInvalidToken)
 }
 
 func (s *S) TestGetAPITokennNotFound(c *check.C) {
-	t, err := getAPIToken("bearer invalid")
+	t, err := getAPIToken(context.Background(), "bearer invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, ErrInvalidToken)
 }
 
 func (s *S) TestGetAPITokenInvalid(c *check.C) {
-	t, err := getAPIToken("invalid")
+	t, err := getAPIToken(context.Background(), "invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, ErrInvalidToken)
 }
@@ -0,0 +1,189 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrInvalidGroupName   = errors.New("invalid group name")
+	ErrGroupAlreadyExists = errors.New("group already exists")
+	ErrGroupNotFound      = errors.New("group not found")
+)
+
+// Group is a set of users independent of teams, like machine users or a
+// guild spanning many teams. Members of a group are granted the roles of the
+// group, without being added to the teams owning apps. Groups are also a
+// permission context, used to control who manages each group.
+type Group struct {
+	Name    string         `bson:"_id"`
+	Members []string       `bson:",omitempty"`
+	Roles   []RoleInstance `bson:",omitempty"`
+}
+
+// CreateGroup creates a group without members or roles.
+func CreateGroup(name string) error {
+	name = strings.TrimSpace(name)
+	if !isTeamNameValid(name) {
+		return ErrInvalidGroupName
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Groups().Insert(Group{Name: name})
+	if mgo.IsDup(err) {
+		return ErrGroupAlreadyExists
+	}
+	return err
+}
+
+// GetGroup finds a group by name.
+func GetGroup(name string) (*Group, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var g Group
+	err = conn.Groups().FindId(name).One(&g)
+	if err == mgo.ErrNotFound {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func listGroups(filter bson.M) ([]Group, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var groups []Group
+	err = conn.Groups().Find(filter).Sort("_id").All(&groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ListGroups returns all groups, sorted by name.
+func ListGroups() ([]Group, error) {
+	return listGroups(nil)
+}
+
+// ListGroupsByMember returns the groups the user with the given email is a
+// member of, sorted by name.
+func ListGroupsByMember(email string) ([]Group, error) {
+	return listGroups(bson.M{"members": email})
+}
+
+// RemoveGroup removes a group. The roles of the group are no longer granted
+// to its members.
+func RemoveGroup(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Groups().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrGroupNotFound
+	}
+	return err
+}
+
+func (g *Group) update(change bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var updated Group
+	_, err = conn.Groups().FindId(g.Name).Apply(mgo.Change{Update: change, ReturnNew: true}, &updated)
+	if err == mgo.ErrNotFound {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	*g = updated
+	return nil
+}
+
+// AddMember adds the user with the given email to the group.
+func (g *Group) AddMember(email string) error {
+	return g.update(bson.M{"$addToSet": bson.M{"members": email}})
+}
+
+// RemoveMember removes the user with the given email from the group.
+func (g *Group) RemoveMember(email string) error {
+	return g.update(bson.M{"$pull": bson.M{"members": email}})
+}
+
+// AddRole grants the role, in the given context, to the members of the group.
+func (g *Group) AddRole(roleName string, contextValue string) error {
+	_, err := permission.FindRole(roleName)
+	if err != nil {
+		return err
+	}
+	return g.update(bson.M{
+		"$addToSet": bson.M{
+			"roles": bson.D([]bson.DocElem{
+				{Name: "name", Value: roleName},
+				{Name: "contextvalue", Value: contextValue},
+			}),
+		},
+	})
+}
+
+// RemoveRole stops granting the role, in the given context, to the members
+// of the group.
+func (g *Group) RemoveRole(roleName string, contextValue string) error {
+	return g.update(bson.M{
+		"$pull": bson.M{
+			"roles": bson.M{"name": roleName, "contextvalue": contextValue},
+		},
+	})
+}
+
+// RemoveRoleFromAllGroups removes the role from every group it was granted
+// to.
+func RemoveRoleFromAllGroups(roleName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Groups().UpdateAll(bson.M{"roles.name": roleName}, bson.M{
+		"$pull": bson.M{
+			"roles": bson.M{"name": roleName},
+		},
+	})
+	return err
+}
+
+func removeMemberFromAllGroups(email string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Groups().UpdateAll(bson.M{"members": email}, bson.M{
+		"$pull": bson.M{"members": email},
+	})
+	return err
+}
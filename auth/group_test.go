@@ -0,0 +1,139 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateGroup(c *check.C) {
+	err := CreateGroup(" guild ")
+	c.Assert(err, check.IsNil)
+	g, err := GetGroup("guild")
+	c.Assert(err, check.IsNil)
+	c.Assert(g, check.DeepEquals, &Group{Name: "guild"})
+}
+
+func (s *S) TestCreateGroupInvalidName(c *check.C) {
+	err := CreateGroup("1guild")
+	c.Assert(err, check.Equals, ErrInvalidGroupName)
+}
+
+func (s *S) TestCreateGroupAlreadyExists(c *check.C) {
+	err := CreateGroup("guild")
+	c.Assert(err, check.IsNil)
+	err = CreateGroup("guild")
+	c.Assert(err, check.Equals, ErrGroupAlreadyExists)
+}
+
+func (s *S) TestGetGroupNotFound(c *check.C) {
+	_, err := GetGroup("guild")
+	c.Assert(err, check.Equals, ErrGroupNotFound)
+}
+
+func (s *S) TestListGroupsByMember(c *check.C) {
+	for _, name := range []string{"robots", "guild", "other"} {
+		err := CreateGroup(name)
+		c.Assert(err, check.IsNil)
+	}
+	for _, name := range []string{"robots", "guild"} {
+		g, err := GetGroup(name)
+		c.Assert(err, check.IsNil)
+		err = g.AddMember(s.user.Email)
+		c.Assert(err, check.IsNil)
+	}
+	groups, err := ListGroupsByMember(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(groups, check.HasLen, 2)
+	c.Assert(groups[0].Name, check.Equals, "guild")
+	c.Assert(groups[1].Name, check.Equals, "robots")
+	groups, err = ListGroups()
+	c.Assert(err, check.IsNil)
+	c.Assert(groups, check.HasLen, 3)
+}
+
+func (s *S) TestGroupMembers(c *check.C) {
+	err := CreateGroup("guild")
+	c.Assert(err, check.IsNil)
+	g, err := GetGroup("guild")
+	c.Assert(err, check.IsNil)
+	err = g.AddMember("a@tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = g.AddMember("b@tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = g.AddMember("a@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Members, check.DeepEquals, []string{"a@tsuru.io", "b@tsuru.io"})
+	err = g.RemoveMember("a@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Members, check.DeepEquals, []string{"b@tsuru.io"})
+	err = RemoveGroup("guild")
+	c.Assert(err, check.IsNil)
+	err = g.AddMember("a@tsuru.io")
+	c.Assert(err, check.Equals, ErrGroupNotFound)
+}
+
+func (s *S) TestGroupRoles(c *check.C) {
+	_, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	g, err := GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("deployer", "myapp")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("deployer", "otherapp")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("unknown", "myapp")
+	c.Assert(err, check.Equals, permission.ErrRoleNotFound)
+	c.Assert(g.Roles, check.DeepEquals, []RoleInstance{
+		{Name: "deployer", ContextValue: "myapp"},
+		{Name: "deployer", ContextValue: "otherapp"},
+	})
+	err = g.RemoveRole("deployer", "myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Roles, check.DeepEquals, []RoleInstance{{Name: "deployer", ContextValue: "otherapp"}})
+	err = RemoveRoleFromAllGroups("deployer")
+	c.Assert(err, check.IsNil)
+	g, err = GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Roles, check.HasLen, 0)
+}
+
+func (s *S) TestRemoveGroupNotFound(c *check.C) {
+	err := RemoveGroup("guild")
+	c.Assert(err, check.Equals, ErrGroupNotFound)
+}
+
+func (s *S) TestUserPermissionsIncludesGroupRoles(c *check.C) {
+	r1, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = r1.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	err = CreateGroup("robots")
+	c.Assert(err, check.IsNil)
+	g, err := GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	err = g.AddRole("deployer", "myapp")
+	c.Assert(err, check.IsNil)
+	u := User{Email: "robot@tsuru.io", Password: "123456"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = g.AddMember(u.Email)
+	c.Assert(err, check.IsNil)
+	perms, err := u.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermUser, Context: permission.Context(permission.CtxUser, u.Email)},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxApp, "myapp")},
+	})
+	c.Assert(u.Roles, check.HasLen, 0)
+	err = u.Delete()
+	c.Assert(err, check.IsNil)
+	g, err = GetGroup("robots")
+	c.Assert(err, check.IsNil)
+	c.Assert(g.Members, check.HasLen, 0)
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoginLockedError is returned by Login when logging in is temporarily
+// refused, after too many failed attempts for the user or from the address
+// of the client.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again after %s", e.Until.UTC().Format(time.RFC3339))
+}
+
+// LockoutScheme is implemented by schemes locking users and client addresses
+// out after too many failed login attempts.
+type LockoutScheme interface {
+	Scheme
+	// UnlockLogin removes the lockout, and the failed attempts, of the
+	// user with the given email or of the given client address. Either may
+	// be empty.
+	UnlockLogin(email, remoteAddr string) error
+}
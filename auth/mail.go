@@ -0,0 +1,45 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+// SendEmail sends the given message to the email address, using the SMTP
+// server defined in the smtp:server setting.
+func SendEmail(email string, data []byte) error {
+	addr, err := smtpServer()
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	user, err := config.GetString("smtp:user")
+	if err != nil {
+		return errors.New(`Setting "smtp:user" is not defined`)
+	}
+	password, _ := config.GetString("smtp:password")
+	if password != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+	return smtp.SendMail(addr, auth, user, []string{email}, data)
+}
+
+func smtpServer() (string, error) {
+	server, _ := config.GetString("smtp:server")
+	if server == "" {
+		return "", errors.New(`Setting "smtp:server" is not defined`)
+	}
+	if !strings.Contains(server, ":") {
+		server += ":25"
+	}
+	return server, nil
+}
@@ -0,0 +1,300 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// namedAPITokenPrefix starts the value of every named API token, so they can
+// be told apart from session tokens and API keys without querying them.
+const namedAPITokenPrefix = "tsr_"
+
+var (
+	ErrAPITokenNotFound      = errors.New("api token not found")
+	ErrAPITokenAlreadyExists = errors.New("an api token with the same name already exists")
+	ErrInvalidAPITokenName   = errors.New("invalid api token name, it must start with a letter and contain only letters, numbers, dashes and underscores")
+
+	apiTokenNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,62}$`)
+)
+
+// NamedAPIToken is a long-lived token created by a user for non interactive
+// clients, like CI systems. Its permissions are the ones of the user,
+// restricted to the permission schemes in AllowedPermissions and to the
+// contexts in AllowedContexts, when they're not empty.
+//
+// Only a hash of the token is stored, its value is available in Value right
+// after its creation.
+type NamedAPIToken struct {
+	Name               string    `json:"name"`
+	UserEmail          string    `json:"email"`
+	Description        string    `json:"description,omitempty"`
+	Hash               string    `json:"-"`
+	CreatedAt          time.Time `json:"createdAt"`
+	ExpiresAt          time.Time `json:"expiresAt,omitempty" bson:",omitempty"`
+	AllowedPermissions []string  `json:"permissions,omitempty" bson:",omitempty"`
+	AllowedContexts    []string  `json:"contexts,omitempty" bson:",omitempty"`
+	Value              string    `json:"token,omitempty" bson:"-"`
+}
+
+// NamedAPITokenOpts holds the options of a new named API token. The token
+// never expires when ExpiresIn is zero.
+type NamedAPITokenOpts struct {
+	Name               string
+	Description        string
+	ExpiresIn          time.Duration
+	AllowedPermissions []string
+	AllowedContexts    []string
+}
+
+var _ Token = &NamedAPIToken{}
+
+func (t *NamedAPIToken) GetValue() string {
+	return t.Value
+}
+
+func (t *NamedAPIToken) User() (*User, error) {
+	return GetUserByEmail(t.UserEmail)
+}
+
+func (t *NamedAPIToken) IsAppToken() bool {
+	return false
+}
+
+func (t *NamedAPIToken) GetUserName() string {
+	return t.UserEmail
+}
+
+func (t *NamedAPIToken) GetAppName() string {
+	return ""
+}
+
+// Expired returns whether the token has an expiration time in the past.
+func (t *NamedAPIToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+func (t *NamedAPIToken) Permissions() ([]permission.Permission, error) {
+	perms, err := BaseTokenPermission(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.AllowedPermissions) > 0 {
+		schemes, err := parsePermissionSchemes(t.AllowedPermissions)
+		if err != nil {
+			return nil, err
+		}
+		perms = restrictPermissions(perms, schemes)
+	}
+	if len(t.AllowedContexts) > 0 {
+		contexts, err := parsePermissionContexts(t.AllowedContexts)
+		if err != nil {
+			return nil, err
+		}
+		perms = restrictContexts(perms, contexts)
+	}
+	return perms, nil
+}
+
+func parsePermissionSchemes(names []string) ([]*permission.PermissionScheme, error) {
+	schemes := make([]*permission.PermissionScheme, len(names))
+	for i, name := range names {
+		scheme, err := permission.SafeGet(name)
+		if err != nil {
+			return nil, errors.Errorf("invalid permission %q: %s", name, err)
+		}
+		schemes[i] = scheme
+	}
+	return schemes, nil
+}
+
+// restrictPermissions returns the permissions in perms limited to the given
+// schemes, keeping their contexts. A permission on a parent of one of the
+// schemes is narrowed down to the scheme.
+func restrictPermissions(perms []permission.Permission, schemes []*permission.PermissionScheme) []permission.Permission {
+	var result []permission.Permission
+	for _, perm := range perms {
+		for _, scheme := range schemes {
+			if perm.Scheme.IsParent(scheme) {
+				result = append(result, permission.Permission{Scheme: scheme, Context: perm.Context})
+			} else if scheme.IsParent(perm.Scheme) {
+				result = append(result, perm)
+			}
+		}
+	}
+	return result
+}
+
+func parsePermissionContexts(values []string) ([]permission.PermissionContext, error) {
+	contexts := make([]permission.PermissionContext, len(values))
+	for i, value := range values {
+		ctx, err := permission.ParseContext(value)
+		if err != nil {
+			return nil, err
+		}
+		contexts[i] = ctx
+	}
+	return contexts, nil
+}
+
+// restrictContexts returns the permissions in perms limited to the given
+// contexts. A global permission is narrowed down to each of the contexts,
+// permissions in other contexts are dropped.
+func restrictContexts(perms []permission.Permission, contexts []permission.PermissionContext) []permission.Permission {
+	var result []permission.Permission
+	for _, perm := range perms {
+		for _, ctx := range contexts {
+			if perm.Context == ctx || ctx.CtxType == permission.CtxGlobal {
+				result = append(result, perm)
+			} else if perm.Context.CtxType == permission.CtxGlobal {
+				result = append(result, permission.Permission{Scheme: perm.Scheme, Context: ctx})
+			}
+		}
+	}
+	return result
+}
+
+func hashAPIToken(value string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
+}
+
+// CreateNamedAPIToken creates a new named API token for the user, returning
+// it with its value.
+func CreateNamedAPIToken(u *User, opts NamedAPITokenOpts) (*NamedAPIToken, error) {
+	if u.Suspended {
+		return nil, ErrUserSuspended
+	}
+	if !apiTokenNameRegexp.MatchString(opts.Name) {
+		return nil, ErrInvalidAPITokenName
+	}
+	if opts.ExpiresIn < 0 {
+		return nil, errors.New("api token expiration must not be negative")
+	}
+	if _, err := parsePermissionSchemes(opts.AllowedPermissions); err != nil {
+		return nil, err
+	}
+	if _, err := parsePermissionContexts(opts.AllowedContexts); err != nil {
+		return nil, err
+	}
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	value := fmt.Sprintf("%s%x", namedAPITokenPrefix, randomBytes)
+	t := NamedAPIToken{
+		Name:               opts.Name,
+		UserEmail:          u.Email,
+		Description:        opts.Description,
+		Hash:               hashAPIToken(value),
+		CreatedAt:          time.Now().UTC(),
+		AllowedPermissions: opts.AllowedPermissions,
+		AllowedContexts:    opts.AllowedContexts,
+	}
+	if opts.ExpiresIn > 0 {
+		t.ExpiresAt = t.CreatedAt.Add(opts.ExpiresIn)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.APITokens().Insert(t)
+	if mgo.IsDup(err) {
+		return nil, ErrAPITokenAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Value = value
+	return &t, nil
+}
+
+// ListNamedAPITokens returns the named API tokens of the user, without their
+// values.
+func ListNamedAPITokens(email string) ([]NamedAPIToken, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var tokens []NamedAPIToken
+	err = conn.APITokens().Find(bson.M{"useremail": email}).Sort("name").All(&tokens)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeNamedAPIToken removes the named API token of the user, it can't be
+// used anymore.
+func RevokeNamedAPIToken(email, name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.APITokens().Remove(bson.M{"useremail": email, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrAPITokenNotFound
+	}
+	return err
+}
+
+func removeNamedAPITokens(email string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.APITokens().RemoveAll(bson.M{"useremail": email})
+	return err
+}
+
+// IsNamedAPIToken returns whether the token in the given header is a named
+// API token, which must be authenticated with NamedAPITokenAuth.
+func IsNamedAPIToken(header string) bool {
+	value, err := ParseToken(header)
+	return err == nil && strings.HasPrefix(value, namedAPITokenPrefix)
+}
+
+// NamedAPITokenAuth returns the named API token in the given header,
+// returning ErrInvalidToken for unknown or expired tokens.
+func NamedAPITokenAuth(ctx context.Context, header string) (*NamedAPIToken, error) {
+	if !IsNamedAPIToken(header) {
+		return nil, ErrInvalidToken
+	}
+	value, _ := ParseToken(header)
+	conn, err := db.ConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var t NamedAPIToken
+	err = conn.APITokens().Find(bson.M{"hash": hashAPIToken(value)}).One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Expired() {
+		return nil, ErrInvalidToken
+	}
+	t.Value = value
+	return &t, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateNamedAPIToken(c *check.C) {
+	t, err := CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci", Description: "deploys", ExpiresIn: time.Hour})
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.HasPrefix(t.Value, namedAPITokenPrefix), check.Equals, true)
+	c.Assert(t.UserEmail, check.Equals, s.user.Email)
+	c.Assert(t.ExpiresAt.Sub(t.CreatedAt), check.Equals, time.Hour)
+	tokens, err := ListNamedAPITokens(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 1)
+	c.Assert(tokens[0].Name, check.Equals, "ci")
+	c.Assert(tokens[0].Description, check.Equals, "deploys")
+	c.Assert(tokens[0].Value, check.Equals, "")
+	c.Assert(tokens[0].Hash, check.Not(check.Equals), t.Value)
+	auth, err := NamedAPITokenAuth(context.Background(), "bearer "+t.Value)
+	c.Assert(err, check.IsNil)
+	c.Assert(auth.Name, check.Equals, "ci")
+	c.Assert(auth.GetValue(), check.Equals, t.Value)
+	c.Assert(auth.GetUserName(), check.Equals, s.user.Email)
+}
+
+func (s *S) TestCreateNamedAPITokenInvalid(c *check.C) {
+	_, err := CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "1-invalid"})
+	c.Assert(err, check.Equals, ErrInvalidAPITokenName)
+	_, err = CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci", ExpiresIn: -time.Hour})
+	c.Assert(err, check.ErrorMatches, "api token expiration must not be negative")
+	_, err = CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci", AllowedPermissions: []string{"app.nothing"}})
+	c.Assert(err, check.ErrorMatches, `invalid permission "app.nothing": unregistered permission`)
+	_, err = CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci", AllowedContexts: []string{"team"}})
+	c.Assert(err, check.ErrorMatches, `invalid context "team", it must be in the <type>:<value> format`)
+	_, err = CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	_, err = CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.Equals, ErrAPITokenAlreadyExists)
+}
+
+func (s *S) TestNamedAPITokenAuthExpired(c *check.C) {
+	t, err := CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci", ExpiresIn: time.Hour})
+	c.Assert(err, check.IsNil)
+	err = s.conn.APITokens().Update(map[string]string{"name": "ci"}, map[string]interface{}{
+		"$set": map[string]time.Time{"expiresat": time.Now().Add(-time.Minute)},
+	})
+	c.Assert(err, check.IsNil)
+	_, err = NamedAPITokenAuth(context.Background(), "bearer "+t.Value)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) TestNamedAPITokenAuthInvalid(c *check.C) {
+	_, err := NamedAPITokenAuth(context.Background(), "bearer "+namedAPITokenPrefix+"unknown")
+	c.Assert(err, check.Equals, ErrInvalidToken)
+	_, err = NamedAPITokenAuth(context.Background(), "bearer sessiontoken")
+	c.Assert(err, check.Equals, ErrInvalidToken)
+	c.Assert(IsNamedAPIToken("bearer "+namedAPITokenPrefix+"abc"), check.Equals, true)
+	c.Assert(IsNamedAPIToken("bearer abc"), check.Equals, false)
+}
+
+func (s *S) TestRevokeNamedAPIToken(c *check.C) {
+	t, err := CreateNamedAPIToken(s.user, NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	err = RevokeNamedAPIToken("other@globo.com", "ci")
+	c.Assert(err, check.Equals, ErrAPITokenNotFound)
+	err = RevokeNamedAPIToken(s.user.Email, "ci")
+	c.Assert(err, check.IsNil)
+	_, err = NamedAPITokenAuth(context.Background(), "bearer "+t.Value)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+	err = RevokeNamedAPIToken(s.user.Email, "ci")
+	c.Assert(err, check.Equals, ErrAPITokenNotFound)
+}
+
+func (s *S) TestDeleteUserRemovesNamedAPITokens(c *check.C) {
+	u := User{Email: "ci@globo.com", Password: "123456"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	_, err = CreateNamedAPIToken(&u, NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	err = u.Delete()
+	c.Assert(err, check.IsNil)
+	tokens, err := ListNamedAPITokens(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 0)
+}
+
+func (s *S) TestNamedAPITokenPermissions(c *check.C) {
+	t := NamedAPIToken{UserEmail: s.user.Email}
+	perms, err := t.Permissions()
+	c.Assert(err, check.IsNil)
+	userPerms, err := s.user.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, userPerms)
+}
+
+func (s *S) TestRestrictPermissions(c *check.C) {
+	perms := []permission.Permission{
+		{Scheme: permission.PermApp, Context: permission.Context(permission.CtxTeam, "myteam")},
+		{Scheme: permission.PermAppUpdateEnvSet, Context: permission.Context(permission.CtxApp, "myapp")},
+		{Scheme: permission.PermTeamCreate, Context: permission.Context(permission.CtxGlobal, "")},
+	}
+	schemes := []*permission.PermissionScheme{permission.PermAppDeploy, permission.PermAppUpdate}
+	c.Assert(restrictPermissions(perms, schemes), check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "myteam")},
+		{Scheme: permission.PermAppUpdate, Context: permission.Context(permission.CtxTeam, "myteam")},
+		{Scheme: permission.PermAppUpdateEnvSet, Context: permission.Context(permission.CtxApp, "myapp")},
+	})
+}
+
+func (s *S) TestNamedAPITokenPermissionsWithContexts(c *check.C) {
+	role, err := permission.NewRole("deployer", "team", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy", "app.update.env")
+	c.Assert(err, check.IsNil)
+	err = s.user.AddRole("deployer", "myteam")
+	c.Assert(err, check.IsNil)
+	err = s.user.AddRole("deployer", "otherteam")
+	c.Assert(err, check.IsNil)
+	t, err := CreateNamedAPIToken(s.user, NamedAPITokenOpts{
+		Name:               "ci",
+		AllowedPermissions: []string{"app.deploy"},
+		AllowedContexts:    []string{"team:myteam"},
+	})
+	c.Assert(err, check.IsNil)
+	t, err = NamedAPITokenAuth(context.Background(), "bearer "+t.Value)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.AllowedContexts, check.DeepEquals, []string{"team:myteam"})
+	perms, err := t.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "myteam")},
+	})
+	c.Assert(permission.Check(t, permission.PermAppDeploy, permission.Context(permission.CtxTeam, "myteam")), check.Equals, true)
+	c.Assert(permission.Check(t, permission.PermAppDeploy, permission.Context(permission.CtxTeam, "otherteam")), check.Equals, false)
+	c.Assert(permission.Check(t, permission.PermAppUpdateEnv, permission.Context(permission.CtxTeam, "myteam")), check.Equals, false)
+}
+
+func (s *S) TestRestrictContexts(c *check.C) {
+	perms := []permission.Permission{
+		{Scheme: permission.PermApp, Context: permission.Context(permission.CtxGlobal, "")},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "myteam")},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "otherteam")},
+		{Scheme: permission.PermAppUpdateEnvSet, Context: permission.Context(permission.CtxApp, "myapp")},
+	}
+	contexts := []permission.PermissionContext{
+		permission.Context(permission.CtxTeam, "myteam"),
+		permission.Context(permission.CtxApp, "myapp"),
+	}
+	c.Assert(restrictContexts(perms, contexts), check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermApp, Context: permission.Context(permission.CtxTeam, "myteam")},
+		{Scheme: permission.PermApp, Context: permission.Context(permission.CtxApp, "myapp")},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "myteam")},
+		{Scheme: permission.PermAppUpdateEnvSet, Context: permission.Context(permission.CtxApp, "myapp")},
+	})
+	global := []permission.PermissionContext{permission.Context(permission.CtxGlobal, "")}
+	c.Assert(restrictContexts(perms, global), check.DeepEquals, perms)
+}
@@ -10,7 +10,14 @@ import (
 	"github.com/tsuru/config"
 )
 
-var passwordChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz1234567890_@#$%^&*()~[]{}?=-+,.<>:;`"
+const (
+	upperPasswordChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerPasswordChars  = "abcdefghijklmnopqrstuvwxyz"
+	digitPasswordChars  = "1234567890"
+	symbolPasswordChars = "_@#$%^&*()~[]{}?=-+,.<>:;`"
+)
+
+var passwordChars = upperPasswordChars + lowerPasswordChars + digitPasswordChars + symbolPasswordChars
 
 func getEmailResetPasswordTemplate() (*template.Template, error) {
 	templateFile, _ := config.GetString("reset-password-template")
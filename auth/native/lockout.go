@@ -0,0 +1,183 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultLoginWindow  = 5 * time.Minute
+	defaultLoginLockout = 15 * time.Minute
+)
+
+// loginLimits holds the limits of failed login attempts set in the
+// auth:login-rate-limit settings. A zero maximum disables the limit.
+type loginLimits struct {
+	MaxPerUser int
+	MaxPerIP   int
+	Window     time.Duration
+	Lockout    time.Duration
+}
+
+// loginAttempts are the failed login attempts for a user or from a client
+// address, counted since WindowStart.
+type loginAttempts struct {
+	Key         string `bson:"_id"`
+	Failures    int
+	WindowStart time.Time
+	LockedUntil time.Time
+	ExpireAt    time.Time
+}
+
+func loginRateLimit() loginLimits {
+	limits := loginLimits{Window: defaultLoginWindow, Lockout: defaultLoginLockout}
+	limits.MaxPerUser, _ = config.GetInt("auth:login-rate-limit:max-attempts-per-user")
+	limits.MaxPerIP, _ = config.GetInt("auth:login-rate-limit:max-attempts-per-ip")
+	if v, err := config.GetInt("auth:login-rate-limit:window"); err == nil && v > 0 {
+		limits.Window = time.Duration(v) * time.Second
+	}
+	if v, err := config.GetInt("auth:login-rate-limit:lockout"); err == nil && v > 0 {
+		limits.Lockout = time.Duration(v) * time.Second
+	}
+	return limits
+}
+
+func userAttemptsKey(email string) string {
+	return "user:" + email
+}
+
+func ipAttemptsKey(remoteAddr string) string {
+	return "ip:" + remoteAddr
+}
+
+// keys returns the keys of the attempts limited for the email and address,
+// along with their maximum.
+func (l loginLimits) keys(email, remoteAddr string) map[string]int {
+	keys := map[string]int{}
+	if l.MaxPerUser > 0 && email != "" {
+		keys[userAttemptsKey(email)] = l.MaxPerUser
+	}
+	if l.MaxPerIP > 0 && remoteAddr != "" {
+		keys[ipAttemptsKey(remoteAddr)] = l.MaxPerIP
+	}
+	return keys
+}
+
+// checkLoginLockout returns an *auth.LoginLockedError if the user or the
+// address are locked out.
+func checkLoginLockout(limits loginLimits, email, remoteAddr string) error {
+	keys := limits.keys(email, remoteAddr)
+	if len(keys) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(keys))
+	for k := range keys {
+		ids = append(ids, k)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var locked []loginAttempts
+	err = conn.LoginAttempts().Find(bson.M{
+		"_id":         bson.M{"$in": ids},
+		"lockeduntil": bson.M{"$gt": time.Now().UTC()},
+	}).Sort("-lockeduntil").All(&locked)
+	if err != nil {
+		return err
+	}
+	if len(locked) > 0 {
+		return &auth.LoginLockedError{Until: locked[0].LockedUntil}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login attempt for the user and the
+// address, locking them out once they reach the maximum attempts in the
+// window.
+func recordLoginFailure(limits loginLimits, email, remoteAddr string) error {
+	keys := limits.keys(email, remoteAddr)
+	if len(keys) == 0 {
+		return nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.LoginAttempts()
+	for key, max := range keys {
+		now := time.Now().UTC()
+		var attempts loginAttempts
+		_, err = coll.Find(bson.M{"_id": key, "windowstart": bson.M{"$gt": now.Add(-limits.Window)}}).Apply(mgo.Change{
+			Update:    bson.M{"$inc": bson.M{"failures": 1}},
+			ReturnNew: true,
+		}, &attempts)
+		if err == mgo.ErrNotFound {
+			attempts = loginAttempts{Key: key, Failures: 1, WindowStart: now}
+			_, err = coll.UpsertId(key, bson.M{"$set": bson.M{
+				"failures":    attempts.Failures,
+				"windowstart": attempts.WindowStart,
+				"expireat":    now.Add(limits.Window),
+			}})
+		}
+		if err != nil {
+			return err
+		}
+		if attempts.Failures < max {
+			continue
+		}
+		lockedUntil := now.Add(limits.Lockout)
+		err = coll.UpdateId(key, bson.M{"$set": bson.M{
+			"failures":    0,
+			"windowstart": now,
+			"lockeduntil": lockedUntil,
+			"expireat":    lockedUntil.Add(limits.Window),
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetLoginFailures discards the failed login attempts of the user, after a
+// successful login. Attempts from the address are kept, as they may be for
+// other users.
+func resetLoginFailures(email string) error {
+	return removeLoginAttempts(userAttemptsKey(email))
+}
+
+func removeLoginAttempts(keys ...string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.LoginAttempts().RemoveAll(bson.M{"_id": bson.M{"$in": keys}})
+	return err
+}
+
+func (s NativeScheme) UnlockLogin(email, remoteAddr string) error {
+	var keys []string
+	if email != "" {
+		keys = append(keys, userAttemptsKey(email))
+	}
+	if remoteAddr != "" {
+		keys = append(keys, ipAttemptsKey(remoteAddr))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return removeLoginAttempts(keys...)
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestLoginRateLimit(c *check.C) {
+	c.Assert(loginRateLimit(), check.Equals, loginLimits{Window: defaultLoginWindow, Lockout: defaultLoginLockout})
+	config.Set("auth:login-rate-limit:max-attempts-per-user", 5)
+	config.Set("auth:login-rate-limit:max-attempts-per-ip", 20)
+	config.Set("auth:login-rate-limit:window", 60)
+	config.Set("auth:login-rate-limit:lockout", 120)
+	defer config.Unset("auth:login-rate-limit")
+	c.Assert(loginRateLimit(), check.Equals, loginLimits{
+		MaxPerUser: 5,
+		MaxPerIP:   20,
+		Window:     time.Minute,
+		Lockout:    2 * time.Minute,
+	})
+}
+
+func (s *S) TestNativeLoginLockoutPerUser(c *check.C) {
+	config.Set("auth:login-rate-limit:max-attempts-per-user", 2)
+	defer config.Unset("auth:login-rate-limit")
+	params := map[string]string{"email": s.user.Email, "password": "wrong-password"}
+	for i := 0; i < 2; i++ {
+		_, err := nativeScheme.Login(params)
+		c.Assert(err, check.FitsTypeOf, auth.AuthenticationFailure{})
+	}
+	params["password"] = "123456"
+	_, err := nativeScheme.Login(params)
+	c.Assert(err, check.FitsTypeOf, &auth.LoginLockedError{})
+	lockErr := err.(*auth.LoginLockedError)
+	c.Assert(lockErr.Until.After(time.Now().Add(defaultLoginLockout-time.Minute)), check.Equals, true)
+	err = nativeScheme.UnlockLogin(s.user.Email, "")
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(params)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestNativeLoginLockoutPerIP(c *check.C) {
+	config.Set("auth:login-rate-limit:max-attempts-per-ip", 2)
+	defer config.Unset("auth:login-rate-limit")
+	params := map[string]string{
+		"email":                   "unknown@tsuru.io",
+		"password":                "123456",
+		auth.LoginParamRemoteAddr: "10.0.0.1",
+	}
+	for i := 0; i < 2; i++ {
+		_, err := nativeScheme.Login(params)
+		c.Assert(err, check.Equals, auth.ErrUserNotFound)
+	}
+	params["email"] = s.user.Email
+	_, err := nativeScheme.Login(params)
+	c.Assert(err, check.FitsTypeOf, &auth.LoginLockedError{})
+	params[auth.LoginParamRemoteAddr] = "10.0.0.2"
+	_, err = nativeScheme.Login(params)
+	c.Assert(err, check.IsNil)
+	err = nativeScheme.UnlockLogin("", "10.0.0.1")
+	c.Assert(err, check.IsNil)
+	params[auth.LoginParamRemoteAddr] = "10.0.0.1"
+	_, err = nativeScheme.Login(params)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestNativeLoginSuccessResetsUserFailures(c *check.C) {
+	config.Set("auth:login-rate-limit:max-attempts-per-user", 2)
+	defer config.Unset("auth:login-rate-limit")
+	wrong := map[string]string{"email": s.user.Email, "password": "wrong-password"}
+	right := map[string]string{"email": s.user.Email, "password": "123456"}
+	_, err := nativeScheme.Login(wrong)
+	c.Assert(err, check.NotNil)
+	_, err = nativeScheme.Login(right)
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(wrong)
+	c.Assert(err, check.FitsTypeOf, auth.AuthenticationFailure{})
+	_, err = nativeScheme.Login(right)
+	c.Assert(err, check.IsNil)
+}
@@ -5,10 +5,15 @@
 package native
 
 import (
+	"context"
+	"time"
+
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/validation"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 var (
@@ -35,19 +40,140 @@ func (s NativeScheme) Login(params map[string]string) (auth.Token, error) {
 	if !ok {
 		return nil, ErrMissingPasswordError
 	}
+	remoteAddr := params[auth.LoginParamRemoteAddr]
+	limits := loginRateLimit()
+	err := checkLoginLockout(limits, email, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
 	user, err := auth.GetUserByEmail(email)
 	if err != nil {
+		if err == auth.ErrUserNotFound {
+			if recordErr := recordLoginFailure(limits, "", remoteAddr); recordErr != nil {
+				return nil, recordErr
+			}
+		}
 		return nil, err
 	}
-	token, err := createToken(user, password)
+	if user.Suspended {
+		return nil, auth.ErrUserSuspended
+	}
+	// An invalid policy must not lock users out, its length limits are
+	// only used when setting passwords.
+	policy, _ := passwordPolicy()
+	if passwordExpired(user, policy) && checkPassword(user.Password, password) == nil {
+		return nil, ErrPasswordExpired
+	}
+	token, err := createSessionToken(user, password, remoteAddr, params[auth.LoginParamUserAgent])
 	if err != nil {
+		if _, ok := err.(auth.AuthenticationFailure); ok {
+			if recordErr := recordLoginFailure(limits, email, remoteAddr); recordErr != nil {
+				return nil, recordErr
+			}
+		}
 		return nil, err
 	}
+	if limits.MaxPerUser > 0 {
+		err = resetLoginFailures(email)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if policy.MaxAgeDays > 0 && user.PasswordChangedAt.IsZero() {
+		user.PasswordChangedAt = time.Now().UTC()
+		err = user.Update()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return token, nil
 }
 
-func (s NativeScheme) Auth(token string) (auth.Token, error) {
-	return getToken(token)
+func (s NativeScheme) Auth(ctx context.Context, token string) (auth.Token, error) {
+	return getToken(ctx, token)
+}
+
+// Refresh replaces the session token with a new one, with a new expiration.
+// App tokens can't be refreshed.
+func (s NativeScheme) Refresh(token auth.Token) (auth.Token, error) {
+	t, ok := token.(*Token)
+	if !ok || t.IsAppToken() {
+		return nil, auth.ErrInvalidToken
+	}
+	user, err := t.User()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	newToken, err := newUserToken(user)
+	if err != nil {
+		return nil, err
+	}
+	newToken.ID = bson.NewObjectId()
+	newToken.RemoteAddr = t.RemoteAddr
+	newToken.UserAgent = t.UserAgent
+	err = conn.Tokens().Insert(newToken)
+	if err != nil {
+		return nil, err
+	}
+	return newToken, deleteToken(t.Token)
+}
+
+// Sessions returns the active session tokens of the user owning the token,
+// oldest first.
+func (s NativeScheme) Sessions(token auth.Token) ([]auth.Session, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var tokens []Token
+	err = conn.Tokens().Find(bson.M{"useremail": token.GetUserName(), "appname": ""}).Sort("creation").All(&tokens)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]auth.Session, 0, len(tokens))
+	for _, t := range tokens {
+		expiration := t.GetExpiration()
+		if !expiration.IsZero() && expiration.Before(time.Now()) {
+			continue
+		}
+		sessions = append(sessions, auth.Session{
+			ID:         t.ID.Hex(),
+			Creation:   t.Creation,
+			Expires:    expiration,
+			RemoteAddr: t.RemoteAddr,
+			UserAgent:  t.UserAgent,
+			Current:    t.Token == token.GetValue(),
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession removes the session token with the given ID, as long as it
+// belongs to the user owning the token.
+func (s NativeScheme) RevokeSession(token auth.Token, id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return auth.ErrSessionNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Tokens().Remove(bson.M{
+		"_id":       bson.ObjectIdHex(id),
+		"useremail": token.GetUserName(),
+		"appname":   "",
+	})
+	if err == mgo.ErrNotFound {
+		return auth.ErrSessionNotFound
+	}
+	return err
 }
 
 func (s NativeScheme) Logout(token string) error {
@@ -66,15 +192,20 @@ func (s NativeScheme) Create(user *auth.User) (*auth.User, error) {
 	if !validation.ValidateEmail(user.Email) {
 		return nil, ErrInvalidEmail
 	}
-	if !validation.ValidateLength(user.Password, passwordMinLen, passwordMaxLen) {
-		return nil, ErrInvalidPassword
+	policy, err := passwordPolicy()
+	if err != nil {
+		return nil, err
 	}
-	if _, err := auth.GetUserByEmail(user.Email); err == nil {
+	if err = policy.Validate(user.Password); err != nil {
+		return nil, err
+	}
+	if _, err = auth.GetUserByEmail(user.Email); err == nil {
 		return nil, ErrEmailRegistered
 	}
-	if err := hashPassword(user); err != nil {
+	if err = hashPassword(user); err != nil {
 		return nil, err
 	}
+	user.PasswordChangedAt = time.Now().UTC()
 	if err := user.Create(); err != nil {
 		return nil, err
 	}
@@ -89,11 +220,13 @@ func (s NativeScheme) ChangePassword(token auth.Token, oldPassword string, newPa
 	if err = checkPassword(user.Password, oldPassword); err != nil {
 		return ErrPasswordMismatch
 	}
-	if !validation.ValidateLength(newPassword, passwordMinLen, passwordMaxLen) {
-		return ErrInvalidPassword
+	policy, err := passwordPolicy()
+	if err != nil {
+		return err
+	}
+	if err = setPassword(user, newPassword, policy); err != nil {
+		return err
 	}
-	user.Password = newPassword
-	hashPassword(user)
 	return user.Update()
 }
 
@@ -107,8 +240,8 @@ func (s NativeScheme) StartPasswordReset(user *auth.User) error {
 }
 
 // ResetPassword actually resets the password of the user. It needs the token
-// string. The new password will be a random string, that will be then sent to
-// the user email.
+// string. The new password will be a random string following the password
+// policy, that will be then sent to the user email.
 func (s NativeScheme) ResetPassword(user *auth.User, resetToken string) error {
 	if resetToken == "" {
 		return auth.ErrInvalidToken
@@ -125,9 +258,20 @@ func (s NativeScheme) ResetPassword(user *auth.User, resetToken string) error {
 	if passToken.UserEmail != user.Email {
 		return auth.ErrInvalidToken
 	}
-	password := generatePassword(12)
-	user.Password = password
-	hashPassword(user)
+	policy, err := passwordPolicy()
+	if err != nil {
+		return err
+	}
+	password, err := generatePolicyPassword(policy)
+	if err != nil {
+		return err
+	}
+	if err = policy.Validate(password); err != nil {
+		return err
+	}
+	if err = replacePassword(user, password, policy); err != nil {
+		return err
+	}
 	go sendNewPassword(user, password)
 	passToken.Used = true
 	conn.PasswordTokens().UpdateId(passToken.Token, passToken)
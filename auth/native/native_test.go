@@ -6,6 +6,7 @@ package native
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"time"
 
@@ -47,6 +48,110 @@ func (s *S) TestNativeLogin(c *check.C) {
 	c.Assert(u.Email, check.Equals, "timeredbull@globo.com")
 }
 
+func (s *S) TestNativeRefresh(c *check.C) {
+	scheme := NativeScheme{}
+	token, err := scheme.Login(map[string]string{"email": "timeredbull@globo.com", "password": "123456"})
+	c.Assert(err, check.IsNil)
+	newToken, err := scheme.Refresh(token)
+	c.Assert(err, check.IsNil)
+	c.Assert(newToken.GetValue(), check.Not(check.Equals), token.GetValue())
+	c.Assert(newToken.GetUserName(), check.Equals, "timeredbull@globo.com")
+	expiration := newToken.(auth.ExpirableToken).GetExpiration()
+	c.Assert(expiration.Before(token.(auth.ExpirableToken).GetExpiration()), check.Equals, false)
+	_, err = scheme.Auth(context.Background(), "bearer "+token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	t, err := scheme.Auth(context.Background(), "bearer "+newToken.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(t.GetValue(), check.Equals, newToken.GetValue())
+}
+
+func (s *S) TestNativeRefreshAppToken(c *check.C) {
+	scheme := NativeScheme{}
+	token, err := scheme.AppLogin("myApp")
+	c.Assert(err, check.IsNil)
+	_, err = scheme.Refresh(token)
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (s *S) TestNativeRefreshKeepsSessionOrigin(c *check.C) {
+	scheme := NativeScheme{}
+	token, err := scheme.Login(map[string]string{
+		"email":                   "timeredbull@globo.com",
+		"password":                "123456",
+		auth.LoginParamRemoteAddr: "10.0.0.1",
+		auth.LoginParamUserAgent:  "tsuru-client/1.0",
+	})
+	c.Assert(err, check.IsNil)
+	newToken, err := scheme.Refresh(token)
+	c.Assert(err, check.IsNil)
+	t := newToken.(*Token)
+	c.Assert(t.RemoteAddr, check.Equals, "10.0.0.1")
+	c.Assert(t.UserAgent, check.Equals, "tsuru-client/1.0")
+}
+
+func (s *S) TestNativeSessions(c *check.C) {
+	scheme := NativeScheme{}
+	user := &auth.User{Email: "x@x.com", Password: "123456"}
+	_, err := scheme.Create(user)
+	c.Assert(err, check.IsNil)
+	first, err := scheme.Login(map[string]string{"email": user.Email, "password": "123456", auth.LoginParamRemoteAddr: "10.0.0.1"})
+	c.Assert(err, check.IsNil)
+	second, err := scheme.Login(map[string]string{"email": user.Email, "password": "123456", auth.LoginParamUserAgent: "tsuru-client/1.0"})
+	c.Assert(err, check.IsNil)
+	_, err = scheme.AppLogin("myApp")
+	c.Assert(err, check.IsNil)
+	expired, err := scheme.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Tokens().Update(bson.M{"token": expired.GetValue()}, bson.M{"$set": bson.M{"creation": time.Now().Add(-30 * 24 * time.Hour)}})
+	c.Assert(err, check.IsNil)
+	sessions, err := scheme.Sessions(second)
+	c.Assert(err, check.IsNil)
+	c.Assert(sessions, check.HasLen, 2)
+	c.Assert(sessions[0].ID, check.Equals, first.(*Token).ID.Hex())
+	c.Assert(sessions[0].RemoteAddr, check.Equals, "10.0.0.1")
+	c.Assert(sessions[0].Current, check.Equals, false)
+	c.Assert(sessions[0].Expires, check.Not(check.Equals), time.Time{})
+	c.Assert(sessions[1].ID, check.Equals, second.(*Token).ID.Hex())
+	c.Assert(sessions[1].UserAgent, check.Equals, "tsuru-client/1.0")
+	c.Assert(sessions[1].Current, check.Equals, true)
+}
+
+func (s *S) TestNativeRevokeSession(c *check.C) {
+	scheme := NativeScheme{}
+	first, err := scheme.Login(map[string]string{"email": "timeredbull@globo.com", "password": "123456"})
+	c.Assert(err, check.IsNil)
+	second, err := scheme.Login(map[string]string{"email": "timeredbull@globo.com", "password": "123456"})
+	c.Assert(err, check.IsNil)
+	err = scheme.RevokeSession(second, first.(*Token).ID.Hex())
+	c.Assert(err, check.IsNil)
+	_, err = scheme.Auth(context.Background(), "bearer "+first.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	_, err = scheme.Auth(context.Background(), "bearer "+second.GetValue())
+	c.Assert(err, check.IsNil)
+	err = scheme.RevokeSession(second, first.(*Token).ID.Hex())
+	c.Assert(err, check.Equals, auth.ErrSessionNotFound)
+	err = scheme.RevokeSession(second, "invalid")
+	c.Assert(err, check.Equals, auth.ErrSessionNotFound)
+}
+
+func (s *S) TestNativeRevokeSessionOfAnotherUser(c *check.C) {
+	scheme := NativeScheme{}
+	user := &auth.User{Email: "x@x.com", Password: "123456"}
+	_, err := scheme.Create(user)
+	c.Assert(err, check.IsNil)
+	other, err := scheme.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	token, err := scheme.Login(map[string]string{"email": "timeredbull@globo.com", "password": "123456"})
+	c.Assert(err, check.IsNil)
+	err = scheme.RevokeSession(token, other.(*Token).ID.Hex())
+	c.Assert(err, check.Equals, auth.ErrSessionNotFound)
+	_, err = scheme.Auth(context.Background(), "bearer "+other.GetValue())
+	c.Assert(err, check.IsNil)
+}
+
 func (s *S) TestNativeLoginWrongPassword(c *check.C) {
 	scheme := NativeScheme{}
 	params := make(map[string]string)
@@ -58,6 +163,18 @@ func (s *S) TestNativeLoginWrongPassword(c *check.C) {
 	c.Assert(isAuthFail, check.Equals, true)
 }
 
+func (s *S) TestNativeLoginSuspendedUser(c *check.C) {
+	u, err := auth.GetUserByEmail("timeredbull@globo.com")
+	c.Assert(err, check.IsNil)
+	err = u.Suspend()
+	c.Assert(err, check.IsNil)
+	scheme := NativeScheme{}
+	_, err = scheme.Login(map[string]string{"email": "timeredbull@globo.com", "password": "123456"})
+	c.Assert(err, check.Equals, auth.ErrUserSuspended)
+	_, err = scheme.Auth(context.Background(), "bearer "+s.token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
 func (s *S) TestNativeLoginInvalidUser(c *check.C) {
 	scheme := NativeScheme{}
 	params := make(map[string]string)
@@ -71,7 +188,7 @@ func (s *S) TestNativeCreateNoPassword(c *check.C) {
 	scheme := NativeScheme{}
 	user := &auth.User{Email: "x@x.com"}
 	_, err := scheme.Create(user)
-	c.Assert(err, check.Equals, ErrInvalidPassword)
+	c.Assert(err, check.DeepEquals, ErrInvalidPassword)
 }
 
 func (s *S) TestNativeCreateNoEmail(c *check.C) {
@@ -85,7 +202,7 @@ func (s *S) TestNativeCreateInvalidPassword(c *check.C) {
 	scheme := NativeScheme{}
 	user := &auth.User{Email: "x@x.com", Password: "123"}
 	_, err := scheme.Create(user)
-	c.Assert(err, check.Equals, ErrInvalidPassword)
+	c.Assert(err, check.DeepEquals, ErrInvalidPassword)
 }
 
 func (s *S) TestNativeCreateExistingEmail(c *check.C) {
@@ -0,0 +1,103 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxLen is the length after which bcrypt ignores the password.
+const bcryptMaxLen = 72
+
+var (
+	ErrPasswordReused  = &tsuruErrors.ValidationError{Message: "the new password must be different from the previous ones"}
+	ErrPasswordExpired = &tsuruErrors.NotAuthorizedError{Message: "your password has expired, please reset it"}
+)
+
+// passwordPolicy returns the password policy set in the
+// auth:password-policy settings, defaulting to the length limits of native
+// passwords.
+func passwordPolicy() (auth.PasswordPolicy, error) {
+	policy := auth.PasswordPolicy{MinLength: passwordMinLen, MaxLength: passwordMaxLen}
+	if v, err := config.GetInt("auth:password-policy:min-length"); err == nil {
+		policy.MinLength = v
+	}
+	if v, err := config.GetInt("auth:password-policy:max-length"); err == nil {
+		policy.MaxLength = v
+	}
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength || policy.MaxLength > bcryptMaxLen {
+		return policy, errors.Errorf("invalid password length limits: the minimum must be at least 1 and the maximum at most %d", bcryptMaxLen)
+	}
+	policy.RequireUppercase, _ = config.GetBool("auth:password-policy:require-uppercase")
+	policy.RequireLowercase, _ = config.GetBool("auth:password-policy:require-lowercase")
+	policy.RequireDigit, _ = config.GetBool("auth:password-policy:require-digit")
+	policy.RequireSymbol, _ = config.GetBool("auth:password-policy:require-symbol")
+	policy.History, _ = config.GetInt("auth:password-policy:history")
+	policy.MaxAgeDays, _ = config.GetInt("auth:password-policy:max-age-days")
+	return policy, nil
+}
+
+func (s NativeScheme) PasswordPolicy() (auth.PasswordPolicy, error) {
+	return passwordPolicy()
+}
+
+// setPassword validates the password against the policy and replaces the
+// password of the user with it. The user is not saved.
+func setPassword(user *auth.User, password string, policy auth.PasswordPolicy) error {
+	if err := policy.Validate(password); err != nil {
+		return err
+	}
+	if policy.History > 0 {
+		hashes := append([]string{user.Password}, user.PasswordHistory...)
+		for i, hash := range hashes {
+			if i >= policy.History {
+				break
+			}
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+				return ErrPasswordReused
+			}
+		}
+	}
+	return replacePassword(user, password, policy)
+}
+
+// replacePassword replaces the password of the user, keeping the hash of the
+// previous one in the password history, as long as the policy requires.
+func replacePassword(user *auth.User, password string, policy auth.PasswordPolicy) error {
+	history := append([]string{user.Password}, user.PasswordHistory...)
+	keep := policy.History - 1
+	if keep < 0 {
+		keep = 0
+	}
+	if len(history) > keep {
+		history = history[:keep]
+	}
+	user.Password = password
+	if err := hashPassword(user); err != nil {
+		return err
+	}
+	user.PasswordHistory = history
+	if len(history) == 0 {
+		user.PasswordHistory = nil
+	}
+	user.PasswordChangedAt = time.Now().UTC()
+	return nil
+}
+
+// passwordExpired returns whether the password of the user is older than
+// the maximum age of the policy.
+func passwordExpired(user *auth.User, policy auth.PasswordPolicy) bool {
+	if policy.MaxAgeDays <= 0 || user.PasswordChangedAt.IsZero() {
+		return false
+	}
+	maxAge := time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+	return time.Since(user.PasswordChangedAt) > maxAge
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/authtest"
+	"github.com/tsuru/tsuru/tsurutest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPasswordPolicyDefault(c *check.C) {
+	policy, err := NativeScheme{}.PasswordPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, auth.PasswordPolicy{MinLength: 6, MaxLength: 50})
+}
+
+func (s *S) TestPasswordPolicyFromConfig(c *check.C) {
+	config.Set("auth:password-policy", map[interface{}]interface{}{
+		"min-length":        10,
+		"max-length":        64,
+		"require-uppercase": true,
+		"require-symbol":    true,
+		"history":           5,
+		"max-age-days":      90,
+	})
+	defer config.Unset("auth:password-policy")
+	policy, err := passwordPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, auth.PasswordPolicy{
+		MinLength:        10,
+		MaxLength:        64,
+		RequireUppercase: true,
+		RequireSymbol:    true,
+		History:          5,
+		MaxAgeDays:       90,
+	})
+}
+
+func (s *S) TestPasswordPolicyInvalidLength(c *check.C) {
+	config.Set("auth:password-policy:max-length", 100)
+	defer config.Unset("auth:password-policy")
+	_, err := passwordPolicy()
+	c.Assert(err, check.ErrorMatches, "invalid password length limits: the minimum must be at least 1 and the maximum at most 72")
+}
+
+func (s *S) TestNativeCreateBreakingPasswordPolicy(c *check.C) {
+	config.Set("auth:password-policy:require-digit", true)
+	defer config.Unset("auth:password-policy")
+	scheme := NativeScheme{}
+	_, err := scheme.Create(&auth.User{Email: "x@x.com", Password: "password"})
+	c.Assert(err, check.ErrorMatches, "password must contain a digit")
+	user, err := scheme.Create(&auth.User{Email: "x@x.com", Password: "passw0rd"})
+	c.Assert(err, check.IsNil)
+	c.Assert(user.PasswordChangedAt.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestChangePasswordHistory(c *check.C) {
+	config.Set("auth:password-policy:history", 2)
+	defer config.Unset("auth:password-policy")
+	scheme := NativeScheme{}
+	user := &auth.User{Email: "x@x.com", Password: "123456"}
+	_, err := scheme.Create(user)
+	c.Assert(err, check.IsNil)
+	token, err := scheme.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	err = scheme.ChangePassword(token, "123456", "123456")
+	c.Assert(err, check.Equals, ErrPasswordReused)
+	err = scheme.ChangePassword(token, "123456", "654321")
+	c.Assert(err, check.IsNil)
+	err = scheme.ChangePassword(token, "654321", "123456")
+	c.Assert(err, check.Equals, ErrPasswordReused)
+	err = scheme.ChangePassword(token, "654321", "999999")
+	c.Assert(err, check.IsNil)
+	err = scheme.ChangePassword(token, "999999", "123456")
+	c.Assert(err, check.IsNil)
+	u, err := auth.GetUserByEmail(user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.PasswordHistory, check.HasLen, 1)
+}
+
+func (s *S) TestNativeLoginExpiredPassword(c *check.C) {
+	config.Set("auth:password-policy:max-age-days", 30)
+	defer config.Unset("auth:password-policy")
+	scheme := NativeScheme{}
+	user := &auth.User{Email: "x@x.com", Password: "123456"}
+	_, err := scheme.Create(user)
+	c.Assert(err, check.IsNil)
+	_, err = scheme.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	user.PasswordChangedAt = time.Now().Add(-31 * 24 * time.Hour)
+	err = user.Update()
+	c.Assert(err, check.IsNil)
+	_, err = scheme.Login(map[string]string{"email": user.Email, "password": "wrongpass"})
+	_, ok := err.(auth.AuthenticationFailure)
+	c.Assert(ok, check.Equals, true)
+	_, err = scheme.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.Equals, ErrPasswordExpired)
+}
+
+func (s *S) TestNativeLoginStartsPasswordAge(c *check.C) {
+	user := auth.User{Email: "x@x.com", Password: "123456"}
+	err := hashPassword(&user)
+	c.Assert(err, check.IsNil)
+	err = user.Create()
+	c.Assert(err, check.IsNil)
+	config.Set("auth:password-policy:max-age-days", 30)
+	defer config.Unset("auth:password-policy")
+	_, err = NativeScheme{}.Login(map[string]string{"email": user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	u, err := auth.GetUserByEmail(user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.PasswordChangedAt.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestResetPasswordFollowsPasswordPolicy(c *check.C) {
+	config.Set("auth:password-policy", map[interface{}]interface{}{
+		"min-length":        16,
+		"max-length":        20,
+		"require-uppercase": true,
+		"require-lowercase": true,
+		"require-digit":     true,
+		"require-symbol":    true,
+	})
+	defer config.Unset("auth:password-policy")
+	defer s.server.Reset()
+	scheme := NativeScheme{}
+	u := auth.User{Email: "reset@tsuru.io", Password: "Old-passw0rd-123"}
+	_, err := scheme.Create(&u)
+	c.Assert(err, check.IsNil)
+	token, err := createPasswordToken(&u)
+	c.Assert(err, check.IsNil)
+	err = scheme.ResetPassword(&u, token.Token)
+	c.Assert(err, check.IsNil)
+	policy, err := passwordPolicy()
+	c.Assert(err, check.IsNil)
+	var m authtest.Mail
+	err = tsurutest.WaitCondition(time.Second, func() bool {
+		s.server.RLock()
+		defer s.server.RUnlock()
+		if len(s.server.MailBox) != 1 {
+			return false
+		}
+		m = s.server.MailBox[0]
+		return true
+	})
+	c.Assert(err, check.IsNil)
+	lines := strings.Split(string(m.Data), "\r\n")
+	sent := lines[len(lines)-4]
+	c.Assert(len(sent) >= 16 && len(sent) <= 20, check.Equals, true, check.Commentf("password: %q", sent))
+	c.Assert(policy.Validate(sent), check.IsNil)
+	c.Assert(checkPassword(u.Password, sent), check.IsNil)
+}
+
+func (s *S) TestResetPasswordInvalidPasswordPolicy(c *check.C) {
+	config.Set("auth:password-policy:max-length", 100)
+	defer config.Unset("auth:password-policy")
+	scheme := NativeScheme{}
+	u := auth.User{Email: "reset@tsuru.io"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	token, err := createPasswordToken(&u)
+	c.Assert(err, check.IsNil)
+	err = scheme.ResetPassword(&u, token.Token)
+	c.Assert(err, check.ErrorMatches, "invalid password length limits: .*")
+}
@@ -5,6 +5,7 @@
 package native
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"fmt"
@@ -36,17 +37,27 @@ var (
 )
 
 type Token struct {
-	Token     string        `json:"token"`
-	Creation  time.Time     `json:"creation"`
-	Expires   time.Duration `json:"expires"`
-	UserEmail string        `json:"email"`
-	AppName   string        `json:"app"`
+	ID         bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	Token      string        `json:"token"`
+	Creation   time.Time     `json:"creation"`
+	Expires    time.Duration `json:"expires"`
+	UserEmail  string        `json:"email"`
+	AppName    string        `json:"app"`
+	RemoteAddr string        `bson:",omitempty" json:"-"`
+	UserAgent  string        `bson:",omitempty" json:"-"`
 }
 
 func (t *Token) GetValue() string {
 	return t.Token
 }
 
+func (t *Token) GetExpiration() time.Time {
+	if t.Expires <= 0 {
+		return time.Time{}
+	}
+	return t.Creation.Add(t.Expires)
+}
+
 func (t *Token) User() (*auth.User, error) {
 	return auth.GetUserByEmail(t.UserEmail)
 }
@@ -160,7 +171,17 @@ func removeOldTokens(userEmail string) error {
 }
 
 func checkPassword(passwordHash string, password string) error {
-	if !validation.ValidateLength(password, passwordMinLen, passwordMaxLen) {
+	minLen, maxLen := passwordMinLen, passwordMaxLen
+	if policy, err := passwordPolicy(); err == nil {
+		// Passwords set before the policy changed must still be accepted.
+		if policy.MinLength < minLen {
+			minLen = policy.MinLength
+		}
+		if policy.MaxLength > maxLen {
+			maxLen = policy.MaxLength
+		}
+	}
+	if !validation.ValidateLength(password, minLen, maxLen) {
 		return &tsuruErrors.ValidationError{Message: passwordError}
 	}
 	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil {
@@ -170,6 +191,12 @@ func checkPassword(passwordHash string, password string) error {
 }
 
 func createToken(u *auth.User, password string) (*Token, error) {
+	return createSessionToken(u, password, "", "")
+}
+
+// createSessionToken creates a session token for the user, recording the
+// address and the user agent of the client logging in.
+func createSessionToken(u *auth.User, password, remoteAddr, userAgent string) (*Token, error) {
 	if u.Email == "" {
 		return nil, errors.New("User does not have an email")
 	}
@@ -185,13 +212,16 @@ func createToken(u *auth.User, password string) (*Token, error) {
 	if err != nil {
 		return nil, err
 	}
+	token.ID = bson.NewObjectId()
+	token.RemoteAddr = remoteAddr
+	token.UserAgent = userAgent
 	err = conn.Tokens().Insert(token)
 	go removeOldTokens(u.Email)
 	return token, err
 }
 
-func getToken(header string) (*Token, error) {
-	conn, err := db.Conn()
+func getToken(ctx context.Context, header string) (*Token, error) {
+	conn, err := db.ConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +239,7 @@ func getToken(header string) (*Token, error) {
 		return nil, err
 	}
 	if t.Expires > 0 && time.Until(t.Creation.Add(t.Expires)) < 1 {
-		return nil, auth.ErrInvalidToken
+		return nil, auth.ErrTokenExpired
 	}
 	return &t, nil
 }
@@ -5,6 +5,7 @@
 package native
 
 import (
+	"context"
 	"crypto"
 	"encoding/json"
 	"fmt"
@@ -275,25 +276,25 @@ func (s *S) TestCreateTokenShouldValidateThePassword(c *check.C) {
 }
 
 func (s *S) TestGetToken(c *check.C) {
-	t, err := getToken("bearer " + s.token.GetValue())
+	t, err := getToken(context.Background(), "bearer "+s.token.GetValue())
 	c.Assert(err, check.IsNil)
 	c.Assert(t.Token, check.Equals, s.token.GetValue())
 }
 
 func (s *S) TestGetTokenEmptyToken(c *check.C) {
-	u, err := getToken("bearer tokenthatdoesnotexist")
+	u, err := getToken(context.Background(), "bearer tokenthatdoesnotexist")
 	c.Assert(u, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenNotFound(c *check.C) {
-	t, err := getToken("bearer invalid")
+	t, err := getToken(context.Background(), "bearer invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenInvalid(c *check.C) {
-	t, err := getToken("invalid")
+	t, err := getToken(context.Background(), "invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
@@ -305,9 +306,9 @@ func (s *S) TestGetExpiredToken(c *check.C) {
 	t.Creation = time.Now().Add(-24 * time.Hour)
 	t.Expires = time.Hour
 	s.conn.Tokens().Update(bson.M{"token": t.Token}, t)
-	t2, err := getToken(t.Token)
+	t2, err := getToken(context.Background(), t.Token)
 	c.Assert(t2, check.IsNil)
-	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	c.Assert(err, check.Equals, auth.ErrTokenExpired)
 }
 
 func (s *S) TestGetTokenNoExpiration(c *check.C) {
@@ -316,7 +317,7 @@ func (s *S) TestGetTokenNoExpiration(c *check.C) {
 	defer s.conn.Tokens().Remove(bson.M{"token": t.Token})
 	t.Creation = time.Now().Add(-24 * time.Hour)
 	s.conn.Tokens().Update(bson.M{"token": t.Token}, t)
-	t2, err := getToken(t.Token)
+	t2, err := getToken(context.Background(), t.Token)
 	c.Assert(err, check.IsNil)
 	c.Assert(t2.GetValue(), check.DeepEquals, t.GetValue())
 }
@@ -326,7 +327,7 @@ func (s *S) TestDeleteToken(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = deleteToken(t.Token)
 	c.Assert(err, check.IsNil)
-	_, err = getToken("bearer " + t.Token)
+	_, err = getToken(context.Background(), "bearer "+t.Token)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
@@ -455,3 +456,11 @@ func (s *S) TestDeleteAllTokens(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(tokensDB, check.HasLen, 1)
 }
+
+func (s *S) TestTokenGetExpiration(c *check.C) {
+	creation := time.Date(2017, 3, 1, 10, 0, 0, 0, time.UTC)
+	t := Token{Creation: creation, Expires: time.Hour}
+	c.Assert(t.GetExpiration(), check.DeepEquals, creation.Add(time.Hour))
+	t.Expires = 0
+	c.Assert(t.GetExpiration().IsZero(), check.Equals, true)
+}
@@ -7,12 +7,8 @@ package native
 import (
 	"bytes"
 	"math/rand"
-	"net"
-	"net/smtp"
-	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/log"
 )
@@ -29,7 +25,7 @@ func sendResetPassword(u *auth.User, t *passwordToken) {
 		log.Errorf("Failed to send password token to user %q: %s", u.Email, err)
 		return
 	}
-	err = sendEmail(u.Email, body.Bytes())
+	err = auth.SendEmail(u.Email, body.Bytes())
 	if err != nil {
 		log.Errorf("Failed to send password token for user %q: %s", u.Email, err)
 	}
@@ -51,7 +47,7 @@ func sendNewPassword(u *auth.User, password string) {
 		log.Errorf("Failed to send new password to user %q: %s", u.Email, err)
 		return
 	}
-	err = sendEmail(u.Email, body.Bytes())
+	err = auth.SendEmail(u.Email, body.Bytes())
 	if err != nil {
 		log.Errorf("Failed to send new password to user %q: %s", u.Email, err)
 	}
@@ -65,31 +61,47 @@ func generatePassword(length int) string {
 	return string(password)
 }
 
-func sendEmail(email string, data []byte) error {
-	addr, err := smtpServer()
-	if err != nil {
-		return err
+// resetPasswordLen is the length of passwords generated on password resets,
+// as long as the password policy allows it.
+const resetPasswordLen = 12
+
+// generatePolicyPassword generates a random password within the length
+// limits of the policy, containing at least one character of each class the
+// policy requires.
+func generatePolicyPassword(policy auth.PasswordPolicy) (string, error) {
+	length := resetPasswordLen
+	if length < policy.MinLength {
+		length = policy.MinLength
 	}
-	var auth smtp.Auth
-	user, err := config.GetString("smtp:user")
-	if err != nil {
-		return errors.New(`Setting "smtp:user" is not defined`)
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		length = policy.MaxLength
 	}
-	password, _ := config.GetString("smtp:password")
-	if password != "" {
-		host, _, _ := net.SplitHostPort(addr)
-		auth = smtp.PlainAuth("", user, password, host)
+	var required []string
+	if policy.RequireUppercase {
+		required = append(required, upperPasswordChars)
 	}
-	return smtp.SendMail(addr, auth, user, []string{email}, data)
-}
-
-func smtpServer() (string, error) {
-	server, _ := config.GetString("smtp:server")
-	if server == "" {
-		return "", errors.New(`Setting "smtp:server" is not defined`)
+	if policy.RequireLowercase {
+		required = append(required, lowerPasswordChars)
 	}
-	if !strings.Contains(server, ":") {
-		server += ":25"
+	if policy.RequireDigit {
+		required = append(required, digitPasswordChars)
+	}
+	if policy.RequireSymbol {
+		required = append(required, symbolPasswordChars)
+	}
+	if len(required) > length {
+		return "", errors.Errorf("unable to generate a password: the password policy requires %d classes of characters in at most %d characters", len(required), length)
+	}
+	password := make([]byte, length)
+	for i := range password {
+		chars := passwordChars
+		if i < len(required) {
+			chars = required[i]
+		}
+		password[i] = chars[rand.Intn(len(chars))]
 	}
-	return server, nil
+	rand.Shuffle(len(password), func(i, j int) {
+		password[i], password[j] = password[j], password[i]
+	})
+	return string(password), nil
 }
@@ -8,79 +8,10 @@ import (
 	"runtime"
 	"sync"
 
-	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
 	"gopkg.in/check.v1"
 )
 
-func (s *S) TestSendEmail(c *check.C) {
-	defer s.server.Reset()
-	err := sendEmail("something@tsuru.io", []byte("Hello world!"))
-	c.Assert(err, check.IsNil)
-	s.server.Lock()
-	defer s.server.Unlock()
-	m := s.server.MailBox[0]
-	c.Assert(m.To, check.DeepEquals, []string{"something@tsuru.io"})
-	c.Assert(m.From, check.Equals, "root")
-	c.Assert(m.Data, check.DeepEquals, []byte("Hello world!\r\n"))
-}
-
-func (s *S) TestSendEmailUndefinedSMTPServer(c *check.C) {
-	old, _ := config.Get("smtp:server")
-	defer config.Set("smtp:server", old)
-	config.Unset("smtp:server")
-	err := sendEmail("something@tsuru.io", []byte("Hello world!"))
-	c.Assert(err, check.NotNil)
-	c.Assert(err.Error(), check.Equals, `Setting "smtp:server" is not defined`)
-}
-
-func (s *S) TestSendEmailUndefinedUser(c *check.C) {
-	old, _ := config.Get("smtp:user")
-	defer config.Set("smtp:user", old)
-	config.Unset("smtp:user")
-	err := sendEmail("something@tsuru.io", []byte("Hello world!"))
-	c.Assert(err, check.NotNil)
-	c.Assert(err.Error(), check.Equals, `Setting "smtp:user" is not defined`)
-}
-
-func (s *S) TestSendEmailUndefinedSMTPPassword(c *check.C) {
-	defer s.server.Reset()
-	old, _ := config.Get("smtp:password")
-	defer config.Set("smtp:password", old)
-	config.Unset("smtp:password")
-	err := sendEmail("something@tsuru.io", []byte("Hello world!"))
-	c.Assert(err, check.IsNil)
-	s.server.Lock()
-	defer s.server.Unlock()
-	m := s.server.MailBox[0]
-	c.Assert(m.To, check.DeepEquals, []string{"something@tsuru.io"})
-	c.Assert(m.From, check.Equals, "root")
-	c.Assert(m.Data, check.DeepEquals, []byte("Hello world!\r\n"))
-}
-
-func (s *S) TestSMTPServer(c *check.C) {
-	var tests = []struct {
-		input   string
-		output  string
-		failure string
-	}{
-		{"smtp.gmail.com", "smtp.gmail.com:25", ""},
-		{"smtp.gmail.com:465", "smtp.gmail.com:465", ""},
-		{"", "", `Setting "smtp:server" is not defined`},
-	}
-	old, _ := config.Get("smtp:server")
-	defer config.Set("smtp:server", old)
-	for _, t := range tests {
-		config.Set("smtp:server", t.input)
-		server, err := smtpServer()
-		if t.failure != "" {
-			c.Check(err, check.ErrorMatches, t.failure)
-		} else {
-			c.Check(err, check.IsNil)
-		}
-		c.Check(server, check.Equals, t.output)
-	}
-}
-
 func (s *S) TestGeneratePassword(c *check.C) {
 	go runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
 	passwords := make([]string, 1000)
@@ -98,3 +29,37 @@ func (s *S) TestGeneratePassword(c *check.C) {
 		c.Check(p, check.Not(check.Equals), first)
 	}
 }
+
+func (s *S) TestGeneratePolicyPassword(c *check.C) {
+	policies := []auth.PasswordPolicy{
+		{MinLength: 6, MaxLength: 50},
+		{MinLength: 20, MaxLength: 72},
+		{MinLength: 4, MaxLength: 8, RequireUppercase: true, RequireDigit: true},
+		{MinLength: 4, MaxLength: 4, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true},
+	}
+	for _, policy := range policies {
+		for i := 0; i < 100; i++ {
+			password, err := generatePolicyPassword(policy)
+			c.Assert(err, check.IsNil)
+			c.Assert(policy.Validate(password), check.IsNil, check.Commentf("policy: %#v, password: %q", policy, password))
+		}
+	}
+}
+
+func (s *S) TestGeneratePolicyPasswordLength(c *check.C) {
+	password, err := generatePolicyPassword(auth.PasswordPolicy{MinLength: 6, MaxLength: 50})
+	c.Assert(err, check.IsNil)
+	c.Assert(password, check.HasLen, resetPasswordLen)
+	password, err = generatePolicyPassword(auth.PasswordPolicy{MinLength: 16, MaxLength: 50})
+	c.Assert(err, check.IsNil)
+	c.Assert(password, check.HasLen, 16)
+	password, err = generatePolicyPassword(auth.PasswordPolicy{MinLength: 6, MaxLength: 8})
+	c.Assert(err, check.IsNil)
+	c.Assert(password, check.HasLen, 8)
+}
+
+func (s *S) TestGeneratePolicyPasswordTooShort(c *check.C) {
+	policy := auth.PasswordPolicy{MinLength: 1, MaxLength: 3, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+	_, err := generatePolicyPassword(policy)
+	c.Assert(err, check.ErrorMatches, "unable to generate a password: the password policy requires 4 classes of characters in at most 3 characters")
+}
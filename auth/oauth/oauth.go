@@ -5,6 +5,7 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -18,7 +19,6 @@ import (
 	"github.com/tsuru/tsuru/auth/native"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
-	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 )
 
@@ -152,7 +152,10 @@ func (s *OAuthScheme) handleToken(t *oauth2.Token) (*Token, error) {
 	if email == "" {
 		return nil, ErrEmptyUserEmail
 	}
-	_, err = auth.GetUserByEmail(email)
+	user, err := auth.GetUserByEmail(email)
+	if err == nil && user.Suspended {
+		return nil, auth.ErrUserSuspended
+	}
 	if err != nil {
 		if err != auth.ErrUserNotFound {
 			return nil, err
@@ -161,7 +164,7 @@ func (s *OAuthScheme) handleToken(t *oauth2.Token) (*Token, error) {
 		if !registrationEnabled {
 			return nil, err
 		}
-		user := &auth.User{Email: email}
+		user = &auth.User{Email: email}
 		err = user.Create()
 		if err != nil {
 			return nil, err
@@ -189,11 +192,11 @@ func (s *OAuthScheme) Logout(token string) error {
 	return deleteToken(token)
 }
 
-func (s *OAuthScheme) Auth(header string) (auth.Token, error) {
-	token, err := getToken(header)
+func (s *OAuthScheme) Auth(ctx context.Context, header string) (auth.Token, error) {
+	token, err := getToken(ctx, header)
 	if err != nil {
 		nativeScheme := native.NativeScheme{}
-		token, nativeErr := nativeScheme.Auth(header)
+		token, nativeErr := nativeScheme.Auth(ctx, header)
 		if nativeErr == nil && token.IsAppToken() {
 			return token, nil
 		}
@@ -203,9 +206,13 @@ func (s *OAuthScheme) Auth(header string) (auth.Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	client := config.Client(context.Background(), &token.Token)
+	client := config.Client(ctx, &token.Token)
+	req, err := http.NewRequest(http.MethodGet, s.InfoUrl, nil)
+	if err != nil {
+		return nil, err
+	}
 	t0 := time.Now()
-	rsp, err := client.Get(s.InfoUrl)
+	rsp, err := client.Do(req.WithContext(ctx))
 	requestLatencies.Observe(time.Since(t0).Seconds())
 	if err != nil {
 		requestErrors.Inc()
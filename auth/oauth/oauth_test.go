@@ -6,6 +6,7 @@ package oauth
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 
@@ -54,7 +55,7 @@ func (s *S) TestOAuthLogin(c *check.C) {
 	c.Assert(s.bodies[0], check.Equals, "client_id=clientid&code=abcdefg&grant_type=authorization_code&redirect_uri=http%3A%2F%2Flocalhost&scope=myscope")
 	c.Assert(s.reqs[1].URL.Path, check.Equals, "/user")
 	c.Assert(s.reqs[1].Header.Get("Authorization"), check.Equals, "Bearer my_token")
-	dbToken, err := getToken("my_token")
+	dbToken, err := getToken(context.Background(), "my_token")
 	c.Assert(err, check.IsNil)
 	c.Assert(dbToken.AccessToken, check.Equals, "my_token")
 	c.Assert(dbToken.UserEmail, check.Equals, "rand@althor.com")
@@ -154,7 +155,7 @@ func (s *S) TestOAuthAuth(c *check.C) {
 	err := existing.save()
 	c.Assert(err, check.IsNil)
 	scheme := OAuthScheme{}
-	token, err := scheme.Auth("bearer myvalidtoken")
+	token, err := scheme.Auth(context.Background(), "bearer myvalidtoken")
 	c.Assert(err, check.IsNil)
 	c.Assert(s.reqs, check.HasLen, 1)
 	c.Assert(s.reqs[0].URL.Path, check.Equals, "/user")
@@ -173,7 +174,7 @@ func (s *S) TestOAuthAuthWithAppToken(c *check.C) {
 	scheme := OAuthScheme{}
 	appToken, err := scheme.AppLogin("myApp")
 	c.Assert(err, check.IsNil)
-	token, err := scheme.Auth("bearer " + appToken.GetValue())
+	token, err := scheme.Auth(context.Background(), "bearer "+appToken.GetValue())
 	c.Assert(err, check.IsNil)
 	c.Assert(s.reqs, check.HasLen, 0)
 	c.Assert(token.IsAppToken(), check.Equals, true)
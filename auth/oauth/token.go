@@ -5,6 +5,8 @@
 package oauth
 
 import (
+	"context"
+
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
@@ -45,8 +47,8 @@ func (t *Token) Permissions() ([]permission.Permission, error) {
 	return auth.BaseTokenPermission(t)
 }
 
-func getToken(header string) (*Token, error) {
-	conn, err := db.Conn()
+func getToken(ctx context.Context, header string) (*Token, error) {
+	conn, err := db.ConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +58,7 @@ func getToken(header string) (*Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	coll := collection()
-	defer coll.Close()
+	coll := tokensCollection(conn)
 	err = coll.Find(bson.M{"token.accesstoken": token}).One(&t)
 	if err != nil {
 		if err == mgo.ErrNotFound {
@@ -88,15 +89,19 @@ func (t *Token) save() error {
 }
 
 func collection() *storage.Collection {
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("Failed to connect to the database: %s", err)
+	}
+	return tokensCollection(conn)
+}
+
+func tokensCollection(conn *db.Storage) *storage.Collection {
 	name, err := config.GetString("auth:oauth:collection")
 	if err != nil {
 		name = "oauth_tokens"
 		log.Debugf("auth:oauth:collection not found using default value: %s.", name)
 	}
-	conn, err := db.Conn()
-	if err != nil {
-		log.Errorf("Failed to connect to the database: %s", err)
-	}
 	coll := conn.Collection(name)
 	coll.EnsureIndex(mgo.Index{Key: []string{"token.accesstoken"}})
 	return coll
@@ -5,6 +5,8 @@
 package oauth
 
 import (
+	"context"
+
 	"github.com/tsuru/tsuru/auth"
 	"golang.org/x/oauth2"
 	"gopkg.in/check.v1"
@@ -18,26 +20,26 @@ func (s *S) TestGetToken(c *check.C) {
 	coll := collection()
 	defer coll.Close()
 	coll.Find(nil).All(&result)
-	t, err := getToken("bearer myvalidtoken")
+	t, err := getToken(context.Background(), "bearer myvalidtoken")
 	c.Assert(err, check.IsNil)
 	c.Assert(t.AccessToken, check.Equals, "myvalidtoken")
 	c.Assert(t.UserEmail, check.Equals, "x@x.com")
 }
 
 func (s *S) TestGetTokenEmptyToken(c *check.C) {
-	u, err := getToken("bearer tokenthatdoesnotexist")
+	u, err := getToken(context.Background(), "bearer tokenthatdoesnotexist")
 	c.Assert(u, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenNotFound(c *check.C) {
-	t, err := getToken("bearer invalid")
+	t, err := getToken(context.Background(), "bearer invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenInvalid(c *check.C) {
-	t, err := getToken("invalid")
+	t, err := getToken(context.Background(), "invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
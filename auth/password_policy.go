@@ -0,0 +1,84 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/tsuru/tsuru/errors"
+)
+
+// PasswordPolicy holds the rules passwords must follow. History is the number
+// of previous passwords, including the current one, that can't be reused and
+// MaxAgeDays is the number of days after which a password expires. Zero
+// disables each of them.
+type PasswordPolicy struct {
+	MinLength        int  `json:"minLength"`
+	MaxLength        int  `json:"maxLength"`
+	RequireUppercase bool `json:"requireUppercase,omitempty"`
+	RequireLowercase bool `json:"requireLowercase,omitempty"`
+	RequireDigit     bool `json:"requireDigit,omitempty"`
+	RequireSymbol    bool `json:"requireSymbol,omitempty"`
+	History          int  `json:"history,omitempty"`
+	MaxAgeDays       int  `json:"maxAgeDays,omitempty"`
+}
+
+// PasswordPolicyScheme is implemented by schemes managing passwords under a
+// password policy.
+type PasswordPolicyScheme interface {
+	Scheme
+	PasswordPolicy() (PasswordPolicy, error)
+}
+
+// Validate checks the password against the length and complexity rules of
+// the policy, returning a *errors.ValidationError describing the rules the
+// password breaks.
+func (p PasswordPolicy) Validate(password string) error {
+	length := len([]rune(password))
+	if length < p.MinLength || (p.MaxLength > 0 && length > p.MaxLength) {
+		return &errors.ValidationError{
+			Message: fmt.Sprintf("password length should be least %d characters and at most %d characters", p.MinLength, p.MaxLength),
+		}
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	var missing []string
+	if p.RequireUppercase && !upper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return &errors.ValidationError{Message: "password must contain " + joinRules(missing)}
+	}
+	return nil
+}
+
+func joinRules(rules []string) string {
+	if len(rules) == 1 {
+		return rules[0]
+	}
+	return strings.Join(rules[:len(rules)-1], ", ") + " and " + rules[len(rules)-1]
+}
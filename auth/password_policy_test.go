@@ -0,0 +1,22 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestPasswordPolicyValidate(c *check.C) {
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 20}
+	c.Assert(policy.Validate("password"), check.IsNil)
+	c.Assert(policy.Validate("short"), check.ErrorMatches, "password length should be least 8 characters and at most 20 characters")
+	c.Assert(policy.Validate("averyveryverylongpassword"), check.ErrorMatches, "password length should be least 8 characters and at most 20 characters")
+	policy.RequireUppercase = true
+	policy.RequireLowercase = true
+	policy.RequireDigit = true
+	policy.RequireSymbol = true
+	c.Assert(policy.Validate("password"), check.ErrorMatches, "password must contain an uppercase letter, a digit and a symbol")
+	c.Assert(policy.Validate("PASSWORD1"), check.ErrorMatches, "password must contain a lowercase letter and a symbol")
+	c.Assert(policy.Validate("Passw0rd"), check.ErrorMatches, "password must contain a symbol")
+	c.Assert(policy.Validate("Passw0rd!"), check.IsNil)
+}
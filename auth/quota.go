@@ -14,9 +14,10 @@ import (
 
 // ReserveApp reserves an app for the user, reserving it in the database. It's
 // used to reserve the app in the user quota, returning an error when there
-// isn't any space available.
+// isn't any space available. Once reserved, the quota of user is set to the
+// one the app was reserved from, as read right before the reservation.
 func ReserveApp(user *User) error {
-	user, err := checkUser(user.Email)
+	current, err := checkUser(user.Email)
 	if err != nil {
 		return err
 	}
@@ -26,19 +27,22 @@ func ReserveApp(user *User) error {
 	}
 	defer conn.Close()
 	err = conn.Users().Update(
-		bson.M{"email": user.Email, "quota.inuse": user.InUse},
+		bson.M{"email": current.Email, "quota.inuse": current.InUse},
 		bson.M{"$inc": bson.M{"quota.inuse": 1}},
 	)
 	for err == mgo.ErrNotFound {
-		user, err = checkUser(user.Email)
+		current, err = checkUser(current.Email)
 		if err != nil {
 			return err
 		}
 		err = conn.Users().Update(
-			bson.M{"email": user.Email, "quota.inuse": user.InUse},
+			bson.M{"email": current.Email, "quota.inuse": current.InUse},
 			bson.M{"$inc": bson.M{"quota.inuse": 1}},
 		)
 	}
+	if err == nil {
+		user.Quota = current.Quota
+	}
 	return err
 }
 
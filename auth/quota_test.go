@@ -33,6 +33,23 @@ func (s *S) TestReserveApp(c *check.C) {
 	c.Assert(user.Quota.InUse, check.Equals, 1)
 }
 
+func (s *S) TestReserveAppSetsReservedQuota(c *check.C) {
+	email := "seven@corp.globo.com"
+	user := &User{
+		Email: email, Password: "123456",
+		Quota: quota.Quota{Limit: 4, InUse: 0},
+	}
+	err := user.Create()
+	c.Assert(err, check.IsNil)
+	defer user.Delete()
+	stale := *user
+	err = ReserveApp(user)
+	c.Assert(err, check.IsNil)
+	err = ReserveApp(&stale)
+	c.Assert(err, check.IsNil)
+	c.Assert(stale.Quota, check.DeepEquals, quota.Quota{Limit: 4, InUse: 1})
+}
+
 func (s *S) TestReserveAppUserNotFound(c *check.C) {
 	user := User{Email: "hills@waaaat.com"}
 	err := ReserveApp(&user)
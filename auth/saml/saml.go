@@ -5,6 +5,7 @@
 package saml
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -159,6 +160,9 @@ func (s *SAMLAuthScheme) Login(params map[string]string) (auth.Token, error) {
 		return nil, ErrRequestWaitingForCredentials
 	}
 	user, err := auth.GetUserByEmail(req.Email)
+	if err == nil && user.Suspended {
+		return nil, auth.ErrUserSuspended
+	}
 	if err != nil {
 		if err != auth.ErrUserNotFound {
 			return nil, err
@@ -252,8 +256,8 @@ func (s *SAMLAuthScheme) Logout(token string) error {
 	return deleteToken(token)
 }
 
-func (s *SAMLAuthScheme) Auth(token string) (auth.Token, error) {
-	return getToken(token)
+func (s *SAMLAuthScheme) Auth(ctx context.Context, token string) (auth.Token, error) {
+	return getToken(ctx, token)
 }
 
 func (s *SAMLAuthScheme) Name() string {
@@ -6,6 +6,7 @@ package saml
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"io"
 	"os"
@@ -445,7 +446,7 @@ func (s *S) TestSamlAuth(c *check.C) {
 	user := auth.User{Email: "x@x.com"}
 	token, _ := createToken(&user)
 	scheme := SAMLAuthScheme{}
-	strtoken, err := scheme.Auth("bearer " + token.GetValue())
+	strtoken, err := scheme.Auth(context.Background(), "bearer "+token.GetValue())
 	c.Assert(err, check.IsNil)
 	c.Assert(token.GetValue(), check.Equals, strtoken.GetValue())
 }
@@ -470,7 +471,7 @@ func (s *S) TestSamlAuthWithAppToken(c *check.C) {
 	scheme := SAMLAuthScheme{}
 	appToken, err := scheme.AppLogin("myApp")
 	c.Assert(err, check.IsNil)
-	token, err := scheme.Auth("bearer " + appToken.GetValue())
+	token, err := scheme.Auth(context.Background(), "bearer "+appToken.GetValue())
 	c.Assert(err, check.IsNil)
 	c.Assert(s.reqs, check.HasLen, 0)
 	c.Assert(token.IsAppToken(), check.Equals, true)
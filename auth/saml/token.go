@@ -5,6 +5,7 @@
 package saml
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"fmt"
@@ -38,6 +39,13 @@ func (t *Token) GetValue() string {
 	return t.Token
 }
 
+func (t *Token) GetExpiration() time.Time {
+	if t.Expires <= 0 {
+		return time.Time{}
+	}
+	return t.Creation.Add(t.Expires)
+}
+
 func (t *Token) User() (*auth.User, error) {
 	return auth.GetUserByEmail(t.UserEmail)
 }
@@ -152,8 +160,8 @@ func createToken(u *auth.User) (*Token, error) {
 	return token, err
 }
 
-func getToken(header string) (*Token, error) {
-	conn, err := db.Conn()
+func getToken(ctx context.Context, header string) (*Token, error) {
+	conn, err := db.ConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +179,7 @@ func getToken(header string) (*Token, error) {
 		return nil, err
 	}
 	if t.Expires > 0 && time.Until(t.Creation.Add(t.Expires)) < 1 {
-		return nil, auth.ErrInvalidToken
+		return nil, auth.ErrTokenExpired
 	}
 	return &t, nil
 }
@@ -5,6 +5,8 @@
 package saml
 
 import (
+	"context"
+
 	"github.com/tsuru/tsuru/auth"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -17,26 +19,26 @@ func (s *S) TestGetToken(c *check.C) {
 	count, err := s.conn.Tokens().Find(bson.M{"useremail": "x@x.com"}).Count()
 	c.Assert(err, check.IsNil)
 	c.Assert(count, check.Equals, 1)
-	t, err := getToken("bearer " + token.Token)
+	t, err := getToken(context.Background(), "bearer "+token.Token)
 	c.Assert(err, check.IsNil)
 	c.Assert(t.Token, check.Equals, token.Token)
 	c.Assert(t.UserEmail, check.Equals, "x@x.com")
 }
 
 func (s *S) TestGetTokenEmptyToken(c *check.C) {
-	u, err := getToken("bearer tokenthatdoesnotexist")
+	u, err := getToken(context.Background(), "bearer tokenthatdoesnotexist")
 	c.Assert(u, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenNotFound(c *check.C) {
-	t, err := getToken("bearer invalid")
+	t, err := getToken(context.Background(), "bearer invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenInvalid(c *check.C) {
-	t, err := getToken("invalid")
+	t, err := getToken(context.Background(), "invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
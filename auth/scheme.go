@@ -4,7 +4,11 @@
 
 package auth
 
-import "github.com/pkg/errors"
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
 
 type SchemeInfo map[string]interface{}
 
@@ -13,7 +17,7 @@ type Scheme interface {
 	AppLogout(token string) error
 	Login(params map[string]string) (Token, error)
 	Logout(token string) error
-	Auth(token string) (Token, error)
+	Auth(ctx context.Context, token string) (Token, error)
 	Info() (SchemeInfo, error)
 	Name() string
 	Create(user *User) (*User, error)
@@ -27,6 +31,13 @@ type ManagedScheme interface {
 	ChangePassword(token Token, oldPassword string, newPassword string) error
 }
 
+// RefreshScheme is implemented by schemes able to replace a session token
+// with a new one, extending the session without asking for credentials.
+type RefreshScheme interface {
+	Scheme
+	Refresh(token Token) (Token, error)
+}
+
 type AuthenticationFailure struct {
 	Message string
 }
@@ -4,7 +4,11 @@
 
 package auth
 
-import "gopkg.in/check.v1"
+import (
+	"context"
+
+	"gopkg.in/check.v1"
+)
 
 type TestScheme struct{}
 
@@ -20,7 +24,7 @@ func (t TestScheme) Login(params map[string]string) (Token, error) {
 func (t TestScheme) Logout(token string) error {
 	return nil
 }
-func (t TestScheme) Auth(token string) (Token, error) {
+func (t TestScheme) Auth(ctx context.Context, token string) (Token, error) {
 	return nil, nil
 }
 func (t TestScheme) Info() (SchemeInfo, error) {
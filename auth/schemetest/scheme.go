@@ -0,0 +1,327 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schemetest provides a fake, in-memory, auth scheme for use in
+// tests.
+//
+// Users can use the fake scheme by just importing this package and setting
+// the "auth:scheme" setting to "fake", or by using the Scheme value directly.
+// Users and tokens managed by the fake scheme are never stored in the
+// database, so tokens returned by it can be used without MongoDB, as long as
+// the code being tested doesn't look users up by itself.
+//
+// This package also includes some helper functions that allow to interact with
+// the unexported state of the fake scheme, allowing users of the package to
+// reset the scheme, set permissions of users or check which tokens are valid.
+package schemetest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func init() {
+	auth.RegisterScheme("fake", Scheme)
+}
+
+var (
+	ErrEmailRegistered  = &errors.ConflictError{Message: "this email is already registered"}
+	ErrPasswordMismatch = &errors.NotAuthorizedError{Message: "the given password didn't match the user's current password"}
+)
+
+// Scheme is the fake scheme registered as "fake".
+var Scheme auth.ManagedScheme = &scheme
+
+var scheme = fakeScheme{
+	users:  make(map[string]*fakeUser),
+	tokens: make(map[string]*Token),
+}
+
+type fakeUser struct {
+	user        auth.User
+	permissions []permission.Permission
+	resetToken  string
+}
+
+type fakeScheme struct {
+	sync.Mutex
+	users  map[string]*fakeUser
+	tokens map[string]*Token
+}
+
+// Token is the token type returned by the fake scheme.
+type Token struct {
+	Value       string
+	UserEmail   string
+	AppName     string
+	permissions []permission.Permission
+}
+
+func (t *Token) GetValue() string {
+	return t.Value
+}
+
+func (t *Token) GetAppName() string {
+	return t.AppName
+}
+
+func (t *Token) GetUserName() string {
+	return t.UserEmail
+}
+
+func (t *Token) IsAppToken() bool {
+	return t.AppName != ""
+}
+
+func (t *Token) User() (*auth.User, error) {
+	scheme.Lock()
+	defer scheme.Unlock()
+	u, ok := scheme.users[t.UserEmail]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	user := u.user
+	return &user, nil
+}
+
+func (t *Token) Permissions() ([]permission.Permission, error) {
+	scheme.Lock()
+	defer scheme.Unlock()
+	if t.IsAppToken() {
+		return t.permissions, nil
+	}
+	u, ok := scheme.users[t.UserEmail]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return u.permissions, nil
+}
+
+func (s *fakeScheme) newToken(email, appName string) *Token {
+	var buf [16]byte
+	rand.Read(buf[:])
+	t := &Token{Value: fmt.Sprintf("%x", buf), UserEmail: email, AppName: appName}
+	s.tokens[t.Value] = t
+	return t
+}
+
+func (s *fakeScheme) AppLogin(appName string) (auth.Token, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.newToken("", appName), nil
+}
+
+func (s *fakeScheme) AppLogout(token string) error {
+	return s.Logout(token)
+}
+
+func (s *fakeScheme) Login(params map[string]string) (auth.Token, error) {
+	s.Lock()
+	defer s.Unlock()
+	u, ok := s.users[params["email"]]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	if u.user.Password != params["password"] {
+		return nil, auth.AuthenticationFailure{}
+	}
+	return s.newToken(u.user.Email, ""), nil
+}
+
+func (s *fakeScheme) Logout(token string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.tokens[token]; !ok {
+		return auth.ErrInvalidToken
+	}
+	delete(s.tokens, token)
+	return nil
+}
+
+func (s *fakeScheme) Auth(ctx context.Context, header string) (auth.Token, error) {
+	value, err := auth.ParseToken(header)
+	if err != nil {
+		return nil, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	t, ok := s.tokens[value]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return t, nil
+}
+
+func (s *fakeScheme) Info() (auth.SchemeInfo, error) {
+	return nil, nil
+}
+
+func (s *fakeScheme) Name() string {
+	return "fake"
+}
+
+func (s *fakeScheme) Create(user *auth.User) (*auth.User, error) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.users[user.Email]; ok {
+		return nil, ErrEmailRegistered
+	}
+	s.users[user.Email] = &fakeUser{user: *user}
+	return user, nil
+}
+
+func (s *fakeScheme) Remove(user *auth.User) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.users[user.Email]; !ok {
+		return auth.ErrUserNotFound
+	}
+	delete(s.users, user.Email)
+	for value, t := range s.tokens {
+		if t.UserEmail == user.Email {
+			delete(s.tokens, value)
+		}
+	}
+	return nil
+}
+
+func (s *fakeScheme) StartPasswordReset(user *auth.User) error {
+	s.Lock()
+	defer s.Unlock()
+	u, ok := s.users[user.Email]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	var buf [8]byte
+	rand.Read(buf[:])
+	u.resetToken = fmt.Sprintf("%x", buf)
+	return nil
+}
+
+// ResetPassword sets the password of the user to "reset-<resetToken>".
+func (s *fakeScheme) ResetPassword(user *auth.User, resetToken string) error {
+	s.Lock()
+	defer s.Unlock()
+	u, ok := s.users[user.Email]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	if resetToken == "" || u.resetToken != resetToken {
+		return auth.ErrInvalidToken
+	}
+	u.resetToken = ""
+	u.user.Password = "reset-" + resetToken
+	user.Password = u.user.Password
+	return nil
+}
+
+func (s *fakeScheme) ChangePassword(token auth.Token, oldPassword string, newPassword string) error {
+	s.Lock()
+	defer s.Unlock()
+	u, ok := s.users[token.GetUserName()]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	if u.user.Password != oldPassword {
+		return ErrPasswordMismatch
+	}
+	u.user.Password = newPassword
+	return nil
+}
+
+// Reset removes all users and tokens from the fake scheme.
+func Reset() {
+	scheme.Lock()
+	defer scheme.Unlock()
+	scheme.users = make(map[string]*fakeUser)
+	scheme.tokens = make(map[string]*Token)
+}
+
+// CreateUserWithPermission creates a user in the fake scheme, with the given
+// permissions, returning a valid token for it.
+func CreateUserWithPermission(email string, perms ...permission.Permission) *Token {
+	scheme.Lock()
+	defer scheme.Unlock()
+	scheme.users[email] = &fakeUser{
+		user:        auth.User{Email: email},
+		permissions: perms,
+	}
+	return scheme.newToken(email, "")
+}
+
+// SetPermissions replaces the permissions of the user with the given email.
+func SetPermissions(email string, perms ...permission.Permission) error {
+	scheme.Lock()
+	defer scheme.Unlock()
+	u, ok := scheme.users[email]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	u.permissions = perms
+	return nil
+}
+
+// SetAppTokenPermissions replaces the permissions of an application token
+// created by the fake scheme.
+func SetAppTokenPermissions(token string, perms ...permission.Permission) error {
+	scheme.Lock()
+	defer scheme.Unlock()
+	t, ok := scheme.tokens[token]
+	if !ok || !t.IsAppToken() {
+		return auth.ErrInvalidToken
+	}
+	t.permissions = perms
+	return nil
+}
+
+// Users returns the sorted list of emails of the users in the fake scheme.
+func Users() []string {
+	scheme.Lock()
+	defer scheme.Unlock()
+	emails := make([]string, 0, len(scheme.users))
+	for email := range scheme.users {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+// Password returns the current password of the user, allowing tests to check
+// the result of password changes and resets.
+func Password(email string) (string, error) {
+	scheme.Lock()
+	defer scheme.Unlock()
+	u, ok := scheme.users[email]
+	if !ok {
+		return "", auth.ErrUserNotFound
+	}
+	return u.user.Password, nil
+}
+
+// PasswordResetToken returns the token generated by the last call to
+// StartPasswordReset for the user, or an empty string.
+func PasswordResetToken(email string) string {
+	scheme.Lock()
+	defer scheme.Unlock()
+	if u, ok := scheme.users[email]; ok {
+		return u.resetToken
+	}
+	return ""
+}
+
+// IsValidToken returns whether the given token value is valid, i.e., it was
+// created by the fake scheme and not logged out.
+func IsValidToken(token string) bool {
+	scheme.Lock()
+	defer scheme.Unlock()
+	_, ok := scheme.tokens[token]
+	return ok
+}
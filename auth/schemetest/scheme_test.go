@@ -0,0 +1,130 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schemetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+var _ = check.Suite(&Suite{})
+
+type Suite struct{}
+
+func (Suite) SetUpTest(c *check.C) {
+	Reset()
+}
+
+func (Suite) TestRegistration(c *check.C) {
+	s, err := auth.GetScheme("fake")
+	c.Assert(err, check.IsNil)
+	c.Assert(s, check.Equals, Scheme)
+	c.Assert(s.Name(), check.Equals, "fake")
+}
+
+func (Suite) TestCreateAndRemove(c *check.C) {
+	_, err := Scheme.Create(&auth.User{Email: "me@tsuru.io", Password: "123456"})
+	c.Assert(err, check.IsNil)
+	c.Assert(Users(), check.DeepEquals, []string{"me@tsuru.io"})
+	_, err = Scheme.Create(&auth.User{Email: "me@tsuru.io"})
+	c.Assert(err, check.Equals, ErrEmailRegistered)
+	token, err := Scheme.Login(map[string]string{"email": "me@tsuru.io", "password": "123456"})
+	c.Assert(err, check.IsNil)
+	err = Scheme.Remove(&auth.User{Email: "me@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	c.Assert(Users(), check.HasLen, 0)
+	c.Assert(IsValidToken(token.GetValue()), check.Equals, false)
+	err = Scheme.Remove(&auth.User{Email: "me@tsuru.io"})
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (Suite) TestLoginAuthLogout(c *check.C) {
+	_, err := Scheme.Create(&auth.User{Email: "me@tsuru.io", Password: "123456"})
+	c.Assert(err, check.IsNil)
+	_, err = Scheme.Login(map[string]string{"email": "me@tsuru.io", "password": "wrong"})
+	c.Assert(err, check.FitsTypeOf, auth.AuthenticationFailure{})
+	_, err = Scheme.Login(map[string]string{"email": "other@tsuru.io", "password": "123456"})
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+	token, err := Scheme.Login(map[string]string{"email": "me@tsuru.io", "password": "123456"})
+	c.Assert(err, check.IsNil)
+	c.Assert(token.GetUserName(), check.Equals, "me@tsuru.io")
+	c.Assert(token.IsAppToken(), check.Equals, false)
+	authToken, err := Scheme.Auth(context.Background(), "bearer "+token.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(authToken, check.Equals, token)
+	user, err := authToken.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(user.Email, check.Equals, "me@tsuru.io")
+	err = Scheme.Logout(token.GetValue())
+	c.Assert(err, check.IsNil)
+	_, err = Scheme.Auth(context.Background(), token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	err = Scheme.Logout(token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (Suite) TestAppLogin(c *check.C) {
+	token, err := Scheme.AppLogin("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(token.IsAppToken(), check.Equals, true)
+	c.Assert(token.GetAppName(), check.Equals, "myapp")
+	perm := permission.Permission{Scheme: permission.PermAppRead, Context: permission.Context(permission.CtxApp, "myapp")}
+	err = SetAppTokenPermissions(token.GetValue(), perm)
+	c.Assert(err, check.IsNil)
+	perms, err := token.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, []permission.Permission{perm})
+	err = Scheme.AppLogout(token.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(IsValidToken(token.GetValue()), check.Equals, false)
+}
+
+func (Suite) TestCreateUserWithPermission(c *check.C) {
+	perm := permission.Permission{Scheme: permission.PermAll, Context: permission.Context(permission.CtxGlobal, "")}
+	token := CreateUserWithPermission("admin@tsuru.io", perm)
+	c.Assert(IsValidToken(token.GetValue()), check.Equals, true)
+	c.Assert(permission.Check(token, permission.PermAppCreate), check.Equals, true)
+	err := SetPermissions("admin@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(permission.Check(token, permission.PermAppCreate), check.Equals, false)
+	err = SetPermissions("other@tsuru.io")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (Suite) TestChangePassword(c *check.C) {
+	token := CreateUserWithPermission("me@tsuru.io")
+	err := Scheme.ChangePassword(token, "wrong", "abcdef")
+	c.Assert(err, check.Equals, ErrPasswordMismatch)
+	err = Scheme.ChangePassword(token, "", "abcdef")
+	c.Assert(err, check.IsNil)
+	password, err := Password("me@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(password, check.Equals, "abcdef")
+}
+
+func (Suite) TestResetPassword(c *check.C) {
+	CreateUserWithPermission("me@tsuru.io")
+	user := &auth.User{Email: "me@tsuru.io"}
+	err := Scheme.ResetPassword(user, "")
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	err = Scheme.StartPasswordReset(user)
+	c.Assert(err, check.IsNil)
+	resetToken := PasswordResetToken("me@tsuru.io")
+	c.Assert(resetToken, check.Not(check.Equals), "")
+	err = Scheme.ResetPassword(user, "invalid")
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+	err = Scheme.ResetPassword(user, resetToken)
+	c.Assert(err, check.IsNil)
+	c.Assert(user.Password, check.Equals, "reset-"+resetToken)
+	c.Assert(PasswordResetToken("me@tsuru.io"), check.Equals, "")
+}
@@ -0,0 +1,39 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Login parameters set by the API with the origin of the login request.
+// They override any value sent by the client.
+const (
+	LoginParamRemoteAddr = "remote-addr"
+	LoginParamUserAgent  = "user-agent"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is an active session token of a user. The value of the token is
+// never exposed, sessions are identified by ID.
+type Session struct {
+	ID         string    `json:"id"`
+	Creation   time.Time `json:"creation"`
+	Expires    time.Time `json:"expires,omitempty"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	Current    bool      `json:"current,omitempty"`
+}
+
+// SessionScheme is implemented by schemes able to list and revoke the
+// session tokens of the user owning a token.
+type SessionScheme interface {
+	Scheme
+	Sessions(token Token) ([]Session, error)
+	RevokeSession(token Token, id string) error
+}
@@ -7,10 +7,12 @@ package auth
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"gopkg.in/mgo.v2"
@@ -21,6 +23,8 @@ var (
 	ErrInvalidTeamName   = errors.New("invalid team name")
 	ErrTeamAlreadyExists = errors.New("team already exists")
 	ErrTeamNotFound      = errors.New("team not found")
+	ErrParentNotFound    = errors.New("parent team not found")
+	ErrTeamCycle         = errors.New("a team can't be a descendant of itself")
 
 	teamNameRegexp = regexp.MustCompile(`^[a-zA-Z][-@_.+\w]+$`)
 )
@@ -28,19 +32,36 @@ var (
 type ErrTeamStillUsed struct {
 	Apps             []string
 	ServiceInstances []string
+	Teams            []string
 }
 
 func (e *ErrTeamStillUsed) Error() string {
+	var refs []string
 	if len(e.Apps) > 0 {
-		return fmt.Sprintf("Apps: %s", strings.Join(e.Apps, ", "))
+		refs = append(refs, fmt.Sprintf("Apps: %s", strings.Join(e.Apps, ", ")))
 	}
-	return fmt.Sprintf("Service instances: %s", strings.Join(e.ServiceInstances, ", "))
+	if len(e.ServiceInstances) > 0 {
+		refs = append(refs, fmt.Sprintf("Service instances: %s", strings.Join(e.ServiceInstances, ", ")))
+	}
+	if len(e.Teams) > 0 {
+		refs = append(refs, fmt.Sprintf("Child teams: %s", strings.Join(e.Teams, ", ")))
+	}
+	return strings.Join(refs, "\n")
 }
 
 // Team represents a real world team, a team has one creating user and a name.
+// Teams may have a parent team, roles granted in the context of the parent
+// also apply to its child teams.
 type Team struct {
 	Name         string `bson:"_id" json:"name"`
 	CreatingUser string
+	Parent       string `bson:",omitempty" json:"parent,omitempty"`
+}
+
+// TeamNode is a team in the team hierarchy, along with its child teams.
+type TeamNode struct {
+	Name     string     `json:"name"`
+	Children []TeamNode `json:"children,omitempty"`
 }
 
 // AllowedApps returns the apps that the team has access.
@@ -136,16 +157,22 @@ func RemoveTeam(teamName string) error {
 	if err != nil {
 		return err
 	}
-	if len(apps) > 0 {
-		return &ErrTeamStillUsed{Apps: apps}
-	}
 	var serviceInstances []string
 	err = conn.ServiceInstances().Find(bson.M{"teams": teamName}).Distinct("name", &serviceInstances)
 	if err != nil {
 		return err
 	}
-	if len(serviceInstances) > 0 {
-		return &ErrTeamStillUsed{ServiceInstances: serviceInstances}
+	var childTeams []Team
+	err = conn.Teams().Find(bson.M{"parent": teamName}).All(&childTeams)
+	if err != nil {
+		return err
+	}
+	var children []string
+	for _, t := range childTeams {
+		children = append(children, t.Name)
+	}
+	if len(apps) > 0 || len(serviceInstances) > 0 || len(children) > 0 {
+		return &ErrTeamStillUsed{Apps: apps, ServiceInstances: serviceInstances, Teams: children}
 	}
 	err = conn.Teams().RemoveId(teamName)
 	if err == mgo.ErrNotFound {
@@ -154,6 +181,75 @@ func RemoveTeam(teamName string) error {
 	return nil
 }
 
+// RenameTeam changes the name of a team, updating the references to it in
+// apps, services, service instances, pools, registry credentials, child
+// teams and in the roles granted to users in the team context.
+func RenameTeam(oldName, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if !isTeamNameValid(newName) {
+		return ErrInvalidTeamName
+	}
+	team, err := GetTeam(oldName)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Teams().Insert(Team{Name: newName, CreatingUser: team.CreatingUser, Parent: team.Parent})
+	if mgo.IsDup(err) {
+		return ErrTeamAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	roles, err := permission.ListRoles()
+	if err != nil {
+		return err
+	}
+	teamRoles := []string{}
+	for _, r := range roles {
+		if r.ContextType == permission.CtxTeam {
+			teamRoles = append(teamRoles, r.Name)
+		}
+	}
+	renames := []struct {
+		coll  *storage.Collection
+		query bson.M
+		field string
+	}{
+		{conn.Apps(), bson.M{"teams": oldName}, "teams.$"},
+		{conn.Apps(), bson.M{"teamowner": oldName}, "teamowner"},
+		{conn.ServiceInstances(), bson.M{"teams": oldName}, "teams.$"},
+		{conn.ServiceInstances(), bson.M{"teamowner": oldName}, "teamowner"},
+		{conn.Services(), bson.M{"teams": oldName}, "teams.$"},
+		{conn.Services(), bson.M{"owner_teams": oldName}, "owner_teams.$"},
+		{conn.PoolsConstraints(), bson.M{"field": "team", "values": oldName}, "values.$"},
+		{conn.RegistryCredentials(), bson.M{"team": oldName}, "team"},
+		{conn.Teams(), bson.M{"parent": oldName}, "parent"},
+		{conn.Users(), bson.M{"roles": bson.M{"$elemMatch": bson.M{
+			"name":         bson.M{"$in": teamRoles},
+			"contextvalue": oldName,
+		}}}, "roles.$.contextvalue"},
+	}
+	for _, rename := range renames {
+		// The positional operator updates only the first matching element of
+		// each document, so updates are repeated until nothing matches.
+		for {
+			info, err := rename.coll.UpdateAll(rename.query, bson.M{"$set": bson.M{rename.field: newName}})
+			if err != nil {
+				return errors.Wrapf(err, "unable to rename team in %s", rename.coll.Name)
+			}
+			if info.Updated == 0 {
+				break
+			}
+		}
+	}
+	return conn.Teams().RemoveId(oldName)
+}
+
 func ListTeams() ([]Team, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -167,3 +263,105 @@ func ListTeams() ([]Team, error) {
 	}
 	return teams, nil
 }
+
+// SetTeamParent sets the parent of the team, an empty parent makes it a root
+// team. It returns ErrTeamCycle when the team is an ancestor of the parent.
+func SetTeamParent(name, parent string) error {
+	if _, err := GetTeam(name); err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if parent == "" {
+		return conn.Teams().UpdateId(name, bson.M{"$unset": bson.M{"parent": ""}})
+	}
+	for ancestor := parent; ancestor != ""; {
+		if ancestor == name {
+			return ErrTeamCycle
+		}
+		team, err := GetTeam(ancestor)
+		if err == ErrTeamNotFound {
+			return ErrParentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		ancestor = team.Parent
+	}
+	return conn.Teams().UpdateId(name, bson.M{"$set": bson.M{"parent": parent}})
+}
+
+// TeamChildren returns the names of the child teams of each team with
+// children.
+func TeamChildren() (map[string][]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var teams []Team
+	err = conn.Teams().Find(bson.M{"parent": bson.M{"$exists": true}}).All(&teams)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[string][]string)
+	for _, t := range teams {
+		children[t.Parent] = append(children[t.Parent], t.Name)
+	}
+	return children, nil
+}
+
+// TeamDescendants returns the names of all teams below the team in the
+// hierarchy described by children, as returned by TeamChildren.
+func TeamDescendants(children map[string][]string, name string) []string {
+	var descendants []string
+	visited := map[string]bool{name: true}
+	pending := children[name]
+	for len(pending) > 0 {
+		team := pending[0]
+		pending = pending[1:]
+		if visited[team] {
+			continue
+		}
+		visited[team] = true
+		descendants = append(descendants, team)
+		pending = append(pending, children[team]...)
+	}
+	return descendants
+}
+
+// BuildTeamTree arranges the teams in trees, sorted by name. Teams whose
+// parent isn't in the list are roots.
+func BuildTeamTree(teams []Team) []TeamNode {
+	names := make(map[string]bool, len(teams))
+	for _, t := range teams {
+		names[t.Name] = true
+	}
+	children := make(map[string][]string)
+	var roots []string
+	for _, t := range teams {
+		if t.Parent == "" || !names[t.Parent] {
+			roots = append(roots, t.Name)
+			continue
+		}
+		children[t.Parent] = append(children[t.Parent], t.Name)
+	}
+	visited := make(map[string]bool, len(teams))
+	var build func(names []string) []TeamNode
+	build = func(names []string) []TeamNode {
+		sort.Strings(names)
+		var nodes []TeamNode
+		for _, name := range names {
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			nodes = append(nodes, TeamNode{Name: name, Children: build(children[name])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
@@ -7,6 +7,7 @@ package auth
 import (
 	"sort"
 
+	"github.com/tsuru/tsuru/permission"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -136,6 +137,103 @@ func (s *S) TestRemoveTeamWithServiceInstances(c *check.C) {
 	c.Assert(err, check.ErrorMatches, "Service instances: vladimir")
 }
 
+func (s *S) TestRemoveTeamWithAppsAndServiceInstances(c *check.C) {
+	team := Team{Name: "atreides"}
+	err := s.conn.Teams().Insert(team)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(bson.M{"name": "leto", "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(bson.M{"name": "duncan", "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	err = RemoveTeam(team.Name)
+	c.Assert(err, check.DeepEquals, &ErrTeamStillUsed{Apps: []string{"leto"}, ServiceInstances: []string{"duncan"}})
+	c.Assert(err.Error(), check.Equals, "Apps: leto\nService instances: duncan")
+}
+
+func (s *S) TestRemoveTeamWithChildTeams(c *check.C) {
+	err := s.conn.Teams().Insert(Team{Name: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "fremen", Parent: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = RemoveTeam("atreides")
+	c.Assert(err, check.DeepEquals, &ErrTeamStillUsed{Teams: []string{"fremen"}})
+	c.Assert(err.Error(), check.Equals, "Child teams: fremen")
+}
+
+func (s *S) TestRenameTeam(c *check.C) {
+	err := s.conn.Teams().Insert(Team{Name: "atreides", CreatingUser: "leto@arrakis.com"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(bson.M{"name": "paul", "teamowner": "atreides", "teams": []string{"fremen", "atreides"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(bson.M{"name": "duncan", "teamowner": "atreides", "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Services().Insert(bson.M{"_id": "spice", "owner_teams": []string{"atreides"}, "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.PoolsConstraints().Insert(bson.M{"poolexpr": "caladan", "field": "team", "values": []string{"atreides", "fremen"}})
+	c.Assert(err, check.IsNil)
+	teamRole, err := permission.NewRole("house-member", "team", "")
+	c.Assert(err, check.IsNil)
+	globalRole, err := permission.NewRole("emperor", "global", "")
+	c.Assert(err, check.IsNil)
+	u := User{Email: "leto@arrakis.com", Password: "123456", Roles: []RoleInstance{
+		{Name: globalRole.Name, ContextValue: "atreides"},
+		{Name: teamRole.Name, ContextValue: "fremen"},
+		{Name: teamRole.Name, ContextValue: "atreides"},
+	}}
+	err = s.conn.Users().Insert(u)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "caladan", Parent: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = RenameTeam("atreides", "paul-atreides")
+	c.Assert(err, check.IsNil)
+	_, err = GetTeam("atreides")
+	c.Assert(err, check.Equals, ErrTeamNotFound)
+	team, err := GetTeam("paul-atreides")
+	c.Assert(err, check.IsNil)
+	c.Assert(team.CreatingUser, check.Equals, "leto@arrakis.com")
+	child, err := GetTeam("caladan")
+	c.Assert(err, check.IsNil)
+	c.Assert(child.Parent, check.Equals, "paul-atreides")
+	var doc bson.M
+	err = s.conn.Apps().Find(bson.M{"name": "paul"}).One(&doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(doc["teamowner"], check.Equals, "paul-atreides")
+	c.Assert(doc["teams"], check.DeepEquals, []interface{}{"fremen", "paul-atreides"})
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "duncan"}).One(&doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(doc["teamowner"], check.Equals, "paul-atreides")
+	c.Assert(doc["teams"], check.DeepEquals, []interface{}{"paul-atreides"})
+	err = s.conn.Services().FindId("spice").One(&doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(doc["owner_teams"], check.DeepEquals, []interface{}{"paul-atreides"})
+	c.Assert(doc["teams"], check.DeepEquals, []interface{}{"paul-atreides"})
+	err = s.conn.PoolsConstraints().Find(bson.M{"poolexpr": "caladan"}).One(&doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(doc["values"], check.DeepEquals, []interface{}{"paul-atreides", "fremen"})
+	dbUser, err := GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbUser.Roles, check.DeepEquals, []RoleInstance{
+		{Name: globalRole.Name, ContextValue: "atreides"},
+		{Name: teamRole.Name, ContextValue: "fremen"},
+		{Name: teamRole.Name, ContextValue: "paul-atreides"},
+	})
+}
+
+func (s *S) TestRenameTeamErrors(c *check.C) {
+	err := RenameTeam("atreides", "harkonnen")
+	c.Assert(err, check.Equals, ErrTeamNotFound)
+	err = s.conn.Teams().Insert(Team{Name: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "harkonnen"})
+	c.Assert(err, check.IsNil)
+	err = RenameTeam("atreides", "harkonnen")
+	c.Assert(err, check.Equals, ErrTeamAlreadyExists)
+	err = RenameTeam("atreides", "1nvalid")
+	c.Assert(err, check.Equals, ErrInvalidTeamName)
+	_, err = GetTeam("atreides")
+	c.Assert(err, check.IsNil)
+}
+
 func (s *S) TestListTeams(c *check.C) {
 	err := s.conn.Teams().Insert(Team{Name: "corrino"})
 	c.Assert(err, check.IsNil)
@@ -148,3 +246,82 @@ func (s *S) TestListTeams(c *check.C) {
 	sort.Strings(names)
 	c.Assert(names, check.DeepEquals, []string{"cobrateam", "corrino", "fenring"})
 }
+
+func (s *S) TestSetTeamParent(c *check.C) {
+	err := s.conn.Teams().Insert(Team{Name: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "fremen"})
+	c.Assert(err, check.IsNil)
+	err = SetTeamParent("fremen", "atreides")
+	c.Assert(err, check.IsNil)
+	team, err := GetTeam("fremen")
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Parent, check.Equals, "atreides")
+	err = SetTeamParent("fremen", "")
+	c.Assert(err, check.IsNil)
+	team, err = GetTeam("fremen")
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Parent, check.Equals, "")
+}
+
+func (s *S) TestSetTeamParentErrors(c *check.C) {
+	err := SetTeamParent("atreides", "")
+	c.Assert(err, check.Equals, ErrTeamNotFound)
+	err = s.conn.Teams().Insert(Team{Name: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "fremen", Parent: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "sietch", Parent: "fremen"})
+	c.Assert(err, check.IsNil)
+	err = SetTeamParent("atreides", "harkonnen")
+	c.Assert(err, check.Equals, ErrParentNotFound)
+	err = SetTeamParent("atreides", "atreides")
+	c.Assert(err, check.Equals, ErrTeamCycle)
+	err = SetTeamParent("atreides", "sietch")
+	c.Assert(err, check.Equals, ErrTeamCycle)
+	team, err := GetTeam("atreides")
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Parent, check.Equals, "")
+}
+
+func (s *S) TestTeamDescendants(c *check.C) {
+	err := s.conn.Teams().Insert(Team{Name: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "fremen", Parent: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "sietch", Parent: "fremen"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "caladan", Parent: "atreides"})
+	c.Assert(err, check.IsNil)
+	children, err := TeamChildren()
+	c.Assert(err, check.IsNil)
+	descendants := TeamDescendants(children, "atreides")
+	sort.Strings(descendants)
+	c.Assert(descendants, check.DeepEquals, []string{"caladan", "fremen", "sietch"})
+	c.Assert(TeamDescendants(children, "fremen"), check.DeepEquals, []string{"sietch"})
+	c.Assert(TeamDescendants(children, "sietch"), check.IsNil)
+}
+
+func (s *S) TestTeamDescendantsWithCycle(c *check.C) {
+	children := map[string][]string{"a": {"b"}, "b": {"a", "c"}}
+	c.Assert(TeamDescendants(children, "a"), check.DeepEquals, []string{"b", "c"})
+}
+
+func (s *S) TestBuildTeamTree(c *check.C) {
+	teams := []Team{
+		{Name: "sietch", Parent: "fremen"},
+		{Name: "atreides"},
+		{Name: "fremen", Parent: "atreides"},
+		{Name: "harkonnen"},
+		{Name: "caladan", Parent: "atreides"},
+		{Name: "giedi", Parent: "corrino"},
+	}
+	c.Assert(BuildTeamTree(teams), check.DeepEquals, []TeamNode{
+		{Name: "atreides", Children: []TeamNode{
+			{Name: "caladan"},
+			{Name: "fremen", Children: []TeamNode{{Name: "sietch"}}},
+		}},
+		{Name: "giedi"},
+		{Name: "harkonnen"},
+	})
+}
@@ -5,7 +5,9 @@
 package auth
 
 import (
+	"context"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/permission"
@@ -20,8 +22,34 @@ type Token interface {
 	Permissions() ([]permission.Permission, error)
 }
 
+// ExpirableToken is implemented by tokens with a limited lifetime.
+// GetExpiration returns the zero time for tokens that never expire.
+type ExpirableToken interface {
+	Token
+	GetExpiration() time.Time
+}
+
 var ErrInvalidToken = errors.New("Invalid token")
 
+// ErrTokenExpired is returned by schemes authenticating a token that was
+// valid but reached its expiration, the user must log in again.
+var ErrTokenExpired = errors.New("Token expired")
+
+type tokenContextKey struct{}
+
+// ContextWithToken returns a copy of ctx carrying the token authenticating
+// the operation, retrieved with TokenFromContext.
+func ContextWithToken(ctx context.Context, t Token) context.Context {
+	return context.WithValue(ctx, tokenContextKey{}, t)
+}
+
+// TokenFromContext returns the token carried by ctx, or nil when there's
+// none.
+func TokenFromContext(ctx context.Context) Token {
+	t, _ := ctx.Value(tokenContextKey{}).(Token)
+	return t
+}
+
 // ParseToken extracts token from a header:
 // 'type token' or 'token'
 func ParseToken(header string) (string, error) {
@@ -66,3 +94,11 @@ func BaseTokenPermission(t Token) ([]permission.Permission, error) {
 	}
 	return user.Permissions()
 }
+
+// ImpersonationToken is the token of a user acting on behalf of a team, e.g.
+// an admin running a support operation. Permissions are still the ones of the
+// authenticated user, events record the team as their acting owner.
+type ImpersonationToken struct {
+	Token
+	Team string
+}
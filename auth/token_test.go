@@ -4,7 +4,11 @@
 
 package auth
 
-import "gopkg.in/check.v1"
+import (
+	"context"
+
+	"gopkg.in/check.v1"
+)
 
 func (s *S) TestParseToken(c *check.C) {
 	t, err := ParseToken("type token")
@@ -20,3 +24,10 @@ func (s *S) TestParseToken(c *check.C) {
 	c.Assert(err, check.Equals, ErrInvalidToken)
 	c.Assert(t, check.Equals, "")
 }
+
+func (s *S) TestContextWithToken(c *check.C) {
+	t := &APIToken{Token: "abc", UserEmail: "me@tsuru.io"}
+	ctx := ContextWithToken(context.Background(), t)
+	c.Assert(TokenFromContext(ctx), check.Equals, t)
+	c.Assert(TokenFromContext(context.Background()), check.IsNil)
+}
@@ -27,11 +27,24 @@ var (
 	ErrUserNotFound = errors.New("user not found")
 	ErrInvalidKey   = errors.New("invalid key")
 	ErrKeyDisabled  = errors.New("key management is disabled")
+
+	// ErrUserSuspended is returned when authenticating or issuing tokens for
+	// a suspended user.
+	ErrUserSuspended = errors.New("user is suspended")
 )
 
+// RoleInstance is a role assigned to a user in a context. Roles assigned with
+// an expiration stop granting permissions after ExpiresAt and are removed by
+// the role reaper of the API.
 type RoleInstance struct {
 	Name         string
 	ContextValue string
+	ExpiresAt    time.Time `bson:",omitempty"`
+}
+
+// Expired returns whether the role has an expiration older than now.
+func (r RoleInstance) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
 }
 
 type User struct {
@@ -40,6 +53,14 @@ type User struct {
 	Password string
 	APIKey   string
 	Roles    []RoleInstance `bson:",omitempty"`
+	// PasswordHistory holds the hashes of the previous passwords of the
+	// user, most recent first, kept to enforce the password policy.
+	PasswordHistory   []string  `bson:",omitempty" json:"-"`
+	PasswordChangedAt time.Time `bson:",omitempty" json:"-"`
+	// Suspended users keep their records, like the ownership of events and
+	// apps, but can't log in and have no permissions.
+	Suspended   bool      `bson:",omitempty"`
+	SuspendedAt time.Time `bson:",omitempty"`
 }
 
 func listUsers(filter bson.M) ([]User, error) {
@@ -65,6 +86,12 @@ func ListUsersWithRole(role string) ([]User, error) {
 	return listUsers(bson.M{"roles.name": role})
 }
 
+// ListUsersWithRoleInContext returns the users the role is assigned to in the
+// given context value.
+func ListUsersWithRoleInContext(role, contextValue string) ([]User, error) {
+	return listUsers(bson.M{"roles": bson.M{"$elemMatch": bson.M{"name": role, "contextvalue": contextValue}}})
+}
+
 func ListUsersWithPermissions(wantedPerms ...permission.Permission) ([]User, error) {
 	allUsers, err := ListUsers()
 	if err != nil {
@@ -149,9 +176,47 @@ func (u *User) Delete() error {
 	if err != nil {
 		log.Errorf("failed to remove user %q from the repository manager: %s", u.Email, err)
 	}
+	err = removeNamedAPITokens(u.Email)
+	if err != nil {
+		log.Errorf("failed to remove api tokens of user %q: %s", u.Email, err)
+	}
+	err = removeMemberFromAllGroups(u.Email)
+	if err != nil {
+		log.Errorf("failed to remove user %q from groups: %s", u.Email, err)
+	}
 	return nil
 }
 
+// Suspend suspends the user, removing its API key, session tokens and named
+// API tokens. The user is kept, along with its roles, to be activated again
+// later.
+func (u *User) Suspend() error {
+	u.Suspended = true
+	u.SuspendedAt = time.Now().UTC()
+	u.APIKey = ""
+	err := u.Update()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Tokens().RemoveAll(bson.M{"useremail": u.Email})
+	if err != nil {
+		return err
+	}
+	return removeNamedAPITokens(u.Email)
+}
+
+// Activate lifts the suspension of the user, which must log in again.
+func (u *User) Activate() error {
+	u.Suspended = false
+	u.SuspendedAt = time.Time{}
+	return u.Update()
+}
+
 func (u *User) Update() error {
 	conn, err := db.Conn()
 	if err != nil {
@@ -202,6 +267,9 @@ func (u *User) createOnRepositoryManager() error {
 }
 
 func (u *User) ShowAPIKey() (string, error) {
+	if u.Suspended {
+		return "", ErrUserSuspended
+	}
 	if u.APIKey == "" {
 		u.RegenerateAPIKey()
 	}
@@ -209,6 +277,9 @@ func (u *User) ShowAPIKey() (string, error) {
 }
 
 func (u *User) RegenerateAPIKey() (string, error) {
+	if u.Suspended {
+		return "", ErrUserSuspended
+	}
 	random_byte := make([]byte, 32)
 	_, err := rand.Read(random_byte)
 	if err != nil {
@@ -232,11 +303,26 @@ func (u *User) Reload() error {
 }
 
 func (u *User) Permissions() ([]permission.Permission, error) {
+	if u.Suspended {
+		return nil, nil
+	}
 	permissions := []permission.Permission{
 		{Scheme: permission.PermUser, Context: permission.Context(permission.CtxUser, u.Email)},
 	}
+	groups, err := ListGroupsByMember(u.Email)
+	if err != nil {
+		return nil, err
+	}
+	roleInstances := append([]RoleInstance{}, u.Roles...)
+	for _, g := range groups {
+		roleInstances = append(roleInstances, g.Roles...)
+	}
 	roles := make(map[string]*permission.Role)
-	for _, roleData := range u.Roles {
+	now := time.Now()
+	for _, roleData := range roleInstances {
+		if roleData.Expired(now) {
+			continue
+		}
 		role := roles[roleData.Name]
 		if role == nil {
 			foundRole, err := permission.FindRole(roleData.Name)
@@ -248,6 +334,33 @@ func (u *User) Permissions() ([]permission.Permission, error) {
 		}
 		permissions = append(permissions, role.PermissionsFor(roleData.ContextValue)...)
 	}
+	return InheritTeamPermissions(permissions)
+}
+
+// InheritTeamPermissions extends the permissions granted in the context of a
+// team to all of its descendant teams.
+func InheritTeamPermissions(permissions []permission.Permission) ([]permission.Permission, error) {
+	var teamPermissions []permission.Permission
+	for _, p := range permissions {
+		if p.Context.CtxType == permission.CtxTeam {
+			teamPermissions = append(teamPermissions, p)
+		}
+	}
+	if len(teamPermissions) == 0 {
+		return permissions, nil
+	}
+	children, err := TeamChildren()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range teamPermissions {
+		for _, team := range TeamDescendants(children, p.Context.Value) {
+			permissions = append(permissions, permission.Permission{
+				Scheme:  p.Scheme,
+				Context: permission.Context(permission.CtxTeam, team),
+			})
+		}
+	}
 	return permissions, nil
 }
 
@@ -277,6 +390,72 @@ func (u *User) AddRole(roleName string, contextValue string) error {
 	return u.Reload()
 }
 
+// AddRoleWithExpiration assigns the role to the user until expiresAt,
+// replacing any previous expiration of the same role in the same context.
+// Assignments without expiration are kept: the role keeps being granted by
+// them after expiresAt.
+func (u *User) AddRoleWithExpiration(roleName string, contextValue string, expiresAt time.Time) error {
+	_, err := permission.FindRole(roleName)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Users().Update(bson.M{"email": u.Email}, bson.M{
+		"$pull": bson.M{
+			"roles": bson.M{"name": roleName, "contextvalue": contextValue, "expiresat": bson.M{"$exists": true}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = conn.Users().Update(bson.M{"email": u.Email}, bson.M{
+		"$push": bson.M{
+			"roles": bson.D([]bson.DocElem{
+				{Name: "name", Value: roleName},
+				{Name: "contextvalue", Value: contextValue},
+				{Name: "expiresat", Value: expiresAt.UTC()},
+			}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return u.Reload()
+}
+
+// ListUsersWithExpiredRoles returns the users with at least one role whose
+// expiration is older than now.
+func ListUsersWithExpiredRoles(now time.Time) ([]User, error) {
+	return listUsers(bson.M{"roles.expiresat": bson.M{"$lte": now.UTC()}})
+}
+
+// RemoveExpiredRole removes the given expiring role assignment from the user.
+// It's a no-op when the assignment was already removed or had its expiration
+// changed.
+func (u *User) RemoveExpiredRole(role RoleInstance) error {
+	if role.ExpiresAt.IsZero() {
+		return errors.New("the role assignment has no expiration")
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Users().Update(bson.M{"email": u.Email}, bson.M{
+		"$pull": bson.M{
+			"roles": bson.M{"name": role.Name, "contextvalue": role.ContextValue, "expiresat": role.ExpiresAt},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return u.Reload()
+}
+
 func RemoveRoleFromAllUsers(roleName string) error {
 	conn, err := db.Conn()
 	if err != nil {
@@ -6,6 +6,7 @@ package auth
 
 import (
 	"sort"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db"
@@ -377,6 +378,118 @@ func (s *S) TestRemoveRoleFromAllUsers(c *check.C) {
 	c.Assert(uDB.Roles, check.DeepEquals, expected)
 }
 
+func (s *S) TestAddRoleWithExpiration(c *check.C) {
+	_, err := permission.NewRole("oncall", "global", "")
+	c.Assert(err, check.IsNil)
+	u := User{Email: "me@tsuru.com", Password: "123"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	err = u.AddRoleWithExpiration("oncall", "", expiresAt.Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	err = u.AddRoleWithExpiration("oncall", "", expiresAt)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.HasLen, 1)
+	c.Assert(u.Roles[0].Name, check.Equals, "oncall")
+	c.Assert(u.Roles[0].ExpiresAt.Equal(expiresAt), check.Equals, true)
+	err = u.AddRole("oncall", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.HasLen, 2)
+}
+
+func (s *S) TestAddRoleWithExpirationRoleNotFound(c *check.C) {
+	u := User{Email: "me@tsuru.com", Password: "123"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRoleWithExpiration("oncall", "", time.Now().Add(time.Hour))
+	c.Assert(err, check.Equals, permission.ErrRoleNotFound)
+}
+
+func (s *S) TestUserPermissionsIgnoresExpiredRoles(c *check.C) {
+	r1, err := permission.NewRole("r1", "app", "")
+	c.Assert(err, check.IsNil)
+	err = r1.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	u := User{Email: "me@tsuru.com", Password: "123"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRoleWithExpiration("r1", "myapp", time.Now().Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	err = u.AddRoleWithExpiration("r1", "myapp2", time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	perms, err := u.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermUser, Context: permission.Context(permission.CtxUser, u.Email)},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxApp, "myapp2")},
+	})
+}
+
+func (s *S) TestListUsersWithExpiredRoles(c *check.C) {
+	_, err := permission.NewRole("r1", "app", "")
+	c.Assert(err, check.IsNil)
+	u1 := User{Email: "me1@tsuru.com", Password: "123"}
+	err = u1.Create()
+	c.Assert(err, check.IsNil)
+	err = u1.AddRoleWithExpiration("r1", "myapp", time.Now().Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	u2 := User{Email: "me2@tsuru.com", Password: "123"}
+	err = u2.Create()
+	c.Assert(err, check.IsNil)
+	err = u2.AddRoleWithExpiration("r1", "myapp", time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	err = u2.AddRole("r1", "myapp2")
+	c.Assert(err, check.IsNil)
+	users, err := ListUsersWithExpiredRoles(time.Now())
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 1)
+	c.Assert(users[0].Email, check.Equals, "me1@tsuru.com")
+}
+
+func (s *S) TestListUsersWithRoleInContext(c *check.C) {
+	_, err := permission.NewRole("r1", "team", "")
+	c.Assert(err, check.IsNil)
+	u1 := User{Email: "me1@tsuru.com", Password: "123"}
+	err = u1.Create()
+	c.Assert(err, check.IsNil)
+	err = u1.AddRole("r1", "team1")
+	c.Assert(err, check.IsNil)
+	u2 := User{Email: "me2@tsuru.com", Password: "123"}
+	err = u2.Create()
+	c.Assert(err, check.IsNil)
+	err = u2.AddRole("r1", "team2")
+	c.Assert(err, check.IsNil)
+	users, err := ListUsersWithRoleInContext("r1", "team1")
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 1)
+	c.Assert(users[0].Email, check.Equals, "me1@tsuru.com")
+}
+
+func (s *S) TestRemoveExpiredRole(c *check.C) {
+	_, err := permission.NewRole("r1", "app", "")
+	c.Assert(err, check.IsNil)
+	u := User{Email: "me@tsuru.com", Password: "123"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRole("r1", "myapp")
+	c.Assert(err, check.IsNil)
+	err = u.AddRoleWithExpiration("r1", "myapp", time.Now().Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.HasLen, 2)
+	err = u.RemoveExpiredRole(u.Roles[1])
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.DeepEquals, []RoleInstance{{Name: "r1", ContextValue: "myapp"}})
+	err = u.RemoveExpiredRole(u.Roles[0])
+	c.Assert(err, check.ErrorMatches, "the role assignment has no expiration")
+}
+
+func (s *S) TestRoleInstanceExpired(c *check.C) {
+	now := time.Now()
+	c.Assert(RoleInstance{Name: "r1"}.Expired(now), check.Equals, false)
+	c.Assert(RoleInstance{Name: "r1", ExpiresAt: now.Add(time.Second)}.Expired(now), check.Equals, false)
+	c.Assert(RoleInstance{Name: "r1", ExpiresAt: now}.Expired(now), check.Equals, true)
+}
+
 func (s *S) TestUserPermissions(c *check.C) {
 	u := User{Email: "me@tsuru.com", Password: "123"}
 	err := u.Create()
@@ -405,6 +518,31 @@ func (s *S) TestUserPermissions(c *check.C) {
 	})
 }
 
+func (s *S) TestUserPermissionsInheritedByChildTeams(c *check.C) {
+	err := s.conn.Teams().Insert(Team{Name: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "fremen", Parent: "atreides"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "sietch", Parent: "fremen"})
+	c.Assert(err, check.IsNil)
+	role, err := permission.NewRole("house-member", "team", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	u := User{Email: "me@tsuru.com", Password: "123"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRole(role.Name, "fremen")
+	c.Assert(err, check.IsNil)
+	perms, err := u.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermUser, Context: permission.Context(permission.CtxUser, u.Email)},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "fremen")},
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxTeam, "sietch")},
+	})
+}
+
 func (s *S) TestUserPermissionsWithRemovedRole(c *check.C) {
 	role, err := permission.NewRole("test", "team", "")
 	c.Assert(err, check.IsNil)
@@ -487,3 +625,52 @@ func (s *S) TestAddRolesForEvent(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(u.Roles, check.DeepEquals, []RoleInstance{{Name: "r1", ContextValue: "team1"}})
 }
+
+func (s *S) TestUserSuspend(c *check.C) {
+	u := User{Email: "me@tsuru.com", Password: "123", APIKey: "key"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	err = s.conn.Tokens().Insert(bson.M{"token": "abc", "useremail": u.Email})
+	c.Assert(err, check.IsNil)
+	_, err = CreateNamedAPIToken(&u, NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.IsNil)
+	err = u.Suspend()
+	c.Assert(err, check.IsNil)
+	uDB, err := GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(uDB.Suspended, check.Equals, true)
+	c.Assert(uDB.SuspendedAt.IsZero(), check.Equals, false)
+	c.Assert(uDB.APIKey, check.Equals, "")
+	count, err := s.conn.Tokens().Find(bson.M{"useremail": u.Email}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	tokens, err := ListNamedAPITokens(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 0)
+	perms, err := uDB.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.HasLen, 0)
+	_, err = uDB.RegenerateAPIKey()
+	c.Assert(err, check.Equals, ErrUserSuspended)
+	_, err = uDB.ShowAPIKey()
+	c.Assert(err, check.Equals, ErrUserSuspended)
+	_, err = CreateNamedAPIToken(uDB, NamedAPITokenOpts{Name: "ci"})
+	c.Assert(err, check.Equals, ErrUserSuspended)
+}
+
+func (s *S) TestUserActivate(c *check.C) {
+	u := User{Email: "me@tsuru.com", Password: "123"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.Suspend()
+	c.Assert(err, check.IsNil)
+	err = u.Activate()
+	c.Assert(err, check.IsNil)
+	uDB, err := GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(uDB.Suspended, check.Equals, false)
+	c.Assert(uDB.SuspendedAt.IsZero(), check.Equals, true)
+	perms, err := uDB.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.HasLen, 1)
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type apiToken struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt,omitempty"`
+	Permissions []string  `json:"permissions,omitempty"`
+	Contexts    []string  `json:"contexts,omitempty"`
+	Token       string    `json:"token,omitempty"`
+}
+
+func formatTokenExpiration(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return "never"
+	}
+	return expiresAt.Local().Format(time.RFC822)
+}
+
+type tokenCreate struct {
+	fs          *gnuflag.FlagSet
+	description string
+	expires     time.Duration
+	permissions StringSliceFlag
+	contexts    StringSliceFlag
+}
+
+func (c *tokenCreate) Info() *Info {
+	return &Info{
+		Name:  "token-create",
+		Usage: "token-create <name> [--description <description>] [--expires <duration>] [--permission <permission>]... [--context <type:value>]...",
+		Desc: `Creates a long-lived API token, to be used by non interactive clients, like CI
+systems, in the TSURU_TOKEN environment variable. The token has the
+permissions of the user, restricted to the given permissions when the
+[[--permission]] flag is used, and never expires unless [[--expires]] is
+given, e.g.: [[--expires 720h]].
+
+The [[--context]] flag restricts the token to the given contexts, in the
+<type>:<value> format. For example, a token created with [[--permission
+app.deploy --context team:myteam]] is only able to deploy apps of the team
+myteam, even if the user is able to do more.
+
+The value of the token is displayed only once, store it in a safe place.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *tokenCreate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("token-create", gnuflag.ExitOnError)
+		desc := "Description of the token"
+		c.fs.StringVar(&c.description, "description", "", desc)
+		c.fs.StringVar(&c.description, "d", "", desc)
+		expires := "Duration of the token, e.g.: 24h"
+		c.fs.DurationVar(&c.expires, "expires", 0, expires)
+		c.fs.DurationVar(&c.expires, "e", 0, expires)
+		perm := "Permission the token is restricted to, can be used multiple times"
+		c.fs.Var(&c.permissions, "permission", perm)
+		c.fs.Var(&c.permissions, "p", perm)
+		ctx := "Context the token is restricted to, in the <type>:<value> format, e.g.: team:myteam. Can be used multiple times"
+		c.fs.Var(&c.contexts, "context", ctx)
+		c.fs.Var(&c.contexts, "c", ctx)
+	}
+	return c.fs
+}
+
+func (c *tokenCreate) Run(context *Context, client *Client) error {
+	v := url.Values{}
+	v.Set("name", context.Args[0])
+	v.Set("description", c.description)
+	if c.expires > 0 {
+		v.Set("expires", c.expires.String())
+	}
+	for _, perm := range c.permissions {
+		v.Add("permission", perm)
+	}
+	for _, ctx := range c.contexts {
+		v.Add("context", ctx)
+	}
+	u, err := GetURLVersion("1.3", "/tokens")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var token apiToken
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(token)
+	}
+	fmt.Fprintf(context.Stdout, "Token %q successfully created, it expires: %s.\n", token.Name, formatTokenExpiration(token.ExpiresAt))
+	fmt.Fprintf(context.Stdout, "Token: %s\n", token.Token)
+	return nil
+}
+
+type tokenList struct{}
+
+func (tokenList) Info() *Info {
+	return &Info{
+		Name:  "token-list",
+		Usage: "token-list",
+		Desc:  "Lists the API tokens of the current user.",
+	}
+}
+
+func (tokenList) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/tokens")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var tokens []apiToken
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&tokens)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if tokens == nil {
+			tokens = []apiToken{}
+		}
+		return context.Render(tokens)
+	}
+	table := NewTable()
+	table.Headers = Row{"Name", "Description", "Permissions", "Contexts", "Created", "Expires"}
+	for _, t := range tokens {
+		perms := strings.Join(t.Permissions, "\n")
+		if perms == "" {
+			perms = "all"
+		}
+		contexts := strings.Join(t.Contexts, "\n")
+		if contexts == "" {
+			contexts = "all"
+		}
+		table.AddRow(Row{t.Name, t.Description, perms, contexts, t.CreatedAt.Local().Format(time.RFC822), formatTokenExpiration(t.ExpiresAt)})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type tokenRevoke struct{}
+
+func (tokenRevoke) Info() *Info {
+	return &Info{
+		Name:    "token-revoke",
+		Usage:   "token-revoke <name>",
+		Desc:    "Revokes the given API token, it can't be used anymore.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (tokenRevoke) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/tokens/"+url.PathEscape(context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Token %q successfully revoked!\n", context.Args[0])
+	return nil
+}
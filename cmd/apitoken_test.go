@@ -0,0 +1,89 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTokenCreateRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"ci"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"name": "ci", "createdAt": "2017-05-10T12:00:00Z", "token": "tsr_abc"}`,
+			Status:  http.StatusCreated,
+		},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/tokens" &&
+				req.Form.Get("name") == "ci" && req.Form.Get("description") == "deploys" &&
+				req.Form.Get("expires") == "24h0m0s" &&
+				len(req.Form["permission"]) == 2 && req.Form["permission"][1] == "app.read" &&
+				len(req.Form["context"]) == 1 && req.Form["context"][0] == "team:myteam"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := tokenCreate{}
+	err := command.Flags().Parse(true, []string{"-d", "deploys", "--expires", "24h", "-p", "app.deploy", "--permission", "app.read", "--context", "team:myteam"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Token \"ci\" successfully created, it expires: never.\nToken: tsr_abc\n")
+}
+
+func (s *S) TestTokenListRun(c *check.C) {
+	created := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	expires := time.Date(2017, 6, 10, 12, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"name": "ci", "description": "deploys", "createdAt": "2017-05-10T12:00:00Z", "expiresAt": "2017-06-10T12:00:00Z", "permissions": ["app.deploy"], "contexts": ["team:myteam"]},
+{"name": "other", "createdAt": "2017-05-10T12:00:00Z", "expiresAt": "0001-01-01T00:00:00Z"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/tokens"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := tokenList{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Name", "Description", "Permissions", "Contexts", "Created", "Expires"}
+	table.AddRow(Row{"ci", "deploys", "app.deploy", "team:myteam", created.Local().Format(time.RFC822), expires.Local().Format(time.RFC822)})
+	table.AddRow(Row{"other", "", "all", "all", created.Local().Format(time.RFC822), "never"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestTokenListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	err := tokenList{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestTokenRevokeRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"ci"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/tokens/ci"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := tokenRevoke{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Token \"ci\" successfully revoked!\n")
+}
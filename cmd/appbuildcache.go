@@ -0,0 +1,163 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+type buildCacheInfo struct {
+	Enabled    bool
+	Cleared    bool
+	BuildImage string
+	CacheImage string
+	Size       int64
+}
+
+type appBuildCache struct {
+	GuessingCommand
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *appBuildCache) Info() *Info {
+	return &Info{
+		Name:  "app-build-cache",
+		Usage: "app-build-cache [enable|disable|clear] [-a/--app appname] [-y/--assume-yes]",
+		Desc: `Manages the build cache of an app. Builds reuse the last image of the app,
+keeping files generated by previous builds (like downloaded dependencies),
+unless the cache is disabled or cleared.
+
+Without arguments, the command reports the state of the cache, the image the
+next build will start from and the size of the cached image, when reported by
+the provisioner of the app. The enable and disable arguments turn the cache
+on and off for all the next builds, while the clear argument discards the
+current cache, making only the next build start from the platform image.`,
+		MinArgs: 0,
+		MaxArgs: 1,
+	}
+}
+
+func (c *appBuildCache) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = MergeFlagSet(c.GuessingCommand.Flags(), c.ConfirmationCommand.Flags())
+	}
+	return c.fs
+}
+
+func (c *appBuildCache) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if len(context.Args) == 0 {
+		return c.info(appName, context, client)
+	}
+	switch context.Args[0] {
+	case "enable":
+		return c.set(appName, true, context, client)
+	case "disable":
+		return c.set(appName, false, context, client)
+	case "clear":
+		return c.clear(appName, context, client)
+	}
+	return errors.Errorf("invalid argument %q, use enable, disable or clear", context.Args[0])
+}
+
+func (c *appBuildCache) info(appName string, context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/build-cache")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var info buildCacheInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(info)
+	}
+	state := "enabled"
+	if !info.Enabled {
+		state = "disabled"
+	} else if info.Cleared {
+		state = "cleared, the next build starts from the platform image"
+	}
+	cacheImage, size := info.CacheImage, ""
+	if cacheImage == "" {
+		cacheImage = "-"
+	}
+	if info.Size > 0 {
+		size = formatBytes(info.Size)
+	}
+	fmt.Fprintf(context.Stdout, "Build cache: %s\n", state)
+	fmt.Fprintf(context.Stdout, "Build image: %s\n", info.BuildImage)
+	fmt.Fprintf(context.Stdout, "Cache image: %s\n", cacheImage)
+	if size != "" {
+		fmt.Fprintf(context.Stdout, "Cache size: %s\n", size)
+	}
+	return nil
+}
+
+func (c *appBuildCache) set(appName string, enabled bool, context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/build-cache")
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("enabled", strconv.FormatBool(enabled))
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		fmt.Fprintf(context.Stdout, "Build cache of app %q enabled.\n", appName)
+	} else {
+		fmt.Fprintf(context.Stdout, "Build cache of app %q disabled.\n", appName)
+	}
+	return nil
+}
+
+func (c *appBuildCache) clear(appName string, context *Context, client *Client) error {
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to clear the build cache of app %q?", appName)) {
+		return nil
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/build-cache")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Build cache of app %q cleared, the next build starts from the platform image.\n", appName)
+	return nil
+}
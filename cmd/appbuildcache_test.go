@@ -0,0 +1,136 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppBuildCacheInfo(c *check.C) {
+	c.Assert((&appBuildCache{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppBuildCacheRunReport(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Enabled":true,"Cleared":false,"BuildImage":"tsuru/app-web:v3","CacheImage":"tsuru/app-web:v3","Size":157286400}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/web/build-cache"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appBuildCache{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Build cache: enabled
+Build image: tsuru/app-web:v3
+Cache image: tsuru/app-web:v3
+Cache size: 150.0 MiB
+`)
+}
+
+func (s *S) TestAppBuildCacheRunReportCleared(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Enabled":true,"Cleared":true,"BuildImage":"tsuru/python:latest","CacheImage":""}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/web/build-cache"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appBuildCache{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Build cache: cleared, the next build starts from the platform image
+Build image: tsuru/python:latest
+Cache image: -
+`)
+}
+
+func (s *S) TestAppBuildCacheRunEnableDisable(c *check.C) {
+	tests := []struct {
+		arg     string
+		enabled string
+		output  string
+	}{
+		{"enable", "true", "Build cache of app \"web\" enabled.\n"},
+		{"disable", "false", "Build cache of app \"web\" disabled.\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		context := Context{Args: []string{tt.arg}, Stdout: &stdout, Stderr: &stderr}
+		enabled := tt.enabled
+		transport := cmdtest.ConditionalTransport{
+			Transport: cmdtest.Transport{Status: http.StatusOK},
+			CondFunc: func(req *http.Request) bool {
+				return req.Method == "PUT" && req.URL.Path == "/1.3/apps/web/build-cache" &&
+					req.FormValue("enabled") == enabled
+			},
+		}
+		client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+		command := appBuildCache{}
+		err := command.Flags().Parse(true, []string{"-a", "web"})
+		c.Assert(err, check.IsNil)
+		err = command.Run(&context, client)
+		c.Assert(err, check.IsNil)
+		c.Assert(stdout.String(), check.Equals, tt.output)
+	}
+}
+
+func (s *S) TestAppBuildCacheRunClear(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"clear"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/apps/web/build-cache"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appBuildCache{}
+	err := command.Flags().Parse(true, []string{"-a", "web", "-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Build cache of app \"web\" cleared, the next build starts from the platform image.\n")
+}
+
+func (s *S) TestAppBuildCacheRunClearAborted(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"clear"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("n\n")}
+	command := appBuildCache{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to clear the build cache of app "web"? (y/n) Abort.`+"\n")
+}
+
+func (s *S) TestAppBuildCacheRunInvalidArgument(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"purge"}, Stdout: &stdout, Stderr: &stderr}
+	command := appBuildCache{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid argument "purge", use enable, disable or clear`)
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type changelogEntry struct {
+	EventID string
+	Time    time.Time
+	Kind    string
+	Owner   string
+	Message string
+}
+
+type appChangelog struct {
+	GuessingCommand
+	fs     *gnuflag.FlagSet
+	limit  int
+	cursor string
+	all    bool
+}
+
+func (c *appChangelog) Info() *Info {
+	return &Info{
+		Name:  "app-changelog",
+		Usage: "app-changelog [-a/--app appname] [-l/--limit <number>] [--cursor <cursor>] [--all]",
+		Desc: `Shows the changes made to an app, newest first: deploys, environment
+variables, units and service bindings changes that finished successfully.
+
+The changes are shown in pages of [[--limit]] entries. When there are older
+changes, the command prints the cursor of the next page, to be used with the
+[[--cursor]] flag. The [[--all]] flag fetches all the pages.`,
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appChangelog) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		limit := "The number of changes in each page"
+		c.fs.IntVar(&c.limit, "limit", 0, limit)
+		c.fs.IntVar(&c.limit, "l", 0, limit)
+		c.fs.StringVar(&c.cursor, "cursor", "", "Show the changes starting at the given cursor")
+		c.fs.BoolVar(&c.all, "all", false, "Fetch all the pages of changes")
+	}
+	return c.fs
+}
+
+func (c *appChangelog) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	entries := []changelogEntry{}
+	cursor := c.cursor
+	for {
+		var next string
+		if context.Structured() {
+			var page []changelogEntry
+			page, next, err = c.fetchEntries(appName, cursor, client)
+			entries = append(entries, page...)
+		} else {
+			next, err = c.fetchText(appName, cursor, context.Stdout, client)
+		}
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			break
+		}
+		if !c.all {
+			if !context.Structured() {
+				fmt.Fprintf(context.Stdout, "\nUse --cursor %s to see older changes.\n", next)
+			}
+			break
+		}
+		cursor = next
+	}
+	if context.Structured() {
+		return context.Render(entries)
+	}
+	return nil
+}
+
+func (c *appChangelog) request(appName, cursor, format string, client *Client) (*http.Response, error) {
+	v := url.Values{}
+	if c.limit > 0 {
+		v.Set("limit", strconv.Itoa(c.limit))
+	}
+	if cursor != "" {
+		v.Set("cursor", cursor)
+	}
+	if format != "" {
+		v.Set("format", format)
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/changelog?"+v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(request)
+}
+
+func (c *appChangelog) fetchText(appName, cursor string, w io.Writer, client *Client) (string, error) {
+	resp, err := c.request(appName, cursor, "text", client)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		if cursor == "" {
+			fmt.Fprintln(w, "No changes found.")
+		}
+		return "", nil
+	}
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("X-Tsuru-Next-Cursor"), nil
+}
+
+func (c *appChangelog) fetchEntries(appName, cursor string, client *Client) ([]changelogEntry, string, error) {
+	resp, err := c.request(appName, cursor, "", client)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, "", nil
+	}
+	var page struct {
+		Entries []changelogEntry
+	}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil {
+		return nil, "", err
+	}
+	return page.Entries, resp.Header.Get("X-Tsuru-Next-Cursor"), nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppChangelogInfo(c *check.C) {
+	c.Assert((&appChangelog{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppChangelogRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: "2017-10-02 15:04:05 UTC  admin@example.com  Deployed version v3\n",
+			Status:  http.StatusOK,
+			Headers: map[string][]string{"X-Tsuru-Next-Cursor": {"59d26b2f1e1b7a0001a1c2d3"}},
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/web/changelog" &&
+				req.URL.Query().Get("format") == "text" && req.URL.Query().Get("limit") == "1" &&
+				req.URL.Query().Get("cursor") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appChangelog{}
+	err := command.Flags().Parse(true, []string{"-a", "web", "-l", "1"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `2017-10-02 15:04:05 UTC  admin@example.com  Deployed version v3
+
+Use --cursor 59d26b2f1e1b7a0001a1c2d3 to see older changes.
+`)
+}
+
+func (s *S) TestAppChangelogRunAll(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{
+					Message: "2017-10-02 15:04:05 UTC  admin@example.com  Deployed version v3\n",
+					Status:  http.StatusOK,
+					Headers: map[string][]string{"X-Tsuru-Next-Cursor": {"59d26b2f1e1b7a0001a1c2d3"}},
+				},
+				CondFunc: func(req *http.Request) bool {
+					return req.URL.Path == "/1.3/apps/web/changelog" && req.URL.Query().Get("cursor") == "" &&
+						req.URL.Query().Get("format") == "text"
+				},
+			},
+			{
+				Transport: cmdtest.Transport{
+					Message: "2017-10-01 10:00:00 UTC  admin@example.com  Added 2 units\n",
+					Status:  http.StatusOK,
+				},
+				CondFunc: func(req *http.Request) bool {
+					return req.URL.Path == "/1.3/apps/web/changelog" && req.URL.Query().Get("cursor") == "59d26b2f1e1b7a0001a1c2d3" &&
+						req.URL.Query().Get("format") == "text"
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appChangelog{}
+	err := command.Flags().Parse(true, []string{"-a", "web", "--all"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `2017-10-02 15:04:05 UTC  admin@example.com  Deployed version v3
+2017-10-01 10:00:00 UTC  admin@example.com  Added 2 units
+`)
+}
+
+func (s *S) TestAppChangelogRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := appChangelog{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No changes found.\n")
+}
+
+func (s *S) TestAppChangelogRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{
+					Message: `{"Entries":[{"EventID":"59d26b2f1e1b7a0001a1c2d4","Time":"2017-10-02T15:04:05Z","Kind":"app.deploy","Owner":"admin@example.com","Message":"Deployed version v3"}],"Next":"59d26b2f1e1b7a0001a1c2d4"}`,
+					Status:  http.StatusOK,
+					Headers: map[string][]string{"X-Tsuru-Next-Cursor": {"59d26b2f1e1b7a0001a1c2d4"}},
+				},
+				CondFunc: func(req *http.Request) bool {
+					return req.URL.Path == "/1.3/apps/web/changelog" && req.URL.Query().Get("format") == ""
+				},
+			},
+			{
+				Transport: cmdtest.Transport{Status: http.StatusNoContent},
+				CondFunc: func(req *http.Request) bool {
+					return req.URL.Path == "/1.3/apps/web/changelog" && req.URL.Query().Get("cursor") == "59d26b2f1e1b7a0001a1c2d4"
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appChangelog{}
+	err := command.Flags().Parse(true, []string{"-a", "web", "--all"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var entries []changelogEntry
+	err = json.Unmarshal(stdout.Bytes(), &entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Message, check.Equals, "Deployed version v3")
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type appClone struct {
+	fs             *gnuflag.FlagSet
+	teamOwner      string
+	pool           string
+	includeSecrets bool
+	newInstances   bool
+}
+
+func (c *appClone) Info() *Info {
+	return &Info{
+		Name:  "app-clone",
+		Usage: "app-clone <source-app> <new-app> [-t/--team <team>] [-o/--pool <pool>] [--include-secrets] [--new-instances]",
+		Desc: `Creates a new app as a copy of another app, like for review environments. The
+new app has the platform, plan, router, teams, tags, labels, environment
+variables and service bindings of the source app, nothing is deployed to it.
+
+Secret environment variables are copied only with the [[--include-secrets]]
+flag. By default the new app is bound to the same service instances of the
+source app, with the [[--new-instances]] flag new instances, with the same
+plans, are created for the new app, named after the source instance and the
+new app.
+
+The team owner and the pool of the source app are used unless the [[--team]]
+and the [[--pool]] flags are given.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *appClone) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("app-clone", gnuflag.ExitOnError)
+		team := "Team owner of the new app"
+		c.fs.StringVar(&c.teamOwner, "team", "", team)
+		c.fs.StringVar(&c.teamOwner, "t", "", team)
+		pool := "Pool of the new app"
+		c.fs.StringVar(&c.pool, "pool", "", pool)
+		c.fs.StringVar(&c.pool, "o", "", pool)
+		c.fs.BoolVar(&c.includeSecrets, "include-secrets", false, "Copy the values of secret environment variables")
+		c.fs.BoolVar(&c.newInstances, "new-instances", false, "Create new service instances for the new app")
+	}
+	return c.fs
+}
+
+func (c *appClone) Run(context *Context, client *Client) error {
+	v := url.Values{}
+	v.Set("name", context.Args[1])
+	if c.teamOwner != "" {
+		v.Set("teamowner", c.teamOwner)
+	}
+	if c.pool != "" {
+		v.Set("pool", c.pool)
+	}
+	v.Set("secrets", strconv.FormatBool(c.includeSecrets))
+	v.Set("newinstances", strconv.FormatBool(c.newInstances))
+	u, err := GetURLVersion("1.3", "/apps/"+context.Args[0]+"/clone")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return StreamJSONResponse(context.Stdout, resp)
+}
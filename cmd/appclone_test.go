@@ -0,0 +1,61 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppCloneInfo(c *check.C) {
+	c.Assert((&appClone{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppCloneRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"web", "web-review"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Message":"---- App \"web-review\" created from \"web\" ----\n"}
+{"Message":"App \"web-review\" cloned from \"web\".\n"}
+`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/web/clone" &&
+				req.FormValue("name") == "web-review" && req.FormValue("teamowner") == "" &&
+				req.FormValue("secrets") == "false" && req.FormValue("newinstances") == "false"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appClone{}
+	err := command.Flags().Parse(true, []string{})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "---- App \"web-review\" created from \"web\" ----\nApp \"web-review\" cloned from \"web\".\n")
+}
+
+func (s *S) TestAppCloneRunWithFlags(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"web", "web-review"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"Message":"done\n"}` + "\n", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.FormValue("teamowner") == "reviewers" && req.FormValue("pool") == "review" &&
+				req.FormValue("secrets") == "true" && req.FormValue("newinstances") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appClone{}
+	err := command.Flags().Parse(true, []string{"-t", "reviewers", "--pool", "review", "--include-secrets", "--new-instances"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "done\n")
+}
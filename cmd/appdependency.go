@@ -0,0 +1,284 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type appDependency struct {
+	App      string
+	Service  string
+	Instance string
+}
+
+func (d appDependency) String() string {
+	if d.App != "" {
+		return fmt.Sprintf("app %q", d.App)
+	}
+	return fmt.Sprintf("service instance %q", d.Service+"/"+d.Instance)
+}
+
+type dependencyNode struct {
+	Type string
+	Name string
+}
+
+type dependencyGraph struct {
+	App   string
+	Nodes []dependencyNode
+	Edges []struct {
+		From string
+		To   dependencyNode
+	}
+	Dependents []string
+}
+
+const appDependencyArgsDesc = `With one argument the dependency is the app with the given name, with two
+arguments it's the instance, in the second argument, of the service, in the
+first argument.`
+
+// dependencyFromArgs returns the dependency described by the arguments of
+// the commands, an app name or a service and an instance names.
+func dependencyFromArgs(args []string) appDependency {
+	if len(args) == 1 {
+		return appDependency{App: args[0]}
+	}
+	return appDependency{Service: args[0], Instance: args[1]}
+}
+
+type appDependencyAdd struct {
+	GuessingCommand
+}
+
+func (c *appDependencyAdd) Info() *Info {
+	return &Info{
+		Name:  "app-dependency-add",
+		Usage: "app-dependency-add <appname>|<servicename> <instancename> [-a/--app appname]",
+		Desc: `Makes the app depend on another app or on a service instance. Deploys, starts
+and restarts of the app wait until its dependencies are ready: apps with at
+least one started unit and service instances that are up.
+
+` + appDependencyArgsDesc,
+		MinArgs: 1,
+		MaxArgs: 2,
+	}
+}
+
+func (c *appDependencyAdd) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	dep := dependencyFromArgs(context.Args)
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/dependencies")
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("app", dep.App)
+	v.Set("service", dep.Service)
+	v.Set("instance", dep.Instance)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "App %q now depends on %s.\n", appName, dep)
+	return nil
+}
+
+type appDependencyRemove struct {
+	GuessingCommand
+}
+
+func (c *appDependencyRemove) Info() *Info {
+	return &Info{
+		Name:    "app-dependency-remove",
+		Usage:   "app-dependency-remove <appname>|<servicename> <instancename> [-a/--app appname]",
+		Desc:    "Removes a dependency of the app.\n\n" + appDependencyArgsDesc,
+		MinArgs: 1,
+		MaxArgs: 2,
+	}
+}
+
+func (c *appDependencyRemove) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	dep := dependencyFromArgs(context.Args)
+	v := url.Values{}
+	if dep.App != "" {
+		v.Set("app", dep.App)
+	} else {
+		v.Set("service", dep.Service)
+		v.Set("instance", dep.Instance)
+	}
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/dependencies?%s", appName, v.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "App %q no longer depends on %s.\n", appName, dep)
+	return nil
+}
+
+type appDependencyList struct {
+	GuessingCommand
+}
+
+func (c *appDependencyList) Info() *Info {
+	return &Info{
+		Name:    "app-dependency-list",
+		Usage:   "app-dependency-list [-a/--app appname]",
+		Desc:    "Lists the apps and service instances the app depends on.",
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appDependencyList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/dependencies")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var deps []appDependency
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&deps)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		return context.Render(deps)
+	}
+	table := NewTable()
+	table.Headers = Row{"Type", "Name"}
+	for _, d := range deps {
+		if d.App != "" {
+			table.AddRow(Row{"app", d.App})
+		} else {
+			table.AddRow(Row{"service instance", d.Service + "/" + d.Instance})
+		}
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type appDependencyGraph struct {
+	GuessingCommand
+	fs  *gnuflag.FlagSet
+	dot bool
+}
+
+func (c *appDependencyGraph) Info() *Info {
+	return &Info{
+		Name:  "app-dependency-graph",
+		Usage: "app-dependency-graph [-a/--app appname] [--dot]",
+		Desc: `Shows the dependencies of the app, direct or not, and the apps depending on
+it, the ones affected when the app is unavailable.
+
+With the --dot flag the graph is printed in the DOT language, that can be
+rendered by Graphviz, like in:
+
+  tsuru app-dependency-graph -a myapp --dot | dot -Tpng > myapp.png`,
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appDependencyGraph) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.BoolVar(&c.dot, "dot", false, "Print the graph in the DOT language")
+	}
+	return c.fs
+}
+
+func (c *appDependencyGraph) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	path := "/apps/" + appName + "/dependencies/graph"
+	if c.dot {
+		path += "?format=dot"
+	}
+	u, err := GetURLVersion("1.3", path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if c.dot {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		_, err = context.Stdout.Write(data)
+		return err
+	}
+	var graph dependencyGraph
+	err = json.NewDecoder(resp.Body).Decode(&graph)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(graph)
+	}
+	table := NewTable()
+	table.Headers = Row{"App", "Depends on"}
+	for _, e := range graph.Edges {
+		to := e.To.Name
+		if e.To.Type != "app" {
+			to += " (service instance)"
+		}
+		table.AddRow(Row{e.From, to})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	if len(graph.Dependents) > 0 {
+		fmt.Fprintf(context.Stdout, "Apps affected by %q: %s\n", graph.App, strings.Join(graph.Dependents, ", "))
+	}
+	return nil
+}
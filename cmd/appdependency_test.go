@@ -0,0 +1,166 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppDependencyAddInfo(c *check.C) {
+	c.Assert((&appDependencyAdd{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppDependencyAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"api"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/web/dependencies" &&
+				req.FormValue("app") == "api" && req.FormValue("service") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyAdd{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `App "web" now depends on app "api".`+"\n")
+}
+
+func (s *S) TestAppDependencyAddRunServiceInstance(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"mysql", "db"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/web/dependencies" &&
+				req.FormValue("app") == "" && req.FormValue("service") == "mysql" && req.FormValue("instance") == "db"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyAdd{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `App "web" now depends on service instance "mysql/db".`+"\n")
+}
+
+func (s *S) TestAppDependencyRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"mysql", "db"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/apps/web/dependencies" &&
+				req.URL.Query().Get("service") == "mysql" && req.URL.Query().Get("instance") == "db"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyRemove{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `App "web" no longer depends on service instance "mysql/db".`+"\n")
+}
+
+func (s *S) TestAppDependencyListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"App":"api"},{"Service":"mysql","Instance":"db"}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/web/dependencies"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyList{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+------------------+----------+
+| Type             | Name     |
++------------------+----------+
+| app              | api      |
+| service instance | mysql/db |
++------------------+----------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestAppDependencyListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyList{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "+------+------+\n| Type | Name |\n+------+------+\n+------+------+\n")
+}
+
+func (s *S) TestAppDependencyGraphRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"App":"api","Edges":[` +
+				`{"From":"api","To":{"Type":"service-instance","Name":"mysql/db"}},` +
+				`{"From":"web","To":{"Type":"app","Name":"api"}}],"Dependents":["web"]}`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/api/dependencies/graph" &&
+				req.URL.Query().Get("format") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyGraph{}
+	err := command.Flags().Parse(true, []string{"-a", "api"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+-----+-----------------------------+
+| App | Depends on                  |
++-----+-----------------------------+
+| api | mysql/db (service instance) |
+| web | api                         |
++-----+-----------------------------+
+Apps affected by "api": web
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestAppDependencyGraphRunDot(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	dot := "digraph \"api\" {\n\t\"api\" [shape=box];\n}\n"
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: dot, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.3/apps/api/dependencies/graph" && req.URL.Query().Get("format") == "dot"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDependencyGraph{}
+	err := command.Flags().Parse(true, []string{"-a", "api", "--dot"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, dot)
+}
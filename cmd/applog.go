@@ -0,0 +1,105 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+const logDateLayout = "2006-01-02 15:04:05 -0700"
+
+type appLogEntry struct {
+	Date    time.Time
+	Message string
+	Source  string
+	Unit    string
+}
+
+type appLogSearch struct {
+	GuessingCommand
+	fs     *gnuflag.FlagSet
+	lines  int
+	source string
+	unit   string
+}
+
+func (c *appLogSearch) Info() *Info {
+	return &Info{
+		Name:  "app-log-search",
+		Usage: "app-log-search [-a/--app appname] <terms>... [-l/--lines numberOfLines] [-s/--source source] [-u/--unit unit]",
+		Desc: `Searches the logs of the app, showing the last matching lines. With the
+default log storage the terms are looked for in the messages ignoring case, and
+with the Elasticsearch storage messages matching all the terms are shown.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *appLogSearch) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.IntVar(&c.lines, "lines", 10, "The number of log lines to display")
+		c.fs.IntVar(&c.lines, "l", 10, "The number of log lines to display")
+		c.fs.StringVar(&c.source, "source", "", "The log from the given source")
+		c.fs.StringVar(&c.source, "s", "", "The log from the given source")
+		c.fs.StringVar(&c.unit, "unit", "", "The log from the given unit")
+		c.fs.StringVar(&c.unit, "u", "", "The log from the given unit")
+	}
+	return c.fs
+}
+
+func (c *appLogSearch) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.lines <= 0 {
+		return errors.New("the number of lines must be a positive integer")
+	}
+	v := url.Values{}
+	v.Set("lines", strconv.Itoa(c.lines))
+	v.Set("search", strings.Join(context.Args, " "))
+	if c.source != "" {
+		v.Set("source", c.source)
+	}
+	if c.unit != "" {
+		v.Set("unit", c.unit)
+	}
+	u, err := GetURL(fmt.Sprintf("/apps/%s/log?%s", appName, v.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var logs []appLogEntry
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&logs)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		return context.Render(logs)
+	}
+	for _, l := range logs {
+		fmt.Fprintf(context.Stdout, "%s [%s][%s]: %s\n", l.Date.Local().Format(logDateLayout), l.Source, l.Unit, l.Message)
+	}
+	return nil
+}
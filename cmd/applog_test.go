@@ -0,0 +1,53 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppLogSearchInfo(c *check.C) {
+	c.Assert((&appLogSearch{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppLogSearchRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"connection", "refused"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Date":"2017-10-14T10:00:00Z","Message":"connection refused","Source":"web","AppName":"myapp","Unit":"u1"}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			q := req.URL.Query()
+			return req.Method == "GET" && req.URL.Path == "/1.0/apps/myapp/log" &&
+				q.Get("search") == "connection refused" && q.Get("lines") == "20" &&
+				q.Get("source") == "web" && q.Get("unit") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appLogSearch{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-l", "20", "-s", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	date := time.Date(2017, 10, 14, 10, 0, 0, 0, time.UTC).Local().Format(logDateLayout)
+	c.Assert(stdout.String(), check.Equals, date+" [web][u1]: connection refused\n")
+}
+
+func (s *S) TestAppLogSearchRunInvalidLines(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"error"}, Stdout: &stdout, Stderr: &stderr}
+	command := appLogSearch{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-l", "0"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the number of lines must be a positive integer")
+}
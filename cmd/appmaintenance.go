@@ -0,0 +1,63 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type appMaintenance struct {
+	GuessingCommand
+}
+
+func (c *appMaintenance) Info() *Info {
+	return &Info{
+		Name:  "app-maintenance",
+		Usage: "app-maintenance <on|off> [-a/--app appname]",
+		Desc: `Puts the app in maintenance or takes it out of maintenance. While in
+maintenance the router of the app answers all requests with a static
+maintenance page, the units of the app keep running and deploys of the app
+are blocked.
+
+The maintenance is recorded as an event of the app, running until the
+maintenance ends. Canceling this event with the event-cancel command also
+takes the app out of maintenance.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *appMaintenance) Run(context *Context, client *Client) error {
+	var method, msg string
+	switch context.Args[0] {
+	case "on":
+		method, msg = "POST", "App %q is now in maintenance.\n"
+	case "off":
+		method, msg = "DELETE", "App %q is no longer in maintenance.\n"
+	default:
+		return errors.Errorf("invalid argument %q, use on or off", context.Args[0])
+	}
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/maintenance")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, msg, appName)
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMaintenanceInfo(c *check.C) {
+	c.Assert((&appMaintenance{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppMaintenanceRunOn(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"on"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/web/maintenance"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appMaintenance{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "App \"web\" is now in maintenance.\n")
+}
+
+func (s *S) TestAppMaintenanceRunOff(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"off"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/apps/web/maintenance"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appMaintenance{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "App \"web\" is no longer in maintenance.\n")
+}
+
+func (s *S) TestAppMaintenanceRunInvalidArgument(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"maybe"}, Stdout: &stdout, Stderr: &stderr}
+	command := appMaintenance{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid argument "maybe", use on or off`)
+}
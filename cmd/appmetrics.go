@@ -0,0 +1,164 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+// sparklineWidth is the maximum number of characters of the sparklines,
+// longer series are averaged in buckets.
+const sparklineWidth = 30
+
+var sparklineTicks = []rune("▁▂▃▄▅▆▇█")
+
+type metricPoint struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+type unitMetrics struct {
+	ID        string
+	CPU       []metricPoint
+	Memory    []metricPoint
+	NetworkRx []metricPoint
+	NetworkTx []metricPoint
+}
+
+type appMetrics struct {
+	GuessingCommand
+	fs     *gnuflag.FlagSet
+	window string
+	step   string
+}
+
+func (c *appMetrics) Info() *Info {
+	return &Info{
+		Name:  "app-metrics",
+		Usage: "app-metrics [-a/--app appname] [-w/--window window] [--step step]",
+		Desc: `Shows the CPU, memory and network usage of each unit of the app over the
+window, like 30m or 6h, with one point every step. The default window is the
+last hour, and the default step splits the window in 60 points.
+
+The CPU is the percentage of one core, and the network is the rate of bytes
+received and sent per second. The last value is shown after the chart.`,
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appMetrics) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.StringVar(&c.window, "window", "1h", "The window of the metrics")
+		c.fs.StringVar(&c.window, "w", "1h", "The window of the metrics")
+		c.fs.StringVar(&c.step, "step", "", "The interval between the points of the metrics")
+	}
+	return c.fs
+}
+
+func (c *appMetrics) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	if c.window != "" {
+		v.Set("window", c.window)
+	}
+	if c.step != "" {
+		v.Set("step", c.step)
+	}
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/metrics?%s", appName, v.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var metrics []unitMetrics
+	err = json.NewDecoder(resp.Body).Decode(&metrics)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(metrics)
+	}
+	table := NewTable()
+	table.Headers = Row{"Unit", "CPU", "Memory", "Network In", "Network Out"}
+	for _, m := range metrics {
+		table.AddRow(Row{
+			m.ID,
+			formatMetric(m.CPU, func(v float64) string { return fmt.Sprintf("%.1f%%", v) }),
+			formatMetric(m.Memory, func(v float64) string { return formatBytes(int64(v)) }),
+			formatMetric(m.NetworkRx, formatByteRate),
+			formatMetric(m.NetworkTx, formatByteRate),
+		})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+func formatByteRate(v float64) string {
+	return formatBytes(int64(v)) + "/s"
+}
+
+func formatMetric(points []metricPoint, format func(float64) string) string {
+	if len(points) == 0 {
+		return "-"
+	}
+	values := make([]float64, len(points))
+	for i := range points {
+		values[i] = points[i].Value
+	}
+	return sparkline(values) + " " + format(values[len(values)-1])
+}
+
+// sparkline draws the values with block characters, scaled between the
+// minimum and the maximum values.
+func sparkline(values []float64) string {
+	if len(values) > sparklineWidth {
+		buckets := make([]float64, sparklineWidth)
+		for i := range buckets {
+			start, end := i*len(values)/sparklineWidth, (i+1)*len(values)/sparklineWidth
+			var sum float64
+			for _, v := range values[start:end] {
+				sum += v
+			}
+			buckets[i] = sum / float64(end-start)
+		}
+		values = buckets
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		tick := 0
+		if max > min {
+			tick = int((v - min) / (max - min) * float64(len(sparklineTicks)-1))
+		}
+		line[i] = sparklineTicks[tick]
+	}
+	return string(line)
+}
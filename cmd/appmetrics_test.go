@@ -0,0 +1,73 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMetricsInfo(c *check.C) {
+	c.Assert((&appMetrics{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppMetricsRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"ID":"myapp-0","CPU":[{"Value":10},{"Value":20},{"Value":80}],` +
+				`"Memory":[{"Value":67108864},{"Value":67108864}],` +
+				`"NetworkRx":[{"Value":1024}],"NetworkTx":[]}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/metrics" &&
+				req.URL.Query().Get("window") == "6h" && req.URL.Query().Get("step") == "10m"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appMetrics{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-w", "6h", "--step", "10m"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Unit", "CPU", "Memory", "Network In", "Network Out"}
+	table.AddRow(Row{"myapp-0", "▁▂█ 80.0%", "▁▁ 64.0 MiB", "▁ 1.0 KiB/s", "-"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestAppMetricsRunDefaultWindow(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `[]`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.3/apps/myapp/metrics" &&
+				req.URL.Query().Get("window") == "1h" && req.URL.Query().Get("step") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appMetrics{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestSparkline(c *check.C) {
+	c.Assert(sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}), check.Equals, "▁▂▃▄▅▆▇█")
+	c.Assert(sparkline([]float64{3, 3, 3}), check.Equals, "▁▁▁")
+	values := make([]float64, 60)
+	for i := 30; i < 60; i++ {
+		values[i] = 1
+	}
+	c.Assert(sparkline(values), check.Equals, strings.Repeat("▁", 15)+strings.Repeat("█", 15))
+}
@@ -0,0 +1,255 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+type reviewApp struct {
+	Name         string    `json:"name"`
+	Branch       string    `json:"branch"`
+	CName        []string  `json:"cname"`
+	LastActivity time.Time `json:"lastActivity"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+type appReviewAppsEnable struct {
+	GuessingCommand
+	fs             *gnuflag.FlagSet
+	gitURL         string
+	ttl            time.Duration
+	pool           string
+	includeSecrets bool
+	newInstances   bool
+}
+
+func (c *appReviewAppsEnable) Info() *Info {
+	return &Info{
+		Name:  "app-review-apps-enable",
+		Usage: "app-review-apps-enable --git-url <url> [-a/--app appname] [--ttl <duration>] [-o/--pool <pool>] [--include-secrets] [--new-instances]",
+		Desc: `Enables review apps for the app, which becomes the template of short-lived
+copies, one for each branch of the git repository, created and deployed when
+the branch is notified with the app-review-app-notify command, like by a
+webhook of the CI.
+
+Review apps are removed after no notifications of their branch for the
+duration in the [[--ttl]] flag, or the default TTL of the tsuru server. They
+are created like the app-clone command does, the [[--pool]],
+[[--include-secrets]] and [[--new-instances]] flags are the same of the
+app-clone command.`,
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appReviewAppsEnable) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.StringVar(&c.gitURL, "git-url", "", "The git repository whose branches are deployed to the review apps")
+		c.fs.DurationVar(&c.ttl, "ttl", 0, "For how long review apps without activity are kept, e.g. 48h")
+		pool := "Pool of the review apps"
+		c.fs.StringVar(&c.pool, "pool", "", pool)
+		c.fs.StringVar(&c.pool, "o", "", pool)
+		c.fs.BoolVar(&c.includeSecrets, "include-secrets", false, "Copy the values of secret environment variables")
+		c.fs.BoolVar(&c.newInstances, "new-instances", false, "Create new service instances for each review app")
+	}
+	return c.fs
+}
+
+func (c *appReviewAppsEnable) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.gitURL == "" {
+		return errors.New("the git url is required, use the --git-url flag")
+	}
+	v := url.Values{}
+	v.Set("git-url", c.gitURL)
+	if c.ttl > 0 {
+		v.Set("ttl", strconv.FormatFloat(c.ttl.Seconds(), 'f', -1, 64))
+	}
+	if c.pool != "" {
+		v.Set("pool", c.pool)
+	}
+	v.Set("secrets", strconv.FormatBool(c.includeSecrets))
+	v.Set("newinstances", strconv.FormatBool(c.newInstances))
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/review-apps")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Review apps enabled for app %q.\n", appName)
+	return nil
+}
+
+type appReviewAppsDisable struct {
+	GuessingCommand
+}
+
+func (c *appReviewAppsDisable) Info() *Info {
+	return &Info{
+		Name:  "app-review-apps-disable",
+		Usage: "app-review-apps-disable [-a/--app appname]",
+		Desc: `Disables review apps for the app. Existing review apps are kept until they're
+inactive for the default TTL of the tsuru server or closed with the
+app-review-app-notify command.`,
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appReviewAppsDisable) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/review-apps")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Review apps disabled for app %q.\n", appName)
+	return nil
+}
+
+type appReviewAppList struct {
+	GuessingCommand
+}
+
+func (c *appReviewAppList) Info() *Info {
+	return &Info{
+		Name:    "app-review-app-list",
+		Usage:   "app-review-app-list [-a/--app appname]",
+		Desc:    "Lists the review apps of the app, with the branches they deploy and when they expire.",
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appReviewAppList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/review-apps")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var apps []reviewApp
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&apps)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		return context.Render(apps)
+	}
+	table := NewTable()
+	table.Headers = Row{"App", "Branch", "CName", "Last Activity", "Expires At"}
+	for _, a := range apps {
+		table.AddRow(Row{
+			a.Name,
+			a.Branch,
+			strings.Join(a.CName, "\n"),
+			a.LastActivity.Local().Format(time.RFC822),
+			a.ExpiresAt.Local().Format(time.RFC822),
+		})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type appReviewAppNotify struct {
+	GuessingCommand
+	fs    *gnuflag.FlagSet
+	close bool
+}
+
+func (c *appReviewAppNotify) Info() *Info {
+	return &Info{
+		Name:  "app-review-app-notify",
+		Usage: "app-review-app-notify <branch> [-a/--app appname] [--close]",
+		Desc: `Notifies the app about activity in a branch of the git repository of its
+review apps. The review app of the branch is created, when it doesn't exist
+yet, and the branch is deployed to it. With the [[--close]] flag the review
+app of the branch is removed instead, like when the branch is merged.
+
+Review apps are named after the app and the branch, like "web-feature-login"
+for the branch "feature/login" of the app "web".`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *appReviewAppNotify) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.BoolVar(&c.close, "close", false, "Remove the review app of the branch")
+	}
+	return c.fs
+}
+
+func (c *appReviewAppNotify) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("branch", context.Args[0])
+	v.Set("action", "sync")
+	if c.close {
+		v.Set("action", "close")
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/review-apps")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return StreamJSONResponse(context.Stdout, resp)
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppReviewAppsEnableInfo(c *check.C) {
+	c.Assert((&appReviewAppsEnable{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppReviewAppsEnableRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/web/review-apps" &&
+				req.FormValue("git-url") == "https://github.com/tsuru/web.git" &&
+				req.FormValue("ttl") == "172800" && req.FormValue("pool") == "review" &&
+				req.FormValue("secrets") == "false" && req.FormValue("newinstances") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appReviewAppsEnable{}
+	err := command.Flags().Parse(true, []string{"-a", "web", "--git-url", "https://github.com/tsuru/web.git", "--ttl", "48h", "-o", "review", "--new-instances"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Review apps enabled for app \"web\".\n")
+}
+
+func (s *S) TestAppReviewAppsEnableRunWithoutGitURL(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	command := appReviewAppsEnable{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the git url is required, use the --git-url flag")
+}
+
+func (s *S) TestAppReviewAppsDisableRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/apps/web/review-apps"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appReviewAppsDisable{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Review apps disabled for app \"web\".\n")
+}
+
+func (s *S) TestAppReviewAppListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	lastActivity := time.Date(2017, 10, 2, 15, 4, 0, 0, time.UTC)
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"name":"web-feature-login","branch":"feature/login","cname":["feature-login.web.review.example.com"],` +
+				`"lastActivity":"2017-10-02T15:04:00Z","expiresAt":"2017-10-05T15:04:00Z"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/web/review-apps"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appReviewAppList{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"App", "Branch", "CName", "Last Activity", "Expires At"}
+	table.AddRow(Row{
+		"web-feature-login", "feature/login", "feature-login.web.review.example.com",
+		lastActivity.Local().Format(time.RFC822), lastActivity.Add(72 * time.Hour).Local().Format(time.RFC822),
+	})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestAppReviewAppListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appReviewAppList{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Matches, `(?s).*\| App.*\| Expires At \|.*`)
+}
+
+func (s *S) TestAppReviewAppNotifyRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"feature/login"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Message":"Review app \"web-feature-login\" of the branch \"feature/login\" deployed.\n"}` + "\n",
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/web/review-apps" &&
+				req.FormValue("branch") == "feature/login" && req.FormValue("action") == "sync"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appReviewAppNotify{}
+	err := command.Flags().Parse(true, []string{"-a", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Review app \"web-feature-login\" of the branch \"feature/login\" deployed.\n")
+}
+
+func (s *S) TestAppReviewAppNotifyRunClose(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"feature/login"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"Message":"removed\n"}` + "\n", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.FormValue("branch") == "feature/login" && req.FormValue("action") == "close"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appReviewAppNotify{}
+	err := command.Flags().Parse(true, []string{"-a", "web", "--close"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "removed\n")
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type appRun struct {
+	ID        string
+	Command   string
+	Owner     string
+	StartTime time.Time
+	EndTime   time.Time
+	Running   bool
+	Error     string
+	Output    string
+}
+
+type appRunStatus struct {
+	GuessingCommand
+}
+
+func (c *appRunStatus) Info() *Info {
+	return &Info{
+		Name:  "app-run-status",
+		Usage: "app-run-status [-a/--app appname] <id>",
+		Desc: `Shows the status and the output of a command started in detached mode in the
+app. While the command is running, the output is updated periodically.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *appRunStatus) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/runs/%s", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var run appRun
+	err = json.NewDecoder(resp.Body).Decode(&run)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(run)
+	}
+	status := ColorStatus("succeeded", true)
+	if run.Running {
+		status = "running"
+	} else if run.Error != "" {
+		status = ColorStatus("failed", false)
+	}
+	fmt.Fprintf(context.Stdout, "Command: %s\n", run.Command)
+	fmt.Fprintf(context.Stdout, "Started by: %s at %s\n", run.Owner, run.StartTime.Local().Format(time.RFC822))
+	fmt.Fprintf(context.Stdout, "Status: %s\n", status)
+	if run.Error != "" {
+		fmt.Fprintf(context.Stdout, "Error: %s\n", run.Error)
+	}
+	if run.Output != "" {
+		fmt.Fprintf(context.Stdout, "Output:\n%s", run.Output)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppRunStatusInfo(c *check.C) {
+	c.Assert((&appRunStatus{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppRunStatusRun(c *check.C) {
+	start := time.Date(2017, 5, 10, 3, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"59128a000000000000000001"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"ID": "59128a000000000000000001", "Command": "./migrate", "Owner": "me@tsuru.io",
+"StartTime": "2017-05-10T03:00:00Z", "EndTime": "2017-05-10T03:00:05Z", "Output": "migrated\n"}`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/runs/59128a000000000000000001"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appRunStatus{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Command: ./migrate\n" +
+		"Started by: me@tsuru.io at " + start.Local().Format(time.RFC822) + "\n" +
+		"Status: " + ColorStatus("succeeded", true) + "\n" +
+		"Output:\nmigrated\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestAppRunStatusRunRunning(c *check.C) {
+	start := time.Date(2017, 5, 10, 3, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"59128a000000000000000001"}}
+	transport := cmdtest.Transport{
+		Message: `{"ID": "59128a000000000000000001", "Command": "./migrate", "Owner": "me@tsuru.io",
+"StartTime": "2017-05-10T03:00:00Z", "Running": true}`,
+		Status: http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appRunStatus{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Command: ./migrate\n" +
+		"Started by: me@tsuru.io at " + start.Local().Format(time.RFC822) + "\n" +
+		"Status: running\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestAppRunStatusRunFailed(c *check.C) {
+	start := time.Date(2017, 5, 10, 3, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"59128a000000000000000001"}}
+	transport := cmdtest.Transport{
+		Message: `{"ID": "59128a000000000000000001", "Command": "./migrate", "Owner": "me@tsuru.io",
+"StartTime": "2017-05-10T03:00:00Z", "Error": "exit status 1", "Output": "no database\n"}`,
+		Status: http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appRunStatus{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Command: ./migrate\n" +
+		"Started by: me@tsuru.io at " + start.Local().Format(time.RFC822) + "\n" +
+		"Status: " + ColorStatus("failed", false) + "\n" +
+		"Error: exit status 1\n" +
+		"Output:\nno database\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
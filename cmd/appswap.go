@@ -0,0 +1,226 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+type gradualSwap struct {
+	ID           string        `json:"id"`
+	App1         string        `json:"app1"`
+	App2         string        `json:"app2"`
+	CNameOnly    bool          `json:"cnameOnly"`
+	Step         int           `json:"step"`
+	Interval     time.Duration `json:"interval"`
+	Weight       int           `json:"weight"`
+	Status       string        `json:"status"`
+	Owner        string        `json:"owner"`
+	Error        string        `json:"error,omitempty"`
+	NextStepTime time.Time     `json:"nextStepTime"`
+}
+
+type appSwapGradual struct {
+	fs        *gnuflag.FlagSet
+	step      int
+	interval  time.Duration
+	cnameOnly bool
+}
+
+func (c *appSwapGradual) Info() *Info {
+	return &Info{
+		Name:  "app-swap-gradual",
+		Usage: "app-swap-gradual <app1> <app2> [-s/--step <percent>] [-i/--interval <duration>] [-c/--cname-only]",
+		Desc: `Swaps the routes of two apps gradually, shifting the traffic of the first app
+to the units of the second app in increments of the percentage in the
+[[--step]] flag, one increment for each [[--interval]]. Once all the traffic
+is shifted, the apps are swapped like the app-swap command does.
+
+Gradual swaps require both apps using the same router, with support for
+weighted routes. They can be paused, resumed and aborted with the
+app-swap-pause, app-swap-resume and app-swap-abort commands. Aborting a swap
+shifts all the traffic back to the units of the first app.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *appSwapGradual) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("", gnuflag.ExitOnError)
+		step := "Percentage of the traffic shifted in each increment"
+		c.fs.IntVar(&c.step, "step", 10, step)
+		c.fs.IntVar(&c.step, "s", 10, step)
+		interval := "Time between increments, e.g. 5m"
+		c.fs.DurationVar(&c.interval, "interval", time.Minute, interval)
+		c.fs.DurationVar(&c.interval, "i", time.Minute, interval)
+		cnameOnly := "Swap only the cnames of the apps in the end"
+		c.fs.BoolVar(&c.cnameOnly, "cname-only", false, cnameOnly)
+		c.fs.BoolVar(&c.cnameOnly, "c", false, cnameOnly)
+	}
+	return c.fs
+}
+
+func (c *appSwapGradual) Run(context *Context, client *Client) error {
+	if c.step < 1 || c.step > 99 {
+		return errors.New("the step must be between 1 and 99 percent")
+	}
+	v := url.Values{}
+	v.Set("app1", context.Args[0])
+	v.Set("app2", context.Args[1])
+	v.Set("cnameOnly", strconv.FormatBool(c.cnameOnly))
+	v.Set("step", strconv.Itoa(c.step))
+	v.Set("interval", strconv.FormatFloat(c.interval.Seconds(), 'f', -1, 64))
+	u, err := GetURL("/swap")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var swap gradualSwap
+	err = json.NewDecoder(resp.Body).Decode(&swap)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Gradual swap %s started, %d%% of the traffic of app %q shifted to app %q.\n", swap.ID, swap.Weight, swap.App1, swap.App2)
+	return nil
+}
+
+type appSwapList struct{}
+
+func (c *appSwapList) Info() *Info {
+	return &Info{
+		Name:    "app-swap-list",
+		Usage:   "app-swap-list",
+		Desc:    "Lists the gradual swaps running or paused, with the percentage of the traffic already shifted.",
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *appSwapList) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/swaps")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var swaps []gradualSwap
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&swaps)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		return context.Render(swaps)
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "From", "To", "Shifted", "Step", "Status", "Next Step"}
+	for _, s := range swaps {
+		nextStep := ""
+		if s.Status == "running" {
+			nextStep = s.NextStepTime.Local().Format(time.RFC822)
+		}
+		table.AddRow(Row{
+			s.ID,
+			s.App1,
+			s.App2,
+			fmt.Sprintf("%d%%", s.Weight),
+			fmt.Sprintf("%d%% every %s", s.Step, s.Interval),
+			s.Status,
+			nextStep,
+		})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+// appSwapUpdate changes the status of a gradual swap, running the action
+// given in the update endpoint of swaps.
+type appSwapUpdate struct {
+	action string
+	desc   string
+	msg    string
+}
+
+func (c *appSwapUpdate) Info() *Info {
+	name := "app-swap-" + c.action
+	return &Info{
+		Name:    name,
+		Usage:   name + " <swap-id>",
+		Desc:    c.desc,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *appSwapUpdate) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/swaps/"+context.Args[0])
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("action", c.action)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, c.msg, context.Args[0])
+	return nil
+}
+
+func newAppSwapPause() *appSwapUpdate {
+	return &appSwapUpdate{
+		action: "pause",
+		desc:   "Pauses a gradual swap, keeping the traffic already shifted until the swap is resumed or aborted.",
+		msg:    "Gradual swap %s paused.\n",
+	}
+}
+
+func newAppSwapResume() *appSwapUpdate {
+	return &appSwapUpdate{
+		action: "resume",
+		desc:   "Resumes a paused gradual swap, shifting the next increment of the traffic without waiting for its interval.",
+		msg:    "Gradual swap %s resumed.\n",
+	}
+}
+
+func newAppSwapAbort() *appSwapUpdate {
+	return &appSwapUpdate{
+		action: "abort",
+		desc:   "Aborts a gradual swap, shifting all the traffic back to the units of the first app.",
+		msg:    "Gradual swap %s aborted.\n",
+	}
+}
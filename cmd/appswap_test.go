@@ -0,0 +1,133 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppSwapGradualInfo(c *check.C) {
+	c.Assert((&appSwapGradual{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppSwapGradualRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{
+		Args:   []string{"web", "web-blue"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"id":"59d26b2f1e1b7a0001a1c2d3","app1":"web","app2":"web-blue","weight":20,"status":"running"}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.0/swap" &&
+				req.FormValue("app1") == "web" && req.FormValue("app2") == "web-blue" &&
+				req.FormValue("step") == "20" && req.FormValue("interval") == "300" &&
+				req.FormValue("cnameOnly") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appSwapGradual{}
+	err := command.Flags().Parse(true, []string{"-s", "20", "--interval", "5m", "-c"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Gradual swap 59d26b2f1e1b7a0001a1c2d3 started, 20% of the traffic of app \"web\" shifted to app \"web-blue\".\n")
+}
+
+func (s *S) TestAppSwapGradualRunInvalidStep(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{
+		Args:   []string{"web", "web-blue"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	command := appSwapGradual{}
+	err := command.Flags().Parse(true, []string{"--step", "100"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the step must be between 1 and 99 percent")
+}
+
+func (s *S) TestAppSwapListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	nextStep := time.Date(2017, 10, 2, 15, 4, 0, 0, time.UTC)
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"id":"59d26b2f1e1b7a0001a1c2d3","app1":"web","app2":"web-blue","step":20,"interval":300000000000,` +
+				`"weight":40,"status":"running","nextStepTime":"2017-10-02T15:04:00Z"},` +
+				`{"id":"59d26b2f1e1b7a0001a1c2d4","app1":"api","app2":"api-blue","step":10,"interval":60000000000,` +
+				`"weight":10,"status":"paused","nextStepTime":"2017-10-02T15:04:00Z"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/swaps"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appSwapList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"ID", "From", "To", "Shifted", "Step", "Status", "Next Step"}
+	table.AddRow(Row{"59d26b2f1e1b7a0001a1c2d3", "web", "web-blue", "40%", "20% every 5m0s", "running", nextStep.Local().Format(time.RFC822)})
+	table.AddRow(Row{"59d26b2f1e1b7a0001a1c2d4", "api", "api-blue", "10%", "10% every 1m0s", "paused", ""})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestAppSwapListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appSwapList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"ID", "From", "To", "Shifted", "Step", "Status", "Next Step"}
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestAppSwapUpdateRun(c *check.C) {
+	tests := []struct {
+		command *appSwapUpdate
+		action  string
+		output  string
+	}{
+		{newAppSwapPause(), "pause", "Gradual swap 59d26b2f1e1b7a0001a1c2d3 paused.\n"},
+		{newAppSwapResume(), "resume", "Gradual swap 59d26b2f1e1b7a0001a1c2d3 resumed.\n"},
+		{newAppSwapAbort(), "abort", "Gradual swap 59d26b2f1e1b7a0001a1c2d3 aborted.\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		context := Context{
+			Args:   []string{"59d26b2f1e1b7a0001a1c2d3"},
+			Stdout: &stdout,
+			Stderr: &stderr,
+		}
+		action := tt.action
+		transport := cmdtest.ConditionalTransport{
+			Transport: cmdtest.Transport{Status: http.StatusOK},
+			CondFunc: func(req *http.Request) bool {
+				return req.Method == "POST" && req.URL.Path == "/1.3/swaps/59d26b2f1e1b7a0001a1c2d3" &&
+					req.FormValue("action") == action
+			},
+		}
+		client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+		c.Assert(tt.command.Info().Name, check.Equals, "app-swap-"+tt.action)
+		err := tt.command.Run(&context, client)
+		c.Assert(err, check.IsNil)
+		c.Assert(stdout.String(), check.Equals, tt.output)
+	}
+}
@@ -5,6 +5,7 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,8 +15,10 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
 	tsuruNet "github.com/tsuru/tsuru/net"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -26,23 +29,25 @@ type loginScheme struct {
 }
 
 type login struct {
-	scheme *loginScheme
+	scheme        *loginScheme
+	fs            *gnuflag.FlagSet
+	passwordStdin bool
 }
 
-func nativeLogin(context *Context, client *Client) error {
+func (c *login) nativeLogin(context *Context, client *Client) error {
 	var email string
 	if len(context.Args) > 0 {
 		email = context.Args[0]
+	} else if c.passwordStdin {
+		return errors.New("the email is required when reading the password from the standard input")
 	} else {
 		fmt.Fprint(context.Stdout, "Email: ")
 		fmt.Fscanf(context.Stdin, "%s\n", &email)
 	}
-	fmt.Fprint(context.Stdout, "Password: ")
-	password, err := PasswordFromReader(context.Stdin)
+	password, err := ReadPassword(context, "Password: ", c.passwordStdin)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(context.Stdout)
 	u, err := GetURL("/users/" + email + "/tokens")
 	if err != nil {
 		return err
@@ -70,7 +75,65 @@ func nativeLogin(context *Context, client *Client) error {
 		return err
 	}
 	fmt.Fprintln(context.Stdout, "Successfully logged in!")
-	return writeToken(out["token"].(string))
+	err = writeToken(out["token"].(string))
+	if err != nil {
+		return err
+	}
+	return writeTokenExpiry(parseTokenExpiry(out["expires"]))
+}
+
+// tokenRefreshWindow is how long before its expiration the client replaces
+// the session token with a new one.
+const tokenRefreshWindow = 24 * time.Hour
+
+// parseTokenExpiry parses the expiration time sent by the API along with a
+// token, returning the zero time when it's missing or invalid.
+func parseTokenExpiry(value interface{}) time.Time {
+	str, _ := value.(string)
+	expires, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return time.Time{}
+	}
+	return expires
+}
+
+// refreshSession replaces the session token with a new one when it's about
+// to expire, so long running commands don't fail half-way. Failures are
+// ignored: the command runs with the current token, and expired sessions are
+// handled when the API rejects it.
+func refreshSession(client *Client) {
+	expires, err := ReadTokenExpiry()
+	if err != nil || expires.IsZero() {
+		return
+	}
+	if left := time.Until(expires); left <= 0 || left > tokenRefreshWindow {
+		return
+	}
+	u, err := GetURLVersion("1.3", "/users/tokens/refresh")
+	if err != nil {
+		return
+	}
+	request, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	var out map[string]interface{}
+	err = json.NewDecoder(response.Body).Decode(&out)
+	if err != nil {
+		return
+	}
+	token, _ := out["token"].(string)
+	if token == "" {
+		return
+	}
+	if writeToken(token) == nil {
+		writeTokenExpiry(parseTokenExpiry(out["expires"]))
+	}
 }
 
 func (c *login) getScheme() *loginScheme {
@@ -92,11 +155,19 @@ func (c *login) Run(context *Context, client *Client) error {
 	if c.getScheme().Name == "saml" {
 		return c.samlLogin(context, client)
 	}
-	return nativeLogin(context, client)
+	return c.nativeLogin(context, client)
+}
+
+func (c *login) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("login", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.passwordStdin, "password-stdin", false, "Read the password from the standard input.")
+	}
+	return c.fs
 }
 
 func (c *login) Info() *Info {
-	usage := "login [email]"
+	usage := "login [email] [--password-stdin]"
 	return &Info{
 		Name:  "login",
 		Usage: usage,
@@ -106,7 +177,23 @@ successfully authenticated. If using OAuth, it will open a web browser for the
 user to complete the login.
 
 After that, the token generated by the tsuru server will be stored in
-[[${HOME}/.tsuru/token]].
+[[${HOME}/.tsuru/token]]. When the [[TSURU_TOKEN_STORAGE]] environment variable,
+or the [[token-storage]] entry of [[${HOME}/.tsuru/config.json]], is set to
+[[keychain]], the token is stored in the OS keychain instead (macOS Keychain,
+Linux secret service or Windows Credential Manager), falling back to the token
+file when the keychain is not available.
+
+The expiration time of the token, when the server reports one, is stored next
+to the token file. Sessions about to expire are refreshed by the client before
+running commands, and commands failing because the session has expired call
+the login command and run again.
+
+For automation, using tsuru native authentication scheme, the password may be
+read from the first line of the standard input with [[--password-stdin]], or
+from the [[TSURU_PASSWORD]] environment variable. Avoid typing the password in
+the command line, the shell keeps it in the history: prefer piping it from a
+file or a secret store, like [[tsuru login me@example.com --password-stdin <
+password.txt]].
 
 All tsuru actions require the user to be authenticated (except [[tsuru login]]
 and [[tsuru version]]).`,
@@ -129,7 +216,7 @@ func (c *logout) Run(context *Context, client *Client) error {
 		request, _ := http.NewRequest("DELETE", url, nil)
 		client.Do(request)
 	}
-	err := filesystem().Remove(JoinWithUserDir(".tsuru", "token"))
+	err := removeToken()
 	if err != nil && os.IsNotExist(err) {
 		return errors.New("You're not logged in!")
 	}
@@ -141,6 +228,7 @@ type APIRolePermissionData struct {
 	Name         string
 	ContextType  string
 	ContextValue string
+	ExpiresAt    *time.Time `json:",omitempty"`
 }
 
 // APIUser is a user in the tsuru API.
@@ -157,6 +245,9 @@ func (u *APIUser) RoleInstances() []string {
 			r.ContextValue = " " + r.ContextValue
 		}
 		roles[i] = fmt.Sprintf("%s(%s%s)", r.Name, r.ContextType, r.ContextValue)
+		if r.ExpiresAt != nil {
+			roles[i] += " expires " + r.ExpiresAt.Local().Format(time.RFC822)
+		}
 	}
 	sort.Strings(roles)
 	return roles
@@ -223,6 +314,402 @@ func (userInfo) Run(context *Context, client *Client) error {
 	return nil
 }
 
+// forceFlags returns the flags of commands asking for confirmation that can
+// be skipped with --force, e.g. when running from scripts.
+func forceFlags(name string, force *bool) *gnuflag.FlagSet {
+	fs := gnuflag.NewFlagSet(name, gnuflag.ExitOnError)
+	fs.BoolVar(force, "f", false, "Don't ask for confirmation.")
+	fs.BoolVar(force, "force", false, "Don't ask for confirmation.")
+	return fs
+}
+
+type userRemove struct {
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *userRemove) Info() *Info {
+	return &Info{
+		Name:  "user-remove",
+		Usage: "user-remove [email] [-f/--force]",
+		Desc: `Removes a user from tsuru. When the email is omitted, the current user is
+removed and logged out. Removing other users requires the user.delete
+permission.`,
+		MaxArgs: 1,
+	}
+}
+
+func (c *userRemove) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = forceFlags("user-remove", &c.yes)
+	}
+	return c.fs
+}
+
+func (c *userRemove) Run(context *Context, client *Client) error {
+	var email string
+	question := "Are you sure you want to remove your user from tsuru?"
+	if len(context.Args) > 0 {
+		email = context.Args[0]
+		question = fmt.Sprintf("Are you sure you want to remove the user %q from tsuru?", email)
+	}
+	if !c.Confirm(context, question) {
+		return nil
+	}
+	path := "/users"
+	if email != "" {
+		path += "?" + url.Values{"user": []string{email}}.Encode()
+	}
+	u, err := GetURL(path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if email == "" {
+		removeToken()
+		fmt.Fprintln(context.Stdout, "User successfully removed.")
+		return nil
+	}
+	fmt.Fprintf(context.Stdout, "User %q successfully removed.\n", email)
+	return nil
+}
+
+type userSuspend struct {
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *userSuspend) Info() *Info {
+	return &Info{
+		Name:  "user-suspend",
+		Usage: "user-suspend <email> [-f/--force]",
+		Desc: `Suspends a user. Suspended users are logged out and can't log in or create
+tokens, but they're kept in tsuru, along with their roles and the records of
+the apps and events they own, until activated again with user-activate.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *userSuspend) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = forceFlags("user-suspend", &c.yes)
+	}
+	return c.fs
+}
+
+func (c *userSuspend) Run(context *Context, client *Client) error {
+	email := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to suspend the user %q?", email)) {
+		return nil
+	}
+	err := doUserStateRequest(client, email, "suspend")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "User %q successfully suspended.\n", email)
+	return nil
+}
+
+type userActivate struct{}
+
+func (userActivate) Info() *Info {
+	return &Info{
+		Name:    "user-activate",
+		Usage:   "user-activate <email>",
+		Desc:    "Activates a suspended user, which is then able to log in again.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (userActivate) Run(context *Context, client *Client) error {
+	email := context.Args[0]
+	err := doUserStateRequest(client, email, "activate")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "User %q successfully activated.\n", email)
+	return nil
+}
+
+type loginUnlock struct {
+	fs *gnuflag.FlagSet
+	ip string
+}
+
+func (c *loginUnlock) Info() *Info {
+	return &Info{
+		Name:  "login-unlock",
+		Usage: "login-unlock [email] [--ip address]",
+		Desc: `Unlocks a user, or a client address, locked out after too many failed login
+attempts. Unlocking addresses requires the permission in the global context.`,
+		MaxArgs: 1,
+	}
+}
+
+func (c *loginUnlock) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("login-unlock", gnuflag.ExitOnError)
+		c.fs.StringVar(&c.ip, "ip", "", "The client address to unlock")
+	}
+	return c.fs
+}
+
+func (c *loginUnlock) Run(context *Context, client *Client) error {
+	var email string
+	if len(context.Args) > 0 {
+		email = context.Args[0]
+	}
+	if email == "" && c.ip == "" {
+		return errors.New("you must provide the email of the user or the address to unlock, with --ip")
+	}
+	v := url.Values{}
+	v.Set("email", email)
+	v.Set("ip", c.ip)
+	u, err := GetURLVersion("1.3", "/auth/unlock")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintln(context.Stdout, "Login successfully unlocked.")
+	return nil
+}
+
+func doUserStateRequest(client *Client, email, action string) error {
+	u, err := GetURLVersion("1.3", "/users/"+url.PathEscape(email)+"/"+action)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type changePassword struct{}
+
+func (changePassword) Info() *Info {
+	return &Info{
+		Name:  "change-password",
+		Usage: "change-password",
+		Desc: `Changes the password of the current user. It asks for the current password,
+the new password and its confirmation, which may also be given in the standard
+input, one per line. The password requirements of the target, when available,
+are shown before asking for the passwords.`,
+	}
+}
+
+// passwordPolicy holds the password rules enforced by the API.
+type passwordPolicy struct {
+	MinLength        int  `json:"minLength"`
+	MaxLength        int  `json:"maxLength"`
+	RequireUppercase bool `json:"requireUppercase"`
+	RequireLowercase bool `json:"requireLowercase"`
+	RequireDigit     bool `json:"requireDigit"`
+	RequireSymbol    bool `json:"requireSymbol"`
+	History          int  `json:"history"`
+}
+
+// passwordRequirements returns a description of the password policy of the
+// target, or an empty string when the target doesn't have one.
+func passwordRequirements(client *Client) string {
+	u, err := GetURLVersion("1.3", "/auth/password-policy")
+	if err != nil {
+		return ""
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return ""
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return ""
+	}
+	defer response.Body.Close()
+	var policy passwordPolicy
+	if json.NewDecoder(response.Body).Decode(&policy) != nil || policy.MinLength == 0 {
+		return ""
+	}
+	rules := []string{fmt.Sprintf("between %d and %d characters", policy.MinLength, policy.MaxLength)}
+	if policy.RequireUppercase {
+		rules = append(rules, "an uppercase letter")
+	}
+	if policy.RequireLowercase {
+		rules = append(rules, "a lowercase letter")
+	}
+	if policy.RequireDigit {
+		rules = append(rules, "a digit")
+	}
+	if policy.RequireSymbol {
+		rules = append(rules, "a symbol")
+	}
+	if policy.History > 0 {
+		rules = append(rules, fmt.Sprintf("different from the last %d passwords", policy.History))
+	}
+	return strings.Join(rules, ", ")
+}
+
+func (changePassword) Run(context *Context, client *Client) error {
+	if requirements := passwordRequirements(client); requirements != "" {
+		fmt.Fprintf(context.Stdout, "Password requirements: %s.\n", requirements)
+	}
+	fmt.Fprint(context.Stdout, "Current password: ")
+	oldPassword, err := PasswordFromReader(context.Stdin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(context.Stdout, "\nNew password: ")
+	newPassword, err := PasswordFromReader(context.Stdin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(context.Stdout, "\nConfirm: ")
+	confirm, err := PasswordFromReader(context.Stdin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout)
+	if newPassword != confirm {
+		return errors.New("New password and password confirmation didn't match.")
+	}
+	u, err := GetURL("/users/password")
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("old", oldPassword)
+	v.Set("new", newPassword)
+	v.Set("confirm", confirm)
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintln(context.Stdout, "Password successfully updated!")
+	return nil
+}
+
+type resetPassword struct {
+	ConfirmationCommand
+	fs    *gnuflag.FlagSet
+	token string
+}
+
+func (c *resetPassword) Info() *Info {
+	return &Info{
+		Name:  "reset-password",
+		Usage: "reset-password <email> [-t/--token <token>] [-f/--force]",
+		Desc: `Resets the password of a user, in two steps. The first run, without the
+[[--token]] flag, sends an email with a confirmation token to the user. Running
+reset-password again with the token generates a new password, which is also
+sent by email.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *resetPassword) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = forceFlags("reset-password", &c.yes)
+		desc := "Token received by email, confirming the password reset"
+		c.fs.StringVar(&c.token, "token", "", desc)
+		c.fs.StringVar(&c.token, "t", "", desc)
+	}
+	return c.fs
+}
+
+func (c *resetPassword) Run(context *Context, client *Client) error {
+	email := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to reset the password of %q?", email)) {
+		return nil
+	}
+	path := "/users/" + url.PathEscape(email) + "/password"
+	if c.token != "" {
+		path += "?" + url.Values{"token": []string{c.token}}.Encode()
+	}
+	u, err := GetURL(path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if c.token == "" {
+		fmt.Fprintln(context.Stdout, "You've successfully started the password reset process, please check the email for the token.")
+		return nil
+	}
+	fmt.Fprintln(context.Stdout, "The password has been reset and sent by email.")
+	return nil
+}
+
+// PasswordEnvVar is the environment variable holding the password used by
+// ReadPassword when it's not read from the standard input.
+const PasswordEnvVar = "TSURU_PASSWORD"
+
+// ReadPassword reads a password for commands that may run without a terminal.
+// With fromStdin, the password is the first line of the standard input.
+// Otherwise the password in the TSURU_PASSWORD environment variable is used,
+// falling back to asking for it with the given prompt.
+func ReadPassword(context *Context, prompt string, fromStdin bool) (string, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(context.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", errors.New("You must provide the password!")
+		}
+		return password, nil
+	}
+	if password := os.Getenv(PasswordEnvVar); password != "" {
+		fmt.Fprintf(context.Stderr, "WARNING: using the password in the %s environment variable. Don't set it in the command line, where it's kept in the shell history, prefer --password-stdin.\n", PasswordEnvVar)
+		return password, nil
+	}
+	fmt.Fprint(context.Stdout, prompt)
+	password, err := PasswordFromReader(context.Stdin)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(context.Stdout)
+	return password, nil
+}
+
 func PasswordFromReader(reader io.Reader) (string, error) {
 	var (
 		password []byte
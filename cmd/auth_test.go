@@ -12,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/tsuru/tsuru/cmd/cmdtest"
 	"github.com/tsuru/tsuru/fs/fstest"
@@ -22,6 +23,87 @@ func nativeScheme() {
 	os.Setenv("TSURU_AUTH_SCHEME", "")
 }
 
+func (s *S) TestNativeLoginStoresTokenExpiry(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	nativeScheme()
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	reader := strings.NewReader("chico\n")
+	context := Context{Args: []string{"foo@foo.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: reader}
+	transport := cmdtest.Transport{
+		Message: `{"token": "sometoken", "expires": "2017-03-08T14:30:00Z"}`,
+		Status:  http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := login{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expires, err := ReadTokenExpiry()
+	c.Assert(err, check.IsNil)
+	c.Assert(expires.Equal(time.Date(2017, 3, 8, 14, 30, 0, 0, time.UTC)), check.Equals, true)
+}
+
+func (s *S) TestRefreshSession(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	err := writeToken("oldtoken")
+	c.Assert(err, check.IsNil)
+	err = writeTokenExpiry(time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"token": "newtoken", "expires": "2017-03-15T14:30:00Z"}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(r *http.Request) bool {
+			return r.Method == "POST" && r.URL.Path == "/1.3/users/tokens/refresh" &&
+				r.Header.Get("Authorization") == "bearer oldtoken"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	refreshSession(client)
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "newtoken")
+	expires, err := ReadTokenExpiry()
+	c.Assert(err, check.IsNil)
+	c.Assert(expires.Equal(time.Date(2017, 3, 15, 14, 30, 0, 0, time.UTC)), check.Equals, true)
+}
+
+func (s *S) TestRefreshSessionNotDue(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	err := writeToken("oldtoken")
+	c.Assert(err, check.IsNil)
+	var calls int
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"token": "newtoken"}`, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			calls++
+			return true
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	refreshSession(client)
+	for _, expires := range []time.Time{time.Now().Add(72 * time.Hour), time.Now().Add(-time.Hour)} {
+		err = writeTokenExpiry(expires)
+		c.Assert(err, check.IsNil)
+		refreshSession(client)
+	}
+	c.Assert(calls, check.Equals, 0)
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "oldtoken")
+}
+
 func (s *S) TestNativeLogin(c *check.C) {
 	os.Unsetenv("TSURU_TOKEN")
 	nativeScheme()
@@ -31,7 +113,7 @@ func (s *S) TestNativeLogin(c *check.C) {
 	}()
 	expected := "Password: \nSuccessfully logged in!\n"
 	reader := strings.NewReader("chico\n")
-	context := Context{[]string{"foo@foo.com"}, globalManager.stdout, globalManager.stderr, reader}
+	context := Context{Args: []string{"foo@foo.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: reader}
 	transport := cmdtest.ConditionalTransport{
 		Transport: cmdtest.Transport{
 			Message: `{"token": "sometoken", "is_admin": true}`,
@@ -63,7 +145,7 @@ func (s *S) TestNativeLoginWithoutEmailFromArg(c *check.C) {
 	}()
 	expected := "Email: Password: \nSuccessfully logged in!\n"
 	reader := strings.NewReader("chico@tsuru.io\nchico\n")
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, reader}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: reader}
 	transport := cmdtest.ConditionalTransport{
 		Transport: cmdtest.Transport{
 			Message: `{"token": "sometoken", "is_admin": true}`,
@@ -95,7 +177,7 @@ func (s *S) TestNativeLoginShouldNotDependOnTsuruTokenFile(c *check.C) {
 	}()
 	expected := "Password: \nSuccessfully logged in!\n"
 	reader := strings.NewReader("chico\n")
-	context := Context{[]string{"foo@foo.com"}, globalManager.stdout, globalManager.stderr, reader}
+	context := Context{Args: []string{"foo@foo.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: reader}
 	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Message: `{"token":"anothertoken"}`, Status: http.StatusOK}}, nil, globalManager)
 	command := login{}
 	err := command.Run(&context, client)
@@ -105,13 +187,79 @@ func (s *S) TestNativeLoginShouldNotDependOnTsuruTokenFile(c *check.C) {
 
 func (s *S) TestNativeLoginShouldReturnErrorIfThePasswordIsNotGiven(c *check.C) {
 	nativeScheme()
-	context := Context{[]string{"foo@foo.com"}, globalManager.stdout, globalManager.stderr, strings.NewReader("\n")}
+	context := Context{Args: []string{"foo@foo.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: strings.NewReader("\n")}
 	command := login{}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err, check.ErrorMatches, "^You must provide the password!$")
 }
 
+func (s *S) TestNativeLoginPasswordStdin(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	nativeScheme()
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"foo@foo.com"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("chico 123\r\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"token": "sometoken"}`, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.FormValue("password") == "chico 123" && r.URL.Path == "/1.0/users/foo@foo.com/tokens"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := login{}
+	err := command.Flags().Parse(true, []string{"--password-stdin"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Successfully logged in!\n")
+	c.Assert(stderr.String(), check.Equals, "")
+}
+
+func (s *S) TestNativeLoginPasswordStdinRequiresEmail(c *check.C) {
+	nativeScheme()
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: strings.NewReader("chico\n")}
+	command := login{}
+	err := command.Flags().Parse(true, []string{"--password-stdin"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the email is required when reading the password from the standard input")
+}
+
+func (s *S) TestNativeLoginPasswordFromEnv(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	nativeScheme()
+	os.Setenv(PasswordEnvVar, "chico")
+	defer os.Unsetenv(PasswordEnvVar)
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"foo@foo.com"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"token": "sometoken"}`, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.FormValue("password") == "chico"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := login{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Successfully logged in!\n")
+	c.Assert(stderr.String(), check.Matches, "WARNING: using the password in the TSURU_PASSWORD environment variable.*\n")
+}
+
+func (s *S) TestReadPasswordStdinEmpty(c *check.C) {
+	context := Context{Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: strings.NewReader("")}
+	_, err := ReadPassword(&context, "Password: ", true)
+	c.Assert(err, check.ErrorMatches, "^You must provide the password!$")
+}
+
 func (s *S) TestLogout(c *check.C) {
 	var called bool
 	rfs := &fstest.RecordingFs{}
@@ -122,7 +270,7 @@ func (s *S) TestLogout(c *check.C) {
 	writeToken("mytoken")
 	os.Setenv("TSURU_TARGET", "localhost:8080")
 	expected := "Successfully logged out!\n"
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := logout{}
 	transport := cmdtest.ConditionalTransport{
 		Transport: cmdtest.Transport{
@@ -150,7 +298,7 @@ func (s *S) TestLogoutWhenNotLoggedIn(c *check.C) {
 	defer func() {
 		fsystem = nil
 	}()
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := logout{}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.NotNil)
@@ -165,7 +313,7 @@ func (s *S) TestLogoutNoTarget(c *check.C) {
 	}()
 	writeToken("mytoken")
 	expected := "Successfully logged out!\n"
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := logout{}
 	transport := cmdtest.Transport{Message: "", Status: http.StatusOK}
 	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
@@ -300,7 +448,7 @@ Roles:
 Permissions:
 	a(y q)
 `
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := userInfo{}
 	transport := cmdtest.ConditionalTransport{
 		Transport: cmdtest.Transport{
@@ -325,6 +473,29 @@ Permissions:
 	c.Assert(called, check.Equals, true)
 }
 
+func (s *S) TestUserInfoRunWithExpiringRole(c *check.C) {
+	expiresAt := time.Date(2026, 10, 15, 10, 30, 0, 0, time.UTC)
+	expected := "Email: myuser@company.com\nRoles:\n\tx(y a)\n\tx(y b) expires " + expiresAt.Local().Format(time.RFC822) + "\n"
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Email":"myuser@company.com","Roles":[
+	{"Name":"x","ContextType":"y","ContextValue":"a"},
+	{"Name":"x","ContextType":"y","ContextValue":"b","ExpiresAt":"2026-10-15T10:30:00Z"}
+]}`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/users/info"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := userInfo{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
 func (s *S) TestPasswordFromReaderUsingFile(c *check.C) {
 	tmpdir, err := filepath.EvalSymlinks(os.TempDir())
 	filename := path.Join(tmpdir, "password-reader.txt")
@@ -345,3 +516,214 @@ func (s *S) TestPasswordFromReaderUsingStringsReader(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(password, check.Equals, "abcd")
 }
+
+func (s *S) TestUserRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"other@tsuru.io"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.0/users" &&
+				req.URL.Query().Get("user") == "other@tsuru.io"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := userRemove{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to remove the user "other@tsuru.io" from tsuru? (y/n) User "other@tsuru.io" successfully removed.`+"\n")
+}
+
+func (s *S) TestUserRemoveRunCurrentUser(c *check.C) {
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.0/users" && req.URL.RawQuery == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := userRemove{}
+	err := command.Flags().Parse(true, []string{"--force"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "User successfully removed.\n")
+	c.Assert(rfs.HasAction("remove "+JoinWithUserDir(".tsuru", "token")), check.Equals, true)
+}
+
+func (s *S) TestUserRemoveRunAbort(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("n\n")}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusInternalServerError}}, nil, globalManager)
+	command := userRemove{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Are you sure you want to remove your user from tsuru? (y/n) Abort.\n")
+}
+
+func (s *S) TestChangePasswordRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("gopher\nbbrothers\nbbrothers\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.0/users/password" &&
+				req.Form.Get("old") == "gopher" && req.Form.Get("new") == "bbrothers" &&
+				req.Form.Get("confirm") == "bbrothers"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := changePassword{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Current password: \nNew password: \nConfirm: \nPassword successfully updated!\n")
+}
+
+func (s *S) TestChangePasswordRunShowsPolicy(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("gopher\nbbrothers\nbbrothers\n")}
+	policy := `{"minLength":8,"maxLength":50,"requireUppercase":true,"requireDigit":true,"history":3}`
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{Message: policy, Status: http.StatusOK},
+				CondFunc: func(req *http.Request) bool {
+					return req.Method == "GET" && req.URL.Path == "/1.3/auth/password-policy"
+				},
+			},
+			{
+				Transport: cmdtest.Transport{Status: http.StatusOK},
+				CondFunc: func(req *http.Request) bool {
+					return req.Method == "PUT" && req.URL.Path == "/1.0/users/password"
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := changePassword{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Password requirements: between 8 and 50 characters, an uppercase letter, a digit, different from the last 3 passwords.\n" +
+		"Current password: \nNew password: \nConfirm: \nPassword successfully updated!\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestChangePasswordRunConfirmationMismatch(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("gopher\nbbrothers\nbrothers\n")}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusOK}}, nil, globalManager)
+	err := changePassword{}.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "New password and password confirmation didn't match.")
+}
+
+func (s *S) TestResetPasswordRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"me@tsuru.io"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.0/users/me@tsuru.io/password" &&
+				req.URL.Query().Get("token") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := resetPassword{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to reset the password of "me@tsuru.io"? (y/n) You've successfully started the password reset process, please check the email for the token.`+"\n")
+}
+
+func (s *S) TestResetPasswordRunWithToken(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"me@tsuru.io"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.0/users/me@tsuru.io/password" &&
+				req.URL.Query().Get("token") == "token123"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := resetPassword{}
+	err := command.Flags().Parse(true, []string{"-f", "-t", "token123"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "The password has been reset and sent by email.\n")
+}
+
+func (s *S) TestUserSuspendRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"other@tsuru.io"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/users/other@tsuru.io/suspend"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := userSuspend{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to suspend the user "other@tsuru.io"? (y/n) User "other@tsuru.io" successfully suspended.`+"\n")
+}
+
+func (s *S) TestUserSuspendRunAbort(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"other@tsuru.io"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("n\n")}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusInternalServerError}}, nil, globalManager)
+	command := userSuspend{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to suspend the user "other@tsuru.io"? (y/n) Abort.`+"\n")
+}
+
+func (s *S) TestLoginUnlockRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"other@tsuru.io"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/auth/unlock" &&
+				req.Form.Get("email") == "other@tsuru.io" && req.Form.Get("ip") == "10.0.0.1"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := loginUnlock{}
+	err := command.Flags().Parse(true, []string{"--ip", "10.0.0.1"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Login successfully unlocked.\n")
+}
+
+func (s *S) TestLoginUnlockRunWithoutTarget(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusOK}}, nil, globalManager)
+	command := loginUnlock{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "you must provide the email of the user or the address to unlock, with --ip")
+}
+
+func (s *S) TestUserActivateRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"other@tsuru.io"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/users/other@tsuru.io/activate"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := userActivate{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "User \"other@tsuru.io\" successfully activated.\n")
+}
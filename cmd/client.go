@@ -5,20 +5,49 @@
 package cmd
 
 import (
+	"bytes"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	tsuruerr "github.com/tsuru/tsuru/errors"
 	tsuruio "github.com/tsuru/tsuru/io"
 )
 
-var errUnauthorized = &tsuruerr.HTTP{Code: http.StatusUnauthorized, Message: "unauthorized"}
+var errUnauthorized = &tsuruerr.HTTP{Code: http.StatusUnauthorized, Message: "unauthorized", ErrorCode: tsuruerr.CodeAuthFailure}
+
+var errTokenExpired = &tsuruerr.HTTP{Code: http.StatusUnauthorized, Message: "session expired", ErrorCode: tsuruerr.CodeTokenExpired}
+
+var (
+	redactedHeader    = regexp.MustCompile(`(?im)^(Authorization: (?:\w+ )?)[^\r\n]+`)
+	redactedJSONField = regexp.MustCompile(`(?i)("(?:token|password)"\s*:\s*")[^"]*"`)
+	redactedFormField = regexp.MustCompile(`(?m)((?:^|&)(?:password|old|new|confirm|token)=)[^&\r\n]*`)
+)
+
+// debugEnabled returns whether the TSURU_DEBUG environment variable asks
+// for HTTP requests and responses to be printed.
+func debugEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("TSURU_DEBUG"))
+	return enabled
+}
+
+// redactDump removes credentials from a dumped request or response, so it
+// can be safely attached to bug reports.
+func redactDump(dump []byte) []byte {
+	dump = redactedHeader.ReplaceAll(dump, []byte("${1}<redacted>"))
+	dump = redactedJSONField.ReplaceAll(dump, []byte(`${1}<redacted>"`))
+	return redactedFormField.ReplaceAll(dump, []byte("${1}<redacted>"))
+}
 
 type Client struct {
 	HTTPClient     *http.Client
@@ -57,6 +86,9 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 	if token, err := ReadToken(); err == nil && token != "" {
 		request.Header.Set("Authorization", "bearer "+token)
 	}
+	if request.Header.Get("Accept") == "" {
+		request.Header.Set("Accept", "application/json")
+	}
 	request.Close = true
 	if c.Verbosity >= 1 {
 		fmt.Fprintf(c.context.Stdout, "*************************** <Request uri=%q> **********************************\n", request.URL.RequestURI())
@@ -64,7 +96,8 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 		if err != nil {
 			return nil, err
 		}
-		fmt.Fprintf(c.context.Stdout, string(requestDump))
+		requestDump = redactDump(requestDump)
+		c.context.Stdout.Write(requestDump)
 		if requestDump[len(requestDump)-1] != '\n' {
 			fmt.Fprintln(c.context.Stdout)
 		}
@@ -81,7 +114,8 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 		if err != nil {
 			return nil, err
 		}
-		fmt.Fprintf(c.context.Stdout, string(responseDump))
+		responseDump = redactDump(responseDump)
+		c.context.Stdout.Write(responseDump)
 		if responseDump[len(responseDump)-1] != '\n' {
 			fmt.Fprintln(c.context.Stdout)
 		}
@@ -105,12 +139,16 @@ and download the last version.
 		fmt.Fprintf(c.context.Stderr, format, c.progname, supported, c.currentVersion)
 	}
 	if response.StatusCode == http.StatusUnauthorized {
+		if isTokenExpired(response) {
+			return response, errTokenExpired
+		}
 		return response, errUnauthorized
 	}
 	if response.StatusCode > 399 {
 		err := &tsuruerr.HTTP{
-			Code:    response.StatusCode,
-			Message: response.Status,
+			Code:      response.StatusCode,
+			Message:   response.Status,
+			ErrorCode: tsuruerr.StatusErrorCode(response.StatusCode),
 		}
 
 		defer response.Body.Close()
@@ -118,12 +156,34 @@ and download the last version.
 		if len(body) > 0 {
 			err.Message = string(body)
 		}
+		var payload tsuruerr.Payload
+		if strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") &&
+			json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+			err.Message = payload.Message
+			err.Fields = payload.Fields
+			if payload.Code != "" {
+				err.ErrorCode = payload.Code
+			}
+		}
 
 		return response, err
 	}
 	return response, nil
 }
 
+// isTokenExpired reports whether the unauthorized response was caused by an
+// expired token. The body is kept readable by the caller.
+func isTokenExpired(response *http.Response) bool {
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		return false
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var payload tsuruerr.Payload
+	return json.Unmarshal(body, &payload) == nil && payload.Code == tsuruerr.CodeTokenExpired
+}
+
 // StreamJSONResponse supports the JSON streaming format from the tsuru API.
 func StreamJSONResponse(w io.Writer, response *http.Response) error {
 	if response == nil {
@@ -129,6 +129,71 @@ func (s *S) TestShouldHandleUnauthorizedErrorSpecially(c *check.C) {
 	c.Assert(err, check.Equals, errUnauthorized)
 }
 
+func (s *S) TestDoStructuredError(c *check.C) {
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"code": "quota-exceeded", "message": "Quota exceeded", "fields": {"units": "at most 2"}}`,
+			Status:  http.StatusForbidden,
+			Headers: map[string][]string{"Content-Type": {"application/json"}},
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Header.Get("Accept") == "application/json"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	_, err = client.Do(request)
+	c.Assert(err, check.DeepEquals, &errors.HTTP{
+		Code:      http.StatusForbidden,
+		Message:   "Quota exceeded",
+		ErrorCode: errors.CodeQuotaExceeded,
+		Fields:    map[string]string{"units": "at most 2"},
+	})
+}
+
+func (s *S) TestDoTokenExpired(c *check.C) {
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	transport := cmdtest.Transport{
+		Message: `{"code": "token-expired", "message": "Your session has expired, please log in again"}`,
+		Status:  http.StatusUnauthorized,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	_, err = client.Do(request)
+	c.Assert(err, check.Equals, errTokenExpired)
+	c.Assert(exitCode(err), check.Equals, ExitCodeAuthFailure)
+}
+
+func (s *S) TestDoUnauthorizedKeepsBody(c *check.C) {
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	transport := cmdtest.Transport{
+		Message: `{"code": "auth-failure", "message": "You must provide a valid Authorization header"}`,
+		Status:  http.StatusUnauthorized,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	response, err := client.Do(request)
+	c.Assert(err, check.Equals, errUnauthorized)
+	body, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(body), check.Equals, transport.Message)
+}
+
+func (s *S) TestDoTextErrorHasErrorCode(c *check.C) {
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Message: "app not found", Status: http.StatusNotFound}}, nil, globalManager)
+	_, err = client.Do(request)
+	c.Assert(err, check.DeepEquals, &errors.HTTP{
+		Code:      http.StatusNotFound,
+		Message:   "app not found",
+		ErrorCode: errors.CodeNotFound,
+	})
+}
+
 func (s *S) TestShouldReturnErrorWhenServerIsDown(c *check.C) {
 	os.Unsetenv("TSURU_TARGET")
 	rfs := &fstest.RecordingFs{FileContent: "http://tsuru.abc.xyz"}
@@ -208,6 +273,32 @@ func (s *S) TestShouldIncludeTheHeaderAuthorizationWhenTsuruTokenFileExists(c *c
 			`Authorization: bearer.*`)
 }
 
+func (s *S) TestVerboseRedactsCredentials(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	fsystem = &fstest.RecordingFs{FileContent: "mytoken"}
+	defer func() {
+		fsystem = nil
+	}()
+	request, err := http.NewRequest("POST", "/users/me@tsuru.io/tokens", strings.NewReader("password=secret&name=me"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	trans := cmdtest.Transport{Message: `{"token": "newtoken", "is_admin": false}`, Status: http.StatusOK}
+	var buf bytes.Buffer
+	context := Context{
+		Stdout: &buf,
+	}
+	client := NewClient(&http.Client{Transport: &trans}, &context, globalManager)
+	client.Verbosity = 2
+	_, err = client.Do(request)
+	c.Assert(err, check.IsNil)
+	c.Assert(request.Header.Get("Authorization"), check.Equals, "bearer mytoken")
+	output := buf.String()
+	c.Assert(output, check.Matches, `(?s).*Authorization: bearer <redacted>\r\n.*password=<redacted>&name=me.*"token": "<redacted>", "is_admin": false.*`)
+	c.Assert(strings.Contains(output, "mytoken"), check.Equals, false)
+	c.Assert(strings.Contains(output, "secret"), check.Equals, false)
+	c.Assert(strings.Contains(output, "newtoken"), check.Equals, false)
+}
+
 func (s *S) TestShouldValidateVersion(c *check.C) {
 	var buf bytes.Buffer
 	request, err := http.NewRequest("GET", "/", nil)
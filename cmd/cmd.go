@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	goVersion "github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
@@ -21,6 +22,7 @@ import (
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/fs"
 	"github.com/tsuru/tsuru/net"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 var (
@@ -35,6 +37,55 @@ const (
 	loginCmdName = "login"
 )
 
+// Exit codes of failed commands, they tell the class of the failure apart so
+// scripts can branch on it.
+const (
+	ExitCodeError         = 1
+	ExitCodeUsage         = 2
+	ExitCodeAuthFailure   = 3
+	ExitCodeNotFound      = 4
+	ExitCodeQuotaExceeded = 5
+	ExitCodeLocked        = 6
+)
+
+// exitCode returns the exit code matching the class of the error returned
+// by a command.
+func exitCode(err error) int {
+	httpErr, ok := errors.Cause(err).(*tsuruErrors.HTTP)
+	if !ok {
+		return ExitCodeError
+	}
+	switch httpErr.Payload().Code {
+	case tsuruErrors.CodeAuthFailure, tsuruErrors.CodeTokenExpired, tsuruErrors.CodeForbidden:
+		return ExitCodeAuthFailure
+	case tsuruErrors.CodeNotFound:
+		return ExitCodeNotFound
+	case tsuruErrors.CodeQuotaExceeded:
+		return ExitCodeQuotaExceeded
+	case tsuruErrors.CodeLocked:
+		return ExitCodeLocked
+	}
+	return ExitCodeError
+}
+
+// fieldErrors formats the invalid fields reported in the error, one per line.
+func fieldErrors(err error) string {
+	httpErr, ok := errors.Cause(err).(*tsuruErrors.HTTP)
+	if !ok || len(httpErr.Fields) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(httpErr.Fields))
+	for name := range httpErr.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "  %s: %s\n", name, httpErr.Fields[name])
+	}
+	return buf.String()
+}
+
 type exiter interface {
 	Exit(int)
 }
@@ -61,6 +112,9 @@ type Manager struct {
 	wrong         bool
 	lookup        Lookup
 	contexts      []*Context
+	motd          bool
+	plugins       bool
+	telemetry     bool
 }
 
 func NewManager(name, ver, verHeader string, stdout, stderr io.Writer, stdin io.Reader, lookup Lookup) *Manager {
@@ -79,7 +133,93 @@ func BuildBaseManager(name, version, versionHeader string, lookup Lookup) *Manag
 	m.Register(&targetRemove{})
 	m.Register(&targetSet{})
 	m.Register(userInfo{})
+	m.Register(&userRemove{})
+	m.Register(&userSuspend{})
+	m.Register(userActivate{})
+	m.Register(&loginUnlock{})
+	m.Register(changePassword{})
+	m.Register(&resetPassword{})
+	m.Register(teamInfo{})
+	m.Register(&teamRemove{})
+	m.Register(teamRename{})
+	m.Register(teamParentSet{})
+	m.Register(teamTree{})
+	m.Register(teamImport{})
+	m.Register(teamExport{})
+	m.Register(&roleClone{})
+	m.Register(groupCreate{})
+	m.Register(groupList{})
+	m.Register(&groupRemove{})
+	m.Register(groupMemberAdd{})
+	m.Register(groupMemberRemove{})
+	m.Register(groupRoleAdd{})
+	m.Register(groupRoleRemove{})
+	m.Register(statusOverview{})
+	m.Register(&storageUsage{})
+	m.Register(&resourceQuotaShow{scope: "team"})
+	m.Register(&resourceQuotaSet{scope: "team"})
+	m.Register(&resourceQuotaShow{scope: "pool"})
+	m.Register(&resourceQuotaSet{scope: "pool"})
+	m.Register(&tokenCreate{})
+	m.Register(tokenList{})
+	m.Register(tokenRevoke{})
+	m.Register(sessionList{})
+	m.Register(sessionRevoke{})
+	m.Register(&deployScheduleList{})
+	m.Register(&deployScheduleCancel{})
+	m.Register(deployApprove{})
+	m.Register(&deployRollbackList{})
+	m.Register(&envHistory{})
+	m.Register(&envDiffCmd{})
+	m.Register(&envRestore{})
+	m.Register(&appDeployApprovalSet{})
+	m.Register(&appRateLimitSet{})
+	m.Register(&appProcessPlanSet{})
+	m.Register(&appHealthcheckSet{})
+	m.Register(&appHealthcheckShow{})
+	m.Register(&appLogSearch{})
+	m.Register(&appMetrics{})
+	m.Register(&logDrainAdd{})
+	m.Register(&logDrainList{})
+	m.Register(&logDrainRemove{})
+	m.Register(&appDependencyAdd{})
+	m.Register(&appDependencyRemove{})
+	m.Register(&appDependencyList{})
+	m.Register(&appDependencyGraph{})
+	m.Register(&appClone{})
+	m.Register(&appReviewAppsEnable{})
+	m.Register(&appReviewAppsDisable{})
+	m.Register(&appReviewAppList{})
+	m.Register(&appReviewAppNotify{})
+	m.Register(&appMaintenance{})
+	m.Register(&appBuildCache{})
+	m.Register(&appChangelog{})
+	m.Register(&appSwapGradual{})
+	m.Register(&appSwapList{})
+	m.Register(newAppSwapPause())
+	m.Register(newAppSwapResume())
+	m.Register(newAppSwapAbort())
+	m.Register(&jobCreate{})
+	m.Register(&jobList{})
+	m.Register(&jobRemove{})
+	m.Register(&jobTrigger{})
+	m.Register(&jobLog{})
+	m.Register(&appRunStatus{})
+	m.Register(&eventList{})
+	m.Register(&eventInfoCmd{})
+	m.Register(&eventCancel{})
+	m.Register(&eventBlockList{})
+	m.Register(&eventBlockAdd{})
+	m.Register(eventBlockRemove{})
+	m.Register(&completion{manager: m})
+	m.Register(&pluginInstall{})
+	m.Register(pluginList{})
+	m.Register(pluginRemove{})
+	m.Register(&telemetry{})
 	m.RegisterTopic("target", fmt.Sprintf(targetTopic, name))
+	m.motd = true
+	m.plugins = true
+	m.telemetry = true
 	return m
 }
 
@@ -152,8 +292,13 @@ func (m *Manager) Run(args []string) {
 	var (
 		status         int
 		verbosity      int
+		verbose        bool
 		displayHelp    bool
 		displayVersion bool
+		format         string
+		target         string
+		noColor        bool
+		noPager        bool
 	)
 	if len(args) == 0 {
 		args = append(args, "help")
@@ -162,15 +307,26 @@ func (m *Manager) Run(args []string) {
 	flagset.SetOutput(m.stderr)
 	flagset.IntVar(&verbosity, "verbosity", 0, "Verbosity level: 1 => print HTTP requests; 2 => print HTTP requests/responses")
 	flagset.IntVar(&verbosity, "v", 0, "Verbosity level: 1 => print HTTP requests; 2 => print HTTP requests/responses")
+	flagset.BoolVar(&verbose, "verbose", false, "Print HTTP requests and responses, same as --verbosity 2")
 	flagset.BoolVar(&displayHelp, "help", false, "Display help and exit")
 	flagset.BoolVar(&displayHelp, "h", false, "Display help and exit")
 	flagset.BoolVar(&displayVersion, "version", false, "Print version and exit")
+	flagset.StringVar(&format, "format", FormatTable, formatUsage)
+	flagset.StringVar(&target, "target", "", "Target used by the command, either a label in the target list or the address of a tsuru server")
+	flagset.BoolVar(&noColor, "no-color", false, "Disable colors in the output")
+	flagset.BoolVar(&noPager, "no-pager", false, "Do not pipe long outputs to a pager")
 	parseErr := flagset.Parse(false, args)
 	if parseErr != nil {
 		fmt.Fprint(m.stderr, parseErr)
-		m.finisher().Exit(2)
+		m.finisher().Exit(ExitCodeUsage)
+		return
+	}
+	if err := setTargetOverride(target); err != nil {
+		fmt.Fprintln(m.stderr, err)
+		m.finisher().Exit(1)
 		return
 	}
+	m.setOutputOverrides(noColor, noPager)
 	args = flagset.Args()
 	if displayHelp {
 		args = append([]string{"help"}, args...)
@@ -190,6 +346,14 @@ func (m *Manager) Run(args []string) {
 	}
 	name := args[0]
 	command, ok := m.Commands[name]
+	if !ok && m.plugins {
+		if status, found := m.runPlugin(args); found {
+			if status != 0 {
+				m.finisher().Exit(status)
+			}
+			return
+		}
+	}
 	if !ok {
 		msg := fmt.Sprintf("%s: %q is not a %s command. See %q.\n", m.name, name, m.name, m.name+" help")
 		var keys []string
@@ -212,7 +376,10 @@ func (m *Manager) Run(args []string) {
 	}
 	args = args[1:]
 	info := command.Info()
-	command, args, err := m.handleFlags(command, name, args)
+	command, args, err := m.handleFlags(command, name, args, &format)
+	if err == nil {
+		err = validateFormat(format)
+	}
 	if err != nil {
 		fmt.Fprint(m.stderr, err)
 		m.finisher().Exit(1)
@@ -232,12 +399,27 @@ func (m *Manager) Run(args []string) {
 		status = 1
 	}
 	context := m.newContext(args, m.stdout, m.stderr, m.stdin)
+	context.Format = format
 	client := NewClient(net.Dial5FullUnlimitedClient, context, m)
+	if verbose || debugEnabled() {
+		verbosity = 2
+	}
 	client.Verbosity = verbosity
+	if m.motd && name != "help" && name != "version" && name != loginCmdName {
+		m.showMotd(client)
+	}
+	if name != loginCmdName && name != "logout" && name != "help" && name != "version" {
+		refreshSession(client)
+	}
+	start := time.Now()
 	err = command.Run(context, client)
-	if err == errUnauthorized && name != loginCmdName {
+	if (err == errUnauthorized || err == errTokenExpired) && name != loginCmdName {
 		if cmd, ok := m.Commands[loginCmdName]; ok {
-			fmt.Fprintln(m.stderr, "Error: you're not authenticated or your session has expired.")
+			if err == errTokenExpired {
+				fmt.Fprintln(m.stderr, "Error: your session has expired.")
+			} else {
+				fmt.Fprintln(m.stderr, "Error: you're not authenticated or your session has expired.")
+			}
 			fmt.Fprintf(m.stderr, "Calling the %q command...\n", loginCmdName)
 			loginContext := m.newContext(nil, m.stdout, m.stderr, m.stdin)
 			if err = cmd.Run(loginContext, client); err == nil {
@@ -256,22 +438,39 @@ func (m *Manager) Run(args []string) {
 			errorMsg += "\n"
 		}
 		if err != ErrAbortCommand {
-			io.WriteString(m.stderr, "Error: "+errorMsg)
+			io.WriteString(m.stderr, "Error: "+errorMsg+fieldErrors(err))
 		}
-		status = 1
+		status = exitCode(err)
+	}
+	if m.telemetry {
+		m.reportTelemetry(name, time.Since(start), status, err)
 	}
 	m.finisher().Exit(status)
 }
 
+// setOutputOverrides disables colors, when requested or when the output is
+// not a terminal, and the pager. The settings are kept in the environment
+// variables read by Colorfy and the pager, so plugins honor them too.
+func (m *Manager) setOutputOverrides(noColor, noPager bool) {
+	if desc, ok := m.stdout.(descriptable); noColor || (ok && !terminal.IsTerminal(int(desc.Fd()))) {
+		os.Setenv("TSURU_DISABLE_COLORS", "1")
+	}
+	if noPager {
+		os.Setenv("TSURU_PAGER", "")
+	}
+}
+
 func (m *Manager) newContext(args []string, stdout io.Writer, stderr io.Writer, stdin io.Reader) *Context {
 	stdout = newPagerWriter(stdout)
 	stdin = newSyncReader(stdin, stdout)
-	ctx := &Context{args, stdout, stderr, stdin}
+	ctx := &Context{Args: args, Stdout: stdout, Stderr: stderr, Stdin: stdin}
 	m.contexts = append(m.contexts, ctx)
 	return ctx
 }
 
-func (m *Manager) handleFlags(command Command, name string, args []string) (Command, []string, error) {
+// handleFlags parses the flags of the command. Commands not defining their own
+// format flag accept the global --format flag after the command name too.
+func (m *Manager) handleFlags(command Command, name string, args []string, format *string) (Command, []string, error) {
 	var flagset *gnuflag.FlagSet
 	if flagged, ok := command.(FlaggedCommand); ok {
 		flagset = flagged.Flags()
@@ -286,6 +485,9 @@ func (m *Manager) handleFlags(command Command, name string, args []string) (Comm
 	if flagset.Lookup("h") == nil {
 		flagset.BoolVar(&helpRequested, "h", false, "Display help and exit")
 	}
+	if flagset.Lookup("format") == nil {
+		flagset.StringVar(format, "format", *format, formatUsage)
+	}
 	err := flagset.Parse(true, args)
 	if err != nil {
 		return nil, nil, err
@@ -346,6 +548,10 @@ type Context struct {
 	Stdout io.Writer
 	Stderr io.Writer
 	Stdin  io.Reader
+	// Format is the output format requested with the --format flag, one of
+	// FormatTable, FormatJSON or FormatYAML. Commands rendering tables
+	// should call Render when Structured returns true.
+	Format string
 }
 
 func (c *Context) RawOutput() {
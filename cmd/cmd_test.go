@@ -8,10 +8,15 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
+	"syscall"
 
+	"github.com/pkg/errors"
 	"github.com/tsuru/gnuflag"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/fs"
 	"github.com/tsuru/tsuru/fs/fstest"
 	"gopkg.in/check.v1"
@@ -152,6 +157,41 @@ func (s *S) TestManagerRunShouldNotWriteErrorOnStderrWhenErrAbortIsTriggered(c *
 	c.Assert(globalManager.e.(*recordingExiter).value(), check.Equals, 1)
 }
 
+func (s *S) TestManagerRunExitCodes(c *check.C) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{&tsuruErrors.HTTP{Code: http.StatusNotFound, Message: "app not found"}, ExitCodeNotFound},
+		{&tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "forbidden"}, ExitCodeAuthFailure},
+		{&tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "Quota exceeded", ErrorCode: tsuruErrors.CodeQuotaExceeded}, ExitCodeQuotaExceeded},
+		{errors.Wrap(&tsuruErrors.HTTP{Code: http.StatusConflict, Message: "locked", ErrorCode: tsuruErrors.CodeLocked}, "unable to deploy"), ExitCodeLocked},
+		{&tsuruErrors.HTTP{Code: http.StatusConflict, Message: "already exists"}, ExitCodeError},
+		{fmt.Errorf("some error"), ExitCodeError},
+	}
+	cmd := &HTTPErrorCommand{}
+	globalManager.Register(cmd)
+	for _, t := range tests {
+		cmd.err = t.err
+		globalManager.Run([]string{"http-error"})
+		c.Check(globalManager.e.(*recordingExiter).value(), check.Equals, t.expected, check.Commentf("%v", t.err))
+	}
+}
+
+func (s *S) TestManagerRunWritesInvalidFields(c *check.C) {
+	globalManager.Register(&HTTPErrorCommand{err: &tsuruErrors.HTTP{
+		Code:    http.StatusBadRequest,
+		Message: "invalid backup policy",
+		Fields:  map[string]string{"schedule": "never runs", "before-unbind": "must be a boolean"},
+	}})
+	globalManager.Run([]string{"http-error"})
+	c.Assert(globalManager.stderr.(*bytes.Buffer).String(), check.Equals, `Error: invalid backup policy
+  before-unbind: must be a boolean
+  schedule: never runs
+`)
+	c.Assert(globalManager.e.(*recordingExiter).value(), check.Equals, ExitCodeError)
+}
+
 func (s *S) TestManagerRunWithHTTPUnauthorizedError(c *check.C) {
 	globalManager.Register(&UnauthorizedErrorCommand{})
 	globalManager.Run([]string{"unauthorized-error"})
@@ -173,6 +213,21 @@ worked nicely!
 	c.Assert(globalManager.stdout.(*bytes.Buffer).String(), check.Equals, expectedStdout)
 }
 
+func (s *S) TestManagerRunWithTokenExpiredAndLoginRegistered(c *check.C) {
+	expectedStderr := `Error: your session has expired.
+Calling the "login" command...
+
+`
+	expectedStdout := `logged in!
+worked nicely!
+`
+	globalManager.Register(&FailAndWorkCommand{err: errTokenExpired})
+	globalManager.Register(&SuccessLoginCommand{})
+	globalManager.Run([]string{"fail-and-work"})
+	c.Assert(globalManager.stderr.(*bytes.Buffer).String(), check.Equals, expectedStderr)
+	c.Assert(globalManager.stdout.(*bytes.Buffer).String(), check.Equals, expectedStdout)
+}
+
 func (s *S) TestManagerRunWithHTTPUnauthorizedErrorAndLoginFailure(c *check.C) {
 	expected := `Error: you're not authenticated or your session has expired.
 Calling the "login" command...
@@ -219,6 +274,110 @@ func (s *S) TestManagerRunWithInvalidValueForFlag(c *check.C) {
 	c.Assert(exiter.value(), check.Equals, 1)
 }
 
+func (s *S) TestManagerRunWithFormat(c *check.C) {
+	cmd := &FormatCommand{}
+	globalManager.Register(cmd)
+	globalManager.Run([]string{"format"})
+	c.Assert(cmd.format, check.Equals, FormatTable)
+	globalManager.Run([]string{"--format", "json", "format"})
+	c.Assert(cmd.format, check.Equals, FormatJSON)
+	globalManager.Run([]string{"format", "--format", "yaml"})
+	c.Assert(cmd.format, check.Equals, FormatYAML)
+}
+
+func (s *S) TestManagerRunWithTemplateFormat(c *check.C) {
+	cmd := &FormatCommand{}
+	globalManager.Register(cmd)
+	globalManager.Run([]string{"format", "--format", "go-template={{.Name}}"})
+	c.Assert(cmd.format, check.Equals, "go-template={{.Name}}")
+	cmd.format = ""
+	globalManager.Run([]string{"format", "--format", "go-template={{.Name"})
+	c.Assert(cmd.format, check.Equals, "")
+	c.Assert(globalManager.stderr.(*bytes.Buffer).String(), check.Matches, `invalid output template: .*unclosed action.*\n`)
+	c.Assert(globalManager.e.(*recordingExiter).value(), check.Equals, 1)
+}
+
+func (s *S) TestManagerRunWithInvalidFormat(c *check.C) {
+	cmd := &FormatCommand{}
+	globalManager.Register(cmd)
+	globalManager.Run([]string{"--format", "xml", "format"})
+	c.Assert(cmd.format, check.Equals, "")
+	c.Assert(globalManager.stderr.(*bytes.Buffer).String(), check.Equals, `invalid output format "xml", valid formats are: table, json, yaml, tsv, go-template=<template>`+"\n")
+	c.Assert(globalManager.e.(*recordingExiter).value(), check.Equals, 1)
+}
+
+func (s *S) TestManagerRunWithTarget(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+		targetOverride = ""
+	}()
+	err := WriteOnTargetList("prod", "https://prod.tsuru.io")
+	c.Assert(err, check.IsNil)
+	cmd := &TargetCommand{}
+	globalManager.Register(cmd)
+	globalManager.Run([]string{"target-cmd"})
+	c.Assert(cmd.target, check.Equals, "http://localhost")
+	globalManager.Run([]string{"--target", "prod", "target-cmd"})
+	c.Assert(cmd.target, check.Equals, "https://prod.tsuru.io")
+	cmd.target = ""
+	globalManager.Run([]string{"--target", "staging", "target-cmd"})
+	c.Assert(cmd.target, check.Equals, "")
+	c.Assert(globalManager.stderr.(*bytes.Buffer).String(), check.Equals, `target "staging" not found in the target list`+"\n")
+	c.Assert(globalManager.e.(*recordingExiter).value(), check.Equals, 1)
+}
+
+func (s *S) TestManagerRunNoColor(c *check.C) {
+	os.Unsetenv("TSURU_DISABLE_COLORS")
+	defer os.Unsetenv("TSURU_DISABLE_COLORS")
+	globalManager.Register(&TestCommand{})
+	globalManager.Run([]string{"foo"})
+	c.Assert(os.Getenv("TSURU_DISABLE_COLORS"), check.Equals, "")
+	globalManager.Run([]string{"--no-color", "foo"})
+	c.Assert(os.Getenv("TSURU_DISABLE_COLORS"), check.Equals, "1")
+	c.Assert(Colorfy("ok", "green", "", ""), check.Equals, "ok")
+}
+
+func (s *S) TestManagerRunDisablesColorsWhenOutputIsNotATerminal(c *check.C) {
+	os.Unsetenv("TSURU_DISABLE_COLORS")
+	defer os.Unsetenv("TSURU_DISABLE_COLORS")
+	f, err := ioutil.TempFile("", "tsuru-output")
+	c.Assert(err, check.IsNil)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	manager := NewManager("glb", "1.0", "", f, globalManager.stderr, os.Stdin, nil)
+	manager.e = globalManager.e
+	manager.Register(&TestCommand{})
+	manager.Run([]string{"foo"})
+	c.Assert(os.Getenv("TSURU_DISABLE_COLORS"), check.Equals, "1")
+}
+
+func (s *S) TestManagerRunNoPager(c *check.C) {
+	os.Unsetenv("TSURU_PAGER")
+	defer os.Unsetenv("TSURU_PAGER")
+	globalManager.Register(&TestCommand{})
+	globalManager.Run([]string{"--no-pager", "foo"})
+	pager, found := syscall.Getenv("TSURU_PAGER")
+	c.Assert(found, check.Equals, true)
+	c.Assert(pager, check.Equals, "")
+}
+
+func (s *S) TestManagerRunVerbosity(c *check.C) {
+	os.Unsetenv("TSURU_DEBUG")
+	cmd := &VerbosityCommand{}
+	globalManager.Register(cmd)
+	globalManager.Run([]string{"verbosity-cmd"})
+	c.Assert(cmd.verbosity, check.Equals, 0)
+	globalManager.Run([]string{"-v", "1", "verbosity-cmd"})
+	c.Assert(cmd.verbosity, check.Equals, 1)
+	globalManager.Run([]string{"--verbose", "verbosity-cmd"})
+	c.Assert(cmd.verbosity, check.Equals, 2)
+	os.Setenv("TSURU_DEBUG", "1")
+	defer os.Unsetenv("TSURU_DEBUG")
+	globalManager.Run([]string{"verbosity-cmd"})
+	c.Assert(cmd.verbosity, check.Equals, 2)
+}
+
 func (s *S) TestRun(c *check.C) {
 	globalManager.Register(&TestCommand{})
 	globalManager.Run([]string{"foo"})
@@ -243,7 +402,7 @@ Available commands:
 Use glb help <commandname> to get more information about a command.
 `
 	globalManager.RegisterDeprecated(&login{}, "login")
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := help{manager: globalManager}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.IsNil)
@@ -269,7 +428,7 @@ Use glb help <topicname> to get more information about a topic.
 `
 	globalManager.Register(&login{})
 	globalManager.RegisterTopic("target", "something")
-	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := help{manager: globalManager}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.IsNil)
@@ -284,7 +443,7 @@ Targets
 Tsuru likes to manage targets
 `
 	globalManager.RegisterTopic("target", "Targets\n\nTsuru likes to manage targets\n")
-	context := Context{[]string{"target"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{"target"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	command := help{manager: globalManager}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.IsNil)
@@ -302,7 +461,7 @@ func (s *S) TestHelpCommandShouldBeRegisteredByDefault(c *check.C) {
 
 func (s *S) TestHelpReturnErrorIfTheGivenCommandDoesNotExist(c *check.C) {
 	command := help{manager: globalManager}
-	context := Context{[]string{"user-create"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := Context{Args: []string{"user-create"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err, check.ErrorMatches, `^command "user-create" does not exist.$`)
@@ -488,7 +647,7 @@ func (s *S) TestVersion(c *check.C) {
 	var exiter recordingExiter
 	mngr.e = &exiter
 	command := version{manager: mngr}
-	context := Context{[]string{}, mngr.stdout, mngr.stderr, mngr.stdin}
+	context := Context{Args: []string{}, Stdout: mngr.stdout, Stderr: mngr.stderr, Stdin: mngr.stdin}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(mngr.stdout.(*bytes.Buffer).String(), check.Equals, "tsuru version 5.0.\n")
@@ -575,7 +734,7 @@ Foo do anything or nothing.
 	var exiter recordingExiter
 	mngr.e = &exiter
 	mngr.Register(&TestCommand{})
-	context := Context{[]string{"foo"}, mngr.stdout, mngr.stderr, mngr.stdin}
+	context := Context{Args: []string{"foo"}, Stdout: mngr.stdout, Stderr: mngr.stderr, Stdin: mngr.stdin}
 	command := help{manager: mngr}
 	err := command.Run(&context, nil)
 	c.Assert(err, check.IsNil)
@@ -652,6 +811,20 @@ func (s *S) TestUserInfoIsRegisteredByBaseManager(c *check.C) {
 	c.Assert(info, check.FitsTypeOf, userInfo{})
 }
 
+func (s *S) TestStatusIsRegisteredByBaseManager(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	info, ok := mngr.Commands["status"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(info, check.FitsTypeOf, statusOverview{})
+}
+
+func (s *S) TestCompletionIsRegisteredByBaseManager(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	cmd, ok := mngr.Commands["completion"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(cmd, check.FitsTypeOf, &completion{})
+}
+
 func (s *S) TestInvalidCommandFuzzyMatch01(c *check.C) {
 	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
 	var stdout, stderr bytes.Buffer
@@ -704,7 +877,20 @@ func (s *S) TestInvalidCommandFuzzyMatch03(c *check.C) {
 	expectedOutput := `.*: "list" is not a tsuru command. See "tsuru help".
 
 Did you mean?
+	app-dependency-list
+	app-deploy-rollback-list
+	app-deploy-schedule-list
+	app-review-app-list
+	app-swap-list
+	event-block-list
+	event-list
+	group-list
+	job-list
+	log-drain-list
+	plugin-list
+	session-list
 	target-list
+	token-list
 `
 	expectedOutput = strings.Replace(expectedOutput, "\n", "\\W", -1)
 	expectedOutput = strings.Replace(expectedOutput, "\t", "\\W+", -1)
@@ -841,6 +1027,45 @@ func (c *TestCommand) Run(context *Context, client *Client) error {
 	return nil
 }
 
+type FormatCommand struct {
+	format string
+}
+
+func (c *FormatCommand) Info() *Info {
+	return &Info{Name: "format"}
+}
+
+func (c *FormatCommand) Run(context *Context, client *Client) error {
+	c.format = context.Format
+	return nil
+}
+
+type TargetCommand struct {
+	target string
+}
+
+func (c *TargetCommand) Info() *Info {
+	return &Info{Name: "target-cmd"}
+}
+
+func (c *TargetCommand) Run(context *Context, client *Client) error {
+	c.target, _ = ReadTarget()
+	return nil
+}
+
+type VerbosityCommand struct {
+	verbosity int
+}
+
+func (c *VerbosityCommand) Info() *Info {
+	return &Info{Name: "verbosity-cmd"}
+}
+
+func (c *VerbosityCommand) Run(context *Context, client *Client) error {
+	c.verbosity = client.Verbosity
+	return nil
+}
+
 type ErrorCommand struct {
 	msg string
 }
@@ -856,8 +1081,21 @@ func (c *ErrorCommand) Run(context *Context, client *Client) error {
 	return fmt.Errorf(c.msg)
 }
 
+type HTTPErrorCommand struct {
+	err error
+}
+
+func (c *HTTPErrorCommand) Info() *Info {
+	return &Info{Name: "http-error"}
+}
+
+func (c *HTTPErrorCommand) Run(context *Context, client *Client) error {
+	return c.err
+}
+
 type FailAndWorkCommand struct {
 	calls int
+	err   error
 }
 
 func (c *FailAndWorkCommand) Info() *Info {
@@ -867,6 +1105,9 @@ func (c *FailAndWorkCommand) Info() *Info {
 func (c *FailAndWorkCommand) Run(context *Context, client *Client) error {
 	c.calls++
 	if c.calls == 1 {
+		if c.err != nil {
+			return c.err
+		}
 		return errUnauthorized
 	}
 	fmt.Fprintln(context.Stdout, "worked nicely!")
@@ -0,0 +1,286 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+// Kinds of values completed using the tsuru API.
+const (
+	completeApps  = "apps"
+	completeTeams = "teams"
+	completePools = "pools"
+)
+
+var completionResources = map[string]string{
+	completeApps:  "/apps",
+	completeTeams: "/teams",
+	completePools: "/pools",
+}
+
+var completionScripts = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Parse(`# bash completion for {{.}}, load it with:
+#   source <({{.}} completion bash)
+_{{.}}_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($({{.}} completion --complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _{{.}}_complete {{.}}
+`)),
+	"zsh": template.Must(template.New("zsh").Parse(`#compdef {{.}}
+# zsh completion for {{.}}, load it with:
+#   source <({{.}} completion zsh)
+_{{.}}() {
+    local -a candidates
+    candidates=(${(f)"$({{.}} completion --complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    compadd -a candidates
+}
+compdef _{{.}} {{.}}
+`)),
+	"fish": template.Must(template.New("fish").Parse(`# fish completion for {{.}}, load it with:
+#   {{.}} completion fish | source
+function __{{.}}_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    set -e tokens[1]
+    {{.}} completion --complete -- $tokens "$current" 2>/dev/null
+end
+complete -c {{.}} -f -a '(__{{.}}_complete)'
+`)),
+}
+
+type completion struct {
+	manager  *Manager
+	fs       *gnuflag.FlagSet
+	complete bool
+}
+
+func (c *completion) Info() *Info {
+	return &Info{
+		Name:  "completion",
+		Usage: "completion <bash|zsh|fish>",
+		Desc: `Generates the shell completion script of the commands. Names of apps, teams
+and pools are completed using the current target.
+
+To load the completions in the current shell session, run:
+
+    source <(tsuru completion bash)
+
+or, in fish:
+
+    tsuru completion fish | source`,
+		MinArgs: 1,
+	}
+}
+
+func (c *completion) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("completion", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.complete, "complete", false, "Print the candidates for the last word of the given command line, used by the completion scripts")
+	}
+	return c.fs
+}
+
+func (c *completion) Run(context *Context, client *Client) error {
+	if c.complete {
+		// Failures of the API are not reported, the shell would either show
+		// them as candidates or hang waiting for a login.
+		candidates, _ := c.manager.completeWords(context.Args, client)
+		for _, candidate := range candidates {
+			fmt.Fprintln(context.Stdout, candidate)
+		}
+		return nil
+	}
+	script, ok := completionScripts[context.Args[0]]
+	if !ok || len(context.Args) > 1 {
+		return errors.Errorf("unsupported shell %q, supported shells are: bash, zsh, fish", strings.Join(context.Args, " "))
+	}
+	return script.Execute(context.Stdout, c.manager.name)
+}
+
+// completeWords returns the candidates for the last word of the command line
+// in words, which doesn't include the program name. The current word is the
+// last one and may be empty.
+func (m *Manager) completeWords(words []string, client *Client) ([]string, error) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	if len(words) == 1 {
+		var names []string
+		for name, cmd := range m.Commands {
+			if _, ok := cmd.(*DeprecatedCommand); !ok {
+				names = append(names, name)
+			}
+		}
+		return filterCandidates(names, current), nil
+	}
+	command, ok := m.Commands[words[0]]
+	if !ok {
+		return nil, nil
+	}
+	var flagset *gnuflag.FlagSet
+	if flagged, ok := command.(FlaggedCommand); ok {
+		flagset = flagged.Flags()
+	}
+	if strings.HasPrefix(current, "-") {
+		var names []string
+		if flagset != nil {
+			flagset.VisitAll(func(f *gnuflag.Flag) {
+				if len(f.Name) == 1 {
+					names = append(names, "-"+f.Name)
+				} else {
+					names = append(names, "--"+f.Name)
+				}
+			})
+		}
+		return filterCandidates(names, current), nil
+	}
+	var kind string
+	if f := lookupValueFlag(flagset, words[len(words)-2]); f != nil {
+		kind = flagCompletionKind(flagset, f)
+	} else {
+		position := 0
+		for i := 1; i < len(words)-1; i++ {
+			if strings.HasPrefix(words[i], "-") {
+				continue
+			}
+			if lookupValueFlag(flagset, words[i-1]) == nil {
+				position++
+			}
+		}
+		args := usageArgs(command.Info().Usage)
+		if position >= len(args) {
+			return nil, nil
+		}
+		kind = usageCompletionKind(args[position])
+	}
+	if kind == "" {
+		return nil, nil
+	}
+	names, err := listResourceNames(client, kind)
+	if err != nil {
+		return nil, err
+	}
+	return filterCandidates(names, current), nil
+}
+
+// lookupValueFlag returns the flag identified by word if it's a flag that
+// takes a value as the next argument.
+func lookupValueFlag(flagset *gnuflag.FlagSet, word string) *gnuflag.Flag {
+	if flagset == nil || !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return nil
+	}
+	f := flagset.Lookup(strings.TrimLeft(word, "-"))
+	if f == nil {
+		return nil
+	}
+	if b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	}); ok && b.IsBoolFlag() {
+		return nil
+	}
+	return f
+}
+
+// flagCompletionKind returns the kind of the values of the flag, based on its
+// name or on the name of the flags it's an alias of.
+func flagCompletionKind(flagset *gnuflag.FlagSet, f *gnuflag.Flag) string {
+	if kind := usageCompletionKind(f.Name); kind != "" {
+		return kind
+	}
+	var kind string
+	if !reflect.TypeOf(f.Value).Comparable() {
+		return kind
+	}
+	flagset.VisitAll(func(other *gnuflag.Flag) {
+		if kind == "" && other != f && reflect.TypeOf(other.Value) == reflect.TypeOf(f.Value) && other.Value == f.Value {
+			kind = usageCompletionKind(other.Name)
+		}
+	})
+	return kind
+}
+
+// usageArgs returns the placeholders of the positional arguments in the
+// usage of a command, skipping flags and their values.
+func usageArgs(usage string) []string {
+	fields := strings.Fields(usage)
+	var args []string
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		if strings.HasPrefix(strings.TrimLeft(field, "["), "-") {
+			if !strings.HasSuffix(field, "]") && !strings.Contains(field, "=") && i+1 < len(fields) &&
+				!strings.HasPrefix(strings.TrimLeft(fields[i+1], "["), "-") {
+				i++
+			}
+			continue
+		}
+		args = append(args, field)
+	}
+	return args
+}
+
+func usageCompletionKind(placeholder string) string {
+	name := strings.ToLower(strings.Trim(placeholder, "<>[]."))
+	name = strings.NewReplacer("-", "", "_", "", " ", "").Replace(name)
+	switch name {
+	case "app", "appname":
+		return completeApps
+	case "team", "teamname":
+		return completeTeams
+	case "pool", "poolname":
+		return completePools
+	}
+	return ""
+}
+
+func listResourceNames(client *Client, kind string) ([]string, error) {
+	url, err := GetURL(completionResources[kind])
+	if err != nil {
+		return nil, err
+	}
+	request, _ := http.NewRequest("GET", url, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	var items []struct {
+		Name string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&items)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	return names, nil
+}
+
+func filterCandidates(candidates []string, prefix string) []string {
+	var result []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			result = append(result, candidate)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
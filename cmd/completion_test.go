@@ -0,0 +1,127 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/gnuflag"
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+type completionTestCommand struct {
+	GuessingCommand
+	fs      *gnuflag.FlagSet
+	restart bool
+}
+
+func (c *completionTestCommand) Info() *Info {
+	return &Info{
+		Name:  "team-grant",
+		Usage: "team-grant <teamname> [-r/--restart] [-a/--app appname]",
+	}
+}
+
+func (c *completionTestCommand) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.BoolVar(&c.restart, "restart", false, "Restart the app.")
+		c.fs.BoolVar(&c.restart, "r", false, "Restart the app.")
+	}
+	return c.fs
+}
+
+func (c *completionTestCommand) Run(context *Context, client *Client) error {
+	return nil
+}
+
+func (s *S) completionManager() *Manager {
+	mngr := NewManager("tsuru", "1.0", "", nil, nil, nil, nil)
+	mngr.Register(&completionTestCommand{})
+	mngr.Register(&completion{manager: mngr})
+	return mngr
+}
+
+func (s *S) TestCompletionInfo(c *check.C) {
+	c.Assert((&completion{}).Info(), check.NotNil)
+}
+
+func (s *S) TestCompletionScripts(c *check.C) {
+	mngr := s.completionManager()
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var stdout bytes.Buffer
+		context := Context{Args: []string{shell}, Stdout: &stdout}
+		err := mngr.Commands["completion"].Run(&context, nil)
+		c.Assert(err, check.IsNil)
+		c.Assert(stdout.String(), check.Matches, "(?s).*tsuru completion --complete -- .*")
+		c.Assert(stdout.String(), check.Matches, "(?s).*\\b_+tsuru(_complete)?\\b.*")
+	}
+}
+
+func (s *S) TestCompletionUnsupportedShell(c *check.C) {
+	mngr := s.completionManager()
+	context := Context{Args: []string{"tcsh"}, Stdout: &bytes.Buffer{}}
+	err := mngr.Commands["completion"].Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `unsupported shell "tcsh", supported shells are: bash, zsh, fish`)
+}
+
+func (s *S) TestCompleteWords(c *check.C) {
+	teams := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `[{"name":"myteam"},{"name":"otherteam"}]`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/teams"
+		},
+	}
+	apps := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `[{"name":"app1"},{"name":"app2"},{"name":"other"}]`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps"
+		},
+	}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{teams, teams, apps, apps, teams},
+	}
+	mngr := s.completionManager()
+	client := NewClient(&http.Client{Transport: &transport}, nil, mngr)
+	tests := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{""}, []string{"completion", "help", "team-grant", "version"}},
+		{[]string{"team-"}, []string{"team-grant"}},
+		{[]string{"team-grant", "--"}, []string{"--app", "--restart"}},
+		{[]string{"team-grant", "my"}, []string{"myteam"}},
+		{[]string{"team-grant", "-r", "o"}, []string{"otherteam"}},
+		{[]string{"team-grant", "myteam", "-a", "app"}, []string{"app1", "app2"}},
+		{[]string{"team-grant", "myteam", "--app", ""}, []string{"app1", "app2", "other"}},
+		{[]string{"team-grant", "-a", "app1", "my"}, []string{"myteam"}},
+		{[]string{"team-grant", "myteam", ""}, nil},
+		{[]string{"unknown", ""}, nil},
+	}
+	for _, tt := range tests {
+		candidates, err := mngr.completeWords(tt.words, client)
+		c.Check(err, check.IsNil)
+		c.Check(candidates, check.DeepEquals, tt.expected, check.Commentf("words %q", tt.words))
+	}
+}
+
+func (s *S) TestCompletionRunComplete(c *check.C) {
+	transport := cmdtest.Transport{Status: http.StatusUnauthorized}
+	mngr := s.completionManager()
+	client := NewClient(&http.Client{Transport: &transport}, nil, mngr)
+	var stdout bytes.Buffer
+	context := Context{Args: []string{"team-grant", ""}, Stdout: &stdout}
+	cmd := mngr.Commands["completion"].(*completion)
+	cmd.Flags().Parse(true, []string{"--complete"})
+	err := cmd.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "")
+	context = Context{Args: []string{"team-g"}, Stdout: &stdout}
+	err = cmd.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "team-grant\n")
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DeployChunkSize is the size of the chunks the deploy archive is split into
+// by UploadDeployChunks. It must not exceed the deploy:chunks:max-size
+// setting of the server, 4 MiB by default.
+const DeployChunkSize = 1 << 20
+
+type deployChunk struct {
+	hash   string
+	offset int64
+	size   int
+}
+
+// UploadDeployChunks splits the archive in chunks identified by their SHA-256
+// sum, asks the server which chunks it doesn't have for the app and uploads
+// only those. It returns the value of the chunks parameter of the deploy
+// request, used by the server to reassemble the archive.
+func UploadDeployChunks(client *Client, appName string, archive io.ReadSeeker) (string, error) {
+	chunks, err := splitDeployChunks(archive)
+	if err != nil {
+		return "", err
+	}
+	hashes := make([]string, len(chunks))
+	byHash := make(map[string]deployChunk, len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = chunk.hash
+		if _, ok := byHash[chunk.hash]; !ok {
+			byHash[chunk.hash] = chunk
+		}
+	}
+	missing, err := missingDeployChunks(client, appName, hashes)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, DeployChunkSize)
+	for _, hash := range missing {
+		chunk, ok := byHash[hash]
+		if !ok {
+			return "", fmt.Errorf("server reported unknown chunk %s as missing", hash)
+		}
+		_, err = archive.Seek(chunk.offset, io.SeekStart)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.ReadFull(archive, buf[:chunk.size])
+		if err != nil {
+			return "", err
+		}
+		err = uploadDeployChunk(client, appName, hash, buf[:chunk.size])
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(hashes, ","), nil
+}
+
+func splitDeployChunks(archive io.ReadSeeker) ([]deployChunk, error) {
+	_, err := archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []deployChunk
+	var offset int64
+	buf := make([]byte, DeployChunkSize)
+	for {
+		n, err := io.ReadFull(archive, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			chunks = append(chunks, deployChunk{hash: hex.EncodeToString(sum[:]), offset: offset, size: n})
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func missingDeployChunks(client *Client, appName string, hashes []string) ([]string, error) {
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/deploy/chunks/missing", appName))
+	if err != nil {
+		return nil, err
+	}
+	body := strings.NewReader(url.Values{"hash": hashes}.Encode())
+	request, err := http.NewRequest("POST", u, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Missing []string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Missing, nil
+}
+
+func uploadDeployChunk(client *Client, appName, hash string, data []byte) error {
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/deploy/chunks/%s", appName, hash))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
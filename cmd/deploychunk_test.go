@@ -0,0 +1,65 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestUploadDeployChunks(c *check.C) {
+	first := bytes.Repeat([]byte("a"), DeployChunkSize)
+	sum := sha256.Sum256(first)
+	firstHash := hex.EncodeToString(sum[:])
+	sum = sha256.Sum256([]byte("bbb"))
+	lastHash := hex.EncodeToString(sum[:])
+	archive := bytes.NewReader(append(append(append([]byte{}, first...), first...), "bbb"...))
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{Message: `{"missing": ["` + lastHash + `"]}`, Status: http.StatusOK},
+				CondFunc: func(req *http.Request) bool {
+					req.ParseForm()
+					return req.Method == "POST" && req.URL.Path == "/1.3/apps/myapp/deploy/chunks/missing" &&
+						c.Check(req.Form["hash"], check.DeepEquals, []string{firstHash, firstHash, lastHash})
+				},
+			},
+			{
+				Transport: cmdtest.Transport{Status: http.StatusCreated},
+				CondFunc: func(req *http.Request) bool {
+					body, _ := ioutil.ReadAll(req.Body)
+					return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/deploy/chunks/"+lastHash &&
+						string(body) == "bbb"
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	chunks, err := UploadDeployChunks(client, "myapp", archive)
+	c.Assert(err, check.IsNil)
+	c.Assert(chunks, check.Equals, firstHash+","+firstHash+","+lastHash)
+	c.Assert(transport.ConditionalTransports, check.HasLen, 0)
+}
+
+func (s *S) TestUploadDeployChunksNothingMissing(c *check.C) {
+	sum := sha256.Sum256([]byte("hello"))
+	hash := hex.EncodeToString(sum[:])
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"missing": []}`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/myapp/deploy/chunks/missing"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	chunks, err := UploadDeployChunks(client, "myapp", bytes.NewReader([]byte("hello")))
+	c.Assert(err, check.IsNil)
+	c.Assert(chunks, check.Equals, hash)
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type rollbackImage struct {
+	Image   string
+	Version string
+	Current bool
+	Deploy  *struct {
+		Timestamp time.Time
+		User      string
+		Commit    string
+		Origin    string
+	} `json:",omitempty"`
+}
+
+type deployRollbackList struct {
+	GuessingCommand
+}
+
+func (c *deployRollbackList) Info() *Info {
+	return &Info{
+		Name:  "app-deploy-rollback-list",
+		Usage: "app-deploy-rollback-list [-a/--app appname]",
+		Desc: `Lists the images of an app retained for rollbacks, the newest first. Any of
+them can be deployed again, without a rebuild, with "tsuru app-deploy-rollback
+-a appname <version>". The number of images retained is set by the
+docker:image-history-size setting of the server.`,
+	}
+}
+
+func (c *deployRollbackList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/deploy/rollback")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var images []rollbackImage
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&images)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if images == nil {
+			images = []rollbackImage{}
+		}
+		return context.Render(images)
+	}
+	table := NewTable()
+	table.Headers = Row{"Version", "Image", "Deployed at", "User", "Commit"}
+	for _, img := range images {
+		version := img.Version
+		if img.Current {
+			version += " (current)"
+		}
+		var deployedAt, user, commit string
+		if img.Deploy != nil {
+			deployedAt = img.Deploy.Timestamp.Local().Format(time.RFC822)
+			user = img.Deploy.User
+			commit = img.Deploy.Commit
+		}
+		table.AddRow(Row{version, img.Image, deployedAt, user, commit})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeployRollbackListRun(c *check.C) {
+	deployedAt := time.Date(2017, 3, 1, 10, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Image": "tsuru/app-myapp:v2", "Version": "v2", "Current": true,
+"Deploy": {"Timestamp": "2017-03-01T10:00:00Z", "User": "me@tsuru.io", "Commit": "abc123"}},
+{"Image": "tsuru/app-myapp:v1", "Version": "v1"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/deploy/rollback"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := deployRollbackList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Version", "Image", "Deployed at", "User", "Commit"}
+	table.AddRow(Row{"v2 (current)", "tsuru/app-myapp:v2", deployedAt.Local().Format(time.RFC822), "me@tsuru.io", "abc123"})
+	table.AddRow(Row{"v1", "tsuru/app-myapp:v1", "", "", ""})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestDeployRollbackListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := deployRollbackList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type scheduledDeploy struct {
+	ID     string
+	RunAt  time.Time
+	Target struct {
+		Type  string
+		Value string
+	}
+	Owner struct {
+		Type string
+		Name string
+	}
+	Attempts        int
+	LastError       string
+	PendingApproval bool
+}
+
+type deployScheduleList struct {
+	GuessingCommand
+}
+
+func (c *deployScheduleList) Info() *Info {
+	return &Info{
+		Name:  "app-deploy-schedule-list",
+		Usage: "app-deploy-schedule-list [-a/--app appname]",
+		Desc: `Lists the deploys of an app scheduled to run at a given time, in the order
+they will run, including the deploys waiting for approval.`,
+	}
+}
+
+func (c *deployScheduleList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("kindname", "app.deploy")
+	v.Set("target.type", "app")
+	v.Set("target.value", appName)
+	u, err := GetURLVersion("1.3", "/events/scheduled?"+v.Encode())
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var deploys []scheduledDeploy
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&deploys)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if deploys == nil {
+			deploys = []scheduledDeploy{}
+		}
+		return context.Render(deploys)
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "Run at", "Owner", "Last error"}
+	for _, d := range deploys {
+		runAt := d.RunAt.Local().Format(time.RFC822)
+		if d.PendingApproval {
+			runAt += " (pending approval)"
+		}
+		table.AddRow(Row{d.ID, runAt, d.Owner.Name, d.LastError})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type deployScheduleCancel struct {
+	ConfirmationCommand
+}
+
+func (c *deployScheduleCancel) Info() *Info {
+	return &Info{
+		Name:    "app-deploy-schedule-cancel",
+		Usage:   "app-deploy-schedule-cancel <id> [-y/--assume-yes]",
+		Desc:    "Cancels a scheduled deploy, as listed by app-deploy-schedule-list.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *deployScheduleCancel) Flags() *gnuflag.FlagSet {
+	return c.ConfirmationCommand.Flags()
+}
+
+func (c *deployScheduleCancel) Run(context *Context, client *Client) error {
+	id := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to cancel the scheduled deploy %q?", id)) {
+		return nil
+	}
+	u, err := GetURLVersion("1.3", "/events/scheduled/"+url.PathEscape(id))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Scheduled deploy %q successfully canceled!\n", id)
+	return nil
+}
+
+type deployApprove struct{}
+
+func (deployApprove) Info() *Info {
+	return &Info{
+		Name:  "app-deploy-approve",
+		Usage: "app-deploy-approve <id>",
+		Desc: `Approves a deploy waiting for approval, as listed by app-deploy-schedule-list.
+The deploy is started as soon as it's approved, or at its scheduled time. A
+deploy can't be approved by the user who started it.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (deployApprove) Run(context *Context, client *Client) error {
+	id := context.Args[0]
+	u, err := GetURLVersion("1.3", "/events/"+url.PathEscape(id)+"/approve")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Deploy %q successfully approved!\n", id)
+	return nil
+}
+
+type appDeployApprovalSet struct {
+	GuessingCommand
+}
+
+func (c *appDeployApprovalSet) Info() *Info {
+	return &Info{
+		Name:  "app-deploy-approval-set",
+		Usage: "app-deploy-approval-set [-a/--app appname] <true|false>",
+		Desc: `Sets whether deploys of the app must be approved before being started. Deploys
+of apps requiring approval wait until a user other than the one who started
+them, holding the app.approve.deploy permission, runs app-deploy-approve.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *appDeployApprovalSet) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	required, err := strconv.ParseBool(context.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid value %q, expected true or false", context.Args[0])
+	}
+	v := url.Values{}
+	v.Set("required", strconv.FormatBool(required))
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/deploy-approval")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if required {
+		fmt.Fprintf(context.Stdout, "Deploys of app %q now require approval.\n", appName)
+		return nil
+	}
+	fmt.Fprintf(context.Stdout, "Deploys of app %q no longer require approval.\n", appName)
+	return nil
+}
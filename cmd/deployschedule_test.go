@@ -0,0 +1,138 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeployScheduleListRun(c *check.C) {
+	runAt := time.Date(2016, 10, 1, 2, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"ID": "57ef1a000000000000000001", "RunAt": "2016-10-01T02:00:00Z", "Target": {"Type": "app", "Value": "myapp"},
+"Owner": {"Type": "user", "Name": "me@tsuru.io"}, "Attempts": 1, "LastError": "event locked"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/events/scheduled" &&
+				req.URL.Query().Get("kindname") == "app.deploy" &&
+				req.URL.Query().Get("target.type") == "app" &&
+				req.URL.Query().Get("target.value") == "myapp"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := deployScheduleList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"ID", "Run at", "Owner", "Last error"}
+	table.AddRow(Row{"57ef1a000000000000000001", runAt.Local().Format(time.RFC822), "me@tsuru.io", "event locked"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestDeployScheduleListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := deployScheduleList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestDeployScheduleCancelRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{
+		Args:   []string{"57ef1a000000000000000001"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("y\n"),
+	}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/events/scheduled/57ef1a000000000000000001"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := deployScheduleCancel{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to cancel the scheduled deploy "57ef1a000000000000000001"? (y/n) Scheduled deploy "57ef1a000000000000000001" successfully canceled!`+"\n")
+}
+
+func (s *S) TestDeployScheduleCancelRunAbort(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{
+		Args:   []string{"57ef1a000000000000000001"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("n\n"),
+	}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusInternalServerError}}, nil, globalManager)
+	command := deployScheduleCancel{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Matches, `(?s).*Abort\.\n`)
+}
+
+func (s *S) TestDeployApproveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"57ef1a000000000000000001"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/events/57ef1a000000000000000001/approve"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := deployApprove{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Deploy "57ef1a000000000000000001" successfully approved!`+"\n")
+}
+
+func (s *S) TestAppDeployApprovalSetRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"true"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/deploy-approval" &&
+				req.Form.Get("required") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appDeployApprovalSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Deploys of app "myapp" now require approval.`+"\n")
+}
+
+func (s *S) TestAppDeployApprovalSetRunInvalidValue(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"maybe"}, Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusOK}}, nil, globalManager)
+	command := appDeployApprovalSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, `invalid value "maybe", expected true or false`)
+}
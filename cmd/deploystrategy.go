@@ -0,0 +1,48 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+// DeployStrategyFlags holds the flags controlling the strategy of a deploy,
+// to be embedded by deploy commands.
+type DeployStrategyFlags struct {
+	Strategy     string
+	Steps        int
+	StepInterval time.Duration
+}
+
+// AddFlags adds the --strategy, --steps and --step-interval flags to the
+// flagset.
+func (f *DeployStrategyFlags) AddFlags(fs *gnuflag.FlagSet) {
+	fs.StringVar(&f.Strategy, "strategy", "", `The deploy strategy: "rolling" (the default), "blue-green" or "canary"`)
+	fs.IntVar(&f.Steps, "steps", 0, "The number of steps in which the traffic is shifted to new units in canary deploys")
+	fs.DurationVar(&f.StepInterval, "step-interval", 0, "For how long new units are watched after each shift of traffic, e.g. 30s")
+}
+
+// Values adds the strategy of the deploy to the values of the deploy request.
+func (f *DeployStrategyFlags) Values(v url.Values) error {
+	if f.Strategy == "" {
+		if f.Steps != 0 || f.StepInterval != 0 {
+			return fmt.Errorf("--steps and --step-interval can only be used along with --strategy")
+		}
+		return nil
+	}
+	v.Set("strategy", f.Strategy)
+	if f.Steps != 0 {
+		v.Set("steps", strconv.Itoa(f.Steps))
+	}
+	if f.StepInterval != 0 {
+		v.Set("step-interval", strconv.FormatFloat(f.StepInterval.Seconds(), 'f', -1, 64))
+	}
+	return nil
+}
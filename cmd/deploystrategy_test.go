@@ -0,0 +1,42 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"net/url"
+
+	"github.com/tsuru/gnuflag"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeployStrategyFlags(c *check.C) {
+	var flags DeployStrategyFlags
+	fs := gnuflag.NewFlagSet("app-deploy", gnuflag.ContinueOnError)
+	flags.AddFlags(fs)
+	err := fs.Parse(true, []string{"--strategy", "canary", "--steps", "4", "--step-interval", "1m30s"})
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	err = flags.Values(v)
+	c.Assert(err, check.IsNil)
+	c.Assert(v, check.DeepEquals, url.Values{
+		"strategy":      {"canary"},
+		"steps":         {"4"},
+		"step-interval": {"90"},
+	})
+}
+
+func (s *S) TestDeployStrategyFlagsEmpty(c *check.C) {
+	var flags DeployStrategyFlags
+	v := url.Values{}
+	err := flags.Values(v)
+	c.Assert(err, check.IsNil)
+	c.Assert(v, check.HasLen, 0)
+}
+
+func (s *S) TestDeployStrategyFlagsStepsWithoutStrategy(c *check.C) {
+	flags := DeployStrategyFlags{Steps: 3}
+	err := flags.Values(url.Values{})
+	c.Assert(err, check.ErrorMatches, "--steps and --step-interval can only be used along with --strategy")
+}
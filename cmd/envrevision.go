@@ -0,0 +1,248 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+type envRevisionVar struct {
+	Name      string
+	Value     string
+	Public    bool
+	Reference string `json:",omitempty"`
+}
+
+func (v *envRevisionVar) String() string {
+	if v == nil {
+		return ""
+	}
+	if v.Reference != "" {
+		return v.Reference
+	}
+	return v.Value
+}
+
+type envRevision struct {
+	Version      int
+	Timestamp    time.Time
+	Kind         string
+	User         string   `json:",omitempty"`
+	Changed      []string `json:",omitempty"`
+	RestoredFrom int      `json:",omitempty"`
+	Envs         map[string]envRevisionVar
+}
+
+type envDiff struct {
+	Name   string
+	Change string
+	From   *envRevisionVar `json:",omitempty"`
+	To     *envRevisionVar `json:",omitempty"`
+}
+
+type envHistory struct {
+	GuessingCommand
+}
+
+func (c *envHistory) Info() *Info {
+	return &Info{
+		Name:  "env-history",
+		Usage: "env-history [-a/--app appname]",
+		Desc: `Lists the revisions of the environment variables of an app, the newest first.
+A revision is recorded by every env-set, env-unset and env-restore changing the
+variables set by users. Variables set by service instances aren't versioned.`,
+	}
+}
+
+func (c *envHistory) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/env/revisions")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var revisions []envRevision
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&revisions)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if revisions == nil {
+			revisions = []envRevision{}
+		}
+		return context.Render(revisions)
+	}
+	table := NewTable()
+	table.Headers = Row{"Version", "Date", "Kind", "User", "Changed", "Variables"}
+	for _, rev := range revisions {
+		kind := rev.Kind
+		if rev.RestoredFrom != 0 {
+			kind = fmt.Sprintf("%s of %d", kind, rev.RestoredFrom)
+		}
+		table.AddRow(Row{
+			strconv.Itoa(rev.Version),
+			rev.Timestamp.Local().Format(time.RFC822),
+			kind,
+			rev.User,
+			strings.Join(rev.Changed, ", "),
+			strconv.Itoa(len(rev.Envs)),
+		})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type envDiffCmd struct {
+	GuessingCommand
+	from int
+	to   int
+	fs   *gnuflag.FlagSet
+}
+
+func (c *envDiffCmd) Info() *Info {
+	return &Info{
+		Name:  "env-diff",
+		Usage: "env-diff [-a/--app appname] --from <version> [--to <version>]",
+		Desc: `Shows the changes in the environment variables of an app between two revisions,
+as listed by env-history. Without --to, the revision is compared to the current
+variables of the app. Values of private variables are never displayed.`,
+	}
+}
+
+func (c *envDiffCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.IntVar(&c.from, "from", 0, "The version of the revision to compare from.")
+		c.fs.IntVar(&c.to, "to", 0, "The version of the revision to compare to, defaults to the current variables.")
+	}
+	return c.fs
+}
+
+func (c *envDiffCmd) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.from <= 0 {
+		return errors.New("the version of the revision to compare from is required, use --from")
+	}
+	v := url.Values{}
+	v.Set("from", strconv.Itoa(c.from))
+	if c.to > 0 {
+		v.Set("to", strconv.Itoa(c.to))
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/env/diff?"+v.Encode())
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var diffs []envDiff
+	err = json.NewDecoder(resp.Body).Decode(&diffs)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(diffs)
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintln(context.Stdout, "No changes in environment variables.")
+		return nil
+	}
+	table := NewTable()
+	table.Headers = Row{"Name", "Change", "From", "To"}
+	for _, diff := range diffs {
+		table.AddRow(Row{diff.Name, diff.Change, diff.From.String(), diff.To.String()})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type envRestore struct {
+	GuessingCommand
+	ConfirmationCommand
+	noRestart bool
+	fs        *gnuflag.FlagSet
+}
+
+func (c *envRestore) Info() *Info {
+	return &Info{
+		Name:  "env-restore",
+		Usage: "env-restore [-a/--app appname] <version> [-y/--assume-yes] [--no-restart]",
+		Desc: `Restores the environment variables of an app set by users to the ones of a
+revision, as listed by env-history. The restore is recorded as a new revision,
+and the app is restarted unless --no-restart is used. Variables set by service
+instances are kept.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *envRestore) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = MergeFlagSet(c.GuessingCommand.Flags(), c.ConfirmationCommand.Flags())
+		c.fs.BoolVar(&c.noRestart, "no-restart", false, "Restore the variables without restarting the app.")
+	}
+	return c.fs
+}
+
+func (c *envRestore) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	version, err := strconv.Atoi(context.Args[0])
+	if err != nil || version <= 0 {
+		return errors.Errorf("invalid revision version %q", context.Args[0])
+	}
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to restore the environment variables of app %q to revision %d?", appName, version)) {
+		return nil
+	}
+	v := url.Values{}
+	v.Set("noRestart", strconv.FormatBool(c.noRestart))
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/env/revisions/%d/restore", appName, version))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return StreamJSONResponse(context.Stdout, resp)
+}
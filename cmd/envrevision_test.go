@@ -0,0 +1,161 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEnvHistoryRun(c *check.C) {
+	changedAt := time.Date(2017, 3, 1, 10, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Version": 3, "Timestamp": "2017-03-01T10:00:00Z", "Kind": "restore", "User": "me@tsuru.io",
+"Changed": ["A", "B"], "RestoredFrom": 1, "Envs": {"A": {"Name": "A", "Value": "1", "Public": true}}},
+{"Version": 2, "Timestamp": "2017-03-01T10:00:00Z", "Kind": "unset", "User": "me@tsuru.io", "Changed": ["A"], "Envs": {}},
+{"Version": 1, "Timestamp": "2017-03-01T10:00:00Z", "Kind": "initial", "Envs": {"A": {"Name": "A", "Value": "1", "Public": true}}}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/env/revisions"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := envHistory{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	date := changedAt.Local().Format(time.RFC822)
+	table := NewTable()
+	table.Headers = Row{"Version", "Date", "Kind", "User", "Changed", "Variables"}
+	table.AddRow(Row{"3", date, "restore of 1", "me@tsuru.io", "A, B", "1"})
+	table.AddRow(Row{"2", date, "unset", "me@tsuru.io", "A", "0"})
+	table.AddRow(Row{"1", date, "initial", "", "", "1"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestEnvHistoryRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := envHistory{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestEnvDiffRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Name": "A", "Change": "removed", "From": {"Name": "A", "Value": "1", "Public": true}},
+{"Name": "B", "Change": "changed", "From": {"Name": "B", "Value": "2", "Public": true}, "To": {"Name": "B", "Value": "20", "Public": true}},
+{"Name": "C", "Change": "added", "To": {"Name": "C", "Value": "*** (private variable)"}}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/env/diff" &&
+				req.URL.Query().Get("from") == "1" && req.URL.Query().Get("to") == "3"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := envDiffCmd{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "--from", "1", "--to", "3"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Name", "Change", "From", "To"}
+	table.AddRow(Row{"A", "removed", "1", ""})
+	table.AddRow(Row{"B", "changed", "2", "20"})
+	table.AddRow(Row{"C", "added", "", "*** (private variable)"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestEnvDiffRunNoChanges(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `[]`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			_, hasTo := req.URL.Query()["to"]
+			return req.URL.Path == "/1.3/apps/myapp/env/diff" && req.URL.Query().Get("from") == "2" && !hasTo
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := envDiffCmd{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "--from", "2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No changes in environment variables.\n")
+}
+
+func (s *S) TestEnvDiffRunWithoutFrom(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	command := envDiffCmd{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the version of the revision to compare from is required, use --from")
+}
+
+func (s *S) TestEnvRestoreRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"1"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Message":"---- Restoring environment variables of revision 1 ----\n"}
+{"Message":"Environment variables restored as revision 3.\n"}
+`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/myapp/env/revisions/1/restore" &&
+				req.FormValue("noRestart") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := envRestore{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-y", "--no-restart"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "---- Restoring environment variables of revision 1 ----\nEnvironment variables restored as revision 3.\n")
+}
+
+func (s *S) TestEnvRestoreRunAborted(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("n\n"), Args: []string{"2"}}
+	command := envRestore{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to restore the environment variables of app "myapp" to revision 2? (y/n) Abort.`+"\n")
+}
+
+func (s *S) TestEnvRestoreRunInvalidVersion(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"v1"}}
+	command := envRestore{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid revision version "v1"`)
+}
@@ -0,0 +1,623 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/yaml.v2"
+)
+
+type eventTarget struct {
+	Type  string
+	Value string
+}
+
+func (t eventTarget) String() string {
+	return strings.TrimSpace(t.Type + " " + t.Value)
+}
+
+type eventOwner struct {
+	Type string
+	Name string
+}
+
+type eventInfo struct {
+	UniqueID        string
+	StartTime       time.Time
+	EndTime         time.Time
+	Target          eventTarget
+	ExtraTargets    []eventTarget
+	StartCustomData bson.Raw
+	EndCustomData   bson.Raw
+	OtherCustomData bson.Raw
+	Kind            struct {
+		Type string
+		Name string
+	}
+	Owner        eventOwner
+	ActingOwner  eventOwner
+	ScheduledFor time.Time
+	Error        string
+	Log          string
+	CancelInfo   struct {
+		Owner    string
+		Reason   string
+		Asked    bool
+		Canceled bool
+	}
+	Cancelable bool
+	Running    bool
+	URL        string
+}
+
+func (e *eventInfo) status() string {
+	if e.Running {
+		return "running"
+	}
+	if e.CancelInfo.Canceled {
+		return ColorStatus("canceled", false)
+	}
+	return ColorStatus(fmt.Sprint(e.Error == ""), e.Error == "")
+}
+
+func (e *eventInfo) duration() string {
+	end := e.EndTime
+	if e.Running {
+		end = time.Now()
+	}
+	return end.Sub(e.StartTime).Truncate(time.Second).String()
+}
+
+// eventTargetFlag parses targets in the form <type>[=<value>].
+type eventTargetFlag eventTarget
+
+func (f *eventTargetFlag) String() string {
+	if f.Value == "" {
+		return f.Type
+	}
+	return f.Type + "=" + f.Value
+}
+
+func (f *eventTargetFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if parts[0] == "" {
+		return errors.New("target type is required")
+	}
+	f.Type = parts[0]
+	f.Value = ""
+	if len(parts) == 2 {
+		f.Value = parts[1]
+	}
+	return nil
+}
+
+func (f *eventTargetFlag) set(v url.Values) {
+	if f.Type != "" {
+		v.Set("target.type", f.Type)
+	}
+	if f.Value != "" {
+		v.Set("target.value", f.Value)
+	}
+}
+
+// parseEventSince accepts either a duration, counted back from now, or a
+// RFC 3339 date.
+func parseEventSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid since %q, use a duration, like 24h, or a date, like 2017-05-10T12:00:00Z", value)
+	}
+	return t, nil
+}
+
+type eventList struct {
+	fs         *gnuflag.FlagSet
+	kind       string
+	target     eventTargetFlag
+	since      string
+	errorsOnly bool
+	running    bool
+	limit      int
+	labels     StringSliceFlag
+}
+
+func (c *eventList) Info() *Info {
+	return &Info{
+		Name:  "event-list",
+		Usage: "event-list [-k/--kind <kind>] [-t/--target <type>[=<value>]] [-s/--since <duration|date>] [-e/--errors-only] [-r/--running] [-l/--limit <limit>] [--label <key>[=<value>]]...",
+		Desc: `Lists the events visible to the current user, the most recent first.
+
+The [[--target]] flag filters events by target type and, optionally, value,
+e.g.: [[--target app=myapp]]. The [[--since]] flag accepts either a duration,
+like [[--since 24h]], or a date, like [[--since 2017-05-10T12:00:00Z]].
+
+The [[--label]] flag, that may be used multiple times, filters events of apps
+and pools with the given labels, e.g.: [[--label cost-center=1234]]. Without
+a value, any app or pool with the label matches.`,
+	}
+}
+
+func (c *eventList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("event-list", gnuflag.ExitOnError)
+		kind := "Filter events by kind name"
+		c.fs.StringVar(&c.kind, "kind", "", kind)
+		c.fs.StringVar(&c.kind, "k", "", kind)
+		target := "Filter events by target, in the form <type>[=<value>]"
+		c.fs.Var(&c.target, "target", target)
+		c.fs.Var(&c.target, "t", target)
+		since := "Filter events started after the given duration or date"
+		c.fs.StringVar(&c.since, "since", "", since)
+		c.fs.StringVar(&c.since, "s", "", since)
+		errorsOnly := "Display only events finished with errors"
+		c.fs.BoolVar(&c.errorsOnly, "errors-only", false, errorsOnly)
+		c.fs.BoolVar(&c.errorsOnly, "e", false, errorsOnly)
+		running := "Display only running events"
+		c.fs.BoolVar(&c.running, "running", false, running)
+		c.fs.BoolVar(&c.running, "r", false, running)
+		limit := "Maximum number of events displayed"
+		c.fs.IntVar(&c.limit, "limit", 0, limit)
+		c.fs.IntVar(&c.limit, "l", 0, limit)
+		c.fs.Var(&c.labels, "label", "Filter events of apps and pools with the label, in the form <key>[=<value>]")
+	}
+	return c.fs
+}
+
+func (c *eventList) Run(context *Context, client *Client) error {
+	v := url.Values{}
+	if c.kind != "" {
+		v.Set("kindname", c.kind)
+	}
+	c.target.set(v)
+	if c.since != "" {
+		since, err := parseEventSince(c.since)
+		if err != nil {
+			return err
+		}
+		v.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	if c.errorsOnly {
+		v.Set("erroronly", "true")
+	}
+	if c.running {
+		v.Set("running", "true")
+	}
+	if c.limit > 0 {
+		v.Set("limit", fmt.Sprint(c.limit))
+	}
+	for _, l := range c.labels {
+		v.Add("label", l)
+	}
+	u, err := GetURLVersion("1.1", "/events?"+v.Encode())
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var events []eventInfo
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&events)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if events == nil {
+			events = []eventInfo{}
+		}
+		return context.Render(events)
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "Start (duration)", "Success", "Owner", "Kind", "Target"}
+	for i := range events {
+		e := &events[i]
+		start := fmt.Sprintf("%s (%s)", e.StartTime.Local().Format(time.RFC822), e.duration())
+		table.AddRow(Row{e.UniqueID, start, e.status(), e.Owner.Name, e.Kind.Name, e.Target.String()})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type eventInfoCmd struct {
+	fs   *gnuflag.FlagSet
+	open bool
+}
+
+func (c *eventInfoCmd) Info() *Info {
+	return &Info{
+		Name:  "event-info",
+		Usage: "event-info <id> [--open]",
+		Desc: `Displays the details of an event, including its custom data and log. With the
+[[--open]] flag, the page of the event in the dashboard is opened in the web
+browser instead, when the tsuru server has a dashboard URL configured.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *eventInfoCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("event-info", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.open, "open", false, "Open the page of the event in the web browser")
+	}
+	return c.fs
+}
+
+func (c *eventInfoCmd) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.1", "/events/"+url.PathEscape(context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var e eventInfo
+	err = json.NewDecoder(resp.Body).Decode(&e)
+	if err != nil {
+		return err
+	}
+	if c.open {
+		if e.URL == "" {
+			return errors.New("the tsuru server has no dashboard URL configured for events")
+		}
+		return open(e.URL)
+	}
+	if context.Structured() {
+		return context.Render(e)
+	}
+	fmt.Fprintf(context.Stdout, "ID:       %s\n", e.UniqueID)
+	fmt.Fprintf(context.Stdout, "Kind:     %s %s\n", e.Kind.Type, e.Kind.Name)
+	fmt.Fprintf(context.Stdout, "Target:   %s\n", e.Target)
+	for _, t := range e.ExtraTargets {
+		fmt.Fprintf(context.Stdout, "          %s\n", t)
+	}
+	owner := e.Owner.Type + " " + e.Owner.Name
+	if e.ActingOwner.Name != "" {
+		owner += fmt.Sprintf(" (acting as %s %s)", e.ActingOwner.Type, e.ActingOwner.Name)
+	}
+	fmt.Fprintf(context.Stdout, "Owner:    %s\n", owner)
+	if !e.ScheduledFor.IsZero() {
+		fmt.Fprintf(context.Stdout, "Scheduled for: %s\n", e.ScheduledFor.Local().Format(time.RFC822))
+	}
+	fmt.Fprintf(context.Stdout, "Start:    %s\n", e.StartTime.Local().Format(time.RFC822))
+	if !e.Running {
+		fmt.Fprintf(context.Stdout, "End:      %s\n", e.EndTime.Local().Format(time.RFC822))
+	}
+	fmt.Fprintf(context.Stdout, "Duration: %s\n", e.duration())
+	fmt.Fprintf(context.Stdout, "Success:  %s\n", e.status())
+	if e.Error != "" {
+		fmt.Fprintf(context.Stdout, "Error:    %q\n", e.Error)
+	}
+	if e.CancelInfo.Asked {
+		fmt.Fprintf(context.Stdout, "Cancel:   asked by %s, reason: %q\n", e.CancelInfo.Owner, e.CancelInfo.Reason)
+	}
+	if e.URL != "" {
+		fmt.Fprintf(context.Stdout, "URL:      %s\n", e.URL)
+	}
+	for _, data := range []struct {
+		title string
+		raw   bson.Raw
+	}{
+		{"Start Custom Data", e.StartCustomData},
+		{"End Custom Data", e.EndCustomData},
+		{"Other Custom Data", e.OtherCustomData},
+	} {
+		formatted, err := formatEventCustomData(data.raw)
+		if err != nil {
+			return err
+		}
+		if formatted != "" {
+			fmt.Fprintf(context.Stdout, "%s:\n%s", data.title, formatted)
+		}
+	}
+	if e.Log != "" {
+		fmt.Fprintf(context.Stdout, "Log:\n%s", indentLines(e.Log, "    "))
+	}
+	return nil
+}
+
+// formatEventCustomData renders the custom data stored in an event as
+// indented YAML. Values are converted to JSON first, so ids and dates are
+// displayed as they are in the API.
+func formatEventCustomData(raw bson.Raw) (string, error) {
+	if raw.Kind == 0 || raw.Kind == 0x0A {
+		return "", nil
+	}
+	var value interface{}
+	err := raw.Unmarshal(&value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	value = nil
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return "", err
+	}
+	data, err = yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return indentLines(string(data), "    "), nil
+}
+
+func indentLines(text, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
+
+type eventCancel struct {
+	ConfirmationCommand
+	fs     *gnuflag.FlagSet
+	reason string
+}
+
+func (c *eventCancel) Info() *Info {
+	return &Info{
+		Name:    "event-cancel",
+		Usage:   "event-cancel <id> -r/--reason <reason> [-y/--assume-yes]",
+		Desc:    "Asks a running event to be canceled. Only cancelable events, like deploys, can be canceled.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *eventCancel) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.ConfirmationCommand.Flags()
+		reason := "Reason for canceling the event"
+		c.fs.StringVar(&c.reason, "reason", "", reason)
+		c.fs.StringVar(&c.reason, "r", "", reason)
+	}
+	return c.fs
+}
+
+func (c *eventCancel) Run(context *Context, client *Client) error {
+	if c.reason == "" {
+		return errors.New("the reason is required to cancel an event, use the --reason flag")
+	}
+	id := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to cancel the event %q?", id)) {
+		return nil
+	}
+	u, err := GetURLVersion("1.1", "/events/"+url.PathEscape(id)+"/cancel")
+	if err != nil {
+		return err
+	}
+	v := url.Values{"reason": []string{c.reason}}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Cancellation of event %q successfully asked!\n", id)
+	return nil
+}
+
+type eventBlock struct {
+	ID         string
+	StartTime  time.Time
+	KindName   string
+	OwnerName  string
+	Target     eventTarget
+	Reason     string
+	Active     bool
+	ExpireTime time.Time
+	Schedule   string
+	Duration   time.Duration
+}
+
+type eventBlockList struct {
+	fs     *gnuflag.FlagSet
+	active bool
+}
+
+func (c *eventBlockList) Info() *Info {
+	return &Info{
+		Name:  "event-block-list",
+		Usage: "event-block-list [-a/--active]",
+		Desc:  "Lists the blocks preventing events from starting.",
+	}
+}
+
+func (c *eventBlockList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("event-block-list", gnuflag.ExitOnError)
+		active := "Display only active blocks"
+		c.fs.BoolVar(&c.active, "active", false, active)
+		c.fs.BoolVar(&c.active, "a", false, active)
+	}
+	return c.fs
+}
+
+func (c *eventBlockList) Run(context *Context, client *Client) error {
+	path := "/events/blocks"
+	if c.active {
+		path += "?active=true"
+	}
+	u, err := GetURLVersion("1.3", path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var blocks []eventBlock
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&blocks)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if blocks == nil {
+			blocks = []eventBlock{}
+		}
+		return context.Render(blocks)
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "Start", "Kind", "Owner", "Target", "Reason", "Active"}
+	for _, b := range blocks {
+		table.AddRow(Row{
+			b.ID,
+			b.StartTime.Local().Format(time.RFC822),
+			valueOrAll(b.KindName),
+			valueOrAll(b.OwnerName),
+			valueOrAll(b.Target.String()),
+			b.Reason,
+			fmt.Sprint(b.Active),
+		})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+func valueOrAll(value string) string {
+	if value == "" {
+		return "all"
+	}
+	return value
+}
+
+type eventBlockAdd struct {
+	fs       *gnuflag.FlagSet
+	kind     string
+	owner    string
+	target   eventTargetFlag
+	duration time.Duration
+}
+
+func (c *eventBlockAdd) Info() *Info {
+	return &Info{
+		Name:  "event-block-add",
+		Usage: "event-block-add <reason> [-k/--kind <kind>] [-o/--owner <owner>] [-t/--target <type>[=<value>]] [-d/--duration <duration>]",
+		Desc: `Blocks events from starting. Without filters, every event is blocked, the
+filters restrict the block to events with the given kind, owner or target.
+The block lasts until removed with event-block-remove or, when
+[[--duration]] is given, until the duration elapses.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *eventBlockAdd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("event-block-add", gnuflag.ExitOnError)
+		kind := "Block only events with the given kind name"
+		c.fs.StringVar(&c.kind, "kind", "", kind)
+		c.fs.StringVar(&c.kind, "k", "", kind)
+		owner := "Block only events started by the given owner"
+		c.fs.StringVar(&c.owner, "owner", "", owner)
+		c.fs.StringVar(&c.owner, "o", "", owner)
+		target := "Block only events with the given target, in the form <type>[=<value>]"
+		c.fs.Var(&c.target, "target", target)
+		c.fs.Var(&c.target, "t", target)
+		duration := "Duration of the block, e.g.: 2h"
+		c.fs.DurationVar(&c.duration, "duration", 0, duration)
+		c.fs.DurationVar(&c.duration, "d", 0, duration)
+	}
+	return c.fs
+}
+
+func (c *eventBlockAdd) Run(context *Context, client *Client) error {
+	v := url.Values{}
+	v.Set("reason", context.Args[0])
+	if c.kind != "" {
+		v.Set("kindname", c.kind)
+	}
+	if c.owner != "" {
+		v.Set("ownername", c.owner)
+	}
+	c.target.set(v)
+	if c.duration > 0 {
+		v.Set("duration", c.duration.String())
+	}
+	u, err := GetURLVersion("1.3", "/events/blocks")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintln(context.Stdout, "Block successfully added.")
+	return nil
+}
+
+type eventBlockRemove struct{}
+
+func (eventBlockRemove) Info() *Info {
+	return &Info{
+		Name:    "event-block-remove",
+		Usage:   "event-block-remove <id>",
+		Desc:    "Removes a block, as listed by event-block-list, allowing the blocked events to start again.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (eventBlockRemove) Run(context *Context, client *Client) error {
+	id := context.Args[0]
+	u, err := GetURLVersion("1.3", "/events/blocks/"+url.PathEscape(id))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Block %s successfully removed.\n", id)
+	return nil
+}
@@ -0,0 +1,309 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"github.com/tsuru/tsuru/exec/exectest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestEventListInfo(c *check.C) {
+	c.Assert((&eventList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestEventListRun(c *check.C) {
+	start := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"UniqueID": "591300000000000000000001", "StartTime": "2017-05-10T12:00:00Z", "EndTime": "2017-05-10T12:01:30Z",
+"Target": {"Type": "app", "Value": "myapp"}, "Kind": {"Type": "permission", "Name": "app.deploy"},
+"Owner": {"Type": "user", "Name": "me@tsuru.io"}, "Error": "deploy failed"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			q := req.URL.Query()
+			return req.Method == "GET" && req.URL.Path == "/1.1/events" &&
+				q.Get("kindname") == "app.deploy" &&
+				q.Get("target.type") == "app" &&
+				q.Get("target.value") == "myapp" &&
+				q.Get("since") == "2017-05-01T00:00:00Z" &&
+				q.Get("erroronly") == "true" &&
+				q.Get("running") == "" &&
+				q.Get("limit") == "10"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventList{}
+	err := command.Flags().Parse(true, []string{"--kind", "app.deploy", "-t", "app=myapp", "--since", "2017-05-01T00:00:00Z", "-e", "-l", "10"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"ID", "Start (duration)", "Success", "Owner", "Kind", "Target"}
+	table.AddRow(Row{"591300000000000000000001", start.Local().Format(time.RFC822) + " (1m30s)", ColorStatus("false", false), "me@tsuru.io", "app.deploy", "app myapp"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestEventListRunRunningSince(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	var since time.Time
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusNoContent},
+		CondFunc: func(req *http.Request) bool {
+			q := req.URL.Query()
+			var err error
+			since, err = time.Parse(time.RFC3339, q.Get("since"))
+			return err == nil && q.Get("running") == "true" && q.Get("target.type") == "node" && q.Get("target.value") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventList{}
+	err := command.Flags().Parse(true, []string{"--running", "--target", "node", "-s", "2h"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(time.Since(since) > 2*time.Hour-time.Minute, check.Equals, true)
+	c.Assert(time.Since(since) < 2*time.Hour+time.Minute, check.Equals, true)
+}
+
+func (s *S) TestEventListRunLabels(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusNoContent},
+		CondFunc: func(req *http.Request) bool {
+			labels := req.URL.Query()["label"]
+			return len(labels) == 2 && labels[0] == "cost-center=1234" && labels[1] == "product"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventList{}
+	err := command.Flags().Parse(true, []string{"--label", "cost-center=1234", "--label", "product"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestEventListRunInvalidSince(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	command := eventList{}
+	err := command.Flags().Parse(true, []string{"--since", "yesterday"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid since "yesterday".*`)
+}
+
+func (s *S) TestEventListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := eventList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestEventInfoRun(c *check.C) {
+	start := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	raw, err := bson.Marshal(bson.M{"d": []bson.M{{"name": "image", "value": "v1"}}})
+	c.Assert(err, check.IsNil)
+	var doc struct {
+		D bson.Raw
+	}
+	err = bson.Unmarshal(raw, &doc)
+	c.Assert(err, check.IsNil)
+	data, err := json.Marshal(map[string]interface{}{
+		"UniqueID":        "591300000000000000000001",
+		"StartTime":       start,
+		"EndTime":         start.Add(time.Minute),
+		"Target":          map[string]string{"Type": "app", "Value": "myapp"},
+		"Kind":            map[string]string{"Type": "permission", "Name": "app.deploy"},
+		"Owner":           map[string]string{"Type": "user", "Name": "me@tsuru.io"},
+		"StartCustomData": doc.D,
+		"Log":             "deploying\ndone\n",
+		"URL":             "https://tsuru.io/events/591300000000000000000001",
+	})
+	c.Assert(err, check.IsNil)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"591300000000000000000001"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(data), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.1/events/591300000000000000000001"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err = (&eventInfoCmd{}).Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `ID:       591300000000000000000001
+Kind:     permission app.deploy
+Target:   app myapp
+Owner:    user me@tsuru.io
+Start:    ` + start.Local().Format(time.RFC822) + `
+End:      ` + start.Add(time.Minute).Local().Format(time.RFC822) + `
+Duration: 1m0s
+Success:  ` + ColorStatus("true", true) + `
+URL:      https://tsuru.io/events/591300000000000000000001
+Start Custom Data:
+    - name: image
+      value: v1
+Log:
+    deploying
+    done
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestEventInfoRunOpen(c *check.C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("this test relies on xdg-open")
+	}
+	fexec := exectest.FakeExecutor{}
+	execut = &fexec
+	defer func() {
+		execut = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"591300000000000000000001"}}
+	transport := cmdtest.Transport{
+		Message: `{"UniqueID":"591300000000000000000001","URL":"https://tsuru.io/events/591300000000000000000001"}`,
+		Status:  http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventInfoCmd{}
+	err := command.Flags().Parse(true, []string{"--open"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(fexec.ExecutedCmd("xdg-open", []string{"https://tsuru.io/events/591300000000000000000001"}), check.Equals, true)
+	c.Assert(stdout.String(), check.Equals, "")
+}
+
+func (s *S) TestEventInfoRunOpenWithoutURL(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"591300000000000000000001"}}
+	transport := cmdtest.Transport{Message: `{"UniqueID":"591300000000000000000001"}`, Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventInfoCmd{}
+	err := command.Flags().Parse(true, []string{"--open"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "the tsuru server has no dashboard URL configured for events")
+}
+
+func (s *S) TestEventCancelRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"591300000000000000000001"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusNoContent},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.1/events/591300000000000000000001/cancel" &&
+				req.FormValue("reason") == "wrong image"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventCancel{}
+	err := command.Flags().Parse(true, []string{"-y", "--reason", "wrong image"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Cancellation of event \"591300000000000000000001\" successfully asked!\n")
+}
+
+func (s *S) TestEventCancelRunWithoutReason(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"591300000000000000000001"}}
+	command := eventCancel{}
+	err := command.Flags().Parse(true, []string{"-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the reason is required to cancel an event, use the --reason flag")
+}
+
+func (s *S) TestEventBlockListRun(c *check.C) {
+	start := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"ID": "591300000000000000000002", "StartTime": "2017-05-10T12:00:00Z", "KindName": "app.deploy",
+"Target": {"Type": "app", "Value": "myapp"}, "Reason": "maintenance", "Active": true}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/events/blocks" && req.URL.Query().Get("active") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventBlockList{}
+	err := command.Flags().Parse(true, []string{"--active"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"ID", "Start", "Kind", "Owner", "Target", "Reason", "Active"}
+	table.AddRow(Row{"591300000000000000000002", start.Local().Format(time.RFC822), "app.deploy", "all", "app myapp", "maintenance", "true"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestEventBlockAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"maintenance"}}
+	var body string
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			data, _ := ioutil.ReadAll(req.Body)
+			body = string(data)
+			return req.Method == "POST" && req.URL.Path == "/1.3/events/blocks" &&
+				req.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := eventBlockAdd{}
+	err := command.Flags().Parse(true, []string{"-k", "app.deploy", "--owner", "me@tsuru.io", "-t", "app=myapp", "-d", "2h"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Block successfully added.\n")
+	params := strings.Split(body, "&")
+	c.Assert(params, check.DeepEquals, []string{
+		"duration=2h0m0s",
+		"kindname=app.deploy",
+		"ownername=me%40tsuru.io",
+		"reason=maintenance",
+		"target.type=app",
+		"target.value=myapp",
+	})
+}
+
+func (s *S) TestEventBlockRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"591300000000000000000002"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/events/blocks/591300000000000000000002"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := eventBlockRemove{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Block 591300000000000000000002 successfully removed.\n")
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats supported by the --format flag.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatTSV   = "tsv"
+	// FormatGoTemplate is the prefix of template formats, the template
+	// follows the prefix, e.g.: go-template={{.Name}}.
+	FormatGoTemplate = "go-template="
+)
+
+const formatUsage = "Output format of list commands: table, json, yaml, tsv or go-template=<template>"
+
+func validateFormat(format string) error {
+	switch format {
+	case FormatTable, FormatJSON, FormatYAML, FormatTSV:
+		return nil
+	}
+	if strings.HasPrefix(format, FormatGoTemplate) {
+		_, err := parseFormatTemplate(format)
+		return err
+	}
+	return errors.Errorf("invalid output format %q, valid formats are: %s, %s, %s, %s, %s<template>\n", format, FormatTable, FormatJSON, FormatYAML, FormatTSV, FormatGoTemplate)
+}
+
+func parseFormatTemplate(format string) (*template.Template, error) {
+	tmpl, err := template.New("format").Option("missingkey=zero").Parse(strings.TrimPrefix(format, FormatGoTemplate))
+	if err != nil {
+		return nil, errors.Errorf("invalid output template: %s\n", err)
+	}
+	return tmpl, nil
+}
+
+// Structured reports whether commands should render their output with
+// Render, in a machine-readable format, instead of a table.
+func (c *Context) Structured() bool {
+	return c.Format != "" && c.Format != FormatTable
+}
+
+// Render writes data to the standard output in the format of the context.
+// Data is marshaled to JSON before being converted to the other formats, so
+// YAML keys, TSV columns and template fields have the names of the JSON
+// output.
+func (c *Context) Render(data interface{}) error {
+	c.RawOutput()
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	switch {
+	case c.Format == FormatYAML:
+		var value interface{}
+		err = yaml.Unmarshal(b, &value)
+		if err != nil {
+			return err
+		}
+		b, err = yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = c.Stdout.Write(b)
+		return err
+	case c.Format == FormatTSV:
+		return renderTSV(c.Stdout, b)
+	case strings.HasPrefix(c.Format, FormatGoTemplate):
+		return renderTemplate(c.Stdout, c.Format, b)
+	}
+	_, err = fmt.Fprintf(c.Stdout, "%s\n", b)
+	return err
+}
+
+// renderTemplate executes the template once for each item when data is a
+// list, or once for the whole data otherwise, ending each execution with a
+// new line.
+func renderTemplate(w io.Writer, format string, data []byte) error {
+	tmpl, err := parseFormatTemplate(format)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	for _, item := range items {
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, item)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		_, err = w.Write(buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderTSV writes one line for each item when data is a list, or a single
+// line otherwise, preceded by a header with the keys of the first item, in
+// the order of the JSON output. Nested values are written as compact JSON.
+func renderTSV(w io.Writer, data []byte) error {
+	rows, err := decodeOrderedRows(data)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	var keys []string
+	for _, field := range rows[0] {
+		keys = append(keys, field.key)
+	}
+	lines := []string{strings.Join(keys, "\t")}
+	for _, row := range rows {
+		values := make(map[string]string, len(row))
+		for _, field := range row {
+			values[field.key] = field.value
+		}
+		line := make([]string, len(keys))
+		for i, key := range keys {
+			line[i] = values[key]
+		}
+		lines = append(lines, strings.Join(line, "\t"))
+	}
+	_, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+type tsvField struct {
+	key   string
+	value string
+}
+
+func decodeOrderedRows(data []byte) ([][]tsvField, error) {
+	var items []json.RawMessage
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		err := json.Unmarshal(trimmed, &items)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		items = []json.RawMessage{trimmed}
+	}
+	rows := make([][]tsvField, 0, len(items))
+	for _, item := range items {
+		row, err := decodeOrderedFields(item)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeOrderedFields returns the fields of a JSON object keeping their order,
+// values other than objects are returned as a single field named value.
+func decodeOrderedFields(data json.RawMessage) ([]tsvField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return []tsvField{{key: "value", value: tsvValue(data)}}, nil
+	}
+	var fields []tsvField
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, tsvField{key: tok.(string), value: tsvValue(raw)})
+	}
+	return fields, nil
+}
+
+func tsvValue(raw json.RawMessage) string {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return strings.NewReplacer("\t", " ", "\n", " ").Replace(str)
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) == nil {
+		return buf.String()
+	}
+	return string(raw)
+}
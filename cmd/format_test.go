@@ -0,0 +1,72 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+
+	"gopkg.in/check.v1"
+)
+
+type formatTestItem struct {
+	Name  string            `json:"name"`
+	Units int               `json:"units"`
+	Tags  []string          `json:"tags"`
+	Env   map[string]string `json:"env,omitempty"`
+}
+
+var formatTestItems = []formatTestItem{
+	{Name: "app1", Units: 2, Tags: []string{"a", "b"}, Env: map[string]string{"K": "v"}},
+	{Name: "app 2", Units: 0},
+}
+
+func (s *S) TestRenderTSV(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout, Format: FormatTSV}
+	err := context.Render(formatTestItems)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "name\tunits\ttags\tenv\n"+
+		"app1\t2\t[\"a\",\"b\"]\t{\"K\":\"v\"}\n"+
+		"app 2\t0\t\t\n")
+}
+
+func (s *S) TestRenderTSVSingleValue(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout, Format: FormatTSV}
+	err := context.Render(formatTestItems[1])
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "name\tunits\ttags\napp 2\t0\t\n")
+	stdout.Reset()
+	err = context.Render([]formatTestItem{})
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "")
+}
+
+func (s *S) TestRenderGoTemplate(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout, Format: "go-template={{.name}} {{.units}}{{range .tags}} {{.}}{{end}}"}
+	err := context.Render(formatTestItems)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "app1 2 a b\napp 2 0\n")
+	stdout.Reset()
+	context.Format = "go-template={{.name}}:{{.missing}}\n"
+	err = context.Render(formatTestItems[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "app1:<no value>\n")
+}
+
+func (s *S) TestContextStructured(c *check.C) {
+	for format, structured := range map[string]bool{
+		"":                      false,
+		FormatTable:             false,
+		FormatJSON:              true,
+		FormatYAML:              true,
+		FormatTSV:               true,
+		"go-template={{.name}}": true,
+	} {
+		context := Context{Format: format}
+		c.Check(context.Structured(), check.Equals, structured, check.Commentf("format %q", format))
+	}
+}
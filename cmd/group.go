@@ -0,0 +1,257 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type apiGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	Roles   []struct {
+		Name    string `json:"name"`
+		Context string `json:"context"`
+	} `json:"roles"`
+}
+
+// doGroupRequest sends a request to the groups API, with the given form
+// values, if any.
+func doGroupRequest(client *Client, method, path string, values url.Values) error {
+	u, err := GetURLVersion("1.3", path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(method, u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	if values != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type groupCreate struct{}
+
+func (groupCreate) Info() *Info {
+	return &Info{
+		Name:  "group-create",
+		Usage: "group-create <group>",
+		Desc: `Creates a group of users. Groups are independent of teams: their members are
+granted the roles of the group without being added to any team, which suits
+machine users and guilds spanning many teams.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (groupCreate) Run(context *Context, client *Client) error {
+	group := context.Args[0]
+	err := doGroupRequest(client, "POST", "/groups", url.Values{"name": []string{group}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Group %q successfully created.\n", group)
+	return nil
+}
+
+type groupList struct{}
+
+func (groupList) Info() *Info {
+	return &Info{
+		Name:  "group-list",
+		Usage: "group-list",
+		Desc:  "Lists the groups the user has access to, along with their members and roles.",
+	}
+}
+
+func (groupList) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/groups")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var groups []apiGroup
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&groups)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if groups == nil {
+			groups = []apiGroup{}
+		}
+		return context.Render(groups)
+	}
+	table := NewTable()
+	table.Headers = Row{"Group", "Members", "Roles"}
+	for _, g := range groups {
+		roles := make([]string, len(g.Roles))
+		for i, r := range g.Roles {
+			roles[i] = r.Name
+			if r.Context != "" {
+				roles[i] += "(" + r.Context + ")"
+			}
+		}
+		table.AddRow(Row{g.Name, strings.Join(g.Members, "\n"), strings.Join(roles, "\n")})
+	}
+	table.LineSeparator = true
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type groupRemove struct {
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *groupRemove) Info() *Info {
+	return &Info{
+		Name:  "group-remove",
+		Usage: "group-remove <group> [-f/--force]",
+		Desc: `Removes a group. The roles of the group are no longer granted to its
+members.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *groupRemove) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = forceFlags("group-remove", &c.yes)
+	}
+	return c.fs
+}
+
+func (c *groupRemove) Run(context *Context, client *Client) error {
+	group := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to remove the group %q?", group)) {
+		return nil
+	}
+	err := doGroupRequest(client, "DELETE", "/groups/"+url.PathEscape(group), nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Group %q successfully removed.\n", group)
+	return nil
+}
+
+type groupMemberAdd struct{}
+
+func (groupMemberAdd) Info() *Info {
+	return &Info{
+		Name:    "group-member-add",
+		Usage:   "group-member-add <group> <email>",
+		Desc:    "Adds a user to a group, granting them the roles of the group.",
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (groupMemberAdd) Run(context *Context, client *Client) error {
+	group, email := context.Args[0], context.Args[1]
+	err := doGroupRequest(client, "PUT", "/groups/"+url.PathEscape(group)+"/members", url.Values{"email": []string{email}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "User %q added to group %q.\n", email, group)
+	return nil
+}
+
+type groupMemberRemove struct{}
+
+func (groupMemberRemove) Info() *Info {
+	return &Info{
+		Name:    "group-member-remove",
+		Usage:   "group-member-remove <group> <email>",
+		Desc:    "Removes a user from a group, revoking the roles of the group from them.",
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (groupMemberRemove) Run(context *Context, client *Client) error {
+	group, email := context.Args[0], context.Args[1]
+	err := doGroupRequest(client, "DELETE", "/groups/"+url.PathEscape(group)+"/members/"+url.PathEscape(email), nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "User %q removed from group %q.\n", email, group)
+	return nil
+}
+
+type groupRoleAdd struct{}
+
+func (groupRoleAdd) Info() *Info {
+	return &Info{
+		Name:  "group-role-add",
+		Usage: "group-role-add <group> <role> [context value]",
+		Desc: `Grants a role, in the given context value, to all members of a group. Only
+roles whose permissions the user has can be granted.`,
+		MinArgs: 2,
+		MaxArgs: 3,
+	}
+}
+
+func (groupRoleAdd) Run(context *Context, client *Client) error {
+	group, role := context.Args[0], context.Args[1]
+	values := url.Values{"role": []string{role}, "context": []string{""}}
+	if len(context.Args) > 2 {
+		values.Set("context", context.Args[2])
+	}
+	err := doGroupRequest(client, "POST", "/groups/"+url.PathEscape(group)+"/roles", values)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Role %q added to group %q.\n", role, group)
+	return nil
+}
+
+type groupRoleRemove struct{}
+
+func (groupRoleRemove) Info() *Info {
+	return &Info{
+		Name:    "group-role-remove",
+		Usage:   "group-role-remove <group> <role> [context value]",
+		Desc:    "Stops granting a role, in the given context value, to the members of a group.",
+		MinArgs: 2,
+		MaxArgs: 3,
+	}
+}
+
+func (groupRoleRemove) Run(context *Context, client *Client) error {
+	group, role := context.Args[0], context.Args[1]
+	path := "/groups/" + url.PathEscape(group) + "/roles/" + url.PathEscape(role)
+	if len(context.Args) > 2 {
+		path += "?" + url.Values{"context": []string{context.Args[2]}}.Encode()
+	}
+	err := doGroupRequest(client, "DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Role %q removed from group %q.\n", role, group)
+	return nil
+}
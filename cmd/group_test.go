@@ -0,0 +1,146 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGroupCreateRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"robots"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/groups" && req.Form.Get("name") == "robots"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupCreate{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Group \"robots\" successfully created.\n")
+}
+
+func (s *S) TestGroupListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"name": "robots", "members": ["ci@tsuru.io", "bot@tsuru.io"], "roles": [{"name": "deployer", "context": "myapp"}, {"name": "auditor", "context": ""}]}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/groups"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupList{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+--------+--------------+-----------------+
+| Group  | Members      | Roles           |
++--------+--------------+-----------------+
+| robots | ci@tsuru.io  | deployer(myapp) |
+|        | bot@tsuru.io | auditor         |
++--------+--------------+-----------------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestGroupListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupList{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestGroupRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"robots"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/groups/robots"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := groupRemove{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to remove the group "robots"? (y/n) Group "robots" successfully removed.`+"\n")
+}
+
+func (s *S) TestGroupMemberAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"robots", "ci@tsuru.io"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/groups/robots/members" &&
+				req.Form.Get("email") == "ci@tsuru.io"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupMemberAdd{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "User \"ci@tsuru.io\" added to group \"robots\".\n")
+}
+
+func (s *S) TestGroupMemberRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"robots", "ci@tsuru.io"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/groups/robots/members/ci@tsuru.io"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupMemberRemove{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "User \"ci@tsuru.io\" removed from group \"robots\".\n")
+}
+
+func (s *S) TestGroupRoleAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"robots", "deployer", "myapp"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/groups/robots/roles" &&
+				req.Form.Get("role") == "deployer" && req.Form.Get("context") == "myapp"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupRoleAdd{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Role \"deployer\" added to group \"robots\".\n")
+}
+
+func (s *S) TestGroupRoleRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"robots", "deployer", "myapp"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/groups/robots/roles/deployer" &&
+				req.URL.Query().Get("context") == "myapp"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := groupRoleRemove{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Role \"deployer\" removed from group \"robots\".\n")
+}
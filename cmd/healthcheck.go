@@ -0,0 +1,168 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type appHealthcheck struct {
+	Path             string
+	Interval         int
+	Timeout          int
+	FailureThreshold int    `json:"failure_threshold"`
+	RestartPolicy    string `json:"restart_policy"`
+}
+
+type appHealthcheckSet struct {
+	GuessingCommand
+	flags *gnuflag.FlagSet
+	hc    appHealthcheck
+}
+
+func (c *appHealthcheckSet) Info() *Info {
+	return &Info{
+		Name: "app-healthcheck-set",
+		Usage: `app-healthcheck-set [-a/--app appname] [--path <path>] [--interval <seconds>]
+[--timeout <seconds>] [--failure-threshold <number>] [--restart-policy <policy>]`,
+		Desc: `Sets the healthcheck of the units of the app, taking precedence over the
+healthcheck in the tsuru.yaml of the app, and the policy for restarting them,
+restarting the units of the app.
+
+The healthcheck is a GET request to the path, expecting the status 200, made
+every interval until the unit is healthy. The unit is considered unhealthy
+after failure-threshold consecutive failures, a request taking longer than the
+timeout fails. The restart policy is one of always (the default), on-failure
+and never.
+
+Running the command without flags removes the healthcheck and the restart
+policy of the app.`,
+	}
+}
+
+func (c *appHealthcheckSet) Flags() *gnuflag.FlagSet {
+	if c.flags == nil {
+		c.flags = c.GuessingCommand.Flags()
+		c.flags.StringVar(&c.hc.Path, "path", "", "The path of the healthcheck request.")
+		c.flags.IntVar(&c.hc.Interval, "interval", 0, "The interval between healthcheck requests, in seconds.")
+		c.flags.IntVar(&c.hc.Timeout, "timeout", 0, "The timeout of each healthcheck request, in seconds.")
+		c.flags.IntVar(&c.hc.FailureThreshold, "failure-threshold", 0, "The number of consecutive failures before the unit is considered unhealthy.")
+		c.flags.StringVar(&c.hc.RestartPolicy, "restart-policy", "", "The policy for restarting units: always, on-failure or never.")
+	}
+	return c.flags
+}
+
+func (c *appHealthcheckSet) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	if c.hc.Path != "" {
+		v.Set("path", c.hc.Path)
+	}
+	if c.hc.Interval != 0 {
+		v.Set("interval", strconv.Itoa(c.hc.Interval))
+	}
+	if c.hc.Timeout != 0 {
+		v.Set("timeout", strconv.Itoa(c.hc.Timeout))
+	}
+	if c.hc.FailureThreshold != 0 {
+		v.Set("failure-threshold", strconv.Itoa(c.hc.FailureThreshold))
+	}
+	if c.hc.RestartPolicy != "" {
+		v.Set("restart-policy", c.hc.RestartPolicy)
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/healthcheck")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return StreamJSONResponse(context.Stdout, resp)
+}
+
+type appHealthcheckShow struct {
+	GuessingCommand
+}
+
+func (c *appHealthcheckShow) Info() *Info {
+	return &Info{
+		Name:  "app-healthcheck-show",
+		Usage: "app-healthcheck-show [-a/--app appname]",
+		Desc:  "Shows the healthcheck and the restart policy set in the app.",
+	}
+}
+
+func (c *appHealthcheckShow) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/healthcheck")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var hc *appHealthcheck
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&hc)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		return context.Render(hc)
+	}
+	if hc == nil {
+		fmt.Fprintf(context.Stdout, "App %q has no healthcheck set, units use the healthcheck in its tsuru.yaml and are always restarted.\n", appName)
+		return nil
+	}
+	restartPolicy := hc.RestartPolicy
+	if restartPolicy == "" {
+		restartPolicy = "always"
+	}
+	table := NewTable()
+	table.Headers = Row{"Path", "Interval", "Timeout", "Failure Threshold", "Restart Policy"}
+	table.AddRow(Row{hc.Path, secondsOrDefault(hc.Interval), secondsOrDefault(hc.Timeout), intOrDefault(hc.FailureThreshold), restartPolicy})
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+func secondsOrDefault(seconds int) string {
+	if seconds == 0 {
+		return "default"
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+func intOrDefault(n int) string {
+	if n == 0 {
+		return "default"
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppHealthcheckSetInfo(c *check.C) {
+	c.Assert((&appHealthcheckSet{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppHealthcheckSetRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Message":"restarting app\n"}` + "\n",
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/healthcheck" &&
+				req.Form.Get("path") == "/status" && req.Form.Get("interval") == "10" &&
+				req.Form.Get("timeout") == "2" && req.Form.Get("failure-threshold") == "3" &&
+				req.Form.Get("restart-policy") == "on-failure"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appHealthcheckSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "--path", "/status", "--interval", "10",
+		"--timeout", "2", "--failure-threshold", "3", "--restart-policy", "on-failure"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "restarting app\n")
+}
+
+func (s *S) TestAppHealthcheckSetRunRemove(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/healthcheck" && len(req.Form) == 0
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appHealthcheckSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestAppHealthcheckShowInfo(c *check.C) {
+	c.Assert((&appHealthcheckShow{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppHealthcheckShowRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Path": "/status", "Interval": 10, "Timeout": 0, "failure_threshold": 3, "restart_policy": ""}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/healthcheck"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appHealthcheckShow{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Path", "Interval", "Timeout", "Failure Threshold", "Restart Policy"}
+	table.AddRow(Row{"/status", "10s", "default", "3", "always"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestAppHealthcheckShowRunNoHealthcheck(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := appHealthcheckShow{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `App "myapp" has no healthcheck set, units use the healthcheck in its tsuru.yaml and are always restarted.`+"\n")
+}
@@ -0,0 +1,287 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+type job struct {
+	Name      string
+	App       string
+	Schedule  string
+	Command   string
+	CreatedAt time.Time
+	CreatedBy string
+}
+
+type jobCreate struct {
+	GuessingCommand
+}
+
+func (c *jobCreate) Info() *Info {
+	return &Info{
+		Name:  "job-create",
+		Usage: "job-create [-a/--app appname] <name> <schedule> <command> [args...]",
+		Desc: `Creates a job in the app, running the command periodically in a new and
+ephemeral unit of the app. The schedule is a cron-like expression evaluated in
+UTC, with five fields: minute, hour, day of month, month and day of week, e.g.
+"0 3 * * *" runs the job every day at 03:00.
+
+Each run is recorded as an event, use job-log to see the output of the last
+runs.`,
+		MinArgs: 3,
+	}
+}
+
+func (c *jobCreate) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("name", context.Args[0])
+	v.Set("schedule", context.Args[1])
+	v.Set("command", strings.Join(context.Args[2:], " "))
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/jobs")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Job %q successfully created in app %q.\n", context.Args[0], appName)
+	return nil
+}
+
+type jobList struct {
+	GuessingCommand
+}
+
+func (c *jobList) Info() *Info {
+	return &Info{
+		Name:  "job-list",
+		Usage: "job-list [-a/--app appname]",
+		Desc:  "Lists the jobs of an app.",
+	}
+}
+
+func (c *jobList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/jobs")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var jobs []job
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&jobs)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if jobs == nil {
+			jobs = []job{}
+		}
+		return context.Render(jobs)
+	}
+	table := NewTable()
+	table.Headers = Row{"Name", "Schedule", "Command"}
+	for _, j := range jobs {
+		table.AddRow(Row{j.Name, j.Schedule, j.Command})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type jobRemove struct {
+	GuessingCommand
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *jobRemove) Info() *Info {
+	return &Info{
+		Name:    "job-remove",
+		Usage:   "job-remove [-a/--app appname] <name> [-y/--assume-yes]",
+		Desc:    "Removes a job from the app, canceling its next run.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *jobRemove) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = MergeFlagSet(c.GuessingCommand.Flags(), c.ConfirmationCommand.Flags())
+	}
+	return c.fs
+}
+
+func (c *jobRemove) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	name := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to remove the job %q from app %q?", name, appName)) {
+		return nil
+	}
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/jobs/%s", appName, name))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Job %q successfully removed from app %q.\n", name, appName)
+	return nil
+}
+
+type jobTrigger struct {
+	GuessingCommand
+}
+
+func (c *jobTrigger) Info() *Info {
+	return &Info{
+		Name:  "job-trigger",
+		Usage: "job-trigger [-a/--app appname] <name>",
+		Desc: `Runs a job of the app now, regardless of its schedule, showing its output. The
+run is recorded as an event, like scheduled runs.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *jobTrigger) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/jobs/%s/trigger", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return StreamJSONResponse(context.Stdout, resp)
+}
+
+type jobLog struct {
+	GuessingCommand
+	runs int
+	fs   *gnuflag.FlagSet
+}
+
+func (c *jobLog) Info() *Info {
+	return &Info{
+		Name:  "job-log",
+		Usage: "job-log [-a/--app appname] <name> [-n/--runs <number>]",
+		Desc: `Shows the output of the last runs of a job of the app, the most recent last.
+The output of a run is available once it finishes.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *jobLog) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.IntVar(&c.runs, "runs", 1, "The number of runs to show.")
+		c.fs.IntVar(&c.runs, "n", 1, "The number of runs to show.")
+	}
+	return c.fs
+}
+
+func (c *jobLog) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.runs <= 0 {
+		return errors.New("the number of runs must be a positive integer")
+	}
+	v := url.Values{}
+	v.Set("limit", strconv.Itoa(c.runs))
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/jobs/%s/runs?%s", appName, context.Args[0], v.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var runs []eventInfo
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&runs)
+		if err != nil {
+			return err
+		}
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(context.Stdout, "Job %q has not run yet.\n", context.Args[0])
+		return nil
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		status := ColorStatus("succeeded", true)
+		if run.Running {
+			status = "running"
+		} else if run.Error != "" {
+			status = ColorStatus("failed", false)
+		}
+		fmt.Fprintf(context.Stdout, "---- Run %s at %s by %s (%s, %s) ----\n", run.UniqueID,
+			run.StartTime.Local().Format(time.RFC822), run.Owner.Name, status, run.duration())
+		fmt.Fprint(context.Stdout, run.Log)
+		if run.Error != "" {
+			fmt.Fprintf(context.Stdout, "Error: %s\n", run.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestJobCreateInfo(c *check.C) {
+	c.Assert((&jobCreate{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobCreateRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"cleanup", "0 3 * * *", "./cleanup.sh", "--all"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/myapp/jobs" &&
+				req.Form.Get("name") == "cleanup" && req.Form.Get("schedule") == "0 3 * * *" &&
+				req.Form.Get("command") == "./cleanup.sh --all"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := jobCreate{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Job "cleanup" successfully created in app "myapp".`+"\n")
+}
+
+func (s *S) TestJobListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Name": "cleanup", "App": "myapp", "Schedule": "0 3 * * *", "Command": "./cleanup.sh"},
+{"Name": "report", "App": "myapp", "Schedule": "*/15 * * * *", "Command": "./report.sh"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/jobs"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := jobList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Name", "Schedule", "Command"}
+	table.AddRow(Row{"cleanup", "0 3 * * *", "./cleanup.sh"})
+	table.AddRow(Row{"report", "*/15 * * * *", "./report.sh"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestJobListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := jobList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestJobRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"cleanup"}, Stdin: strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/apps/myapp/jobs/cleanup"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := jobRemove{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to remove the job "cleanup" from app "myapp"? (y/n) `+
+		`Job "cleanup" successfully removed from app "myapp".`+"\n")
+}
+
+func (s *S) TestJobTriggerRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"cleanup"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Message":"cleaned up\n"}` + "\n",
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/myapp/jobs/cleanup/trigger"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := jobTrigger{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "cleaned up\n")
+}
+
+func (s *S) TestJobLogRun(c *check.C) {
+	start := time.Date(2017, 5, 10, 3, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"cleanup"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"UniqueID": "59128a000000000000000002", "StartTime": "2017-05-10T03:00:00Z", "EndTime": "2017-05-10T03:00:05Z",
+"Owner": {"Type": "user", "Name": "me@tsuru.io"}, "Error": "exit status 1", "Log": "failed to clean up\n"},
+{"UniqueID": "59128a000000000000000001", "StartTime": "2017-05-10T03:00:00Z", "EndTime": "2017-05-10T03:00:02Z",
+"Owner": {"Type": "user", "Name": "me@tsuru.io"}, "Log": "cleaned up\n"}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/jobs/cleanup/runs" &&
+				req.URL.Query().Get("limit") == "2"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := jobLog{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-n", "2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	startStr := start.Local().Format(time.RFC822)
+	expected := "---- Run 59128a000000000000000001 at " + startStr + " by me@tsuru.io (" + ColorStatus("succeeded", true) + ", 2s) ----\n" +
+		"cleaned up\n" +
+		"---- Run 59128a000000000000000002 at " + startStr + " by me@tsuru.io (" + ColorStatus("failed", false) + ", 5s) ----\n" +
+		"failed to clean up\n" +
+		"Error: exit status 1\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestJobLogRunNoRuns(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"cleanup"}}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	command := jobLog{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Job "cleanup" has not run yet.`+"\n")
+}
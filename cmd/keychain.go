@@ -0,0 +1,73 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const keychainService = "tsuru"
+
+// cliConfig holds the preferences of the user stored in ~/.tsuru/config.json.
+type cliConfig struct {
+	TokenStorage string `json:"token-storage"`
+}
+
+func cliConfigPath() string {
+	return JoinWithUserDir(".tsuru", "config.json")
+}
+
+func readCLIConfig() (cliConfig, error) {
+	var conf cliConfig
+	f, err := filesystem().Open(cliConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return conf, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return conf, err
+	}
+	err = json.Unmarshal(data, &conf)
+	return conf, err
+}
+
+// keychainEnabled reports whether the user asked for the token to be stored
+// in the OS keychain, through the TSURU_TOKEN_STORAGE environment variable
+// or the token-storage entry of ~/.tsuru/config.json, the environment
+// variable taking precedence. The plaintext token file is used otherwise.
+func keychainEnabled() bool {
+	if storage := os.Getenv("TSURU_TOKEN_STORAGE"); storage != "" {
+		return storage == "keychain"
+	}
+	conf, err := readCLIConfig()
+	return err == nil && conf.TokenStorage == "keychain"
+}
+
+// keychainAccount returns the account name used to store the token in the
+// keychain. Tokens are stored per target, so switching between targets keeps
+// each session.
+func keychainAccount() string {
+	target, err := ReadTarget()
+	if err != nil || target == "" {
+		return "default"
+	}
+	return target
+}
+
+// migrateTokenToKeychain moves a token found in the token file to the
+// keychain, removing the file afterwards. Failures are ignored, the token
+// file keeps being used in this case.
+func migrateTokenToKeychain(token string) {
+	if keychainSet(keychainAccount(), token) == nil {
+		path, _ := tokenPath()
+		filesystem().Remove(path)
+	}
+}
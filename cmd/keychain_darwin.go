@@ -0,0 +1,56 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/tsuru/tsuru/exec"
+)
+
+func keychainGet(account string) (string, error) {
+	var stdout bytes.Buffer
+	opts := exec.ExecuteOptions{
+		Cmd:    "security",
+		Args:   []string{"find-generic-password", "-s", keychainService, "-a", account, "-w"},
+		Stdout: &stdout,
+	}
+	err := executor().Execute(opts)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// keychainSet stores the secret running the security tool in interactive
+// mode, with the command read from stdin, so the secret never shows up in
+// the arguments of a process.
+func keychainSet(account, secret string) error {
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		securityQuote(keychainService), securityQuote(account), securityQuote(secret))
+	opts := exec.ExecuteOptions{
+		Cmd:   "security",
+		Args:  []string{"-i"},
+		Stdin: strings.NewReader(cmd),
+	}
+	return executor().Execute(opts)
+}
+
+// securityQuote quotes an argument of a command run in the interactive mode
+// of the security tool.
+func securityQuote(arg string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+func keychainRemove(account string) error {
+	opts := exec.ExecuteOptions{
+		Cmd:  "security",
+		Args: []string{"delete-generic-password", "-s", keychainService, "-a", account},
+	}
+	return executor().Execute(opts)
+}
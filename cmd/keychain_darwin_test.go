@@ -0,0 +1,24 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/tsuru/tsuru/exec/exectest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestKeychainSetDarwinKeepsSecretOutOfArgs(c *check.C) {
+	fexec := exectest.FakeExecutor{}
+	execut = &fexec
+	defer func() { execut = nil }()
+	err := keychainSet("http://tsuru.example.com", `my"token`)
+	c.Assert(err, check.IsNil)
+	c.Assert(fexec.ExecutedCmd("security", []string{"-i"}), check.Equals, true)
+}
+
+func (s *S) TestSecurityQuote(c *check.C) {
+	c.Assert(securityQuote("mytoken"), check.Equals, `"mytoken"`)
+	c.Assert(securityQuote(`my"to\ken`), check.Equals, `"my\"to\\ken"`)
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/tsuru/tsuru/exec/exectest"
+	"github.com/tsuru/tsuru/fs/fstest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) setupKeychain(c *check.C, fexec *exectest.FakeExecutor) func() {
+	if runtime.GOOS != "linux" {
+		c.Skip("keychain tests rely on secret-tool")
+	}
+	os.Unsetenv("TSURU_TOKEN")
+	os.Setenv("TSURU_TARGET", "http://tsuru.example.com")
+	os.Setenv("TSURU_TOKEN_STORAGE", "keychain")
+	execut = fexec
+	return func() {
+		execut = nil
+		fsystem = nil
+		os.Unsetenv("TSURU_TOKEN_STORAGE")
+	}
+}
+
+func (s *S) TestWriteTokenKeychain(c *check.C) {
+	fexec := exectest.FakeExecutor{}
+	defer s.setupKeychain(c, &fexec)()
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	err := writeToken("mytoken")
+	c.Assert(err, check.IsNil)
+	args := []string{"store", "--label", "tsuru token (http://tsuru.example.com)", "service", "tsuru", "account", "http://tsuru.example.com"}
+	c.Assert(fexec.ExecutedCmd("secret-tool", args), check.Equals, true)
+	c.Assert(rfs.HasAction("create "+JoinWithUserDir(".tsuru", "token")), check.Equals, false)
+}
+
+func (s *S) TestWriteTokenKeychainFallback(c *check.C) {
+	fexec := exectest.ErrorExecutor{}
+	defer s.setupKeychain(c, &fexec.FakeExecutor)()
+	execut = &fexec
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	err := writeToken("mytoken")
+	c.Assert(err, check.IsNil)
+	file, err := rfs.Open(JoinWithUserDir(".tsuru", "token"))
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(file)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "mytoken")
+}
+
+func (s *S) TestReadTokenKeychain(c *check.C) {
+	fexec := exectest.FakeExecutor{
+		Output: map[string][][]byte{
+			"lookup service tsuru account http://tsuru.example.com": {[]byte("mytoken\n")},
+		},
+	}
+	defer s.setupKeychain(c, &fexec)()
+	fsystem = &fstest.RecordingFs{}
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "mytoken")
+}
+
+func (s *S) TestReadTokenKeychainMigratesTokenFile(c *check.C) {
+	fexec := exectest.FakeExecutor{}
+	defer s.setupKeychain(c, &fexec)()
+	rfs := &fstest.RecordingFs{FileContent: "filetoken"}
+	fsystem = rfs
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "filetoken")
+	args := []string{"store", "--label", "tsuru token (http://tsuru.example.com)", "service", "tsuru", "account", "http://tsuru.example.com"}
+	c.Assert(fexec.ExecutedCmd("secret-tool", args), check.Equals, true)
+	c.Assert(rfs.HasAction("remove "+JoinWithUserDir(".tsuru", "token")), check.Equals, true)
+}
+
+func (s *S) TestRemoveTokenKeychain(c *check.C) {
+	fexec := exectest.FakeExecutor{}
+	defer s.setupKeychain(c, &fexec)()
+	fsystem = &fstest.FileNotFoundFs{}
+	err := removeToken()
+	c.Assert(err, check.IsNil)
+	args := []string{"clear", "service", "tsuru", "account", "http://tsuru.example.com"}
+	c.Assert(fexec.ExecutedCmd("secret-tool", args), check.Equals, true)
+}
+
+func (s *S) TestKeychainEnabledFromConfig(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN_STORAGE")
+	fsystem = &fstest.RecordingFs{FileContent: `{"token-storage": "keychain"}`}
+	defer func() { fsystem = nil }()
+	c.Assert(keychainEnabled(), check.Equals, true)
+	os.Setenv("TSURU_TOKEN_STORAGE", "file")
+	defer os.Unsetenv("TSURU_TOKEN_STORAGE")
+	c.Assert(keychainEnabled(), check.Equals, false)
+}
+
+func (s *S) TestKeychainEnabledWithoutConfig(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN_STORAGE")
+	fsystem = &fstest.FileNotFoundFs{}
+	defer func() { fsystem = nil }()
+	c.Assert(keychainEnabled(), check.Equals, false)
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !windows,!darwin
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/tsuru/tsuru/exec"
+)
+
+func keychainGet(account string) (string, error) {
+	var stdout bytes.Buffer
+	opts := exec.ExecuteOptions{
+		Cmd:    "secret-tool",
+		Args:   []string{"lookup", "service", keychainService, "account", account},
+		Stdout: &stdout,
+	}
+	err := executor().Execute(opts)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func keychainSet(account, secret string) error {
+	opts := exec.ExecuteOptions{
+		Cmd:   "secret-tool",
+		Args:  []string{"store", "--label", "tsuru token (" + account + ")", "service", keychainService, "account", account},
+		Stdin: strings.NewReader(secret),
+	}
+	return executor().Execute(opts)
+}
+
+func keychainRemove(account string) error {
+	opts := exec.ExecuteOptions{
+		Cmd:  "secret-tool",
+		Args: []string{"clear", "service", keychainService, "account", account},
+	}
+	return executor().Execute(opts)
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type logDrain struct {
+	Name string
+	URL  string
+	App  string
+	Pool string
+}
+
+// logDrainTarget holds the flags shared by the log drain commands, that act
+// on the drains of the pool informed with --pool or else of the app.
+type logDrainTarget struct {
+	GuessingCommand
+	fs   *gnuflag.FlagSet
+	pool string
+}
+
+func (c *logDrainTarget) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.StringVar(&c.pool, "pool", "", "The name of the pool, used instead of the app.")
+		c.fs.StringVar(&c.pool, "p", "", "The name of the pool, used instead of the app.")
+	}
+	return c.fs
+}
+
+func (c *logDrainTarget) target() (path string, desc string, err error) {
+	if c.pool != "" {
+		return "/pools/" + c.pool + "/log-drains", fmt.Sprintf("pool %q", c.pool), nil
+	}
+	appName, err := c.Guess()
+	if err != nil {
+		return "", "", err
+	}
+	return "/apps/" + appName + "/log-drains", fmt.Sprintf("app %q", appName), nil
+}
+
+const logDrainTargetDesc = `The drains of the pool are used with the --pool flag, otherwise the drains of
+the app.`
+
+type logDrainAdd struct {
+	logDrainTarget
+}
+
+func (c *logDrainAdd) Info() *Info {
+	return &Info{
+		Name:  "log-drain-add",
+		Usage: "log-drain-add <name> <url> [-a/--app appname] [-p/--pool poolname]",
+		Desc: `Adds a log drain, an external destination that receives the logs of an app,
+or of all apps in a pool, even when tsuru doesn't store them. The url defines
+the protocol, and must be one of:
+
+  syslog+tcp://host:port  RFC 5424 syslog messages over TCP
+  syslog+udp://host:port  RFC 5424 syslog messages over UDP
+  fluentd://host:port     Fluentd forward protocol, tagged as tsuru.<appname>
+
+` + logDrainTargetDesc,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *logDrainAdd) Run(context *Context, client *Client) error {
+	path, desc, err := c.target()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", path)
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("name", context.Args[0])
+	v.Set("url", context.Args[1])
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Log drain %q successfully added to %s.\n", context.Args[0], desc)
+	return nil
+}
+
+type logDrainList struct {
+	logDrainTarget
+}
+
+func (c *logDrainList) Info() *Info {
+	return &Info{
+		Name:    "log-drain-list",
+		Usage:   "log-drain-list [-a/--app appname] [-p/--pool poolname]",
+		Desc:    "Lists the log drains.\n\n" + logDrainTargetDesc,
+		MinArgs: 0,
+		MaxArgs: 0,
+	}
+}
+
+func (c *logDrainList) Run(context *Context, client *Client) error {
+	path, _, err := c.target()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var drains []logDrain
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&drains)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		return context.Render(drains)
+	}
+	table := NewTable()
+	table.Headers = Row{"Name", "URL"}
+	for _, d := range drains {
+		table.AddRow(Row{d.Name, d.URL})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type logDrainRemove struct {
+	logDrainTarget
+}
+
+func (c *logDrainRemove) Info() *Info {
+	return &Info{
+		Name:    "log-drain-remove",
+		Usage:   "log-drain-remove <name> [-a/--app appname] [-p/--pool poolname]",
+		Desc:    "Removes a log drain, logs are no longer sent to it.\n\n" + logDrainTargetDesc,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *logDrainRemove) Run(context *Context, client *Client) error {
+	path, desc, err := c.target()
+	if err != nil {
+		return err
+	}
+	u, err := GetURLVersion("1.3", path+"/"+context.Args[0])
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Log drain %q successfully removed from %s.\n", context.Args[0], desc)
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestLogDrainAddInfo(c *check.C) {
+	c.Assert((&logDrainAdd{}).Info(), check.NotNil)
+}
+
+func (s *S) TestLogDrainAddRunApp(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"central", "syslog+tcp://logs:514"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/apps/myapp/log-drains" &&
+				req.Form.Get("name") == "central" && req.Form.Get("url") == "syslog+tcp://logs:514"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := logDrainAdd{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Log drain "central" successfully added to app "myapp".`+"\n")
+}
+
+func (s *S) TestLogDrainAddRunPool(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"central", "fluentd://fluentd:24224"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/pools/mypool/log-drains" &&
+				req.Form.Get("name") == "central" && req.Form.Get("url") == "fluentd://fluentd:24224"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := logDrainAdd{}
+	err := command.Flags().Parse(true, []string{"--pool", "mypool"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Log drain "central" successfully added to pool "mypool".`+"\n")
+}
+
+func (s *S) TestLogDrainListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Name":"central","URL":"syslog+tcp://logs:514","Pool":"mypool"},{"Name":"fluent","URL":"fluentd://fluentd:24224","Pool":"mypool"}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/pools/mypool/log-drains"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := logDrainList{}
+	err := command.Flags().Parse(true, []string{"-p", "mypool"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Name", "URL"}
+	table.AddRow(Row{"central", "syslog+tcp://logs:514"})
+	table.AddRow(Row{"fluent", "fluentd://fluentd:24224"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestLogDrainListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusNoContent},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/apps/myapp/log-drains"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := logDrainList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Name", "URL"}
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestLogDrainRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"central"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/apps/myapp/log-drains/central"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := logDrainRemove{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Log drain "central" successfully removed from app "myapp".`+"\n")
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const motdCheckInterval = 24 * time.Hour
+
+type motdMessage struct {
+	Text     string
+	Severity string
+}
+
+// showMotd displays the messages of the day announced by the operators of
+// the target, fetching them at most once per motdCheckInterval. Any failure
+// is ignored, the messages are never worth interrupting the command for.
+// Setting the TSURU_DISABLE_MOTD environment variable disables it.
+func (m *Manager) showMotd(client *Client) {
+	if os.Getenv("TSURU_DISABLE_MOTD") != "" {
+		return
+	}
+	if token, err := ReadToken(); err != nil || token == "" {
+		return
+	}
+	checkPath := JoinWithUserDir(".tsuru", "motd-checked")
+	if lastCheck, err := readMotdCheck(checkPath); err == nil && time.Since(lastCheck) < motdCheckInterval {
+		return
+	}
+	url, err := GetURLVersion("1.3", "/motd")
+	if err != nil {
+		return
+	}
+	writeMotdCheck(checkPath, time.Now())
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return
+	}
+	var msgs []motdMessage
+	err = json.NewDecoder(response.Body).Decode(&msgs)
+	if err != nil || len(msgs) == 0 {
+		return
+	}
+	for _, msg := range msgs {
+		fmt.Fprintf(m.stderr, "[%s] %s\n", strings.ToUpper(msg.Severity), msg.Text)
+	}
+	fmt.Fprintln(m.stderr)
+}
+
+func readMotdCheck(path string) (time.Time, error) {
+	f, err := filesystem().Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+}
+
+func writeMotdCheck(path string, t time.Time) {
+	err := filesystem().MkdirAll(JoinWithUserDir(".tsuru"), 0700)
+	if err != nil {
+		return
+	}
+	f, err := filesystem().OpenFile(path, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	io.WriteString(f, t.Format(time.RFC3339))
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"github.com/tsuru/tsuru/fs/fstest"
+	"gopkg.in/check.v1"
+)
+
+func motdTransport() *cmdtest.ConditionalTransport {
+	return &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Text": "maintenance at 10pm", "Severity": "warning"}, {"Text": "welcome", "Severity": "info"}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/motd"
+		},
+	}
+}
+
+func (s *S) TestShowMotd(c *check.C) {
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	manager := NewManager("glb", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	client := NewClient(&http.Client{Transport: motdTransport()}, nil, manager)
+	manager.showMotd(client)
+	c.Assert(stderr.String(), check.Equals, "[WARNING] maintenance at 10pm\n[INFO] welcome\n\n")
+	c.Assert(stdout.String(), check.Equals, "")
+	checkPath := JoinWithUserDir(".tsuru", "motd-checked")
+	c.Assert(rfs.HasAction("openfile "+checkPath+" with mode 0600"), check.Equals, true)
+	lastCheck, err := readMotdCheck(checkPath)
+	c.Assert(err, check.IsNil)
+	c.Assert(time.Since(lastCheck) < time.Minute, check.Equals, true)
+	stderr.Reset()
+	manager.showMotd(client)
+	c.Assert(stderr.String(), check.Equals, "")
+}
+
+func (s *S) TestShowMotdCheckExpired(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	checkPath := JoinWithUserDir(".tsuru", "motd-checked")
+	writeMotdCheck(checkPath, time.Now().Add(-25*time.Hour))
+	var stdout, stderr bytes.Buffer
+	manager := NewManager("glb", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	client := NewClient(&http.Client{Transport: motdTransport()}, nil, manager)
+	manager.showMotd(client)
+	c.Assert(stderr.String(), check.Equals, "[WARNING] maintenance at 10pm\n[INFO] welcome\n\n")
+}
+
+func (s *S) TestShowMotdDisabled(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	os.Setenv("TSURU_DISABLE_MOTD", "1")
+	defer os.Unsetenv("TSURU_DISABLE_MOTD")
+	var stdout, stderr bytes.Buffer
+	manager := NewManager("glb", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	client := NewClient(&http.Client{Transport: motdTransport()}, nil, manager)
+	manager.showMotd(client)
+	c.Assert(stderr.String(), check.Equals, "")
+}
+
+func (s *S) TestShowMotdIgnoresErrors(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	manager := NewManager("glb", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	transport := cmdtest.Transport{Message: "not found", Status: http.StatusNotFound}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	manager.showMotd(client)
+	c.Assert(stderr.String(), check.Equals, "")
+	c.Assert(stdout.String(), check.Equals, "")
+}
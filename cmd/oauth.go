@@ -7,6 +7,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -79,55 +80,88 @@ func convertToken(code, redirectUrl string) (string, error) {
 	if err != nil {
 		return token, errors.Wrap(err, "Error reading body")
 	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return token, errors.Errorf("Error exchanging the authorization code: %s", strings.TrimSpace(string(result)))
+	}
 	data := make(map[string]interface{})
 	err = json.Unmarshal(result, &data)
 	if err != nil {
 		return token, errors.Wrapf(err, "Error parsing response: %s", result)
 	}
-	return data["token"].(string), nil
+	token, _ = data["token"].(string)
+	if token == "" {
+		return token, errors.Errorf("Error parsing response: no token in %s", result)
+	}
+	return token, nil
 }
 
-func callback(redirectUrl string, finish chan bool) http.HandlerFunc {
+// callback handles the redirect of the authorization server, exchanging the
+// code for a tsuru token. Requests without a code or an error, like the
+// ones for the favicon made by browsers, are ignored.
+func callback(redirectUrl string, finish chan error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			finish <- true
-		}()
-		var page string
-		token, err := convertToken(r.URL.Query().Get("code"), redirectUrl)
-		if err == nil {
-			writeToken(token)
-			page = fmt.Sprintf(callbackPage, successMarkup)
+		query := r.URL.Query()
+		code := query.Get("code")
+		oauthErr := query.Get("error")
+		if code == "" && oauthErr == "" {
+			http.NotFound(w, r)
+			return
+		}
+		var err error
+		if oauthErr != "" {
+			err = errors.Errorf("authorization denied: %s", oauthErr)
+			if desc := query.Get("error_description"); desc != "" {
+				err = errors.Errorf("authorization denied: %s: %s", oauthErr, desc)
+			}
 		} else {
-			msg := fmt.Sprintf(errorMarkup, err.Error())
-			page = fmt.Sprintf(callbackPage, msg)
+			var token string
+			token, err = convertToken(code, redirectUrl)
+			if err == nil {
+				err = writeToken(token)
+			}
+		}
+		page := fmt.Sprintf(callbackPage, successMarkup)
+		if err != nil {
+			page = fmt.Sprintf(callbackPage, fmt.Sprintf(errorMarkup, html.EscapeString(err.Error())))
 		}
 		w.Header().Add("Content-Type", "text/html")
 		w.Write([]byte(page))
+		select {
+		case finish <- err:
+		default:
+		}
 	}
 }
 
 func (c *login) oauthLogin(context *Context, client *Client) error {
 	schemeData := c.getScheme().Data
-	finish := make(chan bool)
+	finish := make(chan error, 1)
 	l, err := net.Listen("tcp", port(schemeData))
 	if err != nil {
 		return err
 	}
+	defer l.Close()
 	_, port, err := net.SplitHostPort(l.Addr().String())
 	if err != nil {
 		return err
 	}
 	redirectUrl := fmt.Sprintf("http://localhost:%s", port)
 	authUrl := strings.Replace(schemeData["authorizeUrl"], "__redirect_url__", redirectUrl, 1)
-	http.HandleFunc("/", callback(redirectUrl, finish))
-	server := &http.Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", callback(redirectUrl, finish))
+	server := &http.Server{Handler: mux}
 	go server.Serve(l)
 	err = open(authUrl)
 	if err != nil {
 		fmt.Fprintln(context.Stdout, "Failed to start your browser.")
 		fmt.Fprintf(context.Stdout, "Please open the following URL in your browser: %s\n", authUrl)
+	} else {
+		fmt.Fprintln(context.Stdout, "Waiting for the login to be completed in your browser...")
+	}
+	err = <-finish
+	if err != nil {
+		return err
 	}
-	<-finish
 	fmt.Fprintln(context.Stdout, "Successfully logged in!")
 	return nil
 }
@@ -5,13 +5,15 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"runtime"
-	"strings"
+	"time"
 
 	"github.com/tsuru/tsuru/exec/exectest"
 	"github.com/tsuru/tsuru/fs/fstest"
@@ -51,14 +53,13 @@ func (s *S) TestCallbackHandler(c *check.C) {
 	}()
 	os.Setenv("TSURU_TARGET", ts.URL)
 	redirectUrl := "someurl"
-	finish := make(chan bool, 1)
+	finish := make(chan error, 1)
 	handler := callback(redirectUrl, finish)
-	body := `{"code":"xpto"}`
-	request, err := http.NewRequest("GET", "/", strings.NewReader(body))
+	request, err := http.NewRequest("GET", "/?code=xpto", nil)
 	c.Assert(err, check.IsNil)
 	recorder := httptest.NewRecorder()
 	handler(recorder, request)
-	c.Assert(<-finish, check.Equals, true)
+	c.Assert(<-finish, check.IsNil)
 	expectedPage := fmt.Sprintf(callbackPage, successMarkup)
 	c.Assert(expectedPage, check.Equals, recorder.Body.String())
 	file, err := rfs.Open(JoinWithUserDir(".tsuru", "token"))
@@ -67,3 +68,104 @@ func (s *S) TestCallbackHandler(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(string(data), check.Equals, "xpto")
 }
+
+func (s *S) TestCallbackHandlerIgnoresRequestsWithoutCode(c *check.C) {
+	finish := make(chan error, 1)
+	handler := callback("someurl", finish)
+	request, err := http.NewRequest("GET", "/favicon.ico", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	select {
+	case err = <-finish:
+		c.Fatalf("unexpected login result: %v", err)
+	default:
+	}
+}
+
+func (s *S) TestCallbackHandlerAuthorizationDenied(c *check.C) {
+	finish := make(chan error, 1)
+	handler := callback("someurl", finish)
+	request, err := http.NewRequest("GET", "/?error=access_denied&error_description=user+<denied>", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	c.Assert(<-finish, check.ErrorMatches, "authorization denied: access_denied: user <denied>")
+	c.Assert(recorder.Body.String(), check.Matches, "(?s).*Login Failed!.*user &lt;denied&gt;.*")
+}
+
+func (s *S) TestCallbackHandlerTokenExchangeFailure(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	os.Setenv("TSURU_TARGET", ts.URL)
+	finish := make(chan error, 1)
+	handler := callback("someurl", finish)
+	request, err := http.NewRequest("GET", "/?code=xpto", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	c.Assert(<-finish, check.ErrorMatches, "Error exchanging the authorization code: invalid code")
+	c.Assert(rfs.HasAction("create "+JoinWithUserDir(".tsuru", "token")), check.Equals, false)
+}
+
+func (s *S) TestOAuthLogin(c *check.C) {
+	var code, redirectUrl string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code = r.FormValue("code")
+		redirectUrl = r.FormValue("redirectUrl")
+		w.Write([]byte(`{"token": "xpto"}`))
+	}))
+	defer ts.Close()
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	fexec := exectest.FakeExecutor{}
+	execut = &fexec
+	defer func() {
+		fsystem = nil
+		execut = nil
+	}()
+	os.Setenv("TSURU_TARGET", ts.URL)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	_, loginPort, err := net.SplitHostPort(l.Addr().String())
+	c.Assert(err, check.IsNil)
+	l.Close()
+	cmd := login{scheme: &loginScheme{Name: "oauth", Data: map[string]string{
+		"authorizeUrl": "https://auth.example.com/authorize?redirect_uri=__redirect_url__",
+		"port":         loginPort,
+	}}}
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run(&context, nil)
+	}()
+	callbackUrl := "http://localhost:" + loginPort + "/?code=abc"
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		resp, err = http.Get(callbackUrl)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(<-done, check.IsNil)
+	c.Assert(code, check.Equals, "abc")
+	c.Assert(redirectUrl, check.Equals, "http://localhost:"+loginPort)
+	c.Assert(stdout.String(), check.Matches, "(?s).*Successfully logged in!\n")
+	file, err := rfs.Open(JoinWithUserDir(".tsuru", "token"))
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(file)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "xpto")
+}
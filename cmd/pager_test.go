@@ -0,0 +1,48 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPagerWriterShortOutput(c *check.C) {
+	var buf bytes.Buffer
+	w := &pagerWriter{baseWriter: &buf, pager: "false", height: 3}
+	w.Write([]byte("line 1\nline 2\n"))
+	c.Assert(buf.String(), check.Equals, "")
+	w.close()
+	c.Assert(buf.String(), check.Equals, "line 1\nline 2\n")
+}
+
+func (s *S) TestPagerWriterLongOutput(c *check.C) {
+	var buf bytes.Buffer
+	w := &pagerWriter{baseWriter: &buf, pager: "sed s/^/paged:/", height: 2}
+	w.Write([]byte("line 1\nline 2\nline 3\n"))
+	w.close()
+	c.Assert(buf.String(), check.Equals, "paged:line 1\npaged:line 2\npaged:line 3\n")
+}
+
+func (s *S) TestPagerWriterFallsBackWhenThePagerFails(c *check.C) {
+	var buf bytes.Buffer
+	w := &pagerWriter{baseWriter: &buf, pager: "/does/not/exist", height: 1}
+	w.Write([]byte("line 1\nline 2\n"))
+	w.close()
+	c.Assert(buf.String(), check.Equals, "line 1\nline 2\n")
+}
+
+func (s *S) TestNewPagerWriterDisabled(c *check.C) {
+	os.Setenv("TSURU_PAGER", "")
+	defer os.Unsetenv("TSURU_PAGER")
+	c.Assert(newPagerWriter(os.Stdout), check.Equals, os.Stdout)
+}
+
+func (s *S) TestNewPagerWriterNotATerminal(c *check.C) {
+	var buf bytes.Buffer
+	c.Assert(newPagerWriter(&buf), check.Equals, &buf)
+}
@@ -0,0 +1,196 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+	"github.com/tsuru/tsuru/net"
+)
+
+func pluginsDir() string {
+	return JoinWithUserDir(".tsuru", "plugins")
+}
+
+func pluginPath(name string) string {
+	return JoinWithUserDir(".tsuru", "plugins", name)
+}
+
+func validatePluginName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.Errorf("invalid plugin name %q", name)
+	}
+	return nil
+}
+
+type pluginInstall struct {
+	fs   *gnuflag.FlagSet
+	name string
+}
+
+func (c *pluginInstall) Info() *Info {
+	return &Info{
+		Name:  "plugin-install",
+		Usage: "plugin-install <url> [--name <name>]",
+		Desc: `Downloads the executable in the given URL and installs it as a plugin. The
+name of the plugin defaults to the last element of the URL path, without
+extension.
+
+Plugins are run as commands with their name. They receive the current target
+and token in the TSURU_TARGET and TSURU_TOKEN environment variables, and their
+name in TSURU_PLUGIN_NAME.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *pluginInstall) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("plugin-install", gnuflag.ExitOnError)
+		c.fs.StringVar(&c.name, "name", "", "Name of the plugin")
+		c.fs.StringVar(&c.name, "n", "", "Name of the plugin")
+	}
+	return c.fs
+}
+
+func (c *pluginInstall) Run(context *Context, client *Client) error {
+	pluginURL := context.Args[0]
+	name := c.name
+	if name == "" {
+		u, err := url.Parse(pluginURL)
+		if err != nil {
+			return err
+		}
+		name = path.Base(u.Path)
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if err := validatePluginName(name); err != nil {
+		return err
+	}
+	// The plugin is not downloaded with client, the token of the user must
+	// not be sent to a host other than the target.
+	resp, err := net.Dial5FullUnlimitedClient.Get(pluginURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to download plugin %q: %s", name, resp.Status)
+	}
+	err = filesystem().MkdirAll(pluginsDir(), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := filesystem().OpenFile(pluginPath(name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Plugin %q successfully installed!\n", name)
+	return nil
+}
+
+type pluginList struct{}
+
+func (pluginList) Info() *Info {
+	return &Info{
+		Name:  "plugin-list",
+		Usage: "plugin-list",
+		Desc:  "Lists the installed plugins.",
+	}
+}
+
+func (pluginList) Run(context *Context, client *Client) error {
+	files, err := ioutil.ReadDir(pluginsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			fmt.Fprintln(context.Stdout, file.Name())
+		}
+	}
+	return nil
+}
+
+type pluginRemove struct{}
+
+func (pluginRemove) Info() *Info {
+	return &Info{
+		Name:    "plugin-remove",
+		Usage:   "plugin-remove <name>",
+		Desc:    "Removes the given plugin.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (pluginRemove) Run(context *Context, client *Client) error {
+	name := context.Args[0]
+	if err := validatePluginName(name); err != nil {
+		return err
+	}
+	err := filesystem().Remove(pluginPath(name))
+	if os.IsNotExist(err) {
+		return errors.Errorf("plugin %q not found", name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Plugin %q successfully removed!\n", name)
+	return nil
+}
+
+// runPlugin runs the plugin named after the first argument, if it's
+// installed, with the remaining arguments, and returns its exit status. The
+// returned bool is false when there's no plugin with the name.
+func (m *Manager) runPlugin(args []string) (int, bool) {
+	name := args[0]
+	if validatePluginName(name) != nil {
+		return 0, false
+	}
+	pluginFile := pluginPath(name)
+	if info, err := os.Stat(pluginFile); err != nil || info.IsDir() {
+		return 0, false
+	}
+	env := append(os.Environ(), "TSURU_PLUGIN_NAME="+name)
+	if target, err := GetTarget(); err == nil {
+		env = append(env, "TSURU_TARGET="+target)
+	}
+	if token, err := ReadToken(); err == nil {
+		env = append(env, "TSURU_TOKEN="+token)
+	}
+	cmd := exec.Command(pluginFile, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = m.stdin
+	cmd.Stdout = m.stdout
+	cmd.Stderr = m.stderr
+	err := cmd.Run()
+	if err == nil {
+		return 0, true
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), true
+		}
+	}
+	fmt.Fprintf(m.stderr, "Error: failed to run plugin %q: %s\n", name, err)
+	return 1, true
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"gopkg.in/check.v1"
+)
+
+func setupPluginHome(c *check.C) func() {
+	home, err := ioutil.TempDir("", "tsuru-plugins")
+	c.Assert(err, check.IsNil)
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	return func() {
+		os.Setenv("HOME", oldHome)
+		os.RemoveAll(home)
+	}
+}
+
+func writePlugin(c *check.C, name, script string) {
+	err := os.MkdirAll(pluginsDir(), 0755)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(pluginPath(name), []byte(script), 0755)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPluginInstall(c *check.C) {
+	defer setupPluginHome(c)()
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte("#!/bin/sh\necho hello\n"))
+	}))
+	defer server.Close()
+	var stdout bytes.Buffer
+	context := Context{Args: []string{server.URL + "/releases/myplugin.sh"}, Stdout: &stdout}
+	command := pluginInstall{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Plugin \"myplugin\" successfully installed!\n")
+	c.Assert(authorization, check.Equals, "")
+	data, err := ioutil.ReadFile(pluginPath("myplugin"))
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "#!/bin/sh\necho hello\n")
+	info, err := os.Stat(pluginPath("myplugin"))
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Mode().Perm()&0100, check.Equals, os.FileMode(0100))
+}
+
+func (s *S) TestPluginInstallWithName(c *check.C) {
+	defer setupPluginHome(c)()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plugin"))
+	}))
+	defer server.Close()
+	var stdout bytes.Buffer
+	context := Context{Args: []string{server.URL + "/download"}, Stdout: &stdout}
+	command := pluginInstall{}
+	command.Flags().Parse(true, []string{"--name", "other"})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(pluginPath("other"))
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPluginInstallDownloadFailure(c *check.C) {
+	defer setupPluginHome(c)()
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	context := Context{Args: []string{server.URL + "/myplugin"}, Stdout: &bytes.Buffer{}}
+	command := pluginInstall{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `failed to download plugin "myplugin": 404 Not Found`)
+	_, err = os.Stat(pluginPath("myplugin"))
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *S) TestPluginInstallInvalidName(c *check.C) {
+	context := Context{Args: []string{"http://example.com/"}, Stdout: &bytes.Buffer{}}
+	command := pluginInstall{}
+	command.Flags().Parse(true, []string{"--name", "../bin"})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid plugin name "../bin"`)
+}
+
+func (s *S) TestPluginList(c *check.C) {
+	defer setupPluginHome(c)()
+	writePlugin(c, "myplugin", "#!/bin/sh\n")
+	writePlugin(c, "otherplugin", "#!/bin/sh\n")
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout}
+	err := pluginList{}.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "myplugin\notherplugin\n")
+}
+
+func (s *S) TestPluginListWithoutPlugins(c *check.C) {
+	defer setupPluginHome(c)()
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout}
+	err := pluginList{}.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "")
+}
+
+func (s *S) TestPluginRemove(c *check.C) {
+	defer setupPluginHome(c)()
+	writePlugin(c, "myplugin", "#!/bin/sh\n")
+	var stdout bytes.Buffer
+	context := Context{Args: []string{"myplugin"}, Stdout: &stdout}
+	err := pluginRemove{}.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Plugin \"myplugin\" successfully removed!\n")
+	_, err = os.Stat(pluginPath("myplugin"))
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+	err = pluginRemove{}.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `plugin "myplugin" not found`)
+}
+
+func (s *S) TestManagerRunPlugin(c *check.C) {
+	defer setupPluginHome(c)()
+	writePlugin(c, "myplugin", "#!/bin/sh\necho \"$TSURU_PLUGIN_NAME $TSURU_TARGET $TSURU_TOKEN $*\"\n")
+	var stdout, stderr bytes.Buffer
+	var exiter recordingExiter
+	mngr := NewManager("tsuru", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	mngr.plugins = true
+	mngr.e = &exiter
+	mngr.Run([]string{"myplugin", "arg1", "--flag", "arg2"})
+	c.Assert(stderr.String(), check.Equals, "")
+	c.Assert(stdout.String(), check.Equals, "myplugin http://localhost abc123 arg1 --flag arg2\n")
+	c.Assert(exiter.value(), check.Equals, 0)
+}
+
+func (s *S) TestManagerRunPluginExitStatus(c *check.C) {
+	defer setupPluginHome(c)()
+	writePlugin(c, "myplugin", "#!/bin/sh\necho failed >&2\nexit 3\n")
+	var stdout, stderr bytes.Buffer
+	var exiter recordingExiter
+	mngr := NewManager("tsuru", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	mngr.plugins = true
+	mngr.e = &exiter
+	mngr.Run([]string{"myplugin"})
+	c.Assert(stderr.String(), check.Equals, "failed\n")
+	c.Assert(exiter.value(), check.Equals, 3)
+}
+
+func (s *S) TestManagerRunPluginNotInstalled(c *check.C) {
+	defer setupPluginHome(c)()
+	var stdout, stderr bytes.Buffer
+	var exiter recordingExiter
+	mngr := NewManager("tsuru", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	mngr.plugins = true
+	mngr.e = &exiter
+	mngr.Run([]string{"myplugin"})
+	c.Assert(stderr.String(), check.Equals, "tsuru: \"myplugin\" is not a tsuru command. See \"tsuru help\".\n")
+	c.Assert(exiter.value(), check.Equals, 1)
+}
+
+func (s *S) TestManagerRunPluginsDisabled(c *check.C) {
+	defer setupPluginHome(c)()
+	writePlugin(c, "myplugin", "#!/bin/sh\necho plugin\n")
+	var stdout, stderr bytes.Buffer
+	var exiter recordingExiter
+	mngr := NewManager("tsuru", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	mngr.e = &exiter
+	mngr.Run([]string{"myplugin"})
+	c.Assert(stdout.String(), check.Equals, "")
+	c.Assert(exiter.value(), check.Equals, 1)
+}
+
+func (s *S) TestPluginCommandsAreRegisteredByBaseManager(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	c.Assert(mngr.Commands["plugin-install"], check.FitsTypeOf, &pluginInstall{})
+	c.Assert(mngr.Commands["plugin-list"], check.FitsTypeOf, pluginList{})
+	c.Assert(mngr.Commands["plugin-remove"], check.FitsTypeOf, pluginRemove{})
+	c.Assert(mngr.plugins, check.Equals, true)
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type appProcessPlanSet struct {
+	GuessingCommand
+}
+
+func (c *appProcessPlanSet) Info() *Info {
+	return &Info{
+		Name:  "app-process-plan-set",
+		Usage: "app-process-plan-set [-a/--app appname] <process> [plan]",
+		Desc: `Changes the plan of the units of a process declared in the Procfile of the
+app, restarting them. Units of other processes keep the plan of the app. Omit
+the plan to make the units of the process go back to the plan of the app.`,
+		MinArgs: 1,
+		MaxArgs: 2,
+	}
+}
+
+func (c *appProcessPlanSet) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	process := context.Args[0]
+	var plan string
+	if len(context.Args) > 1 {
+		plan = context.Args[1]
+	}
+	v := url.Values{}
+	v.Set("plan", plan)
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/apps/%s/processes/%s/plan", appName, process))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	err = StreamJSONResponse(context.Stdout, resp)
+	if err != nil {
+		return err
+	}
+	if plan == "" {
+		fmt.Fprintf(context.Stdout, "Plan of process %q of app %q successfully removed.\n", process, appName)
+		return nil
+	}
+	fmt.Fprintf(context.Stdout, "Plan of process %q of app %q successfully set to %q.\n", process, appName, plan)
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppProcessPlanSetInfo(c *check.C) {
+	c.Assert((&appProcessPlanSet{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppProcessPlanSetRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"worker", "big"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Message":"---- Restarting process \"worker\" ----\n"}` + "\n",
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/processes/worker/plan" &&
+				req.Form.Get("plan") == "big"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appProcessPlanSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `---- Restarting process "worker" ----`+"\n"+`Plan of process "worker" of app "myapp" successfully set to "big".`+"\n")
+}
+
+func (s *S) TestAppProcessPlanSetRunRemove(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"worker"}}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			_, ok := req.Form["plan"]
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/processes/worker/plan" &&
+				ok && req.Form.Get("plan") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appProcessPlanSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Plan of process "worker" of app "myapp" successfully removed.`+"\n")
+}
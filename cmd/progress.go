@@ -0,0 +1,167 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const progressInterval = 100 * time.Millisecond
+
+var (
+	progressStepRegexp = regexp.MustCompile(`^\s*----\s*(.*?)[\s.]*(?:----)?\s*$`)
+	spinnerFrames      = []string{"|", "/", "-", "\\"}
+)
+
+// ProgressWriter renders the output streamed by the tsuru API during deploys
+// and platform updates as a list of steps with their elapsed time. Steps are
+// the lines like "---- Building application image ----" and the other lines
+// are only displayed, with the output of the current step, when it fails.
+//
+// When the output is not a terminal, everything is written as is.
+type ProgressWriter struct {
+	mu          sync.Mutex
+	out         io.Writer
+	interactive bool
+	now         func() time.Time
+	partial     []byte
+	step        string
+	stepStart   time.Time
+	stepOutput  bytes.Buffer
+	frame       int
+	done        chan struct{}
+}
+
+// NewProgressWriter returns a ProgressWriter writing to out. Finish must be
+// called at the end of the stream.
+func NewProgressWriter(out io.Writer) *ProgressWriter {
+	if pager, ok := out.(*pagerWriter); ok {
+		out = pager.baseWriter
+	}
+	w := &ProgressWriter{out: out, now: time.Now}
+	if desc, ok := out.(descriptable); ok && terminal.IsTerminal(int(desc.Fd())) {
+		w.interactive = true
+		w.stepStart = w.now()
+		w.done = make(chan struct{})
+		go w.spin()
+	}
+	return w
+}
+
+func (w *ProgressWriter) spin() {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			w.frame++
+			w.render()
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.interactive {
+		return w.out.Write(p)
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(string(w.partial[:i+1]))
+		w.partial = w.partial[i+1:]
+	}
+	w.render()
+	return len(p), nil
+}
+
+func (w *ProgressWriter) line(line string) {
+	if strings.TrimSpace(line) == "please wait..." {
+		return
+	}
+	if m := progressStepRegexp.FindStringSubmatch(line); m != nil && m[1] != "" {
+		w.endStep(true)
+		w.step = m[1]
+		w.stepStart = w.now()
+		w.stepOutput.Reset()
+		return
+	}
+	w.stepOutput.WriteString(line)
+}
+
+func (w *ProgressWriter) elapsed() string {
+	return w.now().Sub(w.stepStart).Truncate(100 * time.Millisecond).String()
+}
+
+func (w *ProgressWriter) stepName() string {
+	if w.step == "" {
+		return "Starting"
+	}
+	return w.step
+}
+
+func (w *ProgressWriter) render() {
+	frame := spinnerFrames[w.frame%len(spinnerFrames)]
+	fmt.Fprintf(w.out, "\r\033[K%s %s (%s)", frame, w.stepName(), w.elapsed())
+}
+
+func (w *ProgressWriter) endStep(success bool) {
+	if w.step == "" && success {
+		fmt.Fprint(w.out, "\r\033[K")
+		return
+	}
+	mark := ColorStatus("ok", true)
+	if !success {
+		mark = ColorStatus("failed", false)
+	}
+	fmt.Fprintf(w.out, "\r\033[K%s %s (%s)\n", w.stepName(), mark, w.elapsed())
+}
+
+// Finish ends the current step, as failed when err is not nil, in which case
+// the output of the step is displayed.
+func (w *ProgressWriter) Finish(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.interactive {
+		return
+	}
+	close(w.done)
+	w.interactive = false
+	if len(w.partial) > 0 {
+		w.stepOutput.Write(w.partial)
+		w.stepOutput.WriteString("\n")
+		w.partial = nil
+	}
+	w.endStep(err == nil)
+	if err != nil {
+		w.out.Write(w.stepOutput.Bytes())
+	}
+}
+
+// StreamJSONResponseWithProgress is like StreamJSONResponse, but renders the
+// stream with a ProgressWriter.
+func StreamJSONResponseWithProgress(w io.Writer, response *http.Response) error {
+	progress := NewProgressWriter(w)
+	err := StreamJSONResponse(progress, response)
+	progress.Finish(err)
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func newTestProgressWriter(out *bytes.Buffer, clock *time.Time) *ProgressWriter {
+	return &ProgressWriter{
+		out:         out,
+		interactive: true,
+		now:         func() time.Time { return *clock },
+		stepStart:   *clock,
+		done:        make(chan struct{}),
+	}
+}
+
+// visibleLines returns the lines that remain in a terminal after the output
+// is written, handling the carriage returns and line clearing sequences.
+func visibleLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, "\r\033[K")
+		lines = append(lines, parts[len(parts)-1])
+	}
+	return lines
+}
+
+func (s *S) TestProgressWriterSteps(c *check.C) {
+	os.Setenv("TSURU_DISABLE_COLORS", "1")
+	defer os.Unsetenv("TSURU_DISABLE_COLORS")
+	var buf bytes.Buffer
+	clock := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	w := newTestProgressWriter(&buf, &clock)
+	w.Write([]byte("uploading files\n---- Building application image ----\n"))
+	clock = clock.Add(2 * time.Second)
+	w.Write([]byte("step 1/3\nstep 2"))
+	c.Assert(visibleLines(buf.String()), check.DeepEquals, []string{"| Building application image (2s)"})
+	w.Write([]byte("/3\n\n---- Starting 2 new units [web: 2] ----\nplease wait...\n"))
+	clock = clock.Add(1500 * time.Millisecond)
+	w.Finish(nil)
+	c.Assert(visibleLines(buf.String()), check.DeepEquals, []string{
+		"Building application image ok (2s)",
+		"Starting 2 new units [web: 2] ok (1.5s)",
+		"",
+	})
+}
+
+func (s *S) TestProgressWriterFailure(c *check.C) {
+	os.Setenv("TSURU_DISABLE_COLORS", "1")
+	defer os.Unsetenv("TSURU_DISABLE_COLORS")
+	var buf bytes.Buffer
+	clock := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	w := newTestProgressWriter(&buf, &clock)
+	w.Write([]byte("---- Building application image ----\nstep 1/3\n"))
+	w.Write([]byte("---- Removing application \"myapp\"...\ncould not remove"))
+	clock = clock.Add(time.Second)
+	w.Finish(errors.New("failed"))
+	c.Assert(visibleLines(buf.String()), check.DeepEquals, []string{
+		"Building application image ok (0s)",
+		`Removing application "myapp" failed (1s)`,
+		"could not remove",
+		"",
+	})
+}
+
+func (s *S) TestProgressWriterNotATerminal(c *check.C) {
+	var buf bytes.Buffer
+	w := NewProgressWriter(&buf)
+	w.Write([]byte("---- Building application image ----\nstep 1/3\n"))
+	w.Finish(nil)
+	c.Assert(buf.String(), check.Equals, "---- Building application image ----\nstep 1/3\n")
+}
+
+func (s *S) TestStreamJSONResponseWithProgress(c *check.C) {
+	var buf bytes.Buffer
+	resp := http.Response{
+		Body: ioutil.NopCloser(bytes.NewBufferString(`{"Message":"---- Updating platform ----\n"}` + "\n" + `{"Message":"","Error":"build failed"}`)),
+	}
+	err := StreamJSONResponseWithProgress(&buf, &resp)
+	c.Assert(err, check.ErrorMatches, "build failed")
+	c.Assert(buf.String(), check.Equals, "---- Updating platform ----\n")
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type appRateLimitSet struct {
+	GuessingCommand
+	flags *gnuflag.FlagSet
+	rps   int
+	burst int
+	perIP bool
+}
+
+func (c *appRateLimitSet) Info() *Info {
+	return &Info{
+		Name:  "app-ratelimit-set",
+		Usage: "app-ratelimit-set [-a/--app appname] --rps <requests> [--burst <requests>] [--per-ip]",
+		Desc: `Limits the requests per second the router sends to the app. Burst is the
+number of requests above the limit accepted in a short period of time. With
+--per-ip the limit is applied to each client IP, otherwise it's applied to all
+clients of the app. Use --rps 0 to remove the limit.
+
+The limit is only accepted when the router of the app supports it.`,
+	}
+}
+
+func (c *appRateLimitSet) Flags() *gnuflag.FlagSet {
+	if c.flags == nil {
+		c.flags = c.GuessingCommand.Flags()
+		c.flags.IntVar(&c.rps, "rps", -1, "The limit of requests per second, 0 removes the limit.")
+		c.flags.IntVar(&c.burst, "burst", 0, "The number of requests above the limit accepted in a short period of time.")
+		c.flags.BoolVar(&c.perIP, "per-ip", false, "Apply the limit to each client IP.")
+	}
+	return c.flags
+}
+
+func (c *appRateLimitSet) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.rps < 0 {
+		return errors.New("the limit of requests per second is required, use --rps")
+	}
+	v := url.Values{}
+	v.Set("rps", strconv.Itoa(c.rps))
+	v.Set("burst", strconv.Itoa(c.burst))
+	v.Set("per-ip", strconv.FormatBool(c.perIP))
+	u, err := GetURLVersion("1.3", "/apps/"+appName+"/ratelimit")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if c.rps == 0 {
+		fmt.Fprintf(context.Stdout, "Rate limit of app %q successfully removed.\n", appName)
+		return nil
+	}
+	fmt.Fprintf(context.Stdout, "Rate limit of app %q successfully set.\n", appName)
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppRateLimitSetInfo(c *check.C) {
+	c.Assert((&appRateLimitSet{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppRateLimitSetRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/apps/myapp/ratelimit" &&
+				req.Form.Get("rps") == "10" && req.Form.Get("burst") == "5" &&
+				req.Form.Get("per-ip") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appRateLimitSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "--rps", "10", "--burst", "5", "--per-ip"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Rate limit of app "myapp" successfully set.`+"\n")
+}
+
+func (s *S) TestAppRateLimitSetRunRemove(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.Form.Get("rps") == "0"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := appRateLimitSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "--rps", "0"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Rate limit of app "myapp" successfully removed.`+"\n")
+}
+
+func (s *S) TestAppRateLimitSetRunWithoutRPS(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusInternalServerError}}, nil, globalManager)
+	command := appRateLimitSet{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "the limit of requests per second is required, use --rps")
+}
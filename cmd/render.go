@@ -341,9 +341,21 @@ func (l rowSlice) Swap(i, j int) {
 	l[i], l[j] = l[j], l[i]
 }
 
+// Colorfy wraps msg in the escape sequences of the given colors and effect,
+// unless colors are disabled with the TSURU_DISABLE_COLORS environment
+// variable, which is set by the --no-color flag and when the output is not a
+// terminal.
 func Colorfy(msg string, fontcolor string, background string, effect string) string {
 	if os.Getenv("TSURU_DISABLE_COLORS") != "" {
 		return msg
 	}
 	return fmt.Sprintf(pattern, fontEffects[effect], fontColors[fontcolor], fontColors[background]+bgFactor, msg)
 }
+
+// ColorStatus renders status columns: green for success and red for errors.
+func ColorStatus(status string, success bool) string {
+	if success {
+		return Colorfy(status, "green", "", "")
+	}
+	return Colorfy(status, "red", "", "")
+}
@@ -445,3 +445,14 @@ jk`})
 3: ↵
 4`})
 }
+
+func (s *S) TestColorStatus(c *check.C) {
+	c.Assert(ColorStatus("succeeded", true), check.Equals, "\033[0;32;10msucceeded\033[0m")
+	c.Assert(ColorStatus("failed", false), check.Equals, "\033[0;31;10mfailed\033[0m")
+}
+
+func (s *S) TestColorStatusDisabled(c *check.C) {
+	os.Setenv("TSURU_DISABLE_COLORS", "1")
+	defer os.Unsetenv("TSURU_DISABLE_COLORS")
+	c.Assert(ColorStatus("failed", false), check.Equals, "failed")
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type resourceLimits struct {
+	Apps   int
+	Units  int
+	Memory int64
+}
+
+type resourceQuota struct {
+	Scope string
+	Name  string
+	Limit resourceLimits
+	InUse resourceLimits
+}
+
+// resourceQuotaShow and resourceQuotaSet are registered once for teams and
+// once for pools, the scope is also the name of the resource in the API.
+type resourceQuotaShow struct {
+	scope string
+}
+
+func (c *resourceQuotaShow) Info() *Info {
+	return &Info{
+		Name:  c.scope + "-quota-show",
+		Usage: fmt.Sprintf("%s-quota-show <%sname>", c.scope, c.scope),
+		Desc: fmt.Sprintf(`Shows the limits and the usage of the apps, units and memory of the apps
+%s. The memory in use is the memory of the plan of each app times its number
+of units.`, resourceQuotaOwnership(c.scope)),
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *resourceQuotaShow) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/%ss/%s/quota", c.scope, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var q resourceQuota
+	err = json.NewDecoder(resp.Body).Decode(&q)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(q)
+	}
+	table := NewTable()
+	table.Headers = Row{"Resource", "In Use", "Limit"}
+	table.AddRow(Row{"Apps", strconv.Itoa(q.InUse.Apps), formatResourceLimit(int64(q.Limit.Apps), strconv.Itoa(q.Limit.Apps))})
+	table.AddRow(Row{"Units", strconv.Itoa(q.InUse.Units), formatResourceLimit(int64(q.Limit.Units), strconv.Itoa(q.Limit.Units))})
+	table.AddRow(Row{"Memory", formatBytes(q.InUse.Memory), formatResourceLimit(q.Limit.Memory, formatBytes(q.Limit.Memory))})
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+func formatResourceLimit(limit int64, formatted string) string {
+	if limit < 0 {
+		return "unlimited"
+	}
+	return formatted
+}
+
+func resourceQuotaOwnership(scope string) string {
+	if scope == "pool" {
+		return "in the pool"
+	}
+	return "owned by the team"
+}
+
+type resourceQuotaSet struct {
+	scope  string
+	flags  *gnuflag.FlagSet
+	apps   string
+	units  string
+	memory string
+}
+
+func (c *resourceQuotaSet) Info() *Info {
+	return &Info{
+		Name:  c.scope + "-quota-set",
+		Usage: fmt.Sprintf("%s-quota-set <%sname> [--apps <number>] [--units <number>] [--memory <size>]", c.scope, c.scope),
+		Desc: fmt.Sprintf(`Changes the limits of apps, units and memory of the apps %s.
+Limits not set keep their current value, -1 means unlimited. The memory accepts
+the suffixes K, M and G, like 512M.
+
+Creating apps and adding units is denied when it would exceed the limits, and
+the new limits can't be lesser than the current usage.`, resourceQuotaOwnership(c.scope)),
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *resourceQuotaSet) Flags() *gnuflag.FlagSet {
+	if c.flags == nil {
+		c.flags = gnuflag.NewFlagSet("", gnuflag.ExitOnError)
+		c.flags.StringVar(&c.apps, "apps", "", "The maximum number of apps.")
+		c.flags.StringVar(&c.units, "units", "", "The maximum number of units of all apps.")
+		c.flags.StringVar(&c.memory, "memory", "", "The maximum memory of all units.")
+	}
+	return c.flags
+}
+
+func (c *resourceQuotaSet) Run(context *Context, client *Client) error {
+	v := url.Values{}
+	if c.apps != "" {
+		v.Set("apps", c.apps)
+	}
+	if c.units != "" {
+		v.Set("units", c.units)
+	}
+	if c.memory != "" {
+		v.Set("memory", c.memory)
+	}
+	if len(v) == 0 {
+		return fmt.Errorf("at least one of --apps, --units and --memory is required")
+	}
+	name := context.Args[0]
+	u, err := GetURLVersion("1.3", fmt.Sprintf("/%ss/%s/quota", c.scope, name))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Quota of %s %q successfully updated.\n", c.scope, name)
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestResourceQuotaShowInfo(c *check.C) {
+	c.Assert((&resourceQuotaShow{scope: "team"}).Info().Name, check.Equals, "team-quota-show")
+	c.Assert((&resourceQuotaShow{scope: "pool"}).Info().Name, check.Equals, "pool-quota-show")
+}
+
+func (s *S) TestResourceQuotaShowRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Scope":"team","Name":"myteam","Limit":{"Apps":10,"Units":-1,"Memory":2147483648},"InUse":{"Apps":2,"Units":3,"Memory":402653184}}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/teams/myteam/quota"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := resourceQuotaShow{scope: "team"}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Resource", "In Use", "Limit"}
+	table.AddRow(Row{"Apps", "2", "10"})
+	table.AddRow(Row{"Units", "3", "unlimited"})
+	table.AddRow(Row{"Memory", "384.0 MiB", "2.0 GiB"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestResourceQuotaSetInfo(c *check.C) {
+	c.Assert((&resourceQuotaSet{scope: "team"}).Info().Name, check.Equals, "team-quota-set")
+	c.Assert((&resourceQuotaSet{scope: "pool"}).Info().Name, check.Equals, "pool-quota-set")
+}
+
+func (s *S) TestResourceQuotaSetRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mypool"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/pools/mypool/quota" &&
+				req.Form.Get("units") == "20" && req.Form.Get("memory") == "4G" &&
+				req.Form.Get("apps") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := resourceQuotaSet{scope: "pool"}
+	err := command.Flags().Parse(true, []string{"--units", "20", "--memory", "4G"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Quota of pool "mypool" successfully updated.`+"\n")
+}
+
+func (s *S) TestResourceQuotaSetRunWithoutLimits(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	command := resourceQuotaSet{scope: "team"}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "at least one of --apps, --units and --memory is required")
+}
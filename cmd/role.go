@@ -0,0 +1,64 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type roleClone struct {
+	fs          *gnuflag.FlagSet
+	description string
+}
+
+func (c *roleClone) Info() *Info {
+	return &Info{
+		Name:  "role-clone",
+		Usage: "role-clone <role> <new-name> [--description/-d <description>]",
+		Desc: `Creates a new role with the same context and permissions of an existing
+role. Later changes to either role don't affect the other one. The built-in
+developer, operator and auditor roles are meant to be cloned and adjusted.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *roleClone) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("role-clone", gnuflag.ExitOnError)
+		desc := "Description of the new role, defaults to the one of the cloned role"
+		c.fs.StringVar(&c.description, "description", "", desc)
+		c.fs.StringVar(&c.description, "d", "", desc)
+	}
+	return c.fs
+}
+
+func (c *roleClone) Run(context *Context, client *Client) error {
+	role, newName := context.Args[0], context.Args[1]
+	u, err := GetURLVersion("1.3", "/roles/"+url.PathEscape(role)+"/clone")
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("name", newName)
+	v.Set("description", c.description)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Role %q successfully cloned to %q.\n", role, newName)
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRoleCloneInfo(c *check.C) {
+	c.Assert((&roleClone{}).Info(), check.NotNil)
+}
+
+func (s *S) TestRoleCloneRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"developer", "backend-developer"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"name": "backend-developer"}`, Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.3/roles/developer/clone" &&
+				req.Form.Get("name") == "backend-developer" && req.Form.Get("description") == "backend devs"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := roleClone{}
+	err := command.Flags().Parse(true, []string{"-d", "backend devs"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Role \"developer\" successfully cloned to \"backend-developer\".\n")
+}
+
+func (s *S) TestRoleCloneIsRegistered(c *check.C) {
+	manager := BuildBaseManager("tsuru", "1.0", "", nil)
+	c.Assert(manager.Commands["role-clone"], check.FitsTypeOf, &roleClone{})
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type session struct {
+	ID         string    `json:"id"`
+	Creation   time.Time `json:"creation"`
+	Expires    time.Time `json:"expires,omitempty"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	Current    bool      `json:"current,omitempty"`
+}
+
+type sessionList struct{}
+
+func (sessionList) Info() *Info {
+	return &Info{
+		Name:  "session-list",
+		Usage: "session-list",
+		Desc: `Lists the active sessions of the current user, with the address and the user
+agent of the client that created them. The session used by the command is
+marked with an asterisk.`,
+	}
+}
+
+func (sessionList) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/users/me/sessions")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var sessions []session
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&sessions)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if sessions == nil {
+			sessions = []session{}
+		}
+		return context.Render(sessions)
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "Address", "User agent", "Created", "Expires"}
+	for _, s := range sessions {
+		id := s.ID
+		if s.Current {
+			id += " *"
+		}
+		table.AddRow(Row{id, s.RemoteAddr, s.UserAgent, s.Creation.Local().Format(time.RFC822), formatTokenExpiration(s.Expires)})
+	}
+	fmt.Fprint(context.Stdout, table.String())
+	return nil
+}
+
+type sessionRevoke struct{}
+
+func (sessionRevoke) Info() *Info {
+	return &Info{
+		Name:  "session-revoke",
+		Usage: "session-revoke <id>",
+		Desc: `Revokes the session with the given ID, as listed by session-list. The token
+of the session can't be used anymore.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (sessionRevoke) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/users/me/sessions/"+url.PathEscape(context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Session %q successfully revoked!\n", context.Args[0])
+	return nil
+}
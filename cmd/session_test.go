@@ -0,0 +1,63 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSessionListRun(c *check.C) {
+	created := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	expires := time.Date(2017, 5, 17, 12, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"id": "5911f2a0", "creation": "2017-05-10T12:00:00Z", "expires": "2017-05-17T12:00:00Z", "remoteAddr": "10.0.0.1", "userAgent": "tsuru-client/1.0"},
+{"id": "5911f2a1", "creation": "2017-05-10T12:00:00Z", "expires": "2017-05-17T12:00:00Z", "current": true}]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/users/me/sessions"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := sessionList{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"ID", "Address", "User agent", "Created", "Expires"}
+	table.AddRow(Row{"5911f2a0", "10.0.0.1", "tsuru-client/1.0", created.Local().Format(time.RFC822), expires.Local().Format(time.RFC822)})
+	table.AddRow(Row{"5911f2a1 *", "", "", created.Local().Format(time.RFC822), expires.Local().Format(time.RFC822)})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestSessionListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	err := sessionList{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestSessionRevokeRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"5911f2a0"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.3/users/me/sessions/5911f2a0"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := sessionRevoke{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Session \"5911f2a0\" successfully revoked!\n")
+}
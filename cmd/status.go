@@ -0,0 +1,150 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type statusQuota struct {
+	Limit int
+	InUse int
+}
+
+func (q statusQuota) String() string {
+	if q.Limit < 0 {
+		return fmt.Sprintf("%d/unlimited", q.InUse)
+	}
+	return fmt.Sprintf("%d/%d", q.InUse, q.Limit)
+}
+
+type statusDeploy struct {
+	Timestamp time.Time
+	Duration  time.Duration
+	Error     string
+	Image     string
+	User      string
+}
+
+type statusSummary struct {
+	API struct {
+		Healthy bool
+		Checks  []struct {
+			Name   string
+			Status string
+		}
+	}
+	Apps []struct {
+		Name       string
+		Pool       string
+		Units      map[string]int
+		Quota      statusQuota
+		LastDeploy *statusDeploy
+	}
+	TotalApps int
+	Quota     statusQuota
+	Events    []struct {
+		ID     string
+		Target struct {
+			Type  string
+			Value string
+		}
+		Kind      string
+		Owner     string
+		StartTime time.Time
+	}
+}
+
+type statusOverview struct{}
+
+func (statusOverview) Info() *Info {
+	return &Info{
+		Name:  "status",
+		Usage: "status",
+		Desc: `Displays an overview of the tsuru API health, the apps you have access
+to, with their units and last deploy, running events on these apps and your
+quota usage.`,
+	}
+}
+
+func (statusOverview) Run(context *Context, client *Client) error {
+	url, err := GetURLVersion("1.3", "/status")
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", url, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var summary statusSummary
+	err = json.NewDecoder(resp.Body).Decode(&summary)
+	if err != nil {
+		return err
+	}
+	if summary.API.Healthy {
+		fmt.Fprintln(context.Stdout, "API: "+ColorStatus("healthy", true))
+	} else {
+		fmt.Fprintln(context.Stdout, "API: "+ColorStatus("unhealthy", false))
+		for _, check := range summary.API.Checks {
+			fmt.Fprintf(context.Stdout, "\t%s: %s\n", check.Name, ColorStatus(check.Status, check.Status == "WORKING"))
+		}
+	}
+	fmt.Fprintf(context.Stdout, "App quota: %s\n", summary.Quota)
+	if len(summary.Apps) > 0 {
+		table := NewTable()
+		table.Headers = Row{"App", "Pool", "Units", "Unit quota", "Last deploy"}
+		for _, a := range summary.Apps {
+			table.AddRow(Row{a.Name, a.Pool, formatUnitCount(a.Units), a.Quota.String(), formatLastDeploy(a.LastDeploy)})
+		}
+		fmt.Fprintf(context.Stdout, "\nApps:\n%s", table.String())
+		if summary.TotalApps > len(summary.Apps) {
+			fmt.Fprintf(context.Stdout, "Showing %d of %d apps, use app-list to list all of them.\n", len(summary.Apps), summary.TotalApps)
+		}
+	}
+	if len(summary.Events) > 0 {
+		table := NewTable()
+		table.Headers = Row{"Started", "Target", "Kind", "Owner"}
+		for _, evt := range summary.Events {
+			target := fmt.Sprintf("%s: %s", evt.Target.Type, evt.Target.Value)
+			table.AddRow(Row{evt.StartTime.Local().Format(time.RFC822), target, evt.Kind, evt.Owner})
+		}
+		fmt.Fprintf(context.Stdout, "\nRunning events:\n%s", table.String())
+	}
+	return nil
+}
+
+func formatUnitCount(units map[string]int) string {
+	if len(units) == 0 {
+		return "none"
+	}
+	statuses := make([]string, 0, len(units))
+	for status := range units {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%d %s", units[status], status)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatLastDeploy(deploy *statusDeploy) string {
+	if deploy == nil {
+		return "never"
+	}
+	result := ColorStatus("succeeded", true)
+	if deploy.Error != "" {
+		result = ColorStatus("failed", false)
+	}
+	return fmt.Sprintf("%s (%s)", deploy.Timestamp.Local().Format(time.RFC822), result)
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestStatusInfo(c *check.C) {
+	c.Assert(statusOverview{}.Info(), check.NotNil)
+}
+
+func (s *S) TestStatusRun(c *check.C) {
+	deployTime := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	eventTime := time.Date(2017, 5, 11, 8, 30, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{
+"API": {"Healthy": true, "Checks": [{"Name": "MongoDB", "Status": "WORKING"}]},
+"Quota": {"Limit": 5, "InUse": 2},
+"Apps": [
+	{"Name": "app1", "Pool": "pool1", "Units": {"started": 2, "error": 1}, "Quota": {"Limit": -1, "InUse": 3},
+	 "LastDeploy": {"Timestamp": "2017-05-10T12:00:00Z", "Error": ""}},
+	{"Name": "app2", "Pool": "pool1", "Units": {}, "Quota": {"Limit": 2, "InUse": 0}}
+],
+"Events": [
+	{"ID": "abc", "Target": {"Type": "app", "Value": "app1"}, "Kind": "app.update.restart", "Owner": "me@me.com", "StartTime": "2017-05-11T08:30:00Z"}
+]}`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/status"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := statusOverview{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	deployStr := deployTime.Local().Format(time.RFC822) + " (" + ColorStatus("succeeded", true) + ")"
+	eventStr := eventTime.Local().Format(time.RFC822)
+	appsTable := NewTable()
+	appsTable.Headers = Row{"App", "Pool", "Units", "Unit quota", "Last deploy"}
+	appsTable.AddRow(Row{"app1", "pool1", "1 error, 2 started", "3/unlimited", deployStr})
+	appsTable.AddRow(Row{"app2", "pool1", "none", "0/2", "never"})
+	eventsTable := NewTable()
+	eventsTable.Headers = Row{"Started", "Target", "Kind", "Owner"}
+	eventsTable.AddRow(Row{eventStr, "app: app1", "app.update.restart", "me@me.com"})
+	expected := "API: " + ColorStatus("healthy", true) + "\nApp quota: 2/5\n\nApps:\n" + appsTable.String() +
+		"\nRunning events:\n" + eventsTable.String()
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestStatusRunTruncatedApps(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `{"API": {"Healthy": true}, "Quota": {"Limit": -1, "InUse": 3}, "TotalApps": 3,
+"Apps": [{"Name": "app1", "Pool": "pool1", "Units": {}, "Quota": {"Limit": -1, "InUse": 0}}]}`,
+		Status: http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := statusOverview{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	appsTable := NewTable()
+	appsTable.Headers = Row{"App", "Pool", "Units", "Unit quota", "Last deploy"}
+	appsTable.AddRow(Row{"app1", "pool1", "none", "0/unlimited", "never"})
+	expected := "API: " + ColorStatus("healthy", true) + "\nApp quota: 3/unlimited\n\nApps:\n" + appsTable.String() +
+		"Showing 1 of 3 apps, use app-list to list all of them.\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestStatusRunUnhealthy(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `{"API": {"Healthy": false, "Checks": [{"Name": "MongoDB", "Status": "WORKING"}, {"Name": "Router", "Status": "fail - timeout"}]},
+"Quota": {"Limit": -1, "InUse": 0}}`,
+		Status: http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := statusOverview{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "API: " + ColorStatus("unhealthy", false) + "\n\tMongoDB: " + ColorStatus("WORKING", true) +
+		"\n\tRouter: " + ColorStatus("fail - timeout", false) + "\nApp quota: 0/unlimited\n"
+	c.Assert(stdout.String(), check.Equals, expected)
+}
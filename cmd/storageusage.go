@@ -0,0 +1,153 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type storageUsageReport struct {
+	Time        time.Time
+	Collections []struct {
+		Name        string
+		Count       int64
+		Size        int64
+		StorageSize int64
+		IndexSize   int64
+	}
+	Events struct {
+		LastDay      int
+		DailyAverage float64
+		BytesPerDay  float64
+		Anomalous    bool
+	}
+	AppLogs []struct {
+		App         string
+		Count       int64
+		Size        int64
+		StorageSize int64
+	}
+	Disk *struct {
+		Used       int64
+		Total      int64
+		Thresholds []struct {
+			Percent     float64
+			Reached     bool
+			ProjectedAt *time.Time
+		}
+	}
+}
+
+type storageUsage struct {
+	fs   *gnuflag.FlagSet
+	apps int
+}
+
+func (c *storageUsage) Info() *Info {
+	return &Info{
+		Name:  "storage-usage",
+		Usage: "storage-usage [--apps <number>]",
+		Desc: `Displays the storage used by tsuru in its database: the size of each
+collection, the growth of the events collection, the apps with the biggest
+logs and, when available, when the disk of the database is projected to reach
+the configured usage thresholds.`,
+	}
+}
+
+func (c *storageUsage) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("storage-usage", gnuflag.ExitOnError)
+		desc := "Number of apps with the biggest logs to display"
+		c.fs.IntVar(&c.apps, "apps", 0, desc)
+		c.fs.IntVar(&c.apps, "a", 0, desc)
+	}
+	return c.fs
+}
+
+func (c *storageUsage) Run(context *Context, client *Client) error {
+	path := "/storage/usage"
+	if c.apps > 0 {
+		path += "?apps=" + strconv.Itoa(c.apps)
+	}
+	u, err := GetURLVersion("1.3", path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var report storageUsageReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(report)
+	}
+	fmt.Fprintf(context.Stdout, "Events: %d in the last day, daily average of %.1f (%s per day)\n",
+		report.Events.LastDay, report.Events.DailyAverage, formatBytes(int64(report.Events.BytesPerDay)))
+	if report.Events.Anomalous {
+		fmt.Fprintln(context.Stdout, "WARNING: the events collection is growing faster than usual.")
+	}
+	if report.Disk != nil {
+		fmt.Fprintf(context.Stdout, "Disk: %s of %s used (%.1f%%)\n", formatBytes(report.Disk.Used),
+			formatBytes(report.Disk.Total), float64(report.Disk.Used)*100/float64(report.Disk.Total))
+		table := NewTable()
+		table.Headers = Row{"Threshold", "Projected"}
+		for _, t := range report.Disk.Thresholds {
+			projected := "not growing"
+			if t.Reached {
+				projected = "reached"
+			} else if t.ProjectedAt != nil {
+				projected = t.ProjectedAt.Local().Format(time.RFC822)
+			}
+			table.AddRow(Row{strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%", projected})
+		}
+		fmt.Fprintf(context.Stdout, "\nDisk thresholds:\n%s", table.String())
+	}
+	if len(report.Collections) > 0 {
+		table := NewTable()
+		table.Headers = Row{"Collection", "Documents", "Size", "Storage size", "Index size"}
+		for _, coll := range report.Collections {
+			table.AddRow(Row{coll.Name, strconv.FormatInt(coll.Count, 10), formatBytes(coll.Size),
+				formatBytes(coll.StorageSize), formatBytes(coll.IndexSize)})
+		}
+		fmt.Fprintf(context.Stdout, "\nCollections:\n%s", table.String())
+	}
+	if len(report.AppLogs) > 0 {
+		table := NewTable()
+		table.Headers = Row{"App", "Log messages", "Size"}
+		for _, logs := range report.AppLogs {
+			table.AddRow(Row{logs.App, strconv.FormatInt(logs.Count, 10), formatBytes(logs.Size)})
+		}
+		fmt.Fprintf(context.Stdout, "\nBiggest app logs:\n%s", table.String())
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestStorageUsageInfo(c *check.C) {
+	c.Assert((&storageUsage{}).Info(), check.NotNil)
+}
+
+func (s *S) TestStorageUsageRun(c *check.C) {
+	projected := time.Date(2017, 9, 1, 12, 0, 0, 0, time.UTC)
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{
+"Collections": [
+	{"Name": "events", "Count": 1200, "Size": 3145728, "StorageSize": 4194304, "IndexSize": 512},
+	{"Name": "apps", "Count": 2, "Size": 900, "StorageSize": 16384, "IndexSize": 8192}
+],
+"Events": {"LastDay": 600, "DailyAverage": 85.7, "BytesPerDay": 2048, "Anomalous": true},
+"AppLogs": [{"App": "app1", "Count": 5000, "Size": 1048576, "StorageSize": 2097152}],
+"Disk": {"Used": 850, "Total": 1000, "Thresholds": [
+	{"Percent": 80, "Reached": true},
+	{"Percent": 90, "Reached": false, "ProjectedAt": "2017-09-01T12:00:00Z"},
+	{"Percent": 97.5, "Reached": false}
+]}}`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/storage/usage" && req.URL.Query().Get("apps") == "1"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := storageUsage{}
+	command.Flags().Parse(true, []string{"-a", "1"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	thresholdsTable := NewTable()
+	thresholdsTable.Headers = Row{"Threshold", "Projected"}
+	thresholdsTable.AddRow(Row{"80%", "reached"})
+	thresholdsTable.AddRow(Row{"90%", projected.Local().Format(time.RFC822)})
+	thresholdsTable.AddRow(Row{"97.5%", "not growing"})
+	collectionsTable := NewTable()
+	collectionsTable.Headers = Row{"Collection", "Documents", "Size", "Storage size", "Index size"}
+	collectionsTable.AddRow(Row{"events", "1200", "3.0 MiB", "4.0 MiB", "512 B"})
+	collectionsTable.AddRow(Row{"apps", "2", "900 B", "16.0 KiB", "8.0 KiB"})
+	logsTable := NewTable()
+	logsTable.Headers = Row{"App", "Log messages", "Size"}
+	logsTable.AddRow(Row{"app1", "5000", "1.0 MiB"})
+	expected := "Events: 600 in the last day, daily average of 85.7 (2.0 KiB per day)\n" +
+		"WARNING: the events collection is growing faster than usual.\n" +
+		"Disk: 850 B of 1000 B used (85.0%)\n" +
+		"\nDisk thresholds:\n" + thresholdsTable.String() +
+		"\nCollections:\n" + collectionsTable.String() +
+		"\nBiggest app logs:\n" + logsTable.String()
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestFormatBytes(c *check.C) {
+	c.Assert(formatBytes(0), check.Equals, "0 B")
+	c.Assert(formatBytes(1023), check.Equals, "1023 B")
+	c.Assert(formatBytes(1536), check.Equals, "1.5 KiB")
+	c.Assert(formatBytes(5*1024*1024*1024), check.Equals, "5.0 GiB")
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"regexp"
 	"sort"
@@ -92,9 +93,56 @@ func (t *targetSlice) String() string {
 	return strings.Join(values, "\n")
 }
 
-// ReadTarget returns the current target, as defined in the TSURU_TARGET
-// environment variable or in the target file.
+type targetItem struct {
+	Label   string `json:"label"`
+	URL     string `json:"url"`
+	Current bool   `json:"current"`
+}
+
+func (t *targetSlice) list() []targetItem {
+	if !t.sorted {
+		t.Sort()
+	}
+	items := make([]targetItem, len(t.targets))
+	for i, target := range t.targets {
+		items[i] = targetItem{Label: target.label, URL: target.url, Current: t.current == i}
+	}
+	return items
+}
+
+// targetOverride is the target selected with the --target flag, it takes
+// precedence over the TSURU_TARGET environment variable and the target file.
+var targetOverride string
+
+// setTargetOverride selects the target of the running command, given either
+// the label of a target in the target list or the address of a target.
+func setTargetOverride(target string) error {
+	targetOverride = ""
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil
+	}
+	targets, err := getTargets()
+	if err != nil {
+		return err
+	}
+	if address, ok := targets[target]; ok {
+		targetOverride = address
+		return nil
+	}
+	if !strings.ContainsAny(target, ".:/") && target != "localhost" {
+		return errors.Errorf("target %q not found in the target list", target)
+	}
+	targetOverride = target
+	return nil
+}
+
+// ReadTarget returns the current target, as defined by the --target flag, in
+// the TSURU_TARGET environment variable or in the target file.
 func ReadTarget() (string, error) {
+	if targetOverride != "" {
+		return targetOverride, nil
+	}
 	if target := os.Getenv("TSURU_TARGET"); target != "" {
 		return target, nil
 	}
@@ -133,6 +181,37 @@ func GetTarget() (string, error) {
 	return prefix + target, nil
 }
 
+func normalizeTarget(target string) string {
+	target = strings.TrimRight(strings.TrimSpace(target), "/")
+	if m, _ := regexp.MatchString("^https?://", target); !m {
+		target = "http://" + target
+	}
+	return target
+}
+
+// targetLabel returns the label of the target in the target list, or an
+// empty string if the target is not in the list.
+func targetLabel(target string) string {
+	targets, err := getTargets()
+	if err != nil {
+		return ""
+	}
+	target = normalizeTarget(target)
+	var found string
+	for label, address := range targets {
+		if normalizeTarget(address) == target && (found == "" || label < found) {
+			found = label
+		}
+	}
+	return found
+}
+
+// targetTokenPath returns the path of the file storing the token of the
+// target with the given label.
+func targetTokenPath(label string) string {
+	return JoinWithUserDir(".tsuru", "token.d", url.PathEscape(label))
+}
+
 func GetTargetLabel() (string, error) {
 	target, err := GetTarget()
 	if err != nil {
@@ -345,6 +424,9 @@ func (t *targetList) Run(ctx *Context, client *Client) error {
 	if current, err := ReadTarget(); err == nil {
 		slice.setCurrent(current)
 	}
+	if ctx.Structured() {
+		return ctx.Render(slice.list())
+	}
 	fmt.Fprintf(ctx.Stdout, "%v\n", slice)
 	return nil
 }
@@ -379,6 +461,8 @@ func (t *targetRemove) Run(ctx *Context, client *Client) error {
 		}
 	}
 	if turl != "" {
+		filesystem().Remove(targetTokenPath(targetLabelToRemove))
+		filesystem().Remove(targetTokenPath(targetLabelToRemove) + tokenExpirySuffix)
 		var current string
 		if current, err = ReadTarget(); err == nil && current == turl {
 			deleteTargetFile()
@@ -92,6 +92,31 @@ func (s *S) TestReadTargetEnvironmentVariable(c *check.C) {
 	c.Assert(target, check.Equals, "https://tsuru.google.com")
 }
 
+func (s *S) TestReadTargetOverride(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+		targetOverride = ""
+	}()
+	err := WriteOnTargetList("prod", "https://prod.tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = setTargetOverride("prod")
+	c.Assert(err, check.IsNil)
+	target, err := ReadTarget()
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "https://prod.tsuru.io")
+	err = setTargetOverride("tsuru.example.com:8080")
+	c.Assert(err, check.IsNil)
+	target, err = ReadTarget()
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "tsuru.example.com:8080")
+	err = setTargetOverride("staging")
+	c.Assert(err, check.ErrorMatches, `target "staging" not found in the target list`)
+	target, err = ReadTarget()
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "http://localhost")
+}
+
 func (s *S) TestReadTargetReturnsEmptyStringIfTheFileDoesNotExist(c *check.C) {
 	os.Unsetenv("TSURU_TARGET")
 	fsystem = &fstest.FileNotFoundFs{}
@@ -265,7 +290,7 @@ func (s *S) TestTargetAddRun(c *check.C) {
 	defer func() {
 		fsystem = nil
 	}()
-	context := &Context{[]string{"default", "http://tsuru.google.com"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"default", "http://tsuru.google.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	targetAdd := &targetAdd{}
 	err := targetAdd.Run(context, nil)
 	c.Assert(err, check.IsNil)
@@ -278,7 +303,7 @@ func (s *S) TestTargetAddRunOnlyOneArg(c *check.C) {
 	defer func() {
 		fsystem = nil
 	}()
-	context := &Context{[]string{"default http://tsuru.google.com"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"default http://tsuru.google.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	targetAdd := &targetAdd{}
 	err := targetAdd.Run(context, nil)
 	c.Assert(err, check.NotNil)
@@ -292,7 +317,7 @@ func (s *S) TestTargetAddWithSet(c *check.C) {
 	defer func() {
 		fsystem = nil
 	}()
-	context := &Context{[]string{"default", "http://tsuru.google.com"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"default", "http://tsuru.google.com"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	targetAdd := &targetAdd{}
 	targetAdd.Flags().Parse(true, []string{"-s"})
 	err := targetAdd.Run(context, nil)
@@ -424,13 +449,56 @@ other	http://other.tsuru.io`
 * first (http://tsuru.io)
   other (http://other.tsuru.io)` + "\n"
 	target := &targetList{}
-	context := &Context{[]string{""}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{""}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	err := target.Run(context, nil)
 	c.Assert(err, check.IsNil)
 	got := context.Stdout.(*bytes.Buffer).String()
 	c.Assert(got, check.Equals, expected)
 }
 
+func (s *S) TestTargetRunStructured(c *check.C) {
+	os.Unsetenv("TSURU_TARGET")
+	rfs := &fstest.RecordingFs{}
+	f, _ := rfs.Create(JoinWithUserDir(".tsuru", "target"))
+	f.Write([]byte("http://tsuru.io"))
+	f.Close()
+	f, _ = rfs.Create(JoinWithUserDir(".tsuru", "targets"))
+	f.Write([]byte("first\thttp://tsuru.io\ndefault\thttp://tsuru.google.com"))
+	f.Close()
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout bytes.Buffer
+	context := &Context{Stdout: &stdout, Format: FormatJSON}
+	err := (&targetList{}).Run(context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `[
+  {
+    "label": "default",
+    "url": "http://tsuru.google.com",
+    "current": false
+  },
+  {
+    "label": "first",
+    "url": "http://tsuru.io",
+    "current": true
+  }
+]
+`)
+	stdout.Reset()
+	context.Format = FormatYAML
+	err = (&targetList{}).Run(context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `- current: false
+  label: default
+  url: http://tsuru.google.com
+- current: true
+  label: first
+  url: http://tsuru.io
+`)
+}
+
 func (s *S) TestResetTargetList(c *check.C) {
 	rfs := &fstest.RecordingFs{FileContent: "first\thttp://tsuru.io/\ndefault\thttp://tsuru.google.com"}
 	fsystem = rfs
@@ -484,7 +552,7 @@ func (s *S) TestTargetRemove(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(got, check.HasLen, len(expectedBefore))
 	targetRemove := &targetRemove{}
-	context := &Context{[]string{"first"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"first"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	err = targetRemove.Run(context, nil)
 	c.Assert(err, check.IsNil)
 	got, err = getTargets()
@@ -510,7 +578,7 @@ func (s *S) TestTargetRemoveCurrentTarget(c *check.C) {
 		fsystem = nil
 	}()
 	targetRemove := &targetRemove{}
-	context := &Context{[]string{"default"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"default"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	err := targetRemove.Run(context, nil)
 	c.Assert(err, check.IsNil)
 	_, err = ReadTarget()
@@ -537,7 +605,7 @@ func (s *S) TestTargetSetRun(c *check.C) {
 		fsystem = nil
 	}()
 	targetSet := &targetSet{}
-	context := &Context{[]string{"default"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"default"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	err := targetSet.Run(context, nil)
 	c.Assert(err, check.IsNil)
 	got := context.Stdout.(*bytes.Buffer).String()
@@ -551,7 +619,7 @@ func (s *S) TestTargetSetRunUnknowTarget(c *check.C) {
 		fsystem = nil
 	}()
 	targetSet := &targetSet{}
-	context := &Context{[]string{"doesnotexist"}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	context := &Context{Args: []string{"doesnotexist"}, Stdout: globalManager.stdout, Stderr: globalManager.stderr, Stdin: globalManager.stdin}
 	err := targetSet.Run(context, nil)
 	c.Assert(err, check.ErrorMatches, "Target not found")
 }
@@ -0,0 +1,388 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type apiTeam struct {
+	Name   string `json:"name"`
+	Parent string `json:"parent,omitempty"`
+	Users  []struct {
+		Email string   `json:"email"`
+		Roles []string `json:"roles"`
+	} `json:"users"`
+	Apps []struct {
+		Name      string `json:"name"`
+		TeamOwner string `json:"teamowner"`
+		Pool      string `json:"pool"`
+	} `json:"apps"`
+	Pools []string `json:"pools"`
+	Roles []struct {
+		Name        string   `json:"name"`
+		Context     string   `json:"context"`
+		SchemeNames []string `json:"scheme_names,omitempty"`
+	} `json:"roles"`
+}
+
+type teamInfo struct{}
+
+func (teamInfo) Info() *Info {
+	return &Info{
+		Name:  "team-info",
+		Usage: "team-info <team>",
+		Desc: `Displays information about a team: its members and the roles granted to them
+in the team, the apps the team has access to and the pools the team can use.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (teamInfo) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/teams/"+url.PathEscape(context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var team apiTeam
+	err = json.NewDecoder(resp.Body).Decode(&team)
+	if err != nil {
+		return err
+	}
+	if context.Structured() {
+		return context.Render(team)
+	}
+	fmt.Fprintf(context.Stdout, "Team: %s\n", team.Name)
+	if team.Parent != "" {
+		fmt.Fprintf(context.Stdout, "Parent: %s\n", team.Parent)
+	}
+	if len(team.Pools) > 0 {
+		fmt.Fprintf(context.Stdout, "Pools: %s\n", strings.Join(team.Pools, ", "))
+	}
+	if len(team.Users) > 0 {
+		table := NewTable()
+		table.Headers = Row{"User", "Roles"}
+		for _, member := range team.Users {
+			table.AddRow(Row{member.Email, strings.Join(member.Roles, "\n")})
+		}
+		table.LineSeparator = true
+		fmt.Fprintf(context.Stdout, "\nMembers:\n%s", table.String())
+	}
+	if len(team.Roles) > 0 {
+		table := NewTable()
+		table.Headers = Row{"Role", "Permissions"}
+		for _, r := range team.Roles {
+			table.AddRow(Row{r.Name, strings.Join(r.SchemeNames, "\n")})
+		}
+		table.LineSeparator = true
+		fmt.Fprintf(context.Stdout, "\nRoles:\n%s", table.String())
+	}
+	if len(team.Apps) > 0 {
+		table := NewTable()
+		table.Headers = Row{"App", "Team owner", "Pool"}
+		for _, a := range team.Apps {
+			table.AddRow(Row{a.Name, a.TeamOwner, a.Pool})
+		}
+		fmt.Fprintf(context.Stdout, "\nApps:\n%s", table.String())
+	}
+	return nil
+}
+
+type teamRemove struct {
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *teamRemove) Info() *Info {
+	return &Info{
+		Name:  "team-remove",
+		Usage: "team-remove <team> [-f/--force]",
+		Desc: `Removes a team from tsuru. Teams with access to apps or service instances
+can't be removed, the references to the team are displayed so they can be
+moved to other teams before removing it.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *teamRemove) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = forceFlags("team-remove", &c.yes)
+	}
+	return c.fs
+}
+
+func (c *teamRemove) Run(context *Context, client *Client) error {
+	team := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to remove the team %q?", team)) {
+		return nil
+	}
+	u, err := GetURL("/teams/" + url.PathEscape(team))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Team %q successfully removed.\n", team)
+	return nil
+}
+
+type teamRename struct{}
+
+func (teamRename) Info() *Info {
+	return &Info{
+		Name:  "team-rename",
+		Usage: "team-rename <team> <new-name>",
+		Desc: `Renames a team. The apps, service instances, pools, events and roles
+referencing the team are updated to use the new name.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (teamRename) Run(context *Context, client *Client) error {
+	team, newName := context.Args[0], context.Args[1]
+	u, err := GetURLVersion("1.3", "/teams/"+url.PathEscape(team))
+	if err != nil {
+		return err
+	}
+	body := strings.NewReader(url.Values{"newname": []string{newName}}.Encode())
+	request, err := http.NewRequest("PUT", u, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Team %q successfully renamed to %q.\n", team, newName)
+	return nil
+}
+
+type teamParentSet struct{}
+
+func (teamParentSet) Info() *Info {
+	return &Info{
+		Name:  "team-parent-set",
+		Usage: "team-parent-set <team> [parent]",
+		Desc: `Sets the parent of a team. Roles granted in the context of the parent team
+also apply to the child team, its apps and service instances. Omitting the
+parent makes the team a root team again.`,
+		MinArgs: 1,
+		MaxArgs: 2,
+	}
+}
+
+func (teamParentSet) Run(context *Context, client *Client) error {
+	team := context.Args[0]
+	var parent string
+	if len(context.Args) > 1 {
+		parent = context.Args[1]
+	}
+	u, err := GetURLVersion("1.3", "/teams/"+url.PathEscape(team)+"/parent")
+	if err != nil {
+		return err
+	}
+	body := strings.NewReader(url.Values{"parent": []string{parent}}.Encode())
+	request, err := http.NewRequest("PUT", u, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if parent == "" {
+		fmt.Fprintf(context.Stdout, "Team %q is now a root team.\n", team)
+	} else {
+		fmt.Fprintf(context.Stdout, "Team %q is now a child of %q.\n", team, parent)
+	}
+	return nil
+}
+
+type teamNode struct {
+	Name     string     `json:"name"`
+	Children []teamNode `json:"children,omitempty"`
+}
+
+type teamTree struct{}
+
+func (teamTree) Info() *Info {
+	return &Info{
+		Name:  "team-tree",
+		Usage: "team-tree",
+		Desc:  "Displays the hierarchy of the teams the user has access to.",
+	}
+}
+
+func (teamTree) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/teams/tree")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var tree []teamNode
+	if resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(&tree)
+		if err != nil {
+			return err
+		}
+	}
+	if context.Structured() {
+		if tree == nil {
+			tree = []teamNode{}
+		}
+		return context.Render(tree)
+	}
+	var printNodes func(nodes []teamNode, indent string)
+	printNodes = func(nodes []teamNode, indent string) {
+		for _, node := range nodes {
+			fmt.Fprintf(context.Stdout, "%s%s\n", indent, node.Name)
+			printNodes(node.Children, indent+"  ")
+		}
+	}
+	printNodes(tree, "")
+	return nil
+}
+
+type teamImport struct{}
+
+func (teamImport) Info() *Info {
+	return &Info{
+		Name:  "team-import",
+		Usage: "team-import <team> <file.csv>",
+		Desc: `Adds many users to a team at once, assigning to each of them a role in the
+team. Each line of the CSV file must have the email of the user and the name of
+the team role to assign, e.g.:
+
+    email,role
+    user1@example.com,team-member
+    user2@example.com,team-admin
+
+The header line is optional and the file can be generated by team-export. The
+import is all or nothing: when any of the lines fails, no roles are assigned
+and the failure of each line is displayed.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (teamImport) Run(context *Context, client *Client) error {
+	team, path := context.Args[0], context.Args[1]
+	file, err := filesystem().Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	u, err := GetURLVersion("1.3", "/teams/"+url.PathEscape(team)+"/users")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, file)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/csv")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Imported bool `json:"imported"`
+		Users    []struct {
+			Line  int    `json:"line"`
+			Email string `json:"email"`
+			Role  string `json:"role"`
+			Error string `json:"error"`
+		} `json:"users"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+	table := NewTable()
+	table.Headers = Row{"Line", "User", "Role", "Status"}
+	for _, item := range result.Users {
+		status := "imported"
+		if item.Error != "" {
+			status = item.Error
+		}
+		table.AddRow(Row{strconv.Itoa(item.Line), item.Email, item.Role, status})
+	}
+	context.Stdout.Write(table.Bytes())
+	if !result.Imported {
+		return fmt.Errorf("no users were imported to team %q", team)
+	}
+	fmt.Fprintf(context.Stdout, "Users successfully imported to team %q.\n", team)
+	return nil
+}
+
+type teamExport struct{}
+
+func (teamExport) Info() *Info {
+	return &Info{
+		Name:  "team-export",
+		Usage: "team-export <team>",
+		Desc: `Writes to the standard output a CSV file with the members of a team and the
+roles granted to them in the team, which can be imported with team-import.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (teamExport) Run(context *Context, client *Client) error {
+	u, err := GetURLVersion("1.3", "/teams/"+url.PathEscape(context.Args[0])+"/users")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(context.Stdout, resp.Body)
+	return err
+}
@@ -0,0 +1,292 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+const teamInfoResponse = `{
+"name": "myteam",
+"users": [
+	{"email": "admin@example.com", "roles": ["team-admin", "team-member"]},
+	{"email": "dev@example.com", "roles": ["team-member"]}
+],
+"apps": [{"name": "app1", "teamowner": "myteam", "pool": "pool1"}],
+"pools": ["pool1", "pool2"],
+"roles": [
+	{"name": "team-admin", "context": "team", "scheme_names": ["team"]},
+	{"name": "team-member", "context": "team", "scheme_names": ["app.read", "app.deploy"]}
+]}`
+
+func (s *S) TestTeamInfoInfo(c *check.C) {
+	c.Assert(teamInfo{}.Info(), check.NotNil)
+}
+
+func (s *S) TestTeamInfoRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: teamInfoResponse, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/teams/myteam"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamInfo{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	membersTable := NewTable()
+	membersTable.Headers = Row{"User", "Roles"}
+	membersTable.AddRow(Row{"admin@example.com", "team-admin\nteam-member"})
+	membersTable.AddRow(Row{"dev@example.com", "team-member"})
+	membersTable.LineSeparator = true
+	rolesTable := NewTable()
+	rolesTable.Headers = Row{"Role", "Permissions"}
+	rolesTable.AddRow(Row{"team-admin", "team"})
+	rolesTable.AddRow(Row{"team-member", "app.read\napp.deploy"})
+	rolesTable.LineSeparator = true
+	appsTable := NewTable()
+	appsTable.Headers = Row{"App", "Team owner", "Pool"}
+	appsTable.AddRow(Row{"app1", "myteam", "pool1"})
+	expected := "Team: myteam\nPools: pool1, pool2\n" +
+		"\nMembers:\n" + membersTable.String() +
+		"\nRoles:\n" + rolesTable.String() +
+		"\nApps:\n" + appsTable.String()
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestTeamInfoRunEmptyTeam(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `{"name": "myteam", "users": [], "apps": [], "pools": [], "roles": []}`,
+		Status:  http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamInfo{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Team: myteam\n")
+}
+
+func (s *S) TestTeamInfoRunWithParent(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `{"name": "myteam", "parent": "org", "users": [], "apps": [], "pools": [], "roles": []}`,
+		Status:  http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamInfo{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Team: myteam\nParent: org\n")
+}
+
+func (s *S) TestTeamParentSetRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"squad", "org"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/teams/squad/parent" &&
+				req.Form.Get("parent") == "org"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamParentSet{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Team \"squad\" is now a child of \"org\".\n")
+}
+
+func (s *S) TestTeamParentSetRunRemovingParent(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"squad"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			_, ok := req.Form["parent"]
+			return req.Method == "PUT" && req.URL.Path == "/1.3/teams/squad/parent" &&
+				ok && req.Form.Get("parent") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamParentSet{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Team \"squad\" is now a root team.\n")
+}
+
+func (s *S) TestTeamTreeRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"name": "org", "children": [{"name": "squad1", "children": [{"name": "tribe"}]}, {"name": "squad2"}]}, {"name": "other"}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/teams/tree"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamTree{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "org\n  squad1\n    tribe\n  squad2\nother\n")
+}
+
+func (s *S) TestTeamTreeRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Format: FormatJSON}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusNoContent}}, nil, globalManager)
+	err := teamTree{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestTeamRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.0/teams/myteam"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := teamRemove{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to remove the team "myteam"? (y/n) Team "myteam" successfully removed.`+"\n")
+}
+
+func (s *S) TestTeamRemoveRunStillUsed(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: "This team cannot be removed because there are still references to it:\nApps: app1",
+		Status:  http.StatusForbidden,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := teamRemove{}
+	err := command.Flags().Parse(true, []string{"-f"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "(?s).*still references to it:\nApps: app1")
+	c.Assert(stdout.String(), check.Equals, "")
+}
+
+func (s *S) TestTeamRemoveRunAbort(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("n\n")}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusInternalServerError}}, nil, globalManager)
+	command := teamRemove{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Are you sure you want to remove the team "myteam"? (y/n) Abort.`+"\n")
+}
+
+func (s *S) TestTeamRenameRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam", "newteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "PUT" && req.URL.Path == "/1.3/teams/myteam" &&
+				req.Form.Get("newname") == "newteam"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamRename{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `Team "myteam" successfully renamed to "newteam".`+"\n")
+}
+
+func (s *S) TestTeamImportRun(c *check.C) {
+	dir, err := ioutil.TempDir("", "team-import")
+	c.Assert(err, check.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.csv")
+	csv := "email,role\nchico@tsuru.io,team-member\n"
+	err = ioutil.WriteFile(path, []byte(csv), 0600)
+	c.Assert(err, check.IsNil)
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam", path}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"imported": true, "users": [{"line": 2, "email": "chico@tsuru.io", "role": "team-member"}]}`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			body, _ := ioutil.ReadAll(req.Body)
+			return req.Method == "POST" && req.URL.Path == "/1.3/teams/myteam/users" &&
+				req.Header.Get("Content-Type") == "text/csv" && string(body) == csv
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err = teamImport{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	table := NewTable()
+	table.Headers = Row{"Line", "User", "Role", "Status"}
+	table.AddRow(Row{"2", "chico@tsuru.io", "team-member", "imported"})
+	c.Assert(stdout.String(), check.Equals, table.String()+`Users successfully imported to team "myteam".`+"\n")
+}
+
+func (s *S) TestTeamImportRunFailure(c *check.C) {
+	dir, err := ioutil.TempDir("", "team-import")
+	c.Assert(err, check.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.csv")
+	err = ioutil.WriteFile(path, []byte("chico@tsuru.io,team-member\nzeca@tsuru.io,team-member\n"), 0600)
+	c.Assert(err, check.IsNil)
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam", path}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `{"imported": false, "users": [
+	{"line": 1, "email": "chico@tsuru.io", "role": "team-member", "error": "not imported due to other failures"},
+	{"line": 2, "email": "zeca@tsuru.io", "role": "team-member", "error": "user not found"}
+]}`,
+		Status: http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err = teamImport{}.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, `no users were imported to team "myteam"`)
+	table := NewTable()
+	table.Headers = Row{"Line", "User", "Role", "Status"}
+	table.AddRow(Row{"1", "chico@tsuru.io", "team-member", "not imported due to other failures"})
+	table.AddRow(Row{"2", "zeca@tsuru.io", "team-member", "user not found"})
+	c.Assert(stdout.String(), check.Equals, table.String())
+}
+
+func (s *S) TestTeamImportRunFileNotFound(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam", "/tmp/does-not-exist/users.csv"}, Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &cmdtest.Transport{Status: http.StatusInternalServerError}}, nil, globalManager)
+	err := teamImport{}.Run(&context, client)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *S) TestTeamExportRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"myteam"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "email,role\nchico@tsuru.io,team-member\n", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.3/teams/myteam/users"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := teamExport{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "email,role\nchico@tsuru.io,team-member\n")
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+)
+
+// telemetryClient sends the usage reports, with a short timeout so an
+// unreachable endpoint never holds the command for long.
+var telemetryClient = &http.Client{Timeout: 2 * time.Second}
+
+// telemetrySettings are the telemetry preferences of the user, stored in
+// ~/.tsuru/telemetry. InstallID is a random identifier, it's the only
+// information tying reports from the same installation together.
+type telemetrySettings struct {
+	Enabled   bool
+	Endpoint  string
+	InstallID string
+}
+
+// telemetryReport is the anonymized report sent after each command: it
+// never includes arguments, flags, targets or user information.
+type telemetryReport struct {
+	InstallID  string
+	Command    string
+	Version    string
+	OS         string
+	Arch       string
+	Duration   float64
+	ExitCode   int
+	ErrorClass string `json:",omitempty"`
+}
+
+func telemetryPath() string {
+	return JoinWithUserDir(".tsuru", "telemetry")
+}
+
+func readTelemetrySettings() (telemetrySettings, error) {
+	var settings telemetrySettings
+	f, err := filesystem().Open(telemetryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return settings, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return settings, err
+	}
+	err = json.Unmarshal(data, &settings)
+	return settings, err
+}
+
+func writeTelemetrySettings(settings telemetrySettings) error {
+	err := filesystem().MkdirAll(JoinWithUserDir(".tsuru"), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := filesystem().OpenFile(telemetryPath(), syscall.O_WRONLY|syscall.O_CREAT|syscall.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(settings)
+}
+
+// errorClass returns the class of the error returned by a command, without
+// any of its details.
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if err == ErrAbortCommand {
+		return "aborted"
+	}
+	if httpErr, ok := errors.Cause(err).(*tsuruErrors.HTTP); ok {
+		return httpErr.Payload().Code
+	}
+	return "client"
+}
+
+// reportTelemetry sends the usage report of a command when the user opted
+// in. Any failure is ignored, telemetry is never worth interrupting the
+// command for. Setting the TSURU_DISABLE_TELEMETRY environment variable
+// disables it.
+func (m *Manager) reportTelemetry(name string, duration time.Duration, status int, err error) {
+	if os.Getenv("TSURU_DISABLE_TELEMETRY") != "" {
+		return
+	}
+	settings, readErr := readTelemetrySettings()
+	if readErr != nil || !settings.Enabled || settings.Endpoint == "" {
+		return
+	}
+	data, marshalErr := json.Marshal(telemetryReport{
+		InstallID:  settings.InstallID,
+		Command:    name,
+		Version:    m.version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Duration:   duration.Seconds(),
+		ExitCode:   status,
+		ErrorClass: errorClass(err),
+	})
+	if marshalErr != nil {
+		return
+	}
+	request, reqErr := http.NewRequest("POST", settings.Endpoint, bytes.NewReader(data))
+	if reqErr != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, reqErr := telemetryClient.Do(request)
+	if reqErr != nil {
+		return
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+}
+
+type telemetry struct {
+	fs       *gnuflag.FlagSet
+	endpoint string
+}
+
+func (c *telemetry) Info() *Info {
+	return &Info{
+		Name:  "telemetry",
+		Usage: "telemetry [on|off] [--endpoint <url>]",
+		Desc: `Enables, disables or shows the status of the anonymous usage reports of the
+client. Telemetry is disabled unless explicitly enabled.
+
+When enabled, the client reports the name of each executed command, its
+duration, exit code and the class of its failure, along with the client
+version, the operating system and a random installation identifier. Command
+arguments, flags, targets and user information are never reported.
+
+The reports are sent to the given endpoint, which is required the first time
+telemetry is enabled. Setting the TSURU_DISABLE_TELEMETRY environment variable
+disables the reports regardless of this setting.`,
+		MaxArgs: 1,
+	}
+}
+
+func (c *telemetry) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("telemetry", gnuflag.ExitOnError)
+		c.fs.StringVar(&c.endpoint, "endpoint", "", "URL receiving the usage reports")
+	}
+	return c.fs
+}
+
+func (c *telemetry) Run(context *Context, client *Client) error {
+	settings, err := readTelemetrySettings()
+	if err != nil {
+		return err
+	}
+	if len(context.Args) == 0 {
+		if !settings.Enabled {
+			fmt.Fprintln(context.Stdout, "Telemetry is disabled.")
+			return nil
+		}
+		fmt.Fprintf(context.Stdout, "Telemetry is enabled, reporting to %s.\n", settings.Endpoint)
+		return nil
+	}
+	switch context.Args[0] {
+	case "on":
+		if c.endpoint != "" {
+			u, parseErr := url.Parse(c.endpoint)
+			if parseErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.Errorf("invalid telemetry endpoint %q, it must be an http or https URL", c.endpoint)
+			}
+			settings.Endpoint = c.endpoint
+		}
+		if settings.Endpoint == "" {
+			return errors.New("the telemetry endpoint is required, use the --endpoint flag")
+		}
+		if settings.InstallID == "" {
+			id := make([]byte, 16)
+			_, err = rand.Read(id)
+			if err != nil {
+				return err
+			}
+			settings.InstallID = hex.EncodeToString(id)
+		}
+		settings.Enabled = true
+	case "off":
+		settings.Enabled = false
+	default:
+		return errors.Errorf("invalid argument %q, use on or off", context.Args[0])
+	}
+	err = writeTelemetrySettings(settings)
+	if err != nil {
+		return err
+	}
+	if settings.Enabled {
+		fmt.Fprintf(context.Stdout, "Telemetry enabled, reporting to %s.\n", settings.Endpoint)
+	} else {
+		fmt.Fprintln(context.Stdout, "Telemetry disabled.")
+	}
+	return nil
+}
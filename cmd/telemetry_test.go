@@ -0,0 +1,203 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/fs/fstest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTelemetryIsRegisteredByBaseManager(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	c.Assert(mngr.Commands["telemetry"], check.FitsTypeOf, &telemetry{})
+	c.Assert(mngr.telemetry, check.Equals, true)
+}
+
+func (s *S) TestTelemetryInfo(c *check.C) {
+	c.Assert((&telemetry{}).Info(), check.NotNil)
+}
+
+func (s *S) TestTelemetryOn(c *check.C) {
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"on"}}
+	command := telemetry{}
+	err := command.Flags().Parse(true, []string{"--endpoint", "https://telemetry.example.com/reports"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Telemetry enabled, reporting to https://telemetry.example.com/reports.\n")
+	c.Assert(rfs.HasAction("openfile "+telemetryPath()+" with mode 0600"), check.Equals, true)
+	settings, err := readTelemetrySettings()
+	c.Assert(err, check.IsNil)
+	c.Assert(settings.Enabled, check.Equals, true)
+	c.Assert(settings.Endpoint, check.Equals, "https://telemetry.example.com/reports")
+	c.Assert(settings.InstallID, check.HasLen, 32)
+	installID := settings.InstallID
+	stdout.Reset()
+	context.Args = []string{"off"}
+	err = (&telemetry{}).Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Telemetry disabled.\n")
+	stdout.Reset()
+	context.Args = []string{"on"}
+	err = (&telemetry{}).Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	settings, err = readTelemetrySettings()
+	c.Assert(err, check.IsNil)
+	c.Assert(settings, check.DeepEquals, telemetrySettings{
+		Enabled:   true,
+		Endpoint:  "https://telemetry.example.com/reports",
+		InstallID: installID,
+	})
+}
+
+func (s *S) TestTelemetryOnWithoutEndpoint(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"on"}}
+	err := (&telemetry{}).Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the telemetry endpoint is required, use the --endpoint flag")
+}
+
+func (s *S) TestTelemetryOnInvalidEndpoint(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"on"}}
+	command := telemetry{}
+	err := command.Flags().Parse(true, []string{"--endpoint", "telemetry.example.com"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid telemetry endpoint "telemetry.example.com", it must be an http or https URL`)
+}
+
+func (s *S) TestTelemetryInvalidArgument(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr, Args: []string{"maybe"}}
+	err := (&telemetry{}).Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid argument "maybe", use on or off`)
+}
+
+func (s *S) TestTelemetryStatus(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	err := (&telemetry{}).Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Telemetry is disabled.\n")
+	err = writeTelemetrySettings(telemetrySettings{Enabled: true, Endpoint: "https://telemetry.example.com"})
+	c.Assert(err, check.IsNil)
+	stdout.Reset()
+	err = (&telemetry{}).Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Telemetry is enabled, reporting to https://telemetry.example.com.\n")
+}
+
+func (s *S) TestReportTelemetry(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var report telemetryReport
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&report)
+	}))
+	defer ts.Close()
+	err := writeTelemetrySettings(telemetrySettings{Enabled: true, Endpoint: ts.URL, InstallID: "abc123"})
+	c.Assert(err, check.IsNil)
+	var stdout, stderr bytes.Buffer
+	manager := NewManager("glb", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	httpErr := &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: "app myapp not found"}
+	manager.reportTelemetry("app-info", 1500*time.Millisecond, ExitCodeNotFound, httpErr)
+	c.Assert(contentType, check.Equals, "application/json")
+	c.Assert(report, check.DeepEquals, telemetryReport{
+		InstallID:  "abc123",
+		Command:    "app-info",
+		Version:    "1.0",
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Duration:   1.5,
+		ExitCode:   ExitCodeNotFound,
+		ErrorClass: tsuruErrors.CodeNotFound,
+	})
+	c.Assert(stdout.String(), check.Equals, "")
+	c.Assert(stderr.String(), check.Equals, "")
+}
+
+func (s *S) TestReportTelemetryDisabled(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer ts.Close()
+	manager := NewManager("glb", "1.0", "", &bytes.Buffer{}, &bytes.Buffer{}, os.Stdin, nil)
+	manager.reportTelemetry("app-list", time.Second, 0, nil)
+	err := writeTelemetrySettings(telemetrySettings{Enabled: false, Endpoint: ts.URL})
+	c.Assert(err, check.IsNil)
+	manager.reportTelemetry("app-list", time.Second, 0, nil)
+	err = writeTelemetrySettings(telemetrySettings{Enabled: true, Endpoint: ts.URL})
+	c.Assert(err, check.IsNil)
+	os.Setenv("TSURU_DISABLE_TELEMETRY", "1")
+	defer os.Unsetenv("TSURU_DISABLE_TELEMETRY")
+	manager.reportTelemetry("app-list", time.Second, 0, nil)
+	c.Assert(calls, check.Equals, 0)
+}
+
+func (s *S) TestReportTelemetryIgnoresErrors(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	err := writeTelemetrySettings(telemetrySettings{Enabled: true, Endpoint: "http://127.0.0.1:1"})
+	c.Assert(err, check.IsNil)
+	var stdout, stderr bytes.Buffer
+	manager := NewManager("glb", "1.0", "", &stdout, &stderr, os.Stdin, nil)
+	manager.reportTelemetry("app-list", time.Second, 0, nil)
+	c.Assert(stdout.String(), check.Equals, "")
+	c.Assert(stderr.String(), check.Equals, "")
+}
+
+func (s *S) TestErrorClass(c *check.C) {
+	c.Assert(errorClass(nil), check.Equals, "")
+	c.Assert(errorClass(ErrAbortCommand), check.Equals, "aborted")
+	c.Assert(errorClass(fmt.Errorf("invalid flag")), check.Equals, "client")
+	c.Assert(errorClass(errUnauthorized), check.Equals, tsuruErrors.CodeAuthFailure)
+	locked := &tsuruErrors.HTTP{Code: http.StatusConflict, ErrorCode: tsuruErrors.CodeLocked}
+	c.Assert(errorClass(errors.Wrap(locked, "deploy failed")), check.Equals, tsuruErrors.CodeLocked)
+}
@@ -18,5 +18,10 @@ The following commands are used to manage targets in the client:
   * target-set: defines the current target, to which the CLI will send next
     commands
 
-See each command usage by running %s help <commandname>
+Each target in the list keeps its own session, so switching between targets
+with target-set doesn't require logging in again. A single command can be sent
+to another target with the --target flag, using either the label of a target
+in the list or an address, e.g.: %[1]s --target prod app-list
+
+See each command usage by running %[1]s help <commandname>
 `
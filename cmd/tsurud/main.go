@@ -12,6 +12,8 @@ import (
 	"github.com/google/gops/agent"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/api"
+	_ "github.com/tsuru/tsuru/app/logstorage/elasticsearch"
+	_ "github.com/tsuru/tsuru/app/metrics/prometheus"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/iaas/dockermachine"
 	_ "github.com/tsuru/tsuru/provision/docker"
@@ -20,6 +22,8 @@ import (
 	_ "github.com/tsuru/tsuru/provision/mesos"
 	_ "github.com/tsuru/tsuru/provision/swarm"
 	_ "github.com/tsuru/tsuru/repository/gandalf"
+	_ "github.com/tsuru/tsuru/secret/file"
+	_ "github.com/tsuru/tsuru/secret/vault"
 )
 
 const defaultConfigPath = "/etc/tsuru/tsuru.conf"
@@ -32,7 +36,7 @@ func buildManager() *cmd.Manager {
 	m.Register(&tsurudCommand{Command: tokenCmd{}})
 	m.Register(&tsurudCommand{Command: &migrateCmd{}})
 	m.Register(&tsurudCommand{Command: gandalfSyncCmd{}})
-	m.Register(&tsurudCommand{Command: createRootUserCmd{}})
+	m.Register(&tsurudCommand{Command: &createRootUserCmd{}})
 	m.Register(&migrationListCmd{})
 	return m
 }
@@ -107,6 +107,9 @@ func (*migrationListCmd) Run(context *cmd.Context, client *cmd.Client) error {
 	if err != nil {
 		return err
 	}
+	if context.Structured() {
+		return context.Render(migrations)
+	}
 	tbl := cmd.NewTable()
 	tbl.Headers = cmd.Row{"Name", "Mandatory?", "Executed?"}
 	for _, m := range migrations {
@@ -129,7 +132,11 @@ func (*migrateCmd) Info() *cmd.Info {
 		Usage: "migrate [-n/--dry] [-f/--force] [--name name]",
 		Desc: `Runs migrations from previous versions of tsurud. Only mandatory migrations
 will be executed by default. To execute an optional migration the --name flag
-must be informed.`,
+must be informed.
+
+Each migration holds a lock while running, so concurrent executions of the
+same migration fail, and is recorded as an event with target type
+"migration".`,
 	}
 }
 
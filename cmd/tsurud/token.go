@@ -6,6 +6,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
@@ -15,11 +16,15 @@ import (
 	_ "github.com/tsuru/tsuru/auth/oauth"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/gnuflag"
 )
 
-type createRootUserCmd struct{}
+type createRootUserCmd struct {
+	fs            *gnuflag.FlagSet
+	passwordStdin bool
+}
 
-func (createRootUserCmd) Run(context *cmd.Context, client *cmd.Client) error {
+func (c *createRootUserCmd) Run(context *cmd.Context, client *cmd.Client) error {
 	context.RawOutput()
 	scheme, err := config.GetString("auth:scheme")
 	if err != nil {
@@ -39,7 +44,12 @@ func (createRootUserCmd) Run(context *cmd.Context, client *cmd.Client) error {
 		fmt.Fprintln(context.Stdout, "Root user successfully updated.")
 	}
 	var confirm, password string
-	if scheme == nativeSchemeName {
+	if scheme == nativeSchemeName && (c.passwordStdin || os.Getenv(cmd.PasswordEnvVar) != "") {
+		password, err = cmd.ReadPassword(context, "", c.passwordStdin)
+		if err != nil {
+			return err
+		}
+	} else if scheme == nativeSchemeName {
 		fmt.Fprint(context.Stdout, "Password: ")
 		password, err = cmd.PasswordFromReader(context.Stdin)
 		if err != nil {
@@ -83,20 +93,42 @@ func addSuperRole(u *auth.User) error {
 	if err != nil {
 		return err
 	}
+	_, err = permission.SeedRoleTemplates()
+	if err != nil {
+		return err
+	}
 	return u.AddRole(defaultRoleName, "")
 }
 
-func (createRootUserCmd) Info() *cmd.Info {
+func (c *createRootUserCmd) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:  "root-user-create",
-		Usage: "root-user-create <email>",
+		Usage: "root-user-create <email> [--password-stdin]",
 		Desc: `Create a root user with all permission. This user can be used when
 bootstraping a tsuru cloud. It can be erased after other users are created and
-roles are properly created and assigned.`,
+roles are properly created and assigned.
+
+The built-in developer, operator and auditor roles are also created, unless
+roles with the same names already exist. They can be assigned as they are or
+cloned and adjusted.
+
+When using the native authentication scheme, the password is asked twice. For
+automation, it may be read from the first line of the standard input with
+[[--password-stdin]], or from the [[TSURU_PASSWORD]] environment variable.
+Don't type the password in the command line, where it's kept in the shell
+history.`,
 		MinArgs: 1,
 	}
 }
 
+func (c *createRootUserCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("root-user-create", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.passwordStdin, "password-stdin", false, "Read the password from the standard input.")
+	}
+	return c.fs
+}
+
 type tokenCmd struct{}
 
 func (tokenCmd) Run(context *cmd.Context, client *cmd.Client) error {
@@ -60,7 +60,7 @@ func (s *S) TestCreateRootUserCmdRun(c *check.C) {
 	}
 	manager := cmd.NewManager("glb", "", "", &stdout, &stderr, os.Stdin, nil)
 	client := cmd.NewClient(&http.Client{}, nil, manager)
-	command := createRootUserCmd{}
+	command := &createRootUserCmd{}
 	err := command.Run(&context, client)
 	c.Assert(err, check.IsNil)
 	c.Assert(stdout.String(), check.Equals, "Password: \nConfirm: \nRoot user successfully created.\n")
@@ -71,4 +71,28 @@ func (s *S) TestCreateRootUserCmdRun(c *check.C) {
 	c.Assert(perms, check.HasLen, 2)
 	c.Assert(perms[0].Scheme, check.Equals, permission.PermUser)
 	c.Assert(perms[1].Scheme, check.Equals, permission.PermAll)
+	for _, tpl := range permission.RoleTemplates {
+		_, err = permission.FindRole(tpl.Name)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestCreateRootUserCmdRunPasswordStdin(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"my@user.com"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("foo123\n"),
+	}
+	manager := cmd.NewManager("glb", "", "", &stdout, &stderr, os.Stdin, nil)
+	client := cmd.NewClient(&http.Client{}, nil, manager)
+	command := &createRootUserCmd{}
+	err := command.Flags().Parse(true, []string{"--password-stdin"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Root user successfully created.\n")
+	_, err = auth.GetUserByEmail("my@user.com")
+	c.Assert(err, check.IsNil)
 }
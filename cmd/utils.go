@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/gnuflag"
@@ -30,9 +31,37 @@ func JoinWithUserDir(p ...string) string {
 	return filepath.Join(paths...)
 }
 
+// tokenPath returns the path of the file storing the token of the current
+// target. Targets in the target list have their own token files, so users can
+// switch between them without logging in again. The legacy token file is used
+// for targets not in the list.
+func tokenPath() (string, bool) {
+	if target, err := ReadTarget(); err == nil {
+		if label := targetLabel(target); label != "" {
+			return targetTokenPath(label), true
+		}
+	}
+	return JoinWithUserDir(".tsuru", "token"), false
+}
+
+// tokenExpirySuffix is appended to the path of a token file to get the path
+// of the file storing the expiration time of the token.
+const tokenExpirySuffix = ".expires"
+
 func writeToken(token string) error {
-	tokenPath := JoinWithUserDir(".tsuru", "token")
-	file, err := filesystem().Create(tokenPath)
+	path, perTarget := tokenPath()
+	filesystem().Remove(path + tokenExpirySuffix)
+	if keychainEnabled() && keychainSet(keychainAccount(), token) == nil {
+		filesystem().Remove(path)
+		return nil
+	}
+	if perTarget {
+		err := filesystem().MkdirAll(JoinWithUserDir(".tsuru", "token.d"), 0700)
+		if err != nil {
+			return err
+		}
+	}
+	file, err := filesystem().Create(path)
 	if err != nil {
 		return err
 	}
@@ -47,18 +76,83 @@ func writeToken(token string) error {
 	return nil
 }
 
+// writeTokenExpiry stores the expiration time of the token of the current
+// target, next to the token file. The expiration is stored even when the
+// token is kept in the keychain.
+func writeTokenExpiry(expires time.Time) error {
+	path, perTarget := tokenPath()
+	path += tokenExpirySuffix
+	if expires.IsZero() {
+		err := filesystem().Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if perTarget {
+		err := filesystem().MkdirAll(JoinWithUserDir(".tsuru", "token.d"), 0700)
+		if err != nil {
+			return err
+		}
+	}
+	file, err := filesystem().Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(expires.UTC().Format(time.RFC3339))
+	return err
+}
+
+// ReadTokenExpiry returns the expiration time of the token of the current
+// target, or the zero time when it's unknown, like for tokens set in the
+// TSURU_TOKEN environment variable.
+func ReadTokenExpiry() (time.Time, error) {
+	if os.Getenv("TSURU_TOKEN") != "" {
+		return time.Time{}, nil
+	}
+	path, _ := tokenPath()
+	data, err := readTokenFile(path + tokenExpirySuffix)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(data))
+}
+
 func ReadToken() (string, error) {
 	if token := os.Getenv("TSURU_TOKEN"); token != "" {
 		return token, nil
 	}
-	tokenPath := JoinWithUserDir(".tsuru", "token")
-	file, err := filesystem().Open(tokenPath)
+	if keychainEnabled() {
+		if token, err := keychainGet(keychainAccount()); err == nil && token != "" {
+			return token, nil
+		}
+	}
+	path, perTarget := tokenPath()
+	token, err := readTokenFile(path)
+	if os.IsNotExist(err) && perTarget {
+		token, err = migrateLegacyToken()
+	}
 	if os.IsNotExist(err) {
 		return "", nil
 	}
 	if err != nil {
 		return "", err
 	}
+	if keychainEnabled() && len(token) > 0 {
+		migrateTokenToKeychain(token)
+	}
+	return token, nil
+}
+
+func readTokenFile(path string) (string, error) {
+	file, err := filesystem().Open(path)
+	if err != nil {
+		return "", err
+	}
 	defer file.Close()
 	token, err := ioutil.ReadAll(file)
 	if err != nil {
@@ -67,6 +161,37 @@ func ReadToken() (string, error) {
 	return string(token), nil
 }
 
+// migrateLegacyToken moves the token in the legacy token file, shared by all
+// targets, to the token file of the current target.
+func migrateLegacyToken() (string, error) {
+	legacyPath := JoinWithUserDir(".tsuru", "token")
+	token, err := readTokenFile(legacyPath)
+	if err != nil || token == "" {
+		return token, err
+	}
+	if writeToken(token) == nil {
+		filesystem().Remove(legacyPath)
+		if data, err := readTokenFile(legacyPath + tokenExpirySuffix); err == nil {
+			if expires, err := time.Parse(time.RFC3339, strings.TrimSpace(data)); err == nil {
+				writeTokenExpiry(expires)
+			}
+			filesystem().Remove(legacyPath + tokenExpirySuffix)
+		}
+	}
+	return token, nil
+}
+
+func removeToken() error {
+	removedFromKeychain := keychainEnabled() && keychainRemove(keychainAccount()) == nil
+	path, _ := tokenPath()
+	filesystem().Remove(path + tokenExpirySuffix)
+	err := filesystem().Remove(path)
+	if removedFromKeychain && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 type ServiceModel struct {
 	Service   string
 	Instances []string
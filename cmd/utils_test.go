@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 
 	"github.com/tsuru/gnuflag"
 	"github.com/tsuru/tsuru/fs/fstest"
@@ -59,6 +60,63 @@ func (s *S) TestReadToken(c *check.C) {
 	c.Assert(token, check.Equals, "123")
 }
 
+func (s *S) TestWriteTokenExpiry(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	expiryPath := JoinWithUserDir(".tsuru", "token.expires")
+	expires := time.Date(2017, 3, 8, 14, 30, 0, 0, time.UTC)
+	err := writeTokenExpiry(expires)
+	c.Assert(err, check.IsNil)
+	c.Assert(rfs.HasAction("create "+expiryPath), check.Equals, true)
+	got, err := ReadTokenExpiry()
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Equal(expires), check.Equals, true)
+	err = writeToken("abc")
+	c.Assert(err, check.IsNil)
+	c.Assert(rfs.HasAction("remove "+expiryPath), check.Equals, true)
+	got, err = ReadTokenExpiry()
+	c.Assert(err, check.IsNil)
+	c.Assert(got.IsZero(), check.Equals, true)
+}
+
+func (s *S) TestReadTokenExpiryWithTokenFromEnv(c *check.C) {
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	os.Unsetenv("TSURU_TOKEN")
+	err := writeTokenExpiry(time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	os.Setenv("TSURU_TOKEN", "abc123")
+	got, err := ReadTokenExpiry()
+	c.Assert(err, check.IsNil)
+	c.Assert(got.IsZero(), check.Equals, true)
+}
+
+func (s *S) TestRemoveTokenRemovesExpiry(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	err := writeToken("abc")
+	c.Assert(err, check.IsNil)
+	err = writeTokenExpiry(time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	err = removeToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(rfs.HasAction("remove "+JoinWithUserDir(".tsuru", "token.expires")), check.Equals, true)
+	got, err := ReadTokenExpiry()
+	c.Assert(err, check.IsNil)
+	c.Assert(got.IsZero(), check.Equals, true)
+}
+
 func (s *S) TestReadTokenFileNotFound(c *check.C) {
 	os.Unsetenv("TSURU_TOKEN")
 	errFs := &fstest.FileNotFoundFs{}
@@ -74,6 +132,62 @@ func (s *S) TestReadTokenFileNotFound(c *check.C) {
 	c.Assert(token, check.Equals, "")
 }
 
+func (s *S) TestTokenPerTarget(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	err := WriteOnTargetList("prod", "https://prod.tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = WriteOnTargetList("staging", "https://staging.tsuru.io/")
+	c.Assert(err, check.IsNil)
+	os.Setenv("TSURU_TARGET", "https://prod.tsuru.io")
+	err = writeToken("prodtoken")
+	c.Assert(err, check.IsNil)
+	c.Assert(rfs.HasAction("create "+JoinWithUserDir(".tsuru", "token.d", "prod")), check.Equals, true)
+	os.Setenv("TSURU_TARGET", "https://staging.tsuru.io")
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "")
+	err = writeToken("stagingtoken")
+	c.Assert(err, check.IsNil)
+	token, err = ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "stagingtoken")
+	os.Setenv("TSURU_TARGET", "https://prod.tsuru.io")
+	token, err = ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "prodtoken")
+	err = removeToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(rfs.HasAction("remove "+JoinWithUserDir(".tsuru", "token.d", "prod")), check.Equals, true)
+}
+
+func (s *S) TestReadTokenMigratesLegacyToken(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	rfs := &fstest.RecordingFs{}
+	fsystem = rfs
+	defer func() {
+		fsystem = nil
+	}()
+	err := WriteOnTargetList("prod", "https://prod.tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = writeToken("legacytoken")
+	c.Assert(err, check.IsNil)
+	os.Setenv("TSURU_TARGET", "https://prod.tsuru.io")
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "legacytoken")
+	c.Assert(rfs.HasAction("remove "+JoinWithUserDir(".tsuru", "token")), check.Equals, true)
+	f, err := rfs.Open(JoinWithUserDir(".tsuru", "token.d", "prod"))
+	c.Assert(err, check.IsNil)
+	b, err := ioutil.ReadAll(f)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(b), check.Equals, "legacytoken")
+}
+
 func (s *S) TestShowServicesInstancesList(c *check.C) {
 	expected := `+----------+-----------+
 | Services | Instances |
@@ -12,7 +12,9 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db/storage"
@@ -78,6 +80,22 @@ func Conn() (*Storage, error) {
 	return &strg, err
 }
 
+// ConnContext is like Conn, but the connection is bound to ctx: it's not
+// opened once ctx is done and its operations time out at the deadline of ctx.
+func ConnContext(ctx context.Context) (*Storage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	strg, err := Conn()
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		strg.SetDeadline(deadline)
+	}
+	return strg, nil
+}
+
 func LogConn() (*LogStorage, error) {
 	var (
 		strg LogStorage
@@ -96,6 +114,59 @@ func (s *Storage) Apps() *storage.Collection {
 	return c
 }
 
+// AppEnvRevisions returns the collection storing the revisions of the
+// environment variables of apps.
+func (s *Storage) AppEnvRevisions() *storage.Collection {
+	versionIndex := mgo.Index{Key: []string{"app", "version"}, Unique: true}
+	c := s.Collection("app_env_revisions")
+	c.EnsureIndex(versionIndex)
+	return c
+}
+
+// Jobs returns the collection storing the scheduled tasks of apps.
+func (s *Storage) Jobs() *storage.Collection {
+	nameIndex := mgo.Index{Key: []string{"app", "name"}, Unique: true}
+	c := s.Collection("jobs")
+	c.EnsureIndex(nameIndex)
+	return c
+}
+
+// ResourceQuotas returns the collection storing the limits of apps, units and
+// memory of teams and pools.
+func (s *Storage) ResourceQuotas() *storage.Collection {
+	nameIndex := mgo.Index{Key: []string{"scope", "name"}, Unique: true}
+	c := s.Collection("resource_quotas")
+	c.EnsureIndex(nameIndex)
+	return c
+}
+
+// LogDrains returns the collection storing the external destinations of the
+// logs of apps and pools.
+func (s *Storage) LogDrains() *storage.Collection {
+	nameIndex := mgo.Index{Key: []string{"app", "pool", "name"}, Unique: true}
+	c := s.Collection("log_drains")
+	c.EnsureIndex(nameIndex)
+	return c
+}
+
+// AppSwaps returns the collection storing the gradual swaps of apps, which
+// shift the traffic between two apps before swapping them.
+func (s *Storage) AppSwaps() *storage.Collection {
+	statusIndex := mgo.Index{Key: []string{"status", "nextsteptime"}}
+	c := s.Collection("app_swaps")
+	c.EnsureIndex(statusIndex)
+	return c
+}
+
+// DeployPauses returns the collection storing the apps whose deploys were
+// paused after a crash loop was detected.
+func (s *Storage) DeployPauses() *storage.Collection {
+	eventIndex := mgo.Index{Key: []string{"deployevent"}}
+	c := s.Collection("deploy_pauses")
+	c.EnsureIndex(eventIndex)
+	return c
+}
+
 // Platforms returns the platforms collection from MongoDB.
 func (s *Storage) Platforms() *storage.Collection {
 	return s.Collection("platforms")
@@ -121,6 +192,15 @@ func (s *Storage) Pools() *storage.Collection {
 	return s.Collection("pool")
 }
 
+// RegistryCredentials returns the collection storing the credentials of
+// private registries used by the apps of pools and teams.
+func (s *Storage) RegistryCredentials() *storage.Collection {
+	scopeIndex := mgo.Index{Key: []string{"server", "pool", "team"}, Unique: true}
+	c := s.Collection("registry_credentials")
+	c.EnsureIndex(scopeIndex)
+	return c
+}
+
 // PoolsConstraints return the pool constraints collection.
 func (s *Storage) PoolsConstraints() *storage.Collection {
 	poolConstraintIndex := mgo.Index{Key: []string{"poolexpr", "field"}, Unique: true}
@@ -129,6 +209,18 @@ func (s *Storage) PoolsConstraints() *storage.Collection {
 	return c
 }
 
+// NodePoolRules returns the collection of rules used to assign new nodes to
+// pools.
+func (s *Storage) NodePoolRules() *storage.Collection {
+	return s.Collection("node_pool_rules")
+}
+
+// CredentialRotations returns the collection of node credentials rotations
+// from MongoDB.
+func (s *Storage) CredentialRotations() *storage.Collection {
+	return s.Collection("credential_rotations")
+}
+
 // Users returns the users collection from MongoDB.
 func (s *Storage) Users() *storage.Collection {
 	emailIndex := mgo.Index{Key: []string{"email"}, Unique: true}
@@ -143,6 +235,26 @@ func (s *Storage) Tokens() *storage.Collection {
 	return coll
 }
 
+// APITokens returns the collection of named API tokens created by users.
+func (s *Storage) APITokens() *storage.Collection {
+	nameIndex := mgo.Index{Key: []string{"useremail", "name"}, Unique: true}
+	hashIndex := mgo.Index{Key: []string{"hash"}, Unique: true}
+	c := s.Collection("api_tokens")
+	c.EnsureIndex(nameIndex)
+	c.EnsureIndex(hashIndex)
+	return c
+}
+
+// LoginAttempts returns the collection of failed login attempts, used to
+// lock users and client addresses out. Attempts are removed once they
+// expire.
+func (s *Storage) LoginAttempts() *storage.Collection {
+	expireIndex := mgo.Index{Key: []string{"expireat"}, ExpireAfter: time.Second}
+	c := s.Collection("login_attempts")
+	c.EnsureIndex(expireIndex)
+	return c
+}
+
 func (s *Storage) PasswordTokens() *storage.Collection {
 	return s.Collection("password_tokens")
 }
@@ -151,11 +263,25 @@ func (s *Storage) UserActions() *storage.Collection {
 	return s.Collection("user_actions")
 }
 
+// UserActivity returns the collection storing the last login and token usage
+// of users.
+func (s *Storage) UserActivity() *storage.Collection {
+	return s.Collection("user_activity")
+}
+
 // Teams returns the teams collection from MongoDB.
 func (s *Storage) Teams() *storage.Collection {
 	return s.Collection("teams")
 }
 
+// Groups returns the user groups collection from MongoDB.
+func (s *Storage) Groups() *storage.Collection {
+	membersIndex := mgo.Index{Key: []string{"members"}}
+	c := s.Collection("groups")
+	c.EnsureIndex(membersIndex)
+	return c
+}
+
 // Quota returns the quota collection from MongoDB.
 func (s *Storage) Quota() *storage.Collection {
 	userIndex := mgo.Index{Key: []string{"owner"}, Unique: true}
@@ -221,14 +347,74 @@ func (s *Storage) Limiter() *storage.Collection {
 func (s *Storage) Events() *storage.Collection {
 	ownerIndex := mgo.Index{Key: []string{"owner"}}
 	kindIndex := mgo.Index{Key: []string{"kind"}}
-	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
+	startTimeIndex := mgo.Index{Key: []string{"-starttime", "-uniqueid"}}
+	endTimeIndex := mgo.Index{Key: []string{"endtime", "uniqueid"}}
+	extraTargetsIndex := mgo.Index{Key: []string{"extratargets.type", "extratargets.value"}}
 	c := s.Collection("events")
 	c.EnsureIndex(ownerIndex)
 	c.EnsureIndex(kindIndex)
 	c.EnsureIndex(startTimeIndex)
+	c.EnsureIndex(endTimeIndex)
+	c.EnsureIndex(extraTargetsIndex)
+	return c
+}
+
+// EventLockOwners returns the collection storing, for each API instance, the
+// event locks it holds and the last time it updated them.
+func (s *Storage) EventLockOwners() *storage.Collection {
+	locksIndex := mgo.Index{Key: []string{"locks"}}
+	c := s.Collection("event_lock_owners")
+	c.EnsureIndex(locksIndex)
+	return c
+}
+
+// EventLockLeader returns the collection storing the lease of the instance
+// elected to reclaim locks held by dead instances.
+func (s *Storage) EventLockLeader() *storage.Collection {
+	return s.Collection("event_lock_leader")
+}
+
+// EventScheduled returns the collection storing the events waiting to be
+// started by the event scheduler.
+func (s *Storage) EventScheduled() *storage.Collection {
+	runAtIndex := mgo.Index{Key: []string{"runat"}}
+	c := s.Collection("event_scheduled")
+	c.EnsureIndex(runAtIndex)
 	return c
 }
 
+// EventExportCheckpoints returns the collection storing the progress of
+// each event export sink.
+func (s *Storage) EventExportCheckpoints() *storage.Collection {
+	return s.Collection("event_export_checkpoints")
+}
+
+func (s *Storage) EventConsumerGroups() *storage.Collection {
+	return s.Collection("event_consumer_groups")
+}
+
+func (s *Storage) EventConsumerDeliveries() *storage.Collection {
+	uniqueIndex := mgo.Index{Key: []string{"group", "eventid"}, Unique: true}
+	deadlineIndex := mgo.Index{Key: []string{"group", "deadline"}}
+	c := s.Collection("event_consumer_deliveries")
+	c.EnsureIndex(uniqueIndex)
+	c.EnsureIndex(deadlineIndex)
+	return c
+}
+
+// EventNotificationRules returns the collection storing the rules users
+// subscribe to in order to be notified about finished events.
+func (s *Storage) EventNotificationRules() *storage.Collection {
+	ownerIndex := mgo.Index{Key: []string{"owner"}}
+	c := s.Collection("event_notification_rules")
+	c.EnsureIndex(ownerIndex)
+	return c
+}
+
+func (s *Storage) EventLegalHolds() *storage.Collection {
+	return s.Collection("event_legal_holds")
+}
+
 func (s *Storage) EventBlocks() *storage.Collection {
 	index := mgo.Index{Key: []string{"ownername", "kindname", "target"}}
 	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
@@ -238,6 +424,30 @@ func (s *Storage) EventBlocks() *storage.Collection {
 	return c
 }
 
+// Motd returns the collection storing the messages of the day displayed by
+// the tsuru client.
+func (s *Storage) Motd() *storage.Collection {
+	return s.Collection("motd")
+}
+
+// ReadOnly returns the collection storing the enabled read-only modes of the
+// API.
+func (s *Storage) ReadOnly() *storage.Collection {
+	return s.Collection("readonly")
+}
+
+// StatusPageIncidents returns the collection storing the events flagged by
+// operators as incidents in the public status page.
+func (s *Storage) StatusPageIncidents() *storage.Collection {
+	return s.Collection("status_page_incidents")
+}
+
+// EventKindAliases returns the collection storing the friendly labels and
+// categories of event kinds.
+func (s *Storage) EventKindAliases() *storage.Collection {
+	return s.Collection("event_kind_aliases")
+}
+
 func (s *Storage) InstallHosts() *storage.Collection {
 	nameIndex := mgo.Index{Key: []string{"name"}, Unique: true}
 	c := s.Collection("install_hosts")
@@ -55,6 +55,22 @@ func (s *Storage) Close() {
 	s.session.Close()
 }
 
+// SetDeadline makes the operations of the storage time out at the given
+// deadline. The storage stops sharing the socket of the other connections,
+// so their timeouts are not changed.
+func (s *Storage) SetDeadline(deadline time.Time) {
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		// mgo takes a zero timeout as no timeout at all.
+		timeout = time.Nanosecond
+	}
+	session := s.session.Copy()
+	s.session.Close()
+	s.session = session
+	s.session.SetSyncTimeout(timeout)
+	s.session.SetSocketTimeout(timeout)
+}
+
 // Collection returns a collection by its name.
 //
 // If the collection does not exist, MongoDB will create it.
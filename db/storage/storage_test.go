@@ -6,6 +6,7 @@ package storage
 
 import (
 	"testing"
+	"time"
 
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2"
@@ -79,3 +80,17 @@ func (s *S) TestCollection(c *check.C) {
 	collection := storage.Collection("users")
 	c.Assert(collection.FullName, check.Equals, storage.dbname+".users")
 }
+
+func (s *S) TestSetDeadline(c *check.C) {
+	storage, err := Open("127.0.0.1:27017", "tsuru_storage_test")
+	c.Assert(err, check.IsNil)
+	defer storage.Close()
+	storage2, err := Open("127.0.0.1:27017", "tsuru_storage_test")
+	c.Assert(err, check.IsNil)
+	defer storage2.Close()
+	storage.SetDeadline(time.Now().Add(time.Hour))
+	c.Assert(storage.session.Ping(), check.IsNil)
+	storage.SetDeadline(time.Now().Add(-time.Second))
+	c.Assert(storage.session.Ping(), check.NotNil)
+	c.Assert(storage2.session.Ping(), check.IsNil)
+}
@@ -5,8 +5,10 @@
 package db
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db/storage"
@@ -67,6 +69,23 @@ func (s *S) TestHealthCheck(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestConnContext(c *check.C) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	strg, err := ConnContext(ctx)
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	c.Assert(strg.Apps().Database.Session.Ping(), check.IsNil)
+}
+
+func (s *S) TestConnContextDone(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	strg, err := ConnContext(ctx)
+	c.Assert(err, check.Equals, context.Canceled)
+	c.Assert(strg, check.IsNil)
+}
+
 func (s *S) TestUsers(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -203,6 +222,24 @@ func (s *S) TestRoles(c *check.C) {
 	c.Assert(roles, check.DeepEquals, rolesc)
 }
 
+func (s *S) TestGroups(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	groups := strg.Groups()
+	groupsc := strg.Collection("groups")
+	c.Assert(groups, check.DeepEquals, groupsc)
+}
+
+func (s *S) TestLoginAttempts(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	attempts := strg.LoginAttempts()
+	attemptsc := strg.Collection("login_attempts")
+	c.Assert(attempts, check.DeepEquals, attemptsc)
+}
+
 func (s *S) TestInstallHosts(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
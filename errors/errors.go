@@ -5,7 +5,24 @@
 // Package errors provides facilities with error handling.
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error codes identify the class of an error in structured error payloads,
+// so clients can handle failures without parsing messages.
+const (
+	CodeInvalid       = "invalid"
+	CodeAuthFailure   = "auth-failure"
+	CodeTokenExpired  = "token-expired"
+	CodeForbidden     = "forbidden"
+	CodeNotFound      = "not-found"
+	CodeConflict      = "conflict"
+	CodeLocked        = "locked"
+	CodeQuotaExceeded = "quota-exceeded"
+	CodeInternal      = "internal"
+)
 
 // HTTP represents an HTTP error. It implements the error interface.
 //
@@ -16,12 +33,56 @@ type HTTP struct {
 
 	// Message explaining what went wrong.
 	Message string
+
+	// ErrorCode is the machine-readable class of the error. When empty, it's
+	// derived from the status code.
+	ErrorCode string
+
+	// Fields optionally maps invalid input fields to what is wrong with them.
+	Fields map[string]string
 }
 
 func (e *HTTP) Error() string {
 	return e.Message
 }
 
+// Payload returns the structured representation of the error.
+func (e *HTTP) Payload() Payload {
+	code := e.ErrorCode
+	if code == "" {
+		code = StatusErrorCode(e.Code)
+	}
+	return Payload{Code: code, Message: e.Message, Fields: e.Fields}
+}
+
+// Payload is the structured representation of an error, sent by the API to
+// clients accepting JSON responses.
+type Payload struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// StatusErrorCode returns the error code matching the given HTTP status
+// code.
+func StatusErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusPreconditionFailed, http.StatusRequestEntityTooLarge:
+		return CodeInvalid
+	case http.StatusUnauthorized:
+		return CodeAuthFailure
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusLocked:
+		return CodeLocked
+	}
+	return CodeInternal
+}
+
 // ValidationError is an error implementation used whenever a validation
 // failure occurs.
 type ValidationError struct {
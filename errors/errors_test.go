@@ -17,10 +17,34 @@ type S struct{}
 var _ = check.Suite(&S{})
 
 func (s *S) TestHTTPError(c *check.C) {
-	e := HTTP{500, "Internal server error"}
+	e := HTTP{Code: 500, Message: "Internal server error"}
 	c.Assert(e.Error(), check.Equals, e.Message)
 }
 
+func (s *S) TestHTTPErrorPayload(c *check.C) {
+	e := HTTP{Code: 404, Message: "app not found"}
+	c.Assert(e.Payload(), check.DeepEquals, Payload{Code: CodeNotFound, Message: "app not found"})
+	e = HTTP{Code: 403, Message: "Quota exceeded", ErrorCode: CodeQuotaExceeded}
+	c.Assert(e.Payload(), check.DeepEquals, Payload{Code: CodeQuotaExceeded, Message: "Quota exceeded"})
+	e = HTTP{Code: 400, Message: "invalid units", Fields: map[string]string{"units": "must be positive"}}
+	c.Assert(e.Payload(), check.DeepEquals, Payload{
+		Code:    CodeInvalid,
+		Message: "invalid units",
+		Fields:  map[string]string{"units": "must be positive"},
+	})
+}
+
+func (s *S) TestStatusErrorCode(c *check.C) {
+	c.Assert(StatusErrorCode(400), check.Equals, CodeInvalid)
+	c.Assert(StatusErrorCode(401), check.Equals, CodeAuthFailure)
+	c.Assert(StatusErrorCode(403), check.Equals, CodeForbidden)
+	c.Assert(StatusErrorCode(404), check.Equals, CodeNotFound)
+	c.Assert(StatusErrorCode(409), check.Equals, CodeConflict)
+	c.Assert(StatusErrorCode(423), check.Equals, CodeLocked)
+	c.Assert(StatusErrorCode(500), check.Equals, CodeInternal)
+	c.Assert(StatusErrorCode(503), check.Equals, CodeInternal)
+}
+
 func (s *S) TestValidationError(c *check.C) {
 	e := ValidationError{Message: "something"}
 	c.Assert(e.Error(), check.Equals, "something")
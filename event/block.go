@@ -6,6 +6,7 @@ package event
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/tsuru/tsuru/db"
@@ -13,7 +14,10 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
-const blockListLimit = 25
+const (
+	blockListLimit  = 25
+	maxBlockWindows = 100
+)
 
 type ErrActiveEventBlockNotFound struct {
 	id string
@@ -46,6 +50,20 @@ type Block struct {
 	Target    Target `bson:"target,omitempty"`
 	Reason    string
 	Active    bool
+	// ExpireTime is the time after which the block is ignored.
+	ExpireTime time.Time `bson:",omitempty"`
+	// Schedule is a cron-like expression, evaluated in UTC, restricting
+	// the block to recurring windows of Duration starting at each
+	// occurrence of the schedule.
+	Schedule string        `bson:",omitempty"`
+	Duration time.Duration `bson:",omitempty"`
+}
+
+// BlockWindow is a period of time in which a scheduled block is enforced.
+type BlockWindow struct {
+	Block     Block
+	StartTime time.Time
+	EndTime   time.Time
 }
 
 func (b *Block) String() string {
@@ -64,7 +82,46 @@ func (b *Block) String() string {
 	return fmt.Sprintf("block %s by %s on %s: %s", kind, owner, target, b.Reason)
 }
 
+func (b *Block) validate() error {
+	if !b.ExpireTime.IsZero() && !b.ExpireTime.After(time.Now()) {
+		return ErrValidation("block expire time must be in the future")
+	}
+	if b.Schedule == "" {
+		if b.Duration != 0 {
+			return ErrValidation("block duration requires a schedule")
+		}
+		return nil
+	}
+	if b.Duration <= 0 {
+		return ErrValidation("block duration is required for scheduled blocks")
+	}
+	_, err := parseSchedule(b.Schedule)
+	return err
+}
+
+// window returns the window of a scheduled block that contains t, or nil if
+// the block is not enforced at t. Unscheduled blocks always return a window
+// with zero start and end times.
+func (b *Block) window(t time.Time) *BlockWindow {
+	if b.Schedule == "" {
+		return &BlockWindow{Block: *b}
+	}
+	sched, err := parseSchedule(b.Schedule)
+	if err != nil {
+		return nil
+	}
+	start := sched.next(t.UTC().Add(-b.Duration))
+	if start.IsZero() || start.After(t) {
+		return nil
+	}
+	return &BlockWindow{Block: *b, StartTime: start, EndTime: start.Add(b.Duration)}
+}
+
 func AddBlock(b *Block) error {
+	err := b.validate()
+	if err != nil {
+		return err
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -90,34 +147,95 @@ func RemoveBlock(id bson.ObjectId) error {
 	return err
 }
 
+func notExpiredQuery(now time.Time) bson.M {
+	return bson.M{"active": true, "$or": []bson.M{
+		{"expiretime": bson.M{"$exists": false}},
+		{"expiretime": bson.M{"$gt": now}},
+	}}
+}
+
+// ListBlocks returns the blocks, filtered by their status if active is not
+// nil. Expired blocks are considered inactive.
 func ListBlocks(active *bool) ([]Block, error) {
 	query := bson.M{}
 	if active != nil {
-		query["active"] = *active
+		now := time.Now()
+		if *active {
+			query = notExpiredQuery(now)
+		} else {
+			query["$or"] = []bson.M{{"active": false}, {"expiretime": bson.M{"$lte": now}}}
+		}
 	}
 	return listBlocks(query)
 }
 
 func listBlocks(query bson.M) ([]Block, error) {
+	return findBlocks(query, blockListLimit)
+}
+
+func findBlocks(query bson.M, limit int) ([]Block, error) {
 	conn, err := db.Conn()
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 	var blocks []Block
-	err = conn.EventBlocks().Find(query).Sort("-starttime").Limit(blockListLimit).All(&blocks)
+	find := conn.EventBlocks().Find(query).Sort("-starttime")
+	if limit > 0 {
+		find = find.Limit(limit)
+	}
+	err = find.All(&blocks)
 	if err != nil {
 		return nil, err
 	}
 	return blocks, nil
 }
 
+// ListBlockWindows returns the windows of active scheduled blocks which are
+// in progress or start before until, sorted by start time.
+func ListBlockWindows(until time.Time) ([]BlockWindow, error) {
+	now := time.Now().UTC()
+	query := notExpiredQuery(now)
+	query["schedule"] = bson.M{"$exists": true}
+	blocks, err := findBlocks(query, 0)
+	if err != nil {
+		return nil, err
+	}
+	var windows []BlockWindow
+	for i := range blocks {
+		b := &blocks[i]
+		sched, err := parseSchedule(b.Schedule)
+		if err != nil {
+			continue
+		}
+		start := sched.next(now.Add(-b.Duration))
+		for n := 0; n < maxBlockWindows && !start.IsZero() && start.Before(until); n++ {
+			if !b.ExpireTime.IsZero() && !start.Before(b.ExpireTime) {
+				break
+			}
+			windows = append(windows, BlockWindow{Block: *b, StartTime: start, EndTime: start.Add(b.Duration)})
+			start = sched.next(start)
+		}
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].StartTime.Before(windows[j].StartTime)
+	})
+	return windows, nil
+}
+
 func checkIsBlocked(evt *Event) error {
+	return checkIsBlockedAt(evt, time.Now(), false)
+}
+
+// checkIsBlockedAt checks whether evt would be blocked at t by the blocks
+// currently active. When scheduledOnly is true, only scheduled blocks are
+// considered, as other blocks are expected to be removed at any time.
+func checkIsBlockedAt(evt *Event, t time.Time, scheduledOnly bool) error {
 	if evt.Target.Type == TargetTypeEventBlock {
 		return nil
 	}
 	query := bson.M{"$and": []bson.M{
-		{"active": true},
+		notExpiredQuery(t),
 		{"$or": []bson.M{{"kindname": evt.Kind.Name}, {"kindname": ""}}},
 		{"$or": []bson.M{{"ownername": evt.Owner.Name}, {"ownername": ""}}},
 		{"$or": []bson.M{
@@ -125,12 +243,17 @@ func checkIsBlocked(evt *Event) error {
 			{"target": bson.M{"$exists": false}},
 			{"target.type": evt.Target.Type, "target.value": ""}}},
 	}}
-	blocks, err := listBlocks(query)
+	if scheduledOnly {
+		query["schedule"] = bson.M{"$exists": true}
+	}
+	blocks, err := findBlocks(query, 0)
 	if err != nil {
 		return err
 	}
-	if len(blocks) > 0 {
-		return &ErrEventBlocked{event: evt, block: &blocks[0]}
+	for i := range blocks {
+		if blocks[i].window(t) != nil {
+			return &ErrEventBlocked{event: evt, block: &blocks[i]}
+		}
 	}
 	return nil
 }
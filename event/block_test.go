@@ -5,9 +5,11 @@
 package event
 
 import (
+	"fmt"
 	"reflect"
 	"time"
 
+	"github.com/tsuru/tsuru/db"
 	check "gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -110,3 +112,80 @@ func (s *S) TestCheckIsBlocked(c *check.C) {
 		}
 	}
 }
+
+func (s *S) TestCheckIsBlockedIgnoresExpiredBlocks(c *check.C) {
+	block := &Block{KindName: "app.deploy", Reason: "maintenance", ExpireTime: time.Now().Add(time.Hour)}
+	err := AddBlock(block)
+	c.Assert(err, check.IsNil)
+	evt := &Event{eventData: eventData{Kind: Kind{Name: "app.deploy"}}}
+	err = checkIsBlocked(evt)
+	c.Assert(err, check.FitsTypeOf, &ErrEventBlocked{})
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.EventBlocks().UpdateId(block.ID, bson.M{"$set": bson.M{"expiretime": time.Now().Add(-time.Minute)}})
+	c.Assert(err, check.IsNil)
+	err = checkIsBlocked(evt)
+	c.Assert(err, check.IsNil)
+	active := true
+	blocks, err := ListBlocks(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(blocks, check.HasLen, 0)
+	active = false
+	blocks, err = ListBlocks(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(blocks, check.HasLen, 1)
+}
+
+func (s *S) TestCheckIsBlockedScheduledBlock(c *check.C) {
+	now := time.Now().UTC()
+	inWindow := &Block{
+		KindName: "app.deploy",
+		Reason:   "maintenance",
+		Schedule: fmt.Sprintf("%d %d * * *", now.Minute(), now.Hour()),
+		Duration: time.Hour,
+	}
+	err := AddBlock(inWindow)
+	c.Assert(err, check.IsNil)
+	later := now.Add(2 * time.Hour)
+	outOfWindow := &Block{
+		KindName: "app.update",
+		Reason:   "maintenance",
+		Schedule: fmt.Sprintf("%d %d * * *", later.Minute(), later.Hour()),
+		Duration: time.Hour,
+	}
+	err = AddBlock(outOfWindow)
+	c.Assert(err, check.IsNil)
+	err = checkIsBlocked(&Event{eventData: eventData{Kind: Kind{Name: "app.deploy"}}})
+	c.Assert(err, check.FitsTypeOf, &ErrEventBlocked{})
+	err = checkIsBlocked(&Event{eventData: eventData{Kind: Kind{Name: "app.update"}}})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestAddBlockInvalidSchedule(c *check.C) {
+	err := AddBlock(&Block{Reason: "maintenance", Schedule: "* *", Duration: time.Hour})
+	c.Assert(err, check.FitsTypeOf, ErrValidation(""))
+}
+
+func (s *S) TestListBlockWindows(c *check.C) {
+	now := time.Now().UTC()
+	next := now.Add(2 * time.Hour).Truncate(time.Minute)
+	block := &Block{
+		KindName: "app.deploy",
+		Reason:   "maintenance",
+		Schedule: fmt.Sprintf("%d %d * * *", next.Minute(), next.Hour()),
+		Duration: 30 * time.Minute,
+	}
+	err := AddBlock(block)
+	c.Assert(err, check.IsNil)
+	err = AddBlock(&Block{KindName: "app.update", Reason: "not scheduled"})
+	c.Assert(err, check.IsNil)
+	windows, err := ListBlockWindows(now.Add(49 * time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 2)
+	for i, w := range windows {
+		c.Assert(w.Block.ID, check.Equals, block.ID)
+		c.Assert(w.StartTime, check.DeepEquals, next.Add(time.Duration(i)*24*time.Hour))
+		c.Assert(w.EndTime, check.DeepEquals, w.StartTime.Add(30*time.Minute))
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const changeRateExceededKind = "change-rate-exceeded"
+
+var changeRateLimits = map[string]ChangeRateSpec{}
+
+// ChangeRateSpec limits the number of events of a kind a single owner can
+// start in a time window, protecting against mass destructive operations,
+// like removing many apps or nodes at once. Events exceeding the limit are
+// only created when explicitly confirmed (see Opts.ConfirmChangeRate) or when
+// the owner has the event-change-rate.bypass permission.
+type ChangeRateSpec struct {
+	KindName string
+	Max      int
+	Time     time.Duration
+}
+
+type ErrChangeRateExceeded struct {
+	Spec  *ChangeRateSpec
+	Owner Owner
+	Count int
+}
+
+func (err ErrChangeRateExceeded) Error() string {
+	return fmt.Sprintf("%s %q started %d %s events in the last %v, exceeding the limit of %d requires confirmation",
+		err.Owner.Type, err.Owner.Name, err.Count, err.Spec.KindName, err.Spec.Time, err.Spec.Max)
+}
+
+// SetChangeRateLimit registers the change rate limit of a kind. The limit may
+// be overridden with the event:change-rate:<kind>:max and
+// event:change-rate:<kind>:time (in seconds) config entries, a max lower than
+// zero disables the limit.
+func SetChangeRateLimit(spec ChangeRateSpec) {
+	changeRateLimits[spec.KindName] = spec
+}
+
+func getChangeRateLimit(k *Kind) *ChangeRateSpec {
+	spec, ok := changeRateLimits[k.Name]
+	if !ok {
+		return nil
+	}
+	prefix := "event:change-rate:" + k.Name
+	if max, err := config.GetInt(prefix + ":max"); err == nil {
+		spec.Max = max
+	}
+	if seconds, err := config.GetFloat(prefix + ":time"); err == nil {
+		spec.Time = time.Duration(seconds * float64(time.Second))
+	}
+	if spec.Max < 0 || spec.Time <= 0 {
+		return nil
+	}
+	return &spec
+}
+
+func checkChangeRate(coll *storage.Collection, opts *Opts, k *Kind, o *Owner) error {
+	if opts.Owner == nil {
+		return nil
+	}
+	spec := getChangeRateLimit(k)
+	if spec == nil {
+		return nil
+	}
+	if permission.Check(opts.Owner, permission.PermEventChangeRateBypass) {
+		return nil
+	}
+	count, err := coll.Find(bson.M{
+		"kind.name":  k.Name,
+		"owner.type": o.Type,
+		"owner.name": o.Name,
+		"starttime":  bson.M{"$gt": time.Now().UTC().Add(-spec.Time)},
+	}).Count()
+	if err != nil {
+		return err
+	}
+	if count < spec.Max {
+		return nil
+	}
+	if opts.ConfirmChangeRate {
+		log.Errorf("[events] %s %q confirmed %s on %s after starting %d %s events in the last %v",
+			o.Type, o.Name, k.Name, opts.Target, count, k.Name, spec.Time)
+		return nil
+	}
+	rateErr := ErrChangeRateExceeded{Spec: spec, Owner: *o, Count: count}
+	if !opts.DryRun {
+		alertChangeRateExceeded(opts.Owner, opts.Target, rateErr)
+	}
+	return rateErr
+}
+
+// alertChangeRateExceeded records an internal event targeting the owner,
+// making blocked attempts visible to admins reading user and app events.
+func alertChangeRateExceeded(t auth.Token, target Target, rateErr ErrChangeRateExceeded) {
+	log.Errorf("[events] %s, blocked %s on %s", rateErr, rateErr.Spec.KindName, target)
+	ownerTarget := Target{Type: TargetTypeUser, Value: rateErr.Owner.Name}
+	allowed := Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, rateErr.Owner.Name))
+	if t.IsAppToken() {
+		ownerTarget = Target{Type: TargetTypeApp, Value: rateErr.Owner.Name}
+		allowed = Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxApp, rateErr.Owner.Name))
+	}
+	evt, err := NewInternal(&Opts{
+		Target:       ownerTarget,
+		InternalKind: changeRateExceededKind,
+		DisableLock:  true,
+		CustomData: map[string]interface{}{
+			"kind":   rateErr.Spec.KindName,
+			"max":    rateErr.Spec.Max,
+			"time":   rateErr.Spec.Time.String(),
+			"count":  rateErr.Count,
+			"target": target,
+		},
+		Allowed: allowed,
+	})
+	if err != nil {
+		log.Errorf("[events] unable to record change rate alert: %s", err)
+		return
+	}
+	evt.Done(rateErr)
+}
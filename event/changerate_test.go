@@ -0,0 +1,114 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func setTestChangeRateLimit(spec ChangeRateSpec) func() {
+	SetChangeRateLimit(spec)
+	return func() {
+		delete(changeRateLimits, spec.KindName)
+	}
+}
+
+func (s *S) newChangeRateEvent(target string, opts Opts) (*Event, error) {
+	opts.Target = Target{Type: "app", Value: target}
+	opts.Kind = permission.PermAppDelete
+	opts.Allowed = Allowed(permission.PermAppReadEvents)
+	return New(&opts)
+}
+
+func (s *S) TestNewChangeRateExceeded(c *check.C) {
+	defer setTestChangeRateLimit(ChangeRateSpec{KindName: "app.delete", Max: 2, Time: time.Minute})()
+	_, token := permissiontest.CustomUserWithPermission(c, auth.ManagedScheme(native.NativeScheme{}), "changer", permission.Permission{
+		Scheme:  permission.PermAppDelete,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	for i := 0; i < 2; i++ {
+		evt, err := s.newChangeRateEvent(fmt.Sprintf("app%d", i), Opts{Owner: token})
+		c.Assert(err, check.IsNil)
+		evt.Done(nil)
+	}
+	_, err := s.newChangeRateEvent("app2", Opts{Owner: token})
+	c.Assert(err, check.FitsTypeOf, ErrChangeRateExceeded{})
+	c.Assert(err, check.ErrorMatches, `user "changer@groundcontrol.com" started 2 app.delete events in the last 1m0s, exceeding the limit of 2 requires confirmation`)
+	evts, err := List(&Filter{KindName: changeRateExceededKind})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target, check.Equals, Target{Type: TargetTypeUser, Value: "changer@groundcontrol.com"})
+	c.Assert(evts[0].Running, check.Equals, false)
+	c.Assert(evts[0].Error, check.Equals, err.Error())
+	var data map[string]interface{}
+	err = evts[0].StartData(&data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data["kind"], check.Equals, "app.delete")
+	c.Assert(data["count"], check.Equals, 2)
+	evt, err := s.newChangeRateEvent("app2", Opts{Owner: token, ConfirmChangeRate: true})
+	c.Assert(err, check.IsNil)
+	evt.Done(nil)
+}
+
+func (s *S) TestNewChangeRateOnlyCountsOwnerEvents(c *check.C) {
+	defer setTestChangeRateLimit(ChangeRateSpec{KindName: "app.delete", Max: 1, Time: time.Minute})()
+	evt, err := s.newChangeRateEvent("app0", Opts{RawOwner: Owner{Type: OwnerTypeUser, Name: "other@me.com"}})
+	c.Assert(err, check.IsNil)
+	evt.Done(nil)
+	_, token := permissiontest.CustomUserWithPermission(c, auth.ManagedScheme(native.NativeScheme{}), "changer", permission.Permission{
+		Scheme:  permission.PermAppDelete,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	evt, err = s.newChangeRateEvent("app1", Opts{Owner: token})
+	c.Assert(err, check.IsNil)
+	evt.Done(nil)
+	_, err = s.newChangeRateEvent("app2", Opts{Owner: token})
+	c.Assert(err, check.FitsTypeOf, ErrChangeRateExceeded{})
+}
+
+func (s *S) TestNewChangeRateBypassPermission(c *check.C) {
+	defer setTestChangeRateLimit(ChangeRateSpec{KindName: "app.delete", Max: 1, Time: time.Minute})()
+	_, token := permissiontest.CustomUserWithPermission(c, auth.ManagedScheme(native.NativeScheme{}), "changer", permission.Permission{
+		Scheme:  permission.PermEventChangeRateBypass,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	for i := 0; i < 3; i++ {
+		evt, err := s.newChangeRateEvent(fmt.Sprintf("app%d", i), Opts{Owner: token})
+		c.Assert(err, check.IsNil)
+		evt.Done(nil)
+	}
+}
+
+func (s *S) TestNewChangeRateConfigOverride(c *check.C) {
+	defer setTestChangeRateLimit(ChangeRateSpec{KindName: "app.delete", Max: 1, Time: time.Minute})()
+	config.Set("event:change-rate:app.delete:max", -1)
+	defer config.Unset("event:change-rate")
+	for i := 0; i < 3; i++ {
+		evt, err := s.newChangeRateEvent(fmt.Sprintf("app%d", i), Opts{Owner: s.token})
+		c.Assert(err, check.IsNil)
+		evt.Done(nil)
+	}
+}
+
+func (s *SchemaSuite) TestGetChangeRateLimit(c *check.C) {
+	defer setTestChangeRateLimit(ChangeRateSpec{KindName: "mykind", Max: 5, Time: time.Minute})()
+	kind := &Kind{Type: KindTypePermission, Name: "mykind"}
+	c.Assert(getChangeRateLimit(kind), check.DeepEquals, &ChangeRateSpec{KindName: "mykind", Max: 5, Time: time.Minute})
+	config.Set("event:change-rate:mykind:max", 10)
+	config.Set("event:change-rate:mykind:time", 30)
+	defer config.Unset("event:change-rate")
+	c.Assert(getChangeRateLimit(kind), check.DeepEquals, &ChangeRateSpec{KindName: "mykind", Max: 10, Time: 30 * time.Second})
+	config.Set("event:change-rate:mykind:max", -1)
+	c.Assert(getChangeRateLimit(kind), check.IsNil)
+	c.Assert(getChangeRateLimit(&Kind{Name: "other"}), check.IsNil)
+}
@@ -0,0 +1,219 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultAckTimeout    = 5 * time.Minute
+	consumeCursorRetries = 5
+)
+
+var (
+	ErrConsumerGroupRequired = ErrValidation("consumer group is required")
+	ErrInvalidConsumeBatch   = ErrValidation("consume batch size must be greater than zero")
+)
+
+// consumeDelay is the minimum time since an event finished before it's
+// handed to consumers, giving time for events finishing concurrently to be
+// stored before the group cursor moves past them.
+var consumeDelay = 5 * time.Second
+
+type consumerGroup struct {
+	Name     string `bson:"_id"`
+	EndTime  time.Time
+	UniqueID bson.ObjectId `bson:",omitempty"`
+}
+
+type consumerDelivery struct {
+	Group    string
+	EventID  bson.ObjectId
+	Deadline time.Time
+	Attempts int
+}
+
+func ackTimeout() time.Duration {
+	seconds, _ := config.GetFloat("event:consumers:ack-timeout")
+	if seconds <= 0 {
+		return defaultAckTimeout
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Consume returns up to batch finished events that were not yet acknowledged
+// by the consumer group. Each returned event must be acknowledged with Ack
+// after being processed, otherwise it will be delivered again once the ack
+// timeout (event:consumers:ack-timeout) expires. Events are delivered at
+// least once, consumers should use the event UniqueID to discard duplicates.
+//
+// Events pending redelivery are returned first, followed by new events in
+// the order they finished.
+func Consume(group string, batch int) ([]Event, error) {
+	if group == "" {
+		return nil, ErrConsumerGroupRequired
+	}
+	if batch <= 0 {
+		return nil, ErrInvalidConsumeBatch
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	evts, err := claimExpiredDeliveries(conn, group, batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(evts) < batch {
+		newEvts, err := consumeNewEvents(conn, group, batch-len(evts))
+		if err != nil {
+			return nil, err
+		}
+		evts = append(evts, newEvts...)
+	}
+	return evts, nil
+}
+
+// Ack acknowledges that the events were processed by the consumer group, so
+// they won't be delivered again. Unknown or already acknowledged ids are
+// ignored.
+func Ack(group string, ids ...bson.ObjectId) error {
+	if group == "" {
+		return ErrConsumerGroupRequired
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.EventConsumerDeliveries().RemoveAll(bson.M{"group": group, "eventid": bson.M{"$in": ids}})
+	return err
+}
+
+func claimExpiredDeliveries(conn *db.Storage, group string, batch int) ([]Event, error) {
+	var ids []bson.ObjectId
+	for len(ids) < batch {
+		now := time.Now().UTC()
+		var delivery consumerDelivery
+		_, err := conn.EventConsumerDeliveries().Find(bson.M{
+			"group":    group,
+			"deadline": bson.M{"$lte": now},
+		}).Sort("deadline").Apply(mgo.Change{
+			Update: bson.M{
+				"$set": bson.M{"deadline": now.Add(ackTimeout())},
+				"$inc": bson.M{"attempts": 1},
+			},
+		}, &delivery)
+		if err == mgo.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, delivery.EventID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return List(&Filter{
+		Raw:            bson.M{"uniqueid": bson.M{"$in": ids}},
+		IncludeRemoved: true,
+		Sort:           "endtime",
+		Limit:          len(ids),
+	})
+}
+
+func consumeNewEvents(conn *db.Storage, group string, batch int) ([]Event, error) {
+	for i := 0; i < consumeCursorRetries; i++ {
+		cursor, err := getConsumerGroup(conn, group)
+		if err != nil {
+			return nil, err
+		}
+		evts, err := eventsAfter(cursor, batch)
+		if err != nil || len(evts) == 0 {
+			return nil, err
+		}
+		deadline := time.Now().UTC().Add(ackTimeout())
+		for j := range evts {
+			err = conn.EventConsumerDeliveries().Insert(consumerDelivery{
+				Group:    group,
+				EventID:  evts[j].UniqueID,
+				Deadline: deadline,
+				Attempts: 1,
+			})
+			if err != nil && !mgo.IsDup(err) {
+				return nil, err
+			}
+		}
+		last := &evts[len(evts)-1]
+		query := bson.M{"_id": group, "endtime": cursor.EndTime}
+		if cursor.UniqueID == "" {
+			query["uniqueid"] = bson.M{"$exists": false}
+		} else {
+			query["uniqueid"] = cursor.UniqueID
+		}
+		err = conn.EventConsumerGroups().Update(query, bson.M{
+			"$set": bson.M{"endtime": last.EndTime, "uniqueid": last.UniqueID},
+		})
+		if err == mgo.ErrNotFound {
+			// Another consumer moved the cursor first, the deliveries
+			// inserted above will be handed out by the other consumer or
+			// redelivered after the ack timeout.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return evts, nil
+	}
+	return nil, nil
+}
+
+func getConsumerGroup(conn *db.Storage, group string) (*consumerGroup, error) {
+	var cg consumerGroup
+	err := conn.EventConsumerGroups().FindId(group).One(&cg)
+	if err == mgo.ErrNotFound {
+		cg = consumerGroup{Name: group}
+		err = conn.EventConsumerGroups().Insert(cg)
+		if mgo.IsDup(err) {
+			err = conn.EventConsumerGroups().FindId(group).One(&cg)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cg, nil
+}
+
+func eventsAfter(cursor *consumerGroup, limit int) ([]Event, error) {
+	endTimeQuery := bson.M{"$lte": time.Now().UTC().Add(-consumeDelay)}
+	query := bson.M{
+		"running": false,
+		"endtime": endTimeQuery,
+	}
+	if cursor.UniqueID != "" {
+		endTimeQuery["$gte"] = cursor.EndTime
+		query["$or"] = []bson.M{
+			{"endtime": bson.M{"$gt": cursor.EndTime}},
+			{"uniqueid": bson.M{"$gt": cursor.UniqueID}},
+		}
+	}
+	return List(&Filter{
+		Raw:            query,
+		IncludeRemoved: true,
+		Sort:           "endtime",
+		Limit:          limit,
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	check "gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newConsumableEvents(c *check.C, n int) []*Event {
+	evts := make([]*Event, n)
+	for i := range evts {
+		evt, err := NewInternal(&Opts{
+			Target:       Target{Type: "app", Value: "myapp"},
+			InternalKind: "healer",
+			Allowed:      Allowed(permission.PermAppReadEvents),
+			DisableLock:  true,
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.Done(nil)
+		c.Assert(err, check.IsNil)
+		evts[i] = evt
+	}
+	return evts
+}
+
+func setConsumeDelay(d time.Duration) func() {
+	old := consumeDelay
+	consumeDelay = d
+	return func() { consumeDelay = old }
+}
+
+func consumedIDs(evts []Event) []bson.ObjectId {
+	ids := make([]bson.ObjectId, len(evts))
+	for i := range evts {
+		ids[i] = evts[i].UniqueID
+	}
+	return ids
+}
+
+func (s *S) TestConsume(c *check.C) {
+	defer setConsumeDelay(0)()
+	evts := s.newConsumableEvents(c, 3)
+	running, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "otherapp"},
+		InternalKind: "healer",
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer running.Done(nil)
+	consumed, err := Consume("billing", 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumedIDs(consumed), check.DeepEquals, []bson.ObjectId{evts[0].UniqueID, evts[1].UniqueID})
+	consumed, err = Consume("billing", 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumedIDs(consumed), check.DeepEquals, []bson.ObjectId{evts[2].UniqueID})
+	consumed, err = Consume("billing", 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumed, check.HasLen, 0)
+	consumed, err = Consume("cmdb", 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumed, check.HasLen, 3)
+}
+
+func (s *S) TestConsumeRedeliversUnacked(c *check.C) {
+	defer setConsumeDelay(0)()
+	evts := s.newConsumableEvents(c, 2)
+	consumed, err := Consume("billing", 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumed, check.HasLen, 2)
+	err = Ack("billing", evts[0].UniqueID)
+	c.Assert(err, check.IsNil)
+	consumed, err = Consume("billing", 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumed, check.HasLen, 0)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	_, err = conn.EventConsumerDeliveries().UpdateAll(bson.M{"group": "billing"}, bson.M{
+		"$set": bson.M{"deadline": time.Now().Add(-time.Second)},
+	})
+	c.Assert(err, check.IsNil)
+	more := s.newConsumableEvents(c, 1)
+	consumed, err = Consume("billing", 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumedIDs(consumed), check.DeepEquals, []bson.ObjectId{evts[1].UniqueID, more[0].UniqueID})
+	var delivery consumerDelivery
+	err = conn.EventConsumerDeliveries().Find(bson.M{"group": "billing", "eventid": evts[1].UniqueID}).One(&delivery)
+	c.Assert(err, check.IsNil)
+	c.Assert(delivery.Attempts, check.Equals, 2)
+	err = Ack("billing", evts[1].UniqueID, more[0].UniqueID)
+	c.Assert(err, check.IsNil)
+	n, err := conn.EventConsumerDeliveries().Find(bson.M{"group": "billing"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestConsumeAckTimeout(c *check.C) {
+	defer setConsumeDelay(0)()
+	config.Set("event:consumers:ack-timeout", 120)
+	defer config.Unset("event:consumers:ack-timeout")
+	evts := s.newConsumableEvents(c, 1)
+	before := time.Now()
+	_, err := Consume("billing", 1)
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	var delivery consumerDelivery
+	err = conn.EventConsumerDeliveries().Find(bson.M{"group": "billing", "eventid": evts[0].UniqueID}).One(&delivery)
+	c.Assert(err, check.IsNil)
+	c.Assert(delivery.Deadline.After(before.Add(119*time.Second)), check.Equals, true)
+	c.Assert(delivery.Deadline.Before(time.Now().Add(121*time.Second)), check.Equals, true)
+}
+
+func (s *S) TestConsumeRespectsDelay(c *check.C) {
+	defer setConsumeDelay(time.Hour)()
+	s.newConsumableEvents(c, 1)
+	consumed, err := Consume("billing", 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumed, check.HasLen, 0)
+}
+
+func (s *S) TestConsumeInvalidArgs(c *check.C) {
+	_, err := Consume("", 10)
+	c.Assert(err, check.Equals, ErrConsumerGroupRequired)
+	_, err = Consume("billing", 0)
+	c.Assert(err, check.Equals, ErrInvalidConsumeBatch)
+	err = Ack("", bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrConsumerGroupRequired)
+}
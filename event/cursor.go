@@ -0,0 +1,71 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrInvalidCursor     = ErrValidation("invalid event cursor")
+	ErrInvalidCursorSort = ErrValidation("event cursor can only be used when sorting by starttime")
+)
+
+type cursor struct {
+	startTime time.Time
+	uniqueID  bson.ObjectId
+}
+
+// Cursor returns an opaque token that can be set in Filter.Cursor to list
+// the events following this one, regardless of events that were created
+// since the listing started.
+func (e *Event) Cursor() string {
+	raw := strconv.FormatInt(e.StartTime.UnixNano(), 10) + ":" + e.UniqueID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(value string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || !bson.IsObjectIdHex(parts[1]) {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{
+		startTime: time.Unix(0, nanos).UTC(),
+		uniqueID:  bson.ObjectIdHex(parts[1]),
+	}, nil
+}
+
+// query returns the condition matching the events after the cursor in the
+// given sort order.
+func (c *cursor) query(sort string) (bson.M, error) {
+	var op string
+	switch sort {
+	case "", "-starttime":
+		op = "$lt"
+	case "starttime":
+		op = "$gt"
+	default:
+		return nil, ErrInvalidCursorSort
+	}
+	// MongoDB stores dates with millisecond precision.
+	startTime := c.startTime.Truncate(time.Millisecond)
+	return bson.M{"$or": []bson.M{
+		{"starttime": bson.M{op: startTime}},
+		{"starttime": startTime, "uniqueid": bson.M{op: c.uniqueID}},
+	}}, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrEventsNotComparable = errors.New("only events with the same kind and target can be compared")
+
+// DataChange is a difference between the custom data of two events. Path is
+// the dotted path of the changed value, where lists of name and value pairs,
+// like the ones created by FormToCustomData, are indexed by name. Old is nil
+// for added values and New is nil for removed values.
+type DataChange struct {
+	Path string
+	Old  interface{} `json:",omitempty"`
+	New  interface{} `json:",omitempty"`
+}
+
+// Diff holds the differences between the custom data of two events of the
+// same kind and target.
+type Diff struct {
+	Kind            Kind
+	Target          Target
+	Event           string
+	Other           string
+	StartCustomData []DataChange
+	EndCustomData   []DataChange
+}
+
+// Compare diffs the start and end custom data of two events of the same kind
+// and target, e.g. showing which environment variables changed between two
+// app updates. Values in the first event are reported as old ones.
+func Compare(id, otherID bson.ObjectId) (*Diff, error) {
+	evt, err := GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	other, err := GetByID(otherID)
+	if err != nil {
+		return nil, err
+	}
+	return evt.Diff(other)
+}
+
+// Diff compares the custom data of the event with the custom data of other,
+// see Compare.
+func (e *Event) Diff(other *Event) (*Diff, error) {
+	if e.Kind != other.Kind || e.Target != other.Target {
+		return nil, ErrEventsNotComparable
+	}
+	startChanges, err := compareRaw(e.StartCustomData, other.StartCustomData)
+	if err != nil {
+		return nil, err
+	}
+	endChanges, err := compareRaw(e.EndCustomData, other.EndCustomData)
+	if err != nil {
+		return nil, err
+	}
+	return &Diff{
+		Kind:            e.Kind,
+		Target:          e.Target,
+		Event:           e.UniqueID.Hex(),
+		Other:           other.UniqueID.Hex(),
+		StartCustomData: startChanges,
+		EndCustomData:   endChanges,
+	}, nil
+}
+
+func compareRaw(raw, otherRaw bson.Raw) ([]DataChange, error) {
+	values, err := flattenRaw(raw)
+	if err != nil {
+		return nil, err
+	}
+	otherValues, err := flattenRaw(otherRaw)
+	if err != nil {
+		return nil, err
+	}
+	var changes []DataChange
+	for path, v := range values {
+		otherV, ok := otherValues[path]
+		if !ok {
+			changes = append(changes, DataChange{Path: path, Old: v})
+		} else if !reflect.DeepEqual(v, otherV) {
+			changes = append(changes, DataChange{Path: path, Old: v, New: otherV})
+		}
+	}
+	for path, otherV := range otherValues {
+		if _, ok := values[path]; !ok {
+			changes = append(changes, DataChange{Path: path, New: otherV})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func flattenRaw(raw bson.Raw) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if raw.Kind == 0 {
+		return values, nil
+	}
+	var data interface{}
+	err := raw.Unmarshal(&data)
+	if err != nil {
+		return nil, err
+	}
+	flatten("", data, values)
+	return values, nil
+}
+
+func flatten(prefix string, data interface{}, values map[string]interface{}) {
+	switch v := data.(type) {
+	case bson.M:
+		for key, value := range v {
+			flatten(joinPath(prefix, key), value, values)
+		}
+	case []interface{}:
+		if named, ok := namedValues(v); ok {
+			for name, value := range named {
+				flatten(joinPath(prefix, name), value, values)
+			}
+			return
+		}
+		for i, value := range v {
+			flatten(joinPath(prefix, fmt.Sprint(i)), value, values)
+		}
+	default:
+		values[prefix] = v
+	}
+}
+
+// namedValues converts a list of documents with only name and value keys to
+// a map, so changes in the order of the list are not reported. Repeated
+// names are kept as a list of values.
+func namedValues(list []interface{}) (map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	named := map[string]interface{}{}
+	for _, item := range list {
+		doc, ok := item.(bson.M)
+		if !ok || len(doc) != 2 {
+			return nil, false
+		}
+		name, ok := doc["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		value, ok := doc["value"]
+		if !ok {
+			return nil, false
+		}
+		if existing, ok := named[name]; ok {
+			if l, isList := existing.([]interface{}); isList {
+				named[name] = append(l, value)
+			} else {
+				named[name] = []interface{}{existing, value}
+			}
+			continue
+		}
+		named[name] = value
+	}
+	return named, true
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"net/url"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func newDiffEvent(c *check.C, start, end interface{}) *Event {
+	startRaw, err := makeBSONRaw(start, nil)
+	c.Assert(err, check.IsNil)
+	endRaw, err := makeBSONRaw(end, nil)
+	c.Assert(err, check.IsNil)
+	return &Event{eventData: eventData{
+		UniqueID:        bson.NewObjectId(),
+		Target:          Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:            Kind{Type: KindTypePermission, Name: "app.update.env.set"},
+		StartCustomData: startRaw,
+		EndCustomData:   endRaw,
+	}}
+}
+
+func (s *SchemaSuite) TestEventDiff(c *check.C) {
+	evt := newDiffEvent(c, FormToCustomData(url.Values{
+		"Envs.0.Name":  {"FOO"},
+		"Envs.0.Value": {"1"},
+		"Envs.1.Name":  {"BAR"},
+		"Private":      {"false"},
+		"teams":        {"t1", "t2"},
+	}), map[string]interface{}{"units": 2, "router": map[string]string{"name": "r1"}})
+	other := newDiffEvent(c, FormToCustomData(url.Values{
+		"Envs.0.Name":  {"FOO"},
+		"Envs.0.Value": {"2"},
+		"Envs.1.Name":  {"BAR"},
+		"NoRestart":    {"true"},
+		"teams":        {"t1", "t3"},
+	}), map[string]interface{}{"units": 2, "router": map[string]string{"name": "r2"}})
+	diff, err := evt.Diff(other)
+	c.Assert(err, check.IsNil)
+	c.Assert(diff, check.DeepEquals, &Diff{
+		Kind:   evt.Kind,
+		Target: evt.Target,
+		Event:  evt.UniqueID.Hex(),
+		Other:  other.UniqueID.Hex(),
+		StartCustomData: []DataChange{
+			{Path: "Envs.0.Value", Old: "1", New: "2"},
+			{Path: "NoRestart", New: "true"},
+			{Path: "Private", Old: "false"},
+			{Path: "teams.1", Old: "t2", New: "t3"},
+		},
+		EndCustomData: []DataChange{
+			{Path: "router.name", Old: "r1", New: "r2"},
+		},
+	})
+}
+
+func (s *SchemaSuite) TestEventDiffNoCustomData(c *check.C) {
+	evt := newDiffEvent(c, nil, nil)
+	other := newDiffEvent(c, map[string]string{"image": "v1"}, nil)
+	diff, err := evt.Diff(other)
+	c.Assert(err, check.IsNil)
+	c.Assert(diff.StartCustomData, check.DeepEquals, []DataChange{{Path: "image", New: "v1"}})
+	c.Assert(diff.EndCustomData, check.IsNil)
+}
+
+func (s *SchemaSuite) TestEventDiffNotComparable(c *check.C) {
+	evt := newDiffEvent(c, nil, nil)
+	other := newDiffEvent(c, nil, nil)
+	other.Target.Value = "otherapp"
+	_, err := evt.Diff(other)
+	c.Assert(err, check.Equals, ErrEventsNotComparable)
+	other = newDiffEvent(c, nil, nil)
+	other.Kind.Name = "app.update.env.unset"
+	_, err = evt.Diff(other)
+	c.Assert(err, check.Equals, ErrEventsNotComparable)
+}
+
+func (s *S) TestCompare(c *check.C) {
+	var ids []bson.ObjectId
+	for _, image := range []string{"v1", "v2"} {
+		evt, err := New(&Opts{
+			Target:     Target{Type: "app", Value: "myapp"},
+			Kind:       permission.PermAppDeploy,
+			Owner:      s.token,
+			CustomData: map[string]string{"image": image},
+			Allowed:    Allowed(permission.PermAppReadEvents),
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.Done(nil)
+		c.Assert(err, check.IsNil)
+		ids = append(ids, evt.UniqueID)
+	}
+	diff, err := Compare(ids[0], ids[1])
+	c.Assert(err, check.IsNil)
+	c.Assert(diff.StartCustomData, check.DeepEquals, []DataChange{{Path: "image", Old: "v1", New: "v2"}})
+	_, err = Compare(ids[0], bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrEventNotFound)
+}
@@ -0,0 +1,43 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+)
+
+// DryRun reports whether the event was created with Opts.DryRun, in which
+// case it was not stored and holds no lock.
+func (e *Event) DryRun() bool {
+	return e.dryRun
+}
+
+// checkDryRun runs the checks done when inserting the event, returning the
+// error its insertion would return. Unlike insertEvt, expired locks are not
+// released, the event would simply take them over.
+func checkDryRun(coll *storage.Collection, evt *Event) error {
+	if len(evt.ID.ObjId) == 0 {
+		var existing Event
+		err := coll.FindId(evt.ID).One(&existing.eventData)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		if err == nil && !lockExpired(&existing) {
+			return ErrEventLocked{event: &existing}
+		}
+	}
+	return checkIsBlocked(evt)
+}
+
+func lockExpired(evt *Event) bool {
+	lastUpdate := evt.LockUpdateTime.UTC()
+	if time.Now().UTC().After(lastUpdate.Add(lockExpireTimeout)) {
+		return true
+	}
+	return evt.Running && isLockOwnerDead(evt.Target, lastUpdate)
+}
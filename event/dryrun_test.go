@@ -0,0 +1,150 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNewDryRun(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		DryRun:  true,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.DryRun(), check.Equals, true)
+	c.Assert(evt.Kind.Name, check.Equals, "app.deploy")
+	c.Assert(evt.Owner, check.DeepEquals, Owner{Type: OwnerTypeUser, Name: "me@me.com"})
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evts, err = All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+	evt, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.DryRun(), check.Equals, false)
+}
+
+func (s *S) TestNewDryRunValidation(c *check.C) {
+	_, err := New(&Opts{
+		Target: Target{Type: "app", Value: "myapp"},
+		Kind:   permission.PermAppDeploy,
+		Owner:  s.token,
+		DryRun: true,
+	})
+	c.Assert(err, check.Equals, ErrNoAllowed)
+}
+
+func (s *S) TestNewDryRunLocked(c *check.C) {
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		DryRun:  true,
+	})
+	c.Assert(err, check.FitsTypeOf, ErrEventLocked{})
+	evt, err := New(&Opts{
+		Target:      Target{Type: "app", Value: "myapp"},
+		Kind:        permission.PermAppDeploy,
+		Owner:       s.token,
+		Allowed:     Allowed(permission.PermAppReadEvents),
+		DisableLock: true,
+		DryRun:      true,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.DryRun(), check.Equals, true)
+}
+
+func (s *S) TestNewDryRunLockExpired(c *check.C) {
+	oldLockExpire := lockExpireTimeout
+	lockExpireTimeout = time.Millisecond
+	defer func() {
+		lockExpireTimeout = oldLockExpire
+	}()
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	updater.stop()
+	time.Sleep(100 * time.Millisecond)
+	_, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		DryRun:  true,
+	})
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, true)
+}
+
+func (s *S) TestNewDryRunBlocked(c *check.C) {
+	err := AddBlock(&Block{KindName: "app.deploy", Reason: "you shall not pass"})
+	c.Assert(err, check.IsNil)
+	_, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		DryRun:  true,
+	})
+	c.Assert(err, check.FitsTypeOf, &ErrEventBlocked{})
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestNewDryRunThrottled(c *check.C) {
+	SetThrottling(ThrottlingSpec{
+		TargetType: TargetTypeApp,
+		Time:       time.Hour,
+		Max:        1,
+	})
+	opts := Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		DryRun:  true,
+	}
+	_, err := New(&opts)
+	c.Assert(err, check.IsNil)
+	opts.DryRun = false
+	evt, err := New(&opts)
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	opts.DryRun = true
+	_, err = New(&opts)
+	c.Assert(err, check.FitsTypeOf, ErrThrottled{})
+}
@@ -5,6 +5,7 @@
 package event
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -38,38 +39,54 @@ var (
 	ErrNotCancelable     = errors.New("event is not cancelable")
 	ErrEventNotFound     = errors.New("event not found")
 	ErrNoTarget          = ErrValidation("event target is mandatory")
+	ErrNoExtraTargetType = ErrValidation("event extra target type is mandatory")
 	ErrNoKind            = ErrValidation("event kind is mandatory")
 	ErrNoOwner           = ErrValidation("event owner is mandatory")
 	ErrNoOpts            = ErrValidation("event opts is mandatory")
 	ErrNoInternalKind    = ErrValidation("event internal kind is mandatory")
 	ErrNoAllowed         = errors.New("event allowed is mandatory")
 	ErrNoAllowedCancel   = errors.New("event allowed cancel is mandatory for cancelable events")
+	ErrNoAllowedApprove  = errors.New("event allowed approve is mandatory for events requiring approval")
 	ErrInvalidOwner      = ErrValidation("event owner must not be set on internal events")
+	ErrNoCancelReason    = ErrValidation("reason is mandatory")
 	ErrInvalidKind       = ErrValidation("event kind must not be set on internal events")
 	ErrInvalidTargetType = errors.New("invalid event target type")
 
 	OwnerTypeUser     = ownerType("user")
 	OwnerTypeApp      = ownerType("app")
 	OwnerTypeInternal = ownerType("internal")
+	OwnerTypeTeam     = ownerType("team")
 
 	KindTypePermission = kindType("permission")
 	KindTypeInternal   = kindType("internal")
 
-	TargetTypeApp             = TargetType("app")
-	TargetTypeNode            = TargetType("node")
-	TargetTypeContainer       = TargetType("container")
-	TargetTypePool            = TargetType("pool")
-	TargetTypeService         = TargetType("service")
-	TargetTypeServiceInstance = TargetType("service-instance")
-	TargetTypeTeam            = TargetType("team")
-	TargetTypeUser            = TargetType("user")
-	TargetTypeIaas            = TargetType("iaas")
-	TargetTypeRole            = TargetType("role")
-	TargetTypePlatform        = TargetType("platform")
-	TargetTypePlan            = TargetType("plan")
-	TargetTypeNodeContainer   = TargetType("node-container")
-	TargetTypeInstallHost     = TargetType("install-host")
-	TargetTypeEventBlock      = TargetType("event-block")
+	TargetTypeApp                = TargetType("app")
+	TargetTypeNode               = TargetType("node")
+	TargetTypeContainer          = TargetType("container")
+	TargetTypePool               = TargetType("pool")
+	TargetTypeService            = TargetType("service")
+	TargetTypeServiceInstance    = TargetType("service-instance")
+	TargetTypeTeam               = TargetType("team")
+	TargetTypeUser               = TargetType("user")
+	TargetTypeIaas               = TargetType("iaas")
+	TargetTypeRole               = TargetType("role")
+	TargetTypePlatform           = TargetType("platform")
+	TargetTypePlan               = TargetType("plan")
+	TargetTypeNodeContainer      = TargetType("node-container")
+	TargetTypeInstallHost        = TargetType("install-host")
+	TargetTypeEventBlock         = TargetType("event-block")
+	TargetTypeEventLegalHold     = TargetType("event-legal-hold")
+	TargetTypeNodePoolRule       = TargetType("node-pool-rule")
+	TargetTypeMigration          = TargetType("migration")
+	TargetTypeMotd               = TargetType("motd")
+	TargetTypeReadOnly           = TargetType("readonly")
+	TargetTypeCredentialRotation = TargetType("credential-rotation")
+	TargetTypeStorage            = TargetType("storage")
+	TargetTypeStatusPageIncident = TargetType("status-page-incident")
+	TargetTypeEventKindAlias     = TargetType("event-kind-alias")
+	TargetTypeGroup              = TargetType("group")
+	TargetTypeRemoteAddr         = TargetType("remote-addr")
+	TargetTypeJob                = TargetType("job")
 )
 
 const (
@@ -147,14 +164,18 @@ type eventData struct {
 	StartTime       time.Time
 	EndTime         time.Time `bson:",omitempty"`
 	Target          Target    `bson:",omitempty"`
+	ExtraTargets    []Target  `bson:",omitempty"`
 	StartCustomData bson.Raw  `bson:",omitempty"`
 	EndCustomData   bson.Raw  `bson:",omitempty"`
 	OtherCustomData bson.Raw  `bson:",omitempty"`
 	Kind            Kind
 	Owner           Owner
+	ActingOwner     Owner `bson:",omitempty"`
 	LockUpdateTime  time.Time
+	ScheduledFor    time.Time `bson:",omitempty"`
 	Error           string
 	Log             string    `bson:",omitempty"`
+	LogOverflow     string    `bson:",omitempty"`
 	RemoveDate      time.Time `bson:",omitempty"`
 	CancelInfo      cancelInfo
 	Cancelable      bool
@@ -248,6 +269,13 @@ type Event struct {
 	eventData
 	logBuffer safe.Buffer
 	logWriter io.Writer
+	phasesMu  sync.Mutex
+	phases    []Phase
+	dryRun    bool
+	scheduled bool
+	logLimits logLimits
+	service   Service
+	ctx       context.Context
 }
 
 type Opts struct {
@@ -261,6 +289,46 @@ type Opts struct {
 	Cancelable    bool
 	Allowed       AllowedPermission
 	AllowedCancel AllowedPermission
+	// WaitLock, when set, makes the event wait up to this duration for the
+	// target lock held by another event, in FIFO order with other waiting
+	// events, instead of failing right away with ErrEventLocked.
+	WaitLock time.Duration
+	// Context, when set, is the context of the operation recording the
+	// event, usually the context of the HTTP request. Events are not
+	// created after it's done and waiting for the target lock is aborted
+	// when it's canceled or its deadline expires. The operation gets it
+	// back from Event.Context.
+	Context context.Context
+	// ConfirmChangeRate allows the event to be created even if its owner
+	// exceeded the change rate limit of the kind.
+	ConfirmChangeRate bool
+	// ExtraTargets are other targets affected by the operation, e.g. the
+	// apps rebalanced by a node removal. Filtering events by target also
+	// matches extra targets, but only the main target is locked.
+	ExtraTargets []Target
+	// DryRun runs all validations and the permission, throttling, change
+	// rate, lock and block checks without inserting the event or locking
+	// its target. The returned event is not stored, finishing it is a
+	// no-op.
+	DryRun bool
+	// RunAt, when set, schedules the event to be started at the given time
+	// by the executor registered for its kind, see SetScheduledExecutor.
+	// The returned event is a placeholder holding the id of the scheduled
+	// event, finishing it is a no-op.
+	RunAt time.Time
+	// ActingOwner is the effective owner of the operation when the
+	// authenticated owner acts on behalf of someone else, e.g. an admin
+	// running a support operation for a team. It's taken from the
+	// impersonated team when Owner is an *auth.ImpersonationToken.
+	ActingOwner Owner
+	// RequireApproval schedules the event, at RunAt or as soon as possible,
+	// only to be started after being approved by a user other than its
+	// owner, see ApproveScheduled. AllowedApprove is the permission
+	// required to approve it.
+	RequireApproval bool
+	AllowedApprove  AllowedPermission
+
+	scheduledFor time.Time
 }
 
 func Allowed(scheme *permission.PermissionScheme, contexts ...permission.PermissionContext) AllowedPermission {
@@ -305,29 +373,38 @@ type TargetFilter struct {
 }
 
 type Filter struct {
-	Target         Target
-	KindType       kindType
-	KindName       string
-	OwnerType      ownerType
-	OwnerName      string
-	Since          time.Time
-	Until          time.Time
-	Running        *bool
-	IncludeRemoved bool
-	ErrorOnly      bool
-	Raw            bson.M
-	AllowedTargets []TargetFilter
-	Permissions    []permission.Permission
-
-	Limit int
-	Skip  int
-	Sort  string
+	Target          Target
+	KindType        kindType
+	KindName        string
+	OwnerType       ownerType
+	OwnerName       string
+	ActingOwnerType ownerType
+	ActingOwnerName string
+	Impersonated    *bool
+	Since           time.Time
+	Until           time.Time
+	Running         *bool
+	IncludeRemoved  bool
+	ErrorOnly       bool
+	Raw             bson.M
+	AllowedTargets  []TargetFilter
+	Permissions     []permission.Permission
+	// Targets restricts the events to the ones whose main target is one of
+	// the targets, no events match an empty non nil list. It's set by the
+	// API, like from the labels of apps and pools.
+	Targets []Target
+
+	Limit  int
+	Skip   int
+	Sort   string
+	Cursor string
 }
 
 func (f *Filter) PruneUserValues() {
 	f.Raw = nil
 	f.AllowedTargets = nil
 	f.Permissions = nil
+	f.Targets = nil
 	if f.Limit > filterMaxLimit || f.Limit <= 0 {
 		f.Limit = filterMaxLimit
 	}
@@ -377,11 +454,36 @@ func (f *Filter) toQuery() (bson.M, error) {
 		}
 		query["$or"] = orBlock
 	}
-	if f.Target.Type != "" {
-		query["target.type"] = f.Target.Type
-	}
-	if f.Target.Value != "" {
-		query["target.value"] = f.Target.Value
+	var andBlock []bson.M
+	if f.Targets != nil {
+		valuesByType := map[TargetType][]string{}
+		for _, t := range f.Targets {
+			valuesByType[t.Type] = append(valuesByType[t.Type], t.Value)
+		}
+		var orBlock []bson.M
+		for targetType, values := range valuesByType {
+			orBlock = append(orBlock, bson.M{"target.type": targetType, "target.value": bson.M{"$in": values}})
+		}
+		if len(orBlock) == 0 {
+			return nil, errInvalidQuery
+		}
+		andBlock = append(andBlock, bson.M{"$or": orBlock})
+	}
+	if f.Target.Type != "" || f.Target.Value != "" {
+		mainTarget := bson.M{}
+		extraTarget := bson.M{}
+		if f.Target.Type != "" {
+			mainTarget["target.type"] = f.Target.Type
+			extraTarget["type"] = f.Target.Type
+		}
+		if f.Target.Value != "" {
+			mainTarget["target.value"] = f.Target.Value
+			extraTarget["value"] = f.Target.Value
+		}
+		andBlock = append(andBlock, bson.M{"$or": []bson.M{
+			mainTarget,
+			{"extratargets": bson.M{"$elemMatch": extraTarget}},
+		}})
 	}
 	if f.KindType != "" {
 		query["kind.type"] = f.KindType
@@ -395,15 +497,34 @@ func (f *Filter) toQuery() (bson.M, error) {
 	if f.OwnerName != "" {
 		query["owner.name"] = f.OwnerName
 	}
-	var timeParts []bson.M
+	if f.ActingOwnerType != "" {
+		query["actingowner.type"] = f.ActingOwnerType
+	}
+	if f.ActingOwnerName != "" {
+		query["actingowner.name"] = f.ActingOwnerName
+	}
+	if f.Impersonated != nil {
+		query["actingowner"] = bson.M{"$exists": *f.Impersonated}
+	}
 	if !f.Since.IsZero() {
-		timeParts = append(timeParts, bson.M{"starttime": bson.M{"$gte": f.Since}})
+		andBlock = append(andBlock, bson.M{"starttime": bson.M{"$gte": f.Since}})
 	}
 	if !f.Until.IsZero() {
-		timeParts = append(timeParts, bson.M{"starttime": bson.M{"$lte": f.Until}})
+		andBlock = append(andBlock, bson.M{"starttime": bson.M{"$lte": f.Until}})
 	}
-	if len(timeParts) != 0 {
-		query["$and"] = timeParts
+	if f.Cursor != "" {
+		c, err := parseCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursorQuery, err := c.query(f.Sort)
+		if err != nil {
+			return nil, err
+		}
+		andBlock = append(andBlock, cursorQuery)
+	}
+	if len(andBlock) != 0 {
+		query["$and"] = andBlock
 	}
 	if f.Running != nil {
 		query["running"] = *f.Running
@@ -422,6 +543,63 @@ func (f *Filter) toQuery() (bson.M, error) {
 	return query, nil
 }
 
+// HasTarget returns whether the main target or one of the extra targets of
+// the event matches t, empty type and value matching anything. It's the in
+// memory equivalent of the Target field of Filter.
+func (e *Event) HasTarget(t Target) bool {
+	if targetMatches(e.Target, t) {
+		return true
+	}
+	for _, extra := range e.ExtraTargets {
+		if targetMatches(extra, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func targetMatches(target, filter Target) bool {
+	return (filter.Type == "" || target.Type == filter.Type) &&
+		(filter.Value == "" || target.Value == filter.Value)
+}
+
+// VisibleTo returns whether the event would be returned by List to a token
+// with the given permissions, i.e., it's the in memory equivalent of the
+// Permissions field of Filter.
+func (e *Event) VisibleTo(perms []permission.Permission) bool {
+	for _, p := range perms {
+		if !strings.HasPrefix(e.Allowed.Scheme, p.Scheme.FullName()) {
+			continue
+		}
+		if p.Context.CtxType == permission.CtxGlobal {
+			return true
+		}
+		for _, ctx := range e.Allowed.Contexts {
+			if ctx == p.Context {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibleToTeams returns whether the event is allowed in the context of any
+// team the permissions are granted on. Permissions in the global context
+// grant access to all teams.
+func (e *Event) visibleToTeams(perms []permission.Permission) bool {
+	for _, ctx := range e.Allowed.Contexts {
+		if ctx.CtxType != permission.CtxTeam {
+			continue
+		}
+		for _, p := range perms {
+			if p.Context.CtxType == permission.CtxGlobal || p.Context == ctx {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func GetKinds() ([]Kind, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -438,6 +616,9 @@ func GetKinds() ([]Kind, error) {
 }
 
 func GetRunning(target Target, kind string) (*Event, error) {
+	if svc := GetService(); svc != nil {
+		return svc.GetRunning(target, kind)
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return nil, err
@@ -460,6 +641,9 @@ func GetRunning(target Target, kind string) (*Event, error) {
 }
 
 func GetByID(id bson.ObjectId) (*Event, error) {
+	if svc := GetService(); svc != nil {
+		return svc.Get(id)
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return nil, err
@@ -484,6 +668,9 @@ func All() ([]Event, error) {
 }
 
 func List(filter *Filter) ([]Event, error) {
+	if svc := GetService(); svc != nil {
+		return listService(svc, filter)
+	}
 	limit := 0
 	skip := 0
 	var query bson.M
@@ -514,7 +701,14 @@ func List(filter *Filter) ([]Event, error) {
 	}
 	defer conn.Close()
 	coll := conn.Events()
-	find := coll.Find(query).Sort(sort)
+	sortFields := []string{sort}
+	switch sort {
+	case "-starttime":
+		sortFields = append(sortFields, "-uniqueid")
+	case "starttime", "endtime":
+		sortFields = append(sortFields, "uniqueid")
+	}
+	find := coll.Find(query).Sort(sortFields...)
 	if limit > 0 {
 		find = find.Limit(limit)
 	}
@@ -548,6 +742,45 @@ func MarkAsRemoved(target Target) error {
 	return err
 }
 
+// RenameTeam updates the finished events targeting a team, or allowed by
+// permissions on it, to reference the team by its new name.
+func RenameTeam(oldName, newName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Events()
+	_, err = coll.UpdateAll(bson.M{
+		"target.type":  TargetTypeTeam,
+		"target.value": oldName,
+		"running":      false,
+	}, bson.M{"$set": bson.M{"target.value": newName}})
+	if err != nil {
+		return err
+	}
+	elemQueries := map[string]bson.M{
+		"extratargets":           {"type": TargetTypeTeam, "value": oldName},
+		"allowed.contexts":       {"ctxtype": permission.CtxTeam, "value": oldName},
+		"allowedcancel.contexts": {"ctxtype": permission.CtxTeam, "value": oldName},
+	}
+	for field, elem := range elemQueries {
+		for {
+			info, err := coll.UpdateAll(bson.M{
+				field:     bson.M{"$elemMatch": elem},
+				"running": false,
+			}, bson.M{"$set": bson.M{field + ".$.value": newName}})
+			if err != nil {
+				return err
+			}
+			if info.Updated == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
 func New(opts *Opts) (*Event, error) {
 	if opts == nil {
 		return nil, ErrNoOpts
@@ -577,7 +810,9 @@ func NewInternal(opts *Opts) (*Event, error) {
 	return newEvt(opts)
 }
 
-func makeBSONRaw(in interface{}) (bson.Raw, error) {
+// makeBSONRaw converts custom data to bson.Raw, validating it against schema
+// when it's not nil.
+func makeBSONRaw(in interface{}, schema *Schema) (bson.Raw, error) {
 	if in == nil {
 		return bson.Raw{}, nil
 	}
@@ -604,26 +839,47 @@ func makeBSONRaw(in interface{}) (bson.Raw, error) {
 	if len(data) == 0 {
 		return bson.Raw{}, errors.Errorf("invalid empty bson object for object %#v", in)
 	}
-	return bson.Raw{
+	raw := bson.Raw{
 		Kind: kind,
 		Data: data,
-	}, nil
+	}
+	err = validateRaw(schema, raw)
+	if err != nil {
+		return bson.Raw{}, err
+	}
+	return raw, nil
 }
 
 func newEvt(opts *Opts) (*Event, error) {
-	updater.start()
+	svc := GetService()
+	if svc == nil {
+		updater.start()
+	}
 	if opts == nil {
 		return nil, ErrNoOpts
 	}
 	if !opts.Target.IsValid() {
 		return nil, ErrNoTarget
 	}
+	for _, t := range opts.ExtraTargets {
+		if !t.IsValid() {
+			return nil, ErrNoExtraTargetType
+		}
+	}
 	if opts.Allowed.Scheme == "" && len(opts.Allowed.Contexts) == 0 {
 		return nil, ErrNoAllowed
 	}
 	if opts.Cancelable && opts.AllowedCancel.Scheme == "" && len(opts.AllowedCancel.Contexts) == 0 {
 		return nil, ErrNoAllowedCancel
 	}
+	if opts.RequireApproval && opts.AllowedApprove.Scheme == "" && len(opts.AllowedApprove.Contexts) == 0 {
+		return nil, ErrNoAllowedApprove
+	}
+	if opts.Context != nil {
+		if err := opts.Context.Err(); err != nil {
+			return nil, err
+		}
+	}
 	var k Kind
 	if opts.Kind == nil {
 		if opts.InternalKind == "" {
@@ -649,12 +905,22 @@ func newEvt(opts *Opts) (*Event, error) {
 		o.Type = OwnerTypeUser
 		o.Name = opts.Owner.GetUserName()
 	}
+	a := opts.ActingOwner
+	if impToken, ok := opts.Owner.(*auth.ImpersonationToken); ok && a.Name == "" {
+		a = Owner{Type: OwnerTypeTeam, Name: impToken.Team}
+	}
+	if svc != nil {
+		return newServiceEvt(svc, opts, &k, &o, &a)
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 	coll := conn.Events()
+	if !opts.RunAt.IsZero() || opts.RequireApproval {
+		return scheduleEvt(conn, opts, &k, &o, &a)
+	}
 	tSpec := getThrottling(&opts.Target, &k)
 	if tSpec != nil && tSpec.Max > 0 && tSpec.Time > 0 {
 		query := bson.M{
@@ -674,8 +940,36 @@ func newEvt(opts *Opts) (*Event, error) {
 			return nil, ErrThrottled{Spec: tSpec, Target: opts.Target}
 		}
 	}
+	err = checkChangeRate(coll, opts, &k, &o)
+	if err != nil {
+		return nil, err
+	}
+	evt, err := buildEvt(opts, &k, &o, &a)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		evt.dryRun = true
+		err = checkDryRun(coll, evt)
+		if err != nil {
+			return nil, err
+		}
+		return evt, nil
+	}
+	err = insertEvt(coll, evt, opts)
+	if _, isLocked := err.(ErrEventLocked); isLocked && opts.WaitLock > 0 {
+		err = waitLockAndInsert(coll, evt, opts, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// buildEvt returns the running event described by opts, not yet stored.
+func buildEvt(opts *Opts, k *Kind, o, a *Owner) (*Event, error) {
 	now := time.Now().UTC()
-	raw, err := makeBSONRaw(opts.CustomData)
+	raw, err := makeBSONRaw(opts.CustomData, getCustomDataSchema(&opts.Target, k).StartCustomData)
 	if err != nil {
 		return nil, err
 	}
@@ -686,33 +980,52 @@ func newEvt(opts *Opts) (*Event, error) {
 	} else {
 		id.Target = opts.Target
 	}
-	evt := Event{eventData: eventData{
+	evt := &Event{eventData: eventData{
 		ID:              id,
 		UniqueID:        uniqID,
 		Target:          opts.Target,
+		ExtraTargets:    opts.ExtraTargets,
 		StartTime:       now,
-		Kind:            k,
-		Owner:           o,
+		Kind:            *k,
+		Owner:           *o,
+		ActingOwner:     *a,
 		StartCustomData: raw,
 		LockUpdateTime:  now,
+		ScheduledFor:    opts.scheduledFor,
 		Running:         true,
 		Cancelable:      opts.Cancelable,
 		Allowed:         opts.Allowed,
 		AllowedCancel:   opts.AllowedCancel,
 	}}
+	evt.logLimits.init()
+	evt.ctx = opts.Context
+	return evt, nil
+}
+
+// Context returns the context given in Opts.Context when the event was
+// created, or a background context when there was none.
+func (e *Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+func insertEvt(coll *storage.Collection, evt *Event, opts *Opts) error {
+	var err error
 	maxRetries := 1
 	for i := 0; i < maxRetries+1; i++ {
 		err = coll.Insert(evt.eventData)
 		if err == nil {
-			err = checkIsBlocked(&evt)
+			err = checkIsBlocked(evt)
 			if err != nil {
 				evt.Done(err)
-				return nil, err
+				return err
 			}
 			if !opts.DisableLock {
 				updater.addCh <- &opts.Target
 			}
-			return &evt, nil
+			return nil
 		}
 		if mgo.IsDup(err) {
 			if i >= maxRetries || !checkIsExpired(coll, evt.ID) {
@@ -726,24 +1039,25 @@ func newEvt(opts *Opts) (*Event, error) {
 				}
 			}
 		} else {
-			return nil, err
+			return err
 		}
 	}
-	return nil, err
+	return err
 }
 
 func (e *Event) RawInsert(start, other, end interface{}) error {
 	e.ID = eventID{ObjId: e.UniqueID}
+	schema := getCustomDataSchema(&e.Target, &e.Kind)
 	var err error
-	e.StartCustomData, err = makeBSONRaw(start)
+	e.StartCustomData, err = makeBSONRaw(start, schema.StartCustomData)
 	if err != nil {
 		return err
 	}
-	e.OtherCustomData, err = makeBSONRaw(other)
+	e.OtherCustomData, err = makeBSONRaw(other, nil)
 	if err != nil {
 		return err
 	}
-	e.EndCustomData, err = makeBSONRaw(end)
+	e.EndCustomData, err = makeBSONRaw(end, schema.EndCustomData)
 	if err != nil {
 		return err
 	}
@@ -773,6 +1087,14 @@ func (e *Event) SetLogWriter(w io.Writer) {
 }
 
 func (e *Event) SetOtherCustomData(data interface{}) error {
+	if !e.stored() {
+		return nil
+	}
+	if e.service != nil {
+		var err error
+		e.OtherCustomData, err = makeBSONRaw(data, nil)
+		return err
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -790,20 +1112,29 @@ func (e *Event) Logf(format string, params ...interface{}) {
 	if e.logWriter != nil {
 		fmt.Fprintf(e.logWriter, format, params...)
 	}
-	fmt.Fprintf(&e.logBuffer, format, params...)
+	if e.allowLogLine() {
+		e.writeLog([]byte(fmt.Sprintf(format, params...)))
+	}
 }
 
 func (e *Event) Write(data []byte) (int, error) {
 	if e.logWriter != nil {
 		e.logWriter.Write(data)
 	}
-	return e.logBuffer.Write(data)
+	return e.writeLog(data)
 }
 
 func (e *Event) TryCancel(reason, owner string) error {
-	if !e.Cancelable || !e.Running {
+	if !e.Cancelable || !e.Running || !e.stored() {
 		return ErrNotCancelable
 	}
+	if e.service != nil {
+		if e.CancelInfo.Asked {
+			return ErrEventNotFound
+		}
+		e.CancelInfo = cancelInfo{Owner: owner, Reason: reason, StartTime: time.Now().UTC(), Asked: true}
+		return nil
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -828,10 +1159,73 @@ func (e *Event) TryCancel(reason, owner string) error {
 	return err
 }
 
+// CancelMany asks for the cancellation of all running cancelable events
+// matching the filter which owner is allowed to cancel, returning the events
+// whose cancellation was asked. Limit, Skip, Sort and Cursor are ignored, all
+// matching events are considered.
+func CancelMany(filter *Filter, reason string, owner auth.Token) ([]*Event, error) {
+	if reason == "" {
+		return nil, ErrNoCancelReason
+	}
+	f := Filter{}
+	if filter != nil {
+		f = *filter
+	}
+	running := true
+	f.Running = &running
+	f.Cursor = ""
+	query, err := f.toQuery()
+	if err != nil {
+		if err == errInvalidQuery {
+			return nil, nil
+		}
+		return nil, err
+	}
+	query["cancelable"] = true
+	query["cancelinfo.asked"] = false
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	iter := conn.Events().Find(query).Sort("starttime").Iter()
+	var canceled []*Event
+	for {
+		var evtData eventData
+		if !iter.Next(&evtData) {
+			break
+		}
+		evt := &Event{eventData: evtData}
+		scheme, err := permission.SafeGet(evt.AllowedCancel.Scheme)
+		if err != nil || !permission.Check(owner, scheme, evt.AllowedCancel.Contexts...) {
+			continue
+		}
+		err = evt.TryCancel(reason, owner.GetUserName())
+		if err == ErrEventNotFound || err == ErrNotCancelable {
+			// The event finished or had its cancellation asked meanwhile.
+			continue
+		}
+		if err != nil {
+			iter.Close()
+			return canceled, err
+		}
+		canceled = append(canceled, evt)
+	}
+	return canceled, iter.Close()
+}
+
 func (e *Event) AckCancel() (bool, error) {
-	if !e.Cancelable || !e.Running {
+	if !e.Cancelable || !e.Running || !e.stored() {
 		return false, nil
 	}
+	if e.service != nil {
+		if !e.CancelInfo.Asked {
+			return false, nil
+		}
+		e.CancelInfo.AckTime = time.Now().UTC()
+		e.CancelInfo.Canceled = true
+		return true, nil
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return false, err
@@ -881,7 +1275,17 @@ func (e *Event) done(evtErr error, customData interface{}, abort bool) (err erro
 			log.Errorf("[events] error marking event as done - %#v: %s", e, err)
 		}
 	}()
-	updater.removeCh <- &e.Target
+	if !e.stored() {
+		return nil
+	}
+	if e.service != nil {
+		return e.serviceDone(evtErr, customData, abort)
+	}
+	if e.ID.ObjId == "" {
+		// Events without locks, which may run alongside the event holding
+		// the lock of their target, must not stop the updates of that lock.
+		updater.removeCh <- &e.Target
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -889,31 +1293,68 @@ func (e *Event) done(evtErr error, customData interface{}, abort bool) (err erro
 	defer conn.Close()
 	coll := conn.Events()
 	if abort {
+		e.finishLog()
 		return coll.RemoveId(e.ID)
 	}
-	if evtErr != nil {
-		e.Error = evtErr.Error()
-	} else if e.CancelInfo.Canceled {
-		e.Error = "canceled by user request"
-	}
-	e.EndTime = time.Now().UTC()
-	e.EndCustomData, err = makeBSONRaw(customData)
+	schemaErr, err := e.finish(evtErr, customData)
 	if err != nil {
 		return err
 	}
-	e.Running = false
-	e.Log = e.logBuffer.String()
 	var dbEvt Event
 	err = coll.FindId(e.ID).One(&dbEvt.eventData)
 	if err == nil {
 		e.OtherCustomData = dbEvt.OtherCustomData
 	}
 	if len(e.ID.ObjId) != 0 {
-		return coll.UpdateId(e.ID, e.eventData)
+		err = coll.UpdateId(e.ID, e.eventData)
+	} else {
+		defer coll.RemoveId(e.ID)
+		e.ID = eventID{ObjId: e.UniqueID}
+		err = coll.Insert(e.eventData)
 	}
-	defer coll.RemoveId(e.ID)
-	e.ID = eventID{ObjId: e.UniqueID}
-	return coll.Insert(e.eventData)
+	if err != nil {
+		return err
+	}
+	return schemaErr
+}
+
+func (e *Event) serviceDone(evtErr error, customData interface{}, abort bool) error {
+	if abort {
+		e.finishLog()
+		return e.service.Remove(e)
+	}
+	schemaErr, err := e.finish(evtErr, customData)
+	if err != nil {
+		return err
+	}
+	err = e.service.Finish(e)
+	if err != nil {
+		return err
+	}
+	return schemaErr
+}
+
+// finish sets the error, end time, end custom data and log of the event,
+// marking it as not running. Invalid end custom data is discarded and its
+// validation error is returned as schemaErr.
+func (e *Event) finish(evtErr error, customData interface{}) (schemaErr error, err error) {
+	if evtErr != nil {
+		e.Error = evtErr.Error()
+	} else if e.CancelInfo.Canceled {
+		e.Error = "canceled by user request"
+	}
+	e.EndTime = time.Now().UTC()
+	e.EndCustomData, err = makeBSONRaw(customData, getCustomDataSchema(&e.Target, &e.Kind).EndCustomData)
+	if _, isValidation := err.(ErrValidation); isValidation {
+		// Invalid custom data is discarded but the event is still finished,
+		// otherwise it would hold the target lock until it expires.
+		schemaErr = err
+	} else if err != nil {
+		return nil, err
+	}
+	e.Running = false
+	e.Log = e.finishLog()
+	return schemaErr, nil
 }
 
 type lockUpdater struct {
@@ -941,6 +1382,7 @@ func (l *lockUpdater) stop() {
 
 func (l *lockUpdater) spin() {
 	set := map[Target]struct{}{}
+	var lastReclaim time.Time
 	for {
 		select {
 		case added := <-l.addCh:
@@ -958,16 +1400,27 @@ func (l *lockUpdater) spin() {
 		}
 		coll := conn.Events()
 		slice := make([]interface{}, len(set))
+		locks := make([]Target, 0, len(set))
 		i := 0
 		for id := range set {
 			slice[i], _ = id.GetBSON()
+			locks = append(locks, id)
 			i++
 		}
-		err = coll.Update(bson.M{"_id": bson.M{"$in": slice}}, bson.M{"$set": bson.M{"lockupdatetime": time.Now().UTC()}})
+		now := time.Now().UTC()
+		_, err = coll.UpdateAll(bson.M{"_id": bson.M{"$in": slice}}, bson.M{"$set": bson.M{"lockupdatetime": now}})
 		if err != nil && err != mgo.ErrNotFound {
 			log.Errorf("[events] [lock update] error updating: %s", err)
 		}
+		err = updateLockOwner(conn, instanceID, locks, now)
+		if err != nil {
+			log.Errorf("[events] [lock update] error updating lock owner: %s", err)
+		}
 		conn.Close()
+		if now.Sub(lastReclaim) >= lockUpdateInterval {
+			lastReclaim = now
+			go reclaimDeadLocksLogErrors()
+		}
 	}
 }
 
@@ -981,6 +1434,10 @@ func checkIsExpired(coll *storage.Collection, id interface{}) bool {
 			existingEvt.Done(errors.Errorf("event expired, no update for %v", time.Since(lastUpdate)))
 			return true
 		}
+		if existingEvt.Running && isLockOwnerDead(existingEvt.Target, lastUpdate) {
+			existingEvt.Done(errors.Errorf("event expired, lock owner stopped updating it %v ago", time.Since(lastUpdate)))
+			return true
+		}
 	}
 	return false
 }
@@ -6,6 +6,7 @@ package event
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"runtime"
@@ -21,6 +22,7 @@ import (
 	"github.com/tsuru/tsuru/db/dbtest"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
 	"github.com/tsuru/tsuru/safe"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/check.v1"
@@ -40,6 +42,7 @@ func (s *S) SetUpTest(c *check.C) {
 	config.Set("database:name", "tsuru_events_tests")
 	config.Set("auth:hash-cost", bcrypt.MinCost)
 	throttlingInfo = map[string]ThrottlingSpec{}
+	scheduledExecutors = map[string]ScheduledFunc{}
 	conn, err := db.Conn()
 	c.Assert(err, check.IsNil)
 	defer conn.Close()
@@ -452,6 +455,66 @@ func (s *S) TestEventCancel(c *check.C) {
 	})
 }
 
+func (s *S) TestCancelMany(c *check.C) {
+	newEvt := func(target string, kind *permission.PermissionScheme, cancelable bool) *Event {
+		opts := &Opts{
+			Target:     Target{Type: "app", Value: target},
+			Kind:       kind,
+			Owner:      s.token,
+			Cancelable: cancelable,
+			Allowed:    Allowed(permission.PermAppReadEvents),
+		}
+		if cancelable {
+			opts.AllowedCancel = Allowed(permission.PermAppUpdateEvents, permission.Context(permission.CtxApp, target))
+		}
+		evt, err := New(opts)
+		c.Assert(err, check.IsNil)
+		return evt
+	}
+	evt1 := newEvt("app1", permission.PermAppDeploy, true)
+	evt2 := newEvt("app2", permission.PermAppDeploy, true)
+	newEvt("app3", permission.PermAppDeploy, true)
+	newEvt("app4", permission.PermAppDeploy, false)
+	newEvt("app5", permission.PermAppUpdateEnvSet, true)
+	finished := newEvt("app6", permission.PermAppDeploy, true)
+	err := finished.Done(nil)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, auth.ManagedScheme(native.NativeScheme{}), "canceler",
+		permission.Permission{Scheme: permission.PermAppUpdateEvents, Context: permission.Context(permission.CtxApp, "app1")},
+		permission.Permission{Scheme: permission.PermAppUpdateEvents, Context: permission.Context(permission.CtxApp, "app2")},
+		permission.Permission{Scheme: permission.PermAppUpdateEvents, Context: permission.Context(permission.CtxApp, "app4")},
+		permission.Permission{Scheme: permission.PermAppUpdateEvents, Context: permission.Context(permission.CtxApp, "app5")},
+		permission.Permission{Scheme: permission.PermAppUpdateEvents, Context: permission.Context(permission.CtxApp, "app6")},
+	)
+	canceled, err := CancelMany(&Filter{KindName: "app.deploy"}, "incident", token)
+	c.Assert(err, check.IsNil)
+	c.Assert(canceled, check.HasLen, 2)
+	c.Assert(canceled[0].UniqueID, check.Equals, evt1.UniqueID)
+	c.Assert(canceled[1].UniqueID, check.Equals, evt2.UniqueID)
+	for _, evt := range canceled {
+		c.Assert(evt.CancelInfo.Asked, check.Equals, true)
+		c.Assert(evt.CancelInfo.Reason, check.Equals, "incident")
+		c.Assert(evt.CancelInfo.Owner, check.Equals, token.GetUserName())
+	}
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	var asked []string
+	for i := range evts {
+		if evts[i].CancelInfo.Asked {
+			asked = append(asked, evts[i].Target.Value)
+		}
+	}
+	c.Assert(asked, check.DeepEquals, []string{"app2", "app1"})
+	canceled, err = CancelMany(&Filter{KindName: "app.deploy"}, "incident", token)
+	c.Assert(err, check.IsNil)
+	c.Assert(canceled, check.HasLen, 0)
+}
+
+func (s *S) TestCancelManyNoReason(c *check.C) {
+	_, err := CancelMany(&Filter{}, "", s.token)
+	c.Assert(err, check.Equals, ErrNoCancelReason)
+}
+
 func (s *S) TestEventCancelNotCancelable(c *check.C) {
 	evt, err := New(&Opts{
 		Target:  Target{Type: "app", Value: "myapp"},
@@ -552,6 +615,8 @@ func (s *S) TestEventNewValidation(c *check.C) {
 	c.Assert(err, check.Equals, ErrNoOpts)
 	_, err = New(&Opts{Kind: permission.PermAppCreate, Owner: s.token})
 	c.Assert(err, check.Equals, ErrNoTarget)
+	_, err = New(&Opts{Target: Target{Type: "node", Value: "n1"}, ExtraTargets: []Target{{Value: "myapp"}}, Kind: permission.PermAppCreate, Owner: s.token})
+	c.Assert(err, check.Equals, ErrNoExtraTargetType)
 	_, err = New(&Opts{Target: Target{Type: "app", Value: "myapp"}, Owner: s.token})
 	c.Assert(err, check.Equals, ErrNoKind)
 	_, err = New(&Opts{Target: Target{Type: "app", Value: "myapp"}, Kind: permission.PermAppCreate})
@@ -670,6 +735,44 @@ func (s *S) TestNewThrottledOneKind(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestNewImpersonated(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   &auth.ImpersonationToken{Token: s.token, Team: "myteam"},
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evt, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "otherapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evts, err := List(&Filter{ActingOwnerType: OwnerTypeTeam, ActingOwnerName: "myteam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target.Value, check.Equals, "myapp")
+	c.Assert(evts[0].Owner, check.DeepEquals, Owner{Type: OwnerTypeUser, Name: s.token.GetUserName()})
+	c.Assert(evts[0].ActingOwner, check.DeepEquals, Owner{Type: OwnerTypeTeam, Name: "myteam"})
+	impersonated := true
+	evts, err = List(&Filter{Impersonated: &impersonated})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target.Value, check.Equals, "myapp")
+	impersonated = false
+	evts, err = List(&Filter{Impersonated: &impersonated})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target.Value, check.Equals, "otherapp")
+	c.Assert(evts[0].ActingOwner, check.DeepEquals, Owner{})
+}
+
 func (s *S) TestListFilterEmpty(c *check.C) {
 	evts, err := List(nil)
 	c.Assert(err, check.IsNil)
@@ -690,6 +793,7 @@ func (s *S) TestListFilterPruneUserValues(c *check.C) {
 		IncludeRemoved: true,
 		Raw:            bson.M{"a": 1},
 		AllowedTargets: []TargetFilter{{Type: TargetTypeApp, Values: []string{"a1"}}},
+		Targets:        []Target{{Type: TargetTypeApp, Value: "a1"}},
 		Limit:          50,
 		Skip:           10,
 		Sort:           "id",
@@ -697,6 +801,7 @@ func (s *S) TestListFilterPruneUserValues(c *check.C) {
 	expectedFilter := f
 	expectedFilter.Raw = nil
 	expectedFilter.AllowedTargets = nil
+	expectedFilter.Targets = nil
 	f.PruneUserValues()
 	c.Assert(f, check.DeepEquals, expectedFilter)
 	f.Limit = 110
@@ -880,6 +985,167 @@ func (s *S) TestNewLockRetryRace(c *check.C) {
 	c.Assert(evts, check.HasLen, int(countOK))
 }
 
+func (s *S) TestNewWaitLock(c *check.C) {
+	defer func(d time.Duration) { lockWaitInterval = d }(lockWaitInterval)
+	lockWaitInterval = 10 * time.Millisecond
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	done := make(chan *Event)
+	go func() {
+		waiting, waitErr := New(&Opts{
+			Target:   Target{Type: "app", Value: "myapp"},
+			Kind:     permission.PermAppUpdateEnvSet,
+			Owner:    s.token,
+			Allowed:  Allowed(permission.PermAppReadEvents),
+			WaitLock: 5 * time.Second,
+		})
+		c.Check(waitErr, check.IsNil)
+		done <- waiting
+	}()
+	time.Sleep(50 * time.Millisecond)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	select {
+	case waiting := <-done:
+		c.Assert(waiting, check.NotNil)
+		c.Assert(waiting.Running, check.Equals, true)
+		c.Assert(waiting.StartTime.After(evt.StartTime), check.Equals, true)
+		err = waiting.Done(nil)
+		c.Assert(err, check.IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for lock")
+	}
+}
+
+func (s *S) TestNewWaitLockTimeout(c *check.C) {
+	defer func(d time.Duration) { lockWaitInterval = d }(lockWaitInterval)
+	lockWaitInterval = 10 * time.Millisecond
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	_, err = New(&Opts{
+		Target:   Target{Type: "app", Value: "myapp"},
+		Kind:     permission.PermAppUpdateEnvSet,
+		Owner:    s.token,
+		Allowed:  Allowed(permission.PermAppReadEvents),
+		WaitLock: 100 * time.Millisecond,
+	})
+	c.Assert(err, check.FitsTypeOf, ErrEventLocked{})
+}
+
+func (s *S) TestNewWaitLockContextCanceled(c *check.C) {
+	defer func(d time.Duration) { lockWaitInterval = d }(lockWaitInterval)
+	lockWaitInterval = 10 * time.Millisecond
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = New(&Opts{
+		Target:   Target{Type: "app", Value: "myapp"},
+		Kind:     permission.PermAppUpdateEnvSet,
+		Owner:    s.token,
+		Allowed:  Allowed(permission.PermAppReadEvents),
+		WaitLock: 5 * time.Second,
+		Context:  ctx,
+	})
+	c.Assert(err, check.Equals, context.DeadlineExceeded)
+}
+
+func (s *S) TestNewContextDone(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		Context: ctx,
+	})
+	c.Assert(err, check.Equals, context.Canceled)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestEventContext(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		Context: ctx,
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	c.Assert(evt.Context(), check.Equals, ctx)
+	other, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "otherapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer other.Done(nil)
+	c.Assert(other.Context(), check.Equals, context.Background())
+}
+
+func (s *S) TestNewWaitLockFIFO(c *check.C) {
+	defer func(d time.Duration) { lockWaitInterval = d }(lockWaitInterval)
+	lockWaitInterval = 10 * time.Millisecond
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	var mu sync.Mutex
+	var order []int
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			waiting, waitErr := New(&Opts{
+				Target:   Target{Type: "app", Value: "myapp"},
+				Kind:     permission.PermAppUpdateEnvSet,
+				Owner:    s.token,
+				Allowed:  Allowed(permission.PermAppReadEvents),
+				WaitLock: 5 * time.Second,
+			})
+			c.Check(waitErr, check.IsNil)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			waiting.Done(nil)
+		}(i)
+		time.Sleep(50 * time.Millisecond)
+	}
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	wg.Wait()
+	c.Assert(order, check.DeepEquals, []int{0, 1, 2})
+}
+
 func (s *S) TestNewCustomDataPtr(c *check.C) {
 	customData := struct{ A string }{A: "value"}
 	evt, err := New(&Opts{
@@ -910,3 +1176,48 @@ func (s *S) TestNewCustomDataPtr(c *check.C) {
 	}}
 	c.Assert(evt, check.DeepEquals, expected)
 }
+
+func (s *S) TestRenameTeam(c *check.C) {
+	finished, err := New(&Opts{
+		Target:       Target{Type: TargetTypeTeam, Value: "dune"},
+		ExtraTargets: []Target{{Type: TargetTypeApp, Value: "myapp"}, {Type: TargetTypeTeam, Value: "dune"}},
+		Kind:         permission.PermTeamCreate,
+		Owner:        s.token,
+		Allowed:      Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, "dune")),
+	})
+	c.Assert(err, check.IsNil)
+	err = finished.Done(nil)
+	c.Assert(err, check.IsNil)
+	running, err := New(&Opts{
+		Target:  Target{Type: TargetTypeTeam, Value: "dune"},
+		Kind:    permission.PermTeamUpdateName,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, "dune")),
+	})
+	c.Assert(err, check.IsNil)
+	other, err := New(&Opts{
+		Target:  Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, "dune")),
+	})
+	c.Assert(err, check.IsNil)
+	err = other.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = RenameTeam("dune", "arrakis")
+	c.Assert(err, check.IsNil)
+	evt, err := GetByID(finished.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Target, check.DeepEquals, Target{Type: TargetTypeTeam, Value: "arrakis"})
+	c.Assert(evt.ExtraTargets, check.DeepEquals, []Target{{Type: TargetTypeApp, Value: "myapp"}, {Type: TargetTypeTeam, Value: "arrakis"}})
+	c.Assert(evt.Allowed, check.DeepEquals, Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, "arrakis")))
+	evt, err = GetByID(other.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Target, check.DeepEquals, Target{Type: TargetTypeApp, Value: "myapp"})
+	c.Assert(evt.Allowed, check.DeepEquals, Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, "arrakis")))
+	evt, err = GetByID(running.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Target, check.DeepEquals, Target{Type: TargetTypeTeam, Value: "dune"})
+	err = running.Done(nil)
+	c.Assert(err, check.IsNil)
+}
@@ -6,6 +6,7 @@ package event_test
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/tsuru/config"
@@ -151,6 +152,67 @@ func (s *S) TestListFilterMany(c *check.C) {
 	checkFilters(&event.Filter{Permissions: []permission.Permission{
 		{Scheme: permission.PermAppRead, Context: permission.Context(permission.CtxApp, "invalid-app")},
 	}, Sort: "_id"}, allEvts[:0])
+	checkFilters(&event.Filter{Targets: []event.Target{}}, allEvts[:0])
+	checkFilters(&event.Filter{Targets: []event.Target{
+		{Type: "app", Value: "myapp2"},
+		{Type: "node", Value: "http://10.0.1.1"},
+	}, Sort: "_id"}, []*event.Event{&allEvts[1], &allEvts[3]})
+}
+
+func (s *S) TestListCursor(c *check.C) {
+	var ids []bson.ObjectId
+	for i := 0; i < 5; i++ {
+		evt, err := event.New(&event.Opts{
+			Target:  event.Target{Type: "app", Value: fmt.Sprintf("myapp%d", i)},
+			Kind:    permission.PermAppUpdateEnvSet,
+			Owner:   s.token,
+			Allowed: event.Allowed(permission.PermAppReadEvents),
+		})
+		c.Assert(err, check.IsNil)
+		ids = append(ids, evt.UniqueID)
+	}
+	evtIDs := func(evts []event.Event) []bson.ObjectId {
+		result := make([]bson.ObjectId, len(evts))
+		for i := range evts {
+			result[i] = evts[i].UniqueID
+		}
+		return result
+	}
+	evts, err := event.List(&event.Filter{Limit: 2})
+	c.Assert(err, check.IsNil)
+	c.Assert(evtIDs(evts), check.DeepEquals, []bson.ObjectId{ids[4], ids[3]})
+	_, err = event.New(&event.Opts{
+		Target:  event.Target{Type: "app", Value: "newapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	evts, err = event.List(&event.Filter{Limit: 2, Cursor: evts[1].Cursor()})
+	c.Assert(err, check.IsNil)
+	c.Assert(evtIDs(evts), check.DeepEquals, []bson.ObjectId{ids[2], ids[1]})
+	evts, err = event.List(&event.Filter{Limit: 2, Cursor: evts[1].Cursor()})
+	c.Assert(err, check.IsNil)
+	c.Assert(evtIDs(evts), check.DeepEquals, []bson.ObjectId{ids[0]})
+	evt, err := event.GetByID(ids[2])
+	c.Assert(err, check.IsNil)
+	evts, err = event.List(&event.Filter{Limit: 2, Sort: "starttime", Cursor: evt.Cursor()})
+	c.Assert(err, check.IsNil)
+	c.Assert(evtIDs(evts), check.DeepEquals, []bson.ObjectId{ids[3], ids[4]})
+}
+
+func (s *S) TestListCursorInvalid(c *check.C) {
+	_, err := event.List(&event.Filter{Cursor: "invalid"})
+	c.Assert(err, check.Equals, event.ErrInvalidCursor)
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = event.List(&event.Filter{Cursor: evt.Cursor(), Sort: "_id"})
+	c.Assert(err, check.Equals, event.ErrInvalidCursorSort)
 }
 
 func (s *S) TestGetByID(c *check.C) {
@@ -211,3 +273,49 @@ func (s *S) TestGetKinds(c *check.C) {
 	}
 	c.Assert(kinds, check.DeepEquals, expected)
 }
+
+func (s *S) TestListFilterExtraTargets(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target:       event.Target{Type: "node", Value: "http://10.0.1.1"},
+		ExtraTargets: []event.Target{{Type: "app", Value: "myapp"}, {Type: "app", Value: "myapp2"}},
+		Kind:         permission.PermNodeDelete,
+		Owner:        s.token,
+		Allowed:      event.Allowed(permission.PermAppAdmin),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evt, err = event.New(&event.Opts{
+		Target:  event.Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppAdmin),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		target event.Target
+		kinds  []string
+	}{
+		{event.Target{Type: "app", Value: "myapp"}, []string{"node.delete", "app.update.env.set"}},
+		{event.Target{Type: "app", Value: "myapp2"}, []string{"node.delete"}},
+		{event.Target{Type: "app"}, []string{"node.delete", "app.update.env.set"}},
+		{event.Target{Type: "node"}, []string{"node.delete"}},
+		{event.Target{Type: "app", Value: "http://10.0.1.1"}, nil},
+		{event.Target{Type: "app", Value: "otherapp"}, nil},
+	}
+	for i, tt := range tests {
+		evts, err := event.List(&event.Filter{Target: tt.target, Sort: "_id"})
+		c.Assert(err, check.IsNil)
+		var kinds []string
+		for j := range evts {
+			kinds = append(kinds, evts[j].Kind.Name)
+		}
+		c.Check(kinds, check.DeepEquals, tt.kinds, check.Commentf("test %d", i))
+	}
+	evts, err := event.List(&event.Filter{KindName: "node.delete"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].ExtraTargets, check.DeepEquals, []event.Target{{Type: "app", Value: "myapp"}, {Type: "app", Value: "myapp2"}})
+}
@@ -0,0 +1,258 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const gridFSLogPrefix = "eventlogs"
+
+var (
+	ErrNoLogOverflow = errors.New("event log was not truncated or its overflow was discarded")
+
+	logOverflowStores = map[string]LogOverflowStore{
+		"gridfs": gridFSLogStore{},
+	}
+)
+
+// LogOverflowStore stores the part of event logs exceeding the size limit set
+// in event:log:max-size, so the event document kept in the database is
+// small.
+type LogOverflowStore interface {
+	// Create returns a writer for the overflow of the event log and the
+	// reference used to open it later.
+	Create(evt *Event) (io.WriteCloser, string, error)
+	Open(ref string) (io.ReadCloser, error)
+}
+
+// RegisterLogOverflowStore makes a store available to be used in the
+// event:log:overflow setting.
+func RegisterLogOverflowStore(name string, store LogOverflowStore) {
+	logOverflowStores[name] = store
+}
+
+// logLimits holds the log limits of an event, read from the config when the
+// event is created, and the state of the log regarding them. Zero limits
+// mean unlimited.
+type logLimits struct {
+	mu           sync.Mutex
+	maxSize      int
+	maxLines     int
+	storeName    string
+	lineWindow   time.Time
+	lines        int
+	dropped      int
+	overflow     io.WriteCloser
+	overflowRef  string
+	overflowSize int
+}
+
+func (l *logLimits) init() {
+	l.maxSize, _ = config.GetInt("event:log:max-size")
+	l.maxLines, _ = config.GetInt("event:log:max-lines-per-second")
+	l.storeName, _ = config.GetString("event:log:overflow")
+}
+
+// allowLogLine reports whether a line written by Logf must be recorded in the
+// event log, considering event:log:max-lines-per-second. Lines are still sent
+// to the log writer.
+func (e *Event) allowLogLine() bool {
+	l := &e.logLimits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxLines <= 0 {
+		return true
+	}
+	now := time.Now()
+	if now.Sub(l.lineWindow) >= time.Second {
+		l.lineWindow = now
+		l.lines = 0
+	}
+	if l.lines >= l.maxLines {
+		l.dropped++
+		return false
+	}
+	l.lines++
+	if l.dropped > 0 {
+		fmt.Fprintf(&e.logBuffer, "... %d log lines dropped by rate limit\n", l.dropped)
+		l.dropped = 0
+	}
+	return true
+}
+
+// writeLog appends data to the event log up to event:log:max-size, data past
+// the limit is written to the configured overflow store or discarded.
+func (e *Event) writeLog(data []byte) (int, error) {
+	l := &e.logLimits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize <= 0 {
+		return e.logBuffer.Write(data)
+	}
+	n := len(data)
+	free := l.maxSize - e.logBuffer.Len()
+	if free >= n {
+		return e.logBuffer.Write(data)
+	}
+	if free > 0 {
+		e.logBuffer.Write(data[:free])
+		data = data[free:]
+	}
+	e.spillLog(data)
+	return n, nil
+}
+
+func (e *Event) spillLog(data []byte) {
+	l := &e.logLimits
+	l.overflowSize += len(data)
+	if l.overflow == nil && l.overflowRef == "" && l.storeName != "" {
+		store, ok := logOverflowStores[l.storeName]
+		if !ok {
+			log.Errorf("[events] unknown log overflow store %q", l.storeName)
+			l.storeName = ""
+			return
+		}
+		w, ref, err := store.Create(e)
+		if err != nil {
+			log.Errorf("[events] unable to create log overflow for %s in %q: %s", e.UniqueID.Hex(), l.storeName, err)
+			l.storeName = ""
+			return
+		}
+		l.overflow = w
+		l.overflowRef = l.storeName + ":" + ref
+	}
+	if l.overflow == nil {
+		return
+	}
+	_, err := l.overflow.Write(data)
+	if err != nil {
+		log.Errorf("[events] unable to write log overflow for %s: %s", e.UniqueID.Hex(), err)
+		l.overflow.Close()
+		l.overflow = nil
+		l.overflowRef = ""
+		l.storeName = ""
+	}
+}
+
+// finishLog returns the log to be stored in the event, adding markers for
+// lines dropped by the rate limit and data past the size limit.
+func (e *Event) finishLog() string {
+	l := &e.logLimits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dropped > 0 {
+		fmt.Fprintf(&e.logBuffer, "... %d log lines dropped by rate limit\n", l.dropped)
+		l.dropped = 0
+	}
+	if l.overflowSize == 0 {
+		return e.logBuffer.String()
+	}
+	if l.overflow != nil {
+		err := l.overflow.Close()
+		l.overflow = nil
+		if err != nil {
+			log.Errorf("[events] unable to close log overflow for %s: %s", e.UniqueID.Hex(), err)
+			l.overflowRef = ""
+		}
+	}
+	e.LogOverflow = l.overflowRef
+	logStr := e.logBuffer.String()
+	if !strings.HasSuffix(logStr, "\n") {
+		logStr += "\n"
+	}
+	if e.LogOverflow != "" {
+		return logStr + fmt.Sprintf("... log truncated at %d bytes, %d bytes stored in %s\n", l.maxSize, l.overflowSize, l.storeName)
+	}
+	return logStr + fmt.Sprintf("... log truncated at %d bytes, %d bytes discarded\n", l.maxSize, l.overflowSize)
+}
+
+// SaveLog stores the log written so far in the event, so it's available
+// before the event is done, e.g. to follow operations running detached from
+// the request that started them. The log is replaced when the event is done.
+func (e *Event) SaveLog() error {
+	if !e.stored() {
+		return nil
+	}
+	if e.service != nil {
+		e.Log = e.logBuffer.String()
+		return nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Events().UpdateId(e.ID, bson.M{
+		"$set": bson.M{"log": e.logBuffer.String()},
+	})
+}
+
+// OverflowLog opens the part of the event log exceeding event:log:max-size,
+// when it was stored in an overflow store.
+func (e *Event) OverflowLog() (io.ReadCloser, error) {
+	if e.LogOverflow == "" {
+		return nil, ErrNoLogOverflow
+	}
+	parts := strings.SplitN(e.LogOverflow, ":", 2)
+	store, ok := logOverflowStores[parts[0]]
+	if !ok || len(parts) != 2 {
+		return nil, errors.Errorf("unknown log overflow store for %q", e.LogOverflow)
+	}
+	return store.Open(parts[1])
+}
+
+type gridFSLogStore struct{}
+
+type gridFSLogFile struct {
+	io.ReadWriteCloser
+	conn *db.Storage
+}
+
+func (f *gridFSLogFile) Close() error {
+	defer f.conn.Close()
+	return f.ReadWriteCloser.Close()
+}
+
+func (gridFSLogStore) Create(evt *Event) (io.WriteCloser, string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := conn.Collection(gridFSLogPrefix).Database.GridFS(gridFSLogPrefix).Create(evt.UniqueID.Hex())
+	if err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	file.SetContentType("text/plain")
+	id, _ := file.Id().(bson.ObjectId)
+	return &gridFSLogFile{ReadWriteCloser: file, conn: conn}, id.Hex(), nil
+}
+
+func (gridFSLogStore) Open(ref string) (io.ReadCloser, error) {
+	if !bson.IsObjectIdHex(ref) {
+		return nil, errors.Errorf("invalid log overflow id %q", ref)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	file, err := conn.Collection(gridFSLogPrefix).Database.GridFS(gridFSLogPrefix).OpenId(bson.ObjectIdHex(ref))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &gridFSLogFile{ReadWriteCloser: file, conn: conn}, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+type memoryLogStore struct {
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (s *memoryLogStore) Create(evt *Event) (io.WriteCloser, string, error) {
+	return s, "mem-ref", nil
+}
+
+func (s *memoryLogStore) Open(ref string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(s.buf.Bytes())), nil
+}
+
+func (s *memoryLogStore) Write(data []byte) (int, error) {
+	return s.buf.Write(data)
+}
+
+func (s *memoryLogStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *SchemaSuite) TestEventLogMaxSize(c *check.C) {
+	evt := &Event{}
+	evt.logLimits.maxSize = 10
+	var streamed bytes.Buffer
+	evt.SetLogWriter(&streamed)
+	n, err := evt.Write([]byte("12345678"))
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 8)
+	n, err = evt.Write([]byte("abcdef"))
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 6)
+	evt.Logf("more")
+	c.Assert(streamed.String(), check.Equals, "12345678abcdefmore\n")
+	c.Assert(evt.logBuffer.String(), check.Equals, "12345678ab")
+	c.Assert(evt.finishLog(), check.Equals, "12345678ab\n... log truncated at 10 bytes, 9 bytes discarded\n")
+	c.Assert(evt.LogOverflow, check.Equals, "")
+	_, err = evt.OverflowLog()
+	c.Assert(err, check.Equals, ErrNoLogOverflow)
+}
+
+func (s *SchemaSuite) TestEventLogMaxSizeOverflowStore(c *check.C) {
+	store := &memoryLogStore{}
+	RegisterLogOverflowStore("memory", store)
+	defer delete(logOverflowStores, "memory")
+	evt := &Event{}
+	evt.logLimits.maxSize = 4
+	evt.logLimits.storeName = "memory"
+	evt.Write([]byte("123456"))
+	evt.Write([]byte("789"))
+	c.Assert(evt.finishLog(), check.Equals, "1234\n... log truncated at 4 bytes, 5 bytes stored in memory\n")
+	c.Assert(store.closed, check.Equals, true)
+	c.Assert(evt.LogOverflow, check.Equals, "memory:mem-ref")
+	overflow, err := evt.OverflowLog()
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(overflow)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "56789")
+}
+
+func (s *SchemaSuite) TestEventLogMaxSizeUnknownStore(c *check.C) {
+	evt := &Event{}
+	evt.logLimits.maxSize = 2
+	evt.logLimits.storeName = "unknown"
+	evt.Write([]byte("abc"))
+	c.Assert(evt.finishLog(), check.Equals, "ab\n... log truncated at 2 bytes, 1 bytes discarded\n")
+	c.Assert(evt.LogOverflow, check.Equals, "")
+}
+
+func (s *SchemaSuite) TestEventLogMaxLinesPerSecond(c *check.C) {
+	evt := &Event{}
+	evt.logLimits.maxLines = 2
+	var streamed bytes.Buffer
+	evt.SetLogWriter(&streamed)
+	for i := 0; i < 5; i++ {
+		evt.Logf("line %d", i)
+	}
+	evt.Write([]byte("output\n"))
+	c.Assert(streamed.String(), check.Equals, "line 0\nline 1\nline 2\nline 3\nline 4\noutput\n")
+	c.Assert(evt.logBuffer.String(), check.Equals, "line 0\nline 1\noutput\n")
+	evt.logLimits.lineWindow = evt.logLimits.lineWindow.Add(-time.Second)
+	evt.Logf("line %d", 5)
+	evt.Logf("line %d", 6)
+	evt.Logf("line %d", 7)
+	c.Assert(evt.finishLog(), check.Equals, "line 0\nline 1\noutput\n"+
+		"... 3 log lines dropped by rate limit\nline 5\nline 6\n"+
+		"... 1 log lines dropped by rate limit\n")
+}
+
+func (s *S) TestEventLogOverflowGridFS(c *check.C) {
+	config.Set("event:log:max-size", 5)
+	config.Set("event:log:overflow", "gridfs")
+	defer config.Unset("event:log")
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	evt.Write([]byte("hello world"))
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Log, check.Equals, "hello\n... log truncated at 5 bytes, 6 bytes stored in gridfs\n")
+	c.Assert(evts[0].LogOverflow, check.Matches, "gridfs:[0-9a-f]{24}")
+	overflow, err := evts[0].OverflowLog()
+	c.Assert(err, check.IsNil)
+	defer overflow.Close()
+	data, err := ioutil.ReadAll(overflow)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, " world")
+}
+
+func (s *S) TestEventSaveLog(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppRun,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	evt.Write([]byte("migrating..."))
+	err = evt.SaveLog()
+	c.Assert(err, check.IsNil)
+	running, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(running.Running, check.Equals, true)
+	c.Assert(running.Log, check.Equals, "migrating...")
+	evt.Write([]byte(" done\n"))
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	done, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(done.Running, check.Equals, false)
+	c.Assert(done.Log, check.Equals, "migrating... done\n")
+}
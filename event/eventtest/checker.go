@@ -63,6 +63,9 @@ func (hasEventChecker) Check(params []interface{}, names []string) (bool, string
 	default:
 		return false, "First parameter must be of type EventDesc or *EventDesc"
 	}
+	if svc, ok := event.GetService().(*Service); ok {
+		return svc.hasEvent(evt)
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return false, err.Error()
@@ -0,0 +1,276 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventtest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tsuru/tsuru/event"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Service is an in-memory event.Service, allowing code recording events to
+// be tested without MongoDB:
+//
+//	svc := eventtest.NewService()
+//	event.SetService(svc)
+//	defer event.SetService(nil)
+//
+// While it's set, the HasEvent checker looks for events in the service.
+//
+// The List method only considers the Target, KindType, KindName, OwnerType,
+// OwnerName, Running, ErrorOnly, Since, Until, Limit and Skip fields of
+// filters.
+type Service struct {
+	mu     sync.Mutex
+	events []*event.Event
+}
+
+var _ event.Service = &Service{}
+
+// NewService returns an empty in-memory service.
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Insert(evt *event.Event) (*event.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if evt.HoldsLock() {
+		for _, e := range s.events {
+			if e.Running && e.HoldsLock() && e.Target == evt.Target {
+				return e, nil
+			}
+		}
+	}
+	s.events = append(s.events, evt)
+	return nil, nil
+}
+
+func (s *Service) Finish(evt *event.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index(evt) < 0 {
+		s.events = append(s.events, evt)
+	}
+	return nil
+}
+
+func (s *Service) Remove(evt *event.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(evt); i >= 0 {
+		s.events = append(s.events[:i], s.events[i+1:]...)
+	}
+	return nil
+}
+
+func (s *Service) index(evt *event.Event) int {
+	for i, e := range s.events {
+		if e == evt || e.UniqueID == evt.UniqueID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Service) Get(id bson.ObjectId) (*event.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.UniqueID == id {
+			return e, nil
+		}
+	}
+	return nil, event.ErrEventNotFound
+}
+
+func (s *Service) GetRunning(target event.Target, kind string) (*event.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.Running && e.HoldsLock() && e.Target == target && e.Kind.Name == kind {
+			return e, nil
+		}
+	}
+	return nil, event.ErrEventNotFound
+}
+
+func (s *Service) List(filter *event.Filter) ([]*event.Event, error) {
+	evts := s.Events()
+	sort.SliceStable(evts, func(i, j int) bool {
+		return evts[i].StartTime.After(evts[j].StartTime)
+	})
+	if filter == nil {
+		return evts, nil
+	}
+	var result []*event.Event
+	for _, e := range evts {
+		if matchesFilter(e, filter) {
+			result = append(result, e)
+		}
+	}
+	if filter.Skip > 0 {
+		if filter.Skip >= len(result) {
+			return nil, nil
+		}
+		result = result[filter.Skip:]
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func matchesFilter(e *event.Event, f *event.Filter) bool {
+	switch {
+	case f.Target.Type != "" && e.Target.Type != f.Target.Type,
+		f.Target.Value != "" && e.Target.Value != f.Target.Value,
+		f.KindType != "" && e.Kind.Type != f.KindType,
+		f.KindName != "" && e.Kind.Name != f.KindName,
+		f.OwnerType != "" && e.Owner.Type != f.OwnerType,
+		f.OwnerName != "" && e.Owner.Name != f.OwnerName,
+		f.Running != nil && e.Running != *f.Running,
+		f.ErrorOnly && e.Error == "",
+		!f.Since.IsZero() && e.StartTime.Before(f.Since),
+		!f.Until.IsZero() && e.StartTime.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// Events returns all the events in the service, running or finished, in the
+// order they were created.
+func (s *Service) Events() []*event.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*event.Event(nil), s.events...)
+}
+
+// Reset removes all the events from the service.
+func (s *Service) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+}
+
+// Find returns the finished events matching desc, as checked by HasEvent.
+func (s *Service) Find(desc EventDesc) []*event.Event {
+	var found []*event.Event
+	for _, e := range s.Events() {
+		if matchesDesc(e, &desc) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func (s *Service) hasEvent(desc EventDesc) (bool, string) {
+	if desc.IsEmpty {
+		if n := len(s.Events()); n != 0 {
+			return false, fmt.Sprintf("expected 0 events, got %d", n)
+		}
+		return true, ""
+	}
+	switch n := len(s.Find(desc)); {
+	case n == 0:
+		all, _ := event.All()
+		return false, fmt.Sprintf("Event not found. Existing events in service: %s", debugEvts(all))
+	case n > 1:
+		return false, "Multiple events match query"
+	}
+	return true, ""
+}
+
+func matchesDesc(e *event.Event, desc *EventDesc) bool {
+	if e.Running || e.Target != desc.Target || e.Kind.Name != desc.Kind || e.Owner.Name != desc.Owner {
+		return false
+	}
+	if desc.ErrorMatches != "" {
+		if !regexp.MustCompile(desc.ErrorMatches).MatchString(e.Error) {
+			return false
+		}
+	} else if e.Error != "" {
+		return false
+	}
+	if desc.LogMatches != "" && !regexp.MustCompile(desc.LogMatches).MatchString(e.Log) {
+		return false
+	}
+	var startData, endData, otherData interface{}
+	e.StartData(&startData)
+	e.EndData(&endData)
+	e.OtherData(&otherData)
+	return matchesCustom(startData, desc.StartCustomData) &&
+		matchesCustom(endData, desc.EndCustomData) &&
+		matchesCustom(otherData, desc.OtherCustomData)
+}
+
+// matchesCustom checks stored custom data against the expected value of an
+// EventDesc, the same way the HasEvent query does in MongoDB: maps match
+// when each of their keys, possibly dotted, matches, and slices of maps
+// match when each map matches one of the elements of the stored data.
+func matchesCustom(data interface{}, expected interface{}) bool {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		return matchesFields(data, exp)
+	case []map[string]interface{}:
+		elements, _ := data.([]interface{})
+		for _, m := range exp {
+			var found bool
+			for _, el := range elements {
+				if matchesFields(el, m) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesFields(data interface{}, fields map[string]interface{}) bool {
+	for k, v := range fields {
+		value, ok := lookupField(data, strings.Split(k, "."))
+		if !ok || !reflect.DeepEqual(value, normalize(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupField(data interface{}, path []string) (interface{}, bool) {
+	for _, part := range path {
+		m, ok := data.(bson.M)
+		if !ok {
+			return nil, false
+		}
+		if data, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// normalize converts v to the types it has when read back from BSON, like
+// int64 for big ints and bson.M for maps.
+func normalize(v interface{}) interface{} {
+	data, err := bson.Marshal(bson.M{"v": v})
+	if err != nil {
+		return v
+	}
+	var doc bson.M
+	if err = bson.Unmarshal(data, &doc); err != nil {
+		return v
+	}
+	return doc["v"]
+}
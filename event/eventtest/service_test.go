@@ -0,0 +1,195 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+var _ = check.Suite(&ServiceSuite{})
+
+type ServiceSuite struct {
+	svc *Service
+}
+
+func (s *ServiceSuite) SetUpTest(c *check.C) {
+	s.svc = NewService()
+	event.SetService(s.svc)
+}
+
+func (s *ServiceSuite) TearDownTest(c *check.C) {
+	event.SetService(nil)
+}
+
+func newOpts(app string, data interface{}) *event.Opts {
+	return &event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: app},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: "me@tsuru.io"},
+		CustomData: data,
+		Allowed:    event.Allowed(permission.PermAppReadEvents),
+	}
+}
+
+func (s *ServiceSuite) TestNewAndDone(c *check.C) {
+	evt, err := event.New(newOpts("myapp", map[string]interface{}{"image": "v1", "units": 2}))
+	c.Assert(err, check.IsNil)
+	evt.Logf("deploying %s", "v1")
+	running, err := event.GetRunning(evt.Target, "app.deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(running, check.Equals, evt)
+	err = evt.DoneCustomData(nil, map[string]interface{}{"version": 1})
+	c.Assert(err, check.IsNil)
+	_, err = event.GetRunning(evt.Target, "app.deploy")
+	c.Assert(err, check.Equals, event.ErrEventNotFound)
+	c.Assert(EventDesc{
+		Target:          event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:            "app.deploy",
+		Owner:           "me@tsuru.io",
+		StartCustomData: map[string]interface{}{"image": "v1", "units": 2},
+		EndCustomData:   map[string]interface{}{"version": 1},
+		LogMatches:      "deploying v1",
+	}, HasEvent)
+	c.Assert(s.svc.Find(EventDesc{
+		Target:          event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:            "app.deploy",
+		Owner:           "me@tsuru.io",
+		StartCustomData: map[string]interface{}{"image": "v2"},
+	}), check.HasLen, 0)
+	dbEvt, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.Running, check.Equals, false)
+}
+
+func (s *ServiceSuite) TestNewLocked(c *check.C) {
+	evt, err := event.New(newOpts("myapp", nil))
+	c.Assert(err, check.IsNil)
+	_, err = event.New(newOpts("myapp", nil))
+	c.Assert(err, check.FitsTypeOf, event.ErrEventLocked{})
+	opts := newOpts("myapp", nil)
+	opts.DisableLock = true
+	unlocked, err := event.New(opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(unlocked.HoldsLock(), check.Equals, false)
+	evt.Done(nil)
+	unlocked.Done(nil)
+	_, err = event.New(newOpts("myapp", nil))
+	c.Assert(err, check.IsNil)
+	c.Assert(s.svc.Events(), check.HasLen, 3)
+}
+
+func (s *ServiceSuite) TestDoneWithError(c *check.C) {
+	evt, err := event.New(newOpts("myapp", []map[string]interface{}{{"name": "image", "value": "v1"}}))
+	c.Assert(err, check.IsNil)
+	evt.Done(errors.New("deploy failed: build error"))
+	c.Assert(EventDesc{
+		Target:          event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:            "app.deploy",
+		Owner:           "me@tsuru.io",
+		StartCustomData: []map[string]interface{}{{"name": "image", "value": "v1"}},
+		ErrorMatches:    "deploy failed: .*",
+	}, HasEvent)
+	c.Assert(s.svc.Find(EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:   "app.deploy",
+		Owner:  "me@tsuru.io",
+	}), check.HasLen, 0)
+}
+
+func (s *ServiceSuite) TestAbort(c *check.C) {
+	evt, err := event.New(newOpts("myapp", nil))
+	c.Assert(err, check.IsNil)
+	err = evt.Abort()
+	c.Assert(err, check.IsNil)
+	c.Assert(EventDesc{IsEmpty: true}, HasEvent)
+}
+
+func (s *ServiceSuite) TestOtherCustomDataAndCancel(c *check.C) {
+	opts := newOpts("myapp", nil)
+	opts.Cancelable = true
+	opts.AllowedCancel = event.Allowed(permission.PermAppUpdateEvents)
+	evt, err := event.New(opts)
+	c.Assert(err, check.IsNil)
+	err = evt.SetOtherCustomData(map[string]interface{}{"step": "build"})
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("too slow", "me@tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("too slow", "me@tsuru.io")
+	c.Assert(err, check.Equals, event.ErrEventNotFound)
+	canceled, err := evt.AckCancel()
+	c.Assert(err, check.IsNil)
+	c.Assert(canceled, check.Equals, true)
+	evt.Done(nil)
+	c.Assert(EventDesc{
+		Target:          event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:            "app.deploy",
+		Owner:           "me@tsuru.io",
+		OtherCustomData: map[string]interface{}{"step": "build"},
+		ErrorMatches:    "canceled by user request",
+	}, HasEvent)
+}
+
+func (s *ServiceSuite) TestList(c *check.C) {
+	for _, app := range []string{"app1", "app2", "app3"} {
+		evt, err := event.New(newOpts(app, nil))
+		c.Assert(err, check.IsNil)
+		if app != "app3" {
+			evt.Done(nil)
+		}
+	}
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 3)
+	running := true
+	evts, err = event.List(&event.Filter{Running: &running})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target.Value, check.Equals, "app3")
+	evts, err = event.List(&event.Filter{Target: event.Target{Type: event.TargetTypeApp, Value: "app1"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target.Value, check.Equals, "app1")
+	evts, err = event.List(&event.Filter{Limit: 1, Skip: 1})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(s.svc.Find(EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "app2"},
+		Kind:   "app.deploy",
+		Owner:  "me@tsuru.io",
+	}), check.HasLen, 1)
+}
+
+func (s *ServiceSuite) TestContext(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := newOpts("myapp", nil)
+	opts.Context = ctx
+	evt, err := event.New(opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Context(), check.Equals, ctx)
+	evt.Done(nil)
+	cancel()
+	_, err = event.New(opts)
+	c.Assert(err, check.Equals, context.Canceled)
+	c.Assert(s.svc.Events(), check.HasLen, 1)
+}
+
+func (s *ServiceSuite) TestReset(c *check.C) {
+	_, err := event.New(newOpts("myapp", nil))
+	c.Assert(err, check.IsNil)
+	s.svc.Reset()
+	c.Assert(s.svc.Events(), check.HasLen, 0)
+	_, err = event.New(newOpts("myapp", nil))
+	c.Assert(err, check.IsNil)
+}
@@ -0,0 +1,324 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package export ships finished events to external audit sinks.
+//
+// Each configured sink keeps its own checkpoint in the database, containing
+// the end time and unique id of the last event successfully delivered to it.
+// The checkpoint is only moved after a batch is accepted by the sink, so
+// events are delivered at least once: a failure or a restart causes the same
+// events to be sent again.
+package export
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultInterval  = 10 * time.Second
+	defaultDelay     = 30 * time.Second
+	defaultBatchSize = 100
+	lockDuration     = time.Minute
+)
+
+// Sink is a destination for exported events.
+type Sink interface {
+	// Send delivers the records, returning an error if any of them may
+	// not have been stored.
+	Send(records []Record) error
+}
+
+type sinkFactory func(name, prefix string) (Sink, error)
+
+var sinkFactories = map[string]sinkFactory{
+	"syslog": newSyslogSink,
+	"http":   newHTTPSink,
+	"kafka":  newKafkaSink,
+	"s3":     newS3Sink,
+}
+
+// Record is the representation of an event sent to sinks.
+type Record struct {
+	ID              bson.ObjectId
+	StartTime       time.Time
+	EndTime         time.Time
+	Target          event.Target
+	Kind            event.Kind
+	Owner           event.Owner
+	Error           string
+	Canceled        bool
+	StartCustomData interface{} `json:",omitempty"`
+	EndCustomData   interface{} `json:",omitempty"`
+	OtherCustomData interface{} `json:",omitempty"`
+	Log             string      `json:",omitempty"`
+}
+
+func newRecord(evt *event.Event) Record {
+	r := Record{
+		ID:        evt.UniqueID,
+		StartTime: evt.StartTime,
+		EndTime:   evt.EndTime,
+		Target:    evt.Target,
+		Kind:      evt.Kind,
+		Owner:     evt.Owner,
+		Error:     evt.Error,
+		Canceled:  evt.CancelInfo.Canceled,
+		Log:       evt.Log,
+	}
+	evt.StartData(&r.StartCustomData)
+	evt.EndData(&r.EndCustomData)
+	evt.OtherData(&r.OtherCustomData)
+	return r
+}
+
+type checkpoint struct {
+	Sink       string `bson:"_id"`
+	EndTime    time.Time
+	UniqueID   bson.ObjectId `bson:",omitempty"`
+	LockOwner  string
+	LockExpire time.Time
+}
+
+type exporter struct {
+	name      string
+	sink      Sink
+	batchSize int
+	delay     time.Duration
+	owner     string
+}
+
+// Exporter periodically sends finished events to all configured sinks.
+type Exporter struct {
+	exporters []*exporter
+	interval  time.Duration
+	done      chan bool
+}
+
+// Initialize starts exporting events to the sinks configured in
+// event:export:sinks. It's a noop if no sink is configured.
+func Initialize() error {
+	e, err := newExporter()
+	if err != nil || e == nil {
+		return err
+	}
+	shutdown.Register(e)
+	go e.run()
+	return nil
+}
+
+func newExporter() (*Exporter, error) {
+	sinksConfig, err := config.Get("event:export:sinks")
+	if err != nil {
+		return nil, nil
+	}
+	sinksMap, _ := sinksConfig.(map[interface{}]interface{})
+	var names []string
+	for k := range sinksMap {
+		if name, ok := k.(string); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	interval := readDuration("event:export:interval", defaultInterval)
+	delay := readDuration("event:export:delay", defaultDelay)
+	batchSize, _ := config.GetInt("event:export:batch-size")
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), bson.NewObjectId().Hex())
+	e := &Exporter{interval: interval, done: make(chan bool)}
+	for _, name := range names {
+		prefix := "event:export:sinks:" + name
+		sinkType, _ := config.GetString(prefix + ":type")
+		factory, ok := sinkFactories[sinkType]
+		if !ok {
+			return nil, errors.Errorf("invalid type %q for event export sink %q", sinkType, name)
+		}
+		sink, err := factory(name, prefix)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to configure event export sink %q", name)
+		}
+		e.exporters = append(e.exporters, &exporter{
+			name:      name,
+			sink:      sink,
+			batchSize: batchSize,
+			delay:     delay,
+			owner:     owner,
+		})
+	}
+	return e, nil
+}
+
+func readDuration(key string, def time.Duration) time.Duration {
+	seconds, _ := config.GetFloat(key)
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (e *Exporter) run() {
+	for {
+		e.runOnce()
+		select {
+		case <-e.done:
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+func (e *Exporter) runOnce() {
+	for _, exp := range e.exporters {
+		err := exp.export()
+		if err != nil {
+			log.Errorf("[event export] unable to export events to %q: %s", exp.name, err)
+		}
+	}
+}
+
+func (e *Exporter) Shutdown() {
+	e.done <- true
+	for _, exp := range e.exporters {
+		exp.unlock()
+	}
+}
+
+func (e *Exporter) String() string {
+	return "event exporter"
+}
+
+// export sends all pending events to the sink, one batch at a time. Only one
+// tsuru instance exports to a sink at any given time.
+func (e *exporter) export() error {
+	cp, err := e.lock()
+	if err != nil || cp == nil {
+		return err
+	}
+	for {
+		evts, err := e.pending(cp)
+		if err != nil {
+			return err
+		}
+		if len(evts) == 0 {
+			return nil
+		}
+		records := make([]Record, len(evts))
+		for i := range evts {
+			records[i] = newRecord(&evts[i])
+		}
+		err = e.sink.Send(records)
+		if err != nil {
+			return err
+		}
+		last := &evts[len(evts)-1]
+		cp.EndTime, cp.UniqueID = last.EndTime, last.UniqueID
+		cp, err = e.saveCheckpoint(cp)
+		if err != nil || cp == nil {
+			return err
+		}
+		if len(evts) < e.batchSize {
+			return nil
+		}
+	}
+}
+
+func (e *exporter) pending(cp *checkpoint) ([]event.Event, error) {
+	endTimeQuery := bson.M{"$lte": time.Now().UTC().Add(-e.delay)}
+	query := bson.M{
+		"running": false,
+		"endtime": endTimeQuery,
+	}
+	if !cp.EndTime.IsZero() {
+		endTimeQuery["$gte"] = cp.EndTime
+		query["$or"] = []bson.M{
+			{"endtime": bson.M{"$gt": cp.EndTime}},
+			{"uniqueid": bson.M{"$gt": cp.UniqueID}},
+		}
+	}
+	return event.List(&event.Filter{
+		Raw:            query,
+		IncludeRemoved: true,
+		Sort:           "endtime",
+		Limit:          e.batchSize,
+	})
+}
+
+// lock acquires the lock on the sink checkpoint, returning nil if another
+// instance holds it.
+func (e *exporter) lock() (*checkpoint, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	var cp checkpoint
+	_, err = conn.EventExportCheckpoints().Find(bson.M{
+		"_id": e.name,
+		"$or": []bson.M{
+			{"lockowner": e.owner},
+			{"lockexpire": bson.M{"$lt": now}},
+		},
+	}).Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"lockowner": e.owner, "lockexpire": now.Add(lockDuration)}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &cp)
+	if mgo.IsDup(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint stores the checkpoint and renews the lock, returning nil if
+// the lock was lost in the meantime.
+func (e *exporter) saveCheckpoint(cp *checkpoint) (*checkpoint, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	cp.LockExpire = time.Now().UTC().Add(lockDuration)
+	err = conn.EventExportCheckpoints().Update(bson.M{"_id": e.name, "lockowner": e.owner}, bson.M{
+		"$set": bson.M{"endtime": cp.EndTime, "uniqueid": cp.UniqueID, "lockexpire": cp.LockExpire},
+	})
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (e *exporter) unlock() {
+	conn, err := db.Conn()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.EventExportCheckpoints().Update(bson.M{"_id": e.name, "lockowner": e.owner}, bson.M{
+		"$set": bson.M{"lockexpire": time.Time{}},
+	})
+}
@@ -0,0 +1,292 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("event:export")
+}
+
+func testRecords() []Record {
+	endTime := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	return []Record{
+		{ID: bson.ObjectIdHex("591300000000000000000001"), EndTime: endTime, Target: event.Target{Type: "app", Value: "myapp"}},
+		{ID: bson.ObjectIdHex("591300000000000000000002"), EndTime: endTime, Target: event.Target{Type: "app", Value: "otherapp"}},
+	}
+}
+
+func (s *S) TestHTTPSink(c *check.C) {
+	var body []byte
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	config.Set("event:export:sinks:audit:url", srv.URL)
+	config.Set("event:export:sinks:audit:headers:X-Token", "secret")
+	sink, err := newHTTPSink("audit", "event:export:sinks:audit")
+	c.Assert(err, check.IsNil)
+	err = sink.Send(testRecords())
+	c.Assert(err, check.IsNil)
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.Header.Get("Content-Type"), check.Equals, "application/x-ndjson")
+	c.Assert(req.Header.Get("X-Token"), check.Equals, "secret")
+	var values []string
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		var r Record
+		err = json.Unmarshal(scanner.Bytes(), &r)
+		c.Assert(err, check.IsNil)
+		values = append(values, r.Target.Value)
+	}
+	c.Assert(values, check.DeepEquals, []string{"myapp", "otherapp"})
+}
+
+func (s *S) TestHTTPSinkError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	config.Set("event:export:sinks:audit:url", srv.URL)
+	sink, err := newHTTPSink("audit", "event:export:sinks:audit")
+	c.Assert(err, check.IsNil)
+	err = sink.Send(testRecords())
+	c.Assert(err, check.ErrorMatches, `invalid response from .*: 503 - unavailable`)
+}
+
+func (s *S) TestHTTPSinkRequiresURL(c *check.C) {
+	_, err := newHTTPSink("audit", "event:export:sinks:audit")
+	c.Assert(err, check.ErrorMatches, "url is required")
+}
+
+func (s *S) TestKafkaSink(c *check.C) {
+	var body []byte
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	config.Set("event:export:sinks:kafka:rest-proxy-url", srv.URL+"/")
+	config.Set("event:export:sinks:kafka:topic", "tsuru-events")
+	sink, err := newKafkaSink("kafka", "event:export:sinks:kafka")
+	c.Assert(err, check.IsNil)
+	err = sink.Send(testRecords())
+	c.Assert(err, check.IsNil)
+	c.Assert(req.URL.Path, check.Equals, "/topics/tsuru-events")
+	c.Assert(req.Header.Get("Content-Type"), check.Equals, "application/vnd.kafka.json.v2+json")
+	var msg struct {
+		Records []struct {
+			Key   string
+			Value Record
+		}
+	}
+	err = json.Unmarshal(body, &msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Records, check.HasLen, 2)
+	c.Assert(msg.Records[0].Key, check.Equals, "591300000000000000000001")
+	c.Assert(msg.Records[1].Value.Target.Value, check.Equals, "otherapp")
+}
+
+func (s *S) TestS3Sink(c *check.C) {
+	var body []byte
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	config.Set("event:export:sinks:s3:bucket", "audit")
+	config.Set("event:export:sinks:s3:prefix", "/tsuru/")
+	config.Set("event:export:sinks:s3:endpoint", srv.URL)
+	config.Set("event:export:sinks:s3:access-key-id", "key")
+	config.Set("event:export:sinks:s3:secret-access-key", "secret")
+	sink, err := newS3Sink("s3", "event:export:sinks:s3")
+	c.Assert(err, check.IsNil)
+	err = sink.Send(testRecords())
+	c.Assert(err, check.IsNil)
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/audit/tsuru/2017/05/10/591300000000000000000001-591300000000000000000002.ndjson")
+	c.Assert(req.Header.Get("Authorization"), check.Matches, "AWS4-HMAC-SHA256 Credential=key/.*/us-east-1/s3/aws4_request.*")
+	c.Assert(strings.Count(string(body), "\n"), check.Equals, 2)
+}
+
+func (s *S) TestNewExporterNoSinks(c *check.C) {
+	e, err := newExporter()
+	c.Assert(err, check.IsNil)
+	c.Assert(e, check.IsNil)
+}
+
+func (s *S) TestNewExporterInvalidType(c *check.C) {
+	config.Set("event:export:sinks:audit:type", "carrier-pigeon")
+	_, err := newExporter()
+	c.Assert(err, check.ErrorMatches, `invalid type "carrier-pigeon" for event export sink "audit"`)
+}
+
+func (s *S) TestNewExporter(c *check.C) {
+	config.Set("event:export:interval", 2)
+	config.Set("event:export:batch-size", 10)
+	config.Set("event:export:sinks:b:type", "http")
+	config.Set("event:export:sinks:b:url", "http://localhost")
+	config.Set("event:export:sinks:a:type", "kafka")
+	config.Set("event:export:sinks:a:rest-proxy-url", "http://localhost")
+	config.Set("event:export:sinks:a:topic", "events")
+	e, err := newExporter()
+	c.Assert(err, check.IsNil)
+	c.Assert(e.interval, check.Equals, 2*time.Second)
+	c.Assert(e.exporters, check.HasLen, 2)
+	c.Assert(e.exporters[0].name, check.Equals, "a")
+	c.Assert(e.exporters[0].sink, check.FitsTypeOf, &kafkaSink{})
+	c.Assert(e.exporters[1].name, check.Equals, "b")
+	c.Assert(e.exporters[1].batchSize, check.Equals, 10)
+	c.Assert(e.exporters[1].delay, check.Equals, defaultDelay)
+}
+
+type fakeSink struct {
+	sync.Mutex
+	records []Record
+	err     error
+}
+
+func (s *fakeSink) Send(records []Record) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+type ExportSuite struct {
+	conn *db.Storage
+}
+
+var _ = check.Suite(&ExportSuite{})
+
+func (s *ExportSuite) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_event_export_tests")
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ExportSuite) TearDownSuite(c *check.C) {
+	s.conn.Events().Database.DropDatabase()
+	s.conn.Close()
+}
+
+func (s *ExportSuite) SetUpTest(c *check.C) {
+	err := dbtest.ClearAllCollections(s.conn.Events().Database)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ExportSuite) newEvents(c *check.C, n int) []*event.Event {
+	evts := make([]*event.Event, n)
+	for i := range evts {
+		evt, err := event.NewInternal(&event.Opts{
+			Target:       event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+			InternalKind: "test",
+			Allowed:      event.Allowed(permission.PermAppReadEvents),
+			DisableLock:  true,
+			CustomData:   map[string]int{"i": i},
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.Done(nil)
+		c.Assert(err, check.IsNil)
+		evts[i] = evt
+	}
+	return evts
+}
+
+func (s *ExportSuite) TestExport(c *check.C) {
+	evts := s.newEvents(c, 5)
+	running, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: "otherapp"},
+		InternalKind: "test",
+		Allowed:      event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer running.Done(nil)
+	sink := &fakeSink{}
+	exp := &exporter{name: "fake", sink: sink, batchSize: 2, owner: "me"}
+	err = exp.export()
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.records, check.HasLen, 5)
+	for i := range evts {
+		c.Assert(sink.records[i].ID, check.Equals, evts[i].UniqueID)
+		c.Assert(sink.records[i].StartCustomData, check.DeepEquals, bson.M{"i": i})
+	}
+	more := s.newEvents(c, 1)
+	err = exp.export()
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.records, check.HasLen, 6)
+	c.Assert(sink.records[5].ID, check.Equals, more[0].UniqueID)
+}
+
+func (s *ExportSuite) TestExportRetriesAfterFailure(c *check.C) {
+	s.newEvents(c, 3)
+	sink := &fakeSink{err: errors.New("sink is down")}
+	exp := &exporter{name: "fake", sink: sink, batchSize: 10, owner: "me"}
+	err := exp.export()
+	c.Assert(err, check.ErrorMatches, "sink is down")
+	sink.err = nil
+	err = exp.export()
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.records, check.HasLen, 3)
+}
+
+func (s *ExportSuite) TestExportLockedByOtherInstance(c *check.C) {
+	s.newEvents(c, 3)
+	sink := &fakeSink{}
+	other := &exporter{name: "fake", sink: sink, batchSize: 10, owner: "other"}
+	_, err := other.lock()
+	c.Assert(err, check.IsNil)
+	exp := &exporter{name: "fake", sink: sink, batchSize: 10, owner: "me"}
+	err = exp.export()
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.records, check.HasLen, 0)
+	other.unlock()
+	err = exp.export()
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.records, check.HasLen, 3)
+}
+
+func (s *ExportSuite) TestExportRespectsDelay(c *check.C) {
+	s.newEvents(c, 2)
+	sink := &fakeSink{}
+	exp := &exporter{name: "fake", sink: sink, batchSize: 10, owner: "me", delay: time.Hour}
+	err := exp.export()
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.records, check.HasLen, 0)
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/syslog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+func encodeNDJSON(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		err := enc.Encode(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func doRequest(client *http.Client, req *http.Request) error {
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("invalid response from %s: %d - %s", req.URL, rsp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+func readHeaders(prefix string) http.Header {
+	headers := http.Header{}
+	raw, err := config.Get(prefix + ":headers")
+	if err != nil {
+		return headers
+	}
+	m, _ := raw.(map[interface{}]interface{})
+	for k, v := range m {
+		headers.Set(fmt.Sprint(k), fmt.Sprint(v))
+	}
+	return headers
+}
+
+// syslogSink writes each event as a JSON message to a syslog server (or the
+// local syslog daemon when no address is configured).
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(name, prefix string) (Sink, error) {
+	network, _ := config.GetString(prefix + ":network")
+	address, _ := config.GetString(prefix + ":address")
+	tag, _ := config.GetString(prefix + ":tag")
+	if tag == "" {
+		tag = "tsuru-audit"
+	}
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) Send(records []Record) error {
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		err = s.writer.Info(string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// httpSink posts events as newline delimited JSON to an HTTP endpoint.
+type httpSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+func newHTTPSink(name, prefix string) (Sink, error) {
+	url, _ := config.GetString(prefix + ":url")
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	return &httpSink{
+		url:     url,
+		headers: readHeaders(prefix),
+		client:  tsuruNet.Dial5Full60ClientNoKeepAlive,
+	}, nil
+}
+
+func (s *httpSink) Send(records []Record) error {
+	data, err := encodeNDJSON(records)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	return doRequest(s.client, req)
+}
+
+// kafkaSink produces events to a Kafka topic through a Kafka REST proxy,
+// using the event id as the message key.
+type kafkaSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Record `json:"value"`
+}
+
+func newKafkaSink(name, prefix string) (Sink, error) {
+	proxyURL, _ := config.GetString(prefix + ":rest-proxy-url")
+	if proxyURL == "" {
+		return nil, errors.New("rest-proxy-url is required")
+	}
+	topic, _ := config.GetString(prefix + ":topic")
+	if topic == "" {
+		return nil, errors.New("topic is required")
+	}
+	return &kafkaSink{
+		url:     strings.TrimRight(proxyURL, "/") + "/topics/" + topic,
+		headers: readHeaders(prefix),
+		client:  tsuruNet.Dial5Full60ClientNoKeepAlive,
+	}, nil
+}
+
+func (s *kafkaSink) Send(records []Record) error {
+	msg := struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: make([]kafkaRecord, len(records))}
+	for i, r := range records {
+		msg.Records[i] = kafkaRecord{Key: r.ID.Hex(), Value: r}
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	return doRequest(s.client, req)
+}
+
+// s3Sink stores each batch of events as a NDJSON object in a S3 bucket. The
+// object key includes the id of the first and last events of the batch, so
+// retried batches overwrite the previous attempt.
+type s3Sink struct {
+	endpoint string
+	bucket   string
+	prefix   string
+	region   string
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+func newS3Sink(name, prefix string) (Sink, error) {
+	bucket, _ := config.GetString(prefix + ":bucket")
+	if bucket == "" {
+		return nil, errors.New("bucket is required")
+	}
+	region, _ := config.GetString(prefix + ":region")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint, _ := config.GetString(prefix + ":endpoint")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	keyID, _ := config.GetString(prefix + ":access-key-id")
+	secret, _ := config.GetString(prefix + ":secret-access-key")
+	var creds *credentials.Credentials
+	if keyID != "" {
+		creds = credentials.NewStaticCredentials(keyID, secret, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	keyPrefix, _ := config.GetString(prefix + ":prefix")
+	return &s3Sink{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		bucket:   bucket,
+		prefix:   strings.Trim(keyPrefix, "/"),
+		region:   region,
+		signer:   v4.NewSigner(creds),
+		client:   tsuruNet.Dial5Full300ClientNoKeepAlive,
+	}, nil
+}
+
+func (s *s3Sink) objectKey(records []Record) string {
+	first, last := records[0], records[len(records)-1]
+	key := fmt.Sprintf("%s/%s-%s.ndjson", first.EndTime.UTC().Format("2006/01/02"), first.ID.Hex(), last.ID.Hex())
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return key
+}
+
+func (s *s3Sink) Send(records []Record) error {
+	data, err := encodeNDJSON(records)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, s.objectKey(records))
+	body := bytes.NewReader(data)
+	req, err := http.NewRequest("PUT", url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	_, err = s.signer.Sign(req, body, "s3", s.region, time.Now())
+	if err != nil {
+		return err
+	}
+	return doRequest(s.client, req)
+}
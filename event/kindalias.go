@@ -0,0 +1,158 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrKindAliasNotFound = errors.New("event kind alias not found")
+
+// KindAlias is the friendly label and the category of an event kind, used
+// when displaying and grouping events. The category of an alias also applies
+// to the kinds under it, so the alias of "app.update" categorizes
+// "app.update.env.set" too, unless that kind has its own category.
+type KindAlias struct {
+	Name     string `bson:"_id"`
+	Label    string `bson:",omitempty" json:",omitempty"`
+	Category string `bson:",omitempty" json:",omitempty"`
+}
+
+func (a *KindAlias) validate() error {
+	if a.Name == "" {
+		return ErrValidation("event kind alias name is required")
+	}
+	if a.Label == "" && a.Category == "" {
+		return ErrValidation("event kind alias requires a label or a category")
+	}
+	return nil
+}
+
+// SetKindAlias adds or replaces the alias of a kind.
+func SetKindAlias(alias KindAlias) error {
+	err := alias.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.EventKindAliases().UpsertId(alias.Name, alias)
+	return err
+}
+
+// RemoveKindAlias removes the alias of a kind, which is then displayed with
+// its default label and category.
+func RemoveKindAlias(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.EventKindAliases().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrKindAliasNotFound
+	}
+	return err
+}
+
+// ListKindAliases returns the registered aliases sorted by kind name.
+func ListKindAliases() ([]KindAlias, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	aliases := []KindAlias{}
+	err = conn.EventKindAliases().Find(nil).Sort("_id").All(&aliases)
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// KindAliases resolves the labels and categories of kinds. The zero value
+// resolves every kind to its default label and category.
+type KindAliases map[string]KindAlias
+
+// LoadKindAliases returns all registered aliases, to resolve the labels of
+// many kinds with a single query.
+func LoadKindAliases() (KindAliases, error) {
+	aliases, err := ListKindAliases()
+	if err != nil {
+		return nil, err
+	}
+	result := make(KindAliases, len(aliases))
+	for _, alias := range aliases {
+		result[alias.Name] = alias
+	}
+	return result, nil
+}
+
+// Resolve returns the alias of the kind with the given name. The label
+// defaults to the kind name with words separated by spaces, like "App update
+// env set", and the category to the category of the closest parent kind or
+// to the first part of the name, like "app".
+func (a KindAliases) Resolve(name string) KindAlias {
+	result := a[name]
+	result.Name = name
+	if result.Label == "" {
+		result.Label = defaultKindLabel(name)
+	}
+	parent := name
+	for result.Category == "" {
+		if alias, ok := a[parent]; ok && alias.Category != "" {
+			result.Category = alias.Category
+			break
+		}
+		i := strings.LastIndex(parent, ".")
+		if i < 0 {
+			result.Category = parent
+			break
+		}
+		parent = parent[:i]
+	}
+	return result
+}
+
+// ResolveKindAlias returns the alias of a single kind, as resolved by
+// KindAliases.Resolve.
+func ResolveKindAlias(name string) (KindAlias, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return KindAlias{}, err
+	}
+	defer conn.Close()
+	names := []string{name}
+	for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name[:i], ".") {
+		names = append(names, name[:i])
+	}
+	var aliases []KindAlias
+	err = conn.EventKindAliases().Find(bson.M{"_id": bson.M{"$in": names}}).All(&aliases)
+	if err != nil {
+		return KindAlias{}, err
+	}
+	resolver := make(KindAliases, len(aliases))
+	for _, alias := range aliases {
+		resolver[alias.Name] = alias
+	}
+	return resolver.Resolve(name), nil
+}
+
+func defaultKindLabel(name string) string {
+	label := strings.Replace(name, ".", " ", -1)
+	label = strings.Replace(label, "-", " ", -1)
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestKindAliasesResolveDefaults(c *check.C) {
+	var aliases KindAliases
+	c.Assert(aliases.Resolve("app.update.env.set"), check.DeepEquals, KindAlias{
+		Name:     "app.update.env.set",
+		Label:    "App update env set",
+		Category: "app",
+	})
+	c.Assert(aliases.Resolve("node.auto-scale"), check.DeepEquals, KindAlias{
+		Name:     "node.auto-scale",
+		Label:    "Node auto scale",
+		Category: "node",
+	})
+}
+
+func (s *S) TestKindAliasesResolveParentCategory(c *check.C) {
+	aliases := KindAliases{
+		"app.update":         {Name: "app.update", Label: "Update app", Category: "configuration"},
+		"app.update.env.set": {Name: "app.update.env.set", Label: "Set environment variables"},
+		"app.deploy":         {Name: "app.deploy", Category: "deploys"},
+	}
+	c.Assert(aliases.Resolve("app.update.env.set"), check.DeepEquals, KindAlias{
+		Name:     "app.update.env.set",
+		Label:    "Set environment variables",
+		Category: "configuration",
+	})
+	c.Assert(aliases.Resolve("app.update.env.unset"), check.DeepEquals, KindAlias{
+		Name:     "app.update.env.unset",
+		Label:    "App update env unset",
+		Category: "configuration",
+	})
+	c.Assert(aliases.Resolve("app.deploy.rollback"), check.DeepEquals, KindAlias{
+		Name:     "app.deploy.rollback",
+		Label:    "App deploy rollback",
+		Category: "deploys",
+	})
+}
+
+func (s *S) TestSetKindAlias(c *check.C) {
+	err := SetKindAlias(KindAlias{Name: "app.update", Label: "Update app"})
+	c.Assert(err, check.IsNil)
+	err = SetKindAlias(KindAlias{Name: "app.update", Label: "Update", Category: "configuration"})
+	c.Assert(err, check.IsNil)
+	err = SetKindAlias(KindAlias{Name: "app.deploy", Category: "deploys"})
+	c.Assert(err, check.IsNil)
+	aliases, err := ListKindAliases()
+	c.Assert(err, check.IsNil)
+	c.Assert(aliases, check.DeepEquals, []KindAlias{
+		{Name: "app.deploy", Category: "deploys"},
+		{Name: "app.update", Label: "Update", Category: "configuration"},
+	})
+}
+
+func (s *S) TestSetKindAliasInvalid(c *check.C) {
+	err := SetKindAlias(KindAlias{Label: "Update app"})
+	c.Assert(err, check.Equals, ErrValidation("event kind alias name is required"))
+	err = SetKindAlias(KindAlias{Name: "app.update"})
+	c.Assert(err, check.Equals, ErrValidation("event kind alias requires a label or a category"))
+}
+
+func (s *S) TestRemoveKindAlias(c *check.C) {
+	err := SetKindAlias(KindAlias{Name: "app.update", Label: "Update app"})
+	c.Assert(err, check.IsNil)
+	err = RemoveKindAlias("app.update")
+	c.Assert(err, check.IsNil)
+	aliases, err := ListKindAliases()
+	c.Assert(err, check.IsNil)
+	c.Assert(aliases, check.HasLen, 0)
+	err = RemoveKindAlias("app.update")
+	c.Assert(err, check.Equals, ErrKindAliasNotFound)
+}
+
+func (s *S) TestResolveKindAlias(c *check.C) {
+	err := SetKindAlias(KindAlias{Name: "app.update", Category: "configuration"})
+	c.Assert(err, check.IsNil)
+	err = SetKindAlias(KindAlias{Name: "app.update.env.set", Label: "Set environment variables"})
+	c.Assert(err, check.IsNil)
+	err = SetKindAlias(KindAlias{Name: "node.create", Label: "Add node"})
+	c.Assert(err, check.IsNil)
+	alias, err := ResolveKindAlias("app.update.env.set")
+	c.Assert(err, check.IsNil)
+	c.Assert(alias, check.DeepEquals, KindAlias{
+		Name:     "app.update.env.set",
+		Label:    "Set environment variables",
+		Category: "configuration",
+	})
+	loaded, err := LoadKindAliases()
+	c.Assert(err, check.IsNil)
+	c.Assert(loaded, check.HasLen, 3)
+	c.Assert(loaded.Resolve("node.create").Label, check.Equals, "Add node")
+}
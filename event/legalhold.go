@@ -0,0 +1,195 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const legalHoldListLimit = 25
+
+type ErrActiveLegalHoldNotFound struct {
+	id string
+}
+
+func (e *ErrActiveLegalHoldNotFound) Error() string {
+	return fmt.Sprintf("active legal hold with id %s not found", e.id)
+}
+
+// LegalHold exempts the events matching its target, owner and start time
+// range from retention, archival and purge jobs, and from the anonymization
+// of their owners, until it's released. Empty fields match all events.
+//
+// Code removing or rewriting stored events must exclude held events from its
+// queries with ExcludeHeld, or check them with Event.IsHeld.
+type LegalHold struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	StartTime time.Time
+	EndTime   time.Time `bson:"endtime,omitempty"`
+	OwnerName string
+	Target    Target    `bson:"target,omitempty"`
+	Since     time.Time `bson:",omitempty"`
+	Until     time.Time `bson:",omitempty"`
+	Reason    string
+	Active    bool
+}
+
+func (h *LegalHold) String() string {
+	owner := h.OwnerName
+	if owner == "" {
+		owner = "all users"
+	}
+	target := "all targets"
+	if h.Target.Type != "" {
+		target = h.Target.String()
+	}
+	return fmt.Sprintf("legal hold of events by %s on %s: %s", owner, target, h.Reason)
+}
+
+func (h *LegalHold) validate() error {
+	if h.Reason == "" {
+		return ErrValidation("legal hold reason is required")
+	}
+	if h.Target.Type == "" && h.Target.Value != "" {
+		return ErrValidation("legal hold target type is required when target value is set")
+	}
+	if !h.Since.IsZero() && !h.Until.IsZero() && h.Until.Before(h.Since) {
+		return ErrValidation("legal hold until must not be before since")
+	}
+	return nil
+}
+
+func (h *LegalHold) query() (bson.M, error) {
+	f := Filter{
+		Target:         h.Target,
+		OwnerName:      h.OwnerName,
+		Since:          h.Since,
+		Until:          h.Until,
+		IncludeRemoved: true,
+	}
+	return f.toQuery()
+}
+
+func (h *LegalHold) matches(evt *Event) bool {
+	if h.Target.Type != "" && !evt.HasTarget(h.Target) {
+		return false
+	}
+	if h.OwnerName != "" && evt.Owner.Name != h.OwnerName {
+		return false
+	}
+	if !h.Since.IsZero() && evt.StartTime.Before(h.Since) {
+		return false
+	}
+	if !h.Until.IsZero() && evt.StartTime.After(h.Until) {
+		return false
+	}
+	return true
+}
+
+func AddLegalHold(h *LegalHold) error {
+	err := h.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	h.Active = true
+	h.ID = bson.NewObjectId()
+	h.StartTime = time.Now()
+	return conn.EventLegalHolds().Insert(h)
+}
+
+// ReleaseLegalHold deactivates the hold, the events it held become subject
+// to the retention jobs again unless they are held by other holds.
+func ReleaseLegalHold(id bson.ObjectId) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	query := bson.M{"_id": id, "active": true}
+	err = conn.EventLegalHolds().Update(query, bson.M{"$set": bson.M{"active": false, "endtime": time.Now()}})
+	if err == mgo.ErrNotFound {
+		return &ErrActiveLegalHoldNotFound{id: id.Hex()}
+	}
+	return err
+}
+
+// ListLegalHolds returns the most recent legal holds, filtered by their
+// status if active is not nil.
+func ListLegalHolds(active *bool) ([]LegalHold, error) {
+	query := bson.M{}
+	if active != nil {
+		query["active"] = *active
+	}
+	return findLegalHolds(query, legalHoldListLimit)
+}
+
+func findLegalHolds(query bson.M, limit int) ([]LegalHold, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var holds []LegalHold
+	find := conn.EventLegalHolds().Find(query).Sort("-starttime")
+	if limit > 0 {
+		find = find.Limit(limit)
+	}
+	err = find.All(&holds)
+	if err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func activeLegalHolds() ([]LegalHold, error) {
+	return findLegalHolds(bson.M{"active": true}, 0)
+}
+
+// ExcludeHeld returns a query matching the events matched by query which are
+// not held by any active legal hold.
+func ExcludeHeld(query bson.M) (bson.M, error) {
+	holds, err := activeLegalHolds()
+	if err != nil {
+		return nil, err
+	}
+	if len(holds) == 0 {
+		return query, nil
+	}
+	held := make([]bson.M, len(holds))
+	for i := range holds {
+		held[i], err = holds[i].query()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if query == nil {
+		query = bson.M{}
+	}
+	return bson.M{"$and": []bson.M{query, {"$nor": held}}}, nil
+}
+
+// IsHeld returns whether the event is held by an active legal hold.
+func (e *Event) IsHeld() (bool, error) {
+	holds, err := activeLegalHolds()
+	if err != nil {
+		return false, err
+	}
+	for i := range holds {
+		if holds[i].matches(e) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAddLegalHold(c *check.C) {
+	hold := &LegalHold{Target: Target{Type: TargetTypeApp, Value: "myapp"}, Reason: "litigation"}
+	err := AddLegalHold(hold)
+	c.Assert(err, check.IsNil)
+	holds, err := ListLegalHolds(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 1)
+	c.Assert(holds[0].Active, check.Equals, true)
+	c.Assert(holds[0].Target, check.DeepEquals, hold.Target)
+	c.Assert(holds[0].Reason, check.Equals, "litigation")
+}
+
+func (s *S) TestAddLegalHoldInvalid(c *check.C) {
+	err := AddLegalHold(&LegalHold{OwnerName: "me@me.com"})
+	c.Assert(err, check.Equals, ErrValidation("legal hold reason is required"))
+	err = AddLegalHold(&LegalHold{Target: Target{Value: "myapp"}, Reason: "r"})
+	c.Assert(err, check.Equals, ErrValidation("legal hold target type is required when target value is set"))
+	now := time.Now()
+	err = AddLegalHold(&LegalHold{Since: now, Until: now.Add(-time.Hour), Reason: "r"})
+	c.Assert(err, check.Equals, ErrValidation("legal hold until must not be before since"))
+}
+
+func (s *S) TestReleaseLegalHold(c *check.C) {
+	hold := &LegalHold{OwnerName: "me@me.com", Reason: "litigation"}
+	err := AddLegalHold(hold)
+	c.Assert(err, check.IsNil)
+	err = ReleaseLegalHold(hold.ID)
+	c.Assert(err, check.IsNil)
+	active := true
+	holds, err := ListLegalHolds(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 0)
+	active = false
+	holds, err = ListLegalHolds(&active)
+	c.Assert(err, check.IsNil)
+	c.Assert(holds, check.HasLen, 1)
+	c.Assert(holds[0].EndTime.IsZero(), check.Equals, false)
+	err = ReleaseLegalHold(hold.ID)
+	c.Assert(err, check.FitsTypeOf, &ErrActiveLegalHoldNotFound{})
+}
+
+func (s *S) TestEventIsHeld(c *check.C) {
+	evt, err := New(&Opts{
+		Target:       Target{Type: TargetTypeApp, Value: "myapp"},
+		ExtraTargets: []Target{{Type: TargetTypePool, Value: "pool1"}},
+		Kind:         permission.PermAppUpdateEnvSet,
+		Owner:        s.token,
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	held, err := evt.IsHeld()
+	c.Assert(err, check.IsNil)
+	c.Assert(held, check.Equals, false)
+	tests := []struct {
+		hold LegalHold
+		held bool
+	}{
+		{LegalHold{Target: Target{Type: TargetTypeApp, Value: "otherapp"}}, false},
+		{LegalHold{OwnerName: "other@me.com"}, false},
+		{LegalHold{Since: time.Now().Add(time.Hour)}, false},
+		{LegalHold{Until: time.Now().Add(-time.Hour)}, false},
+		{LegalHold{Target: Target{Type: TargetTypeApp, Value: "myapp"}}, true},
+		{LegalHold{Target: Target{Type: TargetTypePool, Value: "pool1"}}, true},
+		{LegalHold{Target: Target{Type: TargetTypeApp}, OwnerName: s.token.GetUserName()}, true},
+		{LegalHold{Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour)}, true},
+	}
+	for i, tt := range tests {
+		tt.hold.Reason = "litigation"
+		err = AddLegalHold(&tt.hold)
+		c.Assert(err, check.IsNil)
+		held, err = evt.IsHeld()
+		c.Assert(err, check.IsNil)
+		c.Check(held, check.Equals, tt.held, check.Commentf("hold %d", i))
+		query, err := ExcludeHeld(bson.M{"kind.name": "app.update.env.set"})
+		c.Assert(err, check.IsNil)
+		conn, err := db.Conn()
+		c.Assert(err, check.IsNil)
+		n, err := conn.Events().Find(query).Count()
+		conn.Close()
+		c.Assert(err, check.IsNil)
+		c.Check(n == 0, check.Equals, tt.held, check.Commentf("hold %d", i))
+		err = ReleaseLegalHold(tt.hold.ID)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestExcludeHeldWithoutHolds(c *check.C) {
+	query := bson.M{"kind.name": "app.deploy"}
+	result, err := ExcludeHeld(query)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, query)
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const lockLeaderID = "lock-updater"
+
+var (
+	// lockOwnerExpireTimeout is the time after which an instance that
+	// stopped updating its locks is considered dead, allowing its events to
+	// be reclaimed before lockExpireTimeout.
+	lockOwnerExpireTimeout = 90 * time.Second
+	instanceID             = newInstanceID()
+)
+
+// lockOwner records the event locks held by an API instance. The lock
+// updater refreshes it along with the lock update time of its events, so a
+// stale record means the instance is gone.
+type lockOwner struct {
+	ID         string `bson:"_id"`
+	Locks      []Target
+	UpdateTime time.Time
+}
+
+type lockLeader struct {
+	ID      string `bson:"_id"`
+	Owner   string
+	Expires time.Time
+}
+
+func newInstanceID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), bson.NewObjectId().Hex())
+}
+
+func updateLockOwner(conn *db.Storage, owner string, locks []Target, now time.Time) error {
+	_, err := conn.EventLockOwners().UpsertId(owner, bson.M{
+		"$set": bson.M{"locks": locks, "updatetime": now},
+	})
+	return err
+}
+
+// isLockOwnerDead returns whether the lock on target, last updated at
+// lastUpdate, is held by an instance that stopped updating its locks.
+func isLockOwnerDead(target Target, lastUpdate time.Time) bool {
+	conn, err := db.Conn()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	var owner lockOwner
+	err = conn.EventLockOwners().Find(bson.M{
+		"locks": target,
+		"updatetime": bson.M{
+			"$lt":  time.Now().UTC().Add(-lockOwnerExpireTimeout),
+			"$gte": lastUpdate,
+		},
+	}).One(&owner)
+	return err == nil
+}
+
+// acquireLockLeader tries to make the instance the one responsible for
+// reclaiming locks of dead instances. The leadership is kept while the
+// instance renews it and is taken over by another instance once it expires.
+func acquireLockLeader(conn *db.Storage, owner string, now time.Time) (bool, error) {
+	coll := conn.EventLockLeader()
+	expires := now.Add(lockOwnerExpireTimeout)
+	err := coll.Update(bson.M{
+		"_id": lockLeaderID,
+		"$or": []bson.M{{"owner": owner}, {"expires": bson.M{"$lt": now}}},
+	}, bson.M{"$set": bson.M{"owner": owner, "expires": expires}})
+	if err == nil {
+		return true, nil
+	}
+	if err != mgo.ErrNotFound {
+		return false, err
+	}
+	err = coll.Insert(lockLeader{ID: lockLeaderID, Owner: owner, Expires: expires})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// reclaimDeadLocks finishes the running events whose locks are held by dead
+// instances, removing their lock owner records. It's a noop unless the
+// instance is the lock leader.
+func reclaimDeadLocks() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	leader, err := acquireLockLeader(conn, instanceID, now)
+	if err != nil || !leader {
+		return err
+	}
+	var owners []lockOwner
+	err = conn.EventLockOwners().Find(bson.M{
+		"_id":        bson.M{"$ne": instanceID},
+		"updatetime": bson.M{"$lt": now.Add(-lockOwnerExpireTimeout)},
+	}).All(&owners)
+	if err != nil {
+		return err
+	}
+	coll := conn.Events()
+	for _, owner := range owners {
+		for _, target := range owner.Locks {
+			// Events updated after the instance died were created by other
+			// instances once the lock expired and must not be touched.
+			var evt Event
+			err = coll.Find(bson.M{
+				"_id":            target,
+				"running":        true,
+				"lockupdatetime": bson.M{"$lte": owner.UpdateTime},
+			}).One(&evt.eventData)
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			evt.Done(errors.Errorf("event expired, instance %s stopped updating its locks at %v", owner.ID, owner.UpdateTime))
+		}
+		err = conn.EventLockOwners().RemoveId(owner.ID)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func reclaimDeadLocksLogErrors() {
+	err := reclaimDeadLocks()
+	if err != nil {
+		log.Errorf("[events] [lock update] error reclaiming locks of dead instances: %s", err)
+	}
+}
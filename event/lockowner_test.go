@@ -0,0 +1,148 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) insertDeadInstanceEvent(c *check.C, owner string, target Target, age time.Duration) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	updateTime := time.Now().UTC().Add(-age).Truncate(time.Millisecond)
+	err = conn.Events().Insert(eventData{
+		ID:             eventID{Target: target},
+		UniqueID:       bson.NewObjectId(),
+		StartTime:      updateTime,
+		LockUpdateTime: updateTime,
+		Target:         target,
+		Kind:           Kind{Type: KindTypePermission, Name: "app.update.env.set"},
+		Owner:          Owner{Type: OwnerTypeUser, Name: s.token.GetUserName()},
+		Running:        true,
+		Allowed:        Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = updateLockOwner(conn, owner, []Target{target}, updateTime)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestNewTakesOverLockOfDeadInstance(c *check.C) {
+	target := Target{Type: "app", Value: "myapp"}
+	s.insertDeadInstanceEvent(c, "dead-instance", target, 2*lockOwnerExpireTimeout)
+	evt, err := New(&Opts{
+		Target:  target,
+		Kind:    permission.PermAppUpdateEnvUnset,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	c.Assert(evts[0].Kind.Name, check.Equals, "app.update.env.unset")
+	c.Assert(evts[0].Running, check.Equals, true)
+	c.Assert(evts[1].Running, check.Equals, false)
+	c.Assert(evts[1].Error, check.Matches, `event expired, lock owner stopped updating it .* ago`)
+}
+
+func (s *S) TestNewKeepsLockOfLiveInstance(c *check.C) {
+	target := Target{Type: "app", Value: "myapp"}
+	s.insertDeadInstanceEvent(c, "live-instance", target, time.Second)
+	_, err := New(&Opts{
+		Target:  target,
+		Kind:    permission.PermAppUpdateEnvUnset,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.FitsTypeOf, ErrEventLocked{})
+}
+
+func (s *S) TestReclaimDeadLocks(c *check.C) {
+	deadTarget := Target{Type: "app", Value: "dead"}
+	liveTarget := Target{Type: "app", Value: "live"}
+	s.insertDeadInstanceEvent(c, "dead-instance", deadTarget, 2*lockOwnerExpireTimeout)
+	s.insertDeadInstanceEvent(c, "live-instance", liveTarget, time.Second)
+	err := reclaimDeadLocks()
+	c.Assert(err, check.IsNil)
+	evts, err := List(&Filter{Target: deadTarget})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, false)
+	c.Assert(evts[0].Error, check.Matches, `event expired, instance dead-instance stopped updating its locks at .*`)
+	evts, err = List(&Filter{Target: liveTarget})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, true)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	var owners []lockOwner
+	err = conn.EventLockOwners().Find(nil).Sort("_id").All(&owners)
+	c.Assert(err, check.IsNil)
+	c.Assert(owners, check.HasLen, 1)
+	c.Assert(owners[0].ID, check.Equals, "live-instance")
+}
+
+func (s *S) TestReclaimDeadLocksIgnoresEventsFromOtherInstances(c *check.C) {
+	target := Target{Type: "app", Value: "myapp"}
+	s.insertDeadInstanceEvent(c, "dead-instance", target, 2*lockOwnerExpireTimeout)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Events().Update(bson.M{"_id": target}, bson.M{"$set": bson.M{"lockupdatetime": time.Now().UTC()}})
+	c.Assert(err, check.IsNil)
+	err = reclaimDeadLocks()
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, true)
+}
+
+func (s *S) TestReclaimDeadLocksNotLeader(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	leader, err := acquireLockLeader(conn, "other-instance", time.Now().UTC())
+	c.Assert(err, check.IsNil)
+	c.Assert(leader, check.Equals, true)
+	s.insertDeadInstanceEvent(c, "dead-instance", Target{Type: "app", Value: "myapp"}, 2*lockOwnerExpireTimeout)
+	err = reclaimDeadLocks()
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, true)
+}
+
+func (s *S) TestAcquireLockLeader(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	now := time.Now().UTC()
+	leader, err := acquireLockLeader(conn, "instance1", now)
+	c.Assert(err, check.IsNil)
+	c.Assert(leader, check.Equals, true)
+	leader, err = acquireLockLeader(conn, "instance2", now)
+	c.Assert(err, check.IsNil)
+	c.Assert(leader, check.Equals, false)
+	leader, err = acquireLockLeader(conn, "instance1", now.Add(time.Second))
+	c.Assert(err, check.IsNil)
+	c.Assert(leader, check.Equals, true)
+	later := now.Add(2 * lockOwnerExpireTimeout)
+	leader, err = acquireLockLeader(conn, "instance2", later)
+	c.Assert(err, check.IsNil)
+	c.Assert(leader, check.Equals, true)
+	leader, err = acquireLockLeader(conn, "instance1", later)
+	c.Assert(err, check.IsNil)
+	c.Assert(leader, check.Equals, false)
+}
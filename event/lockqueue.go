@@ -0,0 +1,97 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/db/storage"
+)
+
+var (
+	lockWaitInterval = time.Second
+	lockWaiters      = lockQueue{queues: map[Target][]chan struct{}{}}
+)
+
+// lockQueue keeps, for each target, the list of events waiting for the
+// target lock to be released, ensuring they acquire the lock in the same
+// order they asked for it in this process.
+type lockQueue struct {
+	sync.Mutex
+	queues map[Target][]chan struct{}
+}
+
+// enqueue adds a new waiter for the target. The returned channel is closed
+// when the waiter reaches the head of the queue.
+func (q *lockQueue) enqueue(t Target) chan struct{} {
+	q.Lock()
+	defer q.Unlock()
+	ch := make(chan struct{})
+	q.queues[t] = append(q.queues[t], ch)
+	if len(q.queues[t]) == 1 {
+		close(ch)
+	}
+	return ch
+}
+
+func (q *lockQueue) dequeue(t Target, ch chan struct{}) {
+	q.Lock()
+	defer q.Unlock()
+	queue := q.queues[t]
+	for i := range queue {
+		if queue[i] == ch {
+			queue = append(queue[:i], queue[i+1:]...)
+			if i == 0 && len(queue) > 0 {
+				close(queue[0])
+			}
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(q.queues, t)
+	} else {
+		q.queues[t] = queue
+	}
+}
+
+// waitLockAndInsert waits for its turn in the target queue and retries
+// inserting the event until the lock is released or opts.WaitLock expires,
+// in which case the last ErrEventLocked is returned. Waiting is also aborted
+// when opts.Context is done, returning the context error.
+func waitLockAndInsert(coll *storage.Collection, evt *Event, opts *Opts, lockErr error) error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := time.After(opts.WaitLock)
+	ticket := lockWaiters.enqueue(opts.Target)
+	defer lockWaiters.dequeue(opts.Target, ticket)
+	select {
+	case <-ticket:
+	case <-timeout:
+		return lockErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for {
+		now := time.Now().UTC()
+		evt.StartTime = now
+		evt.LockUpdateTime = now
+		err := insertEvt(coll, evt, opts)
+		if _, isLocked := err.(ErrEventLocked); !isLocked {
+			return err
+		}
+		lockErr = err
+		select {
+		case <-timeout:
+			return lockErr
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockWaitInterval):
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// NotificationScopeAll matches every event visible to the rule owner.
+	NotificationScopeAll = "all"
+	// NotificationScopeApps matches events targeting apps visible to the
+	// rule owner.
+	NotificationScopeApps = "apps"
+	// NotificationScopeTeams matches events visible to members of the teams
+	// the rule owner has permissions on, e.g. events of apps owned by them.
+	NotificationScopeTeams = "teams"
+
+	NotificationChannelEmail   = "email"
+	NotificationChannelWebhook = "webhook"
+)
+
+var (
+	ErrNotificationRuleNotFound   = errors.New("notification rule not found")
+	ErrNotificationOwnerRequired  = ErrValidation("notification rule owner is required")
+	ErrInvalidNotificationScope   = ErrValidation("invalid scope, must be one of all, apps or teams")
+	ErrInvalidNotificationChannel = ErrValidation("invalid channel, must be one of email or webhook")
+	ErrInvalidNotificationURL     = ErrValidation("webhook channel requires an http or https url")
+)
+
+// NotificationRule subscribes a user to finished events matching a filter.
+// Rules are only ever matched against events visible to their owner, as
+// defined by the Allowed permission of each event, and against events that
+// finished after the rule was created.
+type NotificationRule struct {
+	ID          bson.ObjectId `bson:"_id"`
+	Owner       string
+	Scope       string
+	TargetType  TargetType `bson:",omitempty"`
+	TargetValue string     `bson:",omitempty"`
+	KindName    string     `bson:",omitempty"`
+	ErrorOnly   bool
+	Channel     string
+	URL         string `bson:",omitempty"`
+	CreatedAt   time.Time
+}
+
+func (r *NotificationRule) validate() error {
+	if r.Owner == "" {
+		return ErrNotificationOwnerRequired
+	}
+	switch r.Scope {
+	case "":
+		r.Scope = NotificationScopeAll
+	case NotificationScopeAll, NotificationScopeApps, NotificationScopeTeams:
+	default:
+		return ErrInvalidNotificationScope
+	}
+	switch r.Channel {
+	case "":
+		r.Channel = NotificationChannelEmail
+	case NotificationChannelEmail:
+	case NotificationChannelWebhook:
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidNotificationURL
+		}
+	default:
+		return ErrInvalidNotificationChannel
+	}
+	return nil
+}
+
+// Matches returns whether the finished event must be notified to the owner
+// of the rule, whose permissions are perms. Kinds are matched by prefix, so
+// a rule with the kind app.update matches app.update.env.set events.
+func (r *NotificationRule) Matches(evt *Event, perms []permission.Permission) bool {
+	if evt.Running || evt.EndTime.Before(r.CreatedAt) {
+		return false
+	}
+	if r.ErrorOnly && evt.Error == "" {
+		return false
+	}
+	if !evt.HasTarget(Target{Type: r.TargetType, Value: r.TargetValue}) {
+		return false
+	}
+	if r.KindName != "" && evt.Kind.Name != r.KindName && !strings.HasPrefix(evt.Kind.Name, r.KindName+".") {
+		return false
+	}
+	if !evt.VisibleTo(perms) {
+		return false
+	}
+	switch r.Scope {
+	case NotificationScopeApps:
+		return evt.Target.Type == TargetTypeApp
+	case NotificationScopeTeams:
+		return evt.visibleToTeams(perms)
+	}
+	return true
+}
+
+// AddNotificationRule validates and stores a new notification rule,
+// generating its ID when it's not set.
+func AddNotificationRule(r *NotificationRule) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	if r.ID == "" {
+		r.ID = bson.NewObjectId()
+	}
+	r.CreatedAt = time.Now().UTC()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.EventNotificationRules().Insert(r)
+}
+
+// ListNotificationRules returns the notification rules of the owner, or all
+// rules if owner is empty.
+func ListNotificationRules(owner string) ([]NotificationRule, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var query bson.M
+	if owner != "" {
+		query = bson.M{"owner": owner}
+	}
+	var rules []NotificationRule
+	err = conn.EventNotificationRules().Find(query).Sort("createdat", "_id").All(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// RemoveNotificationRule removes the rule with the given id, as long as it
+// belongs to owner.
+func RemoveNotificationRule(owner, id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrNotificationRuleNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.EventNotificationRules().Remove(bson.M{"_id": bson.ObjectIdHex(id), "owner": owner})
+	if err == mgo.ErrNotFound {
+		return ErrNotificationRuleNotFound
+	}
+	return err
+}
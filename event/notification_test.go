@@ -0,0 +1,151 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func notificationTestEvent() *Event {
+	evt := &Event{}
+	evt.Target = Target{Type: TargetTypeApp, Value: "myapp"}
+	evt.Kind = Kind{Type: KindTypePermission, Name: "app.update.env.set"}
+	evt.EndTime = time.Now().UTC()
+	evt.Allowed = Allowed(permission.PermAppReadEvents,
+		permission.Context(permission.CtxApp, "myapp"),
+		permission.Context(permission.CtxTeam, "ateam"),
+	)
+	return evt
+}
+
+func (s *SchemaSuite) TestEventVisibleTo(c *check.C) {
+	evt := notificationTestEvent()
+	tests := []struct {
+		perms   []permission.Permission
+		visible bool
+	}{
+		{nil, false},
+		{[]permission.Permission{{Scheme: permission.PermAppReadEvents, Context: permission.Context(permission.CtxGlobal, "")}}, true},
+		{[]permission.Permission{{Scheme: permission.PermApp, Context: permission.Context(permission.CtxTeam, "ateam")}}, true},
+		{[]permission.Permission{{Scheme: permission.PermAppReadEvents, Context: permission.Context(permission.CtxApp, "myapp")}}, true},
+		{[]permission.Permission{{Scheme: permission.PermAppReadEvents, Context: permission.Context(permission.CtxApp, "otherapp")}}, false},
+		{[]permission.Permission{{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxGlobal, "")}}, false},
+		{[]permission.Permission{{Scheme: permission.PermAll, Context: permission.Context(permission.CtxGlobal, "")}}, true},
+	}
+	for i, tt := range tests {
+		c.Check(evt.VisibleTo(tt.perms), check.Equals, tt.visible, check.Commentf("test %d", i))
+	}
+}
+
+func (s *SchemaSuite) TestNotificationRuleMatches(c *check.C) {
+	evt := notificationTestEvent()
+	teamPerms := []permission.Permission{{Scheme: permission.PermAppReadEvents, Context: permission.Context(permission.CtxTeam, "ateam")}}
+	appPerms := []permission.Permission{{Scheme: permission.PermAppReadEvents, Context: permission.Context(permission.CtxApp, "myapp")}}
+	created := evt.EndTime.Add(-time.Minute)
+	tests := []struct {
+		rule    NotificationRule
+		perms   []permission.Permission
+		matches bool
+	}{
+		{NotificationRule{Scope: NotificationScopeAll, CreatedAt: created}, teamPerms, true},
+		{NotificationRule{Scope: NotificationScopeAll, CreatedAt: created}, nil, false},
+		{NotificationRule{Scope: NotificationScopeAll, CreatedAt: evt.EndTime.Add(time.Minute)}, teamPerms, false},
+		{NotificationRule{Scope: NotificationScopeApps, CreatedAt: created}, appPerms, true},
+		{NotificationRule{Scope: NotificationScopeTeams, CreatedAt: created}, teamPerms, true},
+		{NotificationRule{Scope: NotificationScopeTeams, CreatedAt: created}, appPerms, false},
+		{NotificationRule{Scope: NotificationScopeAll, ErrorOnly: true, CreatedAt: created}, teamPerms, false},
+		{NotificationRule{Scope: NotificationScopeAll, KindName: "app.update", CreatedAt: created}, teamPerms, true},
+		{NotificationRule{Scope: NotificationScopeAll, KindName: "app.update.env", CreatedAt: created}, teamPerms, true},
+		{NotificationRule{Scope: NotificationScopeAll, KindName: "app.update.en", CreatedAt: created}, teamPerms, false},
+		{NotificationRule{Scope: NotificationScopeAll, TargetType: TargetTypeApp, TargetValue: "myapp", CreatedAt: created}, teamPerms, true},
+		{NotificationRule{Scope: NotificationScopeAll, TargetType: TargetTypeApp, TargetValue: "otherapp", CreatedAt: created}, teamPerms, false},
+		{NotificationRule{Scope: NotificationScopeAll, TargetType: TargetTypeNode, CreatedAt: created}, teamPerms, false},
+	}
+	for i, tt := range tests {
+		c.Check(tt.rule.Matches(evt, tt.perms), check.Equals, tt.matches, check.Commentf("test %d", i))
+	}
+	evt.Error = "failed"
+	rule := NotificationRule{Scope: NotificationScopeAll, ErrorOnly: true, CreatedAt: created}
+	c.Assert(rule.Matches(evt, teamPerms), check.Equals, true)
+	evt.Running = true
+	c.Assert(rule.Matches(evt, teamPerms), check.Equals, false)
+}
+
+func (s *SchemaSuite) TestNotificationRuleValidate(c *check.C) {
+	tests := []struct {
+		rule NotificationRule
+		err  error
+	}{
+		{NotificationRule{}, ErrNotificationOwnerRequired},
+		{NotificationRule{Owner: "me@me.com", Scope: "mine"}, ErrInvalidNotificationScope},
+		{NotificationRule{Owner: "me@me.com", Channel: "sms"}, ErrInvalidNotificationChannel},
+		{NotificationRule{Owner: "me@me.com", Channel: NotificationChannelWebhook}, ErrInvalidNotificationURL},
+		{NotificationRule{Owner: "me@me.com", Channel: NotificationChannelWebhook, URL: "ftp://host/x"}, ErrInvalidNotificationURL},
+		{NotificationRule{Owner: "me@me.com", Channel: NotificationChannelWebhook, URL: "https://hooks.example.com/x"}, nil},
+	}
+	for i, tt := range tests {
+		c.Check(tt.rule.validate(), check.Equals, tt.err, check.Commentf("test %d", i))
+	}
+	rule := NotificationRule{Owner: "me@me.com"}
+	c.Assert(rule.validate(), check.IsNil)
+	c.Assert(rule.Scope, check.Equals, NotificationScopeAll)
+	c.Assert(rule.Channel, check.Equals, NotificationChannelEmail)
+}
+
+func (s *S) TestAddListRemoveNotificationRule(c *check.C) {
+	rule := NotificationRule{Owner: "me@me.com", Scope: NotificationScopeApps, ErrorOnly: true}
+	err := AddNotificationRule(&rule)
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.ID, check.Not(check.Equals), "")
+	other := NotificationRule{Owner: "other@me.com", Channel: NotificationChannelWebhook, URL: "http://hooks.example.com"}
+	err = AddNotificationRule(&other)
+	c.Assert(err, check.IsNil)
+	err = AddNotificationRule(&NotificationRule{Owner: "me@me.com", Scope: "invalid"})
+	c.Assert(err, check.Equals, ErrInvalidNotificationScope)
+	rules, err := ListNotificationRules("me@me.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].ID, check.Equals, rule.ID)
+	c.Assert(rules[0].Scope, check.Equals, NotificationScopeApps)
+	c.Assert(rules[0].Channel, check.Equals, NotificationChannelEmail)
+	c.Assert(rules[0].ErrorOnly, check.Equals, true)
+	rules, err = ListNotificationRules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 2)
+	err = RemoveNotificationRule("me@me.com", other.ID.Hex())
+	c.Assert(err, check.Equals, ErrNotificationRuleNotFound)
+	err = RemoveNotificationRule("me@me.com", "invalid")
+	c.Assert(err, check.Equals, ErrNotificationRuleNotFound)
+	err = RemoveNotificationRule("me@me.com", rule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	rules, err = ListNotificationRules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].ID, check.Equals, other.ID)
+}
+
+func (s *SchemaSuite) TestEventHasTarget(c *check.C) {
+	evt := notificationTestEvent()
+	evt.ExtraTargets = []Target{{Type: TargetTypeApp, Value: "otherapp"}, {Type: TargetTypePool, Value: "pool1"}}
+	tests := []struct {
+		target Target
+		has    bool
+	}{
+		{Target{}, true},
+		{Target{Type: TargetTypeApp}, true},
+		{Target{Type: TargetTypeApp, Value: "myapp"}, true},
+		{Target{Type: TargetTypeApp, Value: "otherapp"}, true},
+		{Target{Type: TargetTypePool}, true},
+		{Target{Value: "pool1"}, true},
+		{Target{Type: TargetTypeApp, Value: "pool1"}, false},
+		{Target{Type: TargetTypeNode}, false},
+	}
+	for i, tt := range tests {
+		c.Check(evt.HasTarget(tt.target), check.Equals, tt.has, check.Commentf("test %d", i))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var emailTemplate = template.Must(template.New("notification").Parse(`Subject: [tsuru] {{.Alias.Label}} on {{.Target.Type}} {{.Target.Value}} {{if .Error}}failed{{else}}succeeded{{end}}
+To: {{.To}}
+
+Event:    {{.UniqueID.Hex}}
+{{with .URL}}URL:      {{.}}
+{{end}}Target:   {{.Target.Type}} {{.Target.Value}}{{with .TargetURL}} ({{.}}){{end}}
+Kind:     {{.Kind.Name}} ({{.Alias.Category}})
+Owner:    {{.Owner.Name}}
+Started:  {{.StartTime.Format "2006-01-02 15:04:05 MST"}}
+Finished: {{.EndTime.Format "2006-01-02 15:04:05 MST"}}
+{{if .Error}}Error:    {{.Error}}
+{{end}}
+{{if .RuleID}}You're receiving this message because of the notification rule {{.RuleID.Hex}}.
+{{else}}You're receiving this message because you scheduled this operation for {{.ScheduledFor.Format "2006-01-02 15:04:05 MST"}}.
+{{end}}`))
+
+type emailData struct {
+	*event.Event
+	To     string
+	RuleID bson.ObjectId
+	Alias  event.KindAlias
+}
+
+func sendEmail(rule *event.NotificationRule, evt *event.Event, alias event.KindAlias) error {
+	var body bytes.Buffer
+	err := emailTemplate.Execute(&body, emailData{Event: evt, To: rule.Owner, RuleID: rule.ID, Alias: alias})
+	if err != nil {
+		return err
+	}
+	return auth.SendEmail(rule.Owner, body.Bytes())
+}
+
+// webhookPayload is the body posted to webhook channels.
+type webhookPayload struct {
+	ID           bson.ObjectId
+	Rule         bson.ObjectId
+	Target       event.Target
+	Kind         event.Kind
+	KindLabel    string
+	KindCategory string
+	Owner        event.Owner
+	StartTime    time.Time
+	EndTime      time.Time
+	Error        string
+	// URL and TargetURL are the dashboard pages of the event and its
+	// target, empty when no dashboard URL is configured.
+	URL       string `json:",omitempty"`
+	TargetURL string `json:",omitempty"`
+}
+
+var webhookClient = tsuruNet.Dial5Full60ClientNoKeepAlive
+
+func sendWebhook(rule *event.NotificationRule, evt *event.Event, alias event.KindAlias) error {
+	data, err := json.Marshal(webhookPayload{
+		ID:           evt.UniqueID,
+		Rule:         rule.ID,
+		Target:       evt.Target,
+		Kind:         evt.Kind,
+		KindLabel:    alias.Label,
+		KindCategory: alias.Category,
+		Owner:        evt.Owner,
+		StartTime:    evt.StartTime,
+		EndTime:      evt.EndTime,
+		Error:        evt.Error,
+		URL:          evt.URL(),
+		TargetURL:    evt.TargetURL(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", rule.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("invalid response from %s: %d - %s", rule.URL, rsp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
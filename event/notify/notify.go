@@ -0,0 +1,190 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package notify delivers finished events to the users subscribed to them
+// through notification rules (see event.NotificationRule).
+//
+// Events are read through the event consumer group "event-notifications",
+// so each event is evaluated by a single tsuru instance. Delivery failures
+// are logged and not retried, a flaky webhook should never cause duplicated
+// emails to other users.
+package notify
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	consumerGroup    = "event-notifications"
+	defaultInterval  = 10 * time.Second
+	defaultBatchSize = 100
+	// maxEventAge is the maximum time since an event finished for it to be
+	// notified, avoiding flooding users after notifications were stopped
+	// for a long time.
+	maxEventAge = time.Hour
+)
+
+type sender func(rule *event.NotificationRule, evt *event.Event, alias event.KindAlias) error
+
+var senders = map[string]sender{
+	event.NotificationChannelEmail:   sendEmail,
+	event.NotificationChannelWebhook: sendWebhook,
+}
+
+// Notifier periodically evaluates the notification rules against finished
+// events, delivering the matching ones.
+type Notifier struct {
+	interval  time.Duration
+	batchSize int
+	done      chan bool
+}
+
+// Initialize starts delivering event notifications, unless disabled by the
+// event:notifications:disabled setting.
+func Initialize() error {
+	disabled, _ := config.GetBool("event:notifications:disabled")
+	if disabled {
+		return nil
+	}
+	n := &Notifier{
+		interval:  defaultInterval,
+		batchSize: defaultBatchSize,
+		done:      make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("event:notifications:interval"); seconds > 0 {
+		n.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(n)
+	go n.run()
+	return nil
+}
+
+func (n *Notifier) run() {
+	for {
+		err := n.runOnce()
+		if err != nil {
+			log.Errorf("[event notify] unable to notify events: %s", err)
+		}
+		select {
+		case <-n.done:
+			return
+		case <-time.After(n.interval):
+		}
+	}
+}
+
+func (n *Notifier) runOnce() error {
+	for {
+		evts, err := event.Consume(consumerGroup, n.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(evts) == 0 {
+			return nil
+		}
+		err = notifyEvents(evts)
+		if err != nil {
+			return err
+		}
+		ids := make([]bson.ObjectId, len(evts))
+		for i := range evts {
+			ids[i] = evts[i].UniqueID
+		}
+		err = event.Ack(consumerGroup, ids...)
+		if err != nil {
+			return err
+		}
+		if len(evts) < n.batchSize {
+			return nil
+		}
+	}
+}
+
+func (n *Notifier) Shutdown() {
+	n.done <- true
+}
+
+func (n *Notifier) String() string {
+	return "event notifier"
+}
+
+// notifyEvents delivers the events to the owners of the matching rules. The
+// permissions of each owner are loaded at most once per call, so rules
+// always respect the current permissions of their owners. Events started
+// from a schedule are also emailed to the user who scheduled them, as
+// nobody is usually watching them run. Kinds are displayed with their
+// aliases, falling back to the default labels when they can't be loaded.
+func notifyEvents(evts []event.Event) error {
+	rules, err := event.ListNotificationRules("")
+	if err != nil {
+		return err
+	}
+	aliases, err := event.LoadKindAliases()
+	if err != nil {
+		log.Errorf("[event notify] unable to load event kind aliases: %s", err)
+	}
+	perms := map[string][]permission.Permission{}
+	for i := range evts {
+		evt := &evts[i]
+		if time.Since(evt.EndTime) > maxEventAge {
+			continue
+		}
+		var scheduledOwner string
+		if !evt.ScheduledFor.IsZero() && evt.Owner.Type == event.OwnerTypeUser {
+			scheduledOwner = evt.Owner.Name
+			rule := &event.NotificationRule{Owner: scheduledOwner, Channel: event.NotificationChannelEmail}
+			err = deliver(rule, evt, aliases.Resolve(evt.Kind.Name))
+			if err != nil {
+				log.Errorf("[event notify] unable to notify %q about scheduled event %s: %s", scheduledOwner, evt.UniqueID.Hex(), err)
+			}
+		}
+		for j := range rules {
+			rule := &rules[j]
+			if rule.Owner == scheduledOwner && rule.Channel == event.NotificationChannelEmail {
+				continue
+			}
+			ownerPerms, ok := perms[rule.Owner]
+			if !ok {
+				ownerPerms, err = userPermissions(rule.Owner)
+				if err != nil {
+					log.Errorf("[event notify] unable to load permissions of %q: %s", rule.Owner, err)
+				}
+				perms[rule.Owner] = ownerPerms
+			}
+			if !rule.Matches(evt, ownerPerms) {
+				continue
+			}
+			err = deliver(rule, evt, aliases.Resolve(evt.Kind.Name))
+			if err != nil {
+				log.Errorf("[event notify] unable to notify %q about event %s through %s: %s", rule.Owner, evt.UniqueID.Hex(), rule.Channel, err)
+			}
+		}
+	}
+	return nil
+}
+
+func userPermissions(email string) ([]permission.Permission, error) {
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	return u.Permissions()
+}
+
+func deliver(rule *event.NotificationRule, evt *event.Event, alias event.KindAlias) error {
+	send, ok := senders[rule.Channel]
+	if !ok {
+		return errors.Errorf("unknown channel %q", rule.Channel)
+	}
+	return send(rule, evt, alias)
+}
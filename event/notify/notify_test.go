@@ -0,0 +1,261 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func testEvent() *event.Event {
+	evt := &event.Event{}
+	evt.UniqueID = bson.ObjectIdHex("591300000000000000000001")
+	evt.Target = event.Target{Type: event.TargetTypeApp, Value: "myapp"}
+	evt.Kind = event.Kind{Type: event.KindTypePermission, Name: "app.deploy"}
+	evt.Owner = event.Owner{Type: event.OwnerTypeUser, Name: "deployer@tsuru.io"}
+	evt.StartTime = time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	evt.EndTime = time.Date(2017, 5, 10, 12, 1, 0, 0, time.UTC)
+	evt.Error = "deploy failed"
+	return evt
+}
+
+func (s *S) TestSendWebhook(c *check.C) {
+	var req *http.Request
+	var payload webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer srv.Close()
+	rule := &event.NotificationRule{ID: bson.NewObjectId(), URL: srv.URL}
+	evt := testEvent()
+	err := sendWebhook(rule, evt, event.KindAlias{Name: "app.deploy", Label: "Deploy", Category: "apps"})
+	c.Assert(err, check.IsNil)
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.Header.Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(payload, check.DeepEquals, webhookPayload{
+		ID:           evt.UniqueID,
+		Rule:         rule.ID,
+		Target:       evt.Target,
+		Kind:         evt.Kind,
+		KindLabel:    "Deploy",
+		KindCategory: "apps",
+		Owner:        evt.Owner,
+		StartTime:    evt.StartTime,
+		EndTime:      evt.EndTime,
+		Error:        "deploy failed",
+	})
+}
+
+func (s *S) TestSendWebhookWithDashboardURL(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.tsuru.io/")
+	defer config.Unset("event:dashboard-url")
+	var payload webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer srv.Close()
+	err := sendWebhook(&event.NotificationRule{URL: srv.URL}, testEvent(), event.KindAlias{})
+	c.Assert(err, check.IsNil)
+	c.Assert(payload.URL, check.Equals, "https://dashboard.tsuru.io/events/591300000000000000000001")
+	c.Assert(payload.TargetURL, check.Equals, "https://dashboard.tsuru.io/apps/myapp")
+}
+
+func (s *S) TestSendWebhookError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	err := sendWebhook(&event.NotificationRule{URL: srv.URL}, testEvent(), event.KindAlias{})
+	c.Assert(err, check.ErrorMatches, `invalid response from .*: 503 - unavailable`)
+}
+
+func (s *S) TestEmailTemplate(c *check.C) {
+	var body bytes.Buffer
+	ruleID := bson.ObjectIdHex("591300000000000000000002")
+	alias := event.KindAliases(nil).Resolve("app.deploy")
+	err := emailTemplate.Execute(&body, emailData{Event: testEvent(), To: "me@tsuru.io", RuleID: ruleID, Alias: alias})
+	c.Assert(err, check.IsNil)
+	lines := strings.Split(body.String(), "\n")
+	c.Assert(lines[0], check.Equals, "Subject: [tsuru] App deploy on app myapp failed")
+	c.Assert(lines[1], check.Equals, "To: me@tsuru.io")
+	c.Assert(body.String(), check.Matches, `(?s).*Event:    591300000000000000000001\nTarget:   app myapp\n.*`)
+	c.Assert(body.String(), check.Matches, `(?s).*Kind:     app\.deploy \(app\)\n.*Owner:    deployer@tsuru\.io\n.*Error:    deploy failed\n.*rule 591300000000000000000002\..*`)
+}
+
+func (s *S) TestEmailTemplateWithDashboardURL(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.tsuru.io")
+	defer config.Unset("event:dashboard-url")
+	var body bytes.Buffer
+	alias := event.KindAliases(nil).Resolve("app.deploy")
+	err := emailTemplate.Execute(&body, emailData{Event: testEvent(), To: "me@tsuru.io", RuleID: bson.NewObjectId(), Alias: alias})
+	c.Assert(err, check.IsNil)
+	c.Assert(body.String(), check.Matches, `(?s).*Event:    591300000000000000000001\n`+
+		`URL:      https://dashboard\.tsuru\.io/events/591300000000000000000001\n`+
+		`Target:   app myapp \(https://dashboard\.tsuru\.io/apps/myapp\)\n.*`)
+}
+
+func (s *S) TestEmailTemplateScheduled(c *check.C) {
+	var body bytes.Buffer
+	evt := testEvent()
+	evt.ScheduledFor = time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	err := emailTemplate.Execute(&body, emailData{Event: evt, To: "deployer@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	c.Assert(body.String(), check.Matches, `(?s).*because you scheduled this operation for 2017-05-10 12:00:00 UTC\.\n$`)
+}
+
+type NotifySuite struct {
+	conn *db.Storage
+	sent []string
+}
+
+var _ = check.Suite(&NotifySuite{})
+
+func (s *NotifySuite) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_event_notify_tests")
+	config.Set("auth:hash-cost", bcrypt.MinCost)
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *NotifySuite) TearDownSuite(c *check.C) {
+	s.conn.Events().Database.DropDatabase()
+	s.conn.Close()
+}
+
+func (s *NotifySuite) SetUpTest(c *check.C) {
+	err := dbtest.ClearAllCollections(s.conn.Events().Database)
+	c.Assert(err, check.IsNil)
+	s.sent = nil
+	senders[event.NotificationChannelEmail] = func(rule *event.NotificationRule, evt *event.Event, alias event.KindAlias) error {
+		s.sent = append(s.sent, rule.Owner+" "+evt.Target.Value)
+		return nil
+	}
+}
+
+func (s *NotifySuite) TearDownTest(c *check.C) {
+	senders[event.NotificationChannelEmail] = sendEmail
+}
+
+func (s *NotifySuite) newEvent(c *check.C, app string, evtErr error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: app},
+		InternalKind: "test",
+		Allowed:      event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxApp, app)),
+		DisableLock:  true,
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(evtErr)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *NotifySuite) listEvents(c *check.C) []event.Event {
+	evts, err := event.List(&event.Filter{Sort: "endtime"})
+	c.Assert(err, check.IsNil)
+	return evts
+}
+
+func (s *NotifySuite) TestNotifyEvents(c *check.C) {
+	scheme := auth.ManagedScheme(native.NativeScheme{})
+	permissiontest.CustomUserWithPermission(c, scheme, "myapp", permission.Permission{
+		Scheme:  permission.PermAppReadEvents,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	permissiontest.CustomUserWithPermission(c, scheme, "admin", permission.Permission{
+		Scheme:  permission.PermAll,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	for _, rule := range []event.NotificationRule{
+		{Owner: "myapp@groundcontrol.com", Scope: event.NotificationScopeApps},
+		{Owner: "admin@groundcontrol.com", ErrorOnly: true},
+		{Owner: "removed@groundcontrol.com"},
+	} {
+		err := event.AddNotificationRule(&rule)
+		c.Assert(err, check.IsNil)
+	}
+	s.newEvent(c, "myapp", nil)
+	s.newEvent(c, "otherapp", nil)
+	s.newEvent(c, "otherapp", errors.New("failed"))
+	s.newEvent(c, "myapp", errors.New("failed"))
+	evts := s.listEvents(c)
+	c.Assert(evts, check.HasLen, 4)
+	evts[3].EndTime = time.Now().Add(-2 * maxEventAge)
+	err := notifyEvents(evts)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.sent, check.DeepEquals, []string{
+		"myapp@groundcontrol.com myapp",
+		"admin@groundcontrol.com otherapp",
+	})
+}
+
+func (s *NotifySuite) TestNotifyEventsIgnoresDeliveryErrors(c *check.C) {
+	scheme := auth.ManagedScheme(native.NativeScheme{})
+	permissiontest.CustomUserWithPermission(c, scheme, "admin", permission.Permission{
+		Scheme:  permission.PermAll,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	rule := event.NotificationRule{Owner: "admin@groundcontrol.com", Channel: event.NotificationChannelWebhook, URL: "http://127.0.0.1:1/hook"}
+	err := event.AddNotificationRule(&rule)
+	c.Assert(err, check.IsNil)
+	rule = event.NotificationRule{Owner: "admin@groundcontrol.com"}
+	err = event.AddNotificationRule(&rule)
+	c.Assert(err, check.IsNil)
+	s.newEvent(c, "myapp", nil)
+	err = notifyEvents(s.listEvents(c))
+	c.Assert(err, check.IsNil)
+	c.Assert(s.sent, check.DeepEquals, []string{"admin@groundcontrol.com myapp"})
+}
+
+func (s *NotifySuite) TestNotifyEventsScheduledOwner(c *check.C) {
+	scheme := auth.ManagedScheme(native.NativeScheme{})
+	permissiontest.CustomUserWithPermission(c, scheme, "admin", permission.Permission{
+		Scheme:  permission.PermAll,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	rule := event.NotificationRule{Owner: "admin@groundcontrol.com"}
+	err := event.AddNotificationRule(&rule)
+	c.Assert(err, check.IsNil)
+	s.newEvent(c, "scheduledapp", nil)
+	s.newEvent(c, "otherapp", nil)
+	evts := s.listEvents(c)
+	c.Assert(evts, check.HasLen, 2)
+	evts[0].ScheduledFor = time.Now().Add(-time.Minute)
+	evts[0].Owner = event.Owner{Type: event.OwnerTypeUser, Name: "admin@groundcontrol.com"}
+	evts[1].ScheduledFor = time.Now().Add(-time.Minute)
+	evts[1].Owner = event.Owner{Type: event.OwnerTypeUser, Name: "deployer@groundcontrol.com"}
+	err = notifyEvents(evts)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.sent, check.DeepEquals, []string{
+		"admin@groundcontrol.com scheduledapp",
+		"deployer@groundcontrol.com otherapp",
+		"admin@groundcontrol.com otherapp",
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import "time"
+
+// Phase is a timed step of the operation recorded by an event, like the
+// image build during a deploy. Phases are kept in memory only, operations
+// interested in them must store them in the event custom data.
+type Phase struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	running  bool
+}
+
+// StartPhase starts timing a phase of the operation, which lasts until
+// EndPhase is called with the same name. It's safe to call it on a nil
+// event, so provisioners can time phases of operations running without an
+// event.
+func (e *Event) StartPhase(name string) {
+	if e == nil {
+		return
+	}
+	e.phasesMu.Lock()
+	defer e.phasesMu.Unlock()
+	e.phases = append(e.phases, Phase{Name: name, Start: time.Now().UTC(), running: true})
+}
+
+// EndPhase ends the last started phase with the given name. Calling it for
+// a phase that is not running is a no-op.
+func (e *Event) EndPhase(name string) {
+	if e == nil {
+		return
+	}
+	e.phasesMu.Lock()
+	defer e.phasesMu.Unlock()
+	for i := len(e.phases) - 1; i >= 0; i-- {
+		p := &e.phases[i]
+		if p.Name == name && p.running {
+			p.Duration = time.Since(p.Start)
+			p.running = false
+			return
+		}
+	}
+}
+
+// AddPhase records a phase timed before the event was created, like the
+// upload of the deploy archive.
+func (e *Event) AddPhase(name string, start time.Time, duration time.Duration) {
+	if e == nil {
+		return
+	}
+	e.phasesMu.Lock()
+	defer e.phasesMu.Unlock()
+	e.phases = append(e.phases, Phase{Name: name, Start: start.UTC(), Duration: duration})
+}
+
+// Phases returns the phases recorded in the event, in the order they were
+// started. Phases still running last until now.
+func (e *Event) Phases() []Phase {
+	if e == nil {
+		return nil
+	}
+	e.phasesMu.Lock()
+	defer e.phasesMu.Unlock()
+	phases := make([]Phase, len(e.phases))
+	for i, p := range e.phases {
+		if p.running {
+			p.Duration = time.Since(p.Start)
+			p.running = false
+		}
+		phases[i] = p
+	}
+	return phases
+}
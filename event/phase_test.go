@@ -0,0 +1,55 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *SchemaSuite) TestEventPhases(c *check.C) {
+	evt := &Event{}
+	start := time.Now().UTC().Add(-time.Minute)
+	evt.AddPhase("upload", start, 2*time.Second)
+	evt.StartPhase("build")
+	evt.StartPhase("route-update")
+	evt.EndPhase("build")
+	evt.EndPhase("unknown")
+	phases := evt.Phases()
+	c.Assert(phases, check.HasLen, 3)
+	c.Assert(phases[0], check.DeepEquals, Phase{Name: "upload", Start: start, Duration: 2 * time.Second})
+	c.Assert(phases[1].Name, check.Equals, "build")
+	c.Assert(phases[1].running, check.Equals, false)
+	c.Assert(phases[2].Name, check.Equals, "route-update")
+	c.Assert(phases[2].running, check.Equals, false)
+	c.Assert(evt.phases[2].running, check.Equals, true)
+	buildDuration := phases[1].Duration
+	time.Sleep(10 * time.Millisecond)
+	phases = evt.Phases()
+	c.Assert(phases[1].Duration, check.Equals, buildDuration)
+	c.Assert(phases[2].Duration > buildDuration, check.Equals, true)
+}
+
+func (s *SchemaSuite) TestEventPhasesRepeated(c *check.C) {
+	evt := &Event{}
+	evt.StartPhase("route-update")
+	evt.EndPhase("route-update")
+	evt.StartPhase("route-update")
+	evt.EndPhase("route-update")
+	evt.EndPhase("route-update")
+	phases := evt.Phases()
+	c.Assert(phases, check.HasLen, 2)
+	c.Assert(phases[0].Name, check.Equals, "route-update")
+	c.Assert(phases[1].Name, check.Equals, "route-update")
+}
+
+func (s *SchemaSuite) TestEventPhasesNilEvent(c *check.C) {
+	var evt *Event
+	evt.StartPhase("build")
+	evt.EndPhase("build")
+	evt.AddPhase("upload", time.Now(), time.Second)
+	c.Assert(evt.Phases(), check.IsNil)
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/tsuru/tsuru/auth"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	redriveHandlers = map[string]RedriveFunc{}
+
+	ErrEventNotFailed = ErrValidation("only finished events with errors can be redriven")
+)
+
+// RedriveFunc re-executes the operation recorded by a failed event, using
+// the arguments stored in its StartCustomData. The operation runs on behalf
+// of t, and is expected to check its permissions and record a new event, as
+// if it was requested again. Progress output is written to w.
+type RedriveFunc func(evt *Event, t auth.Token, w io.Writer) error
+
+type ErrRedriveNotSupported struct {
+	Kind string
+}
+
+func (err ErrRedriveNotSupported) Error() string {
+	return fmt.Sprintf("redrive is not supported for events of kind %q", err.Kind)
+}
+
+// SetRedriveHandler registers the function used to redrive failed events of
+// the given kind.
+func SetRedriveHandler(kindName string, fn RedriveFunc) {
+	redriveHandlers[kindName] = fn
+}
+
+// CanRedrive returns whether failed events of the given kind can be
+// redriven.
+func CanRedrive(kindName string) bool {
+	_, ok := redriveHandlers[kindName]
+	return ok
+}
+
+// Redrive re-executes the operation of the failed event with the given id
+// using the handler registered for its kind.
+func Redrive(id bson.ObjectId, t auth.Token, w io.Writer) error {
+	evt, err := GetByID(id)
+	if err != nil {
+		return err
+	}
+	if evt.Running || evt.Error == "" {
+		return ErrEventNotFailed
+	}
+	fn, ok := redriveHandlers[evt.Kind.Name]
+	if !ok {
+		return ErrRedriveNotSupported{Kind: evt.Kind.Name}
+	}
+	return fn(evt, t, w)
+}
+
+// CustomDataToForm converts custom data generated by FormToCustomData back
+// to form values.
+func CustomDataToForm(data []map[string]interface{}) url.Values {
+	form := url.Values{}
+	for _, entry := range data {
+		name, _ := entry["name"].(string)
+		if name == "" {
+			continue
+		}
+		switch v := entry["value"].(type) {
+		case string:
+			form.Add(name, v)
+		case []string:
+			for _, s := range v {
+				form.Add(name, s)
+			}
+		case []interface{}:
+			for _, s := range v {
+				form.Add(name, fmt.Sprint(s))
+			}
+		case nil:
+		default:
+			form.Add(name, fmt.Sprint(v))
+		}
+	}
+	return form
+}
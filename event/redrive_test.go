@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	check "gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func setTestRedriveHandler(kind string, fn RedriveFunc) func() {
+	SetRedriveHandler(kind, fn)
+	return func() { delete(redriveHandlers, kind) }
+}
+
+func (s *S) TestRedrive(c *check.C) {
+	var calledForm url.Values
+	defer setTestRedriveHandler("mykind", func(evt *Event, t auth.Token, w io.Writer) error {
+		var data []map[string]interface{}
+		err := evt.StartData(&data)
+		if err != nil {
+			return err
+		}
+		calledForm = CustomDataToForm(data)
+		_, err = w.Write([]byte("done"))
+		return err
+	})()
+	c.Assert(CanRedrive("mykind"), check.Equals, true)
+	evt, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "myapp"},
+		InternalKind: "mykind",
+		CustomData:   FormToCustomData(url.Values{"a": {"1"}, "b": {"2", "3"}}),
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("failed"))
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = Redrive(evt.UniqueID, s.token, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "done")
+	c.Assert(calledForm, check.DeepEquals, url.Values{"a": {"1"}, "b": {"2", "3"}})
+}
+
+func (s *S) TestRedriveNotFailed(c *check.C) {
+	defer setTestRedriveHandler("mykind", func(evt *Event, t auth.Token, w io.Writer) error {
+		c.Fatal("redrive handler must not be called")
+		return nil
+	})()
+	evt, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "myapp"},
+		InternalKind: "mykind",
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = Redrive(evt.UniqueID, s.token, nil)
+	c.Assert(err, check.Equals, ErrEventNotFailed)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = Redrive(evt.UniqueID, s.token, nil)
+	c.Assert(err, check.Equals, ErrEventNotFailed)
+}
+
+func (s *S) TestRedriveNotSupported(c *check.C) {
+	c.Assert(CanRedrive("otherkind"), check.Equals, false)
+	evt, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "myapp"},
+		InternalKind: "otherkind",
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("failed"))
+	c.Assert(err, check.IsNil)
+	err = Redrive(evt.UniqueID, s.token, nil)
+	c.Assert(err, check.Equals, ErrRedriveNotSupported{Kind: "otherkind"})
+}
+
+func (s *S) TestRedriveNotFound(c *check.C) {
+	err := Redrive(bson.NewObjectId(), s.token, nil)
+	c.Assert(err, check.Equals, ErrEventNotFound)
+}
+
+func (s *S) TestCustomDataToForm(c *check.C) {
+	form := CustomDataToForm([]map[string]interface{}{
+		{"name": "a", "value": "1"},
+		{"name": "b", "value": []interface{}{"2", "3"}},
+		{"name": "c", "value": 4},
+		{"name": "d"},
+		{"value": "ignored"},
+	})
+	c.Assert(form, check.DeepEquals, url.Values{"a": {"1"}, "b": {"2", "3"}, "c": {"4"}})
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleSearch is how far in the future next looks for an occurrence
+// of a schedule before giving up.
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+type scheduleField struct {
+	name     string
+	min, max int
+}
+
+var scheduleFields = []scheduleField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// schedule is a parsed cron-like expression with five fields: minute, hour,
+// day of month, month and day of week. Each field accepts "*", numbers,
+// ranges ("1-5"), lists ("1,3,5") and steps ("*/15", "0-30/10").
+type schedule struct {
+	minute, hour, dom, month, dow []bool
+	domAny, dowAny                bool
+}
+
+// NextScheduleTime returns the first time matching the cron-like schedule
+// expression strictly after t. Schedules are evaluated in UTC.
+func NextScheduleTime(expr string, t time.Time) (time.Time, error) {
+	sched, err := parseSchedule(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := sched.next(t.UTC())
+	if next.IsZero() {
+		return next, ErrValidation(fmt.Sprintf("invalid schedule %q: it never runs", expr))
+	}
+	return next, nil
+}
+
+func parseSchedule(expr string) (*schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(scheduleFields) {
+		return nil, ErrValidation(fmt.Sprintf("invalid schedule %q: expected %d fields, got %d", expr, len(scheduleFields), len(parts)))
+	}
+	values := make([][]bool, len(parts))
+	for i, part := range parts {
+		var err error
+		values[i], err = parseScheduleField(part, scheduleFields[i])
+		if err != nil {
+			return nil, ErrValidation(fmt.Sprintf("invalid schedule %q: %s", expr, err))
+		}
+	}
+	return &schedule{
+		minute: values[0],
+		hour:   values[1],
+		dom:    values[2],
+		month:  values[3],
+		dow:    values[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseScheduleField(value string, field scheduleField) ([]bool, error) {
+	result := make([]bool, field.max+1)
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %s field: %q", field.name, item)
+			}
+			rangePart = item[:idx]
+		}
+		start, end := field.min, field.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %s field: %q", field.name, item)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value in %s field: %q", field.name, item)
+				}
+			} else if step > 1 {
+				end = field.max
+			}
+			if start < field.min || end > field.max || start > end {
+				return nil, fmt.Errorf("%s field out of range [%d-%d]: %q", field.name, field.min, field.max, item)
+			}
+		}
+		for i := start; i <= end; i += step {
+			result[i] = true
+		}
+	}
+	return result, nil
+}
+
+func (s *schedule) matchDay(t time.Time) bool {
+	domMatch := s.dom[t.Day()]
+	dowMatch := s.dow[int(t.Weekday())]
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next returns the first time matching the schedule strictly after t, or
+// the zero time if there's no such time in the next five years.
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleSearch)
+	for t.Before(limit) {
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
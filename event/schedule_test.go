@@ -0,0 +1,104 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+type ScheduleSuite struct{}
+
+var _ = check.Suite(&ScheduleSuite{})
+
+func (s *ScheduleSuite) TestParseScheduleInvalid(c *check.C) {
+	tt := []struct {
+		expr string
+		err  string
+	}{
+		{"* * * *", `invalid schedule "\* \* \* \*": expected 5 fields, got 4`},
+		{"60 * * * *", `.*minute field out of range \[0-59\]: "60"`},
+		{"* 5-2 * * *", `.*hour field out of range \[0-23\]: "5-2"`},
+		{"* * 0 * *", `.*day of month field out of range \[1-31\]: "0"`},
+		{"*/0 * * * *", `.*invalid step in minute field: "\*/0"`},
+		{"* * * jan *", `.*invalid value in month field: "jan"`},
+	}
+	for _, t := range tt {
+		_, err := parseSchedule(t.expr)
+		c.Check(err, check.ErrorMatches, t.err)
+		c.Check(err, check.FitsTypeOf, ErrValidation(""))
+	}
+}
+
+func (s *ScheduleSuite) TestScheduleNext(c *check.C) {
+	base := time.Date(2017, 5, 10, 12, 30, 15, 0, time.UTC) // Wednesday
+	tt := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2017, 5, 10, 12, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2017, 5, 10, 12, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2017, 5, 11, 3, 0, 0, 0, time.UTC)},
+		{"30 12 * * *", time.Date(2017, 5, 11, 12, 30, 0, 0, time.UTC)},
+		{"0 22 * * 6", time.Date(2017, 5, 13, 22, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 1-3 *", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * 1", time.Date(2017, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, t := range tt {
+		sched, err := parseSchedule(t.expr)
+		c.Assert(err, check.IsNil)
+		c.Check(sched.next(base), check.DeepEquals, t.expected, check.Commentf("%s", t.expr))
+	}
+}
+
+func (s *ScheduleSuite) TestNextScheduleTime(c *check.C) {
+	base := time.Date(2017, 5, 10, 9, 30, 15, 0, time.FixedZone("BRT", -3*3600))
+	next, err := NextScheduleTime("0 12 * * *", base)
+	c.Assert(err, check.IsNil)
+	c.Assert(next, check.DeepEquals, time.Date(2017, 5, 11, 12, 0, 0, 0, time.UTC))
+	_, err = NextScheduleTime("0 0 30 2 *", base)
+	c.Assert(err, check.ErrorMatches, `invalid schedule "0 0 30 2 \*": it never runs`)
+	c.Assert(err, check.FitsTypeOf, ErrValidation(""))
+	_, err = NextScheduleTime("* * *", base)
+	c.Assert(err, check.FitsTypeOf, ErrValidation(""))
+}
+
+func (s *ScheduleSuite) TestBlockWindow(c *check.C) {
+	b := &Block{Schedule: "0 2 * * *", Duration: 2 * time.Hour}
+	c.Assert(b.window(time.Date(2017, 5, 10, 1, 59, 0, 0, time.UTC)), check.IsNil)
+	w := b.window(time.Date(2017, 5, 10, 3, 15, 0, 0, time.UTC))
+	c.Assert(w, check.NotNil)
+	c.Assert(w.StartTime, check.DeepEquals, time.Date(2017, 5, 10, 2, 0, 0, 0, time.UTC))
+	c.Assert(w.EndTime, check.DeepEquals, time.Date(2017, 5, 10, 4, 0, 0, 0, time.UTC))
+	c.Assert(b.window(time.Date(2017, 5, 10, 4, 0, 0, 0, time.UTC)), check.IsNil)
+	unscheduled := &Block{}
+	c.Assert(unscheduled.window(time.Now()), check.NotNil)
+}
+
+func (s *ScheduleSuite) TestBlockValidate(c *check.C) {
+	tt := []struct {
+		block *Block
+		err   string
+	}{
+		{&Block{}, ""},
+		{&Block{ExpireTime: time.Now().Add(time.Hour)}, ""},
+		{&Block{ExpireTime: time.Now().Add(-time.Hour)}, "block expire time must be in the future"},
+		{&Block{Duration: time.Hour}, "block duration requires a schedule"},
+		{&Block{Schedule: "0 2 * * *"}, "block duration is required for scheduled blocks"},
+		{&Block{Schedule: "0 2 *", Duration: time.Hour}, `invalid schedule "0 2 \*".*`},
+		{&Block{Schedule: "0 2 * * *", Duration: time.Hour}, ""},
+	}
+	for i, t := range tt {
+		err := t.block.validate()
+		if t.err == "" {
+			c.Check(err, check.IsNil, check.Commentf("%d", i))
+		} else {
+			c.Check(err, check.ErrorMatches, t.err, check.Commentf("%d", i))
+		}
+	}
+}
@@ -0,0 +1,409 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	scheduledExecutors = map[string]ScheduledFunc{}
+
+	// scheduledRetryInterval is the time waited before trying again to
+	// start a scheduled event whose target is locked or throttled.
+	scheduledRetryInterval = 30 * time.Second
+	// scheduledMaxDelay is the maximum time after RunAt a scheduled event
+	// is still started, it's discarded past it.
+	scheduledMaxDelay = time.Hour
+
+	ErrScheduledEventNotFound = errors.New("scheduled event not found")
+	ErrScheduledEventStarting = ErrValidation("scheduled event is already being started")
+	ErrNotPendingApproval     = ErrValidation("scheduled event is not pending approval")
+	ErrApproverIsOwner        = ErrValidation("scheduled event can't be approved by its owner")
+)
+
+// ScheduledFunc executes the operation of a scheduled event once it's
+// started, using the arguments stored in its StartCustomData. The event is
+// finished with the returned error, progress output may be written to it.
+// Executors needing to store end custom data may finish the event
+// themselves, e.g. calling DoneCustomData.
+type ScheduledFunc func(evt *Event) error
+
+type ErrScheduleNotSupported struct {
+	Kind string
+}
+
+func (err ErrScheduleNotSupported) Error() string {
+	return fmt.Sprintf("scheduling is not supported for events of kind %q", err.Kind)
+}
+
+// ScheduledEvent is an event waiting to be started by the scheduler at
+// RunAt. Attempts and LastError record the tries to start it while its
+// target was locked. Events created with Opts.RequireApproval are not
+// started while PendingApproval is set, see ApproveScheduled.
+type ScheduledEvent struct {
+	ID            bson.ObjectId `bson:"_id"`
+	RunAt         time.Time
+	CreatedAt     time.Time
+	Target        Target
+	ExtraTargets  []Target `bson:",omitempty"`
+	Kind          Kind
+	Owner         Owner
+	ActingOwner   Owner    `bson:",omitempty"`
+	CustomData    bson.Raw `bson:",omitempty"`
+	DisableLock   bool
+	Cancelable    bool
+	Allowed       AllowedPermission
+	AllowedCancel AllowedPermission
+	Attempts      int
+	LastError     string
+	ClaimExpires  time.Time `json:"-"`
+
+	PendingApproval bool              `bson:",omitempty"`
+	AllowedApprove  AllowedPermission `bson:",omitempty"`
+	ApprovedBy      string            `bson:",omitempty"`
+	ApprovedAt      time.Time         `bson:",omitempty"`
+}
+
+// SetScheduledExecutor registers the function used to execute scheduled
+// events of the given kind. Events of kinds without an executor can't be
+// scheduled.
+func SetScheduledExecutor(kindName string, fn ScheduledFunc) {
+	scheduledExecutors[kindName] = fn
+}
+
+// CanSchedule returns whether events of the given kind can be scheduled.
+func CanSchedule(kindName string) bool {
+	_, ok := scheduledExecutors[kindName]
+	return ok
+}
+
+// Scheduled reports whether the event was created with Opts.RunAt or
+// Opts.RequireApproval, in which case it's a placeholder for the scheduled
+// event with the same UniqueID.
+func (e *Event) Scheduled() bool {
+	return e.scheduled
+}
+
+// stored returns whether the event was inserted in the events collection.
+func (e *Event) stored() bool {
+	return !e.dryRun && !e.scheduled
+}
+
+func scheduleEvt(conn *db.Storage, opts *Opts, k *Kind, o, a *Owner) (*Event, error) {
+	if !CanSchedule(k.Name) {
+		return nil, ErrScheduleNotSupported{Kind: k.Name}
+	}
+	err := checkChangeRate(conn.Events(), opts, k, o)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := makeBSONRaw(opts.CustomData, getCustomDataSchema(&opts.Target, k).StartCustomData)
+	if err != nil {
+		return nil, err
+	}
+	runAt := opts.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	sched := ScheduledEvent{
+		ID:            bson.NewObjectId(),
+		RunAt:         runAt.UTC(),
+		CreatedAt:     time.Now().UTC(),
+		Target:        opts.Target,
+		ExtraTargets:  opts.ExtraTargets,
+		Kind:          *k,
+		Owner:         *o,
+		ActingOwner:   *a,
+		CustomData:    raw,
+		DisableLock:   opts.DisableLock,
+		Cancelable:    opts.Cancelable,
+		Allowed:       opts.Allowed,
+		AllowedCancel: opts.AllowedCancel,
+
+		PendingApproval: opts.RequireApproval,
+		AllowedApprove:  opts.AllowedApprove,
+	}
+	evt := &Event{
+		eventData: eventData{
+			UniqueID:        sched.ID,
+			Target:          sched.Target,
+			ExtraTargets:    sched.ExtraTargets,
+			Kind:            sched.Kind,
+			Owner:           sched.Owner,
+			ActingOwner:     sched.ActingOwner,
+			StartCustomData: sched.CustomData,
+			ScheduledFor:    sched.RunAt,
+			Cancelable:      sched.Cancelable,
+			Allowed:         sched.Allowed,
+			AllowedCancel:   sched.AllowedCancel,
+		},
+		dryRun:    opts.DryRun,
+		scheduled: true,
+	}
+	// Blocks are checked again when the event is started, refusing it here
+	// only spares the owner from finding out about a freeze window after
+	// the fact.
+	err = checkIsBlockedAt(evt, sched.RunAt, true)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		err = conn.EventScheduled().Insert(sched)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return evt, nil
+}
+
+// GetScheduled returns the scheduled event with the given id.
+func GetScheduled(id bson.ObjectId) (*ScheduledEvent, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var sched ScheduledEvent
+	err = conn.EventScheduled().FindId(id).One(&sched)
+	if err == mgo.ErrNotFound {
+		return nil, ErrScheduledEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sched, nil
+}
+
+// ListScheduled returns the scheduled events matching the target, kind,
+// owner and permissions of the filter, ordered by RunAt.
+func ListScheduled(filter *Filter) ([]ScheduledEvent, error) {
+	query := bson.M{}
+	limit := 0
+	if filter != nil {
+		var err error
+		query, err = filter.toQuery()
+		if err != nil {
+			if err == errInvalidQuery {
+				return nil, nil
+			}
+			return nil, err
+		}
+		limit = filter.Limit
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	find := conn.EventScheduled().Find(query).Sort("runat", "_id")
+	if limit > 0 {
+		find = find.Limit(limit)
+	}
+	var scheduled []ScheduledEvent
+	err = find.All(&scheduled)
+	if err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// CancelScheduled removes the scheduled event with the given id, unless
+// it's being started by the scheduler.
+func CancelScheduled(id bson.ObjectId) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.EventScheduled()
+	err = coll.Remove(bson.M{"_id": id, "claimexpires": bson.M{"$lt": time.Now().UTC()}})
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	n, err := coll.FindId(id).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrScheduledEventNotFound
+	}
+	return ErrScheduledEventStarting
+}
+
+// ApproveScheduled approves the scheduled event with the given id, pending
+// since it was created with Opts.RequireApproval, allowing the scheduler to
+// start it. The approver must not be the owner of the event. Events whose
+// RunAt has already passed are started as soon as they're approved.
+func ApproveScheduled(id bson.ObjectId, approver string) (*ScheduledEvent, error) {
+	sched, err := GetScheduled(id)
+	if err != nil {
+		return nil, err
+	}
+	if !sched.PendingApproval {
+		return nil, ErrNotPendingApproval
+	}
+	if sched.Owner.Name == approver {
+		return nil, ErrApproverIsOwner
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	update := bson.M{
+		"pendingapproval": false,
+		"approvedby":      approver,
+		"approvedat":      now,
+	}
+	if sched.RunAt.Before(now) {
+		update["runat"] = now
+	}
+	_, err = conn.EventScheduled().Find(bson.M{"_id": id, "pendingapproval": true}).Apply(mgo.Change{
+		Update:    bson.M{"$set": update},
+		ReturnNew: true,
+	}, sched)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNotPendingApproval
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sched, nil
+}
+
+// RunScheduled starts all scheduled events whose RunAt has passed, creating
+// them under the same locking, throttling and block rules of any other
+// event, and runs their executors. Each scheduled event is claimed before
+// being started, so running it concurrently in many tsuru instances is safe.
+// Events whose target is locked or throttled are retried later.
+func RunScheduled() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.EventScheduled()
+	for {
+		now := time.Now().UTC()
+		var sched ScheduledEvent
+		_, err = coll.Find(bson.M{
+			"runat":           bson.M{"$lte": now},
+			"claimexpires":    bson.M{"$lt": now},
+			"pendingapproval": bson.M{"$ne": true},
+		}).Sort("runat").Apply(mgo.Change{
+			Update:    bson.M{"$set": bson.M{"claimexpires": now.Add(scheduledRetryInterval)}},
+			ReturnNew: true,
+		}, &sched)
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = startScheduled(conn, &sched, now)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func startScheduled(conn *db.Storage, sched *ScheduledEvent, now time.Time) error {
+	coll := conn.EventScheduled()
+	opts, err := sched.opts()
+	if err != nil {
+		log.Errorf("[events] [scheduler] invalid scheduled event %s (%s on %s): %s", sched.ID.Hex(), sched.Kind, sched.Target, err)
+		return removeScheduled(coll, sched.ID)
+	}
+	evt, err := newEvt(opts)
+	if err != nil {
+		switch err.(type) {
+		case ErrEventLocked, ErrThrottled:
+			if now.Sub(sched.RunAt) < scheduledMaxDelay {
+				return coll.UpdateId(sched.ID, bson.M{
+					"$inc": bson.M{"attempts": 1},
+					"$set": bson.M{"lasterror": err.Error()},
+				})
+			}
+		}
+		log.Errorf("[events] [scheduler] unable to start scheduled event %s (%s on %s): %s", sched.ID.Hex(), sched.Kind, sched.Target, err)
+		return removeScheduled(coll, sched.ID)
+	}
+	err = removeScheduled(coll, sched.ID)
+	if err != nil {
+		evt.Abort()
+		return err
+	}
+	fn, ok := scheduledExecutors[sched.Kind.Name]
+	if !ok {
+		return evt.Done(ErrScheduleNotSupported{Kind: sched.Kind.Name})
+	}
+	err = fn(evt)
+	if err != nil {
+		log.Errorf("[events] [scheduler] scheduled event %s (%s on %s) failed: %s", sched.ID.Hex(), sched.Kind, sched.Target, err)
+	}
+	if !evt.Running {
+		return nil
+	}
+	return evt.Done(err)
+}
+
+func removeScheduled(coll *storage.Collection, id bson.ObjectId) error {
+	err := coll.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *ScheduledEvent) opts() (*Opts, error) {
+	opts := &Opts{
+		Target:        s.Target,
+		ExtraTargets:  s.ExtraTargets,
+		RawOwner:      s.Owner,
+		ActingOwner:   s.ActingOwner,
+		DisableLock:   s.DisableLock,
+		Cancelable:    s.Cancelable,
+		Allowed:       s.Allowed,
+		AllowedCancel: s.AllowedCancel,
+		scheduledFor:  s.RunAt,
+	}
+	if s.Kind.Type == KindTypePermission {
+		var err error
+		opts.Kind, err = permission.SafeGet(s.Kind.Name)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		opts.InternalKind = s.Kind.Name
+	}
+	// The custom data is decoded back so it's validated against the schema
+	// of the kind again, like the custom data of any other event.
+	var err error
+	switch s.CustomData.Kind {
+	case 0x03:
+		var data bson.D
+		err = s.CustomData.Unmarshal(&data)
+		opts.CustomData = data
+	case 0x04:
+		var data []interface{}
+		err = s.CustomData.Unmarshal(&data)
+		opts.CustomData = data
+	}
+	if err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestNewScheduled(c *check.C) {
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error { return nil })
+	runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	evt, err := New(&Opts{
+		Target:     Target{Type: "app", Value: "myapp"},
+		Kind:       permission.PermAppUpdateRestart,
+		Owner:      s.token,
+		CustomData: []map[string]interface{}{{"name": "process", "value": "web"}},
+		Allowed:    Allowed(permission.PermAppReadEvents),
+		RunAt:      runAt,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Scheduled(), check.Equals, true)
+	c.Assert(evt.ScheduledFor.Equal(runAt), check.Equals, true)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	sched, err := GetScheduled(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.RunAt.Equal(runAt), check.Equals, true)
+	c.Assert(sched.Target, check.Equals, Target{Type: "app", Value: "myapp"})
+	c.Assert(sched.Kind, check.Equals, Kind{Type: KindTypePermission, Name: "app.update.restart"})
+	c.Assert(sched.Owner, check.Equals, Owner{Type: OwnerTypeUser, Name: "me@me.com"})
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	evts, err = All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestNewScheduledNotSupported(c *check.C) {
+	c.Assert(CanSchedule("app.update.restart"), check.Equals, false)
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		RunAt:   time.Now().Add(time.Hour),
+	})
+	c.Assert(err, check.Equals, ErrScheduleNotSupported{Kind: "app.update.restart"})
+	_, err = GetScheduled(bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrScheduledEventNotFound)
+}
+
+func (s *S) TestRunScheduled(c *check.C) {
+	var process string
+	var started *Event
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error {
+		var data []map[string]interface{}
+		err := evt.StartData(&data)
+		c.Assert(err, check.IsNil)
+		process = CustomDataToForm(data).Get("process")
+		started = evt
+		evt.Logf("restarting %s", process)
+		return nil
+	})
+	runAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+	sched, err := New(&Opts{
+		Target:     Target{Type: "app", Value: "myapp"},
+		Kind:       permission.PermAppUpdateRestart,
+		Owner:      s.token,
+		CustomData: []map[string]interface{}{{"name": "process", "value": "web"}},
+		Allowed:    Allowed(permission.PermAppReadEvents),
+		RunAt:      runAt,
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(process, check.Equals, "web")
+	c.Assert(started, check.NotNil)
+	c.Assert(started.Scheduled(), check.Equals, false)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, false)
+	c.Assert(evts[0].Error, check.Equals, "")
+	c.Assert(evts[0].ScheduledFor.Equal(runAt), check.Equals, true)
+	c.Assert(evts[0].Kind.Name, check.Equals, "app.update.restart")
+	c.Assert(evts[0].Owner, check.Equals, Owner{Type: OwnerTypeUser, Name: "me@me.com"})
+	c.Assert(evts[0].Log, check.Matches, "(?s).*restarting web.*")
+	_, err = GetScheduled(sched.UniqueID)
+	c.Assert(err, check.Equals, ErrScheduledEventNotFound)
+}
+
+func (s *S) TestRunScheduledImpersonated(c *check.C) {
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error { return nil })
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   &auth.ImpersonationToken{Token: s.token, Team: "myteam"},
+		Allowed: Allowed(permission.PermAppReadEvents),
+		RunAt:   time.Now().Add(-time.Minute),
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Owner, check.Equals, Owner{Type: OwnerTypeUser, Name: "me@me.com"})
+	c.Assert(evts[0].ActingOwner, check.Equals, Owner{Type: OwnerTypeTeam, Name: "myteam"})
+}
+
+func (s *S) TestRunScheduledExecutorError(c *check.C) {
+	SetScheduledExecutor("my-maintenance", func(evt *Event) error {
+		return errors.New("maintenance failed")
+	})
+	_, err := New(&Opts{
+		Target:       Target{Type: "node", Value: "mynode"},
+		InternalKind: "my-maintenance",
+		Allowed:      Allowed(permission.PermAppReadEvents),
+		RunAt:        time.Now().Add(-time.Minute),
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Kind, check.Equals, Kind{Type: KindTypeInternal, Name: "my-maintenance"})
+	c.Assert(evts[0].Owner, check.Equals, Owner{Type: OwnerTypeInternal})
+	c.Assert(evts[0].Error, check.Equals, "maintenance failed")
+}
+
+func (s *S) TestRunScheduledLocked(c *check.C) {
+	var calls int
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error {
+		calls++
+		return nil
+	})
+	running, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	sched, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		RunAt:   time.Now().Add(-time.Minute),
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 0)
+	pending, err := GetScheduled(sched.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(pending.Attempts, check.Equals, 1)
+	c.Assert(pending.LastError, check.Matches, "event locked: .*")
+	err = running.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 0)
+	err = CancelScheduled(sched.UniqueID)
+	c.Assert(err, check.Equals, ErrScheduledEventStarting)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.EventScheduled().UpdateId(sched.UniqueID, map[string]interface{}{
+		"$set": map[string]interface{}{"claimexpires": time.Now().Add(-time.Second)},
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 1)
+}
+
+func (s *S) TestRunScheduledLockedTooLong(c *check.C) {
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error { return nil })
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	sched, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		RunAt:   time.Now().Add(-2 * scheduledMaxDelay),
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	_, err = GetScheduled(sched.UniqueID)
+	c.Assert(err, check.Equals, ErrScheduledEventNotFound)
+}
+
+func (s *S) TestListAndCancelScheduled(c *check.C) {
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error { return nil })
+	now := time.Now()
+	var ids []string
+	for _, appName := range []string{"app2", "app1"} {
+		evt, err := New(&Opts{
+			Target:  Target{Type: "app", Value: appName},
+			Kind:    permission.PermAppUpdateRestart,
+			Owner:   s.token,
+			Allowed: Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxApp, appName)),
+			RunAt:   now.Add(time.Duration(len(ids)+1) * time.Hour),
+		})
+		c.Assert(err, check.IsNil)
+		ids = append(ids, evt.UniqueID.Hex())
+	}
+	scheduled, err := ListScheduled(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 2)
+	c.Assert(scheduled[0].ID.Hex(), check.Equals, ids[0])
+	c.Assert(scheduled[1].ID.Hex(), check.Equals, ids[1])
+	scheduled, err = ListScheduled(&Filter{Target: Target{Type: "app", Value: "app1"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+	c.Assert(scheduled[0].ID.Hex(), check.Equals, ids[1])
+	scheduled, err = ListScheduled(&Filter{Permissions: []permission.Permission{
+		{Scheme: permission.PermAppReadEvents, Context: permission.Context(permission.CtxApp, "app2")},
+	}})
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+	c.Assert(scheduled[0].ID.Hex(), check.Equals, ids[0])
+	err = CancelScheduled(scheduled[0].ID)
+	c.Assert(err, check.IsNil)
+	err = CancelScheduled(scheduled[0].ID)
+	c.Assert(err, check.Equals, ErrScheduledEventNotFound)
+	scheduled, err = ListScheduled(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 1)
+	c.Assert(scheduled[0].ID.Hex(), check.Equals, ids[1])
+}
+
+func (s *S) TestNewScheduledInBlockWindow(c *check.C) {
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error { return nil })
+	runAt := time.Now().UTC().Add(2 * time.Hour).Truncate(time.Minute)
+	err := AddBlock(&Block{
+		KindName: "app.update.restart",
+		Reason:   "freeze",
+		Schedule: fmt.Sprintf("%d %d * * *", runAt.Minute(), runAt.Hour()),
+		Duration: time.Hour,
+	})
+	c.Assert(err, check.IsNil)
+	opts := &Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateRestart,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		RunAt:   runAt.Add(30 * time.Minute),
+	}
+	_, err = New(opts)
+	c.Assert(err, check.FitsTypeOf, &ErrEventBlocked{})
+	scheduled, err := ListScheduled(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduled, check.HasLen, 0)
+	opts.RunAt = runAt.Add(90 * time.Minute)
+	_, err = New(opts)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRunScheduledExecutorFinishesEvent(c *check.C) {
+	SetScheduledExecutor(permission.PermAppDeploy.FullName(), func(evt *Event) error {
+		err := errors.New("deploy failed")
+		evt.DoneCustomData(err, map[string]interface{}{"image": "myimg"})
+		return err
+	})
+	_, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		RunAt:   time.Now().Add(-time.Minute),
+	})
+	c.Assert(err, check.IsNil)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Error, check.Equals, "deploy failed")
+	var data map[string]interface{}
+	err = evts[0].EndData(&data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, map[string]interface{}{"image": "myimg"})
+}
+
+func (s *S) TestApproveScheduled(c *check.C) {
+	var started bool
+	SetScheduledExecutor(permission.PermAppUpdateRestart.FullName(), func(evt *Event) error {
+		started = true
+		return nil
+	})
+	_, err := New(&Opts{
+		Target:          Target{Type: "app", Value: "myapp"},
+		Kind:            permission.PermAppUpdateRestart,
+		Owner:           s.token,
+		Allowed:         Allowed(permission.PermAppReadEvents),
+		RequireApproval: true,
+	})
+	c.Assert(err, check.Equals, ErrNoAllowedApprove)
+	evt, err := New(&Opts{
+		Target:          Target{Type: "app", Value: "myapp"},
+		Kind:            permission.PermAppUpdateRestart,
+		Owner:           s.token,
+		Allowed:         Allowed(permission.PermAppReadEvents),
+		AllowedApprove:  Allowed(permission.PermAppUpdateRestart),
+		RequireApproval: true,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Scheduled(), check.Equals, true)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(started, check.Equals, false)
+	sched, err := GetScheduled(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.PendingApproval, check.Equals, true)
+	c.Assert(sched.AllowedApprove, check.DeepEquals, Allowed(permission.PermAppUpdateRestart))
+	_, err = ApproveScheduled(evt.UniqueID, s.token.GetUserName())
+	c.Assert(err, check.Equals, ErrApproverIsOwner)
+	sched, err = ApproveScheduled(evt.UniqueID, "approver@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.PendingApproval, check.Equals, false)
+	c.Assert(sched.ApprovedBy, check.Equals, "approver@tsuru.io")
+	_, err = ApproveScheduled(evt.UniqueID, "approver@tsuru.io")
+	c.Assert(err, check.Equals, ErrNotPendingApproval)
+	err = RunScheduled()
+	c.Assert(err, check.IsNil)
+	c.Assert(started, check.Equals, true)
+	_, err = ApproveScheduled(evt.UniqueID, "approver@tsuru.io")
+	c.Assert(err, check.Equals, ErrScheduledEventNotFound)
+}
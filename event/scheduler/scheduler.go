@@ -0,0 +1,69 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package scheduler periodically starts the events scheduled to run at a
+// given time (see event.Opts.RunAt) using the executors registered for their
+// kinds.
+//
+// Every tsuru instance runs the scheduler, scheduled events are claimed
+// before being started so each one is started by a single instance.
+package scheduler
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+)
+
+const defaultInterval = 10 * time.Second
+
+// Scheduler periodically starts the scheduled events that are due.
+type Scheduler struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// Initialize starts running scheduled events, unless disabled by the
+// event:scheduler:disabled setting.
+func Initialize() error {
+	disabled, _ := config.GetBool("event:scheduler:disabled")
+	if disabled {
+		return nil
+	}
+	s := &Scheduler{
+		interval: defaultInterval,
+		done:     make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("event:scheduler:interval"); seconds > 0 {
+		s.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(s)
+	go s.run()
+	return nil
+}
+
+func (s *Scheduler) run() {
+	for {
+		err := event.RunScheduled()
+		if err != nil {
+			log.Errorf("[event scheduler] unable to run scheduled events: %s", err)
+		}
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+func (s *Scheduler) Shutdown() {
+	s.done <- true
+}
+
+func (s *Scheduler) String() string {
+	return "event scheduler"
+}
@@ -0,0 +1,322 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	SchemaTypeObject  = "object"
+	SchemaTypeArray   = "array"
+	SchemaTypeString  = "string"
+	SchemaTypeInteger = "integer"
+	SchemaTypeNumber  = "number"
+	SchemaTypeBoolean = "boolean"
+
+	SchemaFormatDateTime = "date-time"
+)
+
+var (
+	customDataSchemas = map[string]CustomDataSchema{}
+
+	typeTime = reflect.TypeOf(time.Time{})
+)
+
+// Schema describes the expected structure of event custom data using a
+// subset of JSON Schema: type, format, properties, required, items and enum.
+// An empty schema accepts any value. Null values are handled as missing
+// values, so they're only rejected for required properties.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+}
+
+// CustomDataSchema declares the schemas of the custom data stored in events
+// of a kind. When TargetType is set, the schemas only apply to events with
+// the given target type, taking precedence over schemas declared for the
+// kind alone. Nil schemas disable validation.
+type CustomDataSchema struct {
+	TargetType      TargetType `json:",omitempty"`
+	KindName        string
+	StartCustomData *Schema `json:",omitempty"`
+	EndCustomData   *Schema `json:",omitempty"`
+}
+
+// SetCustomDataSchema registers the custom data schemas for a kind. Custom
+// data not matching the schemas is rejected when the event is created or
+// finished.
+func SetCustomDataSchema(spec CustomDataSchema) {
+	customDataSchemas[customDataSchemaKey(spec.TargetType, spec.KindName)] = spec
+}
+
+// CustomDataSchemas returns all registered custom data schemas, sorted by
+// kind name and target type.
+func CustomDataSchemas() []CustomDataSchema {
+	specs := make([]CustomDataSchema, 0, len(customDataSchemas))
+	for _, spec := range customDataSchemas {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		if specs[i].KindName == specs[j].KindName {
+			return specs[i].TargetType < specs[j].TargetType
+		}
+		return specs[i].KindName < specs[j].KindName
+	})
+	return specs
+}
+
+func customDataSchemaKey(targetType TargetType, kindName string) string {
+	if targetType == "" {
+		return kindName
+	}
+	return fmt.Sprintf("%s_%s", targetType, kindName)
+}
+
+func getCustomDataSchema(t *Target, k *Kind) *CustomDataSchema {
+	if s, ok := customDataSchemas[customDataSchemaKey(t.Type, k.Name)]; ok {
+		return &s
+	}
+	if s, ok := customDataSchemas[k.Name]; ok {
+		return &s
+	}
+	return &CustomDataSchema{}
+}
+
+// SchemaOf returns a schema describing the BSON representation of v, which
+// is usually a struct used as event custom data. Properties are named after
+// their bson keys and are required unless tagged with omitempty or declared
+// as pointers or interfaces.
+func SchemaOf(v interface{}) *Schema {
+	return schemaOfType(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+func schemaOfType(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case typeTime:
+		return &Schema{Type: SchemaTypeString, Format: SchemaFormatDateTime}
+	case reflect.TypeOf(bson.ObjectId("")):
+		return &Schema{Type: SchemaTypeString}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: SchemaTypeString}
+	case reflect.Bool:
+		return &Schema{Type: SchemaTypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: SchemaTypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaTypeNumber}
+	case reflect.Map:
+		return &Schema{Type: SchemaTypeObject}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are stored as BSON binary data.
+			return &Schema{}
+		}
+		return &Schema{Type: SchemaTypeArray, Items: schemaOfType(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return &Schema{Type: SchemaTypeObject}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		schema := &Schema{Type: SchemaTypeObject, Properties: map[string]*Schema{}}
+		addStructProperties(schema, t, seen)
+		return schema
+	}
+	return &Schema{}
+}
+
+func addStructProperties(schema *Schema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("bson")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		var omitEmpty, inline bool
+		for _, flag := range parts[1:] {
+			switch flag {
+			case "omitempty":
+				omitEmpty = true
+			case "inline":
+				inline = true
+			}
+		}
+		if inline && field.Type.Kind() == reflect.Struct {
+			addStructProperties(schema, field.Type, seen)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		schema.Properties[name] = schemaOfType(field.Type, seen)
+		kind := field.Type.Kind()
+		if !omitEmpty && kind != reflect.Ptr && kind != reflect.Interface {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// validateRaw checks raw custom data against the schema, returning an
+// ErrValidation describing the first mismatch found.
+func validateRaw(schema *Schema, raw bson.Raw) error {
+	if schema == nil || raw.Kind == 0 {
+		return nil
+	}
+	var value interface{}
+	err := raw.Unmarshal(&value)
+	if err != nil {
+		return err
+	}
+	err = schema.validate("", value)
+	if err != nil {
+		return ErrValidation(fmt.Sprintf("invalid custom data: %s", err))
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value interface{}) error {
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		return schemaError(path, "value %v is not one of %v", value, s.Enum)
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case SchemaTypeObject:
+		doc, ok := value.(bson.M)
+		if !ok {
+			return schemaTypeError(path, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if doc[name] == nil {
+				return schemaError(joinSchemaPath(path, name), "field is required")
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v := doc[name]
+			if v == nil {
+				continue
+			}
+			err := s.Properties[name].validate(joinSchemaPath(path, name), v)
+			if err != nil {
+				return err
+			}
+		}
+	case SchemaTypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return schemaTypeError(path, s.Type, value)
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, v := range items {
+			if v == nil {
+				continue
+			}
+			err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), v)
+			if err != nil {
+				return err
+			}
+		}
+	case SchemaTypeString:
+		switch value.(type) {
+		case string, bson.ObjectId:
+			if s.Format == SchemaFormatDateTime {
+				return schemaTypeError(path, SchemaFormatDateTime, value)
+			}
+		case time.Time:
+			if s.Format != SchemaFormatDateTime {
+				return schemaTypeError(path, s.Type, value)
+			}
+		default:
+			return schemaTypeError(path, s.Type, value)
+		}
+	case SchemaTypeInteger:
+		switch value.(type) {
+		case int, int32, int64:
+		default:
+			return schemaTypeError(path, s.Type, value)
+		}
+	case SchemaTypeNumber:
+		switch value.(type) {
+		case int, int32, int64, float64:
+		default:
+			return schemaTypeError(path, s.Type, value)
+		}
+	case SchemaTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return schemaTypeError(path, s.Type, value)
+		}
+	default:
+		return schemaError(path, "unknown schema type %q", s.Type)
+	}
+	return nil
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	// Numbers may be decoded from BSON with a different type than the one
+	// used in the schema, so values are also compared by their string
+	// representation.
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) || fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func schemaTypeError(path, expected string, value interface{}) error {
+	return schemaError(path, "expected %s, got %T", expected, value)
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	err := errors.Errorf(format, args...)
+	if path != "" {
+		err = errors.Errorf("%q: %s", path, err)
+	}
+	return err
+}
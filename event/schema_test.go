@@ -0,0 +1,231 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/permission"
+	check "gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type SchemaSuite struct{}
+
+var _ = check.Suite(&SchemaSuite{})
+
+type schemaTestNested struct {
+	Name  string
+	Count int `bson:"total"`
+}
+
+type schemaTestData struct {
+	ID       bson.ObjectId `bson:"_id"`
+	Message  string
+	Ratio    float64
+	Enabled  bool
+	When     time.Time
+	Tags     []string
+	Meta     map[string]string
+	Nested   schemaTestNested
+	Optional *schemaTestNested
+	Note     string `bson:",omitempty"`
+	Ignored  string `bson:"-"`
+	internal string
+}
+
+func setTestSchema(spec CustomDataSchema) func() {
+	SetCustomDataSchema(spec)
+	return func() {
+		delete(customDataSchemas, customDataSchemaKey(spec.TargetType, spec.KindName))
+	}
+}
+
+func (s *SchemaSuite) TestSchemaOf(c *check.C) {
+	nested := &Schema{
+		Type: SchemaTypeObject,
+		Properties: map[string]*Schema{
+			"name":  {Type: SchemaTypeString},
+			"total": {Type: SchemaTypeInteger},
+		},
+		Required: []string{"name", "total"},
+	}
+	c.Assert(SchemaOf(&schemaTestData{}), check.DeepEquals, &Schema{
+		Type: SchemaTypeObject,
+		Properties: map[string]*Schema{
+			"_id":      {Type: SchemaTypeString},
+			"message":  {Type: SchemaTypeString},
+			"ratio":    {Type: SchemaTypeNumber},
+			"enabled":  {Type: SchemaTypeBoolean},
+			"when":     {Type: SchemaTypeString, Format: SchemaFormatDateTime},
+			"tags":     {Type: SchemaTypeArray, Items: &Schema{Type: SchemaTypeString}},
+			"meta":     {Type: SchemaTypeObject},
+			"nested":   nested,
+			"optional": nested,
+			"note":     {Type: SchemaTypeString},
+		},
+		Required: []string{"_id", "message", "ratio", "enabled", "when", "tags", "meta", "nested"},
+	})
+	c.Assert(SchemaOf(nil), check.DeepEquals, &Schema{})
+}
+
+func (s *SchemaSuite) TestMakeBSONRawValidStruct(c *check.C) {
+	data := schemaTestData{
+		ID:      bson.NewObjectId(),
+		Message: "hello",
+		When:    time.Now(),
+		Tags:    []string{"a"},
+		Nested:  schemaTestNested{Name: "n", Count: 2},
+	}
+	raw, err := makeBSONRaw(data, SchemaOf(data))
+	c.Assert(err, check.IsNil)
+	c.Assert(raw.Kind, check.Equals, byte(3))
+	data.Optional = &schemaTestNested{Name: "o"}
+	_, err = makeBSONRaw(&data, SchemaOf(data))
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SchemaSuite) TestMakeBSONRawInvalid(c *check.C) {
+	schema := &Schema{
+		Type: SchemaTypeObject,
+		Properties: map[string]*Schema{
+			"image": {Type: SchemaTypeString},
+			"units": {Type: SchemaTypeInteger},
+			"mode":  {Type: SchemaTypeString, Enum: []interface{}{"warn", "enforce"}},
+			"procs": {Type: SchemaTypeArray, Items: &Schema{
+				Type:       SchemaTypeObject,
+				Properties: map[string]*Schema{"name": {Type: SchemaTypeString}},
+				Required:   []string{"name"},
+			}},
+		},
+		Required: []string{"image"},
+	}
+	tt := []struct {
+		data interface{}
+		err  string
+	}{
+		{map[string]interface{}{"image": "img", "units": 2, "mode": "warn"}, ""},
+		{map[string]interface{}{"image": "img", "units": int64(2), "procs": []bson.M{{"name": "web"}}}, ""},
+		{map[string]interface{}{"image": "img", "units": nil}, ""},
+		{map[string]interface{}{"units": 2}, `invalid custom data: "image": field is required`},
+		{map[string]interface{}{"image": nil}, `invalid custom data: "image": field is required`},
+		{map[string]interface{}{"image": 10}, `invalid custom data: "image": expected string, got int`},
+		{map[string]interface{}{"image": "img", "units": "2"}, `invalid custom data: "units": expected integer, got string`},
+		{map[string]interface{}{"image": "img", "units": 1.5}, `invalid custom data: "units": expected integer, got float64`},
+		{map[string]interface{}{"image": "img", "mode": "off"}, `invalid custom data: "mode": value off is not one of \[warn enforce\]`},
+		{map[string]interface{}{"image": "img", "procs": []bson.M{{"name": 1}}}, `invalid custom data: "procs\[0\].name": expected string, got int`},
+		{map[string]interface{}{"image": "img", "procs": []bson.M{{}}}, `invalid custom data: "procs\[0\].name": field is required`},
+		{[]string{"img"}, `invalid custom data: expected object, got \[\]interface {}`},
+	}
+	for i, t := range tt {
+		_, err := makeBSONRaw(t.data, schema)
+		if t.err == "" {
+			c.Check(err, check.IsNil, check.Commentf("%d", i))
+			continue
+		}
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%d", i))
+		c.Check(err, check.FitsTypeOf, ErrValidation(""), check.Commentf("%d", i))
+	}
+}
+
+func (s *SchemaSuite) TestMakeBSONRawNilSkipsValidation(c *check.C) {
+	schema := &Schema{Type: SchemaTypeObject, Required: []string{"image"}}
+	raw, err := makeBSONRaw(nil, schema)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw, check.DeepEquals, bson.Raw{})
+	_, err = makeBSONRaw(map[string]string{"x": "y"}, nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SchemaSuite) TestGetCustomDataSchema(c *check.C) {
+	byKind := &Schema{Type: SchemaTypeObject}
+	byTarget := &Schema{Type: SchemaTypeArray}
+	defer setTestSchema(CustomDataSchema{KindName: "mykind", StartCustomData: byKind})()
+	defer setTestSchema(CustomDataSchema{TargetType: TargetTypeNode, KindName: "mykind", StartCustomData: byTarget})()
+	kind := &Kind{Type: KindTypeInternal, Name: "mykind"}
+	c.Assert(getCustomDataSchema(&Target{Type: TargetTypeApp}, kind).StartCustomData, check.Equals, byKind)
+	c.Assert(getCustomDataSchema(&Target{Type: TargetTypeNode}, kind).StartCustomData, check.Equals, byTarget)
+	other := &Kind{Type: KindTypeInternal, Name: "other"}
+	c.Assert(getCustomDataSchema(&Target{Type: TargetTypeNode}, other), check.DeepEquals, &CustomDataSchema{})
+}
+
+func (s *SchemaSuite) TestCustomDataSchemas(c *check.C) {
+	defer setTestSchema(CustomDataSchema{KindName: "zkind"})()
+	defer setTestSchema(CustomDataSchema{TargetType: TargetTypeNode, KindName: "akind"})()
+	defer setTestSchema(CustomDataSchema{KindName: "akind"})()
+	var names []string
+	for _, spec := range CustomDataSchemas() {
+		if spec.KindName == "akind" || spec.KindName == "zkind" {
+			names = append(names, customDataSchemaKey(spec.TargetType, spec.KindName))
+		}
+	}
+	c.Assert(names, check.DeepEquals, []string{"akind", "node_akind", "zkind"})
+}
+
+func (s *S) TestNewEventInvalidCustomData(c *check.C) {
+	defer setTestSchema(CustomDataSchema{
+		KindName: "mykind",
+		StartCustomData: &Schema{
+			Type:       SchemaTypeObject,
+			Properties: map[string]*Schema{"image": {Type: SchemaTypeString}},
+			Required:   []string{"image"},
+		},
+	})()
+	_, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "myapp"},
+		InternalKind: "mykind",
+		CustomData:   map[string]int{"image": 1},
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.ErrorMatches, `invalid custom data: "image": expected string, got int`)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+	evt, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "myapp"},
+		InternalKind: "mykind",
+		CustomData:   map[string]string{"image": "img"},
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestEventDoneInvalidCustomData(c *check.C) {
+	defer setTestSchema(CustomDataSchema{
+		KindName:      "mykind",
+		EndCustomData: &Schema{Type: SchemaTypeObject, Required: []string{"image"}},
+	})()
+	evt, err := NewInternal(&Opts{
+		Target:       Target{Type: "app", Value: "myapp"},
+		InternalKind: "mykind",
+		Allowed:      Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.DoneCustomData(nil, map[string]string{"other": "x"})
+	c.Assert(err, check.ErrorMatches, `invalid custom data: "image": field is required`)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, false)
+	c.Assert(evts[0].EndCustomData.Kind, check.Equals, byte(0))
+}
+
+func (s *S) TestEventRawInsertInvalidCustomData(c *check.C) {
+	defer setTestSchema(CustomDataSchema{
+		KindName:        "mykind",
+		StartCustomData: &Schema{Type: SchemaTypeArray},
+	})()
+	evt := &Event{eventData: eventData{
+		UniqueID: bson.NewObjectId(),
+		Target:   Target{Type: "app", Value: "myapp"},
+		Kind:     Kind{Type: KindTypeInternal, Name: "mykind"},
+		Owner:    Owner{Type: OwnerTypeInternal},
+		Allowed:  Allowed(permission.PermAppReadEvents),
+	}}
+	err := evt.RawInsert(map[string]string{"a": "b"}, nil, nil)
+	c.Assert(err, check.ErrorMatches, `invalid custom data: expected array, got bson.M`)
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Service stores events in place of the events collection in MongoDB, which
+// is used when no service is set. It's meant for tests of code recording
+// events, see the eventtest package for an in-memory implementation.
+//
+// Events created while a service is set are not checked against blocks,
+// throttling and change rate limits, events with Opts.WaitLock don't wait for
+// locks and events can't be scheduled.
+type Service interface {
+	// Insert stores a new running event. When the event takes the lock of
+	// its target and another running event holds it, the running event is
+	// returned and nothing is stored.
+	Insert(evt *Event) (locked *Event, err error)
+
+	// Finish stores the final state of the event.
+	Finish(evt *Event) error
+
+	// Remove removes an aborted event.
+	Remove(evt *Event) error
+
+	// Get returns the event with the given unique ID, or ErrEventNotFound.
+	Get(id bson.ObjectId) (*Event, error)
+
+	// GetRunning returns the running event of the given kind holding the
+	// lock of the target, or ErrEventNotFound.
+	GetRunning(target Target, kind string) (*Event, error)
+
+	// List returns the events matching the filter, newest first.
+	List(filter *Filter) ([]*Event, error)
+}
+
+var (
+	serviceMu sync.RWMutex
+	service   Service
+)
+
+// SetService replaces the storage of events with s. A nil service restores
+// the default storage, in MongoDB.
+func SetService(s Service) {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
+	service = s
+}
+
+// GetService returns the service set with SetService, or nil when events
+// are stored in MongoDB.
+func GetService() Service {
+	serviceMu.RLock()
+	defer serviceMu.RUnlock()
+	return service
+}
+
+// HoldsLock returns whether the event holds the lock of its target while
+// it's running, which is the case unless it was created with
+// Opts.DisableLock.
+func (e *Event) HoldsLock() bool {
+	return len(e.ID.ObjId) == 0
+}
+
+func newServiceEvt(svc Service, opts *Opts, k *Kind, o, a *Owner) (*Event, error) {
+	if !opts.RunAt.IsZero() || opts.RequireApproval {
+		return nil, ErrScheduleNotSupported{Kind: k.Name}
+	}
+	evt, err := buildEvt(opts, k, o, a)
+	if err != nil {
+		return nil, err
+	}
+	evt.service = svc
+	if opts.DryRun {
+		evt.dryRun = true
+		return evt, nil
+	}
+	locked, err := svc.Insert(evt)
+	if err != nil {
+		return nil, err
+	}
+	if locked != nil {
+		return nil, ErrEventLocked{event: locked}
+	}
+	return evt, nil
+}
+
+func listService(svc Service, filter *Filter) ([]Event, error) {
+	found, err := svc.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	evts := make([]Event, len(found))
+	for i := range found {
+		evts[i].eventData = found[i].eventData
+		evts[i].service = svc
+	}
+	return evts, nil
+}
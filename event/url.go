@@ -0,0 +1,89 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/tsuru/config"
+)
+
+// URLBuilder returns the path, relative to the dashboard URL, of the page
+// describing the given target.
+type URLBuilder func(target Target) string
+
+var (
+	urlBuildersMu sync.RWMutex
+	urlBuilders   = map[TargetType]URLBuilder{
+		TargetTypeApp:             pathURLBuilder("/apps/"),
+		TargetTypeNode:            pathURLBuilder("/nodes/"),
+		TargetTypeContainer:       pathURLBuilder("/containers/"),
+		TargetTypePool:            pathURLBuilder("/pools/"),
+		TargetTypeService:         pathURLBuilder("/services/"),
+		TargetTypeServiceInstance: pathURLBuilder("/service-instances/"),
+		TargetTypeTeam:            pathURLBuilder("/teams/"),
+		TargetTypeUser:            pathURLBuilder("/users/"),
+		TargetTypePlatform:        pathURLBuilder("/platforms/"),
+		TargetTypeRole:            pathURLBuilder("/roles/"),
+	}
+)
+
+func pathURLBuilder(prefix string) URLBuilder {
+	return func(target Target) string {
+		return prefix + url.PathEscape(target.Value)
+	}
+}
+
+// RegisterURLBuilder sets the builder used to generate dashboard URLs for
+// targets of the given type, replacing any builder previously registered.
+func RegisterURLBuilder(targetType TargetType, builder URLBuilder) {
+	urlBuildersMu.Lock()
+	defer urlBuildersMu.Unlock()
+	urlBuilders[targetType] = builder
+}
+
+func dashboardURL() string {
+	base, _ := config.GetString("event:dashboard-url")
+	return strings.TrimRight(base, "/")
+}
+
+// TargetURL returns the dashboard URL for the given target. A custom path
+// may be set for each target type in the event:urls:<target-type> config,
+// where {value} is replaced by the target value. An empty string is returned
+// if the dashboard URL is not configured or there's no way to build an URL
+// for the target type.
+func TargetURL(target Target) string {
+	base := dashboardURL()
+	if base == "" || target.Value == "" {
+		return ""
+	}
+	if tpl, _ := config.GetString("event:urls:" + string(target.Type)); tpl != "" {
+		return base + strings.Replace(tpl, "{value}", url.PathEscape(target.Value), -1)
+	}
+	urlBuildersMu.RLock()
+	builder, ok := urlBuilders[target.Type]
+	urlBuildersMu.RUnlock()
+	if !ok {
+		return ""
+	}
+	return base + builder(target)
+}
+
+// URL returns the dashboard URL for the event itself, or an empty string if
+// the dashboard URL is not configured.
+func (e *Event) URL() string {
+	base := dashboardURL()
+	if base == "" {
+		return ""
+	}
+	return base + "/events/" + e.UniqueID.Hex()
+}
+
+// TargetURL returns the dashboard URL for the event target.
+func (e *Event) TargetURL() string {
+	return TargetURL(e.Target)
+}
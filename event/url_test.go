@@ -0,0 +1,54 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestTargetURL(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.example.com/")
+	defer config.Unset("event")
+	c.Assert(TargetURL(Target{Type: TargetTypeApp, Value: "myapp"}), check.Equals, "https://dashboard.example.com/apps/myapp")
+	c.Assert(TargetURL(Target{Type: TargetTypeNode, Value: "http://10.0.0.1:2375"}), check.Equals, "https://dashboard.example.com/nodes/http:%2F%2F10.0.0.1:2375")
+	c.Assert(TargetURL(Target{Type: TargetTypeEventBlock, Value: "x"}), check.Equals, "")
+	c.Assert(TargetURL(Target{Type: TargetTypeApp}), check.Equals, "")
+}
+
+func (s *S) TestTargetURLNoDashboard(c *check.C) {
+	c.Assert(TargetURL(Target{Type: TargetTypeApp, Value: "myapp"}), check.Equals, "")
+}
+
+func (s *S) TestTargetURLCustomTemplate(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.example.com")
+	config.Set("event:urls:app", "/applications/{value}/info")
+	defer config.Unset("event")
+	c.Assert(TargetURL(Target{Type: TargetTypeApp, Value: "myapp"}), check.Equals, "https://dashboard.example.com/applications/myapp/info")
+}
+
+func (s *S) TestRegisterURLBuilder(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.example.com")
+	defer config.Unset("event")
+	RegisterURLBuilder(TargetTypeEventBlock, func(t Target) string {
+		return "/blocks#" + t.Value
+	})
+	defer func() {
+		urlBuildersMu.Lock()
+		delete(urlBuilders, TargetTypeEventBlock)
+		urlBuildersMu.Unlock()
+	}()
+	c.Assert(TargetURL(Target{Type: TargetTypeEventBlock, Value: "b1"}), check.Equals, "https://dashboard.example.com/blocks#b1")
+}
+
+func (s *S) TestEventURL(c *check.C) {
+	config.Set("event:dashboard-url", "https://dashboard.example.com")
+	defer config.Unset("event")
+	id := bson.NewObjectId()
+	evt := Event{eventData: eventData{UniqueID: id, Target: Target{Type: TargetTypeApp, Value: "myapp"}}}
+	c.Assert(evt.URL(), check.Equals, "https://dashboard.example.com/events/"+id.Hex())
+	c.Assert(evt.TargetURL(), check.Equals, "https://dashboard.example.com/apps/myapp")
+}
@@ -21,7 +21,10 @@ const defaultUserData = `#!/bin/bash
 curl -sL https://raw.github.com/tsuru/now/master/run.bash | bash -s -- --docker-only
 `
 
-var ErrNoDefaultIaaS = errors.New("no default iaas configured")
+var (
+	ErrNoDefaultIaaS           = errors.New("no default iaas configured")
+	ErrCredentialsNotSupported = errors.New("iaas doesn't support credentials rotation")
+)
 
 // Every Tsuru IaaS must implement this interface.
 type IaaS interface {
@@ -44,6 +47,19 @@ type InitializableIaaS interface {
 	Initialize() error
 }
 
+// CredentialsIaaS is an IaaS able to replace the credentials used to access
+// its machines.
+type CredentialsIaaS interface {
+	// RotateCredentials generates new credentials and installs them in the
+	// machine. The current credentials of the machine must keep working until
+	// RevokeCredentials is called with them.
+	RotateCredentials(m *Machine) (*Credentials, error)
+
+	// RevokeCredentials invalidates credentials previously used to access
+	// the machine.
+	RevokeCredentials(m *Machine, old *Credentials) error
+}
+
 type NamedIaaS struct {
 	BaseIaaSName string
 	IaaSName     string
@@ -65,6 +65,17 @@ type Machine struct {
 	CaCert         []byte                 `json:"-"`
 	ClientCert     []byte                 `json:"-"`
 	ClientKey      []byte                 `json:"-"`
+	KeyPair        string                 `bson:",omitempty"`
+}
+
+// Credentials are the credentials used to access a machine: the TLS
+// certificates of its docker API and the name of the IaaS keypair allowed to
+// access it through SSH.
+type Credentials struct {
+	CaCert     []byte
+	ClientCert []byte
+	ClientKey  []byte
+	KeyPair    string
 }
 
 func CreateMachine(params map[string]string) (*Machine, error) {
@@ -172,6 +183,74 @@ func (m *Machine) Destroy() error {
 	return m.removeFromDB()
 }
 
+// Credentials returns the credentials currently used to access the machine.
+func (m *Machine) Credentials() *Credentials {
+	return &Credentials{
+		CaCert:     m.CaCert,
+		ClientCert: m.ClientCert,
+		ClientKey:  m.ClientKey,
+		KeyPair:    m.KeyPair,
+	}
+}
+
+// RotateCredentials asks the IaaS of the machine to generate and install new
+// credentials, returning them. The machine keeps its current credentials
+// until SetCredentials is called, ErrCredentialsNotSupported is returned if
+// the IaaS can't rotate credentials.
+func (m *Machine) RotateCredentials() (*Credentials, error) {
+	rotator, err := m.credentialsIaaS()
+	if err != nil {
+		return nil, err
+	}
+	return rotator.RotateCredentials(m)
+}
+
+// SetCredentials stores creds as the credentials used to access the
+// machine.
+func (m *Machine) SetCredentials(creds *Credentials) error {
+	coll, err := collection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.UpdateId(m.Id, bson.M{"$set": bson.M{
+		"cacert":     creds.CaCert,
+		"clientcert": creds.ClientCert,
+		"clientkey":  creds.ClientKey,
+		"keypair":    creds.KeyPair,
+	}})
+	if err != nil {
+		return err
+	}
+	m.CaCert = creds.CaCert
+	m.ClientCert = creds.ClientCert
+	m.ClientKey = creds.ClientKey
+	m.KeyPair = creds.KeyPair
+	return nil
+}
+
+// RevokeCredentials asks the IaaS of the machine to invalidate old, the
+// credentials previously used to access it.
+func (m *Machine) RevokeCredentials(old *Credentials) error {
+	rotator, err := m.credentialsIaaS()
+	if err != nil {
+		return err
+	}
+	return rotator.RevokeCredentials(m, old)
+}
+
+func (m *Machine) credentialsIaaS() (CredentialsIaaS, error) {
+	iaas, err := getIaasProvider(m.Iaas)
+	if err != nil {
+		return nil, err
+	}
+	rotator, ok := iaas.(CredentialsIaaS)
+	if !ok {
+		return nil, ErrCredentialsNotSupported
+	}
+	return rotator, nil
+}
+
 func (m *Machine) FormatNodeAddress() string {
 	protocol := m.Protocol
 	if protocol == "" {
@@ -159,6 +159,41 @@ func (s *S) TestDestroy(c *check.C) {
 	c.Assert(machines, check.HasLen, 0)
 }
 
+func (s *S) TestRotateCredentials(c *check.C) {
+	RegisterIaasProvider("creds-iaas", newTestCredentialsIaaS)
+	m, err := CreateMachineForIaaS("creds-iaas", map[string]string{"id": "myid1"})
+	c.Assert(err, check.IsNil)
+	m.ClientCert = []byte("old-cert")
+	old := m.Credentials()
+	creds, err := m.RotateCredentials()
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.KeyPair, check.Equals, "new-key-pair")
+	err = m.SetCredentials(creds)
+	c.Assert(err, check.IsNil)
+	c.Assert(m.ClientCert, check.DeepEquals, []byte("new-cert"))
+	dbMachine, err := FindMachineById("myid1")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbMachine.ClientCert, check.DeepEquals, []byte("new-cert"))
+	c.Assert(dbMachine.ClientKey, check.DeepEquals, []byte("new-key"))
+	c.Assert(dbMachine.KeyPair, check.Equals, "new-key-pair")
+	err = m.RevokeCredentials(old)
+	c.Assert(err, check.IsNil)
+	iaas, err := getIaasProvider("creds-iaas")
+	c.Assert(err, check.IsNil)
+	credsIaaS := iaas.(*TestCredentialsIaaS)
+	c.Assert(credsIaaS.cmds, check.DeepEquals, []string{"create", "rotate", "revoke"})
+	c.Assert(credsIaaS.revoked, check.DeepEquals, []*Credentials{old})
+}
+
+func (s *S) TestRotateCredentialsNotSupported(c *check.C) {
+	m, err := CreateMachineForIaaS("test-iaas", map[string]string{"id": "myid1"})
+	c.Assert(err, check.IsNil)
+	_, err = m.RotateCredentials()
+	c.Assert(err, check.Equals, ErrCredentialsNotSupported)
+	err = m.RevokeCredentials(m.Credentials())
+	c.Assert(err, check.Equals, ErrCredentialsNotSupported)
+}
+
 func (s *S) TestFindById(c *check.C) {
 	_, err := CreateMachineForIaaS("test-iaas", map[string]string{"id": "myid1"})
 	c.Assert(err, check.IsNil)
@@ -102,6 +102,26 @@ func (i *TestHealthCheckerIaaS) HealthCheck() error {
 	return i.err
 }
 
+type TestCredentialsIaaS struct {
+	TestIaaS
+	revoked []*Credentials
+}
+
+func (i *TestCredentialsIaaS) RotateCredentials(m *Machine) (*Credentials, error) {
+	i.cmds = append(i.cmds, "rotate")
+	return &Credentials{ClientCert: []byte("new-cert"), ClientKey: []byte("new-key"), KeyPair: "new-key-pair"}, nil
+}
+
+func (i *TestCredentialsIaaS) RevokeCredentials(m *Machine, old *Credentials) error {
+	i.cmds = append(i.cmds, "revoke")
+	i.revoked = append(i.revoked, old)
+	return nil
+}
+
+func newTestCredentialsIaaS(name string) IaaS {
+	return &TestCredentialsIaaS{}
+}
+
 func newTestHealthcheckIaaS(name string) IaaS {
 	return &TestHealthCheckerIaaS{}
 }
@@ -5,6 +5,7 @@
 package testing
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/tsuru/tsuru/iaas"
@@ -73,3 +74,47 @@ func (t *TestHealerIaaS) CreateMachine(params map[string]string) (*iaas.Machine,
 func (t *TestHealerIaaS) Describe() string {
 	return "iaas describe"
 }
+
+// TestCredentialsIaaS is a TestHealerIaaS able to rotate the credentials of
+// its machines. The generated credentials are fake, they're only meant to be
+// compared in tests.
+type TestCredentialsIaaS struct {
+	TestHealerIaaS
+	RotateErr error
+	RevokeErr error
+	Rotated   []string
+	Revoked   []string
+}
+
+func NewCredentialsIaaSConstructorWithInst(addr string) (func(string) iaas.IaaS, *TestCredentialsIaaS) {
+	inst := &TestCredentialsIaaS{TestHealerIaaS: TestHealerIaaS{Addr: addr}}
+	return func(name string) iaas.IaaS {
+		return inst
+	}, inst
+}
+
+func (t *TestCredentialsIaaS) RotateCredentials(m *iaas.Machine) (*iaas.Credentials, error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.RotateErr != nil {
+		return nil, t.RotateErr
+	}
+	t.Rotated = append(t.Rotated, m.Id)
+	n := len(t.Rotated)
+	return &iaas.Credentials{
+		CaCert:     m.CaCert,
+		ClientCert: []byte(fmt.Sprintf("cert-%s-%d", m.Id, n)),
+		ClientKey:  []byte(fmt.Sprintf("key-%s-%d", m.Id, n)),
+		KeyPair:    fmt.Sprintf("keypair-%s-%d", m.Id, n),
+	}, nil
+}
+
+func (t *TestCredentialsIaaS) RevokeCredentials(m *iaas.Machine, old *iaas.Credentials) error {
+	t.Lock()
+	defer t.Unlock()
+	if t.RevokeErr != nil {
+		return t.RevokeErr
+	}
+	t.Revoked = append(t.Revoked, old.KeyPair)
+	return nil
+}
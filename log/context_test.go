@@ -0,0 +1,17 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestContextWithRequestID(c *check.C) {
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	c.Assert(RequestIDFromContext(ctx), check.Equals, "req-1")
+	c.Assert(RequestIDFromContext(context.Background()), check.Equals, "")
+}
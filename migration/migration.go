@@ -14,6 +14,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -37,6 +39,10 @@ var ErrMigrationAlreadyExecuted = errors.New("migration already executed")
 // parameter is supplied without the name of a migration to run.
 var ErrCannotForceMandatory = errors.New("mandatory migrations can only run once")
 
+// ErrMigrationRunning is the error returned by Run when the migration is
+// being executed by another process.
+var ErrMigrationRunning = errors.New("migration is already running")
+
 // MigrateFunc represents a migration function, that can be registered with the
 // Register function. Migrations are later ran in the registration order, and
 // this package keeps track of which migrate have ran already.
@@ -105,19 +111,22 @@ func run(args RunArgs) error {
 		return err
 	}
 	defer coll.Close()
+	var mandatory []migration
 	for _, m := range migrationsToRun {
-		if m.Optional {
-			continue
+		if !m.Optional {
+			mandatory = append(mandatory, m)
 		}
-		fmt.Fprintf(args.Writer, "Running %q... ", m.Name)
+	}
+	for i, m := range mandatory {
+		fmt.Fprintf(args.Writer, "Running %q (%d/%d)... ", m.Name, i+1, len(mandatory))
 		if !args.Dry {
-			err := m.fn()
-			if err != nil {
-				return err
+			err = execute(coll, &m, false)
+			if err == ErrMigrationAlreadyExecuted {
+				fmt.Fprintln(args.Writer, "already executed")
+				continue
 			}
-			m.Ran = true
-			err = coll.Insert(m)
 			if err != nil {
+				fmt.Fprintln(args.Writer, "FAILED")
 				return err
 			}
 		}
@@ -126,6 +135,48 @@ func run(args RunArgs) error {
 	return nil
 }
 
+// execute runs the migration holding a lock on an event targeting it, which
+// also records the execution. As another process may have executed the
+// migration before the lock was acquired, it's checked again while holding
+// the lock, returning ErrMigrationAlreadyExecuted unless force is set.
+func execute(coll *storage.Collection, m *migration, force bool) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeMigration, Value: m.Name},
+		InternalKind: "migrate",
+		Allowed:      event.Allowed(permission.PermMigrationReadEvents),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			return ErrMigrationRunning
+		}
+		return err
+	}
+	defer func() {
+		if err == ErrMigrationAlreadyExecuted {
+			evt.Abort()
+		} else {
+			evt.Done(err)
+		}
+	}()
+	if !force {
+		var n int
+		n, err = coll.Find(bson.M{"name": m.Name, "ran": true}).Count()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrMigrationAlreadyExecuted
+		}
+	}
+	err = m.fn()
+	if err != nil {
+		return err
+	}
+	m.Ran = true
+	_, err = coll.Upsert(bson.M{"name": m.Name}, m)
+	return err
+}
+
 func runOptional(args RunArgs) error {
 	migrationsToRun, err := getMigrations(false)
 	if err != nil {
@@ -154,13 +205,13 @@ func runOptional(args RunArgs) error {
 			return err
 		}
 		defer coll.Close()
-		err = toRun.fn()
-		if err != nil {
-			return err
+		err = execute(coll, toRun, args.Force)
+		if err == ErrMigrationAlreadyExecuted {
+			fmt.Fprintln(args.Writer, "already executed")
+			return ErrMigrationAlreadyExecuted
 		}
-		toRun.Ran = true
-		_, err = coll.Upsert(bson.M{"name": toRun.Name}, toRun)
 		if err != nil {
+			fmt.Fprintln(args.Writer, "FAILED")
 			return err
 		}
 	}
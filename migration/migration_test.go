@@ -12,6 +12,8 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
 	"gopkg.in/check.v1"
 )
 
@@ -45,9 +47,9 @@ func (s *Suite) TearDownSuite(c *check.C) {
 }
 
 func (s *Suite) TestRun(c *check.C) {
-	expected := `Running "migration1"... OK
-Running "migration2"... OK
-Running "migration3"... OK
+	expected := `Running "migration1" (1/3)... OK
+Running "migration2" (2/3)... OK
+Running "migration3" (3/3)... OK
 `
 	var buf bytes.Buffer
 	var runs []string
@@ -124,15 +126,16 @@ func (s *Suite) TestFailingMigration(c *check.C) {
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, "something went wrong")
 	c.Assert(runs, check.HasLen, 0)
+	c.Assert(buf.String(), check.Equals, "Running \"mig1\" (1/2)... FAILED\n")
 	err = Run(RunArgs{Writer: &buf, Dry: false})
 	c.Assert(err, check.IsNil)
 	c.Assert(runs, check.DeepEquals, []string{"mig1", "mig2"})
 }
 
 func (s *Suite) TestRunDryMode(c *check.C) {
-	expected := `Running "migration1"... OK
-Running "migration2"... OK
-Running "migration3"... OK
+	expected := `Running "migration1" (1/3)... OK
+Running "migration2" (2/3)... OK
+Running "migration3" (3/3)... OK
 `
 	var buf bytes.Buffer
 	var runs []string
@@ -240,3 +243,72 @@ func (s *Suite) TestList(c *check.C) {
 		{Name: "migration3", Optional: true, Ran: true},
 	})
 }
+
+func (s *Suite) TestRunRecordsEvents(c *check.C) {
+	var buf bytes.Buffer
+	err := Register("migration1", func() error { return nil })
+	c.Assert(err, check.IsNil)
+	err = Register("migration2", func() error { return errors.New("migration failed") })
+	c.Assert(err, check.IsNil)
+	err = Run(RunArgs{Writer: &buf})
+	c.Assert(err, check.ErrorMatches, "migration failed")
+	evts, err := event.List(&event.Filter{Target: event.Target{Type: event.TargetTypeMigration}, Sort: "starttime"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	c.Assert(evts[0].Target.Value, check.Equals, "migration1")
+	c.Assert(evts[0].Kind.Name, check.Equals, "migrate")
+	c.Assert(evts[0].Error, check.Equals, "")
+	c.Assert(evts[1].Target.Value, check.Equals, "migration2")
+	c.Assert(evts[1].Error, check.Equals, "migration failed")
+}
+
+func (s *Suite) TestRunMigrationRunning(c *check.C) {
+	var buf bytes.Buffer
+	var runs int
+	err := Register("migration1", func() error {
+		runs++
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeMigration, Value: "migration1"},
+		InternalKind: "migrate",
+		Allowed:      event.Allowed(permission.PermMigrationReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = Run(RunArgs{Writer: &buf})
+	c.Assert(err, check.Equals, ErrMigrationRunning)
+	c.Assert(runs, check.Equals, 0)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = Run(RunArgs{Writer: &buf})
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.Equals, 1)
+}
+
+func (s *Suite) TestRunMigrationExecutedByOtherProcess(c *check.C) {
+	var runs int
+	err := Register("migration1", func() error {
+		runs++
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	coll, err := collection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	m := migrations[0]
+	err = coll.Insert(m)
+	c.Assert(err, check.IsNil)
+	err = execute(coll, &m, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.Equals, 1)
+	err = execute(coll, &m, false)
+	c.Assert(err, check.Equals, ErrMigrationAlreadyExecuted)
+	c.Assert(runs, check.Equals, 1)
+	err = execute(coll, &m, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.Equals, 2)
+	n, err := coll.Find(nil).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+}
@@ -25,7 +25,9 @@ function missing_handlers {
 
     ignored=$(cat <<EOF
 github.com/tsuru/tsuru/api.authScheme
+github.com/tsuru/tsuru/api.passwordPolicy
 github.com/tsuru/tsuru/api.healthcheck
+github.com/tsuru/tsuru/api.statusPage
 github.com/tsuru/tsuru/api.index
 github.com/tsuru/tsuru/api.info
 github.com/tsuru/tsuru/api.resetPassword
@@ -35,6 +37,7 @@ github.com/tsuru/tsuru/api.addKeyToUser
 github.com/tsuru/tsuru/api.listPlans
 github.com/tsuru/tsuru/api.login
 github.com/tsuru/tsuru/api.logout
+github.com/tsuru/tsuru/api.refreshToken
 github.com/tsuru/tsuru/api.changePassword
 github.com/tsuru/tsuru/api.userInfo
 github.com/tsuru/tsuru/api.serviceInfo
@@ -42,10 +45,18 @@ github.com/tsuru/tsuru/api.serviceInstances
 github.com/tsuru/tsuru/api.listKeys
 github.com/tsuru/tsuru/api.listUsers
 github.com/tsuru/tsuru/api.removeKeyFromUser
+github.com/tsuru/tsuru/api.createAPIToken
+github.com/tsuru/tsuru/api.listAPITokens
+github.com/tsuru/tsuru/api.revokeAPIToken
+github.com/tsuru/tsuru/api.nodeCredentialsResume
+github.com/tsuru/tsuru/api.nodeCredentialsRotationList
+github.com/tsuru/tsuru/api.nodeCredentialsRotationInfo
 github.com/tsuru/tsuru/api.setNodeStatus
 github.com/tsuru/tsuru/api.kindList
+github.com/tsuru/tsuru/api.eventKindAliasList
 github.com/tsuru/tsuru/api.eventList
 github.com/tsuru/tsuru/api.eventInfo
+github.com/tsuru/tsuru/api.eventNotificationList
 github.com/tsuru/tsuru/api.eventCancel
 github.com/tsuru/tsuru/api.listNodesHandler
 github.com/tsuru/tsuru/api.nodeContainerInfo
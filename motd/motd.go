@@ -0,0 +1,134 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package motd manages the messages of the day, announcements created by
+// platform operators that are displayed by the tsuru client before the output
+// of commands.
+package motd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var (
+	ErrTextRequired    = errors.New("message text is required")
+	ErrInvalidSeverity = errors.New("invalid severity, must be one of info, warning or critical")
+	ErrAlreadyExpired  = errors.New("expiration time must be in the future")
+	ErrMessageNotFound = errors.New("message not found")
+)
+
+// Message is an announcement displayed to users. Messages with no teams are
+// displayed to every user, otherwise only to members of the listed teams.
+// Messages with a zero Expires are displayed until removed.
+type Message struct {
+	ID        bson.ObjectId `bson:"_id"`
+	Text      string
+	Severity  string
+	Teams     []string  `bson:",omitempty"`
+	Expires   time.Time `bson:",omitempty"`
+	Owner     string
+	CreatedAt time.Time
+}
+
+func (m *Message) validate() error {
+	if m.Text == "" {
+		return ErrTextRequired
+	}
+	switch m.Severity {
+	case "":
+		m.Severity = SeverityInfo
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return ErrInvalidSeverity
+	}
+	if !m.Expires.IsZero() && !m.Expires.After(time.Now()) {
+		return ErrAlreadyExpired
+	}
+	return nil
+}
+
+// Add validates and stores a new message, generating its ID when it's not
+// set.
+func Add(m *Message) error {
+	err := m.validate()
+	if err != nil {
+		return err
+	}
+	if m.ID == "" {
+		m.ID = bson.NewObjectId()
+	}
+	m.CreatedAt = time.Now().UTC()
+	if !m.Expires.IsZero() {
+		m.Expires = m.Expires.UTC()
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Motd().Insert(m)
+}
+
+func Remove(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrMessageNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Motd().RemoveId(bson.ObjectIdHex(id))
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	return err
+}
+
+// List returns all messages, including expired ones, newest first.
+func List() ([]Message, error) {
+	return list(nil)
+}
+
+// ListActive returns the messages that didn't expire yet and are displayed
+// either to every user or to members of at least one of the given teams,
+// newest first.
+func ListActive(teams []string) ([]Message, error) {
+	audience := []bson.M{{"teams": bson.M{"$exists": false}}}
+	if len(teams) > 0 {
+		audience = append(audience, bson.M{"teams": bson.M{"$in": teams}})
+	}
+	return list(bson.M{"$and": []bson.M{
+		{"$or": []bson.M{
+			{"expires": bson.M{"$exists": false}},
+			{"expires": bson.M{"$gt": time.Now().UTC()}},
+		}},
+		{"$or": audience},
+	}})
+}
+
+func list(query bson.M) ([]Message, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var msgs []Message
+	err = conn.Motd().Find(query).Sort("-createdat", "-_id").All(&msgs)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motd
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAdd(c *check.C) {
+	msg := Message{Text: "maintenance at 10pm", Teams: []string{"ateam"}, Owner: "admin@tsuru.io"}
+	err := Add(&msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.ID, check.Not(check.Equals), "")
+	c.Assert(msg.Severity, check.Equals, SeverityInfo)
+	msgs, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].ID, check.Equals, msg.ID)
+	c.Assert(msgs[0].Text, check.Equals, "maintenance at 10pm")
+	c.Assert(msgs[0].Teams, check.DeepEquals, []string{"ateam"})
+	c.Assert(msgs[0].Expires.IsZero(), check.Equals, true)
+	c.Assert(msgs[0].Owner, check.Equals, "admin@tsuru.io")
+}
+
+func (s *S) TestAddInvalid(c *check.C) {
+	tests := []struct {
+		msg Message
+		err error
+	}{
+		{Message{}, ErrTextRequired},
+		{Message{Text: "hi", Severity: "panic"}, ErrInvalidSeverity},
+		{Message{Text: "hi", Expires: time.Now().Add(-time.Minute)}, ErrAlreadyExpired},
+	}
+	for _, tt := range tests {
+		c.Check(Add(&tt.msg), check.Equals, tt.err)
+	}
+	msgs, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 0)
+}
+
+func (s *S) TestRemove(c *check.C) {
+	msg := Message{Text: "hi"}
+	err := Add(&msg)
+	c.Assert(err, check.IsNil)
+	err = Remove(msg.ID.Hex())
+	c.Assert(err, check.IsNil)
+	err = Remove(msg.ID.Hex())
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+	err = Remove("invalid")
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+}
+
+func (s *S) TestListActive(c *check.C) {
+	everyone := Message{Text: "everyone", Severity: SeverityWarning}
+	team := Message{Text: "team", Teams: []string{"ateam", "bteam"}}
+	other := Message{Text: "other", Teams: []string{"cteam"}}
+	expiring := Message{Text: "expiring", Expires: time.Now().Add(time.Hour)}
+	for _, m := range []*Message{&everyone, &team, &other, &expiring} {
+		err := Add(m)
+		c.Assert(err, check.IsNil)
+	}
+	err := s.conn.Motd().Insert(Message{ID: bson.NewObjectId(), Text: "expired", Expires: time.Now().Add(-time.Hour)})
+	c.Assert(err, check.IsNil)
+	texts := func(msgs []Message) []string {
+		var result []string
+		for _, m := range msgs {
+			result = append(result, m.Text)
+		}
+		return result
+	}
+	msgs, err := ListActive([]string{"bteam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(texts(msgs), check.DeepEquals, []string{"expiring", "team", "everyone"})
+	msgs, err = ListActive(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(texts(msgs), check.DeepEquals, []string{"expiring", "everyone"})
+	msgs, err = List()
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 5)
+}
@@ -42,6 +42,34 @@ func Contexts(t contextType, values []string) []PermissionContext {
 	return contexts
 }
 
+// ParseContext parses a context in the "<type>:<value>" format, like
+// "team:myteam", or the "global" context, which has no value.
+func ParseContext(value string) (PermissionContext, error) {
+	parts := strings.SplitN(value, ":", 2)
+	ctxType, err := parseContext(parts[0])
+	if err != nil {
+		return PermissionContext{}, err
+	}
+	if ctxType == CtxGlobal {
+		if len(parts) > 1 {
+			return PermissionContext{}, errors.Errorf("invalid context %q, the global context has no value", value)
+		}
+		return Context(CtxGlobal, ""), nil
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return PermissionContext{}, errors.Errorf("invalid context %q, it must be in the <type>:<value> format", value)
+	}
+	return Context(ctxType, parts[1]), nil
+}
+
+// String returns the context in the format accepted by ParseContext.
+func (c PermissionContext) String() string {
+	if c.CtxType == CtxGlobal {
+		return string(c.CtxType)
+	}
+	return string(c.CtxType) + ":" + c.Value
+}
+
 type contextType string
 
 var (
@@ -53,9 +81,10 @@ var (
 	CtxIaaS            = contextType("iaas")
 	CtxService         = contextType("service")
 	CtxServiceInstance = contextType("service-instance")
+	CtxGroup           = contextType("group")
 
 	ContextTypes = []contextType{
-		CtxGlobal, CtxApp, CtxTeam, CtxPool, CtxIaaS, CtxService, CtxServiceInstance,
+		CtxGlobal, CtxApp, CtxTeam, CtxPool, CtxIaaS, CtxService, CtxServiceInstance, CtxGroup,
 	}
 )
 
@@ -64,6 +64,28 @@ func (t *userToken) Permissions() ([]Permission, error) {
 	return t.permissions, nil
 }
 
+func (s *S) TestParseContext(c *check.C) {
+	ctx, err := ParseContext("team:myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(ctx, check.DeepEquals, Context(CtxTeam, "myteam"))
+	c.Assert(ctx.String(), check.Equals, "team:myteam")
+	ctx, err = ParseContext("service-instance:mysql/db:1")
+	c.Assert(err, check.IsNil)
+	c.Assert(ctx, check.DeepEquals, Context(CtxServiceInstance, "mysql/db:1"))
+	ctx, err = ParseContext("global")
+	c.Assert(err, check.IsNil)
+	c.Assert(ctx, check.DeepEquals, Context(CtxGlobal, ""))
+	c.Assert(ctx.String(), check.Equals, "global")
+	_, err = ParseContext("global:x")
+	c.Assert(err, check.ErrorMatches, `invalid context "global:x", the global context has no value`)
+	_, err = ParseContext("team")
+	c.Assert(err, check.ErrorMatches, `invalid context "team", it must be in the <type>:<value> format`)
+	_, err = ParseContext("team:")
+	c.Assert(err, check.ErrorMatches, `invalid context "team:", it must be in the <type>:<value> format`)
+	_, err = ParseContext("planet:earth")
+	c.Assert(err, check.ErrorMatches, `invalid context type "planet"`)
+}
+
 func (s *S) TestCheck(c *check.C) {
 	t := &userToken{
 		permissions: []Permission{
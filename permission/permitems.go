@@ -8,190 +8,312 @@
 package permission
 
 var (
-	PermAll                              = PermissionRegistry.get("")                                    // [global]
-	PermApp                              = PermissionRegistry.get("app")                                 // [global app team pool]
-	PermAppAdmin                         = PermissionRegistry.get("app.admin")                           // [global app team pool]
-	PermAppAdminQuota                    = PermissionRegistry.get("app.admin.quota")                     // [global app team pool]
-	PermAppAdminRoutes                   = PermissionRegistry.get("app.admin.routes")                    // [global app team pool]
-	PermAppAdminUnlock                   = PermissionRegistry.get("app.admin.unlock")                    // [global app team pool]
-	PermAppCreate                        = PermissionRegistry.get("app.create")                          // [global team]
-	PermAppDelete                        = PermissionRegistry.get("app.delete")                          // [global app team pool]
-	PermAppDeploy                        = PermissionRegistry.get("app.deploy")                          // [global app team pool]
-	PermAppDeployArchiveUrl              = PermissionRegistry.get("app.deploy.archive-url")              // [global app team pool]
-	PermAppDeployBuild                   = PermissionRegistry.get("app.deploy.build")                    // [global app team pool]
-	PermAppDeployGit                     = PermissionRegistry.get("app.deploy.git")                      // [global app team pool]
-	PermAppDeployImage                   = PermissionRegistry.get("app.deploy.image")                    // [global app team pool]
-	PermAppDeployRollback                = PermissionRegistry.get("app.deploy.rollback")                 // [global app team pool]
-	PermAppDeployUpload                  = PermissionRegistry.get("app.deploy.upload")                   // [global app team pool]
-	PermAppRead                          = PermissionRegistry.get("app.read")                            // [global app team pool]
-	PermAppReadCertificate               = PermissionRegistry.get("app.read.certificate")                // [global app team pool]
-	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")                     // [global app team pool]
-	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")                        // [global app team pool]
-	PermAppReadEvents                    = PermissionRegistry.get("app.read.events")                     // [global app team pool]
-	PermAppReadLog                       = PermissionRegistry.get("app.read.log")                        // [global app team pool]
-	PermAppReadMetric                    = PermissionRegistry.get("app.read.metric")                     // [global app team pool]
-	PermAppRun                           = PermissionRegistry.get("app.run")                             // [global app team pool]
-	PermAppRunShell                      = PermissionRegistry.get("app.run.shell")                       // [global app team pool]
-	PermAppUpdate                        = PermissionRegistry.get("app.update")                          // [global app team pool]
-	PermAppUpdateBind                    = PermissionRegistry.get("app.update.bind")                     // [global app team pool]
-	PermAppUpdateCertificate             = PermissionRegistry.get("app.update.certificate")              // [global app team pool]
-	PermAppUpdateCertificateSet          = PermissionRegistry.get("app.update.certificate.set")          // [global app team pool]
-	PermAppUpdateCertificateUnset        = PermissionRegistry.get("app.update.certificate.unset")        // [global app team pool]
-	PermAppUpdateCname                   = PermissionRegistry.get("app.update.cname")                    // [global app team pool]
-	PermAppUpdateCnameAdd                = PermissionRegistry.get("app.update.cname.add")                // [global app team pool]
-	PermAppUpdateCnameRemove             = PermissionRegistry.get("app.update.cname.remove")             // [global app team pool]
-	PermAppUpdateDescription             = PermissionRegistry.get("app.update.description")              // [global app team pool]
-	PermAppUpdateEnv                     = PermissionRegistry.get("app.update.env")                      // [global app team pool]
-	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")                  // [global app team pool]
-	PermAppUpdateEnvUnset                = PermissionRegistry.get("app.update.env.unset")                // [global app team pool]
-	PermAppUpdateEvents                  = PermissionRegistry.get("app.update.events")                   // [global app team pool]
-	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")                    // [global app team pool]
-	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")                      // [global app team pool]
-	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")                     // [global app team pool]
-	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")                     // [global app team pool]
-	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")                  // [global app team pool]
-	PermAppUpdateRevoke                  = PermissionRegistry.get("app.update.revoke")                   // [global app team pool]
-	PermAppUpdateRouter                  = PermissionRegistry.get("app.update.router")                   // [global app team pool]
-	PermAppUpdateSleep                   = PermissionRegistry.get("app.update.sleep")                    // [global app team pool]
-	PermAppUpdateStart                   = PermissionRegistry.get("app.update.start")                    // [global app team pool]
-	PermAppUpdateStop                    = PermissionRegistry.get("app.update.stop")                     // [global app team pool]
-	PermAppUpdateSwap                    = PermissionRegistry.get("app.update.swap")                     // [global app team pool]
-	PermAppUpdateTags                    = PermissionRegistry.get("app.update.tags")                     // [global app team pool]
-	PermAppUpdateTeamowner               = PermissionRegistry.get("app.update.teamowner")                // [global app team pool]
-	PermAppUpdateUnbind                  = PermissionRegistry.get("app.update.unbind")                   // [global app team pool]
-	PermAppUpdateUnit                    = PermissionRegistry.get("app.update.unit")                     // [global app team pool]
-	PermAppUpdateUnitAdd                 = PermissionRegistry.get("app.update.unit.add")                 // [global app team pool]
-	PermAppUpdateUnitRegister            = PermissionRegistry.get("app.update.unit.register")            // [global app team pool]
-	PermAppUpdateUnitRemove              = PermissionRegistry.get("app.update.unit.remove")              // [global app team pool]
-	PermAppUpdateUnitStatus              = PermissionRegistry.get("app.update.unit.status")              // [global app team pool]
-	PermDebug                            = PermissionRegistry.get("debug")                               // [global]
-	PermEventBlock                       = PermissionRegistry.get("event-block")                         // [global]
-	PermEventBlockAdd                    = PermissionRegistry.get("event-block.add")                     // [global]
-	PermEventBlockRead                   = PermissionRegistry.get("event-block.read")                    // [global]
-	PermEventBlockReadEvents             = PermissionRegistry.get("event-block.read.events")             // [global]
-	PermEventBlockRemove                 = PermissionRegistry.get("event-block.remove")                  // [global]
-	PermHealing                          = PermissionRegistry.get("healing")                             // [global pool]
-	PermHealingDelete                    = PermissionRegistry.get("healing.delete")                      // [global pool]
-	PermHealingRead                      = PermissionRegistry.get("healing.read")                        // [global pool]
-	PermHealingUpdate                    = PermissionRegistry.get("healing.update")                      // [global pool]
-	PermInstall                          = PermissionRegistry.get("install")                             // [global]
-	PermInstallManage                    = PermissionRegistry.get("install.manage")                      // [global]
-	PermKubernetes                       = PermissionRegistry.get("kubernetes")                          // [global]
-	PermKubernetesCluster                = PermissionRegistry.get("kubernetes.cluster")                  // [global]
-	PermKubernetesClusterDelete          = PermissionRegistry.get("kubernetes.cluster.delete")           // [global]
-	PermKubernetesClusterRead            = PermissionRegistry.get("kubernetes.cluster.read")             // [global]
-	PermKubernetesClusterReadEvents      = PermissionRegistry.get("kubernetes.cluster.read.events")      // [global]
-	PermKubernetesClusterUpdate          = PermissionRegistry.get("kubernetes.cluster.update")           // [global]
-	PermMachine                          = PermissionRegistry.get("machine")                             // [global iaas]
-	PermMachineCreate                    = PermissionRegistry.get("machine.create")                      // [global iaas]
-	PermMachineDelete                    = PermissionRegistry.get("machine.delete")                      // [global iaas]
-	PermMachineRead                      = PermissionRegistry.get("machine.read")                        // [global iaas]
-	PermMachineReadEvents                = PermissionRegistry.get("machine.read.events")                 // [global iaas]
-	PermMachineTemplate                  = PermissionRegistry.get("machine.template")                    // [global iaas]
-	PermMachineTemplateCreate            = PermissionRegistry.get("machine.template.create")             // [global iaas]
-	PermMachineTemplateDelete            = PermissionRegistry.get("machine.template.delete")             // [global iaas]
-	PermMachineTemplateRead              = PermissionRegistry.get("machine.template.read")               // [global iaas]
-	PermMachineTemplateUpdate            = PermissionRegistry.get("machine.template.update")             // [global iaas]
-	PermNode                             = PermissionRegistry.get("node")                                // [global pool]
-	PermNodeAutoscale                    = PermissionRegistry.get("node.autoscale")                      // [global]
-	PermNodeAutoscaleDelete              = PermissionRegistry.get("node.autoscale.delete")               // [global]
-	PermNodeAutoscaleRead                = PermissionRegistry.get("node.autoscale.read")                 // [global]
-	PermNodeAutoscaleUpdate              = PermissionRegistry.get("node.autoscale.update")               // [global]
-	PermNodeAutoscaleUpdateRun           = PermissionRegistry.get("node.autoscale.update.run")           // [global]
-	PermNodeCreate                       = PermissionRegistry.get("node.create")                         // [global pool]
-	PermNodeDelete                       = PermissionRegistry.get("node.delete")                         // [global pool]
-	PermNodeRead                         = PermissionRegistry.get("node.read")                           // [global pool]
-	PermNodeUpdate                       = PermissionRegistry.get("node.update")                         // [global pool]
-	PermNodeUpdateMove                   = PermissionRegistry.get("node.update.move")                    // [global pool]
-	PermNodeUpdateMoveContainer          = PermissionRegistry.get("node.update.move.container")          // [global pool]
-	PermNodeUpdateMoveContainers         = PermissionRegistry.get("node.update.move.containers")         // [global pool]
-	PermNodeUpdateRebalance              = PermissionRegistry.get("node.update.rebalance")               // [global pool]
-	PermNodecontainer                    = PermissionRegistry.get("nodecontainer")                       // [global pool]
-	PermNodecontainerCreate              = PermissionRegistry.get("nodecontainer.create")                // [global pool]
-	PermNodecontainerDelete              = PermissionRegistry.get("nodecontainer.delete")                // [global pool]
-	PermNodecontainerRead                = PermissionRegistry.get("nodecontainer.read")                  // [global pool]
-	PermNodecontainerUpdate              = PermissionRegistry.get("nodecontainer.update")                // [global pool]
-	PermNodecontainerUpdateUpgrade       = PermissionRegistry.get("nodecontainer.update.upgrade")        // [global pool]
-	PermPlan                             = PermissionRegistry.get("plan")                                // [global]
-	PermPlanCreate                       = PermissionRegistry.get("plan.create")                         // [global]
-	PermPlanDelete                       = PermissionRegistry.get("plan.delete")                         // [global]
-	PermPlanRead                         = PermissionRegistry.get("plan.read")                           // [global]
-	PermPlanReadEvents                   = PermissionRegistry.get("plan.read.events")                    // [global]
-	PermPlatform                         = PermissionRegistry.get("platform")                            // [global]
-	PermPlatformCreate                   = PermissionRegistry.get("platform.create")                     // [global]
-	PermPlatformDelete                   = PermissionRegistry.get("platform.delete")                     // [global]
-	PermPlatformRead                     = PermissionRegistry.get("platform.read")                       // [global]
-	PermPlatformReadEvents               = PermissionRegistry.get("platform.read.events")                // [global]
-	PermPlatformUpdate                   = PermissionRegistry.get("platform.update")                     // [global]
-	PermPool                             = PermissionRegistry.get("pool")                                // [global pool]
-	PermPoolCreate                       = PermissionRegistry.get("pool.create")                         // [global]
-	PermPoolDelete                       = PermissionRegistry.get("pool.delete")                         // [global pool]
-	PermPoolRead                         = PermissionRegistry.get("pool.read")                           // [global pool]
-	PermPoolReadConstraints              = PermissionRegistry.get("pool.read.constraints")               // [global pool]
-	PermPoolReadEvents                   = PermissionRegistry.get("pool.read.events")                    // [global pool]
-	PermPoolUpdate                       = PermissionRegistry.get("pool.update")                         // [global pool]
-	PermPoolUpdateConstraints            = PermissionRegistry.get("pool.update.constraints")             // [global pool]
-	PermPoolUpdateConstraintsSet         = PermissionRegistry.get("pool.update.constraints.set")         // [global pool]
-	PermPoolUpdateLogs                   = PermissionRegistry.get("pool.update.logs")                    // [global pool]
-	PermPoolUpdateTeam                   = PermissionRegistry.get("pool.update.team")                    // [global pool]
-	PermPoolUpdateTeamAdd                = PermissionRegistry.get("pool.update.team.add")                // [global pool]
-	PermPoolUpdateTeamRemove             = PermissionRegistry.get("pool.update.team.remove")             // [global pool]
-	PermRole                             = PermissionRegistry.get("role")                                // [global]
-	PermRoleCreate                       = PermissionRegistry.get("role.create")                         // [global]
-	PermRoleDefault                      = PermissionRegistry.get("role.default")                        // [global]
-	PermRoleDefaultCreate                = PermissionRegistry.get("role.default.create")                 // [global]
-	PermRoleDefaultDelete                = PermissionRegistry.get("role.default.delete")                 // [global]
-	PermRoleDelete                       = PermissionRegistry.get("role.delete")                         // [global]
-	PermRoleRead                         = PermissionRegistry.get("role.read")                           // [global]
-	PermRoleReadEvents                   = PermissionRegistry.get("role.read.events")                    // [global]
-	PermRoleUpdate                       = PermissionRegistry.get("role.update")                         // [global]
-	PermRoleUpdateAssign                 = PermissionRegistry.get("role.update.assign")                  // [global]
-	PermRoleUpdateDissociate             = PermissionRegistry.get("role.update.dissociate")              // [global]
-	PermRoleUpdatePermission             = PermissionRegistry.get("role.update.permission")              // [global]
-	PermRoleUpdatePermissionAdd          = PermissionRegistry.get("role.update.permission.add")          // [global]
-	PermRoleUpdatePermissionRemove       = PermissionRegistry.get("role.update.permission.remove")       // [global]
-	PermService                          = PermissionRegistry.get("service")                             // [global service team]
-	PermServiceInstance                  = PermissionRegistry.get("service-instance")                    // [global service-instance team]
-	PermServiceInstanceCreate            = PermissionRegistry.get("service-instance.create")             // [global team]
-	PermServiceInstanceDelete            = PermissionRegistry.get("service-instance.delete")             // [global service-instance team]
-	PermServiceInstanceRead              = PermissionRegistry.get("service-instance.read")               // [global service-instance team]
-	PermServiceInstanceReadEvents        = PermissionRegistry.get("service-instance.read.events")        // [global service-instance team]
-	PermServiceInstanceReadStatus        = PermissionRegistry.get("service-instance.read.status")        // [global service-instance team]
-	PermServiceInstanceUpdate            = PermissionRegistry.get("service-instance.update")             // [global service-instance team]
-	PermServiceInstanceUpdateBind        = PermissionRegistry.get("service-instance.update.bind")        // [global service-instance team]
-	PermServiceInstanceUpdateDescription = PermissionRegistry.get("service-instance.update.description") // [global service-instance team]
-	PermServiceInstanceUpdateGrant       = PermissionRegistry.get("service-instance.update.grant")       // [global service-instance team]
-	PermServiceInstanceUpdateProxy       = PermissionRegistry.get("service-instance.update.proxy")       // [global service-instance team]
-	PermServiceInstanceUpdateRevoke      = PermissionRegistry.get("service-instance.update.revoke")      // [global service-instance team]
-	PermServiceInstanceUpdateTags        = PermissionRegistry.get("service-instance.update.tags")        // [global service-instance team]
-	PermServiceInstanceUpdateUnbind      = PermissionRegistry.get("service-instance.update.unbind")      // [global service-instance team]
-	PermServiceCreate                    = PermissionRegistry.get("service.create")                      // [global team]
-	PermServiceDelete                    = PermissionRegistry.get("service.delete")                      // [global service team]
-	PermServiceRead                      = PermissionRegistry.get("service.read")                        // [global service team]
-	PermServiceReadDoc                   = PermissionRegistry.get("service.read.doc")                    // [global service team]
-	PermServiceReadEvents                = PermissionRegistry.get("service.read.events")                 // [global service team]
-	PermServiceReadPlans                 = PermissionRegistry.get("service.read.plans")                  // [global service team]
-	PermServiceUpdate                    = PermissionRegistry.get("service.update")                      // [global service team]
-	PermServiceUpdateDoc                 = PermissionRegistry.get("service.update.doc")                  // [global service team]
-	PermServiceUpdateGrantAccess         = PermissionRegistry.get("service.update.grant-access")         // [global service team]
-	PermServiceUpdateProxy               = PermissionRegistry.get("service.update.proxy")                // [global service team]
-	PermServiceUpdateRevokeAccess        = PermissionRegistry.get("service.update.revoke-access")        // [global service team]
-	PermTeam                             = PermissionRegistry.get("team")                                // [global team]
-	PermTeamCreate                       = PermissionRegistry.get("team.create")                         // [global]
-	PermTeamDelete                       = PermissionRegistry.get("team.delete")                         // [global team]
-	PermTeamRead                         = PermissionRegistry.get("team.read")                           // [global team]
-	PermTeamReadEvents                   = PermissionRegistry.get("team.read.events")                    // [global team]
-	PermUser                             = PermissionRegistry.get("user")                                // [global user]
-	PermUserCreate                       = PermissionRegistry.get("user.create")                         // [global]
-	PermUserDelete                       = PermissionRegistry.get("user.delete")                         // [global user]
-	PermUserRead                         = PermissionRegistry.get("user.read")                           // [global user]
-	PermUserReadEvents                   = PermissionRegistry.get("user.read.events")                    // [global user]
-	PermUserUpdate                       = PermissionRegistry.get("user.update")                         // [global user]
-	PermUserUpdateKey                    = PermissionRegistry.get("user.update.key")                     // [global user]
-	PermUserUpdateKeyAdd                 = PermissionRegistry.get("user.update.key.add")                 // [global user]
-	PermUserUpdateKeyRemove              = PermissionRegistry.get("user.update.key.remove")              // [global user]
-	PermUserUpdatePassword               = PermissionRegistry.get("user.update.password")                // [global user]
-	PermUserUpdateQuota                  = PermissionRegistry.get("user.update.quota")                   // [global user]
-	PermUserUpdateReset                  = PermissionRegistry.get("user.update.reset")                   // [global user]
-	PermUserUpdateToken                  = PermissionRegistry.get("user.update.token")                   // [global user]
+	PermAll                               = PermissionRegistry.get("")                                      // [global]
+	PermApp                               = PermissionRegistry.get("app")                                   // [global app team pool]
+	PermAppAdmin                          = PermissionRegistry.get("app.admin")                             // [global app team pool]
+	PermAppAdminDeployApproval            = PermissionRegistry.get("app.admin.deploy-approval")             // [global app team pool]
+	PermAppAdminQuota                     = PermissionRegistry.get("app.admin.quota")                       // [global app team pool]
+	PermAppAdminRoutes                    = PermissionRegistry.get("app.admin.routes")                      // [global app team pool]
+	PermAppAdminSecrets                   = PermissionRegistry.get("app.admin.secrets")                     // [global app team pool]
+	PermAppAdminUnlock                    = PermissionRegistry.get("app.admin.unlock")                      // [global app team pool]
+	PermAppApprove                        = PermissionRegistry.get("app.approve")                           // [global app team pool]
+	PermAppApproveDeploy                  = PermissionRegistry.get("app.approve.deploy")                    // [global app team pool]
+	PermAppCreate                         = PermissionRegistry.get("app.create")                            // [global team]
+	PermAppCreateClone                    = PermissionRegistry.get("app.create.clone")                      // [global team]
+	PermAppDelete                         = PermissionRegistry.get("app.delete")                            // [global app team pool]
+	PermAppDeploy                         = PermissionRegistry.get("app.deploy")                            // [global app team pool]
+	PermAppDeployArchiveUrl               = PermissionRegistry.get("app.deploy.archive-url")                // [global app team pool]
+	PermAppDeployBuild                    = PermissionRegistry.get("app.deploy.build")                      // [global app team pool]
+	PermAppDeployGit                      = PermissionRegistry.get("app.deploy.git")                        // [global app team pool]
+	PermAppDeployImage                    = PermissionRegistry.get("app.deploy.image")                      // [global app team pool]
+	PermAppDeployResume                   = PermissionRegistry.get("app.deploy.resume")                     // [global app team pool]
+	PermAppDeployReviewApp                = PermissionRegistry.get("app.deploy.review-app")                 // [global app team pool]
+	PermAppDeployRollback                 = PermissionRegistry.get("app.deploy.rollback")                   // [global app team pool]
+	PermAppDeployUpload                   = PermissionRegistry.get("app.deploy.upload")                     // [global app team pool]
+	PermAppRead                           = PermissionRegistry.get("app.read")                              // [global app team pool]
+	PermAppReadCertificate                = PermissionRegistry.get("app.read.certificate")                  // [global app team pool]
+	PermAppReadDeploy                     = PermissionRegistry.get("app.read.deploy")                       // [global app team pool]
+	PermAppReadEnv                        = PermissionRegistry.get("app.read.env")                          // [global app team pool]
+	PermAppReadEvents                     = PermissionRegistry.get("app.read.events")                       // [global app team pool]
+	PermAppReadJob                        = PermissionRegistry.get("app.read.job")                          // [global app team pool]
+	PermAppReadLog                        = PermissionRegistry.get("app.read.log")                          // [global app team pool]
+	PermAppReadMetric                     = PermissionRegistry.get("app.read.metric")                       // [global app team pool]
+	PermAppRun                            = PermissionRegistry.get("app.run")                               // [global app team pool]
+	PermAppRunJob                         = PermissionRegistry.get("app.run.job")                           // [global app team pool]
+	PermAppRunShell                       = PermissionRegistry.get("app.run.shell")                         // [global app team pool]
+	PermAppUpdate                         = PermissionRegistry.get("app.update")                            // [global app team pool]
+	PermAppUpdateBind                     = PermissionRegistry.get("app.update.bind")                       // [global app team pool]
+	PermAppUpdateBuildCache               = PermissionRegistry.get("app.update.build-cache")                // [global app team pool]
+	PermAppUpdateBuildCacheClear          = PermissionRegistry.get("app.update.build-cache.clear")          // [global app team pool]
+	PermAppUpdateBuildCacheSet            = PermissionRegistry.get("app.update.build-cache.set")            // [global app team pool]
+	PermAppUpdateCertificate              = PermissionRegistry.get("app.update.certificate")                // [global app team pool]
+	PermAppUpdateCertificateSet           = PermissionRegistry.get("app.update.certificate.set")            // [global app team pool]
+	PermAppUpdateCertificateUnset         = PermissionRegistry.get("app.update.certificate.unset")          // [global app team pool]
+	PermAppUpdateCname                    = PermissionRegistry.get("app.update.cname")                      // [global app team pool]
+	PermAppUpdateCnameAdd                 = PermissionRegistry.get("app.update.cname.add")                  // [global app team pool]
+	PermAppUpdateCnameRemove              = PermissionRegistry.get("app.update.cname.remove")               // [global app team pool]
+	PermAppUpdateDependency               = PermissionRegistry.get("app.update.dependency")                 // [global app team pool]
+	PermAppUpdateDependencyAdd            = PermissionRegistry.get("app.update.dependency.add")             // [global app team pool]
+	PermAppUpdateDependencyRemove         = PermissionRegistry.get("app.update.dependency.remove")          // [global app team pool]
+	PermAppUpdateDescription              = PermissionRegistry.get("app.update.description")                // [global app team pool]
+	PermAppUpdateEnv                      = PermissionRegistry.get("app.update.env")                        // [global app team pool]
+	PermAppUpdateEnvRestore               = PermissionRegistry.get("app.update.env.restore")                // [global app team pool]
+	PermAppUpdateEnvSet                   = PermissionRegistry.get("app.update.env.set")                    // [global app team pool]
+	PermAppUpdateEnvUnset                 = PermissionRegistry.get("app.update.env.unset")                  // [global app team pool]
+	PermAppUpdateEvents                   = PermissionRegistry.get("app.update.events")                     // [global app team pool]
+	PermAppUpdateGrant                    = PermissionRegistry.get("app.update.grant")                      // [global app team pool]
+	PermAppUpdateHealthcheck              = PermissionRegistry.get("app.update.healthcheck")                // [global app team pool]
+	PermAppUpdateJob                      = PermissionRegistry.get("app.update.job")                        // [global app team pool]
+	PermAppUpdateJobCreate                = PermissionRegistry.get("app.update.job.create")                 // [global app team pool]
+	PermAppUpdateJobDelete                = PermissionRegistry.get("app.update.job.delete")                 // [global app team pool]
+	PermAppUpdateLabels                   = PermissionRegistry.get("app.update.labels")                     // [global app team pool]
+	PermAppUpdateLog                      = PermissionRegistry.get("app.update.log")                        // [global app team pool]
+	PermAppUpdateLogDrain                 = PermissionRegistry.get("app.update.log-drain")                  // [global app team pool]
+	PermAppUpdateLogDrainAdd              = PermissionRegistry.get("app.update.log-drain.add")              // [global app team pool]
+	PermAppUpdateLogDrainRemove           = PermissionRegistry.get("app.update.log-drain.remove")           // [global app team pool]
+	PermAppUpdateMaintenance              = PermissionRegistry.get("app.update.maintenance")                // [global app team pool]
+	PermAppUpdatePlan                     = PermissionRegistry.get("app.update.plan")                       // [global app team pool]
+	PermAppUpdatePool                     = PermissionRegistry.get("app.update.pool")                       // [global app team pool]
+	PermAppUpdateRatelimit                = PermissionRegistry.get("app.update.ratelimit")                  // [global app team pool]
+	PermAppUpdateRestart                  = PermissionRegistry.get("app.update.restart")                    // [global app team pool]
+	PermAppUpdateReviewApps               = PermissionRegistry.get("app.update.review-apps")                // [global app team pool]
+	PermAppUpdateRevoke                   = PermissionRegistry.get("app.update.revoke")                     // [global app team pool]
+	PermAppUpdateRouter                   = PermissionRegistry.get("app.update.router")                     // [global app team pool]
+	PermAppUpdateSleep                    = PermissionRegistry.get("app.update.sleep")                      // [global app team pool]
+	PermAppUpdateStart                    = PermissionRegistry.get("app.update.start")                      // [global app team pool]
+	PermAppUpdateStop                     = PermissionRegistry.get("app.update.stop")                       // [global app team pool]
+	PermAppUpdateSwap                     = PermissionRegistry.get("app.update.swap")                       // [global app team pool]
+	PermAppUpdateTags                     = PermissionRegistry.get("app.update.tags")                       // [global app team pool]
+	PermAppUpdateTeamowner                = PermissionRegistry.get("app.update.teamowner")                  // [global app team pool]
+	PermAppUpdateUnbind                   = PermissionRegistry.get("app.update.unbind")                     // [global app team pool]
+	PermAppUpdateUnit                     = PermissionRegistry.get("app.update.unit")                       // [global app team pool]
+	PermAppUpdateUnitAdd                  = PermissionRegistry.get("app.update.unit.add")                   // [global app team pool]
+	PermAppUpdateUnitRegister             = PermissionRegistry.get("app.update.unit.register")              // [global app team pool]
+	PermAppUpdateUnitRemove               = PermissionRegistry.get("app.update.unit.remove")                // [global app team pool]
+	PermAppUpdateUnitStatus               = PermissionRegistry.get("app.update.unit.status")                // [global app team pool]
+	PermDebug                             = PermissionRegistry.get("debug")                                 // [global]
+	PermEventBlock                        = PermissionRegistry.get("event-block")                           // [global]
+	PermEventBlockAdd                     = PermissionRegistry.get("event-block.add")                       // [global]
+	PermEventBlockRead                    = PermissionRegistry.get("event-block.read")                      // [global]
+	PermEventBlockReadEvents              = PermissionRegistry.get("event-block.read.events")               // [global]
+	PermEventBlockRemove                  = PermissionRegistry.get("event-block.remove")                    // [global]
+	PermEventChangeRate                   = PermissionRegistry.get("event-change-rate")                     // [global]
+	PermEventChangeRateBypass             = PermissionRegistry.get("event-change-rate.bypass")              // [global]
+	PermEventConsumer                     = PermissionRegistry.get("event-consumer")                        // [global]
+	PermEventConsumerConsume              = PermissionRegistry.get("event-consumer.consume")                // [global]
+	PermEventKindAlias                    = PermissionRegistry.get("event-kind-alias")                      // [global]
+	PermEventKindAliasRead                = PermissionRegistry.get("event-kind-alias.read")                 // [global]
+	PermEventKindAliasReadEvents          = PermissionRegistry.get("event-kind-alias.read.events")          // [global]
+	PermEventKindAliasRemove              = PermissionRegistry.get("event-kind-alias.remove")               // [global]
+	PermEventKindAliasSet                 = PermissionRegistry.get("event-kind-alias.set")                  // [global]
+	PermEventLegalHold                    = PermissionRegistry.get("event-legal-hold")                      // [global]
+	PermEventLegalHoldAdd                 = PermissionRegistry.get("event-legal-hold.add")                  // [global]
+	PermEventLegalHoldRead                = PermissionRegistry.get("event-legal-hold.read")                 // [global]
+	PermEventLegalHoldReadEvents          = PermissionRegistry.get("event-legal-hold.read.events")          // [global]
+	PermEventLegalHoldRelease             = PermissionRegistry.get("event-legal-hold.release")              // [global]
+	PermGroup                             = PermissionRegistry.get("group")                                 // [global group]
+	PermGroupCreate                       = PermissionRegistry.get("group.create")                          // [global]
+	PermGroupDelete                       = PermissionRegistry.get("group.delete")                          // [global group]
+	PermGroupRead                         = PermissionRegistry.get("group.read")                            // [global group]
+	PermGroupReadEvents                   = PermissionRegistry.get("group.read.events")                     // [global group]
+	PermGroupUpdate                       = PermissionRegistry.get("group.update")                          // [global group]
+	PermGroupUpdateMember                 = PermissionRegistry.get("group.update.member")                   // [global group]
+	PermGroupUpdateMemberAdd              = PermissionRegistry.get("group.update.member.add")               // [global group]
+	PermGroupUpdateMemberRemove           = PermissionRegistry.get("group.update.member.remove")            // [global group]
+	PermGroupUpdateRole                   = PermissionRegistry.get("group.update.role")                     // [global group]
+	PermGroupUpdateRoleAdd                = PermissionRegistry.get("group.update.role.add")                 // [global group]
+	PermGroupUpdateRoleRemove             = PermissionRegistry.get("group.update.role.remove")              // [global group]
+	PermHealing                           = PermissionRegistry.get("healing")                               // [global pool]
+	PermHealingDelete                     = PermissionRegistry.get("healing.delete")                        // [global pool]
+	PermHealingRead                       = PermissionRegistry.get("healing.read")                          // [global pool]
+	PermHealingUpdate                     = PermissionRegistry.get("healing.update")                        // [global pool]
+	PermInstall                           = PermissionRegistry.get("install")                               // [global]
+	PermInstallManage                     = PermissionRegistry.get("install.manage")                        // [global]
+	PermKubernetes                        = PermissionRegistry.get("kubernetes")                            // [global]
+	PermKubernetesCluster                 = PermissionRegistry.get("kubernetes.cluster")                    // [global]
+	PermKubernetesClusterDelete           = PermissionRegistry.get("kubernetes.cluster.delete")             // [global]
+	PermKubernetesClusterRead             = PermissionRegistry.get("kubernetes.cluster.read")               // [global]
+	PermKubernetesClusterReadEvents       = PermissionRegistry.get("kubernetes.cluster.read.events")        // [global]
+	PermKubernetesClusterUpdate           = PermissionRegistry.get("kubernetes.cluster.update")             // [global]
+	PermMachine                           = PermissionRegistry.get("machine")                               // [global iaas]
+	PermMachineCreate                     = PermissionRegistry.get("machine.create")                        // [global iaas]
+	PermMachineDelete                     = PermissionRegistry.get("machine.delete")                        // [global iaas]
+	PermMachineRead                       = PermissionRegistry.get("machine.read")                          // [global iaas]
+	PermMachineReadEvents                 = PermissionRegistry.get("machine.read.events")                   // [global iaas]
+	PermMachineTemplate                   = PermissionRegistry.get("machine.template")                      // [global iaas]
+	PermMachineTemplateCreate             = PermissionRegistry.get("machine.template.create")               // [global iaas]
+	PermMachineTemplateDelete             = PermissionRegistry.get("machine.template.delete")               // [global iaas]
+	PermMachineTemplateRead               = PermissionRegistry.get("machine.template.read")                 // [global iaas]
+	PermMachineTemplateUpdate             = PermissionRegistry.get("machine.template.update")               // [global iaas]
+	PermMigration                         = PermissionRegistry.get("migration")                             // [global]
+	PermMigrationRead                     = PermissionRegistry.get("migration.read")                        // [global]
+	PermMigrationReadEvents               = PermissionRegistry.get("migration.read.events")                 // [global]
+	PermMotd                              = PermissionRegistry.get("motd")                                  // [global]
+	PermMotdCreate                        = PermissionRegistry.get("motd.create")                           // [global]
+	PermMotdDelete                        = PermissionRegistry.get("motd.delete")                           // [global]
+	PermMotdRead                          = PermissionRegistry.get("motd.read")                             // [global]
+	PermNode                              = PermissionRegistry.get("node")                                  // [global pool]
+	PermNodeAutoscale                     = PermissionRegistry.get("node.autoscale")                        // [global]
+	PermNodeAutoscaleDelete               = PermissionRegistry.get("node.autoscale.delete")                 // [global]
+	PermNodeAutoscaleRead                 = PermissionRegistry.get("node.autoscale.read")                   // [global]
+	PermNodeAutoscaleUpdate               = PermissionRegistry.get("node.autoscale.update")                 // [global]
+	PermNodeAutoscaleUpdateRun            = PermissionRegistry.get("node.autoscale.update.run")             // [global]
+	PermNodeCreate                        = PermissionRegistry.get("node.create")                           // [global pool]
+	PermNodeDelete                        = PermissionRegistry.get("node.delete")                           // [global pool]
+	PermNodeRead                          = PermissionRegistry.get("node.read")                             // [global pool]
+	PermNodeUpdate                        = PermissionRegistry.get("node.update")                           // [global pool]
+	PermNodeUpdateCredentials             = PermissionRegistry.get("node.update.credentials")               // [global pool]
+	PermNodeUpdateMove                    = PermissionRegistry.get("node.update.move")                      // [global pool]
+	PermNodeUpdateMoveContainer           = PermissionRegistry.get("node.update.move.container")            // [global pool]
+	PermNodeUpdateMoveContainers          = PermissionRegistry.get("node.update.move.containers")           // [global pool]
+	PermNodeUpdateRebalance               = PermissionRegistry.get("node.update.rebalance")                 // [global pool]
+	PermNodecontainer                     = PermissionRegistry.get("nodecontainer")                         // [global pool]
+	PermNodecontainerCreate               = PermissionRegistry.get("nodecontainer.create")                  // [global pool]
+	PermNodecontainerDelete               = PermissionRegistry.get("nodecontainer.delete")                  // [global pool]
+	PermNodecontainerRead                 = PermissionRegistry.get("nodecontainer.read")                    // [global pool]
+	PermNodecontainerUpdate               = PermissionRegistry.get("nodecontainer.update")                  // [global pool]
+	PermNodecontainerUpdateUpgrade        = PermissionRegistry.get("nodecontainer.update.upgrade")          // [global pool]
+	PermPlan                              = PermissionRegistry.get("plan")                                  // [global]
+	PermPlanCreate                        = PermissionRegistry.get("plan.create")                           // [global]
+	PermPlanDelete                        = PermissionRegistry.get("plan.delete")                           // [global]
+	PermPlanRead                          = PermissionRegistry.get("plan.read")                             // [global]
+	PermPlanReadEvents                    = PermissionRegistry.get("plan.read.events")                      // [global]
+	PermPlatform                          = PermissionRegistry.get("platform")                              // [global]
+	PermPlatformCreate                    = PermissionRegistry.get("platform.create")                       // [global]
+	PermPlatformDelete                    = PermissionRegistry.get("platform.delete")                       // [global]
+	PermPlatformRead                      = PermissionRegistry.get("platform.read")                         // [global]
+	PermPlatformReadEvents                = PermissionRegistry.get("platform.read.events")                  // [global]
+	PermPlatformUpdate                    = PermissionRegistry.get("platform.update")                       // [global]
+	PermPool                              = PermissionRegistry.get("pool")                                  // [global pool]
+	PermPoolCreate                        = PermissionRegistry.get("pool.create")                           // [global]
+	PermPoolDelete                        = PermissionRegistry.get("pool.delete")                           // [global pool]
+	PermPoolRead                          = PermissionRegistry.get("pool.read")                             // [global pool]
+	PermPoolReadConstraints               = PermissionRegistry.get("pool.read.constraints")                 // [global pool]
+	PermPoolReadDefaults                  = PermissionRegistry.get("pool.read.defaults")                    // [global pool]
+	PermPoolReadEvents                    = PermissionRegistry.get("pool.read.events")                      // [global pool]
+	PermPoolReadLogDrain                  = PermissionRegistry.get("pool.read.log-drain")                   // [global pool]
+	PermPoolReadNodeRules                 = PermissionRegistry.get("pool.read.node-rules")                  // [global pool]
+	PermPoolReadQuota                     = PermissionRegistry.get("pool.read.quota")                       // [global pool]
+	PermPoolReadRegistry                  = PermissionRegistry.get("pool.read.registry")                    // [global pool]
+	PermPoolUpdate                        = PermissionRegistry.get("pool.update")                           // [global pool]
+	PermPoolUpdateConstraints             = PermissionRegistry.get("pool.update.constraints")               // [global pool]
+	PermPoolUpdateConstraintsSet          = PermissionRegistry.get("pool.update.constraints.set")           // [global pool]
+	PermPoolUpdateDefaults                = PermissionRegistry.get("pool.update.defaults")                  // [global pool]
+	PermPoolUpdateDefaultsSet             = PermissionRegistry.get("pool.update.defaults.set")              // [global pool]
+	PermPoolUpdateLogDrain                = PermissionRegistry.get("pool.update.log-drain")                 // [global pool]
+	PermPoolUpdateLogDrainAdd             = PermissionRegistry.get("pool.update.log-drain.add")             // [global pool]
+	PermPoolUpdateLogDrainRemove          = PermissionRegistry.get("pool.update.log-drain.remove")          // [global pool]
+	PermPoolUpdateLogs                    = PermissionRegistry.get("pool.update.logs")                      // [global pool]
+	PermPoolUpdateNodeRules               = PermissionRegistry.get("pool.update.node-rules")                // [global pool]
+	PermPoolUpdateNodeRulesRemove         = PermissionRegistry.get("pool.update.node-rules.remove")         // [global pool]
+	PermPoolUpdateNodeRulesSet            = PermissionRegistry.get("pool.update.node-rules.set")            // [global pool]
+	PermPoolUpdateQuota                   = PermissionRegistry.get("pool.update.quota")                     // [global pool]
+	PermPoolUpdateRegistry                = PermissionRegistry.get("pool.update.registry")                  // [global pool]
+	PermPoolUpdateRegistryRemove          = PermissionRegistry.get("pool.update.registry.remove")           // [global pool]
+	PermPoolUpdateRegistrySet             = PermissionRegistry.get("pool.update.registry.set")              // [global pool]
+	PermPoolUpdateTeam                    = PermissionRegistry.get("pool.update.team")                      // [global pool]
+	PermPoolUpdateTeamAdd                 = PermissionRegistry.get("pool.update.team.add")                  // [global pool]
+	PermPoolUpdateTeamRemove              = PermissionRegistry.get("pool.update.team.remove")               // [global pool]
+	PermReadonly                          = PermissionRegistry.get("readonly")                              // [global]
+	PermReadonlyDisable                   = PermissionRegistry.get("readonly.disable")                      // [global]
+	PermReadonlyEnable                    = PermissionRegistry.get("readonly.enable")                       // [global]
+	PermReadonlyRead                      = PermissionRegistry.get("readonly.read")                         // [global]
+	PermReadonlyReadEvents                = PermissionRegistry.get("readonly.read.events")                  // [global]
+	PermRole                              = PermissionRegistry.get("role")                                  // [global]
+	PermRoleCreate                        = PermissionRegistry.get("role.create")                           // [global]
+	PermRoleDefault                       = PermissionRegistry.get("role.default")                          // [global]
+	PermRoleDefaultCreate                 = PermissionRegistry.get("role.default.create")                   // [global]
+	PermRoleDefaultDelete                 = PermissionRegistry.get("role.default.delete")                   // [global]
+	PermRoleDelete                        = PermissionRegistry.get("role.delete")                           // [global]
+	PermRoleRead                          = PermissionRegistry.get("role.read")                             // [global]
+	PermRoleReadEvents                    = PermissionRegistry.get("role.read.events")                      // [global]
+	PermRoleUpdate                        = PermissionRegistry.get("role.update")                           // [global]
+	PermRoleUpdateAssign                  = PermissionRegistry.get("role.update.assign")                    // [global]
+	PermRoleUpdateDissociate              = PermissionRegistry.get("role.update.dissociate")                // [global]
+	PermRoleUpdatePermission              = PermissionRegistry.get("role.update.permission")                // [global]
+	PermRoleUpdatePermissionAdd           = PermissionRegistry.get("role.update.permission.add")            // [global]
+	PermRoleUpdatePermissionRemove        = PermissionRegistry.get("role.update.permission.remove")         // [global]
+	PermScim                              = PermissionRegistry.get("scim")                                  // [global]
+	PermScimGroups                        = PermissionRegistry.get("scim.groups")                           // [global]
+	PermScimUsers                         = PermissionRegistry.get("scim.users")                            // [global]
+	PermService                           = PermissionRegistry.get("service")                               // [global service team]
+	PermServiceInstance                   = PermissionRegistry.get("service-instance")                      // [global service-instance team]
+	PermServiceInstanceCreate             = PermissionRegistry.get("service-instance.create")               // [global team]
+	PermServiceInstanceDelete             = PermissionRegistry.get("service-instance.delete")               // [global service-instance team]
+	PermServiceInstanceRead               = PermissionRegistry.get("service-instance.read")                 // [global service-instance team]
+	PermServiceInstanceReadBackups        = PermissionRegistry.get("service-instance.read.backups")         // [global service-instance team]
+	PermServiceInstanceReadEvents         = PermissionRegistry.get("service-instance.read.events")          // [global service-instance team]
+	PermServiceInstanceReadStatus         = PermissionRegistry.get("service-instance.read.status")          // [global service-instance team]
+	PermServiceInstanceUpdate             = PermissionRegistry.get("service-instance.update")               // [global service-instance team]
+	PermServiceInstanceUpdateBackup       = PermissionRegistry.get("service-instance.update.backup")        // [global service-instance team]
+	PermServiceInstanceUpdateBackupPolicy = PermissionRegistry.get("service-instance.update.backup.policy") // [global service-instance team]
+	PermServiceInstanceUpdateBind         = PermissionRegistry.get("service-instance.update.bind")          // [global service-instance team]
+	PermServiceInstanceUpdateDescription  = PermissionRegistry.get("service-instance.update.description")   // [global service-instance team]
+	PermServiceInstanceUpdateGrant        = PermissionRegistry.get("service-instance.update.grant")         // [global service-instance team]
+	PermServiceInstanceUpdateProxy        = PermissionRegistry.get("service-instance.update.proxy")         // [global service-instance team]
+	PermServiceInstanceUpdateRestore      = PermissionRegistry.get("service-instance.update.restore")       // [global service-instance team]
+	PermServiceInstanceUpdateRevoke       = PermissionRegistry.get("service-instance.update.revoke")        // [global service-instance team]
+	PermServiceInstanceUpdateTags         = PermissionRegistry.get("service-instance.update.tags")          // [global service-instance team]
+	PermServiceInstanceUpdateUnbind       = PermissionRegistry.get("service-instance.update.unbind")        // [global service-instance team]
+	PermServiceCreate                     = PermissionRegistry.get("service.create")                        // [global team]
+	PermServiceDelete                     = PermissionRegistry.get("service.delete")                        // [global service team]
+	PermServiceRead                       = PermissionRegistry.get("service.read")                          // [global service team]
+	PermServiceReadDoc                    = PermissionRegistry.get("service.read.doc")                      // [global service team]
+	PermServiceReadEvents                 = PermissionRegistry.get("service.read.events")                   // [global service team]
+	PermServiceReadPlans                  = PermissionRegistry.get("service.read.plans")                    // [global service team]
+	PermServiceUpdate                     = PermissionRegistry.get("service.update")                        // [global service team]
+	PermServiceUpdateDoc                  = PermissionRegistry.get("service.update.doc")                    // [global service team]
+	PermServiceUpdateGrantAccess          = PermissionRegistry.get("service.update.grant-access")           // [global service team]
+	PermServiceUpdateProxy                = PermissionRegistry.get("service.update.proxy")                  // [global service team]
+	PermServiceUpdateRevokeAccess         = PermissionRegistry.get("service.update.revoke-access")          // [global service team]
+	PermStatusPage                        = PermissionRegistry.get("status-page")                           // [global]
+	PermStatusPageIncident                = PermissionRegistry.get("status-page.incident")                  // [global]
+	PermStatusPageIncidentAdd             = PermissionRegistry.get("status-page.incident.add")              // [global]
+	PermStatusPageIncidentResolve         = PermissionRegistry.get("status-page.incident.resolve")          // [global]
+	PermStatusPageRead                    = PermissionRegistry.get("status-page.read")                      // [global]
+	PermStatusPageReadEvents              = PermissionRegistry.get("status-page.read.events")               // [global]
+	PermStorageUsage                      = PermissionRegistry.get("storage-usage")                         // [global]
+	PermStorageUsageRead                  = PermissionRegistry.get("storage-usage.read")                    // [global]
+	PermStorageUsageReadEvents            = PermissionRegistry.get("storage-usage.read.events")             // [global]
+	PermTeam                              = PermissionRegistry.get("team")                                  // [global team]
+	PermTeamCreate                        = PermissionRegistry.get("team.create")                           // [global]
+	PermTeamDelete                        = PermissionRegistry.get("team.delete")                           // [global team]
+	PermTeamImpersonate                   = PermissionRegistry.get("team.impersonate")                      // [global team]
+	PermTeamRead                          = PermissionRegistry.get("team.read")                             // [global team]
+	PermTeamReadEvents                    = PermissionRegistry.get("team.read.events")                      // [global team]
+	PermTeamReadQuota                     = PermissionRegistry.get("team.read.quota")                       // [global team]
+	PermTeamReadRegistry                  = PermissionRegistry.get("team.read.registry")                    // [global team]
+	PermTeamUpdate                        = PermissionRegistry.get("team.update")                           // [global team]
+	PermTeamUpdateName                    = PermissionRegistry.get("team.update.name")                      // [global team]
+	PermTeamUpdateParent                  = PermissionRegistry.get("team.update.parent")                    // [global team]
+	PermTeamUpdateQuota                   = PermissionRegistry.get("team.update.quota")                     // [global team]
+	PermTeamUpdateRegistry                = PermissionRegistry.get("team.update.registry")                  // [global team]
+	PermTeamUpdateRegistryRemove          = PermissionRegistry.get("team.update.registry.remove")           // [global team]
+	PermTeamUpdateRegistrySet             = PermissionRegistry.get("team.update.registry.set")              // [global team]
+	PermUser                              = PermissionRegistry.get("user")                                  // [global user]
+	PermUserCreate                        = PermissionRegistry.get("user.create")                           // [global]
+	PermUserDelete                        = PermissionRegistry.get("user.delete")                           // [global user]
+	PermUserRead                          = PermissionRegistry.get("user.read")                             // [global user]
+	PermUserReadActivity                  = PermissionRegistry.get("user.read.activity")                    // [global user]
+	PermUserReadEvents                    = PermissionRegistry.get("user.read.events")                      // [global user]
+	PermUserReadSessions                  = PermissionRegistry.get("user.read.sessions")                    // [global user]
+	PermUserReadTokens                    = PermissionRegistry.get("user.read.tokens")                      // [global user]
+	PermUserUpdate                        = PermissionRegistry.get("user.update")                           // [global user]
+	PermUserUpdateActivate                = PermissionRegistry.get("user.update.activate")                  // [global user]
+	PermUserUpdateKey                     = PermissionRegistry.get("user.update.key")                       // [global user]
+	PermUserUpdateKeyAdd                  = PermissionRegistry.get("user.update.key.add")                   // [global user]
+	PermUserUpdateKeyRemove               = PermissionRegistry.get("user.update.key.remove")                // [global user]
+	PermUserUpdateNotification            = PermissionRegistry.get("user.update.notification")              // [global user]
+	PermUserUpdateNotificationAdd         = PermissionRegistry.get("user.update.notification.add")          // [global user]
+	PermUserUpdateNotificationRemove      = PermissionRegistry.get("user.update.notification.remove")       // [global user]
+	PermUserUpdatePassword                = PermissionRegistry.get("user.update.password")                  // [global user]
+	PermUserUpdateQuota                   = PermissionRegistry.get("user.update.quota")                     // [global user]
+	PermUserUpdateReset                   = PermissionRegistry.get("user.update.reset")                     // [global user]
+	PermUserUpdateSession                 = PermissionRegistry.get("user.update.session")                   // [global user]
+	PermUserUpdateSessionRevoke           = PermissionRegistry.get("user.update.session.revoke")            // [global user]
+	PermUserUpdateSuspend                 = PermissionRegistry.get("user.update.suspend")                   // [global user]
+	PermUserUpdateToken                   = PermissionRegistry.get("user.update.token")                     // [global user]
+	PermUserUpdateTokenCreate             = PermissionRegistry.get("user.update.token.create")              // [global user]
+	PermUserUpdateTokenRevoke             = PermissionRegistry.get("user.update.token.revoke")              // [global user]
+	PermUserUpdateUnlock                  = PermissionRegistry.get("user.update.unlock")                    // [global user]
 )
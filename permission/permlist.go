@@ -11,8 +11,10 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).addWithCtx(
 	"app.create", []contextType{CtxTeam},
 ).add(
+	"app.create.clone",
 	"app.update.description",
 	"app.update.tags",
+	"app.update.labels",
 	"app.update.log",
 	"app.update.pool",
 	"app.update.unit.add",
@@ -21,6 +23,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.unit.status",
 	"app.update.env.set",
 	"app.update.env.unset",
+	"app.update.env.restore",
 	"app.update.restart",
 	"app.update.sleep",
 	"app.update.start",
@@ -38,13 +41,28 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.unbind",
 	"app.update.certificate.set",
 	"app.update.certificate.unset",
+	"app.update.build-cache.set",
+	"app.update.build-cache.clear",
+	"app.update.ratelimit",
+	"app.update.job.create",
+	"app.update.job.delete",
+	"app.update.healthcheck",
+	"app.update.log-drain.add",
+	"app.update.log-drain.remove",
+	"app.update.dependency.add",
+	"app.update.dependency.remove",
+	"app.update.review-apps",
+	"app.update.maintenance",
 	"app.deploy",
 	"app.deploy.archive-url",
 	"app.deploy.build",
 	"app.deploy.git",
 	"app.deploy.image",
+	"app.deploy.resume",
 	"app.deploy.rollback",
 	"app.deploy.upload",
+	"app.deploy.review-app",
+	"app.approve.deploy",
 	"app.read",
 	"app.read.deploy",
 	"app.read.env",
@@ -52,12 +70,16 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.read.metric",
 	"app.read.log",
 	"app.read.certificate",
+	"app.read.job",
 	"app.delete",
 	"app.run",
 	"app.run.shell",
+	"app.run.job",
 	"app.admin.unlock",
 	"app.admin.routes",
 	"app.admin.quota",
+	"app.admin.secrets",
+	"app.admin.deploy-approval",
 ).addWithCtx(
 	"node", []contextType{CtxPool},
 ).add(
@@ -66,6 +88,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"node.update.move.container",
 	"node.update.move.containers",
 	"node.update.rebalance",
+	"node.update.credentials",
 	"node.delete",
 ).addWithCtx(
 	"node.autoscale", []contextType{},
@@ -92,6 +115,31 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"team.read.events",
 	"team.delete",
+	"team.impersonate",
+	"team.update.name",
+	"team.update.parent",
+	"team.update.registry.set",
+	"team.update.registry.remove",
+	"team.read.registry",
+	"team.read.quota",
+	"team.update.quota",
+).addWithCtx(
+	"group", []contextType{CtxGroup},
+).addWithCtx(
+	"group.create", []contextType{},
+).add(
+	"group.read",
+	"group.read.events",
+	"group.delete",
+	"group.update.member.add",
+	"group.update.member.remove",
+	"group.update.role.add",
+	"group.update.role.remove",
+).addWithCtx(
+	"scim", []contextType{},
+).add(
+	"scim.users",
+	"scim.groups",
 ).addWithCtx(
 	"user", []contextType{CtxUser},
 ).addWithCtx(
@@ -99,12 +147,23 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"user.delete",
 	"user.read.events",
+	"user.read.activity",
+	"user.read.tokens",
+	"user.read.sessions",
 	"user.update.token",
+	"user.update.token.create",
+	"user.update.token.revoke",
+	"user.update.session.revoke",
 	"user.update.quota",
 	"user.update.password",
 	"user.update.reset",
+	"user.update.suspend",
+	"user.update.activate",
+	"user.update.unlock",
 	"user.update.key.add",
 	"user.update.key.remove",
+	"user.update.notification.add",
+	"user.update.notification.remove",
 ).addWithCtx(
 	"service", []contextType{CtxService, CtxTeam},
 ).addWithCtx(
@@ -125,6 +184,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"service-instance.read.events",
 	"service-instance.read.status",
+	"service-instance.read.backups",
 	"service-instance.delete",
 	"service-instance.update.proxy",
 	"service-instance.update.bind",
@@ -133,6 +193,9 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"service-instance.update.revoke",
 	"service-instance.update.description",
 	"service-instance.update.tags",
+	"service-instance.update.backup",
+	"service-instance.update.backup.policy",
+	"service-instance.update.restore",
 ).add(
 	"role.create",
 	"role.delete",
@@ -162,7 +225,20 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"pool.update.team.remove",
 	"pool.update.constraints.set",
 	"pool.read.constraints",
+	"pool.update.node-rules.set",
+	"pool.update.node-rules.remove",
+	"pool.read.node-rules",
+	"pool.update.defaults.set",
+	"pool.read.defaults",
+	"pool.update.registry.set",
+	"pool.update.registry.remove",
+	"pool.read.registry",
+	"pool.read.quota",
+	"pool.update.quota",
 	"pool.update.logs",
+	"pool.update.log-drain.add",
+	"pool.update.log-drain.remove",
+	"pool.read.log-drain",
 	"pool.delete",
 ).add(
 	"debug",
@@ -184,11 +260,43 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"nodecontainer.delete",
 ).add(
 	"install.manage",
+).add(
+	"motd.read",
+	"motd.create",
+	"motd.delete",
 ).add(
 	"event-block.read",
 	"event-block.read.events",
 	"event-block.add",
 	"event-block.remove",
+).add(
+	"event-legal-hold.read",
+	"event-legal-hold.read.events",
+	"event-legal-hold.add",
+	"event-legal-hold.release",
+).add(
+	"event-kind-alias.read.events",
+	"event-kind-alias.set",
+	"event-kind-alias.remove",
+).add(
+	"readonly.read",
+	"readonly.read.events",
+	"readonly.enable",
+	"readonly.disable",
+).add(
+	"status-page.read",
+	"status-page.read.events",
+	"status-page.incident.add",
+	"status-page.incident.resolve",
+).add(
+	"storage-usage.read",
+	"storage-usage.read.events",
+).add(
+	"event-consumer.consume",
+).add(
+	"event-change-rate.bypass",
+).add(
+	"migration.read.events",
 ).add(
 	"kubernetes.cluster.read.events",
 	"kubernetes.cluster.update",
@@ -0,0 +1,113 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package permission
+
+import "gopkg.in/mgo.v2/bson"
+
+// RoleTemplate is a built-in role, created by SeedRoleTemplates so common
+// roles don't have to be assembled permission by permission.
+type RoleTemplate struct {
+	Name        string
+	ContextType contextType
+	Description string
+	SchemeNames []string
+}
+
+var RoleTemplates = []RoleTemplate{
+	{
+		Name:        "developer",
+		ContextType: CtxTeam,
+		Description: "creates, deploys and manages the apps of the team",
+		SchemeNames: []string{
+			"app.create",
+			"app.read",
+			"app.deploy",
+			"app.run",
+			"app.update.env.set",
+			"app.update.env.unset",
+			"app.update.restart",
+			"app.update.bind",
+			"app.update.unbind",
+			"service-instance.create",
+			"service-instance.read",
+			"service-instance.update.bind",
+			"service-instance.update.unbind",
+			"team.read",
+		},
+	},
+	{
+		Name:        "operator",
+		ContextType: CtxTeam,
+		Description: "manages every aspect of the apps and service instances of the team",
+		SchemeNames: []string{
+			"app",
+			"service-instance",
+			"team.read",
+		},
+	},
+	{
+		Name:        "auditor",
+		ContextType: CtxGlobal,
+		Description: "reads apps, teams, services and pools, without changing them",
+		SchemeNames: []string{
+			"app.read",
+			"team.read",
+			"service.read",
+			"service-instance.read",
+			"pool.read",
+			"role.read.events",
+		},
+	},
+}
+
+// SeedRoleTemplates creates the roles in RoleTemplates that don't exist yet,
+// returning the created ones. Existing roles are never changed, even when
+// they don't match the template.
+func SeedRoleTemplates() ([]Role, error) {
+	var created []Role
+	for _, tpl := range RoleTemplates {
+		role, err := NewRole(tpl.Name, string(tpl.ContextType), tpl.Description)
+		if err == ErrRoleAlreadyExists {
+			continue
+		}
+		if err != nil {
+			return created, err
+		}
+		err = role.AddPermissions(tpl.SchemeNames...)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, role)
+	}
+	return created, nil
+}
+
+// Clone creates a role named newName with the context and a snapshot of the
+// permissions of the role. Later changes to any of them don't affect the
+// other. The description of the role is used when description is empty,
+// role events are not copied.
+func (r *Role) Clone(newName, description string) (Role, error) {
+	if description == "" {
+		description = r.Description
+	}
+	clone, err := NewRole(newName, string(r.ContextType), description)
+	if err != nil {
+		return Role{}, err
+	}
+	if len(r.SchemeNames) == 0 {
+		return clone, nil
+	}
+	coll, err := rolesCollection()
+	if err != nil {
+		return Role{}, err
+	}
+	defer coll.Close()
+	clone.SchemeNames = append([]string(nil), r.SchemeNames...)
+	err = coll.UpdateId(clone.Name, bson.M{"$set": bson.M{"schemenames": clone.SchemeNames}})
+	if err != nil {
+		return Role{}, err
+	}
+	return clone, nil
+}
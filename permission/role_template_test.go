@@ -0,0 +1,84 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package permission
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestSeedRoleTemplates(c *check.C) {
+	created, err := SeedRoleTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.HasLen, len(RoleTemplates))
+	for _, tpl := range RoleTemplates {
+		role, err := FindRole(tpl.Name)
+		c.Assert(err, check.IsNil)
+		c.Assert(role.ContextType, check.Equals, tpl.ContextType)
+		c.Assert(role.Description, check.Equals, tpl.Description)
+		c.Assert(role.SchemeNames, check.HasLen, len(tpl.SchemeNames))
+	}
+	created, err = SeedRoleTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.HasLen, 0)
+}
+
+func (s *S) TestSeedRoleTemplatesKeepsExistingRoles(c *check.C) {
+	role, err := NewRole("developer", "app", "my own developer")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.read")
+	c.Assert(err, check.IsNil)
+	created, err := SeedRoleTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.HasLen, len(RoleTemplates)-1)
+	role, err = FindRole("developer")
+	c.Assert(err, check.IsNil)
+	c.Assert(role.ContextType, check.Equals, CtxApp)
+	c.Assert(role.Description, check.Equals, "my own developer")
+	c.Assert(role.SchemeNames, check.DeepEquals, []string{"app.read"})
+}
+
+func (s *S) TestRoleClone(c *check.C) {
+	role, err := NewRole("deployer", "team", "deploys apps")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy", "app.read")
+	c.Assert(err, check.IsNil)
+	err = role.AddEvent(RoleEventTeamCreate.String())
+	c.Assert(err, check.IsNil)
+	clone, err := role.Clone("deployer2", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.Name, check.Equals, "deployer2")
+	dbClone, err := FindRole("deployer2")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbClone.ContextType, check.Equals, CtxTeam)
+	c.Assert(dbClone.Description, check.Equals, "deploys apps")
+	c.Assert(dbClone.SchemeNames, check.DeepEquals, []string{"app.deploy", "app.read"})
+	c.Assert(dbClone.Events, check.HasLen, 0)
+	err = role.RemovePermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	dbClone, err = FindRole("deployer2")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbClone.SchemeNames, check.DeepEquals, []string{"app.deploy", "app.read"})
+}
+
+func (s *S) TestRoleCloneWithDescription(c *check.C) {
+	role, err := NewRole("deployer", "app", "deploys apps")
+	c.Assert(err, check.IsNil)
+	_, err = role.Clone("deployer2", "deploys other apps")
+	c.Assert(err, check.IsNil)
+	dbClone, err := FindRole("deployer2")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbClone.ContextType, check.Equals, CtxApp)
+	c.Assert(dbClone.Description, check.Equals, "deploys other apps")
+	c.Assert(dbClone.SchemeNames, check.HasLen, 0)
+}
+
+func (s *S) TestRoleCloneAlreadyExists(c *check.C) {
+	role, err := NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	_, err = NewRole("deployer2", "team", "")
+	c.Assert(err, check.IsNil)
+	_, err = role.Clone("deployer2", "")
+	c.Assert(err, check.Equals, ErrRoleAlreadyExists)
+	_, err = role.Clone("  ", "")
+	c.Assert(err, check.Equals, ErrInvalidRoleName)
+}
@@ -56,6 +56,8 @@ type changeUnitsPipelineArgs struct {
 	appDestroy  bool
 	exposedPort string
 	event       *event.Event
+	gate        *unitGate
+	strategy    provision.DeployStrategy
 }
 
 type callbackFunc func(*container.Container, chan *container.Container) error
@@ -331,6 +333,8 @@ var provisionAddUnitsToHost = action.Action{
 		if err := checkCanceled(args.event); err != nil {
 			return nil, err
 		}
+		args.event.StartPhase(provision.DeployPhaseUnitStart)
+		defer args.event.EndPhase(provision.DeployPhaseUnitStart)
 		containers, err := addContainersWithHost(&args)
 		if err != nil {
 			return nil, err
@@ -384,7 +388,9 @@ var bindAndHealthcheck = action.Action{
 			}
 		}
 		fmt.Fprintf(writer, "\n---- Binding and checking %d new %s ----\n", len(newContainers), pluralize("unit", len(newContainers)))
-		return newContainers, runInContainers(newContainers, func(c *container.Container, toRollback chan *container.Container) error {
+		args.event.StartPhase(provision.DeployPhaseHealthcheck)
+		defer args.event.EndPhase(provision.DeployPhaseHealthcheck)
+		err = runInContainers(newContainers, func(c *container.Container, toRollback chan *container.Container) error {
 			unit := c.AsUnit(args.app)
 			err := args.app.BindUnit(&unit)
 			if err != nil {
@@ -392,9 +398,16 @@ var bindAndHealthcheck = action.Action{
 			}
 			toRollback <- c
 			if doHealthcheck && c.ProcessName == webProcessName {
-				err = runHealthcheck(c, writer)
-				if err != nil {
-					return err
+				if args.gate != nil {
+					healthy, gateErr := args.gate.check(&args, c, writer)
+					if gateErr != nil || !healthy {
+						return gateErr
+					}
+				} else {
+					err = runAppHealthcheck(args.app, c, writer)
+					if err != nil {
+						return err
+					}
 				}
 			}
 			err = args.provisioner.runRestartAfterHooks(c, writer)
@@ -404,12 +417,25 @@ var bindAndHealthcheck = action.Action{
 			fmt.Fprintf(writer, " ---> Bound and checked unit %s [%s]\n", c.ShortID(), c.ProcessName)
 			return nil
 		}, func(c *container.Container) {
+			if args.gate != nil && args.gate.removed(c) {
+				return
+			}
 			unit := c.AsUnit(args.app)
 			err := args.app.UnbindUnit(&unit)
 			if err != nil {
 				log.Errorf("Unable to unbind unit %q: %s", c.ID, err)
 			}
 		}, true)
+		if err != nil || args.gate == nil {
+			return newContainers, err
+		}
+		gatedContainers := make([]container.Container, 0, len(newContainers))
+		for _, c := range newContainers {
+			if !args.gate.removed(&c) {
+				gatedContainers = append(gatedContainers, c)
+			}
+		}
+		return gatedContainers, nil
 	},
 	Backward: func(ctx action.BWContext) {
 		args := ctx.Params[0].(changeUnitsPipelineArgs)
@@ -457,11 +483,16 @@ var addNewRoutes = action.Action{
 		if len(newContainers) > 0 {
 			fmt.Fprintf(writer, "\n---- Adding routes to new units ----\n")
 		}
+		args.event.StartPhase(provision.DeployPhaseRouteUpdate)
+		defer args.event.EndPhase(provision.DeployPhaseRouteUpdate)
 		var routesToAdd []*url.URL
 		for i, c := range newContainers {
 			if c.ProcessName != webProcessName {
 				continue
 			}
+			if args.gate != nil && !args.gate.routable(&c) {
+				continue
+			}
 			if c.ValidAddr() {
 				routesToAdd = append(routesToAdd, c.Address())
 				newContainers[i].Routable = true
@@ -470,9 +501,22 @@ var addNewRoutes = action.Action{
 		if len(routesToAdd) == 0 {
 			return newContainers, nil
 		}
+		wRouter, weighted := r.(router.WeightedRouter)
+		weighted = weighted && !args.strategy.IsRolling()
+		if weighted {
+			// New units only receive requests as the traffic is shifted
+			// to them, following the deploy strategy.
+			err = wRouter.SetRoutesWeight(args.app.GetName(), routesToAdd, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
 		err = r.AddRoutes(args.app.GetName(), routesToAdd)
 		if err != nil {
 			r.RemoveRoutes(args.app.GetName(), routesToAdd)
+			if weighted {
+				wRouter.SetRoutesWeight(args.app.GetName(), nil, 0)
+			}
 			return nil, err
 		}
 		for _, c := range newContainers {
@@ -605,6 +649,8 @@ var removeOldRoutes = action.Action{
 		if len(args.toRemove) > 0 {
 			fmt.Fprintf(writer, "\n---- Removing routes from old units ----\n")
 		}
+		args.event.StartPhase(provision.DeployPhaseRouteUpdate)
+		defer args.event.EndPhase(provision.DeployPhaseRouteUpdate)
 		currentImageName, err := image.AppCurrentImageName(args.app.GetName())
 		if err != nil && err != image.ErrNoImagesAvailable {
 			return
@@ -782,8 +828,11 @@ var followLogsAndCommit = action.Action{
 				return nil, errors.Errorf("Exit status %d", result.status)
 			}
 		}
+		args.event.EndPhase(provision.DeployPhaseBuild)
 		fmt.Fprintf(args.writer, "\n---- Building application image ----\n")
+		args.event.StartPhase(provision.DeployPhaseImagePush)
 		imageId, err := c.Commit(args.provisioner, args.writer)
+		args.event.EndPhase(provision.DeployPhaseImagePush)
 		if err != nil {
 			log.Errorf("error on commit container %s - %s", c.ID, err)
 			return nil, err
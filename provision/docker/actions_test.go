@@ -1216,6 +1216,137 @@ func (s *S) TestBindAndHealthcheckForwardHealthcheckError(c *check.C) {
 	c.Assert(fakeApp.HasBind(&u2), check.Equals, false)
 }
 
+// gatedHealthcheckContainers adds two web containers to the app, the first
+// passing the healthcheck and the second failing it.
+func (s *S) gatedHealthcheckContainers(c *check.C, args *changeUnitsPipelineArgs) ([]container.Container, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":   "/x/y",
+			"status": http.StatusOK,
+		},
+		"processes": map[string]interface{}{
+			"web": "python start_app.py",
+		},
+	}
+	err := s.newFakeImage(s.p, args.imageId, customData)
+	c.Assert(err, check.IsNil)
+	containers, err := addContainersWithHost(args)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	for i, srv := range []*httptest.Server{server, failingServer} {
+		u, _ := url.Parse(srv.URL)
+		containers[i].HostAddr, containers[i].HostPort, _ = net.SplitHostPort(u.Host)
+	}
+	return containers, func() {
+		server.Close()
+		failingServer.Close()
+	}
+}
+
+func (s *S) TestBindAndHealthcheckForwardGatedRemove(c *check.C) {
+	fakeApp := provisiontest.NewFakeApp("myapp", "python", 0)
+	s.p.Provision(fakeApp)
+	defer s.p.Destroy(fakeApp)
+	gate, err := newUnitGate()
+	c.Assert(err, check.IsNil)
+	buf := safe.NewBuffer(nil)
+	args := changeUnitsPipelineArgs{
+		app:         fakeApp,
+		provisioner: s.p,
+		writer:      buf,
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2}},
+		imageId:     "tsuru/app-myapp",
+		gate:        gate,
+	}
+	containers, closeServers := s.gatedHealthcheckContainers(c, &args)
+	defer closeServers()
+	context := action.FWContext{Params: []interface{}{args}, Previous: containers}
+	result, err := bindAndHealthcheck.Forward(context)
+	c.Assert(err, check.IsNil)
+	resultContainers := result.([]container.Container)
+	c.Assert(resultContainers, check.HasLen, 1)
+	c.Assert(resultContainers[0].ID, check.Equals, containers[0].ID)
+	u1 := containers[0].AsUnit(fakeApp)
+	u2 := containers[1].AsUnit(fakeApp)
+	c.Assert(fakeApp.HasBind(&u1), check.Equals, true)
+	c.Assert(fakeApp.HasBind(&u2), check.Equals, false)
+	_, err = s.p.GetContainer(containers[1].ID)
+	c.Assert(err, check.NotNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*Unit .* \[web\] failed the healthcheck, removing it: healthcheck fail.*`)
+	results := gate.finish(resultContainers)
+	c.Assert(results, check.HasLen, 2)
+	for _, r := range results {
+		if r.ID == containers[0].ID {
+			c.Assert(r.Checked, check.Equals, true)
+			c.Assert(r.Healthy, check.Equals, true)
+			c.Assert(r.Removed, check.Equals, false)
+		} else {
+			c.Assert(r.ID, check.Equals, containers[1].ID)
+			c.Assert(r.Healthy, check.Equals, false)
+			c.Assert(r.Removed, check.Equals, true)
+			c.Assert(r.Error, check.Matches, `healthcheck fail\(.*?\): wrong status code, expected 200, got: 404`)
+		}
+	}
+}
+
+func (s *S) TestBindAndHealthcheckForwardGatedKeep(c *check.C) {
+	config.Set("docker:healthcheck:gating:failure-policy", "keep")
+	defer config.Unset("docker:healthcheck:gating")
+	fakeApp := provisiontest.NewFakeApp("myapp", "python", 0)
+	s.p.Provision(fakeApp)
+	defer s.p.Destroy(fakeApp)
+	routertest.FakeRouter.AddBackend(fakeApp.GetName())
+	defer routertest.FakeRouter.RemoveBackend(fakeApp.GetName())
+	gate, err := newUnitGate()
+	c.Assert(err, check.IsNil)
+	args := changeUnitsPipelineArgs{
+		app:         fakeApp,
+		provisioner: s.p,
+		writer:      safe.NewBuffer(nil),
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2}},
+		imageId:     "tsuru/app-myapp",
+		gate:        gate,
+	}
+	containers, closeServers := s.gatedHealthcheckContainers(c, &args)
+	defer closeServers()
+	context := action.FWContext{Params: []interface{}{args}, Previous: containers}
+	result, err := bindAndHealthcheck.Forward(context)
+	c.Assert(err, check.IsNil)
+	resultContainers := result.([]container.Container)
+	c.Assert(resultContainers, check.HasLen, 2)
+	c.Assert(gate.routable(&containers[0]), check.Equals, true)
+	c.Assert(gate.routable(&containers[1]), check.Equals, false)
+	u2 := containers[1].AsUnit(fakeApp)
+	c.Assert(fakeApp.HasBind(&u2), check.Equals, true)
+	context = action.FWContext{Params: []interface{}{args}, Previous: resultContainers}
+	result, err = addNewRoutes.Forward(context)
+	c.Assert(err, check.IsNil)
+	resultContainers = result.([]container.Container)
+	c.Assert(resultContainers[0].Routable, check.Equals, true)
+	c.Assert(resultContainers[1].Routable, check.Equals, false)
+	c.Assert(routertest.FakeRouter.HasRoute(fakeApp.GetName(), containers[0].Address().String()), check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasRoute(fakeApp.GetName(), containers[1].Address().String()), check.Equals, false)
+	results := gate.finish(resultContainers)
+	c.Assert(results, check.HasLen, 2)
+	for _, r := range results {
+		c.Assert(r.Removed, check.Equals, false)
+		c.Assert(r.Routed, check.Equals, r.ID == containers[0].ID)
+	}
+}
+
+func (s *S) TestNewUnitGateInvalidPolicy(c *check.C) {
+	config.Set("docker:healthcheck:gating:failure-policy", "ignore")
+	defer config.Unset("docker:healthcheck:gating")
+	_, err := newUnitGate()
+	c.Assert(err, check.ErrorMatches, `invalid healthcheck gating failure policy "ignore", valid policies are: remove, keep`)
+}
+
 func (s *S) TestBindAndHealthcheckForwardRestartError(c *check.C) {
 	s.server.CustomHandler("/exec/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -100,6 +100,12 @@ func (c *listHealingHistoryCmd) Run(ctx *cmd.Context, client *cmd.Client) error
 			return err
 		}
 	}
+	if ctx.Structured() {
+		if history == nil {
+			history = []types.HealingEvent{}
+		}
+		return ctx.Render(history)
+	}
 	if filter != "" {
 		renderHistoryTable(history, filter, ctx)
 	} else {
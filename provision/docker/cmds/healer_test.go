@@ -6,12 +6,14 @@ package cmds
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"github.com/tsuru/tsuru/provision/docker/types"
 	"gopkg.in/check.v1"
 )
 
@@ -150,6 +152,45 @@ Container:
 	c.Assert(buf.String(), check.Equals, expected)
 }
 
+func (s *S) TestListHealingHistoryCmdRunStructured(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf, Format: cmd.FormatJSON}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: healingJsonData, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/docker/healing"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	healing := &listHealingHistoryCmd{}
+	err := healing.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var history []types.HealingEvent
+	err = json.Unmarshal(buf.Bytes(), &history)
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 5)
+	c.Assert(history[0].FailingNode.Address, check.Equals, "addr1")
+	c.Assert(history[3].Error, check.Equals, "err1")
+}
+
+func (s *S) TestListHealingHistoryCmdRunStructuredEmpty(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf, Format: cmd.FormatYAML}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusNoContent},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/docker/healing"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	healing := &listHealingHistoryCmd{}
+	err := healing.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "[]\n")
+}
+
 func (s *S) TestListHealingHistoryCmdRunFilterNode(c *check.C) {
 	var buf bytes.Buffer
 	context := cmd.Context{Stdout: &buf}
@@ -5,6 +5,7 @@
 package container
 
 import (
+	"context"
 	"crypto"
 	"fmt"
 	"io"
@@ -127,7 +128,10 @@ func (c *Container) Create(args *CreateArgs) error {
 		User:         user,
 		Labels:       labelSet.ToLabels(),
 	}
-	c.addEnvsToConfig(args, strings.TrimSuffix(c.ExposedPort, "/tcp"), &conf)
+	err = c.addEnvsToConfig(args, strings.TrimSuffix(c.ExposedPort, "/tcp"), &conf)
+	if err != nil {
+		return err
+	}
 	opts := docker.CreateContainerOptions{Name: c.Name, Config: &conf, HostConfig: hostConf}
 	var nodeList []string
 	if len(args.DestinationHosts) > 0 {
@@ -157,8 +161,11 @@ func (c *Container) Create(args *CreateArgs) error {
 	return nil
 }
 
-func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) {
-	envs := provision.EnvsForApp(args.App, c.ProcessName, args.Deploy)
+func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) error {
+	envs, err := provision.EnvsForApp(args.App, c.ProcessName, args.Deploy)
+	if err != nil {
+		return err
+	}
 	for _, envData := range envs {
 		cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, envData.Value))
 	}
@@ -170,6 +177,7 @@ func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.C
 		}
 		cfg.Env = append(cfg.Env, fmt.Sprintf("TSURU_SHAREDFS_MOUNTPOINT=%s", sharedMount))
 	}
+	return nil
 }
 
 func (c *Container) user() string {
@@ -427,10 +435,49 @@ func (c *Container) Stop(p DockerProvisioner) error {
 	return nil
 }
 
+// StopContext stops the container like Stop, aborting the request to the
+// docker node when ctx is done.
+func (c *Container) StopContext(ctx context.Context, p DockerProvisioner) error {
+	if c.Status == provision.StatusStopped.String() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := p.ActionLimiter().Start(c.HostAddr)
+	client, err := c.nodeClient(p)
+	if err == nil {
+		err = client.StopContainerWithContext(c.ID, 10, ctx)
+	}
+	done()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		log.Errorf("error on stop container %s: %s", c.ID, err)
+	}
+	c.SetStatus(p, provision.StatusStopped, true)
+	return nil
+}
+
 type StartArgs struct {
 	Provisioner DockerProvisioner
 	App         provision.App
 	Deploy      bool
+	// Context aborts the request to the docker node when done, it's
+	// optional.
+	Context context.Context
+}
+
+// restartPolicy returns the docker restart policy of the units of the app.
+func restartPolicy(app provision.App) docker.RestartPolicy {
+	switch provision.RestartPolicyForApp(app) {
+	case provision.RestartPolicyOnFailure:
+		return docker.RestartOnFailure(0)
+	case provision.RestartPolicyNever:
+		return docker.NeverRestart()
+	}
+	return docker.AlwaysRestart()
 }
 
 func (c *Container) hostConfig(app provision.App, isDeploy bool) (*docker.HostConfig, error) {
@@ -438,14 +485,15 @@ func (c *Container) hostConfig(app provision.App, isDeploy bool) (*docker.HostCo
 	sharedMount, _ := config.GetString("docker:sharedfs:mountpoint")
 	sharedIsolation, _ := config.GetBool("docker:sharedfs:app-isolation")
 	sharedSalt, _ := config.GetString("docker:sharedfs:salt")
+	memory, swap, cpuShare := provision.ProcessResources(app, c.ProcessName)
 	hostConfig := docker.HostConfig{
-		CPUShares: int64(app.GetCpuShare()),
+		CPUShares: int64(cpuShare),
 	}
 
 	if !isDeploy {
-		hostConfig.Memory = app.GetMemory()
-		hostConfig.MemorySwap = app.GetMemory() + app.GetSwap()
-		hostConfig.RestartPolicy = docker.AlwaysRestart()
+		hostConfig.Memory = memory
+		hostConfig.MemorySwap = memory + swap
+		hostConfig.RestartPolicy = restartPolicy(app)
 		hostConfig.PortBindings = map[docker.Port][]docker.PortBinding{
 			docker.Port(c.ExposedPort): {{HostIP: "", HostPort: ""}},
 		}
@@ -482,8 +530,13 @@ func (c *Container) hostConfig(app provision.App, isDeploy bool) (*docker.HostCo
 }
 
 func (c *Container) Start(args *StartArgs) error {
+	var err error
 	done := args.Provisioner.ActionLimiter().Start(c.HostAddr)
-	err := args.Provisioner.Cluster().StartContainer(c.ID, nil)
+	if args.Context != nil {
+		err = c.startContext(args.Context, args.Provisioner)
+	} else {
+		err = args.Provisioner.Cluster().StartContainer(c.ID, nil)
+	}
 	done()
 	if err != nil {
 		return err
@@ -495,6 +548,26 @@ func (c *Container) Start(args *StartArgs) error {
 	return c.SetStatus(args.Provisioner, initialStatus, false)
 }
 
+func (c *Container) startContext(ctx context.Context, p DockerProvisioner) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client, err := c.nodeClient(p)
+	if err != nil {
+		return err
+	}
+	return client.StartContainerWithContext(c.ID, nil, ctx)
+}
+
+// nodeClient returns a client to the docker node running the container.
+func (c *Container) nodeClient(p DockerProvisioner) (*docker.Client, error) {
+	node, err := p.GetNodeByHost(c.HostAddr)
+	if err != nil {
+		return nil, err
+	}
+	return node.Client()
+}
+
 func (c *Container) Logs(p DockerProvisioner, w io.Writer) (int, error) {
 	container, err := p.Cluster().InspectContainer(c.ID)
 	if err != nil {
@@ -332,6 +332,15 @@ func (s *S) TestContainerCreateForDeploy(c *check.C) {
 	c.Assert(dockerContainer.HostConfig.OomScoreAdj, check.Equals, 1000)
 }
 
+func (s *S) TestContainerRestartPolicy(c *check.C) {
+	app := provisiontest.NewFakeApp("app-name", "brainfuck", 1)
+	c.Assert(restartPolicy(app), check.Equals, docker.AlwaysRestart())
+	app.Healthcheck = &provision.AppHealthcheck{RestartPolicy: provision.RestartPolicyOnFailure}
+	c.Assert(restartPolicy(app), check.Equals, docker.RestartOnFailure(0))
+	app.Healthcheck = &provision.AppHealthcheck{RestartPolicy: provision.RestartPolicyNever}
+	c.Assert(restartPolicy(app), check.Equals, docker.NeverRestart())
+}
+
 func (s *S) TestContainerCreateDoesNotSetEnvs(c *check.C) {
 	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := docker.Image{
@@ -120,7 +120,7 @@ func (p *dockerProvisioner) runReplaceUnitsPipeline(w io.Writer, a provision.App
 	return pipeline.Result().([]container.Container), nil
 }
 
-func (p *dockerProvisioner) runCreateUnitsPipeline(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, imageId, exposedPort string) ([]container.Container, error) {
+func (p *dockerProvisioner) runCreateUnitsPipeline(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, imageId, exposedPort string, gate *unitGate) ([]container.Container, error) {
 	if w == nil {
 		w = ioutil.Discard
 	}
@@ -133,6 +133,7 @@ func (p *dockerProvisioner) runCreateUnitsPipeline(w io.Writer, a provision.App,
 		provisioner: p,
 		exposedPort: exposedPort,
 		event:       evt,
+		gate:        gate,
 	}
 	pipeline := action.NewPipeline(
 		&provisionAddUnitsToHost,
@@ -95,6 +95,8 @@ func (p *dockerProvisioner) deployPipeline(app provision.App, imageId string, co
 		provisioner:   p,
 		event:         evt,
 	}
+	evt.StartPhase(provision.DeployPhaseBuild)
+	defer evt.EndPhase(provision.DeployPhaseBuild)
 	err = pipeline.Execute(args)
 	if err != nil {
 		log.Errorf("error on execute deploy pipeline for app %s - %s", app.GetName(), err)
@@ -171,6 +173,30 @@ func (p *dockerProvisioner) PushImage(name, tag string) error {
 	return nil
 }
 
+// registryAuthForApp returns the credentials used to pull or push images of
+// the app from the registry server. Credentials registered for the pool or
+// the team of the app take precedence over the ones of the tsuru registry in
+// the configuration file.
+func (p *dockerProvisioner) registryAuthForApp(app provision.App, server string) (docker.AuthConfiguration, *provision.RegistryCredential, error) {
+	cred, err := provision.RegistryCredentialForApp(app, server)
+	if err != nil {
+		return docker.AuthConfiguration{}, nil, err
+	}
+	if cred != nil {
+		return docker.AuthConfiguration{
+			Username:      cred.Username,
+			Password:      cred.Password,
+			Email:         cred.Email,
+			ServerAddress: cred.Server,
+		}, cred, nil
+	}
+	authConfig := p.RegistryAuthConfig()
+	if server == "" || server != authConfig.ServerAddress {
+		return docker.AuthConfiguration{}, nil, nil
+	}
+	return authConfig, nil, nil
+}
+
 func (p *dockerProvisioner) RegistryAuthConfig() docker.AuthConfiguration {
 	var authConfig docker.AuthConfiguration
 	authConfig.Email, _ = config.GetString("docker:registry-auth:email")
@@ -214,6 +214,20 @@ func (s *S) TestGetImageAppWhenDeployIsMultipleOf10(c *check.C) {
 	c.Assert(img, check.Equals, fmt.Sprintf("%s/%s:latest", repoNamespace, app.Platform))
 }
 
+func (s *S) TestGetImageWhenBuildCacheDisabled(c *check.C) {
+	app := provisiontest.NewFakeApp("myapp", "python", 1)
+	app.Deploys = 3
+	err := image.AppendAppImageName(app.GetName(), "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	img := image.GetBuildImage(app)
+	c.Assert(img, check.Equals, "tsuru/app-myapp:v1")
+	app.BuildCacheDisabled = true
+	img = image.GetBuildImage(app)
+	repoNamespace, err := config.GetString("docker:repository-namespace")
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, fmt.Sprintf("%s/python:latest", repoNamespace))
+}
+
 func (s *S) TestGetImageWithRegistry(c *check.C) {
 	config.Set("docker:registry", "localhost:3030")
 	defer config.Unset("docker:registry")
@@ -439,6 +453,38 @@ func (s *S) TestPushImageAuth(c *check.C) {
 	c.Assert(providedAuth.Password, check.Equals, "mypassword")
 }
 
+func (s *S) TestRegistryAuthForApp(c *check.C) {
+	config.Set("docker:registry", "localhost:3030")
+	config.Set("docker:registry-auth:username", "myuser")
+	config.Set("docker:registry-auth:password", "mypassword")
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("docker:registry")
+	defer config.Unset("docker:registry-auth")
+	defer config.Unset("registry-credentials")
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	a.TeamOwner = s.team.Name
+	authConfig, cred, err := s.p.registryAuthForApp(a, "localhost:3030")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred, check.IsNil)
+	c.Assert(authConfig.Username, check.Equals, "myuser")
+	authConfig, cred, err = s.p.registryAuthForApp(a, "private.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred, check.IsNil)
+	c.Assert(authConfig, check.DeepEquals, docker.AuthConfiguration{})
+	err = provision.SetRegistryCredential(&provision.RegistryCredential{
+		Server: "private.io", Team: s.team.Name, Username: "teamuser", Password: "teampass",
+	})
+	c.Assert(err, check.IsNil)
+	authConfig, cred, err = s.p.registryAuthForApp(a, "private.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred, check.NotNil)
+	c.Assert(authConfig, check.DeepEquals, docker.AuthConfiguration{
+		Username:      "teamuser",
+		Password:      "teampass",
+		ServerAddress: "private.io",
+	})
+}
+
 func (s *S) TestPushImageNoRegistry(c *check.C) {
 	var request *http.Request
 	server, err := testing.NewServer("127.0.0.1:0", nil, func(r *http.Request) {
@@ -0,0 +1,133 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+)
+
+const (
+	gateFailureRemove = "remove"
+	gateFailureKeep   = "keep"
+)
+
+// unitGate holds the results of gating the traffic to units added to an app
+// on their healthcheck. Units failing the healthcheck are removed or kept
+// without routes, according to the failure policy.
+type unitGate struct {
+	gracePeriod   time.Duration
+	failurePolicy string
+	mu            sync.Mutex
+	results       map[string]*provision.UnitGateResult
+}
+
+func newUnitGate() (*unitGate, error) {
+	gate := unitGate{
+		failurePolicy: gateFailureRemove,
+		results:       map[string]*provision.UnitGateResult{},
+	}
+	if seconds, _ := config.GetFloat("docker:healthcheck:gating:grace-period"); seconds > 0 {
+		gate.gracePeriod = time.Duration(seconds * float64(time.Second))
+	}
+	if policy, _ := config.GetString("docker:healthcheck:gating:failure-policy"); policy != "" {
+		if policy != gateFailureRemove && policy != gateFailureKeep {
+			return nil, errors.Errorf("invalid healthcheck gating failure policy %q, valid policies are: %s, %s", policy, gateFailureRemove, gateFailureKeep)
+		}
+		gate.failurePolicy = policy
+	}
+	return &gate, nil
+}
+
+func (g *unitGate) result(c *container.Container) *provision.UnitGateResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := g.results[c.ID]
+	if r == nil {
+		r = &provision.UnitGateResult{ID: c.ID, Process: c.ProcessName}
+		g.results[c.ID] = r
+	}
+	return r
+}
+
+// check runs the healthcheck of the container after the grace period. A
+// failed healthcheck is recorded and handled according to the failure policy
+// instead of being returned, the returned error is only set if removing the
+// unit failed.
+func (g *unitGate) check(args *changeUnitsPipelineArgs, c *container.Container, w io.Writer) (healthy bool, err error) {
+	if g.gracePeriod > 0 {
+		fmt.Fprintf(w, " ---> Waiting %s before checking unit %s\n", g.gracePeriod, c.ShortID())
+		time.Sleep(g.gracePeriod)
+	}
+	hcErr := runAppHealthcheck(args.app, c, w)
+	r := g.result(c)
+	g.mu.Lock()
+	r.Checked = true
+	r.Healthy = hcErr == nil
+	if hcErr != nil {
+		r.Error = hcErr.Error()
+	}
+	g.mu.Unlock()
+	if hcErr == nil {
+		return true, nil
+	}
+	if g.failurePolicy == gateFailureKeep {
+		fmt.Fprintf(w, " ---> Unit %s [%s] failed the healthcheck, keeping it without routes: %s\n", c.ShortID(), c.ProcessName, hcErr)
+		return false, nil
+	}
+	fmt.Fprintf(w, " ---> Unit %s [%s] failed the healthcheck, removing it: %s\n", c.ShortID(), c.ProcessName, hcErr)
+	unit := c.AsUnit(args.app)
+	err = args.app.UnbindUnit(&unit)
+	if err != nil {
+		log.Errorf("Unable to unbind unit %q: %s", c.ID, err)
+	}
+	err = c.Remove(args.provisioner)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to remove unit %s after failed healthcheck", c.ShortID())
+	}
+	g.mu.Lock()
+	r.Removed = true
+	g.mu.Unlock()
+	return false, nil
+}
+
+func (g *unitGate) routable(c *container.Container) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := g.results[c.ID]
+	return r == nil || !r.Checked || r.Healthy
+}
+
+func (g *unitGate) removed(c *container.Container) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := g.results[c.ID]
+	return r != nil && r.Removed
+}
+
+// finish returns the results of all units, marking the ones routed by the
+// pipeline.
+func (g *unitGate) finish(containers []container.Container) []provision.UnitGateResult {
+	for i := range containers {
+		g.result(&containers[i]).Routed = containers[i].Routable
+	}
+	results := make([]provision.UnitGateResult, 0, len(g.results))
+	for _, r := range g.results {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
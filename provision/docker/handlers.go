@@ -5,6 +5,7 @@
 package docker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -319,12 +320,12 @@ func logsConfigSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 		if pool != "" {
 			filter.Pools = []string{pool}
 		}
-		return tryRestartAppsByFilter(filter, writer)
+		return tryRestartAppsByFilter(r.Context(), filter, writer)
 	}
 	return nil
 }
 
-func tryRestartAppsByFilter(filter *app.Filter, writer io.Writer) error {
+func tryRestartAppsByFilter(ctx context.Context, filter *app.Filter, writer io.Writer) error {
 	apps, err := app.List(filter)
 	if err != nil {
 		return err
@@ -344,7 +345,7 @@ func tryRestartAppsByFilter(filter *app.Filter, writer io.Writer) error {
 		go func(i int) {
 			defer wg.Done()
 			a := apps[i]
-			err := a.Restart("", writer)
+			err := a.Restart(ctx, "", writer)
 			if err != nil {
 				fmt.Fprintf(writer, "Error: unable to restart %s: %s\n", a.Name, err.Error())
 			} else {
@@ -17,19 +17,60 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 )
 
+// healthcheckOpts are the parameters of the healthcheck of a unit.
+type healthcheckOpts struct {
+	path            string
+	method          string
+	match           string
+	status          int
+	allowedFailures int
+	interval        time.Duration
+	timeout         time.Duration
+}
+
+// runAppHealthcheck runs the healthcheck configured in the app, falling back
+// to the healthcheck in the tsuru.yaml of the unit image when the app has
+// none.
+func runAppHealthcheck(a provision.App, cont *container.Container, w io.Writer) error {
+	hc := provision.HealthcheckForApp(a)
+	if hc == nil || hc.Path == "" {
+		return runHealthcheck(cont, w)
+	}
+	opts := healthcheckOpts{
+		path:     hc.Path,
+		interval: time.Duration(hc.Interval) * time.Second,
+		timeout:  time.Duration(hc.Timeout) * time.Second,
+	}
+	if hc.FailureThreshold > 0 {
+		opts.allowedFailures = hc.FailureThreshold - 1
+	}
+	return checkHealth(cont, opts, w)
+}
+
 func runHealthcheck(cont *container.Container, w io.Writer) error {
 	yamlData, err := image.GetImageTsuruYamlData(cont.Image)
 	if err != nil {
 		return err
 	}
-	path := yamlData.Healthcheck.Path
-	method := yamlData.Healthcheck.Method
-	match := yamlData.Healthcheck.Match
-	status := yamlData.Healthcheck.Status
-	allowedFailures := yamlData.Healthcheck.AllowedFailures
+	return checkHealth(cont, healthcheckOpts{
+		path:            yamlData.Healthcheck.Path,
+		method:          yamlData.Healthcheck.Method,
+		match:           yamlData.Healthcheck.Match,
+		status:          yamlData.Healthcheck.Status,
+		allowedFailures: yamlData.Healthcheck.AllowedFailures,
+	}, w)
+}
+
+func checkHealth(cont *container.Container, opts healthcheckOpts, w io.Writer) error {
+	path := opts.path
+	method := opts.method
+	match := opts.match
+	status := opts.status
+	allowedFailures := opts.allowedFailures
 	if path == "" {
 		return nil
 	}
@@ -44,6 +85,7 @@ func runHealthcheck(cont *container.Container, w io.Writer) error {
 	var matchRE *regexp.Regexp
 	if match != "" {
 		match = "(?s)" + match
+		var err error
 		matchRE, err = regexp.Compile(match)
 		if err != nil {
 			return err
@@ -55,6 +97,13 @@ func runHealthcheck(cont *container.Container, w io.Writer) error {
 	}
 	maxWaitTime = maxWaitTime * int(time.Second)
 	sleepTime := 3 * time.Second
+	if opts.interval > 0 {
+		sleepTime = opts.interval
+	}
+	client := net.Dial5Full60ClientNoKeepAlive
+	if opts.timeout > 0 {
+		client = &http.Client{Transport: client.Transport, Timeout: opts.timeout}
+	}
 	startedTime := time.Now()
 	url := fmt.Sprintf("http://%s:%s/%s", cont.HostAddr, cont.HostPort, path)
 	for {
@@ -63,7 +112,7 @@ func runHealthcheck(cont *container.Container, w io.Writer) error {
 		if err != nil {
 			return err
 		}
-		rsp, err := net.Dial5Full60ClientNoKeepAlive.Do(req)
+		rsp, err := client.Do(req)
 		if err != nil {
 			lastError = errors.Wrapf(err, "healthcheck fail(%s)", cont.ShortID())
 		} else {
@@ -16,8 +16,10 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"github.com/tsuru/tsuru/provision/docker/types"
+	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -299,3 +301,98 @@ func (s *S) TestHealthcheckSuccessfulWithAllowedFailures(c *check.C) {
 	c.Assert(requests[2].Method, check.Equals, "GET")
 	c.Assert(requests[2].URL.Path, check.Equals, "/x/y")
 }
+
+func (s *S) TestAppHealthcheck(c *check.C) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if len(requests) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	imageName := "tsuru/app"
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":   "/x/y",
+			"method": "Post",
+		},
+	}
+	err := image.SaveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp1", "python", 0)
+	a.Healthcheck = &provision.AppHealthcheck{Path: "/status", Interval: 1, FailureThreshold: 3}
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	cont := container.Container{Container: types.Container{AppName: a.GetName(), HostAddr: host, HostPort: port, Image: imageName}}
+	buf := bytes.Buffer{}
+	err = runAppHealthcheck(a, &cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(requests, check.HasLen, 3)
+	c.Assert(requests[2].URL.Path, check.Equals, "/status")
+	c.Assert(requests[2].Method, check.Equals, "GET")
+	c.Assert(buf.String(), check.Equals, " ---> healthcheck fail(): wrong status code, expected 200, got: 500. Trying again in 1s\n"+
+		" ---> healthcheck fail(): wrong status code, expected 200, got: 500. Trying again in 1s\n"+
+		" ---> healthcheck successful()\n")
+}
+
+func (s *S) TestAppHealthcheckFailureThreshold(c *check.C) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	a := provisiontest.NewFakeApp("myapp1", "python", 0)
+	a.Healthcheck = &provision.AppHealthcheck{Path: "/status", Interval: 1, FailureThreshold: 2}
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	cont := container.Container{Container: types.Container{AppName: a.GetName(), HostAddr: host, HostPort: port, Image: "tsuru/app"}}
+	err := runAppHealthcheck(a, &cont, new(bytes.Buffer))
+	c.Assert(err, check.ErrorMatches, "healthcheck fail\\(\\): wrong status code, expected 200, got: 500")
+	c.Assert(requests, check.HasLen, 2)
+}
+
+func (s *S) TestAppHealthcheckTimeout(c *check.C) {
+	config.Set("docker:healthcheck:max-time", 1)
+	defer config.Unset("docker:healthcheck:max-time")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer server.Close()
+	a := provisiontest.NewFakeApp("myapp1", "python", 0)
+	a.Healthcheck = &provision.AppHealthcheck{Path: "/status", Timeout: 1}
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	cont := container.Container{Container: types.Container{AppName: a.GetName(), HostAddr: host, HostPort: port, Image: "tsuru/app"}}
+	err := runAppHealthcheck(a, &cont, new(bytes.Buffer))
+	c.Assert(err, check.ErrorMatches, "healthcheck fail\\(\\): .*Client.Timeout exceeded.*")
+}
+
+func (s *S) TestAppHealthcheckWithoutAppHealthcheck(c *check.C) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	imageName := "tsuru/app"
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path": "/x/y",
+		},
+	}
+	err := image.SaveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp1", "python", 0)
+	a.Healthcheck = &provision.AppHealthcheck{RestartPolicy: provision.RestartPolicyNever}
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	cont := container.Container{Container: types.Container{AppName: a.GetName(), HostAddr: host, HostPort: port, Image: imageName}}
+	err = runAppHealthcheck(a, &cont, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	c.Assert(requests, check.HasLen, 1)
+	c.Assert(requests[0].URL.Path, check.Equals, "/x/y")
+}
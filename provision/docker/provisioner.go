@@ -7,6 +7,7 @@ package docker
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -91,25 +92,27 @@ type dockerProvisioner struct {
 }
 
 var (
-	_ provision.Provisioner              = &dockerProvisioner{}
-	_ provision.ArchiveDeployer          = &dockerProvisioner{}
-	_ provision.UploadDeployer           = &dockerProvisioner{}
-	_ provision.ImageDeployer            = &dockerProvisioner{}
-	_ provision.RollbackableDeployer     = &dockerProvisioner{}
-	_ provision.RebuildableDeployer      = &dockerProvisioner{}
-	_ provision.ShellProvisioner         = &dockerProvisioner{}
-	_ provision.ExecutableProvisioner    = &dockerProvisioner{}
-	_ provision.SleepableProvisioner     = &dockerProvisioner{}
-	_ provision.MessageProvisioner       = &dockerProvisioner{}
-	_ provision.InitializableProvisioner = &dockerProvisioner{}
-	_ provision.OptionalLogsProvisioner  = &dockerProvisioner{}
-	_ provision.UnitStatusProvisioner    = &dockerProvisioner{}
-	_ provision.NodeProvisioner          = &dockerProvisioner{}
-	_ provision.NodeRebalanceProvisioner = &dockerProvisioner{}
-	_ provision.NodeContainerProvisioner = &dockerProvisioner{}
-	_ provision.UnitFinderProvisioner    = &dockerProvisioner{}
-	_ provision.AppFilterProvisioner     = &dockerProvisioner{}
-	_ provision.ExtensibleProvisioner    = &dockerProvisioner{}
+	_ provision.Provisioner                = &dockerProvisioner{}
+	_ provision.ArchiveDeployer            = &dockerProvisioner{}
+	_ provision.UploadDeployer             = &dockerProvisioner{}
+	_ provision.ImageDeployer              = &dockerProvisioner{}
+	_ provision.RollbackableDeployer       = &dockerProvisioner{}
+	_ provision.RebuildableDeployer        = &dockerProvisioner{}
+	_ provision.ShellProvisioner           = &dockerProvisioner{}
+	_ provision.ExecutableProvisioner      = &dockerProvisioner{}
+	_ provision.SleepableProvisioner       = &dockerProvisioner{}
+	_ provision.MessageProvisioner         = &dockerProvisioner{}
+	_ provision.InitializableProvisioner   = &dockerProvisioner{}
+	_ provision.OptionalLogsProvisioner    = &dockerProvisioner{}
+	_ provision.UnitStatusProvisioner      = &dockerProvisioner{}
+	_ provision.NodeProvisioner            = &dockerProvisioner{}
+	_ provision.NodeRebalanceProvisioner   = &dockerProvisioner{}
+	_ provision.NodeCredentialsProvisioner = &dockerProvisioner{}
+	_ provision.NodeContainerProvisioner   = &dockerProvisioner{}
+	_ provision.UnitFinderProvisioner      = &dockerProvisioner{}
+	_ provision.AppFilterProvisioner       = &dockerProvisioner{}
+	_ provision.ExtensibleProvisioner      = &dockerProvisioner{}
+	_ provision.ImageSizer                 = &dockerProvisioner{}
 )
 
 type hookHealer struct {
@@ -316,31 +319,13 @@ func (p *dockerProvisioner) Provision(app provision.App) error {
 	return nil
 }
 
-func (p *dockerProvisioner) Restart(a provision.App, process string, w io.Writer) error {
-	containers, err := p.listContainersByProcess(a.GetName(), process)
-	if err != nil {
-		return err
-	}
-	imageId, err := image.AppCurrentImageName(a.GetName())
-	if err != nil {
-		return err
-	}
-	if w == nil {
-		w = ioutil.Discard
-	}
-	toAdd := make(map[string]*containersToAdd, len(containers))
-	for _, c := range containers {
-		if _, ok := toAdd[c.ProcessName]; !ok {
-			toAdd[c.ProcessName] = &containersToAdd{Quantity: 0}
-		}
-		toAdd[c.ProcessName].Quantity++
-		toAdd[c.ProcessName].Status = provision.StatusStarted
-	}
-	_, err = p.runReplaceUnitsPipeline(w, a, toAdd, containers, imageId)
-	return err
+func (p *dockerProvisioner) Restart(ctx context.Context, a provision.App, process string, w io.Writer) error {
+	return p.RollingRestart(ctx, a, provision.RollingRestartArgs{
+		Progress: provision.RestartProgress{Process: process},
+	}, w)
 }
 
-func (p *dockerProvisioner) Start(app provision.App, process string) error {
+func (p *dockerProvisioner) Start(ctx context.Context, app provision.App, process string) error {
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Got error while getting app containers: %s", err))
@@ -349,6 +334,7 @@ func (p *dockerProvisioner) Start(app provision.App, process string) error {
 		startErr := c.Start(&container.StartArgs{
 			Provisioner: p,
 			App:         app,
+			Context:     ctx,
 		})
 		if startErr != nil {
 			return startErr
@@ -362,14 +348,14 @@ func (p *dockerProvisioner) Start(app provision.App, process string) error {
 	return err
 }
 
-func (p *dockerProvisioner) Stop(app provision.App, process string) error {
+func (p *dockerProvisioner) Stop(ctx context.Context, app provision.App, process string) error {
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
 		log.Errorf("Got error while getting app containers: %s", err)
 		return nil
 	}
 	return runInContainers(containers, func(c *container.Container, _ chan *container.Container) error {
-		err := c.Stop(p)
+		err := c.StopContext(ctx, p)
 		if err != nil {
 			log.Errorf("Failed to stop %q: %s", app.GetName(), err)
 		}
@@ -442,10 +428,15 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, evt *
 	if err != nil {
 		return "", err
 	}
-	err = cluster.PullImage(pullOpts, docker.AuthConfiguration{}, node)
+	pullServer := provision.ImageRegistry(imageId)
+	pullAuth, pullCred, err := p.registryAuthForApp(app, pullServer)
 	if err != nil {
 		return "", err
 	}
+	err = cluster.PullImage(pullOpts, pullAuth, node)
+	if err != nil {
+		return "", provision.RegistryAuthError(err, pullServer, imageId, pullCred)
+	}
 	fmt.Fprintln(w, "---- Getting process from image ----")
 	cmd := "cat /home/application/current/Procfile || cat /app/user/Procfile || cat /Procfile"
 	var outBuf bytes.Buffer
@@ -453,16 +444,23 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, evt *
 	if err != nil {
 		return "", err
 	}
+	registry, _ := config.GetString("docker:registry")
+	pushAuth, pushCred, err := p.registryAuthForApp(app, registry)
+	if err != nil {
+		return "", err
+	}
+	evt.StartPhase(provision.DeployPhaseImagePush)
 	newImage, err := dockercommon.PrepareImageForDeploy(dockercommon.PrepareImageArgs{
 		Client:      cluster,
 		App:         app,
 		ProcfileRaw: outBuf.String(),
 		ImageId:     imageId,
-		AuthConfig:  p.RegistryAuthConfig(),
+		AuthConfig:  pushAuth,
 		Out:         w,
 	})
+	evt.EndPhase(provision.DeployPhaseImagePush)
 	if err != nil {
-		return "", err
+		return "", provision.RegistryAuthError(err, registry, "", pushCred)
 	}
 	app.SetUpdatePlatform(true)
 	return newImage, p.deploy(app, newImage, evt)
@@ -526,13 +524,17 @@ func (p *dockerProvisioner) deploy(a provision.App, imageId string, evt *event.E
 		if err = setQuota(a, toAdd); err != nil {
 			return err
 		}
-		_, err = p.runCreateUnitsPipeline(evt, a, toAdd, imageId, imageData.ExposedPort)
+		_, err = p.runCreateUnitsPipeline(evt, a, toAdd, imageId, imageData.ExposedPort, nil)
 	} else {
 		toAdd := getContainersToAdd(imageData, containers)
 		if err = setQuota(a, toAdd); err != nil {
 			return err
 		}
-		_, err = p.runReplaceUnitsPipeline(evt, a, toAdd, containers, imageId)
+		if strategy := provision.GetDeployStrategy(a); !strategy.IsRolling() {
+			_, err = p.runStrategyPipeline(evt, a, toAdd, containers, imageId, strategy)
+		} else {
+			_, err = p.runReplaceUnitsPipeline(evt, a, toAdd, containers, imageId)
+		}
 	}
 	return err
 }
@@ -704,25 +706,40 @@ func addContainersWithHost(args *changeUnitsPipelineArgs) ([]container.Container
 }
 
 func (p *dockerProvisioner) AddUnits(a provision.App, units uint, process string, w io.Writer) error {
+	_, err := p.AddUnitsGated(a, units, process, w)
+	return err
+}
+
+// AddUnitsGated adds units to the app, only routing traffic to each unit
+// after its healthcheck passes. Units failing the healthcheck are removed or
+// kept without routes according to docker:healthcheck:gating:failure-policy.
+func (p *dockerProvisioner) AddUnitsGated(a provision.App, units uint, process string, w io.Writer) ([]provision.UnitGateResult, error) {
 	if a.GetDeploys() == 0 {
-		return errors.New("New units can only be added after the first deployment")
+		return nil, errors.New("New units can only be added after the first deployment")
 	}
 	if units == 0 {
-		return errors.New("Cannot add 0 units")
+		return nil, errors.New("Cannot add 0 units")
 	}
 	if w == nil {
 		w = ioutil.Discard
 	}
+	gate, err := newUnitGate()
+	if err != nil {
+		return nil, err
+	}
 	imageId, err := image.AppCurrentImageName(a.GetName())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	imageData, err := image.GetImageCustomData(imageId)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = p.runCreateUnitsPipeline(w, a, map[string]*containersToAdd{process: {Quantity: int(units)}}, imageId, imageData.ExposedPort)
-	return err
+	containers, err := p.runCreateUnitsPipeline(w, a, map[string]*containersToAdd{process: {Quantity: int(units)}}, imageId, imageData.ExposedPort, gate)
+	if err != nil {
+		return nil, err
+	}
+	return gate.finish(containers), nil
 }
 
 func (p *dockerProvisioner) RemoveUnits(a provision.App, units uint, processName string, w io.Writer) error {
@@ -945,6 +962,14 @@ func (p *dockerProvisioner) PlatformRemove(name string) error {
 	return err
 }
 
+func (p *dockerProvisioner) ImageSize(imageName string) (int64, error) {
+	img, err := p.Cluster().InspectImage(imageName)
+	if err != nil {
+		return 0, err
+	}
+	return img.VirtualSize, nil
+}
+
 // GetAppFromUnitID returns app from unit id
 func (p *dockerProvisioner) GetAppFromUnitID(unitID string) (provision.App, error) {
 	cnt, err := p.GetContainer(unitID)
@@ -1292,6 +1317,49 @@ func (p *dockerProvisioner) UpdateNode(opts provision.UpdateNodeOptions) error {
 	return err
 }
 
+func (p *dockerProvisioner) NodeCredentials(address string) (*provision.NodeCredentials, error) {
+	node, err := p.Cluster().GetNode(address)
+	if err != nil {
+		if err == clusterStorage.ErrNoSuchNode {
+			return nil, provision.ErrNodeNotFound
+		}
+		return nil, err
+	}
+	return &provision.NodeCredentials{
+		CaCert:     node.CaCert,
+		ClientCert: node.ClientCert,
+		ClientKey:  node.ClientKey,
+	}, nil
+}
+
+func (p *dockerProvisioner) UpdateNodeCredentials(address string, creds provision.NodeCredentials) error {
+	node, err := p.Cluster().GetNode(address)
+	if err != nil {
+		if err == clusterStorage.ErrNoSuchNode {
+			return provision.ErrNodeNotFound
+		}
+		return err
+	}
+	newNode := cluster.Node{
+		Address:        node.Address,
+		Healing:        node.Healing,
+		Metadata:       node.Metadata,
+		CreationStatus: node.CreationStatus,
+		CaCert:         creds.CaCert,
+		ClientCert:     creds.ClientCert,
+		ClientKey:      creds.ClientKey,
+	}
+	client, err := newNode.Client()
+	if err != nil {
+		return errors.Wrapf(err, "invalid credentials for node %q", address)
+	}
+	err = client.Ping()
+	if err != nil {
+		return errors.Wrapf(err, "unable to reach node %q using the new credentials", address)
+	}
+	return p.storage.UpdateNode(newNode)
+}
+
 func (p *dockerProvisioner) GetNode(address string) (provision.Node, error) {
 	node, err := p.Cluster().GetNode(address)
 	if err != nil {
@@ -6,6 +6,7 @@ package docker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -82,7 +83,7 @@ func (s *S) TestProvisionerRestart(c *check.C) {
 	}, nil)
 	c.Assert(err, check.IsNil)
 	defer s.removeTestContainer(cont2)
-	err = s.p.Start(app, "")
+	err = s.p.Start(context.Background(), app, "")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err := s.p.Cluster().InspectContainer(cont1.ID)
 	c.Assert(err, check.IsNil)
@@ -90,7 +91,7 @@ func (s *S) TestProvisionerRestart(c *check.C) {
 	dockerContainer, err = s.p.Cluster().InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, true)
-	err = s.p.Restart(app, "", nil)
+	err = s.p.Restart(context.Background(), app, "", nil)
 	c.Assert(err, check.IsNil)
 	dbConts, err := s.p.listAllContainers()
 	c.Assert(err, check.IsNil)
@@ -134,7 +135,7 @@ func (s *S) TestProvisionerRestartStoppedContainer(c *check.C) {
 	}, nil)
 	c.Assert(err, check.IsNil)
 	defer s.removeTestContainer(cont2)
-	err = s.p.Stop(app, "")
+	err = s.p.Stop(context.Background(), app, "")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err := s.p.Cluster().InspectContainer(cont1.ID)
 	c.Assert(err, check.IsNil)
@@ -142,7 +143,7 @@ func (s *S) TestProvisionerRestartStoppedContainer(c *check.C) {
 	dockerContainer, err = s.p.Cluster().InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, false)
-	err = s.p.Restart(app, "", nil)
+	err = s.p.Restart(context.Background(), app, "", nil)
 	c.Assert(err, check.IsNil)
 	dbConts, err := s.p.listAllContainers()
 	c.Assert(err, check.IsNil)
@@ -192,7 +193,7 @@ func (s *S) TestProvisionerRestartProcess(c *check.C) {
 	}, nil)
 	c.Assert(err, check.IsNil)
 	defer s.removeTestContainer(cont2)
-	err = s.p.Start(app, "")
+	err = s.p.Start(context.Background(), app, "")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err := s.p.Cluster().InspectContainer(cont1.ID)
 	c.Assert(err, check.IsNil)
@@ -200,7 +201,7 @@ func (s *S) TestProvisionerRestartProcess(c *check.C) {
 	dockerContainer, err = s.p.Cluster().InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, true)
-	err = s.p.Restart(app, "web", nil)
+	err = s.p.Restart(context.Background(), app, "web", nil)
 	c.Assert(err, check.IsNil)
 	dbConts, err := s.p.listAllContainers()
 	c.Assert(err, check.IsNil)
@@ -219,6 +220,116 @@ func (s *S) TestProvisionerRestartProcess(c *check.C) {
 	c.Assert(dbConts[0].HostPort, check.Equals, expectedPort)
 }
 
+func (s *S) newRollingRestartContainers(c *check.C, appName string, n int) []*container.Container {
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python web.py",
+		},
+	}
+	conts := make([]*container.Container, n)
+	for i := range conts {
+		cont, err := s.newContainer(&newContainerOpts{
+			AppName:         appName,
+			ProcessName:     "web",
+			ImageCustomData: customData,
+			Image:           "tsuru/app-" + appName,
+		}, nil)
+		c.Assert(err, check.IsNil)
+		conts[i] = cont
+	}
+	return conts
+}
+
+func (s *S) TestProvisionerRollingRestartResume(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	conts := s.newRollingRestartContainers(c, app.GetName(), 3)
+	var reported []provision.RestartProgress
+	err := s.p.RollingRestart(context.Background(), app, provision.RollingRestartArgs{
+		Progress: provision.RestartProgress{Restarted: []string{conts[0].ID}},
+		OnProgress: func(p provision.RestartProgress) {
+			reported = append(reported, p)
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	dbConts, err := s.p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbConts, check.HasLen, 3)
+	ids := map[string]bool{}
+	for _, cont := range dbConts {
+		ids[cont.ID] = true
+	}
+	c.Assert(ids[conts[0].ID], check.Equals, true)
+	c.Assert(ids[conts[1].ID], check.Equals, false)
+	c.Assert(ids[conts[2].ID], check.Equals, false)
+	c.Assert(reported, check.HasLen, 2)
+	c.Assert(reported[0].Restarted, check.HasLen, 2)
+	c.Assert(reported[1].Restarted, check.HasLen, 3)
+	for _, id := range reported[1].Restarted {
+		c.Assert(ids[id], check.Equals, true)
+	}
+}
+
+func (s *S) TestProvisionerRollingRestartMaxUnavailable(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	conts := s.newRollingRestartContainers(c, app.GetName(), 2)
+	var reported []provision.RestartProgress
+	buf := bytes.Buffer{}
+	err := s.p.RollingRestart(context.Background(), app, provision.RollingRestartArgs{
+		Progress: provision.RestartProgress{
+			Updates: map[string]provision.RollingUpdate{"web": {MaxUnavailable: 2}},
+		},
+		OnProgress: func(p provision.RestartProgress) {
+			reported = append(reported, p)
+		},
+	}, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*---- Restarting 2 of 2 pending units of process "web" ----.*`)
+	dbConts, err := s.p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbConts, check.HasLen, 2)
+	for _, cont := range dbConts {
+		c.Assert(cont.ID, check.Not(check.Equals), conts[0].ID)
+		c.Assert(cont.ID, check.Not(check.Equals), conts[1].ID)
+	}
+	c.Assert(reported, check.HasLen, 2)
+	c.Assert(reported[0].Missing, check.DeepEquals, map[string]int{"web": 2})
+	c.Assert(reported[0].Restarted, check.HasLen, 0)
+	c.Assert(reported[1].Missing, check.HasLen, 0)
+	c.Assert(reported[1].Restarted, check.HasLen, 2)
+}
+
+func (s *S) TestProvisionerRollingRestartReplacesMissingUnits(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	conts := s.newRollingRestartContainers(c, app.GetName(), 1)
+	err := s.p.RollingRestart(context.Background(), app, provision.RollingRestartArgs{
+		Progress: provision.RestartProgress{
+			Restarted: []string{conts[0].ID},
+			Missing:   map[string]int{"web": 1},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	dbConts, err := s.p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbConts, check.HasLen, 2)
+}
+
+func (s *S) TestProvisionerRollingRestartContextCanceled(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	conts := s.newRollingRestartContainers(c, app.GetName(), 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.p.RollingRestart(ctx, app, provision.RollingRestartArgs{}, nil)
+	c.Assert(err, check.ErrorMatches, `rolling restart of process "web" stopped: context canceled`)
+	dbConts, err := s.p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbConts, check.HasLen, 2)
+	ids := map[string]bool{}
+	for _, cont := range dbConts {
+		ids[cont.ID] = true
+	}
+	c.Assert(ids, check.DeepEquals, map[string]bool{conts[0].ID: true, conts[1].ID: true})
+}
+
 func (s *S) stopContainers(endpoint string, n uint) <-chan bool {
 	ch := make(chan bool)
 	go func() {
@@ -2118,7 +2229,7 @@ func (s *S) TestProvisionerStart(c *check.C) {
 	dockerContainer, err = dcli.InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, false)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err = dcli.InspectContainer(cont1.ID)
 	c.Assert(err, check.IsNil)
@@ -2176,7 +2287,7 @@ func (s *S) TestProvisionerStartProcess(c *check.C) {
 	dockerContainer, err = dcli.InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, false)
-	err = s.p.Start(a, "web")
+	err = s.p.Start(context.Background(), a, "web")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err = dcli.InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
@@ -2228,7 +2339,7 @@ func (s *S) TestProvisionerStop(c *check.C) {
 	dockerContainer, err = dcli.InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, true)
-	err = s.p.Stop(a, "")
+	err = s.p.Stop(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err = dcli.InspectContainer(cont1.ID)
 	c.Assert(err, check.IsNil)
@@ -2273,7 +2384,7 @@ func (s *S) TestProvisionerStopProcess(c *check.C) {
 	dockerContainer, err = dcli.InspectContainer(cont2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer.State.Running, check.Equals, true)
-	err = s.p.Stop(a, "worker")
+	err = s.p.Stop(context.Background(), a, "worker")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err = dcli.InspectContainer(cont1.ID)
 	c.Assert(err, check.IsNil)
@@ -2304,7 +2415,7 @@ func (s *S) TestProvisionerStopSkipAlreadyStoppedContainers(c *check.C) {
 	dockerContainer2, err := dcli.InspectContainer(container2.ID)
 	c.Assert(err, check.IsNil)
 	c.Assert(dockerContainer2.State.Running, check.Equals, false)
-	err = s.p.Stop(a, "")
+	err = s.p.Stop(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	dockerContainer, err = dcli.InspectContainer(container.ID)
 	c.Assert(err, check.IsNil)
@@ -2557,6 +2668,16 @@ func (s *S) TestProvisionerPlatformRemove(c *check.C) {
 	c.Assert(requests[3].URL.Path, check.Matches, "/images/[^/]+")
 }
 
+func (s *S) TestProvisionerImageSize(c *check.C) {
+	err := s.newFakeImage(s.p, "tsuru/app-myapp:v1", nil)
+	c.Assert(err, check.IsNil)
+	size, err := s.p.ImageSize("tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(size >= 0, check.Equals, true)
+	_, err = s.p.ImageSize("tsuru/app-unknown:v1")
+	c.Assert(err, check.NotNil)
+}
+
 func (s *S) TestProvisionerPlatformRemoveReturnsStorageError(c *check.C) {
 	registryServer := httptest.NewServer(nil)
 	defer registryServer.Close()
@@ -0,0 +1,138 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+)
+
+var ErrRestartCanceled = errors.New("restart canceled by user action")
+
+// RollingRestart replaces the containers of the app in batches limited by
+// the rolling update of each process. New containers must pass the
+// healthcheck before the old ones are removed, the restart stops on the first
+// failed batch, when args.Event is canceled or when ctx is done, keeping the
+// progress reported to args.OnProgress.
+func (p *dockerProvisioner) RollingRestart(ctx context.Context, a provision.App, args provision.RollingRestartArgs, w io.Writer) error {
+	progress := args.Progress
+	containers, err := p.listContainersByProcess(a.GetName(), progress.Process)
+	if err != nil {
+		return err
+	}
+	imageId, err := image.AppCurrentImageName(a.GetName())
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	evt := args.Event
+	restarted := make(map[string]bool, len(progress.Restarted))
+	for _, id := range progress.Restarted {
+		restarted[id] = true
+	}
+	pending := map[string][]container.Container{}
+	for _, c := range containers {
+		if !restarted[c.ID] {
+			pending[c.ProcessName] = append(pending[c.ProcessName], c)
+		}
+	}
+	for process := range progress.Missing {
+		if _, ok := pending[process]; !ok {
+			pending[process] = nil
+		}
+	}
+	processes := make([]string, 0, len(pending))
+	for process := range pending {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	report := func() {
+		if args.OnProgress != nil {
+			args.OnProgress(progress)
+		}
+	}
+	for _, process := range processes {
+		toRestart := pending[process]
+		update := progress.Update(process)
+		for len(toRestart) > 0 || progress.Missing[process] > 0 {
+			if evt != nil {
+				canceled, cancelErr := evt.AckCancel()
+				if cancelErr != nil {
+					log.Errorf("[restart] unable to check if event should be canceled, ignoring: %s", cancelErr)
+				}
+				if canceled {
+					return ErrRestartCanceled
+				}
+			}
+			if err = ctx.Err(); err != nil {
+				return errors.Wrapf(err, "rolling restart of process %q stopped", process)
+			}
+			unavailable := update.MaxUnavailable
+			if unavailable > len(toRestart) {
+				unavailable = len(toRestart)
+			}
+			surge := update.MaxSurge
+			if surge > len(toRestart)-unavailable {
+				surge = len(toRestart) - unavailable
+			}
+			fmt.Fprintf(w, "\n---- Restarting %d of %d pending %s of process %q ----\n",
+				unavailable+surge+progress.Missing[process], len(toRestart)+progress.Missing[process],
+				pluralize("unit", len(toRestart)+progress.Missing[process]), process)
+			if unavailable > 0 {
+				err = p.removeRestartedUnits(w, a, toRestart[:unavailable])
+				if err != nil {
+					return errors.Wrapf(err, "rolling restart of process %q stopped", process)
+				}
+				if progress.Missing == nil {
+					progress.Missing = map[string]int{}
+				}
+				progress.Missing[process] += unavailable
+				report()
+			}
+			toAdd := map[string]*containersToAdd{process: {
+				Quantity: surge + progress.Missing[process],
+				Status:   provision.StatusStarted,
+			}}
+			newContainers, err := p.runReplaceUnitsPipeline(w, a, toAdd, toRestart[unavailable:unavailable+surge], imageId)
+			if err != nil {
+				return errors.Wrapf(err, "rolling restart of process %q stopped, resume it after fixing the failure", process)
+			}
+			for _, c := range newContainers {
+				progress.Restarted = append(progress.Restarted, c.ID)
+			}
+			delete(progress.Missing, process)
+			toRestart = toRestart[unavailable+surge:]
+			report()
+		}
+	}
+	return nil
+}
+
+func (p *dockerProvisioner) removeRestartedUnits(w io.Writer, a provision.App, toRemove []container.Container) error {
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toRemove:    toRemove,
+		writer:      w,
+		provisioner: p,
+	}
+	pipeline := action.NewPipeline(
+		&removeOldRoutes,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	return pipeline.Execute(args)
+}
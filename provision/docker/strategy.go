@@ -0,0 +1,253 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/router"
+)
+
+// strategyCheckInterval is the interval between the checks of new units
+// while they're watched after each shift of traffic.
+var strategyCheckInterval = 5 * time.Second
+
+// ValidateDeployStrategy checks whether the router of the app is able to
+// split the traffic between units, required by canary deploys. Blue-green
+// deploys on routers unable to do it switch the traffic by removing the
+// routes of the old units right after adding the routes of the new ones.
+func (p *dockerProvisioner) ValidateDeployStrategy(a provision.App, strategy provision.DeployStrategy) error {
+	if strategy.Name != provision.DeployStrategyCanary {
+		return nil
+	}
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.(router.WeightedRouter); !ok {
+		return errors.Errorf("the router of app %q can't split the traffic between units, as required by %s deploys", a.GetName(), strategy.Name)
+	}
+	return nil
+}
+
+// runStrategyPipeline replaces the containers of the app following the deploy
+// strategy: the new containers are started alongside the old ones, the
+// traffic is shifted to them and the old containers are only removed after
+// the new ones are watched. Failures shift the traffic back to the old
+// containers and remove the new ones.
+func (p *dockerProvisioner) runStrategyPipeline(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, toRemoveContainers []container.Container, imageId string, strategy provision.DeployStrategy) ([]container.Container, error) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	evt, _ := w.(*event.Event)
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toAdd:       toAdd,
+		toRemove:    toRemoveContainers,
+		writer:      w,
+		imageId:     imageId,
+		provisioner: p,
+		event:       evt,
+		strategy:    strategy,
+	}
+	pipeline := action.NewPipeline(
+		&provisionAddUnitsToHost,
+		&bindAndHealthcheck,
+		&addNewRoutes,
+		&setRouterHealthcheck,
+		&shiftTrafficToNewUnits,
+		&removeOldRoutes,
+		&watchNewUnits,
+		&updateAppImage,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	err := pipeline.Execute(args)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Result().([]container.Container), nil
+}
+
+func routableAddresses(containers []container.Container) []*url.URL {
+	var addresses []*url.URL
+	for _, c := range containers {
+		if c.Routable {
+			addresses = append(addresses, c.Address())
+		}
+	}
+	return addresses
+}
+
+func weightedRouterForApp(a provision.App) (router.WeightedRouter, bool) {
+	r, err := getRouterForApp(a)
+	if err != nil {
+		log.Errorf("[deploy-strategy] unable to get the router of app %q: %s", a.GetName(), err)
+		return nil, false
+	}
+	wRouter, ok := r.(router.WeightedRouter)
+	return wRouter, ok
+}
+
+// shiftTrafficToNewUnits shifts the traffic to the new units in the steps of
+// the strategy, watching them after each step but the last one, which is
+// watched by watchNewUnits once the routes of the old units are removed.
+var shiftTrafficToNewUnits = action.Action{
+	Name: "shift-traffic-to-new-units",
+	Forward: func(ctx action.FWContext) (result action.Result, err error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		newContainers := ctx.Previous.([]container.Container)
+		wRouter, ok := weightedRouterForApp(args.app)
+		routes := routableAddresses(newContainers)
+		if !ok || len(routes) == 0 {
+			return newContainers, nil
+		}
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		defer func() {
+			if err != nil {
+				fmt.Fprintf(writer, "\n---- Shifting the traffic back to old units ----\n")
+				if rmErr := wRouter.SetRoutesWeight(args.app.GetName(), nil, 0); rmErr != nil {
+					log.Errorf("[shift-traffic-to-new-units] unable to remove the weights of routes: %s", rmErr)
+				}
+			}
+		}()
+		args.event.StartPhase(provision.DeployPhaseRouteUpdate)
+		defer args.event.EndPhase(provision.DeployPhaseRouteUpdate)
+		weights := args.strategy.Weights()
+		for i, weight := range weights {
+			if err = checkCanceled(args.event); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(writer, "\n---- Shifting %d%% of the traffic to new units (step %d of %d) ----\n", weight, i+1, len(weights))
+			err = wRouter.SetRoutesWeight(args.app.GetName(), routes, weight)
+			if err != nil {
+				return nil, err
+			}
+			if i < len(weights)-1 {
+				err = watchUnits(&args, newContainers, writer)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		return newContainers, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		wRouter, ok := weightedRouterForApp(args.app)
+		if !ok {
+			return
+		}
+		w := args.writer
+		if w == nil {
+			w = ioutil.Discard
+		}
+		fmt.Fprintf(w, "\n---- Shifting the traffic back to old units ----\n")
+		err := wRouter.SetRoutesWeight(args.app.GetName(), nil, 0)
+		if err != nil {
+			log.Errorf("[shift-traffic-to-new-units:Backward] unable to remove the weights of routes: %s", err)
+		}
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
+// watchNewUnits watches the new units after all the traffic is sent to them,
+// removing the weights of their routes when they pass.
+var watchNewUnits = action.Action{
+	Name: "watch-new-units",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		newContainers := ctx.Previous.([]container.Container)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		err := watchUnits(&args, newContainers, writer)
+		if err != nil {
+			return nil, err
+		}
+		if wRouter, ok := weightedRouterForApp(args.app); ok && len(routableAddresses(newContainers)) > 0 {
+			err = wRouter.SetRoutesWeight(args.app.GetName(), nil, 0)
+			if err != nil {
+				log.Errorf("[watch-new-units] unable to remove the weights of routes: %s", err)
+			}
+		}
+		return newContainers, nil
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
+// watchUnits checks the status and the healthcheck of the units of the web
+// process during the step interval of the strategy, failing on the first
+// unit in error or failing the healthcheck.
+func watchUnits(args *changeUnitsPipelineArgs, containers []container.Container, w io.Writer) error {
+	webProcessName, err := image.GetImageWebProcessName(args.imageId)
+	if err != nil {
+		log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
+	}
+	var toWatch []container.Container
+	for _, c := range containers {
+		if c.ProcessName == webProcessName {
+			toWatch = append(toWatch, c)
+		}
+	}
+	if len(toWatch) == 0 {
+		return nil
+	}
+	interval := args.strategy.StepInterval
+	fmt.Fprintf(w, " ---> Watching %d new %s for %s\n", len(toWatch), pluralize("unit", len(toWatch)), interval)
+	deadline := time.Now().Add(interval)
+	for {
+		if err = checkCanceled(args.event); err != nil {
+			return err
+		}
+		for i := range toWatch {
+			err = checkWatchedUnit(args.provisioner, args.app, &toWatch[i])
+			if err != nil {
+				return err
+			}
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			fmt.Fprintf(w, " ---> New units are healthy\n")
+			return nil
+		}
+		if remaining > strategyCheckInterval {
+			remaining = strategyCheckInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+func checkWatchedUnit(p *dockerProvisioner, a provision.App, c *container.Container) error {
+	current, err := p.GetContainer(c.ID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get new unit %s", c.ShortID())
+	}
+	if current.Status == provision.StatusError.String() {
+		return errors.Errorf("new unit %s is in error", c.ShortID())
+	}
+	err = runAppHealthcheck(a, current, ioutil.Discard)
+	if err != nil {
+		return errors.Wrapf(err, "new unit %s failed the healthcheck", c.ShortID())
+	}
+	return nil
+}
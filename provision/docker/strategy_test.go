@@ -0,0 +1,80 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestValidateDeployStrategy(c *check.C) {
+	config.Set("routers:fake-weighted:type", "fake-weighted")
+	defer config.Unset("routers:fake-weighted")
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	canary := provision.DeployStrategy{Name: provision.DeployStrategyCanary}
+	err := s.p.ValidateDeployStrategy(app, canary)
+	c.Assert(err, check.ErrorMatches, `the router of app "almah" can't split the traffic between units, as required by canary deploys`)
+	err = s.p.ValidateDeployStrategy(app, provision.DeployStrategy{Name: provision.DeployStrategyBlueGreen})
+	c.Assert(err, check.IsNil)
+	app.Router = "fake-weighted"
+	err = s.p.ValidateDeployStrategy(app, canary)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestProvisionerDeployCanary(c *check.C) {
+	config.Set("routers:fake-weighted:type", "fake-weighted")
+	defer config.Unset("routers:fake-weighted")
+	routertest.WeightedRouter.Reset()
+	defer routertest.WeightedRouter.Reset()
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	app.Router = "fake-weighted"
+	app.Strategy = provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: 4}
+	conts := s.newRollingRestartContainers(c, app.GetName(), 2)
+	routertest.WeightedRouter.AddBackend(app.GetName())
+	for _, cont := range conts {
+		routertest.WeightedRouter.AddRoute(app.GetName(), cont.Address())
+	}
+	oldConts, err := s.p.listContainersByApp(app.GetName())
+	c.Assert(err, check.IsNil)
+	buf := bytes.Buffer{}
+	toAdd := map[string]*containersToAdd{"web": {Quantity: 2, Status: provision.StatusStarted}}
+	newConts, err := s.p.runStrategyPipeline(&buf, app, toAdd, oldConts, conts[0].Image, app.GetDeployStrategy())
+	c.Assert(err, check.IsNil)
+	c.Assert(newConts, check.HasLen, 2)
+	c.Assert(buf.String(), check.Matches, `(?s).*---- Shifting 25% of the traffic to new units \(step 1 of 4\) ----.*`)
+	c.Assert(buf.String(), check.Matches, `(?s).*---- Shifting 100% of the traffic to new units \(step 4 of 4\) ----.*`)
+	c.Assert(routertest.WeightedRouter.History[app.GetName()], check.DeepEquals, []int{0, 25, 50, 75, 100, -1})
+	c.Assert(routertest.WeightedRouter.Weights[app.GetName()], check.IsNil)
+	dbConts, err := s.p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbConts, check.HasLen, 2)
+	for _, cont := range dbConts {
+		c.Assert(cont.ID, check.Not(check.Equals), conts[0].ID)
+		c.Assert(cont.ID, check.Not(check.Equals), conts[1].ID)
+		c.Assert(routertest.WeightedRouter.HasRoute(app.GetName(), cont.Address().String()), check.Equals, true)
+	}
+	for _, cont := range conts {
+		c.Assert(routertest.WeightedRouter.HasRoute(app.GetName(), cont.Address().String()), check.Equals, false)
+	}
+}
+
+func (s *S) TestProvisionerDeployBlueGreenWithoutWeightedRouter(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	app.Strategy = provision.DeployStrategy{Name: provision.DeployStrategyBlueGreen}
+	conts := s.newRollingRestartContainers(c, app.GetName(), 1)
+	oldConts, err := s.p.listContainersByApp(app.GetName())
+	c.Assert(err, check.IsNil)
+	toAdd := map[string]*containersToAdd{"web": {Quantity: 1, Status: provision.StatusStarted}}
+	newConts, err := s.p.runStrategyPipeline(nil, app, toAdd, oldConts, conts[0].Image, app.GetDeployStrategy())
+	c.Assert(err, check.IsNil)
+	c.Assert(newConts, check.HasLen, 1)
+	c.Assert(routertest.FakeRouter.HasRoute(app.GetName(), newConts[0].Address().String()), check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasRoute(app.GetName(), conts[0].Address().String()), check.Equals, false)
+}
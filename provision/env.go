@@ -9,6 +9,7 @@ import (
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/secret"
 )
 
 func WebProcessDefaultPort() string {
@@ -19,10 +20,19 @@ func WebProcessDefaultPort() string {
 	return fmt.Sprint(port)
 }
 
-func EnvsForApp(a App, process string, isDeploy bool) []bind.EnvVar {
+// EnvsForApp returns the environment variables of units of the app, with the
+// values of secret variables resolved from the secrets backend.
+func EnvsForApp(a App, process string, isDeploy bool) ([]bind.EnvVar, error) {
 	var envs []bind.EnvVar
 	if !isDeploy {
 		for _, envData := range a.Envs() {
+			if envData.Secret {
+				value, err := secret.Resolve(envData.Value)
+				if err != nil {
+					return nil, err
+				}
+				envData.Value = value
+			}
 			envs = append(envs, envData)
 		}
 		envs = append(envs, bind.EnvVar{Name: "TSURU_PROCESSNAME", Value: process})
@@ -36,5 +46,5 @@ func EnvsForApp(a App, process string, isDeploy bool) []bind.EnvVar {
 			{Name: "PORT", Value: port},
 		}...)
 	}
-	return envs
+	return envs, nil
 }
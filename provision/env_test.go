@@ -9,6 +9,8 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/secret/secrettest"
 	"gopkg.in/check.v1"
 )
 
@@ -27,7 +29,8 @@ func (s *S) TestWebProcessDefaultPortWithConfig(c *check.C) {
 func (s *S) TestEnvsForApp(c *check.C) {
 	a := provisiontest.NewFakeApp("myapp", "crystal", 1)
 	a.SetEnv(bind.EnvVar{Name: "e1", Value: "v1"})
-	envs := provision.EnvsForApp(a, "p1", false)
+	envs, err := provision.EnvsForApp(a, "p1", false)
+	c.Assert(err, check.IsNil)
 	c.Assert(envs, check.DeepEquals, []bind.EnvVar{
 		{Name: "e1", Value: "v1"},
 		{Name: "TSURU_PROCESSNAME", Value: "p1"},
@@ -35,7 +38,8 @@ func (s *S) TestEnvsForApp(c *check.C) {
 		{Name: "port", Value: "8888"},
 		{Name: "PORT", Value: "8888"},
 	})
-	envs = provision.EnvsForApp(a, "p1", true)
+	envs, err = provision.EnvsForApp(a, "p1", true)
+	c.Assert(err, check.IsNil)
 	c.Assert(envs, check.DeepEquals, []bind.EnvVar{
 		{Name: "TSURU_HOST", Value: ""},
 	})
@@ -48,7 +52,8 @@ func (s *S) TestEnvsForAppCustomConfig(c *check.C) {
 	defer config.Unset("docker:run-cmd:port")
 	a := provisiontest.NewFakeApp("myapp", "crystal", 1)
 	a.SetEnv(bind.EnvVar{Name: "e1", Value: "v1"})
-	envs := provision.EnvsForApp(a, "p1", false)
+	envs, err := provision.EnvsForApp(a, "p1", false)
+	c.Assert(err, check.IsNil)
 	c.Assert(envs, check.DeepEquals, []bind.EnvVar{
 		{Name: "e1", Value: "v1"},
 		{Name: "TSURU_PROCESSNAME", Value: "p1"},
@@ -56,8 +61,66 @@ func (s *S) TestEnvsForAppCustomConfig(c *check.C) {
 		{Name: "port", Value: "8989"},
 		{Name: "PORT", Value: "8989"},
 	})
-	envs = provision.EnvsForApp(a, "p1", true)
+	envs, err = provision.EnvsForApp(a, "p1", true)
+	c.Assert(err, check.IsNil)
 	c.Assert(envs, check.DeepEquals, []bind.EnvVar{
 		{Name: "TSURU_HOST", Value: "cloud.tsuru.io"},
 	})
 }
+
+func (s *S) TestEnvsForAppResolvesSecrets(c *check.C) {
+	config.Set("secrets:backend", "fake")
+	defer config.Unset("secrets:backend")
+	defer secrettest.Reset()
+	ref, err := secret.Store("myapp", "e1", "v1")
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "crystal", 1)
+	a.SetEnv(bind.EnvVar{Name: "e1", Value: ref, Secret: true})
+	envs, err := provision.EnvsForApp(a, "p1", false)
+	c.Assert(err, check.IsNil)
+	c.Assert(envs[0], check.DeepEquals, bind.EnvVar{Name: "e1", Value: "v1", Secret: true})
+	a.SetEnv(bind.EnvVar{Name: "e1", Value: "fake:myapp/missing", Secret: true})
+	_, err = provision.EnvsForApp(a, "p1", false)
+	c.Assert(err, check.ErrorMatches, `unable to resolve secret "fake:myapp/missing": secret not found`)
+}
+
+type processPlanApp struct {
+	*provisiontest.FakeApp
+}
+
+func (a processPlanApp) GetProcessMemory(process string) int64 {
+	if process == "worker" {
+		return 2048
+	}
+	return a.GetMemory()
+}
+
+func (a processPlanApp) GetProcessSwap(process string) int64 {
+	return a.GetSwap()
+}
+
+func (a processPlanApp) GetProcessCpuShare(process string) int {
+	if process == "worker" {
+		return 200
+	}
+	return a.GetCpuShare()
+}
+
+func (s *S) TestProcessResources(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "crystal", 1)
+	a.Memory = 1024
+	a.Swap = 512
+	a.CpuShare = 100
+	memory, swap, cpuShare := provision.ProcessResources(a, "worker")
+	c.Assert(memory, check.Equals, int64(1024))
+	c.Assert(swap, check.Equals, int64(512))
+	c.Assert(cpuShare, check.Equals, 100)
+	pa := processPlanApp{FakeApp: a}
+	memory, swap, cpuShare = provision.ProcessResources(pa, "worker")
+	c.Assert(memory, check.Equals, int64(2048))
+	c.Assert(swap, check.Equals, int64(512))
+	c.Assert(cpuShare, check.Equals, 200)
+	memory, _, cpuShare = provision.ProcessResources(pa, "web")
+	c.Assert(memory, check.Equals, int64(1024))
+	c.Assert(cpuShare, check.Equals, 100)
+}
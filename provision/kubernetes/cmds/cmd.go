@@ -147,6 +147,9 @@ func (c *kubernetesClusterList) Run(context *cmd.Context, client *cmd.Client) er
 	}
 	defer response.Body.Close()
 	if response.StatusCode == http.StatusNoContent {
+		if context.Structured() {
+			return context.Render([]cluster.Cluster{})
+		}
 		fmt.Fprintln(context.Stdout, "No kubernetes clusters registered.")
 		return nil
 	}
@@ -159,10 +162,13 @@ func (c *kubernetesClusterList) Run(context *cmd.Context, client *cmd.Client) er
 	if err != nil {
 		return errors.Wrapf(err, "unable to parse data %q", string(data))
 	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	if context.Structured() {
+		return context.Render(clusters)
+	}
 	tbl := cmd.NewTable()
 	tbl.LineSeparator = true
 	tbl.Headers = cmd.Row{"Name", "Addresses", "Namespace", "Default", "Pools"}
-	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
 	for _, c := range clusters {
 		tbl.AddRow(cmd.Row{c.Name, strings.Join(c.Addresses, "\n"), c.Namespace(), strconv.FormatBool(c.Default), strings.Join(c.Pools, "\n")})
 	}
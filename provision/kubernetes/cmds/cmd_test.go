@@ -137,6 +137,36 @@ func (s *S) TestKubernetesClusterListRun(c *check.C) {
 `)
 }
 
+func (s *S) TestKubernetesClusterListRunStructured(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Format: cmd.FormatJSON,
+	}
+	clusters := []cluster.Cluster{
+		{Name: "c2", Addresses: []string{"addr3"}, Pools: []string{"p1"}},
+		{Name: "c1", Addresses: []string{"addr1"}, Default: true},
+	}
+	data, err := json.Marshal(clusters)
+	c.Assert(err, check.IsNil)
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(data), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.3/kubernetes/clusters" && req.Method == "GET"
+		},
+	}
+	manager := cmd.NewManager("admin", "0.1", "admin-ver", &stdout, &stderr, nil, nil)
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	myCmd := kubernetesClusterList{}
+	err = myCmd.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var result []cluster.Cluster
+	err = json.Unmarshal(stdout.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []cluster.Cluster{clusters[1], clusters[0]})
+}
+
 func (s *S) TestKubernetesClusterRemoveRun(c *check.C) {
 	var stdout, stderr bytes.Buffer
 	context := cmd.Context{
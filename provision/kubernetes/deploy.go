@@ -6,6 +6,7 @@ package kubernetes
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -96,11 +97,18 @@ func createBuildPod(params buildPodParams) error {
 	}
 	buildImageLabel := &provision.LabelSet{}
 	buildImageLabel.SetBuildImage(params.destinationImage)
-	appEnvs := provision.EnvsForApp(params.app, "", true)
+	appEnvs, err := provision.EnvsForApp(params.app, "", true)
+	if err != nil {
+		return err
+	}
 	var envs []v1.EnvVar
 	for _, envData := range appEnvs {
 		envs = append(envs, v1.EnvVar{Name: envData.Name, Value: envData.Value})
 	}
+	pullSecrets, err := ensureRegistrySecret(params.client, params.app, params.sourceImage)
+	if err != nil {
+		return err
+	}
 	commitContainer := "committer-cont"
 	pod := &v1.Pod{
 		ObjectMeta: v1.ObjectMeta{
@@ -120,7 +128,8 @@ func createBuildPod(params buildPodParams) error {
 					},
 				},
 			},
-			RestartPolicy: v1.RestartPolicyNever,
+			RestartPolicy:    v1.RestartPolicyNever,
+			ImagePullSecrets: pullSecrets,
 			Containers: []v1.Container{
 				{
 					Name:      baseName,
@@ -213,6 +222,23 @@ func probeFromHC(hc provision.TsuruYamlHealthcheck, port int) (*v1.Probe, error)
 	}, nil
 }
 
+// probeFromAppHC returns the probe for the healthcheck configured in the app.
+// The restart policy of the app is not used, units of deployments are always
+// restarted.
+func probeFromAppHC(hc provision.AppHealthcheck, port int) *v1.Probe {
+	return &v1.Probe{
+		PeriodSeconds:    int32(hc.Interval),
+		TimeoutSeconds:   int32(hc.Timeout),
+		FailureThreshold: int32(hc.FailureThreshold),
+		Handler: v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: hc.Path,
+				Port: intstr.FromInt(port),
+			},
+		},
+	}
+}
+
 func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deployment, a provision.App, process, imageName string, replicas int, labels *provision.LabelSet) (*extensions.Deployment, *provision.LabelSet, error) {
 	provision.ExtendServiceLabels(labels, provision.ServiceLabelExtendedOpts{
 		Provisioner: provisionerName,
@@ -224,7 +250,10 @@ func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deploy
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
-	appEnvs := provision.EnvsForApp(a, process, false)
+	appEnvs, err := provision.EnvsForApp(a, process, false)
+	if err != nil {
+		return nil, nil, err
+	}
 	var envs []v1.EnvVar
 	for _, envData := range appEnvs {
 		envs = append(envs, v1.EnvVar{Name: envData.Name, Value: envData.Value})
@@ -241,11 +270,18 @@ func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deploy
 	if err != nil {
 		return nil, nil, err
 	}
+	if hc := provision.HealthcheckForApp(a); hc != nil && hc.Path != "" {
+		probe = probeFromAppHC(*hc, portInt)
+	}
 	maxSurge := intstr.FromString("100%")
 	maxUnavailable := intstr.FromInt(0)
 	nodeSelector := provision.NodeLabels(provision.NodeLabelsOpts{
 		Pool: a.GetPool(),
 	}).ToNodeByPoolSelector()
+	pullSecrets, err := ensureRegistrySecret(client, a, imageName)
+	if err != nil {
+		return nil, nil, err
+	}
 	deployment := extensions.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      depName,
@@ -269,8 +305,9 @@ func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deploy
 					Labels: labels.ToLabels(),
 				},
 				Spec: v1.PodSpec{
-					RestartPolicy: v1.RestartPolicyAlways,
-					NodeSelector:  nodeSelector,
+					RestartPolicy:    v1.RestartPolicyAlways,
+					NodeSelector:     nodeSelector,
+					ImagePullSecrets: pullSecrets,
 					Containers: []v1.Container{
 						{
 							Name:           depName,
@@ -293,9 +330,20 @@ func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deploy
 	return newDep, labels, errors.WithStack(err)
 }
 
+// serviceManager manages the deployments of apps in the kubernetes cluster.
+// Waiting for the rollout of deployments is aborted once ctx, when set, is
+// done.
 type serviceManager struct {
 	client *clusterClient
 	writer io.Writer
+	ctx    context.Context
+}
+
+func (m *serviceManager) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
 }
 
 var _ servicecommon.ServiceManager = &serviceManager{}
@@ -348,7 +396,7 @@ func createDeployTimeoutError(client *clusterClient, a provision.App, processNam
 	return errors.Errorf("timeout after %v waiting for units%s", timeout, msgErrorPart)
 }
 
-func monitorDeployment(client *clusterClient, dep *extensions.Deployment, a provision.App, processName string, w io.Writer) error {
+func monitorDeployment(ctx context.Context, client *clusterClient, dep *extensions.Deployment, a provision.App, processName string, w io.Writer) error {
 	fmt.Fprintf(w, "\n---- Updating units [%s] ----\n", processName)
 	timeout := time.After(defaultDeploymentProgressTimeout)
 	var err error
@@ -361,6 +409,8 @@ func monitorDeployment(client *clusterClient, dep *extensions.Deployment, a prov
 		case <-time.After(100 * time.Millisecond):
 		case <-timeout:
 			return errors.Errorf("timeout waiting for deployment generation to update")
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 	var specReplicas int32
@@ -412,6 +462,8 @@ func monitorDeployment(client *clusterClient, dep *extensions.Deployment, a prov
 			return createDeployTimeoutError(client, a, processName, w, time.Since(t0))
 		case <-timeout:
 			return createDeployTimeoutError(client, a, processName, w, time.Since(t0))
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 		dep, err = client.Extensions().Deployments(client.Namespace()).Get(dep.Name)
 		if err != nil {
@@ -423,6 +475,9 @@ func monitorDeployment(client *clusterClient, dep *extensions.Deployment, a prov
 }
 
 func (m *serviceManager) DeployService(a provision.App, process string, labels *provision.LabelSet, replicas int, image string) error {
+	if err := m.context().Err(); err != nil {
+		return err
+	}
 	depName := deploymentNameForApp(a, process)
 	dep, err := m.client.Extensions().Deployments(m.client.Namespace()).Get(depName)
 	if err != nil {
@@ -438,7 +493,7 @@ func (m *serviceManager) DeployService(a provision.App, process string, labels *
 	if m.writer == nil {
 		m.writer = ioutil.Discard
 	}
-	err = monitorDeployment(m.client, dep, a, process, m.writer)
+	err = monitorDeployment(m.context(), m.client, dep, a, process, m.writer)
 	if err != nil {
 		fmt.Fprintf(m.writer, "\n**** ROLLING BACK AFTER FAILURE ****\n ---> %s <---\n", err)
 		rollbackErr := m.client.Extensions().Deployments(m.client.Namespace()).Rollback(&extensions.DeploymentRollback{
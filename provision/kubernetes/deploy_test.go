@@ -327,6 +327,46 @@ func (s *S) TestServiceManagerDeployServiceWithHC(c *check.C) {
 	})
 }
 
+func (s *S) TestServiceManagerDeployServiceWithAppHC(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name, Healthcheck: &provision.AppHealthcheck{
+		Path:             "/status",
+		Interval:         10,
+		Timeout:          2,
+		FailureThreshold: 3,
+	}}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+		"healthcheck": provision.TsuruYamlHealthcheck{
+			Path: "/hc",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Start: true},
+	})
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].ReadinessProbe, check.DeepEquals, &v1.Probe{
+		PeriodSeconds:    10,
+		TimeoutSeconds:   2,
+		FailureThreshold: 3,
+		Handler: v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: "/status",
+				Port: intstr.FromInt(8888),
+			},
+		},
+	})
+}
+
 func (s *S) TestServiceManagerDeployServiceWithHCInvalidMethod(c *check.C) {
 	m := serviceManager{client: s.client.clusterClient}
 	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
@@ -39,6 +39,10 @@ func execCommandPodNameForApp(a provision.App) string {
 	return fmt.Sprintf("%s-isolated-run", a.GetName())
 }
 
+func registrySecretNameForApp(a provision.App) string {
+	return fmt.Sprintf("%s-registry", a.GetName())
+}
+
 func daemonSetName(name, pool string) string {
 	if pool == "" {
 		return fmt.Sprintf("node-container-%s-all", name)
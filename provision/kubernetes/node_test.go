@@ -5,6 +5,7 @@
 package kubernetes
 
 import (
+	"context"
 	"net/url"
 
 	"github.com/tsuru/tsuru/app"
@@ -131,7 +132,7 @@ func (s *S) TestNodeUnits(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = image.AppendAppImageName(a.GetName(), imgName)
 	c.Assert(err, check.IsNil)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	wait()
 	node, err := s.p.GetNode("192.168.99.1")
@@ -5,6 +5,7 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -103,7 +104,7 @@ func (p *kubernetesProvisioner) Destroy(a provision.App) error {
 	return nil
 }
 
-func changeState(a provision.App, process string, state servicecommon.ProcessState, w io.Writer) error {
+func changeState(ctx context.Context, a provision.App, process string, state servicecommon.ProcessState, w io.Writer) error {
 	client, err := clusterForPool(a.GetPool())
 	if err != nil {
 		return err
@@ -111,6 +112,7 @@ func changeState(a provision.App, process string, state servicecommon.ProcessSta
 	return servicecommon.ChangeAppState(&serviceManager{
 		client: client,
 		writer: w,
+		ctx:    ctx,
 	}, a, process, state)
 }
 
@@ -133,16 +135,16 @@ func (p *kubernetesProvisioner) RemoveUnits(a provision.App, units uint, process
 	return changeUnits(a, -int(units), processName, w)
 }
 
-func (p *kubernetesProvisioner) Restart(a provision.App, process string, w io.Writer) error {
-	return changeState(a, process, servicecommon.ProcessState{Start: true, Restart: true}, w)
+func (p *kubernetesProvisioner) Restart(ctx context.Context, a provision.App, process string, w io.Writer) error {
+	return changeState(ctx, a, process, servicecommon.ProcessState{Start: true, Restart: true}, w)
 }
 
-func (p *kubernetesProvisioner) Start(a provision.App, process string) error {
-	return changeState(a, process, servicecommon.ProcessState{Start: true}, nil)
+func (p *kubernetesProvisioner) Start(ctx context.Context, a provision.App, process string) error {
+	return changeState(ctx, a, process, servicecommon.ProcessState{Start: true}, nil)
 }
 
-func (p *kubernetesProvisioner) Stop(a provision.App, process string) error {
-	return changeState(a, process, servicecommon.ProcessState{Stop: true}, nil)
+func (p *kubernetesProvisioner) Stop(ctx context.Context, a provision.App, process string) error {
+	return changeState(ctx, a, process, servicecommon.ProcessState{Stop: true}, nil)
 }
 
 var stateMap = map[v1.PodPhase]provision.Status{
@@ -228,6 +230,9 @@ func (p *kubernetesProvisioner) podsToUnits(client *clusterClient, pods []v1.Pod
 			Status:      stateMap[pod.Status.Phase],
 			Address:     url,
 		}
+		for _, status := range pod.Status.ContainerStatuses {
+			units[i].Restarts += int(status.RestartCount)
+		}
 	}
 	return units, nil
 }
@@ -539,6 +544,7 @@ func (p *kubernetesProvisioner) ImageDeploy(a provision.App, imageID string, evt
 	manager := &serviceManager{
 		client: client,
 		writer: evt,
+		ctx:    evt.Context(),
 	}
 	err = servicecommon.RunServicePipeline(manager, a, newImage, nil)
 	if err != nil {
@@ -577,15 +583,20 @@ func (p *kubernetesProvisioner) UploadDeploy(a provision.App, archiveFile io.Rea
 		attachInput:      archiveFile,
 		attachOutput:     evt,
 	}
+	evt.StartPhase(provision.DeployPhaseBuild)
 	err = createBuildPod(params)
+	evt.EndPhase(provision.DeployPhaseBuild)
 	if err != nil {
 		return "", err
 	}
 	manager := &serviceManager{
 		client: client,
 		writer: evt,
+		ctx:    evt.Context(),
 	}
+	evt.StartPhase(provision.DeployPhaseUnitStart)
 	err = servicecommon.RunServicePipeline(manager, a, buildingImage, nil)
+	evt.EndPhase(provision.DeployPhaseUnitStart)
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
@@ -684,7 +695,10 @@ func runIsolatedCmdPod(client *clusterClient, a provision.App, out io.Writer, cm
 	if err != nil {
 		return err
 	}
-	appEnvs := provision.EnvsForApp(a, "", false)
+	appEnvs, err := provision.EnvsForApp(a, "", false)
+	if err != nil {
+		return err
+	}
 	var envs []v1.EnvVar
 	for _, envData := range appEnvs {
 		envs = append(envs, v1.EnvVar{Name: envData.Name, Value: envData.Value})
@@ -735,5 +749,5 @@ func (p *kubernetesProvisioner) StartupMessage() (string, error) {
 }
 
 func (p *kubernetesProvisioner) Sleep(a provision.App, process string) error {
-	return changeState(a, process, servicecommon.ProcessState{Stop: true, Sleep: true}, nil)
+	return changeState(context.Background(), a, process, servicecommon.ProcessState{Stop: true, Sleep: true}, nil)
 }
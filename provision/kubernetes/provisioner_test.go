@@ -6,6 +6,7 @@ package kubernetes
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -112,7 +113,7 @@ func (s *S) TestUnits(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = image.AppendAppImageName(a.GetName(), imgName)
 	c.Assert(err, check.IsNil)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	wait()
 	units, err := s.p.Units(a)
@@ -289,7 +290,7 @@ func (s *S) TestRestart(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 1)
 	id := units[0].ID
-	err = s.p.Restart(a, "", nil)
+	err = s.p.Restart(context.Background(), a, "", nil)
 	c.Assert(err, check.IsNil)
 	wait()
 	units, err = s.p.Units(a)
@@ -313,13 +314,13 @@ func (s *S) TestStopStart(c *check.C) {
 	err = s.p.AddUnits(a, 1, "web", nil)
 	c.Assert(err, check.IsNil)
 	wait()
-	err = s.p.Stop(a, "")
+	err = s.p.Stop(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	wait()
 	units, err := s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 0)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	wait()
 	units, err = s.p.Units(a)
@@ -801,7 +802,7 @@ func (s *S) TestSleepStart(c *check.C) {
 	units, err := s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 0)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	wait()
 	units, err = s.p.Units(a)
@@ -0,0 +1,73 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/provision"
+	k8sErrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+type dockercfgEntry struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+// ensureRegistrySecret stores the credentials registered for the pool or the
+// team of the app to the registries of the images in a secret, returning the
+// image pull secrets used by the pods of the app. No secret is used when none
+// of the registries has credentials.
+func ensureRegistrySecret(client *clusterClient, a provision.App, images ...string) ([]v1.LocalObjectReference, error) {
+	cfg := map[string]dockercfgEntry{}
+	for _, img := range images {
+		server := provision.ImageRegistry(img)
+		if _, ok := cfg[server]; ok || server == "" {
+			continue
+		}
+		cred, err := provision.RegistryCredentialForApp(a, server)
+		if err != nil {
+			return nil, err
+		}
+		if cred == nil {
+			continue
+		}
+		cfg[server] = dockercfgEntry{
+			Username: cred.Username,
+			Password: cred.Password,
+			Email:    cred.Email,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password)),
+		}
+	}
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	secret := &v1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      registrySecretNameForApp(a),
+			Namespace: client.Namespace(),
+		},
+		Type: v1.SecretTypeDockercfg,
+		Data: map[string][]byte{v1.DockerConfigKey: data},
+	}
+	secrets := client.Core().Secrets(client.Namespace())
+	_, err = secrets.Update(secret)
+	if k8sErrors.IsNotFound(err) {
+		_, err = secrets.Create(secret)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return []v1.LocalObjectReference{{Name: secret.Name}}, nil
+}
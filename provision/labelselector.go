@@ -0,0 +1,132 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// maxLabelValueLength is the maximum length of the value of a label set on
+// apps and pools.
+const maxLabelValueLength = 255
+
+// labelKeyRegexp matches the keys of labels. Dots and dollar signs aren't
+// allowed because labels are stored as document fields.
+var labelKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_/-]{0,61}[a-zA-Z0-9])?$`)
+
+// ParseLabels parses labels in the key=value format, like the ones sent in
+// the label parameter when creating and updating apps and pools. An empty
+// value, like in "key=", is kept in the result and means that the label
+// should be removed on updates.
+func ParseLabels(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid label %q, labels must be in the key=value format", v)}
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		err := validateLabel(key, value)
+		if err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func validateLabel(key, value string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		msg := fmt.Sprintf("invalid label key %q, keys should have at most 63 characters, "+
+			"containing only letters, numbers, dashes, underscores or slashes, "+
+			"starting and ending with a letter or a number", key)
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	if len(value) > maxLabelValueLength {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("value of label %q is longer than %d characters", key, maxLabelValueLength)}
+	}
+	return nil
+}
+
+// MergeLabels applies the changes to the labels, removing the ones with empty
+// values in changes, and returns the result, nil when there are no labels
+// left.
+func MergeLabels(labels, changes map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range labels {
+		result[k] = v
+	}
+	for k, v := range changes {
+		if v == "" {
+			delete(result, k)
+		} else {
+			result[k] = v
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// LabelSelector selects apps and pools by their labels. Keys with an empty
+// value match any value, the label just have to be set.
+type LabelSelector map[string]string
+
+// ParseLabelSelector parses a selector from filters in the key=value or key
+// formats.
+func ParseLabelSelector(values []string) (LabelSelector, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	selector := LabelSelector{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		var value string
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		err := validateLabel(key, value)
+		if err != nil {
+			return nil, err
+		}
+		selector[key] = value
+	}
+	return selector, nil
+}
+
+// Matches returns whether the labels have all the labels in the selector.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, v := range s {
+		value, ok := labels[k]
+		if !ok || (v != "" && value != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns the query matching documents with the labels of the
+// selector, stored in the given field.
+func (s LabelSelector) Query(field string) bson.M {
+	query := bson.M{}
+	for k, v := range s {
+		if v == "" {
+			query[field+"."+k] = bson.M{"$exists": true}
+		} else {
+			query[field+"."+k] = v
+		}
+	}
+	return query
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestParseLabels(c *check.C) {
+	labels, err := ParseLabels([]string{"cost-center=1234", " product = store ", "team/owner=", "url=http://a=b"})
+	c.Assert(err, check.IsNil)
+	c.Assert(labels, check.DeepEquals, map[string]string{
+		"cost-center": "1234",
+		"product":     "store",
+		"team/owner":  "",
+		"url":         "http://a=b",
+	})
+	labels, err = ParseLabels(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(labels, check.IsNil)
+}
+
+func (s *S) TestParseLabelsInvalid(c *check.C) {
+	tests := []struct {
+		label string
+		msg   string
+	}{
+		{"cost-center", `invalid label "cost-center", labels must be in the key=value format`},
+		{"=1234", `invalid label key "", .*`},
+		{"cost.center=1234", `invalid label key "cost.center", .*`},
+		{"$where=1", `invalid label key "\$where", .*`},
+		{"-a=1", `invalid label key "-a", .*`},
+	}
+	for _, tt := range tests {
+		_, err := ParseLabels([]string{tt.label})
+		c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+		c.Assert(err, check.ErrorMatches, tt.msg)
+	}
+}
+
+func (s *S) TestMergeLabels(c *check.C) {
+	labels := map[string]string{"a": "1", "b": "2"}
+	c.Assert(MergeLabels(labels, map[string]string{"a": "", "c": "3"}), check.DeepEquals, map[string]string{"b": "2", "c": "3"})
+	c.Assert(labels, check.DeepEquals, map[string]string{"a": "1", "b": "2"})
+	c.Assert(MergeLabels(labels, map[string]string{"a": "", "b": ""}), check.IsNil)
+	c.Assert(MergeLabels(nil, map[string]string{"a": ""}), check.IsNil)
+}
+
+func (s *S) TestLabelSelector(c *check.C) {
+	selector, err := ParseLabelSelector([]string{"cost-center=1234", "product"})
+	c.Assert(err, check.IsNil)
+	c.Assert(selector, check.DeepEquals, LabelSelector{"cost-center": "1234", "product": ""})
+	c.Assert(selector.Matches(map[string]string{"cost-center": "1234", "product": "store"}), check.Equals, true)
+	c.Assert(selector.Matches(map[string]string{"cost-center": "1234"}), check.Equals, false)
+	c.Assert(selector.Matches(map[string]string{"cost-center": "4321", "product": "store"}), check.Equals, false)
+	c.Assert(selector.Matches(nil), check.Equals, false)
+	c.Assert(selector.Query("labels"), check.DeepEquals, bson.M{
+		"labels.cost-center": "1234",
+		"labels.product":     bson.M{"$exists": true},
+	})
+	var empty LabelSelector
+	c.Assert(empty.Matches(nil), check.Equals, true)
+	c.Assert(empty.Query("labels"), check.DeepEquals, bson.M{})
+	_, err = ParseLabelSelector([]string{"cost.center"})
+	c.Assert(err, check.ErrorMatches, `invalid label key "cost.center", .*`)
+}
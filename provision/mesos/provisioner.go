@@ -5,6 +5,7 @@
 package mesos
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -51,15 +52,15 @@ func (p *mesosProvisioner) RemoveUnits(provision.App, uint, string, io.Writer) e
 	return errNotImplemented
 }
 
-func (p *mesosProvisioner) Restart(provision.App, string, io.Writer) error {
+func (p *mesosProvisioner) Restart(context.Context, provision.App, string, io.Writer) error {
 	return errNotImplemented
 }
 
-func (p *mesosProvisioner) Start(provision.App, string) error {
+func (p *mesosProvisioner) Start(context.Context, provision.App, string) error {
 	return errNotImplemented
 }
 
-func (p *mesosProvisioner) Stop(provision.App, string) error {
+func (p *mesosProvisioner) Stop(context.Context, provision.App, string) error {
 	return errNotImplemented
 }
 
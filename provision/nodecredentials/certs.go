@@ -0,0 +1,111 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nodecredentials
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+var (
+	clientCertValidity = 365 * 24 * time.Hour
+	clientKeyBits      = 2048
+)
+
+// generateClientCert generates a new client certificate and key, signed by
+// the CA in the docker:tls:root-path config, which must be the CA of the node
+// identified by nodeCaCert.
+func generateClientCert(nodeCaCert []byte) (certPEM, keyPEM []byte, err error) {
+	caPath, _ := config.GetString("docker:tls:root-path")
+	if caPath == "" {
+		return nil, nil, errors.New("docker:tls:root-path must be set to generate node client certificates")
+	}
+	caCertPEM, err := ioutil.ReadFile(filepath.Join(caPath, "ca.pem"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read CA certificate")
+	}
+	if !bytes.Equal(bytes.TrimSpace(caCertPEM), bytes.TrimSpace(nodeCaCert)) {
+		return nil, nil, errors.New("the CA of the node is not the CA in docker:tls:root-path")
+	}
+	caKeyPEM, err := ioutil.ReadFile(filepath.Join(caPath, "ca-key.pem"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read CA key")
+	}
+	caCert, err := parseCertificate(caCertPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, err := parsePrivateKey(caKeyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := rsa.GenerateKey(rand.Reader, clientKeyBits)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate client key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate certificate serial number")
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "tsuru"},
+		NotBefore:    now.Add(-5 * time.Minute),
+		NotAfter:     now.Add(clientCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to sign client certificate")
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM, nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid CA certificate: no PEM data found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	return cert, errors.Wrap(err, "invalid CA certificate")
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid CA key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CA key")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("invalid CA key: unsupported key type")
+	}
+	return signer, nil
+}
@@ -0,0 +1,388 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nodecredentials provides a workflow for rotating the credentials
+// used to access nodes: new credentials are generated and rolled out one node
+// at a time, each node is verified to be reachable using them before the old
+// ones are revoked. The progress is stored, so failed or canceled rotations
+// can be resumed.
+package nodecredentials
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/iaas"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+
+	NodeStatusPending  = "pending"
+	NodeStatusVerified = "verified"
+	NodeStatusDone     = "done"
+	NodeStatusSkipped  = "skipped"
+)
+
+var (
+	ErrRotationNotFound = errors.New("credentials rotation not found")
+	ErrRotationFinished = errors.New("credentials rotation already finished")
+	ErrRotationCanceled = errors.New("credentials rotation canceled")
+	ErrNoNodes          = errors.New("no nodes to rotate credentials")
+)
+
+type ErrNodeInRotation struct {
+	Node     string
+	Rotation string
+}
+
+func (e *ErrNodeInRotation) Error() string {
+	return fmt.Sprintf("node %q is already part of the unfinished credentials rotation %q", e.Node, e.Rotation)
+}
+
+// Rotation is a credentials rotation of a set of nodes. Nodes are handled in
+// order, Pools holds the pools of the nodes and Events the ids of the events
+// recorded by each run of the rotation.
+type Rotation struct {
+	ID        string `bson:"_id"`
+	Pools     []string
+	Nodes     []NodeRotation
+	Status    string
+	Error     string
+	Events    []string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// NodeRotation is the progress of the rotation of a single node. A verified
+// node is already accessed using the new credentials, while the old ones,
+// kept in Old, are not revoked yet.
+type NodeRotation struct {
+	Address string
+	Status  string
+	Error   string
+	Old     *iaas.Credentials `json:"-" bson:",omitempty"`
+}
+
+// FindNodes returns the nodes whose credentials can be rotated, either the
+// nodes with the given addresses or all nodes in pool, when no address is
+// given. An empty pool matches all nodes.
+func FindNodes(pool string, addresses []string) ([]provision.Node, error) {
+	var nodes []provision.Node
+	if len(addresses) > 0 {
+		for _, addr := range addresses {
+			prov, node, err := provision.FindNode(addr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to find node %q", addr)
+			}
+			if _, ok := prov.(provision.NodeCredentialsProvisioner); !ok {
+				return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "node credentials rotation"}
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes, nil
+	}
+	provs, err := provision.Registry()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, prov := range provs {
+		if _, ok := prov.(provision.NodeCredentialsProvisioner); !ok {
+			continue
+		}
+		provNodes, err := prov.(provision.NodeProvisioner).ListNodes(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range provNodes {
+			if seen[n.Address()] || (pool != "" && n.Pool() != pool) {
+				continue
+			}
+			seen[n.Address()] = true
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// Create stores a new pending rotation for the given nodes. Nodes can't be
+// part of more than one unfinished rotation.
+func Create(nodes []provision.Node) (*Rotation, error) {
+	if len(nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	r := &Rotation{
+		ID:        bson.NewObjectId().Hex(),
+		Status:    StatusPending,
+		StartTime: time.Now().UTC(),
+	}
+	addrs := make([]string, len(nodes))
+	pools := map[string]bool{}
+	for i, n := range nodes {
+		addrs[i] = n.Address()
+		r.Nodes = append(r.Nodes, NodeRotation{Address: n.Address(), Status: NodeStatusPending})
+		if !pools[n.Pool()] {
+			pools[n.Pool()] = true
+			r.Pools = append(r.Pools, n.Pool())
+		}
+	}
+	var unfinished Rotation
+	err = conn.CredentialRotations().Find(bson.M{
+		"status":        bson.M{"$ne": StatusDone},
+		"nodes.address": bson.M{"$in": addrs},
+	}).One(&unfinished)
+	if err == nil {
+		for _, n := range unfinished.Nodes {
+			for _, addr := range addrs {
+				if n.Address == addr {
+					return nil, &ErrNodeInRotation{Node: addr, Rotation: unfinished.ID}
+				}
+			}
+		}
+	}
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	err = conn.CredentialRotations().Insert(r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func Get(id string) (*Rotation, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var r Rotation
+	err = conn.CredentialRotations().FindId(id).One(&r)
+	if err == mgo.ErrNotFound {
+		return nil, ErrRotationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// List returns all rotations, the most recent first.
+func List() ([]Rotation, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var rotations []Rotation
+	err = conn.CredentialRotations().Find(nil).Sort("-starttime").All(&rotations)
+	return rotations, err
+}
+
+// Targets returns event targets for the nodes in the rotation.
+func (r *Rotation) Targets() []event.Target {
+	targets := make([]event.Target, len(r.Nodes))
+	for i, n := range r.Nodes {
+		targets[i] = event.Target{Type: event.TargetTypeNode, Value: n.Address}
+	}
+	return targets
+}
+
+// Run rotates the credentials of the nodes not handled by previous runs,
+// logging the progress to evt. The rotation stops on the first node failure
+// or when evt is canceled, keeping the progress so it can be resumed by
+// calling Run again.
+func (r *Rotation) Run(evt *event.Event) (err error) {
+	if r.Status == StatusDone {
+		return ErrRotationFinished
+	}
+	r.Status = StatusRunning
+	r.Error = ""
+	r.Events = append(r.Events, evt.UniqueID.Hex())
+	err = r.save()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		r.Status = StatusDone
+		r.Error = ""
+		if err == ErrRotationCanceled {
+			r.Status = StatusCanceled
+		} else if err != nil {
+			r.Status = StatusFailed
+			r.Error = err.Error()
+		}
+		r.EndTime = time.Now().UTC()
+		if saveErr := r.save(); saveErr != nil {
+			log.Errorf("[credentials rotation] unable to store rotation %q: %s", r.ID, saveErr)
+		}
+	}()
+	for i := range r.Nodes {
+		node := &r.Nodes[i]
+		if node.Status == NodeStatusDone || node.Status == NodeStatusSkipped {
+			continue
+		}
+		canceled, cancelErr := evt.AckCancel()
+		if cancelErr != nil {
+			log.Errorf("[credentials rotation] unable to check if event should be canceled, ignoring: %s", cancelErr)
+		}
+		if canceled {
+			evt.Logf("Rotation canceled, %d of %d nodes handled.", i, len(r.Nodes))
+			return ErrRotationCanceled
+		}
+		evt.Logf("---- Rotating credentials of node %q (%d/%d) ----", node.Address, i+1, len(r.Nodes))
+		err = r.rotateNode(node, evt)
+		if err != nil {
+			node.Error = err.Error()
+			r.save()
+			return errors.Wrapf(err, "unable to rotate credentials of node %q", node.Address)
+		}
+		node.Error = ""
+	}
+	evt.Logf("Credentials of %d nodes successfully rotated.", len(r.Nodes))
+	return nil
+}
+
+func (r *Rotation) rotateNode(node *NodeRotation, evt *event.Event) error {
+	if node.Status == NodeStatusPending {
+		prov, provNode, err := provision.FindNode(node.Address)
+		if err != nil {
+			return err
+		}
+		credsProv, ok := prov.(provision.NodeCredentialsProvisioner)
+		if !ok {
+			return provision.ProvisionerNotSupported{Prov: prov, Action: "node credentials rotation"}
+		}
+		current, err := credsProv.NodeCredentials(node.Address)
+		if err != nil {
+			return err
+		}
+		m, err := findMachine(provNode)
+		if err != nil {
+			return err
+		}
+		newCreds, byIaaS, err := newCredentials(m, current)
+		if err != nil {
+			return err
+		}
+		if newCreds == nil {
+			evt.Logf("Node doesn't use TLS and its IaaS can't rotate credentials, skipping.")
+			node.Status = NodeStatusSkipped
+			return r.save()
+		}
+		evt.Logf("New credentials generated, verifying the node is reachable using them...")
+		err = credsProv.UpdateNodeCredentials(node.Address, provision.NodeCredentials{
+			CaCert:     newCreds.CaCert,
+			ClientCert: newCreds.ClientCert,
+			ClientKey:  newCreds.ClientKey,
+		})
+		if err != nil {
+			if byIaaS {
+				if revokeErr := m.RevokeCredentials(newCreds); revokeErr != nil {
+					evt.Logf("Unable to revoke the unused new credentials: %s", revokeErr)
+				}
+			}
+			return err
+		}
+		if m != nil {
+			old := m.Credentials()
+			err = m.SetCredentials(newCreds)
+			if err != nil {
+				return err
+			}
+			if byIaaS {
+				node.Old = old
+			}
+		}
+		node.Status = NodeStatusVerified
+		err = r.save()
+		if err != nil {
+			return err
+		}
+	}
+	if node.Old != nil {
+		evt.Logf("Node verified, revoking old credentials...")
+		m, err := iaas.FindMachineByAddress(net.URLToHost(node.Address))
+		if err != nil {
+			return errors.Wrap(err, "unable to find the machine to revoke old credentials")
+		}
+		err = m.RevokeCredentials(node.Old)
+		if err != nil {
+			return err
+		}
+	} else {
+		evt.Logf("Node verified. The old client certificate can't be revoked, it remains valid until it expires.")
+	}
+	node.Old = nil
+	node.Status = NodeStatusDone
+	return r.save()
+}
+
+// newCredentials returns new credentials for a node, generated by the IaaS
+// of its machine when supported, otherwise a client certificate signed by
+// the docker CA. byIaaS tells whether the credentials were generated by the
+// IaaS, in which case they must be revoked by it. Nil credentials are
+// returned for nodes not using TLS when the IaaS can't rotate credentials.
+func newCredentials(m *iaas.Machine, current *provision.NodeCredentials) (creds *iaas.Credentials, byIaaS bool, err error) {
+	if m != nil {
+		creds, err = m.RotateCredentials()
+		if err != iaas.ErrCredentialsNotSupported {
+			return creds, err == nil, err
+		}
+	}
+	if !current.UsesTLS() {
+		return nil, false, nil
+	}
+	certPEM, keyPEM, err := generateClientCert(current.CaCert)
+	if err != nil {
+		return nil, false, err
+	}
+	creds = &iaas.Credentials{
+		CaCert:     current.CaCert,
+		ClientCert: certPEM,
+		ClientKey:  keyPEM,
+	}
+	if m != nil {
+		creds.KeyPair = m.KeyPair
+	}
+	return creds, false, nil
+}
+
+func findMachine(node provision.Node) (*iaas.Machine, error) {
+	m, err := iaas.FindMachineByIdOrAddress(node.Metadata()["iaas-id"], net.URLToHost(node.Address()))
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *Rotation) save() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.CredentialRotations().UpdateId(r.ID, r)
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nodecredentials
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/iaas"
+	iaasTesting "github.com/tsuru/tsuru/iaas/testing"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addMachineNode(c *check.C, inst *iaasTesting.TestCredentialsIaaS, addr, pool string) *iaas.Machine {
+	inst.Addr = addr
+	m, err := iaas.CreateMachineForIaaS("creds-iaas", map[string]string{})
+	c.Assert(err, check.IsNil)
+	err = m.SetCredentials(&iaas.Credentials{KeyPair: "old-" + addr})
+	c.Assert(err, check.IsNil)
+	err = provisiontest.ProvisionerInstance.AddNode(provision.AddNodeOptions{
+		Address:  "http://" + addr + ":2375",
+		Metadata: map[string]string{"pool": pool, "iaas-id": m.Id},
+	})
+	c.Assert(err, check.IsNil)
+	return m
+}
+
+func (s *S) TestFindNodes(c *check.C) {
+	p := provisiontest.ProvisionerInstance
+	p.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": "p1"}})
+	p.AddNode(provision.AddNodeOptions{Address: "http://n2:2375", Metadata: map[string]string{"pool": "p2"}})
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 2)
+	nodes, err = FindNodes("p2", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Address(), check.Equals, "http://n2:2375")
+	nodes, err = FindNodes("", []string{"http://n1:2375"})
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Address(), check.Equals, "http://n1:2375")
+	_, err = FindNodes("", []string{"http://n3:2375"})
+	c.Assert(err, check.ErrorMatches, `unable to find node "http://n3:2375": node not found`)
+}
+
+func (s *S) TestCreate(c *check.C) {
+	p := provisiontest.ProvisionerInstance
+	p.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": "p1"}})
+	p.AddNode(provision.AddNodeOptions{Address: "http://n2:2375", Metadata: map[string]string{"pool": "p1"}})
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Status, check.Equals, StatusPending)
+	c.Assert(r.Pools, check.DeepEquals, []string{"p1"})
+	dbRotation, err := Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Nodes, check.HasLen, 2)
+	c.Assert(dbRotation.Targets(), check.HasLen, 2)
+	_, err = Create(nodes[1:])
+	c.Assert(err, check.FitsTypeOf, &ErrNodeInRotation{})
+	c.Assert(err.(*ErrNodeInRotation).Rotation, check.Equals, r.ID)
+	_, err = Create(nil)
+	c.Assert(err, check.Equals, ErrNoNodes)
+	_, err = Get("unknown")
+	c.Assert(err, check.Equals, ErrRotationNotFound)
+}
+
+func (s *S) TestRunWithIaaS(c *check.C) {
+	factory, inst := iaasTesting.NewCredentialsIaaSConstructorWithInst("")
+	iaas.RegisterIaasProvider("creds-iaas", factory)
+	m := s.addMachineNode(c, inst, "addr1", "p1")
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	evt := s.newEvent(c, r)
+	err = r.Run(evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(inst.Rotated, check.DeepEquals, []string{m.Id})
+	c.Assert(inst.Revoked, check.DeepEquals, []string{"old-addr1"})
+	creds, err := provisiontest.ProvisionerInstance.NodeCredentials("http://addr1:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(creds.ClientCert), check.Equals, "cert-m-addr1-1")
+	dbMachine, err := iaas.FindMachineById(m.Id)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbMachine.KeyPair, check.Equals, "keypair-m-addr1-1")
+	c.Assert(string(dbMachine.ClientKey), check.Equals, "key-m-addr1-1")
+	dbRotation, err := Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Status, check.Equals, StatusDone)
+	c.Assert(dbRotation.Events, check.DeepEquals, []string{evt.UniqueID.Hex()})
+	c.Assert(dbRotation.Nodes, check.DeepEquals, []NodeRotation{
+		{Address: "http://addr1:2375", Status: NodeStatusDone},
+	})
+	err = r.Run(evt)
+	c.Assert(err, check.Equals, ErrRotationFinished)
+}
+
+func (s *S) TestRunVerificationFailureAndResume(c *check.C) {
+	factory, inst := iaasTesting.NewCredentialsIaaSConstructorWithInst("")
+	iaas.RegisterIaasProvider("creds-iaas", factory)
+	s.addMachineNode(c, inst, "addr1", "p1")
+	s.addMachineNode(c, inst, "addr2", "p1")
+	nodes, err := FindNodes("", []string{"http://addr1:2375", "http://addr2:2375"})
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	provisiontest.ProvisionerInstance.PrepareFailure("UpdateNodeCredentials:http://addr2:2375", errors.New("node unreachable"))
+	evt := s.newEvent(c, r)
+	err = r.Run(evt)
+	c.Assert(err, check.ErrorMatches, `unable to rotate credentials of node "http://addr2:2375": node unreachable`)
+	evt.Done(err)
+	c.Assert(inst.Revoked, check.DeepEquals, []string{"old-addr1", "keypair-m-addr2-2"})
+	dbRotation, err := Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Status, check.Equals, StatusFailed)
+	c.Assert(dbRotation.Nodes, check.DeepEquals, []NodeRotation{
+		{Address: "http://addr1:2375", Status: NodeStatusDone},
+		{Address: "http://addr2:2375", Status: NodeStatusPending, Error: "node unreachable"},
+	})
+	dbMachine, err := iaas.FindMachineByAddress("addr2")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbMachine.KeyPair, check.Equals, "old-addr2")
+	evt = s.newEvent(c, dbRotation)
+	err = dbRotation.Run(evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(inst.Rotated, check.DeepEquals, []string{"m-addr1", "m-addr2", "m-addr2"})
+	c.Assert(inst.Revoked, check.DeepEquals, []string{"old-addr1", "keypair-m-addr2-2", "old-addr2"})
+	dbRotation, err = Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Status, check.Equals, StatusDone)
+	c.Assert(dbRotation.Events, check.HasLen, 2)
+}
+
+func (s *S) TestRunResumesVerifiedNode(c *check.C) {
+	factory, inst := iaasTesting.NewCredentialsIaaSConstructorWithInst("")
+	iaas.RegisterIaasProvider("creds-iaas", factory)
+	s.addMachineNode(c, inst, "addr1", "p1")
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	inst.RevokeErr = errors.New("iaas unavailable")
+	evt := s.newEvent(c, r)
+	err = r.Run(evt)
+	c.Assert(err, check.ErrorMatches, `.*iaas unavailable`)
+	evt.Done(err)
+	dbRotation, err := Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Nodes[0].Status, check.Equals, NodeStatusVerified)
+	c.Assert(dbRotation.Nodes[0].Old.KeyPair, check.Equals, "old-addr1")
+	inst.RevokeErr = nil
+	err = dbRotation.Run(s.newEvent(c, dbRotation))
+	c.Assert(err, check.IsNil)
+	c.Assert(inst.Rotated, check.HasLen, 1)
+	c.Assert(inst.Revoked, check.DeepEquals, []string{"old-addr1"})
+}
+
+func (s *S) TestRunCanceled(c *check.C) {
+	factory, inst := iaasTesting.NewCredentialsIaaSConstructorWithInst("")
+	iaas.RegisterIaasProvider("creds-iaas", factory)
+	s.addMachineNode(c, inst, "addr1", "p1")
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	evt := s.newEvent(c, r)
+	err = evt.TryCancel("because", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = r.Run(evt)
+	c.Assert(err, check.Equals, ErrRotationCanceled)
+	c.Assert(inst.Rotated, check.HasLen, 0)
+	dbRotation, err := Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Status, check.Equals, StatusCanceled)
+}
+
+func (s *S) TestRunSkipsNodesWithoutTLS(c *check.C) {
+	provisiontest.ProvisionerInstance.AddNode(provision.AddNodeOptions{Address: "http://n1:2375"})
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	err = r.Run(s.newEvent(c, r))
+	c.Assert(err, check.IsNil)
+	dbRotation, err := Get(r.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRotation.Nodes[0].Status, check.Equals, NodeStatusSkipped)
+}
+
+func (s *S) TestRunGeneratesClientCertificate(c *check.C) {
+	caCert := s.setUpCA(c)
+	provisiontest.ProvisionerInstance.AddNode(provision.AddNodeOptions{
+		Address:    "https://n1:2376",
+		CaCert:     caCert,
+		ClientCert: []byte("old-cert"),
+		ClientKey:  []byte("old-key"),
+	})
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	evt := s.newEvent(c, r)
+	err = r.Run(evt)
+	c.Assert(err, check.IsNil)
+	creds, err := provisiontest.ProvisionerInstance.NodeCredentials("https://n1:2376")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.CaCert, check.DeepEquals, caCert)
+	c.Assert(creds.ClientKey, check.Not(check.DeepEquals), []byte("old-key"))
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCert)
+	block, _ := pem.Decode(creds.ClientCert)
+	c.Assert(block, check.NotNil)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	c.Assert(err, check.IsNil)
+	_, err = cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	c.Assert(err, check.IsNil)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+}
+
+func (s *S) TestRunGeneratesClientCertificateUnknownCA(c *check.C) {
+	s.setUpCA(c)
+	provisiontest.ProvisionerInstance.AddNode(provision.AddNodeOptions{
+		Address: "https://n1:2376",
+		CaCert:  []byte("other-ca"),
+	})
+	nodes, err := FindNodes("", nil)
+	c.Assert(err, check.IsNil)
+	r, err := Create(nodes)
+	c.Assert(err, check.IsNil)
+	err = r.Run(s.newEvent(c, r))
+	c.Assert(err, check.ErrorMatches, `.*the CA of the node is not the CA in docker:tls:root-path`)
+	creds, err := provisiontest.ProvisionerInstance.NodeCredentials("https://n1:2376")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.CaCert, check.DeepEquals, []byte("other-ca"))
+}
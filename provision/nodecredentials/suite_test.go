@@ -0,0 +1,107 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nodecredentials
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/iaas"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+var _ = check.Suite(&S{})
+
+type S struct {
+	caPath string
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017?maxPoolSize=100")
+	config.Set("database:name", "provision_nodecredentials_tests")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	provisiontest.ProvisionerInstance.Reset()
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	dbtest.ClearAllCollections(conn.Apps().Database)
+	iaas.ResetAll()
+	s.caPath = ""
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	if s.caPath != "" {
+		os.RemoveAll(s.caPath)
+	}
+	config.Unset("docker:tls:root-path")
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
+
+func (s *S) newEvent(c *check.C, r *Rotation) *event.Event {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypeCredentialRotation, Value: r.ID},
+		InternalKind:  "credentials-rotation-test",
+		Allowed:       event.Allowed(permission.PermPoolReadEvents),
+		AllowedCancel: event.Allowed(permission.PermNodeUpdateCredentials),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+// setUpCA generates a CA in a temporary directory, configured as the docker
+// TLS root path, returning its PEM encoded certificate.
+func (s *S) setUpCA(c *check.C) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, check.IsNil)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tsuru-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	c.Assert(err, check.IsNil)
+	s.caPath, err = ioutil.TempDir("", "nodecredentials")
+	c.Assert(err, check.IsNil)
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	err = ioutil.WriteFile(filepath.Join(s.caPath, "ca.pem"), caCert, 0600)
+	c.Assert(err, check.IsNil)
+	caKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	err = ioutil.WriteFile(filepath.Join(s.caPath, "ca-key.pem"), caKey, 0600)
+	c.Assert(err, check.IsNil)
+	config.Set("docker:tls:root-path", s.caPath)
+	return caCert
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	ErrNodePoolRuleNameRequired     = errors.New("node pool rule name is required")
+	ErrNodePoolRulePoolRequired     = errors.New("node pool rule pool is required")
+	ErrNodePoolRuleMetadataRequired = errors.New("node pool rule must match at least one metadata")
+	ErrNodePoolRuleInvalidMetadata  = errors.New("node pool rule cannot match on the pool metadata")
+	ErrNodePoolRuleAlreadyExists    = errors.New("node pool rule already exists")
+	ErrNodePoolRuleNotFound         = errors.New("node pool rule not found")
+)
+
+// NodePoolRule assigns nodes whose metadata contains all the key/value pairs
+// in Metadata to Pool. When more than one rule matches a node, the rule
+// matching the largest number of metadata wins.
+type NodePoolRule struct {
+	Name     string `bson:"_id"`
+	Pool     string
+	Metadata map[string]string
+}
+
+type NodePoolRuleConflictError struct {
+	Rule     string
+	Conflict string
+}
+
+func (e *NodePoolRuleConflictError) Error() string {
+	return fmt.Sprintf("node pool rule %q conflicts with rule %q: both may match the same node with different pools", e.Rule, e.Conflict)
+}
+
+// Matches returns whether the rule applies to a node with the given
+// metadata.
+func (r *NodePoolRule) Matches(metadata map[string]string) bool {
+	for k, v := range r.Metadata {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts returns whether a node could be matched by both rules with the
+// same precedence while being assigned to different pools.
+func (r *NodePoolRule) conflicts(other *NodePoolRule) bool {
+	if r.Pool == other.Pool || len(r.Metadata) != len(other.Metadata) {
+		return false
+	}
+	for k, v := range r.Metadata {
+		if otherV, ok := other.Metadata[k]; ok && otherV != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *NodePoolRule) validate() error {
+	if r.Name == "" {
+		return ErrNodePoolRuleNameRequired
+	}
+	if r.Pool == "" {
+		return ErrNodePoolRulePoolRequired
+	}
+	if len(r.Metadata) == 0 {
+		return ErrNodePoolRuleMetadataRequired
+	}
+	if _, ok := r.Metadata["pool"]; ok {
+		return ErrNodePoolRuleInvalidMetadata
+	}
+	if _, err := GetPoolByName(r.Pool); err != nil {
+		return err
+	}
+	rules, err := ListNodePoolRules()
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		if rules[i].Name != r.Name && r.conflicts(&rules[i]) {
+			return &NodePoolRuleConflictError{Rule: r.Name, Conflict: rules[i].Name}
+		}
+	}
+	return nil
+}
+
+func AddNodePoolRule(rule NodePoolRule) error {
+	err := rule.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.NodePoolRules().Insert(rule)
+	if mgo.IsDup(err) {
+		return ErrNodePoolRuleAlreadyExists
+	}
+	return err
+}
+
+func UpdateNodePoolRule(rule NodePoolRule) error {
+	err := rule.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.NodePoolRules().UpdateId(rule.Name, rule)
+	if err == mgo.ErrNotFound {
+		return ErrNodePoolRuleNotFound
+	}
+	return err
+}
+
+func RemoveNodePoolRule(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.NodePoolRules().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrNodePoolRuleNotFound
+	}
+	return err
+}
+
+func GetNodePoolRule(name string) (*NodePoolRule, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var rule NodePoolRule
+	err = conn.NodePoolRules().FindId(name).One(&rule)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNodePoolRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func ListNodePoolRules() ([]NodePoolRule, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var rules []NodePoolRule
+	err = conn.NodePoolRules().Find(nil).Sort("_id").All(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+type nodePoolRuleList []NodePoolRule
+
+func (l nodePoolRuleList) Len() int      { return len(l) }
+func (l nodePoolRuleList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l nodePoolRuleList) Less(i, j int) bool {
+	if len(l[i].Metadata) == len(l[j].Metadata) {
+		return l[i].Name < l[j].Name
+	}
+	return len(l[i].Metadata) > len(l[j].Metadata)
+}
+
+// FindNodePoolRule returns the rule that should be used to assign a node with
+// the given metadata to a pool, or nil if no rule matches it.
+func FindNodePoolRule(metadata map[string]string) (*NodePoolRule, error) {
+	rules, err := ListNodePoolRules()
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(nodePoolRuleList(rules))
+	for i := range rules {
+		if rules[i].Matches(metadata) {
+			return &rules[i], nil
+		}
+	}
+	return nil, nil
+}
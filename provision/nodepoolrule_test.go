@@ -0,0 +1,103 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addRulePools(c *check.C) {
+	for _, name := range []string{"pool1", "pool2"} {
+		err := AddPool(AddPoolOptions{Name: name})
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestAddNodePoolRule(c *check.C) {
+	s.addRulePools(c)
+	rule := NodePoolRule{Name: "r1", Pool: "pool1", Metadata: map[string]string{"zone": "a"}}
+	err := AddNodePoolRule(rule)
+	c.Assert(err, check.IsNil)
+	rules, err := ListNodePoolRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []NodePoolRule{rule})
+	err = AddNodePoolRule(rule)
+	c.Assert(err, check.Equals, ErrNodePoolRuleAlreadyExists)
+}
+
+func (s *S) TestAddNodePoolRuleValidation(c *check.C) {
+	s.addRulePools(c)
+	tests := []struct {
+		rule NodePoolRule
+		err  error
+	}{
+		{NodePoolRule{Pool: "pool1", Metadata: map[string]string{"zone": "a"}}, ErrNodePoolRuleNameRequired},
+		{NodePoolRule{Name: "r1", Metadata: map[string]string{"zone": "a"}}, ErrNodePoolRulePoolRequired},
+		{NodePoolRule{Name: "r1", Pool: "pool1"}, ErrNodePoolRuleMetadataRequired},
+		{NodePoolRule{Name: "r1", Pool: "pool1", Metadata: map[string]string{"pool": "a"}}, ErrNodePoolRuleInvalidMetadata},
+		{NodePoolRule{Name: "r1", Pool: "nopool", Metadata: map[string]string{"zone": "a"}}, ErrPoolNotFound},
+	}
+	for _, tt := range tests {
+		err := AddNodePoolRule(tt.rule)
+		c.Assert(err, check.Equals, tt.err)
+	}
+}
+
+func (s *S) TestAddNodePoolRuleConflict(c *check.C) {
+	s.addRulePools(c)
+	err := AddNodePoolRule(NodePoolRule{Name: "r1", Pool: "pool1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	err = AddNodePoolRule(NodePoolRule{Name: "r2", Pool: "pool2", Metadata: map[string]string{"type": "large"}})
+	c.Assert(err, check.DeepEquals, &NodePoolRuleConflictError{Rule: "r2", Conflict: "r1"})
+	err = AddNodePoolRule(NodePoolRule{Name: "r2", Pool: "pool2", Metadata: map[string]string{"zone": "b"}})
+	c.Assert(err, check.IsNil)
+	err = AddNodePoolRule(NodePoolRule{Name: "r3", Pool: "pool2", Metadata: map[string]string{"zone": "a", "type": "large"}})
+	c.Assert(err, check.IsNil)
+	err = AddNodePoolRule(NodePoolRule{Name: "r4", Pool: "pool1", Metadata: map[string]string{"type": "large"}})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestUpdateNodePoolRule(c *check.C) {
+	s.addRulePools(c)
+	err := AddNodePoolRule(NodePoolRule{Name: "r1", Pool: "pool1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	rule := NodePoolRule{Name: "r1", Pool: "pool2", Metadata: map[string]string{"zone": "b"}}
+	err = UpdateNodePoolRule(rule)
+	c.Assert(err, check.IsNil)
+	dbRule, err := GetNodePoolRule("r1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbRule, check.DeepEquals, rule)
+	err = UpdateNodePoolRule(NodePoolRule{Name: "r2", Pool: "pool2", Metadata: map[string]string{"zone": "c"}})
+	c.Assert(err, check.Equals, ErrNodePoolRuleNotFound)
+}
+
+func (s *S) TestRemoveNodePoolRule(c *check.C) {
+	s.addRulePools(c)
+	err := AddNodePoolRule(NodePoolRule{Name: "r1", Pool: "pool1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	err = RemoveNodePoolRule("r1")
+	c.Assert(err, check.IsNil)
+	_, err = GetNodePoolRule("r1")
+	c.Assert(err, check.Equals, ErrNodePoolRuleNotFound)
+	err = RemoveNodePoolRule("r1")
+	c.Assert(err, check.Equals, ErrNodePoolRuleNotFound)
+}
+
+func (s *S) TestFindNodePoolRule(c *check.C) {
+	s.addRulePools(c)
+	err := AddNodePoolRule(NodePoolRule{Name: "r1", Pool: "pool1", Metadata: map[string]string{"zone": "a"}})
+	c.Assert(err, check.IsNil)
+	err = AddNodePoolRule(NodePoolRule{Name: "r2", Pool: "pool2", Metadata: map[string]string{"zone": "a", "type": "large"}})
+	c.Assert(err, check.IsNil)
+	rule, err := FindNodePoolRule(map[string]string{"zone": "a", "type": "small"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.Name, check.Equals, "r1")
+	rule, err = FindNodePoolRule(map[string]string{"zone": "a", "type": "large", "iaas": "ec2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.Name, check.Equals, "r2")
+	rule, err = FindNodePoolRule(map[string]string{"zone": "b"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rule, check.IsNil)
+}
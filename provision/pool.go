@@ -35,6 +35,30 @@ type Pool struct {
 	Name        string `bson:"_id"`
 	Default     bool
 	Provisioner string
+	Defaults    PoolDefaults `bson:",omitempty"`
+	// Labels are key/value pairs used to group pools, like by cost center.
+	Labels map[string]string `bson:",omitempty"`
+}
+
+// PoolDefaults holds the values used when creating apps in the pool without
+// explicitly setting them. Empty values fall back to the global defaults.
+type PoolDefaults struct {
+	Plan      string   `bson:",omitempty"`
+	TeamOwner string   `bson:",omitempty"`
+	Router    string   `bson:",omitempty"`
+	Tags      []string `bson:",omitempty"`
+}
+
+// ErrInvalidPoolDefault is returned when a default value is not allowed by
+// the constraints of the pool.
+type ErrInvalidPoolDefault struct {
+	Pool  string
+	Field string
+	Value string
+}
+
+func (e *ErrInvalidPoolDefault) Error() string {
+	return fmt.Sprintf("%s %q is not allowed in pool %q", e.Field, e.Value, e.Pool)
 }
 
 type AddPoolOptions struct {
@@ -43,6 +67,7 @@ type AddPoolOptions struct {
 	Default     bool
 	Force       bool
 	Provisioner string
+	Labels      map[string]string
 }
 
 type UpdatePoolOptions struct {
@@ -50,6 +75,9 @@ type UpdatePoolOptions struct {
 	Public      *bool
 	Force       bool
 	Provisioner string
+	// Labels are merged into the labels of the pool, the ones with empty
+	// values are removed.
+	Labels map[string]string
 }
 
 func (p *Pool) GetProvisioner() (Provisioner, error) {
@@ -157,6 +185,10 @@ func (p *Pool) MarshalJSON() ([]byte, error) {
 	result["provisioner"] = p.Provisioner
 	result["teams"] = resolvedConstraints["team"]
 	result["allowed"] = resolvedConstraints
+	result["defaults"] = p.Defaults
+	if len(p.Labels) > 0 {
+		result["labels"] = p.Labels
+	}
 	return json.Marshal(&result)
 }
 
@@ -175,7 +207,12 @@ func AddPool(opts AddPoolOptions) error {
 			return err
 		}
 	}
-	pool := Pool{Name: opts.Name, Default: opts.Default, Provisioner: opts.Provisioner}
+	pool := Pool{
+		Name:        opts.Name,
+		Default:     opts.Default,
+		Provisioner: opts.Provisioner,
+		Labels:      MergeLabels(nil, opts.Labels),
+	}
 	err = conn.Pools().Insert(pool)
 	if err != nil {
 		return err
@@ -215,6 +252,10 @@ func RemovePool(poolName string) error {
 	if err == mgo.ErrNotFound {
 		return ErrPoolNotFound
 	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.RegistryCredentials().RemoveAll(bson.M{"pool": poolName})
 	return err
 }
 
@@ -337,7 +378,7 @@ func PoolUpdate(name string, opts UpdatePoolOptions) error {
 		return err
 	}
 	defer conn.Close()
-	_, err = GetPoolByName(name)
+	pool, err := GetPoolByName(name)
 	if err != nil {
 		return err
 	}
@@ -366,6 +407,9 @@ func PoolUpdate(name string, opts UpdatePoolOptions) error {
 	if opts.Provisioner != "" {
 		query["provisioner"] = opts.Provisioner
 	}
+	if len(opts.Labels) > 0 {
+		query["labels"] = MergeLabels(pool.Labels, opts.Labels)
+	}
 	if len(query) == 0 {
 		return nil
 	}
@@ -376,6 +420,50 @@ func PoolUpdate(name string, opts UpdatePoolOptions) error {
 	return err
 }
 
+// SetPoolDefaults replaces the app defaults of the pool. The default team
+// owner and router must be allowed by the pool constraints.
+func SetPoolDefaults(name string, defaults PoolDefaults) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	pool, err := GetPoolByName(name)
+	if err != nil {
+		return err
+	}
+	if defaults.TeamOwner != "" {
+		err = pool.checkDefault("team", defaults.TeamOwner, pool.GetTeams)
+		if err != nil {
+			return err
+		}
+	}
+	if defaults.Router != "" {
+		err = pool.checkDefault("router", defaults.Router, pool.GetRouters)
+		if err != nil {
+			return err
+		}
+	}
+	err = conn.Pools().UpdateId(name, bson.M{"$set": bson.M{"defaults": defaults}})
+	if err == mgo.ErrNotFound {
+		return ErrPoolNotFound
+	}
+	return err
+}
+
+func (p *Pool) checkDefault(field, value string, allowed func() ([]string, error)) error {
+	values, err := allowed()
+	if err != nil && err != ErrPoolHasNoTeam && err != ErrPoolHasNoRouter {
+		return err
+	}
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	return &ErrInvalidPoolDefault{Pool: p.Name, Field: field, Value: value}
+}
+
 type PoolConstraint struct {
 	PoolExpr  string
 	Field     string
@@ -311,6 +311,24 @@ func (s *S) TestPoolUpdate(c *check.C) {
 	c.Assert(constraint.AllowsAll(), check.Equals, true)
 }
 
+func (s *S) TestPoolUpdateLabels(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Labels: map[string]string{"cost-center": "1234", "empty": ""}})
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Labels, check.DeepEquals, map[string]string{"cost-center": "1234"})
+	err = PoolUpdate("pool1", UpdatePoolOptions{Labels: map[string]string{"product": "store"}})
+	c.Assert(err, check.IsNil)
+	pool, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Labels, check.DeepEquals, map[string]string{"cost-center": "1234", "product": "store"})
+	err = PoolUpdate("pool1", UpdatePoolOptions{Labels: map[string]string{"cost-center": "", "product": ""}})
+	c.Assert(err, check.IsNil)
+	pool, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Labels, check.HasLen, 0)
+}
+
 func (s *S) TestPoolUpdateToDefault(c *check.C) {
 	opts := AddPoolOptions{
 		Name:    "pool1",
@@ -565,3 +583,49 @@ func (s *S) TestPoolAllowedValues(c *check.C) {
 		"router": {"router", "router1", "router2"},
 	})
 }
+
+func (s *S) TestSetPoolDefaults(c *check.C) {
+	config.Set("routers:router1:type", "hipache")
+	config.Set("routers:router2:type", "hipache")
+	defer config.Unset("routers")
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool1", []string{"ateam"})
+	c.Assert(err, check.IsNil)
+	defaults := PoolDefaults{Plan: "small", TeamOwner: "ateam", Router: "router2", Tags: []string{"tag1"}}
+	err = SetPoolDefaults("pool1", defaults)
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Defaults, check.DeepEquals, defaults)
+	err = SetPoolDefaults("pool1", PoolDefaults{})
+	c.Assert(err, check.IsNil)
+	pool, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Defaults, check.DeepEquals, PoolDefaults{})
+}
+
+func (s *S) TestSetPoolDefaultsNotFound(c *check.C) {
+	err := SetPoolDefaults("notfound", PoolDefaults{Plan: "small"})
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
+func (s *S) TestSetPoolDefaultsValidatesConstraints(c *check.C) {
+	config.Set("routers:router1:type", "hipache")
+	config.Set("routers:router2:type", "hipache")
+	defer config.Unset("routers")
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool1", []string{"ateam"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool1", Field: "router", Values: []string{"router1"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolDefaults("pool1", PoolDefaults{TeamOwner: "test"})
+	c.Assert(err, check.DeepEquals, &ErrInvalidPoolDefault{Pool: "pool1", Field: "team", Value: "test"})
+	c.Assert(err, check.ErrorMatches, `team "test" is not allowed in pool "pool1"`)
+	err = SetPoolDefaults("pool1", PoolDefaults{Router: "router2"})
+	c.Assert(err, check.DeepEquals, &ErrInvalidPoolDefault{Pool: "pool1", Field: "router", Value: "router2"})
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Defaults, check.DeepEquals, PoolDefaults{})
+}
@@ -7,6 +7,7 @@
 package provision
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -22,6 +23,17 @@ import (
 
 const defaultDockerProvisioner = "docker"
 
+// Phases of a deploy timed by provisioners in the deploy event, see
+// event.Event.StartPhase.
+const (
+	DeployPhaseUpload      = "upload"
+	DeployPhaseBuild       = "build"
+	DeployPhaseImagePush   = "image-push"
+	DeployPhaseUnitStart   = "unit-start"
+	DeployPhaseHealthcheck = "healthcheck"
+	DeployPhaseRouteUpdate = "route-update"
+)
+
 var (
 	ErrInvalidStatus = errors.New("invalid status")
 	ErrEmptyApp      = errors.New("no units for this app")
@@ -143,6 +155,9 @@ type Unit struct {
 	Ip          string
 	Status      Status
 	Address     *url.URL
+	// Restarts is the number of times the unit was restarted by the
+	// provisioner after crashing, when it's tracked by the provisioner.
+	Restarts int `json:",omitempty"`
 }
 
 // GetName returns the name of the unit.
@@ -217,13 +232,81 @@ type App interface {
 	SetUpdatePlatform(bool) error
 	GetUpdatePlatform() bool
 
+	// GetBuildCacheDisabled returns whether builds of the app must always
+	// start from the platform image, instead of reusing the last app image.
+	GetBuildCacheDisabled() bool
+
 	GetRouterName() (string, error)
 
 	GetPool() string
 
+	GetTeamOwner() string
+
 	SetQuotaInUse(int) error
 }
 
+// ProcessPlanApp is an app whose processes may have plans other than the plan
+// of the app.
+type ProcessPlanApp interface {
+	GetProcessMemory(process string) int64
+	GetProcessSwap(process string) int64
+	GetProcessCpuShare(process string) int
+}
+
+// ProcessResources returns the memory and swap limits (in bytes) and the cpu
+// share for units of the process of the app.
+func ProcessResources(a App, process string) (memory, swap int64, cpuShare int) {
+	if pa, ok := a.(ProcessPlanApp); ok {
+		return pa.GetProcessMemory(process), pa.GetProcessSwap(process), pa.GetProcessCpuShare(process)
+	}
+	return a.GetMemory(), a.GetSwap(), a.GetCpuShare()
+}
+
+const (
+	RestartPolicyAlways    = "always"
+	RestartPolicyOnFailure = "on-failure"
+	RestartPolicyNever     = "never"
+)
+
+// RestartPolicies are the valid policies for restarting the units of an app.
+var RestartPolicies = []string{RestartPolicyAlways, RestartPolicyOnFailure, RestartPolicyNever}
+
+// AppHealthcheck is the healthcheck configured in an app, taking precedence
+// over the healthcheck in the tsuru.yaml of the app, along with the policy for
+// restarting its units. Interval and Timeout are in seconds, zero values use
+// the defaults of the provisioner. FailureThreshold is the number of
+// consecutive failed checks before a unit is considered unhealthy.
+type AppHealthcheck struct {
+	Path             string
+	Interval         int
+	Timeout          int
+	FailureThreshold int    `json:"failure_threshold" bson:"failure_threshold"`
+	RestartPolicy    string `json:"restart_policy" bson:"restart_policy"`
+}
+
+// HealthcheckApp is an app that may have a healthcheck configured in the app.
+type HealthcheckApp interface {
+	GetHealthcheck() *AppHealthcheck
+}
+
+// HealthcheckForApp returns the healthcheck configured in the app, or nil
+// when it has none.
+func HealthcheckForApp(a App) *AppHealthcheck {
+	if ha, ok := a.(HealthcheckApp); ok {
+		return ha.GetHealthcheck()
+	}
+	return nil
+}
+
+// RestartPolicyForApp returns the policy for restarting the units of the app,
+// RestartPolicyAlways when the app has none.
+func RestartPolicyForApp(a App) string {
+	if hc := HealthcheckForApp(a); hc != nil && hc.RestartPolicy != "" {
+		return hc.RestartPolicy
+	}
+	return RestartPolicyAlways
+}
+
 type AppLock interface {
 	json.Marshaler
 
@@ -247,6 +330,12 @@ type ShellOptions struct {
 	Term   string
 }
 
+// ImageSizer is a provisioner that can report the size of images.
+type ImageSizer interface {
+	// ImageSize returns the size, in bytes, of the image.
+	ImageSize(image string) (int64, error)
+}
+
 // ArchiveDeployer is a provisioner that can deploy archives.
 type ArchiveDeployer interface {
 	ArchiveDeploy(app App, archiveURL string, evt *event.Event) (string, error)
@@ -264,6 +353,88 @@ type ImageDeployer interface {
 	ImageDeploy(app App, image string, evt *event.Event) (string, error)
 }
 
+// UnitGateResult is the result of gating the traffic to a new unit on its
+// healthcheck. Units failing the healthcheck are either removed or kept
+// without routes, according to the failure policy of the provisioner.
+type UnitGateResult struct {
+	ID      string
+	Process string
+	Checked bool
+	Healthy bool
+	Routed  bool
+	Removed bool
+	Error   string `json:",omitempty"`
+}
+
+// GatedUnitsAdder is a provisioner that only adds new units to the router
+// after their healthcheck passes, reporting the result for each unit.
+type GatedUnitsAdder interface {
+	AddUnitsGated(app App, units uint, process string, w io.Writer) ([]UnitGateResult, error)
+}
+
+// RollingUpdate controls how units of a process are replaced during a
+// rolling restart: MaxSurge is the number of units that may be created above
+// the current quantity and MaxUnavailable the number of units that may be
+// removed before their replacements are healthy. When both are zero units
+// are replaced one at a time, with a surge of one unit.
+type RollingUpdate struct {
+	MaxSurge       int `json:",omitempty"`
+	MaxUnavailable int `json:",omitempty"`
+}
+
+// Normalized returns the update, with the default surge when both limits are
+// zero.
+func (u RollingUpdate) Normalized() RollingUpdate {
+	if u.MaxSurge <= 0 && u.MaxUnavailable <= 0 {
+		return RollingUpdate{MaxSurge: 1}
+	}
+	if u.MaxSurge < 0 {
+		u.MaxSurge = 0
+	}
+	if u.MaxUnavailable < 0 {
+		u.MaxUnavailable = 0
+	}
+	return u
+}
+
+// RestartProgress is the progress of a rolling restart. Restarted holds the
+// ids of the units already restarted and Missing the number of units of each
+// process removed but not replaced yet, both are skipped or replaced when
+// the restart is resumed.
+type RestartProgress struct {
+	Process   string
+	Updates   map[string]RollingUpdate `json:",omitempty"`
+	Restarted []string
+	Missing   map[string]int `json:",omitempty"`
+}
+
+// Update returns the rolling update of the process, the update of the empty
+// process is used for processes without one.
+func (p *RestartProgress) Update(process string) RollingUpdate {
+	if u, ok := p.Updates[process]; ok {
+		return u.Normalized()
+	}
+	return p.Updates[""].Normalized()
+}
+
+// RollingRestartArgs are the arguments of a rolling restart. Progress is
+// the progress of a previous interrupted restart, to be resumed, and
+// OnProgress is called after each batch of replaced units. The restart stops
+// between batches when Event is canceled or the context of the restart is
+// done.
+type RollingRestartArgs struct {
+	Progress   RestartProgress
+	OnProgress func(RestartProgress)
+	Event      *event.Event
+}
+
+// RollingRestarter is a provisioner that restarts the units of an app in
+// batches, stopping on the first batch whose units aren't healthy. The
+// restart can be resumed from the reported progress.
+type RollingRestarter interface {
+	RollingRestart(ctx context.Context, app App, args RollingRestartArgs, w io.Writer) error
+}
+
 // RollbackableDeployer is a provisioner that allows rolling back to a
 // previously deployed version.
 type RollbackableDeployer interface {
@@ -302,18 +473,20 @@ type Provisioner interface {
 	// Restart restarts the units of the application, with an optional
 	// string parameter represeting the name of the process to start. When
 	// the process is empty, Restart will restart all units of the
-	// application.
-	Restart(App, string, io.Writer) error
+	// application. The restart is aborted when the context is done.
+	Restart(context.Context, App, string, io.Writer) error
 
 	// Start starts the units of the application, with an optional string
 	// parameter representing the name of the process to start. When the
 	// process is empty, Start will start all units of the application.
-	Start(App, string) error
+	// Starting is aborted when the context is done.
+	Start(context.Context, App, string) error
 
 	// Stop stops the units of the application, with an optional string
 	// parameter representing the name of the process to start. When the
 	// process is empty, Stop will stop all units of the application.
-	Stop(App, string) error
+	// Stopping is aborted when the context is done.
+	Stop(context.Context, App, string) error
 
 	// Units returns information about units by App.
 	Units(App) ([]Unit, error)
@@ -380,6 +553,37 @@ type UnitStatusProvisioner interface {
 	SetUnitStatus(Unit, Status) error
 }
 
+// MetricsOpts is the window of the metrics of the units, with one point for
+// every step between Start and End.
+type MetricsOpts struct {
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// MetricPoint is the value of a metric at a given time.
+type MetricPoint struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// UnitMetrics holds the resource usage of a unit over time. CPU is the
+// percentage of one core, Memory is in bytes and NetworkRx and NetworkTx are
+// in bytes per second.
+type UnitMetrics struct {
+	ID        string
+	CPU       []MetricPoint
+	Memory    []MetricPoint
+	NetworkRx []MetricPoint
+	NetworkTx []MetricPoint
+}
+
+// MetricsProvisioner is a provisioner that reports the resource usage of the
+// units of an app.
+type MetricsProvisioner interface {
+	UnitsMetrics(App, MetricsOpts) ([]UnitMetrics, error)
+}
+
 type AddNodeOptions struct {
 	Address    string
 	Metadata   map[string]string
@@ -431,6 +635,30 @@ type RebalanceNodesOptions struct {
 	Force          bool
 }
 
+// NodeCredentials are the TLS credentials used to access a node.
+type NodeCredentials struct {
+	CaCert     []byte
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+// UsesTLS returns whether the credentials hold TLS certificates.
+func (c *NodeCredentials) UsesTLS() bool {
+	return len(c.CaCert) > 0 || len(c.ClientCert) > 0
+}
+
+// NodeCredentialsProvisioner is a provisioner whose nodes are accessed using
+// credentials that can be replaced.
+type NodeCredentialsProvisioner interface {
+	// NodeCredentials returns the credentials currently used to access the
+	// node.
+	NodeCredentials(address string) (*NodeCredentials, error)
+
+	// UpdateNodeCredentials checks the node is reachable using creds before
+	// storing them as the credentials used to access it.
+	UpdateNodeCredentials(address string, creds NodeCredentials) error
+}
+
 type NodeRebalanceProvisioner interface {
 	RebalanceNodes(RebalanceNodesOptions) (bool, error)
 }
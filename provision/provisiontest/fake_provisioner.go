@@ -5,6 +5,7 @@
 package provisiontest
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -30,7 +31,10 @@ var (
 	errNotProvisioned         = &provision.Error{Reason: "App is not provisioned."}
 	uniqueIpCounter     int32 = 0
 
-	_ provision.NodeProvisioner = &FakeProvisioner{}
+	_ provision.NodeProvisioner            = &FakeProvisioner{}
+	_ provision.NodeCredentialsProvisioner = &FakeProvisioner{}
+	_ provision.StrategyDeployer           = &FakeProvisioner{}
+	_ provision.MetricsProvisioner         = &FakeProvisioner{}
 )
 
 const fakeAppImage = "app-image"
@@ -50,28 +54,35 @@ func init() {
 
 // Fake implementation for provision.App.
 type FakeApp struct {
-	name           string
-	cname          []string
-	Ip             string
-	platform       string
-	units          []provision.Unit
-	logs           []string
-	logMut         sync.Mutex
-	Commands       []string
-	Memory         int64
-	Swap           int64
-	CpuShare       int
-	commMut        sync.Mutex
-	Deploys        uint
-	env            map[string]bind.EnvVar
-	bindCalls      []*provision.Unit
-	bindLock       sync.Mutex
-	instances      map[string][]bind.ServiceInstance
-	instancesLock  sync.Mutex
-	Pool           string
-	UpdatePlatform bool
-	TeamOwner      string
-	Teams          []string
+	name               string
+	cname              []string
+	Ip                 string
+	platform           string
+	units              []provision.Unit
+	logs               []string
+	logMut             sync.Mutex
+	Commands           []string
+	Memory             int64
+	Swap               int64
+	CpuShare           int
+	commMut            sync.Mutex
+	Deploys            uint
+	env                map[string]bind.EnvVar
+	bindCalls          []*provision.Unit
+	bindLock           sync.Mutex
+	instances          map[string][]bind.ServiceInstance
+	instancesLock      sync.Mutex
+	Pool               string
+	UpdatePlatform     bool
+	BuildCacheDisabled bool
+	TeamOwner          string
+	Teams              []string
+	// Router is the name of the router of the app, "fake" when empty.
+	Router string
+	// Strategy is the strategy returned by GetDeployStrategy.
+	Strategy provision.DeployStrategy
+	// Healthcheck is the healthcheck returned by GetHealthcheck.
+	Healthcheck *provision.AppHealthcheck
 	quota.Quota
 }
 
@@ -117,6 +128,10 @@ func (a *FakeApp) GetCpuShare() int {
 	return a.CpuShare
 }
 
+func (a *FakeApp) GetHealthcheck() *provision.AppHealthcheck {
+	return a.Healthcheck
+}
+
 func (a *FakeApp) HasBind(unit *provision.Unit) bool {
 	a.bindLock.Lock()
 	defer a.bindLock.Unlock()
@@ -256,6 +271,10 @@ func (a *FakeApp) GetPool() string {
 	return a.Pool
 }
 
+func (a *FakeApp) GetTeamOwner() string {
+	return a.TeamOwner
+}
+
 func (a *FakeApp) GetPlatform() string {
 	return a.platform
 }
@@ -336,6 +355,10 @@ func (a *FakeApp) GetUpdatePlatform() bool {
 	return a.UpdatePlatform
 }
 
+func (a *FakeApp) GetBuildCacheDisabled() bool {
+	return a.BuildCacheDisabled
+}
+
 func (a *FakeApp) SetUpdatePlatform(check bool) error {
 	a.commMut.Lock()
 	a.UpdatePlatform = check
@@ -344,9 +367,16 @@ func (a *FakeApp) SetUpdatePlatform(check bool) error {
 }
 
 func (app *FakeApp) GetRouterName() (string, error) {
+	if app.Router != "" {
+		return app.Router, nil
+	}
 	return "fake", nil
 }
 
+func (app *FakeApp) GetDeployStrategy() provision.DeployStrategy {
+	return app.Strategy
+}
+
 type Cmd struct {
 	Cmd  string
 	Args []string
@@ -403,6 +433,7 @@ type FakeNode struct {
 	p          *FakeProvisioner
 	failures   int
 	hasSuccess bool
+	creds      provision.NodeCredentials
 }
 
 func (n *FakeNode) Pool() string {
@@ -475,6 +506,11 @@ func (p *FakeProvisioner) AddNode(opts provision.AddNodeOptions) error {
 		Meta:     metadata,
 		p:        p,
 		status:   "enabled",
+		creds: provision.NodeCredentials{
+			CaCert:     opts.CaCert,
+			ClientCert: opts.ClientCert,
+			ClientKey:  opts.ClientKey,
+		},
 	}
 	return nil
 }
@@ -539,6 +575,35 @@ func (p *FakeProvisioner) UpdateNode(opts provision.UpdateNodeOptions) error {
 	return nil
 }
 
+func (p *FakeProvisioner) NodeCredentials(address string) (*provision.NodeCredentials, error) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	n, ok := p.nodes[address]
+	if !ok {
+		return nil, provision.ErrNodeNotFound
+	}
+	creds := n.creds
+	return &creds, nil
+}
+
+// UpdateNodeCredentials stores the credentials of the node, failures prepared
+// for "UpdateNodeCredentials:<address>" simulate nodes that can't be reached
+// with the new credentials.
+func (p *FakeProvisioner) UpdateNodeCredentials(address string, creds provision.NodeCredentials) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if err := p.getError("UpdateNodeCredentials:" + address); err != nil {
+		return err
+	}
+	n, ok := p.nodes[address]
+	if !ok {
+		return provision.ErrNodeNotFound
+	}
+	n.creds = creds
+	p.nodes[address] = n
+	return nil
+}
+
 type nodeList []provision.Node
 
 func (l nodeList) Len() int           { return len(l) }
@@ -799,6 +864,7 @@ func (p *FakeProvisioner) ArchiveDeploy(app provision.App, archiveURL string, ev
 		return "", errNotProvisioned
 	}
 	evt.Write([]byte("Archive deploy called"))
+	pApp.lastStrategy = provision.GetDeployStrategy(app)
 	pApp.lastArchive = archiveURL
 	p.apps[app.GetName()] = pApp
 	return fakeAppImage, nil
@@ -815,6 +881,7 @@ func (p *FakeProvisioner) UploadDeploy(app provision.App, file io.ReadCloser, fi
 		return "", errNotProvisioned
 	}
 	evt.Write([]byte("Upload deploy called"))
+	pApp.lastStrategy = provision.GetDeployStrategy(app)
 	pApp.lastFile = file
 	p.apps[app.GetName()] = pApp
 	return fakeAppImage, nil
@@ -832,6 +899,7 @@ func (p *FakeProvisioner) ImageDeploy(app provision.App, img string, evt *event.
 	}
 	pApp.image = img
 	evt.Write([]byte("Image deploy called"))
+	pApp.lastStrategy = provision.GetDeployStrategy(app)
 	p.apps[app.GetName()] = pApp
 	return img, nil
 }
@@ -847,6 +915,7 @@ func (p *FakeProvisioner) Rollback(app provision.App, img string, evt *event.Eve
 		return "", errNotProvisioned
 	}
 	evt.Write([]byte("Rollback deploy called"))
+	pApp.lastStrategy = provision.GetDeployStrategy(app)
 	p.apps[app.GetName()] = pApp
 	return img, nil
 }
@@ -862,10 +931,24 @@ func (p *FakeProvisioner) Rebuild(app provision.App, evt *event.Event) (string,
 		return "", errNotProvisioned
 	}
 	evt.Write([]byte("Rebuild deploy called"))
+	pApp.lastStrategy = provision.GetDeployStrategy(app)
 	p.apps[app.GetName()] = pApp
 	return fakeAppImage, nil
 }
 
+// ValidateDeployStrategy accepts every strategy, failing with the errors
+// queued for ValidateDeployStrategy.
+func (p *FakeProvisioner) ValidateDeployStrategy(app provision.App, strategy provision.DeployStrategy) error {
+	return p.getError("ValidateDeployStrategy")
+}
+
+// LastDeployStrategy returns the strategy of the last deploy of the app.
+func (p *FakeProvisioner) LastDeployStrategy(app provision.App) provision.DeployStrategy {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].lastStrategy
+}
+
 func (p *FakeProvisioner) Provision(app provision.App) error {
 	if err := p.getError("Provision"); err != nil {
 		return err
@@ -885,10 +968,15 @@ func (p *FakeProvisioner) Provision(app provision.App) error {
 	return nil
 }
 
-func (p *FakeProvisioner) Restart(app provision.App, process string, w io.Writer) error {
+// Restart counts a restart of the process, failing with the errors queued
+// for Restart or with the error of ctx once it's done.
+func (p *FakeProvisioner) Restart(ctx context.Context, app provision.App, process string, w io.Writer) error {
 	if err := p.getError("Restart"); err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	p.mut.Lock()
 	defer p.mut.Unlock()
 	pApp, ok := p.apps[app.GetName()]
@@ -903,7 +991,63 @@ func (p *FakeProvisioner) Restart(app provision.App, process string, w io.Writer
 	return nil
 }
 
-func (p *FakeProvisioner) Start(app provision.App, process string) error {
+// RollingRestart restarts the units of the app not restarted yet, reporting
+// the progress after each unit. It counts as a restart of the process and
+// fails with the errors queued for Restart or with the error of ctx once
+// it's done.
+func (p *FakeProvisioner) RollingRestart(ctx context.Context, app provision.App, args provision.RollingRestartArgs, w io.Writer) error {
+	if err := p.getError("Restart"); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		p.mut.Unlock()
+		return errNotProvisioned
+	}
+	progress := args.Progress
+	restarted := map[string]bool{}
+	for _, id := range progress.Restarted {
+		restarted[id] = true
+	}
+	var toRestart []string
+	for _, u := range pApp.units {
+		if !restarted[u.ID] && (progress.Process == "" || u.ProcessName == progress.Process) {
+			toRestart = append(toRestart, u.ID)
+		}
+	}
+	pApp.restarts[progress.Process]++
+	last := args.Progress
+	pApp.lastRestart = &last
+	p.apps[app.GetName()] = pApp
+	p.mut.Unlock()
+	for _, id := range toRestart {
+		progress.Restarted = append(progress.Restarted, id)
+		if args.OnProgress != nil {
+			args.OnProgress(progress)
+		}
+	}
+	if w != nil {
+		fmt.Fprintf(w, "restarting app")
+	}
+	return nil
+}
+
+// LastRestartProgress returns the progress the last rolling restart of the
+// app started with.
+func (p *FakeProvisioner) LastRestartProgress(a provision.App) *provision.RestartProgress {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[a.GetName()].lastRestart
+}
+
+func (p *FakeProvisioner) Start(ctx context.Context, app provision.App, process string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	p.mut.Lock()
 	defer p.mut.Unlock()
 	pApp, ok := p.apps[app.GetName()]
@@ -933,6 +1077,20 @@ func (p *FakeProvisioner) AddUnits(app provision.App, n uint, process string, w
 	return err
 }
 
+// AddUnitsGated adds units like AddUnits, reporting all of them as healthy
+// and routed.
+func (p *FakeProvisioner) AddUnitsGated(app provision.App, n uint, process string, w io.Writer) ([]provision.UnitGateResult, error) {
+	units, err := p.AddUnitsToNode(app, n, process, w, "")
+	if err != nil {
+		return nil, err
+	}
+	results := make([]provision.UnitGateResult, len(units))
+	for i, u := range units {
+		results[i] = provision.UnitGateResult{ID: u.ID, Process: u.ProcessName, Checked: true, Healthy: true, Routed: true}
+	}
+	return results, nil
+}
+
 func (p *FakeProvisioner) AddUnitsToNode(app provision.App, n uint, process string, w io.Writer, nodeAddr string) ([]provision.Unit, error) {
 	if err := p.getError("AddUnits"); err != nil {
 		return nil, err
@@ -1161,6 +1319,26 @@ func (p *FakeProvisioner) Units(app provision.App) ([]provision.Unit, error) {
 	return p.apps[app.GetName()].units, nil
 }
 
+// UnitsMetrics returns constant metrics for each unit of the app: 10% of CPU,
+// 64MiB of memory, 1KB/s received and 2KB/s sent, one point every step.
+func (p *FakeProvisioner) UnitsMetrics(app provision.App, opts provision.MetricsOpts) ([]provision.UnitMetrics, error) {
+	if err := p.getError("UnitsMetrics"); err != nil {
+		return nil, err
+	}
+	units, _ := p.Units(app)
+	metrics := make([]provision.UnitMetrics, len(units))
+	for i, u := range units {
+		metrics[i].ID = u.ID
+		for t := opts.Start; opts.Step > 0 && !t.After(opts.End); t = t.Add(opts.Step) {
+			metrics[i].CPU = append(metrics[i].CPU, provision.MetricPoint{Timestamp: t, Value: 10})
+			metrics[i].Memory = append(metrics[i].Memory, provision.MetricPoint{Timestamp: t, Value: 64 * 1024 * 1024})
+			metrics[i].NetworkRx = append(metrics[i].NetworkRx, provision.MetricPoint{Timestamp: t, Value: 1024})
+			metrics[i].NetworkTx = append(metrics[i].NetworkTx, provision.MetricPoint{Timestamp: t, Value: 2048})
+		}
+	}
+	return metrics, nil
+}
+
 func (p *FakeProvisioner) RoutableAddresses(app provision.App) ([]url.URL, error) {
 	p.mut.Lock()
 	defer p.mut.Unlock()
@@ -1259,7 +1437,10 @@ func (p *FakeProvisioner) HasCName(app provision.App, cname string) bool {
 	return false
 }
 
-func (p *FakeProvisioner) Stop(app provision.App, process string) error {
+func (p *FakeProvisioner) Stop(ctx context.Context, app provision.App, process string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	p.mut.Lock()
 	defer p.mut.Unlock()
 	pApp, ok := p.apps[app.GetName()]
@@ -1477,18 +1658,20 @@ func (p *ExtensibleFakeProvisioner) PlatformRemove(name string) error {
 }
 
 type provisionedApp struct {
-	units       []provision.Unit
-	app         provision.App
-	restarts    map[string]int
-	starts      map[string]int
-	stops       map[string]int
-	sleeps      map[string]int
-	lastArchive string
-	lastFile    io.ReadCloser
-	cnames      []string
-	unitLen     int
-	lastData    map[string]interface{}
-	image       string
+	units        []provision.Unit
+	app          provision.App
+	restarts     map[string]int
+	starts       map[string]int
+	stops        map[string]int
+	sleeps       map[string]int
+	lastArchive  string
+	lastFile     io.ReadCloser
+	cnames       []string
+	unitLen      int
+	lastData     map[string]interface{}
+	image        string
+	lastRestart  *provision.RestartProgress
+	lastStrategy provision.DeployStrategy
 }
 
 type provisionedPlatform struct {
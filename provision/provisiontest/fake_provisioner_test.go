@@ -6,10 +6,12 @@ package provisiontest
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
@@ -413,6 +415,24 @@ func (s *S) TestGetUnits(c *check.C) {
 	c.Assert(units, check.DeepEquals, list)
 }
 
+func (s *S) TestUnitsMetrics(c *check.C) {
+	app := NewFakeApp("chain-lighting", "rush", 0)
+	p := NewFakeProvisioner()
+	p.apps = map[string]provisionedApp{
+		app.GetName(): {app: app, units: []provision.Unit{{ID: "chain-lighting-0"}}},
+	}
+	start := time.Date(2017, 10, 14, 10, 0, 0, 0, time.UTC)
+	metrics, err := p.UnitsMetrics(app, provision.MetricsOpts{Start: start, End: start.Add(2 * time.Minute), Step: time.Minute})
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.HasLen, 1)
+	c.Assert(metrics[0].ID, check.Equals, "chain-lighting-0")
+	c.Assert(metrics[0].CPU, check.HasLen, 3)
+	c.Assert(metrics[0].CPU[2], check.Equals, provision.MetricPoint{Timestamp: start.Add(2 * time.Minute), Value: 10})
+	c.Assert(metrics[0].Memory, check.HasLen, 3)
+	c.Assert(metrics[0].NetworkRx, check.HasLen, 3)
+	c.Assert(metrics[0].NetworkTx, check.HasLen, 3)
+}
+
 func (s *S) TestPrepareOutput(c *check.C) {
 	output := []byte("the body eletric")
 	p := NewFakeProvisioner()
@@ -614,7 +634,7 @@ func (s *S) TestRestart(c *check.C) {
 	c.Assert(err, check.IsNil)
 	p := NewFakeProvisioner()
 	p.Provision(a)
-	err = p.Restart(a, "web", nil)
+	err = p.Restart(context.Background(), a, "web", nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(p.Restarts(a, "web"), check.Equals, 1)
 }
@@ -623,9 +643,9 @@ func (s *S) TestStart(c *check.C) {
 	app := NewFakeApp("kid-gloves", "rush", 1)
 	p := NewFakeProvisioner()
 	p.Provision(app)
-	err := p.Start(app, "")
+	err := p.Start(context.Background(), app, "")
 	c.Assert(err, check.IsNil)
-	err = p.Start(app, "web")
+	err = p.Start(context.Background(), app, "web")
 	c.Assert(err, check.IsNil)
 	c.Assert(p.Starts(app, ""), check.Equals, 1)
 	c.Assert(p.Starts(app, "web"), check.Equals, 1)
@@ -635,7 +655,7 @@ func (s *S) TestStop(c *check.C) {
 	app := NewFakeApp("kid-gloves", "rush", 1)
 	p := NewFakeProvisioner()
 	p.Provision(app)
-	err := p.Stop(app, "")
+	err := p.Stop(context.Background(), app, "")
 	c.Assert(err, check.IsNil)
 	c.Assert(p.Stops(app, ""), check.Equals, 1)
 }
@@ -652,15 +672,32 @@ func (s *S) TestSleep(c *check.C) {
 func (s *S) TestRestartNotProvisioned(c *check.C) {
 	app := NewFakeApp("kid-gloves", "rush", 1)
 	p := NewFakeProvisioner()
-	err := p.Restart(app, "web", nil)
+	err := p.Restart(context.Background(), app, "web", nil)
 	c.Assert(err, check.Equals, errNotProvisioned)
 }
 
+func (s *S) TestRestartContextCanceled(c *check.C) {
+	app := NewFakeApp("kid-gloves", "rush", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := p.Restart(ctx, app, "web", nil)
+	c.Assert(err, check.Equals, context.Canceled)
+	err = p.Start(ctx, app, "web")
+	c.Assert(err, check.Equals, context.Canceled)
+	err = p.Stop(ctx, app, "web")
+	c.Assert(err, check.Equals, context.Canceled)
+	c.Assert(p.Restarts(app, "web"), check.Equals, 0)
+	c.Assert(p.Starts(app, "web"), check.Equals, 0)
+	c.Assert(p.Stops(app, "web"), check.Equals, 0)
+}
+
 func (s *S) TestRestartWithPreparedFailure(c *check.C) {
 	app := NewFakeApp("fairy-tale", "shaman", 1)
 	p := NewFakeProvisioner()
 	p.PrepareFailure("Restart", errors.New("Failed to restart."))
-	err := p.Restart(app, "web", nil)
+	err := p.Restart(context.Background(), app, "web", nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, "Failed to restart.")
 }
@@ -0,0 +1,265 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrRegistryCredentialNotFound    = errors.New("registry credential not found")
+	ErrRegistryServerRequired        = errors.New("registry server is required")
+	ErrRegistryUserRequired          = errors.New("registry username and password are required")
+	ErrRegistryScopeRequired         = errors.New("registry credentials must be scoped to either a pool or a team")
+	ErrRegistryCredentialsKeyMissing = errors.New("registry-credentials:key must be set to store registry credentials")
+
+	registryAuthErrorMessages = []string{
+		"unauthorized",
+		"authentication required",
+		"access denied",
+		"denied: requested access",
+		"no basic auth credentials",
+	}
+)
+
+// RegistryCredential holds the credentials of a private docker registry used
+// to pull and push the images of the apps in a pool or owned by a team.
+// Credentials of the pool of an app take precedence over the ones of its team
+// owner. The password is stored encrypted and never returned by the API.
+type RegistryCredential struct {
+	Server            string
+	Pool              string
+	Team              string
+	Username          string
+	Email             string `bson:",omitempty"`
+	Password          string `json:"-" bson:"-"`
+	EncryptedPassword string `json:"-"`
+	UpdatedAt         time.Time
+}
+
+func (c *RegistryCredential) scope() string {
+	if c.Pool != "" {
+		return fmt.Sprintf("pool %q", c.Pool)
+	}
+	return fmt.Sprintf("team %q", c.Team)
+}
+
+// SetRegistryCredential stores the credentials of a registry for the pool or
+// the team, replacing the current ones, which is how credentials are rotated.
+func SetRegistryCredential(c *RegistryCredential) error {
+	c.Server = normalizeRegistryServer(c.Server)
+	if c.Server == "" {
+		return ErrRegistryServerRequired
+	}
+	if (c.Pool == "") == (c.Team == "") {
+		return ErrRegistryScopeRequired
+	}
+	if c.Username == "" || c.Password == "" {
+		return ErrRegistryUserRequired
+	}
+	var err error
+	if c.Pool != "" {
+		_, err = GetPoolByName(c.Pool)
+	} else {
+		_, err = auth.GetTeam(c.Team)
+	}
+	if err != nil {
+		return err
+	}
+	c.EncryptedPassword, err = encryptRegistryPassword(c.Password)
+	if err != nil {
+		return err
+	}
+	c.UpdatedAt = time.Now().UTC()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.RegistryCredentials().Upsert(bson.M{"server": c.Server, "pool": c.Pool, "team": c.Team}, c)
+	return err
+}
+
+// RemoveRegistryCredential removes the credentials of a registry from the
+// pool or the team.
+func RemoveRegistryCredential(server, pool, team string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.RegistryCredentials().Remove(bson.M{"server": normalizeRegistryServer(server), "pool": pool, "team": team})
+	if err == mgo.ErrNotFound {
+		return ErrRegistryCredentialNotFound
+	}
+	return err
+}
+
+// ListRegistryCredentials returns the registry credentials matching the
+// query, sorted by server. Passwords are not decrypted.
+func ListRegistryCredentials(query bson.M) ([]RegistryCredential, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	creds := []RegistryCredential{}
+	err = conn.RegistryCredentials().Find(query).Sort("server", "pool", "team").All(&creds)
+	if err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// RegistryCredentialForApp returns the decrypted credentials used to pull
+// and push images of the app from the registry server, or nil if there are
+// no credentials of the server registered for the pool or the team owner of
+// the app.
+func RegistryCredentialForApp(app App, server string) (*RegistryCredential, error) {
+	if server == "" {
+		return nil, nil
+	}
+	creds, err := ListRegistryCredentials(bson.M{
+		"server": server,
+		"$or": []bson.M{
+			{"pool": app.GetPool(), "team": ""},
+			{"pool": "", "team": app.GetTeamOwner()},
+		},
+	})
+	if err != nil || len(creds) == 0 {
+		return nil, err
+	}
+	cred := creds[0]
+	for _, c := range creds {
+		if c.Pool != "" {
+			cred = c
+		}
+	}
+	cred.Password, err = decryptRegistryPassword(cred.EncryptedPassword)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt the credentials of registry %q", server)
+	}
+	return &cred, nil
+}
+
+// ImageRegistry returns the registry server of the image, or an empty string
+// for images of the docker hub.
+func ImageRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	if parts[0] != "localhost" && !strings.ContainsAny(parts[0], ".:") {
+		return ""
+	}
+	return parts[0]
+}
+
+func normalizeRegistryServer(server string) string {
+	server = strings.TrimSpace(server)
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+	return strings.TrimRight(server, "/")
+}
+
+func registryCipher() (cipher.AEAD, error) {
+	key, _ := config.GetString("registry-credentials:key")
+	if key == "" {
+		return nil, ErrRegistryCredentialsKeyMissing
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptRegistryPassword(password string) (string, error) {
+	aead, err := registryCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(password), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptRegistryPassword(encrypted string) (string, error) {
+	aead, err := registryCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted password")
+	}
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	password, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// ErrRegistryAuth is returned when a registry denies access to the image of
+// an app, either because the registered credentials were rejected or because
+// the registry requires credentials and none are registered.
+type ErrRegistryAuth struct {
+	Image      string
+	Server     string
+	Credential *RegistryCredential
+	Err        error
+}
+
+func (e *ErrRegistryAuth) Error() string {
+	msg := fmt.Sprintf("registry %q denied access", e.Server)
+	if e.Image != "" {
+		msg += fmt.Sprintf(" to image %q", e.Image)
+	}
+	if e.Credential == nil {
+		return fmt.Sprintf("%s and no credentials for it are registered in the pool or the team of the app: %s", msg, e.Err)
+	}
+	return fmt.Sprintf("%s using the credentials of %s, they may be invalid or expired: %s", msg, e.Credential.scope(), e.Err)
+}
+
+// RegistryAuthError returns an ErrRegistryAuth if err was caused by the
+// registry server denying access to the image, otherwise err is returned
+// unchanged.
+func RegistryAuthError(err error, server, image string, cred *RegistryCredential) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range registryAuthErrorMessages {
+		if strings.Contains(msg, m) {
+			return &ErrRegistryAuth{Image: image, Server: server, Credential: cred, Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"errors"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type registryTestApp struct {
+	App
+	pool, team string
+}
+
+func (a *registryTestApp) GetPool() string      { return a.pool }
+func (a *registryTestApp) GetTeamOwner() string { return a.team }
+
+func (s *S) TestSetRegistryCredential(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	cred := RegistryCredential{Server: "https://registry.example.com/", Pool: "pool1", Username: "user", Password: "pass"}
+	err = SetRegistryCredential(&cred)
+	c.Assert(err, check.IsNil)
+	creds, err := ListRegistryCredentials(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.HasLen, 1)
+	c.Assert(creds[0].Server, check.Equals, "registry.example.com")
+	c.Assert(creds[0].Password, check.Equals, "")
+	c.Assert(creds[0].EncryptedPassword, check.Not(check.Equals), "")
+	c.Assert(creds[0].EncryptedPassword, check.Not(check.Matches), ".*pass.*")
+	cred = RegistryCredential{Server: "registry.example.com", Pool: "pool1", Username: "user", Password: "rotated"}
+	err = SetRegistryCredential(&cred)
+	c.Assert(err, check.IsNil)
+	creds, err = ListRegistryCredentials(bson.M{"pool": "pool1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.HasLen, 1)
+	found, err := RegistryCredentialForApp(&registryTestApp{pool: "pool1"}, "registry.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(found.Password, check.Equals, "rotated")
+}
+
+func (s *S) TestSetRegistryCredentialInvalid(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	err := SetRegistryCredential(&RegistryCredential{Pool: "pool1", Username: "user", Password: "pass"})
+	c.Assert(err, check.Equals, ErrRegistryServerRequired)
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Pool: "pool1", Team: "ateam", Username: "user", Password: "pass"})
+	c.Assert(err, check.Equals, ErrRegistryScopeRequired)
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Team: "ateam", Username: "user"})
+	c.Assert(err, check.Equals, ErrRegistryUserRequired)
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Pool: "pool1", Username: "user", Password: "pass"})
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+	config.Unset("registry-credentials")
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Team: "ateam", Username: "user", Password: "pass"})
+	c.Assert(err, check.Equals, ErrRegistryCredentialsKeyMissing)
+}
+
+func (s *S) TestRegistryCredentialForAppPoolTakesPrecedence(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Team: "ateam", Username: "team-user", Password: "pass"})
+	c.Assert(err, check.IsNil)
+	a := &registryTestApp{pool: "pool1", team: "ateam"}
+	cred, err := RegistryCredentialForApp(a, "r.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred.Username, check.Equals, "team-user")
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Pool: "pool1", Username: "pool-user", Password: "pass"})
+	c.Assert(err, check.IsNil)
+	cred, err = RegistryCredentialForApp(a, "r.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred.Username, check.Equals, "pool-user")
+	c.Assert(cred.Password, check.Equals, "pass")
+	cred, err = RegistryCredentialForApp(&registryTestApp{pool: "other", team: "test"}, "r.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred, check.IsNil)
+	cred, err = RegistryCredentialForApp(a, "other.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(cred, check.IsNil)
+}
+
+func (s *S) TestRemoveRegistryCredential(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	err := SetRegistryCredential(&RegistryCredential{Server: "r.io", Team: "ateam", Username: "user", Password: "pass"})
+	c.Assert(err, check.IsNil)
+	err = RemoveRegistryCredential("r.io", "", "test")
+	c.Assert(err, check.Equals, ErrRegistryCredentialNotFound)
+	err = RemoveRegistryCredential("r.io", "", "ateam")
+	c.Assert(err, check.IsNil)
+	creds, err := ListRegistryCredentials(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.HasLen, 0)
+}
+
+func (s *S) TestRemovePoolRemovesRegistryCredentials(c *check.C) {
+	config.Set("registry-credentials:key", "secret")
+	defer config.Unset("registry-credentials")
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetRegistryCredential(&RegistryCredential{Server: "r.io", Pool: "pool1", Username: "user", Password: "pass"})
+	c.Assert(err, check.IsNil)
+	err = RemovePool("pool1")
+	c.Assert(err, check.IsNil)
+	creds, err := ListRegistryCredentials(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.HasLen, 0)
+}
+
+func (s *S) TestImageRegistry(c *check.C) {
+	tests := map[string]string{
+		"tsuru/python":                    "",
+		"python:3":                        "",
+		"registry.example.com/tsuru/app":  "registry.example.com",
+		"localhost:5000/tsuru/app-a:v1":   "localhost:5000",
+		"localhost/app":                   "localhost",
+		"10.0.0.1:5000/app":               "10.0.0.1:5000",
+		"registry.example.com:443/a/b:v2": "registry.example.com:443",
+	}
+	for img, server := range tests {
+		c.Check(ImageRegistry(img), check.Equals, server, check.Commentf("image %q", img))
+	}
+}
+
+func (s *S) TestRegistryAuthError(c *check.C) {
+	err := RegistryAuthError(errors.New("unauthorized: authentication required"), "r.io", "r.io/app:v1", nil)
+	c.Assert(err, check.FitsTypeOf, &ErrRegistryAuth{})
+	c.Assert(err, check.ErrorMatches, `registry "r.io" denied access to image "r.io/app:v1" and no credentials for it are registered in the pool or the team of the app: unauthorized: authentication required`)
+	cred := &RegistryCredential{Server: "r.io", Pool: "pool1"}
+	err = RegistryAuthError(errors.New("denied: requested access to the resource is denied"), "r.io", "", cred)
+	c.Assert(err, check.ErrorMatches, `registry "r.io" denied access using the credentials of pool "pool1", they may be invalid or expired: denied: requested access to the resource is denied`)
+	baseErr := errors.New("connection refused")
+	c.Assert(RegistryAuthError(baseErr, "r.io", "r.io/app", cred), check.Equals, baseErr)
+	c.Assert(RegistryAuthError(nil, "r.io", "r.io/app", cred), check.IsNil)
+}
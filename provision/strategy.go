@@ -0,0 +1,115 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DeployStrategyRolling   = "rolling"
+	DeployStrategyBlueGreen = "blue-green"
+	DeployStrategyCanary    = "canary"
+
+	// DefaultCanarySteps is the number of steps of canary deploys without
+	// explicit steps.
+	DefaultCanarySteps = 10
+)
+
+// DeployStrategy controls how the units of an app are replaced by the units
+// of a newly deployed image. With the rolling strategy, the default, old
+// units are replaced as soon as the new ones pass the healthcheck. With the
+// blue-green strategy the new units are started alongside the old ones and
+// all the traffic is switched to them at once, while with the canary
+// strategy the traffic is shifted to them in Steps increments. In both the
+// healthcheck of the new units is watched for StepInterval after each shift,
+// old units being removed only after the last one; failures shift the
+// traffic back to the old units and remove the new ones.
+type DeployStrategy struct {
+	Name         string        `json:",omitempty" bson:",omitempty"`
+	Steps        int           `json:",omitempty" bson:",omitempty"`
+	StepInterval time.Duration `json:",omitempty" bson:",omitempty"`
+}
+
+// IsRolling returns whether the strategy is the rolling replacement of
+// units, which needs no support from provisioners or routers.
+func (s DeployStrategy) IsRolling() bool {
+	return s.Name == "" || s.Name == DeployStrategyRolling
+}
+
+// Validate checks the name and the steps of the strategy.
+func (s DeployStrategy) Validate() error {
+	if s.Steps < 0 || s.StepInterval < 0 {
+		return errors.New("steps and step interval must not be negative")
+	}
+	switch s.Name {
+	case "", DeployStrategyRolling, DeployStrategyBlueGreen:
+		if s.Steps > 1 {
+			return errors.Errorf("steps are only allowed in the %q strategy", DeployStrategyCanary)
+		}
+		return nil
+	case DeployStrategyCanary:
+		if s.Steps > 100 {
+			return errors.New("canary deploys must have at most 100 steps")
+		}
+		return nil
+	}
+	return errors.Errorf("invalid deploy strategy %q, valid strategies are %q, %q and %q",
+		s.Name, DeployStrategyRolling, DeployStrategyBlueGreen, DeployStrategyCanary)
+}
+
+// Weights returns the percentage of requests sent to the new units after
+// each shift of traffic. The last weight is always 100.
+func (s DeployStrategy) Weights() []int {
+	if s.Name != DeployStrategyCanary {
+		return []int{100}
+	}
+	steps := s.Steps
+	if steps <= 0 {
+		steps = DefaultCanarySteps
+	}
+	weights := make([]int, steps)
+	for i := range weights {
+		weights[i] = 100 * (i + 1) / steps
+	}
+	return weights
+}
+
+func (s DeployStrategy) String() string {
+	if s.IsRolling() {
+		return DeployStrategyRolling
+	}
+	if s.Name == DeployStrategyCanary {
+		return fmt.Sprintf("%s in %d steps", s.Name, len(s.Weights()))
+	}
+	return s.Name
+}
+
+// StrategyApp is an app being deployed with a deploy strategy.
+type StrategyApp interface {
+	GetDeployStrategy() DeployStrategy
+}
+
+// StrategyDeployer is a provisioner able to deploy apps following strategies
+// other than the rolling replacement of units. Deploys of apps implementing
+// StrategyApp follow their strategy.
+type StrategyDeployer interface {
+	// ValidateDeployStrategy returns an error when the app can't be
+	// deployed following the strategy, e.g. when its router is unable to
+	// split the traffic between units.
+	ValidateDeployStrategy(app App, strategy DeployStrategy) error
+}
+
+// GetDeployStrategy returns the strategy of the deploy of the app, the
+// rolling strategy when it doesn't implement StrategyApp.
+func GetDeployStrategy(app App) DeployStrategy {
+	if sApp, ok := app.(StrategyApp); ok {
+		return sApp.GetDeployStrategy()
+	}
+	return DeployStrategy{}
+}
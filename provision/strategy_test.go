@@ -0,0 +1,39 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision_test
+
+import (
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeployStrategyValidate(c *check.C) {
+	tests := []struct {
+		strategy provision.DeployStrategy
+		valid    bool
+	}{
+		{provision.DeployStrategy{}, true},
+		{provision.DeployStrategy{Name: provision.DeployStrategyRolling}, true},
+		{provision.DeployStrategy{Name: provision.DeployStrategyBlueGreen}, true},
+		{provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: 10}, true},
+		{provision.DeployStrategy{Name: provision.DeployStrategyCanary}, true},
+		{provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: 101}, false},
+		{provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: -1}, false},
+		{provision.DeployStrategy{Name: provision.DeployStrategyBlueGreen, Steps: 3}, false},
+		{provision.DeployStrategy{Name: "recreate"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.strategy.Validate()
+		c.Check(err == nil, check.Equals, tt.valid, check.Commentf("%#v: %v", tt.strategy, err))
+	}
+}
+
+func (s *S) TestDeployStrategyWeights(c *check.C) {
+	c.Assert(provision.DeployStrategy{}.Weights(), check.DeepEquals, []int{100})
+	c.Assert(provision.DeployStrategy{Name: provision.DeployStrategyBlueGreen}.Weights(), check.DeepEquals, []int{100})
+	c.Assert(provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: 4}.Weights(), check.DeepEquals, []int{25, 50, 75, 100})
+	c.Assert(provision.DeployStrategy{Name: provision.DeployStrategyCanary, Steps: 3}.Weights(), check.DeepEquals, []int{33, 66, 100})
+	c.Assert(provision.DeployStrategy{Name: provision.DeployStrategyCanary}.Weights(), check.HasLen, provision.DefaultCanarySteps)
+}
@@ -311,12 +311,14 @@ func extraRegisterCmds(app provision.App) string {
 
 func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 	var envs []string
-	appEnvs := provision.EnvsForApp(opts.app, opts.process, opts.isDeploy)
+	appEnvs, err := provision.EnvsForApp(opts.app, opts.process, opts.isDeploy)
+	if err != nil {
+		return nil, err
+	}
 	for _, envData := range appEnvs {
 		envs = append(envs, fmt.Sprintf("%s=%s", envData.Name, envData.Value))
 	}
 	var cmds []string
-	var err error
 	var endpointSpec *swarm.EndpointSpec
 	var networks []swarm.NetworkAttachmentConfig
 	var healthConfig *container.HealthConfig
@@ -343,6 +345,9 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 			return nil, errors.WithStack(err)
 		}
 		healthConfig = toHealthConfig(yamlData.Healthcheck, portInt)
+		if hc := provision.HealthcheckForApp(opts.app); hc != nil && hc.Path != "" {
+			healthConfig = appHealthConfig(*hc, portInt)
+		}
 	}
 	if opts.labels == nil {
 		opts.labels, err = provision.ServiceLabels(provision.ServiceLabelsOpts{
@@ -387,7 +392,7 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 			},
 			Networks: networks,
 			RestartPolicy: &swarm.RestartPolicy{
-				Condition: swarm.RestartPolicyConditionAny,
+				Condition: restartCondition(opts.app),
 			},
 			Placement: &swarm.Placement{
 				Constraints: opts.constraints,
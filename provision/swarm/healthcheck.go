@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/provision"
 )
@@ -51,3 +52,32 @@ func toHealthConfig(hc provision.TsuruYamlHealthcheck, port int) *container.Heal
 		},
 	}
 }
+
+// appHealthConfig returns the health config for the healthcheck configured in
+// the app, which checks the status of a GET request to the path.
+func appHealthConfig(hc provision.AppHealthcheck, port int) *container.HealthConfig {
+	healthConfig := toHealthConfig(provision.TsuruYamlHealthcheck{Path: hc.Path}, port)
+	if healthConfig == nil {
+		return nil
+	}
+	if hc.Interval > 0 {
+		healthConfig.Interval = time.Duration(hc.Interval) * time.Second
+	}
+	if hc.Timeout > 0 {
+		healthConfig.Timeout = time.Duration(hc.Timeout) * time.Second
+	}
+	if hc.FailureThreshold > 0 {
+		healthConfig.Retries = hc.FailureThreshold
+	}
+	return healthConfig
+}
+
+func restartCondition(a provision.App) swarm.RestartPolicyCondition {
+	switch provision.RestartPolicyForApp(a) {
+	case provision.RestartPolicyOnFailure:
+		return swarm.RestartPolicyConditionOnFailure
+	case provision.RestartPolicyNever:
+		return swarm.RestartPolicyConditionNone
+	}
+	return swarm.RestartPolicyConditionAny
+}
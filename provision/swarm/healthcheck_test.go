@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
 )
 
@@ -65,3 +67,37 @@ func (s *S) TestToHealthConfig(c *check.C) {
 		c.Assert(result, check.DeepEquals, test.expected, check.Commentf("failed test %d", i))
 	}
 }
+
+func (s *S) TestAppHealthConfig(c *check.C) {
+	result := appHealthConfig(provision.AppHealthcheck{}, 9000)
+	c.Assert(result, check.IsNil)
+	result = appHealthConfig(provision.AppHealthcheck{Path: "/hc"}, 9000)
+	c.Assert(result, check.DeepEquals, &container.HealthConfig{
+		Test: []string{
+			"CMD-SHELL",
+			"curl -XGET -fsSL http://localhost:9000/hc -o/dev/null -w '%{http_code}' | grep 200",
+		},
+		Timeout:  120 * time.Second,
+		Interval: 3 * time.Second,
+		Retries:  1,
+	})
+	result = appHealthConfig(provision.AppHealthcheck{Path: "/hc", Interval: 10, Timeout: 2, FailureThreshold: 3}, 9000)
+	c.Assert(result, check.DeepEquals, &container.HealthConfig{
+		Test: []string{
+			"CMD-SHELL",
+			"curl -XGET -fsSL http://localhost:9000/hc -o/dev/null -w '%{http_code}' | grep 200",
+		},
+		Timeout:  2 * time.Second,
+		Interval: 10 * time.Second,
+		Retries:  3,
+	})
+}
+
+func (s *S) TestRestartCondition(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	c.Assert(restartCondition(a), check.Equals, swarm.RestartPolicyConditionAny)
+	a.Healthcheck = &provision.AppHealthcheck{RestartPolicy: provision.RestartPolicyOnFailure}
+	c.Assert(restartCondition(a), check.Equals, swarm.RestartPolicyConditionOnFailure)
+	a.Healthcheck = &provision.AppHealthcheck{RestartPolicy: provision.RestartPolicyNever}
+	c.Assert(restartCondition(a), check.Equals, swarm.RestartPolicyConditionNone)
+}
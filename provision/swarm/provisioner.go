@@ -6,6 +6,7 @@ package swarm
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -149,33 +150,36 @@ func (p *swarmProvisioner) RemoveUnits(a provision.App, units uint, processName
 	}, a, -int(units), processName)
 }
 
-func (p *swarmProvisioner) Restart(a provision.App, process string, w io.Writer) error {
+func (p *swarmProvisioner) Restart(ctx context.Context, a provision.App, process string, w io.Writer) error {
 	client, err := chooseDBSwarmNode()
 	if err != nil {
 		return err
 	}
 	return servicecommon.ChangeAppState(&serviceManager{
 		client: client,
+		ctx:    ctx,
 	}, a, process, servicecommon.ProcessState{Start: true, Restart: true})
 }
 
-func (p *swarmProvisioner) Start(a provision.App, process string) error {
+func (p *swarmProvisioner) Start(ctx context.Context, a provision.App, process string) error {
 	client, err := chooseDBSwarmNode()
 	if err != nil {
 		return err
 	}
 	return servicecommon.ChangeAppState(&serviceManager{
 		client: client,
+		ctx:    ctx,
 	}, a, process, servicecommon.ProcessState{Start: true})
 }
 
-func (p *swarmProvisioner) Stop(a provision.App, process string) error {
+func (p *swarmProvisioner) Stop(ctx context.Context, a provision.App, process string) error {
 	client, err := chooseDBSwarmNode()
 	if err != nil {
 		return err
 	}
 	return servicecommon.ChangeAppState(&serviceManager{
 		client: client,
+		ctx:    ctx,
 	}, a, process, servicecommon.ProcessState{Stop: true})
 }
 
@@ -622,7 +626,7 @@ func (p *swarmProvisioner) ArchiveDeploy(a provision.App, archiveURL string, evt
 	if err != nil {
 		return "", err
 	}
-	err = deployProcesses(a, buildingImage, nil)
+	err = deployProcesses(evt.Context(), a, buildingImage, nil)
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
@@ -662,7 +666,7 @@ func (p *swarmProvisioner) ImageDeploy(a provision.App, imgID string, evt *event
 		return "", err
 	}
 	a.SetUpdatePlatform(true)
-	err = deployProcesses(a, newImage, nil)
+	err = deployProcesses(evt.Context(), a, newImage, nil)
 	if err != nil {
 		return "", err
 	}
@@ -679,7 +683,7 @@ func (p *swarmProvisioner) UploadDeploy(app provision.App, archiveFile io.ReadCl
 	if err != nil {
 		return "", err
 	}
-	err = deployProcesses(app, buildingImage, nil)
+	err = deployProcesses(evt.Context(), app, buildingImage, nil)
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
@@ -868,24 +872,35 @@ func (p *swarmProvisioner) StartupMessage() (string, error) {
 	return out, nil
 }
 
-func deployProcesses(a provision.App, newImg string, updateSpec servicecommon.ProcessSpec) error {
+func deployProcesses(ctx context.Context, a provision.App, newImg string, updateSpec servicecommon.ProcessSpec) error {
 	client, err := chooseDBSwarmNode()
 	if err != nil {
 		return err
 	}
 	manager := &serviceManager{
 		client: client,
+		ctx:    ctx,
 	}
 	return servicecommon.RunServicePipeline(manager, a, newImg, updateSpec)
 }
 
+// serviceManager manages the services of apps in the swarm cluster. The
+// changes are aborted once ctx, when set, is done.
 type serviceManager struct {
 	client *docker.Client
+	ctx    context.Context
+}
+
+func (m *serviceManager) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
 }
 
 func (m *serviceManager) RemoveService(a provision.App, process string) error {
 	srvName := serviceNameForApp(a, process)
-	err := m.client.RemoveService(docker.RemoveServiceOptions{ID: srvName})
+	err := m.client.RemoveService(docker.RemoveServiceOptions{ID: srvName, Context: m.context()})
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -905,6 +920,9 @@ func (m *serviceManager) CurrentLabels(a provision.App, process string) (*provis
 }
 
 func (m *serviceManager) DeployService(a provision.App, process string, labels *provision.LabelSet, replicas int, imgID string) error {
+	if err := m.context().Err(); err != nil {
+		return err
+	}
 	srvName := serviceNameForApp(a, process)
 	srv, err := m.client.InspectService(srvName)
 	if err != nil {
@@ -930,6 +948,7 @@ func (m *serviceManager) DeployService(a provision.App, process string, labels *
 	if srv == nil {
 		_, err = m.client.CreateService(docker.CreateServiceOptions{
 			ServiceSpec: *spec,
+			Context:     m.context(),
 		})
 		if err != nil {
 			return errors.WithStack(err)
@@ -939,6 +958,7 @@ func (m *serviceManager) DeployService(a provision.App, process string, labels *
 		err = m.client.UpdateService(srv.ID, docker.UpdateServiceOptions{
 			Version:     srv.Version.Index,
 			ServiceSpec: srv.Spec,
+			Context:     m.context(),
 		})
 		if err != nil {
 			return errors.WithStack(err)
@@ -6,6 +6,7 @@ package swarm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -344,12 +345,12 @@ func (s *S) TestRestart(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = image.AppendAppImageName(a.GetName(), imgName)
 	c.Assert(err, check.IsNil)
-	err = s.p.Restart(a, "web", nil)
+	err = s.p.Restart(context.Background(), a, "web", nil)
 	c.Assert(err, check.IsNil)
 	units, err := s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 1)
-	err = s.p.Restart(a, "", nil)
+	err = s.p.Restart(context.Background(), a, "", nil)
 	c.Assert(err, check.IsNil)
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
@@ -377,7 +378,7 @@ func (s *S) TestRestartExisting(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = s.p.AddUnits(a, 1, "web", nil)
 	c.Assert(err, check.IsNil)
-	err = s.p.Restart(a, "web", nil)
+	err = s.p.Restart(context.Background(), a, "web", nil)
 	c.Assert(err, check.IsNil)
 	units, err := s.p.Units(a)
 	c.Assert(err, check.IsNil)
@@ -414,12 +415,12 @@ func (s *S) TestStopStart(c *check.C) {
 	units, err := s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 3)
-	err = s.p.Stop(a, "")
+	err = s.p.Stop(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 0)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
@@ -453,13 +454,13 @@ func (s *S) TestStopStartSingleProcess(c *check.C) {
 	units, err := s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 2)
-	err = s.p.Stop(a, "worker")
+	err = s.p.Stop(context.Background(), a, "worker")
 	c.Assert(err, check.IsNil)
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 1)
 	c.Assert(units[0].ProcessName, check.Equals, "web")
-	err = s.p.Start(a, "worker")
+	err = s.p.Start(context.Background(), a, "worker")
 	c.Assert(err, check.IsNil)
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
@@ -2045,7 +2046,7 @@ func (s *S) TestSleepStart(c *check.C) {
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 0)
-	err = s.p.Start(a, "")
+	err = s.p.Start(context.Background(), a, "")
 	c.Assert(err, check.IsNil)
 	units, err = s.p.Units(a)
 	c.Assert(err, check.IsNil)
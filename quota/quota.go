@@ -5,7 +5,15 @@
 // Package quota provides primitives for quota management in tsuru.
 package quota
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/tsuru/config"
+)
+
+// SoftLimitEventKind is the internal event kind used to warn that a quota
+// reached its soft limit.
+const SoftLimitEventKind = "quota.soft-limit"
 
 var Unlimited = Quota{Limit: -1, InUse: 0}
 
@@ -18,6 +26,42 @@ func (q *Quota) Unlimited() bool {
 	return q.Limit == -1
 }
 
+// Usage returns the percentage of the quota in use, or -1 for unlimited
+// quotas.
+func (q *Quota) Usage() float64 {
+	if q.Unlimited() {
+		return -1
+	}
+	if q.Limit == 0 {
+		return 100
+	}
+	return float64(q.InUse) * 100 / float64(q.Limit)
+}
+
+// CrossesSoftLimit reports whether allocating quantity more items makes the
+// quota usage reach the soft limit. It only returns true when the usage goes
+// from below to above the soft limit, so warnings are not repeated on every
+// allocation.
+func (q *Quota) CrossesSoftLimit(quantity int) bool {
+	percent := SoftLimitPercent()
+	if percent == 0 || q.Unlimited() || q.Limit <= 0 {
+		return false
+	}
+	threshold := float64(q.Limit) * float64(percent) / 100
+	return float64(q.InUse) < threshold && float64(q.InUse+quantity) >= threshold
+}
+
+// SoftLimitPercent returns the percentage of usage, defined in the
+// quota:soft-limit setting, after which tsuru warns about quotas before
+// enforcing the hard limit. It returns 0 when soft limits are disabled.
+func SoftLimitPercent() int {
+	percent, _ := config.GetInt("quota:soft-limit")
+	if percent <= 0 || percent >= 100 {
+		return 0
+	}
+	return percent
+}
+
 type QuotaExceededError struct {
 	Requested uint
 	Available uint
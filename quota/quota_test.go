@@ -7,6 +7,7 @@ package quota
 import (
 	"testing"
 
+	"github.com/tsuru/config"
 	"gopkg.in/check.v1"
 )
 
@@ -30,3 +31,36 @@ func (Suite) TestQuotaUnlimited(c *check.C) {
 	q.Limit = 4
 	c.Assert(q.Unlimited(), check.Equals, false)
 }
+
+func (Suite) TestQuotaUsage(c *check.C) {
+	c.Assert((&Quota{Limit: -1, InUse: 3}).Usage(), check.Equals, float64(-1))
+	c.Assert((&Quota{Limit: 0, InUse: 0}).Usage(), check.Equals, float64(100))
+	c.Assert((&Quota{Limit: 4, InUse: 3}).Usage(), check.Equals, float64(75))
+}
+
+func (Suite) TestQuotaCrossesSoftLimit(c *check.C) {
+	config.Set("quota:soft-limit", 80)
+	defer config.Unset("quota:soft-limit")
+	q := Quota{Limit: 10, InUse: 7}
+	c.Assert(q.CrossesSoftLimit(1), check.Equals, true)
+	c.Assert(q.CrossesSoftLimit(3), check.Equals, true)
+	q.InUse = 8
+	c.Assert(q.CrossesSoftLimit(1), check.Equals, false)
+	q.InUse = 5
+	c.Assert(q.CrossesSoftLimit(1), check.Equals, false)
+	c.Assert(Unlimited.CrossesSoftLimit(100), check.Equals, false)
+}
+
+func (Suite) TestQuotaCrossesSoftLimitDisabled(c *check.C) {
+	q := Quota{Limit: 10, InUse: 7}
+	c.Assert(q.CrossesSoftLimit(3), check.Equals, false)
+}
+
+func (Suite) TestSoftLimitPercent(c *check.C) {
+	c.Assert(SoftLimitPercent(), check.Equals, 0)
+	config.Set("quota:soft-limit", 80)
+	defer config.Unset("quota:soft-limit")
+	c.Assert(SoftLimitPercent(), check.Equals, 80)
+	config.Set("quota:soft-limit", 120)
+	c.Assert(SoftLimitPercent(), check.Equals, 0)
+}
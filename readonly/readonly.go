@@ -0,0 +1,146 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package readonly manages the read-only mode of the API, enabled for the
+// whole cluster or for some pools during database maintenance. Mutating
+// operations are rejected while the mode is enabled and reads keep working.
+package readonly
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	mgo "gopkg.in/mgo.v2"
+)
+
+var (
+	ErrReasonRequired = errors.New("reason is required")
+	ErrNotReadOnly    = errors.New("read-only mode is not enabled")
+
+	// cacheTTL is how long the read-only statuses are cached by each API
+	// instance before being read again from the database.
+	cacheTTL = 5 * time.Second
+
+	cache statusCache
+)
+
+// Status describes an enabled read-only mode. Pool is empty when the whole
+// API is read-only.
+type Status struct {
+	Pool   string `bson:"_id"`
+	Reason string
+	Owner  string
+	Since  time.Time
+}
+
+// ErrReadOnly is returned when a mutating operation is attempted under an
+// enabled read-only mode.
+type ErrReadOnly struct {
+	Status Status
+}
+
+func (e *ErrReadOnly) Error() string {
+	since := e.Status.Since.Format(time.RFC3339)
+	if e.Status.Pool == "" {
+		return fmt.Sprintf("tsuru is in read-only mode since %s: %s", since, e.Status.Reason)
+	}
+	return fmt.Sprintf("pool %q is in read-only mode since %s: %s", e.Status.Pool, since, e.Status.Reason)
+}
+
+// Enable enables the read-only mode in the given pool, or in the whole API
+// when pool is empty, replacing the reason of an already enabled mode.
+func Enable(pool, reason, owner string) error {
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ReadOnly().UpsertId(pool, Status{
+		Pool:   pool,
+		Reason: reason,
+		Owner:  owner,
+		Since:  time.Now().UTC(),
+	})
+	cache.invalidate()
+	return err
+}
+
+// Disable disables the read-only mode in the given pool, or in the whole API
+// when pool is empty.
+func Disable(pool string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.ReadOnly().RemoveId(pool)
+	cache.invalidate()
+	if err == mgo.ErrNotFound {
+		return ErrNotReadOnly
+	}
+	return err
+}
+
+// List returns the enabled read-only modes, the global one first.
+func List() ([]Status, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var statuses []Status
+	err = conn.ReadOnly().Find(nil).Sort("_id").All(&statuses)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Check returns an *ErrReadOnly when the whole API or the given pool is
+// read-only. Statuses are cached for a few seconds, when they can't be read
+// the last known ones are used.
+func Check(pool string) error {
+	statuses := cache.get()
+	for _, s := range statuses {
+		if s.Pool == "" || (pool != "" && s.Pool == pool) {
+			return &ErrReadOnly{Status: s}
+		}
+	}
+	return nil
+}
+
+type statusCache struct {
+	mu       sync.Mutex
+	statuses []Status
+	updated  time.Time
+}
+
+func (c *statusCache) get() []Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.updated) < cacheTTL {
+		return c.statuses
+	}
+	statuses, err := List()
+	if err != nil {
+		log.Errorf("[readonly] unable to read read-only statuses, using last known ones: %s", err)
+		return c.statuses
+	}
+	c.statuses = statuses
+	c.updated = time.Now()
+	return c.statuses
+}
+
+func (c *statusCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updated = time.Time{}
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package readonly
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEnableAndList(c *check.C) {
+	err := Enable("pool1", "moving pool database", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = Enable("", "database upgrade", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	statuses, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 2)
+	c.Assert(statuses[0].Pool, check.Equals, "")
+	c.Assert(statuses[0].Reason, check.Equals, "database upgrade")
+	c.Assert(statuses[0].Owner, check.Equals, "admin@example.com")
+	c.Assert(time.Since(statuses[0].Since) < time.Minute, check.Equals, true)
+	c.Assert(statuses[1].Pool, check.Equals, "pool1")
+	c.Assert(statuses[1].Reason, check.Equals, "moving pool database")
+}
+
+func (s *S) TestEnableReplacesReason(c *check.C) {
+	err := Enable("", "database upgrade", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = Enable("", "database upgrade, take 2", "other@example.com")
+	c.Assert(err, check.IsNil)
+	statuses, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 1)
+	c.Assert(statuses[0].Reason, check.Equals, "database upgrade, take 2")
+	c.Assert(statuses[0].Owner, check.Equals, "other@example.com")
+}
+
+func (s *S) TestEnableReasonRequired(c *check.C) {
+	err := Enable("", "", "admin@example.com")
+	c.Assert(err, check.Equals, ErrReasonRequired)
+}
+
+func (s *S) TestDisable(c *check.C) {
+	err := Enable("pool1", "maintenance", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = Disable("")
+	c.Assert(err, check.Equals, ErrNotReadOnly)
+	err = Disable("pool1")
+	c.Assert(err, check.IsNil)
+	statuses, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 0)
+}
+
+func (s *S) TestCheck(c *check.C) {
+	c.Assert(Check(""), check.IsNil)
+	err := Enable("pool1", "maintenance", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(""), check.IsNil)
+	c.Assert(Check("pool2"), check.IsNil)
+	err = Check("pool1")
+	c.Assert(err, check.FitsTypeOf, &ErrReadOnly{})
+	c.Assert(err, check.ErrorMatches, `pool "pool1" is in read-only mode since .+: maintenance`)
+	err = Enable("", "database upgrade", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(""), check.ErrorMatches, `tsuru is in read-only mode since .+: database upgrade`)
+	c.Assert(Check("pool2"), check.ErrorMatches, `tsuru is in read-only mode since .+: database upgrade`)
+	err = Disable("")
+	c.Assert(err, check.IsNil)
+	c.Assert(Check("pool2"), check.IsNil)
+}
+
+func (s *S) TestCheckCached(c *check.C) {
+	c.Assert(Check(""), check.IsNil)
+	_, err := s.conn.ReadOnly().UpsertId("", Status{Reason: "maintenance"})
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(""), check.IsNil)
+	cache.invalidate()
+	c.Assert(Check(""), check.NotNil)
+}
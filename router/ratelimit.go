@@ -0,0 +1,64 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+)
+
+var (
+	ErrRateLimitNotSupported = &RateLimitError{Reason: "router does not support rate limiting"}
+	ErrInvalidRateLimit      = &RateLimitError{Reason: "requests per second must be greater than zero and burst must not be negative"}
+)
+
+// RateLimitError is returned when a rate limit is invalid or can't be
+// enforced by the router.
+type RateLimitError struct {
+	Reason string
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Reason
+}
+
+// RateLimit limits the requests sent to an app. Burst is the number of
+// requests above RequestsPerSecond accepted in a short period of time. When
+// PerIP is set requests are limited for each client IP, otherwise they are
+// limited for all clients of the app.
+type RateLimit struct {
+	RequestsPerSecond int
+	Burst             int
+	PerIP             bool
+}
+
+// RateLimitSupport describes the rate limits a router is able to enforce.
+// MaxRequestsPerSecond is zero when the router has no upper limit.
+type RateLimitSupport struct {
+	PerIP                bool
+	Global               bool
+	Burst                bool
+	MaxRequestsPerSecond int
+}
+
+// ValidateRateLimit checks whether the limit can be enforced by a router
+// with the given support.
+func ValidateRateLimit(limit RateLimit, support RateLimitSupport) error {
+	if limit.RequestsPerSecond <= 0 || limit.Burst < 0 {
+		return ErrInvalidRateLimit
+	}
+	if limit.PerIP && !support.PerIP {
+		return &RateLimitError{Reason: "router does not support per-IP rate limiting"}
+	}
+	if !limit.PerIP && !support.Global {
+		return &RateLimitError{Reason: "router does not support global rate limiting"}
+	}
+	if limit.Burst > 0 && !support.Burst {
+		return &RateLimitError{Reason: "router does not support rate limiting bursts"}
+	}
+	if support.MaxRequestsPerSecond > 0 && limit.RequestsPerSecond > support.MaxRequestsPerSecond {
+		return &RateLimitError{Reason: fmt.Sprintf("router supports at most %d requests per second", support.MaxRequestsPerSecond)}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestValidateRateLimit(c *check.C) {
+	full := RateLimitSupport{PerIP: true, Global: true, Burst: true}
+	tests := []struct {
+		limit   RateLimit
+		support RateLimitSupport
+		err     string
+	}{
+		{RateLimit{RequestsPerSecond: 10, Burst: 20, PerIP: true}, full, ""},
+		{RateLimit{RequestsPerSecond: 10}, RateLimitSupport{Global: true}, ""},
+		{RateLimit{}, full, ErrInvalidRateLimit.Error()},
+		{RateLimit{RequestsPerSecond: 10, Burst: -1}, full, ErrInvalidRateLimit.Error()},
+		{RateLimit{RequestsPerSecond: 10, PerIP: true}, RateLimitSupport{Global: true}, "router does not support per-IP rate limiting"},
+		{RateLimit{RequestsPerSecond: 10}, RateLimitSupport{PerIP: true}, "router does not support global rate limiting"},
+		{RateLimit{RequestsPerSecond: 10, Burst: 5}, RateLimitSupport{Global: true}, "router does not support rate limiting bursts"},
+		{RateLimit{RequestsPerSecond: 101}, RateLimitSupport{Global: true, MaxRequestsPerSecond: 100}, "router supports at most 100 requests per second"},
+	}
+	for i, tt := range tests {
+		err := ValidateRateLimit(tt.limit, tt.support)
+		if tt.err == "" {
+			c.Check(err, check.IsNil, check.Commentf("test %d", i))
+		} else {
+			c.Check(err, check.ErrorMatches, tt.err, check.Commentf("test %d", i))
+		}
+	}
+}
@@ -24,16 +24,17 @@ import (
 type routerFactory func(routerName, configPrefix string) (Router, error)
 
 var (
-	ErrBackendExists         = errors.New("Backend already exists")
-	ErrBackendNotFound       = errors.New("Backend not found")
-	ErrBackendSwapped        = errors.New("Backend is swapped cannot remove")
-	ErrRouteExists           = errors.New("Route already exists")
-	ErrRouteNotFound         = errors.New("Route not found")
-	ErrCNameExists           = errors.New("CName already exists")
-	ErrCNameNotFound         = errors.New("CName not found")
-	ErrCNameNotAllowed       = errors.New("CName as router subdomain not allowed")
-	ErrCertificateNotFound   = errors.New("Certificate not found")
-	ErrDefaultRouterNotFound = errors.New("No default router found")
+	ErrBackendExists           = errors.New("Backend already exists")
+	ErrBackendNotFound         = errors.New("Backend not found")
+	ErrBackendSwapped          = errors.New("Backend is swapped cannot remove")
+	ErrRouteExists             = errors.New("Route already exists")
+	ErrRouteNotFound           = errors.New("Route not found")
+	ErrCNameExists             = errors.New("CName already exists")
+	ErrCNameNotFound           = errors.New("CName not found")
+	ErrCNameNotAllowed         = errors.New("CName as router subdomain not allowed")
+	ErrCertificateNotFound     = errors.New("Certificate not found")
+	ErrDefaultRouterNotFound   = errors.New("No default router found")
+	ErrMaintenanceNotSupported = errors.New("Router does not support maintenance pages")
 )
 
 type ErrRouterNotFound struct {
@@ -153,6 +154,35 @@ type TLSRouter interface {
 	GetCertificate(cname string) (string, error)
 }
 
+// RateLimitRouter is a router that supports limiting the rate of requests
+// sent to the backend of an app. RateLimitSupport describes the limits the
+// router is able to enforce, SetRateLimit is only called with limits
+// validated against it.
+type RateLimitRouter interface {
+	SetRateLimit(name string, limit RateLimit) error
+	RemoveRateLimit(name string) error
+	RateLimitSupport() RateLimitSupport
+}
+
+// WeightedRouter is a router able to split the requests sent to a backend
+// between two sets of routes, used to shift the traffic gradually to the
+// units of a new version of an app. SetRoutesWeight sends weight percent of
+// the requests to the given routes, even those not added yet, and the
+// remaining requests to the other routes of the backend. Calling it with no
+// addresses removes the weights, splitting requests evenly among all routes.
+type WeightedRouter interface {
+	SetRoutesWeight(name string, addresses []*url.URL, weight int) error
+}
+
+// MaintenanceRouter is a router able to answer all requests sent to a
+// backend with a static page, keeping its routes, while the app is under
+// maintenance. RemoveMaintenancePage restores the routing of requests to the
+// routes of the backend.
+type MaintenanceRouter interface {
+	SetMaintenancePage(name string, page string) error
+	RemoveMaintenancePage(name string) error
+}
+
 type HealthcheckData struct {
 	Path   string
 	Status int
@@ -24,12 +24,32 @@ var TLSRouter = tlsRouter{
 	Keys:       make(map[string]string),
 }
 
+var RateLimitRouter = rateLimitRouter{
+	fakeRouter: newFakeRouter(),
+	Limits:     make(map[string]router.RateLimit),
+	Support:    router.RateLimitSupport{PerIP: true, Global: true, Burst: true},
+}
+
+var WeightedRouter = weightedRouter{
+	fakeRouter: newFakeRouter(),
+	Weights:    make(map[string]map[string]int),
+	History:    make(map[string][]int),
+}
+
+var MaintenanceRouter = maintenanceRouter{
+	fakeRouter: newFakeRouter(),
+	Pages:      make(map[string]string),
+}
+
 var ErrForcedFailure = errors.New("Forced failure")
 
 func init() {
 	router.Register("fake", createRouter)
 	router.Register("fake-hc", createHCRouter)
 	router.Register("fake-tls", createTLSRouter)
+	router.Register("fake-ratelimit", createRateLimitRouter)
+	router.Register("fake-weighted", createWeightedRouter)
+	router.Register("fake-maintenance", createMaintenanceRouter)
 }
 
 func createRouter(name, prefix string) (router.Router, error) {
@@ -44,6 +64,18 @@ func createTLSRouter(name, prefix string) (router.Router, error) {
 	return &TLSRouter, nil
 }
 
+func createRateLimitRouter(name, prefix string) (router.Router, error) {
+	return &RateLimitRouter, nil
+}
+
+func createWeightedRouter(name, prefix string) (router.Router, error) {
+	return &WeightedRouter, nil
+}
+
+func createMaintenanceRouter(name, prefix string) (router.Router, error) {
+	return &MaintenanceRouter, nil
+}
+
 func newFakeRouter() fakeRouter {
 	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), mutex: &sync.Mutex{}}
 }
@@ -421,3 +453,126 @@ func (r *tlsRouter) GetCertificate(cname string) (string, error) {
 	}
 	return data, nil
 }
+
+type rateLimitRouter struct {
+	fakeRouter
+	Limits  map[string]router.RateLimit
+	Support router.RateLimitSupport
+}
+
+func (r *rateLimitRouter) Reset() {
+	r.fakeRouter.Reset()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Limits = make(map[string]router.RateLimit)
+	r.Support = router.RateLimitSupport{PerIP: true, Global: true, Burst: true}
+}
+
+func (r *rateLimitRouter) SetRateLimit(name string, limit router.RateLimit) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !r.HasBackend(backendName) {
+		return router.ErrBackendNotFound
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Limits[backendName] = limit
+	return nil
+}
+
+func (r *rateLimitRouter) RemoveRateLimit(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.Limits, backendName)
+	return nil
+}
+
+func (r *rateLimitRouter) RateLimitSupport() router.RateLimitSupport {
+	return r.Support
+}
+
+type weightedRouter struct {
+	fakeRouter
+	// Weights holds the weight of the weighted routes of each backend, by
+	// the host of the route.
+	Weights map[string]map[string]int
+	// History holds every weight set in each backend, removals of weights
+	// are recorded as -1.
+	History map[string][]int
+}
+
+func (r *weightedRouter) Reset() {
+	r.fakeRouter.Reset()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Weights = make(map[string]map[string]int)
+	r.History = make(map[string][]int)
+}
+
+func (r *weightedRouter) SetRoutesWeight(name string, addresses []*url.URL, weight int) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !r.HasBackend(backendName) {
+		return router.ErrBackendNotFound
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(addresses) == 0 {
+		delete(r.Weights, backendName)
+		r.History[backendName] = append(r.History[backendName], -1)
+		return nil
+	}
+	weights := make(map[string]int, len(addresses))
+	for _, addr := range addresses {
+		weights[addr.Host] = weight
+	}
+	r.Weights[backendName] = weights
+	r.History[backendName] = append(r.History[backendName], weight)
+	return nil
+}
+
+type maintenanceRouter struct {
+	fakeRouter
+	// Pages holds the maintenance page served by each backend.
+	Pages map[string]string
+}
+
+func (r *maintenanceRouter) Reset() {
+	r.fakeRouter.Reset()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Pages = make(map[string]string)
+}
+
+func (r *maintenanceRouter) SetMaintenancePage(name string, page string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !r.HasBackend(backendName) {
+		return router.ErrBackendNotFound
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Pages[backendName] = page
+	return nil
+}
+
+func (r *maintenanceRouter) RemoveMaintenancePage(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.Pages, backendName)
+	return nil
+}
@@ -9,8 +9,10 @@ package vulcand
 import (
 	"crypto/md5"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/hc"
@@ -18,10 +20,21 @@ import (
 	"github.com/vulcand/route"
 	"github.com/vulcand/vulcand/api"
 	"github.com/vulcand/vulcand/engine"
+	"github.com/vulcand/vulcand/plugin/cbreaker"
+	"github.com/vulcand/vulcand/plugin/ratelimit"
 	"github.com/vulcand/vulcand/plugin/registry"
 )
 
-const routerName = "vulcand"
+const (
+	routerName = "vulcand"
+
+	rateLimitMiddleware   = "tsuru_ratelimit"
+	maintenanceMiddleware = "tsuru_maintenance"
+
+	// maintenanceDuration is the time a tripped maintenance circuit breaker
+	// keeps serving the maintenance page, longer than any maintenance.
+	maintenanceDuration = 10 * 365 * 24 * time.Hour
+)
 
 func init() {
 	router.Register(routerName, createRouter)
@@ -163,6 +176,10 @@ func (r *vulcandRouter) RemoveBackend(name string) (err error) {
 		}
 		return &router.RouterError{Err: err, Op: "remove-backend"}
 	}
+	err = removeWeights(backendKey.Id)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "remove-backend"}
+	}
 	return nil
 }
 
@@ -182,15 +199,14 @@ func (r *vulcandRouter) AddRoute(name string, address *url.URL) (err error) {
 	if found, _ := r.client.GetServer(serverKey); found != nil {
 		return router.ErrRouteExists
 	}
-	server, err := engine.NewServer(serverKey.Id, address.String())
+	weights, err := getWeights(serverKey.BackendKey.Id)
 	if err != nil {
 		return &router.RouterError{Err: err, Op: "add-route"}
 	}
-	err = r.client.UpsertServer(serverKey.BackendKey, *server, engine.NoTTL)
-	if err != nil {
-		return &router.RouterError{Err: err, Op: "add-route"}
+	if weights.isIdle(address.Host) {
+		return router.ErrRouteExists
 	}
-	return nil
+	return r.syncServers(usedName, []*url.URL{address}, nil, "add-route")
 }
 
 func (r *vulcandRouter) AddRoutes(name string, addresses []*url.URL) (err error) {
@@ -202,21 +218,7 @@ func (r *vulcandRouter) AddRoutes(name string, addresses []*url.URL) (err error)
 	if err != nil {
 		return err
 	}
-	for _, addr := range addresses {
-		serverKey := engine.ServerKey{
-			Id:         r.serverName(addr.Host),
-			BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
-		}
-		server, err := engine.NewServer(serverKey.Id, addr.String())
-		if err != nil {
-			return &router.RouterError{Err: err, Op: "add-route"}
-		}
-		err = r.client.UpsertServer(serverKey.BackendKey, *server, engine.NoTTL)
-		if err != nil {
-			return &router.RouterError{Err: err, Op: "add-route"}
-		}
-	}
-	return nil
+	return r.syncServers(usedName, addresses, nil, "add-route")
 }
 
 func (r *vulcandRouter) RemoveRoute(name string, address *url.URL) (err error) {
@@ -232,14 +234,16 @@ func (r *vulcandRouter) RemoveRoute(name string, address *url.URL) (err error) {
 		Id:         r.serverName(address.Host),
 		BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
 	}
-	err = r.client.DeleteServer(serverKey)
-	if err != nil {
-		if _, ok := err.(*engine.NotFoundError); ok {
+	if found, _ := r.client.GetServer(serverKey); found == nil {
+		weights, err := getWeights(serverKey.BackendKey.Id)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: "remove-route"}
+		}
+		if !weights.isIdle(address.Host) {
 			return router.ErrRouteNotFound
 		}
-		return &router.RouterError{Err: err, Op: "remove-route"}
 	}
-	return nil
+	return r.syncServers(usedName, nil, []*url.URL{address}, "remove-route")
 }
 
 func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) (err error) {
@@ -251,20 +255,7 @@ func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) (err err
 	if err != nil {
 		return err
 	}
-	for _, addr := range addresses {
-		serverKey := engine.ServerKey{
-			Id:         r.serverName(addr.Host),
-			BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
-		}
-		err = r.client.DeleteServer(serverKey)
-		if err != nil {
-			if _, ok := err.(*engine.NotFoundError); ok {
-				continue
-			}
-			return &router.RouterError{Err: err, Op: "remove-route"}
-		}
-	}
-	return nil
+	return r.syncServers(usedName, nil, addresses, "remove-route")
 }
 
 func (r *vulcandRouter) CNames(name string) (urls []*url.URL, err error) {
@@ -322,6 +313,20 @@ func (r *vulcandRouter) SetCName(cname, name string) (err error) {
 	if err != nil {
 		return &router.RouterError{Err: err, Op: "set-cname"}
 	}
+	appFrontend := engine.FrontendKey{Id: r.frontendName(r.frontendHostname(usedName))}
+	for _, id := range []string{rateLimitMiddleware, maintenanceMiddleware} {
+		m, err := r.client.GetMiddleware(engine.MiddlewareKey{FrontendKey: appFrontend, Id: id})
+		if err != nil {
+			if _, ok := err.(*engine.NotFoundError); ok {
+				continue
+			}
+			return &router.RouterError{Err: err, Op: "set-cname"}
+		}
+		err = r.client.UpsertMiddleware(engine.FrontendKey{Id: frontendName}, *m, engine.NoTTL)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: "set-cname"}
+		}
+	}
 	return nil
 }
 
@@ -381,10 +386,23 @@ func (r *vulcandRouter) Routes(name string) (routes []*url.URL, err error) {
 	if err != nil {
 		return nil, &router.RouterError{Err: err, Op: "routes"}
 	}
-	routes = make([]*url.URL, len(servers))
-	for i, server := range servers {
+	routes = []*url.URL{}
+	for _, server := range servers {
+		if isServerCopy(server.Id) {
+			continue
+		}
 		parsedUrl, _ := url.Parse(server.URL)
-		routes[i] = parsedUrl
+		routes = append(routes, parsedUrl)
+	}
+	weights, err := getWeights(r.backendName(usedName))
+	if err != nil {
+		return nil, &router.RouterError{Err: err, Op: "routes"}
+	}
+	if weights != nil {
+		for _, addr := range weights.Idle {
+			parsedUrl, _ := url.Parse(addr)
+			routes = append(routes, parsedUrl)
+		}
 	}
 	return routes, nil
 }
@@ -401,3 +419,147 @@ func (r *vulcandRouter) HealthCheck() (err error) {
 	}()
 	return r.client.GetStatus()
 }
+
+// SetRateLimit adds a ratelimit middleware to the frontends of the app,
+// including the ones of its cnames. Vulcand burst is the size of the token
+// bucket, so the extra requests of the limit are added to the rate.
+func (r *vulcandRouter) SetRateLimit(name string, limit router.RateLimit) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	variable := "request.host"
+	if limit.PerIP {
+		variable = "client.ip"
+	}
+	m, err := ratelimit.FromOther(ratelimit.RateLimit{
+		PeriodSeconds: 1,
+		Requests:      int64(limit.RequestsPerSecond),
+		Burst:         int64(limit.RequestsPerSecond + limit.Burst),
+		Variable:      variable,
+	})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-ratelimit"}
+	}
+	frontends, err := r.appFrontends(name)
+	if err != nil {
+		return err
+	}
+	middleware := engine.Middleware{Id: rateLimitMiddleware, Type: "ratelimit", Priority: 1, Middleware: m}
+	for _, fk := range frontends {
+		err = r.client.UpsertMiddleware(fk, middleware, engine.NoTTL)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: "set-ratelimit"}
+		}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RemoveRateLimit(name string) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	frontends, err := r.appFrontends(name)
+	if err != nil {
+		return err
+	}
+	for _, fk := range frontends {
+		err = r.client.DeleteMiddleware(engine.MiddlewareKey{FrontendKey: fk, Id: rateLimitMiddleware})
+		if err != nil {
+			if _, ok := err.(*engine.NotFoundError); ok {
+				continue
+			}
+			return &router.RouterError{Err: err, Op: "remove-ratelimit"}
+		}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RateLimitSupport() router.RateLimitSupport {
+	return router.RateLimitSupport{PerIP: true, Global: true, Burst: true}
+}
+
+// SetMaintenancePage adds a cbreaker middleware answering the requests sent
+// to the frontends of the app with the page. Vulcand only serves static
+// responses as the fallback of a tripped circuit breaker, so the middleware
+// trips on the first request it handles, which still reaches the units of the
+// app, and keeps serving the page until it's removed.
+func (r *vulcandRouter) SetMaintenancePage(name string, page string) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	m, err := cbreaker.FromOther(cbreaker.Spec{
+		Condition: "NetworkErrorRatio() >= 0.0",
+		Fallback: map[string]interface{}{
+			"Type": "response",
+			"Action": map[string]interface{}{
+				"StatusCode":  http.StatusServiceUnavailable,
+				"ContentType": "text/html; charset=utf-8",
+				"Body":        []byte(page),
+			},
+		},
+		FallbackDuration: maintenanceDuration,
+		RecoveryDuration: time.Second,
+		CheckPeriod:      time.Millisecond,
+	})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-maintenance-page"}
+	}
+	frontends, err := r.appFrontends(name)
+	if err != nil {
+		return err
+	}
+	middleware := engine.Middleware{Id: maintenanceMiddleware, Type: cbreaker.Type, Middleware: m}
+	for _, fk := range frontends {
+		err = r.client.UpsertMiddleware(fk, middleware, engine.NoTTL)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: "set-maintenance-page"}
+		}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RemoveMaintenancePage(name string) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	frontends, err := r.appFrontends(name)
+	if err != nil {
+		return err
+	}
+	for _, fk := range frontends {
+		err = r.client.DeleteMiddleware(engine.MiddlewareKey{FrontendKey: fk, Id: maintenanceMiddleware})
+		if err != nil {
+			if _, ok := err.(*engine.NotFoundError); ok {
+				continue
+			}
+			return &router.RouterError{Err: err, Op: "remove-maintenance-page"}
+		}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) appFrontends(name string) ([]engine.FrontendKey, error) {
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	frontends, err := r.client.GetFrontends()
+	if err != nil {
+		return nil, &router.RouterError{Err: err, Op: "frontends"}
+	}
+	backendName := r.backendName(usedName)
+	var keys []engine.FrontendKey
+	for _, f := range frontends {
+		if f.BackendId == backendName {
+			keys = append(keys, engine.FrontendKey{Id: f.GetId()})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, router.ErrBackendNotFound
+	}
+	return keys, nil
+}
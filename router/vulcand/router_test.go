@@ -25,6 +25,8 @@ import (
 	"github.com/vulcand/vulcand/api"
 	"github.com/vulcand/vulcand/engine"
 	"github.com/vulcand/vulcand/engine/memng"
+	"github.com/vulcand/vulcand/plugin/cbreaker"
+	"github.com/vulcand/vulcand/plugin/ratelimit"
 	"github.com/vulcand/vulcand/plugin/registry"
 	"github.com/vulcand/vulcand/supervisor"
 	"gopkg.in/check.v1"
@@ -466,3 +468,267 @@ func (s *S) TestHealthCheckFailure(c *check.C) {
 	c.Assert(ok, check.Equals, true)
 	c.Assert(hcRouter.HealthCheck(), check.ErrorMatches, ".* connection refused")
 }
+
+func (s *S) TestSetRateLimit(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.CNameRouter).SetCName("myapp.cname.example.com", "myapp")
+	c.Assert(err, check.IsNil)
+	rlRouter, ok := vRouter.(router.RateLimitRouter)
+	c.Assert(ok, check.Equals, true)
+	err = rlRouter.SetRateLimit("myapp", router.RateLimit{RequestsPerSecond: 10, Burst: 5, PerIP: true})
+	c.Assert(err, check.IsNil)
+	for _, frontend := range []string{"tsuru_myapp.vulcand.example.com", "tsuru_myapp.cname.example.com"} {
+		m, err := s.engine.GetMiddleware(engine.MiddlewareKey{
+			FrontendKey: engine.FrontendKey{Id: frontend},
+			Id:          "tsuru_ratelimit",
+		})
+		c.Assert(err, check.IsNil)
+		limit, ok := m.Middleware.(*ratelimit.RateLimit)
+		c.Assert(ok, check.Equals, true)
+		c.Assert(limit.PeriodSeconds, check.Equals, int64(1))
+		c.Assert(limit.Requests, check.Equals, int64(10))
+		c.Assert(limit.Burst, check.Equals, int64(15))
+		c.Assert(limit.Variable, check.Equals, "client.ip")
+	}
+	err = rlRouter.RemoveRateLimit("myapp")
+	c.Assert(err, check.IsNil)
+	middlewares, err := s.engine.GetMiddlewares(engine.FrontendKey{Id: "tsuru_myapp.vulcand.example.com"})
+	c.Assert(err, check.IsNil)
+	c.Assert(middlewares, check.HasLen, 0)
+}
+
+func (s *S) TestSetCNameKeepsRateLimit(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.RateLimitRouter).SetRateLimit("myapp", router.RateLimit{RequestsPerSecond: 10})
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.CNameRouter).SetCName("myapp.cname.example.com", "myapp")
+	c.Assert(err, check.IsNil)
+	m, err := s.engine.GetMiddleware(engine.MiddlewareKey{
+		FrontendKey: engine.FrontendKey{Id: "tsuru_myapp.cname.example.com"},
+		Id:          "tsuru_ratelimit",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Middleware.(*ratelimit.RateLimit).Variable, check.Equals, "request.host")
+}
+
+func (s *S) TestSetRateLimitBackendNotFound(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.RateLimitRouter).SetRateLimit("myapp", router.RateLimit{RequestsPerSecond: 10})
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+}
+
+func (s *S) TestSetMaintenancePage(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.CNameRouter).SetCName("myapp.cname.example.com", "myapp")
+	c.Assert(err, check.IsNil)
+	mRouter, ok := vRouter.(router.MaintenanceRouter)
+	c.Assert(ok, check.Equals, true)
+	err = mRouter.SetMaintenancePage("myapp", "<h1>Back soon</h1>")
+	c.Assert(err, check.IsNil)
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app"))
+	})
+	for _, frontend := range []string{"tsuru_myapp.vulcand.example.com", "tsuru_myapp.cname.example.com"} {
+		m, err := s.engine.GetMiddleware(engine.MiddlewareKey{
+			FrontendKey: engine.FrontendKey{Id: frontend},
+			Id:          "tsuru_maintenance",
+		})
+		c.Assert(err, check.IsNil)
+		spec, ok := m.Middleware.(*cbreaker.Spec)
+		c.Assert(ok, check.Equals, true)
+		handler, err := spec.NewHandler(app)
+		c.Assert(err, check.IsNil)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		c.Assert(recorder.Body.String(), check.Equals, "app")
+		for i := 0; i < 2; i++ {
+			recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+			c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+			c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/html; charset=utf-8")
+			c.Assert(recorder.Body.String(), check.Equals, "<h1>Back soon</h1>")
+		}
+	}
+	err = mRouter.RemoveMaintenancePage("myapp")
+	c.Assert(err, check.IsNil)
+	for _, frontend := range []string{"tsuru_myapp.vulcand.example.com", "tsuru_myapp.cname.example.com"} {
+		middlewares, err := s.engine.GetMiddlewares(engine.FrontendKey{Id: frontend})
+		c.Assert(err, check.IsNil)
+		c.Assert(middlewares, check.HasLen, 0)
+	}
+}
+
+func (s *S) TestSetCNameKeepsMaintenancePage(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.MaintenanceRouter).SetMaintenancePage("myapp", "<h1>Back soon</h1>")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.CNameRouter).SetCName("myapp.cname.example.com", "myapp")
+	c.Assert(err, check.IsNil)
+	m, err := s.engine.GetMiddleware(engine.MiddlewareKey{
+		FrontendKey: engine.FrontendKey{Id: "tsuru_myapp.cname.example.com"},
+		Id:          "tsuru_maintenance",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Type, check.Equals, "cbreaker")
+	_, err = s.engine.GetMiddleware(engine.MiddlewareKey{
+		FrontendKey: engine.FrontendKey{Id: "tsuru_myapp.cname.example.com"},
+		Id:          "tsuru_ratelimit",
+	})
+	c.Assert(err, check.FitsTypeOf, &engine.NotFoundError{})
+}
+
+func (s *S) TestSetMaintenancePageBackendNotFound(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.MaintenanceRouter).SetMaintenancePage("myapp", "<h1>Back soon</h1>")
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+	err = vRouter.(router.MaintenanceRouter).RemoveMaintenancePage("myapp")
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+}
+
+func (s *S) serversByHost(c *check.C, backend string) map[string]int {
+	servers, err := s.engine.GetServers(engine.BackendKey{Id: backend})
+	c.Assert(err, check.IsNil)
+	hosts := make(map[string]int)
+	for _, server := range servers {
+		u, err := url.Parse(server.URL)
+		c.Assert(err, check.IsNil)
+		hosts[u.Host]++
+	}
+	return hosts
+}
+
+func (s *S) TestSetRoutesWeight(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	err = vRouter.AddRoute("myapp", u1)
+	c.Assert(err, check.IsNil)
+	wRouter, ok := vRouter.(router.WeightedRouter)
+	c.Assert(ok, check.Equals, true)
+	err = wRouter.SetRoutesWeight("myapp", []*url.URL{u2}, 0)
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddRoute("myapp", u2)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"1.1.1.1:111": 1})
+	routes, err := vRouter.Routes("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{u1, u2})
+	err = vRouter.AddRoute("myapp", u2)
+	c.Assert(err, check.Equals, router.ErrRouteExists)
+	err = wRouter.SetRoutesWeight("myapp", []*url.URL{u2}, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"1.1.1.1:111": 9, "2.2.2.2:222": 1})
+	err = wRouter.SetRoutesWeight("myapp", []*url.URL{u2}, 50)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"1.1.1.1:111": 1, "2.2.2.2:222": 1})
+	err = wRouter.SetRoutesWeight("myapp", []*url.URL{u2}, 100)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"2.2.2.2:222": 1})
+	routes, err = vRouter.Routes("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{u2, u1})
+	err = wRouter.SetRoutesWeight("myapp", nil, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"1.1.1.1:111": 1, "2.2.2.2:222": 1})
+	count, err := s.conn.Collection("vulcand_weights").Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestSetRoutesWeightAddAndRemoveRoutes(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	u3, _ := url.Parse("http://3.3.3.3:333")
+	err = vRouter.AddRoutes("myapp", []*url.URL{u1, u2})
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.WeightedRouter).SetRoutesWeight("myapp", []*url.URL{u3}, 20)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"1.1.1.1:111": 1, "2.2.2.2:222": 1})
+	err = vRouter.AddRoutes("myapp", []*url.URL{u3})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{
+		"1.1.1.1:111": 2,
+		"2.2.2.2:222": 2,
+		"3.3.3.3:333": 1,
+	})
+	err = vRouter.RemoveRoute("myapp", u1)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"2.2.2.2:222": 4, "3.3.3.3:333": 1})
+	routes, err := vRouter.Routes("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{u2, u3})
+	err = vRouter.RemoveRoutes("myapp", []*url.URL{u2})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"3.3.3.3:333": 1})
+}
+
+func (s *S) TestSetRoutesWeightRemoveIdleRoute(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	err = vRouter.AddRoutes("myapp", []*url.URL{u1, u2})
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.WeightedRouter).SetRoutesWeight("myapp", []*url.URL{u2}, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serversByHost(c, "tsuru_myapp"), check.DeepEquals, map[string]int{"1.1.1.1:111": 1})
+	err = vRouter.RemoveRoute("myapp", u2)
+	c.Assert(err, check.IsNil)
+	routes, err := vRouter.Routes("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{u1})
+	err = vRouter.RemoveRoute("myapp", u2)
+	c.Assert(err, check.Equals, router.ErrRouteNotFound)
+}
+
+func (s *S) TestSetRoutesWeightBackendNotFound(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	err = vRouter.(router.WeightedRouter).SetRoutesWeight("myapp", []*url.URL{u1}, 10)
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+}
+
+func (s *S) TestRemoveBackendRemovesWeights(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	err = vRouter.AddRoutes("myapp", []*url.URL{u1, u2})
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.WeightedRouter).SetRoutesWeight("myapp", []*url.URL{u2}, 0)
+	c.Assert(err, check.IsNil)
+	err = vRouter.RemoveBackend("myapp")
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.Collection("vulcand_weights").Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	backends, err := s.engine.GetBackends()
+	c.Assert(err, check.IsNil)
+	c.Assert(backends, check.HasLen, 0)
+}
@@ -0,0 +1,309 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !windows
+
+package vulcand
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/router"
+	"github.com/vulcand/vulcand/engine"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// backendWeights holds the weights set with SetRoutesWeight in a backend.
+//
+// Vulcand splits requests evenly among the servers of a backend, so weights
+// are applied by adding copies of the servers of the routes, which only
+// differ from the server of the route by the path of their URL, ignored when
+// requests are forwarded. Routes receiving no requests are removed from
+// vulcand and kept in Idle, until the weights change.
+type backendWeights struct {
+	Backend string `bson:"_id"`
+	Hosts   []string
+	Weight  int
+	Idle    []string
+}
+
+func (w *backendWeights) isIdle(host string) bool {
+	if w == nil {
+		return false
+	}
+	for _, addr := range w.Idle {
+		if u, err := url.Parse(addr); err == nil && u.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+func weightsCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("vulcand_weights"), nil
+}
+
+func getWeights(backendName string) (*backendWeights, error) {
+	coll, err := weightsCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var weights backendWeights
+	err = coll.FindId(backendName).One(&weights)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &weights, nil
+}
+
+func removeWeights(backendName string) error {
+	coll, err := weightsCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(backendName)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// SetRoutesWeight sends weight percent of the requests of the backend to the
+// given routes, through copies of their servers in vulcand. The weights are
+// applied again whenever routes are added or removed.
+func (r *vulcandRouter) SetRoutesWeight(name string, addresses []*url.URL, weight int) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	backendName := r.backendName(usedName)
+	if found, _ := r.client.GetBackend(engine.BackendKey{Id: backendName}); found == nil {
+		return router.ErrBackendNotFound
+	}
+	if len(addresses) == 0 {
+		weights, err := getWeights(backendName)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: "set-routes-weight"}
+		}
+		if weights == nil {
+			return nil
+		}
+	}
+	hosts := make([]string, len(addresses))
+	for i, addr := range addresses {
+		hosts[i] = addr.Host
+	}
+	coll, err := weightsCollection()
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-routes-weight"}
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(backendName, bson.M{"$set": bson.M{"hosts": hosts, "weight": weight}})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-routes-weight"}
+	}
+	err = r.syncServers(usedName, nil, nil, "set-routes-weight")
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		err = removeWeights(backendName)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: "set-routes-weight"}
+		}
+	}
+	return nil
+}
+
+// syncServers adds and removes routes of the backend, updating the servers in
+// vulcand so that each route has the number of copies required by the
+// weights of the backend.
+func (r *vulcandRouter) syncServers(usedName string, toAdd, toRemove []*url.URL, op string) error {
+	backendKey := engine.BackendKey{Id: r.backendName(usedName)}
+	weights, err := getWeights(backendKey.Id)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: op}
+	}
+	servers, err := r.client.GetServers(backendKey)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: op}
+	}
+	current := make(map[string]string, len(servers))
+	routes := make(map[string]*url.URL)
+	var hosts []string
+	setRoute := func(addr *url.URL) {
+		if _, ok := routes[addr.Host]; !ok {
+			hosts = append(hosts, addr.Host)
+		}
+		routes[addr.Host] = addr
+	}
+	for _, server := range servers {
+		current[server.Id] = server.URL
+		if isServerCopy(server.Id) {
+			continue
+		}
+		if u, err := url.Parse(server.URL); err == nil {
+			setRoute(u)
+		}
+	}
+	weighted := make(map[string]bool)
+	if weights != nil {
+		for _, addr := range weights.Idle {
+			if u, err := url.Parse(addr); err == nil {
+				setRoute(u)
+			}
+		}
+		for _, host := range weights.Hosts {
+			weighted[host] = true
+		}
+	}
+	for _, addr := range toAdd {
+		setRoute(addr)
+	}
+	for _, addr := range toRemove {
+		delete(routes, addr.Host)
+	}
+	var weightedCount, othersCount int
+	for host := range routes {
+		if weighted[host] {
+			weightedCount++
+		} else {
+			othersCount++
+		}
+	}
+	weight := 0
+	if weights != nil {
+		weight = weights.Weight
+	}
+	weightedCopies, othersCopies := serverCopies(weightedCount, othersCount, weight)
+	var wantedIds []string
+	wanted := make(map[string]string)
+	idle := []string{}
+	for _, host := range hosts {
+		addr, ok := routes[host]
+		if !ok {
+			continue
+		}
+		copies := othersCopies
+		if weighted[host] {
+			copies = weightedCopies
+		}
+		if copies == 0 {
+			idle = append(idle, addr.String())
+			continue
+		}
+		for i := 0; i < copies; i++ {
+			id, serverAddr := r.serverName(host), addr.String()
+			if i > 0 {
+				copyAddr := *addr
+				copyAddr.Path = fmt.Sprintf("/tsuru_copy_%d", i)
+				id, serverAddr = fmt.Sprintf("%s_%d", id, i), copyAddr.String()
+			}
+			wantedIds = append(wantedIds, id)
+			wanted[id] = serverAddr
+		}
+	}
+	for _, id := range wantedIds {
+		addr := wanted[id]
+		if current[id] == addr {
+			continue
+		}
+		server, err := engine.NewServer(id, addr)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: op}
+		}
+		err = r.client.UpsertServer(backendKey, *server, engine.NoTTL)
+		if err != nil {
+			return &router.RouterError{Err: err, Op: op}
+		}
+	}
+	for id := range current {
+		if _, ok := wanted[id]; ok {
+			continue
+		}
+		err = r.client.DeleteServer(engine.ServerKey{Id: id, BackendKey: backendKey})
+		if err != nil {
+			if _, ok := err.(*engine.NotFoundError); ok {
+				continue
+			}
+			return &router.RouterError{Err: err, Op: op}
+		}
+	}
+	if weights == nil {
+		return nil
+	}
+	sort.Strings(idle)
+	coll, err := weightsCollection()
+	if err != nil {
+		return &router.RouterError{Err: err, Op: op}
+	}
+	defer coll.Close()
+	err = coll.UpdateId(backendKey.Id, bson.M{"$set": bson.M{"idle": idle}})
+	if err != nil && err != mgo.ErrNotFound {
+		return &router.RouterError{Err: err, Op: op}
+	}
+	return nil
+}
+
+// isServerCopy returns whether the server id is of a copy of the server of a
+// route, named after the server of the route with an index suffix.
+func isServerCopy(id string) bool {
+	return strings.Count(id, "_") > 1
+}
+
+// serverCopies returns the number of servers of each weighted route and of
+// each of the other routes of a backend, so that the weighted routes receive
+// about weight percent of the requests. Weights are ignored unless the
+// backend has both kinds of routes.
+func serverCopies(weightedCount, othersCount, weight int) (int, int) {
+	if weightedCount == 0 || othersCount == 0 {
+		return 1, 1
+	}
+	if weight <= 0 {
+		return 0, 1
+	}
+	if weight >= 100 {
+		return 1, 0
+	}
+	weightedCopies := roundDiv(weight, weightedCount)
+	if weightedCopies == 0 {
+		weightedCopies = 1
+	}
+	othersCopies := roundDiv(100-weight, othersCount)
+	if othersCopies == 0 {
+		othersCopies = 1
+	}
+	d := gcd(weightedCopies, othersCopies)
+	return weightedCopies / d, othersCopies / d
+}
+
+func roundDiv(a, b int) int {
+	return (2*a + b) / (2 * b)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
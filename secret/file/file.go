@@ -0,0 +1,103 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package file provides an implementation of the secret.Backend, that stores
+// each secret in a file readable only by tsuru's user. This package doesn't
+// expose any public types, in order to use it, users need to import the
+// package and then configure tsuru to use the "file" secrets backend.
+//
+//     import _ "github.com/tsuru/tsuru/secret/file"
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/secret"
+)
+
+func init() {
+	secret.Register("file", fileBackend{})
+}
+
+type fileBackend struct{}
+
+func (fileBackend) path(key string) (string, error) {
+	root, err := config.GetString("secrets:file:path")
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 {
+		return "", errors.Errorf("invalid secret key %q", key)
+	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", errors.Errorf("invalid secret key %q", key)
+		}
+	}
+	return filepath.Join(root, parts[0], parts[1]), nil
+}
+
+func (b fileBackend) Set(appName, name, value string) (string, error) {
+	key := appName + "/" + name
+	path, err := b.path(key)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", err
+	}
+	// The value is written to a temporary file and renamed, so units never
+	// read a partially written secret.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+name)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (b fileBackend) Get(key string) (string, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", secret.ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (b fileBackend) Remove(key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return secret.ErrSecretNotFound
+	}
+	return err
+}
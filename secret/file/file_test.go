@@ -0,0 +1,78 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	dir string
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	s.dir = c.MkDir()
+	config.Set("secrets:file:path", s.dir)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("secrets")
+}
+
+func (s *S) TestSetGetAndRemove(c *check.C) {
+	var b fileBackend
+	key, err := b.Set("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.Equals, "myapp/DATABASE_PASSWORD")
+	path := filepath.Join(s.dir, "myapp", "DATABASE_PASSWORD")
+	info, err := os.Stat(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Mode().Perm(), check.Equals, os.FileMode(0600))
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "s3cr3t")
+	_, err = b.Set("myapp", "DATABASE_PASSWORD", "n3w")
+	c.Assert(err, check.IsNil)
+	value, err := b.Get(key)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "n3w")
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, "myapp"))
+	c.Assert(err, check.IsNil)
+	c.Assert(files, check.HasLen, 1)
+	err = b.Remove(key)
+	c.Assert(err, check.IsNil)
+	_, err = b.Get(key)
+	c.Assert(err, check.Equals, secret.ErrSecretNotFound)
+	err = b.Remove(key)
+	c.Assert(err, check.Equals, secret.ErrSecretNotFound)
+}
+
+func (s *S) TestInvalidKeys(c *check.C) {
+	var b fileBackend
+	for _, key := range []string{"myapp", "myapp/", "../A", "myapp/../../A", "myapp/a/b"} {
+		_, err := b.Get(key)
+		c.Check(err, check.ErrorMatches, "invalid secret key.*")
+	}
+	_, err := b.Set("..", "A", "value")
+	c.Assert(err, check.ErrorMatches, `invalid secret key "\.\./A"`)
+}
+
+func (s *S) TestPathNotConfigured(c *check.C) {
+	config.Unset("secrets:file:path")
+	var b fileBackend
+	_, err := b.Set("myapp", "A", "value")
+	c.Assert(err, check.NotNil)
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package secret contains types and functions for storing the values of
+// secret environment variables outside of tsuru's database.
+//
+// Values are stored in the backend defined by the "secrets:backend" setting
+// and referenced by the name of the backend and the key where they're stored,
+// so references stay valid when the configured backend changes.
+package secret
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+var backends map[string]Backend
+
+var (
+	ErrNoBackend      = errors.New("secrets backend not configured")
+	ErrSecretNotFound = errors.New("secret not found")
+)
+
+// Backend represents a storage of secret values.
+type Backend interface {
+	// Set stores the value of the secret variable name of the app, returning
+	// the key where it's stored.
+	Set(appName, name, value string) (string, error)
+
+	// Get returns the value stored in the given key.
+	Get(key string) (string, error)
+
+	// Remove removes the value stored in the given key.
+	Remove(key string) error
+}
+
+// Register registers a new secrets backend, that can be later configured and
+// used.
+func Register(name string, backend Backend) {
+	if backends == nil {
+		backends = make(map[string]Backend)
+	}
+	backends[name] = backend
+}
+
+// Check returns an error if no valid secrets backend is configured.
+func Check() error {
+	_, _, err := configuredBackend()
+	return err
+}
+
+func configuredBackend() (string, Backend, error) {
+	name, err := config.GetString("secrets:backend")
+	if err != nil {
+		return "", nil, ErrNoBackend
+	}
+	backend, err := getBackend(name)
+	return name, backend, err
+}
+
+func getBackend(name string) (Backend, error) {
+	backend, ok := backends[name]
+	if !ok {
+		return nil, errors.Errorf("unknown secrets backend %q", name)
+	}
+	return backend, nil
+}
+
+func parseRef(ref string) (Backend, string, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, "", errors.Errorf("invalid secret reference %q", ref)
+	}
+	backend, err := getBackend(parts[0])
+	return backend, parts[1], err
+}
+
+// Store stores the value of the secret variable name of the app in the
+// configured backend, returning the reference to the stored value.
+func Store(appName, name, value string) (string, error) {
+	backendName, backend, err := configuredBackend()
+	if err != nil {
+		return "", err
+	}
+	key, err := backend.Set(appName, name, value)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to store secret %q", name)
+	}
+	return backendName + ":" + key, nil
+}
+
+// Resolve returns the value referenced by ref.
+func Resolve(ref string) (string, error) {
+	backend, key, err := parseRef(ref)
+	if err != nil {
+		return "", err
+	}
+	value, err := backend.Get(key)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to resolve secret %q", ref)
+	}
+	return value, nil
+}
+
+// Remove removes the value referenced by ref. Removing a secret that doesn't
+// exist isn't an error.
+func Remove(ref string) error {
+	backend, key, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+	err = backend.Remove(key)
+	if err != nil && err != ErrSecretNotFound {
+		return errors.Wrapf(err, "unable to remove secret %q", ref)
+	}
+	return nil
+}
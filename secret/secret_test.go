@@ -0,0 +1,109 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type mapBackend map[string]string
+
+func (b mapBackend) Set(appName, name, value string) (string, error) {
+	key := appName + "/" + name
+	b[key] = value
+	return key, nil
+}
+
+func (b mapBackend) Get(key string) (string, error) {
+	value, ok := b[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (b mapBackend) Remove(key string) error {
+	if _, ok := b[key]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(b, key)
+	return nil
+}
+
+type failingBackend struct{ mapBackend }
+
+func (failingBackend) Remove(key string) error {
+	return errors.New("backend unavailable")
+}
+
+func (s *S) TestRegisterOnNilMap(c *check.C) {
+	oldBackends := backends
+	backends = nil
+	defer func() {
+		backends = oldBackends
+	}()
+	backend := mapBackend{}
+	Register("map", backend)
+	c.Assert(backends["map"], check.DeepEquals, backend)
+}
+
+func (s *S) TestStoreResolveAndRemove(c *check.C) {
+	backend := mapBackend{}
+	Register("map", backend)
+	defer delete(backends, "map")
+	config.Set("secrets:backend", "map")
+	defer config.Unset("secrets:backend")
+	c.Assert(Check(), check.IsNil)
+	ref, err := Store("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(ref, check.Equals, "map:myapp/DATABASE_PASSWORD")
+	c.Assert(backend, check.DeepEquals, mapBackend{"myapp/DATABASE_PASSWORD": "s3cr3t"})
+	// references are resolved by the backend that stored them, even after
+	// the configured backend changes.
+	config.Set("secrets:backend", "other")
+	value, err := Resolve(ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	err = Remove(ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(backend, check.HasLen, 0)
+	err = Remove(ref)
+	c.Assert(err, check.IsNil)
+	_, err = Resolve(ref)
+	c.Assert(err, check.ErrorMatches, `unable to resolve secret "map:myapp/DATABASE_PASSWORD": secret not found`)
+}
+
+func (s *S) TestStoreNoBackend(c *check.C) {
+	config.Unset("secrets:backend")
+	c.Assert(Check(), check.Equals, ErrNoBackend)
+	_, err := Store("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.Equals, ErrNoBackend)
+}
+
+func (s *S) TestStoreUnknownBackend(c *check.C) {
+	config.Set("secrets:backend", "unknown")
+	defer config.Unset("secrets:backend")
+	c.Assert(Check(), check.ErrorMatches, `unknown secrets backend "unknown"`)
+	_, err := Store("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.ErrorMatches, `unknown secrets backend "unknown"`)
+}
+
+func (s *S) TestResolveInvalidReference(c *check.C) {
+	for _, ref := range []string{"", "map", "map:"} {
+		_, err := Resolve(ref)
+		c.Check(err, check.ErrorMatches, "invalid secret reference.*")
+	}
+	_, err := Resolve("unknown:myapp/A")
+	c.Assert(err, check.ErrorMatches, `unknown secrets backend "unknown"`)
+}
+
+func (s *S) TestRemoveError(c *check.C) {
+	Register("failing", failingBackend{})
+	defer delete(backends, "failing")
+	err := Remove("failing:myapp/A")
+	c.Assert(err, check.ErrorMatches, `unable to remove secret "failing:myapp/A": backend unavailable`)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package secrettest provides a fake secrets backend for use in tests.
+//
+// Users can use the fake backend by just importing this package, setting the
+// "secrets:backend" setting to "fake" and interacting with the backend.
+package secrettest
+
+import (
+	"sync"
+
+	"github.com/tsuru/tsuru/secret"
+)
+
+func init() {
+	secret.Register("fake", &backend)
+}
+
+var backend = fakeBackend{secrets: make(map[string]string)}
+
+type fakeBackend struct {
+	secrets map[string]string
+	mu      sync.Mutex
+}
+
+func (b *fakeBackend) Set(appName, name, value string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := appName + "/" + name
+	b.secrets[key] = value
+	return key, nil
+}
+
+func (b *fakeBackend) Get(key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.secrets[key]
+	if !ok {
+		return "", secret.ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (b *fakeBackend) Remove(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.secrets[key]; !ok {
+		return secret.ErrSecretNotFound
+	}
+	delete(b.secrets, key)
+	return nil
+}
+
+// Secrets returns a copy of the secrets stored in the fake backend, by key.
+func Secrets() map[string]string {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	result := make(map[string]string, len(backend.secrets))
+	for key, value := range backend.secrets {
+		result[key] = value
+	}
+	return result
+}
+
+// Reset removes all secrets stored in the fake backend.
+func Reset() {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.secrets = make(map[string]string)
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vault provides an implementation of the secret.Backend, that stores
+// secrets in the key/value secrets engine of HashiCorp Vault
+// (https://www.vaultproject.io). This package doesn't expose any public types,
+// in order to use it, users need to import the package and then configure
+// tsuru to use the "vault" secrets backend.
+//
+//     import _ "github.com/tsuru/tsuru/secret/vault"
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/secret"
+)
+
+const (
+	defaultMount     = "secret"
+	defaultPrefix    = "tsuru"
+	defaultKVVersion = 2
+)
+
+func init() {
+	secret.Register("vault", vaultBackend{})
+	hc.AddChecker("Vault", healthCheck)
+}
+
+func healthCheck() error {
+	backendName, _ := config.GetString("secrets:backend")
+	if backendName != "vault" {
+		return hc.ErrDisabledComponent
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	resp, err := c.do("GET", "/v1/sys/health", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status - %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type client struct {
+	address   string
+	token     string
+	mount     string
+	kvVersion int
+	http      *http.Client
+}
+
+func newClient() (*client, error) {
+	address, err := config.GetString("secrets:vault:address")
+	if err != nil {
+		return nil, err
+	}
+	token, err := config.GetString("secrets:vault:token")
+	if err != nil {
+		return nil, err
+	}
+	mount, _ := config.GetString("secrets:vault:mount")
+	if mount == "" {
+		mount = defaultMount
+	}
+	kvVersion, _ := config.GetInt("secrets:vault:kv-version")
+	if kvVersion == 0 {
+		kvVersion = defaultKVVersion
+	}
+	if kvVersion != 1 && kvVersion != 2 {
+		return nil, errors.Errorf("invalid vault kv-version %d, expected 1 or 2", kvVersion)
+	}
+	return &client{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		mount:     strings.Trim(mount, "/"),
+		kvVersion: kvVersion,
+		http:      net.Dial5Full60ClientNoKeepAlive,
+	}, nil
+}
+
+func (c *client) do(method, path string, body interface{}) (*http.Response, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, c.address+path, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(req)
+}
+
+// path returns the path in the API of the given key. In version 2 of the
+// key/value engine, data and metadata are handled by different paths.
+func (c *client) path(kind, key string) string {
+	if c.kvVersion == 1 {
+		return fmt.Sprintf("/v1/%s/%s", c.mount, key)
+	}
+	return fmt.Sprintf("/v1/%s/%s/%s", c.mount, kind, key)
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return secret.ErrSecretNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+type vaultBackend struct{}
+
+func (vaultBackend) Set(appName, name, value string) (string, error) {
+	c, err := newClient()
+	if err != nil {
+		return "", err
+	}
+	prefix, _ := config.GetString("secrets:vault:prefix")
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	key := fmt.Sprintf("%s/apps/%s/%s", strings.Trim(prefix, "/"), appName, name)
+	var body interface{} = map[string]string{"value": value}
+	if c.kvVersion == 2 {
+		body = map[string]interface{}{"data": body}
+	}
+	resp, err := c.do("POST", c.path("data", key), body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (vaultBackend) Get(key string) (string, error) {
+	c, err := newClient()
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do("GET", c.path("data", key), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Data struct {
+			Value *string
+			Data  struct {
+				Value *string
+			}
+		}
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", err
+	}
+	value := result.Data.Value
+	if c.kvVersion == 2 {
+		value = result.Data.Data.Value
+	}
+	if value == nil {
+		return "", secret.ErrSecretNotFound
+	}
+	return *value, nil
+}
+
+func (vaultBackend) Remove(key string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	resp, err := c.do("DELETE", c.path("metadata", key), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	server *httptest.Server
+	data   map[string]json.RawMessage
+	mu     sync.Mutex
+}
+
+var _ = check.Suite(&S{})
+
+// ServeHTTP implements a minimal key/value secrets engine, storing the request
+// bodies by path.
+func (s *S) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "my-token" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := strings.Replace(r.URL.Path, "/metadata/", "/data/", 1)
+	switch r.Method {
+	case "GET":
+		if r.URL.Path == "/v1/sys/health" {
+			return
+		}
+		data, ok := s.data[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":` + string(data) + `}`))
+	case "POST":
+		var data json.RawMessage
+		json.NewDecoder(r.Body).Decode(&data)
+		s.data[path] = data
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if _, ok := s.data[path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.data, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.data = make(map[string]json.RawMessage)
+	s.server = httptest.NewServer(s)
+	config.Set("secrets:backend", "vault")
+	config.Set("secrets:vault:address", s.server.URL)
+	config.Set("secrets:vault:token", "my-token")
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+	config.Unset("secrets")
+}
+
+func (s *S) TestSetGetAndRemove(c *check.C) {
+	var b vaultBackend
+	key, err := b.Set("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.Equals, "tsuru/apps/myapp/DATABASE_PASSWORD")
+	c.Assert(string(s.data["/v1/secret/data/tsuru/apps/myapp/DATABASE_PASSWORD"]), check.Equals, `{"data":{"value":"s3cr3t"}}`)
+	value, err := b.Get(key)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	err = b.Remove(key)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.data, check.HasLen, 0)
+	_, err = b.Get(key)
+	c.Assert(err, check.Equals, secret.ErrSecretNotFound)
+	err = b.Remove(key)
+	c.Assert(err, check.Equals, secret.ErrSecretNotFound)
+}
+
+func (s *S) TestSetGetKVVersion1(c *check.C) {
+	config.Set("secrets:vault:kv-version", 1)
+	config.Set("secrets:vault:mount", "/kv/")
+	config.Set("secrets:vault:prefix", "paas")
+	var b vaultBackend
+	key, err := b.Set("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.Equals, "paas/apps/myapp/DATABASE_PASSWORD")
+	c.Assert(string(s.data["/v1/kv/paas/apps/myapp/DATABASE_PASSWORD"]), check.Equals, `{"value":"s3cr3t"}`)
+	value, err := b.Get(key)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestInvalidKVVersion(c *check.C) {
+	config.Set("secrets:vault:kv-version", 3)
+	var b vaultBackend
+	_, err := b.Set("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.ErrorMatches, "invalid vault kv-version 3, expected 1 or 2")
+}
+
+func (s *S) TestErrorStatus(c *check.C) {
+	config.Set("secrets:vault:token", "wrong-token")
+	var b vaultBackend
+	_, err := b.Set("myapp", "DATABASE_PASSWORD", "s3cr3t")
+	c.Assert(err, check.ErrorMatches, `vault returned status 403: {"errors":\["permission denied"\]}`)
+}
+
+func (s *S) TestHealthCheck(c *check.C) {
+	c.Assert(healthCheck(), check.IsNil)
+	config.Set("secrets:vault:token", "wrong-token")
+	c.Assert(healthCheck(), check.ErrorMatches, "unexpected status - 403")
+	config.Set("secrets:backend", "file")
+	c.Assert(healthCheck(), check.Equals, hc.ErrDisabledComponent)
+}
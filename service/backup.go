@@ -0,0 +1,65 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Backup is a backup of a service instance, as reported by the service API.
+type Backup struct {
+	ID      string
+	Created time.Time
+	Status  string
+	Size    int64
+}
+
+// BackupPolicy defines when tsuru asks the service to back up an instance:
+// periodically, following the cron-like Schedule evaluated in UTC, and
+// before unbinding apps from the instance, when BeforeUnbind is set.
+type BackupPolicy struct {
+	Schedule     string `bson:",omitempty" json:",omitempty"`
+	BeforeUnbind bool   `bson:",omitempty" json:",omitempty"`
+}
+
+// Backup asks the service to back up the instance.
+func (si *ServiceInstance) Backup(requestID string) (*Backup, error) {
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return nil, err
+	}
+	return endpoint.CreateBackup(si, requestID)
+}
+
+// Backups returns the backups of the instance available in the service.
+func (si *ServiceInstance) Backups(requestID string) ([]Backup, error) {
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return nil, err
+	}
+	return endpoint.ListBackups(si, requestID)
+}
+
+// Restore asks the service to restore the instance from the given backup.
+func (si *ServiceInstance) Restore(backupID, requestID string) error {
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return err
+	}
+	return endpoint.RestoreBackup(si, backupID, requestID)
+}
+
+// SetBackupPolicy replaces the backup policy of the instance. The schedule
+// must be validated by the caller.
+func (si *ServiceInstance) SetBackupPolicy(policy BackupPolicy) error {
+	err := si.updateData(bson.M{"$set": bson.M{"backup_policy": policy}})
+	if err != nil {
+		return err
+	}
+	si.BackupPolicy = policy
+	return nil
+}
@@ -26,6 +26,8 @@ var (
 	ErrInstanceAlreadyExistsInAPI = errors.New("instance already exists in the service API")
 	ErrInstanceNotFoundInAPI      = errors.New("instance does not exist in the service API")
 	ErrInstanceNotReady           = errors.New("instance is not ready yet")
+	ErrBackupNotSupported         = errors.New("the service does not support backups")
+	ErrBackupNotFound             = errors.New("backup not found in the service API")
 
 	requestLatencies = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "tsuru_service_request_duration_seconds",
@@ -349,6 +351,90 @@ func (c *Client) Plans(requestID string) ([]Plan, error) {
 	return result, nil
 }
 
+// CreateBackup asks the service to back up the instance. Backups are
+// optional in the service API, ErrBackupNotSupported is returned when
+// the service doesn't implement them.
+// The api should be prepared to receive the request,
+// like below:
+// POST /resources/<name>/backups
+func (c *Client) CreateBackup(instance *ServiceInstance, requestID string) (*Backup, error) {
+	log.Debugf("Attempting to call backup of service instance %q at %q api", instance.Name, instance.ServiceName)
+	params := map[string][]string{
+		"requestID": {requestID},
+	}
+	resp, err := c.issueRequest("/resources/"+instance.GetIdentifier()+"/backups", "POST", params)
+	if err == nil {
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode < 300:
+			var backup Backup
+			err = c.jsonFromResponse(resp, &backup)
+			if err != nil {
+				return nil, err
+			}
+			return &backup, nil
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+			return nil, ErrBackupNotSupported
+		}
+	}
+	err = errors.Wrapf(c.buildErrorMessage(err, resp), "Failed to back up the instance %s", instance.Name)
+	return nil, log.WrapError(err)
+}
+
+// ListBackups returns the backups of the instance available in the service.
+// The api should be prepared to receive the request,
+// like below:
+// GET /resources/<name>/backups
+func (c *Client) ListBackups(instance *ServiceInstance, requestID string) ([]Backup, error) {
+	params := map[string][]string{
+		"requestID": {requestID},
+	}
+	resp, err := c.issueRequest("/resources/"+instance.GetIdentifier()+"/backups", "GET", params)
+	if err == nil {
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			backups := []Backup{}
+			err = c.jsonFromResponse(resp, &backups)
+			if err != nil {
+				return nil, err
+			}
+			return backups, nil
+		case resp.StatusCode == http.StatusNoContent:
+			return []Backup{}, nil
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+			return nil, ErrBackupNotSupported
+		}
+	}
+	err = errors.Wrapf(c.buildErrorMessage(err, resp), "Failed to list the backups of the instance %s", instance.Name)
+	return nil, log.WrapError(err)
+}
+
+// RestoreBackup asks the service to restore the instance from one of its
+// backups.
+// The api should be prepared to receive the request,
+// like below:
+// POST /resources/<name>/backups/<backup>/restore
+func (c *Client) RestoreBackup(instance *ServiceInstance, backupID, requestID string) error {
+	log.Debugf("Attempting to call restore of service instance %q from backup %q at %q api", instance.Name, backupID, instance.ServiceName)
+	params := map[string][]string{
+		"requestID": {requestID},
+	}
+	path := "/resources/" + instance.GetIdentifier() + "/backups/" + url.PathEscape(backupID) + "/restore"
+	resp, err := c.issueRequest(path, "POST", params)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrBackupNotFound
+		}
+	}
+	err = errors.Wrapf(c.buildErrorMessage(err, resp), "Failed to restore the instance %s from the backup %s", instance.Name, backupID)
+	return log.WrapError(err)
+}
+
 // Proxy is a proxy between tsuru and the service.
 // This method allow customized service methods.
 func (c *Client) Proxy(path string, w http.ResponseWriter, r *http.Request) error {
@@ -630,3 +630,106 @@ func (s *S) TestProxyWithBodyAndHeaders(c *check.C) {
 	c.Assert(proxiedRequest.Host, check.Equals, tsUrl.Host)
 	c.Assert(string(readBodyStr), check.Equals, `{"bla": "bla"}`)
 }
+
+func (s *S) TestCreateBackup(c *check.C) {
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ID": "bkp-1", "Status": "pending", "Size": 1024}`))
+	}))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	backup, err := client.CreateBackup(&instance, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(backup, check.DeepEquals, &Backup{ID: "bkp-1", Status: "pending", Size: 1024})
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.Path, check.Equals, "/resources/my-redis/backups")
+	c.Assert("Basic dXNlcjphYmNkZQ==", check.Equals, req.Header.Get("Authorization"))
+}
+
+func (s *S) TestCreateBackupNotSupported(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(notFoundHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	backup, err := client.CreateBackup(&instance, "")
+	c.Assert(err, check.Equals, ErrBackupNotSupported)
+	c.Assert(backup, check.IsNil)
+}
+
+func (s *S) TestCreateBackupFailure(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(failHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.CreateBackup(&instance, "")
+	c.Assert(err, check.ErrorMatches, "^Failed to back up the instance my-redis: invalid response: Server failed to do its job.$")
+}
+
+func (s *S) TestListBackups(c *check.C) {
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.Write([]byte(`[{"ID": "bkp-1", "Status": "done"}, {"ID": "bkp-2", "Status": "pending"}]`))
+	}))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	backups, err := client.ListBackups(&instance, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(backups, check.DeepEquals, []Backup{
+		{ID: "bkp-1", Status: "done"},
+		{ID: "bkp-2", Status: "pending"},
+	})
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/resources/my-redis/backups")
+}
+
+func (s *S) TestListBackupsEmpty(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	backups, err := client.ListBackups(&instance, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(backups, check.DeepEquals, []Backup{})
+}
+
+func (s *S) TestListBackupsNotSupported(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.ListBackups(&instance, "")
+	c.Assert(err, check.Equals, ErrBackupNotSupported)
+}
+
+func (s *S) TestRestoreBackup(c *check.C) {
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	err := client.RestoreBackup(&instance, "bkp-1", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.Path, check.Equals, "/resources/my-redis/backups/bkp-1/restore")
+}
+
+func (s *S) TestRestoreBackupNotFound(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(notFoundHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	err := client.RestoreBackup(&instance, "bkp-1", "")
+	c.Assert(err, check.Equals, ErrBackupNotFound)
+}
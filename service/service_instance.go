@@ -6,7 +6,9 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"regexp"
 	"strconv"
 	"strings"
@@ -36,16 +38,17 @@ var (
 )
 
 type ServiceInstance struct {
-	Name        string
-	Id          int
-	ServiceName string `bson:"service_name"`
-	PlanName    string `bson:"plan_name"`
-	Apps        []string
-	Units       []string
-	Teams       []string
-	TeamOwner   string
-	Description string
-	Tags        []string
+	Name         string
+	Id           int
+	ServiceName  string `bson:"service_name"`
+	PlanName     string `bson:"plan_name"`
+	Apps         []string
+	Units        []string
+	Teams        []string
+	TeamOwner    string
+	Description  string
+	Tags         []string
+	BackupPolicy BackupPolicy `bson:"backup_policy,omitempty"`
 }
 
 // DeleteInstance deletes the service instance from the database.
@@ -212,11 +215,23 @@ func (si *ServiceInstance) BindUnit(app bind.App, unit bind.Unit) error {
 	return nil
 }
 
-// UnbindApp makes the unbind between the service instance and an app.
+// UnbindApp makes the unbind between the service instance and an app. The
+// instance is backed up first when its backup policy requires it.
 func (si *ServiceInstance) UnbindApp(app bind.App, shouldRestart bool, writer io.Writer) error {
 	if si.FindApp(app.GetName()) == -1 {
 		return ErrAppNotBound
 	}
+	if si.BackupPolicy.BeforeUnbind {
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		fmt.Fprintf(writer, "---- Backing up service instance %q before unbinding ----\n", si.Name)
+		backup, err := si.Backup("")
+		if err != nil {
+			return errors.Wrapf(err, "unable to back up the instance %q before unbinding the app %q", si.Name, app.GetName())
+		}
+		fmt.Fprintf(writer, "Backup %q created.\n", backup.ID)
+	}
 	args := bindPipelineArgs{
 		serviceInstance: si,
 		app:             app,
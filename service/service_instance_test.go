@@ -966,3 +966,97 @@ func (s *InstanceSuite) TestUnbindAppMultipleApps(c *check.C) {
 	sort.Strings(siDB.Apps)
 	c.Assert(siDB.Apps, check.DeepEquals, []string{})
 }
+
+func (s *InstanceSuite) TestUnbindAppBackupBeforeUnbind(c *check.C) {
+	var reqs []*http.Request
+	var mut sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		reqs = append(reqs, r)
+		if r.URL.Path == "/resources/my-mysql/backups" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"ID": "bkp-1", "Status": "done"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	serv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := serv.Create()
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "static", 0)
+	si := ServiceInstance{
+		Name:         "my-mysql",
+		ServiceName:  "mysql",
+		Teams:        []string{s.team.Name},
+		Apps:         []string{a.GetName()},
+		BackupPolicy: BackupPolicy{BeforeUnbind: true},
+	}
+	err = si.Create()
+	c.Assert(err, check.IsNil)
+	err = a.AddInstance(bind.InstanceApp{
+		ServiceName: si.ServiceName,
+		Instance:    bind.ServiceInstance{Name: si.Name, Envs: map[string]string{"ENV1": "VAL1"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = si.UnbindApp(a, false, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "---- Backing up service instance \"my-mysql\" before unbinding ----\nBackup \"bkp-1\" created.\nremove instance")
+	c.Assert(len(reqs) > 1, check.Equals, true)
+	c.Assert(reqs[0].Method, check.Equals, "POST")
+	c.Assert(reqs[0].URL.Path, check.Equals, "/resources/my-mysql/backups")
+	c.Assert(reqs[len(reqs)-1].Method, check.Equals, "DELETE")
+	c.Assert(reqs[len(reqs)-1].URL.Path, check.Equals, "/resources/my-mysql/bind-app")
+}
+
+func (s *InstanceSuite) TestUnbindAppBackupBeforeUnbindFailure(c *check.C) {
+	var reqs []*http.Request
+	var mut sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		reqs = append(reqs, r)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("disk full"))
+	}))
+	defer ts.Close()
+	serv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := serv.Create()
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "static", 0)
+	si := ServiceInstance{
+		Name:         "my-mysql",
+		ServiceName:  "mysql",
+		Teams:        []string{s.team.Name},
+		Apps:         []string{a.GetName()},
+		BackupPolicy: BackupPolicy{BeforeUnbind: true},
+	}
+	err = si.Create()
+	c.Assert(err, check.IsNil)
+	err = si.UnbindApp(a, false, nil)
+	c.Assert(err, check.ErrorMatches, `unable to back up the instance "my-mysql" before unbinding the app "myapp": .*disk full`)
+	c.Assert(reqs, check.HasLen, 1)
+	siDB, err := GetServiceInstance("mysql", si.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(siDB.Apps, check.DeepEquals, []string{"myapp"})
+}
+
+func (s *InstanceSuite) TestSetBackupPolicy(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	err := si.Create()
+	c.Assert(err, check.IsNil)
+	policy := BackupPolicy{Schedule: "0 3 * * *", BeforeUnbind: true}
+	err = si.SetBackupPolicy(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(si.BackupPolicy, check.DeepEquals, policy)
+	siDB, err := GetServiceInstance("mysql", si.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(siDB.BackupPolicy, check.DeepEquals, policy)
+	err = si.SetBackupPolicy(BackupPolicy{})
+	c.Assert(err, check.IsNil)
+	siDB, err = GetServiceInstance("mysql", si.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(siDB.BackupPolicy, check.DeepEquals, BackupPolicy{})
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statuspage
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+var pageTemplate = template.Must(template.New("status-page").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format(time.RFC1123) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>tsuru status</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.2em 1em 0.2em 0; }
+.ok { color: #2e7d32; }
+.fail { color: #c62828; }
+</style>
+</head>
+<body>
+<h1>tsuru status</h1>
+{{with .API}}
+<h2>API</h2>
+<p class="{{if .Healthy}}ok{{else}}fail{{end}}">{{if .Healthy}}All systems operational{{else}}Some components are failing{{end}}</p>
+{{if .Checks}}<table>
+{{range .Checks}}<tr><td>{{.Name}}</td><td class="{{if .Healthy}}ok{{else}}fail{{end}}">{{if .Healthy}}OK{{else}}failing{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{end}}
+{{with .Maintenance}}
+<h2>Maintenance</h2>
+<ul>
+{{range .}}<li>{{if .Pool}}Pool {{.Pool}}{{else}}tsuru{{end}} is read-only since {{date .Since}}: {{.Reason}}</li>
+{{end}}</ul>
+{{end}}
+{{with .Incidents}}
+<h2>Incidents</h2>
+<ul>
+{{range .}}<li><strong>{{.Title}}</strong> started at {{date .Started}}{{with .Resolved}}, resolved at {{date .}}{{else}}, ongoing{{end}}{{with .Description}}<br>{{.}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+{{with .Pools}}
+<h2>Pools</h2>
+<table>
+<tr><th>Pool</th><th>Ready nodes</th><th>Units</th><th>Headroom</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.ReadyNodes}}/{{.Nodes}}</td><td>{{.Units}}</td><td>{{with .Capacity}}{{.Headroom}} units ({{printf "%.0f" .HeadroomPercent}}%){{else}}-{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+<p><small>Updated at {{date .Time}}</small></p>
+</body>
+</html>
+`))
+
+// WriteHTML renders the status page as a standalone HTML document.
+func WriteHTML(w io.Writer, page *Page) error {
+	return pageTemplate.Execute(w, page)
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statuspage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrIncidentNotFound = errors.New("incident not found")
+	ErrAlreadyFlagged   = errors.New("event is already flagged as an incident")
+	ErrAlreadyResolved  = errors.New("incident is already resolved")
+)
+
+// Incident is an event flagged by an operator to be displayed in the status
+// page. The incident is identified by the unique id of the flagged event and
+// Started is the start time of the event.
+type Incident struct {
+	ID          bson.ObjectId `bson:"_id"`
+	Title       string
+	Description string
+	Started     time.Time
+	FlaggedBy   string
+	FlaggedAt   time.Time
+	Resolved    *time.Time `bson:",omitempty"`
+}
+
+// FlagIncident flags the event with the given unique id as an incident. The
+// title defaults to the kind and target of the event.
+func FlagIncident(eventID bson.ObjectId, title, description, owner string) (*Incident, error) {
+	evt, err := event.GetByID(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if title == "" {
+		title = fmt.Sprintf("%s on %s", evt.Kind, evt.Target)
+	}
+	incident := Incident{
+		ID:          eventID,
+		Title:       title,
+		Description: description,
+		Started:     evt.StartTime,
+		FlaggedBy:   owner,
+		FlaggedAt:   time.Now().UTC(),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.StatusPageIncidents().Insert(incident)
+	if err != nil {
+		if mgo.IsDup(err) {
+			return nil, ErrAlreadyFlagged
+		}
+		return nil, err
+	}
+	cache.invalidate()
+	return &incident, nil
+}
+
+// ResolveIncident marks the incident as resolved. Resolved incidents are
+// displayed until status-page:incidents-period elapses.
+func ResolveIncident(id bson.ObjectId) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	err = conn.StatusPageIncidents().Update(
+		bson.M{"_id": id, "resolved": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"resolved": now}},
+	)
+	if err == mgo.ErrNotFound {
+		var n int
+		n, err = conn.StatusPageIncidents().FindId(id).Count()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrIncidentNotFound
+		}
+		return ErrAlreadyResolved
+	}
+	if err != nil {
+		return err
+	}
+	cache.invalidate()
+	return nil
+}
+
+// ListIncidents returns all flagged incidents, the most recent first.
+func ListIncidents() ([]Incident, error) {
+	return listIncidents(time.Time{})
+}
+
+// listIncidents returns the unresolved incidents and the ones resolved after
+// the given time.
+func listIncidents(resolvedAfter time.Time) ([]Incident, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{}
+	if !resolvedAfter.IsZero() {
+		query["$or"] = []bson.M{
+			{"resolved": bson.M{"$exists": false}},
+			{"resolved": bson.M{"$gte": resolvedAfter}},
+		}
+	}
+	incidents := []Incident{}
+	err = conn.StatusPageIncidents().Find(query).Sort("-started").All(&incidents)
+	if err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
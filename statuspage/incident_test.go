@@ -0,0 +1,79 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statuspage
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newEvent(c *check.C, app string) *event.Event {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: app},
+		InternalKind: "healer",
+		Allowed:      event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxApp, app)),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestFlagIncident(c *check.C) {
+	evt := s.newEvent(c, "myapp")
+	incident, err := FlagIncident(evt.UniqueID, "", "units restarting", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(incident.Title, check.Equals, "healer on app(myapp)")
+	c.Assert(incident.Description, check.Equals, "units restarting")
+	c.Assert(incident.FlaggedBy, check.Equals, "admin@example.com")
+	c.Assert(incident.Started.Equal(evt.StartTime), check.Equals, true)
+	incidents, err := ListIncidents()
+	c.Assert(err, check.IsNil)
+	c.Assert(incidents, check.HasLen, 1)
+	c.Assert(incidents[0].ID, check.Equals, evt.UniqueID)
+	_, err = FlagIncident(evt.UniqueID, "again", "", "admin@example.com")
+	c.Assert(err, check.Equals, ErrAlreadyFlagged)
+}
+
+func (s *S) TestFlagIncidentEventNotFound(c *check.C) {
+	_, err := FlagIncident(bson.NewObjectId(), "title", "", "admin@example.com")
+	c.Assert(err, check.Equals, event.ErrEventNotFound)
+}
+
+func (s *S) TestResolveIncident(c *check.C) {
+	incident, err := FlagIncident(s.newEvent(c, "myapp").UniqueID, "deploy failures", "", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = ResolveIncident(incident.ID)
+	c.Assert(err, check.IsNil)
+	incidents, err := ListIncidents()
+	c.Assert(err, check.IsNil)
+	c.Assert(incidents, check.HasLen, 1)
+	c.Assert(incidents[0].Resolved, check.NotNil)
+	err = ResolveIncident(incident.ID)
+	c.Assert(err, check.Equals, ErrAlreadyResolved)
+	err = ResolveIncident(bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrIncidentNotFound)
+}
+
+func (s *S) TestPageIncidentsPeriod(c *check.C) {
+	ongoing, err := FlagIncident(s.newEvent(c, "app1").UniqueID, "ongoing", "", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	old, err := FlagIncident(s.newEvent(c, "app2").UniqueID, "old", "", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	resolvedAt := time.Now().UTC().Add(-48 * time.Hour)
+	err = s.conn.StatusPageIncidents().UpdateId(old.ID, bson.M{"$set": bson.M{"resolved": resolvedAt}})
+	c.Assert(err, check.IsNil)
+	page, err := Generate(Settings{Incidents: true, IncidentsPeriod: time.Hour})
+	c.Assert(err, check.IsNil)
+	c.Assert(page.Incidents, check.HasLen, 1)
+	c.Assert(page.Incidents[0].Title, check.Equals, ongoing.Title)
+	page, err = Generate(Settings{Incidents: true, IncidentsPeriod: 72 * time.Hour})
+	c.Assert(err, check.IsNil)
+	c.Assert(page.Incidents, check.HasLen, 2)
+}
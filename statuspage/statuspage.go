@@ -0,0 +1,319 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package statuspage generates the public status page of tsuru, a summary of
+// the platform health that is served without authentication and suitable for
+// embedding in other pages. Operators choose the sections exposed in the
+// configuration file and flag events as incidents to be displayed.
+package statuspage
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/readonly"
+)
+
+const (
+	defaultCacheTTL        = 30 * time.Second
+	defaultIncidentsPeriod = 7 * 24 * time.Hour
+)
+
+var cache pageCache
+
+// Settings are the operator controls over the status page, read from the
+// status-page section of the configuration file.
+type Settings struct {
+	Enabled         bool
+	CacheTTL        time.Duration
+	API             bool
+	Pools           bool
+	Maintenance     bool
+	Incidents       bool
+	PoolNames       []string
+	UnitsPerNode    int
+	IncidentsPeriod time.Duration
+}
+
+// LoadSettings reads the status page settings. Every section is exposed by
+// default once the page is enabled.
+func LoadSettings() Settings {
+	s := Settings{
+		CacheTTL:        defaultCacheTTL,
+		API:             exposed("api"),
+		Pools:           exposed("pools"),
+		Maintenance:     exposed("maintenance"),
+		Incidents:       exposed("incidents"),
+		IncidentsPeriod: defaultIncidentsPeriod,
+	}
+	s.Enabled, _ = config.GetBool("status-page:enabled")
+	if seconds, _ := config.GetFloat("status-page:cache-ttl"); seconds > 0 {
+		s.CacheTTL = time.Duration(seconds * float64(time.Second))
+	}
+	if seconds, _ := config.GetFloat("status-page:incidents-period"); seconds > 0 {
+		s.IncidentsPeriod = time.Duration(seconds * float64(time.Second))
+	}
+	s.PoolNames, _ = config.GetList("status-page:pools")
+	s.UnitsPerNode, _ = config.GetInt("status-page:units-per-node")
+	if s.UnitsPerNode <= 0 {
+		s.UnitsPerNode, _ = config.GetInt("docker:auto-scale:max-container-count")
+	}
+	return s
+}
+
+func exposed(section string) bool {
+	value, err := config.GetBool("status-page:expose:" + section)
+	return err != nil || value
+}
+
+func (s *Settings) poolExposed(pool string) bool {
+	if len(s.PoolNames) == 0 {
+		return true
+	}
+	for _, name := range s.PoolNames {
+		if name == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// Page is the public summary of the platform health. Sections not exposed by
+// the settings are nil.
+type Page struct {
+	Time        time.Time
+	API         *APIHealth
+	Pools       []PoolCapacity
+	Maintenance []Maintenance
+	Incidents   []PageIncident
+}
+
+// APIHealth reports whether the components checked by the API healthcheck
+// are working, without the details of the failures.
+type APIHealth struct {
+	Healthy bool
+	Checks  []Check
+}
+
+type Check struct {
+	Name    string
+	Healthy bool
+}
+
+// PoolCapacity reports the nodes and units of a pool. Capacity is only
+// reported when the number of units per node is configured.
+type PoolCapacity struct {
+	Name       string
+	Nodes      int
+	ReadyNodes int
+	Units      int
+	Capacity   *Capacity `json:",omitempty"`
+}
+
+type Capacity struct {
+	Units           int
+	Headroom        int
+	HeadroomPercent float64
+}
+
+// Maintenance is an ongoing maintenance window, during which the whole API,
+// or the pool when set, is read-only.
+type Maintenance struct {
+	Pool   string `json:",omitempty"`
+	Reason string
+	Since  time.Time
+}
+
+// PageIncident is the public view of an incident flagged by operators.
+type PageIncident struct {
+	Title       string
+	Description string `json:",omitempty"`
+	Started     time.Time
+	Resolved    *time.Time `json:",omitempty"`
+}
+
+// Get returns the status page, regenerated at most once every
+// status-page:cache-ttl seconds. When it can't be generated the last
+// generated page is returned.
+func Get() (*Page, error) {
+	return cache.get(LoadSettings())
+}
+
+// Generate builds the status page with the sections exposed by the given
+// settings.
+func Generate(s Settings) (*Page, error) {
+	page := &Page{Time: time.Now().UTC()}
+	var err error
+	if s.API {
+		page.API = apiHealth()
+	}
+	if s.Pools {
+		page.Pools, err = poolsCapacity(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.Maintenance {
+		page.Maintenance, err = maintenanceWindows(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.Incidents {
+		page.Incidents, err = recentIncidents(page.Time.Add(-s.IncidentsPeriod))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func apiHealth() *APIHealth {
+	health := &APIHealth{Healthy: true, Checks: []Check{}}
+	for _, result := range hc.Check() {
+		check := Check{Name: result.Name, Healthy: result.Status == hc.HealthCheckOK}
+		if !check.Healthy {
+			health.Healthy = false
+		}
+		health.Checks = append(health.Checks, check)
+	}
+	return health
+}
+
+func poolsCapacity(s Settings) ([]PoolCapacity, error) {
+	pools, err := provision.ListPossiblePools(nil)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*PoolCapacity{}
+	result := make([]PoolCapacity, 0, len(pools))
+	for _, p := range pools {
+		if s.poolExposed(p.Name) {
+			result = append(result, PoolCapacity{Name: p.Name})
+		}
+	}
+	for i := range result {
+		byName[result[i].Name] = &result[i]
+	}
+	provs, err := provision.Registry()
+	if err != nil {
+		return nil, err
+	}
+	for _, prov := range provs {
+		nodeProv, ok := prov.(provision.NodeProvisioner)
+		if !ok {
+			continue
+		}
+		nodes, err := nodeProv.ListNodes(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			pool := byName[n.Pool()]
+			if pool == nil {
+				continue
+			}
+			pool.Nodes++
+			if !nodeReady(n) {
+				continue
+			}
+			pool.ReadyNodes++
+			units, err := n.Units()
+			if err != nil {
+				return nil, err
+			}
+			pool.Units += len(units)
+		}
+	}
+	if s.UnitsPerNode > 0 {
+		for i := range result {
+			pool := &result[i]
+			capacity := &Capacity{Units: pool.ReadyNodes * s.UnitsPerNode}
+			if capacity.Units > pool.Units {
+				capacity.Headroom = capacity.Units - pool.Units
+			}
+			if capacity.Units > 0 {
+				capacity.HeadroomPercent = float64(capacity.Headroom) * 100 / float64(capacity.Units)
+			}
+			pool.Capacity = capacity
+		}
+	}
+	return result, nil
+}
+
+// nodeReady reports whether the node accepts units. Provisioners report the
+// status with different cases, and the ones without node health checks
+// report enabled nodes.
+func nodeReady(n provision.Node) bool {
+	status := n.Status()
+	return strings.EqualFold(status, "ready") || status == "enabled"
+}
+
+func maintenanceWindows(s Settings) ([]Maintenance, error) {
+	statuses, err := readonly.List()
+	if err != nil {
+		return nil, err
+	}
+	result := []Maintenance{}
+	for _, status := range statuses {
+		if status.Pool != "" && !s.poolExposed(status.Pool) {
+			continue
+		}
+		result = append(result, Maintenance{Pool: status.Pool, Reason: status.Reason, Since: status.Since})
+	}
+	return result, nil
+}
+
+func recentIncidents(since time.Time) ([]PageIncident, error) {
+	incidents, err := listIncidents(since)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PageIncident, len(incidents))
+	for i, incident := range incidents {
+		result[i] = PageIncident{
+			Title:       incident.Title,
+			Description: incident.Description,
+			Started:     incident.Started,
+			Resolved:    incident.Resolved,
+		}
+	}
+	return result, nil
+}
+
+type pageCache struct {
+	mu      sync.Mutex
+	page    *Page
+	updated time.Time
+}
+
+func (c *pageCache) get(s Settings) (*Page, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.page != nil && time.Since(c.updated) < s.CacheTTL {
+		return c.page, nil
+	}
+	page, err := Generate(s)
+	if err != nil {
+		if c.page == nil {
+			return nil, err
+		}
+		log.Errorf("[status-page] unable to generate the status page, using the last generated one: %s", err)
+		return c.page, nil
+	}
+	c.page = page
+	c.updated = time.Now()
+	return c.page, nil
+}
+
+func (c *pageCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updated = time.Time{}
+}
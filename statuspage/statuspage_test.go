@@ -0,0 +1,133 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statuspage
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/readonly"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addNodes(c *check.C) {
+	for _, p := range []string{"pool1", "pool2"} {
+		err := provision.AddPool(provision.AddPoolOptions{Name: p, Public: true})
+		c.Assert(err, check.IsNil)
+	}
+	prov := provisiontest.ProvisionerInstance
+	for _, addr := range []string{"http://n1:2375", "http://n2:2375"} {
+		err := prov.AddNode(provision.AddNodeOptions{Address: addr, Metadata: map[string]string{"pool": "pool1"}})
+		c.Assert(err, check.IsNil)
+	}
+	err := prov.AddNode(provision.AddNodeOptions{Address: "http://n3:2375", Metadata: map[string]string{"pool": "pool2"}})
+	c.Assert(err, check.IsNil)
+	err = prov.UpdateNode(provision.UpdateNodeOptions{Address: "http://n2:2375", Disable: true})
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	err = prov.Provision(a)
+	c.Assert(err, check.IsNil)
+	_, err = prov.AddUnitsToNode(a, 3, "web", nil, "http://n1:2375")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestLoadSettingsDefaults(c *check.C) {
+	settings := LoadSettings()
+	c.Assert(settings, check.DeepEquals, Settings{
+		CacheTTL:        30 * time.Second,
+		API:             true,
+		Pools:           true,
+		Maintenance:     true,
+		Incidents:       true,
+		IncidentsPeriod: 7 * 24 * time.Hour,
+	})
+}
+
+func (s *S) TestLoadSettings(c *check.C) {
+	config.Set("status-page:enabled", true)
+	config.Set("status-page:cache-ttl", 60)
+	config.Set("status-page:incidents-period", 3600)
+	config.Set("status-page:expose:pools", false)
+	config.Set("status-page:pools", []interface{}{"pool1"})
+	config.Set("status-page:units-per-node", 10)
+	settings := LoadSettings()
+	c.Assert(settings, check.DeepEquals, Settings{
+		Enabled:         true,
+		CacheTTL:        time.Minute,
+		API:             true,
+		Maintenance:     true,
+		Incidents:       true,
+		PoolNames:       []string{"pool1"},
+		UnitsPerNode:    10,
+		IncidentsPeriod: time.Hour,
+	})
+}
+
+func (s *S) TestGenerate(c *check.C) {
+	s.addNodes(c)
+	s.hcFailed = true
+	err := readonly.Enable("pool2", "moving pool database", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer readonly.Disable("pool2")
+	page, err := Generate(Settings{API: true, Pools: true, Maintenance: true, Incidents: true, UnitsPerNode: 4})
+	c.Assert(err, check.IsNil)
+	c.Assert(page.API, check.DeepEquals, &APIHealth{Checks: []Check{{Name: "router"}}})
+	c.Assert(page.Pools, check.DeepEquals, []PoolCapacity{
+		{Name: "pool1", Nodes: 2, ReadyNodes: 1, Units: 3, Capacity: &Capacity{Units: 4, Headroom: 1, HeadroomPercent: 25}},
+		{Name: "pool2", Nodes: 1, ReadyNodes: 1, Capacity: &Capacity{Units: 4, Headroom: 4, HeadroomPercent: 100}},
+	})
+	c.Assert(page.Maintenance, check.HasLen, 1)
+	c.Assert(page.Maintenance[0].Pool, check.Equals, "pool2")
+	c.Assert(page.Maintenance[0].Reason, check.Equals, "moving pool database")
+	c.Assert(page.Incidents, check.DeepEquals, []PageIncident{})
+}
+
+func (s *S) TestGenerateRestrictedSections(c *check.C) {
+	s.addNodes(c)
+	err := readonly.Enable("pool2", "moving pool database", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer readonly.Disable("pool2")
+	page, err := Generate(Settings{Pools: true, Maintenance: true, PoolNames: []string{"pool1"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(page.API, check.IsNil)
+	c.Assert(page.Incidents, check.IsNil)
+	c.Assert(page.Pools, check.DeepEquals, []PoolCapacity{{Name: "pool1", Nodes: 2, ReadyNodes: 1, Units: 3}})
+	c.Assert(page.Maintenance, check.DeepEquals, []Maintenance{})
+}
+
+func (s *S) TestGetIsCached(c *check.C) {
+	config.Set("status-page:expose:pools", false)
+	page, err := Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(page.API.Healthy, check.Equals, true)
+	s.hcFailed = true
+	cached, err := Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(cached, check.Equals, page)
+	cache.invalidate()
+	page, err = Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(page.API.Healthy, check.Equals, false)
+}
+
+func (s *S) TestWriteHTML(c *check.C) {
+	resolved := time.Now().UTC()
+	page := &Page{
+		Time: time.Now().UTC(),
+		API:  &APIHealth{Healthy: true, Checks: []Check{{Name: "router", Healthy: true}}},
+		Incidents: []PageIncident{
+			{Title: "<b>deploy failures</b>", Started: resolved.Add(-time.Hour), Resolved: &resolved},
+		},
+	}
+	var buf bytes.Buffer
+	err := WriteHTML(&buf, page)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*All systems operational.*<td>router</td>.*`)
+	c.Assert(buf.String(), check.Matches, `(?s).*<strong>&lt;b&gt;deploy failures&lt;/b&gt;</strong>.*resolved at.*`)
+	c.Assert(buf.String(), check.Not(check.Matches), `(?s).*<h2>Pools</h2>.*`)
+}
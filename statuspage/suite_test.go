@@ -0,0 +1,58 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statuspage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+type S struct {
+	conn     *db.Storage
+	hcFailed bool
+}
+
+var _ = check.Suite(&S{})
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	err := config.ReadConfigFile("testdata/config.yaml")
+	c.Assert(err, check.IsNil)
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	hc.AddChecker("router", func() error {
+		if s.hcFailed {
+			return errors.New("router is down")
+		}
+		return nil
+	})
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	defer s.conn.Close()
+	s.conn.StatusPageIncidents().Database.DropDatabase()
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	err := dbtest.ClearAllCollections(s.conn.StatusPageIncidents().Database)
+	c.Assert(err, check.IsNil)
+	provisiontest.ProvisionerInstance.Reset()
+	s.hcFailed = false
+	cache = pageCache{}
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("status-page")
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storageusage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// AnomalousGrowthEventKind is the internal kind of the events recording
+	// anomalous growth of the events collection.
+	AnomalousGrowthEventKind = "storage-usage-anomalous-growth"
+
+	defaultAlertInterval = time.Hour
+	alertPeriod          = 24 * time.Hour
+)
+
+type ErrAnomalousGrowth struct {
+	Growth EventsGrowth
+}
+
+func (e *ErrAnomalousGrowth) Error() string {
+	return fmt.Sprintf("%d events started in the last day, the daily average of the previous %d days is %.1f",
+		e.Growth.LastDay, growthDays, e.Growth.DailyAverage)
+}
+
+// alerter periodically checks the growth of the events collection.
+type alerter struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// Initialize starts checking the growth of the events collection when
+// enabled by the database:usage:alerts:enabled setting. Anomalous growth is
+// recorded in a failed internal event, at most once a day, so admins can be
+// notified about it using event notification rules.
+func Initialize() error {
+	enabled, _ := config.GetBool("database:usage:alerts:enabled")
+	if !enabled {
+		return nil
+	}
+	a := &alerter{
+		interval: defaultAlertInterval,
+		done:     make(chan bool),
+	}
+	if seconds, _ := config.GetFloat("database:usage:alerts:interval"); seconds > 0 {
+		a.interval = time.Duration(seconds * float64(time.Second))
+	}
+	shutdown.Register(a)
+	go a.run()
+	return nil
+}
+
+func (a *alerter) run() {
+	for {
+		err := checkGrowth(time.Now().UTC())
+		if err != nil {
+			log.Errorf("[storage usage] unable to check events growth: %s", err)
+		}
+		select {
+		case <-a.done:
+			return
+		case <-time.After(a.interval):
+		}
+	}
+}
+
+func (a *alerter) Shutdown() {
+	a.done <- true
+}
+
+func (a *alerter) String() string {
+	return "storage usage alerts"
+}
+
+// checkGrowth records an anomalous growth event when the growth of the
+// events collection is anomalous and no other alert was recorded in the last
+// alertPeriod. The event locks the storage target, so concurrent checks
+// from other instances don't record duplicated alerts.
+func checkGrowth(now time.Time) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	growth, err := eventsGrowth(conn, now, 0)
+	if err != nil {
+		return err
+	}
+	if !growth.Anomalous {
+		return nil
+	}
+	count, err := conn.Events().Find(bson.M{
+		"kind.name": AnomalousGrowthEventKind,
+		"starttime": bson.M{"$gt": now.Add(-alertPeriod)},
+	}).Count()
+	if err != nil || count > 0 {
+		return err
+	}
+	_, dbName := db.DbConfig("")
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeStorage, Value: dbName},
+		InternalKind: AnomalousGrowthEventKind,
+		CustomData:   growth,
+		Allowed:      event.Allowed(permission.PermStorageUsageReadEvents),
+	})
+	if _, locked := err.(event.ErrEventLocked); locked {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	growthErr := &ErrAnomalousGrowth{Growth: growth}
+	log.Errorf("[storage usage] anomalous growth of the events collection: %s", growthErr)
+	return evt.Done(growthErr)
+}
@@ -0,0 +1,286 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package storageusage reports the storage used by tsuru in MongoDB: the size
+// of its collections, the growth rate of the events collection, the apps with
+// the biggest logs and when the disk of the database is projected to reach
+// the configured thresholds. Anomalous growth of the events collection may be
+// alerted with internal events, see Initialize.
+package storageusage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultAppsLimit     = 10
+	defaultGrowthFactor  = 3
+	growthDays           = 7
+	minAnomalousEvents   = 100
+	logsCollectionPrefix = "logs_"
+
+	// namespaceNotFoundCode is returned by collStats for the logs
+	// collections of apps that never logged.
+	namespaceNotFoundCode = 26
+)
+
+var defaultDiskThresholds = []float64{80, 90}
+
+// Report is the storage usage of tsuru at a given time.
+type Report struct {
+	Time        time.Time
+	Collections []CollectionUsage
+	Events      EventsGrowth
+	AppLogs     []AppLogUsage
+	Disk        *DiskUsage `json:",omitempty"`
+}
+
+// CollectionUsage is the size of a collection in the tsuru database, in
+// bytes. Size is the size of the documents, StorageSize the space allocated
+// to them in the disk.
+type CollectionUsage struct {
+	Name        string
+	Count       int64
+	Size        int64
+	StorageSize int64
+	IndexSize   int64
+}
+
+// EventsGrowth compares the number of events started in the last day with
+// the daily average of the previous week. BytesPerDay is the estimated growth
+// of the events collection, based on the average and the average size of
+// events. The growth is anomalous when the last day is greater than the
+// average multiplied by the database:usage:growth-factor setting.
+type EventsGrowth struct {
+	LastDay      int
+	DailyAverage float64
+	BytesPerDay  float64
+	Anomalous    bool
+}
+
+// AppLogUsage is the size of the logs stored for an app, in bytes.
+type AppLogUsage struct {
+	App         string
+	Count       int64
+	Size        int64
+	StorageSize int64
+}
+
+// DiskUsage is the usage of the disk of the database, in bytes, and the
+// projection of when each threshold is reached if the events collection keeps
+// growing at the current rate.
+type DiskUsage struct {
+	Used       int64
+	Total      int64
+	Thresholds []DiskThreshold
+}
+
+// DiskThreshold is a percentage of the disk usage. ProjectedAt is empty when
+// the threshold is already reached or the usage isn't growing.
+type DiskThreshold struct {
+	Percent     float64
+	Reached     bool
+	ProjectedAt *time.Time `json:",omitempty"`
+}
+
+type collStats struct {
+	Count          int64 `bson:"count"`
+	Size           int64 `bson:"size"`
+	StorageSize    int64 `bson:"storageSize"`
+	TotalIndexSize int64 `bson:"totalIndexSize"`
+	AvgObjSize     int64 `bson:"avgObjSize"`
+}
+
+type dbStats struct {
+	FsUsedSize  int64 `bson:"fsUsedSize"`
+	FsTotalSize int64 `bson:"fsTotalSize"`
+}
+
+// GetReport computes the current storage usage, listing up to appsLimit apps
+// in AppLogs. A non positive appsLimit lists the default number of apps.
+func GetReport(appsLimit int) (*Report, error) {
+	if appsLimit <= 0 {
+		appsLimit = defaultAppsLimit
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	report := Report{Time: now}
+	database := conn.Collection("events").Database
+	names, err := database.CollectionNames()
+	if err != nil {
+		return nil, err
+	}
+	var eventsStats collStats
+	for _, name := range names {
+		if strings.HasPrefix(name, "system.") {
+			continue
+		}
+		stats, err := getCollStats(database, name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "events" {
+			eventsStats = stats
+		}
+		report.Collections = append(report.Collections, CollectionUsage{
+			Name:        name,
+			Count:       stats.Count,
+			Size:        stats.Size,
+			StorageSize: stats.StorageSize,
+			IndexSize:   stats.TotalIndexSize,
+		})
+	}
+	sort.SliceStable(report.Collections, func(i, j int) bool {
+		return report.Collections[i].StorageSize > report.Collections[j].StorageSize
+	})
+	report.Events, err = eventsGrowth(conn, now, eventsStats.AvgObjSize)
+	if err != nil {
+		return nil, err
+	}
+	report.AppLogs, err = appLogs(appsLimit)
+	if err != nil {
+		return nil, err
+	}
+	var stats dbStats
+	err = database.Run(bson.D{{Name: "dbStats", Value: 1}}, &stats)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get database stats")
+	}
+	if stats.FsTotalSize > 0 {
+		report.Disk = &DiskUsage{
+			Used:       stats.FsUsedSize,
+			Total:      stats.FsTotalSize,
+			Thresholds: projectThresholds(stats.FsUsedSize, stats.FsTotalSize, report.Events.BytesPerDay, diskThresholds(), now),
+		}
+	}
+	return &report, nil
+}
+
+func getCollStats(database *mgo.Database, name string) (collStats, error) {
+	var stats collStats
+	err := database.Run(bson.D{{Name: "collStats", Value: name}}, &stats)
+	if err != nil {
+		return stats, errors.Wrapf(err, "unable to get stats of collection %q", name)
+	}
+	return stats, nil
+}
+
+// eventsGrowth counts the events started in each of the last growthDays+1
+// days before now. avgObjSize is the average size of an event, in bytes.
+func eventsGrowth(conn *db.Storage, now time.Time, avgObjSize int64) (EventsGrowth, error) {
+	var growth EventsGrowth
+	var previous int
+	for day := 0; day <= growthDays; day++ {
+		end := now.Add(-time.Duration(day) * 24 * time.Hour)
+		count, err := conn.Events().Find(bson.M{
+			"starttime": bson.M{"$gt": end.Add(-24 * time.Hour), "$lte": end},
+		}).Count()
+		if err != nil {
+			return growth, err
+		}
+		if day == 0 {
+			growth.LastDay = count
+		} else {
+			previous += count
+		}
+	}
+	growth.DailyAverage = float64(previous) / growthDays
+	growth.BytesPerDay = growth.DailyAverage * float64(avgObjSize)
+	growth.Anomalous = growth.LastDay >= minAnomalousEvents && float64(growth.LastDay) > growth.DailyAverage*growthFactor()
+	return growth, nil
+}
+
+func appLogs(limit int) ([]AppLogUsage, error) {
+	conn, err := db.LogConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	colls, err := conn.LogsCollections()
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]AppLogUsage, 0, len(colls))
+	for _, c := range colls {
+		stats, err := getCollStats(c.Database, c.Name)
+		if qErr, ok := errors.Cause(err).(*mgo.QueryError); ok && qErr.Code == namespaceNotFoundCode {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, AppLogUsage{
+			App:         strings.TrimPrefix(c.Name, logsCollectionPrefix),
+			Count:       stats.Count,
+			Size:        stats.Size,
+			StorageSize: stats.StorageSize,
+		})
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Size > logs[j].Size
+	})
+	if len(logs) > limit {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
+
+// projectThresholds returns, for each percentage of total, whether used
+// already reached it or when it's going to be reached growing bytesPerDay.
+func projectThresholds(used, total int64, bytesPerDay float64, percents []float64, now time.Time) []DiskThreshold {
+	thresholds := make([]DiskThreshold, len(percents))
+	for i, percent := range percents {
+		thresholds[i].Percent = percent
+		limit := float64(total) * percent / 100
+		if float64(used) >= limit {
+			thresholds[i].Reached = true
+			continue
+		}
+		if bytesPerDay <= 0 {
+			continue
+		}
+		days := (limit - float64(used)) / bytesPerDay
+		projected := now.Add(time.Duration(days * float64(24*time.Hour)))
+		thresholds[i].ProjectedAt = &projected
+	}
+	return thresholds
+}
+
+func diskThresholds() []float64 {
+	values, err := config.GetList("database:usage:disk-thresholds")
+	if err != nil || len(values) == 0 {
+		return defaultDiskThresholds
+	}
+	var thresholds []float64
+	for _, v := range values {
+		percent, err := strconv.ParseFloat(v, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			continue
+		}
+		thresholds = append(thresholds, percent)
+	}
+	sort.Float64s(thresholds)
+	return thresholds
+}
+
+func growthFactor() float64 {
+	factor, err := config.GetFloat("database:usage:growth-factor")
+	if err != nil || factor <= 0 {
+		return defaultGrowthFactor
+	}
+	return factor
+}
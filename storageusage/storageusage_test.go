@@ -0,0 +1,140 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storageusage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) insertEvents(c *check.C, start time.Time, n int) {
+	for i := 0; i < n; i++ {
+		err := s.conn.Events().Insert(bson.M{
+			"uniqueid":  bson.NewObjectId(),
+			"starttime": start.Add(time.Duration(i) * time.Second),
+			"kind":      bson.M{"type": "permission", "name": "app.deploy"},
+		})
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestGetReport(c *check.C) {
+	now := time.Now().UTC()
+	s.insertEvents(c, now.Add(-2*time.Hour), 3)
+	s.insertEvents(c, now.Add(-50*time.Hour), 7)
+	for _, name := range []string{"app1", "app2", "app3"} {
+		err := s.conn.Apps().Insert(bson.M{"name": name})
+		c.Assert(err, check.IsNil)
+	}
+	logConn, err := db.LogConn()
+	c.Assert(err, check.IsNil)
+	defer logConn.Close()
+	for i := 0; i < 5; i++ {
+		err = logConn.Logs("app2").Insert(bson.M{"message": strings.Repeat("x", 100)})
+		c.Assert(err, check.IsNil)
+	}
+	err = logConn.Logs("app1").Insert(bson.M{"message": "x"})
+	c.Assert(err, check.IsNil)
+	report, err := GetReport(2)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Time.After(now.Add(-time.Second)), check.Equals, true)
+	collections := map[string]CollectionUsage{}
+	for _, coll := range report.Collections {
+		collections[coll.Name] = coll
+	}
+	c.Assert(collections["events"].Count, check.Equals, int64(10))
+	c.Assert(collections["events"].Size > 0, check.Equals, true)
+	c.Assert(collections["apps"].Count, check.Equals, int64(3))
+	c.Assert(report.Events.LastDay, check.Equals, 3)
+	c.Assert(report.Events.DailyAverage, check.Equals, 1.0)
+	c.Assert(report.Events.BytesPerDay > 0, check.Equals, true)
+	c.Assert(report.Events.Anomalous, check.Equals, false)
+	c.Assert(report.AppLogs, check.HasLen, 2)
+	c.Assert(report.AppLogs[0].App, check.Equals, "app2")
+	c.Assert(report.AppLogs[0].Count, check.Equals, int64(5))
+	c.Assert(report.AppLogs[1].App, check.Equals, "app1")
+	c.Assert(report.AppLogs[1].Count, check.Equals, int64(1))
+}
+
+func (s *S) TestEventsGrowthAnomalous(c *check.C) {
+	now := time.Now().UTC()
+	s.insertEvents(c, now.Add(-time.Hour), minAnomalousEvents)
+	s.insertEvents(c, now.Add(-30*time.Hour), 70)
+	growth, err := eventsGrowth(s.conn, now, 100)
+	c.Assert(err, check.IsNil)
+	c.Assert(growth, check.DeepEquals, EventsGrowth{
+		LastDay:      minAnomalousEvents,
+		DailyAverage: 10,
+		BytesPerDay:  1000,
+		Anomalous:    true,
+	})
+	config.Set("database:usage:growth-factor", 20)
+	growth, err = eventsGrowth(s.conn, now, 100)
+	c.Assert(err, check.IsNil)
+	c.Assert(growth.Anomalous, check.Equals, false)
+}
+
+func (s *S) TestEventsGrowthIgnoresFewEvents(c *check.C) {
+	now := time.Now().UTC()
+	s.insertEvents(c, now.Add(-time.Hour), minAnomalousEvents-1)
+	growth, err := eventsGrowth(s.conn, now, 100)
+	c.Assert(err, check.IsNil)
+	c.Assert(growth.LastDay, check.Equals, minAnomalousEvents-1)
+	c.Assert(growth.DailyAverage, check.Equals, 0.0)
+	c.Assert(growth.Anomalous, check.Equals, false)
+}
+
+func (s *S) TestProjectThresholds(c *check.C) {
+	now := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	thresholds := projectThresholds(850, 1000, 10, []float64{80, 90, 95}, now)
+	projected90 := now.Add(5 * 24 * time.Hour)
+	projected95 := now.Add(10 * 24 * time.Hour)
+	c.Assert(thresholds, check.DeepEquals, []DiskThreshold{
+		{Percent: 80, Reached: true},
+		{Percent: 90, ProjectedAt: &projected90},
+		{Percent: 95, ProjectedAt: &projected95},
+	})
+	thresholds = projectThresholds(850, 1000, 0, []float64{90}, now)
+	c.Assert(thresholds, check.DeepEquals, []DiskThreshold{{Percent: 90}})
+}
+
+func (s *S) TestDiskThresholds(c *check.C) {
+	c.Assert(diskThresholds(), check.DeepEquals, []float64{80, 90})
+	config.Set("database:usage:disk-thresholds", []interface{}{95, "70", "invalid", 120})
+	c.Assert(diskThresholds(), check.DeepEquals, []float64{70, 95})
+}
+
+func (s *S) TestCheckGrowth(c *check.C) {
+	now := time.Now().UTC()
+	s.insertEvents(c, now.Add(-time.Hour), minAnomalousEvents)
+	err := checkGrowth(now)
+	c.Assert(err, check.IsNil)
+	var alerts []bson.M
+	err = s.conn.Events().Find(bson.M{"kind.name": AnomalousGrowthEventKind}).All(&alerts)
+	c.Assert(err, check.IsNil)
+	c.Assert(alerts, check.HasLen, 1)
+	c.Assert(alerts[0]["target"], check.DeepEquals, bson.M{"type": "storage", "value": "tsuru_storageusage_test"})
+	c.Assert(alerts[0]["error"], check.Equals, "100 events started in the last day, the daily average of the previous 7 days is 0.0")
+	err = checkGrowth(now)
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.Events().Find(bson.M{"kind.name": AnomalousGrowthEventKind}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
+
+func (s *S) TestCheckGrowthNotAnomalous(c *check.C) {
+	now := time.Now().UTC()
+	s.insertEvents(c, now.Add(-time.Hour), 10)
+	err := checkGrowth(now)
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.Events().Find(bson.M{"kind.name": AnomalousGrowthEventKind}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}